@@ -0,0 +1,1011 @@
+"""Admin API: GeoIP datasets, tenants and their keys, geofences, the RBAC
+policy, the audit log and profiles of the process.
+
+Tenant keys are API keys (exchanged for tokens) and request signing keys.
+
+Served under /admin/v1, apart from the data-plane API, and only to
+bearer tokens carrying the admin scope (403 otherwise). Disabled with
+ADMIN_API_ENABLED=false, e.g. on public-facing replicas when the admin
+API is served by an internal deployment. The profiling endpoints are
+only served with PROFILING_ENABLED=true as well.
+"""
+import asyncio
+import os
+import shutil
+import tempfile
+from datetime import datetime, timedelta
+from typing import Optional
+
+from fastapi import APIRouter, Depends, File, HTTPException, Query, Response, UploadFile, status
+from sqlalchemy.orm import Session
+
+from src.api.dependencies import require_admin
+from src.api.geofence_routes import geofence_response, get_geofence_or_404, request_geometry
+from src.api.poi_routes import AUTH_RESPONSES
+from src.api.uploads import save_upload
+from src.config import get_config
+from src.database import get_db_session
+from src.models.database_models import Tenant, TenantApiKey, TenantDataKey, TenantSigningKey
+from src.models.schemas import (
+    ApiKeyAllowedCidrsRequest,
+    ApiKeyInfo,
+    ApiKeyListResponse,
+    ApiKeyPrivacyRequest,
+    ApiKeyQuotaPlanRequest,
+    ApiKeyRotateRequest,
+    ApiKeyRotateResponse,
+    AllocationProfileResponse,
+    AllocationSiteInfo,
+    AuditLogEntryInfo,
+    AuditLogListResponse,
+    AuditLogVerifyResponse,
+    BillingExportResponse,
+    BillingRecordInfo,
+    BillingUsageResponse,
+    DataKeyInfo,
+    DataKeyListResponse,
+    DataKeyReencryptResponse,
+    DataKeyRewrapResponse,
+    DatasetUploadResponse,
+    ErrorResponse,
+    GeofenceCreate,
+    GeofenceListResponse,
+    GeofenceResponse,
+    GeoIPDatasetInfo,
+    QuotaPlanInfo,
+    QuotaPlanListResponse,
+    RbacPolicyResponse,
+    RbacRuleInfo,
+    SigningKeyCreateRequest,
+    SigningKeyInfo,
+    SigningKeyIssuedResponse,
+    SigningKeyListResponse,
+    TenantCreate,
+    TenantListResponse,
+    TenantResponse,
+)
+from src.services.audit_log_service import AuditLogService
+from src.services.auth_service import get_token_verifier
+from src.services.billing_service import BillingService, get_billing_exporter, to_csv
+from src.services.geofence_service import GeofenceService
+from src.services.geoip_update_service import GeoIPUpdateService, LocalFileSource, invalidate_cached_lookups
+from src.services.ip_allowlist_service import stored_cidrs
+from src.services.location_encryption_service import LocationCipher, location_cipher
+from src.services.mmdb_service import get_mmdb_reader
+from src.services.profiling_service import ProfilerBusyError, get_profiler, thread_dump
+from src.services.quota_service import get_quota_enforcer
+from src.services.rbac_service import PolicyStore, get_policy_store
+from src.services.refresh_token_service import RefreshTokenService
+from src.services.request_signing_service import RequestSigningService, signing_key_active
+from src.services.snapshot_service import get_snapshot_store
+from src.services.tenant_service import TenantService, key_active, rotation_due
+
+router = APIRouter(prefix="/admin/v1", tags=["admin"], dependencies=[Depends(require_admin)])
+profiling_router = APIRouter(
+    prefix="/admin/v1/debug/profile", tags=["admin"], dependencies=[Depends(require_admin)]
+)
+
+ADMIN_RESPONSES = {
+    **AUTH_RESPONSES,
+    403: {"model": ErrorResponse, "description": "Token lacks the admin scope"},
+}
+
+
+def _bad_request(e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_400_BAD_REQUEST,
+        detail={"error_code": "E002", "error_message": str(e), "details": None},
+    )
+
+
+def _not_found(e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_404_NOT_FOUND,
+        detail={"error_code": "E004", "error_message": str(e), "details": None},
+    )
+
+
+def _unavailable(e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+        detail={"error_code": "E003", "error_message": str(e), "details": None},
+    )
+
+
+def _tenant_service(session: Session) -> TenantService:
+    """Tenant service issuing keys with the configured rotation schedule."""
+    config = get_config()
+    max_age = config.tenant_key_max_age_days
+    return TenantService(
+        session,
+        key_max_age=timedelta(days=max_age) if max_age > 0 else None,
+        key_grace=timedelta(seconds=config.tenant_key_rotation_grace_seconds),
+        default_scopes=config.tenant_key_default_scopes,
+    )
+
+
+def _key_info(key: TenantApiKey) -> ApiKeyInfo:
+    return ApiKeyInfo(
+        key_id=key.key_id,
+        tenant_id=key.tenant_id,
+        prefix=key.prefix,
+        scopes=key.scopes.split(),
+        allowed_cidrs=list(stored_cidrs(key.allowed_cidrs)),
+        privacy_precision=key.privacy_precision,
+        quota_plan=key.quota_plan,
+        created_at=key.created_at,
+        rotate_after=key.rotate_after,
+        expires_at=key.expires_at,
+        revoked_at=key.revoked_at,
+        last_used_at=key.last_used_at,
+        active=key_active(key),
+        rotation_due=rotation_due(key),
+    )
+
+
+def _signing_key_info(key: TenantSigningKey) -> SigningKeyInfo:
+    return SigningKeyInfo(
+        key_id=key.key_id,
+        tenant_id=key.tenant_id,
+        created_at=key.created_at,
+        expires_at=key.expires_at,
+        revoked_at=key.revoked_at,
+        active=signing_key_active(key),
+    )
+
+
+def _data_key_info(key: TenantDataKey, rows: int) -> DataKeyInfo:
+    return DataKeyInfo(
+        key_id=key.key_id,
+        tenant_id=key.tenant_id,
+        master_key_id=key.master_key_id,
+        created_at=key.created_at,
+        retired_at=key.retired_at,
+        active=key.retired_at is None,
+        rows=rows,
+    )
+
+
+def _location_cipher(session: Session) -> LocationCipher:
+    """Configured location cipher.
+
+    Raises:
+        HTTPException: 503 if location encryption is off or misconfigured
+    """
+    try:
+        cipher = location_cipher(session)
+    except RuntimeError as e:
+        raise _unavailable(e)
+    if cipher is None:
+        raise _unavailable(RuntimeError("Location encryption is not configured"))
+    return cipher
+
+
+def _tenant_response(service: TenantService, tenant: Tenant, api_key: Optional[str] = None) -> TenantResponse:
+    return TenantResponse(
+        tenant_id=tenant.tenant_id,
+        name=tenant.name,
+        created_at=tenant.created_at,
+        api_keys=[_key_info(k) for k in service.list_keys(tenant.tenant_id)],
+        api_key=api_key,
+    )
+
+
+def _dataset_updater(path: str, version: Optional[str]) -> GeoIPUpdateService:
+    """Updater installing a local file as the active dataset (the old one is snapshotted)."""
+    config = get_config()
+    updater = GeoIPUpdateService(
+        LocalFileSource(path, version),
+        config.geoip_database_path,
+        snapshots=get_snapshot_store(),
+    )
+    updater.register_activation_callback(invalidate_cached_lookups)
+    return updater
+
+
+def _dataset_info(updater: GeoIPUpdateService) -> GeoIPDatasetInfo:
+    reader = get_mmdb_reader()
+    return GeoIPDatasetInfo(
+        database_path=updater.database_path,
+        database_type=(reader.database_type or None) if reader else None,
+        build_epoch=reader.build_epoch if reader else None,
+        ip_version=reader.ip_version if reader else None,
+        sha256=updater.installed_checksum,
+        loaded=reader is not None,
+    )
+
+
+@router.get("/datasets/geoip", response_model=GeoIPDatasetInfo, responses=ADMIN_RESPONSES)
+async def get_geoip_dataset():
+    """The active GeoIP dataset."""
+    return _dataset_info(_dataset_updater(get_config().geoip_database_path, None))
+
+
+@router.post(
+    "/datasets/geoip",
+    response_model=DatasetUploadResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Not a valid MMDB file or archive"},
+        413: {"model": ErrorResponse, "description": "Upload larger than ADMIN_DATASET_MAX_BYTES"},
+        **ADMIN_RESPONSES,
+    },
+)
+async def upload_geoip_dataset(
+    file: UploadFile = File(..., description="GeoIP2/GeoLite2 .mmdb file, or a MaxMind tar.gz archive"),
+):
+    """Install an uploaded GeoIP dataset and hot swap it in.
+
+    The upload is validated like a downloaded release (it must open as an
+    MMDB) before it atomically replaces the active file; the outgoing
+    build is kept in the snapshot store. Uploading the dataset already
+    installed changes nothing. With GEOIP_UPDATE_ENABLED, the background
+    updater installs its source's release over an upload at its next check.
+
+    Raises:
+        HTTPException: 400 for an invalid dataset, 413 for an upload over
+            ADMIN_DATASET_MAX_BYTES
+    """
+    config = get_config()
+    target_dir = os.path.dirname(os.path.abspath(config.geoip_database_path))
+    os.makedirs(target_dir, exist_ok=True)
+    staging_dir = tempfile.mkdtemp(prefix=".geoip-upload-", dir=target_dir)
+    try:
+        path = os.path.join(staging_dir, "upload")
+        await asyncio.to_thread(save_upload, file, path, config.admin_dataset_max_bytes)
+        updater = _dataset_updater(path, file.filename)
+        try:
+            updated = await updater.check_for_update()
+        except (RuntimeError, ValueError, OSError) as e:
+            raise _bad_request(ValueError(f"Dataset rejected: {e}"))
+    finally:
+        shutil.rmtree(staging_dir, ignore_errors=True)
+    return DatasetUploadResponse(updated=updated, dataset=_dataset_info(updater))
+
+
+@router.post(
+    "/tenants",
+    response_model=TenantResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={400: {"model": ErrorResponse, "description": "Invalid or existing tenant ID"}, **ADMIN_RESPONSES},
+)
+async def create_tenant(request: TenantCreate, session: Session = Depends(get_db_session)):
+    """Create a tenant; the response carries its first API key, which is not shown again."""
+    service = _tenant_service(session)
+    try:
+        tenant, issued = service.create_tenant(
+            request.tenant_id,
+            request.name,
+            request.scopes,
+            request.allowed_cidrs or (),
+            request.privacy_precision,
+            request.quota_plan,
+        )
+    except ValueError as e:
+        raise _bad_request(e)
+    return _tenant_response(service, tenant, issued.api_key)
+
+
+@router.get("/tenants", response_model=TenantListResponse, responses=ADMIN_RESPONSES)
+async def list_tenants(session: Session = Depends(get_db_session)):
+    """Every tenant with its API keys, oldest first."""
+    service = _tenant_service(session)
+    return TenantListResponse(tenants=[_tenant_response(service, t) for t in service.list_tenants()])
+
+
+@router.get(
+    "/tenants/{tenant_id}",
+    response_model=TenantResponse,
+    responses={404: {"model": ErrorResponse, "description": "Tenant not found"}, **ADMIN_RESPONSES},
+)
+async def get_tenant(tenant_id: str, session: Session = Depends(get_db_session)):
+    """A tenant with its API keys."""
+    service = _tenant_service(session)
+    tenant = service.get_tenant(tenant_id)
+    if tenant is None:
+        raise _not_found(LookupError(f"Tenant {tenant_id} not found"))
+    return _tenant_response(service, tenant)
+
+
+@router.post(
+    "/tenants/{tenant_id}/keys/rotate",
+    response_model=ApiKeyRotateResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={404: {"model": ErrorResponse, "description": "Tenant not found"}, **ADMIN_RESPONSES},
+)
+async def rotate_tenant_key(
+    tenant_id: str,
+    request: Optional[ApiKeyRotateRequest] = None,
+    session: Session = Depends(get_db_session),
+):
+    """Issue a new API key for a tenant and retire its current ones.
+
+    Previous keys keep working for grace_seconds (default
+    TENANT_KEY_ROTATION_GRACE_SECONDS); 0 revokes them at once, e.g. for a
+    leaked key. The new key keeps the scopes and allowed networks of the
+    newest key unless `scopes` or `allowed_cidrs` is given, and its
+    privacy mode and quota plan. It is only shown in this response.
+
+    Raises:
+        HTTPException: 404 if the tenant does not exist
+    """
+    grace_seconds = request.grace_seconds if request else None
+    if grace_seconds is None:
+        grace_seconds = get_config().tenant_key_rotation_grace_seconds
+    service = _tenant_service(session)
+    try:
+        issued = service.rotate_key(
+            tenant_id,
+            grace_seconds,
+            request.scopes if request else None,
+            request.allowed_cidrs if request else None,
+        )
+    except LookupError as e:
+        raise _not_found(e)
+    except ValueError as e:
+        raise _bad_request(e)
+    return ApiKeyRotateResponse(
+        tenant_id=tenant_id,
+        key=_key_info(issued.key),
+        api_key=issued.api_key,
+        api_keys=[_key_info(k) for k in service.list_keys(tenant_id)],
+    )
+
+
+@router.put(
+    "/tenants/{tenant_id}/keys/{key_id}/allowed-cidrs",
+    response_model=ApiKeyInfo,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid network"},
+        404: {"model": ErrorResponse, "description": "API key not found"},
+        **ADMIN_RESPONSES,
+    },
+)
+async def set_tenant_key_allowed_cidrs(
+    tenant_id: str, key_id: str, request: ApiKeyAllowedCidrsRequest, session: Session = Depends(get_db_session)
+):
+    """Restrict an API key to source networks (an empty list lifts the restriction).
+
+    Outside them the key is not exchanged for tokens, its refresh tokens
+    are refused, and requests made with its access tokens get 403 (E011).
+    Access tokens issued before the change keep the previous list until
+    they are refreshed.
+
+    Raises:
+        HTTPException: 400 for an invalid network, 404 if the tenant has no such key
+    """
+    try:
+        key = _tenant_service(session).set_allowed_cidrs(tenant_id, key_id, request.allowed_cidrs)
+    except LookupError as e:
+        raise _not_found(e)
+    except ValueError as e:
+        raise _bad_request(e)
+    return _key_info(key)
+
+
+@router.put(
+    "/tenants/{tenant_id}/keys/{key_id}/privacy",
+    response_model=ApiKeyInfo,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid precision"},
+        404: {"model": ErrorResponse, "description": "API key not found"},
+        **ADMIN_RESPONSES,
+    },
+)
+async def set_tenant_key_privacy(
+    tenant_id: str, key_id: str, request: ApiKeyPrivacyRequest, session: Session = Depends(get_db_session)
+):
+    """Put an API key in privacy mode (a null precision takes it out).
+
+    Requests made with its access tokens get coordinates truncated to the
+    precision and no street-level data, and their IP addresses are kept
+    out of the logs. Access tokens issued before the change keep the
+    previous setting until they are refreshed.
+
+    Raises:
+        HTTPException: 400 for an invalid precision, 404 if the tenant has no such key
+    """
+    try:
+        key = _tenant_service(session).set_privacy(tenant_id, key_id, request.precision)
+    except LookupError as e:
+        raise _not_found(e)
+    except ValueError as e:
+        raise _bad_request(e)
+    return _key_info(key)
+
+
+@router.put(
+    "/tenants/{tenant_id}/keys/{key_id}/plan",
+    response_model=ApiKeyInfo,
+    responses={
+        400: {"model": ErrorResponse, "description": "Unknown plan"},
+        404: {"model": ErrorResponse, "description": "API key not found"},
+        **ADMIN_RESPONSES,
+    },
+)
+async def set_tenant_key_plan(
+    tenant_id: str, key_id: str, request: ApiKeyQuotaPlanRequest, session: Session = Depends(get_db_session)
+):
+    """Put an API key on a quota plan (a null plan stops metering it).
+
+    Requests made with its access tokens count against the plan's monthly
+    caps; past them they get 429 (E015) until the month ends. Access
+    tokens issued before the change keep the previous plan until they are
+    refreshed.
+
+    Raises:
+        HTTPException: 400 for an unknown plan, 404 if the tenant has no such key
+    """
+    try:
+        key = _tenant_service(session).set_quota_plan(tenant_id, key_id, request.plan)
+    except LookupError as e:
+        raise _not_found(e)
+    except ValueError as e:
+        raise _bad_request(e)
+    return _key_info(key)
+
+
+@router.get("/quota-plans", response_model=QuotaPlanListResponse, responses=ADMIN_RESPONSES)
+async def list_quota_plans():
+    """Quota plans API keys can be put on (built-in ones changed by QUOTA_PLANS)."""
+    plans = get_quota_enforcer().plans
+    return QuotaPlanListResponse(plans=[QuotaPlanInfo(**plans[name].to_dict()) for name in sorted(plans)])
+
+
+@router.delete(
+    "/tenants/{tenant_id}/keys/{key_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    responses={404: {"model": ErrorResponse, "description": "API key not found"}, **ADMIN_RESPONSES},
+)
+async def revoke_tenant_key(tenant_id: str, key_id: str, session: Session = Depends(get_db_session)):
+    """Revoke an API key at once, e.g. after a leak.
+
+    The key stops being exchanged for tokens and the sessions started
+    from it end: their refresh tokens are refused and, through the token
+    denylist, so are their access tokens. The tenant's other keys keep
+    working; rotate first if the tenant needs a replacement.
+
+    Raises:
+        HTTPException: 404 if the tenant has no such key
+    """
+    try:
+        _tenant_service(session).revoke_key(tenant_id, key_id)
+        RefreshTokenService(session, get_token_verifier()).revoke_api_key(key_id)
+    except LookupError as e:
+        raise _not_found(e)
+    except ValueError as e:
+        raise _bad_request(e)
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={"error_code": "E003", "error_message": str(e), "details": None},
+        )
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.post(
+    "/tenants/{tenant_id}/signing-keys",
+    response_model=SigningKeyIssuedResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={404: {"model": ErrorResponse, "description": "Tenant not found"}, **ADMIN_RESPONSES},
+)
+async def create_signing_key(
+    tenant_id: str,
+    request: Optional[SigningKeyCreateRequest] = None,
+    session: Session = Depends(get_db_session),
+):
+    """Issue a request signing key for a tenant, or rotate it.
+
+    From now on the tokens issued to the tenant only work on requests
+    signed with one of its keys (X-Request-Signature). Previous keys keep
+    working for grace_seconds (default TENANT_KEY_ROTATION_GRACE_SECONDS).
+    The secret is only shown in this response.
+
+    Raises:
+        HTTPException: 404 if the tenant does not exist
+    """
+    grace_seconds = request.grace_seconds if request else None
+    if grace_seconds is None:
+        grace_seconds = get_config().tenant_key_rotation_grace_seconds
+    service = RequestSigningService(session)
+    try:
+        key = service.create_key(tenant_id, grace_seconds)
+    except LookupError as e:
+        raise _not_found(e)
+    except ValueError as e:
+        raise _bad_request(e)
+    return SigningKeyIssuedResponse(
+        tenant_id=tenant_id,
+        key=_signing_key_info(key),
+        secret=key.secret,
+        signing_keys=[_signing_key_info(k) for k in service.list_keys(tenant_id)],
+    )
+
+
+@router.get(
+    "/tenants/{tenant_id}/signing-keys",
+    response_model=SigningKeyListResponse,
+    responses={404: {"model": ErrorResponse, "description": "Tenant not found"}, **ADMIN_RESPONSES},
+)
+async def list_signing_keys(tenant_id: str, session: Session = Depends(get_db_session)):
+    """Request signing keys of a tenant, and whether its new tokens require signing."""
+    if _tenant_service(session).get_tenant(tenant_id) is None:
+        raise _not_found(LookupError(f"Tenant {tenant_id} not found"))
+    keys = RequestSigningService(session).list_keys(tenant_id)
+    return SigningKeyListResponse(
+        tenant_id=tenant_id,
+        signing_required=any(signing_key_active(k) for k in keys),
+        signing_keys=[_signing_key_info(k) for k in keys],
+    )
+
+
+@router.delete(
+    "/tenants/{tenant_id}/signing-keys/{key_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    responses={404: {"model": ErrorResponse, "description": "Signing key not found"}, **ADMIN_RESPONSES},
+)
+async def revoke_signing_key(tenant_id: str, key_id: str, session: Session = Depends(get_db_session)):
+    """Revoke a signing key at once; requests signed with it get 401.
+
+    Revoking the tenant's last key makes signing optional for the tokens
+    issued afterwards.
+
+    Raises:
+        HTTPException: 404 if the tenant has no such key
+    """
+    try:
+        RequestSigningService(session).revoke_key(tenant_id, key_id)
+    except LookupError as e:
+        raise _not_found(e)
+    except ValueError as e:
+        raise _bad_request(e)
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+DATA_KEY_RESPONSES = {
+    404: {"model": ErrorResponse, "description": "Tenant not found"},
+    503: {"model": ErrorResponse, "description": "Location encryption not configured or master key unavailable"},
+    **ADMIN_RESPONSES,
+}
+
+
+@router.get("/tenants/{tenant_id}/data-keys", response_model=DataKeyListResponse, responses=DATA_KEY_RESPONSES)
+async def list_data_keys(tenant_id: str, session: Session = Depends(get_db_session)):
+    """Location data keys of a tenant, with the sightings each seals."""
+    if _tenant_service(session).get_tenant(tenant_id) is None:
+        raise _not_found(LookupError(f"Tenant {tenant_id} not found"))
+    try:
+        cipher = location_cipher(session)
+    except RuntimeError as e:
+        raise _unavailable(e)
+    keys = cipher.list_keys(tenant_id) if cipher is not None else []
+    return DataKeyListResponse(
+        tenant_id=tenant_id,
+        encryption_enabled=cipher is not None,
+        data_keys=[_data_key_info(key, rows) for key, rows in keys],
+    )
+
+
+@router.post(
+    "/tenants/{tenant_id}/data-keys/rotate",
+    response_model=DataKeyListResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses=DATA_KEY_RESPONSES,
+)
+async def rotate_data_key(tenant_id: str, session: Session = Depends(get_db_session)):
+    """Retire the tenant's location data key and create a new one.
+
+    New sightings are sealed with the new key. Those sealed with retired
+    keys stay readable until moved with POST .../data-keys/reencrypt.
+
+    Raises:
+        HTTPException: 404 if the tenant does not exist, 503 if the master
+            key is unavailable
+    """
+    if _tenant_service(session).get_tenant(tenant_id) is None:
+        raise _not_found(LookupError(f"Tenant {tenant_id} not found"))
+    cipher = _location_cipher(session)
+    try:
+        cipher.rotate_data_key(tenant_id)
+    except RuntimeError as e:
+        raise _unavailable(e)
+    return DataKeyListResponse(
+        tenant_id=tenant_id,
+        encryption_enabled=True,
+        data_keys=[_data_key_info(key, rows) for key, rows in cipher.list_keys(tenant_id)],
+    )
+
+
+@router.post(
+    "/tenants/{tenant_id}/data-keys/reencrypt",
+    response_model=DataKeyReencryptResponse,
+    responses=DATA_KEY_RESPONSES,
+)
+async def reencrypt_location_history(
+    tenant_id: str,
+    limit: int = Query(1000, ge=1, le=10000, description="Most sightings re-encrypted in this call"),
+    session: Session = Depends(get_db_session),
+):
+    """Seal a batch of the tenant's sightings with its active data key.
+
+    Moves sightings off retired keys and seals those stored before
+    encryption was enabled; retired keys left unused are deleted. Call
+    again until `remaining` is 0.
+
+    Raises:
+        HTTPException: 404 if the tenant does not exist, 503 if a key is
+            unavailable
+    """
+    if _tenant_service(session).get_tenant(tenant_id) is None:
+        raise _not_found(LookupError(f"Tenant {tenant_id} not found"))
+    cipher = _location_cipher(session)
+    try:
+        result = cipher.reencrypt(tenant_id, limit)
+    except RuntimeError as e:
+        raise _unavailable(e)
+    return DataKeyReencryptResponse(tenant_id=tenant_id, **result.to_dict())
+
+
+@router.post(
+    "/data-keys/rewrap",
+    response_model=DataKeyRewrapResponse,
+    responses={
+        503: {"model": ErrorResponse, "description": "Location encryption not configured or master key unavailable"},
+        **ADMIN_RESPONSES,
+    },
+)
+async def rewrap_data_keys(session: Session = Depends(get_db_session)):
+    """Wrap every tenant's data keys with the current master key.
+
+    Run after changing the master key; the previous one
+    (LOCATION_ENCRYPTION_PREVIOUS_MASTER_KEY, or the old KMS key) must
+    still be usable. Sightings are not touched.
+
+    Raises:
+        HTTPException: 503 if a data key cannot be unwrapped or re-wrapped
+    """
+    cipher = _location_cipher(session)
+    try:
+        rewrapped = cipher.rewrap_data_keys()
+    except RuntimeError as e:
+        raise _unavailable(e)
+    return DataKeyRewrapResponse(master_key_id=cipher.master_key.key_id, rewrapped=rewrapped)
+
+
+@router.get(
+    "/api-keys",
+    response_model=ApiKeyListResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid key prefix"}, **ADMIN_RESPONSES},
+)
+async def list_api_keys(
+    prefix: Optional[str] = Query(
+        None, description="Leading characters of a key (gek_...), e.g. from a leaked-credential report"
+    ),
+    rotation_due: bool = Query(False, description="Only active keys past their scheduled rotation"),
+    limit: int = Query(100, ge=1, le=1000, description="Most keys returned"),
+    session: Session = Depends(get_db_session),
+):
+    """API keys of every tenant, oldest first, with when each was last used."""
+    try:
+        keys = _tenant_service(session).find_keys(prefix, due_only=rotation_due, limit=limit)
+    except ValueError as e:
+        raise _bad_request(e)
+    return ApiKeyListResponse(api_keys=[_key_info(k) for k in keys])
+
+
+def _policy_response(store: PolicyStore) -> RbacPolicyResponse:
+    policy = store.policy
+    return RbacPolicyResponse(
+        enabled=bool(store.path),
+        rules=[RbacRuleInfo(**rule.to_dict()) for rule in policy.rules] if policy else [],
+        default_scopes=list(policy.default_scopes) if policy and policy.default_scopes is not None else None,
+        version=store.version,
+        loaded_at=store.loaded_at,
+        last_error=store.last_error,
+    )
+
+
+@router.get("/rbac/policy", response_model=RbacPolicyResponse, responses=ADMIN_RESPONSES)
+async def get_rbac_policy():
+    """Active RBAC policy, and why the latest file change was rejected if it was."""
+    return _policy_response(get_policy_store())
+
+
+@router.post(
+    "/rbac/policy/reload",
+    response_model=RbacPolicyResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Policy file rejected; previous policy stays active"},
+        503: {"model": ErrorResponse, "description": "RBAC_POLICY_PATH not set"},
+        **ADMIN_RESPONSES,
+    },
+)
+async def reload_rbac_policy():
+    """Re-read the RBAC policy file now instead of waiting for the change check.
+
+    Raises:
+        HTTPException: 400 if the file is invalid, 503 if RBAC_POLICY_PATH is not set
+    """
+    store = get_policy_store()
+    if not store.path:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={"error_code": "E003", "error_message": "RBAC_POLICY_PATH is not set", "details": None},
+        )
+    if not store.reload():
+        raise _bad_request(ValueError(store.last_error))
+    return _policy_response(store)
+
+
+@router.get(
+    "/audit/log",
+    response_model=AuditLogListResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid cursor"}, **ADMIN_RESPONSES},
+)
+async def list_audit_log(
+    since: Optional[datetime] = Query(None, description="Only entries at or after this time"),
+    until: Optional[datetime] = Query(None, description="Only entries before this time"),
+    tenant_id: Optional[str] = Query(None, description="Only entries of this tenant"),
+    actor: Optional[str] = Query(None, description="Only entries of this actor (token subject)"),
+    category: Optional[str] = Query(None, description="admin, detection or security"),
+    action: Optional[str] = Query(None, description="Only entries of this action"),
+    limit: int = Query(100, ge=1, le=1000, description="Most entries returned"),
+    cursor: Optional[str] = Query(None, description="next_cursor of the previous page"),
+    session: Session = Depends(get_db_session),
+):
+    """Admin actions and detection decisions, newest first, a page at a time."""
+    try:
+        page = AuditLogService(session).page(since, until, tenant_id, actor, category, action, limit, cursor)
+    except ValueError as e:
+        raise _bad_request(e)
+    return AuditLogListResponse(
+        entries=[AuditLogEntryInfo(**entry.to_dict()) for entry in page.items],
+        next_cursor=page.next_cursor,
+    )
+
+
+@router.get("/audit/log/verify", response_model=AuditLogVerifyResponse, responses=ADMIN_RESPONSES)
+async def verify_audit_log(session: Session = Depends(get_db_session)):
+    """Check the audit log's hash chain from its first entry.
+
+    An invalid chain names the first entry that was altered, or that no
+    longer follows its predecessor because entries were removed.
+    """
+    return AuditLogVerifyResponse(**AuditLogService(session).verify().to_dict())
+
+
+@router.get(
+    "/billing/usage",
+    response_model=BillingUsageResponse,
+    responses={
+        200: {"content": {"text/csv": {}}, "description": "Hourly billable requests (CSV with format=csv)"},
+        400: {"model": ErrorResponse, "description": "Invalid range"},
+        **ADMIN_RESPONSES,
+    },
+)
+async def get_billing_usage(
+    since: Optional[datetime] = Query(None, description="Start of the range (UTC; default: start of this month)"),
+    until: Optional[datetime] = Query(None, description="End of the range, exclusive (UTC; default: now)"),
+    tenant_id: Optional[str] = Query(None, description="Only this tenant"),
+    output_format: str = Query("json", alias="format", description="json, or csv as in the export files"),
+    session: Session = Depends(get_db_session),
+):
+    """Billable requests of tenants by UTC hour.
+
+    Hours are counted from the one containing since up to the one
+    containing until (excluded, so by default the current hour is left
+    out). Counts are stored every BILLING_FLUSH_INTERVAL_SECONDS.
+
+    Raises:
+        HTTPException: 400 if until is not after since
+    """
+    until = until or datetime.utcnow()
+    since = since or until.replace(day=1, hour=0, minute=0, second=0, microsecond=0)
+    try:
+        records = BillingService(session).hourly(since, until, tenant_id)
+    except ValueError as e:
+        raise _bad_request(e)
+    if output_format.strip().lower() == "csv":
+        return Response(content=to_csv(records), media_type="text/csv")
+    return BillingUsageResponse(
+        since=since,
+        until=until,
+        total=sum(record.requests for record in records),
+        records=[
+            BillingRecordInfo(
+                tenant_id=record.tenant_id,
+                period_start=record.period_start,
+                period_end=record.period_end,
+                requests=record.requests,
+            )
+            for record in records
+        ],
+    )
+
+
+@router.post(
+    "/billing/export",
+    response_model=BillingExportResponse,
+    responses={
+        503: {"model": ErrorResponse, "description": "No export destination configured, or export failed"},
+        **ADMIN_RESPONSES,
+    },
+)
+async def export_billing_usage():
+    """Export the ended hours not exported yet now, instead of at the next
+    BILLING_EXPORT_INTERVAL_SECONDS run.
+
+    Raises:
+        HTTPException: 503 without BILLING_EXPORT_URL and Stripe, or if the
+            export file cannot be written
+    """
+    try:
+        exporter = get_billing_exporter()
+    except ValueError as e:
+        raise _unavailable(e)
+    if not exporter.configured:
+        raise _unavailable(ValueError("Set BILLING_EXPORT_URL or BILLING_STRIPE_API_KEY to export billing usage"))
+    try:
+        result = await exporter.run_once()
+    except RuntimeError as e:
+        raise _unavailable(e)
+    return BillingExportResponse(**vars(result))
+
+
+@router.get(
+    "/geofences",
+    response_model=GeofenceListResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid cursor"}, **ADMIN_RESPONSES},
+)
+async def list_geofences(
+    limit: int = Query(100, ge=1, le=1000, description="Most geofences returned"),
+    cursor: Optional[str] = Query(None, description="next_cursor of the previous page"),
+    session: Session = Depends(get_db_session),
+):
+    """Stored geofences, newest first, a page at a time."""
+    try:
+        page = GeofenceService(session).geofence_page(limit, cursor)
+    except ValueError as e:
+        raise _bad_request(e)
+    return GeofenceListResponse(
+        geofences=[geofence_response(g) for g in page.items],
+        next_cursor=page.next_cursor,
+    )
+
+
+@router.post(
+    "/geofences",
+    response_model=GeofenceResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={400: {"model": ErrorResponse, "description": "Invalid geometry"}, **ADMIN_RESPONSES},
+)
+async def create_geofence(request: GeofenceCreate, session: Session = Depends(get_db_session)):
+    """Create a geofence, as POST /api/v1/geofences does (GeoJSON, WKT or WKB geometry)."""
+    try:
+        geometry, crs = request_geometry(request)
+        geofence = GeofenceService(session).create_geofence(request.name, geometry, request.properties)
+        return geofence_response(geofence, crs)
+    except ValueError as e:
+        raise _bad_request(e)
+
+
+@router.put(
+    "/geofences/{geofence_id}",
+    response_model=GeofenceResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid geometry"},
+        404: {"model": ErrorResponse, "description": "Geofence not found"},
+        **ADMIN_RESPONSES,
+    },
+)
+async def update_geofence(geofence_id: str, request: GeofenceCreate, session: Session = Depends(get_db_session)):
+    """Replace a geofence's name, geometry and properties, keeping its ID.
+
+    The S2 covering is recomputed; membership tests and alerts use the new
+    polygon from the next position report. Tracked entities keep their
+    confirmed membership until a report says otherwise.
+
+    Raises:
+        HTTPException: 400 for invalid input, 404 if the geofence does not exist
+    """
+    service = GeofenceService(session)
+    geofence = get_geofence_or_404(service, geofence_id)
+    try:
+        geometry, crs = request_geometry(request)
+        geofence = service.update_geofence(geofence, request.name, geometry, request.properties)
+        return geofence_response(geofence, crs)
+    except ValueError as e:
+        raise _bad_request(e)
+
+
+@router.delete(
+    "/geofences/{geofence_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    responses={404: {"model": ErrorResponse, "description": "Geofence not found"}, **ADMIN_RESPONSES},
+)
+async def delete_geofence(geofence_id: str, session: Session = Depends(get_db_session)):
+    """Delete a geofence and entity membership of it; its stored alerts are kept."""
+    service = GeofenceService(session)
+    get_geofence_or_404(service, geofence_id)
+    service.delete_geofence(geofence_id)
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+PROFILE_RESPONSES = {
+    400: {"model": ErrorResponse, "description": "Duration out of range"},
+    409: {"model": ErrorResponse, "description": "Another profile is being captured"},
+    **ADMIN_RESPONSES,
+}
+
+
+def _profiler_busy(e: ProfilerBusyError) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_409_CONFLICT,
+        detail={"error_code": "E008", "error_message": str(e), "details": None},
+    )
+
+
+@profiling_router.get(
+    "/cpu",
+    responses={
+        200: {"content": {"text/plain": {}}, "description": "Folded stacks with their sample counts"},
+        **PROFILE_RESPONSES,
+    },
+)
+async def get_cpu_profile(seconds: float = Query(10, description="Capture duration (at most PROFILING_MAX_SECONDS)")):
+    """Sample the stack of every thread for a while.
+
+    Answers one line per distinct stack, frames outermost first and
+    separated by `;`, then the number of samples (every 10 ms) that caught
+    that stack, as flame graph tools and speedscope read it. The event
+    loop shows up as the `MainThread` stacks, lookups run on the worker
+    pools as the other threads.
+
+    Raises:
+        HTTPException: 400 for a duration out of range, 409 while another
+            profile is being captured
+    """
+    try:
+        profile = await asyncio.to_thread(get_profiler().cpu_profile, seconds)
+    except ValueError as e:
+        raise _bad_request(e)
+    except ProfilerBusyError as e:
+        raise _profiler_busy(e)
+    return Response(content=profile, media_type="text/plain")
+
+
+@profiling_router.get("/allocations", response_model=AllocationProfileResponse, responses=PROFILE_RESPONSES)
+async def get_allocation_profile(
+    seconds: float = Query(10, description="Capture duration (at most PROFILING_MAX_SECONDS)"),
+    limit: int = Query(50, ge=1, le=1000, description="Allocation sites listed"),
+):
+    """Trace allocations for a while, and list the sites whose allocations
+    are still held at the end.
+
+    Tracing slows the process down by about a third while it lasts, so
+    keep captures short on busy replicas.
+
+    Raises:
+        HTTPException: 400 for a duration out of range, 409 while another
+            profile is being captured
+    """
+    try:
+        sites = await asyncio.to_thread(get_profiler().allocation_profile, seconds, limit)
+    except ValueError as e:
+        raise _bad_request(e)
+    except ProfilerBusyError as e:
+        raise _profiler_busy(e)
+    return AllocationProfileResponse(
+        seconds=seconds,
+        total_bytes=sum(site["size_bytes"] for site in sites),
+        sites=[AllocationSiteInfo(**site) for site in sites],
+    )
+
+
+@profiling_router.get(
+    "/threads",
+    responses={200: {"content": {"text/plain": {}}, "description": "Stacks"}, **ADMIN_RESPONSES},
+)
+async def get_thread_dump():
+    """Current stack of every thread and asyncio task, e.g. to find what a
+    stuck request waits on."""
+    return Response(content=thread_dump(asyncio.get_running_loop()), media_type="text/plain")
@@ -0,0 +1,163 @@
+"""API routes issuing, refreshing and revoking bearer tokens."""
+from fastapi import APIRouter, Depends, HTTPException, Request, status
+from sqlalchemy.orm import Session
+
+from src.config import get_config
+from src.database import get_db_session
+from src.models.schemas import (
+    ErrorResponse,
+    RefreshTokenRequest,
+    TokenRequest,
+    TokenResponse,
+    TokenRevokeRequest,
+    TokenRevokeResponse,
+)
+from src.services.auth_service import AuthenticationError, get_token_verifier
+from src.services.auth_throttle_service import api_key_identity
+from src.services.ip_allowlist_service import SourceNetworkError, client_address
+from src.services.refresh_token_service import REFRESH_TOKEN_PREFIX, RefreshTokenService, TokenPair
+from src.services.tenant_service import TenantService
+from src.services.usage_service import API_KEY_SUBJECT_PREFIX
+
+router = APIRouter(prefix="/api/v1", tags=["auth"])
+
+TOKEN_RESPONSES = {
+    401: {"model": ErrorResponse, "description": "Invalid, expired or revoked credentials"},
+    403: {"model": ErrorResponse, "description": "API key not allowed from the client address"},
+    429: {"model": ErrorResponse, "description": "Client address locked out after failed authentications"},
+    503: {"model": ErrorResponse, "description": "Token authentication not configured"},
+}
+
+
+def _unauthorized(e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_401_UNAUTHORIZED,
+        detail={"error_code": "E006", "error_message": str(e), "details": None},
+    )
+
+
+def _forbidden(e: SourceNetworkError) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_403_FORBIDDEN,
+        detail={"error_code": "E011", "error_message": str(e), "details": {"client_ip": e.client_ip}},
+    )
+
+
+def _client_ip(http_request: Request):
+    return client_address(http_request.scope, get_config().api_key_client_ip_header.strip())
+
+
+def _unavailable(e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+        detail={"error_code": "E003", "error_message": str(e), "details": None},
+    )
+
+
+def _to_response(pair: TokenPair) -> TokenResponse:
+    return TokenResponse(
+        access_token=pair.access_token,
+        expires_in=pair.expires_in,
+        refresh_token=pair.refresh_token,
+        refresh_expires_in=pair.refresh_expires_in,
+        tenant_id=pair.tenant_id,
+    )
+
+
+@router.post("/auth/token", response_model=TokenResponse, responses=TOKEN_RESPONSES)
+async def issue_token(
+    request: TokenRequest, http_request: Request, session: Session = Depends(get_db_session)
+):
+    """Exchange a tenant API key for an access token and a refresh token.
+
+    The access token names the key's tenant, carries its scopes and lasts
+    JWT_EXPIRATION_MINUTES; renew it with the refresh token at
+    /api/v1/auth/refresh. Keys rotated out keep working until their grace
+    period ends. Keys restricted to networks only work from those.
+    Addresses failing repeatedly are locked out for a while (429).
+
+    Args:
+        request: Tenant API key
+        http_request: Request (for the client address)
+        session: Database session (injected dependency)
+
+    Returns:
+        TokenResponse: Token pair and lifetimes
+
+    Raises:
+        HTTPException: 401 for an unusable key, 403 from outside the key's
+            networks, 503 if token authentication is not configured
+    """
+    # Failures are counted against the key by the brute-force protection
+    http_request.state.auth_identity = api_key_identity(request.api_key)
+    try:
+        key = TenantService(session).authenticate(request.api_key, _client_ip(http_request))
+    except SourceNetworkError as e:
+        raise _forbidden(e)
+    if key is None:
+        raise _unauthorized(AuthenticationError("Invalid API key"))
+    try:
+        pair = RefreshTokenService(session, get_token_verifier()).start_session(
+            f"{API_KEY_SUBJECT_PREFIX}{key.key_id}", key.tenant_id, scopes=key.scopes.split(), api_key_id=key.key_id
+        )
+    except (RuntimeError, ValueError) as e:
+        raise _unavailable(e)
+    return _to_response(pair)
+
+
+@router.post("/auth/refresh", response_model=TokenResponse, responses=TOKEN_RESPONSES)
+async def refresh_token(
+    request: RefreshTokenRequest, http_request: Request, session: Session = Depends(get_db_session)
+):
+    """Spend a refresh token for a new token pair.
+
+    Each refresh token works once. Presenting one again revokes its whole
+    session, since only a leaked copy can still be holding it.
+
+    Raises:
+        HTTPException: 401 for an unknown, expired, revoked or reused
+            token, 403 from outside the networks of the session's API key,
+            503 if token authentication is not configured
+    """
+    try:
+        pair = RefreshTokenService(session, get_token_verifier()).refresh(
+            request.refresh_token, _client_ip(http_request)
+        )
+    except AuthenticationError as e:
+        raise _unauthorized(e)
+    except SourceNetworkError as e:
+        raise _forbidden(e)
+    except (RuntimeError, ValueError) as e:
+        raise _unavailable(e)
+    return _to_response(pair)
+
+
+@router.post("/auth/revoke", response_model=TokenRevokeResponse, responses={503: TOKEN_RESPONSES[503]})
+async def revoke_token(request: TokenRevokeRequest, session: Session = Depends(get_db_session)):
+    """Revoke a token before it expires (RFC 7009).
+
+    A refresh token ends its whole session (logout): the session's
+    refresh tokens stop working and its access tokens are denied. An
+    access token is denied for the rest of its lifetime. Invalid and
+    already revoked tokens are not an error; `revoked` is false for them.
+
+    Raises:
+        HTTPException: 503 if the denylist cannot be reached
+    """
+    verifier = get_token_verifier()
+    try:
+        if request.token.startswith(REFRESH_TOKEN_PREFIX):
+            revoked = RefreshTokenService(session, verifier).revoke(request.token)
+        else:
+            try:
+                principal = verifier.verify(request.token)
+            except AuthenticationError:
+                return TokenRevokeResponse(revoked=False)
+            verifier.revoke(principal)
+            revoked = True
+    except ValueError:
+        # A token without a jti claim, e.g. signed by another issuer
+        return TokenRevokeResponse(revoked=False)
+    except RuntimeError as e:
+        raise _unavailable(e)
+    return TokenRevokeResponse(revoked=revoked)
@@ -0,0 +1,223 @@
+"""Shared FastAPI dependencies."""
+from typing import Optional
+
+from fastapi import Depends, Header, HTTPException, Request, status
+from sqlalchemy.orm import Session
+
+from src.config import get_config
+from src.database import get_db_session
+from src.services.auth_service import (
+    ADMIN_READ_SCOPE,
+    ADMIN_WRITE_SCOPE,
+    AuthenticationError,
+    Principal,
+    get_token_verifier,
+)
+from src.services.mtls_service import SCOPE_EXTENSION, client_certificate_principal
+from src.services.oidc_service import get_oidc_verifier
+from src.services.request_signing_service import (
+    BODY_DIGEST_EXTENSION,
+    SIGNATURE_HEADER,
+    RequestSigningService,
+    body_sha256,
+    get_nonce_store,
+)
+
+# Methods the read-only admin role may call
+_READ_METHODS = frozenset({"GET", "HEAD"})
+
+
+def _has_client_certificate(request: Request) -> bool:
+    return bool((request.scope.get("extensions") or {}).get(SCOPE_EXTENSION))
+
+
+async def _body_digest(request: Request) -> str:
+    """Hex SHA-256 of the request body, which the route may already have read."""
+    digest = (request.scope.get("extensions") or {}).get(BODY_DIGEST_EXTENSION)
+    if digest is None:
+        return body_sha256(await request.body())
+    if not digest.complete:
+        await request.body()  # Not read by the route; hashed on its way in
+    return digest.hexdigest()
+
+
+async def _check_signature(request: Request, principal: Principal, session: Session) -> None:
+    """Require a valid X-Request-Signature for tokens of tenants with signing
+    keys (and from anyone sending one).
+
+    Raises:
+        AuthenticationError: If the signature is required but missing, or invalid
+        RuntimeError: If the nonce store cannot be reached
+    """
+    header = request.headers.get(SIGNATURE_HEADER)
+    if header is None and not principal.signed_requests:
+        return
+    digest = await _body_digest(request) if header is not None else None
+    service = RequestSigningService(
+        session, get_nonce_store(), get_config().request_signing_tolerance_seconds
+    )
+    service.verify(
+        principal.tenant_id,
+        header,
+        request.method,
+        request.url.path,
+        request.url.query,
+        digest,
+        required=principal.signed_requests,
+    )
+
+
+async def get_principal(
+    request: Request,
+    authorization: Optional[str] = Header(None),
+    session: Session = Depends(get_db_session),
+) -> Principal:
+    """Authenticate the caller from an `Authorization: Bearer <JWT>` header,
+    or without one from the client certificate of a mutual TLS connection.
+
+    Tokens of tenants with signing keys only work on signed requests
+    (X-Request-Signature, see src.services.request_signing_service).
+
+    Args:
+        request: Request (for its client certificate and signature)
+        authorization: Authorization header
+        session: Database session (for the tenant's signing keys)
+
+    Returns:
+        Authenticated principal
+
+    Raises:
+        HTTPException: 401 for missing or invalid credentials, 503 if token
+            authentication is not configured
+    """
+    scheme, _, token = (authorization or "").partition(" ")
+    try:
+        if authorization is None and _has_client_certificate(request):
+            principal = client_certificate_principal(request.scope)
+            if principal is None:
+                raise AuthenticationError("Client certificate is not mapped to a tenant")
+        else:
+            if scheme.lower() != "bearer" or not token.strip():
+                raise AuthenticationError("Bearer token required")
+            principal = get_token_verifier().verify(token.strip())
+            await _check_signature(request, principal, session)
+    except AuthenticationError as e:
+        raise HTTPException(
+            status_code=status.HTTP_401_UNAUTHORIZED,
+            detail={
+                "error_code": "E006",
+                "error_message": str(e),
+                "details": None,
+            },
+            headers={"WWW-Authenticate": "Bearer"},
+        )
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    # The caller whose API key usage is counted (see src.middleware.usage)
+    request.state.principal = principal
+    return principal
+
+
+async def get_tenant_id(principal: Principal = Depends(get_principal)) -> str:
+    """Tenant of the authenticated caller (from the signed token claim)."""
+    return principal.tenant_id
+
+
+async def get_admin_principal(
+    request: Request,
+    authorization: Optional[str] = Header(None),
+    session: Session = Depends(get_db_session),
+) -> Principal:
+    """Authenticate an operator: a token of the corporate IdP (OIDC), or a
+    token of this service as for get_principal.
+
+    Tokens whose issuer is OIDC_ISSUER_URL are verified against the IdP;
+    their roles come from the user's groups.
+
+    Raises:
+        HTTPException: 401 for missing or invalid credentials, 503 if the
+            IdP cannot be reached or token authentication is not configured
+    """
+    oidc = get_oidc_verifier()
+    scheme, _, token = (authorization or "").partition(" ")
+    if oidc is None or scheme.lower() != "bearer" or not oidc.handles(token.strip()):
+        return await get_principal(request, authorization, session)
+    try:
+        return await oidc.verify(token.strip())
+    except AuthenticationError as e:
+        raise HTTPException(
+            status_code=status.HTTP_401_UNAUTHORIZED,
+            detail={
+                "error_code": "E006",
+                "error_message": str(e),
+                "details": None,
+            },
+            headers={"WWW-Authenticate": "Bearer"},
+        )
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": f"Identity provider unavailable: {str(e)}",
+                "details": None,
+            },
+        )
+
+
+async def require_admin(
+    request: Request, principal: Principal = Depends(get_admin_principal)
+) -> Principal:
+    """Authenticated operator allowed to make this admin request.
+
+    GET and HEAD requests need admin:read, others admin:write; the admin
+    and admin:* scopes grant both.
+
+    Raises:
+        HTTPException: 403 if the token lacks the scope (401/503 as for
+            get_admin_principal)
+    """
+    # The actor of the request's audit log entry (see src.middleware.audit_log)
+    request.state.principal = principal
+    required = ADMIN_READ_SCOPE if request.method in _READ_METHODS else ADMIN_WRITE_SCOPE
+    if not principal.has_scope(required):
+        raise HTTPException(
+            status_code=status.HTTP_403_FORBIDDEN,
+            detail={
+                "error_code": "E010",
+                "error_message": f"Token lacks the '{required}' scope",
+                "details": None,
+            },
+        )
+    return principal
+
+
+async def get_optional_principal(
+    request: Request,
+    authorization: Optional[str] = Header(None),
+    session: Session = Depends(get_db_session),
+) -> Optional[Principal]:
+    """Caller when a bearer token or client certificate is sent, otherwise None.
+
+    For endpoints that also serve anonymous callers; credentials that are
+    sent must still be valid (401 otherwise).
+    """
+    if authorization is None and not _has_client_certificate(request):
+        return None
+    return await get_principal(request, authorization, session)
+
+
+async def get_optional_tenant_id(
+    principal: Optional[Principal] = Depends(get_optional_principal),
+) -> Optional[str]:
+    """Tenant of the caller when a bearer token or client certificate is
+    sent, otherwise None (see get_optional_principal).
+    """
+    return principal.tenant_id if principal is not None else None
@@ -0,0 +1,507 @@
+"""API routes for location-based detection rules.
+
+Every verdict is written to the audit log (src.services.audit_log_service).
+"""
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
+
+from fastapi import APIRouter, Depends, Header, HTTPException, Query, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_optional_principal, get_optional_tenant_id
+from src.api.lookup_routes import (
+    compare_device_location,
+    lookup_ip_address,
+    tenant_overrides,
+    track_session,
+)
+from src.database import get_db_session
+from src.models.schemas import (
+    AccountSessionRequest,
+    AccountSharingResponse,
+    AnomalyEventRequest,
+    ErrorResponse,
+    FlaggedAccountInfo,
+    FlaggedAccountListResponse,
+    LocationAnomalyInfo,
+    LocationMismatchRequest,
+    LocationMismatchResponse,
+    LocationProfileResponse,
+    ResidentialProxyRequest,
+    ResidentialProxyResponse,
+    TravelAssessmentResponse,
+    TravelEventRequest,
+)
+from src.services.account_sharing_service import AccountSharingService
+from src.services.anomaly_service import LocationAnomalyDetector
+from src.services.audit_log_service import AuditCategory, AuditLogService, AuditOutcome
+from src.services.auth_service import Principal
+from src.services.travel_service import TravelDetectionService, TravelPoint
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter(prefix="/api/v1", tags=["detection rules"])
+
+
+def _error(status_code: int, code: str, e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status_code,
+        detail={"error_code": code, "error_message": str(e), "details": None},
+    )
+
+
+def _record_decision(
+    session: Session,
+    action: str,
+    caller: Optional[Principal],
+    resource: str,
+    flagged: bool,
+    details: Dict[str, Any],
+) -> None:
+    """Write a detection verdict to the audit log.
+
+    Args:
+        session: Database session
+        action: Detection route
+        caller: Authenticated caller (None if anonymous)
+        resource: Subject of the decision, e.g. user:alice
+        flagged: Whether the rule fired
+        details: The verdict as returned
+    """
+    try:
+        AuditLogService(session).append(
+            AuditCategory.DETECTION,
+            action,
+            AuditOutcome.FLAGGED if flagged else AuditOutcome.CLEAR,
+            actor=caller.subject if caller is not None else None,
+            tenant_id=caller.tenant_id if caller is not None else None,
+            resource=resource,
+            details=details,
+        )
+    except ValueError as e:
+        # The verdict is still returned; the loss is in the application log
+        logger.error(f"Audit log entry lost for {action} of {resource}: {e}")
+
+
+def _login_point(
+    event: TravelEventRequest, api_key: Optional[str], tenant_id: Optional[str], session: Session
+) -> TravelPoint:
+    """Location of a login event, from its coordinate or by locating its IP.
+
+    Raises:
+        ValueError: If both or neither of ip_address and latitude/longitude are given
+        LookupError: If the IP address has no coordinate in the dataset
+        RuntimeError: If no GeoIP dataset is available
+    """
+    observed_at = event.timestamp or datetime.now(timezone.utc)
+    has_coordinate = event.latitude is not None or event.longitude is not None
+    if event.ip_address is not None and has_coordinate:
+        raise ValueError("Pass either ip_address or latitude/longitude, not both")
+
+    if event.ip_address is None:
+        if event.latitude is None or event.longitude is None:
+            raise ValueError("ip_address or latitude and longitude are required")
+        return TravelPoint(event.latitude, event.longitude, observed_at, event.accuracy_km)
+
+    located = lookup_ip_address(
+        event.ip_address, api_key, overrides=tenant_overrides(tenant_id, session)
+    )
+    if located.latitude is None or located.longitude is None:
+        raise LookupError(f"No coordinate for {event.ip_address}")
+    return TravelPoint(
+        latitude=located.latitude,
+        longitude=located.longitude,
+        observed_at=observed_at,
+        accuracy_km=located.accuracy_radius_km,
+        ip_address=located.ip_address,
+        country_code=located.country_iso_code,
+    )
+
+
+@router.post(
+    "/detect/travel",
+    response_model=TravelAssessmentResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid login event"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        404: {"model": ErrorResponse, "description": "IP address not in dataset"},
+        503: {"model": ErrorResponse, "description": "GeoIP dataset unavailable"},
+    },
+)
+async def detect_travel(
+    event: TravelEventRequest,
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    caller: Optional[Principal] = Depends(get_optional_principal),
+    session: Session = Depends(get_db_session),
+):
+    """Record a login and flag impossible travel from the user's previous one.
+
+    The login is compared with the user's latest login at or before its
+    timestamp; user IDs are scoped to the calling tenant.
+
+    Args:
+        event: User, time, and IP address or coordinate of the login
+        x_api_key: Caller API key (for locating the IP address)
+        tenant_id: Calling tenant, if a bearer token was sent
+        caller: Calling principal, if credentials were sent (audit log actor)
+        session: Database session (injected dependency)
+
+    Returns:
+        TravelAssessmentResponse: Distance, elapsed time, implied speed and verdict
+
+    Raises:
+        HTTPException: 400 for an invalid event, 401 for an invalid token,
+            404 if the IP cannot be located, 503 if no dataset
+    """
+    try:
+        login = _login_point(event, x_api_key, tenant_id, session)
+        assessment = TravelDetectionService(session).evaluate(event.user_id, login, tenant_id)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except LookupError as e:
+        raise _error(status.HTTP_404_NOT_FOUND, "E004", e)
+    except RuntimeError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+    response = TravelAssessmentResponse(**assessment.to_dict())
+    _record_decision(
+        session, "detect_travel", caller, f"user:{event.user_id}", response.impossible,
+        response.model_dump(mode="json"),
+    )
+    return response
+
+
+@router.post(
+    "/detect/location-mismatch",
+    response_model=LocationMismatchResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid IP address or coordinate"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        404: {"model": ErrorResponse, "description": "IP address not in dataset or without a coordinate"},
+        503: {"model": ErrorResponse, "description": "GeoIP dataset unavailable"},
+    },
+)
+async def detect_location_mismatch(
+    request: LocationMismatchRequest,
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    caller: Optional[Principal] = Depends(get_optional_principal),
+    session: Session = Depends(get_db_session),
+):
+    """Flag device coordinates too far from where the IP address geolocates.
+
+    Args:
+        request: Client IP address and device-reported coordinate
+        x_api_key: Caller API key; selects whether anonymizer flags explain a mismatch
+        tenant_id: Calling tenant, if a bearer token was sent
+        caller: Calling principal, if credentials were sent (audit log actor)
+        session: Database session (injected dependency)
+
+    Returns:
+        LocationMismatchResponse: Both locations, the distance and the verdict
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 for an invalid token,
+            404 if the IP cannot be located, 503 if no dataset
+    """
+    try:
+        located = lookup_ip_address(
+            request.ip_address, x_api_key, overrides=tenant_overrides(tenant_id, session)
+        )
+        comparison = compare_device_location(
+            located, request.latitude, request.longitude, request.accuracy_m
+        )
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except LookupError as e:
+        raise _error(status.HTTP_404_NOT_FOUND, "E004", e)
+    except RuntimeError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+    response = LocationMismatchResponse(
+        ip_address=located.ip_address,
+        device_latitude=request.latitude,
+        device_longitude=request.longitude,
+        ip_latitude=located.latitude,
+        ip_longitude=located.longitude,
+        ip_country_code=located.country_iso_code,
+        anonymizer=located.anonymizer,
+        comparison=comparison,
+    )
+    _record_decision(
+        session, "detect_location_mismatch", caller, f"ip:{response.ip_address}",
+        response.comparison.mismatch, response.model_dump(mode="json"),
+    )
+    return response
+
+
+@router.post(
+    "/detect/residential-proxy",
+    response_model=ResidentialProxyResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid session ID or IP address"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        503: {"model": ErrorResponse, "description": "Velocity store unavailable"},
+    },
+)
+async def detect_residential_proxy(
+    request: ResidentialProxyRequest,
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    caller: Optional[Principal] = Depends(get_optional_principal),
+    session: Session = Depends(get_db_session),
+):
+    """Record a session request and flag rotating residential proxy use.
+
+    Residential proxy networks hop between consumer ISPs from one request
+    to the next, so a session is flagged once it has come from more
+    access-network ASNs than RESIDENTIAL_PROXY_MAX_SESSION_ASNS within
+    RESIDENTIAL_PROXY_WINDOW, or immediately if the address is a listed
+    residential proxy endpoint. This is separate from the anonymizer's
+    VPN and public proxy flags.
+
+    Args:
+        request: Session ID, client IP address and optional request time
+        tenant_id: Calling tenant, if a bearer token was sent; session IDs are scoped to it
+        caller: Calling principal, if credentials were sent (audit log actor)
+        session: Database session (for the audit log)
+
+    Returns:
+        ResidentialProxyResponse: ASN and address counts of the session and the verdict
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 for an invalid token,
+            503 if the velocity store is unavailable
+    """
+    timestamp = None
+    if request.timestamp is not None:
+        when = request.timestamp
+        if when.tzinfo is None:
+            when = when.replace(tzinfo=timezone.utc)
+        timestamp = when.timestamp()
+    try:
+        response = track_session(request.session_id, request.ip_address, tenant_id, timestamp)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except RuntimeError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+    _record_decision(
+        session, "detect_residential_proxy", caller, f"session:{request.session_id}",
+        response.detected, response.model_dump(mode="json"),
+    )
+    return response
+
+
+@router.post(
+    "/detect/anomaly",
+    response_model=LocationAnomalyInfo,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid event"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        404: {"model": ErrorResponse, "description": "IP address not in dataset"},
+        503: {"model": ErrorResponse, "description": "GeoIP dataset unavailable"},
+    },
+)
+async def detect_anomaly(
+    event: AnomalyEventRequest,
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    caller: Optional[Principal] = Depends(get_optional_principal),
+    session: Session = Depends(get_db_session),
+):
+    """Score an event against the user's historical login locations.
+
+    Nothing is recorded; the user's profile is trained from their stored
+    logins if it is missing or stale.
+
+    Args:
+        event: User and IP address or coordinate of the event
+        x_api_key: Caller API key (for locating the IP address)
+        tenant_id: Calling tenant, if a bearer token was sent
+        caller: Calling principal, if credentials were sent (audit log actor)
+        session: Database session (injected dependency)
+
+    Returns:
+        LocationAnomalyInfo: Distance from the user's centroid, score and verdict
+
+    Raises:
+        HTTPException: 400 for an invalid event, 401 for an invalid token,
+            404 if the IP cannot be located, 503 if no dataset
+    """
+    login = TravelEventRequest(
+        user_id=event.user_id,
+        ip_address=event.ip_address,
+        latitude=event.latitude,
+        longitude=event.longitude,
+    )
+    try:
+        point = _login_point(login, x_api_key, tenant_id, session)
+        assessment = LocationAnomalyDetector(session).evaluate(
+            event.user_id, point.latitude, point.longitude, tenant_id
+        )
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except LookupError as e:
+        raise _error(status.HTTP_404_NOT_FOUND, "E004", e)
+    except RuntimeError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+    response = LocationAnomalyInfo(**assessment.to_dict())
+    _record_decision(
+        session, "detect_anomaly", caller, f"user:{event.user_id}", response.anomalous,
+        response.model_dump(mode="json"),
+    )
+    return response
+
+
+@router.get(
+    "/detect/anomaly/profiles/{user_id}",
+    response_model=LocationProfileResponse,
+    responses={404: {"model": ErrorResponse, "description": "Profile not trained"}},
+)
+async def get_location_profile(
+    user_id: str,
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Stored location profile of a user.
+
+    Args:
+        user_id: User identifier
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        LocationProfileResponse: Centroid, distance median and MAD
+
+    Raises:
+        HTTPException: 401 for an invalid token, 404 if never trained
+    """
+    profile = LocationAnomalyDetector(session).profile(user_id, tenant_id)
+    if profile is None:
+        raise _error(
+            status.HTTP_404_NOT_FOUND, "E004", LookupError(f"No location profile for user {user_id}")
+        )
+    return LocationProfileResponse(**profile.to_dict())
+
+
+@router.post(
+    "/detect/anomaly/profiles/{user_id}/train",
+    response_model=LocationProfileResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid user ID"},
+        404: {"model": ErrorResponse, "description": "No logins stored for the user"},
+    },
+)
+async def train_location_profile(
+    user_id: str,
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Retrain a user's location profile from their stored logins now.
+
+    Args:
+        user_id: User identifier
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        LocationProfileResponse: The new profile
+
+    Raises:
+        HTTPException: 400 for an invalid user ID, 401 for an invalid token,
+            404 if the user has no logins
+    """
+    try:
+        profile = LocationAnomalyDetector(session).train(user_id, tenant_id)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except LookupError as e:
+        raise _error(status.HTTP_404_NOT_FOUND, "E004", e)
+    return LocationProfileResponse(**profile.to_dict())
+
+
+@router.post(
+    "/detect/account-sharing",
+    response_model=AccountSharingResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid activity"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        404: {"model": ErrorResponse, "description": "IP address not in dataset"},
+        503: {"model": ErrorResponse, "description": "GeoIP dataset unavailable"},
+    },
+)
+async def detect_account_sharing(
+    activity: AccountSessionRequest,
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    caller: Optional[Principal] = Depends(get_optional_principal),
+    session: Session = Depends(get_db_session),
+):
+    """Record activity of a session and flag the account if another session is too far away.
+
+    Send this on login and periodically while a session is in use. Sessions
+    idle for ACCOUNT_SHARING_SESSION_TTL_SECONDS are no longer active.
+
+    Args:
+        activity: Account, session and IP address or coordinate of the activity
+        x_api_key: Caller API key (for locating the IP address)
+        tenant_id: Calling tenant, if a bearer token was sent; account IDs are scoped to it
+        caller: Calling principal, if credentials were sent (audit log actor)
+        session: Database session (injected dependency)
+
+    Returns:
+        AccountSharingResponse: Active sessions and the ones that conflict
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 for an invalid token,
+            404 if the IP cannot be located, 503 if no dataset
+    """
+    event = TravelEventRequest(
+        user_id=activity.account_id,
+        timestamp=activity.timestamp,
+        ip_address=activity.ip_address,
+        latitude=activity.latitude,
+        longitude=activity.longitude,
+        accuracy_km=activity.accuracy_km,
+    )
+    try:
+        point = _login_point(event, x_api_key, tenant_id, session)
+        assessment = AccountSharingService(session).observe(
+            activity.account_id, activity.session_id, point, tenant_id
+        )
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except LookupError as e:
+        raise _error(status.HTTP_404_NOT_FOUND, "E004", e)
+    except RuntimeError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+    response = AccountSharingResponse(**assessment.to_dict())
+    _record_decision(
+        session, "detect_account_sharing", caller, f"account:{activity.account_id}", response.flagged,
+        response.model_dump(mode="json"),
+    )
+    return response
+
+
+@router.get(
+    "/detect/account-sharing/flagged",
+    response_model=FlaggedAccountListResponse,
+    responses={401: {"model": ErrorResponse, "description": "Invalid bearer token"}},
+)
+async def list_flagged_accounts(
+    limit: int = Query(100, ge=1, le=1000, description="Most accounts returned"),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Accounts currently flagged for sharing, for trust & safety review.
+
+    An account stays flagged while its conflicting sessions are active.
+
+    Args:
+        limit: Most accounts returned
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        FlaggedAccountListResponse: Flagged accounts, most recently detected first
+    """
+    flags = AccountSharingService(session).flagged(tenant_id, limit)
+    return FlaggedAccountListResponse(accounts=[FlaggedAccountInfo(**f.to_dict()) for f in flags])
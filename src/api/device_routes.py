@@ -0,0 +1,236 @@
+"""API routes for device location history."""
+from datetime import datetime, timezone
+from typing import Optional
+
+from fastapi import APIRouter, Depends, Header, HTTPException, Query, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_optional_tenant_id
+from src.api.lookup_routes import lookup_ip_address, tenant_overrides
+from src.database import get_db_session
+from src.models.schemas import (
+    CountryPresenceInfo,
+    DeviceCountriesResponse,
+    DeviceCountryResponse,
+    DeviceHistoryResponse,
+    DeviceLocationRequest,
+    DeviceLocationResponse,
+    DeviceObservationInfo,
+    ErrorResponse,
+)
+from src.services.device_history_service import DeviceHistoryService, DeviceObservation
+
+router = APIRouter(prefix="/api/v1", tags=["devices"])
+
+
+def _error(status_code: int, code: str, e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status_code,
+        detail={"error_code": code, "error_message": str(e), "details": None},
+    )
+
+
+def _observation(
+    sighting: DeviceLocationRequest, api_key: Optional[str], tenant_id: Optional[str], session: Session
+) -> DeviceObservation:
+    """Sighting from its IP address or its country code.
+
+    Raises:
+        ValueError: If both or neither of ip_address and country_code are given
+        LookupError: If the IP address is not in the dataset
+        RuntimeError: If no GeoIP dataset is available
+    """
+    observed_at = sighting.timestamp or datetime.now(timezone.utc)
+    if sighting.ip_address is None:
+        if sighting.country_code is None:
+            raise ValueError("ip_address or country_code is required")
+        return DeviceObservation(
+            observed_at=observed_at,
+            country_code=sighting.country_code,
+            city_name=sighting.city_name,
+            latitude=sighting.latitude,
+            longitude=sighting.longitude,
+            accuracy_km=sighting.accuracy_km,
+        )
+    if sighting.country_code is not None or sighting.city_name is not None:
+        raise ValueError("Pass either ip_address or country_code/city_name, not both")
+
+    located = lookup_ip_address(
+        sighting.ip_address, api_key, overrides=tenant_overrides(tenant_id, session)
+    )
+    # A device-reported coordinate is more precise than the IP location
+    own_coordinate = sighting.latitude is not None
+    return DeviceObservation(
+        observed_at=observed_at,
+        country_code=located.country_iso_code,
+        city_name=located.city_name,
+        latitude=sighting.latitude if own_coordinate else located.latitude,
+        longitude=sighting.longitude if own_coordinate else located.longitude,
+        accuracy_km=sighting.accuracy_km if own_coordinate else located.accuracy_radius_km,
+        ip_address=located.ip_address,
+    )
+
+
+@router.post(
+    "/devices/{device_id}/locations",
+    response_model=DeviceLocationResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid device ID or location"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        404: {"model": ErrorResponse, "description": "IP address not in dataset"},
+        503: {"model": ErrorResponse, "description": "GeoIP dataset or location data key unavailable"},
+    },
+)
+async def record_device_location(
+    device_id: str,
+    sighting: DeviceLocationRequest,
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Record where a device was seen and compare it with its history.
+
+    Args:
+        device_id: Device identifier (scoped to the calling tenant)
+        sighting: IP address, or country code with optional city and coordinate
+        x_api_key: Caller API key (for locating the IP address)
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        DeviceLocationResponse: Stored sighting and whether the device and
+            country were known before it
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 for an invalid token,
+            404 if the IP cannot be located, 503 if no dataset or the
+            tenant's data key is unavailable
+    """
+    try:
+        observation = _observation(sighting, x_api_key, tenant_id, session)
+        correlation = DeviceHistoryService(session).correlate(device_id, observation, tenant_id)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except LookupError as e:
+        raise _error(status.HTTP_404_NOT_FOUND, "E004", e)
+    except RuntimeError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+    return DeviceLocationResponse(
+        **correlation.to_dict(), observation=DeviceObservationInfo(**observation.to_dict())
+    )
+
+
+@router.get(
+    "/devices/{device_id}/locations",
+    response_model=DeviceHistoryResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid device ID or cursor"},
+        503: {"model": ErrorResponse, "description": "Location data key unavailable"},
+    },
+)
+async def get_device_locations(
+    device_id: str,
+    since: Optional[datetime] = Query(None, description="Only sightings at or after this time"),
+    limit: int = Query(100, ge=1, le=1000, description="Most sightings returned"),
+    cursor: Optional[str] = Query(None, description="next_cursor of the previous page"),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Sightings of a device, newest first, a page at a time.
+
+    Args:
+        device_id: Device identifier
+        since: Earliest sighting time
+        limit: Most sightings returned
+        cursor: Continue after the previous page
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        DeviceHistoryResponse: Sightings (empty for unknown devices) and
+            the cursor of the next page
+
+    Raises:
+        HTTPException: 400 for an invalid device ID or cursor, 401 for an
+            invalid token, 503 if sightings cannot be decrypted
+    """
+    try:
+        page = DeviceHistoryService(session).history_page(device_id, tenant_id, since, limit, cursor)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except RuntimeError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+    return DeviceHistoryResponse(
+        device_id=device_id,
+        observations=[DeviceObservationInfo(**o.to_dict()) for o in page.items],
+        next_cursor=page.next_cursor,
+    )
+
+
+@router.get(
+    "/devices/{device_id}/countries",
+    response_model=DeviceCountriesResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid device ID"}},
+)
+async def get_device_countries(
+    device_id: str,
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Countries a device has been seen in, with first and last sightings.
+
+    Args:
+        device_id: Device identifier
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        DeviceCountriesResponse: Countries, first seen first
+
+    Raises:
+        HTTPException: 400 for an invalid device ID, 401 for an invalid token
+    """
+    try:
+        countries = DeviceHistoryService(session).countries(device_id, tenant_id)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    return DeviceCountriesResponse(
+        device_id=device_id,
+        countries=[CountryPresenceInfo(**c.to_dict()) for c in countries],
+    )
+
+
+@router.get(
+    "/devices/{device_id}/countries/{country_code}",
+    response_model=DeviceCountryResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid device ID or country code"}},
+)
+async def get_device_country(
+    device_id: str,
+    country_code: str,
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Whether a device has ever been seen in a country.
+
+    Args:
+        device_id: Device identifier
+        country_code: ISO 3166-1 alpha-2 country code
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        DeviceCountryResponse: `seen`, with the sighting count and first/last
+            sighting when seen
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 for an invalid token
+    """
+    try:
+        presence = DeviceHistoryService(session).seen_in_country(device_id, country_code, tenant_id)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    if presence is None:
+        return DeviceCountryResponse(
+            device_id=device_id, country_code=country_code.strip().upper(), seen=False
+        )
+    return DeviceCountryResponse(device_id=device_id, seen=True, **presence.to_dict())
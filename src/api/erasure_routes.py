@@ -0,0 +1,98 @@
+"""API routes for erasing data subjects' records (right to erasure)."""
+import json
+
+from fastapi import APIRouter, Depends, HTTPException, status
+from sqlalchemy.orm import Session
+
+from src.api.dependencies import get_tenant_id
+from src.api.poi_routes import AUTH_RESPONSES
+from src.database import get_db_session
+from src.models.database_models import ErasureJob
+from src.models.schemas import ErasureCreate, ErasureReportInfo, ErasureResponse, ErrorResponse
+from src.services.erasure_service import ErasureService, get_erasure_runner, parse_ip_addresses
+
+router = APIRouter(prefix="/api/v1", tags=["erasure"])
+
+
+def _to_response(job: ErasureJob) -> ErasureResponse:
+    return ErasureResponse(
+        erasure_id=job.erasure_id,
+        status=job.status,
+        report=ErasureReportInfo(**json.loads(job.report)) if job.report else None,
+        error=job.error,
+        created_at=job.created_at,
+        started_at=job.started_at,
+        finished_at=job.finished_at,
+    )
+
+
+@router.post(
+    "/erasures",
+    response_model=ErasureResponse,
+    status_code=status.HTTP_202_ACCEPTED,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid subject or IP address"},
+        **AUTH_RESPONSES,
+    },
+)
+async def request_erasure(
+    request: ErasureCreate,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Erase a data subject's records and return at once.
+
+    The subject is tombstoned before this returns, so its records are no
+    longer stored; the records already stored are deleted in the
+    background. Poll GET /erasures/{erasure_id} for the verification
+    report. Requesting the erasure of a subject again (e.g. after a
+    failure) deletes whatever is left.
+
+    Args:
+        request: Subject identifier and, optionally, its IP addresses
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        ErasureResponse: The queued erasure
+
+    Raises:
+        HTTPException: 400 for an invalid subject or address, 401 without a valid token
+    """
+    try:
+        ip_addresses = parse_ip_addresses(request.ip_addresses)
+        job = ErasureService(session).request(tenant_id, request.subject)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={"error_code": "E002", "error_message": str(e), "details": None},
+        )
+    get_erasure_runner().submit(job.erasure_id, tenant_id, request.subject.strip(), ip_addresses)
+    return _to_response(job)
+
+
+@router.get(
+    "/erasures/{erasure_id}",
+    response_model=ErasureResponse,
+    responses={
+        404: {"model": ErrorResponse, "description": "Erasure not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def get_erasure(
+    erasure_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Status and verification report of one of the calling tenant's erasures."""
+    job = ErasureService(session).get(tenant_id, erasure_id)
+    if job is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"Erasure {erasure_id} not found",
+                "details": None,
+            },
+        )
+    return _to_response(job)
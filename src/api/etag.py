@@ -0,0 +1,83 @@
+"""ETags and conditional requests for dataset-backed responses.
+
+A lookup answered by the same dataset build, for the same request, gives
+the same response. Such responses carry an ETag made of the dataset build
+and a digest of the request and response body, e.g.
+`"1707523200-3f9a0c2be41d7a5c86e1"`. A repeated request with that tag in
+If-None-Match gets an empty 304 instead of the body. The body digest keeps
+the tag correct when something besides the dataset changes the answer,
+such as tenant overrides, enrichment data or rules.
+"""
+
+import hashlib
+from datetime import datetime
+from typing import Any, Dict, Optional
+
+from fastapi import Request, Response, status
+from fastapi.responses import JSONResponse
+
+# Revalidated on every use; tenant-specific answers are not for shared caches
+CACHE_CONTROL = "private, no-cache"
+
+
+def dataset_version(built_at: Optional[datetime]) -> str:
+    """ETag prefix for a dataset build (0 when the build time is unknown)."""
+    return str(int(built_at.timestamp())) if built_at is not None else "0"
+
+
+def compute_etag(version: str, request: Request, body: bytes) -> str:
+    """Strong ETag of a response body for a request against a dataset version."""
+    digest = hashlib.sha256()
+    digest.update(request.url.path.encode())
+    digest.update(b"?")
+    digest.update(str(sorted(request.query_params.multi_items())).encode())
+    # Enrichments depend on the API key, overrides and risk areas on the tenant
+    digest.update(request.headers.get("x-api-key", "").encode())
+    digest.update(request.headers.get("authorization", "").encode())
+    digest.update(b"\0")
+    digest.update(body)
+    return f'"{version}-{digest.hexdigest()[:20]}"'
+
+
+def etag_matches(if_none_match: Optional[str], etag: str) -> bool:
+    """Whether an If-None-Match header matches a tag (weak comparison, RFC 9110)."""
+    if not if_none_match:
+        return False
+    if if_none_match.strip() == "*":
+        return True
+    tags = (tag.strip() for tag in if_none_match.split(","))
+    return any(tag.removeprefix("W/") == etag for tag in tags)
+
+
+def conditional_response(
+    request: Request, body: bytes, media_type: str, version: str, headers: Optional[Dict[str, str]] = None
+) -> Response:
+    """Response with an ETag, or a 304 if the client holds the same one.
+
+    Args:
+        request: Request being answered
+        body: Encoded response body
+        media_type: Content type of the body
+        version: Dataset version (see dataset_version)
+        headers: Further headers, sent with the 304 too (e.g. Vary)
+    """
+    etag = compute_etag(version, request, body)
+    headers = {**(headers or {}), "ETag": etag, "Cache-Control": CACHE_CONTROL}
+    if etag_matches(request.headers.get("if-none-match"), etag):
+        return Response(status_code=status.HTTP_304_NOT_MODIFIED, headers=headers)
+    return Response(content=body, media_type=media_type, headers=headers)
+
+
+def conditional_json(
+    request: Request, content: Any, version: str, headers: Optional[Dict[str, str]] = None
+) -> Response:
+    """JSON response with an ETag, or a 304 if the client holds the same one.
+
+    Args:
+        request: Request being answered
+        content: JSON-serializable response content
+        version: Dataset version (see dataset_version)
+        headers: Further headers, sent with the 304 too
+    """
+    body = JSONResponse(content).body
+    return conditional_response(request, body, "application/json", version, headers)
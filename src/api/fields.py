@@ -0,0 +1,81 @@
+"""Sparse fieldsets: `fields=` selection of response attributes.
+
+High-volume integrators often need a handful of attributes of a large
+response. `fields` is a comma-separated list of attribute names, e.g.
+`fields=country_iso_code,asn,risk.score`. A name selects the whole
+attribute, and dotted names reach into nested objects (or into each item
+of a list of objects). The selection is checked against the response
+model, so a typo is a 400 rather than a silently empty response.
+Attributes that are null stay in the response as null.
+"""
+
+import typing
+from typing import Any, Dict, List, Optional, Type
+
+from pydantic import BaseModel
+
+# Selection tree: attribute name -> sub-selection (None: whole attribute)
+FieldTree = Dict[str, Optional["FieldTree"]]
+
+
+def _nested_model(annotation: Any) -> Optional[Type[BaseModel]]:
+    """Model inside an annotation (Optional[X], List[X], ...), if any."""
+    if isinstance(annotation, type) and issubclass(annotation, BaseModel):
+        return annotation
+    for argument in typing.get_args(annotation):
+        model = _nested_model(argument)
+        if model is not None:
+            return model
+    return None
+
+
+def parse_fields(value: Optional[str], model: Type[BaseModel]) -> Optional[FieldTree]:
+    """Selection tree of a `fields` parameter (None: every attribute).
+
+    Raises:
+        ValueError: For an attribute the model does not have
+    """
+    if value is None or not value.strip():
+        return None
+    tree: FieldTree = {}
+    for path in (part.strip() for part in value.split(",")):
+        if not path:
+            continue
+        node, current = tree, model
+        names = path.split(".")
+        for depth, name in enumerate(names):
+            if current is None or name not in current.model_fields:
+                raise ValueError(f"Unknown field: {path}")
+            last = depth == len(names) - 1
+            if last or node.get(name, {}) is None:
+                # The whole attribute (an ancestor selection wins over a nested one)
+                if last:
+                    node[name] = None
+                break
+            node = node.setdefault(name, {})
+            current = _nested_model(current.model_fields[name].annotation)
+    return tree or None
+
+
+def _select(value: Any, tree: FieldTree) -> Any:
+    if isinstance(value, list):
+        return [_select(item, tree) for item in value]
+    if not isinstance(value, dict):
+        return value
+    return {
+        name: value[name] if sub is None else _select(value[name], sub)
+        for name, sub in tree.items()
+        if name in value
+    }
+
+
+def select_fields(data: Dict[str, Any], tree: Optional[FieldTree]) -> Dict[str, Any]:
+    """The selected attributes of a serialized response (all of them without a selection)."""
+    if tree is None:
+        return data
+    return _select(data, tree)
+
+
+def field_names(model: Type[BaseModel]) -> List[str]:
+    """Top-level attributes of a model, for error details."""
+    return list(model.model_fields)
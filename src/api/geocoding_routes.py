@@ -0,0 +1,529 @@
+"""API routes for geocoding coordinates and addresses."""
+import logging
+from dataclasses import asdict
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Tuple
+from fastapi import APIRouter, HTTPException, Query, status
+from src.api.geojson import GEOJSON_CONTENT, geojson_response, wants_geojson
+from src.models.schemas import (
+    AdminArea,
+    ElevationResponse,
+    ErrorResponse,
+    GeocodeCandidateResponse,
+    GeocodeResponse,
+    GeocodingProviderStatusResponse,
+    LocaleInfo,
+    MaritimeZoneResponse,
+    ReverseGeocodeResponse,
+    TimezoneInfo,
+)
+from src.services.elevation_service import get_elevation_service, lookup_elevation_m
+from src.services.geocoding_service import (
+    GeocodeQuery,
+    GeocodingProviderError,
+    get_provider_chain,
+)
+from src.services.locale_service import get_locale_directory
+from src.services.lookup_cache_service import LookupType, get_lookup_cache
+from src.services.maritime_service import ZoneType, get_maritime_zone_service
+from src.services.region_group_service import get_region_groups
+from src.services.reverse_geocoding_service import (
+    AdminLevel,
+    ReverseGeocodeResult,
+    get_reverse_geocoding_service,
+)
+from src.services.timezone_service import TimezoneResult, get_timezone_service
+from src.spatial import geohash as geohash_codec
+from src.spatial import geojson
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter(prefix="/api/v1", tags=["geocoding"])
+
+
+def _resolve_point(
+    lat: Optional[float], lon: Optional[float], geohash: Optional[str]
+) -> Tuple[float, float]:
+    """Coordinate from lat/lon, or the center of a geohash cell.
+
+    Raises:
+        ValueError: If neither (or both) forms are given
+    """
+    if geohash is not None:
+        if lat is not None or lon is not None:
+            raise ValueError("Pass either lat/lon or geohash, not both")
+        latitude, longitude, _, _ = geohash_codec.decode(geohash)
+        return latitude, longitude
+    if lat is None or lon is None:
+        raise ValueError("lat and lon (or geohash) are required")
+    return lat, lon
+
+
+def timezone_info(result: TimezoneResult) -> TimezoneInfo:
+    """Convert a timezone result to the API model."""
+    return TimezoneInfo(
+        tzid=result.tzid,
+        utc_offset=result.utc_offset,
+        utc_offset_seconds=result.utc_offset_seconds,
+        dst=result.is_dst,
+        abbreviation=result.abbreviation,
+        source=result.source,
+    )
+
+
+def _reverse_response(
+    result: ReverseGeocodeResult, timezone: Optional[TimezoneResult] = None
+) -> ReverseGeocodeResponse:
+    """Convert a reverse geocoding result to the API response model."""
+    def _area(boundary):
+        return AdminArea(**boundary.to_dict()) if boundary is not None else None
+
+    locale = None
+    groups = []
+    if result.country is not None and result.country.code:
+        info = get_locale_directory().infer(
+            result.country.code,
+            [result.admin1.code] if result.admin1 is not None and result.admin1.code else [],
+        )
+        locale = LocaleInfo(**info.to_dict()) if info is not None else None
+        groups = get_region_groups().groups_for(result.country.code)
+
+    return ReverseGeocodeResponse(
+        latitude=result.latitude,
+        longitude=result.longitude,
+        country=_area(result.country),
+        admin1=_area(result.admin1),
+        admin2=_area(result.admin2),
+        city=_area(result.city),
+        postal_code=result.postal.code if result.postal is not None else None,
+        timezone=timezone_info(timezone) if timezone is not None else None,
+        locale=locale,
+        groups=groups,
+    )
+
+
+def reverse_geocode_point(
+    lat: float, lon: float, include_elevation: bool = False
+) -> ReverseGeocodeResponse:
+    """Reverse geocode one coordinate, including its timezone.
+
+    A timezone or elevation failure is logged and leaves the field out
+    rather than failing an otherwise successful lookup.
+
+    Raises:
+        ValueError: If the coordinate is out of range
+        LookupError: If no boundary contains the coordinate
+        RuntimeError: If no boundary data is loaded
+    """
+    cache = get_lookup_cache()
+    if cache is None:
+        return _reverse_geocode(lat, lon, include_elevation)[1]
+    return cache.read_through(
+        LookupType.REVERSE_GEOCODE,
+        get_reverse_geocoding_service().version,
+        {"lat": lat, "lon": lon, "elevation": include_elevation},
+        ReverseGeocodeResponse,
+        lambda: _reverse_geocode(lat, lon, include_elevation)[1],
+    )
+
+
+def warm_reverse_geocode(query: Dict[str, Any]) -> None:
+    """Cache the answer of a hot reverse geocoding lookup (see src.services.cache_warming_service)."""
+    reverse_geocode_point(query["lat"], query["lon"], query["elevation"])
+
+
+def _reverse_geocode(
+    lat: float, lon: float, include_elevation: bool = False
+) -> Tuple[ReverseGeocodeResult, ReverseGeocodeResponse]:
+    """Matched boundaries and API response of reverse_geocode_point."""
+    service = get_reverse_geocoding_service()
+    if not service.levels:
+        raise RuntimeError("Boundary data unavailable")
+
+    result = service.reverse(lat, lon)
+    if not result.matched:
+        raise LookupError(f"No boundary contains ({lat}, {lon})")
+
+    try:
+        timezone = get_timezone_service().lookup(lat, lon)
+    except RuntimeError as e:
+        logger.error(f"Timezone lookup failed for ({lat}, {lon}): {str(e)}")
+        timezone = None
+    response = _reverse_response(result, timezone)
+    if include_elevation:
+        response.elevation_m = lookup_elevation_m(lat, lon)
+    return result, response
+
+
+def _reverse_geojson(result: ReverseGeocodeResult, response: ReverseGeocodeResponse) -> dict:
+    """FeatureCollection of the queried point and each matched boundary."""
+    features = [
+        geojson.feature(
+            geojson.point(response.longitude, response.latitude),
+            response.model_dump(mode="json"),
+        )
+    ]
+    for boundary in (result.country, result.admin1, result.admin2, result.city, result.postal):
+        if boundary is not None:
+            features.append(
+                geojson.feature(boundary.geometry.to_geojson(), {"level": boundary.level, **boundary.to_dict()})
+            )
+    return geojson.feature_collection(features)
+
+
+@router.get(
+    "/reverse",
+    response_model=ReverseGeocodeResponse,
+    responses={
+        200: GEOJSON_CONTENT,
+        400: {"model": ErrorResponse, "description": "Invalid coordinate or format"},
+        404: {"model": ErrorResponse, "description": "No boundary contains the coordinate"},
+        503: {"model": ErrorResponse, "description": "Boundary data unavailable"},
+    },
+)
+async def reverse_geocode(
+    lat: Optional[float] = Query(None, description="Latitude in degrees"),
+    lon: Optional[float] = Query(None, description="Longitude in degrees"),
+    geohash: Optional[str] = Query(None, description="Geohash (alternative to lat/lon)"),
+    elevation: bool = Query(False, description="Include terrain elevation"),
+    output_format: str = Query("json", alias="format", description="json, or geojson for a GeoJSON FeatureCollection"),
+):
+    """Map a coordinate to country, admin1, admin2, city and postal code.
+
+    Uses locally loaded boundary polygons; no external provider is called.
+    With `format=geojson` the answer is a FeatureCollection: the queried
+    point with the JSON response as properties, then the polygon of every
+    matched boundary with its level, code and name.
+
+    Args:
+        lat: Latitude (-90 to 90)
+        lon: Longitude (-180 to 180)
+        geohash: Geohash whose cell center is reverse geocoded
+        elevation: Add the SRTM terrain elevation
+        output_format: Response format (json or geojson)
+
+    Returns:
+        ReverseGeocodeResponse: Admin areas containing the coordinate
+
+    Raises:
+        HTTPException: 400 for invalid input, 404 if nothing matches, 503 if no data
+    """
+    as_geojson = wants_geojson(output_format)
+    try:
+        lat, lon = _resolve_point(lat, lon, geohash)
+        if as_geojson:
+            result, response = _reverse_geocode(lat, lon, include_elevation=elevation)
+        else:
+            response = reverse_geocode_point(lat, lon, include_elevation=elevation)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except LookupError as e:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    if as_geojson:
+        return geojson_response(_reverse_geojson(result, response))
+    return response
+
+
+@router.get(
+    "/timezone",
+    response_model=TimezoneInfo,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid coordinate"},
+        503: {"model": ErrorResponse, "description": "Timezone data unavailable"},
+    },
+)
+async def lookup_timezone(
+    lat: float = Query(..., description="Latitude in degrees"),
+    lon: float = Query(..., description="Longitude in degrees"),
+    at: Optional[datetime] = Query(None, description="Instant for the offset (default: now)"),
+):
+    """Resolve the IANA timezone and UTC offset for a coordinate.
+
+    Coordinates outside every timezone polygon (open ocean) resolve to
+    the nautical Etc/GMT zone for their longitude.
+
+    Args:
+        lat: Latitude (-90 to 90)
+        lon: Longitude (-180 to 180)
+        at: ISO 8601 instant; offsets reflect DST at that time
+
+    Returns:
+        TimezoneInfo: Timezone name, offset and DST flag
+
+    Raises:
+        HTTPException: 400 for invalid input, 503 if the timezone dataset
+            and the installed tz database disagree
+    """
+    try:
+        result = get_timezone_service().lookup(lat, lon, at)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    return timezone_info(result)
+
+
+@router.get(
+    "/elevation",
+    response_model=ElevationResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid coordinate"},
+        404: {"model": ErrorResponse, "description": "No elevation data for the coordinate"},
+        503: {"model": ErrorResponse, "description": "Elevation data unavailable"},
+    },
+)
+async def lookup_elevation(
+    lat: float = Query(..., description="Latitude in degrees"),
+    lon: float = Query(..., description="Longitude in degrees"),
+):
+    """Terrain elevation at a coordinate from the SRTM tiles.
+
+    Args:
+        lat: Latitude (-90 to 90)
+        lon: Longitude (-180 to 180)
+
+    Returns:
+        ElevationResponse: Height above the geoid and the tile used
+
+    Raises:
+        HTTPException: 400 for invalid input, 404 if no tile covers the
+            coordinate (ocean, beyond SRTM coverage, or a void), 503 if no
+            tiles are installed or a tile cannot be read
+    """
+    try:
+        service = get_elevation_service()
+        if not service.tile_count:
+            raise RuntimeError("Elevation data unavailable")
+        result = service.lookup(lat, lon)
+        if result is None:
+            raise LookupError(f"No elevation data for ({lat}, {lon})")
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except LookupError as e:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    return ElevationResponse(
+        latitude=lat,
+        longitude=lon,
+        elevation_m=result.elevation_m,
+        resolution_arcsec=result.resolution_arcsec,
+        tile=result.tile,
+    )
+
+
+@router.get(
+    "/maritime-zone",
+    response_model=MaritimeZoneResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid coordinate"},
+        404: {"model": ErrorResponse, "description": "Coordinate is on land"},
+        503: {"model": ErrorResponse, "description": "Maritime boundary data unavailable"},
+    },
+)
+async def lookup_maritime_zone(
+    lat: float = Query(..., description="Latitude in degrees"),
+    lon: float = Query(..., description="Longitude in degrees"),
+):
+    """Resolve the territorial sea or EEZ containing a sea coordinate.
+
+    Sea outside every zone is high seas. The boundary layers cover sea
+    only, so a coordinate outside them but inside a loaded country
+    boundary is reported as land rather than high seas.
+
+    Args:
+        lat: Latitude (-90 to 90)
+        lon: Longitude (-180 to 180)
+
+    Returns:
+        MaritimeZoneResponse: Zone type, name and sovereign state
+
+    Raises:
+        HTTPException: 400 for invalid input, 404 on land, 503 if no
+            maritime boundaries are loaded
+    """
+    try:
+        service = get_maritime_zone_service()
+        if not service.zone_count:
+            raise RuntimeError("Maritime boundary data unavailable")
+        result = service.zone_at(lat, lon)
+        if result.zone_type == ZoneType.HIGH_SEAS and get_reverse_geocoding_service().boundary_at(
+            AdminLevel.COUNTRY, lat, lon
+        ):
+            raise LookupError(f"({lat}, {lon}) is on land")
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except LookupError as e:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+
+    zone = result.zone
+    return MaritimeZoneResponse(
+        latitude=lat,
+        longitude=lon,
+        zone_type=result.zone_type,
+        name=zone.name if zone else None,
+        sovereign=zone.sovereign if zone else None,
+        territory=zone.territory if zone else None,
+        mrgid=zone.mrgid if zone else None,
+        shared=zone.shared if zone else False,
+    )
+
+
+@router.get(
+    "/geocode",
+    response_model=GeocodeResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid query"},
+        502: {"model": ErrorResponse, "description": "All geocoding providers failed"},
+    },
+)
+async def geocode(
+    q: str = Query(..., description="Free-text address"),
+    limit: int = Query(5, ge=1, le=50, description="Maximum candidates"),
+    language: Optional[str] = Query(None, description="Preferred result language (e.g., en, de)"),
+    country: Optional[List[str]] = Query(None, description="Restrict to ISO 3166-1 alpha-2 countries"),
+):
+    """Geocode an address through the configured provider chain.
+
+    Providers are tried in GEOCODING_PROVIDERS order; the first one that
+    returns candidates answers. Providers over their daily budget or with
+    an open circuit are skipped.
+
+    Args:
+        q: Address to geocode
+        limit: Maximum number of candidates
+        language: Preferred language
+        country: Country filter (repeatable)
+
+    Returns:
+        GeocodeResponse: Normalized candidates with confidence scores
+
+    Raises:
+        HTTPException: 400 for invalid input, 502 if every provider failed
+    """
+    query = GeocodeQuery(
+        query=q, limit=limit, language=language, country_codes=country or ()
+    )
+    try:
+        result = await get_provider_chain().geocode(query)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except GeocodingProviderError as e:
+        raise HTTPException(
+            status_code=status.HTTP_502_BAD_GATEWAY,
+            detail={
+                "error_code": "E005",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+
+    return GeocodeResponse(
+        query=result.query,
+        provider=result.provider,
+        candidates=[
+            GeocodeCandidateResponse(**asdict(candidate))
+            for candidate in result.candidates
+        ],
+    )
+
+
+@router.get("/geocode/providers", response_model=List[GeocodingProviderStatusResponse])
+async def geocoding_provider_status():
+    """Report the circuit state and budget usage of each geocoding provider.
+
+    Returns:
+        List[GeocodingProviderStatusResponse]: Providers in failover order
+    """
+    return [
+        GeocodingProviderStatusResponse(**asdict(provider_status))
+        for provider_status in get_provider_chain().status()
+    ]
@@ -0,0 +1,448 @@
+"""API routes for geofence management, membership tests and alerts."""
+import asyncio
+import logging
+from datetime import datetime
+from typing import Any, Dict, Optional, Tuple
+from fastapi import APIRouter, Depends, File, Header, HTTPException, Query, Response, UploadFile, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_tenant_id
+from src.api.geojson import GEOJSON_CONTENT, GEOJSON_MEDIA_TYPE, geojson_response, wants_geojson
+from src.api.idempotency import IDEMPOTENCY_RESPONSES, idempotent
+from src.api.poi_routes import AUTH_RESPONSES
+from src.api.uploads import UPLOAD_RESPONSES, csv_text, import_response
+from src.config import get_config
+from src.database import get_db_session
+from src.models.database_models import Geofence
+from src.models.schemas import (
+    CsvImportResponse,
+    ErrorResponse,
+    GeofenceAlertInfo,
+    GeofenceAlertListResponse,
+    GeofenceContainsResponse,
+    GeofenceCreate,
+    GeofenceMatchResponse,
+    GeofencePositionRequest,
+    GeofencePositionResponse,
+    GeofenceResponse,
+)
+from src.services.csv_import_service import CsvImportService
+from src.services.elevation_service import lookup_elevation_m
+from src.services.geofence_alert_service import GeofenceAlertEvent, GeofenceAlertService
+from src.services.geofence_service import GeofenceService
+from src.services.live_event_service import alert_event, get_live_event_hub, position_event
+from src.services.webhook_service import WebhookService, get_webhook_dispatcher
+from src.spatial import crs as crs_module
+from src.spatial import geojson, wkt
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter(prefix="/api/v1", tags=["geofences"])
+
+
+def _bad_request(e: ValueError) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_400_BAD_REQUEST,
+        detail={
+            "error_code": "E002",
+            "error_message": str(e),
+            "details": None,
+        },
+    )
+
+
+def geofence_response(geofence: Geofence, crs: crs_module.CRS = crs_module.WGS84) -> GeofenceResponse:
+    """Convert a stored geofence to the API response model.
+
+    Raises:
+        ValueError: If the geometry cannot be expressed in the CRS
+    """
+    geometry = geofence.geometry
+    if crs != crs_module.WGS84:
+        geometry = crs_module.transform_geometry(
+            geometry, crs_module.transformer(crs_module.WGS84, crs)
+        )
+    return GeofenceResponse(
+        geofence_id=geofence.geofence_id,
+        name=geofence.name,
+        geometry=geometry,
+        crs=crs.name,
+        properties=geofence.properties,
+        s2_cell_count=len(geofence.s2_covering),
+        s2_interior_cell_count=len(geofence.s2_interior),
+        created_at=geofence.created_at,
+    )
+
+
+def request_geometry(request: GeofenceCreate) -> Tuple[Dict[str, Any], crs_module.CRS]:
+    """WGS84 GeoJSON geometry of a create or update request, and the request's CRS.
+
+    The geometry is read from geometry, wkt or wkb and converted from the
+    request's CRS to WGS84 for storage.
+
+    Raises:
+        ValueError: Unless exactly one geometry form is given, for malformed
+            WKT/WKB, or for an SRID contradicting the request's crs
+    """
+    forms = [form for form in ("geometry", "wkt", "wkb") if getattr(request, form) is not None]
+    if len(forms) != 1:
+        raise ValueError("Pass exactly one of geometry, wkt or wkb")
+    geometry, srid = request.geometry, None
+    if request.wkt is not None:
+        geometry, srid = wkt.parse_wkt(request.wkt)
+    elif request.wkb is not None:
+        geometry, srid = wkt.parse_wkb_hex(request.wkb)
+
+    crs = crs_module.parse_crs(request.crs) if request.crs else crs_module.WGS84
+    if srid:
+        embedded = crs_module.parse_crs(f"EPSG:{srid}")
+        if request.crs and embedded != crs:
+            raise ValueError(f"Geometry SRID {srid} contradicts crs {crs.name}")
+        crs = embedded
+    if crs != crs_module.WGS84:
+        geometry = crs_module.transform_geometry(
+            geometry, crs_module.transformer(crs, crs_module.WGS84)
+        )
+    return geometry, crs
+
+
+def geofence_feature(geofence: Geofence) -> Dict[str, Any]:
+    """GeoJSON Feature of a stored geofence (its other attributes as properties)."""
+    attributes = geofence_response(geofence).model_dump(mode="json", exclude={"geofence_id", "geometry", "crs"})
+    return geojson.feature(geofence.geometry, attributes, geofence.geofence_id)
+
+
+def get_geofence_or_404(service: GeofenceService, geofence_id: str) -> Geofence:
+    geofence = service.get_geofence(geofence_id)
+    if geofence is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"Geofence {geofence_id} not found",
+                "details": None,
+            },
+        )
+    return geofence
+
+
+@router.post(
+    "/geofences",
+    response_model=GeofenceResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={
+        201: GEOJSON_CONTENT,
+        400: {"model": ErrorResponse, "description": "Invalid geometry or format"},
+        **IDEMPOTENCY_RESPONSES,
+    },
+)
+async def create_geofence(
+    request: GeofenceCreate,
+    output_format: str = Query("json", alias="format", description="json, or geojson for a GeoJSON Feature"),
+    idempotency_key: Optional[str] = Header(None, description="Retry-safe request key"),
+    session: Session = Depends(get_db_session),
+):
+    """Create a geofence and precompute its S2 cell covering.
+
+    The geometry is GeoJSON, or WKT/WKB from GIS exports, whose EWKT/EWKB
+    SRID stands for `crs`. Geometry in another CRS is converted to WGS84
+    for storage and every ring is re-wound to RFC 7946 orientation; the
+    response echoes it in the request's CRS, or as a WGS84 GeoJSON
+    Feature with `format=geojson`. Retries carrying the same
+    Idempotency-Key get the first response instead of a second geofence.
+
+    Args:
+        request: Geofence name, geometry (GeoJSON, WKT or WKB), metadata and optional CRS
+        output_format: Response format (json or geojson)
+        idempotency_key: Idempotency-Key header
+        session: Database session (injected dependency)
+
+    Returns:
+        GeofenceResponse: Stored geofence
+
+    Raises:
+        HTTPException: 400 for invalid input, 409/422 for a conflicting
+            Idempotency-Key
+    """
+
+    as_geojson = wants_geojson(output_format)
+
+    def create() -> Any:
+        try:
+            geometry, crs = request_geometry(request)
+            geofence = GeofenceService(session).create_geofence(
+                request.name, geometry, request.properties
+            )
+            return geofence_feature(geofence) if as_geojson else geofence_response(geofence, crs)
+        except ValueError as e:
+            raise _bad_request(e)
+
+    response = idempotent(
+        idempotency_key,
+        "POST /geofences?format=geojson" if as_geojson else "POST /geofences",
+        request.model_dump(mode="json"),
+        status.HTTP_201_CREATED,
+        create,
+    )
+    if not as_geojson:
+        return response
+    if isinstance(response, Response):
+        # Stored or replayed by the idempotency layer, which answers in JSON
+        response.headers["content-type"] = GEOJSON_MEDIA_TYPE
+        return response
+    return geojson_response(response, status.HTTP_201_CREATED)
+
+
+@router.post(
+    "/geofences/import",
+    response_model=CsvImportResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={
+        400: {"model": ErrorResponse, "description": "CSV without name and geometry columns"},
+        **UPLOAD_RESPONSES,
+        **AUTH_RESPONSES,
+    },
+)
+async def import_geofences(
+    file: UploadFile = File(..., description="CSV with name, geometry (GeoJSON) and optional properties columns"),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Create geofences from an uploaded CSV, one per valid row.
+
+    Rows are validated one by one; rejected rows do not stop the import.
+    The response counts both and lists the first rejected rows, and the
+    outcome of every row (its geofence_id or error) is downloaded from
+    result_url, like a batch job's results.
+
+    Args:
+        file: Uploaded CSV (multipart)
+        tenant_id: Calling tenant (from the bearer token), owner of the results
+        session: Database session (injected dependency)
+
+    Returns:
+        CsvImportResponse: Row counts, first row errors and the results URL
+
+    Raises:
+        HTTPException: 400 for a CSV without the required columns, 401
+            without a valid token, 413 for an upload over UPLOAD_MAX_BYTES
+    """
+    try:
+        result = CsvImportService(session, get_config().batch_job_result_dir).import_geofences(
+            tenant_id, file.filename, csv_text(file)
+        )
+    except ValueError as e:
+        raise _bad_request(e)
+    return import_response(result)
+
+
+@router.get(
+    "/geofences/match",
+    response_model=GeofenceMatchResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid coordinate"}},
+)
+async def match_geofences(
+    lat: float = Query(..., description="Latitude in degrees"),
+    lon: float = Query(..., description="Longitude in degrees"),
+    elevation: bool = Query(False, description="Include terrain elevation"),
+    session: Session = Depends(get_db_session),
+):
+    """Find every stored geofence containing a coordinate.
+
+    Declared before /geofences/{geofence_id} so "match" is not taken as an ID.
+
+    Args:
+        lat: Latitude (-90 to 90)
+        lon: Longitude (-180 to 180)
+        elevation: Add the terrain elevation (e.g., for altitude-limited drone zones)
+        session: Database session (injected dependency)
+
+    Returns:
+        GeofenceMatchResponse: Matching geofence IDs
+
+    Raises:
+        HTTPException: 400 for invalid input
+    """
+    try:
+        geofence_ids = GeofenceService(session).matching_geofences(lat, lon)
+    except ValueError as e:
+        raise _bad_request(e)
+    return GeofenceMatchResponse(
+        latitude=lat,
+        longitude=lon,
+        geofence_ids=geofence_ids,
+        elevation_m=lookup_elevation_m(lat, lon) if elevation else None,
+    )
+
+
+def _notify(session: Session, tenant_id: str, alert: GeofenceAlertEvent) -> None:
+    """Schedule webhook delivery of an alert without waiting for it.
+
+    Subscribers are resolved now, while the request's session is open.
+    """
+    try:
+        targets = WebhookService(session).targets(tenant_id, alert.webhook_event)
+        if targets:
+            asyncio.create_task(get_webhook_dispatcher().dispatch(
+                targets, alert.webhook_event, GeofenceAlertInfo(**alert.to_dict()).model_dump(mode="json")
+            ))
+    except Exception as e:
+        logger.error(f"Failed to schedule webhook delivery of alert {alert.alert_id}: {str(e)}")
+
+
+@router.post(
+    "/geofences/positions",
+    response_model=GeofencePositionResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid entity ID or coordinate"},
+        **AUTH_RESPONSES,
+    },
+)
+async def report_position(
+    report: GeofencePositionRequest,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Report where a tracked entity is and raise geofence alerts.
+
+    Confirmed enter, exit and dwell events are stored and delivered to the
+    tenant's webhooks subscribed to geofence.enter, geofence.exit or
+    geofence.dwell. The position and the events are also pushed to live
+    subscribers of the entity (stale reports are not). Declared before
+    /geofences/{geofence_id}.
+
+    Args:
+        report: Entity ID, coordinate and optional time
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        GeofencePositionResponse: Geofences containing the position and the
+            alerts this report confirmed
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 without a valid token
+    """
+    try:
+        result = GeofenceAlertService(session).observe(
+            tenant_id, report.entity_id, report.latitude, report.longitude, report.timestamp
+        )
+    except ValueError as e:
+        raise _bad_request(e)
+    for alert in result.alerts:
+        _notify(session, tenant_id, alert)
+    if not result.stale:
+        hub = get_live_event_hub()
+        hub.publish(position_event(
+            tenant_id, result.entity_id, report.latitude, report.longitude, result.geofence_ids, report.timestamp
+        ))
+        for alert in result.alerts:
+            hub.publish(alert_event(tenant_id, alert))
+    return GeofencePositionResponse(
+        entity_id=result.entity_id,
+        geofence_ids=result.geofence_ids,
+        stale=result.stale,
+        alerts=[GeofenceAlertInfo(**a.to_dict()) for a in result.alerts],
+    )
+
+
+@router.get(
+    "/geofences/alerts",
+    response_model=GeofenceAlertListResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid cursor"}, **AUTH_RESPONSES},
+)
+async def list_geofence_alerts(
+    entity_id: Optional[str] = Query(None, description="Only alerts of this entity"),
+    geofence_id: Optional[str] = Query(None, description="Only alerts of this geofence"),
+    since: Optional[datetime] = Query(None, description="Only alerts at or after this time"),
+    limit: int = Query(100, ge=1, le=1000, description="Most alerts returned"),
+    cursor: Optional[str] = Query(None, description="next_cursor of the previous page"),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Stored geofence alerts of the calling tenant, newest first, a page at a time.
+
+    Declared before /geofences/{geofence_id}.
+    """
+    try:
+        page = GeofenceAlertService(session).alert_page(
+            tenant_id, entity_id, geofence_id, since, limit, cursor
+        )
+    except ValueError as e:
+        raise _bad_request(e)
+    return GeofenceAlertListResponse(
+        alerts=[GeofenceAlertInfo(**a.to_dict()) for a in page.items],
+        next_cursor=page.next_cursor,
+    )
+
+
+@router.get(
+    "/geofences/{geofence_id}",
+    response_model=GeofenceResponse,
+    responses={
+        200: GEOJSON_CONTENT,
+        400: {"model": ErrorResponse, "description": "Invalid CRS or format"},
+        404: {"model": ErrorResponse, "description": "Geofence not found"},
+    },
+)
+async def get_geofence(
+    geofence_id: str,
+    crs: Optional[str] = Query(None, description="CRS of the returned geometry (default EPSG:4326)"),
+    output_format: str = Query("json", alias="format", description="json, or geojson for a GeoJSON Feature"),
+    session: Session = Depends(get_db_session),
+):
+    """Fetch a geofence by ID, optionally with its geometry in another CRS or as a GeoJSON Feature."""
+    as_geojson = wants_geojson(output_format, crs)
+    geofence = get_geofence_or_404(GeofenceService(session), geofence_id)
+    if as_geojson:
+        return geojson_response(geofence_feature(geofence))
+    try:
+        return geofence_response(geofence, crs_module.parse_crs(crs) if crs else crs_module.WGS84)
+    except ValueError as e:
+        raise _bad_request(e)
+
+
+@router.get(
+    "/geofences/{geofence_id}/contains",
+    response_model=GeofenceContainsResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid coordinate"},
+        404: {"model": ErrorResponse, "description": "Geofence not found"},
+    },
+)
+async def geofence_contains(
+    geofence_id: str,
+    lat: float = Query(..., description="Latitude in degrees"),
+    lon: float = Query(..., description="Longitude in degrees"),
+    elevation: bool = Query(False, description="Include terrain elevation"),
+    session: Session = Depends(get_db_session),
+):
+    """Test whether a coordinate lies inside a geofence.
+
+    The S2 covering decides most points without an exact polygon test.
+
+    Args:
+        geofence_id: Geofence identifier
+        lat: Latitude (-90 to 90)
+        lon: Longitude (-180 to 180)
+        elevation: Add the terrain elevation
+        session: Database session (injected dependency)
+
+    Returns:
+        GeofenceContainsResponse: Membership result
+
+    Raises:
+        HTTPException: 400 for invalid input, 404 if the geofence does not exist
+    """
+    service = GeofenceService(session)
+    geofence = get_geofence_or_404(service, geofence_id)
+    try:
+        inside = service.contains(geofence, lat, lon)
+    except ValueError as e:
+        raise _bad_request(e)
+    return GeofenceContainsResponse(
+        geofence_id=geofence_id,
+        latitude=lat,
+        longitude=lon,
+        inside=inside,
+        elevation_m=lookup_elevation_m(lat, lon) if elevation else None,
+    )
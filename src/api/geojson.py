@@ -0,0 +1,61 @@
+"""GeoJSON output (format=geojson) for routes returning geometry.
+
+Such routes answer with their JSON model by default; `format=geojson`
+turns the answer into an RFC 7946 Feature or FeatureCollection (see
+src.spatial.geojson) served as application/geo+json. RFC 7946 positions
+are always WGS84, so `format=geojson` cannot be combined with a `crs`
+other than EPSG:4326.
+"""
+
+from typing import Any, Dict, Optional
+
+from fastapi import HTTPException, status
+from fastapi.responses import JSONResponse
+
+from src.spatial import crs as crs_module
+
+GEOJSON_MEDIA_TYPE = "application/geo+json"
+FORMATS = ("json", "geojson")
+
+# Merged into the 200 response of routes offering format=geojson
+GEOJSON_CONTENT = {"content": {GEOJSON_MEDIA_TYPE: {}}}
+
+
+def wants_geojson(output_format: str, crs: Optional[str] = None) -> bool:
+    """Whether a route should answer in GeoJSON.
+
+    Args:
+        output_format: `format` parameter (json or geojson)
+        crs: `crs` parameter of the route, if it has one
+
+    Raises:
+        HTTPException: 400 for an unknown format, or GeoJSON with a CRS
+            other than WGS84
+    """
+    if output_format not in FORMATS:
+        raise _bad_request(f"Unknown format: {output_format}", {"supported": list(FORMATS)})
+    if output_format != "geojson":
+        return False
+    if crs:
+        try:
+            parsed = crs_module.parse_crs(crs)
+        except ValueError as e:
+            raise _bad_request(str(e))
+        if parsed != crs_module.WGS84:
+            raise _bad_request(
+                f"GeoJSON output is WGS84 (RFC 7946) and cannot use {parsed.name}; "
+                "use format=json for other reference systems"
+            )
+    return True
+
+
+def geojson_response(document: Dict[str, Any], status_code: int = status.HTTP_200_OK) -> JSONResponse:
+    """application/geo+json response of a Feature or FeatureCollection."""
+    return JSONResponse(document, status_code=status_code, media_type=GEOJSON_MEDIA_TYPE)
+
+
+def _bad_request(message: str, details: Optional[Dict[str, Any]] = None) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_400_BAD_REQUEST,
+        detail={"error_code": "E002", "error_message": message, "details": details},
+    )
@@ -0,0 +1,297 @@
+"""GraphQL endpoint composing IP lookups, timezones, risk and geofence membership.
+
+A single query fetches what would otherwise take several REST calls, and
+only the selected fields are computed: the timezone, risk score, rule
+decision and geofence checks of a located address each run only when the
+query asks for them. Field names match the REST JSON (snake_case).
+
+    {
+      ip(address: "81.2.69.142") {
+        country_iso_code
+        city_name
+        timezone { tzid utc_offset }
+        risk { score reasons { code } }
+        geofences
+        depot: inside_geofence(geofence_id: "...")
+      }
+    }
+
+Requires the optional `graphql` extra (graphql-core).
+"""
+from dataclasses import dataclass
+from functools import wraps
+from typing import Any, Callable, Dict, List, Optional
+
+from fastapi import APIRouter, Depends, Header, HTTPException, status
+from fastapi.concurrency import run_in_threadpool
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_optional_tenant_id
+from src.api.geocoding_routes import timezone_info
+from src.api.lookup_routes import lookup_ip_address, score_lookup, tenant_overrides
+from src.database import get_db_session
+from src.models.schemas import ErrorResponse, GraphQLRequest, RuleEvaluationInfo
+from src.services.batch_lookup_service import ERROR_CODES
+from src.services.enrichment_service import enabled_enrichments
+from src.services.geofence_service import GeofenceService
+from src.services.rule_service import get_rule_engine, rule_context
+from src.services.timezone_service import get_timezone_service
+
+router = APIRouter(prefix="/api/v1", tags=["graphql"])
+
+SCHEMA = """
+type Query {
+  "Geolocate an IPv4 or IPv6 address (as GET /lookup/ip/{ip})"
+  ip(address: String!): IpLocation
+  "A coordinate, for its timezone and geofence membership"
+  point(lat: Float!, lon: Float!): Point
+}
+
+type IpLocation {
+  ip_address: String!
+  ip_version: Int!
+  network: String!
+  country_iso_code: String
+  country_name: String
+  continent_code: String
+  city_name: String
+  postal_code: String
+  latitude: Float
+  longitude: Float
+  accuracy_radius_km: Float
+  confidence: Float!
+  groups: [String!]
+  asn: Asn
+  anonymizer: Anonymizer
+  "Timezone and current UTC offset at the located coordinate"
+  timezone: Timezone
+  "Risk score (null when risk is not enabled for the API key)"
+  risk: Risk
+  "Detection rule decision (null without rules)"
+  rules: Rules
+  "IDs of the geofences containing the located coordinate"
+  geofences: [String!]!
+  inside_geofence(geofence_id: String!): Boolean!
+}
+
+type Point {
+  latitude: Float!
+  longitude: Float!
+  timezone: Timezone
+  geofences: [String!]!
+  inside_geofence(geofence_id: String!): Boolean!
+}
+
+type Asn {
+  "Float, as 4-byte ASNs exceed GraphQL's 32-bit Int"
+  number: Float
+  organization: String
+  isp: String
+  connection_type: String
+}
+
+type Anonymizer {
+  vpn: Boolean
+  tor: Boolean
+  proxy: Boolean
+  residential_proxy: Boolean
+  hosting: Boolean
+}
+
+type Timezone {
+  tzid: String!
+  utc_offset: String!
+  utc_offset_seconds: Int!
+  dst: Boolean!
+  abbreviation: String
+}
+
+type Risk {
+  score: Int!
+  reasons: [RiskReason!]!
+}
+
+type RiskReason {
+  code: String!
+  signal: String!
+  weight: Float!
+  detail: String
+}
+
+type Rules {
+  decision: String!
+  matches: [RuleMatch!]!
+}
+
+type RuleMatch {
+  rule_id: String!
+  action: String!
+}
+"""
+
+
+SERVICE_ERRORS = tuple(error_type for error_type, _ in ERROR_CODES)
+
+
+@dataclass
+class _Point:
+    latitude: float
+    longitude: float
+
+
+def _resolver(fn: Callable) -> Callable:
+    """Report service errors as GraphQL errors carrying the REST error code."""
+
+    @wraps(fn)
+    def resolve(obj, info, **kwargs):
+        from graphql import GraphQLError
+
+        try:
+            return fn(obj, info.context, **kwargs)
+        except SERVICE_ERRORS as e:
+            code = next(code for error_type, code in ERROR_CODES if isinstance(e, error_type))
+            raise GraphQLError(str(e), extensions={"error_code": code})
+
+    return resolve
+
+
+@_resolver
+def _resolve_ip(_, context: Dict[str, Any], address: str):
+    overrides = tenant_overrides(context["tenant_id"], context["session"])
+    return lookup_ip_address(address, context["api_key"], overrides=overrides)
+
+
+@_resolver
+def _resolve_point(_, context: Dict[str, Any], lat: float, lon: float):
+    if not -90 <= lat <= 90 or not -180 <= lon <= 180:
+        raise ValueError(f"Coordinate out of range: ({lat}, {lon})")
+    return _Point(lat, lon)
+
+
+@_resolver
+def _resolve_timezone(located, context: Dict[str, Any]):
+    if located.latitude is None or located.longitude is None:
+        return None
+    return timezone_info(get_timezone_service().lookup(located.latitude, located.longitude))
+
+
+def _risk(located, context: Dict[str, Any]):
+    """The lookup's risk score, computed once and only if enabled for the API key."""
+    if located.risk is None and "risk" in enabled_enrichments(context["api_key"]):
+        located.risk = score_lookup(located, None, context["tenant_id"], context["session"])
+    return located.risk
+
+
+@_resolver
+def _resolve_risk(located, context: Dict[str, Any]):
+    return _risk(located, context)
+
+
+@_resolver
+def _resolve_rules(located, context: Dict[str, Any]):
+    engine = get_rule_engine()
+    if not engine.rules:
+        return None
+    _risk(located, context)
+    evaluation = engine.evaluate(rule_context(located), located.ip_address)
+    return RuleEvaluationInfo(**evaluation.to_dict())
+
+
+@_resolver
+def _resolve_geofences(located, context: Dict[str, Any]) -> List[str]:
+    if located.latitude is None or located.longitude is None:
+        return []
+    return GeofenceService(context["session"]).matching_geofences(located.latitude, located.longitude)
+
+
+@_resolver
+def _resolve_inside_geofence(located, context: Dict[str, Any], geofence_id: str) -> bool:
+    service = GeofenceService(context["session"])
+    geofence = service.get_geofence(geofence_id)
+    if geofence is None:
+        raise LookupError(f"Geofence {geofence_id} not found")
+    if located.latitude is None or located.longitude is None:
+        return False
+    return service.contains(geofence, located.latitude, located.longitude)
+
+
+# Global GraphQL schema (built on first use)
+_schema: Optional[Any] = None
+
+
+def get_graphql_schema() -> Any:
+    """Get the executable GraphQL schema.
+
+    Raises:
+        RuntimeError: If graphql-core is not installed
+    """
+    global _schema
+    if _schema is None:
+        try:
+            from graphql import build_schema
+        except ImportError:
+            raise RuntimeError("GraphQL requires the optional 'graphql' extra (pip install -e '.[graphql]')")
+        schema = build_schema(SCHEMA)
+        schema.query_type.fields["ip"].resolve = _resolve_ip
+        schema.query_type.fields["point"].resolve = _resolve_point
+        for type_name in ("IpLocation", "Point"):
+            fields = schema.type_map[type_name].fields
+            fields["timezone"].resolve = _resolve_timezone
+            fields["geofences"].resolve = _resolve_geofences
+            fields["inside_geofence"].resolve = _resolve_inside_geofence
+        schema.type_map["IpLocation"].fields["risk"].resolve = _resolve_risk
+        schema.type_map["IpLocation"].fields["rules"].resolve = _resolve_rules
+        _schema = schema
+    return _schema
+
+
+@router.post(
+    "/graphql",
+    responses={
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        503: {"model": ErrorResponse, "description": "GraphQL support not installed"},
+    },
+)
+async def graphql_query(
+    request: GraphQLRequest,
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Run a GraphQL query against the lookup, timezone, risk and geofence services.
+
+    Errors are reported per field in `errors`, following the GraphQL
+    response format, with the REST error code in `extensions.error_code`.
+
+    Args:
+        request: Query, variables and operation name
+        x_api_key: Caller API key; selects which enrichments are included
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        dict: `data` and, if any field failed, `errors`
+
+    Raises:
+        HTTPException: 401 for an invalid token, 503 if graphql-core is not installed
+    """
+    try:
+        schema = get_graphql_schema()
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={"error_code": "E003", "error_message": str(e), "details": None},
+        )
+    from graphql import graphql_sync
+
+    result = await run_in_threadpool(
+        graphql_sync,
+        schema,
+        request.query,
+        variable_values=request.variables,
+        operation_name=request.operation_name,
+        context_value={"tenant_id": tenant_id, "api_key": x_api_key, "session": session},
+    )
+    body: Dict[str, Any] = {"data": result.data}
+    if result.errors:
+        body["errors"] = [error.formatted for error in result.errors]
+    return body
@@ -0,0 +1,79 @@
+"""API routes for named country groups."""
+from typing import List
+
+from fastapi import APIRouter, HTTPException, status
+from src.models.schemas import CountryGroupsResponse, ErrorResponse, RegionGroupResponse
+from src.services.region_group_service import get_region_groups
+
+router = APIRouter(prefix="/api/v1", tags=["groups"])
+
+
+@router.get("/groups", response_model=List[RegionGroupResponse])
+async def list_groups():
+    """List the configured country groups and their members.
+
+    Returns:
+        List of RegionGroupResponse, sorted by name
+    """
+    groups = get_region_groups()
+    return [RegionGroupResponse(name=name, countries=groups.members(name)) for name in groups.names]
+
+
+@router.get(
+    "/groups/{name}",
+    response_model=RegionGroupResponse,
+    responses={404: {"model": ErrorResponse, "description": "Group not defined"}},
+)
+async def get_group(name: str):
+    """Members of one group.
+
+    Args:
+        name: Group name (case-sensitive)
+
+    Returns:
+        RegionGroupResponse: Group and its countries
+
+    Raises:
+        HTTPException: 404 if no group has this name
+    """
+    countries = get_region_groups().members(name)
+    if countries is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"Region group {name} not found",
+                "details": None,
+            },
+        )
+    return RegionGroupResponse(name=name, countries=countries)
+
+
+@router.get(
+    "/countries/{country_code}/groups",
+    response_model=CountryGroupsResponse,
+    responses={400: {"model": ErrorResponse, "description": "Malformed country code"}},
+)
+async def get_country_groups(country_code: str):
+    """Groups containing a country.
+
+    Args:
+        country_code: ISO 3166-1 alpha-2 country code
+
+    Returns:
+        CountryGroupsResponse: Sorted group names (empty if the country is in none)
+
+    Raises:
+        HTTPException: 400 if the code is not two letters
+    """
+    country = country_code.strip().upper()
+    if len(country) != 2 or not country.isalpha():
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": f"Invalid country code {country_code!r}: expected ISO 3166-1 alpha-2",
+                "details": None,
+            },
+        )
+    return CountryGroupsResponse(country_code=country, groups=get_region_groups().groups_for(country))
@@ -0,0 +1,80 @@
+"""Kubernetes probe endpoints: /livez, /readyz and /healthz."""
+import asyncio
+import time
+
+from fastapi import APIRouter, Response, status
+
+from src.config import get_config
+from src.models.schemas import DependencyHealthInfo, ProbeResponse
+from src.services.health_service import HealthReport, HealthService, HealthStatus
+
+router = APIRouter(tags=["health"])
+
+_STARTED = time.monotonic()
+
+
+def _probe_response(report: HealthReport, response: Response) -> ProbeResponse:
+    """Probe body of a report; 503 when a critical check failed."""
+    if report.status == HealthStatus.FAIL:
+        response.status_code = status.HTTP_503_SERVICE_UNAVAILABLE
+    response.headers["Cache-Control"] = "no-store"
+    return ProbeResponse(
+        status=report.status,
+        version=get_config().app_version,
+        uptime_seconds=round(time.monotonic() - _STARTED, 3),
+        checks={
+            check.name: DependencyHealthInfo(
+                status=check.status,
+                critical=check.critical,
+                latency_ms=check.latency_ms,
+                detail=check.detail,
+            )
+            for check in report.checks
+        },
+    )
+
+
+@router.get(
+    "/livez",
+    response_model=ProbeResponse,
+)
+async def livez(response: Response):
+    """Liveness probe: the process is serving HTTP.
+
+    Checks no dependency, so an outage of the database or Redis never
+    makes Kubernetes restart otherwise healthy pods.
+    """
+    return _probe_response(HealthReport(), response)
+
+
+@router.get(
+    "/readyz",
+    response_model=ProbeResponse,
+    responses={503: {"model": ProbeResponse, "description": "A critical dependency failed"}},
+)
+async def readyz(response: Response):
+    """Readiness probe: database, GeoIP dataset and configured Redis servers.
+
+    Returns:
+        ProbeResponse: Per-dependency status; 503 when a critical check failed
+    """
+    report = await asyncio.to_thread(HealthService().readiness)
+    return _probe_response(report, response)
+
+
+@router.get(
+    "/healthz",
+    response_model=ProbeResponse,
+    responses={503: {"model": ProbeResponse, "description": "A critical dependency failed"}},
+)
+async def healthz(response: Response):
+    """Deep health: the readiness checks plus GeoIP dataset age.
+
+    A dataset older than HEALTH_DATASET_MAX_AGE_DAYS reports `degraded`
+    with 200, since lookups are still answered.
+
+    Returns:
+        ProbeResponse: Per-dependency status; 503 when a critical check failed
+    """
+    report = await asyncio.to_thread(HealthService().health)
+    return _probe_response(report, response)
@@ -0,0 +1,75 @@
+"""Idempotency-Key handling for create routes (see idempotency_service)."""
+from typing import Any, Callable, Optional
+
+from fastapi import HTTPException, status
+from fastapi.encoders import jsonable_encoder
+from fastapi.responses import JSONResponse
+
+from src.models.schemas import ErrorResponse
+from src.services.idempotency_service import (
+    IdempotencyConflict,
+    get_idempotency_service,
+    request_fingerprint,
+)
+
+REPLAYED_HEADER = "Idempotent-Replayed"
+
+IDEMPOTENCY_RESPONSES = {
+    409: {"model": ErrorResponse, "description": "Idempotency-Key in use by a request still in progress"},
+    422: {"model": ErrorResponse, "description": "Idempotency-Key already used for a different request"},
+}
+
+
+def _error(status_code: int, code: str, e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status_code,
+        detail={"error_code": code, "error_message": str(e), "details": None},
+    )
+
+
+def idempotent(
+    idempotency_key: Optional[str],
+    scope: str,
+    payload: Any,
+    status_code: int,
+    create: Callable[[], Any],
+) -> Any:
+    """Run a create handler at most once per Idempotency-Key.
+
+    Without a key the handler just runs. With one, the first request runs
+    it and stores its response; retries with the same key and payload get
+    that response again, marked with the Idempotent-Replayed header.
+
+    Args:
+        idempotency_key: Idempotency-Key header (None: not idempotent)
+        scope: Caller and route the key belongs to, e.g. "acme:POST /jobs"
+        payload: JSON-serializable request content (reused keys must match it)
+        status_code: Status of a successful response
+        create: Handler creating the resource (HTTPExceptions release the key)
+
+    Raises:
+        HTTPException: 400 for a malformed key, 409 while the first request
+            runs, 422 if the key was used for a different payload
+    """
+    if idempotency_key is None:
+        return create()
+    service = get_idempotency_service()
+    fingerprint = request_fingerprint(payload)
+    try:
+        entry = service.begin(scope, idempotency_key, fingerprint)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except IdempotencyConflict as e:
+        code = status.HTTP_409_CONFLICT if e.in_progress else status.HTTP_422_UNPROCESSABLE_ENTITY
+        raise _error(code, "E009", e)
+    if entry is not None:
+        return JSONResponse(entry.body, status_code=entry.status_code, headers={REPLAYED_HEADER: "true"})
+
+    try:
+        result = create()
+    except BaseException:
+        service.release(scope, idempotency_key)
+        raise
+    body = jsonable_encoder(result)
+    service.complete(scope, idempotency_key, fingerprint, status_code, body)
+    return JSONResponse(body, status_code=status_code)
@@ -0,0 +1,137 @@
+"""API routes for tenant IP range overrides."""
+from typing import List
+
+from fastapi import APIRouter, Depends, HTTPException, Response, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_tenant_id
+from src.api.poi_routes import AUTH_RESPONSES
+from src.database import get_db_session
+from src.models.database_models import IpRangeOverride
+from src.models.schemas import ErrorResponse, IpOverrideCreate, IpOverrideResponse
+from src.services.ip_override_service import IpOverrideService
+
+router = APIRouter(prefix="/api/v1", tags=["ip-overrides"])
+
+
+def _to_response(override: IpRangeOverride) -> IpOverrideResponse:
+    """Convert a stored override to the API response model."""
+    return IpOverrideResponse(
+        override_id=override.override_id,
+        network=override.network,
+        label=override.label,
+        latitude=override.latitude,
+        longitude=override.longitude,
+        accuracy_radius_km=override.accuracy_radius_km,
+        country_iso_code=override.country_iso_code,
+        city_name=override.city_name,
+        properties=override.properties,
+        created_at=override.created_at,
+    )
+
+
+def _get_or_404(service: IpOverrideService, tenant_id: str, override_id: str) -> IpRangeOverride:
+    override = service.get_override(tenant_id, override_id)
+    if override is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"IP override {override_id} not found",
+                "details": None,
+            },
+        )
+    return override
+
+
+@router.post(
+    "/ip-overrides",
+    response_model=IpOverrideResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid or duplicate override"},
+        **AUTH_RESPONSES,
+    },
+)
+async def create_ip_override(
+    request: IpOverrideCreate,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Register a CIDR -> site mapping for the calling tenant.
+
+    Args:
+        request: Network, site label and location
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        IpOverrideResponse: Stored override (network in canonical form)
+
+    Raises:
+        HTTPException: 400 for invalid input or an existing override for
+            the network, 401 without a valid token
+    """
+    try:
+        override = IpOverrideService(session).create_override(tenant_id, **request.model_dump())
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={"error_code": "E002", "error_message": str(e), "details": None},
+        )
+    return _to_response(override)
+
+
+@router.get(
+    "/ip-overrides",
+    response_model=List[IpOverrideResponse],
+    responses=AUTH_RESPONSES,
+)
+async def list_ip_overrides(
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """List the calling tenant's IP range overrides."""
+    return [_to_response(o) for o in IpOverrideService(session).list_overrides(tenant_id)]
+
+
+@router.get(
+    "/ip-overrides/{override_id}",
+    response_model=IpOverrideResponse,
+    responses={
+        404: {"model": ErrorResponse, "description": "Override not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def get_ip_override(
+    override_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Fetch one of the calling tenant's IP range overrides."""
+    return _to_response(_get_or_404(IpOverrideService(session), tenant_id, override_id))
+
+
+@router.delete(
+    "/ip-overrides/{override_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    responses={
+        404: {"model": ErrorResponse, "description": "Override not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def delete_ip_override(
+    override_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Delete an IP range override; lookups fall back to the dataset."""
+    service = IpOverrideService(session)
+    override = _get_or_404(service, tenant_id, override_id)
+    try:
+        service.delete_override(override)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail={"error_code": "E999", "error_message": str(e), "details": None},
+        )
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
@@ -0,0 +1,275 @@
+"""API routes for asynchronous batch lookup jobs."""
+import os
+import uuid
+from functools import partial
+from typing import Any, Dict, Optional
+
+from fastapi import APIRouter, Depends, File, Header, HTTPException, UploadFile, status
+from fastapi.responses import FileResponse
+from sqlalchemy.orm import Session
+
+from src.api.dependencies import get_tenant_id
+from src.api.idempotency import IDEMPOTENCY_RESPONSES, idempotent
+from src.api.lookup_routes import batch_result, lookup_batch_item, tenant_overrides
+from src.api.poi_routes import AUTH_RESPONSES
+from src.api.uploads import UPLOAD_RESPONSES, save_upload
+from src.config import get_config
+from src.database import get_db_session
+from src.models.database_models import BatchJob
+from src.models.schemas import BatchJobRequest, BatchJobResponse, BatchLookupItem, ErrorResponse
+from src.services.batch_job_service import BatchJobService, JobStatus, Row, get_batch_job_runner, read_rows
+from src.services.batch_lookup_service import BatchOutcome
+from src.services.ip_override_service import OverrideTable
+
+router = APIRouter(prefix="/api/v1", tags=["jobs"])
+
+
+def _not_found(job_id: str) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_404_NOT_FOUND,
+        detail={
+            "error_code": "E004",
+            "error_message": f"Job {job_id} not found",
+            "details": None,
+        },
+    )
+
+
+def _to_response(job: BatchJob) -> BatchJobResponse:
+    progress = None
+    if job.total_rows is not None:
+        progress = round(job.processed_rows / job.total_rows, 4) if job.total_rows else 1.0
+    return BatchJobResponse(
+        job_id=job.job_id,
+        status=job.status,
+        source=job.source,
+        total_rows=job.total_rows,
+        processed_rows=job.processed_rows,
+        failed_rows=job.failed_rows,
+        progress=progress,
+        error=job.error,
+        result_url=f"/api/v1/jobs/{job.job_id}/result" if job.status == JobStatus.SUCCEEDED else None,
+        created_at=job.created_at,
+        started_at=job.started_at,
+        finished_at=job.finished_at,
+    )
+
+
+def _coordinate(value: Optional[str], name: str) -> Optional[float]:
+    if value is None:
+        return None
+    try:
+        return float(value)
+    except ValueError:
+        raise ValueError(f"Invalid {name}: {value}")
+
+
+def lookup_row(row: Row, api_key: Optional[str] = None, overrides: Optional[OverrideTable] = None) -> Any:
+    """Look up one input row, as an item of POST /api/v1/lookup/batch."""
+    item = BatchLookupItem(
+        ip=row["ip"], lat=_coordinate(row["lat"], "lat"), lon=_coordinate(row["lon"], "lon")
+    )
+    return lookup_batch_item(item, api_key, overrides=overrides)
+
+
+def result_record(outcome: BatchOutcome) -> Dict[str, Any]:
+    """NDJSON line of a row: the batch endpoint's per-item result."""
+    return batch_result(outcome).model_dump(mode="json", exclude_none=True)
+
+
+@router.post(
+    "/jobs",
+    response_model=BatchJobResponse,
+    status_code=status.HTTP_202_ACCEPTED,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid source reference"},
+        **AUTH_RESPONSES,
+        **IDEMPOTENCY_RESPONSES,
+    },
+)
+async def submit_job(
+    request: BatchJobRequest,
+    x_api_key: Optional[str] = Header(None),
+    idempotency_key: Optional[str] = Header(None, description="Retry-safe request key"),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Queue a batch lookup over an input file and return at once.
+
+    The job downloads the file and looks up every row in the background;
+    poll GET /jobs/{job_id} for progress and download the results from
+    GET /jobs/{job_id}/result once it has succeeded. Retries carrying the
+    same Idempotency-Key get the first job instead of a second one.
+
+    Args:
+        request: Input file reference
+        x_api_key: Caller API key; selects which enrichments IP results include
+        idempotency_key: Idempotency-Key header
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        BatchJobResponse: The queued job
+
+    Raises:
+        HTTPException: 400 for an invalid source, 401 without a valid token,
+            409/422 for a conflicting Idempotency-Key
+    """
+
+    def submit() -> BatchJobResponse:
+        try:
+            job = BatchJobService(session).create(
+                tenant_id, request.source, get_config().batch_job_input_dir or None
+            )
+        except ValueError as e:
+            raise HTTPException(
+                status_code=status.HTTP_400_BAD_REQUEST,
+                detail={
+                    "error_code": "E002",
+                    "error_message": str(e),
+                    "details": None,
+                },
+            )
+        # Loaded here: the session must not be shared with the worker threads
+        overrides = tenant_overrides(tenant_id, session)
+        get_batch_job_runner().submit(
+            job.job_id, partial(lookup_row, api_key=x_api_key, overrides=overrides), result_record
+        )
+        return _to_response(job)
+
+    return idempotent(
+        idempotency_key,
+        f"{tenant_id}:POST /jobs",
+        request.model_dump(mode="json"),
+        status.HTTP_202_ACCEPTED,
+        submit,
+    )
+
+
+@router.post(
+    "/jobs/upload",
+    response_model=BatchJobResponse,
+    status_code=status.HTTP_202_ACCEPTED,
+    responses={
+        400: {"model": ErrorResponse, "description": "CSV without an ip column or lat and lon columns"},
+        **UPLOAD_RESPONSES,
+        **AUTH_RESPONSES,
+    },
+)
+async def upload_job(
+    file: UploadFile = File(..., description="CSV with an ip column or lat and lon columns"),
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Queue a batch lookup over an uploaded CSV file and return at once.
+
+    The multipart `file` is processed like a job's downloaded input, with
+    the source recorded as upload:<filename>: poll GET /jobs/{job_id} and
+    download the results from GET /jobs/{job_id}/result.
+
+    Args:
+        file: Uploaded CSV
+        x_api_key: Caller API key; selects which enrichments IP results include
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        BatchJobResponse: The queued job
+
+    Raises:
+        HTTPException: 400 for a CSV without the lookup columns, 401
+            without a valid token, 413 for an upload over UPLOAD_MAX_BYTES
+    """
+    runner = get_batch_job_runner()
+    os.makedirs(runner.result_dir, exist_ok=True)
+    staged = os.path.join(runner.result_dir, f"upload-{uuid.uuid4()}.part")
+    try:
+        save_upload(file, staged)
+        rows = read_rows(staged)
+        try:
+            next(rows, None)
+        except ValueError as e:
+            raise HTTPException(
+                status_code=status.HTTP_400_BAD_REQUEST,
+                detail={"error_code": "E002", "error_message": str(e), "details": None},
+            )
+        finally:
+            rows.close()
+        job = BatchJobService(session).create_upload(tenant_id, file.filename)
+        os.replace(staged, runner.input_path(job.job_id))
+    finally:
+        if os.path.exists(staged):
+            os.remove(staged)
+
+    overrides = tenant_overrides(tenant_id, session)
+    runner.submit(job.job_id, partial(lookup_row, api_key=x_api_key, overrides=overrides), result_record)
+    return _to_response(job)
+
+
+@router.get(
+    "/jobs/{job_id}",
+    response_model=BatchJobResponse,
+    responses={
+        404: {"model": ErrorResponse, "description": "Job not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def get_job(
+    job_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Status and progress of one of the calling tenant's jobs."""
+    job = BatchJobService(session).get(tenant_id, job_id)
+    if job is None:
+        raise _not_found(job_id)
+    return _to_response(job)
+
+
+@router.get(
+    "/jobs/{job_id}/result",
+    response_class=FileResponse,
+    responses={
+        200: {"content": {"application/x-ndjson": {}}, "description": "One result line per input row"},
+        404: {"model": ErrorResponse, "description": "Job or result not found"},
+        409: {"model": ErrorResponse, "description": "Job has not succeeded"},
+        **AUTH_RESPONSES,
+    },
+)
+async def download_job_result(
+    job_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Download a succeeded job's results as NDJSON.
+
+    Each line is {"index", "ip" | "location" | "error"} for the input row
+    at `index` (0-based, header excluded), in input order.
+
+    Raises:
+        HTTPException: 404 for an unknown job or a removed result file,
+            409 while the job is queued or running or if it failed
+    """
+    job = BatchJobService(session).get(tenant_id, job_id)
+    if job is None:
+        raise _not_found(job_id)
+    if job.status != JobStatus.SUCCEEDED:
+        raise HTTPException(
+            status_code=status.HTTP_409_CONFLICT,
+            detail={
+                "error_code": "E008",
+                "error_message": f"Job {job_id} has not succeeded",
+                "details": {"status": job.status},
+            },
+        )
+    if not job.result_path or not os.path.isfile(job.result_path):
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"Result of job {job_id} is no longer available",
+                "details": None,
+            },
+        )
+    return FileResponse(job.result_path, media_type="application/x-ndjson", filename=f"{job_id}.ndjson")
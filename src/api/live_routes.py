@@ -0,0 +1,225 @@
+"""Streaming routes for live tracking and geofence events (WebSocket and SSE)."""
+import asyncio
+import json
+import logging
+from typing import AsyncIterator, List, Optional
+
+from fastapi import APIRouter, Header, HTTPException, Query, Request, WebSocket, status
+from fastapi.responses import StreamingResponse
+
+from src.api.poi_routes import AUTH_RESPONSES
+from src.models.schemas import ErrorResponse
+from src.openapi import BEARER_SCHEME
+from src.services.auth_service import AuthenticationError, get_token_verifier
+from src.services.live_event_service import (
+    EVENT_DETECTION,
+    LiveEvent,
+    Subscription,
+    get_live_event_hub,
+)
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter(prefix="/api/v1", tags=["live"])
+
+# Event types of the SSE feed (positions are only streamed over WebSocket)
+FEED_EVENTS = ("geofence.enter", "geofence.exit", "geofence.dwell", EVENT_DETECTION)
+
+
+def _stream_tenant(authorization: Optional[str], token: Optional[str]) -> str:
+    """Tenant of a streaming client.
+
+    Browsers cannot set headers on WebSocket or EventSource requests, so
+    the bearer token may also be passed as the `token` query parameter.
+
+    Raises:
+        AuthenticationError: For a missing or invalid token
+        RuntimeError: If token authentication is not configured
+    """
+    if token is None:
+        scheme, _, header_token = (authorization or "").partition(" ")
+        if scheme.lower() != "bearer":
+            raise AuthenticationError("Bearer token required")
+        token = header_token
+    if not token.strip():
+        raise AuthenticationError("Bearer token required")
+    return get_token_verifier().verify(token.strip()).tenant_id
+
+
+async def _wait_for_disconnect(websocket: WebSocket) -> None:
+    """Consume client messages (ignored) until the client goes away."""
+    while True:
+        message = await websocket.receive()
+        if message["type"] == "websocket.disconnect":
+            return
+
+
+async def _stream(websocket: WebSocket, subscription: Subscription) -> None:
+    """Send the subscription's events until the client disconnects."""
+    disconnected = asyncio.ensure_future(_wait_for_disconnect(websocket))
+    try:
+        while True:
+            next_event = asyncio.ensure_future(subscription.get())
+            await asyncio.wait({next_event, disconnected}, return_when=asyncio.FIRST_COMPLETED)
+            if disconnected.done():
+                next_event.cancel()
+                return
+            await websocket.send_json(next_event.result().to_dict())
+    finally:
+        disconnected.cancel()
+
+
+@router.websocket("/ws/entities/{entity_id}")
+async def track_entity(
+    websocket: WebSocket,
+    entity_id: str,
+    token: Optional[str] = Query(None, description="Bearer token, for clients that cannot send headers"),
+):
+    """Push an entity's position reports and geofence transitions as they happen.
+
+    Each message is a JSON object with `type` (position, geofence.enter,
+    geofence.exit or geofence.dwell), `entity_id`, `occurred_at` and `data`:
+    the reported coordinate and containing geofences for positions, the
+    stored alert for transitions. Only events reported after connecting
+    are sent.
+
+    The connection is closed with code 1008 for a missing or invalid token
+    and 1011 if token authentication is not configured.
+
+    Args:
+        websocket: Client connection
+        entity_id: Tracked entity of the calling tenant
+        token: Bearer token (alternative to the Authorization header)
+    """
+    try:
+        tenant_id = _stream_tenant(websocket.headers.get("authorization"), token)
+    except AuthenticationError as e:
+        logger.info(f"Rejected live tracking connection: {str(e)}")
+        await websocket.close(code=status.WS_1008_POLICY_VIOLATION)
+        return
+    except RuntimeError as e:
+        logger.error(f"Live tracking unavailable: {str(e)}")
+        await websocket.close(code=status.WS_1011_INTERNAL_ERROR)
+        return
+
+    hub = get_live_event_hub()
+    subscription = hub.subscribe(tenant_id, entity_id=entity_id.strip())
+    try:
+        await websocket.accept()
+        await _stream(websocket, subscription)
+    finally:
+        hub.unsubscribe(subscription)
+
+
+def format_sse(event: LiveEvent) -> str:
+    """One Server-Sent Events message (named after the event type)."""
+    return f"event: {event.type}\ndata: {json.dumps(event.to_dict())}\n\n"
+
+
+async def sse_messages(
+    subscription: Subscription,
+    is_disconnected,
+    keepalive_seconds: float,
+) -> AsyncIterator[str]:
+    """SSE messages of a subscription until the client disconnects.
+
+    A comment line is sent after `keepalive_seconds` without events, so
+    proxies keep the connection open and disconnects are noticed.
+
+    Args:
+        subscription: Events to stream
+        is_disconnected: Coroutine function telling whether the client is gone
+        keepalive_seconds: Idle time before a keep-alive comment
+    """
+    yield ": connected\n\n"
+    while not await is_disconnected():
+        try:
+            event = await asyncio.wait_for(subscription.get(), timeout=keepalive_seconds)
+        except asyncio.TimeoutError:
+            yield ": keep-alive\n\n"
+            continue
+        yield format_sse(event)
+
+
+@router.get(
+    "/events/stream",
+    response_class=StreamingResponse,
+    responses={
+        200: {"content": {"text/event-stream": {}}, "description": "Event stream"},
+        400: {"model": ErrorResponse, "description": "Unknown event type"},
+        **AUTH_RESPONSES,
+    },
+    # The token is read by hand (header or query), so declare it here
+    openapi_extra={"security": [{BEARER_SCHEME: []}]},
+)
+async def stream_events(
+    request: Request,
+    geofence_id: Optional[List[str]] = Query(None, description="Only events of these geofences (repeatable)"),
+    event: Optional[List[str]] = Query(None, description="Only these event types (repeatable)"),
+    token: Optional[str] = Query(None, description="Bearer token, for clients that cannot send headers"),
+    authorization: Optional[str] = Header(None),
+):
+    """Server-Sent Events feed of the calling tenant's geofence and detection events.
+
+    Streams geofence.enter, geofence.exit, geofence.dwell and detection
+    events as they happen, each as an SSE message named after its type
+    whose data is the JSON object sent over the WebSocket API. Detections
+    are included if they were submitted with the tenant's bearer token.
+
+    Args:
+        request: Client request (to notice disconnects)
+        geofence_id: Only events concerning these geofences
+        event: Only these event types
+        token: Bearer token (alternative to the Authorization header)
+        authorization: Authorization header
+
+    Returns:
+        StreamingResponse: text/event-stream of events after connecting
+
+    Raises:
+        HTTPException: 400 for an unknown event type, 401 without a valid
+            token, 503 if token authentication is not configured
+    """
+    try:
+        tenant_id = _stream_tenant(authorization, token)
+    except AuthenticationError as e:
+        raise HTTPException(
+            status_code=status.HTTP_401_UNAUTHORIZED,
+            detail={"error_code": "E006", "error_message": str(e), "details": None},
+            headers={"WWW-Authenticate": "Bearer"},
+        )
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={"error_code": "E003", "error_message": str(e), "details": None},
+        )
+    unknown = sorted(set(event or ()) - set(FEED_EVENTS))
+    if unknown:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": f"Unknown event types: {', '.join(unknown)}",
+                "details": {"supported": list(FEED_EVENTS)},
+            },
+        )
+
+    from src.config import get_config
+
+    hub = get_live_event_hub()
+    subscription = hub.subscribe(tenant_id, geofence_ids=geofence_id, types=event or FEED_EVENTS)
+
+    async def messages() -> AsyncIterator[str]:
+        try:
+            async for message in sse_messages(
+                subscription, request.is_disconnected, get_config().live_event_keepalive_seconds
+            ):
+                yield message
+        finally:
+            hub.unsubscribe(subscription)
+
+    return StreamingResponse(
+        messages(),
+        media_type="text/event-stream",
+        headers={"Cache-Control": "no-cache", "X-Accel-Buffering": "no"},
+    )
@@ -0,0 +1,879 @@
+"""API routes for IP geolocation lookups backed by the GeoIP dataset."""
+import logging
+from datetime import datetime, timezone
+from functools import partial
+from typing import Any, Callable, Dict, Optional, Set, Tuple, Union
+from fastapi import APIRouter, Depends, Header, HTTPException, Query, Request, Response, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_optional_tenant_id
+from src.api.etag import conditional_json, dataset_version
+from src.api.fields import FieldTree, field_names, parse_fields, select_fields
+from src.api.geocoding_routes import reverse_geocode_point
+from src.api.protobuf import PROTOBUF_MEDIA_TYPE, VARY_ACCEPT, protobuf_response, wants_protobuf
+from src.database import get_db_session
+from src.grpc_api.messages import to_batch_response, to_ip_location
+from src.metrics import DEGRADED_LOOKUPS
+from src.models.schemas import (
+    BatchLookupItem,
+    BatchLookupRequest,
+    BatchLookupResponse,
+    BatchLookupResult,
+    CarrierInfo,
+    DatacenterClassificationResponse,
+    DeviceCorrelationInfo,
+    ErrorResponse,
+    IpLookupResponse,
+    IpOverrideMatch,
+    LocationAnomalyInfo,
+    LocationMismatchInfo,
+    ResidentialProxyInfo,
+    ResidentialProxyResponse,
+    ReverseGeocodeResponse,
+    RiskScoreInfo,
+    RuleEvaluationInfo,
+    TravelAssessmentResponse,
+)
+from src.services.accuracy_service import Granularity, get_accuracy_estimator
+from src.services.anomaly_service import LocationAnomalyDetector
+from src.services.batch_lookup_service import BatchOutcome, BatchOverloadedError, get_batch_lookup_service
+from src.services.carrier_service import get_carrier_directory
+from src.services.degraded_service import DEPENDENCY_ERRORS, DegradedReason, get_degraded_mode
+from src.services.device_history_service import DeviceHistoryService, DeviceObservation
+from src.services.enrichment_service import enabled_enrichments, get_enrichment_pipeline
+from src.services.ip_lookup_service import (
+    IpLookupResult,
+    IpLookupService,
+    get_ip_lookup_service,
+    normalize_ip,
+)
+from src.services.ip_override_service import IpOverrideService, OverrideEntry, OverrideTable
+from src.services.location_mismatch_service import LocationMismatchDetector
+from src.services.lookup_cache_service import LookupType, get_lookup_cache
+from src.services.mmdb_service import GeoIPRecord
+from src.services.residential_proxy_service import get_session_asn_tracker
+from src.services.risk_area_service import RiskAreaService
+from src.services.risk_service import RiskContext, get_risk_scorer
+from src.services.rule_service import get_rule_engine, rule_context
+from src.services.snapshot_service import get_snapshot_store
+from src.services.travel_service import TravelDetectionService, TravelPoint
+from src.services.velocity_service import get_velocity_tracker
+from src.spatial import geohash
+
+logger = logging.getLogger(__name__)
+
+router = APIRouter(prefix="/api/v1", tags=["lookup"])
+
+
+def _record_geohash(record: GeoIPRecord, radius_km: Optional[float]) -> Optional[str]:
+    """Geohash of a record's location, no finer than its accuracy radius."""
+    if record.latitude is None or record.longitude is None:
+        return None
+    precision = geohash.MAX_PRECISION
+    if radius_km:
+        precision = geohash.precision_for_radius(radius_km * 1000)
+    return geohash.encode(record.latitude, record.longitude, precision)
+
+
+def _to_response(
+    result: IpLookupResult,
+    enrichments: Optional[Dict[str, Any]] = None,
+    as_of: Optional[datetime] = None,
+    override: Optional[OverrideEntry] = None,
+) -> IpLookupResponse:
+    """Convert an engine lookup result to the API response model."""
+    normalized = result.normalized
+    record = result.record
+    accuracy = get_accuracy_estimator().estimate(
+        record,
+        result.dataset_build_epoch,
+        now=as_of.timestamp() if as_of is not None else None,
+        granularity=Granularity.SITE if override is not None else None,
+    )
+    return IpLookupResponse(
+        ip_address=str(normalized.original),
+        ip_version=normalized.ip_version,
+        normalized_ip=(
+            str(normalized.address)
+            if normalized.address != normalized.original
+            else None
+        ),
+        tunnel=normalized.tunnel,
+        network=record.network,
+        country_iso_code=record.country_iso_code,
+        country_name=record.country_name,
+        continent_code=record.continent_code,
+        city_name=record.city_name,
+        postal_code=record.postal_code,
+        latitude=record.latitude,
+        longitude=record.longitude,
+        accuracy_radius_km=accuracy.accuracy_radius_km,
+        granularity=accuracy.granularity,
+        confidence=accuracy.confidence,
+        confidence_flag=accuracy.confidence_flag,
+        dataset_age_days=accuracy.dataset_age_days,
+        dataset_built_at=(
+            datetime.fromtimestamp(result.dataset_build_epoch, tz=timezone.utc)
+            if result.dataset_build_epoch
+            else None
+        ),
+        time_zone=record.time_zone,
+        geohash=_record_geohash(record, accuracy.accuracy_radius_km),
+        subdivisions=record.subdivisions,
+        override=(
+            IpOverrideMatch(
+                override_id=override.override_id,
+                label=override.label,
+                properties=override.properties,
+            )
+            if override is not None
+            else None
+        ),
+        **(enrichments or {}),
+    )
+
+
+def _lookup_service_as_of(as_of: Optional[datetime]) -> IpLookupService:
+    """Lookup service for the dataset build active at an instant (default: now)."""
+    service = get_ip_lookup_service()
+    if service is None:
+        raise RuntimeError("GeoIP dataset unavailable")
+    if as_of is None or as_of.timestamp() >= service.reader.build_epoch:
+        return service
+
+    snapshots = get_snapshot_store()
+    if snapshots is None:
+        raise RuntimeError("Historical lookups are disabled (GEOIP_SNAPSHOT_DIR is not set)")
+    try:
+        return IpLookupService(snapshots.reader_for(as_of))
+    except (OSError, ValueError) as e:
+        raise RuntimeError(f"GeoIP dataset snapshot unavailable: {e}")
+
+
+def tenant_overrides(tenant_id: Optional[str], session: Session) -> Optional[OverrideTable]:
+    """The calling tenant's compiled IP overrides (None for anonymous callers)."""
+    if tenant_id is None:
+        return None
+    return IpOverrideService(session).table(tenant_id)
+
+
+def _override_lookup(
+    ip: str, overrides: OverrideTable, as_of: Optional[datetime]
+) -> Optional[Tuple[IpLookupResult, OverrideEntry]]:
+    """Locate an address from a tenant override, trying embedded IPv4 first."""
+    normalized = normalize_ip(ip)
+    for candidate in dict.fromkeys((normalized.address, normalized.original)):
+        override = overrides.match(candidate, as_of)
+        if override is not None:
+            result = IpLookupResult(normalized=normalized, record=override.record(str(candidate)))
+            return result, override
+    return None
+
+
+def lookup_ip_address(
+    ip: str,
+    api_key: Optional[str] = None,
+    as_of: Optional[datetime] = None,
+    overrides: Optional[OverrideTable] = None,
+) -> IpLookupResponse:
+    """Geolocate and enrich one address.
+
+    Args:
+        ip: IPv4 or IPv6 address
+        api_key: Caller API key; selects which enrichments are included
+        as_of: Locate the address with the dataset build active at this
+            instant (naive values are UTC; default: the active dataset)
+        overrides: Calling tenant's IP range overrides, checked before the
+            dataset (only overrides registered by as_of apply)
+
+    Returns:
+        IpLookupResponse
+
+    Raises:
+        ValueError: If the address is invalid
+        LookupError: If the address is not in the dataset, or no snapshot
+            is retained for as_of
+        RuntimeError: If no GeoIP dataset (or snapshot store) is available
+    """
+    if as_of is not None and as_of.tzinfo is None:
+        as_of = as_of.replace(tzinfo=timezone.utc)
+
+    match = _override_lookup(ip, overrides, as_of) if overrides is not None else None
+    if match is not None:
+        result, override = match
+        enrichments = get_enrichment_pipeline().apply(
+            result, enabled_enrichments(api_key)
+        )
+        return _to_response(result, enrichments, as_of, override)
+
+    return _locate(ip, enabled_enrichments(api_key), as_of)
+
+
+def _locate(ip: str, enabled: Set[str], as_of: Optional[datetime] = None) -> IpLookupResponse:
+    """Geolocate an address from the dataset, with the named enrichments, through the lookup cache."""
+    service = _lookup_service_as_of(as_of)
+
+    def locate() -> IpLookupResponse:
+        result = service.lookup(ip)
+        if result.record is None:
+            raise LookupError(f"No geolocation data for {ip}")
+        return _to_response(result, get_enrichment_pipeline().apply(result, enabled), as_of)
+
+    cache = get_lookup_cache()
+    if cache is None:
+        return locate()
+    # Without an override the answer only depends on the dataset build and the enrichments
+    return cache.read_through(
+        LookupType.IP if as_of is None else LookupType.IP_AS_OF,
+        service.reader.version,
+        {"ip": ip, "enrichments": sorted(enabled), "as_of": as_of},
+        IpLookupResponse,
+        locate,
+    )
+
+
+def warm_ip_lookup(query: Dict[str, Any]) -> None:
+    """Cache the answer of a hot IP lookup (see src.services.cache_warming_service)."""
+    _locate(query["ip"], set(query["enrichments"]))
+
+
+def compare_device_location(
+    located: IpLookupResponse,
+    latitude: float,
+    longitude: float,
+    accuracy_m: Optional[float] = None,
+) -> LocationMismatchInfo:
+    """Compare a device-reported coordinate with a located IP address.
+
+    Raises:
+        ValueError: If the coordinate is out of range
+        LookupError: If the IP record has no coordinate
+    """
+    if located.latitude is None or located.longitude is None:
+        raise LookupError(f"No coordinate for {located.ip_address}")
+    comparison = LocationMismatchDetector().compare(
+        latitude,
+        longitude,
+        located.latitude,
+        located.longitude,
+        ip_accuracy_km=located.accuracy_radius_km,
+        device_accuracy_m=accuracy_m,
+        anonymizer=located.anonymizer.model_dump() if located.anonymizer is not None else None,
+    )
+    return LocationMismatchInfo(**comparison.to_dict())
+
+
+def track_session(
+    session_id: str,
+    ip: str,
+    tenant_id: Optional[str] = None,
+    timestamp: Optional[float] = None,
+) -> ResidentialProxyResponse:
+    """Record a session request from an address and check it for residential proxy use.
+
+    The address's ASN and anonymizer flags are looked up regardless of the
+    enrichments enabled for the caller.
+
+    Raises:
+        ValueError: If the session ID or address is invalid
+        RuntimeError: If the velocity store cannot be reached
+    """
+    normalized = normalize_ip(ip)
+    blocks = get_enrichment_pipeline().apply(
+        IpLookupResult(normalized=normalized), {"asn", "anonymizer"}
+    )
+    asn = blocks.get("asn") or {}
+    churn = get_session_asn_tracker().observe(
+        session_id,
+        str(normalized.original),
+        asn.get("number"),
+        connection_type=asn.get("connection_type"),
+        listed=(blocks.get("anonymizer") or {}).get("residential_proxy"),
+        timestamp=timestamp,
+        tenant_id=tenant_id,
+    )
+    return ResidentialProxyResponse(
+        ip_address=str(normalized.original), asn=asn.get("number"), **churn.to_dict()
+    )
+
+
+def score_lookup(
+    located: IpLookupResponse,
+    user_id: Optional[str] = None,
+    tenant_id: Optional[str] = None,
+    session: Optional[Session] = None,
+) -> RiskScoreInfo:
+    """Risk score of a located address and the detection blocks attached to it.
+
+    With a user ID, the account's velocity counts feed in as well; when the
+    velocity store cannot be reached the velocity signal is skipped. With a
+    tenant and a session, the tenant's high-risk list replaces the global one.
+    """
+    velocity = None
+    if user_id is not None:
+        try:
+            velocity = get_velocity_tracker().counts("account", user_id, tenant_id=tenant_id)
+        except (ValueError, RuntimeError) as e:
+            logger.warning(f"Risk scoring without velocity counts: {e}")
+    high_risk = RiskAreaService(session).risk_list(tenant_id) if session is not None else None
+    score = get_risk_scorer().score(RiskContext.from_lookup(located, velocity, high_risk))
+    return RiskScoreInfo(**score.to_dict())
+
+
+def assess_ip(
+    ip: str,
+    session: Session,
+    api_key: Optional[str] = None,
+    tenant_id: Optional[str] = None,
+    as_of: Optional[datetime] = None,
+    user_id: Optional[str] = None,
+    device_id: Optional[str] = None,
+    device_lat: Optional[float] = None,
+    device_lon: Optional[float] = None,
+    device_accuracy_m: Optional[float] = None,
+    session_id: Optional[str] = None,
+) -> IpLookupResponse:
+    """Geolocate an address and attach the detection blocks, risk score and rule decision.
+
+    Shared by the REST and gRPC lookup handlers; see lookup_ip for the
+    blocks each argument adds.
+
+    Raises:
+        ValueError: If the address or device coordinate is invalid
+        LookupError: If the address is not in the dataset, or no snapshot
+            is retained for as_of
+        RuntimeError: If no GeoIP dataset (or snapshot store) is available
+    """
+    if (device_lat is None) != (device_lon is None):
+        raise ValueError("device_lat and device_lon must be passed together")
+    response = lookup_ip_address(ip, api_key, as_of, tenant_overrides(tenant_id, session))
+    if user_id is not None and response.latitude is not None and response.longitude is not None:
+        # Scored before the lookup is stored as a login
+        anomaly = LocationAnomalyDetector(session).evaluate(
+            user_id, response.latitude, response.longitude, tenant_id
+        )
+        response.anomaly = LocationAnomalyInfo(**anomaly.to_dict())
+        login = TravelPoint(
+            latitude=response.latitude,
+            longitude=response.longitude,
+            observed_at=as_of or datetime.now(timezone.utc),
+            accuracy_km=response.accuracy_radius_km,
+            ip_address=response.ip_address,
+            country_code=response.country_iso_code,
+        )
+        assessment = TravelDetectionService(session).evaluate(user_id, login, tenant_id)
+        response.travel = TravelAssessmentResponse(**assessment.to_dict())
+    if device_id is not None:
+        observation = DeviceObservation(
+            observed_at=as_of or datetime.now(timezone.utc),
+            country_code=response.country_iso_code,
+            city_name=response.city_name,
+            latitude=response.latitude,
+            longitude=response.longitude,
+            accuracy_km=response.accuracy_radius_km,
+            ip_address=response.ip_address,
+        )
+        if device_lat is not None:
+            observation.latitude, observation.longitude = device_lat, device_lon
+            observation.accuracy_km = (
+                device_accuracy_m / 1000.0 if device_accuracy_m is not None else None
+            )
+        correlation = DeviceHistoryService(session).correlate(device_id, observation, tenant_id)
+        response.device = DeviceCorrelationInfo(**correlation.to_dict())
+    if device_lat is not None and response.latitude is not None:
+        response.location_mismatch = compare_device_location(
+            response, device_lat, device_lon, device_accuracy_m
+        )
+    if session_id is not None:
+        try:
+            tracked = track_session(session_id, ip, tenant_id)
+            response.residential_proxy = ResidentialProxyInfo(
+                **tracked.model_dump(exclude={"ip_address", "asn"})
+            )
+        except RuntimeError as e:
+            logger.warning(f"Residential proxy check skipped: {e}")
+    if "risk" in enabled_enrichments(api_key):
+        response.risk = score_lookup(response, user_id, tenant_id, session)
+    engine = get_rule_engine()
+    if engine.rules:
+        evaluation = engine.evaluate(rule_context(response), response.ip_address)
+        response.rules = RuleEvaluationInfo(**evaluation.to_dict())
+    return response
+
+
+def fields_selection(fields: Optional[str], model) -> Optional[FieldTree]:
+    """Parsed `fields` parameter of a route returning `model`.
+
+    Raises:
+        HTTPException: 400 for an attribute the model does not have
+    """
+    try:
+        return parse_fields(fields, model)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": {"supported": field_names(model)},
+            },
+        )
+
+
+def lookup_response(
+    request: Request,
+    response,
+    selection: Optional[FieldTree],
+    built_at: Optional[datetime],
+    to_message: Optional[Callable[[Any], Any]] = None,
+) -> Response:
+    """Response of a lookup's selected attributes, with a dataset ETag.
+
+    Args:
+        request: Request being answered (for If-None-Match and Accept)
+        response: Lookup result model
+        selection: Attributes to return (None: all)
+        built_at: Build time of the answering dataset
+        to_message: Builds the route's protobuf message from the message
+            module, if the route answers in protobuf when asked; protobuf
+            responses always hold every attribute
+
+    Raises:
+        HTTPException: 503 if protobuf is asked for without the grpc dependencies
+    """
+    version = dataset_version(built_at)
+    if to_message is None:
+        return conditional_json(request, select_fields(response.model_dump(mode="json"), selection), version)
+    if wants_protobuf(request):
+        return protobuf_response(request, to_message, version)
+    content = select_fields(response.model_dump(mode="json"), selection)
+    return conditional_json(request, content, version, VARY_ACCEPT)
+
+
+def _country_only(ip: str) -> Optional[IpLookupResponse]:
+    """The dataset record of an address cut down to its country, if the dataset answers."""
+    service = get_ip_lookup_service()
+    if service is None:
+        return None
+    result = service.lookup(ip)
+    if result.record is None:
+        return None
+    record = result.record
+    result.record = GeoIPRecord(
+        ip_address=record.ip_address,
+        network=record.network,
+        country_iso_code=record.country_iso_code,
+        country_name=record.country_name,
+        continent_code=record.continent_code,
+    )
+    return _to_response(result)
+
+
+def _degraded(response: IpLookupResponse, reason: str, answer: str) -> IpLookupResponse:
+    DEGRADED_LOOKUPS.labels(reason=reason, answer=answer).inc()
+    return response.model_copy(update={"degraded": True, "degraded_reason": reason})
+
+
+def assess_ip_degradable(ip: str, session: Session, **options) -> IpLookupResponse:
+    """assess_ip, answering approximately instead when degraded mode says so.
+
+    See src.services.degraded_service. Historical (as_of) lookups are never
+    degraded. Raises as assess_ip when degraded mode is off or has nothing
+    to answer with.
+    """
+    mode = get_degraded_mode()
+    if mode is None or options.get("as_of") is not None:
+        return assess_ip(ip, session, **options)
+    # Blocks of users, devices and sessions belong to one request, so only plain answers are kept
+    plain = all(options.get(name) is None for name in ("user_id", "device_id", "session_id", "device_lat"))
+    key = (
+        options.get("tenant_id"),
+        frozenset(enabled_enrichments(options.get("api_key"))),
+        str(normalize_ip(ip).original),
+    )
+    if plain and mode.overloaded():
+        recent = mode.answers.get(key)
+        if recent is not None:
+            return _degraded(recent, DegradedReason.OVERLOAD, "recent")
+
+    try:
+        response = assess_ip(ip, session, **options)
+    except DEPENDENCY_ERRORS as e:
+        recent = mode.answers.get(key)
+        if recent is not None:
+            logger.warning(f"Answering {ip} with its last answer: {e}")
+            return _degraded(recent, DegradedReason.DEPENDENCY_UNAVAILABLE, "recent")
+        coarse = _country_only(ip)
+        if coarse is None:
+            raise
+        logger.warning(f"Answering {ip} with its country only: {e}")
+        return _degraded(coarse, DegradedReason.DEPENDENCY_UNAVAILABLE, "country")
+    if plain:
+        mode.answers.put(key, response)
+    return response
+
+
+def assess_ip_or_raise(ip: str, session: Session, **options) -> IpLookupResponse:
+    """assess_ip with its errors as HTTP errors (for the lookup routes of every version).
+
+    With DEGRADED_MODE_ENABLED, failing dependencies and overload give
+    degraded answers instead (see assess_ip_degradable).
+
+    Raises:
+        HTTPException: 400 for invalid input, 404 if not found, 503 if no dataset
+    """
+    try:
+        return assess_ip_degradable(ip, session, **options)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except LookupError as e:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+
+
+@router.get(
+    "/lookup/ip/{ip}",
+    response_model=IpLookupResponse,
+    responses={
+        200: {
+            "content": {PROTOBUF_MEDIA_TYPE: {}},
+            "description": "Location record (IpLocation of geolocation.proto with Accept: application/x-protobuf)",
+        },
+        304: {"description": "Not modified (If-None-Match holds the response's ETag)"},
+        400: {"model": ErrorResponse, "description": "Invalid IP address"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        404: {"model": ErrorResponse, "description": "IP address not in dataset, or no snapshot for as_of"},
+        503: {"model": ErrorResponse, "description": "GeoIP dataset unavailable"},
+    },
+)
+async def lookup_ip(
+    request: Request,
+    ip: str,
+    as_of: Optional[datetime] = Query(
+        None, description="Locate with the dataset active at this date or instant"
+    ),
+    user_id: Optional[str] = Query(
+        None, max_length=255, description="Record the lookup as this user's login and add a travel verdict"
+    ),
+    device_id: Optional[str] = Query(
+        None, max_length=255, description="Record the lookup as a sighting of this device and check its history"
+    ),
+    device_lat: Optional[float] = Query(None, description="Device-reported latitude to compare"),
+    device_lon: Optional[float] = Query(None, description="Device-reported longitude to compare"),
+    device_accuracy_m: Optional[float] = Query(None, ge=0, description="Device-reported accuracy"),
+    session_id: Optional[str] = Query(
+        None, max_length=255, description="Record the lookup as a request of this client session"
+    ),
+    fields: Optional[str] = Query(
+        None, description="Comma-separated attributes to return, e.g. country_iso_code,asn,risk.score"
+    ),
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Geolocate an IPv4 or IPv6 address using the GeoIP dataset.
+
+    IPv6 addresses that embed an IPv4 client (IPv4-mapped, 6to4, Teredo)
+    are resolved via the embedded address. Callers with a bearer token get
+    their tenant's IP range overrides ahead of the dataset. With `as_of`,
+    the address is located with the retained dataset build that was active
+    at that time; enrichments always come from the current datasets. With
+    `user_id`, the lookup is recorded as a login of that user (at `as_of`,
+    or now) and the `travel` block compares it with their previous login,
+    while the `anomaly` block scores it against their usual locations.
+    With `device_lat`/`device_lon`, the `location_mismatch` block compares
+    the device's own coordinate with the IP location. With `device_id`, the
+    lookup is recorded as a sighting of that device and the `device` block
+    says whether it had been seen in the country before. With `session_id`,
+    the `residential_proxy` block counts the ASNs the session has come from
+    (skipped when the velocity store cannot be reached). The `risk` block
+    scores the result, including those blocks, and the `rules` block holds
+    the decision of the detection rules. With `fields`, only the listed
+    attributes are returned (dotted names select inside blocks). Responses
+    carry an ETag of the dataset build and content; sending it back in
+    If-None-Match gets a 304 while the answer is unchanged. With
+    `Accept: application/x-protobuf` the result is the IpLocation message of
+    geolocation.proto, which ignores `fields`.
+
+    Args:
+        request: Request being answered (for If-None-Match and Accept)
+        ip: IPv4 or IPv6 address (brackets and zone IDs are accepted)
+        as_of: ISO 8601 date or instant for a historical lookup
+        user_id: User whose login this lookup is
+        device_id: Device this lookup is a sighting of
+        device_lat: Device-reported latitude
+        device_lon: Device-reported longitude
+        device_accuracy_m: Device-reported accuracy in meters
+        session_id: Client session this lookup is a request of
+        fields: Attributes to return (default: all)
+        x_api_key: Caller API key; selects which enrichments are included
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        IpLookupResponse: Location record for the address
+
+    Raises:
+        HTTPException: 400 for invalid input or an unknown field, 401 for
+            an invalid token, 404 if not found, 503 if no dataset (or
+            protobuf is asked for without the grpc dependencies)
+    """
+    selection = fields_selection(fields, IpLookupResponse)
+    response = assess_ip_or_raise(
+        ip,
+        session,
+        api_key=x_api_key,
+        tenant_id=tenant_id,
+        as_of=as_of,
+        user_id=user_id,
+        device_id=device_id,
+        device_lat=device_lat,
+        device_lon=device_lon,
+        device_accuracy_m=device_accuracy_m,
+        session_id=session_id,
+    )
+    return lookup_response(
+        request,
+        response,
+        selection,
+        response.dataset_built_at,
+        to_message=lambda protos: to_ip_location(protos, response),
+    )
+
+
+@router.get(
+    "/lookup/datacenter/{ip}",
+    response_model=DatacenterClassificationResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid IP address"},
+        503: {"model": ErrorResponse, "description": "No cloud ranges or ASN database loaded"},
+    },
+)
+async def lookup_datacenter(ip: str):
+    """Classify an address as datacenter/cloud traffic without geolocating it.
+
+    Meant for WAF rules: answers from the cloud ranges and ASN data alone,
+    so addresses missing from the GeoIP dataset are still classified.
+
+    Args:
+        ip: IPv4 or IPv6 address
+
+    Returns:
+        DatacenterClassificationResponse: `is_datacenter` with provider and source
+
+    Raises:
+        HTTPException: 400 for an invalid address, 503 if no source is loaded
+    """
+    try:
+        normalized = normalize_ip(ip)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={"error_code": "E002", "error_message": str(e), "details": None},
+        )
+    blocks = get_enrichment_pipeline().apply(IpLookupResult(normalized=normalized), {"datacenter"})
+    if "datacenter" not in blocks:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": "Datacenter classification unavailable (no cloud ranges or ASN database)",
+                "details": None,
+            },
+        )
+    return DatacenterClassificationResponse(ip_address=str(normalized.original), **blocks["datacenter"])
+
+
+@router.get(
+    "/lookup/carrier/{mcc}/{mnc}",
+    response_model=CarrierInfo,
+    responses={
+        400: {"model": ErrorResponse, "description": "Malformed MCC or MNC"},
+        404: {"model": ErrorResponse, "description": "MCC/MNC pair not in directory"},
+        503: {"model": ErrorResponse, "description": "Carrier directory unavailable"},
+    },
+)
+async def lookup_carrier(mcc: str, mnc: str):
+    """Name the mobile carrier for an MCC/MNC pair.
+
+    Args:
+        mcc: Mobile country code (3 digits)
+        mnc: Mobile network code (2 or 3 digits, leading zeros significant)
+
+    Returns:
+        CarrierInfo: Carrier name and country
+
+    Raises:
+        HTTPException: 400 for malformed codes, 404 if unknown, 503 if no directory
+    """
+    directory = get_carrier_directory()
+    if directory is None:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": "Carrier directory unavailable",
+                "details": None,
+            },
+        )
+    try:
+        carrier = directory.get(mcc, mnc)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    if carrier is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"No carrier for MCC {mcc} MNC {mnc}",
+                "details": None,
+            },
+        )
+    return CarrierInfo(
+        mcc=carrier.mcc,
+        mnc=carrier.mnc,
+        name=carrier.name,
+        country_iso_code=carrier.country_iso_code,
+    )
+
+
+def lookup_batch_item(
+    item: BatchLookupItem,
+    api_key: Optional[str] = None,
+    overrides: Optional[OverrideTable] = None,
+) -> Union[IpLookupResponse, ReverseGeocodeResponse]:
+    """Look up one batch item: geolocate an IP or reverse geocode a coordinate."""
+    if item.ip is not None:
+        if item.lat is not None or item.lon is not None:
+            raise ValueError("Pass either ip or lat/lon, not both")
+        return lookup_ip_address(item.ip, api_key, overrides=overrides)
+    if item.lat is None or item.lon is None:
+        raise ValueError("Each item needs an ip or both lat and lon")
+    return reverse_geocode_point(item.lat, item.lon)
+
+
+def batch_result(outcome: BatchOutcome) -> BatchLookupResult:
+    if not outcome.ok:
+        return BatchLookupResult(
+            index=outcome.index,
+            error=ErrorResponse(
+                error_code=outcome.error_code, error_message=outcome.error_message
+            ),
+        )
+    if isinstance(outcome.result, IpLookupResponse):
+        return BatchLookupResult(index=outcome.index, ip=outcome.result)
+    return BatchLookupResult(index=outcome.index, location=outcome.result)
+
+
+@router.post(
+    "/lookup/batch",
+    response_model=BatchLookupResponse,
+    responses={
+        200: {
+            "content": {PROTOBUF_MEDIA_TYPE: {}},
+            "description": "Per-item results (BatchLookupResponse of geolocation.proto with "
+                           "Accept: application/x-protobuf)",
+        },
+        400: {"model": ErrorResponse, "description": "Empty or oversized batch"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        503: {"model": ErrorResponse, "description": "Too many batch items pending; retry later"},
+    },
+)
+async def lookup_batch(
+    request: BatchLookupRequest,
+    http_request: Request,
+    response: Response,
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Look up many IP addresses and/or coordinates in one request.
+
+    Items run concurrently on a worker pool (BATCH_LOOKUP_WORKERS, at most
+    BATCH_LOOKUP_WORKERS_PER_BATCH of them per batch). Each
+    item reports its own result or error, using the error codes of the
+    single-item endpoints (E002 invalid, E004 not found, E003 unavailable).
+    With `Accept: application/x-protobuf` the results are the
+    BatchLookupResponse message of geolocation.proto, coordinates carried
+    as JSON in `reverse_geocode_json`.
+
+    Args:
+        request: Up to BATCH_LOOKUP_MAX_ITEMS items, each an `ip` or `lat`/`lon`
+        http_request: Request being answered (for Accept)
+        response: JSON response (for its Vary header)
+        x_api_key: Caller API key; selects which enrichments IP results include
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        BatchLookupResponse: Per-item results in request order
+
+    Raises:
+        HTTPException: 400 if the batch is empty or too large, 401 for an
+            invalid token, 503 if BATCH_LOOKUP_MAX_PENDING_ITEMS items are
+            pending or protobuf is asked for without the grpc dependencies
+    """
+    # Loaded here: the session must not be shared with the worker threads
+    overrides = tenant_overrides(tenant_id, session)
+    try:
+        outcomes = await get_batch_lookup_service().run(
+            request.items, partial(lookup_batch_item, api_key=x_api_key, overrides=overrides)
+        )
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    except BatchOverloadedError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E016",
+                "error_message": str(e),
+                "details": None,
+            },
+            headers={"Retry-After": "1"},
+        )
+
+    if wants_protobuf(http_request):
+        return protobuf_response(http_request, lambda protos: to_batch_response(protos, outcomes))
+    succeeded = sum(1 for outcome in outcomes if outcome.ok)
+    response.headers.update(VARY_ACCEPT)
+    return BatchLookupResponse(
+        total=len(outcomes),
+        succeeded=succeeded,
+        failed=len(outcomes) - succeeded,
+        results=[batch_result(outcome) for outcome in outcomes],
+    )
@@ -0,0 +1,140 @@
+"""Version 2 of the IP lookup route: the v1 result in nested objects."""
+from datetime import datetime
+from typing import Optional
+
+from fastapi import Depends, Header, Query, Request
+from sqlalchemy.orm import Session
+
+from src.api.dependencies import get_optional_tenant_id
+from src.api.lookup_routes import assess_ip_or_raise, fields_selection, lookup_response
+from src.api.versioning import versioned_router
+from src.database import get_db_session
+from src.models.schemas import (
+    CoordinateInfo,
+    CountryInfo,
+    DatasetInfo,
+    ErrorResponse,
+    IpAddressInfo,
+    IpLocationInfo,
+    IpLookupResponse,
+    IpLookupV2Response,
+    LocationConfidenceInfo,
+)
+
+router = versioned_router("v2", tags=["lookup"])
+
+# Fields v2 moves into the ip, location, confidence and dataset objects;
+# every other v1 field is an enrichment block, copied as is
+_REGROUPED = {
+    "ip_address", "ip_version", "normalized_ip", "tunnel", "network",
+    "country_iso_code", "country_name", "continent_code", "city_name", "postal_code",
+    "latitude", "longitude", "accuracy_radius_km", "granularity", "time_zone",
+    "geohash", "subdivisions", "confidence", "confidence_flag",
+    "dataset_age_days", "dataset_built_at",
+}
+
+
+def to_v2(response: IpLookupResponse) -> IpLookupV2Response:
+    """Regroup a v1 lookup result into the v2 shape."""
+    coordinates = None
+    if response.latitude is not None and response.longitude is not None:
+        coordinates = CoordinateInfo(latitude=response.latitude, longitude=response.longitude)
+    return IpLookupV2Response(
+        ip=IpAddressInfo(
+            address=response.ip_address,
+            version=response.ip_version,
+            normalized=response.normalized_ip,
+            tunnel=response.tunnel,
+            network=response.network,
+        ),
+        location=IpLocationInfo(
+            country=CountryInfo(iso_code=response.country_iso_code, name=response.country_name),
+            continent_code=response.continent_code,
+            city=response.city_name,
+            postal_code=response.postal_code,
+            coordinates=coordinates,
+            accuracy_radius_km=response.accuracy_radius_km,
+            granularity=response.granularity,
+            time_zone=response.time_zone,
+            geohash=response.geohash,
+            subdivisions=response.subdivisions,
+        ),
+        confidence=LocationConfidenceInfo(score=response.confidence, flag=response.confidence_flag),
+        dataset=DatasetInfo(age_days=response.dataset_age_days, built_at=response.dataset_built_at),
+        **{
+            name: getattr(response, name)
+            for name in IpLookupResponse.model_fields
+            if name not in _REGROUPED
+        },
+    )
+
+
+@router.get(
+    "/lookup/ip/{ip}",
+    response_model=IpLookupV2Response,
+    responses={
+        304: {"description": "Not modified (If-None-Match holds the response's ETag)"},
+        400: {"model": ErrorResponse, "description": "Invalid IP address"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+        404: {"model": ErrorResponse, "description": "IP address not in dataset, or no snapshot for as_of"},
+        503: {"model": ErrorResponse, "description": "GeoIP dataset unavailable"},
+    },
+)
+async def lookup_ip_v2(
+    request: Request,
+    ip: str,
+    as_of: Optional[datetime] = Query(
+        None, description="Locate with the dataset active at this date or instant"
+    ),
+    user_id: Optional[str] = Query(
+        None, max_length=255, description="Record the lookup as this user's login and add a travel verdict"
+    ),
+    device_id: Optional[str] = Query(
+        None, max_length=255, description="Record the lookup as a sighting of this device and check its history"
+    ),
+    device_lat: Optional[float] = Query(None, description="Device-reported latitude to compare"),
+    device_lon: Optional[float] = Query(None, description="Device-reported longitude to compare"),
+    device_accuracy_m: Optional[float] = Query(None, ge=0, description="Device-reported accuracy"),
+    session_id: Optional[str] = Query(
+        None, max_length=255, description="Record the lookup as a request of this client session"
+    ),
+    fields: Optional[str] = Query(
+        None, description="Comma-separated attributes to return, e.g. location.country,asn,risk.score"
+    ),
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Geolocate an IPv4 or IPv6 address, with the result in nested objects.
+
+    Same lookup, parameters and errors as GET /api/v1/lookup/ip/{ip}. The
+    address fields are grouped under `ip`, the location under `location`
+    (with `coordinates` null when the record has none), the confidence
+    score and flag under `confidence` and the dataset age and build time
+    under `dataset`; the enrichment blocks are unchanged. `fields` names
+    v2 attributes. ETags and If-None-Match work as in v1.
+
+    Returns:
+        IpLookupV2Response: Location record for the address
+
+    Raises:
+        HTTPException: 400 for invalid input or an unknown field, 401 for
+            an invalid token, 404 if not found, 503 if no dataset
+    """
+    selection = fields_selection(fields, IpLookupV2Response)
+    response = to_v2(
+        assess_ip_or_raise(
+            ip,
+            session,
+            api_key=x_api_key,
+            tenant_id=tenant_id,
+            as_of=as_of,
+            user_id=user_id,
+            device_id=device_id,
+            device_lat=device_lat,
+            device_lon=device_lon,
+            device_accuracy_m=device_accuracy_m,
+            session_id=session_id,
+        )
+    )
+    return lookup_response(request, response, selection, response.dataset.built_at)
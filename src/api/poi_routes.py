@@ -0,0 +1,260 @@
+"""API routes for tenant POI datasets and nearest-neighbor search."""
+from typing import List, Optional
+
+from fastapi import APIRouter, Depends, File, Form, HTTPException, Query, Response, UploadFile, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_tenant_id
+from src.api.uploads import UPLOAD_RESPONSES, csv_text, import_response
+from src.config import get_config
+from src.database import get_db_session
+from src.models.database_models import PoiDataset
+from src.models.schemas import (
+    CsvImportResponse,
+    ErrorResponse,
+    PoiDatasetCreate,
+    PoiDatasetResponse,
+    PoiMatchResponse,
+    PoiNearestResponse,
+)
+from src.services.csv_import_service import CsvImportService
+from src.services.poi_service import PoiService
+
+router = APIRouter(prefix="/api/v1", tags=["poi"])
+
+AUTH_RESPONSES = {
+    401: {"model": ErrorResponse, "description": "Missing or invalid bearer token"},
+    503: {"model": ErrorResponse, "description": "Token authentication not configured"},
+}
+
+
+def _to_response(dataset: PoiDataset) -> PoiDatasetResponse:
+    """Convert a stored dataset to the API response model."""
+    return PoiDatasetResponse(
+        dataset_id=dataset.dataset_id,
+        name=dataset.name,
+        poi_count=dataset.poi_count,
+        created_at=dataset.created_at,
+    )
+
+
+def _bad_request(e: ValueError) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_400_BAD_REQUEST,
+        detail={"error_code": "E002", "error_message": str(e), "details": None},
+    )
+
+
+def _get_or_404(service: PoiService, tenant_id: str, dataset_id: str) -> PoiDataset:
+    dataset = service.get_dataset(tenant_id, dataset_id)
+    if dataset is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"POI dataset {dataset_id} not found",
+                "details": None,
+            },
+        )
+    return dataset
+
+
+@router.post(
+    "/poi/datasets",
+    response_model=PoiDatasetResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid dataset"},
+        **AUTH_RESPONSES,
+    },
+)
+async def create_poi_dataset(
+    request: PoiDatasetCreate,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Upload a POI dataset for the calling tenant.
+
+    Args:
+        request: Dataset name and POIs
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        PoiDatasetResponse: Stored dataset
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 without a valid token
+    """
+    try:
+        dataset = PoiService(session).create_dataset(
+            tenant_id,
+            request.name,
+            [poi.model_dump() for poi in request.pois],
+        )
+    except ValueError as e:
+        raise _bad_request(e)
+    return _to_response(dataset)
+
+
+@router.post(
+    "/poi/datasets/import",
+    response_model=CsvImportResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid CSV or no valid rows"},
+        **UPLOAD_RESPONSES,
+        **AUTH_RESPONSES,
+    },
+)
+async def import_poi_dataset(
+    name: str = Form(..., min_length=1, max_length=255, description="Dataset name"),
+    file: UploadFile = File(..., description="CSV with id, latitude, longitude and optional name and properties columns"),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Create a POI dataset from the valid rows of an uploaded CSV.
+
+    Rows are validated one by one (a repeated id is rejected too); the
+    dataset is made of the accepted rows. The response counts both and
+    lists the first rejected rows, and the outcome of every row is
+    downloaded from result_url, like a batch job's results.
+
+    Args:
+        name: Dataset display name (multipart field)
+        file: Uploaded CSV (multipart)
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        CsvImportResponse: Row counts, first row errors, the results URL
+            and the dataset_id
+
+    Raises:
+        HTTPException: 400 for a CSV without the required columns or
+            without valid rows, 401 without a valid token, 413 for an
+            upload over UPLOAD_MAX_BYTES
+    """
+    try:
+        result = CsvImportService(session, get_config().batch_job_result_dir).import_pois(
+            tenant_id, file.filename, name, csv_text(file)
+        )
+    except ValueError as e:
+        raise _bad_request(e)
+    return import_response(result)
+
+
+@router.get(
+    "/poi/datasets",
+    response_model=List[PoiDatasetResponse],
+    responses=AUTH_RESPONSES,
+)
+async def list_poi_datasets(
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """List the calling tenant's POI datasets."""
+    return [_to_response(d) for d in PoiService(session).list_datasets(tenant_id)]
+
+
+@router.get(
+    "/poi/datasets/{dataset_id}",
+    response_model=PoiDatasetResponse,
+    responses={
+        404: {"model": ErrorResponse, "description": "Dataset not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def get_poi_dataset(
+    dataset_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Fetch one of the calling tenant's POI datasets."""
+    return _to_response(_get_or_404(PoiService(session), tenant_id, dataset_id))
+
+
+@router.delete(
+    "/poi/datasets/{dataset_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    responses={
+        404: {"model": ErrorResponse, "description": "Dataset not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def delete_poi_dataset(
+    dataset_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Delete a POI dataset and drop its in-memory index."""
+    service = PoiService(session)
+    dataset = _get_or_404(service, tenant_id, dataset_id)
+    try:
+        service.delete_dataset(dataset)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail={"error_code": "E999", "error_message": str(e), "details": None},
+        )
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.get(
+    "/poi/datasets/{dataset_id}/nearest",
+    response_model=PoiNearestResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid query"},
+        404: {"model": ErrorResponse, "description": "Dataset not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def nearest_pois(
+    dataset_id: str,
+    lat: float = Query(..., description="Latitude in degrees"),
+    lon: float = Query(..., description="Longitude in degrees"),
+    n: int = Query(10, ge=1, description="Maximum number of POIs"),
+    radius_m: Optional[float] = Query(None, gt=0, description="Search radius in meters"),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Find the POIs nearest to a coordinate.
+
+    Args:
+        dataset_id: Dataset to search
+        lat: Latitude (-90 to 90)
+        lon: Longitude (-180 to 180)
+        n: Maximum number of results (up to POI_MAX_RESULTS)
+        radius_m: Only return POIs within this distance
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        PoiNearestResponse: Matches, nearest first
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 without a valid token,
+            404 if the dataset does not exist for this tenant
+    """
+    service = PoiService(session)
+    dataset = _get_or_404(service, tenant_id, dataset_id)
+    try:
+        matches = service.nearest(dataset, lat, lon, n=n, radius_meters=radius_m)
+    except ValueError as e:
+        raise _bad_request(e)
+    return PoiNearestResponse(
+        dataset_id=dataset_id,
+        latitude=lat,
+        longitude=lon,
+        radius_m=radius_m,
+        results=[
+            PoiMatchResponse(
+                id=m.poi.poi_id,
+                name=m.poi.name,
+                latitude=m.poi.latitude,
+                longitude=m.poi.longitude,
+                distance_m=round(m.distance_meters, 2),
+                properties=m.poi.properties,
+            )
+            for m in matches
+        ],
+    )
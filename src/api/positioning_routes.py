@@ -0,0 +1,129 @@
+"""API routes for positioning devices from radio observations."""
+from fastapi import APIRouter, Depends, HTTPException, status
+from sqlalchemy.orm import Session
+from src.database import get_db_session
+from src.models.schemas import (
+    CellLocateRequest,
+    ErrorResponse,
+    RadioPositionResponse,
+    WifiLocateRequest,
+)
+from src.services.cell_service import CellObservation, CellPositioningService
+from src.services.wifi_service import WifiPositioningService
+from src.spatial import geohash
+from src.spatial.positioning import PositionEstimate
+
+router = APIRouter(prefix="/api/v1", tags=["positioning"])
+
+
+def _to_response(estimate: PositionEstimate, source: str, known: int) -> RadioPositionResponse:
+    """Convert a position estimate to the API response model."""
+    return RadioPositionResponse(
+        latitude=round(estimate.latitude, 6),
+        longitude=round(estimate.longitude, 6),
+        accuracy_m=round(estimate.accuracy_m, 1),
+        geohash=geohash.encode(
+            estimate.latitude,
+            estimate.longitude,
+            geohash.precision_for_radius(estimate.accuracy_m),
+        ),
+        source=source,
+        anchors_used=estimate.anchors_used,
+        anchors_known=known,
+    )
+
+
+def _bad_request(e: ValueError) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_400_BAD_REQUEST,
+        detail={"error_code": "E002", "error_message": str(e), "details": None},
+    )
+
+
+def _not_found(e: LookupError) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_404_NOT_FOUND,
+        detail={"error_code": "E004", "error_message": str(e), "details": None},
+    )
+
+
+@router.post(
+    "/position/wifi",
+    response_model=RadioPositionResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid observations"},
+        404: {"model": ErrorResponse, "description": "Too few known access points"},
+    },
+)
+async def locate_wifi(
+    request: WifiLocateRequest,
+    session: Session = Depends(get_db_session),
+):
+    """Estimate a device position from the WiFi access points it hears.
+
+    Args:
+        request: Observed BSSIDs with their RSSI
+        session: Database session (injected dependency)
+
+    Returns:
+        RadioPositionResponse: Estimated position and accuracy radius
+
+    Raises:
+        HTTPException: 400 for invalid observations, 404 if fewer than
+            WIFI_MIN_ACCESS_POINTS observed access points are known
+    """
+    try:
+        position = WifiPositioningService(session).locate(
+            [(ap.bssid, ap.rssi) for ap in request.access_points]
+        )
+    except ValueError as e:
+        raise _bad_request(e)
+    except LookupError as e:
+        raise _not_found(e)
+    return _to_response(position.estimate, "wifi", position.access_points_known)
+
+
+@router.post(
+    "/position/cell",
+    response_model=RadioPositionResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid observations"},
+        404: {"model": ErrorResponse, "description": "Too few known cells"},
+    },
+)
+async def locate_cell(
+    request: CellLocateRequest,
+    session: Session = Depends(get_db_session),
+):
+    """Estimate a device position from the cells it reports.
+
+    Args:
+        request: Serving and neighbor cells (MCC/MNC/LAC/CID, optional
+            radio type and timing advance)
+        session: Database session (injected dependency)
+
+    Returns:
+        RadioPositionResponse: Estimated position and accuracy radius
+
+    Raises:
+        HTTPException: 400 for invalid observations, 404 if fewer than
+            CELL_MIN_TOWERS observed cells are known
+    """
+    observations = [
+        CellObservation(
+            mcc=cell.mcc,
+            mnc=cell.mnc,
+            lac=cell.lac,
+            cell_id=cell.cid,
+            radio=cell.radio,
+            timing_advance=cell.timing_advance,
+        )
+        for cell in request.cells
+    ]
+    try:
+        position = CellPositioningService(session).locate(observations)
+    except ValueError as e:
+        raise _bad_request(e)
+    except LookupError as e:
+        raise _not_found(e)
+    return _to_response(position.estimate, "cell", position.towers_known)
@@ -0,0 +1,170 @@
+"""API routes for postal code areas."""
+from typing import Any, Dict, Optional
+
+from fastapi import APIRouter, Depends, HTTPException, Query, status
+from sqlalchemy.orm import Session
+from src.api.geojson import GEOJSON_CONTENT, geojson_response, wants_geojson
+from src.database import get_db_session
+from src.models.database_models import PostalArea
+from src.models.schemas import ErrorResponse, PostalAreaResponse, PostalValidationResponse
+from src.services.postal_service import PostalService
+from src.spatial import geojson
+
+router = APIRouter(prefix="/api/v1", tags=["postal"])
+
+
+def _to_response(area: PostalArea, include_geometry: bool = False) -> PostalAreaResponse:
+    """Convert a stored postal area to the API response model."""
+    return PostalAreaResponse(
+        country_code=area.country_code,
+        postal_code=area.postal_code,
+        name=area.name,
+        latitude=round(area.centroid_lat, 6),
+        longitude=round(area.centroid_lon, 6),
+        bbox=[area.min_lon, area.min_lat, area.max_lon, area.max_lat],
+        geometry=area.geometry if include_geometry else None,
+    )
+
+
+def _to_feature(area: PostalArea) -> Dict[str, Any]:
+    """GeoJSON Feature of a postal area: its boundary (or centroid, if none was imported)."""
+    attributes = _to_response(area).model_dump(exclude={"geometry", "bbox"})
+    geometry = area.geometry or geojson.point(attributes["longitude"], attributes["latitude"])
+    return geojson.feature(geometry, attributes, f"{area.country_code}-{area.postal_code}")
+
+
+def _bad_request(e: ValueError) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_400_BAD_REQUEST,
+        detail={"error_code": "E002", "error_message": str(e), "details": None},
+    )
+
+
+def _not_found(message: str) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_404_NOT_FOUND,
+        detail={"error_code": "E004", "error_message": message, "details": None},
+    )
+
+
+@router.get(
+    "/postal-codes/locate",
+    response_model=PostalAreaResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid coordinate or country"},
+        200: GEOJSON_CONTENT,
+        404: {"model": ErrorResponse, "description": "No postal area contains the coordinate"},
+    },
+)
+async def locate_postal_code(
+    lat: float = Query(..., description="Latitude (-90 to 90)"),
+    lon: float = Query(..., description="Longitude (-180 to 180)"),
+    country: Optional[str] = Query(None, description="Restrict to an ISO 3166-1 alpha-2 country"),
+    geometry: bool = Query(False, description="Include the GeoJSON boundary"),
+    output_format: str = Query("json", alias="format", description="json, or geojson for a GeoJSON Feature"),
+    session: Session = Depends(get_db_session),
+):
+    """Find the postal code area containing a coordinate.
+
+    Args:
+        lat: Latitude
+        lon: Longitude
+        country: Optional country filter
+        geometry: Include the boundary polygon
+        output_format: Response format (json, or geojson: a Feature of the boundary)
+        session: Database session (injected dependency)
+
+    Returns:
+        PostalAreaResponse: Containing area (the smallest where areas overlap)
+
+    Raises:
+        HTTPException: 400 for invalid input, 404 if no imported area contains the point
+    """
+    as_geojson = wants_geojson(output_format)
+    try:
+        area = PostalService(session).locate(lat, lon, country)
+    except ValueError as e:
+        raise _bad_request(e)
+    if area is None:
+        raise _not_found(f"No postal area contains ({lat}, {lon})")
+    if as_geojson:
+        return geojson_response(_to_feature(area))
+    return _to_response(area, geometry)
+
+
+@router.get(
+    "/postal-codes/{country}/{postal_code}",
+    response_model=PostalAreaResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Malformed country or postal code"},
+        200: GEOJSON_CONTENT,
+        404: {"model": ErrorResponse, "description": "Postal code not in the dataset"},
+    },
+)
+async def get_postal_code(
+    country: str,
+    postal_code: str,
+    geometry: bool = Query(False, description="Include the GeoJSON boundary"),
+    output_format: str = Query("json", alias="format", description="json, or geojson for a GeoJSON Feature"),
+    session: Session = Depends(get_db_session),
+):
+    """Centroid and boundary of a postal code.
+
+    Args:
+        country: ISO 3166-1 alpha-2 country code
+        postal_code: Postal code (case and spacing are normalized)
+        geometry: Include the boundary polygon
+        output_format: Response format (json, or geojson: a Feature of the boundary)
+        session: Database session (injected dependency)
+
+    Returns:
+        PostalAreaResponse: Postal area
+
+    Raises:
+        HTTPException: 400 for malformed input, 404 if the code is not imported
+    """
+    as_geojson = wants_geojson(output_format)
+    try:
+        area = PostalService(session).get(country, postal_code)
+    except ValueError as e:
+        raise _bad_request(e)
+    if area is None:
+        raise _not_found(f"Postal code {postal_code} not found for {country.upper()}")
+    if as_geojson:
+        return geojson_response(_to_feature(area))
+    return _to_response(area, geometry)
+
+
+@router.get(
+    "/postal-codes/{country}/{postal_code}/validate",
+    response_model=PostalValidationResponse,
+    responses={400: {"model": ErrorResponse, "description": "Malformed country or postal code"}},
+)
+async def validate_postal_code(
+    country: str,
+    postal_code: str,
+    session: Session = Depends(get_db_session),
+):
+    """Check a postal code against the country's format and the dataset.
+
+    Args:
+        country: ISO 3166-1 alpha-2 country code
+        postal_code: Postal code
+        session: Database session (injected dependency)
+
+    Returns:
+        PostalValidationResponse: Format check and whether the area is known
+
+    Raises:
+        HTTPException: 400 for malformed input
+    """
+    try:
+        result = PostalService(session).validate(country, postal_code)
+    except ValueError as e:
+        raise _bad_request(e)
+    return PostalValidationResponse(
+        country_code=result.country_code,
+        postal_code=result.postal_code,
+        format_valid=result.format_valid,
+        known=result.known,
+    )
@@ -0,0 +1,93 @@
+"""Protobuf responses for lookup routes (content negotiation on Accept).
+
+Lookup routes answer in JSON unless the request prefers protobuf:
+
+    Accept: application/x-protobuf
+
+The body is then the route's message of geolocation.proto (IpLocation for
+a single lookup, BatchLookupResponse for a batch) in the binary encoding,
+as the gRPC API returns it. Errors are always JSON, and so is the answer
+to clients listing application/json at the same or a higher quality.
+Responses of negotiating routes carry `Vary: Accept` so caches keep the
+two formats apart.
+"""
+
+from typing import Any, Callable, Dict, Optional
+
+from fastapi import HTTPException, Request, Response, status
+
+from src.api.etag import conditional_response
+from src.grpc_api.messages import get_protos
+
+PROTOBUF_MEDIA_TYPE = "application/x-protobuf"
+JSON_MEDIA_TYPE = "application/json"
+
+# Sent by every route whose format depends on Accept
+VARY_ACCEPT = {"Vary": "Accept"}
+
+
+def accept_qualities(accept: Optional[str]) -> Dict[str, float]:
+    """Quality of each media range of an Accept header (RFC 9110), lower-cased.
+
+    Malformed q values count as 1; a media range listed twice keeps its
+    highest quality.
+    """
+    qualities: Dict[str, float] = {}
+    for part in (accept or "").split(","):
+        media_range, *params = (item.strip() for item in part.split(";"))
+        if not media_range:
+            continue
+        quality = 1.0
+        for param in params:
+            name, _, value = param.partition("=")
+            if name.strip().lower() == "q":
+                try:
+                    quality = min(max(float(value), 0.0), 1.0)
+                except ValueError:
+                    quality = 1.0
+        media_range = media_range.lower()
+        qualities[media_range] = max(quality, qualities.get(media_range, 0.0))
+    return qualities
+
+
+def wants_protobuf(request: Request) -> bool:
+    """Whether the request prefers protobuf over JSON.
+
+    Only an explicit application/x-protobuf range selects protobuf (a
+    wildcard keeps JSON), unless application/json is listed at the same or
+    a higher quality.
+    """
+    qualities = accept_qualities(request.headers.get("accept"))
+    protobuf = qualities.get(PROTOBUF_MEDIA_TYPE, 0.0)
+    return protobuf > 0 and protobuf > qualities.get(JSON_MEDIA_TYPE, 0.0)
+
+
+def protobuf_response(
+    request: Request, to_message: Callable[[Any], Any], version: Optional[str] = None
+) -> Response:
+    """Protobuf response, with a dataset ETag if a version is given.
+
+    Args:
+        request: Request being answered (for If-None-Match)
+        to_message: Builds the message from the message module of geolocation.proto
+        version: Dataset version (see dataset_version); None for no ETag
+
+    Raises:
+        HTTPException: 503 if the grpc dependencies are not installed
+    """
+    try:
+        protos = get_protos()
+    except RuntimeError as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={
+                "error_code": "E003",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    # Deterministic: the same message always gives the same bytes (and ETag)
+    body = to_message(protos).SerializeToString(deterministic=True)
+    if version is None:
+        return Response(content=body, media_type=PROTOBUF_MEDIA_TYPE, headers=VARY_ACCEPT)
+    return conditional_response(request, body, PROTOBUF_MEDIA_TYPE, version, VARY_ACCEPT)
@@ -0,0 +1,138 @@
+"""API routes for tenant high-risk countries, regions and polygons."""
+from typing import Any, Dict
+
+from fastapi import APIRouter, Depends, HTTPException, Query, Response, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_tenant_id
+from src.api.geojson import GEOJSON_CONTENT, geojson_response, wants_geojson
+from src.api.poi_routes import AUTH_RESPONSES
+from src.database import get_db_session
+from src.models.database_models import TenantRiskArea
+from src.models.schemas import ErrorResponse, RiskAreaCreate, RiskAreaListResponse, RiskAreaResponse
+from src.services.risk_area_service import RiskAreaService
+from src.spatial import geojson
+
+router = APIRouter(prefix="/api/v1", tags=["risk"])
+
+
+def _to_response(area: TenantRiskArea) -> RiskAreaResponse:
+    """Convert a stored entry to the API response model."""
+    return RiskAreaResponse(
+        area_id=area.area_id,
+        kind=area.kind,
+        code=area.code,
+        name=area.name,
+        geometry=area.geometry,
+        created_at=area.created_at,
+    )
+
+
+def _to_feature(area: TenantRiskArea) -> Dict[str, Any]:
+    """GeoJSON Feature of an entry (null geometry for countries and regions)."""
+    attributes = _to_response(area).model_dump(mode="json", exclude={"area_id", "geometry"})
+    return geojson.feature(area.geometry, attributes, area.area_id)
+
+
+def _get_or_404(service: RiskAreaService, tenant_id: str, area_id: str) -> TenantRiskArea:
+    area = service.get_area(tenant_id, area_id)
+    if area is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"High-risk area {area_id} not found",
+                "details": None,
+            },
+        )
+    return area
+
+
+@router.post(
+    "/risk/areas",
+    response_model=RiskAreaResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={
+        201: GEOJSON_CONTENT,
+        400: {"model": ErrorResponse, "description": "Invalid or duplicate entry, or unknown format"},
+        **AUTH_RESPONSES,
+    },
+)
+async def create_risk_area(
+    request: RiskAreaCreate,
+    output_format: str = Query("json", alias="format", description="json, or geojson for a GeoJSON Feature"),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Add a country, region or polygon to the calling tenant's high-risk list.
+
+    Once the tenant has an entry, its list replaces RISK_HIGH_RISK_COUNTRIES
+    when scoring the tenant's lookups.
+
+    Args:
+        request: Kind and code (countries, regions) or geometry (polygons)
+        output_format: Response format (json or geojson)
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        RiskAreaResponse: Stored entry
+
+    Raises:
+        HTTPException: 400 for invalid or duplicate entries, 401 without a valid token
+    """
+    as_geojson = wants_geojson(output_format)
+    try:
+        area = RiskAreaService(session).create_area(
+            tenant_id, request.kind, request.code, request.name, request.geometry
+        )
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={"error_code": "E002", "error_message": str(e), "details": None},
+        )
+    if as_geojson:
+        return geojson_response(_to_feature(area), status.HTTP_201_CREATED)
+    return _to_response(area)
+
+
+@router.get(
+    "/risk/areas",
+    response_model=RiskAreaListResponse,
+    responses={
+        200: GEOJSON_CONTENT,
+        400: {"model": ErrorResponse, "description": "Unknown format"},
+        **AUTH_RESPONSES,
+    },
+)
+async def list_risk_areas(
+    output_format: str = Query(
+        "json", alias="format", description="json, or geojson for a GeoJSON FeatureCollection"
+    ),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """List the calling tenant's high-risk entries, optionally as a GeoJSON FeatureCollection."""
+    as_geojson = wants_geojson(output_format)
+    areas = RiskAreaService(session).list_areas(tenant_id)
+    if as_geojson:
+        return geojson_response(geojson.feature_collection([_to_feature(a) for a in areas]))
+    return RiskAreaListResponse(areas=[_to_response(a) for a in areas])
+
+
+@router.delete(
+    "/risk/areas/{area_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    responses={
+        404: {"model": ErrorResponse, "description": "Entry not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def delete_risk_area(
+    area_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Remove an entry; without entries the global list applies again."""
+    service = RiskAreaService(session)
+    service.delete_area(_get_or_404(service, tenant_id, area_id))
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
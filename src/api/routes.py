@@ -1,35 +1,62 @@
 """API routes for detection ingestion with CoT/TAK output."""
-from fastapi import APIRouter, Depends, HTTPException, status
+import asyncio
+import logging
+from datetime import datetime
+from typing import List, Optional
+from fastapi import APIRouter, Depends, HTTPException, Query, status
 from fastapi.responses import Response
 from sqlalchemy.orm import Session
-from src.models.schemas import DetectionInput, ErrorResponse
+from src.api.dependencies import get_optional_tenant_id
+from src.models.schemas import DetectionInput, DetectionOutput, ErrorResponse
 from src.services.detection_service import DetectionService
 from src.services.cot_service import CotService
+from src.services.live_event_service import LiveEvent, detection_event, get_live_event_hub
+from src.services.webhook_service import WebhookService, get_webhook_dispatcher
 from src.database import get_db_session
 from src.config import get_config
 
+logger = logging.getLogger(__name__)
+
 router = APIRouter(prefix="/api/v1", tags=["detections"])
 
 
+def _notify(session: Session, event: LiveEvent) -> None:
+    """Schedule webhook delivery of a detection event without waiting for it.
+
+    Subscribers are resolved now, while the request's session is open.
+    """
+    try:
+        targets = WebhookService(session).targets(event.tenant_id, event.type)
+        if targets:
+            asyncio.create_task(get_webhook_dispatcher().dispatch(targets, event.type, event.data))
+    except Exception as e:
+        logger.error(f"Failed to schedule webhook delivery of detection {event.data['detection_id']}: {str(e)}")
+
+
 @router.post(
     "/detections",
     status_code=status.HTTP_201_CREATED,
     responses={
         400: {"model": ErrorResponse, "description": "Invalid detection payload"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
     }
 )
 async def create_detection(
     detection: DetectionInput,
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
     session: Session = Depends(get_db_session),
 ):
     """Accept detection data and return CoT/TAK format.
 
     Ingests AI detection with image pixels and camera metadata,
     calculates geolocation via photogrammetry, and returns standard
-    Cursor on Target (CoT) XML for TAK system integration.
+    Cursor on Target (CoT) XML for TAK system integration. Detections
+    sent with a bearer token are also pushed to the tenant's live event
+    feed and delivered to its webhooks subscribed to detection events.
 
     Args:
         detection: Detection payload with image and pixel coordinates
+        tenant_id: Calling tenant, if a bearer token was sent
         session: Database session (injected dependency)
 
     Returns:
@@ -41,7 +68,7 @@ async def create_detection(
     try:
         # Process detection: geolocate and store
         service = DetectionService(session)
-        det_result = service.accept_detection(detection)
+        det_result = service.accept_detection(detection, tenant_id=tenant_id)
         detection_id = det_result["detection_id"]
         geolocation = det_result["geolocation"]
 
@@ -64,15 +91,34 @@ async def create_detection(
         except Exception:
             pass  # Non-critical, continue even if TAK push fails
 
+        if tenant_id is not None:
+            event = detection_event(
+                tenant_id,
+                detection_id,
+                detection.object_class,
+                detection.camera_id,
+                geolocation.latitude,
+                geolocation.longitude,
+                geolocation.confidence_flag,
+                det_result["geofence_ids"],
+                detection.timestamp,
+            )
+            get_live_event_hub().publish(event)
+            _notify(session, event)
+
+        headers = {
+            "X-Detection-ID": detection_id,
+            "X-Confidence-Flag": geolocation.confidence_flag,
+        }
+        if det_result["geofence_ids"]:
+            headers["X-Geofence-IDs"] = ",".join(det_result["geofence_ids"])
+
         # Return CoT XML as primary response
         return Response(
             content=cot_xml,
             status_code=status.HTTP_201_CREATED,
             media_type="application/xml",
-            headers={
-                "X-Detection-ID": detection_id,
-                "X-Confidence-Flag": geolocation.confidence_flag,
-            },
+            headers=headers,
         )
 
     except ValueError as e:
@@ -98,6 +144,76 @@ async def create_detection(
         )
 
 
+@router.get(
+    "/detections",
+    response_model=List[DetectionOutput],
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid geohash"},
+        401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+    }
+)
+async def list_detections(
+    geohash: str = Query(..., description="Geohash prefix of the area to query"),
+    since: Optional[datetime] = Query(None, description="Captured at or after"),
+    until: Optional[datetime] = Query(None, description="Captured before"),
+    limit: int = Query(100, ge=1, le=1000, description="Maximum detections"),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """List stored detections located in a geohash cell.
+
+    Shorter prefixes cover larger areas (5 characters is roughly 5km), so
+    clients can bucket nearby detections without a spatial query. Callers
+    see the detections their tenant submitted; anonymous callers only
+    those submitted without a token.
+
+    Args:
+        geohash: Geohash prefix
+        since: Lower capture-time bound
+        until: Upper capture-time bound
+        limit: Maximum number of results
+        tenant_id: Calling tenant, if a bearer token was sent
+        session: Database session (injected dependency)
+
+    Returns:
+        List[DetectionOutput]: Matching detections, newest first
+
+    Raises:
+        HTTPException: 400 for an invalid geohash
+    """
+    try:
+        detections = DetectionService(session).find_by_geohash_prefix(
+            geohash, tenant_id=tenant_id, since=since, until=until, limit=limit
+        )
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+
+    return [
+        DetectionOutput(
+            detection_id=d.detection_id,
+            calculated_lat=d.calculated_lat,
+            calculated_lon=d.calculated_lon,
+            confidence_flag=d.confidence_flag,
+            confidence_value=d.confidence_value,
+            uncertainty_radius_meters=d.uncertainty_radius_meters,
+            object_class=d.object_class,
+            ai_confidence=d.ai_confidence,
+            source=d.source,
+            timestamp=d.timestamp,
+            processed_at=d.created_at,
+            geohash=d.geohash,
+        )
+        for d in detections
+    ]
+
+
 @router.get("/health")
 async def health_check():
     """Health check endpoint."""
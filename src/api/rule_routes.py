@@ -0,0 +1,149 @@
+"""API routes for detection rules and feedback on their decisions."""
+from datetime import datetime
+from typing import Dict, Optional
+
+from fastapi import APIRouter, Depends, HTTPException, Query, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_tenant_id
+from src.api.poi_routes import AUTH_RESPONSES
+from src.database import get_db_session
+from src.models.schemas import (
+    ErrorResponse,
+    RuleFeedbackRequest,
+    RuleFeedbackResponse,
+    RuleFeedbackStatsInfo,
+    RuleFeedbackStatsResponse,
+    RuleInfo,
+    RuleListResponse,
+    RuleValidateRequest,
+    RuleValidateResponse,
+)
+from src.services.rule_dsl import RuleSyntaxError, compile_expression, describe_variables
+from src.services.rule_feedback_service import RuleFeedbackService
+from src.services.rule_service import RuleEngine, get_rule_engine
+
+router = APIRouter(prefix="/api/v1", tags=["rules"])
+
+
+def _list_response(engine: RuleEngine) -> RuleListResponse:
+    return RuleListResponse(
+        rules=[RuleInfo(**rule.to_dict()) for rule in engine.rules],
+        version=engine.version,
+        loaded_at=engine.loaded_at,
+        last_error=engine.last_error,
+    )
+
+
+@router.get("/rules", response_model=RuleListResponse)
+async def list_rules():
+    """Active detection rules, and why the latest file change was rejected if it was."""
+    return _list_response(get_rule_engine())
+
+
+@router.post(
+    "/rules/reload",
+    response_model=RuleListResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Rules file rejected; previous rules stay active"},
+        503: {"model": ErrorResponse, "description": "RULES_PATH not set"},
+    },
+)
+async def reload_rules():
+    """Re-read the rules file now instead of waiting for the change check.
+
+    Returns:
+        RuleListResponse: Newly active rules
+
+    Raises:
+        HTTPException: 400 if the file is invalid, 503 if RULES_PATH is not set
+    """
+    engine = get_rule_engine()
+    if not engine.path:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={"error_code": "E003", "error_message": "RULES_PATH is not set", "details": None},
+        )
+    if not engine.reload():
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={"error_code": "E002", "error_message": engine.last_error, "details": None},
+        )
+    return _list_response(engine)
+
+
+@router.post("/rules/validate", response_model=RuleValidateResponse)
+async def validate_rule(request: RuleValidateRequest):
+    """Check a rule expression before adding it to the rules file."""
+    try:
+        expression = compile_expression(request.expression)
+    except RuleSyntaxError as e:
+        return RuleValidateResponse(valid=False, error=str(e), position=e.position)
+    return RuleValidateResponse(valid=True, variables=sorted(expression.variables))
+
+
+@router.get("/rules/variables", response_model=Dict[str, Dict[str, str]])
+async def rule_variables():
+    """Variables available to rule expressions, with their types."""
+    return describe_variables()
+
+
+@router.post(
+    "/rules/feedback",
+    response_model=RuleFeedbackResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid feedback or unknown rule"},
+        **AUTH_RESPONSES,
+    },
+)
+async def report_rule_feedback(
+    request: RuleFeedbackRequest,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Report that a rule decision was wrong (or confirm it was right).
+
+    Args:
+        request: Rules from the lookup's rules block and the verdict
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        RuleFeedbackResponse: Stored feedback
+
+    Raises:
+        HTTPException: 400 for invalid feedback or rules not in the active
+            rules file, 401 without a valid token
+    """
+    known = {rule.rule_id for rule in get_rule_engine().rules}
+    try:
+        rows = RuleFeedbackService(session).record(
+            tenant_id,
+            request.rule_ids,
+            request.verdict,
+            decision=request.decision,
+            ip_address=request.ip_address,
+            comment=request.comment,
+            known_rule_ids=known,
+        )
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={"error_code": "E002", "error_message": str(e), "details": None},
+        )
+    return RuleFeedbackResponse(
+        feedback_id=rows[0].feedback_id,
+        rule_ids=[row.rule_id for row in rows],
+        verdict=rows[0].verdict,
+        created_at=rows[0].created_at,
+    )
+
+
+@router.get("/rules/feedback/stats", response_model=RuleFeedbackStatsResponse)
+async def rule_feedback_stats(
+    since: Optional[datetime] = Query(None, description="Only feedback at or after this time"),
+    session: Session = Depends(get_db_session),
+):
+    """Per-rule precision from integrator feedback, least precise first."""
+    stats = RuleFeedbackService(session).stats(since)
+    return RuleFeedbackStatsResponse(rules=[RuleFeedbackStatsInfo(**entry.to_dict()) for entry in stats])
@@ -0,0 +1,139 @@
+"""API routes for tenant sanctions policies and the block audit trail."""
+from datetime import datetime
+from typing import Optional
+
+from fastapi import APIRouter, Depends, HTTPException, Query, Response, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_tenant_id
+from src.api.poi_routes import AUTH_RESPONSES
+from src.database import get_db_session
+from src.models.schemas import (
+    ErrorResponse,
+    SanctionsAuditListResponse,
+    SanctionsAuditRecordInfo,
+    SanctionsPolicyRequest,
+    SanctionsPolicyResponse,
+)
+from src.services.sanctions_service import SanctionsScreener, SanctionsService, get_sanctions_screener
+
+router = APIRouter(prefix="/api/v1", tags=["sanctions"])
+
+
+def _error(status_code: int, code: str, e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status_code,
+        detail={"error_code": code, "error_message": str(e), "details": None},
+    )
+
+
+def _screener() -> SanctionsScreener:
+    try:
+        return get_sanctions_screener()
+    except ValueError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+
+
+def _policy_response(tenant_id: str, session: Session) -> SanctionsPolicyResponse:
+    policy = _screener().policy_for(tenant_id, session)
+    row = SanctionsService(session).get_policy(tenant_id)
+    return SanctionsPolicyResponse(
+        action=policy.action,
+        codes=sorted(policy.codes),
+        override_action=row.action if row else None,
+        extra_codes=row.extra_codes if row else [],
+        exempt_codes=row.exempt_codes if row else [],
+        updated_at=row.updated_at if row else None,
+    )
+
+
+@router.get(
+    "/sanctions/policy",
+    response_model=SanctionsPolicyResponse,
+    responses={
+        503: {"model": ErrorResponse, "description": "Invalid SANCTIONS_MODE or SANCTIONS_COUNTRIES"},
+        **AUTH_RESPONSES,
+    },
+)
+async def get_sanctions_policy(
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Sanctions policy applied to the calling tenant's requests."""
+    return _policy_response(tenant_id, session)
+
+
+@router.put(
+    "/sanctions/policy",
+    response_model=SanctionsPolicyResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid country or region code"},
+        503: {"model": ErrorResponse, "description": "Invalid SANCTIONS_MODE or SANCTIONS_COUNTRIES"},
+        **AUTH_RESPONSES,
+    },
+)
+async def set_sanctions_policy(
+    request: SanctionsPolicyRequest,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Replace the calling tenant's adjustments to the global sanctions policy.
+
+    Args:
+        request: Action and added/exempted countries or regions
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        SanctionsPolicyResponse: Policy now applied to the tenant
+
+    Raises:
+        HTTPException: 400 for invalid codes, 401 without a valid token
+    """
+    try:
+        SanctionsService(session).set_policy(
+            tenant_id, request.action, request.extra_codes, request.exempt_codes
+        )
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    return _policy_response(tenant_id, session)
+
+
+@router.delete(
+    "/sanctions/policy",
+    status_code=status.HTTP_204_NO_CONTENT,
+    responses={
+        404: {"model": ErrorResponse, "description": "Tenant has no adjustments"},
+        **AUTH_RESPONSES,
+    },
+)
+async def delete_sanctions_policy(
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Revert the calling tenant to the global sanctions policy."""
+    if not SanctionsService(session).delete_policy(tenant_id):
+        raise _error(status.HTTP_404_NOT_FOUND, "E004", LookupError("No tenant sanctions policy"))
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.get(
+    "/sanctions/audit",
+    response_model=SanctionsAuditListResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid cursor"}, **AUTH_RESPONSES},
+)
+async def list_sanctions_audit(
+    since: Optional[datetime] = Query(None, description="Only blocks at or after this time"),
+    limit: int = Query(100, ge=1, le=1000, description="Most records returned"),
+    cursor: Optional[str] = Query(None, description="next_cursor of the previous page"),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Requests of the calling tenant refused for a sanctions match, newest first, a page at a time."""
+    try:
+        page = SanctionsService(session).audit_page(tenant_id, since, limit, cursor)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    return SanctionsAuditListResponse(
+        records=[SanctionsAuditRecordInfo(**record.to_dict()) for record in page.items],
+        next_cursor=page.next_cursor,
+    )
@@ -0,0 +1,315 @@
+"""API routes for spatial utilities (geohash, H3, distance, CRS conversion)."""
+from datetime import datetime
+from typing import Optional
+from fastapi import APIRouter, Depends, HTTPException, Query, status
+from sqlalchemy.orm import Session
+from src.api.geojson import GEOJSON_CONTENT, geojson_response, wants_geojson
+from src.database import get_db_session
+from src.models.schemas import (
+    CrsTransformResponse,
+    DistanceResponse,
+    ErrorResponse,
+    GeohashResponse,
+    H3CellResponse,
+    H3HeatmapCell,
+    H3HeatmapResponse,
+)
+from src.services.heatmap_service import HeatmapService
+from src.spatial import crs as crs_module
+from src.spatial import distance as geodesy
+from src.spatial import geohash, geojson, h3index
+
+router = APIRouter(prefix="/api/v1", tags=["spatial"])
+
+
+def _geohash_response(value: str) -> GeohashResponse:
+    """Decode a geohash into the API response model."""
+    latitude, longitude, latitude_error, longitude_error = geohash.decode(value)
+    bbox = geohash.decode_bbox(value)
+    return GeohashResponse(
+        geohash=value,
+        latitude=latitude,
+        longitude=longitude,
+        latitude_error=latitude_error,
+        longitude_error=longitude_error,
+        bbox=[bbox.min_lon, bbox.min_lat, bbox.max_lon, bbox.max_lat],
+        neighbors=geohash.neighbors(value),
+    )
+
+
+def _bad_request(e: ValueError) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_400_BAD_REQUEST,
+        detail={
+            "error_code": "E002",
+            "error_message": str(e),
+            "details": None,
+        },
+    )
+
+
+def _unavailable(e: RuntimeError) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+        detail={
+            "error_code": "E003",
+            "error_message": str(e),
+            "details": None,
+        },
+    )
+
+
+@router.get(
+    "/geohash/encode",
+    response_model=GeohashResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid coordinate or precision"}},
+)
+async def encode_geohash(
+    lat: float = Query(..., description="Latitude in degrees"),
+    lon: float = Query(..., description="Longitude in degrees"),
+    precision: int = Query(9, description="Geohash length (1-12)"),
+):
+    """Encode a coordinate as a geohash.
+
+    Args:
+        lat: Latitude (-90 to 90)
+        lon: Longitude (-180 to 180)
+        precision: Number of characters; 5 is ~5km cells, 7 ~150m, 9 ~5m
+
+    Returns:
+        GeohashResponse: Geohash with its cell bounds and neighbors
+
+    Raises:
+        HTTPException: 400 for invalid input
+    """
+    try:
+        return _geohash_response(geohash.encode(lat, lon, precision))
+    except ValueError as e:
+        raise _bad_request(e)
+
+
+@router.get(
+    "/geohash/{value}",
+    response_model=GeohashResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid geohash"}},
+)
+async def decode_geohash(value: str):
+    """Decode a geohash to its cell center, error bounds and neighbors.
+
+    Args:
+        value: Geohash (1-12 base32 characters)
+
+    Returns:
+        GeohashResponse: Decoded cell
+
+    Raises:
+        HTTPException: 400 for invalid input
+    """
+    try:
+        return _geohash_response(geohash.validate(value))
+    except ValueError as e:
+        raise _bad_request(e)
+
+
+@router.get(
+    "/h3/cell",
+    response_model=H3CellResponse,
+    responses={
+        200: GEOJSON_CONTENT,
+        400: {"model": ErrorResponse, "description": "Invalid coordinate, resolution or format"},
+        503: {"model": ErrorResponse, "description": "H3 support not installed"},
+    },
+)
+async def h3_cell(
+    lat: float = Query(..., description="Latitude in degrees"),
+    lon: float = Query(..., description="Longitude in degrees"),
+    resolution: int = Query(9, description="H3 resolution (0-15)"),
+    crs: Optional[str] = Query(None, description="CRS of the returned boundary (default EPSG:4326)"),
+    output_format: str = Query("json", alias="format", description="json, or geojson for a GeoJSON Feature"),
+):
+    """Convert a coordinate to the H3 cell containing it.
+
+    With `format=geojson` the cell is a Feature of its hexagon, the other
+    attributes as properties.
+
+    Args:
+        lat: Latitude (-90 to 90)
+        lon: Longitude (-180 to 180)
+        resolution: H3 resolution; 7 is ~5km² cells, 9 ~0.1km², 11 ~2000m²
+        crs: Reference system for the boundary vertices
+        output_format: Response format (json or geojson)
+
+    Returns:
+        H3CellResponse: Cell index, center, area and boundary
+
+    Raises:
+        HTTPException: 400 for invalid input, 503 if H3 is unavailable
+    """
+    as_geojson = wants_geojson(output_format, crs)
+    try:
+        target = crs_module.parse_crs(crs) if crs else crs_module.WGS84
+        cell = h3index.latlng_to_cell(lat, lon, resolution)
+        latitude, longitude = h3index.cell_to_latlng(cell)
+        transform = crs_module.transformer(crs_module.WGS84, target)
+        response = H3CellResponse(
+            cell=cell,
+            resolution=resolution,
+            latitude=latitude,
+            longitude=longitude,
+            area_km2=h3index.cell_area_km2(cell),
+            boundary=[list(transform(*vertex)) for vertex in h3index.cell_boundary(cell)],
+            crs=target.name,
+        )
+    except ValueError as e:
+        raise _bad_request(e)
+    except RuntimeError as e:
+        raise _unavailable(e)
+    if not as_geojson:
+        return response
+    hexagon = {"type": "Polygon", "coordinates": [response.boundary + response.boundary[:1]]}
+    attributes = response.model_dump(exclude={"cell", "boundary", "crs"})
+    return geojson_response(geojson.feature(hexagon, attributes, response.cell))
+
+
+@router.get(
+    "/h3/heatmap",
+    response_model=H3HeatmapResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid resolution"},
+        503: {"model": ErrorResponse, "description": "H3 support not installed"},
+    },
+)
+async def h3_heatmap(
+    resolution: int = Query(7, description="H3 resolution (0-15)"),
+    since: Optional[datetime] = Query(None, description="Captured at or after"),
+    until: Optional[datetime] = Query(None, description="Captured before"),
+    object_class: Optional[str] = Query(None, description="Only this object class"),
+    session: Session = Depends(get_db_session),
+):
+    """Aggregate stored detections per H3 cell for heatmaps.
+
+    Args:
+        resolution: H3 resolution of the aggregation cells
+        since: Lower capture-time bound
+        until: Upper capture-time bound
+        object_class: Object class filter
+        session: Database session (injected dependency)
+
+    Returns:
+        H3HeatmapResponse: Per-cell detection counts
+
+    Raises:
+        HTTPException: 400 for invalid input, 503 if H3 is unavailable
+    """
+    try:
+        heatmap = HeatmapService(session).h3_counts(
+            resolution, since=since, until=until, object_class=object_class
+        )
+    except ValueError as e:
+        raise _bad_request(e)
+    except RuntimeError as e:
+        raise _unavailable(e)
+
+    return H3HeatmapResponse(
+        resolution=resolution,
+        total=heatmap.total,
+        truncated=heatmap.truncated,
+        cells=[
+            H3HeatmapCell(
+                cell=cell.cell,
+                count=cell.count,
+                latitude=cell.latitude,
+                longitude=cell.longitude,
+            )
+            for cell in heatmap.cells
+        ],
+    )
+
+
+@router.get(
+    "/distance",
+    response_model=DistanceResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid coordinate, unit or method"}},
+)
+async def calculate_distance(
+    from_lat: float = Query(..., description="Start latitude"),
+    from_lon: float = Query(..., description="Start longitude"),
+    to_lat: float = Query(..., description="End latitude"),
+    to_lon: float = Query(..., description="End longitude"),
+    method: str = Query(geodesy.DistanceMethod.VINCENTY, description="haversine or vincenty"),
+    unit: str = Query("m", description="m, km, mi, nmi or ft"),
+):
+    """Distance and bearings between two coordinates.
+
+    Vincenty (WGS84 ellipsoid) is accurate to well under a millimeter;
+    haversine (spherical) is cheaper with up to ~0.5% error.
+
+    Args:
+        from_lat: Start latitude (-90 to 90)
+        from_lon: Start longitude (-180 to 180)
+        to_lat: End latitude (-90 to 90)
+        to_lon: End longitude (-180 to 180)
+        method: Calculation method
+        unit: Output unit
+
+    Returns:
+        DistanceResponse: Distance and initial/final bearings
+
+    Raises:
+        HTTPException: 400 for invalid input
+    """
+    try:
+        result = geodesy.distance(from_lat, from_lon, to_lat, to_lon, method)
+        value = result.in_unit(unit)
+    except ValueError as e:
+        raise _bad_request(e)
+
+    return DistanceResponse(
+        distance=value,
+        unit=unit,
+        method=result.method,
+        initial_bearing=result.initial_bearing,
+        final_bearing=result.final_bearing,
+    )
+
+
+@router.get(
+    "/crs/transform",
+    response_model=CrsTransformResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid coordinate or CRS"}},
+)
+async def transform_coordinate(
+    x: float = Query(..., description="Easting, or longitude for EPSG:4326"),
+    y: float = Query(..., description="Northing, or latitude for EPSG:4326"),
+    from_crs: str = Query("EPSG:4326", description="Source CRS"),
+    to_crs: Optional[str] = Query(
+        None, description="Target CRS (default: the UTM zone containing the coordinate)"
+    ),
+):
+    """Convert a coordinate between WGS84, Web Mercator and UTM zones.
+
+    Args:
+        x: First coordinate (longitude or easting)
+        y: Second coordinate (latitude or northing)
+        from_crs: Source reference system
+        to_crs: Target reference system; omitted picks the UTM zone
+
+    Returns:
+        CrsTransformResponse: Converted coordinate with its WGS84 position
+
+    Raises:
+        HTTPException: 400 for invalid input
+    """
+    try:
+        source = crs_module.parse_crs(from_crs)
+        longitude, latitude = crs_module.to_wgs84(source, x, y)
+        target = (
+            crs_module.parse_crs(to_crs) if to_crs else crs_module.utm_crs_for(longitude, latitude)
+        )
+        target_x, target_y = crs_module.from_wgs84(target, longitude, latitude)
+    except ValueError as e:
+        raise _bad_request(e)
+
+    return CrsTransformResponse(
+        x=target_x, y=target_y, crs=target.name, longitude=longitude, latitude=latitude
+    )
@@ -0,0 +1,76 @@
+"""API routes serving Mapbox Vector Tiles of geofences and detection density."""
+from datetime import datetime
+from typing import Optional
+
+from fastapi import APIRouter, Depends, HTTPException, Query, Response, status
+from sqlalchemy.orm import Session
+
+from src.database import get_db_session
+from src.models.schemas import ErrorResponse
+from src.services.tile_service import TILE_LAYERS, TileService
+from src.spatial.mvt import MEDIA_TYPE
+
+router = APIRouter(prefix="/api/v1", tags=["tiles"])
+
+
+@router.get(
+    "/tiles/{z}/{x}/{y}.mvt",
+    response_class=Response,
+    responses={
+        200: {"content": {MEDIA_TYPE: {}}, "description": "Vector tile (empty body: nothing in the tile)"},
+        400: {"model": ErrorResponse, "description": "Tile outside the grid or unknown layer"},
+    },
+)
+async def get_tile(
+    z: int,
+    x: int,
+    y: int,
+    layers: Optional[str] = Query(
+        None, description="Comma-separated layers (geofences, detections; default both)"
+    ),
+    since: Optional[datetime] = Query(None, description="Detections captured at or after"),
+    until: Optional[datetime] = Query(None, description="Detections captured before"),
+    object_class: Optional[str] = Query(None, description="Only detections of this object class"),
+    session: Session = Depends(get_db_session),
+):
+    """Mapbox Vector Tile of geofences and detection counts, for MapLibre and Mapbox GL.
+
+    The `geofences` layer holds the geofence polygons crossing the tile
+    (attributes `geofence_id`, `name`); the `detections` layer holds one
+    point per occupied cell of a 64 x 64 grid over the tile with the
+    `count` of detections in it. At most HEATMAP_MAX_DETECTIONS of the
+    tile's most recent matching detections are counted; the
+    `X-Heatmap-Truncated: true` header marks a tile that hit the limit.
+
+    Args:
+        z: Zoom level (0-24)
+        x: Tile column
+        y: Tile row, from the north (XYZ scheme)
+        layers: Layers to include
+        since: Lower capture-time bound of detections
+        until: Upper capture-time bound of detections
+        object_class: Object class filter of detections
+        session: Database session (injected dependency)
+
+    Returns:
+        Response: application/vnd.mapbox-vector-tile body
+
+    Raises:
+        HTTPException: 400 for a tile outside the grid or an unknown layer
+    """
+    selected = [name.strip() for name in layers.split(",") if name.strip()] if layers else TILE_LAYERS
+    try:
+        tile = TileService(session).render(
+            z, x, y, selected, since=since, until=until, object_class=object_class
+        )
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={
+                "error_code": "E002",
+                "error_message": str(e),
+                "details": None,
+            },
+        )
+    headers = {"X-Heatmap-Truncated": "true"} if tile.truncated else None
+    return Response(content=tile.data, media_type=MEDIA_TYPE, headers=headers)
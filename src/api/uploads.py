@@ -0,0 +1,86 @@
+"""Multipart CSV uploads shared by lookup jobs and the geofence and POI imports."""
+import codecs
+import os
+import shutil
+from typing import BinaryIO, Optional, TextIO
+
+from fastapi import HTTPException, UploadFile, status
+
+from src.models.schemas import CsvImportResponse, CsvRowError, ErrorResponse
+from src.services.csv_import_service import CsvImportResult
+
+UPLOAD_RESPONSES = {
+    413: {"model": ErrorResponse, "description": "Upload larger than UPLOAD_MAX_BYTES"},
+}
+
+_COPY_CHUNK = 1024 * 1024
+
+
+def upload_file(upload: UploadFile, max_bytes: Optional[int] = None) -> BinaryIO:
+    """The uploaded file, rewound, once its size is checked against the limit.
+
+    Args:
+        upload: Multipart upload
+        max_bytes: Size limit (default UPLOAD_MAX_BYTES)
+
+    Raises:
+        HTTPException: 413 for an upload over the limit
+    """
+    if max_bytes is None:
+        from src.config import get_config
+
+        max_bytes = get_config().upload_max_bytes
+    upload.file.seek(0, os.SEEK_END)
+    size = upload.file.tell()
+    upload.file.seek(0)
+    if size > max_bytes:
+        raise HTTPException(
+            status_code=status.HTTP_413_REQUEST_ENTITY_TOO_LARGE,
+            detail={
+                "error_code": "E002",
+                "error_message": f"Upload of {size} bytes exceeds the limit of {max_bytes}",
+                "details": {"max_bytes": max_bytes},
+            },
+        )
+    return upload.file
+
+
+def csv_text(upload: UploadFile) -> TextIO:
+    """The uploaded CSV as UTF-8 text (a leading byte order mark is skipped), read as it is parsed.
+
+    Raises:
+        HTTPException: 413 for an upload over UPLOAD_MAX_BYTES
+    """
+    # A stream reader rather than TextIOWrapper: spooled upload files are not io.IOBase before 3.11
+    return codecs.getreader("utf-8-sig")(upload_file(upload))
+
+
+def save_upload(upload: UploadFile, path: str, max_bytes: Optional[int] = None) -> None:
+    """Copy the uploaded file to a path, a chunk at a time.
+
+    Raises:
+        HTTPException: 413 for an upload over max_bytes (default UPLOAD_MAX_BYTES)
+    """
+    source = upload_file(upload, max_bytes)
+    with open(path, "wb") as out:
+        shutil.copyfileobj(source, out, _COPY_CHUNK)
+
+
+def import_response(result: CsvImportResult) -> CsvImportResponse:
+    """API response of a finished import."""
+    job = result.job
+    return CsvImportResponse(
+        import_id=job.job_id,
+        total_rows=job.total_rows,
+        imported_rows=job.processed_rows - job.failed_rows,
+        failed_rows=job.failed_rows,
+        errors=[
+            CsvRowError(
+                index=outcome.index,
+                error=ErrorResponse(error_code=outcome.error_code, error_message=outcome.error_message),
+            )
+            for outcome in result.errors
+        ],
+        result_url=f"/api/v1/jobs/{job.job_id}/result",
+        dataset_id=result.dataset_id,
+    )
@@ -0,0 +1,157 @@
+"""API routes reporting the usage of a tenant's API keys."""
+from datetime import date
+from typing import Optional, Tuple
+
+from fastapi import APIRouter, Depends, HTTPException, Query, Response, status
+from sqlalchemy.orm import Session
+
+from src.api.dependencies import get_tenant_id
+from src.api.poi_routes import AUTH_RESPONSES
+from src.config import get_config
+from src.database import get_db_session
+from src.models.schemas import (
+    ErrorResponse,
+    KeyUsageInfo,
+    QuotaAlertPreferencesRequest,
+    QuotaAlertPreferencesResponse,
+    UsageResponse,
+)
+from src.services.quota_alert_service import QuotaAlertService
+from src.services.quota_service import get_quota_enforcer
+from src.services.usage_service import UsageService, default_range
+
+router = APIRouter(prefix="/api/v1", tags=["usage"])
+
+
+def _bad_request(message: str) -> HTTPException:
+    return HTTPException(
+        status_code=status.HTTP_400_BAD_REQUEST,
+        detail={"error_code": "E002", "error_message": message, "details": None},
+    )
+
+
+def _alert_thresholds() -> Tuple[int, ...]:
+    try:
+        return get_quota_enforcer().alert_thresholds
+    except (ValueError, RuntimeError) as e:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail={"error_code": "E003", "error_message": str(e), "details": None},
+        )
+
+
+def _alerts_response(tenant_id: str, session: Session) -> QuotaAlertPreferencesResponse:
+    available = _alert_thresholds()
+    service = QuotaAlertService(session)
+    preferences = service.preferences(tenant_id, available)
+    row = service.get_row(tenant_id)
+    return QuotaAlertPreferencesResponse(
+        thresholds=preferences.thresholds,
+        webhook=preferences.webhook,
+        emails=preferences.emails,
+        available_thresholds=list(available),
+        updated_at=row.updated_at if row else None,
+    )
+
+
+@router.get(
+    "/usage",
+    response_model=UsageResponse,
+    responses={400: {"model": ErrorResponse, "description": "Invalid date range"}, **AUTH_RESPONSES},
+)
+async def get_usage(
+    since: Optional[date] = Query(None, description="First day (UTC; default: 29 days before until)"),
+    until: Optional[date] = Query(None, description="Last day, inclusive (UTC; default: today)"),
+    key_id: Optional[str] = Query(None, description="Only this API key"),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Requests made with the calling tenant's API keys, by endpoint, day
+    and response class.
+
+    Counts are stored every USAGE_FLUSH_INTERVAL_SECONDS, so the latest
+    requests may not be included yet.
+
+    Raises:
+        HTTPException: 400 for a reversed range or one longer than
+            USAGE_MAX_DAYS, 401 without a valid token
+    """
+    default_since, until = default_range(until)
+    since = since or default_since
+    max_days = get_config().usage_max_days
+    if (until - since).days + 1 > max_days:
+        raise _bad_request(f"Usage can be queried for at most {max_days} days at a time")
+    try:
+        keys = UsageService(session).breakdown(tenant_id, since, until, key_id)
+    except ValueError as e:
+        raise _bad_request(str(e))
+    return UsageResponse(
+        since=since.isoformat(),
+        until=until.isoformat(),
+        total=sum(usage.total for usage in keys),
+        keys=[KeyUsageInfo(**vars(usage)) for usage in keys],
+    )
+
+
+@router.get(
+    "/usage/quota-alerts",
+    response_model=QuotaAlertPreferencesResponse,
+    responses={503: {"model": ErrorResponse, "description": "Invalid quota configuration"}, **AUTH_RESPONSES},
+)
+async def get_quota_alerts(
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """How the calling tenant is alerted as its API keys approach their quotas."""
+    return _alerts_response(tenant_id, session)
+
+
+@router.put(
+    "/usage/quota-alerts",
+    response_model=QuotaAlertPreferencesResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Unknown threshold or invalid email address"},
+        503: {"model": ErrorResponse, "description": "Invalid quota configuration"},
+        **AUTH_RESPONSES,
+    },
+)
+async def set_quota_alerts(
+    request: QuotaAlertPreferencesRequest,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Replace the calling tenant's quota alert preferences.
+
+    Thresholds are percentages of a cap, of those in QUOTA_ALERT_THRESHOLDS;
+    an empty list turns alerts off. Webhook alerts go to the tenant's
+    webhooks subscribed to `quota.threshold`.
+
+    Raises:
+        HTTPException: 400 for unknown thresholds or invalid addresses,
+            401 without a valid token
+    """
+    try:
+        QuotaAlertService(session).set_preferences(
+            tenant_id, request.thresholds, request.webhook, request.emails, _alert_thresholds()
+        )
+    except ValueError as e:
+        raise _bad_request(str(e))
+    return _alerts_response(tenant_id, session)
+
+
+@router.delete(
+    "/usage/quota-alerts",
+    status_code=status.HTTP_204_NO_CONTENT,
+    responses={404: {"model": ErrorResponse, "description": "Tenant has no preferences"}, **AUTH_RESPONSES},
+)
+async def delete_quota_alerts(
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Revert the calling tenant to the default alerts: every threshold, by webhook only."""
+    if not QuotaAlertService(session).delete_preferences(tenant_id):
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={"error_code": "E004", "error_message": "No quota alert preferences", "details": None},
+        )
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
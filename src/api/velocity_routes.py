@@ -0,0 +1,129 @@
+"""API routes for per-entity velocity counters."""
+from datetime import timezone
+from typing import Optional
+
+from fastapi import APIRouter, Depends, Header, HTTPException, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_optional_tenant_id
+from src.api.lookup_routes import lookup_ip_address, tenant_overrides
+from src.database import get_db_session
+from src.models.schemas import ErrorResponse, VelocityObservationRequest, VelocityResponse
+from src.services.erasure_service import ErasureService
+from src.services.velocity_service import get_velocity_tracker
+
+router = APIRouter(prefix="/api/v1", tags=["velocity"])
+
+RESPONSES = {
+    400: {"model": ErrorResponse, "description": "Invalid entity or location"},
+    401: {"model": ErrorResponse, "description": "Invalid bearer token"},
+    503: {"model": ErrorResponse, "description": "Velocity store or GeoIP dataset unavailable"},
+}
+
+
+def _error(status_code: int, code: str, e: Exception) -> HTTPException:
+    return HTTPException(
+        status_code=status_code,
+        detail={"error_code": code, "error_message": str(e), "details": None},
+    )
+
+
+@router.post(
+    "/velocity/observe",
+    response_model=VelocityResponse,
+    responses={**RESPONSES, 404: {"model": ErrorResponse, "description": "IP address not in dataset"}},
+)
+async def observe_velocity(
+    observation: VelocityObservationRequest,
+    x_api_key: Optional[str] = Header(None),
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Record where an account or device appeared and return its counts.
+
+    Erased entities are not recorded; their counts are returned as they are.
+
+    Args:
+        observation: Entity and its IP address, or country code and city
+        x_api_key: Caller API key (for locating the IP address)
+        tenant_id: Calling tenant, if a bearer token was sent; entity IDs are scoped to it
+        session: Database session (injected dependency)
+
+    Returns:
+        VelocityResponse: Distinct countries and cities per window, this observation included
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 for an invalid token,
+            404 if the IP cannot be located, 503 if the store or dataset is unavailable
+    """
+    timestamp = None
+    if observation.timestamp is not None:
+        when = observation.timestamp
+        if when.tzinfo is None:
+            when = when.replace(tzinfo=timezone.utc)
+        timestamp = when.timestamp()
+
+    try:
+        if observation.ip_address is not None:
+            if observation.country_code is not None or observation.city is not None:
+                raise ValueError("Pass either ip_address or country_code/city, not both")
+            located = lookup_ip_address(
+                observation.ip_address, x_api_key, overrides=tenant_overrides(tenant_id, session)
+            )
+            country, city = located.country_iso_code, located.city_name
+        elif observation.country_code is not None:
+            country, city = observation.country_code, observation.city
+        else:
+            raise ValueError("ip_address or country_code is required")
+
+        tracker = get_velocity_tracker()
+        if ErasureService(session).is_erased(tenant_id, observation.entity_id):
+            snapshot = tracker.counts(observation.entity_type, observation.entity_id, tenant_id=tenant_id)
+        else:
+            snapshot = tracker.observe(
+                observation.entity_type,
+                observation.entity_id,
+                country,
+                city,
+                timestamp=timestamp,
+                tenant_id=tenant_id,
+            )
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except LookupError as e:
+        raise _error(status.HTTP_404_NOT_FOUND, "E004", e)
+    except RuntimeError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+    return VelocityResponse(**snapshot.to_dict())
+
+
+@router.get(
+    "/velocity/{entity_type}/{entity_id}",
+    response_model=VelocityResponse,
+    responses=RESPONSES,
+)
+async def get_velocity(
+    entity_type: str,
+    entity_id: str,
+    tenant_id: Optional[str] = Depends(get_optional_tenant_id),
+):
+    """Distinct countries and cities an account or device appeared from per window.
+
+    Args:
+        entity_type: "account" or "device"
+        entity_id: Account or device identifier
+        tenant_id: Calling tenant, if a bearer token was sent
+
+    Returns:
+        VelocityResponse: Counts per window (zero for unknown entities)
+
+    Raises:
+        HTTPException: 400 for an unknown entity type, 401 for an invalid
+            token, 503 if the store is unavailable
+    """
+    try:
+        snapshot = get_velocity_tracker().counts(entity_type, entity_id, tenant_id=tenant_id)
+    except ValueError as e:
+        raise _error(status.HTTP_400_BAD_REQUEST, "E002", e)
+    except RuntimeError as e:
+        raise _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", e)
+    return VelocityResponse(**snapshot.to_dict())
@@ -0,0 +1,94 @@
+"""API versions and their deprecation policy.
+
+Every route lives under /api/<version>/. A version stays stable once
+released: breaking response-shape changes ship as routes of the next
+version, while the old route keeps answering until its sunset date. A
+deprecated version's responses carry the standard headers announcing it:
+
+    Deprecation: @1798761600                          (RFC 9745, when it was deprecated)
+    Sunset: Thu, 01 Jul 2027 00:00:00 GMT             (RFC 8594, when it may stop answering)
+    Link: </api/v2/lookup/ip/81.2.69.142>; rel="successor-version"
+"""
+
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from email.utils import format_datetime
+from typing import Dict, Optional
+
+from fastapi import APIRouter
+
+VERSIONS = ("v1", "v2")
+
+
+def parse_policy_date(value: str, name: str) -> Optional[datetime]:
+    """ISO 8601 date or instant from configuration (empty: None), in UTC.
+
+    Raises:
+        ValueError: If the value is not ISO 8601
+    """
+    value = (value or "").strip()
+    if not value:
+        return None
+    try:
+        parsed = datetime.fromisoformat(value.replace("Z", "+00:00"))
+    except ValueError:
+        raise ValueError(f"{name} must be an ISO 8601 date or instant, got {value!r}")
+    if parsed.tzinfo is None:
+        parsed = parsed.replace(tzinfo=timezone.utc)
+    return parsed.astimezone(timezone.utc)
+
+
+def versioned_router(version: str, **kwargs) -> APIRouter:
+    """Router of the routes of one API version (mounted under /api/<version>).
+
+    Raises:
+        ValueError: For a version the API does not serve
+    """
+    if version not in VERSIONS:
+        raise ValueError(f"Unknown API version: {version}")
+    return APIRouter(prefix=f"/api/{version}", **kwargs)
+
+
+@dataclass(frozen=True)
+class ApiVersion:
+    """One API version and its deprecation schedule."""
+    name: str                                # v1, v2, ...
+    deprecated_at: Optional[datetime] = None  # UTC; None while supported
+    sunset_at: Optional[datetime] = None      # UTC; when it may stop answering
+    successor: Optional[str] = None          # Version replacing it
+
+    @property
+    def prefix(self) -> str:
+        return f"/api/{self.name}"
+
+    def headers(self, successor_path: Optional[str] = None) -> Dict[str, str]:
+        """Deprecation headers of the version's responses (empty while supported).
+
+        Args:
+            successor_path: The same route in the successor version, if it exists
+        """
+        headers: Dict[str, str] = {}
+        if self.deprecated_at is not None:
+            headers["Deprecation"] = f"@{int(self.deprecated_at.timestamp())}"
+        if self.sunset_at is not None:
+            headers["Sunset"] = format_datetime(self.sunset_at, usegmt=True)
+        if headers and successor_path is not None:
+            headers["Link"] = f'<{successor_path}>; rel="successor-version"'
+        return headers
+
+
+def api_versions(config) -> Dict[str, ApiVersion]:
+    """Versions served by the API, keyed by name, with v1's schedule from configuration.
+
+    Raises:
+        ValueError: If API_V1_DEPRECATED_AT or API_V1_SUNSET_AT is malformed
+    """
+    return {
+        "v1": ApiVersion(
+            "v1",
+            deprecated_at=parse_policy_date(config.api_v1_deprecated_at, "API_V1_DEPRECATED_AT"),
+            sunset_at=parse_policy_date(config.api_v1_sunset_at, "API_V1_SUNSET_AT"),
+            successor="v2",
+        ),
+        "v2": ApiVersion("v2"),
+    }
@@ -0,0 +1,274 @@
+"""API routes for tenant webhooks and their delivery logs."""
+import asyncio
+import json
+from typing import Optional
+
+from fastapi import APIRouter, Depends, HTTPException, Query, Response, status
+from sqlalchemy.orm import Session
+from src.api.dependencies import get_tenant_id
+from src.api.poi_routes import AUTH_RESPONSES
+from src.database import get_db_session
+from src.models.database_models import Webhook, WebhookDelivery
+from src.models.schemas import (
+    ErrorResponse,
+    WebhookCreate,
+    WebhookDeliveryInfo,
+    WebhookDeliveryListResponse,
+    WebhookListResponse,
+    WebhookResponse,
+)
+from src.services.webhook_service import WebhookService, WebhookTarget, get_webhook_dispatcher
+
+router = APIRouter(prefix="/api/v1", tags=["webhooks"])
+
+
+def _to_response(webhook: Webhook, include_secret: bool = False) -> WebhookResponse:
+    """Convert a stored webhook to the API response model."""
+    return WebhookResponse(
+        webhook_id=webhook.webhook_id,
+        url=webhook.url,
+        events=webhook.events,
+        created_at=webhook.created_at,
+        secret=webhook.secret if include_secret else None,
+    )
+
+
+def _to_delivery_info(delivery: WebhookDelivery, include_payload: bool = False) -> WebhookDeliveryInfo:
+    """Convert a logged delivery to the API response model."""
+    return WebhookDeliveryInfo(
+        delivery_id=delivery.delivery_id,
+        webhook_id=delivery.webhook_id,
+        event=delivery.event,
+        status=delivery.status,
+        attempts=delivery.attempts,
+        last_status_code=delivery.last_status_code,
+        last_error=delivery.last_error,
+        created_at=delivery.created_at,
+        last_attempt_at=delivery.last_attempt_at,
+        delivered_at=delivery.delivered_at,
+        payload=json.loads(delivery.body) if include_payload else None,
+    )
+
+
+def _get_or_404(service: WebhookService, tenant_id: str, webhook_id: str) -> Webhook:
+    webhook = service.get_webhook(tenant_id, webhook_id)
+    if webhook is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"Webhook {webhook_id} not found",
+                "details": None,
+            },
+        )
+    return webhook
+
+
+def _get_delivery_or_404(
+    service: WebhookService, tenant_id: str, webhook_id: str, delivery_id: str
+) -> WebhookDelivery:
+    _get_or_404(service, tenant_id, webhook_id)
+    delivery = service.get_delivery(tenant_id, webhook_id, delivery_id)
+    if delivery is None:
+        raise HTTPException(
+            status_code=status.HTTP_404_NOT_FOUND,
+            detail={
+                "error_code": "E004",
+                "error_message": f"Delivery {delivery_id} not found",
+                "details": None,
+            },
+        )
+    return delivery
+
+
+@router.post(
+    "/webhooks",
+    response_model=WebhookResponse,
+    status_code=status.HTTP_201_CREATED,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid URL or events"},
+        **AUTH_RESPONSES,
+    },
+)
+async def create_webhook(
+    request: WebhookCreate,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Register an endpoint for the calling tenant's events.
+
+    Args:
+        request: URL, subscribed events and optional signing secret
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        WebhookResponse: Stored webhook with its signing secret (not
+            returned again)
+
+    Raises:
+        HTTPException: 400 for invalid input, 401 without a valid token
+    """
+    try:
+        webhook = WebhookService(session).create_webhook(
+            tenant_id, request.url, request.events, request.secret
+        )
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={"error_code": "E002", "error_message": str(e), "details": None},
+        )
+    return _to_response(webhook, include_secret=True)
+
+
+@router.get(
+    "/webhooks",
+    response_model=WebhookListResponse,
+    responses=AUTH_RESPONSES,
+)
+async def list_webhooks(
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """List the calling tenant's webhooks."""
+    return WebhookListResponse(
+        webhooks=[_to_response(w) for w in WebhookService(session).list_webhooks(tenant_id)]
+    )
+
+
+@router.get(
+    "/webhooks/{webhook_id}",
+    response_model=WebhookResponse,
+    responses={
+        404: {"model": ErrorResponse, "description": "Webhook not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def get_webhook(
+    webhook_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Fetch one of the calling tenant's webhooks."""
+    return _to_response(_get_or_404(WebhookService(session), tenant_id, webhook_id))
+
+
+@router.delete(
+    "/webhooks/{webhook_id}",
+    status_code=status.HTTP_204_NO_CONTENT,
+    responses={
+        404: {"model": ErrorResponse, "description": "Webhook not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def delete_webhook(
+    webhook_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Delete a webhook and its delivery log; pending deliveries to it still complete."""
+    service = WebhookService(session)
+    _get_or_404(service, tenant_id, webhook_id)
+    service.delete_webhook(tenant_id, webhook_id)
+    return Response(status_code=status.HTTP_204_NO_CONTENT)
+
+
+@router.get(
+    "/webhooks/{webhook_id}/deliveries",
+    response_model=WebhookDeliveryListResponse,
+    responses={
+        400: {"model": ErrorResponse, "description": "Invalid status or cursor"},
+        404: {"model": ErrorResponse, "description": "Webhook not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def list_webhook_deliveries(
+    webhook_id: str,
+    delivery_status: Optional[str] = Query(
+        None, alias="status", description="Only pending, delivered or failed deliveries"
+    ),
+    limit: int = Query(100, ge=1, le=1000, description="Most deliveries returned"),
+    cursor: Optional[str] = Query(None, description="next_cursor of the previous page"),
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Delivery log of one of the calling tenant's webhooks, newest first, a page at a time."""
+    service = WebhookService(session)
+    _get_or_404(service, tenant_id, webhook_id)
+    try:
+        page = service.delivery_page(tenant_id, webhook_id, delivery_status, limit, cursor)
+    except ValueError as e:
+        raise HTTPException(
+            status_code=status.HTTP_400_BAD_REQUEST,
+            detail={"error_code": "E002", "error_message": str(e), "details": None},
+        )
+    return WebhookDeliveryListResponse(
+        deliveries=[_to_delivery_info(d) for d in page.items],
+        next_cursor=page.next_cursor,
+    )
+
+
+@router.get(
+    "/webhooks/{webhook_id}/deliveries/{delivery_id}",
+    response_model=WebhookDeliveryInfo,
+    responses={
+        404: {"model": ErrorResponse, "description": "Webhook or delivery not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def get_webhook_delivery(
+    webhook_id: str,
+    delivery_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Fetch one logged delivery, with the JSON body that was sent."""
+    delivery = _get_delivery_or_404(WebhookService(session), tenant_id, webhook_id, delivery_id)
+    return _to_delivery_info(delivery, include_payload=True)
+
+
+@router.post(
+    "/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver",
+    response_model=WebhookDeliveryInfo,
+    status_code=status.HTTP_202_ACCEPTED,
+    responses={
+        404: {"model": ErrorResponse, "description": "Webhook or delivery not found"},
+        **AUTH_RESPONSES,
+    },
+)
+async def redeliver_webhook_delivery(
+    webhook_id: str,
+    delivery_id: str,
+    tenant_id: str = Depends(get_tenant_id),
+    session: Session = Depends(get_db_session),
+):
+    """Send a logged delivery again, in the background.
+
+    The stored body is resent unchanged, with the same X-Webhook-Delivery
+    ID, and signed with the webhook's current secret. The delivery goes
+    back to pending, gets the usual retries, and its attempts keep
+    counting.
+
+    Args:
+        webhook_id: Webhook of the calling tenant
+        delivery_id: Logged delivery to resend
+        tenant_id: Calling tenant (from the bearer token)
+        session: Database session (injected dependency)
+
+    Returns:
+        WebhookDeliveryInfo: The delivery, now pending
+
+    Raises:
+        HTTPException: 404 for an unknown webhook or delivery, 401 without
+            a valid token
+    """
+    service = WebhookService(session)
+    webhook = _get_or_404(service, tenant_id, webhook_id)
+    delivery = service.requeue_delivery(
+        _get_delivery_or_404(service, tenant_id, webhook_id, delivery_id)
+    )
+    target = WebhookTarget(webhook.webhook_id, webhook.url, webhook.secret, webhook.tenant_id)
+    asyncio.create_task(get_webhook_dispatcher().redeliver(
+        target, delivery.delivery_id, delivery.event, delivery.body
+    ))
+    return _to_delivery_info(delivery)
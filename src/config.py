@@ -1,12 +1,70 @@
 """Configuration management for Detection to COP service."""
+import json
+import logging
 import os
-from typing import List
+from typing import Dict, FrozenSet, List
+
+logger = logging.getLogger(__name__)
+
+
+def _parse_name_lists(raw: str, setting: str, what: str) -> Dict[str, FrozenSet[str]]:
+    """Parse a JSON object of name -> list of strings; see the callers."""
+    if not raw:
+        return {}
+    try:
+        entries = json.loads(raw)
+    except json.JSONDecodeError as e:
+        logger.error(f"Invalid {setting}: {str(e)}")
+        return {}
+    if not isinstance(entries, dict):
+        logger.error(f"Invalid {setting}: expected a JSON object")
+        return {}
+
+    parsed: Dict[str, FrozenSet[str]] = {}
+    for name, values in entries.items():
+        if not isinstance(values, list) or not all(isinstance(v, str) for v in values):
+            logger.error(f"Invalid {setting} entry: expected a list of {what}")
+            continue
+        parsed[name] = frozenset(values)
+    return parsed
+
+
+def parse_enrichment_profiles(raw: str) -> Dict[str, FrozenSet[str]]:
+    """Parse ENRICHMENT_API_KEY_PROFILES.
+
+    Args:
+        raw: JSON object mapping API key -> list of enrichment names
+
+    Returns:
+        dict of API key -> enrichment names. Malformed JSON yields no
+        profiles; entries whose value is not a list of strings are skipped.
+    """
+    return _parse_name_lists(raw, "ENRICHMENT_API_KEY_PROFILES", "enrichment names")
+
+
+def parse_group_roles(raw: str) -> Dict[str, FrozenSet[str]]:
+    """Parse OIDC_GROUP_ROLES.
+
+    Args:
+        raw: JSON object mapping IdP group -> list of roles
+
+    Returns:
+        dict of group -> roles. Malformed JSON maps no group (so nobody
+        gets a role); entries whose value is not a list of strings are skipped.
+    """
+    return _parse_name_lists(raw, "OIDC_GROUP_ROLES", "roles")
 
 
 class Config:
     """Application configuration."""
 
-    def __init__(self):
+    def __init__(self, load_secrets: bool = True):
+        """Read the configuration from the environment.
+
+        Args:
+            load_secrets: Overlay the settings held by SECRETS_BACKEND (see
+                src.services.secrets_service); False reads only the environment
+        """
         self.app_title: str = "Detection to COP"
         self.app_version: str = "0.1.0"
         self.cors_origins: List[str] = [
@@ -15,6 +73,36 @@ class Config:
             "http://127.0.0.1:3000",
         ]
         self.debug: bool = os.getenv("DEBUG", "False").lower() == "true"
+
+        # Secrets backend: "aws" (Secrets Manager) or "vault" (empty: environment only)
+        self.secrets_backend: str = os.getenv("SECRETS_BACKEND", "").strip().lower()
+        self.secrets_refresh_interval_seconds: float = float(
+            os.getenv("SECRETS_REFRESH_INTERVAL_SECONDS", "300")
+        )
+        self.secrets_aws_secret_id: str = os.getenv("SECRETS_AWS_SECRET_ID", "")
+        self.secrets_aws_region: str = os.getenv("SECRETS_AWS_REGION", "")
+        self.vault_addr: str = os.getenv("VAULT_ADDR", "")
+        self.vault_token: str = os.getenv("VAULT_TOKEN", "")
+        self.vault_token_file: str = os.getenv("VAULT_TOKEN_FILE", "")
+        self.vault_namespace: str = os.getenv("VAULT_NAMESPACE", "")
+        self.vault_kv_mount: str = os.getenv("VAULT_KV_MOUNT", "secret")
+        self.vault_secret_path: str = os.getenv("VAULT_SECRET_PATH", "")
+
+        self.database_url: str = os.getenv("DATABASE_URL", "sqlite:///./app/data/app.db")
+        # Credentials replacing those in DATABASE_URL (empty: as in the URL)
+        self.database_username: str = os.getenv("DATABASE_USERNAME", "")
+        self.database_password: str = os.getenv("DATABASE_PASSWORD", "")
+        # Connection pool per process (src.database): kept open, and opened beyond under bursts
+        self.database_pool_size: int = int(os.getenv("DATABASE_POOL_SIZE", "10"))
+        self.database_max_overflow: int = int(os.getenv("DATABASE_MAX_OVERFLOW", "10"))
+        # Longest a request waits for a connection before failing with 503
+        self.database_pool_timeout_seconds: float = float(os.getenv("DATABASE_POOL_TIMEOUT_SECONDS", "5"))
+        # Connections older than this are replaced (before server or proxy idle limits)
+        self.database_pool_recycle_seconds: int = int(os.getenv("DATABASE_POOL_RECYCLE_SECONDS", "1800"))
+        # Compiled SQL statements cached per engine
+        self.database_statement_cache_size: int = int(os.getenv("DATABASE_STATEMENT_CACHE_SIZE", "500"))
+        # PostgreSQL statement_timeout of every connection (0: none)
+        self.database_statement_timeout_ms: int = int(os.getenv("DATABASE_STATEMENT_TIMEOUT_MS", "5000"))
         self.tak_server_url: str = os.getenv(
             "TAK_SERVER_URL",
             "http://localhost:8080/CoT"
@@ -23,16 +111,203 @@ class Config:
             "JWT_SECRET_KEY",
             "your-secret-key-change-in-production"
         )
-        self.jwt_algorithm: str = "HS256"
-        self.jwt_expiration_minutes: int = 60
+        # HS256 signs with JWT_SECRET_KEY; RS256/ES256 use the key pair below
+        self.jwt_algorithm: str = os.getenv("JWT_ALGORITHM", "HS256")
+        # PEM keys, inline or as file paths (only issuing instances need the private key)
+        self.jwt_public_key: str = os.getenv("JWT_PUBLIC_KEY", "")
+        self.jwt_public_key_path: str = os.getenv("JWT_PUBLIC_KEY_PATH", "")
+        self.jwt_private_key: str = os.getenv("JWT_PRIVATE_KEY", "")
+        self.jwt_private_key_path: str = os.getenv("JWT_PRIVATE_KEY_PATH", "")
+        # Keys replaced by a rotation, still accepted for the tokens they signed
+        self.jwt_previous_secret_key: str = os.getenv("JWT_PREVIOUS_SECRET_KEY", "")
+        self.jwt_previous_public_key: str = os.getenv("JWT_PREVIOUS_PUBLIC_KEY", "")
+        # Required iss/aud claims (empty: not checked)
+        self.jwt_issuer: str = os.getenv("JWT_ISSUER", "")
+        self.jwt_audience: str = os.getenv("JWT_AUDIENCE", "")
+        # Token claim naming the tenant the caller acts for
+        self.jwt_tenant_claim: str = os.getenv("JWT_TENANT_CLAIM", "tenant_id")
+        # Lifetime of access tokens issued by /api/v1/auth/token and /auth/refresh
+        self.jwt_expiration_minutes: int = int(os.getenv("JWT_EXPIRATION_MINUTES", "60"))
+        # Lifetime of refresh tokens (each refresh issues a new one)
+        self.jwt_refresh_expiration_days: int = int(os.getenv("JWT_REFRESH_EXPIRATION_DAYS", "30"))
+        # Revoked token IDs (shared across workers only with Redis)
+        self.token_denylist_redis_url: str = os.getenv("TOKEN_DENYLIST_REDIS_URL", "")
+        self.token_denylist_key_prefix: str = os.getenv("TOKEN_DENYLIST_KEY_PREFIX", "token-denylist")
+        # Lockout of addresses failing authentication repeatedly (src.services.auth_throttle_service)
+        self.auth_throttle_enabled: bool = os.getenv("AUTH_THROTTLE_ENABLED", "true").lower() == "true"
+        self.auth_throttle_paths: str = os.getenv(
+            "AUTH_THROTTLE_PATHS", "/api/v1/auth/token,/api/v1/auth/refresh,/admin/"
+        )
+        self.auth_throttle_max_failures: int = int(os.getenv("AUTH_THROTTLE_MAX_FAILURES", "5"))
+        self.auth_throttle_window_seconds: int = int(os.getenv("AUTH_THROTTLE_WINDOW_SECONDS", "900"))
+        self.auth_throttle_lockout_seconds: int = int(os.getenv("AUTH_THROTTLE_LOCKOUT_SECONDS", "30"))
+        self.auth_throttle_max_lockout_seconds: int = int(
+            os.getenv("AUTH_THROTTLE_MAX_LOCKOUT_SECONDS", "3600")
+        )
+        self.auth_throttle_alert_failures: int = int(os.getenv("AUTH_THROTTLE_ALERT_FAILURES", "20"))
+        # Failure counters (shared across workers only with Redis)
+        self.auth_throttle_redis_url: str = os.getenv("AUTH_THROTTLE_REDIS_URL", "")
+        self.auth_throttle_key_prefix: str = os.getenv("AUTH_THROTTLE_KEY_PREFIX", "auth-throttle")
+        # Reject requests without a valid bearer token (outside AUTH_EXEMPT_PATHS)
+        self.auth_required: bool = os.getenv("AUTH_REQUIRED", "false").lower() == "true"
+        self.auth_exempt_paths: str = os.getenv(
+            "AUTH_EXEMPT_PATHS",
+            "/api/v1/health,/healthz,/readyz,/livez,/metrics,/docs,/redoc,/openapi.json,/api/v1/auth/",
+        )
+        # Signed requests of tenants with signing keys (see src.services.request_signing_service)
+        self.request_signing_tolerance_seconds: int = int(
+            os.getenv("REQUEST_SIGNING_TOLERANCE_SECONDS", "300")
+        )
+        # Nonces of accepted signatures (shared across workers only with Redis)
+        self.request_signing_redis_url: str = os.getenv("REQUEST_SIGNING_REDIS_URL", "")
+        # Per-endpoint scopes, hot-reloaded from this JSON file (see src.services.rbac_service)
+        self.rbac_policy_path: str = os.getenv("RBAC_POLICY_PATH", "")
+        self.rbac_reload_interval_seconds: float = float(
+            os.getenv("RBAC_RELOAD_INTERVAL_SECONDS", "5")
+        )
+
+        # TLS listener of `python -m src.server` (empty cert: plain HTTP)
+        self.tls_cert_file: str = os.getenv("TLS_CERT_FILE", "")
+        self.tls_key_file: str = os.getenv("TLS_KEY_FILE", "")
+        # CAs of internal callers' client certificates (empty: none requested)
+        self.tls_client_ca_file: str = os.getenv("TLS_CLIENT_CA_FILE", "")
+        # "optional" also serves bearer token callers; "required" refuses them at the handshake
+        self.tls_client_auth: str = os.getenv("TLS_CLIENT_AUTH", "optional").strip().lower()
+        # JSON object of certificate name (URI:spiffe://..., DNS:...) -> {"tenant_id", "scopes"}
+        self.mtls_identities: str = os.getenv("MTLS_IDENTITIES", "")
 
-        # Rate limiting
+        # Corporate IdP (OIDC) tokens for the admin API (empty issuer: disabled)
+        self.oidc_issuer_url: str = os.getenv("OIDC_ISSUER_URL", "").rstrip("/")
+        self.oidc_audience: str = os.getenv("OIDC_AUDIENCE", "")
+        # Claim listing the user's groups; dotted for nested claims (realm_access.roles)
+        self.oidc_groups_claim: str = os.getenv("OIDC_GROUPS_CLAIM", "groups")
+        # JSON object of group -> roles ("admin", "admin:read")
+        self.oidc_group_roles: Dict[str, FrozenSet[str]] = parse_group_roles(
+            os.getenv("OIDC_GROUP_ROLES", "")
+        )
+        # Tenant operators act for in the admin API
+        self.oidc_tenant_id: str = os.getenv("OIDC_TENANT_ID", "platform")
+        self.oidc_algorithms: List[str] = [
+            a.strip() for a in os.getenv("OIDC_ALGORITHMS", "RS256,ES256").split(",") if a.strip()
+        ]
+        # How long discovery and signing keys are cached (unknown key IDs refetch sooner)
+        self.oidc_jwks_cache_seconds: int = int(os.getenv("OIDC_JWKS_CACHE_SECONDS", "3600"))
+
+        # Admin API (/admin/v1; tokens need the "admin" scope)
+        self.admin_api_enabled: bool = os.getenv("ADMIN_API_ENABLED", "true").lower() == "true"
+        # Dataset uploads can be far larger than CSV uploads (UPLOAD_MAX_BYTES)
+        self.admin_dataset_max_bytes: int = int(
+            os.getenv("ADMIN_DATASET_MAX_BYTES", str(1024 * 1024 * 1024))
+        )
+        # How long a tenant's previous API keys keep working after a rotation
+        self.tenant_key_rotation_grace_seconds: int = int(
+            os.getenv("TENANT_KEY_ROTATION_GRACE_SECONDS", "86400")
+        )
+        # Age at which API keys are due for rotation; they keep working for
+        # the grace period after that (0: keys never expire)
+        self.tenant_key_max_age_days: float = float(os.getenv("TENANT_KEY_MAX_AGE_DAYS", "0"))
+        # Scopes of a new tenant's first API key when the request names none
+        self.tenant_key_default_scopes: List[str] = [
+            scope.strip()
+            for scope in os.getenv("TENANT_KEY_DEFAULT_SCOPES", "lookup:read,geofence:read").split(",")
+            if scope.strip()
+        ]
+        # Header carrying the client address behind a trusted proxy, for the
+        # allowed networks of API keys (rightmost entry used; empty: the peer)
+        self.api_key_client_ip_header: str = os.getenv("API_KEY_CLIENT_IP_HEADER", "")
+
+        # Profiling (/admin/v1/debug/profile/*; off unless enabled)
+        self.profiling_enabled: bool = os.getenv("PROFILING_ENABLED", "false").lower() == "true"
+        # Longest CPU or allocation capture an admin can ask for
+        self.profiling_max_seconds: float = float(os.getenv("PROFILING_MAX_SECONDS", "60"))
+        # Continuous profiling: Pyroscope server the agent pushes to (empty: off)
+        self.profiling_pyroscope_server_address: str = os.getenv(
+            "PROFILING_PYROSCOPE_SERVER_ADDRESS", ""
+        )
+        self.profiling_pyroscope_application_name: str = os.getenv(
+            "PROFILING_PYROSCOPE_APPLICATION_NAME", "geolocation-engine"
+        )
+        # Stack samples per second
+        self.profiling_pyroscope_sample_rate: int = int(
+            os.getenv("PROFILING_PYROSCOPE_SAMPLE_RATE", "100")
+        )
+        # Labels of the profiles, e.g. env=staging,region=eu-west-1
+        self.profiling_pyroscope_tags: Dict[str, str] = {
+            name.strip(): value.strip()
+            for name, _, value in (
+                tag.partition("=") for tag in os.getenv("PROFILING_PYROSCOPE_TAGS", "").split(",")
+            )
+            if name.strip() and value.strip()
+        }
+
+        # Rate limiting: token bucket per caller (src.services.rate_limit_service)
+        self.rate_limit_enabled: bool = os.getenv("RATE_LIMIT_ENABLED", "true").lower() == "true"
+        # token_bucket or sliding_window
+        self.rate_limit_algorithm: str = os.getenv("RATE_LIMIT_ALGORITHM", "token_bucket")
         self.rate_limit_capacity: int = int(
             os.getenv("RATE_LIMIT_CAPACITY", "100")
         )
         self.rate_limit_refill_rate: float = float(
             os.getenv("RATE_LIMIT_REFILL_RATE", "10.0")
         )
+        # sliding_window: RATE_LIMIT_CAPACITY requests in any window of this length
+        self.rate_limit_window_seconds: float = float(os.getenv("RATE_LIMIT_WINDOW_SECONDS", "10"))
+        self.rate_limit_exempt_paths: str = os.getenv(
+            "RATE_LIMIT_EXEMPT_PATHS", "/api/v1/health,/healthz,/readyz,/livez,/metrics"
+        )
+        # Spike arrest on top of the sustained limit: at most this many requests
+        # per caller in any spike window (0: off)
+        self.rate_limit_spike_limit: int = int(os.getenv("RATE_LIMIT_SPIKE_LIMIT", "0"))
+        self.rate_limit_spike_window_seconds: float = float(os.getenv("RATE_LIMIT_SPIKE_WINDOW_SECONDS", "1"))
+        # Buckets (shared across workers only with Redis)
+        self.rate_limit_redis_url: str = os.getenv("RATE_LIMIT_REDIS_URL", "")
+        self.rate_limit_key_prefix: str = os.getenv("RATE_LIMIT_KEY_PREFIX", "rate-limit")
+
+        # Monthly quotas of API keys on plans (src.services.quota_service)
+        self.quota_enabled: bool = os.getenv("QUOTA_ENABLED", "true").lower() == "true"
+        # JSON object of plans changing or adding to free/standard/enterprise
+        self.quota_plans: str = os.getenv("QUOTA_PLANS", "")
+        # Shares of a cap that warn and refuse (plans may set their own)
+        self.quota_warn_ratio: float = float(os.getenv("QUOTA_WARN_RATIO", "0.8"))
+        self.quota_block_ratio: float = float(os.getenv("QUOTA_BLOCK_RATIO", "1.0"))
+        # Counters (shared across workers only with Redis)
+        # Percentages of a cap whose crossing tenants are alerted of
+        self.quota_alert_thresholds: str = os.getenv("QUOTA_ALERT_THRESHOLDS", "80,95,100")
+        self.quota_redis_url: str = os.getenv("QUOTA_REDIS_URL", "")
+        self.quota_key_prefix: str = os.getenv("QUOTA_KEY_PREFIX", "quota")
+
+        # Outgoing email (quota alerts; empty host: no email)
+        self.smtp_host: str = os.getenv("SMTP_HOST", "")
+        self.smtp_port: int = int(os.getenv("SMTP_PORT", "587"))
+        self.smtp_username: str = os.getenv("SMTP_USERNAME", "")
+        self.smtp_password: str = os.getenv("SMTP_PASSWORD", "")
+        self.smtp_from: str = os.getenv("SMTP_FROM", "geolocation-engine@localhost")
+        self.smtp_starttls: bool = os.getenv("SMTP_STARTTLS", "true").lower() == "true"
+        self.smtp_timeout_seconds: float = float(os.getenv("SMTP_TIMEOUT_SECONDS", "10"))
+
+        # Concurrency limiting and load shedding (src.services.concurrency_service)
+        self.concurrency_limit_enabled: bool = os.getenv("CONCURRENCY_LIMIT_ENABLED", "true").lower() == "true"
+        # Requests served at a time by each process; more get 503
+        self.concurrency_max_in_flight: int = int(os.getenv("CONCURRENCY_MAX_IN_FLIGHT", "256"))
+        # JSON object of endpoint (method and route template) -> requests at a time
+        self.concurrency_endpoint_limits: str = os.getenv("CONCURRENCY_ENDPOINT_LIMITS", "")
+        # JSON object of quota plan -> share of the slots its keys may fill
+        # near saturation (changes or adds to enterprise 1.0, standard 0.9, free 0.6)
+        self.concurrency_priority_shares: str = os.getenv("CONCURRENCY_PRIORITY_SHARES", "")
+        # Share of callers without a plan (other tokens, anonymous requests)
+        self.concurrency_default_share: float = float(os.getenv("CONCURRENCY_DEFAULT_SHARE", "0.8"))
+        self.concurrency_retry_after_seconds: int = int(os.getenv("CONCURRENCY_RETRY_AFTER_SECONDS", "1"))
+        self.concurrency_exempt_paths: str = os.getenv(
+            "CONCURRENCY_EXEMPT_PATHS", "/api/v1/health,/healthz,/readyz,/livez,/metrics"
+        )
+
+        # Degraded IP lookups (src.services.degraded_service)
+        self.degraded_mode_enabled: bool = os.getenv("DEGRADED_MODE_ENABLED", "false").lower() == "true"
+        # Recent answers kept per process to fall back on
+        self.degraded_cache_max_entries: int = int(os.getenv("DEGRADED_CACHE_MAX_ENTRIES", "10000"))
+        self.degraded_stale_max_age_seconds: float = float(os.getenv("DEGRADED_STALE_MAX_AGE_SECONDS", "86400"))
+        # Share of CONCURRENCY_MAX_IN_FLIGHT from which plain lookups are answered from recent answers
+        self.degraded_overload_ratio: float = float(os.getenv("DEGRADED_OVERLOAD_RATIO", "0.9"))
 
         # Cache
         self.cache_ttl_seconds: float = float(
@@ -41,12 +316,436 @@ class Config:
         self.cache_max_entries: int = int(
             os.getenv("CACHE_MAX_ENTRIES", "1000")
         )
+        # Read-through cache of lookup results (src.services.lookup_cache_service;
+        # CACHE_TTL_SECONDS is the TTL of ip lookups, CACHE_MAX_ENTRIES the size in process memory)
+        self.lookup_cache_enabled: bool = os.getenv("LOOKUP_CACHE_ENABLED", "false").lower() == "true"
+        self.lookup_cache_redis_url: str = os.getenv("LOOKUP_CACHE_REDIS_URL", "")
+        self.lookup_cache_key_prefix: str = os.getenv("LOOKUP_CACHE_KEY_PREFIX", "lookup")
+        # Longest time Redis entries are also kept in process memory (L1)
+        self.lookup_cache_l1_ttl_seconds: int = int(os.getenv("LOOKUP_CACHE_L1_TTL_SECONDS", "10"))
+        # Warming at startup from the hottest lookups (src.services.cache_warming_service)
+        self.lookup_cache_warm_enabled: bool = os.getenv("LOOKUP_CACHE_WARM_ENABLED", "false").lower() == "true"
+        self.lookup_cache_warm_top_n: int = int(os.getenv("LOOKUP_CACHE_WARM_TOP_N", "1000"))
+        self.lookup_cache_warm_persist_interval_seconds: int = int(
+            os.getenv("LOOKUP_CACHE_WARM_PERSIST_INTERVAL_SECONDS", "300")
+        )
+        # Longest warming holds back readiness
+        self.lookup_cache_warm_timeout_seconds: float = float(os.getenv("LOOKUP_CACHE_WARM_TIMEOUT_SECONDS", "60"))
+        # Hot lookups persisted here without LOOKUP_CACHE_REDIS_URL
+        self.lookup_cache_hot_keys_path: str = os.getenv("LOOKUP_CACHE_HOT_KEYS_PATH", "")
+        # JSON object of lookup type (ip, ip_as_of, reverse_geocode) -> seconds (0: not cached)
+        self.lookup_cache_ttls: str = os.getenv("LOOKUP_CACHE_TTLS", "")
+        # Seconds not-found answers are cached (at most the type's TTL; 0: not cached)
+        self.lookup_cache_negative_ttl_seconds: int = int(os.getenv("LOOKUP_CACHE_NEGATIVE_TTL_SECONDS", "60"))
+
+        # GeoIP dataset
+        self.geoip_database_path: str = os.getenv(
+            "GEOIP_DATABASE_PATH", "./data/GeoLite2-City.mmdb"
+        )
+        self.geoip_use_mmap: bool = os.getenv(
+            "GEOIP_USE_MMAP", "True"
+        ).lower() == "true"
+        # Dataset age (days) at which lookup confidence halves; 0 disables aging
+        self.geoip_confidence_half_life_days: float = float(
+            os.getenv("GEOIP_CONFIDENCE_HALF_LIFE_DAYS", "365")
+        )
+
+        # Lookup enrichment
+        self.geoip_asn_database_path: str = os.getenv(
+            "GEOIP_ASN_DATABASE_PATH", "./data/GeoLite2-ASN.mmdb"
+        )
+        self.geoip_connection_type_database_path: str = os.getenv(
+            "GEOIP_CONNECTION_TYPE_DATABASE_PATH", ""
+        )
+        self.geoip_anonymous_ip_database_path: str = os.getenv(
+            "GEOIP_ANONYMOUS_IP_DATABASE_PATH", ""
+        )
+        self.anonymizer_vpn_ranges_path: str = os.getenv(
+            "ANONYMIZER_VPN_RANGES_PATH", ""
+        )
+        self.anonymizer_proxy_ranges_path: str = os.getenv(
+            "ANONYMIZER_PROXY_RANGES_PATH", ""
+        )
+        self.anonymizer_tor_exit_list_path: str = os.getenv(
+            "ANONYMIZER_TOR_EXIT_LIST_PATH", ""
+        )
+        self.anonymizer_residential_proxy_ranges_path: str = os.getenv(
+            "ANONYMIZER_RESIDENTIAL_PROXY_RANGES_PATH", ""
+        )
+        # Published cloud ranges (comma-separated AWS/GCP/Azure JSON or CIDR files)
+        self.datacenter_range_files: str = os.getenv(
+            "DATACENTER_RANGE_FILES", ""
+        )
+        # ISO 3166-2 subdivisions (CSV: code,name,type,parent)
+        self.subdivision_data_path: str = os.getenv(
+            "SUBDIVISION_DATA_PATH", "./data/iso3166-2.csv"
+        )
+        # Mobile carriers (CSV: mcc,mnc,name,country and network,mcc,mnc)
+        self.carrier_mcc_mnc_path: str = os.getenv(
+            "CARRIER_MCC_MNC_PATH", "./data/mcc-mnc.csv"
+        )
+        self.carrier_ip_ranges_path: str = os.getenv(
+            "CARRIER_IP_RANGES_PATH", ""
+        )
+        # Country languages and currencies (GeoNames countryInfo.txt)
+        self.country_info_path: str = os.getenv(
+            "COUNTRY_INFO_PATH", "./data/countryInfo.txt"
+        )
+        # Named country groups (JSON object of name -> country codes or group names)
+        self.region_groups_path: str = os.getenv(
+            "REGION_GROUPS_PATH", ""
+        )
+        self.enrichment_defaults: str = os.getenv(
+            "ENRICHMENT_DEFAULTS", "asn,anonymizer,datacenter,hierarchy,carrier,locale,currency,groups,risk"
+        )
+        # JSON object mapping API key -> list of enrichment names
+        self.enrichment_api_key_profiles: str = os.getenv(
+            "ENRICHMENT_API_KEY_PROFILES", ""
+        )
+        self.enrichment_profiles: Dict[str, FrozenSet[str]] = (
+            parse_enrichment_profiles(self.enrichment_api_key_profiles)
+        )
+
+        # Bulk lookups (/api/v1/lookup/batch)
+        self.batch_lookup_max_items: int = int(
+            os.getenv("BATCH_LOOKUP_MAX_ITEMS", "10000")
+        )
+        self.batch_lookup_workers: int = int(
+            os.getenv("BATCH_LOOKUP_WORKERS", "8")
+        )
+        # Workers one batch uses at most, so concurrent batches share the pool
+        self.batch_lookup_workers_per_batch: int = int(
+            os.getenv("BATCH_LOOKUP_WORKERS_PER_BATCH", "4")
+        )
+        # Items waiting or running beyond which new batches get 503 (0: unbounded)
+        self.batch_lookup_max_pending_items: int = int(
+            os.getenv("BATCH_LOOKUP_MAX_PENDING_ITEMS", "50000")
+        )
+
+        # Asynchronous batch jobs (/api/v1/jobs)
+        self.batch_job_result_dir: str = os.getenv("BATCH_JOB_RESULT_DIR", "./data/jobs")
+        self.batch_job_input_dir: str = os.getenv("BATCH_JOB_INPUT_DIR", "")
+        self.batch_job_chunk_size: int = int(
+            os.getenv("BATCH_JOB_CHUNK_SIZE", "1000")
+        )
+        self.batch_job_concurrency: int = int(
+            os.getenv("BATCH_JOB_CONCURRENCY", "2")
+        )
+        # Batch lookup workers one job's chunks use at most
+        self.batch_job_workers: int = int(
+            os.getenv("BATCH_JOB_WORKERS", "2")
+        )
+
+        # Right-to-erasure jobs (/api/v1/erasures)
+        self.erasure_batch_size: int = int(
+            os.getenv("ERASURE_BATCH_SIZE", "500")
+        )
+
+        # Usage analytics of API keys (/api/v1/usage)
+        self.usage_analytics_enabled: bool = os.getenv("USAGE_ANALYTICS_ENABLED", "true").lower() == "true"
+        self.usage_flush_interval_seconds: int = int(os.getenv("USAGE_FLUSH_INTERVAL_SECONDS", "60"))
+        self.usage_max_days: int = int(os.getenv("USAGE_MAX_DAYS", "366"))
+
+        # Billing metering and export (src.services.billing_service)
+        self.billing_enabled: bool = os.getenv("BILLING_ENABLED", "false").lower() == "true"
+        self.billing_flush_interval_seconds: int = int(os.getenv("BILLING_FLUSH_INTERVAL_SECONDS", "60"))
+        self.billing_exempt_paths: str = os.getenv(
+            "BILLING_EXEMPT_PATHS", "/admin/,/api/v1/auth/,/api/v1/usage,/api/v1/health,/metrics"
+        )
+        # s3://bucket/prefix or a directory (empty: no export files)
+        self.billing_export_url: str = os.getenv("BILLING_EXPORT_URL", "")
+        self.billing_export_interval_seconds: int = int(os.getenv("BILLING_EXPORT_INTERVAL_SECONDS", "3600"))
+        # Hours are exported this long after they end, once every worker has flushed them
+        self.billing_export_delay_seconds: int = int(os.getenv("BILLING_EXPORT_DELAY_SECONDS", "300"))
+        # Stripe metered billing (empty key: not reported)
+        self.billing_stripe_api_key: str = os.getenv("BILLING_STRIPE_API_KEY", "")
+        self.billing_stripe_event_name: str = os.getenv("BILLING_STRIPE_EVENT_NAME", "geolocation_requests")
+        # JSON object of tenant ID -> Stripe customer ID
+        self.billing_stripe_customers: str = os.getenv("BILLING_STRIPE_CUSTOMERS", "")
+
+        # Multipart CSV uploads (/jobs/upload and the geofence and POI imports)
+        self.upload_max_bytes: int = int(os.getenv("UPLOAD_MAX_BYTES", str(100 * 1024 * 1024)))
+
+        # gRPC API (0 disables; needs the grpc extra)
+        self.grpc_host: str = os.getenv("GRPC_HOST", "[::]")
+        self.grpc_port: int = int(os.getenv("GRPC_PORT", "0"))
+        # PEM certificate chain and key of the gRPC listener (empty cert: plaintext)
+        self.grpc_tls_cert_file: str = os.getenv("GRPC_TLS_CERT_FILE", "")
+        self.grpc_tls_key_file: str = os.getenv("GRPC_TLS_KEY_FILE", "")
+
+        # Reverse geocoding boundaries (<level>.geojson files)
+        self.boundary_data_dir: str = os.getenv(
+            "BOUNDARY_DATA_DIR", "./data/boundaries"
+        )
+
+        # IANA timezone boundaries (GeoJSON with a tzid property)
+        self.timezone_boundary_path: str = os.getenv(
+            "TIMEZONE_BOUNDARY_PATH", "./data/timezones.geojson"
+        )
+
+        # Marine Regions maritime boundaries (World EEZ and 12 NM layers, GeoJSON)
+        self.maritime_eez_path: str = os.getenv(
+            "MARITIME_EEZ_PATH", "./data/eez.geojson"
+        )
+        self.maritime_territorial_path: str = os.getenv(
+            "MARITIME_TERRITORIAL_PATH", "./data/territorial_seas.geojson"
+        )
+
+        # SRTM elevation tiles (.hgt / .hgt.zip, read on first use)
+        self.elevation_tile_dir: str = os.getenv(
+            "ELEVATION_TILE_DIR", "./data/srtm"
+        )
+        self.elevation_tile_cache_size: int = int(
+            os.getenv("ELEVATION_TILE_CACHE_SIZE", "8")
+        )
+
+        # Geofence S2 coverings (computed at creation)
+        self.geofence_s2_max_cells: int = int(
+            os.getenv("GEOFENCE_S2_MAX_CELLS", "128")
+        )
+        self.geofence_s2_max_level: int = int(
+            os.getenv("GEOFENCE_S2_MAX_LEVEL", "20")
+        )
+        self.geofence_cache_size: int = int(
+            os.getenv("GEOFENCE_CACHE_SIZE", "1024")
+        )
+
+        # Geofence enter/exit/dwell alerts
+        self.geofence_alert_debounce_seconds: float = float(
+            os.getenv("GEOFENCE_ALERT_DEBOUNCE_SECONDS", "0")
+        )
+        self.geofence_alert_dwell_seconds: float = float(
+            os.getenv("GEOFENCE_ALERT_DWELL_SECONDS", "0")
+        )
+
+        # Webhook delivery
+        self.webhook_timeout_seconds: float = float(
+            os.getenv("WEBHOOK_TIMEOUT_SECONDS", "5")
+        )
+        self.webhook_max_attempts: int = int(os.getenv("WEBHOOK_MAX_ATTEMPTS", "3"))
+        self.webhook_retry_backoff_seconds: float = float(
+            os.getenv("WEBHOOK_RETRY_BACKOFF_SECONDS", "1")
+        )
+
+        # Live event streaming (WebSocket and SSE)
+        self.live_event_queue_size: int = int(
+            os.getenv("LIVE_EVENT_QUEUE_SIZE", "100")
+        )
+        self.live_event_keepalive_seconds: float = float(
+            os.getenv("LIVE_EVENT_KEEPALIVE_SECONDS", "15")
+        )
+
+        # Detection heatmaps
+        self.heatmap_max_detections: int = int(
+            os.getenv("HEATMAP_MAX_DETECTIONS", "100000")
+        )
+
+        # Tenant POI datasets (nearest-neighbor search)
+        self.poi_max_dataset_size: int = int(
+            os.getenv("POI_MAX_DATASET_SIZE", "100000")
+        )
+        self.poi_max_results: int = int(os.getenv("POI_MAX_RESULTS", "100"))
+        self.poi_index_cache_size: int = int(
+            os.getenv("POI_INDEX_CACHE_SIZE", "64")
+        )
+
+        # Tenant IP range overrides (checked before the GeoIP dataset)
+        self.ip_override_max_per_tenant: int = int(
+            os.getenv("IP_OVERRIDE_MAX_PER_TENANT", "10000")
+        )
+        self.ip_override_cache_size: int = int(
+            os.getenv("IP_OVERRIDE_CACHE_SIZE", "256")
+        )
+
+        # WiFi BSSID positioning
+        self.wifi_min_access_points: int = int(
+            os.getenv("WIFI_MIN_ACCESS_POINTS", "2")
+        )
+        self.wifi_max_observations: int = int(
+            os.getenv("WIFI_MAX_OBSERVATIONS", "100")
+        )
+        self.wifi_reference_rssi_dbm: float = float(
+            os.getenv("WIFI_REFERENCE_RSSI_DBM", "-40")
+        )
+        self.wifi_path_loss_exponent: float = float(
+            os.getenv("WIFI_PATH_LOSS_EXPONENT", "3.0")
+        )
+        self.wifi_max_ap_spread_m: float = float(
+            os.getenv("WIFI_MAX_AP_SPREAD_M", "500")
+        )
+
+        # Cell tower positioning (OpenCelliD)
+        self.cell_min_towers: int = int(os.getenv("CELL_MIN_TOWERS", "1"))
+        self.cell_max_observations: int = int(
+            os.getenv("CELL_MAX_OBSERVATIONS", "32")
+        )
+        self.cell_max_tower_spread_m: float = float(
+            os.getenv("CELL_MAX_TOWER_SPREAD_M", "20000")
+        )
+
+        # Impossible travel detection (consecutive logins per user)
+        self.travel_max_speed_kmh: float = float(
+            os.getenv("TRAVEL_MAX_SPEED_KMH", "1000")
+        )
+        self.travel_min_distance_km: float = float(
+            os.getenv("TRAVEL_MIN_DISTANCE_KM", "100")
+        )
+
+        # Account sharing detection (concurrent sessions; distances use the travel thresholds)
+        self.account_sharing_session_ttl_seconds: float = float(
+            os.getenv("ACCOUNT_SHARING_SESSION_TTL_SECONDS", "1800")
+        )
+
+        # GPS vs IP location mismatch (distance beyond both accuracy radii)
+        self.location_mismatch_threshold_km: float = float(
+            os.getenv("LOCATION_MISMATCH_THRESHOLD_KM", "500")
+        )
+
+        # Location anomaly detection (per-user centroid + MAD of login distances)
+        self.anomaly_threshold: float = float(os.getenv("ANOMALY_THRESHOLD", "3.5"))
+        self.anomaly_min_samples: int = int(os.getenv("ANOMALY_MIN_SAMPLES", "5"))
+        self.anomaly_min_mad_km: float = float(os.getenv("ANOMALY_MIN_MAD_KM", "25"))
+        self.anomaly_history_limit: int = int(os.getenv("ANOMALY_HISTORY_LIMIT", "500"))
+        self.anomaly_profile_max_age_hours: float = float(
+            os.getenv("ANOMALY_PROFILE_MAX_AGE_HOURS", "24")
+        )
+
+        # Velocity counters (distinct countries/cities per account or device)
+        self.velocity_windows: str = os.getenv("VELOCITY_WINDOWS", "15m,1h,24h")
+        self.velocity_redis_url: str = os.getenv("VELOCITY_REDIS_URL", "")
+        self.velocity_key_prefix: str = os.getenv("VELOCITY_KEY_PREFIX", "velocity")
+
+        # Idempotency keys of create requests (Redis shares them between workers)
+        self.idempotency_redis_url: str = os.getenv("IDEMPOTENCY_REDIS_URL", "")
+        self.idempotency_key_prefix: str = os.getenv("IDEMPOTENCY_KEY_PREFIX", "idempotency")
+        self.idempotency_ttl_seconds: int = int(os.getenv("IDEMPOTENCY_TTL_SECONDS", "86400"))
+
+        # Residential proxy detection (distinct access-network ASNs per session)
+        self.residential_proxy_window: str = os.getenv("RESIDENTIAL_PROXY_WINDOW", "1h")
+        self.residential_proxy_max_session_asns: int = int(
+            os.getenv("RESIDENTIAL_PROXY_MAX_SESSION_ASNS", "2")
+        )
+
+        # Risk scoring (signal:weight pairs; unset signals keep their defaults)
+        self.risk_weights: str = os.getenv("RISK_WEIGHTS", "")
+        self.risk_high_risk_countries: str = os.getenv("RISK_HIGH_RISK_COUNTRIES", "")
+        # Tenants whose high-risk lists are kept compiled in memory
+        self.risk_area_cache_size: int = int(os.getenv("RISK_AREA_CACHE_SIZE", "256"))
+        self.risk_velocity_max_countries: int = int(
+            os.getenv("RISK_VELOCITY_MAX_COUNTRIES", "2")
+        )
+
+        # Detection rules (JSON file of DSL expressions, re-read when it changes)
+        self.rules_path: str = os.getenv("RULES_PATH", "")
+        self.rules_reload_interval_seconds: float = float(
+            os.getenv("RULES_RELOAD_INTERVAL_SECONDS", "5")
+        )
+
+        # Sanctioned-country enforcement (off, tag or block; ISO 3166-1/3166-2 codes)
+        self.sanctions_mode: str = os.getenv("SANCTIONS_MODE", "off")
+        self.sanctions_countries: str = os.getenv(
+            "SANCTIONS_COUNTRIES", "CU,IR,KP,SY,UA-43,UA-40,UA-14,UA-09"
+        )
+        # Header carrying the client address behind a trusted proxy (rightmost entry used)
+        self.sanctions_client_ip_header: str = os.getenv("SANCTIONS_CLIENT_IP_HEADER", "")
+        self.sanctions_exempt_paths: str = os.getenv(
+            "SANCTIONS_EXEMPT_PATHS",
+            "/api/v1/health,/healthz,/readyz,/livez,/metrics,/docs,/openapi.json",
+        )
+
+        # Health probes (/healthz, /readyz, /livez)
+        # Dataset age (days) beyond which /healthz reports degraded; 0 disables
+        self.health_dataset_max_age_days: float = float(
+            os.getenv("HEALTH_DATASET_MAX_AGE_DAYS", "30")
+        )
+        self.health_check_timeout_seconds: float = float(
+            os.getenv("HEALTH_CHECK_TIMEOUT_SECONDS", "2")
+        )
+
+        # Response compression (encodings in preference order; empty disables)
+        self.compression_encodings: str = os.getenv("COMPRESSION_ENCODINGS", "br,gzip")
+        self.compression_min_size: int = int(os.getenv("COMPRESSION_MIN_SIZE", "1024"))
+        self.compression_level: int = int(os.getenv("COMPRESSION_LEVEL", "6"))
+        self.compression_exempt_paths: str = os.getenv("COMPRESSION_EXEMPT_PATHS", "")
+
+        # API version deprecation schedule (ISO 8601 dates; empty: v1 supported)
+        self.api_v1_deprecated_at: str = os.getenv("API_V1_DEPRECATED_AT", "")
+        self.api_v1_sunset_at: str = os.getenv("API_V1_SUNSET_AT", "")
+
+        # Forward geocoding providers (tried in order)
+        self.geocoding_providers: str = os.getenv(
+            "GEOCODING_PROVIDERS", "nominatim"
+        )
+        self.nominatim_url: str = os.getenv(
+            "NOMINATIM_URL", "https://nominatim.openstreetmap.org"
+        )
+        self.nominatim_user_agent: str = os.getenv(
+            "NOMINATIM_USER_AGENT", "geolocation-engine"
+        )
+        self.nominatim_email: str = os.getenv("NOMINATIM_EMAIL", "")
+        self.google_geocoding_api_key: str = os.getenv(
+            "GOOGLE_GEOCODING_API_KEY", ""
+        )
+        # provider:limit pairs, e.g. "nominatim:10000,google:2500" (UTC days)
+        self.geocoding_daily_budgets: str = os.getenv(
+            "GEOCODING_DAILY_BUDGETS", ""
+        )
+        self.geocoding_circuit_failure_threshold: int = int(
+            os.getenv("GEOCODING_CIRCUIT_FAILURE_THRESHOLD", "5")
+        )
+        self.geocoding_circuit_cooldown_seconds: float = float(
+            os.getenv("GEOCODING_CIRCUIT_COOLDOWN_SECONDS", "60")
+        )
+
+        # GeoIP dataset updates
+        self.geoip_update_enabled: bool = os.getenv(
+            "GEOIP_UPDATE_ENABLED", "False"
+        ).lower() == "true"
+        self.geoip_update_source: str = os.getenv(
+            "GEOIP_UPDATE_SOURCE", "maxmind"
+        ).lower()
+        self.geoip_update_interval_seconds: int = int(
+            os.getenv("GEOIP_UPDATE_INTERVAL_SECONDS", "86400")
+        )
+        self.geoip_edition_id: str = os.getenv(
+            "GEOIP_EDITION_ID", "GeoLite2-City"
+        )
+        self.maxmind_account_id: str = os.getenv("MAXMIND_ACCOUNT_ID", "")
+        self.maxmind_license_key: str = os.getenv("MAXMIND_LICENSE_KEY", "")
+        self.geoip_s3_bucket: str = os.getenv("GEOIP_S3_BUCKET", "")
+        self.geoip_s3_key: str = os.getenv("GEOIP_S3_KEY", "")
+        # Replaced builds kept for ?as_of= lookups (empty disables history)
+        self.geoip_snapshot_dir: str = os.getenv("GEOIP_SNAPSHOT_DIR", "")
+        self.geoip_snapshot_retention_days: float = float(
+            os.getenv("GEOIP_SNAPSHOT_RETENTION_DAYS", "365")
+        )
+
+        # Location history encryption (see src.services.location_encryption_service)
+        # KMS key wrapping tenant data keys; takes precedence over a local key
+        self.location_encryption_kms_key_id: str = os.getenv(
+            "LOCATION_ENCRYPTION_KMS_KEY_ID", ""
+        )
+        self.location_encryption_kms_region: str = os.getenv(
+            "LOCATION_ENCRYPTION_KMS_REGION", ""
+        )
+        # Base64-encoded 256-bit keys (empty: history stored in plaintext)
+        self.location_encryption_master_key: str = os.getenv(
+            "LOCATION_ENCRYPTION_MASTER_KEY", ""
+        )
+        self.location_encryption_previous_master_key: str = os.getenv(
+            "LOCATION_ENCRYPTION_PREVIOUS_MASTER_KEY", ""
+        )
 
         # Security
         self.enforce_https: bool = os.getenv(
             "ENFORCE_HTTPS", "False"
         ).lower() == "true"
 
+        if load_secrets and self.secrets_backend:
+            from src.services.secrets_service import apply_secrets
+
+            apply_secrets(self)
+
 
 def get_config() -> Config:
     """Get application configuration.
@@ -1,15 +1,71 @@
-"""Database connection and session management."""
-import os
+"""Database connection and session management.
+
+Connections come from a pool per process, sized by DATABASE_POOL_SIZE
+plus DATABASE_MAX_OVERFLOW beyond it under bursts. A request waits at
+most DATABASE_POOL_TIMEOUT_SECONDS for a connection, then fails (503)
+rather than queueing without bound; connections are checked with a ping
+before use and replaced after DATABASE_POOL_RECYCLE_SECONDS. On
+PostgreSQL each statement is cancelled after DATABASE_STATEMENT_TIMEOUT_MS.
+Compiled statements are cached (DATABASE_STATEMENT_CACHE_SIZE).
+
+`db_pool_connections` (by state), `db_pool_checkout_seconds` and
+`db_pool_checkout_timeouts_total` show how close the pool runs to
+exhaustion, and readiness fails while every connection is in use, so the
+load balancer routes new requests to other workers.
+"""
 from contextlib import asynccontextmanager, contextmanager
+import time
 from sqlalchemy import create_engine, event, inspect
+from sqlalchemy.engine import make_url
 from sqlalchemy.orm import sessionmaker, Session
 from sqlalchemy.pool import QueuePool
-from sqlalchemy.exc import SQLAlchemyError
+from sqlalchemy.exc import SQLAlchemyError, TimeoutError as PoolTimeoutError
 import logging
 
+from src.metrics import publish_db_pool
+from src.services.secrets_service import secrets_generation
+
 logger = logging.getLogger(__name__)
 
 
+def configured_database_url(config=None) -> str:
+    """DATABASE_URL with DATABASE_USERNAME and DATABASE_PASSWORD applied.
+
+    Args:
+        config: Application configuration (default: current)
+    """
+    if config is None:
+        from src.config import get_config
+
+        config = get_config()
+    url = make_url(config.database_url)
+    if config.database_username:
+        url = url.set(username=config.database_username)
+    if config.database_password:
+        url = url.set(password=config.database_password)
+    return url.render_as_string(hide_password=False)
+
+
+def _display_url(database_url: str) -> str:
+    return make_url(database_url).render_as_string(hide_password=True)
+
+
+class InstrumentedQueuePool(QueuePool):
+    """QueuePool measuring how long checkouts wait, and counting those that time out."""
+
+    def _do_get(self):
+        from src.metrics import DB_POOL_CHECKOUT_SECONDS, DB_POOL_CHECKOUT_TIMEOUTS
+
+        started = time.perf_counter()
+        try:
+            return super()._do_get()
+        except PoolTimeoutError:
+            DB_POOL_CHECKOUT_TIMEOUTS.inc()
+            raise
+        finally:
+            DB_POOL_CHECKOUT_SECONDS.observe(time.perf_counter() - started)
+
+
 class DatabaseManager:
     """Manages database connections and sessions."""
 
@@ -17,53 +73,75 @@ class DatabaseManager:
         """Initialize database manager.
 
         Args:
-            database_url: SQLite database URL. If None, uses environment variable or default.
+            database_url: SQLite database URL. If None, uses the configured
+                one (see configured_database_url).
         """
-        self.database_url = (
-            database_url
-            or os.getenv("DATABASE_URL", "sqlite:///./app/data/app.db")
-        )
+        self.database_url = database_url or configured_database_url()
         self.engine = None
         self.SessionLocal = None
         self._initialize_engine()
 
     def _initialize_engine(self):
         """Initialize SQLAlchemy engine with connection pooling."""
-        # SQLite-specific configuration
-        if self.database_url.startswith("sqlite"):
-            self.engine = create_engine(
-                self.database_url,
-                connect_args={"check_same_thread": False},
-                poolclass=QueuePool,
-                pool_size=5,
-                max_overflow=5,
-                pool_pre_ping=True,
-                echo=False,
-            )
-        else:
-            # PostgreSQL or other databases
-            self.engine = create_engine(
-                self.database_url,
-                poolclass=QueuePool,
-                pool_size=5,
-                max_overflow=5,
-                pool_pre_ping=True,
-                echo=False,
-            )
+        from src.config import get_config
+
+        config = get_config()
+        sqlite = self.database_url.startswith("sqlite")
+        self.max_overflow = config.database_max_overflow
+        self.engine = create_engine(
+            self.database_url,
+            connect_args={"check_same_thread": False} if sqlite else {},
+            poolclass=InstrumentedQueuePool,
+            pool_size=config.database_pool_size,
+            max_overflow=config.database_max_overflow,
+            pool_timeout=config.database_pool_timeout_seconds,
+            pool_recycle=config.database_pool_recycle_seconds,
+            pool_pre_ping=True,
+            query_cache_size=config.database_statement_cache_size,
+            echo=False,
+        )
 
         # Register SQLite-specific event listeners
-        if self.database_url.startswith("sqlite"):
+        if sqlite:
             @event.listens_for(self.engine, "connect")
             def set_sqlite_pragma(dbapi_conn, connection_record):
                 cursor = dbapi_conn.cursor()
                 cursor.execute("PRAGMA journal_mode=WAL")
                 cursor.execute("PRAGMA synchronous=NORMAL")
                 cursor.close()
+        elif self.engine.dialect.name == "postgresql" and config.database_statement_timeout_ms > 0:
+            statement_timeout_ms = int(config.database_statement_timeout_ms)
+
+            @event.listens_for(self.engine, "connect")
+            def set_statement_timeout(dbapi_conn, connection_record):
+                cursor = dbapi_conn.cursor()
+                cursor.execute(f"SET statement_timeout = {statement_timeout_ms}")
+                cursor.close()
+                dbapi_conn.commit()
 
         self.SessionLocal = sessionmaker(
             bind=self.engine, expire_on_commit=False, class_=Session
         )
-        logger.info(f"Database engine initialized: {self.database_url}")
+        logger.info(
+            f"Database engine initialized: {_display_url(self.database_url)} "
+            f"(pool {config.database_pool_size}+{config.database_max_overflow})"
+        )
+
+    def reconnect(self, database_url: str) -> None:
+        """Switch to new connection settings, e.g. rotated credentials.
+
+        New sessions use the new engine at once. The old engine's idle
+        connections are closed; those still checked out finish their work
+        and are discarded when returned.
+
+        Args:
+            database_url: New database URL
+        """
+        old_engine = self.engine
+        self.database_url = database_url
+        self._initialize_engine()
+        if old_engine is not None:
+            old_engine.dispose()
 
     def create_all(self):
         """Create all tables in the database."""
@@ -72,6 +150,19 @@ class DatabaseManager:
         Base.metadata.create_all(self.engine)
         logger.info("Database tables created")
 
+    def upgrade(self) -> list:
+        """Apply schema upgrades to tables created by older releases.
+
+        Returns:
+            list: Names of upgrades that changed the database.
+        """
+        from src.migrations import upgrade_database
+
+        applied = upgrade_database(self.engine)
+        if applied:
+            logger.info(f"Database upgrades applied: {', '.join(applied)}")
+        return applied
+
     def get_session(self) -> Session:
         """Get a new database session.
 
@@ -106,12 +197,19 @@ class DatabaseManager:
         """
         pool = self.engine.pool
         return {
-            "pool_size": getattr(pool, "pool_size", 5),
-            "max_overflow": getattr(pool, "max_overflow", 5),
-            "checked_in": getattr(pool, "checkedin", 0),
-            "checked_out": getattr(pool, "checkedout", 0),
+            "pool_size": pool.size(),
+            "max_overflow": self.max_overflow,
+            "checked_in": pool.checkedin(),
+            "checked_out": pool.checkedout(),
         }
 
+    def pool_exhausted(self) -> bool:
+        """Whether every connection the pool may open is checked out."""
+        stats = self.get_pool_size()
+        if stats["max_overflow"] < 0:
+            return False  # unbounded overflow
+        return stats["checked_out"] >= stats["pool_size"] + stats["max_overflow"]
+
     def health_check(self) -> bool:
         """Perform a health check on the database connection.
 
@@ -148,8 +246,9 @@ class DatabaseManager:
         return {"columns": columns, "indices": indices}
 
 
-# Global database manager instance
+# Global database manager instance (reconnects when secrets rotate)
 _db_manager = None
+_db_manager_generation = 0
 
 
 def get_db_manager() -> DatabaseManager:
@@ -158,9 +257,18 @@ def get_db_manager() -> DatabaseManager:
     Returns:
         DatabaseManager: The database manager instance.
     """
-    global _db_manager
+    global _db_manager, _db_manager_generation
+    generation = secrets_generation()
     if _db_manager is None:
+        _db_manager_generation = generation
         _db_manager = DatabaseManager()
+        publish_db_pool(_db_manager.get_pool_size)
+    elif _db_manager_generation != generation:
+        _db_manager_generation = generation
+        database_url = configured_database_url()
+        if database_url != _db_manager.database_url:
+            logger.info("Database credentials changed; reconnecting")
+            _db_manager.reconnect(database_url)
     return _db_manager
 
 
@@ -168,6 +276,7 @@ def init_db():
     """Initialize the database on application startup."""
     db_manager = get_db_manager()
     db_manager.create_all()
+    db_manager.upgrade()
     health = db_manager.health_check()
     if health:
         logger.info("Database initialized successfully")
@@ -0,0 +1,72 @@
+"""Server interceptor applying the CallPolicy to every RPC.
+
+Imported by GrpcServer.start only, as it needs the grpc extra.
+"""
+
+import logging
+from typing import Any, Awaitable, Callable
+
+import grpc
+
+from src.grpc_api.policy import CallPolicy, CallRefused, current_principal, peer_address
+
+logger = logging.getLogger(__name__)
+
+
+class PolicyInterceptor(grpc.aio.ServerInterceptor):
+    """Refuses calls the policy does not admit before their handler runs."""
+
+    def __init__(self, policy: CallPolicy):
+        self.policy = policy
+
+    async def _admit(self, rpc: str, context: Any) -> None:
+        metadata = {key.lower(): value for key, value in (context.invocation_metadata() or ())}
+        try:
+            principal = await self.policy.admit(rpc, metadata, peer_address(context.peer()))
+        except CallRefused as e:
+            if e.retry_after is not None:
+                context.set_trailing_metadata((("retry-after", str(e.retry_after)),))
+            await context.abort(getattr(grpc.StatusCode, e.status), e.message)
+        # Each call runs in its own task, so the servicer sees its own caller
+        current_principal.set(principal)
+
+    async def intercept_service(
+        self,
+        continuation: Callable[[grpc.HandlerCallDetails], Awaitable[grpc.RpcMethodHandler]],
+        handler_call_details: grpc.HandlerCallDetails,
+    ) -> grpc.RpcMethodHandler:
+        handler = await continuation(handler_call_details)
+        if handler is None:
+            return None
+        rpc = handler_call_details.method.rsplit("/", 1)[-1]
+        serializers = {
+            "request_deserializer": handler.request_deserializer,
+            "response_serializer": handler.response_serializer,
+        }
+
+        if handler.unary_unary:
+            async def unary_unary(request, context):
+                await self._admit(rpc, context)
+                return await handler.unary_unary(request, context)
+
+            return grpc.unary_unary_rpc_method_handler(unary_unary, **serializers)
+        if handler.unary_stream:
+            async def unary_stream(request, context):
+                await self._admit(rpc, context)
+                async for response in handler.unary_stream(request, context):
+                    yield response
+
+            return grpc.unary_stream_rpc_method_handler(unary_stream, **serializers)
+        if handler.stream_unary:
+            async def stream_unary(request_iterator, context):
+                await self._admit(rpc, context)
+                return await handler.stream_unary(request_iterator, context)
+
+            return grpc.stream_unary_rpc_method_handler(stream_unary, **serializers)
+
+        async def stream_stream(request_iterator, context):
+            await self._admit(rpc, context)
+            async for response in handler.stream_stream(request_iterator, context):
+                yield response
+
+        return grpc.stream_stream_rpc_method_handler(stream_stream, **serializers)
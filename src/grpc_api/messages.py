@@ -0,0 +1,123 @@
+"""Messages of geolocation.proto and conversions from the REST models.
+
+Shared by the gRPC server and the REST routes that answer in protobuf
+(Accept: application/x-protobuf), so both encode results the same way.
+The message classes are compiled from geolocation.proto at runtime
+(grpc.protos_and_services), which needs the optional `grpc` dependencies.
+"""
+
+import os
+import sys
+from typing import Any, List, Tuple
+
+from src.models.schemas import IpLookupResponse
+from src.services.batch_lookup_service import BatchOutcome
+
+# Directory holding the `src` package; protos_and_services resolves proto
+# files (and names their modules) relative to sys.path entries
+PACKAGE_ROOT = os.path.dirname(os.path.dirname(os.path.dirname(os.path.abspath(__file__))))
+PROTO_PATH = os.path.relpath(
+    os.path.join(os.path.dirname(os.path.abspath(__file__)), "geolocation.proto"), PACKAGE_ROOT
+)
+
+
+def load_protos() -> Tuple[Any, Any]:
+    """Compile geolocation.proto into message and service modules.
+
+    Returns:
+        (messages, services) modules, as grpc_tools would generate them
+
+    Raises:
+        RuntimeError: If grpcio or grpcio-tools is not installed
+    """
+    try:
+        import grpc
+
+        # The working directory is only on sys.path when started from the repo root
+        if PACKAGE_ROOT not in sys.path:
+            sys.path.append(PACKAGE_ROOT)
+        return grpc.protos_and_services(PROTO_PATH)
+    except ImportError:
+        raise RuntimeError("grpcio and grpcio-tools are required for protobuf messages")
+
+
+# Global message module (compiled on first use)
+_protos = None
+
+
+def get_protos() -> Any:
+    """Get or compile the message module of geolocation.proto.
+
+    Raises:
+        RuntimeError: If grpcio or grpcio-tools is not installed
+    """
+    global _protos
+    if _protos is None:
+        _protos, _ = load_protos()
+    return _protos
+
+
+def to_ip_location(protos: Any, response: Any) -> Any:
+    """Convert an IpLookupResponse to the IpLocation message."""
+    scalars = {
+        field: getattr(response, field)
+        for field in (
+            "country_iso_code", "country_name", "continent_code", "city_name", "postal_code",
+            "latitude", "longitude", "accuracy_radius_km", "time_zone",
+        )
+        if getattr(response, field) is not None
+    }
+    location = protos.IpLocation(
+        ip_address=response.ip_address,
+        ip_version=response.ip_version,
+        network=response.network,
+        confidence=response.confidence,
+        confidence_flag=response.confidence_flag.value,
+        details_json=response.model_dump_json(exclude_none=True),
+        **scalars,
+    )
+    if response.asn is not None:
+        asn = response.asn.model_dump(include={"number", "organization", "connection_type"}, exclude_none=True)
+        location.asn.CopyFrom(protos.Asn(**asn))
+    if response.anonymizer is not None:
+        flags = response.anonymizer.model_dump(include={"vpn", "tor", "proxy", "hosting", "residential_proxy"})
+        location.anonymizer.CopyFrom(protos.Anonymizer(**{key: bool(value) for key, value in flags.items()}))
+    if response.risk is not None:
+        location.risk.CopyFrom(protos.Risk(
+            score=response.risk.score, reasons=[reason.code for reason in response.risk.reasons]
+        ))
+    if response.rules is not None:
+        location.rules.CopyFrom(protos.Rules(
+            decision=response.rules.decision,
+            matches=[protos.RuleMatch(rule_id=m.rule_id, action=m.action) for m in response.rules.matches],
+        ))
+    return location
+
+
+def to_lookup_result(protos: Any, outcome: BatchOutcome) -> Any:
+    """Convert a batch outcome to the LookupResult message.
+
+    IP results become `location`; reverse geocoded coordinates, which have
+    no message of their own, are carried as JSON in `reverse_geocode_json`.
+    """
+    if not outcome.ok:
+        return protos.LookupResult(
+            index=outcome.index,
+            error=protos.Error(error_code=outcome.error_code, error_message=outcome.error_message),
+        )
+    if isinstance(outcome.result, IpLookupResponse):
+        return protos.LookupResult(index=outcome.index, location=to_ip_location(protos, outcome.result))
+    return protos.LookupResult(
+        index=outcome.index, reverse_geocode_json=outcome.result.model_dump_json(exclude_none=True)
+    )
+
+
+def to_batch_response(protos: Any, outcomes: List[BatchOutcome]) -> Any:
+    """Convert batch outcomes (in request order) to the BatchLookupResponse message."""
+    succeeded = sum(1 for outcome in outcomes if outcome.ok)
+    return protos.BatchLookupResponse(
+        total=len(outcomes),
+        succeeded=succeeded,
+        failed=len(outcomes) - succeeded,
+        results=[to_lookup_result(protos, outcome) for outcome in outcomes],
+    )
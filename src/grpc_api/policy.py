@@ -0,0 +1,256 @@
+"""Call policy of the gRPC API: the checks the HTTP middleware applies to
+REST requests, applied to every RPC.
+
+In the order of the HTTP stack, a call is refused when its token's API
+key is restricted to other networks, when its peer address is in a
+sanctioned region, when its caller is over the rate limit or its API key
+over a quota, when its bearer token does not verify (or is missing with
+AUTH_REQUIRED), and when the token lacks the scopes the RBAC policy
+requires. RPCs are checked as their REST equivalents in RPC_ROUTES, so
+RBAC rules and quotas of an endpoint cover both APIs.
+
+The checks run in src.grpc_api.interceptor, which hands the verified
+caller to the servicer through `current_principal`. This module does not
+import grpc, so the policy can be used and tested without the extra.
+"""
+
+import asyncio
+import contextvars
+import logging
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, Mapping, Optional, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.middleware.quota import metered_principal, send_alerts
+from src.middleware.rate_limit import caller_key
+from src.services.auth_service import AuthenticationError, Principal, TokenVerifier, get_token_verifier
+from src.services.ip_allowlist_service import SourceNetworkError, address_allowed, token_cidrs
+from src.services.privacy_service import PRIVACY_CLAIM, PrivacyModeError
+from src.services.quota_service import QuotaEnforcer, get_quota_enforcer
+from src.services.rate_limit_service import RateLimiter, get_rate_limiter
+from src.services.rbac_service import PolicyStore, get_policy_store
+from src.services.sanctions_service import SanctionsScreener, SanctionsService, get_sanctions_screener
+from src.services.usage_service import api_key_of
+
+logger = logging.getLogger(__name__)
+
+# REST method and route of each RPC (RBAC rules, quota endpoints, sanctions audit records)
+RPC_ROUTES: Dict[str, Tuple[str, str]] = {
+    "LookupIp": ("GET", "/api/v1/lookup/ip/{ip}"),
+    "BatchLookup": ("POST", "/api/v1/lookup/batch"),
+    "StreamLookup": ("GET", "/api/v1/lookup/ip/{ip}"),
+    "MatchGeofences": ("GET", "/api/v1/geofences/match"),
+    "CheckGeofence": ("GET", "/api/v1/geofences/{geofence_id}/contains"),
+}
+
+# Caller of the RPC being served (None: anonymous); each call runs in its own task
+current_principal: contextvars.ContextVar[Optional[Principal]] = contextvars.ContextVar(
+    "grpc_principal", default=None
+)
+
+
+@dataclass
+class CallRefused(Exception):
+    """A call the policy refuses, with the gRPC status it gets."""
+
+    status: str  # grpc.StatusCode name
+    message: str
+    retry_after: Optional[int] = None
+
+    def __str__(self) -> str:
+        return self.message
+
+
+def peer_address(peer: Optional[str]) -> Optional[str]:
+    """Client address of a gRPC peer ("ipv4:1.2.3.4:5678", "ipv6:[::1]:5678")."""
+    if not peer:
+        return None
+    kind, _, address = peer.partition(":")
+    if kind == "ipv4":
+        return address.rsplit(":", 1)[0]
+    if kind == "ipv6":
+        return address.rsplit(":", 1)[0].strip("[]")
+    return None
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+class CallPolicy:
+    """Decides whether an RPC call is served, and as whom."""
+
+    def __init__(
+        self,
+        sanctions: bool = False,
+        rate_limit: bool = False,
+        quota: bool = False,
+        require_token: bool = False,
+        verifier: Optional[TokenVerifier] = None,
+        policy_store: Optional[PolicyStore] = None,
+        screener_factory: Callable[[], SanctionsScreener] = get_sanctions_screener,
+        limiter_factory: Callable[[], RateLimiter] = get_rate_limiter,
+        enforcer_factory: Callable[[], QuotaEnforcer] = get_quota_enforcer,
+        session_factory: Callable[[], Session] = _default_session,
+    ):
+        """Initialize policy.
+
+        Args:
+            sanctions: Screen peer addresses against sanctioned regions
+            rate_limit: Apply the callers' rate limits
+            quota: Count calls of API keys on quota plans
+            require_token: Refuse anonymous calls (AUTH_REQUIRED)
+            verifier: Token verifier (default: the global one)
+            policy_store: RBAC policy (default: the global one)
+            screener_factory: Returns the sanctions screener
+            limiter_factory: Returns the rate limiter
+            enforcer_factory: Returns the quota enforcer
+            session_factory: Creates database sessions for sanctions and quota alerts
+        """
+        self.sanctions = sanctions
+        self.rate_limit = rate_limit
+        self.quota = quota
+        self.require_token = require_token
+        self.verifier = verifier
+        self.policy_store = policy_store
+        self.screener_factory = screener_factory
+        self.limiter_factory = limiter_factory
+        self.enforcer_factory = enforcer_factory
+        self.session_factory = session_factory
+
+    @classmethod
+    def from_config(cls, config: Any) -> "CallPolicy":
+        """Policy enforcing the checks the configuration enables for HTTP."""
+        return cls(
+            sanctions=config.sanctions_mode.strip().lower() != "off",
+            rate_limit=config.rate_limit_enabled,
+            quota=config.quota_enabled,
+            require_token=config.auth_required,
+        )
+
+    def _verifier(self) -> TokenVerifier:
+        return self.verifier or get_token_verifier()
+
+    def _screen(self, rpc: str, peer: str, token: str, user_agent: Optional[str]) -> None:
+        tenant_id = None
+        if token:
+            try:
+                tenant_id = self._verifier().verify(token).tenant_id
+            except (AuthenticationError, RuntimeError):
+                # Authentication refuses the call; screen with the global policy
+                pass
+        session = self.session_factory()
+        try:
+            decision = self.screener_factory().screen(peer, tenant_id, session)
+            if not decision.blocked:
+                return
+            method, path = RPC_ROUTES.get(rpc, ("POST", rpc))
+            try:
+                SanctionsService(session).record_block(decision, method=method, path=path, user_agent=user_agent)
+            except ValueError as e:
+                logger.error(f"Sanctions audit record lost: {e}")
+        finally:
+            session.close()
+        raise CallRefused("PERMISSION_DENIED", "Service unavailable in your region")
+
+    def _check_rate(self, scope: Mapping[str, Any]) -> None:
+        limiter = self.limiter_factory()
+        key = caller_key(scope, self._verifier())
+        try:
+            decision = limiter.check(key)
+        except Exception as e:
+            # An unreachable bucket store must not take the API down with it
+            logger.error(f"Rate limit not applied to {key}: {e}")
+            return
+        if not decision.allowed:
+            raise CallRefused("RESOURCE_EXHAUSTED", "Rate limit exceeded", decision.retry_after)
+
+    def _count_quota(self, rpc: str, scope: Mapping[str, Any]) -> None:
+        from src.metrics import QUOTA_REQUESTS_REFUSED
+
+        principal = metered_principal(scope, self._verifier())
+        if principal is None:
+            return
+        key_id = api_key_of(principal.subject)
+        enforcer = self.enforcer_factory()
+        method, path = RPC_ROUTES.get(rpc, ("POST", rpc))
+        endpoint = f"{method} {path}" if enforcer.needs_endpoint(principal.quota_plan) else ""
+        try:
+            decision = enforcer.count(key_id, principal.quota_plan, endpoint)
+        except Exception as e:
+            logger.error(f"Quota not applied to API key {key_id}: {e}")
+            return
+        if decision.alerts:
+            send_alerts(self.session_factory, principal.tenant_id, decision.alerts, enforcer)
+        if decision.blocking is not None:
+            QUOTA_REQUESTS_REFUSED.labels(plan=decision.plan).inc()
+            raise CallRefused("RESOURCE_EXHAUSTED", "Quota exceeded", max(1, decision.reset_seconds))
+
+    async def _authenticate(self, rpc: str, authorization: Optional[str]) -> Optional[Principal]:
+        store = self.policy_store or get_policy_store()
+        policy = store.policy
+        if store.path and policy is None:
+            # Never serve a deployment meant to be restricted without its policy
+            raise CallRefused("UNAVAILABLE", "RBAC policy not loaded")
+        method, path = RPC_ROUTES.get(rpc, ("POST", rpc))
+        rule, required = policy.required_scopes(method, path) if policy else (None, None)
+        if authorization is None and not self.require_token and not required:
+            return None
+        scheme, _, token = (authorization or "").partition(" ")
+        if scheme.lower() != "bearer" or not token.strip():
+            raise CallRefused("UNAUTHENTICATED", "Bearer token required")
+        try:
+            # The denylist may be a blocking Redis call
+            principal = await asyncio.to_thread(self._verifier().verify, token.strip())
+        except AuthenticationError as e:
+            raise CallRefused("UNAUTHENTICATED", str(e))
+        except RuntimeError as e:
+            raise CallRefused("UNAVAILABLE", str(e))
+        if principal.claims.get(PRIVACY_CLAIM) is not None:
+            # Protobuf responses are not rewritten for privacy mode
+            raise CallRefused("PERMISSION_DENIED", str(PrivacyModeError()))
+        if required and not any(principal.has_scope(scope) for scope in required):
+            logger.info(
+                f"RBAC denied {rpc} ({method} {path}) to {principal.subject}"
+                f" (rule {rule.rule_id if rule else 'default'})"
+            )
+            raise CallRefused("PERMISSION_DENIED", f"Token lacks the '{' or '.join(required)}' scope")
+        return principal
+
+    async def admit(self, rpc: str, metadata: Mapping[str, str], peer: Optional[str]) -> Optional[Principal]:
+        """Check a call before it is served.
+
+        Args:
+            rpc: Method name, e.g. "LookupIp"
+            metadata: Call metadata, lower-case keys
+            peer: Client address
+
+        Returns:
+            The verified caller, None for an anonymous call (only without
+            require_token, to RPCs the RBAC policy requires no scope for)
+
+        Raises:
+            CallRefused: If the call must not be served
+        """
+        authorization = metadata.get("authorization")
+        scheme, _, token = (authorization or "").partition(" ")
+        token = token.strip() if scheme.lower() == "bearer" else ""
+        # Shaped like an ASGI scope, for the helpers shared with the HTTP middleware
+        scope = {
+            "headers": [(b"authorization", authorization.encode("latin-1"))] if authorization else [],
+            "client": (peer, 0) if peer else None,
+        }
+
+        if not address_allowed(peer, token_cidrs(token) if token else ()):
+            logger.warning(f"Refused token of a network-restricted API key from {peer} ({rpc})")
+            raise CallRefused("PERMISSION_DENIED", str(SourceNetworkError(peer)))
+        if self.sanctions and peer:
+            await asyncio.to_thread(self._screen, rpc, peer, token, metadata.get("user-agent"))
+        if self.rate_limit:
+            self._check_rate(scope)
+        if self.quota:
+            self._count_quota(rpc, scope)
+        return await self._authenticate(rpc, authorization)
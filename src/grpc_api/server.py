@@ -0,0 +1,264 @@
+"""gRPC server exposing lookups, batch lookups and geofence checks.
+
+The servicer calls the same functions as the REST handlers (assess_ip,
+lookup_ip_address, GeofenceService), so both APIs return the same answers.
+Credentials travel as call metadata: `authorization: Bearer <JWT>` selects
+the caller's tenant and `x-api-key` the enabled enrichments, as the REST
+headers do. Every call passes the CallPolicy (policy.py) first: the token
+is verified and the IP allowlist, sanctions screen, rate limit and quotas
+of the HTTP API apply. Tokens of API keys in privacy mode are refused, as
+protobuf responses are not rewritten.
+
+The listener serves TLS with GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE,
+plaintext without them (for trusted networks only: tokens travel in the
+clear).
+
+The message classes are compiled from geolocation.proto at startup
+(see messages.py), so no generated code is checked in; the optional
+`grpc` dependencies must be installed when GRPC_PORT is set.
+"""
+
+import asyncio
+import logging
+from functools import partial
+from typing import Any, Callable, Optional, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.api.lookup_routes import assess_ip, lookup_ip_address, tenant_overrides
+from src.grpc_api.messages import load_protos, to_batch_response, to_ip_location
+from src.grpc_api.policy import CallPolicy, current_principal
+from src.services.batch_lookup_service import ERROR_CODES, get_batch_lookup_service
+from src.services.geofence_service import GeofenceService
+
+logger = logging.getLogger(__name__)
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+def _error_code(error: Exception) -> str:
+    for error_type, code in ERROR_CODES:
+        if isinstance(error, error_type):
+            return code
+    return "E999"
+
+
+def _optional(message: Any, field: str) -> Any:
+    return getattr(message, field) if message.HasField(field) else None
+
+
+class GeolocationServicer:
+    """Implements the Geolocation service of geolocation.proto."""
+
+    def __init__(self, protos: Any, session_factory: Callable[[], Session] = _default_session):
+        """Initialize servicer.
+
+        Args:
+            protos: Message module from load_protos
+            session_factory: Creates database sessions for tenant data and history
+        """
+        self.protos = protos
+        self.session_factory = session_factory
+
+    @staticmethod
+    def _credentials(context: Any) -> Tuple[Optional[str], Optional[str]]:
+        """(tenant ID, API key) of the call; the interceptor verified the token."""
+        metadata = {key.lower(): value for key, value in (context.invocation_metadata() or ())}
+        principal = current_principal.get()
+        return (principal.tenant_id if principal else None), metadata.get("x-api-key")
+
+    async def _abort(self, context: Any, error: Exception) -> None:
+        import grpc
+
+        status = {
+            "E002": grpc.StatusCode.INVALID_ARGUMENT,
+            "E004": grpc.StatusCode.NOT_FOUND,
+            "E003": grpc.StatusCode.UNAVAILABLE,
+        }.get(_error_code(error))
+        if status is None:
+            logger.error(f"gRPC call failed: {type(error).__name__}: {str(error)}")
+            await context.abort(grpc.StatusCode.INTERNAL, "Internal error")
+        await context.abort(status, str(error))
+
+    def _assess(self, request: Any, tenant_id: Optional[str], api_key: Optional[str]) -> Any:
+        session = self.session_factory()
+        try:
+            response = assess_ip(
+                request.ip,
+                session,
+                api_key=api_key,
+                tenant_id=tenant_id,
+                as_of=request.as_of.ToDatetime() if request.HasField("as_of") else None,
+                user_id=_optional(request, "user_id"),
+                device_id=_optional(request, "device_id"),
+                device_lat=_optional(request, "device_lat"),
+                device_lon=_optional(request, "device_lon"),
+                device_accuracy_m=_optional(request, "device_accuracy_m"),
+                session_id=_optional(request, "session_id"),
+            )
+        finally:
+            session.close()
+        return to_ip_location(self.protos, response)
+
+    async def LookupIp(self, request, context):
+        try:
+            tenant_id, api_key = self._credentials(context)
+            return await asyncio.to_thread(self._assess, request, tenant_id, api_key)
+        except Exception as e:
+            await self._abort(context, e)
+
+    async def BatchLookup(self, request, context):
+        try:
+            tenant_id, api_key = self._credentials(context)
+            session = self.session_factory()
+            try:
+                overrides = tenant_overrides(tenant_id, session)
+            finally:
+                session.close()
+            outcomes = await get_batch_lookup_service().run(
+                list(request.ips), partial(lookup_ip_address, api_key=api_key, overrides=overrides)
+            )
+        except Exception as e:
+            await self._abort(context, e)
+            return
+
+        return to_batch_response(self.protos, outcomes)
+
+    async def StreamLookup(self, request_iterator, context):
+        try:
+            tenant_id, api_key = self._credentials(context)
+        except Exception as e:
+            await self._abort(context, e)
+            return
+        index = 0
+        async for request in request_iterator:
+            try:
+                location = await asyncio.to_thread(self._assess, request, tenant_id, api_key)
+                yield self.protos.LookupResult(index=index, location=location)
+            except Exception as e:
+                code = _error_code(e)
+                if code == "E999":
+                    logger.error(f"Stream lookup {index} failed: {type(e).__name__}: {str(e)}")
+                message = str(e) if code != "E999" else "Internal error"
+                yield self.protos.LookupResult(
+                    index=index, error=self.protos.Error(error_code=code, error_message=message)
+                )
+            index += 1
+
+    def _geofences(self, handler: Callable[[Any], Any]) -> Any:
+        session = self.session_factory()
+        try:
+            return handler(GeofenceService(session))
+        finally:
+            session.close()
+
+    async def MatchGeofences(self, request, context):
+        try:
+            geofence_ids = await asyncio.to_thread(
+                self._geofences, lambda service: service.matching_geofences(request.lat, request.lon)
+            )
+        except Exception as e:
+            await self._abort(context, e)
+            return
+        return self.protos.GeofenceMatchResponse(
+            latitude=request.lat, longitude=request.lon, geofence_ids=geofence_ids
+        )
+
+    async def CheckGeofence(self, request, context):
+        def contains(service):
+            geofence = service.get_geofence(request.geofence_id)
+            if geofence is None:
+                raise LookupError(f"Geofence {request.geofence_id} not found")
+            return service.contains(geofence, request.lat, request.lon)
+
+        try:
+            inside = await asyncio.to_thread(self._geofences, contains)
+        except Exception as e:
+            await self._abort(context, e)
+            return
+        return self.protos.GeofenceContainsResponse(
+            geofence_id=request.geofence_id, latitude=request.lat, longitude=request.lon, inside=inside
+        )
+
+
+class GrpcServer:
+    """grpc.aio server running next to the REST app on the same event loop."""
+
+    def __init__(
+        self,
+        address: str,
+        session_factory: Callable[[], Session] = _default_session,
+        policy: Optional[CallPolicy] = None,
+        tls_cert_file: str = "",
+        tls_key_file: str = "",
+    ):
+        """Initialize server.
+
+        Args:
+            address: Listen address, e.g. "[::]:50051"
+            session_factory: Creates database sessions for the servicer
+            policy: Checks calls must pass (default: token verification only)
+            tls_cert_file: PEM certificate chain of the listener (empty: plaintext)
+            tls_key_file: PEM private key of the certificate
+
+        Raises:
+            RuntimeError: If the grpc dependencies are not installed
+            ValueError: If only one of the TLS files is given
+            OSError: If a TLS file cannot be read
+        """
+        if bool(tls_cert_file) != bool(tls_key_file):
+            raise ValueError("GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE must be set together")
+        self.address = address
+        self.session_factory = session_factory
+        self.policy = policy or CallPolicy()
+        self._tls: Optional[Tuple[bytes, bytes]] = None
+        if tls_cert_file:
+            with open(tls_key_file, "rb") as key, open(tls_cert_file, "rb") as cert:
+                self._tls = (key.read(), cert.read())
+        self.protos, self.services = load_protos()
+        self._server = None
+        self.port: Optional[int] = None
+
+    async def start(self) -> None:
+        """Bind and start serving."""
+        import grpc
+
+        from src.grpc_api.interceptor import PolicyInterceptor
+
+        self._server = grpc.aio.server(interceptors=[PolicyInterceptor(self.policy)])
+        servicer = GeolocationServicer(self.protos, self.session_factory)
+        self.services.add_GeolocationServicer_to_server(servicer, self._server)
+        if self._tls is not None:
+            credentials = grpc.ssl_server_credentials([self._tls])
+            self.port = self._server.add_secure_port(self.address, credentials)
+        else:
+            self.port = self._server.add_insecure_port(self.address)
+        await self._server.start()
+        logger.info(f"gRPC API listening on {self.address} ({'TLS' if self._tls else 'plaintext'})")
+
+    async def stop(self, grace: float = 5.0) -> None:
+        """Stop serving, letting in-flight calls finish within the grace period."""
+        if self._server is not None:
+            await self._server.stop(grace)
+            self._server = None
+
+
+def build_grpc_server(config: Any) -> Optional[GrpcServer]:
+    """gRPC server from configuration (None when GRPC_PORT is 0).
+
+    Raises:
+        RuntimeError: If GRPC_PORT is set but the grpc dependencies are missing
+        ValueError: If TLS is half configured
+    """
+    if not config.grpc_port:
+        return None
+    return GrpcServer(
+        f"{config.grpc_host}:{config.grpc_port}",
+        policy=CallPolicy.from_config(config),
+        tls_cert_file=config.grpc_tls_cert_file,
+        tls_key_file=config.grpc_tls_key_file,
+    )
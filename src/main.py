@@ -1,9 +1,51 @@
 """FastAPI application for Detection to COP integration."""
+import asyncio
 from fastapi import FastAPI, Request
-from fastapi.responses import JSONResponse
+from fastapi.exception_handlers import http_exception_handler
+from fastapi.responses import JSONResponse, Response
+from sqlalchemy.exc import TimeoutError as PoolTimeoutError
+from starlette.exceptions import HTTPException as StarletteHTTPException
 from src.config import get_config
 from src.middleware import setup_middleware
 from src.api.routes import router as detection_router
+from src.api.health_routes import router as health_router
+from src.api.admin_routes import profiling_router, router as admin_router
+from src.api.auth_routes import router as auth_router
+from src.api.lookup_routes import router as lookup_router, warm_ip_lookup
+from src.api.lookup_v2_routes import router as lookup_v2_router
+from src.api.geocoding_routes import router as geocoding_router, warm_reverse_geocode
+from src.api.spatial_routes import router as spatial_router
+from src.api.tile_routes import router as tile_router
+from src.api.geofence_routes import router as geofence_router
+from src.api.poi_routes import router as poi_router
+from src.api.ip_override_routes import router as ip_override_router
+from src.api.positioning_routes import router as positioning_router
+from src.api.postal_routes import router as postal_router
+from src.api.group_routes import router as group_router
+from src.api.detect_routes import router as detect_router
+from src.api.velocity_routes import router as velocity_router
+from src.api.device_routes import router as device_router
+from src.api.webhook_routes import router as webhook_router
+from src.api.sanctions_routes import router as sanctions_router
+from src.api.risk_area_routes import router as risk_area_router
+from src.api.rule_routes import router as rule_router
+from src.api.graphql_routes import router as graphql_router
+from src.api.live_routes import router as live_router
+from src.api.job_routes import router as job_router
+from src.api.erasure_routes import router as erasure_router
+from src.api.usage_routes import router as usage_router
+from src.grpc_api.server import build_grpc_server
+from src.metrics import CONTENT_TYPE_LATEST, generate_latest
+from src.openapi import install_openapi
+from src.services.batch_job_service import get_batch_job_runner
+from src.services.billing_service import get_billing_exporter, get_billing_meter
+from src.services.cache_warming_service import build_cache_warmer
+from src.services.erasure_service import get_erasure_runner
+from src.services.geoip_update_service import build_update_service
+from src.services.lookup_cache_service import LookupType
+from src.services.profiling_service import start_continuous_profiling
+from src.services.secrets_service import get_secret_store
+from src.services.usage_service import get_usage_recorder
 
 # Create FastAPI app
 config = get_config()
@@ -17,7 +59,106 @@ app = FastAPI(
 setup_middleware(app, config)
 
 # Register routes
+app.include_router(health_router)
 app.include_router(detection_router)
+app.include_router(lookup_router)
+app.include_router(lookup_v2_router)
+app.include_router(geocoding_router)
+app.include_router(spatial_router)
+app.include_router(tile_router)
+app.include_router(geofence_router)
+app.include_router(poi_router)
+app.include_router(ip_override_router)
+app.include_router(positioning_router)
+app.include_router(postal_router)
+app.include_router(group_router)
+app.include_router(detect_router)
+app.include_router(velocity_router)
+app.include_router(device_router)
+app.include_router(webhook_router)
+app.include_router(sanctions_router)
+app.include_router(risk_area_router)
+app.include_router(rule_router)
+app.include_router(graphql_router)
+app.include_router(live_router)
+app.include_router(job_router)
+app.include_router(erasure_router)
+app.include_router(usage_router)
+app.include_router(auth_router)
+if config.admin_api_enabled:
+    app.include_router(admin_router)
+    if config.profiling_enabled:
+        app.include_router(profiling_router)
+install_openapi(app)
+
+
+@app.on_event("startup")
+async def start_background_services():
+    """Start background services enabled by configuration."""
+    start_continuous_profiling(config)
+    secret_store = get_secret_store()
+    if secret_store is not None:
+        app.state.secret_store_task = asyncio.create_task(secret_store.start())
+    updater = build_update_service(config)
+    if updater is not None:
+        app.state.geoip_updater = updater
+        app.state.geoip_updater_task = asyncio.create_task(updater.start())
+    grpc_server = build_grpc_server(config)
+    if grpc_server is not None:
+        await grpc_server.start()
+        app.state.grpc_server = grpc_server
+    warmer = build_cache_warmer(
+        config, {LookupType.IP: warm_ip_lookup, LookupType.REVERSE_GEOCODE: warm_reverse_geocode}
+    )
+    if warmer is not None:
+        # /readyz fails until the hot lookups are cached
+        app.state.cache_warmer = warmer
+        app.state.cache_warmer_task = asyncio.create_task(warmer.start())
+    await asyncio.to_thread(get_batch_job_runner().recover)
+    await asyncio.to_thread(get_erasure_runner().recover)
+    if config.usage_analytics_enabled:
+        app.state.usage_recorder_task = asyncio.create_task(get_usage_recorder().start())
+    if config.billing_enabled:
+        app.state.billing_meter_task = asyncio.create_task(get_billing_meter().start())
+        if get_billing_exporter().configured:
+            app.state.billing_exporter_task = asyncio.create_task(get_billing_exporter().start())
+
+
+@app.on_event("shutdown")
+async def stop_background_services():
+    """Stop background services started at startup."""
+    task = getattr(app.state, "secret_store_task", None)
+    if task is not None:
+        get_secret_store().stop()
+        task.cancel()
+    task = getattr(app.state, "geoip_updater_task", None)
+    if task is not None:
+        app.state.geoip_updater.stop()
+        task.cancel()
+    grpc_server = getattr(app.state, "grpc_server", None)
+    if grpc_server is not None:
+        await grpc_server.stop()
+    task = getattr(app.state, "cache_warmer_task", None)
+    if task is not None:
+        app.state.cache_warmer.stop()
+        task.cancel()
+        await asyncio.to_thread(app.state.cache_warmer.persist)
+    await get_batch_job_runner().stop()
+    await get_erasure_runner().stop()
+    task = getattr(app.state, "usage_recorder_task", None)
+    if task is not None:
+        get_usage_recorder().stop()
+        task.cancel()
+        await asyncio.to_thread(get_usage_recorder().flush)
+    task = getattr(app.state, "billing_exporter_task", None)
+    if task is not None:
+        get_billing_exporter().stop()
+        task.cancel()
+    task = getattr(app.state, "billing_meter_task", None)
+    if task is not None:
+        get_billing_meter().stop()
+        task.cancel()
+        await asyncio.to_thread(get_billing_meter().flush)
 
 
 # Health check endpoint
@@ -35,6 +176,12 @@ async def health_check():
     }
 
 
+@app.get("/metrics")
+async def metrics():
+    """Prometheus metrics endpoint."""
+    return Response(content=generate_latest(), media_type=CONTENT_TYPE_LATEST)
+
+
 # Error handlers
 # Temporarily disabled to see real validation errors
 # @app.exception_handler(400)
@@ -48,13 +195,35 @@ async def health_check():
 
 @app.exception_handler(404)
 async def not_found_handler(request: Request, exc: Exception):
-    """Handle 404 Not Found errors."""
+    """Handle 404 Not Found errors.
+
+    Only unmatched routes get the plain body; a 404 raised by an endpoint
+    keeps its structured detail (error code E004).
+    """
+    if isinstance(exc, StarletteHTTPException) and exc.detail != "Not Found":
+        return await http_exception_handler(request, exc)
     return JSONResponse(
         status_code=404,
         content={"detail": "Not found"},
     )
 
 
+@app.exception_handler(PoolTimeoutError)
+async def database_busy_handler(request: Request, exc: PoolTimeoutError):
+    """No database connection freed up within DATABASE_POOL_TIMEOUT_SECONDS."""
+    return JSONResponse(
+        status_code=503,
+        headers={"Retry-After": "1"},
+        content={
+            "detail": {
+                "error_code": "E003",
+                "error_message": "Database busy; try again later",
+                "details": None,
+            }
+        },
+    )
+
+
 @app.exception_handler(500)
 async def internal_error_handler(request: Request, exc: Exception):
     """Handle 500 Internal Server Error."""
@@ -0,0 +1,184 @@
+"""Prometheus metrics for the geolocation engine."""
+import time
+from typing import Callable, Dict
+
+from prometheus_client import CONTENT_TYPE_LATEST, Counter, Gauge, Histogram, generate_latest
+
+__all__ = [
+    "CONTENT_TYPE_LATEST",
+    "generate_latest",
+    "AUTH_FAILURES",
+    "AUTH_LOCKOUTS",
+    "BATCH_LOOKUP_PENDING_ITEMS",
+    "BATCH_LOOKUPS_REFUSED",
+    "CREDENTIAL_ATTACK_ALERTS",
+    "DB_POOL_CHECKOUT_SECONDS",
+    "DB_POOL_CHECKOUT_TIMEOUTS",
+    "DB_POOL_CONNECTIONS",
+    "DEGRADED_LOOKUPS",
+    "GEOIP_DATASET_AGE",
+    "GEOIP_DATASET_BUILD_TIMESTAMP",
+    "GEOIP_DATASET_LAST_CHECK",
+    "GEOIP_DATASET_UPDATES",
+    "LOOKUP_CACHE_REQUESTS",
+    "LOOKUP_CACHE_TIER_REQUESTS",
+    "LOOKUP_CACHE_TIER_SECONDS",
+    "QUOTA_REQUESTS_REFUSED",
+    "REQUESTS_IN_FLIGHT",
+    "REQUESTS_SHED",
+    "RULE_EVALUATIONS",
+    "RULE_MATCHES",
+    "SANCTIONS_DECISIONS",
+    "WEBHOOK_DELIVERIES",
+    "publish_db_pool",
+    "record_dataset_build",
+]
+
+# GeoIP dataset freshness
+GEOIP_DATASET_BUILD_TIMESTAMP = Gauge(
+    "geoip_dataset_build_timestamp_seconds",
+    "Build time of the active GeoIP dataset (Unix epoch seconds)",
+)
+GEOIP_DATASET_AGE = Gauge(
+    "geoip_dataset_age_seconds",
+    "Seconds since the active GeoIP dataset was built",
+)
+GEOIP_DATASET_LAST_CHECK = Gauge(
+    "geoip_dataset_last_check_timestamp_seconds",
+    "Time of the last GeoIP dataset update check (Unix epoch seconds)",
+)
+GEOIP_DATASET_UPDATES = Counter(
+    "geoip_dataset_updates_total",
+    "GeoIP dataset update checks by outcome",
+    ["result"],
+)
+
+# Webhook notifications
+WEBHOOK_DELIVERIES = Counter(
+    "webhook_deliveries_total",
+    "Webhook deliveries by event type and outcome",
+    ["event", "result"],
+)
+
+# Sanctioned-country enforcement
+SANCTIONS_DECISIONS = Counter(
+    "sanctions_decisions_total",
+    "Requests matching a sanctioned country or region, by action taken",
+    ["action"],
+)
+
+# Detection rules
+RULE_EVALUATIONS = Counter(
+    "rule_evaluations_total",
+    "Lookups evaluated against the detection rules",
+)
+RULE_MATCHES = Counter(
+    "rule_matches_total",
+    "Detection rule matches by rule, action and state (shadow matches never affect responses)",
+    ["rule", "action", "state"],
+)
+
+# Brute-force protection of authentication
+AUTH_FAILURES = Counter(
+    "auth_failures_total",
+    "Failed authentications by endpoint",
+    ["endpoint"],
+)
+AUTH_LOCKOUTS = Counter(
+    "auth_lockouts_total",
+    "Client addresses locked out after repeated failed authentications, by endpoint",
+    ["endpoint"],
+)
+CREDENTIAL_ATTACK_ALERTS = Counter(
+    "credential_attack_alerts_total",
+    "API keys and admin accounts failing often enough to suggest credential stuffing, by kind",
+    ["kind"],
+)
+
+# Plan-based quotas
+QUOTA_REQUESTS_REFUSED = Counter(
+    "quota_requests_refused_total",
+    "Requests of API keys refused beyond a monthly quota, by plan",
+    ["plan"],
+)
+
+# Concurrency limiting
+REQUESTS_IN_FLIGHT = Gauge(
+    "http_requests_in_flight",
+    "HTTP requests being served by the process",
+)
+REQUESTS_SHED = Counter(
+    "http_requests_shed_total",
+    "HTTP requests refused with 503 because a concurrency budget was used up, by budget",
+    ["budget"],
+)
+LOOKUP_CACHE_REQUESTS = Counter(
+    "lookup_cache_requests_total",
+    "Lookup cache reads, by lookup type and result (hit, negative_hit, miss, shared, error)",
+    ["type", "result"],
+)
+LOOKUP_CACHE_TIER_REQUESTS = Counter(
+    "lookup_cache_tier_requests_total",
+    "Lookup cache tier reads, by tier (l1, l2), lookup type and result (hit, miss, error)",
+    ["tier", "type", "result"],
+)
+LOOKUP_CACHE_TIER_SECONDS = Histogram(
+    "lookup_cache_tier_duration_seconds",
+    "Lookup cache tier reads and writes, by tier, lookup type and operation (get, set)",
+    ["tier", "type", "operation"],
+    buckets=(0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25),
+)
+# Database connection pool
+DB_POOL_CONNECTIONS = Gauge(
+    "db_pool_connections",
+    "Database connections of the process's pool, by state (checked_out, idle, capacity)",
+    ["state"],
+)
+DB_POOL_CHECKOUT_SECONDS = Histogram(
+    "db_pool_checkout_seconds",
+    "Time spent waiting for a database connection from the pool",
+    buckets=(0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10),
+)
+DB_POOL_CHECKOUT_TIMEOUTS = Counter(
+    "db_pool_checkout_timeouts_total",
+    "Requests that got no database connection within DATABASE_POOL_TIMEOUT_SECONDS",
+)
+
+# Batch lookup worker pool
+BATCH_LOOKUP_PENDING_ITEMS = Gauge(
+    "batch_lookup_pending_items",
+    "Items of accepted batch lookups and job chunks not looked up yet",
+)
+BATCH_LOOKUPS_REFUSED = Counter(
+    "batch_lookups_refused_total",
+    "Batch lookups refused with 503 because BATCH_LOOKUP_MAX_PENDING_ITEMS were pending",
+)
+
+DEGRADED_LOOKUPS = Counter(
+    "geoip_degraded_lookups_total",
+    "IP lookups answered in degraded mode, by reason and answer (recent or country)",
+    ["reason", "answer"],
+)
+
+
+def record_dataset_build(build_epoch: int) -> None:
+    """Publish the build time of the newly active GeoIP dataset.
+
+    Args:
+        build_epoch: Dataset build time from the MMDB metadata
+    """
+    GEOIP_DATASET_BUILD_TIMESTAMP.set(build_epoch)
+    GEOIP_DATASET_AGE.set_function(lambda: max(0.0, time.time() - build_epoch))
+
+
+def publish_db_pool(pool_stats: Callable[[], Dict[str, int]]) -> None:
+    """Publish the state of the database connection pool, read at each scrape.
+
+    Args:
+        pool_stats: Returns pool_size, max_overflow, checked_in and checked_out
+    """
+    DB_POOL_CONNECTIONS.labels(state="checked_out").set_function(lambda: pool_stats()["checked_out"])
+    DB_POOL_CONNECTIONS.labels(state="idle").set_function(lambda: pool_stats()["checked_in"])
+    DB_POOL_CONNECTIONS.labels(state="capacity").set_function(
+        lambda: pool_stats()["pool_size"] + max(0, pool_stats()["max_overflow"])
+    )
@@ -1,7 +1,22 @@
 """Middleware setup for FastAPI application."""
 from fastapi import FastAPI
 from fastapi.middleware.cors import CORSMiddleware
+from src.api.versioning import api_versions
 from src.config import Config
+from src.middleware.audit_log import AuditLogMiddleware
+from src.middleware.auth_throttle import AuthThrottleMiddleware
+from src.middleware.authentication import AuthenticationMiddleware
+from src.middleware.billing import BillingMiddleware
+from src.middleware.compression import CompressionMiddleware, parse_encodings
+from src.middleware.concurrency import ConcurrencyMiddleware
+from src.middleware.ip_allowlist import IpAllowlistMiddleware
+from src.middleware.privacy import PrivacyMiddleware
+from src.middleware.quota import QuotaMiddleware
+from src.middleware.rate_limit import RateLimitMiddleware
+from src.middleware.request_signing import BodyDigestMiddleware
+from src.middleware.sanctions import SanctionsMiddleware
+from src.middleware.usage import UsageMiddleware
+from src.middleware.versioning import ApiVersionMiddleware
 
 
 def setup_middleware(app: FastAPI, config: Config) -> None:
@@ -11,6 +26,91 @@ def setup_middleware(app: FastAPI, config: Config) -> None:
         app: FastAPI application instance.
         config: Application configuration.
     """
+    # Hash the bodies of signed requests as routes read them (innermost, next to the routes)
+    app.add_middleware(BodyDigestMiddleware)
+
+    # Announce deprecated API versions on their responses
+    app.add_middleware(ApiVersionMiddleware, versions=api_versions(config).values())
+
+    # Require bearer tokens and per-endpoint scopes outside the exempt paths
+    # (inside the sanctions screen, so blocked regions are refused before
+    # their tokens are checked)
+    if config.auth_required or config.rbac_policy_path:
+        app.add_middleware(
+            AuthenticationMiddleware,
+            exempt_paths=[path.strip() for path in config.auth_exempt_paths.split(",")],
+            require_token=config.auth_required,
+        )
+
+    # Count requests made with API key tokens (outside authentication,
+    # whose principal names the key)
+    if config.usage_analytics_enabled:
+        app.add_middleware(UsageMiddleware)
+
+    # Meter billable tenant requests (outside authentication, like usage counting)
+    if config.billing_enabled:
+        app.add_middleware(
+            BillingMiddleware,
+            exempt_paths=[path.strip() for path in config.billing_exempt_paths.split(",")],
+        )
+
+    # Write admin API changes and refused admin requests to the audit log
+    # (outside authentication, so that rejected credentials are recorded too)
+    if config.admin_api_enabled:
+        app.add_middleware(AuditLogMiddleware)
+
+    # Lock out addresses failing authentication repeatedly (outside the
+    # audit log, so that locked-out attempts do not flood it)
+    if config.auth_throttle_enabled:
+        app.add_middleware(
+            AuthThrottleMiddleware,
+            paths=[path.strip() for path in config.auth_throttle_paths.split(",")],
+            client_ip_header=config.api_key_client_ip_header.strip(),
+        )
+
+    # Screen client addresses against sanctioned countries (added first so
+    # that CORS wraps it and blocked responses still carry CORS headers)
+    if config.sanctions_mode.strip().lower() != "off":
+        app.add_middleware(
+            SanctionsMiddleware,
+            exempt_paths=[path.strip() for path in config.sanctions_exempt_paths.split(",")],
+            client_ip_header=config.sanctions_client_ip_header.strip(),
+        )
+
+    # Refuse tokens of network-restricted API keys from other addresses
+    # (outside the sanctions screen and authentication, before either
+    # looks at the token)
+    app.add_middleware(IpAllowlistMiddleware, client_ip_header=config.api_key_client_ip_header.strip())
+
+    # Truncate coordinates and redact logs for privacy-mode API keys (inside
+    # compression, which must see the rewritten bodies)
+    app.add_middleware(PrivacyMiddleware)
+
+    # Refuse requests of API keys beyond their plans' monthly quotas (inside
+    # the rate limit, so that rate-limited requests are not counted)
+    if config.quota_enabled:
+        app.add_middleware(QuotaMiddleware)
+
+    # Limit each caller's request rate (outside every other check, so that
+    # refused requests cost nothing else; inside CORS, so that 429s carry
+    # CORS headers)
+    if config.rate_limit_enabled:
+        app.add_middleware(
+            RateLimitMiddleware,
+            exempt_paths=[path.strip() for path in config.rate_limit_exempt_paths.split(",")],
+            client_ip_header=config.api_key_client_ip_header.strip(),
+        )
+
+    # Shed requests beyond the concurrency budgets (outside the rate limit,
+    # so that an overloaded process does no other work for them; inside
+    # CORS, so that 503s carry CORS headers)
+    if config.concurrency_limit_enabled:
+        app.add_middleware(
+            ConcurrencyMiddleware,
+            exempt_paths=[path.strip() for path in config.concurrency_exempt_paths.split(",")],
+            retry_after_seconds=config.concurrency_retry_after_seconds,
+        )
+
     # Add CORS middleware
     app.add_middleware(
         CORSMiddleware,
@@ -19,3 +119,14 @@ def setup_middleware(app: FastAPI, config: Config) -> None:
         allow_methods=["*"],
         allow_headers=["*"],
     )
+
+    # Compress response bodies (outermost, so every response is covered)
+    encodings = parse_encodings(config.compression_encodings)
+    if encodings:
+        app.add_middleware(
+            CompressionMiddleware,
+            encodings=encodings,
+            minimum_size=config.compression_min_size,
+            level=config.compression_level,
+            exempt_paths=[path.strip() for path in config.compression_exempt_paths.split(",")],
+        )
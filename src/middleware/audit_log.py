@@ -0,0 +1,101 @@
+"""Audit logging of admin API requests."""
+import logging
+from typing import Any, Callable, Dict, Tuple
+
+from fastapi import Request
+from sqlalchemy.orm import Session
+from starlette.concurrency import run_in_threadpool
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.routing import Match
+
+from src.services.audit_log_service import AuditCategory, AuditLogService, AuditOutcome
+
+logger = logging.getLogger(__name__)
+
+# Reads are not audited unless they are refused
+_READ_METHODS = frozenset({"GET", "HEAD", "OPTIONS"})
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+def _route_of(request: Request) -> Tuple[str, Dict[str, Any]]:
+    """Name and path parameters of the route serving the request."""
+    for route in request.app.router.routes:
+        match, child_scope = route.matches(request.scope)
+        if match == Match.FULL:
+            endpoint = child_scope.get("endpoint")
+            return getattr(endpoint, "__name__", request.url.path), child_scope.get("path_params", {})
+    return f"{request.method} {request.url.path}", {}
+
+
+def _outcome(status_code: int) -> str:
+    if status_code in (401, 403):
+        return AuditOutcome.DENIED
+    if status_code >= 400:
+        return AuditOutcome.FAILED
+    return AuditOutcome.SUCCESS
+
+
+class AuditLogMiddleware(BaseHTTPMiddleware):
+    """Writes admin API changes, and refused admin requests, to the audit log.
+
+    The actor is the subject of the principal the admin dependency put in
+    `request.state.principal` (None when the credentials were rejected);
+    the action is the name of the route that handled the request.
+    """
+
+    def __init__(
+        self,
+        app,
+        session_factory: Callable[[], Session] = _default_session,
+        path_prefix: str = "/admin/",
+    ):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            session_factory: Creates database sessions for audit entries
+            path_prefix: Paths of the admin API
+        """
+        super().__init__(app)
+        self.session_factory = session_factory
+        self.path_prefix = path_prefix
+
+    def _record(self, request: Request, status_code: int) -> None:
+        principal = getattr(request.state, "principal", None)
+        action, path_params = _route_of(request)
+        session = self.session_factory()
+        try:
+            AuditLogService(session).append(
+                AuditCategory.ADMIN,
+                action,
+                _outcome(status_code),
+                actor=principal.subject if principal is not None else None,
+                tenant_id=path_params.get("tenant_id"),
+                resource=request.url.path,
+                details={
+                    "method": request.method,
+                    "query": request.url.query or None,
+                    "status_code": status_code,
+                    "client": request.client.host if request.client else None,
+                },
+            )
+        except ValueError as e:
+            # The request has already been handled; the loss is in the application log
+            logger.error(f"Audit log entry lost for {request.method} {request.url.path}: {e}")
+        finally:
+            session.close()
+
+    async def dispatch(self, request: Request, call_next):
+        if not request.url.path.startswith(self.path_prefix):
+            return await call_next(request)
+        # Create the state now, so that it is shared with the route however the scope is copied
+        request.state.principal = None
+        response = await call_next(request)
+        if request.method not in _READ_METHODS or response.status_code in (401, 403):
+            await run_in_threadpool(self._record, request, response.status_code)
+        return response
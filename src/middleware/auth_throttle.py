@@ -0,0 +1,100 @@
+"""Brute-force protection of the authentication endpoints (see
+src.services.auth_throttle_service).
+
+Locked-out addresses are answered 429 (E013) with Retry-After and
+RateLimit-* headers (the failures allowed, none remaining until the
+lockout ends) before any authentication work is done; every 401 answer of the
+throttled paths counts as a failure of the caller's address and of the
+credential it tried. Routes name that credential in
+`request.state.auth_identity` (the token endpoints name the API key);
+otherwise it is the subject of the rejected bearer token.
+"""
+import logging
+from typing import Callable, Sequence
+
+from fastapi import Request, status
+from fastapi.responses import JSONResponse
+from sqlalchemy.orm import Session
+from starlette.middleware.base import BaseHTTPMiddleware
+
+from src.middleware.ip_allowlist import bearer_token
+from src.services.auth_throttle_service import AuthThrottle, get_auth_throttle, report_attack, token_identity
+from src.services.ip_allowlist_service import client_address
+from src.services.rate_limit_service import rate_limit_headers
+
+logger = logging.getLogger(__name__)
+
+_LABELS = {"/api/v1/auth/token": "token", "/api/v1/auth/refresh": "refresh", "/admin/": "admin"}
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+class AuthThrottleMiddleware(BaseHTTPMiddleware):
+    """Locks out addresses failing authentication and reports attacked credentials."""
+
+    def __init__(
+        self,
+        app,
+        paths: Sequence[str],
+        client_ip_header: str = "",
+        throttle_factory: Callable[[], AuthThrottle] = get_auth_throttle,
+        session_factory: Callable[[], Session] = _default_session,
+    ):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            paths: Path prefixes of the throttled endpoints
+            client_ip_header: Header carrying the client address behind a
+                trusted proxy; its rightmost entry is used
+            throttle_factory: Returns the failure counters
+            session_factory: Creates database sessions for attack reports
+        """
+        super().__init__(app)
+        self.paths = [path for path in paths if path]
+        self.client_ip_header = client_ip_header
+        self.throttle_factory = throttle_factory
+        self.session_factory = session_factory
+
+    def _report(self, alert) -> None:
+        session = self.session_factory()
+        try:
+            report_attack(session, alert)
+        finally:
+            session.close()
+
+    async def dispatch(self, request: Request, call_next):
+        path = next((p for p in self.paths if request.url.path.startswith(p)), None)
+        if path is None:
+            return await call_next(request)
+        endpoint = _LABELS.get(path, path.strip("/"))
+        throttle = self.throttle_factory()
+        client_ip = client_address(request.scope, self.client_ip_header)
+        retry_after = throttle.retry_after(client_ip)
+        if retry_after:
+            return JSONResponse(
+                status_code=status.HTTP_429_TOO_MANY_REQUESTS,
+                content={
+                    "detail": {
+                        "error_code": "E013",
+                        "error_message": "Too many failed authentications; try again later",
+                        "details": {"retry_after": retry_after},
+                    }
+                },
+                headers=rate_limit_headers(throttle.max_failures, 0, retry_after, retry_after),
+            )
+
+        # Create the state now, so that it is shared with the route however the scope is copied
+        request.state.auth_identity = None
+        response = await call_next(request)
+        if response.status_code == status.HTTP_401_UNAUTHORIZED:
+            token = bearer_token(request.scope)
+            identity = request.state.auth_identity or (token_identity(token) if token else None)
+            alert = throttle.failed(endpoint, client_ip, identity)
+            if alert is not None:
+                self._report(alert)
+        return response
@@ -0,0 +1,128 @@
+"""Bearer token, client certificate and per-endpoint scope enforcement
+(AUTH_REQUIRED, MTLS_IDENTITIES, RBAC_POLICY_PATH)."""
+import logging
+from typing import Iterable, Optional
+
+from fastapi import Request, status
+from fastapi.responses import JSONResponse
+from starlette.concurrency import run_in_threadpool
+from starlette.middleware.base import BaseHTTPMiddleware
+
+from src.services.auth_service import AuthenticationError, Principal, TokenVerifier, get_token_verifier
+from src.services.mtls_service import SCOPE_EXTENSION, ClientCertificateMapper, get_client_certificate_mapper
+from src.services.oidc_service import OIDCVerifier, get_oidc_verifier
+from src.services.rbac_service import PolicyStore, get_policy_store
+
+logger = logging.getLogger(__name__)
+
+
+def _error(status_code: int, error_code: str, message: str, headers=None) -> JSONResponse:
+    return JSONResponse(
+        status_code=status_code,
+        content={"detail": {"error_code": error_code, "error_message": message, "details": None}},
+        headers=headers,
+    )
+
+
+class AuthenticationMiddleware(BaseHTTPMiddleware):
+    """Rejects requests without a valid bearer token (401) or without the
+    scopes the RBAC policy requires for the endpoint (403).
+
+    Routes that already require a token keep checking it themselves; this
+    closes the anonymous ones (AUTH_REQUIRED) and enforces per-endpoint
+    scopes (RBAC_POLICY_PATH). The verified caller is available to routes
+    as `request.state.principal`. Under the OIDC paths (the admin API)
+    tokens of the corporate IdP are accepted as well. Requests without an
+    Authorization header authenticate with their verified client
+    certificate, if the listener received one (mutual TLS).
+    """
+
+    def __init__(
+        self,
+        app,
+        verifier: Optional[TokenVerifier] = None,
+        exempt_paths: Iterable[str] = (),
+        oidc: Optional[OIDCVerifier] = None,
+        oidc_paths: Iterable[str] = ("/admin/",),
+        require_token: bool = True,
+        policy_store: Optional[PolicyStore] = None,
+        certificates: Optional[ClientCertificateMapper] = None,
+    ):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            verifier: Token verifier (default: the global one)
+            exempt_paths: Path prefixes served without a token (health
+                checks, metrics, docs, token issuance)
+            oidc: IdP token verifier (default: the global one, if configured)
+            oidc_paths: Path prefixes accepting IdP tokens
+            require_token: Require a token on every request, not only on
+                those the policy requires scopes for
+            policy_store: RBAC policy (default: the global one)
+            certificates: Client certificate identities (default: the global ones)
+        """
+        super().__init__(app)
+        self.verifier = verifier
+        self.exempt_paths = tuple(path for path in exempt_paths if path)
+        self.oidc = oidc
+        self.oidc_paths = tuple(path for path in oidc_paths if path)
+        self.require_token = require_token
+        self.policy_store = policy_store
+        self.certificates = certificates
+
+    async def _authenticate(self, request: Request, token: str) -> Principal:
+        oidc = self.oidc or get_oidc_verifier()
+        if oidc is not None and request.url.path.startswith(self.oidc_paths) and oidc.handles(token):
+            return await oidc.verify(token)
+        verifier = self.verifier or get_token_verifier()
+        # The denylist may be a blocking Redis call
+        return await run_in_threadpool(verifier.verify, token)
+
+    async def dispatch(self, request: Request, call_next):
+        path = request.url.path
+        if request.method == "OPTIONS" or path.startswith(self.exempt_paths):
+            return await call_next(request)
+
+        store = self.policy_store or get_policy_store()
+        policy = store.policy
+        if store.path and policy is None:
+            # Never serve a deployment meant to be restricted without its policy
+            return _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", "RBAC policy not loaded")
+        rule, required = policy.required_scopes(request.method, path) if policy else (None, None)
+        if not self.require_token and not required:
+            return await call_next(request)
+
+        authorization = request.headers.get("authorization")
+        scheme, _, token = (authorization or "").partition(" ")
+        token = token.strip()
+        try:
+            if authorization is None and (request.scope.get("extensions") or {}).get(SCOPE_EXTENSION):
+                principal = (self.certificates or get_client_certificate_mapper()).from_scope(request.scope)
+                if principal is None:
+                    raise AuthenticationError("Client certificate is not mapped to a tenant")
+            elif scheme.lower() != "bearer" or not token:
+                raise AuthenticationError("Bearer token required")
+            else:
+                principal = await self._authenticate(request, token)
+        except AuthenticationError as e:
+            return _error(
+                status.HTTP_401_UNAUTHORIZED, "E006", str(e), headers={"WWW-Authenticate": "Bearer"}
+            )
+        except RuntimeError as e:
+            logger.error(f"Cannot authenticate request to {path}: {e}")
+            return _error(status.HTTP_503_SERVICE_UNAVAILABLE, "E003", str(e))
+
+        if required and not any(principal.has_scope(scope) for scope in required):
+            logger.info(
+                f"RBAC denied {request.method} {path} to {principal.subject}"
+                f" (rule {rule.rule_id if rule else 'default'})"
+            )
+            return _error(
+                status.HTTP_403_FORBIDDEN,
+                "E010",
+                f"Token lacks the '{' or '.join(required)}' scope",
+            )
+
+        request.state.principal = principal
+        return await call_next(request)
@@ -0,0 +1,45 @@
+"""Billing metering of tenant requests (see src.services.billing_service)."""
+import logging
+from typing import Callable, Iterable
+
+from fastapi import Request
+from starlette.middleware.base import BaseHTTPMiddleware
+
+from src.services.billing_service import BillingMeter, billable, get_billing_meter
+
+logger = logging.getLogger(__name__)
+
+
+class BillingMiddleware(BaseHTTPMiddleware):
+    """Counts each billable request of a tenant.
+
+    The tenant is the principal put in `request.state.principal` by
+    authentication, so rejected and anonymous requests are not billed.
+    """
+
+    def __init__(
+        self,
+        app,
+        exempt_paths: Iterable[str] = (),
+        meter_factory: Callable[[], BillingMeter] = get_billing_meter,
+    ):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            exempt_paths: Path prefixes never billed (admin API, token exchange)
+            meter_factory: Returns the billing meter
+        """
+        super().__init__(app)
+        self.exempt_paths = tuple(path for path in exempt_paths if path)
+        self.meter_factory = meter_factory
+
+    async def dispatch(self, request: Request, call_next):
+        # Create the state now, so that it is shared with the route however the scope is copied
+        if getattr(request.state, "principal", None) is None:
+            request.state.principal = None
+        response = await call_next(request)
+        principal = request.state.principal
+        if principal is not None and billable(request.url.path, response.status_code, self.exempt_paths):
+            self.meter_factory().record(principal.tenant_id)
+        return response
@@ -0,0 +1,202 @@
+"""Content-Encoding negotiation (br, gzip) for HTTP responses.
+
+Responses are compressed with the best encoding the client accepts
+(Accept-Encoding q-values, then server preference) once their body reaches
+a minimum size. Streamed bodies are compressed chunk by chunk. Responses
+are left alone when:
+
+- they are Server-Sent Events (each event must reach the client at once),
+- they already have a Content-Encoding,
+- their path starts with one of the exempt prefixes (per-route opt-out).
+
+Strong ETags are weakened on compressed responses (the bytes differ from
+the identity representation), which conditional requests still match.
+"""
+import logging
+import zlib
+from typing import Iterable, Optional, Tuple
+
+from starlette.datastructures import Headers, MutableHeaders
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+try:
+    import brotli
+except ImportError:  # pragma: no cover - exercised when brotli is not installed
+    brotli = None
+
+logger = logging.getLogger(__name__)
+
+SUPPORTED_ENCODINGS = ("br", "gzip")
+UNCOMPRESSED_TYPES = ("text/event-stream",)
+
+
+def parse_encodings(value: str) -> Tuple[str, ...]:
+    """Encodings to offer, in preference order (br dropped without the brotli package).
+
+    Raises:
+        ValueError: For an encoding other than br or gzip
+    """
+    encodings = []
+    for name in (part.strip().lower() for part in value.split(",")):
+        if not name:
+            continue
+        if name not in SUPPORTED_ENCODINGS:
+            raise ValueError(f"Unsupported compression encoding: {name}")
+        if name == "br" and brotli is None:
+            logger.warning("br compression requires the optional 'brotli' extra; offering gzip only")
+            continue
+        if name not in encodings:
+            encodings.append(name)
+    return tuple(encodings)
+
+
+def negotiate(accept_encoding: str, offered: Iterable[str]) -> Optional[str]:
+    """Best offered encoding acceptable to the client, None for identity."""
+    weights = {}
+    for item in accept_encoding.split(","):
+        name, _, params = item.strip().partition(";")
+        name = name.strip().lower()
+        if not name:
+            continue
+        q = 1.0
+        for param in params.split(";"):
+            key, _, value = param.strip().partition("=")
+            if key.lower() == "q":
+                try:
+                    q = float(value)
+                except ValueError:
+                    q = 0.0
+        weights[name] = q
+    best, best_q = None, 0.0
+    for name in offered:
+        q = weights.get(name, weights.get("*", 0.0))
+        if q > best_q:
+            best, best_q = name, q
+    return best
+
+
+class _Compressor:
+    """Incremental compressor of one response body."""
+
+    def __init__(self, encoding: str, level: int):
+        if encoding == "br":
+            self._brotli = brotli.Compressor(quality=min(level, 11))
+            self._zlib = None
+        else:
+            self._brotli = None
+            self._zlib = zlib.compressobj(level, zlib.DEFLATED, 16 + zlib.MAX_WBITS)
+
+    def compress(self, data: bytes, final: bool) -> bytes:
+        if self._brotli is not None:
+            out = self._brotli.process(data)
+            return out + (self._brotli.finish() if final else self._brotli.flush())
+        out = self._zlib.compress(data)
+        return out + self._zlib.flush(zlib.Z_FINISH if final else zlib.Z_SYNC_FLUSH)
+
+
+class CompressionMiddleware:
+    """Compresses response bodies with the best encoding the client accepts.
+
+    A plain ASGI middleware rather than a BaseHTTPMiddleware, so streamed
+    responses (batch results, SSE) are not buffered.
+    """
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        encodings: Iterable[str] = ("gzip",),
+        minimum_size: int = 1024,
+        level: int = 6,
+        exempt_paths: Iterable[str] = (),
+    ):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            encodings: Encodings offered, in preference order (br, gzip)
+            minimum_size: Smallest body (bytes) worth compressing
+            level: Compression level (gzip 1-9; br quality, capped at 11)
+            exempt_paths: Path prefixes never compressed
+        """
+        self.app = app
+        self.encodings = tuple(encodings)
+        self.minimum_size = minimum_size
+        self.level = level
+        self.exempt_paths = tuple(path for path in exempt_paths if path)
+
+    def exempt(self, path: str) -> bool:
+        """Whether a path opts out of compression."""
+        return any(path.startswith(prefix) for prefix in self.exempt_paths)
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send) -> None:
+        if scope["type"] != "http" or self.exempt(scope["path"]):
+            await self.app(scope, receive, send)
+            return
+        encoding = negotiate(Headers(scope=scope).get("accept-encoding", ""), self.encodings)
+        if encoding is None:
+            await self.app(scope, receive, send)
+            return
+        await _CompressingSend(self, encoding, send).run(scope, receive)
+
+
+class _CompressingSend:
+    """send() wrapper deciding, at the first body chunk, whether to compress."""
+
+    def __init__(self, middleware: CompressionMiddleware, encoding: str, send: Send):
+        self.middleware = middleware
+        self.encoding = encoding
+        self.send = send
+        self.start: Optional[Message] = None
+        self.compressor: Optional[_Compressor] = None
+        self.passthrough = False
+
+    async def run(self, scope: Scope, receive: Receive) -> None:
+        await self.middleware.app(scope, receive, self)
+
+    def _compressible(self, headers: Headers, body: bytes, more_body: bool) -> bool:
+        if "content-encoding" in headers:
+            return False
+        if headers.get("content-type", "").split(";")[0].strip() in UNCOMPRESSED_TYPES:
+            return False
+        # A body sent in one piece is only compressed from the minimum size on
+        return more_body or len(body) >= self.middleware.minimum_size
+
+    async def __call__(self, message: Message) -> None:
+        if message["type"] == "http.response.start":
+            self.start = message
+            return
+        if message["type"] != "http.response.body" or self.passthrough:
+            await self.send(message)
+            return
+
+        body = message.get("body", b"")
+        more_body = message.get("more_body", False)
+        if self.start is not None:
+            start, self.start = self.start, None
+            headers = MutableHeaders(raw=start["headers"])
+            if not self._compressible(headers, body, more_body):
+                self.passthrough = True
+                await self.send(start)
+                await self.send(message)
+                return
+            self.compressor = _Compressor(self.encoding, self.middleware.level)
+            headers["Content-Encoding"] = self.encoding
+            headers.add_vary_header("Accept-Encoding")
+            etag = headers.get("etag")
+            if etag is not None and not etag.startswith("W/"):
+                headers["ETag"] = f"W/{etag}"
+            if "content-length" in headers:
+                del headers["content-length"]
+            if not more_body:
+                compressed = self.compressor.compress(body, final=True)
+                headers["Content-Length"] = str(len(compressed))
+                await self.send(start)
+                await self.send({"type": "http.response.body", "body": compressed})
+                return
+            await self.send(start)
+
+        await self.send({
+            "type": "http.response.body",
+            "body": self.compressor.compress(body, final=not more_body),
+            "more_body": more_body,
+        })
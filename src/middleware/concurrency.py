@@ -0,0 +1,98 @@
+"""Concurrency limiting and load shedding (see src.services.concurrency_service).
+
+Runs outside every other check, so that a shed request costs one counter
+update. A request holds its slot until its response has been sent, and
+requests beyond the global or their endpoint's budget get 503 (E016)
+with Retry-After straight away rather than queueing. Near saturation the
+caller's priority class is the `plan` claim of its API key token, with
+the signature checked (so a token cannot claim a premium plan) but not
+the denylist.
+"""
+import logging
+from typing import Callable, Iterable, Optional
+
+from fastapi import Request, status
+from fastapi.responses import JSONResponse
+from starlette.types import ASGIApp, Receive, Scope, Send
+
+from src.metrics import REQUESTS_IN_FLIGHT, REQUESTS_SHED
+from src.middleware.quota import metered_principal
+from src.middleware.usage import route_template
+from src.services.auth_service import TokenVerifier, get_token_verifier
+from src.services.concurrency_service import ConcurrencyLimiter, get_concurrency_limiter
+
+logger = logging.getLogger(__name__)
+
+
+class ConcurrencyMiddleware:
+    """Sheds requests beyond the concurrency budgets of the process."""
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        exempt_paths: Iterable[str] = (),
+        retry_after_seconds: int = 1,
+        limiter_factory: Callable[[], ConcurrencyLimiter] = get_concurrency_limiter,
+        verifier: Optional[TokenVerifier] = None,
+    ):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            exempt_paths: Path prefixes never limited (health checks, metrics)
+            retry_after_seconds: Retry-After of shed requests
+            limiter_factory: Returns the concurrency limiter
+            verifier: Token verifier (default: the global one)
+        """
+        self.app = app
+        self.exempt_paths = tuple(path for path in exempt_paths if path)
+        self.retry_after_seconds = max(1, retry_after_seconds)
+        self.limiter_factory = limiter_factory
+        self.verifier = verifier
+
+    def priority(self, scope: Scope) -> Optional[str]:
+        """Priority class of a request: the quota plan of its API key, if any."""
+        principal = metered_principal(scope, self.verifier or get_token_verifier())
+        return principal.quota_plan if principal is not None else None
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send) -> None:
+        if scope["type"] != "http" or scope["path"].startswith(self.exempt_paths):
+            await self.app(scope, receive, send)
+            return
+        limiter = self.limiter_factory()
+        # Resolving the route costs a scan of the routes, only done when endpoints have budgets
+        endpoint = route_template(Request(scope)) if limiter.needs_endpoint() else ""
+        # Checking the token costs a signature verification, only done once classes matter
+        decision = limiter.acquire(endpoint, self.priority(scope) if limiter.contended() else None)
+        if not decision.allowed:
+            REQUESTS_SHED.labels(budget=decision.budget).inc()
+            # Debug only: under overload a line per shed request would add to the load
+            logger.debug(
+                f"Shed {scope['method']} {scope['path']}: {decision.in_flight} requests in flight "
+                f"under the {decision.budget} budget of {decision.limit}"
+            )
+            response = JSONResponse(
+                status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+                content={
+                    "detail": {
+                        "error_code": "E016",
+                        "error_message": "Server overloaded; try again later",
+                        "details": {
+                            "budget": decision.budget,
+                            "limit": decision.limit,
+                            "retry_after": self.retry_after_seconds,
+                        },
+                    }
+                },
+                headers={"Retry-After": str(self.retry_after_seconds)},
+            )
+            await response(scope, receive, send)
+            return
+
+        REQUESTS_IN_FLIGHT.inc()
+        try:
+            await self.app(scope, receive, send)
+        finally:
+            # Also when the client went away or the route failed, or the slot would be lost for good
+            REQUESTS_IN_FLIGHT.dec()
+            limiter.release(endpoint)
@@ -0,0 +1,73 @@
+"""Network allowlists of API keys for requests made with their tokens
+(see src.services.ip_allowlist_service).
+
+Access tokens issued from a restricted key list its networks in their
+`cidrs` claim. This middleware reads the claim without verifying the
+token and refuses requests from other addresses (403, E011) before any
+authentication work is done: no signature check, denylist lookup or
+database query. A token altered to drop the claim still fails
+verification afterwards.
+"""
+import logging
+from urllib.parse import parse_qs
+
+from fastapi import status
+from fastapi.responses import JSONResponse
+from starlette.types import ASGIApp, Receive, Scope, Send
+
+from src.services.ip_allowlist_service import SourceNetworkError, address_allowed, client_address, token_cidrs
+
+logger = logging.getLogger(__name__)
+
+
+def bearer_token(scope: Scope) -> str:
+    """Token of the Authorization header, or of the `token` query parameter
+    (used by the event stream and WebSocket routes)."""
+    for name, value in scope.get("headers") or ():
+        if name == b"authorization":
+            scheme, _, token = value.decode("latin-1").partition(" ")
+            return token.strip() if scheme.lower() == "bearer" else ""
+    query = parse_qs((scope.get("query_string") or b"").decode("latin-1"))
+    return (query.get("token") or [""])[0].strip()
+
+
+class IpAllowlistMiddleware:
+    """Refuses tokens of network-restricted API keys from other addresses."""
+
+    def __init__(self, app: ASGIApp, client_ip_header: str = ""):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            client_ip_header: Header carrying the client address behind a
+                trusted proxy; its rightmost entry is used
+        """
+        self.app = app
+        self.client_ip_header = client_ip_header
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send) -> None:
+        if scope["type"] not in ("http", "websocket"):
+            await self.app(scope, receive, send)
+            return
+        token = bearer_token(scope)
+        cidrs = token_cidrs(token) if token else ()
+        client_ip = client_address(scope, self.client_ip_header)
+        if address_allowed(client_ip, cidrs):
+            await self.app(scope, receive, send)
+            return
+
+        logger.warning(f"Refused token of a network-restricted API key from {client_ip} ({scope.get('path')})")
+        if scope["type"] == "websocket":
+            await send({"type": "websocket.close", "code": 1008})
+            return
+        response = JSONResponse(
+            status_code=status.HTTP_403_FORBIDDEN,
+            content={
+                "detail": {
+                    "error_code": "E011",
+                    "error_message": str(SourceNetworkError(client_ip)),
+                    "details": {"client_ip": client_ip},
+                }
+            },
+        )
+        await response(scope, receive, send)
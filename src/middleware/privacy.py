@@ -0,0 +1,186 @@
+"""Privacy mode for requests made with tokens of privacy-mode API keys
+(see src.services.privacy_service).
+
+JSON responses (application/json, application/geo+json and NDJSON job
+results) are rewritten with their coordinates truncated and street-level
+fields omitted; their ETags become weak, as the bytes differ from those
+the route tagged. Responses in formats that cannot be rewritten get 403
+(E012) instead, and WebSocket connections are closed (1008). While the
+request is handled, log records have their IP addresses redacted.
+"""
+import json
+import logging
+from typing import Optional
+
+from fastapi import status
+from fastapi.responses import JSONResponse
+from starlette.datastructures import MutableHeaders
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from src.middleware.ip_allowlist import bearer_token
+from src.services.privacy_service import (
+    PrivacyModeError,
+    enter_privacy_mode,
+    install_log_redaction,
+    leave_privacy_mode,
+    redact_content,
+    token_precision,
+)
+
+logger = logging.getLogger(__name__)
+
+JSON_TYPES = ("application/json", "application/geo+json", "application/problem+json")
+NDJSON_TYPE = "application/x-ndjson"
+# Formats that may carry coordinates but cannot be rewritten
+REFUSED_TYPES = (
+    "application/x-protobuf",
+    "application/vnd.mapbox-vector-tile",
+    "text/event-stream",
+    "application/xml",
+    "text/xml",
+)
+
+
+def _media_type(headers: MutableHeaders) -> str:
+    return headers.get("content-type", "").split(";", 1)[0].strip().lower()
+
+
+class _ResponseRewriter:
+    """Rewrites one HTTP response as it is sent."""
+
+    def __init__(self, send: Send, precision: int, scope: Scope, receive: Receive):
+        self.send = send
+        self.precision = precision
+        self.scope = scope
+        self.receive = receive
+        self.start: Optional[Message] = None
+        self.mode = "pass"
+        self.body = bytearray()
+
+    async def __call__(self, message: Message) -> None:
+        if message["type"] == "http.response.start":
+            headers = MutableHeaders(raw=list(message.get("headers", [])))
+            media_type = _media_type(headers)
+            if media_type in JSON_TYPES:
+                self.mode = "json"
+            elif media_type == NDJSON_TYPE:
+                self.mode = "ndjson"
+            elif media_type in REFUSED_TYPES:
+                self.mode = "refuse"
+            if self.mode == "pass":
+                await self.send(message)
+            elif self.mode == "refuse":
+                # At once: event streams never finish their body
+                await self._refuse()
+            else:
+                self._hold(message, headers)
+            return
+
+        if message["type"] != "http.response.body" or self.mode == "pass":
+            await self.send(message)
+            return
+        if self.mode == "refuse":
+            return  # The refusal was sent instead
+        more_body = message.get("more_body", False)
+        self.body.extend(message.get("body", b""))
+        if self.mode == "ndjson":
+            await self._send_lines(more_body)
+        elif not more_body:
+            await self._send_json()
+
+    def _hold(self, message: Message, headers: MutableHeaders) -> None:
+        """Keep the response start until the rewritten body's length is known."""
+        if "content-length" in headers:
+            del headers["content-length"]
+        etag = headers.get("etag")
+        if etag is not None and not etag.startswith("W/"):
+            headers["etag"] = f"W/{etag}"
+        self.start = {**message, "headers": headers.raw}
+
+    async def _send_json(self) -> None:
+        body = bytes(self.body)
+        if body:
+            try:
+                content = json.loads(body)
+            except ValueError:
+                await self._refuse()
+                return
+            body = json.dumps(
+                redact_content(content, self.precision), ensure_ascii=False, separators=(",", ":")
+            ).encode()
+        headers = MutableHeaders(raw=self.start["headers"])
+        if self.start.get("status") != status.HTTP_304_NOT_MODIFIED:
+            headers["content-length"] = str(len(body))
+        await self.send({**self.start, "headers": headers.raw})
+        await self.send({"type": "http.response.body", "body": body})
+
+    async def _send_lines(self, more_body: bool) -> None:
+        if self.start is not None:
+            await self.send(self.start)  # Streamed line by line, without a length
+            self.start = None
+        data = bytes(self.body)
+        if more_body:
+            complete, newline, rest = data.rpartition(b"\n")
+            if not newline:
+                return  # No whole line yet
+        else:
+            complete, rest = data, b""
+        lines = []
+        for line in complete.split(b"\n"):
+            if line.strip():
+                try:
+                    content = redact_content(json.loads(line), self.precision)
+                except ValueError:
+                    content = {}
+                line = json.dumps(content, ensure_ascii=False, separators=(",", ":")).encode()
+            lines.append(line)
+        self.body = bytearray(rest)
+        chunk = b"\n".join(lines) + (b"\n" if more_body else b"")
+        await self.send({"type": "http.response.body", "body": chunk, "more_body": more_body})
+
+    async def _refuse(self) -> None:
+        logger.info(f"Refused response of {self.scope.get('path')} in privacy mode")
+        response = JSONResponse(
+            status_code=status.HTTP_403_FORBIDDEN,
+            content={
+                "detail": {
+                    "error_code": "E012",
+                    "error_message": str(PrivacyModeError()),
+                    "details": None,
+                }
+            },
+        )
+        await response(self.scope, self.receive, self.send)
+
+
+class PrivacyMiddleware:
+    """Applies the privacy mode of API keys to requests made with their tokens."""
+
+    def __init__(self, app: ASGIApp):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+        """
+        self.app = app
+        install_log_redaction()
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send) -> None:
+        if scope["type"] not in ("http", "websocket"):
+            await self.app(scope, receive, send)
+            return
+        token = bearer_token(scope)
+        precision = token_precision(token) if token else None
+        if precision is None:
+            await self.app(scope, receive, send)
+            return
+
+        if scope["type"] == "websocket":
+            logger.info(f"Refused WebSocket of a privacy-mode API key ({scope.get('path')})")
+            await send({"type": "websocket.close", "code": 1008})
+            return
+        mode = enter_privacy_mode(precision)
+        try:
+            await self.app(scope, receive, _ResponseRewriter(send, precision, scope, receive))
+        finally:
+            leave_privacy_mode(mode)
@@ -0,0 +1,152 @@
+"""Monthly quotas of API keys on quota plans (see src.services.quota_service).
+
+Requests are counted against the plan named by their bearer token's
+`plan` claim, with the signature checked (so a token cannot claim another
+plan) but not the denylist. Tokens without the claim are not metered.
+Responses of metered requests carry X-Quota-Plan, X-Quota-Limit,
+X-Quota-Remaining and X-Quota-Reset headers, and X-Quota-Warning near a
+cap; requests beyond a cap get 429 (E015) with Retry-After and the
+RateLimit-* headers of the cap. Requests crossing an alert threshold
+alert the key's tenant (see src.services.quota_alert_service).
+"""
+import logging
+from typing import Callable, List, Optional
+
+from fastapi import Request, status
+from fastapi.responses import JSONResponse
+from sqlalchemy.orm import Session
+from starlette.datastructures import MutableHeaders
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from src.metrics import QUOTA_REQUESTS_REFUSED
+from src.middleware.ip_allowlist import bearer_token
+from src.middleware.usage import route_template
+from src.services.auth_service import AuthenticationError, Principal, TokenVerifier, get_token_verifier
+from src.services.quota_alert_service import notify
+from src.services.quota_service import QuotaAlert, QuotaEnforcer, get_quota_enforcer
+from src.services.rate_limit_service import rate_limit_headers
+from src.services.usage_service import api_key_of
+
+logger = logging.getLogger(__name__)
+
+
+def metered_principal(scope: Scope, verifier: TokenVerifier) -> Optional[Principal]:
+    """Principal of a request's token, if it is an API key token on a plan."""
+    token = bearer_token(scope)
+    if not token:
+        return None
+    try:
+        principal = verifier.verify(token, check_revocation=False)
+    except (AuthenticationError, RuntimeError):
+        return None
+    if principal.quota_plan is None or api_key_of(principal.subject) is None:
+        return None
+    return principal
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+def send_alerts(
+    session_factory: Callable[[], Session], tenant_id: str, alerts: List[QuotaAlert], enforcer: QuotaEnforcer
+) -> None:
+    """Alert a tenant of the thresholds its request crossed; failures are only logged."""
+    try:
+        session = session_factory()
+    except Exception as e:
+        logger.error(f"Quota alerts of tenant {tenant_id} lost: {e}")
+        return
+    try:
+        for alert in alerts:
+            notify(session, tenant_id, alert, enforcer.alert_thresholds)
+    except Exception as e:
+        # Alerting must never fail the request that crossed the threshold
+        logger.error(f"Quota alerts of tenant {tenant_id} lost: {e}")
+    finally:
+        session.close()
+
+
+class QuotaMiddleware:
+    """Refuses requests of API keys beyond their plans' monthly caps."""
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        enforcer_factory: Callable[[], QuotaEnforcer] = get_quota_enforcer,
+        verifier: Optional[TokenVerifier] = None,
+        session_factory: Callable[[], Session] = _default_session,
+    ):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            enforcer_factory: Returns the quota enforcer
+            verifier: Token verifier (default: the global one)
+            session_factory: Creates database sessions for threshold alerts
+        """
+        self.app = app
+        self.enforcer_factory = enforcer_factory
+        self.verifier = verifier
+        self.session_factory = session_factory
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send) -> None:
+        if scope["type"] != "http":
+            await self.app(scope, receive, send)
+            return
+        principal = metered_principal(scope, self.verifier or get_token_verifier())
+        if principal is None:
+            await self.app(scope, receive, send)
+            return
+        key_id = api_key_of(principal.subject)
+        enforcer = self.enforcer_factory()
+        # Resolving the route costs a scan of the routes, only done for plans that need it
+        endpoint = route_template(Request(scope)) if enforcer.needs_endpoint(principal.quota_plan) else ""
+        try:
+            decision = enforcer.count(key_id, principal.quota_plan, endpoint)
+        except Exception as e:
+            # An unreachable counter store must not take the API down with it
+            logger.error(f"Quota not applied to API key {key_id}: {e}")
+            decision = None
+        if decision is None:
+            await self.app(scope, receive, send)
+            return
+        if decision.alerts:
+            send_alerts(self.session_factory, principal.tenant_id, decision.alerts, enforcer)
+
+        headers = decision.headers()
+        blocking = decision.blocking
+        if blocking is not None:
+            QUOTA_REQUESTS_REFUSED.labels(plan=decision.plan).inc()
+            logger.debug(f"Refused request of API key {key_id} over its {blocking.scope} quota")
+            retry_after = max(1, decision.reset_seconds)
+            response = JSONResponse(
+                status_code=status.HTTP_429_TOO_MANY_REQUESTS,
+                content={
+                    "detail": {
+                        "error_code": "E015",
+                        "error_message": "Quota exceeded",
+                        "details": {
+                            "plan": decision.plan,
+                            "quota": blocking.scope,
+                            "limit": blocking.limit,
+                            "retry_after": retry_after,
+                        },
+                    }
+                },
+                headers={
+                    **headers,
+                    **rate_limit_headers(blocking.limit, 0, retry_after, retry_after),
+                },
+            )
+            await response(scope, receive, send)
+            return
+
+        async def send_with_headers(message: Message) -> None:
+            if message["type"] == "http.response.start":
+                MutableHeaders(scope=message).update(headers)
+            await send(message)
+
+        await self.app(scope, receive, send_with_headers)
@@ -0,0 +1,105 @@
+"""Rate limiting of HTTP requests (see src.services.rate_limit_service).
+
+Runs before authentication and every other check, so refused requests
+cost one limiter check. The caller's bucket is chosen from the bearer
+token's tenant and subject, with the signature checked (so callers cannot
+spend each other's tokens) but not the denylist; requests without a
+valid token share the bucket of their client address. Responses carry
+RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and
+RateLimit-Policy headers; refused requests get 429 (E014) and Retry-After.
+A 429 of a limit further in (lockouts, quotas) keeps the headers of that
+limit, so that clients back off for the limit that refused them.
+"""
+import logging
+from typing import Callable, Iterable, Optional
+
+from fastapi import status
+from fastapi.responses import JSONResponse
+from starlette.datastructures import MutableHeaders
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from src.middleware.ip_allowlist import bearer_token
+from src.services.auth_service import AuthenticationError, TokenVerifier, get_token_verifier
+from src.services.ip_allowlist_service import client_address
+from src.services.rate_limit_service import RateLimiter, get_rate_limiter
+
+logger = logging.getLogger(__name__)
+
+
+def caller_key(scope: Scope, verifier: TokenVerifier, client_ip_header: str = "") -> str:
+    """Bucket of a request: its token's tenant and subject, else its client address."""
+    token = bearer_token(scope)
+    if token:
+        try:
+            principal = verifier.verify(token, check_revocation=False)
+            return f"token:{principal.tenant_id}:{principal.subject}"
+        except (AuthenticationError, RuntimeError):
+            pass
+    return f"ip:{client_address(scope, client_ip_header)}"
+
+
+class RateLimitMiddleware:
+    """Refuses requests of callers over their rate limit."""
+
+    def __init__(
+        self,
+        app: ASGIApp,
+        exempt_paths: Iterable[str] = (),
+        client_ip_header: str = "",
+        limiter_factory: Callable[[], RateLimiter] = get_rate_limiter,
+        verifier: Optional[TokenVerifier] = None,
+    ):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            exempt_paths: Path prefixes never limited (health checks, metrics)
+            client_ip_header: Header carrying the client address behind a
+                trusted proxy; its rightmost entry is used
+            limiter_factory: Returns the rate limiter
+            verifier: Token verifier (default: the global one)
+        """
+        self.app = app
+        self.exempt_paths = tuple(path for path in exempt_paths if path)
+        self.client_ip_header = client_ip_header
+        self.limiter_factory = limiter_factory
+        self.verifier = verifier
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send) -> None:
+        if scope["type"] != "http" or scope["path"].startswith(self.exempt_paths):
+            await self.app(scope, receive, send)
+            return
+        limiter = self.limiter_factory()
+        key = caller_key(scope, self.verifier or get_token_verifier(), self.client_ip_header)
+        try:
+            decision = limiter.check(key)
+        except Exception as e:
+            # An unreachable bucket store must not take the API down with it
+            logger.error(f"Rate limit not applied to {key}: {e}")
+            await self.app(scope, receive, send)
+            return
+        headers = {**decision.headers(), "RateLimit-Policy": limiter.policy}
+        if not decision.allowed:
+            logger.debug(f"Rate limited {key} ({scope['method']} {scope['path']})")
+            response = JSONResponse(
+                status_code=status.HTTP_429_TOO_MANY_REQUESTS,
+                content={
+                    "detail": {
+                        "error_code": "E014",
+                        "error_message": "Rate limit exceeded",
+                        "details": {"retry_after": decision.retry_after, "policy": decision.policy},
+                    }
+                },
+                headers=headers,
+            )
+            await response(scope, receive, send)
+            return
+
+        async def send_with_headers(message: Message) -> None:
+            if message["type"] == "http.response.start":
+                response_headers = MutableHeaders(scope=message)
+                if "RateLimit-Limit" not in response_headers:
+                    response_headers.update(headers)
+            await send(message)
+
+        await self.app(scope, receive, send_with_headers)
@@ -0,0 +1,36 @@
+"""Body digests of signed requests (see src.services.request_signing_service).
+
+Route handlers parse request bodies (JSON, multipart uploads) before the
+signature is checked, which leaves the raw bytes unavailable to the
+check. For requests carrying X-Request-Signature this middleware hashes
+the body as the application receives it, so the check can compare the
+digest without buffering uploads.
+"""
+from starlette.types import ASGIApp, Message, Receive, Scope, Send
+
+from src.services.request_signing_service import BODY_DIGEST_EXTENSION, SIGNATURE_HEADER, BodyDigest
+
+_HEADER = SIGNATURE_HEADER.lower().encode("latin-1")
+
+
+class BodyDigestMiddleware:
+    """Records the SHA-256 of signed request bodies in the ASGI scope."""
+
+    def __init__(self, app: ASGIApp):
+        self.app = app
+
+    async def __call__(self, scope: Scope, receive: Receive, send: Send) -> None:
+        if scope["type"] != "http" or not any(name == _HEADER for name, _ in scope.get("headers", ())):
+            await self.app(scope, receive, send)
+            return
+
+        digest = BodyDigest()
+        scope = {**scope, "extensions": {**(scope.get("extensions") or {}), BODY_DIGEST_EXTENSION: digest}}
+
+        async def hashing_receive() -> Message:
+            message = await receive()
+            if message["type"] == "http.request":
+                digest.update(message.get("body", b""), message.get("more_body", False))
+            return message
+
+        await self.app(scope, hashing_receive, send)
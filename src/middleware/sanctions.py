@@ -0,0 +1,132 @@
+"""Sanctioned-country enforcement for incoming requests."""
+import logging
+from typing import Callable, Iterable, Optional
+
+from fastapi import Request, status
+from fastapi.responses import JSONResponse
+from sqlalchemy.orm import Session
+from starlette.concurrency import run_in_threadpool
+from starlette.middleware.base import BaseHTTPMiddleware
+
+from src.services.auth_service import AuthenticationError, get_token_verifier
+from src.services.sanctions_service import (
+    SanctionsDecision,
+    SanctionsScreener,
+    SanctionsService,
+    get_sanctions_screener,
+)
+
+logger = logging.getLogger(__name__)
+
+SANCTIONS_HEADER = "X-Sanctions-Match"
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+def _tenant_of(request: Request) -> Optional[str]:
+    """Tenant of a valid bearer token, None for anonymous or invalid credentials."""
+    scheme, _, token = request.headers.get("authorization", "").partition(" ")
+    if scheme.lower() != "bearer" or not token.strip():
+        return None
+    try:
+        return get_token_verifier().verify(token.strip()).tenant_id
+    except (AuthenticationError, RuntimeError):
+        # The route itself rejects bad credentials; screen with the global policy
+        return None
+
+
+class SanctionsMiddleware(BaseHTTPMiddleware):
+    """Blocks (451) or tags requests from sanctioned countries and regions.
+
+    Tagged requests carry the decision in `request.state.sanctions` and
+    the matched code in the X-Sanctions-Match response header. Blocks are
+    written to the sanctions audit trail.
+    """
+
+    def __init__(
+        self,
+        app,
+        screener: Optional[SanctionsScreener] = None,
+        session_factory: Callable[[], Session] = _default_session,
+        exempt_paths: Iterable[str] = (),
+        client_ip_header: str = "",
+    ):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            screener: Sanctions screener (default: the global one)
+            session_factory: Creates database sessions for tenant policies and audit records
+            exempt_paths: Path prefixes that are never screened (health checks, metrics)
+            client_ip_header: Header carrying the client address behind a
+                trusted proxy; its rightmost entry is used
+        """
+        super().__init__(app)
+        self.screener = screener
+        self.session_factory = session_factory
+        self.exempt_paths = tuple(path for path in exempt_paths if path)
+        self.client_ip_header = client_ip_header
+
+    def client_ip(self, request: Request) -> Optional[str]:
+        """Address the request is screened for."""
+        if self.client_ip_header:
+            forwarded = request.headers.get(self.client_ip_header, "")
+            entries = [entry.strip() for entry in forwarded.split(",") if entry.strip()]
+            if entries:
+                return entries[-1]
+        return request.client.host if request.client else None
+
+    def _screen(self, request: Request, ip: str) -> SanctionsDecision:
+        screener = self.screener or get_sanctions_screener()
+        tenant_id = _tenant_of(request)
+        session = self.session_factory()
+        try:
+            decision = screener.screen(ip, tenant_id, session)
+            if decision.blocked:
+                try:
+                    SanctionsService(session).record_block(
+                        decision,
+                        method=request.method,
+                        path=request.url.path,
+                        user_agent=request.headers.get("user-agent"),
+                    )
+                except ValueError as e:
+                    # Still refuse the request; the block is in the log
+                    logger.error(f"Sanctions audit record lost: {e}")
+            return decision
+        finally:
+            session.close()
+
+    async def dispatch(self, request: Request, call_next):
+        if request.url.path.startswith(self.exempt_paths):
+            return await call_next(request)
+        ip = self.client_ip(request)
+        if not ip:
+            return await call_next(request)
+
+        decision = await run_in_threadpool(self._screen, request, ip)
+        if decision.blocked:
+            return JSONResponse(
+                status_code=status.HTTP_451_UNAVAILABLE_FOR_LEGAL_REASONS,
+                content={
+                    "detail": {
+                        "error_code": "E007",
+                        "error_message": "Service unavailable in your region",
+                        "details": {
+                            "country_code": decision.country_code,
+                            "region_code": decision.region_code,
+                            "matched_code": decision.matched_code,
+                        },
+                    }
+                },
+            )
+
+        request.state.sanctions = decision
+        response = await call_next(request)
+        if decision.tagged:
+            response.headers[SANCTIONS_HEADER] = decision.matched_code
+        return response
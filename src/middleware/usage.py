@@ -0,0 +1,53 @@
+"""Usage counting of requests made with API key tokens (see
+src.services.usage_service)."""
+import logging
+from typing import Callable
+
+from fastapi import Request
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.routing import Match
+
+from src.services.usage_service import UsageRecorder, api_key_of, get_usage_recorder
+
+logger = logging.getLogger(__name__)
+
+
+def route_template(request: Request) -> str:
+    """Method and path template of the route serving the request, so that
+    requests for different resources count under one endpoint."""
+    for route in request.app.router.routes:
+        match, _ = route.matches(request.scope)
+        if match == Match.FULL:
+            return f"{request.method} {getattr(route, 'path', request.url.path)}"
+    return f"{request.method} (unmatched)"
+
+
+class UsageMiddleware(BaseHTTPMiddleware):
+    """Counts each request authenticated with an API key token.
+
+    The caller is the principal put in `request.state.principal` by
+    authentication, so rejected and anonymous requests are not counted.
+    """
+
+    def __init__(self, app, recorder_factory: Callable[[], UsageRecorder] = get_usage_recorder):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            recorder_factory: Returns the usage counters
+        """
+        super().__init__(app)
+        self.recorder_factory = recorder_factory
+
+    async def dispatch(self, request: Request, call_next):
+        # Create the state now, so that it is shared with the route however the scope is copied
+        if getattr(request.state, "principal", None) is None:
+            request.state.principal = None
+        response = await call_next(request)
+        principal = request.state.principal
+        key_id = api_key_of(principal.subject) if principal is not None else None
+        if key_id is not None:
+            self.recorder_factory().record(
+                principal.tenant_id, key_id, route_template(request), response.status_code
+            )
+        return response
@@ -0,0 +1,52 @@
+"""Deprecation headers for responses of deprecated API versions."""
+from typing import Iterable, Optional
+
+from fastapi import Request
+from starlette.middleware.base import BaseHTTPMiddleware
+from starlette.routing import Match
+
+from src.api.versioning import ApiVersion
+
+
+def _successor_path(request: Request, version: ApiVersion) -> Optional[str]:
+    """Path of the request in the successor version, if a route serves it there."""
+    if version.successor is None:
+        return None
+    path = f"/api/{version.successor}" + request.url.path[len(version.prefix):]
+    scope = {"type": "http", "path": path, "root_path": "", "method": request.method}
+    for route in request.app.router.routes:
+        match, _ = route.matches(scope)
+        if match == Match.FULL:
+            return path
+    return None
+
+
+class ApiVersionMiddleware(BaseHTTPMiddleware):
+    """Adds Deprecation, Sunset and successor Link headers to deprecated versions' responses."""
+
+    def __init__(self, app, versions: Iterable[ApiVersion] = ()):
+        """Initialize middleware.
+
+        Args:
+            app: ASGI application
+            versions: Served API versions; those without a schedule are left alone
+        """
+        super().__init__(app)
+        self.versions = tuple(
+            version for version in versions
+            if version.deprecated_at is not None or version.sunset_at is not None
+        )
+
+    def version_of(self, path: str) -> Optional[ApiVersion]:
+        """Deprecated version a request path belongs to."""
+        for version in self.versions:
+            if path == version.prefix or path.startswith(version.prefix + "/"):
+                return version
+        return None
+
+    async def dispatch(self, request: Request, call_next):
+        version = self.version_of(request.url.path)
+        response = await call_next(request)
+        if version is not None:
+            response.headers.update(version.headers(_successor_path(request, version)))
+        return response
@@ -0,0 +1,225 @@
+"""Idempotent schema upgrades for databases created by older releases.
+
+`Base.metadata.create_all` creates missing tables but never alters
+existing ones. Each upgrade here inspects the live schema first, so the
+whole set is safe to run on every startup (`init_db`) or by hand:
+
+    python -m src.migrations
+"""
+
+import logging
+from typing import Callable, List, Tuple
+
+from sqlalchemy import inspect, text
+from sqlalchemy.engine import Engine
+
+from src.spatial import geohash
+
+logger = logging.getLogger(__name__)
+
+
+def add_detection_geohash(engine: Engine, batch_size: int = 1000) -> bool:
+    """Add and backfill detections.geohash (and its index).
+
+    Args:
+        engine: Database engine
+        batch_size: Rows updated per transaction during the backfill
+
+    Returns:
+        True if anything was changed
+    """
+    inspector = inspect(engine)
+    if "detections" not in inspector.get_table_names():
+        return False
+
+    changed = False
+    columns = {column["name"] for column in inspector.get_columns("detections")}
+    if "geohash" not in columns:
+        with engine.begin() as connection:
+            connection.execute(text("ALTER TABLE detections ADD COLUMN geohash VARCHAR(12)"))
+        logger.info("Added detections.geohash column")
+        changed = True
+
+    indexes = {index["name"] for index in inspect(engine).get_indexes("detections")}
+    if "idx_detection_geohash" not in indexes:
+        with engine.begin() as connection:
+            connection.execute(
+                text("CREATE INDEX idx_detection_geohash ON detections (geohash)")
+            )
+        logger.info("Created idx_detection_geohash index")
+        changed = True
+
+    backfilled = backfill_detection_geohash(engine, batch_size)
+    return changed or backfilled > 0
+
+
+def backfill_detection_geohash(engine: Engine, batch_size: int = 1000) -> int:
+    """Compute geohashes for detections stored before the column existed.
+
+    Rows with missing or out-of-range coordinates are skipped.
+
+    Args:
+        engine: Database engine
+        batch_size: Rows updated per transaction
+
+    Returns:
+        Number of rows updated
+    """
+    updated = 0
+    last_id = 0
+    while True:
+        with engine.begin() as connection:
+            rows = connection.execute(
+                text(
+                    "SELECT id, calculated_lat, calculated_lon FROM detections "
+                    "WHERE geohash IS NULL AND id > :last_id ORDER BY id LIMIT :limit"
+                ),
+                {"last_id": last_id, "limit": batch_size},
+            ).fetchall()
+            if not rows:
+                break
+            last_id = rows[-1][0]
+
+            values = []
+            for row_id, latitude, longitude in rows:
+                try:
+                    value = geohash.encode(latitude, longitude, geohash.MAX_PRECISION)
+                except (TypeError, ValueError):
+                    continue
+                values.append({"id": row_id, "geohash": value})
+            if values:
+                connection.execute(
+                    text("UPDATE detections SET geohash = :geohash WHERE id = :id"),
+                    values,
+                )
+                updated += len(values)
+
+    if updated:
+        logger.info(f"Backfilled geohash for {updated} detections")
+    return updated
+
+
+def add_detection_tenant(engine: Engine) -> bool:
+    """Add detections.tenant_id (and its index).
+
+    Existing rows stay unattributed (NULL), so only anonymous callers
+    list them.
+
+    Returns:
+        True if anything was changed
+    """
+    inspector = inspect(engine)
+    if "detections" not in inspector.get_table_names():
+        return False
+
+    changed = False
+    columns = {column["name"] for column in inspector.get_columns("detections")}
+    if "tenant_id" not in columns:
+        with engine.begin() as connection:
+            connection.execute(text("ALTER TABLE detections ADD COLUMN tenant_id VARCHAR(64)"))
+        logger.info("Added detections.tenant_id column")
+        changed = True
+
+    indexes = {index["name"] for index in inspect(engine).get_indexes("detections")}
+    if "idx_detection_tenant_geohash" not in indexes:
+        with engine.begin() as connection:
+            connection.execute(
+                text("CREATE INDEX idx_detection_tenant_geohash ON detections (tenant_id, geohash)")
+            )
+        logger.info("Created idx_detection_tenant_geohash index")
+        changed = True
+    return changed
+
+
+def add_device_location_sealing(engine: Engine) -> bool:
+    """Add device_locations.sealed and data_key_id (and their index).
+
+    Existing rows stay in plaintext until re-encrypted (see
+    src.services.location_encryption_service).
+
+    Returns:
+        True if anything was changed
+    """
+    inspector = inspect(engine)
+    if "device_locations" not in inspector.get_table_names():
+        return False
+
+    changed = False
+    columns = {column["name"] for column in inspector.get_columns("device_locations")}
+    for name, sql_type in (("sealed", "TEXT"), ("data_key_id", "VARCHAR(32)")):
+        if name not in columns:
+            with engine.begin() as connection:
+                connection.execute(text(f"ALTER TABLE device_locations ADD COLUMN {name} {sql_type}"))
+            logger.info(f"Added device_locations.{name} column")
+            changed = True
+
+    indexes = {index["name"] for index in inspect(engine).get_indexes("device_locations")}
+    if "idx_device_location_data_key" not in indexes:
+        with engine.begin() as connection:
+            connection.execute(
+                text("CREATE INDEX idx_device_location_data_key ON device_locations (data_key_id)")
+            )
+        logger.info("Created idx_device_location_data_key index")
+        changed = True
+    return changed
+
+
+def add_tenant_key_settings(engine: Engine) -> bool:
+    """Add tenant_api_keys.allowed_cidrs, privacy_precision and quota_plan.
+
+    Existing keys stay unrestricted, out of privacy mode and unmetered.
+
+    Returns:
+        True if anything was changed
+    """
+    inspector = inspect(engine)
+    if "tenant_api_keys" not in inspector.get_table_names():
+        return False
+
+    changed = False
+    columns = {column["name"] for column in inspector.get_columns("tenant_api_keys")}
+    for name, definition in (
+        ("allowed_cidrs", "VARCHAR(2500) NOT NULL DEFAULT ''"),
+        ("privacy_precision", "INTEGER"),
+        ("quota_plan", "VARCHAR(32)"),
+    ):
+        if name not in columns:
+            with engine.begin() as connection:
+                connection.execute(text(f"ALTER TABLE tenant_api_keys ADD COLUMN {name} {definition}"))
+            logger.info(f"Added tenant_api_keys.{name} column")
+            changed = True
+    return changed
+
+
+# Applied in order; each must be idempotent
+UPGRADES: List[Tuple[str, Callable[[Engine], bool]]] = [
+    ("detection_geohash", add_detection_geohash),
+    ("detection_tenant", add_detection_tenant),
+    ("device_location_sealing", add_device_location_sealing),
+    ("tenant_key_settings", add_tenant_key_settings),
+]
+
+
+def upgrade_database(engine: Engine) -> List[str]:
+    """Apply all schema upgrades.
+
+    Args:
+        engine: Database engine
+
+    Returns:
+        Names of upgrades that changed the database
+    """
+    applied = []
+    for name, upgrade in UPGRADES:
+        if upgrade(engine):
+            applied.append(name)
+    return applied
+
+
+if __name__ == "__main__":
+    from src.database import get_db_manager
+
+    logging.basicConfig(level=logging.INFO)
+    manager = get_db_manager()
+    manager.create_all()
+    print(f"Applied upgrades: {', '.join(upgrade_database(manager.engine)) or 'none'}")
@@ -1,13 +1,17 @@
 """SQLAlchemy database models for Detection to COP integration."""
 from datetime import datetime
 from sqlalchemy import (
+    BigInteger,
+    Boolean,
     Column,
     Integer,
     String,
+    Text,
     Float,
     DateTime,
     JSON,
     Index,
+    event,
 )
 from sqlalchemy.orm import declarative_base
 
@@ -54,8 +58,10 @@ class Detection(Base):
     confidence_flag = Column(String(10), nullable=False)  # GREEN/YELLOW/RED
     uncertainty_radius_meters = Column(Float, nullable=False)
     calculation_method = Column(String(50), nullable=False, default="ground_plane_intersection")
+    geohash = Column(String(12), nullable=True)  # Geohash of calculated position (precision 12)
 
     # Processing metadata
+    tenant_id = Column(String(64), nullable=True)  # Submitting tenant; None for anonymous callers
     created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
 
     __table_args__ = (
@@ -63,6 +69,8 @@ class Detection(Base):
         Index("idx_created_at", "created_at"),
         Index("idx_detection_id", "detection_id"),
         Index("idx_confidence_flag", "confidence_flag"),
+        Index("idx_detection_geohash", "geohash"),
+        Index("idx_detection_tenant_geohash", "tenant_id", "geohash"),
     )
 
 
@@ -112,3 +120,674 @@ class AuditEvent(Base):
         Index("idx_audit_event_timestamp", "timestamp"),
         Index("idx_audit_event_type", "event_type"),
     )
+
+
+class AuditLogEntry(Base):
+    """Admin action or detection decision in the hash-chained audit log.
+
+    Each entry stores the hash of its predecessor (prev_hash, unique so
+    the chain cannot fork) and its own hash over that and its fields, so
+    altering or removing an entry breaks the chain from there on.
+    """
+
+    __tablename__ = "audit_log"
+
+    id = Column(Integer, primary_key=True, index=True)   # Chain order
+    entry_id = Column(String(36), unique=True, nullable=False)
+    recorded_at = Column(DateTime, nullable=False)
+    category = Column(String(16), nullable=False)        # admin, detection or security
+    action = Column(String(128), nullable=False)         # Route, e.g. rotate_tenant_key
+    actor = Column(String(255), nullable=True)           # Caller's token subject (None if anonymous)
+    tenant_id = Column(String(64), nullable=True)        # Tenant acted on or calling tenant
+    resource = Column(String(2048), nullable=True)       # Request path or decision subject
+    outcome = Column(String(16), nullable=False)         # success, denied, failed / flagged, clear
+    details = Column(Text, nullable=False)               # Canonical JSON, as hashed
+    prev_hash = Column(String(64), unique=True, nullable=False)
+    entry_hash = Column(String(64), unique=True, nullable=False)
+
+    __table_args__ = (
+        Index("idx_audit_log_recorded", "recorded_at"),
+        Index("idx_audit_log_tenant", "tenant_id", "recorded_at"),
+        Index("idx_audit_log_actor", "actor", "recorded_at"),
+    )
+
+
+@event.listens_for(AuditLogEntry, "before_update")
+@event.listens_for(AuditLogEntry, "before_delete")
+def _refuse_audit_log_change(mapper, connection, target):
+    raise ValueError("The audit log is append-only")
+
+
+class Geofence(Base):
+    """Polygon geofence with a precomputed S2 cell covering."""
+
+    __tablename__ = "geofences"
+
+    id = Column(Integer, primary_key=True, index=True)
+    geofence_id = Column(String(36), unique=True, nullable=False)
+    name = Column(String(255), nullable=False)
+    geometry = Column(JSON, nullable=False)  # GeoJSON Polygon or MultiPolygon
+    properties = Column(JSON, nullable=True)
+
+    # S2 tokens covering the polygon; interior cells lie entirely inside it
+    s2_covering = Column(JSON, nullable=False)
+    s2_interior = Column(JSON, nullable=False)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    updated_at = Column(DateTime, default=datetime.utcnow, onupdate=datetime.utcnow, nullable=False)
+
+    __table_args__ = (Index("idx_geofence_geofence_id", "geofence_id"),)
+
+
+class PoiDataset(Base):
+    """Tenant-owned point-of-interest dataset."""
+
+    __tablename__ = "poi_datasets"
+
+    id = Column(Integer, primary_key=True, index=True)
+    dataset_id = Column(String(36), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=False)
+    name = Column(String(255), nullable=False)
+    poi_count = Column(Integer, nullable=False, default=0)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    updated_at = Column(DateTime, default=datetime.utcnow, onupdate=datetime.utcnow, nullable=False)
+
+    __table_args__ = (
+        Index("idx_poi_dataset_dataset_id", "dataset_id"),
+        Index("idx_poi_dataset_tenant", "tenant_id"),
+    )
+
+
+class Poi(Base):
+    """Point of interest belonging to a PoiDataset."""
+
+    __tablename__ = "pois"
+
+    id = Column(Integer, primary_key=True, index=True)
+    dataset_id = Column(String(36), nullable=False)
+    poi_id = Column(String(255), nullable=False)  # Caller-supplied identifier
+    name = Column(String(255), nullable=True)
+    latitude = Column(Float, nullable=False)
+    longitude = Column(Float, nullable=False)
+    properties = Column(JSON, nullable=True)
+
+    __table_args__ = (
+        Index("idx_poi_dataset_poi", "dataset_id", "poi_id", unique=True),
+    )
+
+
+class IpRangeOverride(Base):
+    """Tenant-registered CIDR whose location replaces the public dataset's."""
+
+    __tablename__ = "ip_range_overrides"
+
+    id = Column(Integer, primary_key=True, index=True)
+    override_id = Column(String(36), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=False)
+    network = Column(String(43), nullable=False)  # Canonical CIDR, e.g. 10.20.0.0/16
+    label = Column(String(255), nullable=False)   # Site name, e.g. "London HQ"
+    latitude = Column(Float, nullable=False)
+    longitude = Column(Float, nullable=False)
+    accuracy_radius_km = Column(Float, nullable=True)
+    country_iso_code = Column(String(2), nullable=True)
+    city_name = Column(String(255), nullable=True)
+    properties = Column(JSON, nullable=True)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    updated_at = Column(DateTime, default=datetime.utcnow, onupdate=datetime.utcnow, nullable=False)
+
+    __table_args__ = (
+        Index("idx_ip_override_override_id", "override_id"),
+        Index("idx_ip_override_tenant_network", "tenant_id", "network", unique=True),
+    )
+
+
+class WifiAccessPoint(Base):
+    """WiFi access point with a known location, for BSSID positioning."""
+
+    __tablename__ = "wifi_access_points"
+
+    id = Column(Integer, primary_key=True, index=True)
+    bssid = Column(String(17), unique=True, nullable=False)  # Lowercase, colon-separated
+    ssid = Column(String(32), nullable=True)
+    latitude = Column(Float, nullable=False)
+    longitude = Column(Float, nullable=False)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    updated_at = Column(DateTime, default=datetime.utcnow, onupdate=datetime.utcnow, nullable=False)
+
+    __table_args__ = (
+        Index("idx_wifi_ap_bssid", "bssid"),
+    )
+
+
+class CellTower(Base):
+    """Cell tower location from an OpenCelliD extract, for cell positioning."""
+
+    __tablename__ = "cell_towers"
+
+    id = Column(Integer, primary_key=True, index=True)
+    radio = Column(String(8), nullable=False)     # GSM, UMTS, LTE, NR, CDMA
+    mcc = Column(Integer, nullable=False)
+    mnc = Column(Integer, nullable=False)         # OpenCelliD drops leading zeros
+    lac = Column(Integer, nullable=False)         # LAC / TAC
+    cell_id = Column(BigInteger, nullable=False)  # NR cell identities use 36 bits
+    latitude = Column(Float, nullable=False)
+    longitude = Column(Float, nullable=False)
+    range_m = Column(Float, nullable=True)        # Estimated coverage radius
+    samples = Column(Integer, nullable=True)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    updated_at = Column(DateTime, default=datetime.utcnow, onupdate=datetime.utcnow, nullable=False)
+
+    __table_args__ = (
+        Index("idx_cell_tower_identity", "mcc", "mnc", "lac", "cell_id", "radio", unique=True),
+    )
+
+
+class PostalArea(Base):
+    """Postal code boundary polygon, imported from a postal dataset."""
+
+    __tablename__ = "postal_areas"
+
+    id = Column(Integer, primary_key=True, index=True)
+    country_code = Column(String(2), nullable=False)   # ISO 3166-1 alpha-2
+    postal_code = Column(String(20), nullable=False)   # Normalized (upper case, single spaces)
+    name = Column(String(255), nullable=True)
+    geometry = Column(JSON, nullable=False)            # GeoJSON Polygon or MultiPolygon
+    centroid_lat = Column(Float, nullable=False)
+    centroid_lon = Column(Float, nullable=False)
+    min_lat = Column(Float, nullable=False)
+    min_lon = Column(Float, nullable=False)
+    max_lat = Column(Float, nullable=False)
+    max_lon = Column(Float, nullable=False)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    updated_at = Column(DateTime, default=datetime.utcnow, onupdate=datetime.utcnow, nullable=False)
+
+    __table_args__ = (
+        Index("idx_postal_area_code", "country_code", "postal_code", unique=True),
+        Index("idx_postal_area_bbox", "min_lat", "max_lat", "min_lon", "max_lon"),
+    )
+
+
+class LoginLocation(Base):
+    """Where a user logged in from, for impossible travel detection."""
+
+    __tablename__ = "login_locations"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), nullable=True)       # None for anonymous callers
+    user_id = Column(String(255), nullable=False)
+    observed_at = Column(DateTime, nullable=False)      # Login time (UTC)
+    latitude = Column(Float, nullable=False)
+    longitude = Column(Float, nullable=False)
+    accuracy_km = Column(Float, nullable=True)
+    ip_address = Column(String(45), nullable=True)
+    country_code = Column(String(2), nullable=True)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+
+    __table_args__ = (
+        Index("idx_login_location_user", "tenant_id", "user_id", "observed_at"),
+    )
+
+
+class DeviceLocation(Base):
+    """Where a device was seen, for device history checks."""
+
+    __tablename__ = "device_locations"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), nullable=True)       # None for anonymous callers
+    device_id = Column(String(255), nullable=False)     # Device fingerprint or identifier
+    observed_at = Column(DateTime, nullable=False)      # UTC
+    country_code = Column(String(2), nullable=True)
+    # Empty in encrypted rows, whose values are in `sealed`
+    city_name = Column(String(255), nullable=True)
+    latitude = Column(Float, nullable=True)
+    longitude = Column(Float, nullable=True)
+    accuracy_km = Column(Float, nullable=True)
+    ip_address = Column(String(45), nullable=True)
+    # Encrypted precise fields (see src.services.location_encryption_service)
+    sealed = Column(Text, nullable=True)
+    data_key_id = Column(String(32), nullable=True)     # TenantDataKey that sealed them
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+
+    __table_args__ = (
+        Index("idx_device_location_time", "tenant_id", "device_id", "observed_at"),
+        Index("idx_device_location_country", "tenant_id", "device_id", "country_code"),
+        Index("idx_device_location_data_key", "data_key_id"),
+    )
+
+
+class UserLocationProfile(Base):
+    """Trained location pattern of a user, for anomaly detection."""
+
+    __tablename__ = "user_location_profiles"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), nullable=True)       # None for anonymous callers
+    user_id = Column(String(255), nullable=False)
+    centroid_lat = Column(Float, nullable=False)
+    centroid_lon = Column(Float, nullable=False)
+    median_distance_km = Column(Float, nullable=False)  # Median login distance from the centroid
+    mad_km = Column(Float, nullable=False)              # Median absolute deviation of those distances
+    samples = Column(Integer, nullable=False)           # Logins the profile was trained on
+    trained_at = Column(DateTime, nullable=False)
+
+    __table_args__ = (
+        Index("idx_user_location_profile", "tenant_id", "user_id", unique=True),
+    )
+
+
+class Webhook(Base):
+    """Tenant endpoint that receives event notifications."""
+
+    __tablename__ = "webhooks"
+
+    id = Column(Integer, primary_key=True, index=True)
+    webhook_id = Column(String(36), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=False)
+    url = Column(String(2048), nullable=False)
+    secret = Column(String(128), nullable=False)        # HMAC-SHA256 signing key
+    events = Column(JSON, nullable=False)               # Event types, "geofence.*" or "*"
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+
+    __table_args__ = (Index("idx_webhook_tenant", "tenant_id"),)
+
+
+class WebhookDelivery(Base):
+    """One event delivered (or being delivered) to a webhook, with its outcome."""
+
+    __tablename__ = "webhook_deliveries"
+
+    id = Column(Integer, primary_key=True, index=True)
+    delivery_id = Column(String(36), unique=True, nullable=False)
+    webhook_id = Column(String(36), nullable=False)
+    tenant_id = Column(String(64), nullable=False)
+    event = Column(String(64), nullable=False)
+    body = Column(Text, nullable=False)                 # Signed JSON envelope, resent on redelivery
+    status = Column(String(16), nullable=False)         # pending, delivered or failed
+    attempts = Column(Integer, default=0, nullable=False)
+    last_status_code = Column(Integer, nullable=True)   # HTTP status of the latest answer
+    last_error = Column(String(512), nullable=True)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    last_attempt_at = Column(DateTime, nullable=True)
+    delivered_at = Column(DateTime, nullable=True)
+
+    __table_args__ = (
+        Index("idx_webhook_delivery_webhook", "tenant_id", "webhook_id"),
+    )
+
+
+class GeofenceEntityState(Base):
+    """Confirmed and pending geofence membership of a tracked entity."""
+
+    __tablename__ = "geofence_entity_states"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), nullable=False)
+    entity_id = Column(String(255), nullable=False)
+    geofence_id = Column(String(36), nullable=False)
+    inside = Column(Boolean, nullable=False, default=False)  # Confirmed state
+    entered_at = Column(DateTime, nullable=True)        # Start of the confirmed stay inside
+    dwell_alerted = Column(Boolean, nullable=False, default=False)
+    pending_inside = Column(Boolean, nullable=True)     # Observed change awaiting debounce
+    pending_since = Column(DateTime, nullable=True)
+    last_seen_at = Column(DateTime, nullable=False)
+
+    __table_args__ = (
+        Index("idx_geofence_entity_state", "tenant_id", "entity_id", "geofence_id", unique=True),
+    )
+
+
+class GeofenceAlert(Base):
+    """Geofence enter, exit or dwell event of a tracked entity."""
+
+    __tablename__ = "geofence_alerts"
+
+    id = Column(Integer, primary_key=True, index=True)
+    alert_id = Column(String(36), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=False)
+    entity_id = Column(String(255), nullable=False)
+    geofence_id = Column(String(36), nullable=False)
+    event = Column(String(16), nullable=False)          # enter, exit or dwell
+    occurred_at = Column(DateTime, nullable=False)      # When the change started (UTC)
+    latitude = Column(Float, nullable=False)            # Position that confirmed the event
+    longitude = Column(Float, nullable=False)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+
+    __table_args__ = (
+        Index("idx_geofence_alert_entity", "tenant_id", "entity_id", "occurred_at"),
+        Index("idx_geofence_alert_fence", "tenant_id", "geofence_id", "occurred_at"),
+    )
+
+
+class AccountSession(Base):
+    """Last known location of an active client session of an account."""
+
+    __tablename__ = "account_sessions"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), nullable=True)       # None for anonymous callers
+    account_id = Column(String(255), nullable=False)
+    session_id = Column(String(255), nullable=False)
+    latitude = Column(Float, nullable=False)
+    longitude = Column(Float, nullable=False)
+    accuracy_km = Column(Float, nullable=True)
+    ip_address = Column(String(45), nullable=True)
+    country_code = Column(String(2), nullable=True)
+    started_at = Column(DateTime, nullable=False)       # First activity (UTC)
+    last_seen_at = Column(DateTime, nullable=False)     # Latest activity (UTC)
+
+    __table_args__ = (
+        Index("idx_account_session", "tenant_id", "account_id", "session_id", unique=True),
+        Index("idx_account_session_seen", "tenant_id", "account_id", "last_seen_at"),
+    )
+
+
+class AccountSharingFlag(Base):
+    """Account seen in concurrent sessions too far apart for one person."""
+
+    __tablename__ = "account_sharing_flags"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), nullable=True)       # None for anonymous callers
+    account_id = Column(String(255), nullable=False)
+    session_id = Column(String(255), nullable=False)    # Session whose activity raised the flag
+    other_session_id = Column(String(255), nullable=False)
+    distance_km = Column(Float, nullable=False)         # Between the two sessions
+    speed_kmh = Column(Float, nullable=True)            # Implied speed; None if simultaneous
+    country_code = Column(String(2), nullable=True)
+    other_country_code = Column(String(2), nullable=True)
+    flagged_at = Column(DateTime, nullable=False)       # Start of the current flag (UTC)
+    last_detected_at = Column(DateTime, nullable=False)
+
+    __table_args__ = (
+        Index("idx_account_sharing_flag", "tenant_id", "account_id", unique=True),
+        Index("idx_account_sharing_flag_seen", "tenant_id", "last_detected_at"),
+    )
+
+
+class TenantSanctionsPolicy(Base):
+    """Tenant adjustments to the global sanctioned-country policy."""
+
+    __tablename__ = "tenant_sanctions_policies"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), unique=True, nullable=False)
+    action = Column(String(8), nullable=True)           # off, tag or block; None keeps SANCTIONS_MODE
+    extra_codes = Column(JSON, nullable=False)           # Countries/regions sanctioned in addition
+    exempt_codes = Column(JSON, nullable=False)          # Countries/regions not sanctioned for the tenant
+
+    updated_at = Column(DateTime, default=datetime.utcnow, onupdate=datetime.utcnow, nullable=False)
+
+
+class SanctionsAuditRecord(Base):
+    """Request blocked by sanctions enforcement."""
+
+    __tablename__ = "sanctions_audit_records"
+
+    id = Column(Integer, primary_key=True, index=True)
+    record_id = Column(String(36), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=True)       # None for anonymous callers
+    ip_address = Column(String(45), nullable=False)
+    country_code = Column(String(2), nullable=True)
+    region_code = Column(String(16), nullable=True)     # ISO 3166-2, e.g. UA-43
+    matched_code = Column(String(16), nullable=False)   # Sanctioned code that matched
+    action = Column(String(8), nullable=False)          # block
+    policy_source = Column(String(8), nullable=False)   # global or tenant
+    method = Column(String(10), nullable=True)
+    path = Column(String(2048), nullable=True)
+    user_agent = Column(String(512), nullable=True)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+
+    __table_args__ = (Index("idx_sanctions_audit_tenant", "tenant_id", "created_at"),)
+
+
+class TenantRiskArea(Base):
+    """Country, ISO 3166-2 region or polygon a tenant treats as high risk."""
+
+    __tablename__ = "tenant_risk_areas"
+
+    id = Column(Integer, primary_key=True, index=True)
+    area_id = Column(String(36), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=False)
+    kind = Column(String(8), nullable=False)     # country, region or polygon
+    code = Column(String(16), nullable=True)     # ISO code; None for polygons
+    name = Column(String(255), nullable=True)
+    geometry = Column(JSON, nullable=True)       # GeoJSON Polygon/MultiPolygon for polygons
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+
+    __table_args__ = (Index("idx_tenant_risk_area_code", "tenant_id", "code", unique=True),)
+
+
+class RuleFeedback(Base):
+    """Integrator verdict on a detection rule that fired (one row per rule reported)."""
+
+    __tablename__ = "rule_feedback"
+
+    id = Column(Integer, primary_key=True, index=True)
+    feedback_id = Column(String(36), nullable=False, index=True)  # Shared by the rules of one report
+    tenant_id = Column(String(64), nullable=False)
+    rule_id = Column(String(128), nullable=False)
+    verdict = Column(String(16), nullable=False)     # false_positive or true_positive
+    decision = Column(String(8), nullable=True)      # Reported decision: flag, review or block
+    ip_address = Column(String(45), nullable=True)
+    comment = Column(String(1000), nullable=True)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+
+    __table_args__ = (Index("idx_rule_feedback_rule", "rule_id", "created_at"),)
+
+
+class BatchJob(Base):
+    """Asynchronous batch lookup over an input file."""
+
+    __tablename__ = "batch_jobs"
+
+    id = Column(Integer, primary_key=True, index=True)
+    job_id = Column(String(36), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=False)
+    source = Column(String(1024), nullable=False)        # Input file reference (s3://, https:// or file:)
+    status = Column(String(16), nullable=False)          # queued, running, succeeded or failed
+    total_rows = Column(Integer, nullable=True)          # Known once the input is downloaded
+    processed_rows = Column(Integer, default=0, nullable=False)
+    failed_rows = Column(Integer, default=0, nullable=False)
+    error = Column(String(1000), nullable=True)          # Why the job failed
+    result_path = Column(String(1024), nullable=True)    # NDJSON results, once succeeded
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    started_at = Column(DateTime, nullable=True)
+    finished_at = Column(DateTime, nullable=True)
+
+    __table_args__ = (Index("idx_batch_job_tenant", "tenant_id", "created_at"),)
+
+
+class ErasureJob(Base):
+    """Asynchronous erasure of a data subject's records (right to erasure)."""
+
+    __tablename__ = "erasure_jobs"
+
+    id = Column(Integer, primary_key=True, index=True)
+    erasure_id = Column(String(36), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=False)
+    subject_hash = Column(String(64), nullable=False)    # The subject is never stored (see SubjectTombstone)
+    status = Column(String(16), nullable=False)          # queued, running, succeeded or failed
+    report = Column(Text, nullable=True)                 # JSON verification report, once finished
+    error = Column(String(1000), nullable=True)          # Why the erasure failed
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    started_at = Column(DateTime, nullable=True)
+    finished_at = Column(DateTime, nullable=True)
+
+    __table_args__ = (Index("idx_erasure_job_tenant", "tenant_id", "created_at"),)
+
+
+class SubjectTombstone(Base):
+    """Erased data subject whose records must not be stored again."""
+
+    __tablename__ = "subject_tombstones"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), nullable=False)
+    subject_hash = Column(String(64), nullable=False)    # SHA-256 of the tenant and subject identifier
+    erasure_id = Column(String(36), nullable=False)      # First erasure of the subject
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+
+    __table_args__ = (Index("idx_subject_tombstone", "tenant_id", "subject_hash", unique=True),)
+
+
+class Tenant(Base):
+    """Customer account created through the admin API."""
+
+    __tablename__ = "tenants"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), unique=True, nullable=False)
+    name = Column(String(255), nullable=False)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+
+
+class TenantApiKey(Base):
+    """API key a tenant exchanges for bearer tokens (only its hash is stored)."""
+
+    __tablename__ = "tenant_api_keys"
+
+    id = Column(Integer, primary_key=True, index=True)
+    key_id = Column(String(36), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=False)
+    key_hash = Column(String(64), unique=True, nullable=False)  # SHA-256 of the key
+    prefix = Column(String(16), nullable=False)                 # Leading characters, to recognise a key
+    scopes = Column(String(1000), nullable=False, default="")   # Space-separated, passed on to tokens
+    allowed_cidrs = Column(String(2500), nullable=False, default="")  # Space-separated source networks (empty: any)
+    privacy_precision = Column(Integer, nullable=True)   # Coordinate decimals in privacy mode (None: off)
+    quota_plan = Column(String(32), nullable=True)       # Monthly quota plan (None: not metered)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    rotate_after = Column(DateTime, nullable=True)       # Rotation due (TENANT_KEY_MAX_AGE_DAYS; cleared once rotated)
+    expires_at = Column(DateTime, nullable=True)         # End of validity (rotation grace period end)
+    revoked_at = Column(DateTime, nullable=True)
+    last_used_at = Column(DateTime, nullable=True)       # Last exchange for a token (minute resolution)
+
+    __table_args__ = (
+        Index("idx_tenant_api_key_tenant", "tenant_id", "created_at"),
+        Index("idx_tenant_api_key_prefix", "prefix"),
+    )
+
+
+class ApiKeyUsage(Base):
+    """Daily request count of a tenant API key per endpoint and response class."""
+
+    __tablename__ = "api_key_usage"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), nullable=False)
+    key_id = Column(String(36), nullable=False)
+    day = Column(String(10), nullable=False)             # UTC date, YYYY-MM-DD
+    endpoint = Column(String(255), nullable=False)       # Method and route, e.g. "GET /api/v1/geofences/{geofence_id}"
+    status_class = Column(String(3), nullable=False)     # 2xx, 3xx, 4xx or 5xx
+    requests = Column(BigInteger, default=0, nullable=False)
+
+    __table_args__ = (
+        Index("idx_api_key_usage", "tenant_id", "key_id", "day", "endpoint", "status_class", unique=True),
+        Index("idx_api_key_usage_tenant_day", "tenant_id", "day"),
+    )
+
+
+class BillingUsage(Base):
+    """Billable requests of a tenant in one UTC hour, and how many of them were exported."""
+
+    __tablename__ = "billing_usage"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), nullable=False)
+    hour = Column(String(13), nullable=False)            # UTC hour, YYYY-MM-DDTHH
+    requests = Column(BigInteger, default=0, nullable=False)
+    exported_requests = Column(BigInteger, default=0, nullable=False)  # Written to export files so far
+    reported_requests = Column(BigInteger, default=0, nullable=False)  # Sent to Stripe so far
+
+    __table_args__ = (
+        Index("idx_billing_usage", "tenant_id", "hour", unique=True),
+        Index("idx_billing_usage_hour", "hour"),
+    )
+
+
+class QuotaAlertPreference(Base):
+    """How a tenant is alerted as its API keys approach their quotas."""
+
+    __tablename__ = "quota_alert_preferences"
+
+    id = Column(Integer, primary_key=True, index=True)
+    tenant_id = Column(String(64), unique=True, nullable=False)
+    thresholds = Column(JSON, nullable=False)            # Percentages of a cap alerted of
+    webhook = Column(Boolean, default=True, nullable=False)  # Deliver quota.threshold webhook events
+    emails = Column(JSON, nullable=False)                # Addresses alerted by email
+
+    updated_at = Column(DateTime, default=datetime.utcnow, onupdate=datetime.utcnow, nullable=False)
+
+
+class TenantSigningKey(Base):
+    """HMAC secret a tenant signs its requests with; while one is active, unsigned requests are refused."""
+
+    __tablename__ = "tenant_signing_keys"
+
+    id = Column(Integer, primary_key=True, index=True)
+    key_id = Column(String(36), unique=True, nullable=False)   # Named by the keyId of signatures
+    tenant_id = Column(String(64), nullable=False)
+    secret = Column(String(128), nullable=False)               # HMAC-SHA256 key (verifying needs the plaintext)
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    expires_at = Column(DateTime, nullable=True)         # End of validity (rotation grace period end)
+    revoked_at = Column(DateTime, nullable=True)
+
+    __table_args__ = (Index("idx_tenant_signing_key_tenant", "tenant_id", "created_at"),)
+
+
+class TenantDataKey(Base):
+    """Data key encrypting a tenant's location history, stored wrapped by the master key."""
+
+    __tablename__ = "tenant_data_keys"
+
+    id = Column(Integer, primary_key=True, index=True)
+    key_id = Column(String(32), unique=True, nullable=False)
+    tenant_id = Column(String(64), nullable=True)       # None for anonymous callers
+    wrapped_key = Column(Text, nullable=False)           # Base64 data key encrypted by the master key
+    master_key_id = Column(String(2048), nullable=False)  # Master key (KMS key or local fingerprint) that wrapped it
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    retired_at = Column(DateTime, nullable=True)         # Replaced by a rotation; still decrypts its rows
+
+    __table_args__ = (Index("idx_tenant_data_key_tenant", "tenant_id", "created_at"),)
+
+
+class RefreshToken(Base):
+    """Refresh token of a login session; using it replaces it with a new one."""
+
+    __tablename__ = "refresh_tokens"
+
+    id = Column(Integer, primary_key=True, index=True)
+    token_id = Column(String(36), unique=True, nullable=False)
+    session_id = Column(String(36), nullable=False)      # Shared by the tokens of one session (sid)
+    tenant_id = Column(String(64), nullable=False)
+    subject = Column(String(255), nullable=False)
+    scopes = Column(String(1000), nullable=False)        # Space-separated
+    api_key_id = Column(String(36), nullable=True)       # Tenant API key the session started from
+    token_hash = Column(String(64), unique=True, nullable=False)  # SHA-256 of the token
+
+    created_at = Column(DateTime, default=datetime.utcnow, nullable=False)
+    expires_at = Column(DateTime, nullable=False)
+    used_at = Column(DateTime, nullable=True)            # Exchanged for its successor
+    revoked_at = Column(DateTime, nullable=True)
+
+    __table_args__ = (Index("idx_refresh_token_session", "session_id"),)
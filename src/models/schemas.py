@@ -1,7 +1,7 @@
 """Pydantic models for API validation and data serialization."""
 from pydantic import BaseModel, Field, field_validator, ConfigDict
 from datetime import datetime
-from typing import Optional, Dict, Any, Literal
+from typing import Optional, Dict, Any, List, Literal
 from enum import Enum
 import base64
 
@@ -192,6 +192,7 @@ class DetectionOutput(BaseModel):
     source: str = Field(..., description="Detection source/model")
     timestamp: datetime = Field(..., description="Image capture timestamp")
     processed_at: datetime = Field(default_factory=datetime.utcnow, description="Processing timestamp")
+    geohash: Optional[str] = Field(None, description="Geohash of the calculated position")
 
 
 class ErrorResponse(BaseModel):
@@ -227,3 +228,2489 @@ class APIResponse(BaseModel):
     data: Optional[Any] = None
     error: Optional[ErrorResponse] = None
     timestamp: datetime = Field(default_factory=datetime.utcnow)
+
+
+class AsnInfo(BaseModel):
+    """Autonomous system and ISP enrichment for an IP address."""
+
+    number: Optional[int] = Field(None, description="Autonomous system number")
+    organization: Optional[str] = Field(None, description="Autonomous system organization")
+    isp: Optional[str] = Field(None, description="Internet service provider")
+    connection_type: Optional[Literal["residential", "business", "cellular", "hosting"]] = Field(
+        None, description="Connection type classification"
+    )
+
+
+class AnonymizerInfo(BaseModel):
+    """Anonymizer detection flags for an IP address.
+
+    A null flag means no configured data source could check it.
+    """
+
+    vpn: Optional[bool] = Field(None, description="Address belongs to a known VPN provider")
+    tor: Optional[bool] = Field(None, description="Address is a Tor exit node")
+    proxy: Optional[bool] = Field(None, description="Address is a known public proxy")
+    residential_proxy: Optional[bool] = Field(
+        None, description="Address is a known residential proxy endpoint"
+    )
+    hosting: Optional[bool] = Field(None, description="Address belongs to a hosting/cloud provider")
+
+
+class ResidentialProxyInfo(BaseModel):
+    """Residential proxy verdict for a client session."""
+
+    session_id: str = Field(..., description="Client session identifier")
+    detected: bool = Field(..., description="Listed endpoint or ASN churn")
+    listed: Optional[bool] = Field(
+        None, description="Address is a known residential proxy endpoint (null if no list is loaded)"
+    )
+    asn_churn: bool = Field(..., description="Session used more access-network ASNs than allowed")
+    distinct_asns: int = Field(..., ge=0, description="Access-network ASNs the session used in the window")
+    distinct_ips: int = Field(..., ge=0, description="Addresses the session used in the window")
+    max_asns: int = Field(..., ge=0, description="Most ASNs allowed in the window")
+    window: str = Field(..., description="Window the counts cover (e.g., 1h)")
+
+
+class DatacenterInfo(BaseModel):
+    """Datacenter / cloud classification of an IP address."""
+
+    is_datacenter: bool = Field(..., description="Address belongs to a cloud or hosting provider")
+    provider: Optional[str] = Field(None, description="Provider (aws, gcp, azure, ... or the AS organization)")
+    source: Optional[Literal["cloud_ranges", "asn", "connection_type"]] = Field(
+        None, description="What classified the address: published ranges, a hosting ASN or the connection type"
+    )
+
+
+class DatacenterClassificationResponse(DatacenterInfo):
+    """Datacenter classification of one address, for WAF rules."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "ip_address": "3.5.140.2",
+                "is_datacenter": True,
+                "provider": "aws",
+                "source": "cloud_ranges"
+            }
+        }
+    )
+
+    ip_address: str = Field(..., description="Classified address")
+
+
+class SubdivisionLevel(BaseModel):
+    """One level of the ISO 3166-2 administrative hierarchy."""
+
+    level: int = Field(..., ge=1, description="Depth in the hierarchy (1 = top-level subdivision)")
+    iso_code: str = Field(..., description="ISO 3166-2 code (e.g., GB-ENG)")
+    name: str = Field(..., description="Subdivision name")
+    type: Optional[str] = Field(None, description="Subdivision type (state, province, county, ...)")
+
+
+class CarrierInfo(BaseModel):
+    """Mobile network operator for an IP address or MCC/MNC pair."""
+
+    mcc: str = Field(..., description="Mobile country code")
+    mnc: str = Field(..., description="Mobile network code")
+    name: Optional[str] = Field(None, description="Carrier name (null if the pair is not in the directory)")
+    country_iso_code: Optional[str] = Field(None, description="Carrier's ISO 3166-1 alpha-2 country code")
+
+
+class IpOverrideMatch(BaseModel):
+    """Tenant IP range override that answered a lookup."""
+
+    override_id: str = Field(..., description="Override identifier")
+    label: str = Field(..., description="Site label")
+    properties: Optional[Dict[str, Any]] = Field(None, description="Override metadata")
+
+
+class LocaleInfo(BaseModel):
+    """Likely locale and languages for a location."""
+
+    locale: str = Field(..., description="BCP 47 language tag with region, e.g. pt-BR")
+    languages: List[str] = Field(..., description="Language tags, most likely first")
+
+
+class CurrencyInfo(BaseModel):
+    """Local currency of a location."""
+
+    code: str = Field(..., description="ISO 4217 currency code")
+    name: Optional[str] = Field(None, description="Currency name")
+
+
+class TravelLocation(BaseModel):
+    """Location and time of one login."""
+
+    latitude: float = Field(..., ge=-90, le=90, description="Login latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Login longitude")
+    timestamp: datetime = Field(..., description="Login time (UTC)")
+    accuracy_km: Optional[float] = Field(None, ge=0, description="Location accuracy radius in km")
+    ip_address: Optional[str] = Field(None, description="Login IP address, if located from one")
+    country_code: Optional[str] = Field(None, description="ISO 3166-1 alpha-2 country code")
+
+
+class TravelAssessmentResponse(BaseModel):
+    """Impossible travel verdict for a login."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "user_id": "alice",
+                "impossible": True,
+                "distance_km": 5572.3,
+                "elapsed_seconds": 3600.0,
+                "speed_kmh": 5552.3,
+                "max_speed_kmh": 1000.0,
+                "current": {"latitude": 40.7128, "longitude": -74.006,
+                            "timestamp": "2026-03-01T10:00:00Z", "accuracy_km": 20.0,
+                            "ip_address": "203.0.113.7", "country_code": "US"},
+                "previous": {"latitude": 51.5142, "longitude": -0.0931,
+                             "timestamp": "2026-03-01T09:00:00Z", "accuracy_km": None,
+                             "ip_address": None, "country_code": "GB"}
+            }
+        }
+    )
+
+    user_id: str = Field(..., description="User identifier")
+    impossible: bool = Field(..., description="Implied speed from the previous login exceeds the threshold")
+    distance_km: Optional[float] = Field(None, ge=0, description="Distance from the previous login (null for a first login)")
+    elapsed_seconds: Optional[float] = Field(None, ge=0, description="Time since the previous login")
+    speed_kmh: Optional[float] = Field(
+        None, ge=0, description="Implied speed after subtracting accuracy radii (null if no time elapsed)"
+    )
+    max_speed_kmh: float = Field(..., description="Configured speed threshold")
+    current: TravelLocation = Field(..., description="Evaluated login")
+    previous: Optional[TravelLocation] = Field(None, description="User's previous login")
+
+
+class LocationMismatchInfo(BaseModel):
+    """Comparison of device-reported and IP-derived locations."""
+
+    distance_km: float = Field(..., ge=0, description="Distance between the device and the IP location")
+    excess_km: float = Field(..., ge=0, description="Distance beyond both accuracy radii")
+    threshold_km: float = Field(..., description="Configured mismatch threshold")
+    mismatch: bool = Field(..., description="Excess distance exceeds the threshold")
+    likely_cause: Optional[Literal["tor", "vpn", "proxy", "residential_proxy", "hosting", "gps_spoofing"]] = Field(
+        None, description="Anonymizer flag explaining the mismatch, else gps_spoofing (null if no mismatch)"
+    )
+    ip_accuracy_km: Optional[float] = Field(None, description="Accuracy radius of the IP location")
+    device_accuracy_m: Optional[float] = Field(None, description="Accuracy reported by the device")
+
+
+class LocationAnomalyInfo(BaseModel):
+    """Score of an event against the user's historical location pattern."""
+
+    user_id: str = Field(..., description="User identifier")
+    anomalous: bool = Field(..., description="Score exceeds the threshold")
+    distance_km: Optional[float] = Field(
+        None, ge=0, description="Distance from the user's location centroid (null without history)"
+    )
+    score: Optional[float] = Field(
+        None, description="Modified z-score of the distance (null without enough history)"
+    )
+    threshold: float = Field(..., description="Configured score threshold")
+    samples: int = Field(..., ge=0, description="Logins the user's profile was trained on")
+
+
+class DeviceCorrelationInfo(BaseModel):
+    """How a device sighting relates to the device's history."""
+
+    device_id: str = Field(..., description="Device identifier")
+    known_device: bool = Field(..., description="Device had been seen before")
+    country_seen_before: Optional[bool] = Field(
+        None, description="Device had been seen in this country before (null if the country is unknown)"
+    )
+    countries_seen: int = Field(..., ge=0, description="Distinct countries the device was seen in before")
+
+
+class RiskReasonInfo(BaseModel):
+    """A risk signal that fired."""
+
+    code: str = Field(..., description="Reason code (e.g., ANONYMIZER_TOR, IMPOSSIBLE_TRAVEL)")
+    signal: str = Field(..., description="Signal that fired")
+    weight: float = Field(..., ge=0, le=1, description="Configured weight of the signal")
+    detail: Optional[str] = Field(None, description="Human-readable explanation")
+
+
+class RiskScoreInfo(BaseModel):
+    """Combined risk score of a request."""
+
+    score: int = Field(..., ge=0, le=100, description="Risk score (0 no signal fired, 100 maximal)")
+    reasons: List[RiskReasonInfo] = Field(default_factory=list, description="Fired signals, highest weight first")
+
+
+class RuleMatchInfo(BaseModel):
+    """A detection rule that fired."""
+
+    rule_id: str = Field(..., description="Rule identifier")
+    action: Literal["flag", "review", "block"] = Field(..., description="Action of the rule")
+
+
+class RuleEvaluationInfo(BaseModel):
+    """Detection rules evaluated for a request."""
+
+    decision: Literal["allow", "flag", "review", "block"] = Field(
+        ..., description="Most severe action of the fired enforced rules (allow if none fired)"
+    )
+    matches: List[RuleMatchInfo] = Field(
+        default_factory=list, description="Fired enforced rules, in file order (shadow rules are never listed)"
+    )
+
+
+class IpLookupResponse(BaseModel):
+    """IP geolocation lookup result."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "ip_address": "2002:5102:458e::1",
+                "ip_version": 6,
+                "normalized_ip": "81.2.69.142",
+                "tunnel": "6to4",
+                "network": "81.2.69.128/26",
+                "country_iso_code": "GB",
+                "country_name": "United Kingdom",
+                "continent_code": "EU",
+                "city_name": "London",
+                "postal_code": "SW1A",
+                "latitude": 51.5142,
+                "longitude": -0.0931,
+                "accuracy_radius_km": 10,
+                "granularity": "postal",
+                "confidence": 0.902,
+                "confidence_flag": "GREEN",
+                "dataset_age_days": 27.3,
+                "dataset_built_at": "2026-02-10T00:00:00Z",
+                "time_zone": "Europe/London",
+                "subdivisions": [{"iso_code": "ENG", "name": "England"}],
+                "asn": {
+                    "number": 20712,
+                    "organization": "Andrews & Arnold Ltd",
+                    "isp": "Andrews & Arnold Ltd",
+                    "connection_type": "residential"
+                },
+                "anonymizer": {"vpn": False, "tor": False, "proxy": False, "hosting": False},
+                "hierarchy": [
+                    {"level": 1, "iso_code": "GB-ENG", "name": "England", "type": "country"},
+                    {"level": 2, "iso_code": "GB-LND", "name": "London, City of", "type": "city corporation"}
+                ],
+                "locale": {"locale": "en-GB", "languages": ["en-GB", "cy-GB", "gd"]},
+                "currency": {"code": "GBP", "name": "Pound"},
+                "groups": ["europe"]
+            }
+        }
+    )
+
+    ip_address: str = Field(..., description="Looked-up IP address (as supplied, canonicalized)")
+    ip_version: Literal[4, 6] = Field(..., description="IP version of the supplied address")
+    normalized_ip: Optional[str] = Field(
+        None, description="Embedded IPv4 address used for the lookup (IPv4-mapped, 6to4, Teredo)"
+    )
+    tunnel: Optional[Literal["ipv4_mapped", "6to4", "teredo"]] = Field(
+        None, description="IPv6 transition mechanism detected in the address"
+    )
+    network: str = Field(..., description="Network (CIDR) containing the address")
+    country_iso_code: Optional[str] = Field(None, description="ISO 3166-1 alpha-2 country code")
+    country_name: Optional[str] = Field(None, description="Country name")
+    continent_code: Optional[str] = Field(None, description="Continent code (e.g., EU, NA)")
+    city_name: Optional[str] = Field(None, description="City name")
+    postal_code: Optional[str] = Field(None, description="Postal code")
+    latitude: Optional[float] = Field(None, ge=-90, le=90, description="Approximate latitude")
+    longitude: Optional[float] = Field(None, ge=-180, le=180, description="Approximate longitude")
+    accuracy_radius_km: Optional[float] = Field(
+        None, ge=0, description="Estimated accuracy radius in kilometers (dataset radius, or typical for the granularity)"
+    )
+    granularity: Optional[Literal["site", "postal", "city", "subdivision", "country", "continent"]] = Field(
+        None, description="Most specific level the record resolves to (site for tenant overrides)"
+    )
+    confidence: float = Field(
+        0.0, ge=0, le=1, description="Location confidence from granularity and dataset age"
+    )
+    confidence_flag: ConfidenceFlagEnum = Field(
+        ConfidenceFlagEnum.RED, description="Confidence flag (GREEN >= 0.75, YELLOW >= 0.5)"
+    )
+    dataset_age_days: Optional[float] = Field(
+        None, ge=0, description="Age of the answering dataset in days (at as_of, for historical lookups)"
+    )
+    dataset_built_at: Optional[datetime] = Field(None, description="Build time of the answering dataset")
+    time_zone: Optional[str] = Field(None, description="IANA time zone")
+    geohash: Optional[str] = Field(
+        None, description="Geohash of the location, truncated to the accuracy radius"
+    )
+    subdivisions: List[Dict[str, Any]] = Field(default_factory=list, description="Subdivisions, largest first")
+    asn: Optional[AsnInfo] = Field(None, description="ASN/ISP enrichment (when enabled for the API key)")
+    anonymizer: Optional[AnonymizerInfo] = Field(
+        None, description="VPN/Tor/proxy/residential proxy/hosting flags (when enabled for the API key)"
+    )
+    datacenter: Optional[DatacenterInfo] = Field(
+        None, description="Cloud/hosting classification (when enabled for the API key)"
+    )
+    residential_proxy: Optional[ResidentialProxyInfo] = Field(
+        None, description="Residential proxy verdict of the session (only when a session_id is passed)"
+    )
+    hierarchy: Optional[List[SubdivisionLevel]] = Field(
+        None, description="ISO 3166-2 subdivisions, top level first (when enabled for the API key)"
+    )
+    carrier: Optional[CarrierInfo] = Field(
+        None, description="Mobile carrier for cellular addresses (when enabled for the API key)"
+    )
+    locale: Optional[LocaleInfo] = Field(
+        None, description="Likely locale and languages (when enabled for the API key)"
+    )
+    currency: Optional[CurrencyInfo] = Field(
+        None, description="ISO 4217 currency of the country (when enabled for the API key)"
+    )
+    groups: Optional[List[str]] = Field(
+        None, description="Named country groups containing the country (when enabled for the API key)"
+    )
+    travel: Optional[TravelAssessmentResponse] = Field(
+        None, description="Impossible travel verdict (only when a user_id is passed)"
+    )
+    location_mismatch: Optional[LocationMismatchInfo] = Field(
+        None, description="Comparison with device coordinates (only when device_lat/device_lon are passed)"
+    )
+    anomaly: Optional[LocationAnomalyInfo] = Field(
+        None, description="Location anomaly score against the user's history (only when a user_id is passed)"
+    )
+    device: Optional[DeviceCorrelationInfo] = Field(
+        None, description="Device history check (only when a device_id is passed)"
+    )
+    risk: Optional[RiskScoreInfo] = Field(
+        None, description="Risk score and reason codes (when enabled for the API key)"
+    )
+    rules: Optional[RuleEvaluationInfo] = Field(
+        None, description="Detection rule decision (when RULES_PATH has rules)"
+    )
+    override: Optional[IpOverrideMatch] = Field(
+        None, description="Calling tenant's IP range override, when one located the address"
+    )
+    degraded: bool = Field(
+        False, description="Answered in degraded mode: stale, or cut down to the country (see degraded_reason)"
+    )
+    degraded_reason: Optional[Literal["overload", "dependency_unavailable"]] = Field(
+        None, description="Why the answer is degraded (only when degraded)"
+    )
+
+class IpAddressInfo(BaseModel):
+    """The looked-up address (v2)."""
+
+    address: str = Field(..., description="Looked-up IP address (as supplied, canonicalized)")
+    version: Literal[4, 6] = Field(..., description="IP version of the supplied address")
+    normalized: Optional[str] = Field(
+        None, description="Embedded IPv4 address used for the lookup (IPv4-mapped, 6to4, Teredo)"
+    )
+    tunnel: Optional[Literal["ipv4_mapped", "6to4", "teredo"]] = Field(
+        None, description="IPv6 transition mechanism detected in the address"
+    )
+    network: str = Field(..., description="Network (CIDR) containing the address")
+
+
+class CountryInfo(BaseModel):
+    """Country of a location (v2)."""
+
+    iso_code: Optional[str] = Field(None, description="ISO 3166-1 alpha-2 country code")
+    name: Optional[str] = Field(None, description="Country name")
+
+
+class CoordinateInfo(BaseModel):
+    """WGS84 coordinate (v2)."""
+
+    latitude: float = Field(..., ge=-90, le=90, description="Latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Longitude")
+
+
+class IpLocationInfo(BaseModel):
+    """Where an address is located (v2)."""
+
+    country: CountryInfo = Field(default_factory=CountryInfo, description="Country")
+    continent_code: Optional[str] = Field(None, description="Continent code (e.g., EU, NA)")
+    city: Optional[str] = Field(None, description="City name")
+    postal_code: Optional[str] = Field(None, description="Postal code")
+    coordinates: Optional[CoordinateInfo] = Field(None, description="Approximate coordinate, if known")
+    accuracy_radius_km: Optional[float] = Field(
+        None, ge=0, description="Estimated accuracy radius in kilometers (dataset radius, or typical for the granularity)"
+    )
+    granularity: Optional[Literal["site", "postal", "city", "subdivision", "country", "continent"]] = Field(
+        None, description="Most specific level the record resolves to (site for tenant overrides)"
+    )
+    time_zone: Optional[str] = Field(None, description="IANA time zone")
+    geohash: Optional[str] = Field(
+        None, description="Geohash of the location, truncated to the accuracy radius"
+    )
+    subdivisions: List[Dict[str, Any]] = Field(default_factory=list, description="Subdivisions, largest first")
+
+
+class LocationConfidenceInfo(BaseModel):
+    """Confidence in a location (v2)."""
+
+    score: float = Field(0.0, ge=0, le=1, description="Location confidence from granularity and dataset age")
+    flag: ConfidenceFlagEnum = Field(
+        ConfidenceFlagEnum.RED, description="Confidence flag (GREEN >= 0.75, YELLOW >= 0.5)"
+    )
+
+
+class DatasetInfo(BaseModel):
+    """Dataset build that answered a lookup (v2)."""
+
+    age_days: Optional[float] = Field(
+        None, ge=0, description="Age of the dataset in days (at as_of, for historical lookups)"
+    )
+    built_at: Optional[datetime] = Field(None, description="Build time of the dataset")
+
+
+class IpLookupV2Response(BaseModel):
+    """IP geolocation lookup result (v2).
+
+    The v1 fields grouped into `ip`, `location`, `confidence` and `dataset`
+    objects; the enrichment blocks are those of v1, unchanged.
+    """
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "ip": {
+                    "address": "2002:5102:458e::1",
+                    "version": 6,
+                    "normalized": "81.2.69.142",
+                    "tunnel": "6to4",
+                    "network": "81.2.69.128/26"
+                },
+                "location": {
+                    "country": {"iso_code": "GB", "name": "United Kingdom"},
+                    "continent_code": "EU",
+                    "city": "London",
+                    "postal_code": "SW1A",
+                    "coordinates": {"latitude": 51.5142, "longitude": -0.0931},
+                    "accuracy_radius_km": 10,
+                    "granularity": "postal",
+                    "time_zone": "Europe/London",
+                    "geohash": "gcpvn",
+                    "subdivisions": [{"iso_code": "ENG", "name": "England"}]
+                },
+                "confidence": {"score": 0.902, "flag": "GREEN"},
+                "dataset": {"age_days": 27.3, "built_at": "2026-02-10T00:00:00Z"},
+                "asn": {
+                    "number": 20712,
+                    "organization": "Andrews & Arnold Ltd",
+                    "isp": "Andrews & Arnold Ltd",
+                    "connection_type": "residential"
+                }
+            }
+        }
+    )
+
+    ip: IpAddressInfo = Field(..., description="Looked-up address")
+    location: IpLocationInfo = Field(..., description="Location of the address")
+    confidence: LocationConfidenceInfo = Field(..., description="Confidence in the location")
+    dataset: DatasetInfo = Field(..., description="Dataset build that answered")
+    asn: Optional[AsnInfo] = Field(None, description="ASN/ISP enrichment (when enabled for the API key)")
+    anonymizer: Optional[AnonymizerInfo] = Field(
+        None, description="VPN/Tor/proxy/residential proxy/hosting flags (when enabled for the API key)"
+    )
+    datacenter: Optional[DatacenterInfo] = Field(
+        None, description="Cloud/hosting classification (when enabled for the API key)"
+    )
+    residential_proxy: Optional[ResidentialProxyInfo] = Field(
+        None, description="Residential proxy verdict of the session (only when a session_id is passed)"
+    )
+    hierarchy: Optional[List[SubdivisionLevel]] = Field(
+        None, description="ISO 3166-2 subdivisions, top level first (when enabled for the API key)"
+    )
+    carrier: Optional[CarrierInfo] = Field(
+        None, description="Mobile carrier for cellular addresses (when enabled for the API key)"
+    )
+    locale: Optional[LocaleInfo] = Field(
+        None, description="Likely locale and languages (when enabled for the API key)"
+    )
+    currency: Optional[CurrencyInfo] = Field(
+        None, description="ISO 4217 currency of the country (when enabled for the API key)"
+    )
+    groups: Optional[List[str]] = Field(
+        None, description="Named country groups containing the country (when enabled for the API key)"
+    )
+    travel: Optional[TravelAssessmentResponse] = Field(
+        None, description="Impossible travel verdict (only when a user_id is passed)"
+    )
+    location_mismatch: Optional[LocationMismatchInfo] = Field(
+        None, description="Comparison with device coordinates (only when device_lat/device_lon are passed)"
+    )
+    anomaly: Optional[LocationAnomalyInfo] = Field(
+        None, description="Location anomaly score against the user's history (only when a user_id is passed)"
+    )
+    device: Optional[DeviceCorrelationInfo] = Field(
+        None, description="Device history check (only when a device_id is passed)"
+    )
+    risk: Optional[RiskScoreInfo] = Field(
+        None, description="Risk score and reason codes (when enabled for the API key)"
+    )
+    rules: Optional[RuleEvaluationInfo] = Field(
+        None, description="Detection rule decision (when RULES_PATH has rules)"
+    )
+    override: Optional[IpOverrideMatch] = Field(
+        None, description="Calling tenant's IP range override, when one located the address"
+    )
+    degraded: bool = Field(
+        False, description="Answered in degraded mode: stale, or cut down to the country (see degraded_reason)"
+    )
+    degraded_reason: Optional[Literal["overload", "dependency_unavailable"]] = Field(
+        None, description="Why the answer is degraded (only when degraded)"
+    )
+
+
+
+class AdminArea(BaseModel):
+    """Administrative area matched by reverse geocoding."""
+
+    code: Optional[str] = Field(None, description="Area code (ISO 3166 code, postal code, ...)")
+    name: Optional[str] = Field(None, description="Area name")
+
+
+class TimezoneInfo(BaseModel):
+    """IANA timezone and current UTC offset for a coordinate."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "tzid": "Europe/London",
+                "utc_offset": "+01:00",
+                "utc_offset_seconds": 3600,
+                "dst": True,
+                "abbreviation": "BST",
+                "source": "boundary"
+            }
+        }
+    )
+
+    tzid: str = Field(..., description="IANA timezone name")
+    utc_offset: str = Field(..., description="UTC offset as ±HH:MM")
+    utc_offset_seconds: int = Field(..., description="UTC offset in seconds")
+    dst: bool = Field(..., description="Daylight saving time in effect")
+    abbreviation: Optional[str] = Field(None, description="Timezone abbreviation (e.g., BST)")
+    source: Literal["boundary", "nautical"] = Field(
+        ..., description="Timezone polygon match, or nautical zone fallback"
+    )
+
+
+class ElevationResponse(BaseModel):
+    """Terrain elevation at a coordinate."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "latitude": 51.5014,
+                "longitude": -0.1419,
+                "elevation_m": 9.0,
+                "resolution_arcsec": 1,
+                "tile": "N51W001"
+            }
+        }
+    )
+
+    latitude: float = Field(..., ge=-90, le=90, description="Queried latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Queried longitude")
+    elevation_m: float = Field(..., description="Meters above the EGM96 geoid (about mean sea level)")
+    resolution_arcsec: Literal[1, 3] = Field(..., description="Source tile resolution (1 = SRTM1, 3 = SRTM3)")
+    tile: str = Field(..., description="SRTM tile used")
+
+
+class MaritimeZoneResponse(BaseModel):
+    """Maritime zone containing a sea coordinate."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "latitude": 50.9,
+                "longitude": 1.2,
+                "zone_type": "eez",
+                "name": "French Exclusive Economic Zone",
+                "sovereign": "FRA",
+                "territory": "FRA",
+                "mrgid": 5677,
+                "shared": False
+            }
+        }
+    )
+
+    latitude: float = Field(..., ge=-90, le=90, description="Queried latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Queried longitude")
+    zone_type: Literal["territorial", "eez", "high_seas"] = Field(
+        ..., description="Territorial sea (12 NM), exclusive economic zone, or high seas"
+    )
+    name: Optional[str] = Field(None, description="Zone name (null on the high seas)")
+    sovereign: Optional[str] = Field(None, description="ISO 3166-1 alpha-3 of the sovereign state")
+    territory: Optional[str] = Field(None, description="ISO 3166-1 alpha-3 of the territory")
+    mrgid: Optional[int] = Field(None, description="Marine Regions identifier")
+    shared: bool = Field(False, description="Overlapping claim or joint regime")
+
+
+class ReverseGeocodeResponse(BaseModel):
+    """Admin areas containing a coordinate."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "latitude": 51.5014,
+                "longitude": -0.1419,
+                "country": {"code": "GB", "name": "United Kingdom"},
+                "admin1": {"code": "GB-ENG", "name": "England"},
+                "admin2": {"code": "GB-WSM", "name": "Westminster"},
+                "city": {"code": None, "name": "London"},
+                "postal_code": "SW1A",
+                "timezone": {"tzid": "Europe/London", "utc_offset": "+01:00",
+                             "utc_offset_seconds": 3600, "dst": True,
+                             "abbreviation": "BST", "source": "boundary"}
+            }
+        }
+    )
+
+    latitude: float = Field(..., ge=-90, le=90, description="Queried latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Queried longitude")
+    country: Optional[AdminArea] = Field(None, description="Country")
+    admin1: Optional[AdminArea] = Field(None, description="First-level subdivision (state, region)")
+    admin2: Optional[AdminArea] = Field(None, description="Second-level subdivision (county, district)")
+    city: Optional[AdminArea] = Field(None, description="City or locality")
+    postal_code: Optional[str] = Field(None, description="Postal code")
+    timezone: Optional[TimezoneInfo] = Field(None, description="IANA timezone and current UTC offset")
+    locale: Optional[LocaleInfo] = Field(None, description="Likely locale and languages")
+    groups: List[str] = Field(default_factory=list, description="Named country groups containing the country")
+    elevation_m: Optional[float] = Field(
+        None, description="Terrain elevation in meters (only when requested and covered)"
+    )
+
+
+class BatchLookupItem(BaseModel):
+    """One item of a bulk lookup: an IP address or a coordinate."""
+
+    ip: Optional[str] = Field(None, description="IPv4 or IPv6 address to geolocate")
+    lat: Optional[float] = Field(None, description="Latitude to reverse geocode")
+    lon: Optional[float] = Field(None, description="Longitude to reverse geocode")
+
+
+class BatchLookupRequest(BaseModel):
+    """Bulk lookup request."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "items": [
+                    {"ip": "81.2.69.142"},
+                    {"lat": 51.5014, "lon": -0.1419},
+                ]
+            }
+        }
+    )
+
+    items: List[BatchLookupItem] = Field(
+        ..., description="Items to look up (at most BATCH_LOOKUP_MAX_ITEMS)"
+    )
+
+
+class BatchLookupResult(BaseModel):
+    """Outcome of one bulk lookup item."""
+
+    index: int = Field(..., ge=0, description="Position of the item in the request")
+    ip: Optional[IpLookupResponse] = Field(None, description="IP lookup result")
+    location: Optional[ReverseGeocodeResponse] = Field(None, description="Reverse geocoding result")
+    error: Optional[ErrorResponse] = Field(None, description="Why the item failed")
+
+
+class BatchLookupResponse(BaseModel):
+    """Per-item results of a bulk lookup, in request order."""
+
+    total: int = Field(..., ge=0, description="Number of items")
+    succeeded: int = Field(..., ge=0, description="Items that returned a result")
+    failed: int = Field(..., ge=0, description="Items that returned an error")
+    results: List[BatchLookupResult] = Field(..., description="One entry per item")
+
+
+class GeocodeCandidateResponse(BaseModel):
+    """Normalized forward geocoding candidate."""
+
+    formatted_address: str = Field(..., description="Provider-formatted address")
+    latitude: float = Field(..., ge=-90, le=90, description="Latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Longitude")
+    confidence: float = Field(..., ge=0, le=1, description="Match confidence (0-1)")
+    provider: str = Field(..., description="Provider that produced the candidate")
+    country_code: Optional[str] = Field(None, description="ISO 3166-1 alpha-2 country code")
+    components: Dict[str, str] = Field(
+        default_factory=dict,
+        description="Address components (house_number, road, city, county, state, postcode, country)",
+    )
+    bbox: Optional[List[float]] = Field(
+        None, description="Bounding box [min_lon, min_lat, max_lon, max_lat]"
+    )
+
+
+class GeocodeResponse(BaseModel):
+    """Forward geocoding result."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "query": "10 Downing Street, London",
+                "provider": "nominatim",
+                "candidates": [{
+                    "formatted_address": "10 Downing Street, Westminster, London, SW1A 2AA, United Kingdom",
+                    "latitude": 51.5034,
+                    "longitude": -0.1276,
+                    "confidence": 0.62,
+                    "provider": "nominatim",
+                    "country_code": "GB",
+                    "components": {"house_number": "10", "road": "Downing Street",
+                                   "city": "London", "postcode": "SW1A 2AA"},
+                    "bbox": [-0.1277, 51.5033, -0.1275, 51.5035]
+                }]
+            }
+        }
+    )
+
+    query: str = Field(..., description="Query as submitted")
+    provider: Optional[str] = Field(None, description="Provider that answered (None if no matches)")
+    candidates: List[GeocodeCandidateResponse] = Field(
+        default_factory=list, description="Candidates, highest confidence first"
+    )
+
+
+class GeocodingProviderStatusResponse(BaseModel):
+    """Circuit state and daily budget of a geocoding provider."""
+
+    name: str = Field(..., description="Provider name")
+    circuit: Literal["closed", "open", "half_open"] = Field(
+        ..., description="closed = in use, open = skipped after failures, half_open = trial pending"
+    )
+    consecutive_failures: int = Field(..., ge=0, description="Failures since the last success")
+    daily_budget: Optional[int] = Field(None, ge=0, description="Requests allowed per UTC day (None = unlimited)")
+    used_today: Optional[int] = Field(None, ge=0, description="Requests sent today")
+
+
+class GeohashResponse(BaseModel):
+    """Decoded geohash cell."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "geohash": "gcpvj0d",
+                "latitude": 51.50734,
+                "longitude": -0.12840,
+                "latitude_error": 0.00069,
+                "longitude_error": 0.00069,
+                "bbox": [-0.12909, 51.50665, -0.12772, 51.50803],
+                "neighbors": {"n": "gcpvj0f", "ne": "gcpvj0g", "e": "gcpvj0e", "se": "gcpvj07",
+                              "s": "gcpvj06", "sw": "gcpvj03", "w": "gcpvj09", "nw": "gcpvj0c"}
+            }
+        }
+    )
+
+    geohash: str = Field(..., description="Geohash")
+    latitude: float = Field(..., ge=-90, le=90, description="Cell center latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Cell center longitude")
+    latitude_error: float = Field(..., ge=0, description="Half cell height in degrees")
+    longitude_error: float = Field(..., ge=0, description="Half cell width in degrees")
+    bbox: List[float] = Field(..., description="Cell bounds [min_lon, min_lat, max_lon, max_lat]")
+    neighbors: Dict[str, str] = Field(default_factory=dict, description="Adjacent cells by direction")
+
+
+class H3CellResponse(BaseModel):
+    """H3 cell containing a coordinate."""
+
+    cell: str = Field(..., description="H3 cell index")
+    resolution: int = Field(..., ge=0, le=15, description="H3 resolution")
+    latitude: float = Field(..., ge=-90, le=90, description="Cell center latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Cell center longitude")
+    area_km2: float = Field(..., ge=0, description="Cell area in square kilometers")
+    boundary: List[List[float]] = Field(..., description="Cell vertices as [lon, lat] pairs, or [x, y] in crs")
+    crs: str = Field("EPSG:4326", description="CRS of the boundary coordinates")
+
+
+class H3HeatmapCell(BaseModel):
+    """Detection count for one H3 cell."""
+
+    cell: str = Field(..., description="H3 cell index")
+    count: int = Field(..., ge=1, description="Detections in the cell")
+    latitude: float = Field(..., ge=-90, le=90, description="Cell center latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Cell center longitude")
+
+
+class H3HeatmapResponse(BaseModel):
+    """Detection counts aggregated per H3 cell."""
+
+    resolution: int = Field(..., ge=0, le=15, description="H3 resolution")
+    total: int = Field(..., ge=0, description="Detections aggregated")
+    truncated: bool = Field(
+        False,
+        description="More detections matched than HEATMAP_MAX_DETECTIONS; only the most recent were aggregated",
+    )
+    cells: List[H3HeatmapCell] = Field(default_factory=list, description="Cells, highest count first")
+
+
+class GeofenceCreate(BaseModel):
+    """Request to create a geofence."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "name": "Depot perimeter",
+                "geometry": {
+                    "type": "Polygon",
+                    "coordinates": [[[-0.13, 51.50], [-0.12, 51.50], [-0.12, 51.51],
+                                     [-0.13, 51.51], [-0.13, 51.50]]]
+                },
+                "properties": {"site": "north"}
+            }
+        }
+    )
+
+    name: str = Field(..., min_length=1, max_length=255, description="Geofence name")
+    geometry: Optional[Dict[str, Any]] = Field(None, description="GeoJSON Polygon or MultiPolygon")
+    wkt: Optional[str] = Field(
+        None, description="WKT or EWKT POLYGON/MULTIPOLYGON (instead of geometry)"
+    )
+    wkb: Optional[str] = Field(
+        None, description="Hex-encoded WKB or EWKB Polygon/MultiPolygon (instead of geometry)"
+    )
+    properties: Optional[Dict[str, Any]] = Field(None, description="Arbitrary metadata")
+    crs: Optional[str] = Field(
+        None,
+        description="CRS of the geometry coordinates, e.g. EPSG:3857 (default: the EWKT/EWKB SRID, else EPSG:4326)",
+    )
+
+
+class GeofenceResponse(BaseModel):
+    """Stored geofence."""
+
+    geofence_id: str = Field(..., description="Geofence identifier")
+    name: str = Field(..., description="Geofence name")
+    geometry: Dict[str, Any] = Field(..., description="GeoJSON geometry")
+    crs: str = Field("EPSG:4326", description="CRS of the geometry coordinates")
+    properties: Optional[Dict[str, Any]] = Field(None, description="Metadata")
+    s2_cell_count: int = Field(..., ge=0, description="Cells in the S2 covering")
+    s2_interior_cell_count: int = Field(..., ge=0, description="Covering cells entirely inside the fence")
+    created_at: datetime = Field(..., description="Creation timestamp")
+
+
+class GeofenceContainsResponse(BaseModel):
+    """Result of a geofence membership test."""
+
+    geofence_id: str = Field(..., description="Geofence identifier")
+    latitude: float = Field(..., ge=-90, le=90, description="Tested latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Tested longitude")
+    inside: bool = Field(..., description="Coordinate is inside the geofence")
+    elevation_m: Optional[float] = Field(
+        None, description="Terrain elevation in meters (only when requested and covered)"
+    )
+
+
+class GeofenceMatchResponse(BaseModel):
+    """Geofences containing a coordinate."""
+
+    latitude: float = Field(..., ge=-90, le=90, description="Tested latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Tested longitude")
+    geofence_ids: List[str] = Field(..., description="IDs of every geofence containing the coordinate")
+    elevation_m: Optional[float] = Field(
+        None, description="Terrain elevation in meters (only when requested and covered)"
+    )
+
+
+class CrsTransformResponse(BaseModel):
+    """Coordinate converted between reference systems."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "x": 699330.98,
+                "y": 5710142.07,
+                "crs": "EPSG:32630",
+                "longitude": -0.1276,
+                "latitude": 51.5072
+            }
+        }
+    )
+
+    x: float = Field(..., description="Easting or longitude in the target CRS")
+    y: float = Field(..., description="Northing or latitude in the target CRS")
+    crs: str = Field(..., description="Target CRS")
+    longitude: float = Field(..., ge=-180, le=180, description="WGS84 longitude")
+    latitude: float = Field(..., ge=-90, le=90, description="WGS84 latitude")
+
+
+class DistanceResponse(BaseModel):
+    """Distance and bearings between two coordinates."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "distance": 5585.23,
+                "unit": "km",
+                "method": "vincenty",
+                "initial_bearing": 288.37,
+                "final_bearing": 231.24
+            }
+        }
+    )
+
+    distance: float = Field(..., ge=0, description="Distance in the requested unit")
+    unit: Literal["m", "km", "mi", "nmi", "ft"] = Field(..., description="Distance unit")
+    method: Literal["haversine", "vincenty"] = Field(
+        ..., description="Calculation used (vincenty falls back to haversine near antipodes)"
+    )
+    initial_bearing: Optional[float] = Field(
+        None, ge=0, lt=360, description="Bearing at the start, degrees clockwise from north"
+    )
+    final_bearing: Optional[float] = Field(
+        None, ge=0, lt=360, description="Bearing on arrival, degrees clockwise from north"
+    )
+
+
+class PoiInput(BaseModel):
+    """POI in an uploaded dataset."""
+
+    id: str = Field(..., min_length=1, max_length=255, description="Caller-supplied POI identifier")
+    latitude: float = Field(..., ge=-90, le=90, description="Latitude in degrees")
+    longitude: float = Field(..., ge=-180, le=180, description="Longitude in degrees")
+    name: Optional[str] = Field(None, max_length=255, description="Display name")
+    properties: Optional[Dict[str, Any]] = Field(None, description="Arbitrary metadata")
+
+
+class PoiDatasetCreate(BaseModel):
+    """Request to upload a POI dataset."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "name": "stores",
+                "pois": [
+                    {"id": "store-1", "latitude": 51.5007, "longitude": -0.1246, "name": "Westminster"},
+                    {"id": "store-2", "latitude": 51.5081, "longitude": -0.0759, "name": "Tower Hill"}
+                ]
+            }
+        }
+    )
+
+    name: str = Field(..., min_length=1, max_length=255, description="Dataset name")
+    pois: List[PoiInput] = Field(..., min_length=1, description="Points of interest")
+
+
+class PoiDatasetResponse(BaseModel):
+    """Stored POI dataset."""
+
+    dataset_id: str = Field(..., description="Dataset identifier")
+    name: str = Field(..., description="Dataset name")
+    poi_count: int = Field(..., ge=0, description="Number of POIs")
+    created_at: datetime = Field(..., description="Creation timestamp")
+
+
+class PoiMatchResponse(BaseModel):
+    """POI returned by a nearest-neighbor query."""
+
+    id: str = Field(..., description="POI identifier")
+    name: Optional[str] = Field(None, description="Display name")
+    latitude: float = Field(..., ge=-90, le=90, description="Latitude in degrees")
+    longitude: float = Field(..., ge=-180, le=180, description="Longitude in degrees")
+    distance_m: float = Field(..., ge=0, description="Great-circle distance from the query point")
+    properties: Optional[Dict[str, Any]] = Field(None, description="Metadata")
+
+
+class PoiNearestResponse(BaseModel):
+    """Nearest POIs to a coordinate."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "dataset_id": "6f1c2a9e-8d4b-4c53-9a57-0c3e2f1b7d10",
+                "latitude": 51.5033,
+                "longitude": -0.1196,
+                "radius_m": 5000,
+                "results": [
+                    {"id": "store-1", "name": "Westminster", "latitude": 51.5007,
+                     "longitude": -0.1246, "distance_m": 450.95, "properties": None}
+                ]
+            }
+        }
+    )
+
+    dataset_id: str = Field(..., description="Dataset searched")
+    latitude: float = Field(..., ge=-90, le=90, description="Query latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Query longitude")
+    radius_m: Optional[float] = Field(None, gt=0, description="Search radius, if any")
+    results: List[PoiMatchResponse] = Field(..., description="Matches, nearest first")
+
+
+class IpOverrideCreate(BaseModel):
+    """Request to register an IP range override for the calling tenant."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "network": "10.20.0.0/16",
+                "label": "London HQ",
+                "latitude": 51.5155,
+                "longitude": -0.0922,
+                "accuracy_radius_km": 0.5,
+                "country_iso_code": "GB",
+                "city_name": "London",
+                "properties": {"building": "HQ-1"}
+            }
+        }
+    )
+
+    network: str = Field(..., min_length=1, max_length=43, description="CIDR or single address")
+    label: str = Field(..., min_length=1, max_length=255, description="Site label")
+    latitude: float = Field(..., ge=-90, le=90, description="Site latitude in degrees")
+    longitude: float = Field(..., ge=-180, le=180, description="Site longitude in degrees")
+    accuracy_radius_km: Optional[float] = Field(None, ge=0, description="Site radius in kilometers")
+    country_iso_code: Optional[str] = Field(
+        None, min_length=2, max_length=2, description="ISO 3166-1 alpha-2 country code"
+    )
+    city_name: Optional[str] = Field(None, max_length=255, description="City name")
+    properties: Optional[Dict[str, Any]] = Field(None, description="Arbitrary metadata")
+
+
+class IpOverrideResponse(BaseModel):
+    """Stored IP range override."""
+
+    override_id: str = Field(..., description="Override identifier")
+    network: str = Field(..., description="Canonical CIDR")
+    label: str = Field(..., description="Site label")
+    latitude: float = Field(..., ge=-90, le=90, description="Site latitude in degrees")
+    longitude: float = Field(..., ge=-180, le=180, description="Site longitude in degrees")
+    accuracy_radius_km: Optional[float] = Field(None, ge=0, description="Site radius in kilometers")
+    country_iso_code: Optional[str] = Field(None, description="ISO 3166-1 alpha-2 country code")
+    city_name: Optional[str] = Field(None, description="City name")
+    properties: Optional[Dict[str, Any]] = Field(None, description="Metadata")
+    created_at: datetime = Field(..., description="Creation timestamp")
+
+
+class WifiObservation(BaseModel):
+    """Access point heard by the device."""
+
+    bssid: str = Field(..., min_length=1, max_length=17, description="Access point MAC address")
+    rssi: float = Field(..., le=0, description="Received signal strength in dBm")
+
+
+class WifiLocateRequest(BaseModel):
+    """Request to position a device from the WiFi access points it hears."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "access_points": [
+                    {"bssid": "00:11:22:33:44:55", "rssi": -52},
+                    {"bssid": "00:11:22:33:44:66", "rssi": -67},
+                    {"bssid": "00:11:22:33:44:77", "rssi": -80}
+                ]
+            }
+        }
+    )
+
+    access_points: List[WifiObservation] = Field(..., min_length=1, description="Observed access points")
+
+
+class CellObservationInput(BaseModel):
+    """Cell reported by the device (serving or neighbor)."""
+
+    radio: Optional[str] = Field(None, description="Radio type: GSM, UMTS, LTE, NR or CDMA")
+    mcc: int = Field(..., ge=0, le=999, description="Mobile country code")
+    mnc: int = Field(..., ge=0, le=999, description="Mobile network code")
+    lac: int = Field(..., ge=0, description="Location area code (TAC on LTE/NR)")
+    cid: int = Field(..., ge=0, description="Cell identity")
+    timing_advance: Optional[int] = Field(None, ge=0, description="Timing advance (GSM and LTE)")
+
+
+class CellLocateRequest(BaseModel):
+    """Request to position a device from the cells it reports."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "cells": [
+                    {"radio": "LTE", "mcc": 234, "mnc": 15, "lac": 1234, "cid": 56789012, "timing_advance": 3}
+                ]
+            }
+        }
+    )
+
+    cells: List[CellObservationInput] = Field(..., min_length=1, description="Observed cells")
+
+
+class RadioPositionResponse(BaseModel):
+    """Device position estimated from radio transmitters."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "latitude": 51.50335,
+                "longitude": -0.11952,
+                "accuracy_m": 24.6,
+                "geohash": "gcpuvrb",
+                "source": "wifi",
+                "anchors_used": 3,
+                "anchors_known": 3
+            }
+        }
+    )
+
+    latitude: float = Field(..., ge=-90, le=90, description="Estimated latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Estimated longitude")
+    accuracy_m: float = Field(..., ge=0, description="Estimated accuracy radius in meters")
+    geohash: str = Field(..., description="Geohash of the position, truncated to the accuracy radius")
+    source: Literal["wifi", "cell"] = Field(..., description="Positioning input")
+    anchors_used: int = Field(..., ge=1, description="Transmitters used for the estimate")
+    anchors_known: int = Field(..., ge=0, description="Observed transmitters found in the database")
+
+
+class PostalAreaResponse(BaseModel):
+    """Postal code area with its centroid and boundary."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "country_code": "DE",
+                "postal_code": "10117",
+                "name": "Berlin Mitte",
+                "latitude": 52.51698,
+                "longitude": 13.38886,
+                "bbox": [13.3666, 52.5034, 13.4113, 52.5275],
+                "geometry": None
+            }
+        }
+    )
+
+    country_code: str = Field(..., description="ISO 3166-1 alpha-2 country code")
+    postal_code: str = Field(..., description="Normalized postal code")
+    name: Optional[str] = Field(None, description="Place name from the dataset")
+    latitude: float = Field(..., ge=-90, le=90, description="Centroid latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Centroid longitude")
+    bbox: List[float] = Field(..., description="Area bounds [min_lon, min_lat, max_lon, max_lat]")
+    geometry: Optional[Dict[str, Any]] = Field(
+        None, description="GeoJSON boundary (only when requested)"
+    )
+
+
+class PostalValidationResponse(BaseModel):
+    """Result of checking a postal code."""
+
+    country_code: str = Field(..., description="ISO 3166-1 alpha-2 country code")
+    postal_code: str = Field(..., description="Normalized postal code")
+    format_valid: Optional[bool] = Field(
+        None, description="Code matches the country's postal format (null if the format is unknown)"
+    )
+    known: bool = Field(..., description="An area with this code is in the postal dataset")
+
+
+class RegionGroupResponse(BaseModel):
+    """Named country group."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {"name": "embargoed", "countries": ["CU", "IR", "KP", "SY"]}
+        }
+    )
+
+    name: str = Field(..., description="Group name")
+    countries: List[str] = Field(..., description="ISO 3166-1 alpha-2 codes of the members, sorted")
+
+
+class CountryGroupsResponse(BaseModel):
+    """Named groups containing a country."""
+
+    country_code: str = Field(..., description="ISO 3166-1 alpha-2 country code")
+    groups: List[str] = Field(..., description="Names of the groups containing the country, sorted")
+
+
+class TravelEventRequest(BaseModel):
+    """Login event to evaluate for impossible travel."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "user_id": "alice",
+                "timestamp": "2026-03-01T10:00:00Z",
+                "ip_address": "203.0.113.7"
+            }
+        }
+    )
+
+    user_id: str = Field(..., min_length=1, max_length=255, description="User identifier")
+    timestamp: Optional[datetime] = Field(None, description="Login time (default: now; naive values are UTC)")
+    ip_address: Optional[str] = Field(None, description="Login IP address, located with the GeoIP dataset")
+    latitude: Optional[float] = Field(None, ge=-90, le=90, description="Login latitude (instead of ip_address)")
+    longitude: Optional[float] = Field(None, ge=-180, le=180, description="Login longitude (instead of ip_address)")
+    accuracy_km: Optional[float] = Field(None, ge=0, description="Accuracy radius of latitude/longitude in km")
+
+
+class AnomalyEventRequest(BaseModel):
+    """Event to score against a user's location history."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "user_id": "alice",
+                "ip_address": "203.0.113.7"
+            }
+        }
+    )
+
+    user_id: str = Field(..., min_length=1, max_length=255, description="User identifier")
+    ip_address: Optional[str] = Field(None, description="Event IP address, located with the GeoIP dataset")
+    latitude: Optional[float] = Field(None, ge=-90, le=90, description="Event latitude (instead of ip_address)")
+    longitude: Optional[float] = Field(None, ge=-180, le=180, description="Event longitude (instead of ip_address)")
+
+
+class LocationProfileResponse(BaseModel):
+    """Trained location pattern of a user."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "user_id": "alice",
+                "centroid_lat": 51.4821,
+                "centroid_lon": -0.3012,
+                "median_distance_km": 14.2,
+                "mad_km": 6.8,
+                "samples": 212,
+                "trained_at": "2026-03-01T03:00:00Z"
+            }
+        }
+    )
+
+    user_id: str = Field(..., description="User identifier")
+    centroid_lat: float = Field(..., ge=-90, le=90, description="Latitude of the login centroid")
+    centroid_lon: float = Field(..., ge=-180, le=180, description="Longitude of the login centroid")
+    median_distance_km: float = Field(..., ge=0, description="Median login distance from the centroid")
+    mad_km: float = Field(..., ge=0, description="Median absolute deviation of the login distances")
+    samples: int = Field(..., ge=1, description="Logins trained on")
+    trained_at: datetime = Field(..., description="Training time")
+
+
+class AccountSessionRequest(BaseModel):
+    """Activity of one client session of an account."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "account_id": "alice",
+                "session_id": "sess-8c1f",
+                "ip_address": "203.0.113.7"
+            }
+        }
+    )
+
+    account_id: str = Field(..., min_length=1, max_length=255, description="Account identifier")
+    session_id: str = Field(..., min_length=1, max_length=255, description="Client session identifier")
+    timestamp: Optional[datetime] = Field(None, description="Activity time (default: now; naive values are UTC)")
+    ip_address: Optional[str] = Field(None, description="Client IP address, located with the GeoIP dataset")
+    latitude: Optional[float] = Field(None, ge=-90, le=90, description="Latitude (instead of ip_address)")
+    longitude: Optional[float] = Field(None, ge=-180, le=180, description="Longitude (instead of ip_address)")
+    accuracy_km: Optional[float] = Field(None, ge=0, description="Accuracy radius of latitude/longitude in km")
+
+
+class SessionConflictInfo(BaseModel):
+    """Another active session of the account too far away."""
+
+    session_id: str = Field(..., description="Conflicting session")
+    distance_km: float = Field(..., ge=0, description="Distance between the two sessions")
+    speed_kmh: Optional[float] = Field(None, ge=0, description="Implied travel speed (null if simultaneous)")
+    location: TravelLocation = Field(..., description="Last known location of the conflicting session")
+
+
+class AccountSharingResponse(BaseModel):
+    """Comparison of a session's activity with the account's other active sessions."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "account_id": "alice",
+                "session_id": "sess-8c1f",
+                "flagged": True,
+                "active_sessions": 2,
+                "conflicts": [{
+                    "session_id": "sess-02d7",
+                    "distance_km": 5572.3,
+                    "speed_kmh": 66867.6,
+                    "location": {"latitude": 51.5142, "longitude": -0.0931,
+                                 "timestamp": "2026-03-01T09:55:00Z", "accuracy_km": 10.0,
+                                 "ip_address": "81.2.69.142", "country_code": "GB"}
+                }]
+            }
+        }
+    )
+
+    account_id: str = Field(..., description="Account identifier")
+    session_id: str = Field(..., description="Session that was active")
+    flagged: bool = Field(..., description="Another active session is farther away than one person could travel")
+    active_sessions: int = Field(..., ge=1, description="Active sessions of the account, this one included")
+    conflicts: List[SessionConflictInfo] = Field(
+        default_factory=list, description="Conflicting sessions, farthest first"
+    )
+
+
+class FlaggedAccountInfo(BaseModel):
+    """Account currently flagged for sharing."""
+
+    account_id: str = Field(..., description="Account identifier")
+    session_ids: List[str] = Field(..., description="The two conflicting sessions")
+    distance_km: float = Field(..., ge=0, description="Distance between them")
+    speed_kmh: Optional[float] = Field(None, ge=0, description="Implied travel speed (null if simultaneous)")
+    country_codes: List[Optional[str]] = Field(..., description="Countries of the two sessions")
+    flagged_at: datetime = Field(..., description="When the current flag was raised (UTC)")
+    last_detected_at: datetime = Field(..., description="Latest conflicting activity (UTC)")
+
+
+class FlaggedAccountListResponse(BaseModel):
+    """Currently flagged accounts, most recently detected first."""
+
+    accounts: List[FlaggedAccountInfo] = Field(..., description="Flagged accounts")
+
+
+class LocationMismatchRequest(BaseModel):
+    """Device-reported coordinate and IP address of one request."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "ip_address": "81.2.69.142",
+                "latitude": 40.7128,
+                "longitude": -74.006,
+                "accuracy_m": 15
+            }
+        }
+    )
+
+    ip_address: str = Field(..., description="Client IP address")
+    latitude: float = Field(..., ge=-90, le=90, description="Device-reported latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Device-reported longitude")
+    accuracy_m: Optional[float] = Field(None, ge=0, description="Device-reported accuracy in meters")
+
+
+class LocationMismatchResponse(BaseModel):
+    """Device versus IP location comparison."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "ip_address": "81.2.69.142",
+                "device_latitude": 40.7128,
+                "device_longitude": -74.006,
+                "ip_latitude": 51.5142,
+                "ip_longitude": -0.0931,
+                "ip_country_code": "GB",
+                "anonymizer": {"vpn": True, "tor": False, "proxy": False, "hosting": False},
+                "comparison": {"distance_km": 5572.3, "excess_km": 5562.3, "threshold_km": 500.0,
+                               "mismatch": True, "likely_cause": "vpn",
+                               "ip_accuracy_km": 10.0, "device_accuracy_m": 15.0}
+            }
+        }
+    )
+
+    ip_address: str = Field(..., description="Client IP address")
+    device_latitude: float = Field(..., ge=-90, le=90, description="Device-reported latitude")
+    device_longitude: float = Field(..., ge=-180, le=180, description="Device-reported longitude")
+    ip_latitude: float = Field(..., ge=-90, le=90, description="Latitude of the IP record")
+    ip_longitude: float = Field(..., ge=-180, le=180, description="Longitude of the IP record")
+    ip_country_code: Optional[str] = Field(None, description="Country of the IP record")
+    anonymizer: Optional[AnonymizerInfo] = Field(
+        None, description="Anonymizer flags of the IP (when enabled for the API key)"
+    )
+    comparison: LocationMismatchInfo = Field(..., description="Distance and verdict")
+
+
+class ResidentialProxyRequest(BaseModel):
+    """Address a client session made a request from."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "session_id": "sess-8c1f",
+                "ip_address": "81.2.69.142"
+            }
+        }
+    )
+
+    session_id: str = Field(..., min_length=1, max_length=255, description="Client session identifier")
+    ip_address: str = Field(..., description="Client IP address")
+    timestamp: Optional[datetime] = Field(None, description="Request time (default: now; naive values are UTC)")
+
+
+class ResidentialProxyResponse(ResidentialProxyInfo):
+    """Residential proxy verdict after recording a session request."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "session_id": "sess-8c1f",
+                "ip_address": "81.2.69.142",
+                "asn": 20712,
+                "detected": True,
+                "listed": False,
+                "asn_churn": True,
+                "distinct_asns": 4,
+                "distinct_ips": 5,
+                "max_asns": 2,
+                "window": "1h"
+            }
+        }
+    )
+
+    ip_address: str = Field(..., description="Client IP address")
+    asn: Optional[int] = Field(None, description="Autonomous system of the address (null if unknown)")
+
+
+class VelocityObservationRequest(BaseModel):
+    """Location at which an account or device appeared."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "entity_type": "account",
+                "entity_id": "alice",
+                "ip_address": "81.2.69.142"
+            }
+        }
+    )
+
+    entity_type: Literal["account", "device"] = Field(..., description="Kind of entity")
+    entity_id: str = Field(..., min_length=1, max_length=255, description="Account or device identifier")
+    ip_address: Optional[str] = Field(None, description="IP address to locate (instead of country_code/city)")
+    country_code: Optional[str] = Field(None, min_length=2, max_length=2, description="ISO 3166-1 alpha-2 code")
+    city: Optional[str] = Field(None, max_length=255, description="City name")
+    timestamp: Optional[datetime] = Field(None, description="Observation time (default: now; naive values are UTC)")
+
+
+class VelocityResponse(BaseModel):
+    """Distinct countries and cities of an entity per sliding window."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "entity_type": "account",
+                "entity_id": "alice",
+                "countries": {"15m": 1, "1h": 2, "24h": 3},
+                "cities": {"15m": 1, "1h": 2, "24h": 4}
+            }
+        }
+    )
+
+    entity_type: Literal["account", "device"] = Field(..., description="Kind of entity")
+    entity_id: str = Field(..., description="Account or device identifier")
+    countries: Dict[str, int] = Field(..., description="Window -> distinct countries seen in it")
+    cities: Dict[str, int] = Field(..., description="Window -> distinct cities seen in it")
+
+
+class DeviceLocationRequest(BaseModel):
+    """Where and when a device was seen."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "ip_address": "81.2.69.142",
+                "timestamp": "2026-03-01T10:00:00Z"
+            }
+        }
+    )
+
+    ip_address: Optional[str] = Field(None, description="IP address to locate (instead of country_code)")
+    country_code: Optional[str] = Field(None, min_length=2, max_length=2, description="ISO 3166-1 alpha-2 code")
+    city_name: Optional[str] = Field(None, max_length=255, description="City name")
+    latitude: Optional[float] = Field(None, ge=-90, le=90, description="Device latitude")
+    longitude: Optional[float] = Field(None, ge=-180, le=180, description="Device longitude")
+    accuracy_km: Optional[float] = Field(None, ge=0, description="Accuracy radius of latitude/longitude in km")
+    timestamp: Optional[datetime] = Field(None, description="Sighting time (default: now; naive values are UTC)")
+
+
+class DeviceObservationInfo(BaseModel):
+    """One sighting of a device."""
+
+    timestamp: datetime = Field(..., description="Sighting time (UTC)")
+    country_code: Optional[str] = Field(None, description="ISO 3166-1 alpha-2 country code")
+    city_name: Optional[str] = Field(None, description="City name")
+    latitude: Optional[float] = Field(None, description="Latitude")
+    longitude: Optional[float] = Field(None, description="Longitude")
+    accuracy_km: Optional[float] = Field(None, description="Accuracy radius in km")
+    ip_address: Optional[str] = Field(None, description="IP address the device was seen from")
+
+
+class DeviceLocationResponse(DeviceCorrelationInfo):
+    """Recorded sighting and how it relates to the device's history."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "device_id": "fp-3f9a1c",
+                "known_device": True,
+                "country_seen_before": False,
+                "countries_seen": 1,
+                "observation": {
+                    "timestamp": "2026-03-01T10:00:00Z",
+                    "country_code": "GB",
+                    "city_name": "London",
+                    "latitude": 51.5142,
+                    "longitude": -0.0931,
+                    "accuracy_km": 10.0,
+                    "ip_address": "81.2.69.142"
+                }
+            }
+        }
+    )
+
+    observation: DeviceObservationInfo = Field(..., description="Stored sighting")
+
+
+class DeviceHistoryResponse(BaseModel):
+    """Sightings of a device, newest first."""
+
+    device_id: str = Field(..., description="Device identifier")
+    observations: List[DeviceObservationInfo] = Field(..., description="Sightings, newest first")
+    next_cursor: Optional[str] = Field(None, description="Cursor of the next page (null on the last page)")
+
+
+class CountryPresenceInfo(BaseModel):
+    """A device's sightings in one country."""
+
+    country_code: str = Field(..., description="ISO 3166-1 alpha-2 country code")
+    observations: int = Field(..., ge=1, description="Sightings in the country")
+    first_seen: datetime = Field(..., description="First sighting in the country")
+    last_seen: datetime = Field(..., description="Latest sighting in the country")
+
+
+class DeviceCountriesResponse(BaseModel):
+    """Countries a device has been seen in."""
+
+    device_id: str = Field(..., description="Device identifier")
+    countries: List[CountryPresenceInfo] = Field(..., description="Countries, first seen first")
+
+
+class DeviceCountryResponse(BaseModel):
+    """Whether a device has been seen in one country."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "device_id": "fp-3f9a1c",
+                "country_code": "GB",
+                "seen": True,
+                "observations": 12,
+                "first_seen": "2026-01-04T08:12:00Z",
+                "last_seen": "2026-03-01T10:00:00Z"
+            }
+        }
+    )
+
+    device_id: str = Field(..., description="Device identifier")
+    country_code: str = Field(..., description="ISO 3166-1 alpha-2 country code")
+    seen: bool = Field(..., description="Device has been seen in the country")
+    observations: int = Field(0, ge=0, description="Sightings in the country")
+    first_seen: Optional[datetime] = Field(None, description="First sighting in the country")
+    last_seen: Optional[datetime] = Field(None, description="Latest sighting in the country")
+
+
+class WebhookCreate(BaseModel):
+    """Request to register a webhook."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "url": "https://hooks.example.com/geofence",
+                "events": ["geofence.*"]
+            }
+        }
+    )
+
+    url: str = Field(..., min_length=1, max_length=2048, description="http(s) endpoint receiving POSTed events")
+    events: List[str] = Field(
+        ..., min_length=1, description="Event types (geofence.enter), families (geofence.*) or *"
+    )
+    secret: Optional[str] = Field(
+        None, min_length=16, max_length=128, description="Signing secret (default: generated)"
+    )
+
+
+class WebhookResponse(BaseModel):
+    """Registered webhook."""
+
+    webhook_id: str = Field(..., description="Webhook identifier")
+    url: str = Field(..., description="Endpoint receiving events")
+    events: List[str] = Field(..., description="Subscribed event types")
+    created_at: datetime = Field(..., description="Registration timestamp")
+    secret: Optional[str] = Field(
+        None, description="HMAC-SHA256 signing secret (only returned at registration)"
+    )
+
+
+class WebhookListResponse(BaseModel):
+    """Webhooks of the calling tenant."""
+
+    webhooks: List[WebhookResponse] = Field(..., description="Webhooks, oldest first")
+
+
+class WebhookDeliveryInfo(BaseModel):
+    """Logged delivery of one event to a webhook."""
+
+    delivery_id: str = Field(..., description="Delivery identifier (X-Webhook-Delivery)")
+    webhook_id: str = Field(..., description="Receiving webhook")
+    event: str = Field(..., description="Event type")
+    status: str = Field(..., description="pending, delivered or failed")
+    attempts: int = Field(..., ge=0, description="Attempts made, redeliveries included")
+    last_status_code: Optional[int] = Field(None, description="HTTP status of the latest answer")
+    last_error: Optional[str] = Field(None, description="Why the latest attempt got no answer")
+    created_at: datetime = Field(..., description="When the event was first sent")
+    last_attempt_at: Optional[datetime] = Field(None, description="Latest attempt")
+    delivered_at: Optional[datetime] = Field(None, description="When the endpoint accepted it")
+    payload: Optional[Dict[str, Any]] = Field(
+        None, description="Delivered JSON body (only when fetching one delivery)"
+    )
+
+
+class WebhookDeliveryListResponse(BaseModel):
+    """Page of a webhook's delivery log."""
+
+    deliveries: List[WebhookDeliveryInfo] = Field(..., description="Deliveries, newest first")
+    next_cursor: Optional[str] = Field(None, description="Cursor of the next page (null on the last page)")
+
+
+class GeofencePositionRequest(BaseModel):
+    """Position report of a tracked entity."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "entity_id": "truck-17",
+                "latitude": 51.505,
+                "longitude": -0.125,
+                "timestamp": "2026-03-01T10:00:00Z"
+            }
+        }
+    )
+
+    entity_id: str = Field(..., min_length=1, max_length=255, description="Tracked entity identifier")
+    latitude: float = Field(..., ge=-90, le=90, description="Latitude")
+    longitude: float = Field(..., ge=-180, le=180, description="Longitude")
+    timestamp: Optional[datetime] = Field(None, description="Position time (default: now; naive values are UTC)")
+
+
+class GeofenceAlertInfo(BaseModel):
+    """Geofence enter, exit or dwell event."""
+
+    alert_id: str = Field(..., description="Alert identifier")
+    entity_id: str = Field(..., description="Tracked entity identifier")
+    geofence_id: str = Field(..., description="Geofence identifier")
+    event: Literal["enter", "exit", "dwell"] = Field(..., description="Event type")
+    occurred_at: datetime = Field(..., description="When the change started (UTC)")
+    latitude: float = Field(..., description="Position that confirmed the event")
+    longitude: float = Field(..., description="Position that confirmed the event")
+
+
+class GeofencePositionResponse(BaseModel):
+    """Outcome of a position report."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "entity_id": "truck-17",
+                "geofence_ids": ["5b0c6f1e-8d1a-4a43-9c55-2f6f0c1d7e11"],
+                "stale": False,
+                "alerts": [{
+                    "alert_id": "c1d2e3f4-0000-4000-8000-000000000001",
+                    "entity_id": "truck-17",
+                    "geofence_id": "5b0c6f1e-8d1a-4a43-9c55-2f6f0c1d7e11",
+                    "event": "enter",
+                    "occurred_at": "2026-03-01T10:00:00Z",
+                    "latitude": 51.505,
+                    "longitude": -0.125
+                }]
+            }
+        }
+    )
+
+    entity_id: str = Field(..., description="Tracked entity identifier")
+    geofence_ids: List[str] = Field(..., description="Geofences containing the position")
+    stale: bool = Field(False, description="Older than the entity's last report, so not evaluated")
+    alerts: List[GeofenceAlertInfo] = Field(..., description="Alerts confirmed by this report")
+
+
+class GeofenceAlertListResponse(BaseModel):
+    """Stored geofence alerts, newest first."""
+
+    alerts: List[GeofenceAlertInfo] = Field(..., description="Alerts, newest first")
+    next_cursor: Optional[str] = Field(None, description="Cursor of the next page (null on the last page)")
+
+
+class SanctionsPolicyRequest(BaseModel):
+    """Tenant adjustments to the global sanctions policy."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "action": "block",
+                "extra_codes": ["BY", "RU-KGD"],
+                "exempt_codes": ["CU"]
+            }
+        }
+    )
+
+    action: Optional[Literal["off", "tag", "block"]] = Field(
+        None, description="Action on a match for the tenant's requests (default: SANCTIONS_MODE)"
+    )
+    extra_codes: List[str] = Field(
+        default_factory=list, description="ISO 3166-1 countries or 3166-2 regions sanctioned in addition"
+    )
+    exempt_codes: List[str] = Field(
+        default_factory=list, description="Globally sanctioned countries or regions not applied to the tenant"
+    )
+
+
+class SanctionsPolicyResponse(BaseModel):
+    """Sanctions policy applied to the calling tenant."""
+
+    action: Literal["off", "tag", "block"] = Field(..., description="Effective action on a match")
+    codes: List[str] = Field(..., description="Effective sanctioned countries and regions")
+    override_action: Optional[str] = Field(None, description="Tenant action (None keeps SANCTIONS_MODE)")
+    extra_codes: List[str] = Field(default_factory=list, description="Codes added by the tenant")
+    exempt_codes: List[str] = Field(default_factory=list, description="Codes exempted by the tenant")
+    updated_at: Optional[datetime] = Field(None, description="Last change of the tenant adjustments")
+
+
+class SanctionsAuditRecordInfo(BaseModel):
+    """Request refused because of a sanctions match."""
+
+    record_id: str = Field(..., description="Audit record identifier")
+    ip_address: str = Field(..., description="Client address")
+    country_code: Optional[str] = Field(None, description="Located country")
+    region_code: Optional[str] = Field(None, description="Located ISO 3166-2 region")
+    matched_code: str = Field(..., description="Sanctioned code that matched")
+    action: str = Field(..., description="Action taken")
+    policy_source: Literal["global", "tenant"] = Field(..., description="Policy that decided")
+    method: Optional[str] = Field(None, description="HTTP method")
+    path: Optional[str] = Field(None, description="Request path")
+    user_agent: Optional[str] = Field(None, description="Client User-Agent")
+    created_at: datetime = Field(..., description="Time of the block (UTC)")
+
+
+class SanctionsAuditListResponse(BaseModel):
+    """Blocked requests of the calling tenant, newest first."""
+
+    records: List[SanctionsAuditRecordInfo] = Field(..., description="Audit records, newest first")
+    next_cursor: Optional[str] = Field(None, description="Cursor of the next page (null on the last page)")
+
+
+class RiskAreaCreate(BaseModel):
+    """Entry to add to the calling tenant's high-risk list."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "kind": "polygon",
+                "name": "Port district",
+                "geometry": {
+                    "type": "Polygon",
+                    "coordinates": [[[4.40, 51.22], [4.44, 51.22], [4.44, 51.25],
+                                     [4.40, 51.25], [4.40, 51.22]]]
+                }
+            }
+        }
+    )
+
+    kind: Literal["country", "region", "polygon"] = Field(..., description="Entry kind")
+    code: Optional[str] = Field(
+        None, max_length=16, description="ISO 3166-1 alpha-2 (country) or ISO 3166-2 (region) code"
+    )
+    name: Optional[str] = Field(None, max_length=255, description="Display name")
+    geometry: Optional[Dict[str, Any]] = Field(None, description="GeoJSON Polygon or MultiPolygon (polygon)")
+
+
+class RiskAreaResponse(BaseModel):
+    """Entry of a tenant's high-risk list."""
+
+    area_id: str = Field(..., description="Entry identifier")
+    kind: Literal["country", "region", "polygon"] = Field(..., description="Entry kind")
+    code: Optional[str] = Field(None, description="ISO code (countries and regions)")
+    name: Optional[str] = Field(None, description="Display name")
+    geometry: Optional[Dict[str, Any]] = Field(None, description="GeoJSON geometry (polygons)")
+    created_at: datetime = Field(..., description="Creation timestamp")
+
+
+class RiskAreaListResponse(BaseModel):
+    """High-risk list of the calling tenant."""
+
+    areas: List[RiskAreaResponse] = Field(..., description="Entries, oldest first")
+
+
+class RuleInfo(BaseModel):
+    """Loaded detection rule."""
+
+    rule_id: str = Field(..., description="Rule identifier")
+    action: Literal["flag", "review", "block"] = Field(..., description="Action when the rule fires")
+    state: Literal["enforce", "shadow"] = Field(
+        ..., description="enforce: affects responses; shadow: matches only logged and counted"
+    )
+    expression: str = Field(..., description="Rule expression")
+    description: Optional[str] = Field(None, description="What the rule detects")
+    variables: List[str] = Field(..., description="Variables the expression reads")
+
+
+class RuleListResponse(BaseModel):
+    """Active detection rules and the state of the rules file."""
+
+    rules: List[RuleInfo] = Field(..., description="Active rules, in file order")
+    version: Optional[str] = Field(None, description="Content hash of the active rules file")
+    loaded_at: Optional[datetime] = Field(None, description="When the active rules were loaded")
+    last_error: Optional[str] = Field(
+        None, description="Why the latest change to the file was rejected (previous rules stay active)"
+    )
+
+
+class RuleValidateRequest(BaseModel):
+    """Rule expression to check."""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"expression": "risk > 70 && country in [\"RU\", \"IR\"] && is_vpn"}}
+    )
+
+    expression: str = Field(..., max_length=4096, description="Rule expression")
+
+
+class RuleValidateResponse(BaseModel):
+    """Result of checking a rule expression."""
+
+    valid: bool = Field(..., description="Expression parses and type-checks")
+    error: Optional[str] = Field(None, description="What is wrong with the expression")
+    position: Optional[int] = Field(None, ge=0, description="0-based offset of the error")
+    variables: List[str] = Field(default_factory=list, description="Variables the expression reads")
+
+
+class RuleFeedbackRequest(BaseModel):
+    """Verdict on the rules that fired for a lookup."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "rule_ids": ["vpn-sanctioned"],
+                "verdict": "false_positive",
+                "decision": "block",
+                "ip_address": "185.220.101.1",
+                "comment": "Customer on a corporate VPN",
+            }
+        }
+    )
+
+    rule_ids: List[str] = Field(
+        ..., min_length=1, max_length=50, description="Rules that fired (from the lookup's rules block)"
+    )
+    verdict: Literal["false_positive", "true_positive"] = Field(..., description="Whether the decision was right")
+    decision: Optional[Literal["flag", "review", "block"]] = Field(None, description="Decision the lookup got")
+    ip_address: Optional[str] = Field(None, max_length=45, description="Looked-up address")
+    comment: Optional[str] = Field(None, max_length=1000, description="Why the decision was right or wrong")
+
+
+class RuleFeedbackResponse(BaseModel):
+    """Stored feedback."""
+
+    feedback_id: str = Field(..., description="Feedback identifier")
+    rule_ids: List[str] = Field(..., description="Rules the verdict was recorded for")
+    verdict: Literal["false_positive", "true_positive"] = Field(..., description="Recorded verdict")
+    created_at: datetime = Field(..., description="When the feedback was recorded")
+
+
+class RuleFeedbackStatsInfo(BaseModel):
+    """Feedback totals of one rule."""
+
+    rule_id: str = Field(..., description="Rule identifier")
+    reports: int = Field(..., ge=0, description="Feedback reports naming the rule")
+    true_positives: int = Field(..., ge=0, description="Reports confirming the decision")
+    false_positives: int = Field(..., ge=0, description="Reports of a wrong decision")
+    precision: Optional[float] = Field(
+        None, ge=0, le=1, description="true_positives / reports (null without reports)"
+    )
+
+
+class RuleFeedbackStatsResponse(BaseModel):
+    """Per-rule feedback totals."""
+
+    rules: List[RuleFeedbackStatsInfo] = Field(..., description="Rules with feedback, least precise first")
+
+
+class GraphQLRequest(BaseModel):
+    """GraphQL query (standard POST body)."""
+
+    model_config = ConfigDict(
+        populate_by_name=True,
+        json_schema_extra={
+            "example": {
+                "query": "query($ip: String!) { ip(address: $ip) { city_name timezone { tzid } risk { score } } }",
+                "variables": {"ip": "81.2.69.142"},
+            }
+        },
+    )
+
+    query: str = Field(..., max_length=20000, description="GraphQL document")
+    variables: Optional[Dict[str, Any]] = Field(None, description="Values of the query's variables")
+    operation_name: Optional[str] = Field(
+        None, alias="operationName", description="Operation to run when the document has several"
+    )
+
+
+class BatchJobRequest(BaseModel):
+    """Batch lookup job over an input file."""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"source": "s3://acme-exports/logins-2026-03.csv"}}
+    )
+
+    source: str = Field(
+        ...,
+        max_length=1024,
+        description="CSV with an ip column or lat and lon columns: s3://bucket/key, https:// URL or file:path",
+    )
+
+
+class BatchJobResponse(BaseModel):
+    """Status and progress of a batch job."""
+
+    job_id: str = Field(..., description="Job identifier")
+    status: Literal["queued", "running", "succeeded", "failed"] = Field(..., description="Job status")
+    source: str = Field(..., description="Input file reference")
+    total_rows: Optional[int] = Field(None, ge=0, description="Rows in the input (null until downloaded)")
+    processed_rows: int = Field(..., ge=0, description="Rows looked up so far")
+    failed_rows: int = Field(..., ge=0, description="Processed rows that returned an error")
+    progress: Optional[float] = Field(
+        None, ge=0, le=1, description="processed_rows / total_rows (null until the total is known)"
+    )
+    error: Optional[str] = Field(None, description="Why the job failed")
+    result_url: Optional[str] = Field(None, description="NDJSON results, once the job succeeded")
+    created_at: datetime = Field(..., description="When the job was submitted")
+    started_at: Optional[datetime] = Field(None, description="When processing started")
+    finished_at: Optional[datetime] = Field(None, description="When the job succeeded or failed")
+
+
+class ErasureCreate(BaseModel):
+    """Request to erase a data subject's records."""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"subject": "alice", "ip_addresses": ["81.2.69.142"]}}
+    )
+
+    subject: str = Field(
+        ..., min_length=1, max_length=255, description="User, account, device or tracked entity ID"
+    )
+    ip_addresses: List[str] = Field(
+        default_factory=list,
+        max_length=100,
+        description="Addresses of the subject whose sanctions audit records and rule feedback are erased too",
+    )
+
+
+class ErasureStoreInfo(BaseModel):
+    """Rows of one store an erasure deleted and left."""
+
+    deleted: int = Field(..., ge=0, description="Rows deleted")
+    remaining: int = Field(..., ge=0, description="Rows of the subject found after deletion")
+
+
+class ErasureReportInfo(BaseModel):
+    """Verification report of a finished erasure."""
+
+    stores: Dict[str, ErasureStoreInfo] = Field(..., description="Per table")
+    velocity_counters_cleared: bool = Field(..., description="Whether the velocity counters were dropped")
+    audit_log_entries_retained: int = Field(
+        ..., ge=0, description="Hash-chained audit log entries referencing the subject, kept for tamper evidence"
+    )
+    verified: bool = Field(..., description="No store holds records of the subject any more")
+
+
+class ErasureResponse(BaseModel):
+    """Status and report of an erasure."""
+
+    erasure_id: str = Field(..., description="Erasure identifier")
+    status: Literal["queued", "running", "succeeded", "failed"] = Field(..., description="Erasure status")
+    tombstoned: bool = Field(True, description="Records of the subject are no longer stored")
+    report: Optional[ErasureReportInfo] = Field(None, description="What was deleted, once succeeded")
+    error: Optional[str] = Field(None, description="Why the erasure failed")
+    created_at: datetime = Field(..., description="When the erasure was requested")
+    started_at: Optional[datetime] = Field(None, description="When deletion started")
+    finished_at: Optional[datetime] = Field(None, description="When the erasure succeeded or failed")
+
+
+class KeyUsageInfo(BaseModel):
+    """Requests made with one API key's tokens, broken down."""
+
+    key_id: str = Field(..., description="API key identifier")
+    prefix: Optional[str] = Field(None, description="Leading characters of the key")
+    total: int = Field(..., ge=0, description="Requests in the range")
+    by_endpoint: Dict[str, int] = Field(
+        default_factory=dict, description="Requests per method and route, e.g. GET /api/v1/geofences/{geofence_id}"
+    )
+    by_day: Dict[str, int] = Field(default_factory=dict, description="Requests per UTC day (YYYY-MM-DD)")
+    by_status_class: Dict[str, int] = Field(default_factory=dict, description="Requests per response class (2xx ... 5xx)")
+
+
+class UsageResponse(BaseModel):
+    """Usage of the calling tenant's API keys."""
+
+    since: str = Field(..., description="First day of the range (UTC, YYYY-MM-DD)")
+    until: str = Field(..., description="Last day of the range, inclusive")
+    total: int = Field(..., ge=0, description="Requests of all keys in the range")
+    keys: List[KeyUsageInfo] = Field(default_factory=list, description="Keys with requests, busiest first")
+
+
+class QuotaAlertPreferencesRequest(BaseModel):
+    """How the calling tenant is alerted as its keys approach their quotas."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "thresholds": [80, 100],
+                "webhook": True,
+                "emails": ["ops@example.com"]
+            }
+        }
+    )
+
+    thresholds: List[int] = Field(..., description="Percentages of a cap to be alerted of (of QUOTA_ALERT_THRESHOLDS)")
+    webhook: bool = Field(True, description="Deliver quota.threshold events to webhooks subscribed to them")
+    emails: List[str] = Field(default_factory=list, max_length=10, description="Addresses alerted by email")
+
+
+class QuotaAlertPreferencesResponse(BaseModel):
+    """Quota alert preferences applied to the calling tenant."""
+
+    thresholds: List[int] = Field(..., description="Percentages of a cap alerted of")
+    webhook: bool = Field(..., description="Whether quota.threshold webhook events are delivered")
+    emails: List[str] = Field(default_factory=list, description="Addresses alerted by email")
+    available_thresholds: List[int] = Field(..., description="Thresholds alerts are raised for")
+    updated_at: Optional[datetime] = Field(None, description="Last change of the preferences (None: defaults)")
+
+
+class BillingRecordInfo(BaseModel):
+    """Billable requests of a tenant in one hour."""
+
+    tenant_id: str = Field(..., description="Tenant identifier")
+    period_start: datetime = Field(..., description="Start of the hour (UTC)")
+    period_end: datetime = Field(..., description="End of the hour (UTC)")
+    requests: int = Field(..., ge=0, description="Billable requests")
+
+
+class BillingUsageResponse(BaseModel):
+    """Hourly billable requests of tenants."""
+
+    since: datetime = Field(..., description="Start of the first hour")
+    until: datetime = Field(..., description="End of the range (exclusive)")
+    total: int = Field(..., ge=0, description="Billable requests in the range")
+    records: List[BillingRecordInfo] = Field(default_factory=list, description="Tenant hours, oldest first")
+
+
+class BillingExportResponse(BaseModel):
+    """Outcome of a billing export run."""
+
+    records: int = Field(..., ge=0, description="Tenant hours written to the export file")
+    requests: int = Field(..., ge=0, description="Requests in them")
+    location: Optional[str] = Field(None, description="Where the file was written (null: nothing to export)")
+    reported: int = Field(0, ge=0, description="Tenant hours sent to Stripe")
+    failed: int = Field(0, ge=0, description="Tenant hours Stripe did not accept (retried next run)")
+
+
+class CsvRowError(BaseModel):
+    """Row of an uploaded CSV that could not be imported."""
+
+    index: int = Field(..., ge=0, description="Row position (0-based, header excluded)")
+    error: ErrorResponse = Field(..., description="Why the row was rejected")
+
+
+class CsvImportResponse(BaseModel):
+    """Outcome of a CSV import."""
+
+    import_id: str = Field(..., description="Job recording the import (GET /api/v1/jobs/{import_id})")
+    total_rows: int = Field(..., ge=0, description="Data rows in the upload")
+    imported_rows: int = Field(..., ge=0, description="Rows imported")
+    failed_rows: int = Field(..., ge=0, description="Rows rejected")
+    errors: List[CsvRowError] = Field(..., description="First rejected rows (all are in the results file)")
+    result_url: str = Field(..., description="NDJSON outcome of every row, in input order")
+    dataset_id: Optional[str] = Field(None, description="Created POI dataset (POI imports)")
+
+
+class DependencyHealthInfo(BaseModel):
+    """Status of one dependency in a probe response."""
+
+    status: str = Field(..., description="ok, fail or skipped")
+    critical: bool = Field(..., description="Whether a failure makes the instance unready")
+    latency_ms: Optional[float] = Field(None, ge=0, description="Time the check took")
+    detail: Optional[str] = Field(None, description="Failure reason or what was checked")
+
+
+class ProbeResponse(BaseModel):
+    """Result of a health, readiness or liveness probe."""
+
+    model_config = ConfigDict(
+        json_schema_extra={
+            "example": {
+                "status": "degraded",
+                "version": "1.0.0",
+                "uptime_seconds": 86412.5,
+                "checks": {
+                    "database": {"status": "ok", "critical": True, "latency_ms": 1.8, "detail": "postgresql"},
+                    "geoip_dataset": {"status": "ok", "critical": True, "latency_ms": 0.01, "detail": "GeoLite2-City"},
+                    "velocity_redis": {"status": "skipped", "critical": True, "latency_ms": None, "detail": "not configured"},
+                    "geoip_dataset_age": {"status": "fail", "critical": False, "latency_ms": 0.01,
+                                          "detail": "dataset is 41.2 days old (limit 30)"}
+                }
+            }
+        }
+    )
+
+    status: str = Field(..., description="ok, degraded (only non-critical checks failed) or fail")
+    version: str = Field(..., description="Service version")
+    uptime_seconds: float = Field(..., ge=0, description="Seconds since the process started")
+    checks: Dict[str, DependencyHealthInfo] = Field(
+        default_factory=dict, description="Dependency checks by name"
+    )
+
+
+class TokenRequest(BaseModel):
+    """Exchange of a tenant API key for a bearer token."""
+
+    api_key: str = Field(..., min_length=1, max_length=128, description="Tenant API key (gek_...)")
+
+
+class TokenResponse(BaseModel):
+    """Access and refresh token issued for a tenant API key or a refresh."""
+
+    access_token: str = Field(..., description="JWT to send as Authorization: Bearer")
+    token_type: str = Field("bearer", description="Always bearer")
+    expires_in: int = Field(..., gt=0, description="Seconds until the access token expires")
+    refresh_token: str = Field(..., description="Single-use token for POST /api/v1/auth/refresh (ger_...)")
+    refresh_expires_in: int = Field(..., gt=0, description="Seconds until the refresh token expires")
+    tenant_id: str = Field(..., description="Tenant the token acts for")
+
+
+class RefreshTokenRequest(BaseModel):
+    """Exchange of a refresh token for a new token pair."""
+
+    refresh_token: str = Field(..., min_length=1, max_length=128, description="Refresh token (ger_...)")
+
+
+class TokenRevokeRequest(BaseModel):
+    """Revocation of an access or refresh token (RFC 7009)."""
+
+    token: str = Field(..., min_length=1, max_length=4096, description="Access token (JWT) or refresh token")
+
+
+class TokenRevokeResponse(BaseModel):
+    """Outcome of a token revocation."""
+
+    revoked: bool = Field(..., description="False if the token was invalid, expired or unknown")
+
+
+class TenantCreate(BaseModel):
+    """Request to create a tenant."""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"tenant_id": "acme", "name": "Acme Logistics"}}
+    )
+
+    tenant_id: str = Field(
+        ..., min_length=1, max_length=64,
+        description="Tenant identifier carried in bearer tokens (lowercase letters, digits, - and _)",
+    )
+    name: str = Field(..., min_length=1, max_length=255, description="Display name")
+    scopes: Optional[List[str]] = Field(
+        None, description="Scopes of the first API key (default TENANT_KEY_DEFAULT_SCOPES)"
+    )
+    allowed_cidrs: Optional[List[str]] = Field(
+        None, description="Networks the first API key may be used from (default: any address)"
+    )
+    privacy_precision: Optional[int] = Field(
+        None, ge=0, le=6, description="Put the first API key in privacy mode with this coordinate precision"
+    )
+    quota_plan: Optional[str] = Field(
+        None, max_length=32, description="Quota plan of the first API key (default: not metered)"
+    )
+
+
+class ApiKeyInfo(BaseModel):
+    """Tenant API key (never its secret part)."""
+
+    key_id: str = Field(..., description="Key identifier")
+    tenant_id: str = Field(..., description="Tenant the key belongs to")
+    prefix: str = Field(..., description="Leading characters of the key, to recognise it")
+    scopes: List[str] = Field(default_factory=list, description="Scopes granted to the key's tokens")
+    allowed_cidrs: List[str] = Field(
+        default_factory=list, description="Networks the key may be used from (empty: any address)"
+    )
+    privacy_precision: Optional[int] = Field(
+        None, description="Decimal places of coordinates returned in privacy mode (null: privacy mode off)"
+    )
+    quota_plan: Optional[str] = Field(None, description="Monthly quota plan (null: not metered)")
+    created_at: datetime = Field(..., description="Issue timestamp")
+    rotate_after: Optional[datetime] = Field(
+        None, description="When the key is due for rotation (TENANT_KEY_MAX_AGE_DAYS)"
+    )
+    expires_at: Optional[datetime] = Field(
+        None, description="End of validity: the grace period after a rotation or after rotate_after"
+    )
+    revoked_at: Optional[datetime] = Field(None, description="When the key was revoked")
+    last_used_at: Optional[datetime] = Field(
+        None, description="Last exchange for a token (to the minute)"
+    )
+    active: bool = Field(..., description="Key is currently accepted")
+    rotation_due: bool = Field(False, description="Active key past rotate_after")
+
+
+class ApiKeyListResponse(BaseModel):
+    """API keys across tenants."""
+
+    api_keys: List[ApiKeyInfo] = Field(..., description="Keys, oldest first")
+
+
+class TenantResponse(BaseModel):
+    """Tenant and its API keys."""
+
+    tenant_id: str = Field(..., description="Tenant identifier")
+    name: str = Field(..., description="Display name")
+    created_at: datetime = Field(..., description="Creation timestamp")
+    api_keys: List[ApiKeyInfo] = Field(default_factory=list, description="API keys, oldest first")
+    api_key: Optional[str] = Field(None, description="First API key (only returned at creation)")
+
+
+class TenantListResponse(BaseModel):
+    """Every tenant."""
+
+    tenants: List[TenantResponse] = Field(..., description="Tenants, oldest first")
+
+
+class ApiKeyRotateRequest(BaseModel):
+    """Request to rotate a tenant's API key."""
+
+    grace_seconds: Optional[int] = Field(
+        None, ge=0, description="How long previous keys keep working (default TENANT_KEY_ROTATION_GRACE_SECONDS)"
+    )
+    scopes: Optional[List[str]] = Field(None, description="Scopes of the new key (default: those of the newest key)")
+    allowed_cidrs: Optional[List[str]] = Field(
+        None, description="Networks the new key may be used from (default: those of the newest key)"
+    )
+
+
+class ApiKeyAllowedCidrsRequest(BaseModel):
+    """Networks an API key may be used from."""
+
+    model_config = ConfigDict(
+        json_schema_extra={"example": {"allowed_cidrs": ["203.0.113.0/24", "2001:db8::/32"]}}
+    )
+
+    allowed_cidrs: List[str] = Field(..., description="IPv4/IPv6 networks in CIDR notation (empty: any address)")
+
+
+class ApiKeyPrivacyRequest(BaseModel):
+    """Privacy mode of an API key."""
+
+    model_config = ConfigDict(json_schema_extra={"example": {"precision": 2}})
+
+    precision: Optional[int] = Field(
+        ..., ge=0, le=6,
+        description="Decimal places returned coordinates are truncated to (2 is about 1 km; null: privacy mode off)",
+    )
+
+
+class ApiKeyQuotaPlanRequest(BaseModel):
+    """Quota plan of an API key."""
+
+    model_config = ConfigDict(json_schema_extra={"example": {"plan": "standard"}})
+
+    plan: Optional[str] = Field(..., max_length=32, description="Plan name (null: not metered)")
+
+
+class QuotaPlanInfo(BaseModel):
+    """Monthly request caps of a quota plan."""
+
+    name: str = Field(..., description="Plan name")
+    monthly_requests: Optional[int] = Field(None, description="Requests per month (null: no total cap)")
+    endpoints: Dict[str, int] = Field(
+        default_factory=dict, description="Requests per month of endpoints, by method and route template"
+    )
+    warn_ratio: float = Field(..., description="Share of a cap from which responses carry X-Quota-Warning")
+    block_ratio: float = Field(..., description="Share of a cap beyond which requests get 429 (E015)")
+
+
+class QuotaPlanListResponse(BaseModel):
+    """Configured quota plans."""
+
+    plans: List[QuotaPlanInfo] = Field(..., description="Plans, by name")
+
+
+class ApiKeyRotateResponse(BaseModel):
+    """Newly issued tenant API key."""
+
+    tenant_id: str = Field(..., description="Tenant identifier")
+    key: ApiKeyInfo = Field(..., description="New key")
+    api_key: str = Field(..., description="The new key (only returned now)")
+    api_keys: List[ApiKeyInfo] = Field(..., description="Every key of the tenant after the rotation")
+
+
+class SigningKeyInfo(BaseModel):
+    """Tenant request signing key (never its secret)."""
+
+    key_id: str = Field(..., description="Key identifier, sent as keyId in X-Request-Signature")
+    tenant_id: str = Field(..., description="Tenant the key belongs to")
+    created_at: datetime = Field(..., description="Issue timestamp")
+    expires_at: Optional[datetime] = Field(None, description="End of validity (rotation grace period end)")
+    revoked_at: Optional[datetime] = Field(None, description="When the key was revoked")
+    active: bool = Field(..., description="Key is currently accepted")
+
+
+class SigningKeyCreateRequest(BaseModel):
+    """Request to issue (or rotate) a tenant's signing key."""
+
+    grace_seconds: Optional[int] = Field(
+        None, ge=0, description="How long previous keys keep working (default TENANT_KEY_ROTATION_GRACE_SECONDS)"
+    )
+
+
+class SigningKeyIssuedResponse(BaseModel):
+    """Newly issued signing key."""
+
+    tenant_id: str = Field(..., description="Tenant identifier")
+    key: SigningKeyInfo = Field(..., description="New key")
+    secret: str = Field(..., description="HMAC-SHA256 secret of the key (only returned now)")
+    signing_keys: List[SigningKeyInfo] = Field(..., description="Every signing key of the tenant, oldest first")
+
+
+class SigningKeyListResponse(BaseModel):
+    """Signing keys of a tenant."""
+
+    tenant_id: str = Field(..., description="Tenant identifier")
+    signing_required: bool = Field(..., description="New tokens of the tenant require signed requests")
+    signing_keys: List[SigningKeyInfo] = Field(..., description="Signing keys, oldest first")
+
+
+class DataKeyInfo(BaseModel):
+    """Tenant data key sealing location history (stored wrapped, never shown)."""
+
+    key_id: str = Field(..., description="Key identifier")
+    tenant_id: Optional[str] = Field(None, description="Tenant the key belongs to")
+    master_key_id: str = Field(..., description="Master key the data key is wrapped by (KMS key or local:...)")
+    created_at: datetime = Field(..., description="Creation timestamp")
+    retired_at: Optional[datetime] = Field(None, description="When the key was rotated out")
+    active: bool = Field(..., description="New sightings are sealed with the key")
+    rows: int = Field(..., description="Stored sightings sealed with the key")
+
+
+class DataKeyListResponse(BaseModel):
+    """Location data keys of a tenant."""
+
+    tenant_id: str = Field(..., description="Tenant identifier")
+    encryption_enabled: bool = Field(..., description="Location history encryption is configured")
+    data_keys: List[DataKeyInfo] = Field(..., description="Data keys, oldest first")
+
+
+class DataKeyReencryptResponse(BaseModel):
+    """Outcome of re-encrypting a batch of a tenant's location history."""
+
+    tenant_id: str = Field(..., description="Tenant identifier")
+    reencrypted: int = Field(..., description="Sightings sealed with the active data key")
+    remaining: int = Field(..., description="Sightings still under a retired key or in plaintext")
+    deleted_keys: List[str] = Field(..., description="Retired data keys deleted as no sighting used them")
+
+
+class DataKeyRewrapResponse(BaseModel):
+    """Outcome of re-wrapping data keys with the current master key."""
+
+    master_key_id: str = Field(..., description="Current master key")
+    rewrapped: int = Field(..., description="Data keys re-wrapped")
+
+
+class GeofenceListResponse(BaseModel):
+    """Page of stored geofences."""
+
+    geofences: List[GeofenceResponse] = Field(..., description="Geofences, newest first")
+    next_cursor: Optional[str] = Field(None, description="Cursor of the next page (null on the last page)")
+
+
+class GeoIPDatasetInfo(BaseModel):
+    """Active GeoIP dataset."""
+
+    database_path: str = Field(..., description="Path of the active .mmdb file")
+    database_type: Optional[str] = Field(None, description="MMDB database type, e.g. GeoLite2-City")
+    build_epoch: Optional[int] = Field(None, description="Build time (Unix seconds)")
+    ip_version: Optional[int] = Field(None, description="4 or 6")
+    sha256: Optional[str] = Field(None, description="Checksum of the installed file, if recorded")
+    loaded: bool = Field(..., description="Dataset is loaded and serving lookups")
+
+
+class RbacRuleInfo(BaseModel):
+    """Rule of the RBAC policy."""
+
+    id: str = Field(..., description="Rule identifier")
+    path: str = Field(..., description="Path pattern (* one segment, ** any)")
+    methods: Optional[List[str]] = Field(None, description="HTTP methods (null: every method)")
+    scopes: List[str] = Field(..., description="Scopes of which the caller needs one (empty: none)")
+
+
+class RbacPolicyResponse(BaseModel):
+    """Active RBAC policy and the state of the policy file."""
+
+    enabled: bool = Field(..., description="RBAC_POLICY_PATH is set")
+    rules: List[RbacRuleInfo] = Field(..., description="Active rules, in file order")
+    default_scopes: Optional[List[str]] = Field(
+        None, description="Scopes needed by requests no rule matches (null: unrestricted)"
+    )
+    version: Optional[str] = Field(None, description="Content hash of the active policy file")
+    loaded_at: Optional[datetime] = Field(None, description="When the active policy was loaded")
+    last_error: Optional[str] = Field(
+        None, description="Why the latest change to the file was rejected (previous policy stays active)"
+    )
+
+
+class DatasetUploadResponse(BaseModel):
+    """Outcome of a dataset upload."""
+
+    updated: bool = Field(..., description="False when the upload is the dataset already installed")
+    dataset: GeoIPDatasetInfo = Field(..., description="Active dataset after the upload")
+
+
+class AllocationSiteInfo(BaseModel):
+    """Allocation site of an allocation profile."""
+
+    site: str = Field(..., description="Innermost frame (file:line) of the allocating stack")
+    size_bytes: int = Field(..., description="Memory still held that was allocated here during the capture")
+    count: int = Field(..., description="Live blocks allocated here")
+    traceback: List[str] = Field(..., description="Allocating stack, outermost frame first")
+
+
+class AllocationProfileResponse(BaseModel):
+    """Allocations traced for a while."""
+
+    seconds: float = Field(..., description="Capture duration")
+    total_bytes: int = Field(..., description="Memory held by the listed sites")
+    sites: List[AllocationSiteInfo] = Field(..., description="Sites holding the most memory, largest first")
+
+
+class AuditLogEntryInfo(BaseModel):
+    """Entry of the tamper-evident audit log."""
+
+    seq: int = Field(..., description="Position in the hash chain")
+    entry_id: str = Field(..., description="Entry identifier")
+    recorded_at: datetime = Field(..., description="Time of the event (UTC)")
+    category: Literal["admin", "detection"] = Field(..., description="Admin action or detection decision")
+    action: str = Field(..., description="Route that acted, e.g. rotate_tenant_key or detect_travel")
+    actor: Optional[str] = Field(None, description="Subject of the caller's credentials (null if anonymous)")
+    tenant_id: Optional[str] = Field(None, description="Tenant acted on, or the calling tenant")
+    resource: Optional[str] = Field(None, description="Request path, or subject of the decision")
+    outcome: str = Field(..., description="success, denied or failed; flagged or clear for decisions")
+    details: Dict[str, Any] = Field(..., description="Request status or the verdict returned")
+    prev_hash: str = Field(..., description="entry_hash of the previous entry")
+    entry_hash: str = Field(..., description="SHA-256 over prev_hash and the entry's fields")
+
+
+class AuditLogListResponse(BaseModel):
+    """Matching audit log entries, newest first."""
+
+    entries: List[AuditLogEntryInfo] = Field(..., description="Entries, newest first")
+    next_cursor: Optional[str] = Field(None, description="Cursor of the next page (null on the last page)")
+
+
+class AuditLogVerifyResponse(BaseModel):
+    """Result of checking the audit log's hash chain."""
+
+    valid: bool = Field(..., description="Every entry follows its predecessor and matches its hash")
+    entries: int = Field(..., ge=0, description="Entries checked (up to the first broken one)")
+    head_hash: str = Field(..., description="entry_hash of the last valid entry; keep it to detect truncation")
+    broken_seq: Optional[int] = Field(None, description="First entry that does not verify")
+    broken_entry_id: Optional[str] = Field(None, description="Identifier of that entry")
+    reason: Optional[str] = Field(None, description="Why it does not verify")
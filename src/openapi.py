@@ -0,0 +1,94 @@
+"""OpenAPI document generated from the registered routes.
+
+FastAPI derives paths, parameters and schemas from the route handlers and
+their pydantic models, so the document cannot drift from the code. This
+module adds what the handlers do not declare in a way FastAPI can see:
+the bearer token scheme, attached to every operation whose dependencies
+authenticate the caller (required for get_principal/get_tenant_id and
+get_admin_principal, optional for get_optional_principal and
+get_optional_tenant_id). The document
+is served at /openapi.json and can be exported for client generation:
+
+    python -m src.openapi > openapi.json
+"""
+
+import json
+from typing import Any, Callable, Dict, Iterator, Optional
+
+from fastapi import FastAPI
+from fastapi.dependencies.models import Dependant
+from fastapi.openapi.utils import get_openapi
+from fastapi.routing import APIRoute
+
+from src.api.dependencies import (
+    get_admin_principal,
+    get_optional_principal,
+    get_optional_tenant_id,
+    get_principal,
+)
+
+BEARER_SCHEME = "bearerAuth"
+
+
+def _calls(dependant: Dependant) -> Iterator[Callable]:
+    for dependency in dependant.dependencies:
+        if dependency.call is not None:
+            yield dependency.call
+        yield from _calls(dependency)
+
+
+def route_security(route: APIRoute) -> Optional[list]:
+    """OpenAPI security requirement of a route (None for anonymous routes)."""
+    calls = set(_calls(route.dependant))
+    if get_principal in calls or get_admin_principal in calls:
+        return [{BEARER_SCHEME: []}]
+    if get_optional_principal in calls or get_optional_tenant_id in calls:
+        # An empty requirement makes the token optional
+        return [{}, {BEARER_SCHEME: []}]
+    return None
+
+
+def build_openapi(app: FastAPI) -> Dict[str, Any]:
+    """OpenAPI 3.1 document of an app's HTTP routes, with bearer security."""
+    document = get_openapi(
+        title=app.title,
+        version=app.version,
+        openapi_version=app.openapi_version,
+        description=app.description,
+        routes=app.routes,
+    )
+    document.setdefault("components", {}).setdefault("securitySchemes", {})[BEARER_SCHEME] = {
+        "type": "http",
+        "scheme": "bearer",
+        "bearerFormat": "JWT",
+        "description": "Tenant token (JWT with a tenant_id claim)",
+    }
+    for route in app.routes:
+        if not isinstance(route, APIRoute) or not route.include_in_schema:
+            continue
+        security = route_security(route)
+        if security is None:
+            continue
+        operations = document["paths"].get(route.path_format, {})
+        for method in route.methods:
+            operation = operations.get(method.lower())
+            if operation is not None:
+                operation["security"] = security
+    return document
+
+
+def install_openapi(app: FastAPI) -> None:
+    """Serve build_openapi's document at the app's openapi_url (built once)."""
+
+    def openapi() -> Dict[str, Any]:
+        if app.openapi_schema is None:
+            app.openapi_schema = build_openapi(app)
+        return app.openapi_schema
+
+    app.openapi = openapi
+
+
+if __name__ == "__main__":
+    from src.main import app
+
+    print(json.dumps(app.openapi(), indent=2))
@@ -0,0 +1,88 @@
+"""Cursor (keyset) pagination for newest-first history queries.
+
+History tables are read in (timestamp, id) descending order, which is
+stable even while rows are inserted. A page ends with an opaque
+`next_cursor` naming the last row returned; passing it back continues
+strictly after that row, so pages never repeat or skip rows whatever was
+written in between (newer rows appear on a fresh first page).
+"""
+
+import base64
+import binascii
+import json
+from dataclasses import dataclass
+from datetime import datetime
+from typing import Callable, Generic, List, Optional, TypeVar
+
+from sqlalchemy import and_, or_
+from sqlalchemy.orm import Query
+
+T = TypeVar("T")
+
+
+@dataclass(frozen=True)
+class Cursor:
+    """Sort key of the last row of a page."""
+    at: datetime  # Row timestamp, as stored
+    id: int       # Row primary key (tie-break between equal timestamps)
+
+    def encode(self) -> str:
+        payload = json.dumps({"at": self.at.isoformat(), "id": self.id}, separators=(",", ":"))
+        return base64.urlsafe_b64encode(payload.encode()).decode().rstrip("=")
+
+
+def decode_cursor(token: str) -> Cursor:
+    """Cursor of a next_cursor token.
+
+    Raises:
+        ValueError: If the token was not produced by Cursor.encode
+    """
+    try:
+        padded = token.strip() + "=" * (-len(token.strip()) % 4)
+        payload = json.loads(base64.urlsafe_b64decode(padded.encode()))
+        cursor = Cursor(datetime.fromisoformat(payload["at"]), int(payload["id"]))
+    except (binascii.Error, UnicodeDecodeError, ValueError, TypeError, KeyError):
+        raise ValueError("Invalid cursor")
+    return cursor
+
+
+@dataclass
+class Page(Generic[T]):
+    """One page of a history query."""
+    items: List[T]
+    next_cursor: Optional[str] = None  # None on the last page
+
+
+def keyset_page(
+    query: Query,
+    time_column,
+    id_column,
+    limit: int,
+    cursor: Optional[str],
+    to_item: Callable[[object], T],
+) -> Page[T]:
+    """Newest-first page of a query, continuing after a cursor.
+
+    Args:
+        query: Filtered query (not yet ordered or limited)
+        time_column: Timestamp column rows are ordered by
+        id_column: Integer primary key breaking timestamp ties
+        limit: Most rows on the page
+        cursor: next_cursor of the previous page (None for the first page)
+        to_item: Converts a row to a page item
+
+    Raises:
+        ValueError: If the cursor is invalid
+    """
+    if cursor is not None:
+        after = decode_cursor(cursor)
+        query = query.filter(
+            or_(time_column < after.at, and_(time_column == after.at, id_column < after.id))
+        )
+    rows = query.order_by(time_column.desc(), id_column.desc()).limit(limit + 1).all()
+    next_cursor = None
+    if len(rows) > limit:
+        rows = rows[:limit]
+        last = rows[-1]
+        next_cursor = Cursor(getattr(last, time_column.key), getattr(last, id_column.key)).encode()
+    return Page([to_item(row) for row in rows], next_cursor)
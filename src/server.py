@@ -0,0 +1,62 @@
+"""Run the API server, with TLS and client certificates when configured.
+
+    python -m src.server --host 0.0.0.0 --port 8443
+
+Without TLS_CERT_FILE this is plain `uvicorn src.main:app`. With it the
+listener serves HTTPS; with TLS_CLIENT_CA_FILE as well it asks callers
+for a client certificate, verifies it against those CAs and passes it on
+to the application, where MTLS_IDENTITIES maps it to a tenant (see
+src.services.mtls_service).
+"""
+
+import argparse
+import logging
+
+from src.config import Config, get_config
+from src.services.mtls_service import client_certificate_protocol, uvicorn_ssl_options
+
+logger = logging.getLogger(__name__)
+
+
+def server_options(config: Config) -> dict:
+    """uvicorn.Config keyword arguments for the configured listener.
+
+    Raises:
+        ValueError: If TLS is half configured or TLS_CLIENT_AUTH is unknown
+    """
+    if not config.tls_cert_file:
+        if config.tls_client_ca_file:
+            raise ValueError("TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE")
+        return {}
+    if not config.tls_key_file:
+        raise ValueError("TLS_CERT_FILE needs TLS_KEY_FILE")
+    options = uvicorn_ssl_options(
+        config.tls_cert_file,
+        config.tls_key_file,
+        client_ca_file=config.tls_client_ca_file,
+        client_auth=config.tls_client_auth,
+    )
+    if config.tls_client_ca_file:
+        from uvicorn.protocols.http.auto import AutoHTTPProtocol
+
+        options["http"] = client_certificate_protocol(AutoHTTPProtocol)
+    return options
+
+
+def main() -> None:
+    parser = argparse.ArgumentParser(description=__doc__.splitlines()[0])
+    parser.add_argument("--host", default="0.0.0.0")
+    parser.add_argument("--port", type=int, default=8000)
+    args = parser.parse_args()
+
+    import uvicorn
+
+    logging.basicConfig(level=logging.INFO)
+    options = server_options(get_config())
+    if options.get("ssl_ca_certs"):
+        logger.info(f"Requesting client certificates ({get_config().tls_client_auth})")
+    uvicorn.run("src.main:app", host=args.host, port=args.port, **options)
+
+
+if __name__ == "__main__":
+    main()
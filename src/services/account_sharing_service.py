@@ -0,0 +1,294 @@
+"""Account sharing detection across concurrent sessions.
+
+Every activity of a client session updates the session's last known
+location. A session is active until it has been idle for
+ACCOUNT_SHARING_SESSION_TTL_SECONDS. Each activity is compared with the
+account's other active sessions exactly like consecutive logins in
+impossible travel detection (accuracy radii subtracted,
+TRAVEL_MIN_DISTANCE_KM and TRAVEL_MAX_SPEED_KMH): one person cannot be in
+both places, so the account is flagged as shared (or compromised).
+
+A flag is current while its conflict keeps being observed, i.e. until no
+conflicting activity has been seen for a session TTL. Trust & safety
+teams list the currently flagged accounts of a tenant.
+"""
+
+import logging
+from dataclasses import dataclass, field
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import AccountSession, AccountSharingFlag
+from src.services.erasure_service import ErasureService
+from src.services.travel_service import TravelDetectionService, TravelPoint
+
+logger = logging.getLogger(__name__)
+
+
+def _utc(value: datetime) -> datetime:
+    """Naive UTC datetime (aware values are converted)."""
+    if value.tzinfo is not None:
+        value = value.astimezone(timezone.utc).replace(tzinfo=None)
+    return value
+
+
+def _to_point(row: AccountSession) -> TravelPoint:
+    return TravelPoint(
+        latitude=row.latitude,
+        longitude=row.longitude,
+        observed_at=row.last_seen_at,
+        accuracy_km=row.accuracy_km,
+        ip_address=row.ip_address,
+        country_code=row.country_code,
+    )
+
+
+@dataclass
+class SessionConflict:
+    """Another active session too far from the current one."""
+    session_id: str
+    location: TravelPoint  # Its last known location
+    distance_km: float
+    speed_kmh: Optional[float]  # None if both were seen at the same instant
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "session_id": self.session_id,
+            "distance_km": self.distance_km,
+            "speed_kmh": self.speed_kmh,
+            "location": self.location.to_dict(),
+        }
+
+
+@dataclass
+class SharingAssessment:
+    """Comparison of a session's activity with the account's other sessions."""
+    account_id: str
+    session_id: str
+    active_sessions: int  # Including this one
+    conflicts: List[SessionConflict] = field(default_factory=list)  # Farthest first
+
+    @property
+    def flagged(self) -> bool:
+        """Whether the activity conflicts with another session."""
+        return bool(self.conflicts)
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "account_id": self.account_id,
+            "session_id": self.session_id,
+            "flagged": self.flagged,
+            "active_sessions": self.active_sessions,
+            "conflicts": [conflict.to_dict() for conflict in self.conflicts],
+        }
+
+
+@dataclass
+class SharingFlag:
+    """Currently flagged account."""
+    account_id: str
+    session_ids: List[str]  # The conflicting pair
+    distance_km: float
+    speed_kmh: Optional[float]
+    country_codes: List[Optional[str]]  # Of the pair, in session_ids order
+    flagged_at: datetime  # UTC
+    last_detected_at: datetime  # UTC
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "account_id": self.account_id,
+            "session_ids": self.session_ids,
+            "distance_km": self.distance_km,
+            "speed_kmh": self.speed_kmh,
+            "country_codes": self.country_codes,
+            "flagged_at": self.flagged_at.replace(tzinfo=timezone.utc),
+            "last_detected_at": self.last_detected_at.replace(tzinfo=timezone.utc),
+        }
+
+
+def _to_flag(row: AccountSharingFlag) -> SharingFlag:
+    return SharingFlag(
+        account_id=row.account_id,
+        session_ids=[row.session_id, row.other_session_id],
+        distance_km=row.distance_km,
+        speed_kmh=row.speed_kmh,
+        country_codes=[row.country_code, row.other_country_code],
+        flagged_at=row.flagged_at,
+        last_detected_at=row.last_detected_at,
+    )
+
+
+class AccountSharingService:
+    """Tracks active sessions per account and flags implausibly distant ones."""
+
+    def __init__(
+        self,
+        session: Session,
+        session_ttl_seconds: Optional[float] = None,
+        max_speed_kmh: Optional[float] = None,
+        min_distance_km: Optional[float] = None,
+    ):
+        """Initialize account sharing service.
+
+        Args:
+            session: SQLAlchemy database session
+            session_ttl_seconds: Idle time after which a session is no
+                longer active (default ACCOUNT_SHARING_SESSION_TTL_SECONDS)
+            max_speed_kmh: Fastest plausible travel (default TRAVEL_MAX_SPEED_KMH)
+            min_distance_km: Shortest distance, after accuracy radii, that
+                can be flagged (default TRAVEL_MIN_DISTANCE_KM)
+        """
+        from src.config import get_config
+
+        config = get_config()
+        self.session = session
+        self.ttl = timedelta(
+            seconds=session_ttl_seconds
+            if session_ttl_seconds is not None
+            else config.account_sharing_session_ttl_seconds
+        )
+        self.travel = TravelDetectionService(session, max_speed_kmh, min_distance_km)
+
+    def _active_since(self, now: datetime) -> datetime:
+        return now - self.ttl
+
+    def observe(
+        self,
+        account_id: str,
+        session_id: str,
+        point: TravelPoint,
+        tenant_id: Optional[str] = None,
+    ) -> SharingAssessment:
+        """Record activity of a session and compare it with the account's other sessions.
+
+        Activity of erased accounts is assessed but not recorded.
+
+        Args:
+            account_id: Account identifier (scoped to the tenant)
+            session_id: Client session identifier
+            point: Location and time of the activity (naive times are UTC)
+            tenant_id: Calling tenant, if any
+
+        Returns:
+            SharingAssessment
+
+        Raises:
+            ValueError: If an ID or the coordinate is invalid, or the
+                session cannot be stored
+        """
+        account_id = (account_id or "").strip()
+        session_id = (session_id or "").strip()
+        if not account_id or not session_id:
+            raise ValueError("account_id and session_id must not be empty")
+        if not -90 <= point.latitude <= 90 or not -180 <= point.longitude <= 180:
+            raise ValueError(f"Coordinate out of range: ({point.latitude}, {point.longitude})")
+        now = point.observed_at = _utc(point.observed_at)
+
+        rows = (
+            self.session.query(AccountSession)
+            .filter(AccountSession.tenant_id == tenant_id, AccountSession.account_id == account_id)
+            .all()
+        )
+        current = next((row for row in rows if row.session_id == session_id), None)
+        conflicts: List[SessionConflict] = []
+        active = 1
+        for row in rows:
+            if row is current or row.last_seen_at < self._active_since(now):
+                continue
+            active += 1
+            assessment = self.travel.assess(account_id, _to_point(row), point)
+            if assessment.impossible:
+                conflicts.append(SessionConflict(
+                    row.session_id, assessment.previous, assessment.distance_km, assessment.speed_kmh
+                ))
+        conflicts.sort(key=lambda conflict: conflict.distance_km, reverse=True)
+        if ErasureService(self.session).is_erased(tenant_id, account_id):
+            return SharingAssessment(account_id, session_id, active, conflicts)
+
+        try:
+            for row in rows:
+                if row is not current and row.last_seen_at < self._active_since(now):
+                    self.session.delete(row)
+            if current is None:
+                current = AccountSession(
+                    tenant_id=tenant_id,
+                    account_id=account_id,
+                    session_id=session_id,
+                    started_at=now,
+                    last_seen_at=now,
+                )
+                self.session.add(current)
+            if now >= current.last_seen_at:
+                current.latitude, current.longitude = point.latitude, point.longitude
+                current.accuracy_km = point.accuracy_km
+                current.ip_address, current.country_code = point.ip_address, point.country_code
+                current.last_seen_at = now
+            if conflicts:
+                self._flag(tenant_id, account_id, session_id, point, conflicts[0], now)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store account session: {str(e)}")
+
+        if conflicts:
+            logger.warning(
+                f"Account {account_id} active {conflicts[0].distance_km} km apart in sessions "
+                f"{session_id} and {conflicts[0].session_id}; likely shared"
+            )
+        return SharingAssessment(account_id, session_id, active, conflicts)
+
+    def _flag(
+        self,
+        tenant_id: Optional[str],
+        account_id: str,
+        session_id: str,
+        point: TravelPoint,
+        conflict: SessionConflict,
+        now: datetime,
+    ) -> None:
+        row = (
+            self.session.query(AccountSharingFlag)
+            .filter(AccountSharingFlag.tenant_id == tenant_id, AccountSharingFlag.account_id == account_id)
+            .first()
+        )
+        if row is None:
+            row = AccountSharingFlag(tenant_id=tenant_id, account_id=account_id, flagged_at=now)
+            self.session.add(row)
+        elif row.last_detected_at < self._active_since(now):
+            # The previous flag had lapsed; this is a new incident
+            row.flagged_at = now
+        row.session_id, row.other_session_id = session_id, conflict.session_id
+        row.distance_km, row.speed_kmh = conflict.distance_km, conflict.speed_kmh
+        row.country_code, row.other_country_code = point.country_code, conflict.location.country_code
+        row.last_detected_at = max(now, row.last_detected_at or now)
+
+    def flagged(
+        self, tenant_id: Optional[str] = None, limit: int = 100, now: Optional[datetime] = None
+    ) -> List[SharingFlag]:
+        """Currently flagged accounts of a tenant, most recently detected first.
+
+        Args:
+            tenant_id: Owning tenant
+            limit: Most accounts returned
+            now: Reference time (default: now)
+
+        Returns:
+            list of SharingFlag
+        """
+        now = _utc(now) if now is not None else datetime.utcnow()
+        rows = (
+            self.session.query(AccountSharingFlag)
+            .filter(
+                AccountSharingFlag.tenant_id == tenant_id,
+                AccountSharingFlag.last_detected_at >= self._active_since(now),
+            )
+            .order_by(AccountSharingFlag.last_detected_at.desc(), AccountSharingFlag.id.desc())
+            .limit(limit)
+            .all()
+        )
+        return [_to_flag(row) for row in rows]
@@ -0,0 +1,163 @@
+"""Accuracy radius and confidence scoring for IP lookups.
+
+GeoIP records resolve to different depths: some name a postal code, others
+only a country. The estimate combines that granularity with the age of the
+dataset the record came from:
+
+- The accuracy radius is the dataset's own radius when it reports one, and
+  otherwise a typical radius for the record's granularity.
+- The confidence score (0-1) starts from the granularity's base score and
+  halves every GEOIP_CONFIDENCE_HALF_LIFE_DAYS of dataset age, since IP
+  allocations drift as networks are reassigned.
+"""
+
+import time
+from dataclasses import dataclass
+from typing import Optional
+
+from src.services.mmdb_service import GeoIPRecord
+
+
+class Granularity:
+    """Most specific level a GeoIP record resolves to."""
+    SITE = "site"                # Tenant-registered IP range override
+    POSTAL = "postal"
+    CITY = "city"
+    SUBDIVISION = "subdivision"
+    COUNTRY = "country"
+    CONTINENT = "continent"
+
+
+# Typical radius (km) of a location known only to this level
+DEFAULT_RADIUS_KM = {
+    Granularity.SITE: 1.0,
+    Granularity.POSTAL: 5.0,
+    Granularity.CITY: 25.0,
+    Granularity.SUBDIVISION: 200.0,
+    Granularity.COUNTRY: 1000.0,
+    Granularity.CONTINENT: 3000.0,
+}
+
+# Confidence of a record from a freshly built dataset
+BASE_CONFIDENCE = {
+    Granularity.SITE: 1.0,
+    Granularity.POSTAL: 0.95,
+    Granularity.CITY: 0.85,
+    Granularity.SUBDIVISION: 0.6,
+    Granularity.COUNTRY: 0.4,
+    Granularity.CONTINENT: 0.1,
+}
+
+_SECONDS_PER_DAY = 86400.0
+
+
+def record_granularity(record: GeoIPRecord) -> Optional[str]:
+    """Most specific level a record names.
+
+    Returns:
+        Granularity value, or None if the record names no place at all
+    """
+    if record.postal_code and record.city_name:
+        return Granularity.POSTAL
+    if record.city_name:
+        return Granularity.CITY
+    if record.subdivisions:
+        return Granularity.SUBDIVISION
+    if record.country_iso_code:
+        return Granularity.COUNTRY
+    if record.continent_code:
+        return Granularity.CONTINENT
+    return None
+
+
+@dataclass
+class AccuracyEstimate:
+    """Estimated precision of a GeoIP record."""
+    granularity: Optional[str]
+    accuracy_radius_km: Optional[float]
+    confidence: float            # 0-1
+    dataset_age_days: Optional[float] = None
+
+    @property
+    def confidence_flag(self) -> str:
+        """Map confidence to the flags used for detections."""
+        if self.confidence >= 0.75:
+            return "GREEN"
+        elif self.confidence >= 0.50:
+            return "YELLOW"
+        else:
+            return "RED"
+
+
+class AccuracyEstimator:
+    """Scores GeoIP records by granularity and dataset age."""
+
+    def __init__(self, half_life_days: float = 365.0):
+        """Initialize estimator.
+
+        Args:
+            half_life_days: Dataset age at which confidence halves (0 disables aging)
+        """
+        self.half_life_days = half_life_days
+
+    def estimate(
+        self,
+        record: GeoIPRecord,
+        build_epoch: Optional[int] = None,
+        now: Optional[float] = None,
+        granularity: Optional[str] = None,
+    ) -> AccuracyEstimate:
+        """Estimate the accuracy radius and confidence of a record.
+
+        Args:
+            record: GeoIP record
+            build_epoch: Build time of the dataset the record came from
+                (Unix seconds); unknown ages are not penalized
+            now: Current time (Unix seconds), for tests
+            granularity: Known granularity, instead of inferring it from
+                the record (e.g. Granularity.SITE for overrides)
+
+        Returns:
+            AccuracyEstimate
+        """
+        if granularity is None:
+            granularity = record_granularity(record)
+        radius = record.accuracy_radius_km
+        if not radius and granularity is not None:
+            radius = DEFAULT_RADIUS_KM[granularity]
+
+        age_days = None
+        if build_epoch:
+            now = time.time() if now is None else now
+            age_days = max(0.0, (now - build_epoch) / _SECONDS_PER_DAY)
+
+        confidence = BASE_CONFIDENCE.get(granularity, 0.0)
+        if age_days is not None and self.half_life_days > 0:
+            confidence *= 0.5 ** (age_days / self.half_life_days)
+
+        return AccuracyEstimate(
+            granularity=granularity,
+            accuracy_radius_km=radius,
+            confidence=round(confidence, 3),
+            dataset_age_days=round(age_days, 1) if age_days is not None else None,
+        )
+
+
+# Global estimator (half-life from configuration)
+_accuracy_estimator: Optional[AccuracyEstimator] = None
+
+
+def get_accuracy_estimator() -> AccuracyEstimator:
+    """Get the global accuracy estimator.
+
+    Returns:
+        AccuracyEstimator using GEOIP_CONFIDENCE_HALF_LIFE_DAYS
+    """
+    global _accuracy_estimator
+    if _accuracy_estimator is None:
+        from src.config import get_config
+
+        _accuracy_estimator = AccuracyEstimator(
+            half_life_days=get_config().geoip_confidence_half_life_days
+        )
+    return _accuracy_estimator
@@ -0,0 +1,318 @@
+"""Location anomaly detection over a user's login history.
+
+A user's profile is trained from their stored logins (the impossible
+travel history): the centroid of the logins (mean of their unit vectors
+on the sphere), the median distance of the logins from it, and the
+median absolute deviation (MAD) of those distances. A new event is scored
+with the modified z-score
+
+    z = 0.6745 * (distance - median) / max(MAD, ANOMALY_MIN_MAD_KM)
+
+and flagged when z exceeds ANOMALY_THRESHOLD (3.5 by the Iglewicz and
+Hoaglin rule). The MAD floor keeps users who always log in from one place
+from being flagged for crossing town. Profiles with fewer than
+ANOMALY_MIN_SAMPLES logins give no verdict; they, and profiles older
+than ANOMALY_PROFILE_MAX_AGE_HOURS, are retrained when evaluated.
+
+Train every user with `python -m src.services.anomaly_service`.
+"""
+
+import logging
+import math
+from dataclasses import dataclass
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import LoginLocation, UserLocationProfile
+from src.spatial.distance import haversine
+
+logger = logging.getLogger(__name__)
+
+# Scales the MAD to the standard deviation of normally distributed data
+MAD_CONSISTENCY = 0.6745
+
+
+@dataclass
+class LocationProfile:
+    """Location pattern of one user."""
+    user_id: str
+    centroid_lat: float
+    centroid_lon: float
+    median_distance_km: float
+    mad_km: float
+    samples: int
+    trained_at: datetime  # UTC
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "user_id": self.user_id,
+            "centroid_lat": self.centroid_lat,
+            "centroid_lon": self.centroid_lon,
+            "median_distance_km": self.median_distance_km,
+            "mad_km": self.mad_km,
+            "samples": self.samples,
+            "trained_at": self.trained_at.replace(tzinfo=timezone.utc),
+        }
+
+
+@dataclass
+class AnomalyAssessment:
+    """Score of one event against the user's profile."""
+    user_id: str
+    anomalous: bool
+    distance_km: Optional[float]  # From the profile centroid (None without a profile)
+    score: Optional[float]  # Modified z-score (None without enough history)
+    threshold: float
+    samples: int  # Logins behind the profile
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "user_id": self.user_id,
+            "anomalous": self.anomalous,
+            "distance_km": self.distance_km,
+            "score": self.score,
+            "threshold": self.threshold,
+            "samples": self.samples,
+        }
+
+
+def _median(values: Sequence[float]) -> float:
+    ordered = sorted(values)
+    middle = len(ordered) // 2
+    if len(ordered) % 2:
+        return ordered[middle]
+    return (ordered[middle - 1] + ordered[middle]) / 2.0
+
+
+def spherical_centroid(points: Sequence[Tuple[float, float]]) -> Tuple[float, float]:
+    """Centroid of (lat, lon) points as the normalized mean of their unit vectors.
+
+    Raises:
+        ValueError: If there are no points
+    """
+    if not points:
+        raise ValueError("At least one point is required")
+    x = y = z = 0.0
+    for lat, lon in points:
+        phi, lam = math.radians(lat), math.radians(lon)
+        x += math.cos(phi) * math.cos(lam)
+        y += math.cos(phi) * math.sin(lam)
+        z += math.sin(phi)
+    if math.sqrt(x * x + y * y + z * z) < 1e-9:
+        # Points cancel out (e.g. antipodal pairs); no meaningful center
+        return points[0]
+    return math.degrees(math.atan2(z, math.hypot(x, y))), math.degrees(math.atan2(y, x))
+
+
+def _distance_km(lat1: float, lon1: float, lat2: float, lon2: float) -> float:
+    return haversine(lat1, lon1, lat2, lon2).meters / 1000.0
+
+
+def fit_profile(user_id: str, points: Sequence[Tuple[float, float]], trained_at: datetime) -> LocationProfile:
+    """Fit a profile to (lat, lon) login locations.
+
+    Raises:
+        ValueError: If there are no points
+    """
+    lat, lon = spherical_centroid(points)
+    distances = [_distance_km(lat, lon, p_lat, p_lon) for p_lat, p_lon in points]
+    median = _median(distances)
+    mad = _median([abs(d - median) for d in distances])
+    return LocationProfile(
+        user_id=user_id,
+        centroid_lat=round(lat, 6),
+        centroid_lon=round(lon, 6),
+        median_distance_km=round(median, 3),
+        mad_km=round(mad, 3),
+        samples=len(points),
+        trained_at=trained_at,
+    )
+
+
+def _to_profile(row: UserLocationProfile) -> LocationProfile:
+    return LocationProfile(
+        user_id=row.user_id,
+        centroid_lat=row.centroid_lat,
+        centroid_lon=row.centroid_lon,
+        median_distance_km=row.median_distance_km,
+        mad_km=row.mad_km,
+        samples=row.samples,
+        trained_at=row.trained_at,
+    )
+
+
+class LocationAnomalyDetector:
+    """Trains per-user location profiles and scores events against them."""
+
+    def __init__(
+        self,
+        session: Session,
+        threshold: Optional[float] = None,
+        min_samples: Optional[int] = None,
+        min_mad_km: Optional[float] = None,
+        history_limit: Optional[int] = None,
+        max_profile_age: Optional[timedelta] = None,
+    ):
+        """Initialize anomaly detector.
+
+        Args:
+            session: SQLAlchemy database session
+            threshold: Modified z-score above which an event is flagged
+                (default ANOMALY_THRESHOLD)
+            min_samples: Fewest logins a profile needs to give a verdict
+                (default ANOMALY_MIN_SAMPLES)
+            min_mad_km: Floor of the MAD when scoring (default ANOMALY_MIN_MAD_KM)
+            history_limit: Most recent logins trained on (default ANOMALY_HISTORY_LIMIT)
+            max_profile_age: Age at which profiles are retrained when
+                evaluated (default ANOMALY_PROFILE_MAX_AGE_HOURS)
+        """
+        from src.config import get_config
+
+        config = get_config()
+        self.session = session
+        self.threshold = threshold if threshold is not None else config.anomaly_threshold
+        self.min_samples = min_samples if min_samples is not None else config.anomaly_min_samples
+        self.min_mad_km = min_mad_km if min_mad_km is not None else config.anomaly_min_mad_km
+        self.history_limit = history_limit if history_limit is not None else config.anomaly_history_limit
+        self.max_profile_age = (
+            max_profile_age
+            if max_profile_age is not None
+            else timedelta(hours=config.anomaly_profile_max_age_hours)
+        )
+
+    def _profile_row(self, user_id: str, tenant_id: Optional[str]) -> Optional[UserLocationProfile]:
+        return (
+            self.session.query(UserLocationProfile)
+            .filter(UserLocationProfile.tenant_id == tenant_id, UserLocationProfile.user_id == user_id)
+            .first()
+        )
+
+    def profile(self, user_id: str, tenant_id: Optional[str] = None) -> Optional[LocationProfile]:
+        """Stored profile of a user (None if never trained)."""
+        row = self._profile_row(user_id, tenant_id)
+        return _to_profile(row) if row is not None else None
+
+    def train(self, user_id: str, tenant_id: Optional[str] = None) -> LocationProfile:
+        """Train and store a user's profile from their most recent logins.
+
+        Raises:
+            ValueError: If the user ID is empty or the profile cannot be stored
+            LookupError: If the user has no stored logins
+        """
+        user_id = (user_id or "").strip()
+        if not user_id:
+            raise ValueError("user_id must not be empty")
+        logins = (
+            self.session.query(LoginLocation.latitude, LoginLocation.longitude)
+            .filter(LoginLocation.tenant_id == tenant_id, LoginLocation.user_id == user_id)
+            .order_by(LoginLocation.observed_at.desc(), LoginLocation.id.desc())
+            .limit(self.history_limit)
+            .all()
+        )
+        if not logins:
+            raise LookupError(f"No logins stored for user {user_id}")
+        profile = fit_profile(user_id, [(lat, lon) for lat, lon in logins], datetime.utcnow())
+
+        try:
+            row = self._profile_row(user_id, tenant_id)
+            if row is None:
+                row = UserLocationProfile(tenant_id=tenant_id, user_id=user_id)
+                self.session.add(row)
+            row.centroid_lat = profile.centroid_lat
+            row.centroid_lon = profile.centroid_lon
+            row.median_distance_km = profile.median_distance_km
+            row.mad_km = profile.mad_km
+            row.samples = profile.samples
+            row.trained_at = profile.trained_at
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store location profile: {str(e)}")
+        return profile
+
+    def train_all(self) -> int:
+        """Retrain the profile of every user with stored logins.
+
+        Returns:
+            Number of profiles trained
+        """
+        users: List[Tuple[Optional[str], str]] = (
+            self.session.query(LoginLocation.tenant_id, LoginLocation.user_id).distinct().all()
+        )
+        for tenant_id, user_id in users:
+            self.train(user_id, tenant_id)
+        return len(users)
+
+    def score(self, profile: LocationProfile, latitude: float, longitude: float) -> AnomalyAssessment:
+        """Score a location against a profile without touching the database.
+
+        Raises:
+            ValueError: If the coordinate is out of range
+        """
+        if not -90 <= latitude <= 90 or not -180 <= longitude <= 180:
+            raise ValueError(f"Coordinate out of range: ({latitude}, {longitude})")
+        distance_km = _distance_km(profile.centroid_lat, profile.centroid_lon, latitude, longitude)
+        if profile.samples < self.min_samples:
+            return AnomalyAssessment(
+                profile.user_id, False, round(distance_km, 1), None, self.threshold, profile.samples
+            )
+        spread = max(profile.mad_km, self.min_mad_km)
+        z = MAD_CONSISTENCY * (distance_km - profile.median_distance_km) / spread
+        return AnomalyAssessment(
+            user_id=profile.user_id,
+            anomalous=z > self.threshold,
+            distance_km=round(distance_km, 1),
+            score=round(z, 2),
+            threshold=self.threshold,
+            samples=profile.samples,
+        )
+
+    def evaluate(
+        self, user_id: str, latitude: float, longitude: float, tenant_id: Optional[str] = None
+    ) -> AnomalyAssessment:
+        """Score an event against the user's profile, training it if missing or stale.
+
+        Call before the event itself is stored as a login, so that it is
+        compared with the history before it.
+
+        Raises:
+            ValueError: If the user ID or coordinate is invalid
+        """
+        user_id = (user_id or "").strip()
+        if not user_id:
+            raise ValueError("user_id must not be empty")
+        profile = self.profile(user_id, tenant_id)
+        if (
+            profile is None
+            or profile.samples < self.min_samples
+            or datetime.utcnow() - profile.trained_at > self.max_profile_age
+        ):
+            try:
+                profile = self.train(user_id, tenant_id)
+            except LookupError:
+                if not -90 <= latitude <= 90 or not -180 <= longitude <= 180:
+                    raise ValueError(f"Coordinate out of range: ({latitude}, {longitude})")
+                return AnomalyAssessment(user_id, False, None, None, self.threshold, 0)
+
+        assessment = self.score(profile, latitude, longitude)
+        if assessment.anomalous:
+            logger.warning(
+                f"Location anomaly for user {user_id}: {assessment.distance_km} km from usual "
+                f"area (z={assessment.score})"
+            )
+        return assessment
+
+
+if __name__ == "__main__":
+    from src.database import get_db_manager
+
+    logging.basicConfig(level=logging.INFO)
+    manager = get_db_manager()
+    manager.create_all()
+    with manager.session_scope() as session:
+        count = LocationAnomalyDetector(session).train_all()
+    print(f"Trained {count} location profiles")
@@ -0,0 +1,206 @@
+"""Anonymizer (VPN / proxy / residential proxy / Tor / hosting) detection for IP lookups.
+
+Signals are combined from several sources, any of which may be absent:
+- Known VPN, public proxy and residential proxy endpoint CIDR lists
+- The Tor bulk exit list (plain IP per line, or `ExitAddress` records)
+- A GeoIP2-Anonymous-IP database
+- Hosting ASNs and hosting-provider AS organization names
+
+A flag is None when no loaded source can check it, so "not checked" is
+never reported as "clean".
+"""
+
+import logging
+from typing import Any, Dict, Optional
+
+from src.services.asn_service import AsnEnricher, ConnectionType
+from src.services.datacenter_service import HOSTING_ASN_PROVIDERS
+from src.services.enrichment_service import Enricher
+from src.services.ip_lookup_service import IpLookupResult, IpRangeSet
+from src.services.mmdb_service import MMDBReader
+
+logger = logging.getLogger(__name__)
+
+# Well-known hosting/cloud ASNs (shared with datacenter classification)
+DEFAULT_HOSTING_ASNS = frozenset(HOSTING_ASN_PROVIDERS)
+
+
+def load_tor_exit_list(path: str) -> IpRangeSet:
+    """Load a Tor exit list.
+
+    Accepts both the bulk exit list (one address per line) and the
+    exit-addresses format ("ExitAddress 1.2.3.4 2026-02-10 12:00:00").
+
+    Args:
+        path: File path
+
+    Returns:
+        IpRangeSet of exit node addresses
+    """
+    addresses = []
+    with open(path) as f:
+        for line in f:
+            line = line.split("#", 1)[0].strip()
+            if not line:
+                continue
+            parts = line.split()
+            if parts[0] == "ExitAddress" and len(parts) > 1:
+                addresses.append(parts[1])
+            elif len(parts) == 1:
+                addresses.append(parts[0])
+    return IpRangeSet(addresses)
+
+
+class AnonymizerEnricher(Enricher):
+    """Flags VPN, Tor, proxy, residential proxy and hosting traffic."""
+
+    name = "anonymizer"
+
+    def __init__(
+        self,
+        vpn_ranges: Optional[IpRangeSet] = None,
+        proxy_ranges: Optional[IpRangeSet] = None,
+        tor_exits: Optional[IpRangeSet] = None,
+        residential_proxy_ranges: Optional[IpRangeSet] = None,
+        anonymous_ip_reader: Optional[MMDBReader] = None,
+        asn_enricher: Optional[AsnEnricher] = None,
+        hosting_asns=DEFAULT_HOSTING_ASNS,
+    ):
+        """Initialize anonymizer enricher.
+
+        Args:
+            vpn_ranges: Known VPN provider networks
+            proxy_ranges: Known public proxy networks
+            tor_exits: Tor exit node addresses
+            residential_proxy_ranges: Known residential proxy endpoints
+            anonymous_ip_reader: Optional GeoIP2-Anonymous-IP database
+            asn_enricher: ASN enricher used for hosting heuristics
+            hosting_asns: ASNs treated as hosting providers
+        """
+        self.vpn_ranges = vpn_ranges
+        self.proxy_ranges = proxy_ranges
+        self.tor_exits = tor_exits
+        self.residential_proxy_ranges = residential_proxy_ranges
+        self.anonymous_ip_reader = anonymous_ip_reader
+        self.asn_enricher = asn_enricher
+        self.hosting_asns = frozenset(hosting_asns)
+
+    @property
+    def has_sources(self) -> bool:
+        """True if at least one anonymizer data source is loaded."""
+        return any(
+            source is not None
+            for source in (
+                self.vpn_ranges,
+                self.proxy_ranges,
+                self.tor_exits,
+                self.residential_proxy_ranges,
+                self.anonymous_ip_reader,
+                self.asn_enricher,
+            )
+        )
+
+    def enrich(self, result: IpLookupResult) -> Optional[Dict[str, Any]]:
+        """Compute anonymizer flags for the looked-up address.
+
+        Returns:
+            dict of vpn/tor/proxy/residential_proxy/hosting flags (None for flags no loaded
+            source covers), or None if no source is loaded at all
+        """
+        if not self.has_sources:
+            return None
+
+        # Tor exits and VPN endpoints are matched on the address the client
+        # connected from as well as any embedded IPv4 address
+        addresses = {result.normalized.original, result.normalized.address}
+
+        def _listed(ranges: Optional[IpRangeSet]) -> Optional[bool]:
+            if ranges is None:
+                return None
+            return any(a in ranges for a in addresses)
+
+        flags = {
+            "vpn": _listed(self.vpn_ranges),
+            "tor": _listed(self.tor_exits),
+            "proxy": _listed(self.proxy_ranges),
+            "residential_proxy": _listed(self.residential_proxy_ranges),
+            "hosting": None,
+        }
+
+        if self._anonymous_ip_checkable(result):
+            # An address missing from the database is checked and clean
+            dataset = self._anonymous_ip_record(result) or {}
+            flags["vpn"] = bool(flags["vpn"] or dataset.get("is_anonymous_vpn"))
+            flags["tor"] = bool(flags["tor"] or dataset.get("is_tor_exit_node"))
+            flags["proxy"] = bool(flags["proxy"] or dataset.get("is_public_proxy"))
+            flags["residential_proxy"] = bool(
+                flags["residential_proxy"] or dataset.get("is_residential_proxy")
+            )
+            flags["hosting"] = bool(dataset.get("is_hosting_provider"))
+
+        # Without an ASN record the hosting heuristic cannot be applied
+        if not flags["hosting"] and self.asn_enricher is not None:
+            asn = self.asn_enricher.enrich(result)
+            if asn:
+                flags["hosting"] = (
+                    asn.get("number") in self.hosting_asns
+                    or asn.get("connection_type") == ConnectionType.HOSTING
+                )
+
+        return flags
+
+    def _anonymous_ip_checkable(self, result: IpLookupResult) -> bool:
+        if self.anonymous_ip_reader is None:
+            return False
+        return not (
+            result.normalized.address.version == 6
+            and self.anonymous_ip_reader.ip_version == 4
+        )
+
+    def _anonymous_ip_record(self, result: IpLookupResult) -> Optional[Dict[str, Any]]:
+        raw, _, _ = self.anonymous_ip_reader.lookup_raw(result.normalized.address)
+        return raw
+
+
+def build_anonymizer_enricher(
+    config, asn_enricher: Optional[AsnEnricher] = None
+) -> AnonymizerEnricher:
+    """Create the anonymizer enricher from configured data sources.
+
+    Missing or invalid sources are logged and skipped.
+
+    Args:
+        config: Application configuration
+        asn_enricher: ASN enricher for hosting heuristics, if available
+
+    Returns:
+        AnonymizerEnricher
+    """
+    def _load(path: str, loader, label: str) -> Optional[IpRangeSet]:
+        if not path:
+            return None
+        try:
+            ranges = loader(path)
+            logger.info(f"Loaded {len(ranges)} {label} entries from {path}")
+            return ranges
+        except (OSError, ValueError) as e:
+            logger.warning(f"{label} list unavailable: {e}")
+            return None
+
+    anonymous_ip_reader = None
+    if config.geoip_anonymous_ip_database_path:
+        try:
+            anonymous_ip_reader = MMDBReader(config.geoip_anonymous_ip_database_path)
+        except (OSError, ValueError) as e:
+            logger.warning(f"Anonymous IP database unavailable: {e}")
+
+    return AnonymizerEnricher(
+        vpn_ranges=_load(config.anonymizer_vpn_ranges_path, IpRangeSet.from_file, "VPN range"),
+        proxy_ranges=_load(config.anonymizer_proxy_ranges_path, IpRangeSet.from_file, "proxy range"),
+        tor_exits=_load(config.anonymizer_tor_exit_list_path, load_tor_exit_list, "Tor exit"),
+        residential_proxy_ranges=_load(
+            config.anonymizer_residential_proxy_ranges_path, IpRangeSet.from_file, "residential proxy"
+        ),
+        anonymous_ip_reader=anonymous_ip_reader,
+        asn_enricher=asn_enricher,
+    )
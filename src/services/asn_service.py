@@ -0,0 +1,159 @@
+"""ASN and ISP enrichment for IP lookups.
+
+Reads the autonomous system from a GeoLite2-ASN (or GeoIP2-ISP) database
+and classifies the connection as residential, business, cellular or
+hosting, preferring a GeoIP2-Connection-Type database when one is
+configured and falling back to organization-name heuristics.
+"""
+
+import logging
+import re
+from typing import Any, Dict, Optional
+
+from src.services.enrichment_service import Enricher
+from src.services.ip_lookup_service import IPAddress, IpLookupResult
+from src.services.mmdb_service import MMDBReader
+
+logger = logging.getLogger(__name__)
+
+
+class ConnectionType:
+    """Normalized connection types reported in the ASN block."""
+    RESIDENTIAL = "residential"
+    BUSINESS = "business"
+    CELLULAR = "cellular"
+    HOSTING = "hosting"
+
+
+# GeoIP2-Connection-Type values and GeoIP2 traits.user_type values
+_CONNECTION_TYPE_MAP = {
+    "dialup": ConnectionType.RESIDENTIAL,
+    "cable/dsl": ConnectionType.RESIDENTIAL,
+    "residential": ConnectionType.RESIDENTIAL,
+    "corporate": ConnectionType.BUSINESS,
+    "business": ConnectionType.BUSINESS,
+    "government": ConnectionType.BUSINESS,
+    "university": ConnectionType.BUSINESS,
+    "library": ConnectionType.BUSINESS,
+    "school": ConnectionType.BUSINESS,
+    "military": ConnectionType.BUSINESS,
+    "cellular": ConnectionType.CELLULAR,
+    "hosting": ConnectionType.HOSTING,
+    "content_delivery_network": ConnectionType.HOSTING,
+}
+
+# Organization name fragments that identify hosting/cloud networks
+_HOSTING_PATTERN = re.compile(
+    r"\b(amazon|aws|google cloud|microsoft|azure|digitalocean|ovh|hetzner|"
+    r"linode|akamai|vultr|cloudflare|oracle cloud|alibaba|tencent cloud|"
+    r"leaseweb|choopa|hosting|datacenter|data center|server|colo)\b",
+    re.IGNORECASE,
+)
+_CELLULAR_PATTERN = re.compile(
+    r"\b(mobile|wireless|cellular|telefonica movil|t-mobile|vodafone|verizon wireless)\b",
+    re.IGNORECASE,
+)
+
+
+def classify_organization(organization: Optional[str]) -> Optional[str]:
+    """Guess the connection type from an AS organization name.
+
+    Args:
+        organization: AS organization or ISP name
+
+    Returns:
+        ConnectionType value, or None if no heuristic matches
+    """
+    if not organization:
+        return None
+    if _HOSTING_PATTERN.search(organization):
+        return ConnectionType.HOSTING
+    if _CELLULAR_PATTERN.search(organization):
+        return ConnectionType.CELLULAR
+    return None
+
+
+class AsnEnricher(Enricher):
+    """Adds ASN number, organization, ISP and connection type."""
+
+    name = "asn"
+
+    def __init__(
+        self,
+        asn_reader: MMDBReader,
+        connection_type_reader: Optional[MMDBReader] = None,
+    ):
+        """Initialize ASN enricher.
+
+        Args:
+            asn_reader: GeoLite2-ASN or GeoIP2-ISP database
+            connection_type_reader: Optional GeoIP2-Connection-Type database
+        """
+        self.asn_reader = asn_reader
+        self.connection_type_reader = connection_type_reader
+
+    def enrich(self, result: IpLookupResult) -> Optional[Dict[str, Any]]:
+        """Look up the autonomous system for the normalized address."""
+        address = result.normalized.address
+        if address.version == 6 and self.asn_reader.ip_version == 4:
+            return None
+
+        raw, _, _ = self.asn_reader.lookup_raw(address)
+        if raw is None:
+            return None
+
+        organization = raw.get("autonomous_system_organization")
+        return {
+            "number": raw.get("autonomous_system_number"),
+            "organization": organization,
+            "isp": raw.get("isp") or organization,
+            "connection_type": self._connection_type(result, address, organization),
+        }
+
+    def _connection_type(
+        self, result: IpLookupResult, address: IPAddress, organization: Optional[str]
+    ) -> Optional[str]:
+        """Resolve connection type from dataset, GeoIP2 traits, then heuristics."""
+        if self.connection_type_reader is not None:
+            raw, _, _ = self.connection_type_reader.lookup_raw(address)
+            mapped = _CONNECTION_TYPE_MAP.get(
+                str((raw or {}).get("connection_type", "")).lower()
+            )
+            if mapped:
+                return mapped
+
+        if result.record is not None:
+            traits = result.record.raw.get("traits") or {}
+            for key in ("user_type", "connection_type"):
+                mapped = _CONNECTION_TYPE_MAP.get(str(traits.get(key, "")).lower())
+                if mapped:
+                    return mapped
+
+        return classify_organization(organization)
+
+
+def build_asn_enricher(config) -> Optional[AsnEnricher]:
+    """Create the ASN enricher from configured dataset paths.
+
+    Args:
+        config: Application configuration
+
+    Returns:
+        AsnEnricher, or None if the ASN database cannot be opened
+    """
+    try:
+        asn_reader = MMDBReader(config.geoip_asn_database_path)
+    except (OSError, ValueError) as e:
+        logger.warning(f"ASN enrichment disabled: {e}")
+        return None
+
+    connection_type_reader = None
+    if config.geoip_connection_type_database_path:
+        try:
+            connection_type_reader = MMDBReader(
+                config.geoip_connection_type_database_path
+            )
+        except (OSError, ValueError) as e:
+            logger.warning(f"Connection type database unavailable: {e}")
+
+    return AsnEnricher(asn_reader, connection_type_reader)
@@ -0,0 +1,293 @@
+"""Tamper-evident audit log of admin actions and detection decisions.
+
+Entries are only ever appended. Each one stores the hash of the entry
+before it and a SHA-256 over that hash and its own fields:
+
+    entry_hash = sha256(canonical JSON of prev_hash, entry_id, recorded_at,
+                        category, action, actor, tenant_id, resource,
+                        outcome, details)
+
+The first entry follows GENESIS_HASH. Editing, reordering or deleting an
+entry changes what the next one should point to, which verify() reports
+as the first broken link. Updates and deletes through the ORM are refused
+outright; the chain covers changes made to the table directly. Deleting
+the newest entries only shortens the chain, so keep the head hash
+returned by verify() somewhere outside the database (e.g. a daily export)
+to detect truncation.
+"""
+
+import hashlib
+import json
+import logging
+import uuid
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
+
+from sqlalchemy.exc import IntegrityError
+from sqlalchemy.orm import Session
+
+from src.models.database_models import AuditLogEntry
+from src.pagination import Page, keyset_page
+
+logger = logging.getLogger(__name__)
+
+# prev_hash of the first entry
+GENESIS_HASH = "0" * 64
+
+# Concurrent appends racing for the same predecessor retry this often
+_APPEND_ATTEMPTS = 5
+
+
+class AuditCategory:
+    """Kinds of audited events."""
+    ADMIN = "admin"
+    DETECTION = "detection"
+    SECURITY = "security"
+
+
+class AuditOutcome:
+    """Outcomes of audited events."""
+    SUCCESS = "success"   # Admin request completed
+    DENIED = "denied"     # Admin request refused (401/403)
+    FAILED = "failed"     # Admin request failed otherwise
+    FLAGGED = "flagged"   # Detection rule fired, or attack suspected
+    CLEAR = "clear"       # Detection rule did not fire
+
+
+def canonical_json(value: Any) -> str:
+    """JSON with sorted keys and no whitespace, as hashed."""
+    return json.dumps(value, sort_keys=True, separators=(",", ":"), default=str)
+
+
+def _utc(when: datetime) -> datetime:
+    if when.tzinfo is not None:
+        when = when.astimezone(timezone.utc).replace(tzinfo=None)
+    return when
+
+
+def compute_entry_hash(row: AuditLogEntry) -> str:
+    """Hash of an entry over its predecessor's hash and its own fields."""
+    payload = canonical_json({
+        "prev_hash": row.prev_hash,
+        "entry_id": row.entry_id,
+        "recorded_at": row.recorded_at.isoformat(),
+        "category": row.category,
+        "action": row.action,
+        "actor": row.actor,
+        "tenant_id": row.tenant_id,
+        "resource": row.resource,
+        "outcome": row.outcome,
+        "details": row.details,
+    })
+    return hashlib.sha256(payload.encode()).hexdigest()
+
+
+@dataclass
+class LogEntry:
+    """Stored audit log entry, detached from the database session."""
+    seq: int
+    entry_id: str
+    recorded_at: datetime  # UTC
+    category: str
+    action: str
+    outcome: str
+    prev_hash: str
+    entry_hash: str
+    actor: Optional[str] = None
+    tenant_id: Optional[str] = None
+    resource: Optional[str] = None
+    details: Dict[str, Any] = field(default_factory=dict)
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "seq": self.seq,
+            "entry_id": self.entry_id,
+            "recorded_at": self.recorded_at.replace(tzinfo=timezone.utc),
+            "category": self.category,
+            "action": self.action,
+            "actor": self.actor,
+            "tenant_id": self.tenant_id,
+            "resource": self.resource,
+            "outcome": self.outcome,
+            "details": self.details,
+            "prev_hash": self.prev_hash,
+            "entry_hash": self.entry_hash,
+        }
+
+
+def _to_entry(row: AuditLogEntry) -> LogEntry:
+    return LogEntry(
+        seq=row.id,
+        entry_id=row.entry_id,
+        recorded_at=row.recorded_at,
+        category=row.category,
+        action=row.action,
+        outcome=row.outcome,
+        prev_hash=row.prev_hash,
+        entry_hash=row.entry_hash,
+        actor=row.actor,
+        tenant_id=row.tenant_id,
+        resource=row.resource,
+        details=json.loads(row.details),
+    )
+
+
+@dataclass
+class ChainVerification:
+    """Result of checking the audit log's hash chain."""
+    valid: bool
+    entries: int                 # Entries checked (up to the first broken one)
+    head_hash: str               # Hash of the last valid entry (GENESIS_HASH if none)
+    broken_seq: Optional[int] = None
+    broken_entry_id: Optional[str] = None
+    reason: Optional[str] = None
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "valid": self.valid,
+            "entries": self.entries,
+            "head_hash": self.head_hash,
+            "broken_seq": self.broken_seq,
+            "broken_entry_id": self.broken_entry_id,
+            "reason": self.reason,
+        }
+
+
+class AuditLogService:
+    """Appends to, queries and verifies the audit log."""
+
+    def __init__(self, session: Session):
+        """Initialize service.
+
+        Args:
+            session: SQLAlchemy session
+        """
+        self.session = session
+
+    def append(
+        self,
+        category: str,
+        action: str,
+        outcome: str,
+        actor: Optional[str] = None,
+        tenant_id: Optional[str] = None,
+        resource: Optional[str] = None,
+        details: Optional[Dict[str, Any]] = None,
+    ) -> LogEntry:
+        """Add an entry to the end of the chain.
+
+        Args:
+            category: AuditCategory of the event
+            action: What was done (the route's name)
+            outcome: AuditOutcome of the event
+            actor: Subject of the caller's credentials (None if anonymous)
+            tenant_id: Tenant acted on, or the calling tenant
+            resource: Request path or subject of the decision
+            details: JSON-serializable specifics
+
+        Raises:
+            ValueError: If the entry cannot be stored
+        """
+        details_json = canonical_json(details or {})
+        error: Optional[Exception] = None
+        for _ in range(_APPEND_ATTEMPTS):
+            try:
+                head = self.session.query(AuditLogEntry).order_by(AuditLogEntry.id.desc()).first()
+                row = AuditLogEntry(
+                    entry_id=str(uuid.uuid4()),
+                    recorded_at=datetime.utcnow(),
+                    category=category,
+                    action=action[:128],
+                    actor=actor[:255] if actor else None,
+                    tenant_id=tenant_id,
+                    resource=(resource or "")[:2048] or None,
+                    outcome=outcome,
+                    details=details_json,
+                    prev_hash=head.entry_hash if head is not None else GENESIS_HASH,
+                )
+                row.entry_hash = compute_entry_hash(row)
+                self.session.add(row)
+                self.session.commit()
+                return _to_entry(row)
+            except IntegrityError as e:
+                # Another writer appended to the same head first; chain onto theirs
+                self.session.rollback()
+                error = e
+            except Exception as e:
+                self.session.rollback()
+                raise ValueError(f"Failed to store audit log entry: {str(e)}")
+        raise ValueError(f"Failed to store audit log entry: {str(error)}")
+
+    def page(
+        self,
+        since: Optional[datetime] = None,
+        until: Optional[datetime] = None,
+        tenant_id: Optional[str] = None,
+        actor: Optional[str] = None,
+        category: Optional[str] = None,
+        action: Optional[str] = None,
+        limit: int = 100,
+        cursor: Optional[str] = None,
+    ) -> Page[LogEntry]:
+        """One page of matching entries, newest first.
+
+        Args:
+            since: Only entries at or after this time
+            until: Only entries before this time
+            tenant_id: Only entries of this tenant
+            actor: Only entries of this actor
+            category: Only entries of this AuditCategory
+            action: Only entries of this action
+            limit: Most entries on the page
+            cursor: next_cursor of the previous page
+
+        Raises:
+            ValueError: If the cursor is invalid
+        """
+        query = self.session.query(AuditLogEntry)
+        if since is not None:
+            query = query.filter(AuditLogEntry.recorded_at >= _utc(since))
+        if until is not None:
+            query = query.filter(AuditLogEntry.recorded_at < _utc(until))
+        for column, value in (
+            (AuditLogEntry.tenant_id, tenant_id),
+            (AuditLogEntry.actor, actor),
+            (AuditLogEntry.category, category),
+            (AuditLogEntry.action, action),
+        ):
+            if value is not None:
+                query = query.filter(column == value)
+        return keyset_page(query, AuditLogEntry.recorded_at, AuditLogEntry.id, limit, cursor, _to_entry)
+
+    def verify(self) -> ChainVerification:
+        """Walk the chain from the first entry and check every link and hash.
+
+        Returns:
+            ChainVerification naming the first entry that does not follow
+            its predecessor or whose hash does not match its fields
+        """
+        checked = 0
+        head_hash = GENESIS_HASH
+        rows = self.session.query(AuditLogEntry).order_by(AuditLogEntry.id).yield_per(1000)
+        for row in rows:
+            reason = None
+            if row.prev_hash != head_hash:
+                reason = "does not follow the previous entry (entries removed or reordered)"
+            elif compute_entry_hash(row) != row.entry_hash:
+                reason = "hash does not match its contents (entry altered)"
+            if reason is not None:
+                logger.error(f"Audit log chain broken at entry {row.id}: {reason}")
+                return ChainVerification(
+                    valid=False,
+                    entries=checked,
+                    head_hash=head_hash,
+                    broken_seq=row.id,
+                    broken_entry_id=row.entry_id,
+                    reason=reason,
+                )
+            head_hash = row.entry_hash
+            checked += 1
+        return ChainVerification(valid=True, entries=checked, head_hash=head_hash)
@@ -0,0 +1,382 @@
+"""Bearer token authentication.
+
+API tokens are JWTs signed with JWT_SECRET_KEY (HS256), or with an RSA
+or EC key pair (JWT_ALGORITHM=RS256 and friends): the API verifies with
+JWT_PUBLIC_KEY and only an instance holding JWT_PRIVATE_KEY can issue
+tokens. The algorithm is pinned, so a token cannot pick a weaker one.
+The tenant a caller acts for comes from a signed claim (JWT_TENANT_CLAIM),
+never from a client-supplied header, so one tenant cannot read or modify
+another tenant's data by changing a request header.
+
+A rotated key can stay accepted for the tokens it signed
+(JWT_PREVIOUS_SECRET_KEY, JWT_PREVIOUS_PUBLIC_KEY) while new tokens are
+signed with the current one.
+
+Issued tokens carry an ID (`jti`) and, when refreshable, the ID of their
+login session (`sid`); both can be revoked before the token expires
+(see src.services.token_denylist_service).
+"""
+
+import logging
+import re
+import uuid
+from dataclasses import dataclass, field
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, FrozenSet, Iterable, Optional, Tuple
+
+import jwt
+
+from src.services.secrets_service import secrets_generation
+from src.services.token_denylist_service import TokenDenylist
+
+logger = logging.getLogger(__name__)
+
+# Placeholder shipped in Config; tokens signed with it can be forged by anyone
+INSECURE_DEFAULT_SECRET = "your-secret-key-change-in-production"
+
+# Scope granting access to the admin API (/admin/v1); like "admin:*"
+ADMIN_SCOPE = "admin"
+# Scopes granting read-only and write access to the admin API
+ADMIN_READ_SCOPE = "admin:read"
+ADMIN_WRITE_SCOPE = "admin:write"
+
+_SCOPE = re.compile(r"^(\*|[a-z][a-z0-9_-]*(:(\*|[a-z][a-z0-9_-]*))?)$")
+
+# Algorithm families verified with a public key rather than a shared secret
+_ASYMMETRIC_FAMILIES = ("RS", "PS", "ES")
+
+
+def validate_scopes(scopes: Iterable[str]) -> Tuple[str, ...]:
+    """Check scope syntax (`resource:action`, `resource:*`, `resource` or `*`).
+
+    Returns:
+        The scopes, sorted and without duplicates
+
+    Raises:
+        ValueError: If a scope is malformed
+    """
+    scopes = tuple(scopes)
+    for scope in scopes:
+        if not isinstance(scope, str) or not _SCOPE.match(scope):
+            raise ValueError(f"Invalid scope {scope!r}: expected resource:action, e.g. lookup:read")
+    return tuple(sorted(set(scopes)))
+
+
+def scope_granted(granted: Iterable[str], required: str) -> bool:
+    """Whether granted scopes cover a required one.
+
+    Scopes are `resource:action`. A granted `resource:*` or bare
+    `resource` covers every action on the resource, and `*` covers
+    everything.
+    """
+    resource = required.partition(":")[0]
+    for scope in granted:
+        if scope in (required, "*", resource, f"{resource}:*"):
+            return True
+    return False
+
+
+class AuthenticationError(Exception):
+    """Missing, malformed, expired or otherwise invalid credentials."""
+
+
+@dataclass(frozen=True)
+class Principal:
+    """Authenticated caller."""
+    subject: str
+    tenant_id: str
+    scopes: FrozenSet[str] = field(default_factory=frozenset)
+    claims: Dict[str, Any] = field(default_factory=dict, compare=False, repr=False)
+
+    def has_scope(self, required: str) -> bool:
+        """Whether the principal's scopes cover a required scope (see scope_granted)."""
+        return scope_granted(self.scopes, required)
+
+    @property
+    def token_id(self) -> Optional[str]:
+        """ID of the token (jti claim), if it has one."""
+        return self.claims.get("jti")
+
+    @property
+    def session_id(self) -> Optional[str]:
+        """Login session of a refreshable token (sid claim)."""
+        return self.claims.get("sid")
+
+    @property
+    def signed_requests(self) -> bool:
+        """Whether requests made with the token must be signed (req_sig claim)."""
+        return self.claims.get("req_sig") is True
+
+    @property
+    def quota_plan(self) -> Optional[str]:
+        """Quota plan of the token's API key (plan claim), if it is metered."""
+        plan = self.claims.get("plan")
+        return plan if isinstance(plan, str) and plan else None
+
+
+class TokenVerifier:
+    """Validates bearer tokens and extracts the principal."""
+
+    def __init__(
+        self,
+        secret: str,
+        algorithm: str = "HS256",
+        tenant_claim: str = "tenant_id",
+        allow_insecure_secret: bool = False,
+        public_key: str = "",
+        private_key: str = "",
+        issuer: str = "",
+        audience: str = "",
+        denylist: Optional[TokenDenylist] = None,
+        previous_key: str = "",
+    ):
+        """Initialize verifier.
+
+        Args:
+            secret: Signing secret (HS* algorithms)
+            algorithm: JWT algorithm
+            tenant_claim: Claim holding the caller's tenant ID
+            allow_insecure_secret: Accept the placeholder secret (debug only)
+            public_key: PEM verification key (RS*, PS* and ES* algorithms)
+            private_key: PEM signing key; without it the verifier cannot issue
+                tokens with an asymmetric algorithm
+            issuer: Required iss claim (empty: not checked)
+            audience: Required aud claim (empty: not checked)
+            denylist: Revoked token and session IDs (None: no revocation)
+            previous_key: Secret or public key replaced by a rotation; tokens
+                it signed are still accepted (empty: none)
+        """
+        self.secret = secret
+        self.algorithm = algorithm
+        self.tenant_claim = tenant_claim
+        self.allow_insecure_secret = allow_insecure_secret
+        self.public_key = public_key
+        self.private_key = private_key
+        self.issuer = issuer
+        self.audience = audience
+        self.denylist = denylist
+        self.previous_key = previous_key
+
+    @property
+    def asymmetric(self) -> bool:
+        """True if tokens are verified with a public key (RS256, ES256, ...)."""
+        return self.algorithm[:2].upper() in _ASYMMETRIC_FAMILIES
+
+    @property
+    def configured(self) -> bool:
+        """True if a real verification key is configured."""
+        if self.asymmetric:
+            return bool(self.public_key)
+        if not self.secret:
+            return False
+        return self.allow_insecure_secret or self.secret != INSECURE_DEFAULT_SECRET
+
+    def _not_configured(self) -> RuntimeError:
+        setting = "JWT_PUBLIC_KEY" if self.asymmetric else "JWT_SECRET_KEY"
+        return RuntimeError(f"Token authentication is not configured (set {setting})")
+
+    def verify(self, token: str, check_revocation: bool = True) -> Principal:
+        """Validate a token.
+
+        Args:
+            token: Encoded JWT
+            check_revocation: Look the token up in the denylist; only callers
+                not granting access (e.g. rate limiting) may skip it
+
+        Returns:
+            Principal with subject, tenant and scopes
+
+        Raises:
+            RuntimeError: If no verification key is configured, or the
+                denylist cannot be read (revoked tokens must not slip through)
+            AuthenticationError: If the token is invalid, revoked or lacks a tenant
+        """
+        if not self.configured:
+            raise self._not_configured()
+        try:
+            try:
+                claims = self._decode(token, self.public_key if self.asymmetric else self.secret)
+            except jwt.InvalidSignatureError:
+                if not self.previous_key:
+                    raise
+                claims = self._decode(token, self.previous_key)
+        except jwt.ExpiredSignatureError:
+            raise AuthenticationError("Token has expired")
+        except jwt.InvalidTokenError as e:
+            raise AuthenticationError(f"Invalid token: {str(e)}")
+
+        tenant_id = claims.get(self.tenant_claim)
+        if not isinstance(tenant_id, str) or not tenant_id:
+            raise AuthenticationError(f"Token has no '{self.tenant_claim}' claim")
+        if check_revocation and self.denylist is not None:
+            self._check_denylist(claims)
+
+        scopes = claims.get("scope", "")
+        return Principal(
+            subject=str(claims["sub"]),
+            tenant_id=tenant_id,
+            scopes=frozenset(scopes.split() if isinstance(scopes, str) else scopes),
+            claims=claims,
+        )
+
+    def _decode(self, token: str, key: str) -> Dict[str, Any]:
+        return jwt.decode(
+            token,
+            key,
+            algorithms=[self.algorithm],
+            issuer=self.issuer or None,
+            audience=self.audience or None,
+            options={"require": ["exp", "sub"], "verify_aud": bool(self.audience)},
+        )
+
+    def _check_denylist(self, claims: Dict[str, Any]) -> None:
+        try:
+            revoked = any(
+                isinstance(claims.get(kind), str) and self.denylist.is_revoked(kind, claims[kind])
+                for kind in ("jti", "sid")
+            )
+        except Exception as e:
+            raise RuntimeError(f"Token denylist unavailable: {str(e)}")
+        if revoked:
+            raise AuthenticationError("Token has been revoked")
+
+    def issue(
+        self,
+        subject: str,
+        tenant_id: str,
+        scopes: Iterable[str] = (),
+        expires_in: timedelta = timedelta(minutes=60),
+        session_id: Optional[str] = None,
+        signed_requests: bool = False,
+        allowed_cidrs: Iterable[str] = (),
+        privacy_precision: Optional[int] = None,
+        quota_plan: Optional[str] = None,
+    ) -> str:
+        """Sign a token for a principal.
+
+        Args:
+            subject: sub claim
+            tenant_id: Tenant the token acts for
+            scopes: Granted scopes
+            expires_in: Token lifetime
+            session_id: Login session the token belongs to (sid claim)
+            signed_requests: Requests made with the token must be signed
+                (req_sig claim, see src.services.request_signing_service)
+            allowed_cidrs: Networks the token may be used from (cidrs
+                claim, see src.services.ip_allowlist_service; empty: any)
+            privacy_precision: Coordinate decimal places of privacy mode
+                (privacy claim, see src.services.privacy_service; None: off)
+            quota_plan: Quota plan of the caller's API key (plan claim, see
+                src.services.quota_service; None: not metered)
+
+        Raises:
+            RuntimeError: If no signing key is configured
+        """
+        if not self.configured:
+            raise self._not_configured()
+        if self.asymmetric and not self.private_key:
+            raise RuntimeError(f"Token issuance is not configured (set JWT_PRIVATE_KEY for {self.algorithm})")
+        now = datetime.now(timezone.utc)
+        claims = {
+            "sub": subject,
+            self.tenant_claim: tenant_id,
+            "scope": " ".join(sorted(scopes)),
+            "jti": uuid.uuid4().hex,
+            "iat": now,
+            "exp": now + expires_in,
+        }
+        if session_id:
+            claims["sid"] = session_id
+        if signed_requests:
+            claims["req_sig"] = True
+        if allowed_cidrs:
+            claims["cidrs"] = list(allowed_cidrs)
+        if privacy_precision is not None:
+            claims["privacy"] = privacy_precision
+        if quota_plan:
+            claims["plan"] = quota_plan
+        if self.issuer:
+            claims["iss"] = self.issuer
+        if self.audience:
+            claims["aud"] = self.audience
+        key = self.private_key if self.asymmetric else self.secret
+        return jwt.encode(claims, key, algorithm=self.algorithm)
+
+    def revoke(self, principal: Principal) -> None:
+        """Deny a verified token for the rest of its lifetime.
+
+        Raises:
+            RuntimeError: If there is no denylist
+            ValueError: If the token has no jti claim
+        """
+        if self.denylist is None:
+            raise RuntimeError("Token revocation is not configured")
+        if not principal.token_id:
+            raise ValueError("Token has no jti claim and cannot be revoked")
+        self.denylist.revoke("jti", principal.token_id, _seconds_left(principal.claims.get("exp")))
+
+    def revoke_session(self, session_id: str, ttl_seconds: int) -> None:
+        """Deny every access token of a login session.
+
+        Args:
+            session_id: sid claim of the session's tokens
+            ttl_seconds: Lifetime of the longest-lived access token it may have
+
+        Raises:
+            RuntimeError: If there is no denylist
+        """
+        if self.denylist is None:
+            raise RuntimeError("Token revocation is not configured")
+        self.denylist.revoke("sid", session_id, ttl_seconds)
+
+
+def _seconds_left(exp: Any) -> int:
+    """Seconds until an exp claim, at least 1."""
+    try:
+        return max(1, int(float(exp) - datetime.now(timezone.utc).timestamp()) + 1)
+    except (TypeError, ValueError):
+        return 1
+
+
+def _read_key(value: str, path: str) -> str:
+    """PEM key given inline (escaped \\n newlines allowed), or read from a file."""
+    if value or not path:
+        return value.replace("\\n", "\n")
+    with open(path) as f:
+        return f.read()
+
+
+# Global verifier (built lazily from configuration, rebuilt when secrets rotate)
+_token_verifier: Optional[TokenVerifier] = None
+_token_verifier_generation = 0
+
+
+def get_token_verifier() -> TokenVerifier:
+    """Get the global token verifier."""
+    global _token_verifier, _token_verifier_generation
+    generation = secrets_generation()
+    if _token_verifier is None or _token_verifier_generation != generation:
+        from src.config import get_config
+
+        from src.services.token_denylist_service import get_token_denylist
+
+        config = get_config()
+        previous = config.jwt_previous_secret_key
+        if config.jwt_algorithm[:2].upper() in _ASYMMETRIC_FAMILIES:
+            previous = config.jwt_previous_public_key
+        _token_verifier_generation = generation
+        _token_verifier = TokenVerifier(
+            config.jwt_secret_key,
+            algorithm=config.jwt_algorithm,
+            tenant_claim=config.jwt_tenant_claim,
+            allow_insecure_secret=config.debug,
+            public_key=_read_key(config.jwt_public_key, config.jwt_public_key_path),
+            private_key=_read_key(config.jwt_private_key, config.jwt_private_key_path),
+            issuer=config.jwt_issuer,
+            audience=config.jwt_audience,
+            denylist=get_token_denylist(),
+            previous_key=_read_key(previous, ""),
+        )
+        if not _token_verifier.configured:
+            logger.warning("JWT signing key is not set; tenant-scoped endpoints will return 503")
+    return _token_verifier
@@ -0,0 +1,353 @@
+"""Brute-force protection of the authentication endpoints.
+
+Failed authentications (401 answers of the token endpoints and the admin
+API) are counted per client address and per credential they targeted:
+the API key (by its prefix) or the admin account (by the unverified
+`sub` claim of the rejected token). An address with AUTH_THROTTLE_MAX_FAILURES
+failures in AUTH_THROTTLE_WINDOW_SECONDS is locked out for
+AUTH_THROTTLE_LOCKOUT_SECONDS, doubling with every further failure up to
+AUTH_THROTTLE_MAX_LOCKOUT_SECONDS. Credentials are never locked, so an
+attacker cannot lock their owner out; a credential failing
+AUTH_THROTTLE_ALERT_FAILURES times in a window, from any number of
+addresses, raises a credential-stuffing alert instead (logged, counted,
+audited and, for tenant API keys, sent to the tenant's webhooks as
+`security.credential_attack`).
+
+With AUTH_THROTTLE_REDIS_URL the counters are shared by all workers
+(`pip install -e ".[redis]"`); otherwise each process counts on its own.
+"""
+
+import asyncio
+import logging
+import math
+import threading
+import time
+from dataclasses import dataclass
+from typing import Dict, Optional, Tuple
+
+import jwt
+from sqlalchemy.orm import Session
+
+from src.metrics import AUTH_FAILURES, AUTH_LOCKOUTS, CREDENTIAL_ATTACK_ALERTS
+
+logger = logging.getLogger(__name__)
+
+CREDENTIAL_ATTACK_EVENT = "security.credential_attack"
+
+
+class ThrottleStore:
+    """Expiring failure counters and lockouts."""
+
+    def hit(self, key: str, window_seconds: int) -> int:
+        """Count a failure, forgetting the count window_seconds after the latest.
+
+        Returns:
+            The failures counted so far
+        """
+        raise NotImplementedError
+
+    def lock(self, key: str, seconds: int) -> None:
+        """Lock a key out for a number of seconds."""
+        raise NotImplementedError
+
+    def locked_for(self, key: str) -> float:
+        """Seconds until a key's lockout ends (0 if it is not locked)."""
+        raise NotImplementedError
+
+    def mark(self, key: str, seconds: int) -> bool:
+        """Set a flag for a number of seconds.
+
+        Returns:
+            True if it was not already set
+        """
+        raise NotImplementedError
+
+
+class InMemoryThrottleStore(ThrottleStore):
+    """Process-local counters (not shared between workers)."""
+
+    def __init__(self, clock=time.monotonic):
+        self._entries: Dict[str, Tuple[int, float]] = {}
+        self._lock = threading.Lock()
+        self._clock = clock
+
+    def _live(self, key: str, now: float) -> Optional[int]:
+        entry = self._entries.get(key)
+        if entry is None or entry[1] <= now:
+            return None
+        return entry[0]
+
+    def _set(self, key: str, value: int, now: float, seconds: int) -> None:
+        for stale in [k for k, (_, expires) in self._entries.items() if expires <= now]:
+            del self._entries[stale]
+        self._entries[key] = (value, now + max(1, seconds))
+
+    def hit(self, key: str, window_seconds: int) -> int:
+        now = self._clock()
+        with self._lock:
+            count = (self._live(f"failures:{key}", now) or 0) + 1
+            self._set(f"failures:{key}", count, now, window_seconds)
+        return count
+
+    def lock(self, key: str, seconds: int) -> None:
+        now = self._clock()
+        with self._lock:
+            self._set(f"lock:{key}", 1, now, seconds)
+
+    def locked_for(self, key: str) -> float:
+        now = self._clock()
+        with self._lock:
+            entry = self._entries.get(f"lock:{key}")
+        return max(0.0, entry[1] - now) if entry is not None else 0.0
+
+    def mark(self, key: str, seconds: int) -> bool:
+        now = self._clock()
+        with self._lock:
+            if self._live(f"mark:{key}", now) is not None:
+                return False
+            self._set(f"mark:{key}", 1, now, seconds)
+        return True
+
+
+class RedisThrottleStore(ThrottleStore):
+    """Redis keys with expiry, one per counter, lockout and flag."""
+
+    def __init__(self, client, key_prefix: str = "auth-throttle"):
+        """Initialize Redis store.
+
+        Args:
+            client: redis.Redis client
+            key_prefix: Prefix of the Redis keys
+        """
+        self.client = client
+        self.key_prefix = key_prefix
+
+    @classmethod
+    def from_url(cls, url: str, key_prefix: str = "auth-throttle") -> "RedisThrottleStore":
+        """Connect to Redis.
+
+        Raises:
+            RuntimeError: If the redis package is not installed
+        """
+        try:
+            import redis
+        except ImportError:
+            raise RuntimeError("redis is required for AUTH_THROTTLE_REDIS_URL")
+        return cls(redis.Redis.from_url(url), key_prefix)
+
+    def _key(self, kind: str, key: str) -> str:
+        return f"{self.key_prefix}:{kind}:{key}"
+
+    def hit(self, key: str, window_seconds: int) -> int:
+        name = self._key("failures", key)
+        pipeline = self.client.pipeline()
+        pipeline.incr(name)
+        pipeline.expire(name, max(1, window_seconds))
+        count, _ = pipeline.execute()
+        return int(count)
+
+    def lock(self, key: str, seconds: int) -> None:
+        self.client.set(self._key("lock", key), "1", ex=max(1, seconds))
+
+    def locked_for(self, key: str) -> float:
+        remaining = self.client.pttl(self._key("lock", key))
+        return remaining / 1000.0 if remaining and remaining > 0 else 0.0
+
+    def mark(self, key: str, seconds: int) -> bool:
+        return bool(self.client.set(self._key("mark", key), "1", ex=max(1, seconds), nx=True))
+
+
+def api_key_identity(api_key: str) -> str:
+    """Credential identity of an API key: its prefix, kept in clear in storage."""
+    from src.services.tenant_service import _PREFIX_LENGTH
+
+    return f"key:{api_key.strip()[:_PREFIX_LENGTH]}"
+
+
+def token_identity(token: str) -> Optional[str]:
+    """Credential identity of a bearer token: its `sub` claim, read without
+    verifying the token.
+
+    Rejected tokens cannot be verified, so the claim only names the
+    account the caller meant to authenticate as.
+    """
+    try:
+        claims = jwt.decode(token, options={"verify_signature": False})
+    except jwt.InvalidTokenError:
+        return None
+    subject = claims.get("sub") if isinstance(claims, dict) else None
+    return f"account:{subject[:128]}" if isinstance(subject, str) and subject else None
+
+
+@dataclass
+class CredentialAttackAlert:
+    """A credential failing too often, likely under credential stuffing."""
+
+    identity: str
+    failures: int
+    window_seconds: int
+    endpoint: str
+    client_ip: Optional[str]
+
+    @property
+    def kind(self) -> str:
+        """Credential kind: "key" or "account"."""
+        return self.identity.partition(":")[0]
+
+    def to_dict(self) -> Dict[str, object]:
+        return {
+            "credential": self.identity,
+            "failures": self.failures,
+            "window_seconds": self.window_seconds,
+            "endpoint": self.endpoint,
+            "last_client_ip": self.client_ip,
+        }
+
+
+class AuthThrottle:
+    """Counts authentication failures, locks out addresses and spots attacks."""
+
+    def __init__(
+        self,
+        store: Optional[ThrottleStore] = None,
+        max_failures: int = 5,
+        window_seconds: int = 900,
+        lockout_seconds: int = 30,
+        max_lockout_seconds: int = 3600,
+        alert_failures: int = 20,
+    ):
+        """Initialize throttle.
+
+        Args:
+            store: Counter store (process memory by default)
+            max_failures: Failures of an address before it is locked out
+            window_seconds: Failures are forgotten this long after the latest
+            lockout_seconds: First lockout, doubled by each further failure
+            max_lockout_seconds: Longest lockout
+            alert_failures: Failures of a credential raising an alert
+        """
+        self.store = store or InMemoryThrottleStore()
+        self.max_failures = max(1, max_failures)
+        self.window_seconds = max(1, window_seconds)
+        self.lockout_seconds = max(1, lockout_seconds)
+        self.max_lockout_seconds = max(self.lockout_seconds, max_lockout_seconds)
+        self.alert_failures = max(1, alert_failures)
+
+    def retry_after(self, client_ip: Optional[str]) -> int:
+        """Seconds until a locked-out address may try again (0 if it is not locked)."""
+        if client_ip is None:
+            return 0
+        remaining = self.store.locked_for(f"ip:{client_ip}")
+        return math.ceil(remaining) if remaining > 0 else 0
+
+    def lockout_for(self, failures: int) -> int:
+        """Lockout after a number of failures of an address (0 under the limit)."""
+        if failures < self.max_failures:
+            return 0
+        doublings = min(failures - self.max_failures, 32)
+        return min(self.max_lockout_seconds, self.lockout_seconds * 2 ** doublings)
+
+    def failed(
+        self, endpoint: str, client_ip: Optional[str], identity: Optional[str] = None
+    ) -> Optional[CredentialAttackAlert]:
+        """Count a failed authentication.
+
+        Args:
+            endpoint: Label of the endpoint ("token", "refresh", "admin")
+            client_ip: Caller's address
+            identity: Credential it targeted (see api_key_identity, token_identity)
+
+        Returns:
+            An alert the first time in a window the credential reaches
+            the alert threshold, else None
+        """
+        AUTH_FAILURES.labels(endpoint=endpoint).inc()
+        if client_ip is not None:
+            lockout = self.lockout_for(self.store.hit(f"ip:{client_ip}", self.window_seconds))
+            if lockout:
+                self.store.lock(f"ip:{client_ip}", lockout)
+                AUTH_LOCKOUTS.labels(endpoint=endpoint).inc()
+                logger.warning(f"Locked out {client_ip} for {lockout}s after failed {endpoint} authentications")
+        if identity is None:
+            return None
+        failures = self.store.hit(identity, self.window_seconds)
+        if failures < self.alert_failures or not self.store.mark(f"alert:{identity}", self.window_seconds):
+            return None
+        alert = CredentialAttackAlert(identity, failures, self.window_seconds, endpoint, client_ip)
+        CREDENTIAL_ATTACK_ALERTS.labels(kind=alert.kind).inc()
+        logger.warning(
+            f"Possible credential stuffing against {identity}: {failures} failed {endpoint} "
+            f"authentications within {self.window_seconds}s, latest from {client_ip}"
+        )
+        return alert
+
+
+def report_attack(session: Session, alert: CredentialAttackAlert) -> None:
+    """Audit an alert and notify the targeted key's tenant.
+
+    Must be called from the event loop, which delivers the webhooks.
+    Failures are logged; the alert has already been raised in the logs
+    and metrics.
+    """
+    from src.models.database_models import TenantApiKey
+    from src.services.audit_log_service import AuditCategory, AuditLogService, AuditOutcome
+    from src.services.webhook_service import WebhookService, get_webhook_dispatcher
+
+    key = None
+    if alert.kind == "key":
+        key = session.query(TenantApiKey).filter(
+            TenantApiKey.prefix == alert.identity.partition(":")[2]
+        ).first()
+    try:
+        AuditLogService(session).append(
+            AuditCategory.SECURITY,
+            "credential_attack",
+            AuditOutcome.FLAGGED,
+            tenant_id=key.tenant_id if key is not None else None,
+            resource=f"key:{key.key_id}" if key is not None else alert.identity,
+            details=alert.to_dict(),
+        )
+    except ValueError as e:
+        logger.error(f"Audit log entry lost for credential attack on {alert.identity}: {e}")
+    if key is None:
+        return
+    try:
+        targets = WebhookService(session).targets(key.tenant_id, CREDENTIAL_ATTACK_EVENT)
+        if targets:
+            data = {"key_id": key.key_id, "prefix": key.prefix, **alert.to_dict()}
+            asyncio.create_task(get_webhook_dispatcher().dispatch(targets, CREDENTIAL_ATTACK_EVENT, data))
+    except Exception as e:
+        logger.error(f"Failed to schedule webhook delivery of credential attack on {alert.identity}: {e}")
+
+
+# Global throttle (store chosen lazily from AUTH_THROTTLE_REDIS_URL)
+_auth_throttle: Optional[AuthThrottle] = None
+
+
+def get_auth_throttle() -> AuthThrottle:
+    """Get the global authentication throttle.
+
+    Raises:
+        RuntimeError: If AUTH_THROTTLE_REDIS_URL is set but redis is not installed
+    """
+    global _auth_throttle
+    if _auth_throttle is None:
+        from src.config import get_config
+
+        config = get_config()
+        if config.auth_throttle_redis_url:
+            store: ThrottleStore = RedisThrottleStore.from_url(
+                config.auth_throttle_redis_url, config.auth_throttle_key_prefix
+            )
+        else:
+            logger.info("AUTH_THROTTLE_REDIS_URL not set; authentication failures are counted per process")
+            store = InMemoryThrottleStore()
+        _auth_throttle = AuthThrottle(
+            store,
+            max_failures=config.auth_throttle_max_failures,
+            window_seconds=config.auth_throttle_window_seconds,
+            lockout_seconds=config.auth_throttle_lockout_seconds,
+            max_lockout_seconds=config.auth_throttle_max_lockout_seconds,
+            alert_failures=config.auth_throttle_alert_failures,
+        )
+    return _auth_throttle
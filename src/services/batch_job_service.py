@@ -0,0 +1,467 @@
+"""Asynchronous batch lookup jobs over large input files.
+
+A job names an input file (an S3 object, an HTTPS URL, a file uploaded
+with the request or, when BATCH_JOB_INPUT_DIR is set, a file in that
+directory) with one lookup per CSV row: an `ip` column, or `lat` and `lon` columns. The runner downloads
+the file, counts its rows and then streams it through the batch lookup
+worker pool in chunks, storing the job's progress after each chunk so
+clients can poll it. Results are written as NDJSON, one line per row in
+input order, with the per-item result or error of POST /api/v1/lookup/batch.
+
+Jobs run in the API process that accepted them; jobs interrupted by a
+restart are marked failed at startup (fail_interrupted) rather than resumed.
+"""
+
+import asyncio
+import csv
+import dataclasses
+import itertools
+import json
+import logging
+import os
+import uuid
+from datetime import datetime
+from typing import Any, Callable, Dict, Iterator, List, Optional
+from urllib.parse import urlparse
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import BatchJob
+from src.services.batch_lookup_service import BatchLookupService, BatchOutcome, get_batch_lookup_service
+
+logger = logging.getLogger(__name__)
+
+ROW_COLUMNS = ("ip", "lat", "lon")
+
+# Source scheme of uploaded inputs, stored next to the results until processed
+UPLOAD_SCHEME = "upload"
+
+Row = Dict[str, Optional[str]]
+
+
+class JobStatus:
+    """Lifecycle of a batch job."""
+    QUEUED = "queued"
+    RUNNING = "running"
+    SUCCEEDED = "succeeded"
+    FAILED = "failed"
+
+    ACTIVE = (QUEUED, RUNNING)
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+def _local_path(path: str, input_dir: str) -> str:
+    """Resolve a file: reference inside the input directory.
+
+    Raises:
+        ValueError: If the path leaves the directory
+    """
+    root = os.path.realpath(input_dir)
+    full = os.path.realpath(os.path.join(root, path.lstrip("/")))
+    if full == root or os.path.commonpath([root, full]) != root:
+        raise ValueError("file: references must name a file inside BATCH_JOB_INPUT_DIR")
+    return full
+
+
+def parse_source(source: str, input_dir: Optional[str] = None) -> str:
+    """Validate an input file reference.
+
+    Args:
+        source: s3://bucket/key, https://... or file:relative/path
+        input_dir: Directory file: references are resolved in (None disables them)
+
+    Returns:
+        The reference, stripped
+
+    Raises:
+        ValueError: If the reference is malformed or its scheme is not allowed
+    """
+    source = (source or "").strip()
+    parsed = urlparse(source)
+    if parsed.scheme == "s3":
+        if not parsed.netloc or not parsed.path.strip("/"):
+            raise ValueError("S3 references need a bucket and key: s3://bucket/key")
+    elif parsed.scheme == "https":
+        if not parsed.netloc:
+            raise ValueError(f"Invalid URL: {source}")
+    elif parsed.scheme == "file":
+        if not input_dir:
+            raise ValueError("file: references are disabled (BATCH_JOB_INPUT_DIR is not set)")
+        _local_path(parsed.path, input_dir)
+    else:
+        raise ValueError("source must be an s3://, https:// or file: reference")
+    return source
+
+
+async def fetch_source(
+    source: str,
+    download_path: str,
+    input_dir: Optional[str] = None,
+    s3_client: Any = None,
+) -> str:
+    """Make an input file available locally.
+
+    Args:
+        source: Reference accepted by parse_source
+        download_path: Where remote inputs are downloaded to
+        input_dir: Directory file: references are resolved in
+        s3_client: Optional boto3 S3 client (created on demand)
+
+    Returns:
+        Path to read: download_path for remote and uploaded inputs, the
+        file itself for file: references
+
+    Raises:
+        RuntimeError: If the input cannot be fetched
+    """
+    parsed = urlparse(source)
+    if parsed.scheme == "https":
+        from src.services.geoip_update_service import http_download
+
+        await http_download(source, download_path)
+        return download_path
+    if parsed.scheme == "s3":
+        def _download():
+            client = s3_client
+            if client is None:
+                try:
+                    import boto3
+                except ImportError:
+                    raise RuntimeError("boto3 is required for s3:// job inputs")
+                client = boto3.client("s3")
+            try:
+                client.download_file(parsed.netloc, parsed.path.lstrip("/"), download_path)
+            except Exception as e:
+                raise RuntimeError(f"S3 download failed: {str(e)}")
+
+        await asyncio.to_thread(_download)
+        return download_path
+    if parsed.scheme == UPLOAD_SCHEME:
+        if not os.path.isfile(download_path):
+            raise RuntimeError(f"Uploaded input is no longer available: {source}")
+        return download_path
+    path = _local_path(parsed.path, input_dir or "")
+    if not os.path.isfile(path):
+        raise RuntimeError(f"Input file not found: {source}")
+    return path
+
+
+def _cell(value: Optional[str]) -> Optional[str]:
+    value = (value or "").strip()
+    return value or None
+
+
+def read_rows(path: str) -> Iterator[Row]:
+    """Rows of a CSV input file as {"ip", "lat", "lon"} (absent columns are None).
+
+    Raises:
+        ValueError: If the header has neither an ip column nor lat and lon columns
+    """
+    with open(path, newline="", encoding="utf-8-sig") as f:
+        reader = csv.DictReader(f)
+        columns = {(name or "").strip().lower(): name for name in reader.fieldnames or ()}
+        if "ip" not in columns and not {"lat", "lon"} <= columns.keys():
+            raise ValueError("Input needs a header row with an ip column or lat and lon columns")
+        for row in reader:
+            yield {key: _cell(row.get(columns[key])) if key in columns else None for key in ROW_COLUMNS}
+
+
+def count_rows(path: str) -> int:
+    """Number of data rows of a CSV input file (validates the header)."""
+    return sum(1 for _ in read_rows(path))
+
+
+class BatchJobService:
+    """Stores batch jobs and their progress."""
+
+    def __init__(self, session: Session):
+        """Initialize service.
+
+        Args:
+            session: SQLAlchemy database session
+        """
+        self.session = session
+
+    def create(self, tenant_id: str, source: str, input_dir: Optional[str] = None) -> BatchJob:
+        """Queue a job for an input file.
+
+        Raises:
+            ValueError: If the source reference is invalid
+        """
+        job = BatchJob(
+            job_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            source=parse_source(source, input_dir),
+            status=JobStatus.QUEUED,
+            processed_rows=0,
+            failed_rows=0,
+        )
+        self.session.add(job)
+        self.session.commit()
+        logger.info(f"Tenant {tenant_id} queued batch job {job.job_id} for {job.source}")
+        return job
+
+    def create_upload(self, tenant_id: str, filename: Optional[str]) -> BatchJob:
+        """Queue a job for a file uploaded with the request (source upload:<filename>).
+
+        The caller stores the file at BatchJobRunner.input_path before submitting the job.
+        """
+        name = os.path.basename((filename or "").replace("\\", "/")).strip()[:255] or "upload.csv"
+        job = BatchJob(
+            job_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            source=f"{UPLOAD_SCHEME}:{name}",
+            status=JobStatus.QUEUED,
+            processed_rows=0,
+            failed_rows=0,
+        )
+        self.session.add(job)
+        self.session.commit()
+        logger.info(f"Tenant {tenant_id} queued batch job {job.job_id} for uploaded {name}")
+        return job
+
+    def get(self, tenant_id: str, job_id: str) -> Optional[BatchJob]:
+        """A job of the tenant (None if unknown or another tenant's)."""
+        return self.session.query(BatchJob).filter(
+            BatchJob.tenant_id == tenant_id, BatchJob.job_id == job_id
+        ).first()
+
+    def update(self, job_id: str, **fields: Any) -> BatchJob:
+        """Set fields of a job and commit."""
+        job = self.session.query(BatchJob).filter(BatchJob.job_id == job_id).one()
+        for name, value in fields.items():
+            setattr(job, name, value)
+        self.session.commit()
+        return job
+
+    def fail_interrupted(self) -> int:
+        """Mark queued and running jobs failed (at startup, when none can be running).
+
+        Returns:
+            Number of jobs marked failed
+        """
+        jobs = self.session.query(BatchJob).filter(BatchJob.status.in_(JobStatus.ACTIVE)).all()
+        for job in jobs:
+            job.status = JobStatus.FAILED
+            job.error = "Interrupted by a restart"
+            job.finished_at = datetime.utcnow()
+        self.session.commit()
+        return len(jobs)
+
+
+class BatchJobRunner:
+    """Runs batch jobs in the background of the API process."""
+
+    def __init__(
+        self,
+        result_dir: str,
+        chunk_size: int = 1000,
+        concurrency: int = 2,
+        workers: int = 0,
+        input_dir: Optional[str] = None,
+        session_factory: Callable[[], Session] = _default_session,
+        batch_service: Optional[BatchLookupService] = None,
+        fetch: Callable[..., Any] = fetch_source,
+    ):
+        """Initialize runner.
+
+        Args:
+            result_dir: Directory for downloaded inputs and NDJSON results
+            chunk_size: Rows looked up between progress updates
+            concurrency: Jobs processed at the same time (others wait queued)
+            workers: Batch lookup workers one job uses at most (0: the pool's
+                per-batch limit)
+            input_dir: Directory file: references are resolved in
+            session_factory: Creates database sessions for progress updates
+            batch_service: Worker pool (default: the global batch lookup service)
+            fetch: Coroutine function making an input available (fetch_source)
+        """
+        self.result_dir = result_dir
+        self.chunk_size = max(1, chunk_size)
+        self.workers = max(0, workers) or None
+        self.input_dir = input_dir
+        self.session_factory = session_factory
+        self.batch_service = batch_service
+        self.fetch = fetch
+        self._slots = asyncio.Semaphore(max(1, concurrency))
+        self._tasks: Dict[str, asyncio.Task] = {}
+
+    def result_path(self, job_id: str) -> str:
+        """Where a job's NDJSON results are written."""
+        return os.path.join(self.result_dir, f"{job_id}.ndjson")
+
+    def input_path(self, job_id: str) -> str:
+        """Where a job's input is downloaded (or its upload stored) until processed."""
+        return os.path.join(self.result_dir, f"{job_id}.input")
+
+    def _update(self, job_id: str, **fields: Any) -> BatchJob:
+        session = self.session_factory()
+        try:
+            return BatchJobService(session).update(job_id, **fields)
+        finally:
+            session.close()
+
+    def submit(
+        self,
+        job_id: str,
+        handler: Callable[[Row], Any],
+        to_record: Callable[[BatchOutcome], Dict[str, Any]],
+    ) -> asyncio.Task:
+        """Start a queued job in the background (see run)."""
+        task = asyncio.create_task(self.run(job_id, handler, to_record))
+        self._tasks[job_id] = task
+        task.add_done_callback(lambda _: self._tasks.pop(job_id, None))
+        return task
+
+    async def run(
+        self,
+        job_id: str,
+        handler: Callable[[Row], Any],
+        to_record: Callable[[BatchOutcome], Dict[str, Any]],
+    ) -> None:
+        """Process a queued job to completion, storing progress as it goes.
+
+        Args:
+            job_id: Job to run
+            handler: Lookup for one row; raises ValueError, LookupError or
+                RuntimeError for per-row errors, as for BatchLookupService.run
+            to_record: JSON-ready result line of an outcome
+        """
+        async with self._slots:
+            try:
+                await self._run(job_id, handler, to_record)
+            except asyncio.CancelledError:
+                self._update(
+                    job_id, status=JobStatus.FAILED, error="Interrupted by shutdown", finished_at=datetime.utcnow()
+                )
+                raise
+            except Exception as e:
+                known = isinstance(e, (ValueError, RuntimeError))
+                if not known:
+                    logger.error(f"Batch job {job_id} failed: {type(e).__name__}: {str(e)}", exc_info=True)
+                try:
+                    await asyncio.to_thread(
+                        self._update,
+                        job_id,
+                        status=JobStatus.FAILED,
+                        error=str(e)[:1000] if known else "Internal error",
+                        finished_at=datetime.utcnow(),
+                    )
+                except Exception as update_error:
+                    logger.error(f"Failed to record the failure of batch job {job_id}: {str(update_error)}")
+
+    async def _run(
+        self,
+        job_id: str,
+        handler: Callable[[Row], Any],
+        to_record: Callable[[BatchOutcome], Dict[str, Any]],
+    ) -> None:
+        batch_service = self.batch_service or get_batch_lookup_service()
+        chunk_size = min(self.chunk_size, batch_service.max_items)
+        job = await asyncio.to_thread(
+            self._update, job_id, status=JobStatus.RUNNING, started_at=datetime.utcnow()
+        )
+        os.makedirs(self.result_dir, exist_ok=True)
+        download_path = self.input_path(job_id)
+        result_path = self.result_path(job_id)
+        partial_path = result_path + ".part"
+        input_path = None
+        try:
+            input_path = await self.fetch(job.source, download_path, self.input_dir)
+            total = await asyncio.to_thread(count_rows, input_path)
+            await asyncio.to_thread(self._update, job_id, total_rows=total)
+
+            rows = read_rows(input_path)
+            processed = failed = 0
+            with open(partial_path, "w", encoding="utf-8") as out:
+                while True:
+                    chunk = await asyncio.to_thread(lambda: list(itertools.islice(rows, chunk_size)))
+                    if not chunk:
+                        break
+                    outcomes = await batch_service.run(
+                        chunk, handler, max_workers=self.workers, wait=True
+                    )
+                    await asyncio.to_thread(self._write, out, outcomes, processed, to_record)
+                    processed += len(outcomes)
+                    failed += sum(1 for outcome in outcomes if not outcome.ok)
+                    await asyncio.to_thread(self._update, job_id, processed_rows=processed, failed_rows=failed)
+            os.replace(partial_path, result_path)
+        finally:
+            if input_path == download_path and os.path.exists(download_path):
+                os.remove(download_path)
+            if os.path.exists(partial_path):
+                os.remove(partial_path)
+
+        await asyncio.to_thread(
+            self._update,
+            job_id,
+            status=JobStatus.SUCCEEDED,
+            result_path=result_path,
+            finished_at=datetime.utcnow(),
+        )
+        logger.info(f"Batch job {job_id} finished: {processed} rows, {failed} failed")
+
+    @staticmethod
+    def _write(
+        out: Any,
+        outcomes: List[BatchOutcome],
+        offset: int,
+        to_record: Callable[[BatchOutcome], Dict[str, Any]],
+    ) -> None:
+        for outcome in outcomes:
+            record = to_record(dataclasses.replace(outcome, index=offset + outcome.index))
+            out.write(json.dumps(record) + "\n")
+
+    def recover(self) -> int:
+        """Mark jobs a previous process left queued or running failed.
+
+        Returns:
+            Number of jobs marked failed (0 if the database is unreachable,
+            which is logged)
+        """
+        try:
+            session = self.session_factory()
+            try:
+                interrupted = BatchJobService(session).fail_interrupted()
+            finally:
+                session.close()
+        except Exception as e:
+            logger.warning(f"Could not check for interrupted batch jobs: {str(e)}")
+            return 0
+        if interrupted:
+            logger.warning(f"Marked {interrupted} interrupted batch jobs failed")
+        return interrupted
+
+    async def stop(self) -> None:
+        """Cancel running jobs (they are marked failed)."""
+        tasks = list(self._tasks.values())
+        for task in tasks:
+            task.cancel()
+        if tasks:
+            await asyncio.gather(*tasks, return_exceptions=True)
+
+
+# Global batch job runner (created lazily from configuration)
+_batch_job_runner: Optional[BatchJobRunner] = None
+
+
+def get_batch_job_runner() -> BatchJobRunner:
+    """Get the global batch job runner."""
+    global _batch_job_runner
+    if _batch_job_runner is None:
+        from src.config import get_config
+
+        config = get_config()
+        _batch_job_runner = BatchJobRunner(
+            result_dir=config.batch_job_result_dir,
+            chunk_size=config.batch_job_chunk_size,
+            concurrency=config.batch_job_concurrency,
+            workers=config.batch_job_workers,
+            input_dir=config.batch_job_input_dir or None,
+        )
+    return _batch_job_runner
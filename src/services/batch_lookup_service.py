@@ -0,0 +1,219 @@
+"""Concurrent execution of bulk lookups.
+
+A batch is split into chunks that run on a shared thread pool, so large
+batches of mmap-backed IP or boundary lookups do not block the event loop.
+Each item succeeds or fails on its own; failures are reported per item
+with the same error codes the single-item endpoints use.
+
+The pool is bounded so that one huge batch cannot starve the others or
+online traffic: a batch runs at most BATCH_LOOKUP_WORKERS_PER_BATCH chunks
+at a time (background jobs BATCH_JOB_WORKERS), so concurrent batches take
+turns on the workers, and once BATCH_LOOKUP_MAX_PENDING_ITEMS items are
+waiting or running, new request batches are refused (503) while
+background jobs wait for room.
+"""
+
+import asyncio
+import logging
+import math
+import threading
+from concurrent.futures import ThreadPoolExecutor
+from dataclasses import dataclass
+from typing import Any, Callable, List, Optional, Sequence, Tuple, TypeVar
+
+logger = logging.getLogger(__name__)
+
+T = TypeVar("T")
+
+# Exception type -> API error code (first match wins)
+ERROR_CODES: Tuple[Tuple[type, str], ...] = (
+    (ValueError, "E002"),
+    (LookupError, "E004"),
+    (RuntimeError, "E003"),
+)
+
+
+class BatchOverloadedError(RuntimeError):
+    """Too many batch items are pending to accept another batch now."""
+
+
+@dataclass
+class BatchOutcome:
+    """Result or error for one batch item."""
+    index: int
+    result: Optional[Any] = None
+    error_code: Optional[str] = None
+    error_message: Optional[str] = None
+
+    @property
+    def ok(self) -> bool:
+        """True if the item succeeded."""
+        return self.error_code is None
+
+
+class BatchLookupService:
+    """Runs a lookup function over many items with a worker pool."""
+
+    # Seconds between checks for room while a waiting batch is held back
+    ADMIT_POLL_SECONDS = 0.05
+
+    def __init__(
+        self,
+        workers: int = 8,
+        max_items: int = 10000,
+        workers_per_batch: int = 0,
+        max_pending_items: int = 0,
+    ):
+        """Initialize batch service.
+
+        Args:
+            workers: Worker threads shared by all batches
+            max_items: Largest accepted batch
+            workers_per_batch: Workers one batch uses at most (0: all)
+            max_pending_items: Items waiting or running beyond which new
+                batches are held back (0: unbounded)
+        """
+        self.workers = max(1, workers)
+        self.max_items = max_items
+        self.workers_per_batch = (
+            min(self.workers, workers_per_batch) if workers_per_batch > 0 else self.workers
+        )
+        self.max_pending_items = max_pending_items
+        self._pending_items = 0
+        self._lock = threading.Lock()
+        self._executor = ThreadPoolExecutor(
+            max_workers=self.workers, thread_name_prefix="batch-lookup"
+        )
+
+    @property
+    def pending_items(self) -> int:
+        """Items of accepted batches not looked up yet."""
+        return self._pending_items
+
+    async def run(
+        self,
+        items: Sequence[T],
+        handler: Callable[[T], Any],
+        max_workers: Optional[int] = None,
+        wait: bool = False,
+    ) -> List[BatchOutcome]:
+        """Apply a handler to every item concurrently.
+
+        Args:
+            items: Batch items
+            handler: Lookup for one item; raises ValueError (invalid input),
+                LookupError (no data) or RuntimeError (backend unavailable)
+            max_workers: Workers this batch uses at most (default:
+                workers_per_batch)
+            wait: Wait for room when max_pending_items are pending, rather
+                than refusing the batch (for background jobs)
+
+        Returns:
+            One outcome per item, in input order
+
+        Raises:
+            ValueError: If the batch is empty or larger than max_items
+            BatchOverloadedError: If max_pending_items are pending and not wait
+        """
+        if not items:
+            raise ValueError("Batch must contain at least one item")
+        if len(items) > self.max_items:
+            raise ValueError(
+                f"Batch of {len(items)} items exceeds the limit of {self.max_items}"
+            )
+
+        await self._admit(len(items), wait)
+        try:
+            width = min(max_workers or self.workers_per_batch, self.workers)
+            # A few chunks per worker keeps threads busy without a task per item;
+            # only `width` of them are queued at once, so other batches interleave
+            chunk_size = max(1, math.ceil(len(items) / (width * 4)))
+            slots = asyncio.Semaphore(width)
+            loop = asyncio.get_running_loop()
+
+            async def run_chunk(start: int) -> List[BatchOutcome]:
+                async with slots:
+                    return await loop.run_in_executor(
+                        self._executor, self._run_chunk, handler, items, start, chunk_size
+                    )
+
+            chunks = await asyncio.gather(*[
+                run_chunk(start) for start in range(0, len(items), chunk_size)
+            ])
+        finally:
+            with self._lock:
+                self._pending_items -= len(items)
+        return [outcome for chunk in chunks for outcome in chunk]
+
+    async def _admit(self, count: int, wait: bool) -> None:
+        """Count a batch as pending once there is room for it."""
+        from src.metrics import BATCH_LOOKUPS_REFUSED
+
+        while True:
+            with self._lock:
+                # A batch larger than the bound still runs, alone
+                if (
+                    self.max_pending_items <= 0
+                    or self._pending_items == 0
+                    or self._pending_items + count <= self.max_pending_items
+                ):
+                    self._pending_items += count
+                    return
+                pending = self._pending_items
+            if not wait:
+                BATCH_LOOKUPS_REFUSED.inc()
+                raise BatchOverloadedError(
+                    f"{pending} batch items are pending (limit {self.max_pending_items}); try again later"
+                )
+            await asyncio.sleep(self.ADMIT_POLL_SECONDS)
+
+    @staticmethod
+    def _run_chunk(
+        handler: Callable[[T], Any], items: Sequence[T], start: int, size: int
+    ) -> List[BatchOutcome]:
+        outcomes = []
+        for index in range(start, min(start + size, len(items))):
+            try:
+                outcomes.append(BatchOutcome(index, result=handler(items[index])))
+            except Exception as e:
+                outcomes.append(error_outcome(index, e))
+        return outcomes
+
+    def shutdown(self) -> None:
+        """Stop the worker pool."""
+        self._executor.shutdown(wait=False)
+
+
+def error_outcome(index: int, error: Exception) -> BatchOutcome:
+    """Outcome of an item whose handler raised (E999 for unexpected errors, which are logged)."""
+    for error_type, code in ERROR_CODES:
+        if isinstance(error, error_type):
+            return BatchOutcome(index, error_code=code, error_message=str(error))
+    logger.error(f"Batch item {index} failed: {type(error).__name__}: {str(error)}")
+    return BatchOutcome(index, error_code="E999", error_message="Internal error")
+
+
+# Global batch service (worker pool sized from configuration)
+_batch_lookup_service: Optional[BatchLookupService] = None
+
+
+def get_batch_lookup_service() -> BatchLookupService:
+    """Get the global batch lookup service.
+
+    Returns:
+        BatchLookupService sized by the BATCH_LOOKUP_* settings
+    """
+    global _batch_lookup_service
+    if _batch_lookup_service is None:
+        from src.config import get_config
+        from src.metrics import BATCH_LOOKUP_PENDING_ITEMS
+
+        config = get_config()
+        _batch_lookup_service = BatchLookupService(
+            workers=config.batch_lookup_workers,
+            max_items=config.batch_lookup_max_items,
+            workers_per_batch=config.batch_lookup_workers_per_batch,
+            max_pending_items=config.batch_lookup_max_pending_items,
+        )
+        BATCH_LOOKUP_PENDING_ITEMS.set_function(lambda: _batch_lookup_service.pending_items)
+    return _batch_lookup_service
@@ -0,0 +1,553 @@
+"""Billing metering of tenant requests and its export.
+
+Every billable request (a tenant's authenticated request outside
+BILLING_EXEMPT_PATHS answered below 400) is counted per tenant and UTC
+hour. Counts are kept in memory and added to the `billing_usage` table
+every BILLING_FLUSH_INTERVAL_SECONDS, like the usage analytics, so every
+worker's counts end up in the same rows.
+
+Once an hour has ended (plus BILLING_EXPORT_DELAY_SECONDS, so that every
+worker has flushed it) its counts are exported:
+
+- as a CSV file (tenant_id, period_start, period_end, requests) written
+  to BILLING_EXPORT_URL, an s3://bucket/prefix (needs the s3 extra) or a
+  local directory,
+- and, for the tenants mapped to a customer in BILLING_STRIPE_CUSTOMERS,
+  as Stripe meter events of BILLING_STRIPE_EVENT_NAME.
+
+Each row records how many of its requests were exported and reported, so
+an export only carries what was not sent before: requests flushed after
+their hour was exported are sent by the next export as an adjustment,
+and nothing is sent twice.
+"""
+
+import asyncio
+import csv
+import io
+import json
+import logging
+import os
+import threading
+from collections import Counter
+from dataclasses import dataclass
+from datetime import datetime, timedelta
+from typing import Any, Awaitable, Callable, Dict, Iterable, List, Optional, Sequence, Tuple
+from urllib.parse import urlencode, urlparse
+
+from sqlalchemy.exc import SQLAlchemyError
+from sqlalchemy.orm import Session
+
+from src.models.database_models import BillingUsage
+
+logger = logging.getLogger(__name__)
+
+HOUR_FORMAT = "%Y-%m-%dT%H"
+CSV_COLUMNS = ("tenant_id", "period_start", "period_end", "requests")
+STRIPE_METER_EVENTS_URL = "https://api.stripe.com/v1/billing/meter_events"
+
+# (url, form fields, headers, timeout_seconds) -> (HTTP status, body)
+StripeSender = Callable[[str, Dict[str, str], Dict[str, str], float], Awaitable[Tuple[int, str]]]
+
+
+def hour_of(at: datetime) -> str:
+    """Metering period of a time: its UTC hour, YYYY-MM-DDTHH."""
+    return at.strftime(HOUR_FORMAT)
+
+
+def billable(path: str, status_code: int, exempt_paths: Sequence[str] = ()) -> bool:
+    """Whether a tenant's answered request is billed."""
+    return status_code < 400 and not path.startswith(tuple(p for p in exempt_paths if p))
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+class BillingMeter:
+    """Counts billable requests in memory and adds them to the database periodically."""
+
+    def __init__(self, session_factory: Callable[[], Session], flush_interval_seconds: int = 60):
+        """Initialize meter.
+
+        Args:
+            session_factory: Creates database sessions for flushing
+            flush_interval_seconds: Seconds between flushes
+        """
+        self.session_factory = session_factory
+        self.flush_interval_seconds = max(1, flush_interval_seconds)
+        self._counts: Counter = Counter()
+        self._lock = threading.Lock()
+        self._running = False
+
+    def record(self, tenant_id: str, at: Optional[datetime] = None) -> None:
+        """Count one billable request."""
+        with self._lock:
+            self._counts[(tenant_id, hour_of(at or datetime.utcnow()))] += 1
+
+    def _take(self) -> Dict[Tuple[str, str], int]:
+        with self._lock:
+            counts, self._counts = self._counts, Counter()
+        return dict(counts)
+
+    def _restore(self, counts: Dict[Tuple[str, str], int]) -> None:
+        with self._lock:
+            self._counts.update(counts)
+
+    def flush(self) -> int:
+        """Add the counts so far to the database.
+
+        Counts that cannot be stored are kept for the next flush.
+
+        Returns:
+            Tenant hours written
+        """
+        counts = self._take()
+        if not counts:
+            return 0
+        session = self.session_factory()
+        try:
+            for (tenant_id, hour), requests in counts.items():
+                updated = session.query(BillingUsage).filter(
+                    BillingUsage.tenant_id == tenant_id, BillingUsage.hour == hour
+                ).update({BillingUsage.requests: BillingUsage.requests + requests}, synchronize_session=False)
+                if not updated:
+                    session.add(BillingUsage(tenant_id=tenant_id, hour=hour, requests=requests))
+            session.commit()
+        except SQLAlchemyError as e:
+            # Another worker may have created one of the rows meanwhile; retried next time
+            session.rollback()
+            self._restore(counts)
+            logger.error(f"Failed to store billing usage: {str(e)}")
+            return 0
+        finally:
+            session.close()
+        return len(counts)
+
+    async def start(self) -> None:
+        """Flush periodically until stopped."""
+        self._running = True
+        try:
+            while self._running:
+                await asyncio.sleep(self.flush_interval_seconds)
+                await asyncio.to_thread(self.flush)
+        except asyncio.CancelledError:
+            self._running = False
+
+    def stop(self) -> None:
+        """Stop flushing after the current iteration."""
+        self._running = False
+
+
+@dataclass
+class BillingRecord:
+    """Billable requests of a tenant in one hour."""
+
+    tenant_id: str
+    hour: str
+    requests: int
+
+    @property
+    def period_start(self) -> datetime:
+        return datetime.strptime(self.hour, HOUR_FORMAT)
+
+    @property
+    def period_end(self) -> datetime:
+        return self.period_start + timedelta(hours=1)
+
+
+def to_csv(records: Iterable[BillingRecord]) -> str:
+    """CSV of records, with a header row; periods in ISO 8601 UTC."""
+    out = io.StringIO()
+    writer = csv.writer(out, lineterminator="\n")
+    writer.writerow(CSV_COLUMNS)
+    for record in records:
+        writer.writerow([
+            record.tenant_id,
+            record.period_start.isoformat() + "Z",
+            record.period_end.isoformat() + "Z",
+            record.requests,
+        ])
+    return out.getvalue()
+
+
+class BillingService:
+    """Reads stored billing usage."""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def hourly(self, since: datetime, until: datetime, tenant_id: Optional[str] = None) -> List[BillingRecord]:
+        """Billable requests of the hours from since up to (excluding) until, by tenant and hour.
+
+        Raises:
+            ValueError: If until is not after since
+        """
+        if until <= since:
+            raise ValueError("until must be after since")
+        query = self.session.query(BillingUsage).filter(
+            BillingUsage.hour >= hour_of(since), BillingUsage.hour < hour_of(until)
+        )
+        if tenant_id is not None:
+            query = query.filter(BillingUsage.tenant_id == tenant_id)
+        return [
+            BillingRecord(row.tenant_id, row.hour, row.requests)
+            for row in query.order_by(BillingUsage.hour, BillingUsage.tenant_id)
+        ]
+
+
+class ExportSink:
+    """Destination of export files."""
+
+    def write(self, name: str, body: bytes) -> str:
+        """Store a file; returns where it was written."""
+        raise NotImplementedError
+
+
+class DirectorySink(ExportSink):
+    """Writes export files to a local directory."""
+
+    def __init__(self, path: str):
+        self.path = path
+
+    def write(self, name: str, body: bytes) -> str:
+        os.makedirs(self.path, exist_ok=True)
+        target = os.path.join(self.path, name)
+        partial = target + ".partial"
+        with open(partial, "wb") as f:
+            f.write(body)
+        os.replace(partial, target)  # Readers never see a half-written file
+        return target
+
+
+class S3Sink(ExportSink):
+    """Writes export files to an S3 bucket."""
+
+    def __init__(self, bucket: str, prefix: str = "", client: Any = None):
+        """Initialize sink.
+
+        Args:
+            bucket: Bucket name
+            prefix: Key prefix of the files
+            client: Optional boto3 S3 client (created on demand)
+        """
+        self.bucket = bucket
+        self.prefix = prefix.strip("/")
+        self.client = client
+
+    def write(self, name: str, body: bytes) -> str:
+        if self.client is None:
+            try:
+                import boto3
+            except ImportError:
+                raise RuntimeError("boto3 is required for s3:// billing exports")
+            self.client = boto3.client("s3")
+        key = f"{self.prefix}/{name}" if self.prefix else name
+        self.client.put_object(Bucket=self.bucket, Key=key, Body=body, ContentType="text/csv")
+        return f"s3://{self.bucket}/{key}"
+
+
+def export_sink(url: str) -> Optional[ExportSink]:
+    """Sink of a BILLING_EXPORT_URL (None when it is empty).
+
+    Raises:
+        ValueError: If the URL is neither s3://bucket[/prefix] nor a directory
+    """
+    url = url.strip()
+    if not url:
+        return None
+    parsed = urlparse(url)
+    if parsed.scheme == "s3":
+        if not parsed.netloc:
+            raise ValueError("S3 export URLs need a bucket: s3://bucket/prefix")
+        return S3Sink(parsed.netloc, parsed.path)
+    if parsed.scheme == "file":
+        return DirectorySink(parsed.path)
+    if not parsed.scheme:
+        return DirectorySink(url)
+    raise ValueError("BILLING_EXPORT_URL must be an s3:// URL or a directory")
+
+
+async def _post_form(url: str, fields: Dict[str, str], headers: Dict[str, str], timeout_seconds: float) -> Tuple[int, str]:
+    import aiohttp
+
+    async with aiohttp.ClientSession() as session:
+        async with session.post(
+            url,
+            data=urlencode(fields),
+            headers={**headers, "Content-Type": "application/x-www-form-urlencoded"},
+            timeout=aiohttp.ClientTimeout(total=timeout_seconds),
+        ) as response:
+            return response.status, await response.text()
+
+
+class StripeMeterReporter:
+    """Reports billable requests as Stripe meter events."""
+
+    def __init__(
+        self,
+        api_key: str,
+        customers: Dict[str, str],
+        event_name: str = "geolocation_requests",
+        timeout_seconds: float = 10.0,
+        sender: Optional[StripeSender] = None,
+    ):
+        """Initialize reporter.
+
+        Args:
+            api_key: Stripe secret key
+            customers: Tenant ID -> Stripe customer ID; other tenants are not reported
+            event_name: Event name of the Stripe meter
+            timeout_seconds: Timeout of each call
+            sender: Posts a form (default: aiohttp)
+        """
+        self.api_key = api_key
+        self.customers = dict(customers)
+        self.event_name = event_name
+        self.timeout_seconds = timeout_seconds
+        self.sender = sender or _post_form
+
+    async def report(self, record: BillingRecord, identifier: str) -> bool:
+        """Send one meter event; identifier makes retries of it idempotent."""
+        fields = {
+            "event_name": self.event_name,
+            "identifier": identifier,
+            "timestamp": str(int((record.period_start - datetime(1970, 1, 1)).total_seconds())),
+            "payload[stripe_customer_id]": self.customers[record.tenant_id],
+            "payload[value]": str(record.requests),
+        }
+        try:
+            status_code, body = await self.sender(
+                STRIPE_METER_EVENTS_URL, fields, {"Authorization": f"Bearer {self.api_key}"}, self.timeout_seconds
+            )
+        except Exception as e:
+            logger.error(f"Failed to report billing usage of {record.tenant_id} ({record.hour}) to Stripe: {e}")
+            return False
+        if status_code >= 300:
+            logger.error(
+                f"Stripe refused billing usage of {record.tenant_id} ({record.hour}): {status_code} {body[:200]}"
+            )
+            return False
+        return True
+
+
+def parse_customers(raw: str) -> Dict[str, str]:
+    """Parse BILLING_STRIPE_CUSTOMERS, a JSON object of tenant ID -> Stripe customer ID.
+
+    Raises:
+        ValueError: If it is not such an object
+    """
+    if not raw.strip():
+        return {}
+    try:
+        customers = json.loads(raw)
+    except json.JSONDecodeError as e:
+        raise ValueError(f"Invalid BILLING_STRIPE_CUSTOMERS: {str(e)}")
+    if not isinstance(customers, dict) or not all(
+        isinstance(tenant, str) and isinstance(customer, str) and customer for tenant, customer in customers.items()
+    ):
+        raise ValueError("Invalid BILLING_STRIPE_CUSTOMERS: expected a JSON object of tenant ID -> customer ID")
+    return customers
+
+
+@dataclass
+class ExportResult:
+    """Outcome of one export run."""
+
+    records: int = 0                    # Written to the export file
+    requests: int = 0                   # Requests in them
+    location: Optional[str] = None      # Where the file was written
+    reported: int = 0                   # Sent to Stripe
+    failed: int = 0                     # Not sent to Stripe (retried next run)
+
+
+class BillingExporter:
+    """Exports the billable requests of ended hours."""
+
+    def __init__(
+        self,
+        session_factory: Callable[[], Session],
+        sink: Optional[ExportSink] = None,
+        stripe: Optional[StripeMeterReporter] = None,
+        delay_seconds: int = 300,
+        interval_seconds: int = 3600,
+    ):
+        """Initialize exporter.
+
+        Args:
+            session_factory: Creates database sessions
+            sink: Destination of export files (None: no files)
+            stripe: Reporter of Stripe meter events (None: not reported)
+            delay_seconds: How long after its end an hour is exported
+            interval_seconds: Seconds between runs of start()
+        """
+        self.session_factory = session_factory
+        self.sink = sink
+        self.stripe = stripe
+        self.delay_seconds = max(0, delay_seconds)
+        self.interval_seconds = max(1, interval_seconds)
+        self._running = False
+
+    @property
+    def configured(self) -> bool:
+        return self.sink is not None or self.stripe is not None
+
+    def last_closed_hour(self, now: datetime) -> str:
+        """Latest hour that ended at least delay_seconds before now."""
+        return hour_of(now - timedelta(seconds=self.delay_seconds) - timedelta(hours=1))
+
+    def export_file(self, now: Optional[datetime] = None) -> ExportResult:
+        """Write the requests of ended hours not exported yet to a file.
+
+        Raises:
+            RuntimeError: If the file cannot be written (nothing is marked exported)
+        """
+        result = ExportResult()
+        if self.sink is None:
+            return result
+        now = now or datetime.utcnow()
+        session = self.session_factory()
+        try:
+            rows = session.query(BillingUsage).filter(
+                BillingUsage.hour <= self.last_closed_hour(now),
+                BillingUsage.requests > BillingUsage.exported_requests,
+            ).order_by(BillingUsage.hour, BillingUsage.tenant_id).all()
+            if not rows:
+                return result
+            # The counts read now are exported; later flushes go to the next export
+            exported = {row.id: row.requests for row in rows}
+            records = [BillingRecord(row.tenant_id, row.hour, row.requests - row.exported_requests) for row in rows]
+            try:
+                result.location = self.sink.write(
+                    f"billing-usage-{now.strftime('%Y%m%dT%H%M%S')}.csv", to_csv(records).encode("utf-8")
+                )
+            except Exception as e:
+                raise RuntimeError(f"Failed to write billing export: {e}")
+            for row in rows:
+                row.exported_requests = exported[row.id]
+            session.commit()
+        except SQLAlchemyError as e:
+            session.rollback()
+            raise RuntimeError(f"Failed to mark billing usage exported: {str(e)}")
+        finally:
+            session.close()
+        result.records = len(records)
+        result.requests = sum(record.requests for record in records)
+        logger.info(f"Exported {result.records} billing records ({result.requests} requests) to {result.location}")
+        return result
+
+    async def report_stripe(self, result: ExportResult, now: Optional[datetime] = None) -> ExportResult:
+        """Send the requests of ended hours not reported yet to Stripe."""
+        if self.stripe is None or not self.stripe.customers:
+            return result
+        now = now or datetime.utcnow()
+
+        def pending() -> List[Tuple[int, int, BillingRecord]]:
+            session = self.session_factory()
+            try:
+                rows = session.query(BillingUsage).filter(
+                    BillingUsage.hour <= self.last_closed_hour(now),
+                    BillingUsage.requests > BillingUsage.reported_requests,
+                    BillingUsage.tenant_id.in_(list(self.stripe.customers)),
+                ).order_by(BillingUsage.hour, BillingUsage.tenant_id).all()
+                return [
+                    (row.id, row.requests, BillingRecord(row.tenant_id, row.hour, row.requests - row.reported_requests))
+                    for row in rows
+                ]
+            finally:
+                session.close()
+
+        def mark(row_id: int, reported: int) -> None:
+            session = self.session_factory()
+            try:
+                session.query(BillingUsage).filter(BillingUsage.id == row_id).update(
+                    {BillingUsage.reported_requests: reported}, synchronize_session=False
+                )
+                session.commit()
+            except SQLAlchemyError as e:
+                # The identifier of the event keeps its resend from counting twice
+                session.rollback()
+                logger.error(f"Failed to mark billing usage reported: {str(e)}")
+            finally:
+                session.close()
+
+        for row_id, requests, record in await asyncio.to_thread(pending):
+            identifier = f"{record.tenant_id}:{record.hour}:{requests - record.requests}"
+            if await self.stripe.report(record, identifier):
+                await asyncio.to_thread(mark, row_id, requests)
+                result.reported += 1
+            else:
+                result.failed += 1
+        if result.reported or result.failed:
+            logger.info(f"Reported {result.reported} billing records to Stripe ({result.failed} failed)")
+        return result
+
+    async def run_once(self, now: Optional[datetime] = None) -> ExportResult:
+        """Export ended hours to the file sink and Stripe.
+
+        Raises:
+            RuntimeError: If the export file cannot be written
+        """
+        result = await asyncio.to_thread(self.export_file, now)
+        return await self.report_stripe(result, now)
+
+    async def start(self) -> None:
+        """Export periodically until stopped."""
+        self._running = True
+        try:
+            while self._running:
+                await asyncio.sleep(self.interval_seconds)
+                try:
+                    await self.run_once()
+                except RuntimeError as e:
+                    logger.error(str(e))
+        except asyncio.CancelledError:
+            self._running = False
+
+    def stop(self) -> None:
+        """Stop exporting after the current iteration."""
+        self._running = False
+
+
+# Global meter and exporter (from configuration)
+_billing_meter: Optional[BillingMeter] = None
+_billing_exporter: Optional[BillingExporter] = None
+
+
+def get_billing_meter() -> BillingMeter:
+    """Get the global billing meter."""
+    global _billing_meter
+    if _billing_meter is None:
+        from src.config import get_config
+
+        _billing_meter = BillingMeter(_default_session, get_config().billing_flush_interval_seconds)
+    return _billing_meter
+
+
+def get_billing_exporter() -> BillingExporter:
+    """Get the global billing exporter.
+
+    Raises:
+        ValueError: If BILLING_EXPORT_URL or BILLING_STRIPE_CUSTOMERS is invalid
+    """
+    global _billing_exporter
+    if _billing_exporter is None:
+        from src.config import get_config
+
+        config = get_config()
+        stripe = None
+        if config.billing_stripe_api_key:
+            stripe = StripeMeterReporter(
+                config.billing_stripe_api_key,
+                parse_customers(config.billing_stripe_customers),
+                config.billing_stripe_event_name,
+            )
+        _billing_exporter = BillingExporter(
+            _default_session,
+            export_sink(config.billing_export_url),
+            stripe,
+            config.billing_export_delay_seconds,
+            config.billing_export_interval_seconds,
+        )
+    return _billing_exporter
@@ -0,0 +1,334 @@
+"""Warming the lookup cache at startup from the hottest lookups.
+
+A new pod starts with an empty L1, and after a dataset update or a Redis
+restart the shared cache is empty too, so the first minutes of traffic
+miss and latency spikes. With LOOKUP_CACHE_WARM_ENABLED each process
+counts its lookups by query (see src.services.lookup_cache_service) and
+every LOOKUP_CACHE_WARM_PERSIST_INTERVAL_SECONDS adds the counts to the
+hot key store: a sorted set in the Redis of LOOKUP_CACHE_REDIS_URL,
+shared by all pods, or else the JSON file at LOOKUP_CACHE_HOT_KEYS_PATH.
+
+At startup the LOOKUP_CACHE_WARM_TOP_N hottest queries are looked up
+again, against the data now loaded: entries still in Redis are copied
+into L1, the others are computed and stored in both tiers. Until that is
+done (or LOOKUP_CACHE_WARM_TIMEOUT_SECONDS has passed) /readyz reports
+the `lookup_cache_warming` check failed, so no traffic is routed to the
+pod while it is cold. Counts are cumulative; the store keeps twice
+LOOKUP_CACHE_WARM_TOP_N queries.
+"""
+
+import asyncio
+import json
+import logging
+import os
+import threading
+import time
+from contextlib import contextmanager
+from typing import Any, Callable, Dict, Iterable, Iterator, List, Optional
+
+logger = logging.getLogger(__name__)
+
+# Hot queries of a process not persisted yet, at most
+MAX_TRACKED = 10_000
+
+
+def hot_key(kind: str, query: Any) -> str:
+    """Store member of a lookup: its type and query as canonical JSON."""
+    return json.dumps({"type": kind, "query": query}, sort_keys=True, default=str)
+
+
+class HotKeyTracker:
+    """Counts lookups per query until they are persisted."""
+
+    def __init__(self, kinds: Iterable[str], max_tracked: int = MAX_TRACKED):
+        """Initialize tracker.
+
+        Args:
+            kinds: Lookup types counted (those that can be warmed)
+            max_tracked: Queries counted at most; the least counted half is
+                dropped when more arrive
+        """
+        self.kinds = frozenset(kinds)
+        self.max_tracked = max(2, max_tracked)
+        self._counts: Dict[str, int] = {}
+        self._lock = threading.Lock()
+        self._paused = False
+
+    def record(self, kind: str, query: Any) -> None:
+        if self._paused or kind not in self.kinds:
+            return
+        key = hot_key(kind, query)
+        with self._lock:
+            self._counts[key] = self._counts.get(key, 0) + 1
+            if len(self._counts) > self.max_tracked:
+                kept = sorted(self._counts.items(), key=lambda item: item[1], reverse=True)
+                self._counts = dict(kept[: self.max_tracked // 2])
+
+    def take(self) -> Dict[str, int]:
+        """The counts so far, resetting them."""
+        with self._lock:
+            counts, self._counts = self._counts, {}
+        return counts
+
+    def restore(self, counts: Dict[str, int]) -> None:
+        """Put back counts that could not be persisted."""
+        with self._lock:
+            for key, count in counts.items():
+                self._counts[key] = self._counts.get(key, 0) + count
+
+    @contextmanager
+    def paused(self) -> Iterator[None]:
+        """Lookups made while warming are not counted, or warmed keys would stay hot for good."""
+        self._paused = True
+        try:
+            yield
+        finally:
+            self._paused = False
+
+
+class HotKeyStore:
+    """Persisted lookup counts."""
+
+    def add(self, counts: Dict[str, int], keep: int) -> None:
+        """Add counts, keeping the `keep` most counted queries."""
+        raise NotImplementedError
+
+    def top(self, n: int) -> List[str]:
+        """The n most counted queries, most counted first."""
+        raise NotImplementedError
+
+
+class FileHotKeyStore(HotKeyStore):
+    """A JSON object of query -> count in a file (for a single host)."""
+
+    def __init__(self, path: str):
+        self.path = path
+
+    def _load(self) -> Dict[str, int]:
+        try:
+            with open(self.path) as f:
+                counts = json.load(f)
+        except FileNotFoundError:
+            return {}
+        except (OSError, ValueError) as e:
+            logger.warning(f"Ignoring unreadable hot key file {self.path}: {e}")
+            return {}
+        return counts if isinstance(counts, dict) else {}
+
+    def add(self, counts: Dict[str, int], keep: int) -> None:
+        merged = self._load()
+        for key, count in counts.items():
+            merged[key] = merged.get(key, 0) + count
+        kept = dict(sorted(merged.items(), key=lambda item: item[1], reverse=True)[:keep])
+        directory = os.path.dirname(self.path)
+        if directory:
+            os.makedirs(directory, exist_ok=True)
+        partial = f"{self.path}.tmp"
+        with open(partial, "w") as f:
+            json.dump(kept, f)
+        os.replace(partial, self.path)
+
+    def top(self, n: int) -> List[str]:
+        counts = self._load()
+        return sorted(counts, key=counts.get, reverse=True)[:n]
+
+
+class RedisHotKeyStore(HotKeyStore):
+    """A Redis sorted set of query -> count, shared by all pods."""
+
+    # Left untouched this long (no pod persisting), the set is dropped
+    EXPIRE_SECONDS = 7 * 86400
+
+    def __init__(self, client, key: str = "lookup:hot-keys"):
+        """Initialize Redis store.
+
+        Args:
+            client: redis.Redis client
+            key: Key of the sorted set
+        """
+        self.client = client
+        self.key = key
+
+    @classmethod
+    def from_url(cls, url: str, key: str = "lookup:hot-keys") -> "RedisHotKeyStore":
+        """Connect to Redis.
+
+        Raises:
+            RuntimeError: If the redis package is not installed
+        """
+        try:
+            import redis
+        except ImportError:
+            raise RuntimeError("redis is required for LOOKUP_CACHE_REDIS_URL")
+        return cls(redis.Redis.from_url(url), key)
+
+    def add(self, counts: Dict[str, int], keep: int) -> None:
+        pipe = self.client.pipeline()
+        for member, count in counts.items():
+            pipe.zincrby(self.key, count, member)
+        # Ranks are ascending: drop all but the `keep` highest
+        pipe.zremrangebyrank(self.key, 0, -keep - 1)
+        pipe.expire(self.key, self.EXPIRE_SECONDS)
+        pipe.execute()
+
+    def top(self, n: int) -> List[str]:
+        members = self.client.zrevrange(self.key, 0, n - 1)
+        return [m.decode() if isinstance(m, bytes) else m for m in members]
+
+
+class CacheWarmer:
+    """Persists the hot lookups periodically and looks them up again at startup."""
+
+    def __init__(
+        self,
+        tracker: HotKeyTracker,
+        store: HotKeyStore,
+        warmers: Dict[str, Callable[[Dict[str, Any]], None]],
+        top_n: int = 1000,
+        persist_interval_seconds: int = 300,
+        timeout_seconds: float = 60.0,
+    ):
+        """Initialize warmer.
+
+        Args:
+            tracker: Counts of this process's lookups
+            store: Persisted counts
+            warmers: Per lookup type, looks a query up through the cache
+            top_n: Queries warmed at startup
+            persist_interval_seconds: Seconds between persisting the counts
+            timeout_seconds: Longest warming runs before the pod is ready anyway
+        """
+        self.tracker = tracker
+        self.store = store
+        self.warmers = warmers
+        self.top_n = top_n
+        self.persist_interval_seconds = persist_interval_seconds
+        self.timeout_seconds = timeout_seconds
+        self.warmed: Optional[int] = None  # None until warming is done
+        self._running = False
+
+    @property
+    def ready(self) -> bool:
+        return self.warmed is not None
+
+    def warm(self, clock=time.monotonic) -> int:
+        """Look the hottest queries up again, filling both cache tiers.
+
+        Queries that fail (address no longer in the dataset, data not
+        loaded) are skipped. An unreachable store warms nothing.
+
+        Returns:
+            Queries warmed
+        """
+        try:
+            keys = self.store.top(self.top_n)
+        except Exception as e:
+            logger.warning(f"Lookup cache not warmed: hot keys unavailable: {e}")
+            self.warmed = 0
+            return 0
+        deadline = clock() + self.timeout_seconds
+        warmed = 0
+        with self.tracker.paused():
+            for key in keys:
+                if clock() >= deadline:
+                    logger.warning(f"Lookup cache warming stopped after {self.timeout_seconds:g}s")
+                    break
+                try:
+                    entry = json.loads(key)
+                    warmer = self.warmers.get(entry["type"])
+                    if warmer is None:
+                        continue
+                    warmer(entry["query"])
+                    warmed += 1
+                except Exception as e:
+                    logger.debug(f"Skipped warming {key}: {e}")
+        logger.info(f"Warmed the lookup cache with {warmed} of {len(keys)} hot lookups")
+        self.warmed = warmed
+        return warmed
+
+    def persist(self) -> int:
+        """Add the counts so far to the store.
+
+        Counts that cannot be stored are kept for the next time.
+
+        Returns:
+            Queries persisted
+        """
+        counts = self.tracker.take()
+        if not counts:
+            return 0
+        try:
+            self.store.add(counts, self.top_n * 2)
+        except Exception as e:
+            logger.warning(f"Failed to persist hot lookup keys: {e}")
+            self.tracker.restore(counts)
+            return 0
+        return len(counts)
+
+    async def start(self) -> None:
+        """Warm the cache, then persist the counts periodically until stopped."""
+        self._running = True
+        try:
+            await asyncio.to_thread(self.warm)
+            while self._running:
+                await asyncio.sleep(self.persist_interval_seconds)
+                await asyncio.to_thread(self.persist)
+        except asyncio.CancelledError:
+            self._running = False
+        finally:
+            if self.warmed is None:
+                self.warmed = 0
+
+    def stop(self) -> None:
+        """Stop persisting after the current iteration."""
+        self._running = False
+
+
+# Global cache warmer (set by build_cache_warmer; None unless LOOKUP_CACHE_WARM_ENABLED)
+_cache_warmer: Optional[CacheWarmer] = None
+
+
+def get_cache_warmer() -> Optional[CacheWarmer]:
+    """Get the cache warmer built at startup, if any."""
+    return _cache_warmer
+
+
+def build_cache_warmer(config, warmers: Dict[str, Callable[[Dict[str, Any]], None]]) -> Optional[CacheWarmer]:
+    """Create the cache warmer described by configuration, and count lookups for it.
+
+    Args:
+        config: Application configuration
+        warmers: Per lookup type, looks a query up through the cache
+
+    Returns:
+        CacheWarmer, or None if warming is disabled or has no store
+    """
+    global _cache_warmer
+    from src.services.lookup_cache_service import get_lookup_cache
+
+    if not config.lookup_cache_warm_enabled:
+        return None
+    cache = get_lookup_cache()
+    if cache is None:
+        logger.error("LOOKUP_CACHE_WARM_ENABLED without LOOKUP_CACHE_ENABLED; nothing to warm")
+        return None
+    if config.lookup_cache_redis_url:
+        store: HotKeyStore = RedisHotKeyStore.from_url(
+            config.lookup_cache_redis_url, f"{config.lookup_cache_key_prefix}:hot-keys"
+        )
+    elif config.lookup_cache_hot_keys_path:
+        store = FileHotKeyStore(config.lookup_cache_hot_keys_path)
+    else:
+        logger.error("LOOKUP_CACHE_WARM_ENABLED without LOOKUP_CACHE_REDIS_URL or LOOKUP_CACHE_HOT_KEYS_PATH")
+        return None
+    tracker = HotKeyTracker(warmers)
+    cache.hot_keys = tracker
+    _cache_warmer = CacheWarmer(
+        tracker,
+        store,
+        warmers,
+        config.lookup_cache_warm_top_n,
+        config.lookup_cache_warm_persist_interval_seconds,
+        config.lookup_cache_warm_timeout_seconds,
+    )
+    return _cache_warmer
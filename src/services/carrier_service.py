@@ -0,0 +1,274 @@
+"""Mobile carrier (MCC/MNC) attribution for IP lookups.
+
+A carrier is identified by its mobile country code (MCC) and mobile network
+code (MNC). The MCC/MNC pair for an address comes from a carrier IP range
+dataset, falling back to the GeoIP2 `traits.mobile_country_code` /
+`traits.mobile_network_code` fields; a directory of MCC/MNC pairs then
+supplies the operator name and country.
+"""
+
+import csv
+import logging
+from dataclasses import dataclass
+from typing import Any, Dict, Iterable, Optional, Tuple
+
+from src.services.enrichment_service import Enricher
+from src.services.ip_lookup_service import IpLookupResult, IpPrefixMap
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass(frozen=True)
+class Carrier:
+    """A mobile network operator."""
+    mcc: str
+    mnc: str
+    name: str
+    country_iso_code: Optional[str] = None
+
+
+def normalize_plmn(mcc: Any, mnc: Any) -> Tuple[str, str]:
+    """Validate an MCC/MNC pair.
+
+    MNCs keep their leading zeros: "01" and "001" are different networks
+    in countries that use three-digit MNCs.
+
+    Args:
+        mcc: Mobile country code (3 digits)
+        mnc: Mobile network code (2 or 3 digits)
+
+    Returns:
+        (mcc, mnc) as strings
+
+    Raises:
+        ValueError: If either code is malformed
+    """
+    mcc = str(mcc).strip()
+    mnc = str(mnc).strip()
+    if len(mcc) != 3 or not mcc.isdigit():
+        raise ValueError(f"Invalid MCC {mcc!r}: expected 3 digits")
+    if len(mnc) not in (2, 3) or not mnc.isdigit():
+        raise ValueError(f"Invalid MNC {mnc!r}: expected 2 or 3 digits")
+    return mcc, mnc
+
+
+class CarrierDirectory:
+    """MCC/MNC pair -> carrier name."""
+
+    def __init__(self, carriers: Iterable[Carrier] = ()):
+        """Initialize directory.
+
+        Args:
+            carriers: Known carriers (later duplicates replace earlier ones)
+        """
+        self._by_plmn: Dict[Tuple[str, str], Carrier] = {
+            (c.mcc, c.mnc): c for c in carriers
+        }
+
+    def __len__(self) -> int:
+        return len(self._by_plmn)
+
+    @classmethod
+    def from_csv(cls, path: str) -> "CarrierDirectory":
+        """Load carriers from a CSV with mcc, mnc, name and country columns.
+
+        Rows with a malformed MCC/MNC or no name are skipped.
+
+        Raises:
+            OSError: If the file cannot be read
+            ValueError: If the file has no mcc, mnc or name column
+        """
+        carriers = []
+        with open(path, newline="", encoding="utf-8") as f:
+            reader = csv.DictReader(f)
+            if not {"mcc", "mnc", "name"} <= set(reader.fieldnames or ()):
+                raise ValueError(f"{path}: expected mcc, mnc and name columns")
+            for row in reader:
+                name = (row.get("name") or "").strip()
+                try:
+                    mcc, mnc = normalize_plmn(row.get("mcc") or "", row.get("mnc") or "")
+                except ValueError:
+                    continue
+                if not name:
+                    continue
+                carriers.append(Carrier(
+                    mcc=mcc,
+                    mnc=mnc,
+                    name=name,
+                    country_iso_code=(row.get("country") or "").strip().upper() or None,
+                ))
+        return cls(carriers)
+
+    def get(self, mcc: Any, mnc: Any) -> Optional[Carrier]:
+        """Carrier for an MCC/MNC pair.
+
+        Raises:
+            ValueError: If the pair is malformed
+        """
+        return self._by_plmn.get(normalize_plmn(mcc, mnc))
+
+
+class CarrierRangeMap:
+    """IP networks -> MCC/MNC pair, most specific network first.
+
+    A carrier's more specific allocation inside another operator's block
+    wins over the enclosing block.
+    """
+
+    def __init__(self, networks: Iterable[Tuple[str, str, str]] = ()):
+        """Build the map.
+
+        Args:
+            networks: (CIDR, mcc, mnc) entries
+
+        Raises:
+            ValueError: If a network or MCC/MNC pair is malformed
+        """
+        self._map: IpPrefixMap[Tuple[str, str]] = IpPrefixMap(
+            (cidr, normalize_plmn(mcc, mnc)) for cidr, mcc, mnc in networks
+        )
+
+    @classmethod
+    def from_csv(cls, path: str) -> "CarrierRangeMap":
+        """Load networks from a CSV with network, mcc and mnc columns.
+
+        Raises:
+            OSError: If the file cannot be read
+            ValueError: If the columns are missing or a row is malformed
+        """
+        with open(path, newline="", encoding="utf-8") as f:
+            reader = csv.DictReader(f)
+            if not {"network", "mcc", "mnc"} <= set(reader.fieldnames or ()):
+                raise ValueError(f"{path}: expected network, mcc and mnc columns")
+            rows = [
+                (row["network"], row["mcc"], row["mnc"])
+                for row in reader
+                if (row.get("network") or "").strip()
+            ]
+        return cls(rows)
+
+    def lookup(self, address) -> Optional[Tuple[str, str]]:
+        """MCC/MNC pair of the most specific network containing an address."""
+        return self._map.lookup(address)
+
+    def __len__(self) -> int:
+        """Number of distinct networks in the map."""
+        return len(self._map)
+
+
+class CarrierEnricher(Enricher):
+    """Attributes mobile traffic to its network operator."""
+
+    name = "carrier"
+
+    def __init__(
+        self,
+        directory: Optional[CarrierDirectory] = None,
+        ranges: Optional[CarrierRangeMap] = None,
+    ):
+        """Initialize carrier enricher.
+
+        Args:
+            directory: MCC/MNC directory used to name carriers
+            ranges: Carrier IP allocations
+        """
+        self.directory = directory or CarrierDirectory()
+        self.ranges = ranges
+
+    def enrich(self, result: IpLookupResult) -> Optional[Dict[str, Any]]:
+        """Resolve the MCC/MNC pair for the address, then name the carrier."""
+        plmn = self._plmn(result)
+        if plmn is None:
+            return None
+        mcc, mnc = plmn
+        carrier = self.directory.get(mcc, mnc)
+        return {
+            "mcc": mcc,
+            "mnc": mnc,
+            "name": carrier.name if carrier else None,
+            "country_iso_code": carrier.country_iso_code if carrier else None,
+        }
+
+    def _plmn(self, result: IpLookupResult) -> Optional[Tuple[str, str]]:
+        """MCC/MNC pair from the range dataset, then GeoIP2 traits."""
+        if self.ranges is not None:
+            plmn = self.ranges.lookup(result.normalized.address)
+            if plmn is not None:
+                return plmn
+
+        if result.record is None:
+            return None
+        traits = result.record.raw.get("traits") or {}
+        mcc, mnc = traits.get("mobile_country_code"), traits.get("mobile_network_code")
+        if not mcc or not mnc:
+            return None
+        try:
+            return normalize_plmn(mcc, mnc)
+        except ValueError:
+            logger.debug(f"Ignoring malformed MCC/MNC {mcc}/{mnc} in GeoIP record")
+            return None
+
+
+def load_carrier_directory(config) -> Optional[CarrierDirectory]:
+    """Load the MCC/MNC directory from CARRIER_MCC_MNC_PATH.
+
+    Args:
+        config: Application configuration
+
+    Returns:
+        CarrierDirectory, or None if none is configured or it cannot be read
+    """
+    if not config.carrier_mcc_mnc_path:
+        return None
+    try:
+        directory = CarrierDirectory.from_csv(config.carrier_mcc_mnc_path)
+    except (OSError, ValueError) as e:
+        logger.warning(f"Carrier directory unavailable: {e}")
+        return None
+    logger.info(f"Loaded {len(directory)} MCC/MNC carriers")
+    return directory
+
+
+def build_carrier_enricher(
+    config, directory: Optional[CarrierDirectory] = None
+) -> CarrierEnricher:
+    """Create the carrier enricher from configured data sources.
+
+    Missing or invalid sources are logged and skipped; without a range
+    dataset, carriers come from GeoIP2 traits alone.
+
+    Args:
+        config: Application configuration
+        directory: MCC/MNC directory, if available
+
+    Returns:
+        CarrierEnricher
+    """
+    ranges = None
+    if config.carrier_ip_ranges_path:
+        try:
+            ranges = CarrierRangeMap.from_csv(config.carrier_ip_ranges_path)
+            logger.info(f"Loaded {len(ranges)} carrier networks")
+        except (OSError, ValueError) as e:
+            logger.warning(f"Carrier IP ranges unavailable: {e}")
+    return CarrierEnricher(directory=directory, ranges=ranges)
+
+
+# Global MCC/MNC directory (loaded on first use)
+_carrier_directory: Optional[CarrierDirectory] = None
+_carrier_directory_loaded = False
+
+
+def get_carrier_directory() -> Optional[CarrierDirectory]:
+    """Get the global MCC/MNC directory.
+
+    Returns:
+        CarrierDirectory, or None if no directory could be loaded
+    """
+    global _carrier_directory, _carrier_directory_loaded
+    if not _carrier_directory_loaded:
+        from src.config import get_config
+
+        _carrier_directory = load_carrier_directory(get_config())
+        _carrier_directory_loaded = True
+    return _carrier_directory
@@ -0,0 +1,299 @@
+"""Cell tower positioning from OpenCelliD data.
+
+A device reports the cells it is attached to or can hear, identified by
+MCC, MNC, LAC (TAC on LTE/NR) and cell ID. Each known cell's tower location
+becomes a ranged anchor: its range is the distance implied by the timing
+advance when the device reports one, and otherwise the tower's coverage
+radius from OpenCelliD. The position is the weighted centroid of the
+largest group of mutually close anchors (see src.spatial.positioning).
+
+Towers are loaded from OpenCelliD CSV extracts (plain or gzipped):
+
+    python -m src.services.cell_service 234.csv.gz
+"""
+
+import csv
+import gzip
+import logging
+from dataclasses import dataclass, replace
+from typing import Dict, Iterable, List, Optional, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import CellTower
+from src.spatial.positioning import (
+    PositionEstimate,
+    RangedAnchor,
+    consistent_cluster,
+    weighted_centroid,
+)
+
+logger = logging.getLogger(__name__)
+
+RADIOS = ("GSM", "UMTS", "LTE", "NR", "CDMA")
+
+# Coverage radius assumed when OpenCelliD reports none (meters)
+DEFAULT_RANGE_M = {"GSM": 5000.0, "UMTS": 2000.0, "LTE": 2000.0, "NR": 1000.0, "CDMA": 5000.0}
+
+# Distance per timing advance step (meters): GSM bit period, LTE 16 Ts
+TIMING_ADVANCE_STEP_M = {"GSM": 553.5, "LTE": 78.12}
+
+OPENCELLID_COLUMNS = ("radio", "mcc", "net", "area", "cell", "lon", "lat")
+
+# (radio, mcc, mnc, lac, cell ID)
+CellKey = Tuple[str, int, int, int, int]
+
+
+@dataclass(frozen=True)
+class CellObservation:
+    """Cell reported by a device."""
+    mcc: int
+    mnc: int
+    lac: int
+    cell_id: int
+    radio: Optional[str] = None
+    timing_advance: Optional[int] = None
+
+
+@dataclass
+class CellPosition:
+    """Position estimated from cell observations."""
+    estimate: PositionEstimate
+    towers_known: int  # Observed cells found in the database
+
+
+def normalize_radio(radio: Optional[str]) -> Optional[str]:
+    """Upper-case radio type ("lte" -> "LTE"); None stays None.
+
+    Raises:
+        ValueError: If the radio type is unknown
+    """
+    if radio is None:
+        return None
+    value = radio.strip().upper()
+    if value not in RADIOS:
+        raise ValueError(f"Unknown radio type {radio!r}; expected one of {', '.join(RADIOS)}")
+    return value
+
+
+def validate_observation(observation: CellObservation) -> CellObservation:
+    """Check an observation's identifiers and normalize its radio type.
+
+    Raises:
+        ValueError: If an identifier is out of range
+    """
+    if not 0 <= observation.mcc <= 999:
+        raise ValueError(f"Invalid MCC {observation.mcc}: expected 0-999")
+    if not 0 <= observation.mnc <= 999:
+        raise ValueError(f"Invalid MNC {observation.mnc}: expected 0-999")
+    if observation.lac < 0 or observation.cell_id < 0:
+        raise ValueError("LAC and cell ID must not be negative")
+    if observation.timing_advance is not None and observation.timing_advance < 0:
+        raise ValueError("Timing advance must not be negative")
+    return replace(observation, radio=normalize_radio(observation.radio))
+
+
+def observation_range(observation: CellObservation, tower: CellTower) -> float:
+    """Estimated device distance from a tower.
+
+    Timing advance, when the radio defines it, locates the device in a ring
+    around the tower; the middle of the reported step is used.
+    """
+    step = TIMING_ADVANCE_STEP_M.get(tower.radio)
+    if observation.timing_advance is not None and step is not None:
+        return (observation.timing_advance + 0.5) * step
+    return tower.range_m or DEFAULT_RANGE_M[tower.radio]
+
+
+class CellPositioningService:
+    """Stores cell tower locations and positions devices from observations."""
+
+    def __init__(
+        self,
+        session: Session,
+        min_towers: Optional[int] = None,
+        max_observations: Optional[int] = None,
+        max_spread_m: Optional[float] = None,
+    ):
+        """Initialize cell positioning service.
+
+        Args:
+            session: SQLAlchemy database session
+            min_towers: Known towers required for a fix (default CELL_MIN_TOWERS)
+            max_observations: Observations accepted per request (default CELL_MAX_OBSERVATIONS)
+            max_spread_m: Radius of the group of towers used; others are
+                ignored (default CELL_MAX_TOWER_SPREAD_M)
+        """
+        from src.config import get_config
+
+        config = get_config()
+        self.session = session
+        self.min_towers = min_towers if min_towers is not None else config.cell_min_towers
+        self.max_observations = (
+            max_observations if max_observations is not None else config.cell_max_observations
+        )
+        self.max_spread_m = max_spread_m if max_spread_m is not None else config.cell_max_tower_spread_m
+
+    def ingest(self, rows: Iterable[Dict], batch_size: int = 1000) -> int:
+        """Insert or update towers from OpenCelliD rows.
+
+        Rows with an unknown radio, malformed identifier or invalid
+        coordinate are skipped.
+
+        Args:
+            rows: dicts with OpenCelliD columns (radio, mcc, net, area, cell,
+                lon, lat, and optional range and samples)
+            batch_size: Rows written per transaction
+
+        Returns:
+            Number of towers stored
+
+        Raises:
+            ValueError: If the rows cannot be stored
+        """
+        stored = 0
+        batch: Dict[CellKey, Dict] = {}
+        for row in rows:
+            parsed = self._parse_row(row)
+            if parsed is None:
+                continue
+            key = (parsed["radio"], parsed["mcc"], parsed["mnc"], parsed["lac"], parsed["cell_id"])
+            batch[key] = parsed
+            if len(batch) >= batch_size:
+                stored += self._store(batch)
+                batch = {}
+        if batch:
+            stored += self._store(batch)
+        logger.info(f"Stored {stored} cell towers")
+        return stored
+
+    def import_csv(self, path: str, batch_size: int = 1000) -> int:
+        """Load towers from an OpenCelliD CSV extract (".gz" files are decompressed).
+
+        Raises:
+            OSError: If the file cannot be read
+            ValueError: If OpenCelliD columns are missing or the rows cannot be stored
+        """
+        opener = gzip.open if path.endswith(".gz") else open
+        with opener(path, "rt", newline="", encoding="utf-8") as f:
+            reader = csv.DictReader(f)
+            missing = set(OPENCELLID_COLUMNS) - set(reader.fieldnames or ())
+            if missing:
+                raise ValueError(f"{path}: missing OpenCelliD columns {', '.join(sorted(missing))}")
+            return self.ingest(reader, batch_size=batch_size)
+
+    def locate(self, observations: List[CellObservation]) -> CellPosition:
+        """Estimate a position from observed cells.
+
+        Args:
+            observations: Serving and neighbor cells; a cell without a radio
+                type matches a tower of any radio with the same identifiers
+
+        Returns:
+            CellPosition
+
+        Raises:
+            ValueError: If there are no or too many observations, or one is malformed
+            LookupError: If too few of the cells are in the database
+        """
+        if not observations:
+            raise ValueError("At least one cell observation is required")
+        if len(observations) > self.max_observations:
+            raise ValueError(
+                f"Too many observations: {len(observations)} (max {self.max_observations})"
+            )
+        observations = [validate_observation(o) for o in observations]
+
+        candidates = (
+            self.session.query(CellTower)
+            .filter(CellTower.cell_id.in_({o.cell_id for o in observations}))
+            .all()
+        )
+        anchors = []
+        known = 0
+        for observation in observations:
+            towers = [
+                t for t in candidates
+                if (t.mcc, t.mnc, t.lac, t.cell_id)
+                == (observation.mcc, observation.mnc, observation.lac, observation.cell_id)
+                and observation.radio in (None, t.radio)
+            ]
+            if not towers:
+                continue
+            known += 1
+            # Prefer the best-sampled tower if the radio type was not given
+            tower = max(towers, key=lambda t: t.samples or 0)
+            anchors.append(RangedAnchor(
+                latitude=tower.latitude,
+                longitude=tower.longitude,
+                range_m=observation_range(observation, tower),
+            ))
+
+        anchors = consistent_cluster(anchors, self.max_spread_m)
+        if len(anchors) < self.min_towers:
+            raise LookupError(
+                f"{len(anchors)} usable cells known; at least {self.min_towers} needed for a position"
+            )
+        return CellPosition(estimate=weighted_centroid(anchors), towers_known=known)
+
+    @staticmethod
+    def _parse_row(row: Dict) -> Optional[Dict]:
+        """Validated tower fields, or None to skip the row."""
+        try:
+            parsed = {
+                "radio": normalize_radio(row.get("radio") or ""),
+                "mcc": int(row["mcc"]),
+                "mnc": int(row["net"]),
+                "lac": int(row["area"]),
+                "cell_id": int(row["cell"]),
+                "latitude": float(row["lat"]),
+                "longitude": float(row["lon"]),
+                "range_m": float(row["range"]) if row.get("range") else None,
+                "samples": int(row["samples"]) if row.get("samples") else None,
+            }
+        except (KeyError, TypeError, ValueError):
+            return None
+        if not -90 <= parsed["latitude"] <= 90 or not -180 <= parsed["longitude"] <= 180:
+            return None
+        if not 0 <= parsed["mcc"] <= 999 or not 0 <= parsed["mnc"] <= 999:
+            return None
+        if parsed["range_m"] is not None and parsed["range_m"] <= 0:
+            parsed["range_m"] = None
+        return parsed
+
+    def _store(self, batch: Dict[CellKey, Dict]) -> int:
+        """Upsert one batch of parsed rows."""
+        existing = {
+            (tower.radio, tower.mcc, tower.mnc, tower.lac, tower.cell_id): tower
+            for tower in self.session.query(CellTower)
+            .filter(CellTower.cell_id.in_({key[4] for key in batch}))
+            .all()
+        }
+        try:
+            for key, row in batch.items():
+                tower = existing.get(key)
+                if tower is None:
+                    self.session.add(CellTower(**row))
+                else:
+                    for name, value in row.items():
+                        setattr(tower, name, value)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store cell towers: {str(e)}")
+        return len(batch)
+
+
+if __name__ == "__main__":
+    import sys
+
+    from src.database import get_db_manager
+
+    logging.basicConfig(level=logging.INFO)
+    if len(sys.argv) != 2:
+        sys.exit("usage: python -m src.services.cell_service OPENCELLID.csv[.gz]")
+    manager = get_db_manager()
+    manager.create_all()
+    with manager.session_scope() as session:
+        count = CellPositioningService(session).import_csv(sys.argv[1])
+    print(f"Imported {count} cell towers")
@@ -0,0 +1,209 @@
+"""Concurrency limiting and load shedding of HTTP requests.
+
+A process serves at most CONCURRENCY_MAX_IN_FLIGHT requests at a time,
+and individual endpoints (method and route template, e.g.
+"GET /api/v1/poi/datasets/{dataset_id}/nearest") at most their budget in
+CONCURRENCY_ENDPOINT_LIMITS. Requests beyond either are shed at once with
+503 (E016) and Retry-After instead of waiting: a burst of slow PostGIS
+queries then costs a few fast refusals rather than a growing backlog of
+coroutines, threads and database connections that ends with the process
+running out of memory.
+
+Near saturation, requests are admitted by priority class: the quota plan
+of their API key (see src.services.quota_service), or the default class
+for other callers. Each class may fill its share of the process-wide
+slots (CONCURRENCY_PRIORITY_SHARES, CONCURRENCY_DEFAULT_SHARE), so as
+the process fills up free-tier traffic is shed first and the slots left
+go to premium plans. Callers are only classified once the in-flight
+requests reach the smallest share, so an idle process pays nothing for it.
+
+Slots are counted per process, which is what they protect; with N workers
+a replica serves up to N times the limits.
+"""
+
+import json
+import logging
+import threading
+from dataclasses import dataclass
+from typing import Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+# Budget name of the process-wide limit in decisions and metrics
+GLOBAL_BUDGET = "global"
+
+# Shares of the process-wide slots each quota plan may fill
+DEFAULT_PRIORITY_SHARES: Dict[str, float] = {"enterprise": 1.0, "standard": 0.9, "free": 0.6}
+
+
+@dataclass
+class ConcurrencyDecision:
+    """Outcome of taking a request slot."""
+
+    allowed: bool
+    budget: str               # GLOBAL_BUDGET, "priority:<class>" or the endpoint whose budget was checked last
+    limit: int                # Requests the budget allows at a time
+    in_flight: int            # Requests running under the budget (this one included if allowed)
+
+
+def parse_endpoint_limits(raw: str) -> Dict[str, int]:
+    """Endpoint budgets of a CONCURRENCY_ENDPOINT_LIMITS document.
+
+    Args:
+        raw: JSON object mapping endpoint (method and route template) -> requests at a time
+
+    Raises:
+        ValueError: If the document or a budget is invalid
+    """
+    if not raw.strip():
+        return {}
+    try:
+        entries = json.loads(raw)
+    except json.JSONDecodeError as e:
+        raise ValueError(f"Invalid CONCURRENCY_ENDPOINT_LIMITS: {str(e)}")
+    if not isinstance(entries, dict):
+        raise ValueError("Invalid CONCURRENCY_ENDPOINT_LIMITS: expected a JSON object")
+    for endpoint, limit in entries.items():
+        if isinstance(limit, bool) or not isinstance(limit, int) or limit < 1:
+            raise ValueError(f"Invalid concurrency budget of {endpoint}: expected a positive integer")
+    return dict(entries)
+
+
+def parse_priority_shares(raw: str) -> Dict[str, float]:
+    """The built-in priority shares, changed or extended by a CONCURRENCY_PRIORITY_SHARES document.
+
+    Args:
+        raw: JSON object mapping quota plan -> share of the slots (0 to 1)
+
+    Raises:
+        ValueError: If the document or a share is invalid
+    """
+    shares = dict(DEFAULT_PRIORITY_SHARES)
+    if not raw.strip():
+        return shares
+    try:
+        entries = json.loads(raw)
+    except json.JSONDecodeError as e:
+        raise ValueError(f"Invalid CONCURRENCY_PRIORITY_SHARES: {str(e)}")
+    if not isinstance(entries, dict):
+        raise ValueError("Invalid CONCURRENCY_PRIORITY_SHARES: expected a JSON object")
+    for plan, share in entries.items():
+        if isinstance(share, bool) or not isinstance(share, (int, float)) or not 0 < share <= 1:
+            raise ValueError(f"Invalid priority share of {plan}: expected a number above 0 and at most 1")
+        shares[plan] = float(share)
+    return shares
+
+
+class ConcurrencyLimiter:
+    """Counts the requests in flight against the global and endpoint budgets."""
+
+    def __init__(
+        self,
+        max_in_flight: int = 256,
+        endpoints: Optional[Dict[str, int]] = None,
+        priority_shares: Optional[Dict[str, float]] = None,
+        default_share: float = 0.8,
+    ):
+        """Initialize limiter.
+
+        Args:
+            max_in_flight: Requests served at a time by the process
+            endpoints: Endpoint -> requests served at a time
+            priority_shares: Priority class -> share of the slots it may fill
+                (default: DEFAULT_PRIORITY_SHARES)
+            default_share: Share of the slots of requests in no class
+
+        Raises:
+            ValueError: If a limit is not positive, or a share not above 0 and at most 1
+        """
+        if max_in_flight < 1 or any(limit < 1 for limit in (endpoints or {}).values()):
+            raise ValueError("Concurrency limits must be positive")
+        shares = DEFAULT_PRIORITY_SHARES if priority_shares is None else priority_shares
+        if not all(0 < share <= 1 for share in [default_share, *shares.values()]):
+            raise ValueError("Priority shares must be above 0 and at most 1")
+        self.max_in_flight = max_in_flight
+        self.endpoints = dict(endpoints or {})
+        # Slots each class may fill (at least one, so that no class is locked out entirely)
+        self.priority_limits = {name: max(1, int(max_in_flight * share)) for name, share in shares.items()}
+        self.default_limit = max(1, int(max_in_flight * default_share))
+        self._contended_at = min([self.default_limit, *self.priority_limits.values()])
+        self._in_flight = 0
+        self._by_endpoint: Dict[str, int] = {}
+        self._lock = threading.Lock()
+
+    def needs_endpoint(self) -> bool:
+        """Whether acquire needs the endpoint of requests (any endpoint budgets)."""
+        return bool(self.endpoints)
+
+    def contended(self) -> bool:
+        """Whether requests are admitted by priority: enough in flight to fill the smallest share."""
+        return self._in_flight >= self._contended_at
+
+    def acquire(self, endpoint: str = "", priority: Optional[str] = None) -> ConcurrencyDecision:
+        """Take a slot for a request, unless a budget is used up.
+
+        A request allowed here must be passed to release once it is served.
+
+        Args:
+            endpoint: Method and route template of the request
+            priority: Priority class of the request (None or unknown: the default share)
+        """
+        limit = self.endpoints.get(endpoint)
+        class_limit = self.priority_limits.get(priority, self.default_limit)
+        with self._lock:
+            # The endpoint first, so that refusals name the narrower budget when both are used up
+            if limit is not None and self._by_endpoint.get(endpoint, 0) >= limit:
+                return ConcurrencyDecision(False, endpoint, limit, self._by_endpoint[endpoint])
+            if self._in_flight >= self.max_in_flight:
+                return ConcurrencyDecision(False, GLOBAL_BUDGET, self.max_in_flight, self._in_flight)
+            if self._in_flight >= class_limit:
+                budget = f"priority:{priority if priority in self.priority_limits else 'default'}"
+                return ConcurrencyDecision(False, budget, class_limit, self._in_flight)
+            self._in_flight += 1
+            if limit is None:
+                return ConcurrencyDecision(True, GLOBAL_BUDGET, self.max_in_flight, self._in_flight)
+            self._by_endpoint[endpoint] = self._by_endpoint.get(endpoint, 0) + 1
+            return ConcurrencyDecision(True, endpoint, limit, self._by_endpoint[endpoint])
+
+    def release(self, endpoint: str = "") -> None:
+        """Give back the slot of a served request."""
+        with self._lock:
+            self._in_flight = max(0, self._in_flight - 1)
+            if endpoint in self._by_endpoint:
+                self._by_endpoint[endpoint] -= 1
+                if self._by_endpoint[endpoint] <= 0:
+                    del self._by_endpoint[endpoint]
+
+    def in_flight(self) -> int:
+        """Requests being served."""
+        return self._in_flight
+
+    def in_flight_by_endpoint(self) -> Dict[str, int]:
+        """Requests being served by endpoint, for the endpoints with budgets."""
+        with self._lock:
+            return dict(self._by_endpoint)
+
+
+# Global limiter (limits loaded lazily from configuration)
+_concurrency_limiter: Optional[ConcurrencyLimiter] = None
+
+
+def get_concurrency_limiter() -> ConcurrencyLimiter:
+    """Get the global concurrency limiter.
+
+    Raises:
+        ValueError: If CONCURRENCY_ENDPOINT_LIMITS or CONCURRENCY_PRIORITY_SHARES
+            is invalid, or a limit is not positive
+    """
+    global _concurrency_limiter
+    if _concurrency_limiter is None:
+        from src.config import get_config
+
+        config = get_config()
+        _concurrency_limiter = ConcurrencyLimiter(
+            config.concurrency_max_in_flight,
+            parse_endpoint_limits(config.concurrency_endpoint_limits),
+            parse_priority_shares(config.concurrency_priority_shares),
+            config.concurrency_default_share,
+        )
+    return _concurrency_limiter
@@ -0,0 +1,106 @@
+"""Per-country reference data from the GeoNames country info export.
+
+`countryInfo.txt` (https://download.geonames.org/export/dump/) is a
+tab-separated file with '#' comment lines and one row per country: ISO
+codes, name, continent, currency, languages and more. It backs the
+locale and currency enrichments and postal code validation.
+"""
+
+import csv
+import logging
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+# countryInfo.txt columns
+_ISO = 0
+_ISO3 = 1
+_NAME = 4
+_CONTINENT = 8
+_CURRENCY_CODE = 10
+_CURRENCY_NAME = 11
+_POSTAL_FORMAT = 13
+_POSTAL_REGEX = 14
+_LANGUAGES = 15
+
+
+@dataclass(frozen=True)
+class CountryInfo:
+    """Reference data for one country."""
+    iso_code: str  # ISO 3166-1 alpha-2
+    iso3_code: Optional[str] = None
+    name: Optional[str] = None
+    continent: Optional[str] = None  # Two-letter continent code (EU, NA, ...)
+    currency_code: Optional[str] = None  # ISO 4217
+    currency_name: Optional[str] = None
+    postal_code_format: Optional[str] = None  # e.g. "#####-###" (# digit, @ letter)
+    postal_code_regex: Optional[str] = None
+    languages: List[str] = field(default_factory=list)  # BCP 47 tags, most spoken first
+
+
+def load_country_info(path: str) -> Dict[str, CountryInfo]:
+    """Load a GeoNames countryInfo.txt file.
+
+    Args:
+        path: File path
+
+    Returns:
+        dict of ISO 3166-1 alpha-2 code -> CountryInfo
+
+    Raises:
+        OSError: If the file cannot be read
+        ValueError: If the file has no country rows
+    """
+    def column(row: List[str], index: int) -> Optional[str]:
+        value = row[index].strip() if index < len(row) else ""
+        return value or None
+
+    countries: Dict[str, CountryInfo] = {}
+    with open(path, newline="", encoding="utf-8") as f:
+        for row in csv.reader(f, delimiter="\t"):
+            if not row or row[0].startswith("#") or len(row[0].strip()) != 2:
+                continue
+            iso_code = row[_ISO].strip().upper()
+            countries[iso_code] = CountryInfo(
+                iso_code=iso_code,
+                iso3_code=column(row, _ISO3),
+                name=column(row, _NAME),
+                continent=column(row, _CONTINENT),
+                currency_code=(column(row, _CURRENCY_CODE) or "").upper() or None,
+                currency_name=column(row, _CURRENCY_NAME),
+                postal_code_format=column(row, _POSTAL_FORMAT),
+                postal_code_regex=column(row, _POSTAL_REGEX),
+                languages=[
+                    tag.strip() for tag in (column(row, _LANGUAGES) or "").split(",") if tag.strip()
+                ],
+            )
+    if not countries:
+        raise ValueError(f"{path}: no country rows found")
+    return countries
+
+
+# Global country info (loaded lazily from COUNTRY_INFO_PATH)
+_country_info: Optional[Dict[str, CountryInfo]] = None
+
+
+def get_country_info(config=None) -> Dict[str, CountryInfo]:
+    """Get the global country info table.
+
+    Returns:
+        dict of ISO code -> CountryInfo (empty if the dataset is unavailable)
+    """
+    global _country_info
+    if _country_info is None:
+        if config is None:
+            from src.config import get_config
+
+            config = get_config()
+        path = config.country_info_path
+        try:
+            _country_info = load_country_info(path) if path else {}
+            logger.info(f"Loaded country info for {len(_country_info)} countries")
+        except (OSError, ValueError) as e:
+            logger.warning(f"Country info unavailable: {e}")
+            _country_info = {}
+    return _country_info
@@ -0,0 +1,268 @@
+"""CSV imports of geofences and POI datasets.
+
+An uploaded CSV is parsed row by row and every row is validated on its
+own: valid rows are imported, invalid ones are reported with the reason
+instead of failing the whole file. Geofence rows have a `name`, a
+`geometry` cell holding a WGS84 Polygon or MultiPolygon as GeoJSON, WKT
+or hex WKB, and optional `properties` (a JSON object); POI rows have `id`, `latitude`, `longitude`
+and optional `name` and `properties`, and the valid ones form one dataset.
+
+Each import is recorded as a batch job of the calling tenant whose NDJSON
+results, one line per row in input order, are downloaded like those of
+lookup jobs:
+
+    {"index": 0, "geofence_id": "..."}
+    {"index": 1, "error": {"error_code": "E002", "error_message": "..."}}
+"""
+
+import csv
+import json
+import logging
+import os
+from dataclasses import dataclass, field
+from datetime import datetime
+from typing import Any, Callable, Dict, Iterator, List, Optional, Sequence, TextIO
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import BatchJob
+from src.services.batch_job_service import BatchJobService, JobStatus
+from src.services.batch_lookup_service import BatchOutcome, error_outcome
+from src.services.geofence_service import GeofenceService
+from src.services.poi_service import PoiService
+from src.spatial import wkt
+
+logger = logging.getLogger(__name__)
+
+GEOFENCE_COLUMNS = ("name", "geometry", "properties")
+POI_COLUMNS = ("id", "latitude", "longitude", "name", "properties")
+
+# Row errors returned with the import response (all are in the results file)
+ERROR_PREVIEW = 20
+
+Row = Dict[str, Optional[str]]
+
+
+def read_csv(stream: TextIO, columns: Sequence[str], required: Sequence[str]) -> Iterator[Row]:
+    """Rows of a CSV stream as dicts of the given columns (absent columns and empty cells are None).
+
+    Args:
+        stream: Text stream positioned at the header row
+        columns: Columns read, matched case-insensitively
+        required: Columns the header must have
+
+    Raises:
+        ValueError: If the header lacks a required column (at once), or
+            while iterating, if the CSV is malformed
+    """
+    reader = csv.DictReader(stream)
+    try:
+        names = {(name or "").strip().lower(): name for name in reader.fieldnames or ()}
+    except csv.Error as e:
+        raise ValueError(f"Malformed CSV header: {str(e)}")
+    missing = [column for column in required if column not in names]
+    if missing:
+        wanted = " and ".join([", ".join(required[:-1]), required[-1]] if len(required) > 1 else required)
+        raise ValueError(f"Input needs a header row with {wanted} columns (missing {', '.join(missing)})")
+
+    def rows() -> Iterator[Row]:
+        try:
+            for row in reader:
+                yield {
+                    key: ((row.get(names[key]) or "").strip() or None) if key in names else None
+                    for key in columns
+                }
+        except csv.Error as e:
+            raise ValueError(f"Malformed CSV at line {reader.line_num}: {str(e)}")
+
+    return rows()
+
+
+def _json_object(value: Optional[str], column: str) -> Optional[Dict[str, Any]]:
+    if value is None:
+        return None
+    try:
+        parsed = json.loads(value)
+    except ValueError:
+        raise ValueError(f"{column} is not valid JSON")
+    if not isinstance(parsed, dict):
+        raise ValueError(f"{column} must be a JSON object")
+    return parsed
+
+
+def _geometry(value: Optional[str]) -> Optional[Dict[str, Any]]:
+    """GeoJSON geometry of a geometry cell: a GeoJSON object, WKT or hex WKB in WGS84."""
+    if value is None or value.lstrip().startswith("{"):
+        return _json_object(value, "geometry")
+    geometry, srid = wkt.parse_geometry_text(value)
+    if srid and srid != 4326:
+        raise ValueError(f"geometry must be WGS84, not SRID {srid}")
+    return geometry
+
+
+def geofence_row(row: Row) -> Dict[str, Any]:
+    """Arguments of GeofenceService.create_geofence for one CSV row.
+
+    Raises:
+        ValueError: For a missing name or geometry, or malformed JSON, WKT or WKB
+    """
+    if row["name"] is None:
+        raise ValueError("name is required")
+    geometry = _geometry(row["geometry"])
+    if geometry is None:
+        raise ValueError("geometry is required")
+    return {"name": row["name"], "geometry": geometry, "properties": _json_object(row["properties"], "properties")}
+
+
+def poi_row(row: Row) -> Dict[str, Any]:
+    """POI of PoiService.create_dataset for one CSV row.
+
+    Raises:
+        ValueError: For a missing id or an invalid coordinate or properties
+    """
+    if row["id"] is None:
+        raise ValueError("id is required")
+    poi: Dict[str, Any] = {"id": row["id"], "name": row["name"]}
+    for column in ("latitude", "longitude"):
+        try:
+            poi[column] = float(row[column])
+        except (TypeError, ValueError):
+            raise ValueError(f"{column} is required and must be a number")
+    if not -90 <= poi["latitude"] <= 90:
+        raise ValueError(f"latitude out of range: {poi['latitude']}")
+    if not -180 <= poi["longitude"] <= 180:
+        raise ValueError(f"longitude out of range: {poi['longitude']}")
+    poi["properties"] = _json_object(row["properties"], "properties")
+    return poi
+
+
+@dataclass
+class CsvImportResult:
+    """Recorded import job, with the first row errors."""
+    job: BatchJob
+    errors: List[BatchOutcome] = field(default_factory=list)
+    dataset_id: Optional[str] = None
+
+
+class CsvImportService:
+    """Imports uploaded CSVs, recording each import as a batch job."""
+
+    def __init__(self, session: Session, result_dir: str):
+        """Initialize import service.
+
+        Args:
+            session: SQLAlchemy database session
+            result_dir: Directory of the NDJSON results (BATCH_JOB_RESULT_DIR)
+        """
+        self.session = session
+        self.result_dir = result_dir
+
+    def import_geofences(self, tenant_id: str, filename: Optional[str], stream: TextIO) -> CsvImportResult:
+        """Create a geofence per valid row.
+
+        Raises:
+            ValueError: If the header lacks the name or geometry column
+        """
+        rows = read_csv(stream, GEOFENCE_COLUMNS, ("name", "geometry"))
+        service = GeofenceService(self.session)
+
+        def create(row: Row) -> Dict[str, Any]:
+            return {"geofence_id": service.create_geofence(**geofence_row(row)).geofence_id}
+
+        return self._run(tenant_id, filename, rows, create)
+
+    def import_pois(
+        self, tenant_id: str, filename: Optional[str], name: str, stream: TextIO
+    ) -> CsvImportResult:
+        """Create a POI dataset of the valid rows.
+
+        Rows repeating an earlier row's id are invalid.
+
+        Raises:
+            ValueError: If the header lacks the id, latitude or longitude
+                column, no row is valid, or the dataset cannot be stored
+        """
+        rows = read_csv(stream, POI_COLUMNS, ("id", "latitude", "longitude"))
+        pois: Dict[str, Dict[str, Any]] = {}
+
+        def collect(row: Row) -> Dict[str, Any]:
+            poi = poi_row(row)
+            if poi["id"] in pois:
+                raise ValueError(f"Duplicate POI id: {poi['id']}")
+            pois[poi["id"]] = poi
+            return {"poi_id": poi["id"]}
+
+        def store() -> str:
+            if not pois:
+                raise ValueError("No valid POI rows to import")
+            return PoiService(self.session).create_dataset(tenant_id, name, list(pois.values())).dataset_id
+
+        return self._run(tenant_id, filename, rows, collect, store)
+
+    def _run(
+        self,
+        tenant_id: str,
+        filename: Optional[str],
+        rows: Iterator[Row],
+        handler: Callable[[Row], Dict[str, Any]],
+        finish: Optional[Callable[[], str]] = None,
+    ) -> CsvImportResult:
+        """Apply a handler to every row, writing the results file and recording the job.
+
+        Raises:
+            ValueError: If the CSV turns out malformed or finish fails (the
+                job is then recorded as failed)
+        """
+        jobs = BatchJobService(self.session)
+        job = jobs.create_upload(tenant_id, filename)
+        jobs.update(job.job_id, status=JobStatus.RUNNING, started_at=datetime.utcnow())
+        os.makedirs(self.result_dir, exist_ok=True)
+        result_path = os.path.join(self.result_dir, f"{job.job_id}.ndjson")
+        partial_path = result_path + ".part"
+        result = CsvImportResult(job)
+        processed = failed = 0
+        try:
+            with open(partial_path, "w", encoding="utf-8") as out:
+                for index, row in enumerate(rows):
+                    try:
+                        record = {"index": index, **handler(row)}
+                    except Exception as e:
+                        outcome = error_outcome(index, e)
+                        record = {
+                            "index": index,
+                            "error": {"error_code": outcome.error_code, "error_message": outcome.error_message},
+                        }
+                        failed += 1
+                        if len(result.errors) < ERROR_PREVIEW:
+                            result.errors.append(outcome)
+                    out.write(json.dumps(record) + "\n")
+                    processed += 1
+            dataset_id = finish() if finish is not None else None
+            os.replace(partial_path, result_path)
+        except Exception as e:
+            jobs.update(
+                job.job_id,
+                status=JobStatus.FAILED,
+                total_rows=processed,
+                processed_rows=processed,
+                failed_rows=failed,
+                error=str(e)[:1000] if isinstance(e, ValueError) else "Internal error",
+                finished_at=datetime.utcnow(),
+            )
+            raise
+        finally:
+            if os.path.exists(partial_path):
+                os.remove(partial_path)
+
+        result.dataset_id = dataset_id
+        result.job = jobs.update(
+            job.job_id,
+            status=JobStatus.SUCCEEDED,
+            total_rows=processed,
+            processed_rows=processed,
+            failed_rows=failed,
+            result_path=result_path,
+            finished_at=datetime.utcnow(),
+        )
+        logger.info(f"Tenant {tenant_id} imported {job.source}: {processed} rows, {failed} failed")
+        return result
@@ -0,0 +1,56 @@
+"""Local currency enrichment for IP lookups.
+
+Maps the resolved country to its ISO 4217 currency using the GeoNames
+country info table (see src.services.country_info_service).
+"""
+
+from typing import Any, Dict, Optional
+
+from src.services.country_info_service import CountryInfo, get_country_info
+from src.services.enrichment_service import Enricher
+from src.services.ip_lookup_service import IpLookupResult
+
+
+class CurrencyEnricher(Enricher):
+    """Adds the country's ISO 4217 currency to lookups."""
+
+    name = "currency"
+
+    def __init__(self, countries: Dict[str, CountryInfo]):
+        """Initialize currency enricher.
+
+        Args:
+            countries: ISO 3166-1 alpha-2 code -> CountryInfo
+        """
+        self.countries = countries
+
+    def currency_for(self, country_code: Optional[str]) -> Optional[Dict[str, Any]]:
+        """Currency block for a country, or None if it is unknown or has no currency."""
+        if not country_code:
+            return None
+        info = self.countries.get(country_code.strip().upper())
+        if info is None or not info.currency_code:
+            return None
+        return {"code": info.currency_code, "name": info.currency_name}
+
+    def enrich(self, result: IpLookupResult) -> Optional[Dict[str, Any]]:
+        """Look up the currency of the record's country."""
+        record = result.record
+        if record is None:
+            return None
+        return self.currency_for(record.country_iso_code)
+
+
+def build_currency_enricher(config) -> Optional[CurrencyEnricher]:
+    """Create the currency enricher from COUNTRY_INFO_PATH.
+
+    Args:
+        config: Application configuration
+
+    Returns:
+        CurrencyEnricher, or None if no dataset is configured or it cannot be read
+    """
+    countries = get_country_info(config)
+    if not countries:
+        return None
+    return CurrencyEnricher(countries)
@@ -0,0 +1,199 @@
+"""Datacenter / cloud IP classification for IP lookups.
+
+An address is classified as datacenter traffic from, in order:
+- Published cloud ranges (DATACENTER_RANGE_FILES): AWS `ip-ranges.json`,
+  Google Cloud `cloud.json`, Azure Service Tags JSON, or text files with
+  one `CIDR [provider]` per line (the provider defaults to the file name)
+- Well-known hosting ASNs (AWS, Google, Microsoft, OVH, Hetzner, ...)
+- A hosting connection type of the ASN (GeoIP2-Connection-Type or AS
+  organization-name heuristics)
+
+The block reports `is_datacenter` with the matching provider and source,
+so WAF rules can key off a single flag.
+"""
+
+import json
+import logging
+import os
+from typing import Any, Dict, Iterable, List, Optional
+
+from src.services.asn_service import AsnEnricher, ConnectionType
+from src.services.enrichment_service import Enricher
+from src.services.ip_lookup_service import IpLookupResult, IpRangeSet
+
+logger = logging.getLogger(__name__)
+
+# Well-known hosting/cloud ASNs and the provider each belongs to
+HOSTING_ASN_PROVIDERS = {
+    16509: "aws", 14618: "aws",
+    15169: "gcp", 396982: "gcp",
+    8075: "azure",
+    14061: "digitalocean",
+    16276: "ovh",
+    24940: "hetzner",
+    63949: "linode",
+    20473: "vultr",
+    13335: "cloudflare",
+    31898: "oracle",
+    45102: "alibaba",
+    132203: "tencent",
+    60781: "leaseweb", 28753: "leaseweb",
+}
+
+
+class DatacenterSource:
+    """How an address was classified."""
+    CLOUD_RANGES = "cloud_ranges"
+    ASN = "asn"
+    CONNECTION_TYPE = "connection_type"
+
+
+def _provider_from_path(path: str) -> str:
+    return os.path.splitext(os.path.basename(path))[0].lower()
+
+
+def parse_cloud_ranges(text: str, default_provider: str) -> Dict[str, List[str]]:
+    """Parse a published cloud range file.
+
+    Args:
+        text: File contents (AWS, Google Cloud or Azure JSON, or text lines)
+        default_provider: Provider of text entries without one
+
+    Returns:
+        dict of provider -> CIDRs
+
+    Raises:
+        ValueError: If the JSON has none of the known layouts
+    """
+    stripped = text.lstrip()
+    if not stripped.startswith(("{", "[")):
+        ranges: Dict[str, List[str]] = {}
+        for line in text.splitlines():
+            parts = line.split("#", 1)[0].replace(",", " ").split()
+            if parts:
+                provider = parts[1].lower() if len(parts) > 1 else default_provider
+                ranges.setdefault(provider, []).append(parts[0])
+        return ranges
+
+    data = json.loads(text)
+    if isinstance(data, dict) and "prefixes" in data:
+        prefixes = list(data["prefixes"]) + list(data.get("ipv6_prefixes", []))
+        if any("ip_prefix" in p or "ipv6_prefix" in p for p in prefixes):
+            # AWS ip-ranges.json
+            return {"aws": [p.get("ip_prefix") or p["ipv6_prefix"] for p in prefixes]}
+        # Google Cloud cloud.json
+        return {"gcp": [p.get("ipv4Prefix") or p["ipv6Prefix"] for p in prefixes]}
+    if isinstance(data, dict) and "values" in data:
+        # Azure Service Tags (the AzureCloud tag spans every service)
+        networks = [
+            prefix
+            for value in data["values"]
+            for prefix in (value.get("properties") or {}).get("addressPrefixes", [])
+        ]
+        return {"azure": networks}
+    raise ValueError("Unrecognized cloud range file: expected AWS, Google Cloud or Azure JSON")
+
+
+def load_cloud_ranges(paths: Iterable[str]) -> Dict[str, IpRangeSet]:
+    """Load cloud range files, merging ranges of the same provider.
+
+    Unreadable or malformed files are logged and skipped.
+
+    Args:
+        paths: File paths
+
+    Returns:
+        dict of provider -> IpRangeSet
+    """
+    networks: Dict[str, List[str]] = {}
+    for path in paths:
+        try:
+            with open(path) as f:
+                parsed = parse_cloud_ranges(f.read(), _provider_from_path(path))
+            for provider, cidrs in parsed.items():
+                networks.setdefault(provider, []).extend(cidrs)
+            logger.info(
+                f"Loaded {sum(len(c) for c in parsed.values())} datacenter ranges from {path}"
+            )
+        except (OSError, ValueError, KeyError) as e:
+            logger.warning(f"Datacenter range file {path} unavailable: {e}")
+
+    ranges = {}
+    for provider, cidrs in networks.items():
+        try:
+            ranges[provider] = IpRangeSet(cidrs)
+        except ValueError as e:
+            logger.warning(f"Datacenter ranges of {provider} skipped: {e}")
+    return ranges
+
+
+class DatacenterEnricher(Enricher):
+    """Flags addresses of cloud and hosting providers."""
+
+    name = "datacenter"
+
+    def __init__(
+        self,
+        ranges: Optional[Dict[str, IpRangeSet]] = None,
+        asn_enricher: Optional[AsnEnricher] = None,
+        hosting_asns: Optional[Dict[int, str]] = None,
+    ):
+        """Initialize datacenter enricher.
+
+        Args:
+            ranges: Provider -> published networks
+            asn_enricher: ASN enricher for ASN and connection type heuristics
+            hosting_asns: ASN -> provider (default HOSTING_ASN_PROVIDERS)
+        """
+        self.ranges = ranges or {}
+        self.asn_enricher = asn_enricher
+        self.hosting_asns = hosting_asns if hosting_asns is not None else HOSTING_ASN_PROVIDERS
+
+    @property
+    def has_sources(self) -> bool:
+        """True if cloud ranges or an ASN database are loaded."""
+        return bool(self.ranges) or self.asn_enricher is not None
+
+    def enrich(self, result: IpLookupResult) -> Optional[Dict[str, Any]]:
+        """Classify the looked-up address.
+
+        Returns:
+            dict with is_datacenter, provider and source, or None if no
+            source is loaded
+        """
+        if not self.has_sources:
+            return None
+
+        addresses = {result.normalized.original, result.normalized.address}
+        for provider, ranges in self.ranges.items():
+            if any(a in ranges for a in addresses):
+                return _block(True, provider, DatacenterSource.CLOUD_RANGES)
+
+        asn = self.asn_enricher.enrich(result) if self.asn_enricher is not None else None
+        if asn:
+            provider = self.hosting_asns.get(asn.get("number"))
+            if provider is not None:
+                return _block(True, provider, DatacenterSource.ASN)
+            if asn.get("connection_type") == ConnectionType.HOSTING:
+                return _block(True, asn.get("organization"), DatacenterSource.CONNECTION_TYPE)
+        return _block(False, None, None)
+
+
+def _block(is_datacenter: bool, provider: Optional[str], source: Optional[str]) -> Dict[str, Any]:
+    return {"is_datacenter": is_datacenter, "provider": provider, "source": source}
+
+
+def build_datacenter_enricher(
+    config, asn_enricher: Optional[AsnEnricher] = None
+) -> DatacenterEnricher:
+    """Create the datacenter enricher from configured range files.
+
+    Args:
+        config: Application configuration
+        asn_enricher: ASN enricher for ASN heuristics, if available
+
+    Returns:
+        DatacenterEnricher
+    """
+    paths = [p.strip() for p in config.datacenter_range_files.split(",") if p.strip()]
+    return DatacenterEnricher(load_cloud_ranges(paths), asn_enricher)
@@ -0,0 +1,132 @@
+"""Degraded mode: approximate IP lookup answers instead of errors.
+
+With DEGRADED_MODE_ENABLED, the IP lookup routes keep answering when they
+would otherwise fail or add to an overload, with `degraded: true` and a
+`degraded_reason` in the result:
+
+- dependency_unavailable: the database, Redis or the GeoIP dataset failed
+  during a lookup. The caller gets the last answer given to a plain
+  lookup of the address (at most DEGRADED_STALE_MAX_AGE_SECONDS old), else the dataset record
+  cut down to its country, without the blocks that need the failed
+  dependencies.
+- overload: the process is serving at least DEGRADED_OVERLOAD_RATIO of
+  CONCURRENCY_MAX_IN_FLIGHT requests (see src.services.concurrency_service).
+  Plain lookups (no user, device, session or as_of) are answered from the
+  recent answers without touching the database, while there is one.
+
+Answers to plain lookups are kept per process, by tenant, enabled
+enrichments and address, for DEGRADED_CACHE_MAX_ENTRIES addresses.
+Historical (as_of) lookups are never degraded.
+"""
+
+import logging
+import threading
+import time
+from collections import OrderedDict
+from typing import Any, Callable, Hashable, Optional, Tuple
+
+from sqlalchemy.exc import SQLAlchemyError
+
+logger = logging.getLogger(__name__)
+
+
+class DegradedReason:
+    """Why an answer is degraded."""
+    OVERLOAD = "overload"
+    DEPENDENCY_UNAVAILABLE = "dependency_unavailable"
+
+
+# Failures of what a lookup depends on (stores report theirs as RuntimeError),
+# as opposed to the caller's mistakes (ValueError, LookupError)
+DEPENDENCY_ERRORS = (RuntimeError, SQLAlchemyError, OSError)
+
+
+class RecentAnswers:
+    """Least recently used answers, each kept for at most max_age_seconds."""
+
+    def __init__(self, max_entries: int = 10_000, max_age_seconds: float = 86_400.0, clock=time.monotonic):
+        self.max_entries = max(1, max_entries)
+        self.max_age_seconds = max_age_seconds
+        self._answers: "OrderedDict[Hashable, Tuple[float, Any]]" = OrderedDict()
+        self._lock = threading.Lock()
+        self._clock = clock
+
+    def put(self, key: Hashable, answer: Any) -> None:
+        with self._lock:
+            self._answers[key] = (self._clock(), answer)
+            self._answers.move_to_end(key)
+            while len(self._answers) > self.max_entries:
+                self._answers.popitem(last=False)
+
+    def get(self, key: Hashable) -> Optional[Any]:
+        """The answer stored for a key, unless it is too old."""
+        now = self._clock()
+        with self._lock:
+            entry = self._answers.get(key)
+            if entry is None:
+                return None
+            stored_at, answer = entry
+            if now - stored_at > self.max_age_seconds:
+                del self._answers[key]
+                return None
+            self._answers.move_to_end(key)
+            return answer
+
+    def __len__(self) -> int:
+        return len(self._answers)
+
+
+class DegradedMode:
+    """Decides when lookups degrade, and keeps the answers they degrade to."""
+
+    def __init__(
+        self,
+        answers: Optional[RecentAnswers] = None,
+        overload_ratio: float = 0.9,
+        load: Optional[Callable[[], float]] = None,
+    ):
+        """Initialize degraded mode.
+
+        Args:
+            answers: Recent answers (default: 10,000 kept for a day)
+            overload_ratio: Share of the concurrency limit from which the
+                process counts as overloaded
+            load: Current share of the concurrency limit in use (default:
+                never overloaded)
+        """
+        self.answers = answers or RecentAnswers()
+        self.overload_ratio = overload_ratio
+        self.load = load
+
+    def overloaded(self) -> bool:
+        """Whether lookups should be answered without the database, if they can."""
+        return self.load is not None and self.load() >= self.overload_ratio
+
+
+# Global degraded mode (None while DEGRADED_MODE_ENABLED is false)
+_degraded_mode: Optional[DegradedMode] = None
+
+
+def get_degraded_mode() -> Optional[DegradedMode]:
+    """Get the global degraded mode, if DEGRADED_MODE_ENABLED."""
+    global _degraded_mode
+    if _degraded_mode is None:
+        from src.config import get_config
+
+        config = get_config()
+        if not config.degraded_mode_enabled:
+            return None
+        load = None
+        if config.concurrency_limit_enabled:
+            from src.services.concurrency_service import get_concurrency_limiter
+
+            def load() -> float:
+                limiter = get_concurrency_limiter()
+                return limiter.in_flight() / limiter.max_in_flight
+
+        _degraded_mode = DegradedMode(
+            RecentAnswers(config.degraded_cache_max_entries, config.degraded_stale_max_age_seconds),
+            config.degraded_overload_ratio,
+            load,
+        )
+    return _degraded_mode
@@ -1,12 +1,18 @@
 """Detection service - accepts, geolocates, and stores detections (application service)."""
 import uuid
 import hashlib
+import logging
 from datetime import datetime
+from typing import List, Optional
 from sqlalchemy.orm import Session
 import base64
 from src.models.schemas import DetectionInput
 from src.models.database_models import Detection
+from src.services.geofence_service import GeofenceService
 from src.services.geolocation_service import GeolocationCalculationService
+from src.spatial import geohash
+
+logger = logging.getLogger(__name__)
 
 
 class DetectionService:
@@ -24,14 +30,16 @@ class DetectionService:
             reference_elevation=reference_elevation
         )
 
-    def accept_detection(self, detection: DetectionInput) -> dict:
+    def accept_detection(self, detection: DetectionInput, tenant_id: Optional[str] = None) -> dict:
         """Accept, geolocate, and store a detection in the database.
 
         Args:
             detection: Valid detection payload with image and pixel coordinates
+            tenant_id: Submitting tenant (None: anonymous)
 
         Returns:
-            dict: Contains detection_id and geolocation result
+            dict: Contains detection_id, geolocation result and the IDs of
+                geofences containing the calculated position
 
         Raises:
             ValueError: If detection cannot be processed or stored
@@ -92,6 +100,10 @@ class DetectionService:
                 confidence_flag=geo_result.confidence_flag,
                 uncertainty_radius_meters=geo_result.uncertainty_radius_meters,
                 calculation_method=geo_result.calculation_method,
+                geohash=geohash.encode(
+                    geo_result.latitude, geo_result.longitude, geohash.MAX_PRECISION
+                ),
+                tenant_id=tenant_id,
             )
 
             # Store in database
@@ -99,11 +111,57 @@ class DetectionService:
             self.session.commit()
             self.session.refresh(db_detection)
 
-            return {
-                "detection_id": detection_id,
-                "geolocation": geo_result,
-            }
-
         except Exception as e:
             self.session.rollback()
             raise ValueError(f"Failed to process detection: {str(e)}")
+
+        return {
+            "detection_id": detection_id,
+            "geolocation": geo_result,
+            "geofence_ids": self._matching_geofences(geo_result.latitude, geo_result.longitude),
+        }
+
+    def _matching_geofences(self, latitude: float, longitude: float) -> List[str]:
+        """Geofences containing a stored detection (empty if evaluation fails)."""
+        try:
+            return GeofenceService(self.session).matching_geofences(latitude, longitude)
+        except Exception as e:
+            # The detection is already stored; fence tagging is best effort
+            logger.error(f"Geofence evaluation failed: {type(e).__name__}: {str(e)}")
+            return []
+
+    def find_by_geohash_prefix(
+        self,
+        prefix: str,
+        tenant_id: Optional[str] = None,
+        since: Optional[datetime] = None,
+        until: Optional[datetime] = None,
+        limit: int = 100,
+    ) -> List[Detection]:
+        """Find detections whose calculated position falls in a geohash cell.
+
+        Args:
+            prefix: Geohash prefix (shorter prefixes cover larger areas)
+            tenant_id: Only detections this tenant submitted (None: only
+                anonymous ones)
+            since: Only detections captured at or after this time
+            until: Only detections captured before this time
+            limit: Maximum number of detections
+
+        Returns:
+            Detections, newest first
+
+        Raises:
+            ValueError: If the prefix is not a valid geohash
+        """
+        start, end = geohash.prefix_range(prefix)
+        query = self.session.query(Detection).filter(
+            Detection.tenant_id == tenant_id if tenant_id is not None else Detection.tenant_id.is_(None),
+            Detection.geohash >= start,
+            Detection.geohash < end,
+        )
+        if since is not None:
+            query = query.filter(Detection.timestamp >= since)
+        if until is not None:
+            query = query.filter(Detection.timestamp < until)
+        return query.order_by(Detection.timestamp.desc()).limit(limit).all()
@@ -0,0 +1,332 @@
+"""Device location history.
+
+Stores every (device, location, time) observation so detection rules can
+ask about a device's past rather than only the current request: has it
+ever been seen in this country, when first and last, and from how many
+countries. Device IDs are opaque (a fingerprint hash or SDK identifier)
+and scoped to the calling tenant.
+
+With location encryption configured, the precise fields of each sighting
+(city, coordinate, accuracy, IP address) are stored sealed with the
+tenant's data key (see src.services.location_encryption_service).
+"""
+
+import logging
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from sqlalchemy import func
+from sqlalchemy.orm import Session
+
+from src.models.database_models import DeviceLocation
+from src.pagination import Page, keyset_page
+from src.services.erasure_service import ErasureService
+from src.services.location_encryption_service import SEALED_FIELDS, LocationCipher, location_cipher
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass
+class DeviceObservation:
+    """One sighting of a device."""
+    observed_at: datetime  # UTC
+    country_code: Optional[str] = None
+    city_name: Optional[str] = None
+    latitude: Optional[float] = None
+    longitude: Optional[float] = None
+    accuracy_km: Optional[float] = None
+    ip_address: Optional[str] = None
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "timestamp": self.observed_at.replace(tzinfo=timezone.utc),
+            "country_code": self.country_code,
+            "city_name": self.city_name,
+            "latitude": self.latitude,
+            "longitude": self.longitude,
+            "accuracy_km": self.accuracy_km,
+            "ip_address": self.ip_address,
+        }
+
+
+@dataclass
+class CountryPresence:
+    """A device's sightings in one country."""
+    country_code: str
+    observations: int
+    first_seen: datetime  # UTC
+    last_seen: datetime
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "country_code": self.country_code,
+            "observations": self.observations,
+            "first_seen": self.first_seen.replace(tzinfo=timezone.utc),
+            "last_seen": self.last_seen.replace(tzinfo=timezone.utc),
+        }
+
+
+@dataclass
+class DeviceCorrelation:
+    """How a new sighting relates to the device's history before it."""
+    device_id: str
+    known_device: bool  # Device had been seen before
+    country_seen_before: Optional[bool]  # None if the sighting has no country
+    countries_seen: int  # Distinct countries before this sighting
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "device_id": self.device_id,
+            "known_device": self.known_device,
+            "country_seen_before": self.country_seen_before,
+            "countries_seen": self.countries_seen,
+        }
+
+
+def _utc(value: datetime) -> datetime:
+    """Naive UTC datetime (aware values are converted)."""
+    if value.tzinfo is not None:
+        value = value.astimezone(timezone.utc).replace(tzinfo=None)
+    return value
+
+
+def _device_id(device_id: str) -> str:
+    device_id = (device_id or "").strip()
+    if not device_id:
+        raise ValueError("device_id must not be empty")
+    if len(device_id) > 255:
+        raise ValueError("device_id must be at most 255 characters")
+    return device_id
+
+
+def _country(country_code: Optional[str]) -> Optional[str]:
+    if country_code is None:
+        return None
+    country = country_code.strip().upper()
+    if len(country) != 2 or not country.isalpha():
+        raise ValueError(f"Invalid country code {country_code!r}: expected ISO 3166-1 alpha-2")
+    return country
+
+
+class DeviceHistoryService:
+    """Records device sightings and answers history queries."""
+
+    def __init__(self, session: Session, cipher: Optional[LocationCipher] = None):
+        """Initialize device history service.
+
+        Args:
+            session: SQLAlchemy database session
+            cipher: Seals the precise fields of sightings (default: per
+                the location encryption settings, resolved when first needed)
+        """
+        self.session = session
+        self._cipher = cipher
+        self._cipher_resolved = cipher is not None
+
+    @property
+    def cipher(self) -> Optional[LocationCipher]:
+        """Cipher of the precise fields (None when encryption is off).
+
+        Raises:
+            RuntimeError: If location encryption is misconfigured
+        """
+        if not self._cipher_resolved:
+            self._cipher = location_cipher(self.session)
+            self._cipher_resolved = True
+        return self._cipher
+
+    def _to_observation(self, row: DeviceLocation) -> DeviceObservation:
+        if row.sealed:
+            if self.cipher is None:
+                raise RuntimeError("Device history is encrypted but location encryption is not configured")
+            fields = self.cipher.open(row)
+        else:
+            fields = {name: getattr(row, name) for name in SEALED_FIELDS}
+        return DeviceObservation(observed_at=row.observed_at, country_code=row.country_code, **fields)
+
+    def _query(self, device_id: str, tenant_id: Optional[str]):
+        return self.session.query(DeviceLocation).filter(
+            DeviceLocation.tenant_id == tenant_id,
+            DeviceLocation.device_id == device_id,
+        )
+
+    def record(
+        self, device_id: str, observation: DeviceObservation, tenant_id: Optional[str] = None
+    ) -> DeviceObservation:
+        """Store a sighting (unless the device was erased).
+
+        Args:
+            device_id: Device identifier (scoped to the tenant)
+            observation: Where and when the device was seen (naive times are UTC)
+            tenant_id: Calling tenant, if any
+
+        Returns:
+            The stored DeviceObservation (country normalized, time in UTC)
+
+        Raises:
+            ValueError: If the device ID, country or coordinate is invalid,
+                or the sighting cannot be stored
+            RuntimeError: If the tenant's data key is unavailable
+        """
+        device_id = _device_id(device_id)
+        observation.country_code = _country(observation.country_code)
+        observation.observed_at = _utc(observation.observed_at)
+        if (observation.latitude is None) != (observation.longitude is None):
+            raise ValueError("latitude and longitude must be passed together")
+        if observation.latitude is not None and (
+            not -90 <= observation.latitude <= 90 or not -180 <= observation.longitude <= 180
+        ):
+            raise ValueError(f"Coordinate out of range: ({observation.latitude}, {observation.longitude})")
+        if ErasureService(self.session).is_erased(tenant_id, device_id):
+            return observation
+
+        row = DeviceLocation(
+            tenant_id=tenant_id,
+            device_id=device_id,
+            observed_at=observation.observed_at,
+            country_code=observation.country_code,
+        )
+        fields = {name: getattr(observation, name) for name in SEALED_FIELDS}
+        if self.cipher is not None:
+            row.data_key_id, row.sealed = self.cipher.seal(tenant_id, device_id, fields)
+        else:
+            for name, value in fields.items():
+                setattr(row, name, value)
+        try:
+            self.session.add(row)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store device location: {str(e)}")
+        return observation
+
+    def correlate(
+        self, device_id: str, observation: DeviceObservation, tenant_id: Optional[str] = None
+    ) -> DeviceCorrelation:
+        """Compare a sighting with the device's history, then store it.
+
+        Raises:
+            ValueError, RuntimeError: As for record()
+        """
+        device_id = _device_id(device_id)
+        country = _country(observation.country_code)
+        countries = self.countries(device_id, tenant_id)
+        known = bool(countries) or self._query(device_id, tenant_id).first() is not None
+        correlation = DeviceCorrelation(
+            device_id=device_id,
+            known_device=known,
+            country_seen_before=(
+                any(c.country_code == country for c in countries) if country is not None else None
+            ),
+            countries_seen=len(countries),
+        )
+        self.record(device_id, observation, tenant_id)
+        if known and correlation.country_seen_before is False:
+            logger.info(f"Device {device_id} seen in new country {country}")
+        return correlation
+
+    def history(
+        self,
+        device_id: str,
+        tenant_id: Optional[str] = None,
+        since: Optional[datetime] = None,
+        limit: int = 100,
+    ) -> List[DeviceObservation]:
+        """Sightings of a device, newest first.
+
+        Args:
+            device_id: Device identifier
+            tenant_id: Calling tenant, if any
+            since: Only sightings at or after this time
+            limit: Most sightings returned
+
+        Raises:
+            ValueError: If the device ID is invalid
+            RuntimeError: If a sighting cannot be decrypted
+        """
+        return self.history_page(device_id, tenant_id, since, limit).items
+
+    def history_page(
+        self,
+        device_id: str,
+        tenant_id: Optional[str] = None,
+        since: Optional[datetime] = None,
+        limit: int = 100,
+        cursor: Optional[str] = None,
+    ) -> Page[DeviceObservation]:
+        """One page of a device's sightings, newest first.
+
+        Args:
+            device_id: Device identifier
+            tenant_id: Calling tenant, if any
+            since: Only sightings at or after this time
+            limit: Most sightings on the page
+            cursor: next_cursor of the previous page
+
+        Raises:
+            ValueError: If the device ID or cursor is invalid
+            RuntimeError: If a sighting cannot be decrypted
+        """
+        query = self._query(_device_id(device_id), tenant_id)
+        if since is not None:
+            query = query.filter(DeviceLocation.observed_at >= _utc(since))
+        return keyset_page(
+            query, DeviceLocation.observed_at, DeviceLocation.id, limit, cursor, self._to_observation
+        )
+
+    def countries(self, device_id: str, tenant_id: Optional[str] = None) -> List[CountryPresence]:
+        """Countries a device has been seen in, first seen first.
+
+        Raises:
+            ValueError: If the device ID is invalid
+        """
+        rows = (
+            self.session.query(
+                DeviceLocation.country_code,
+                func.count(DeviceLocation.id),
+                func.min(DeviceLocation.observed_at),
+                func.max(DeviceLocation.observed_at),
+            )
+            .filter(
+                DeviceLocation.tenant_id == tenant_id,
+                DeviceLocation.device_id == _device_id(device_id),
+                DeviceLocation.country_code.isnot(None),
+            )
+            .group_by(DeviceLocation.country_code)
+            .all()
+        )
+        presences = [CountryPresence(*row) for row in rows]
+        presences.sort(key=lambda presence: (presence.first_seen, presence.country_code))
+        return presences
+
+    def seen_in_country(
+        self, device_id: str, country_code: str, tenant_id: Optional[str] = None
+    ) -> Optional[CountryPresence]:
+        """The device's sightings in a country (None if never seen there).
+
+        Raises:
+            ValueError: If the device ID or country code is invalid
+        """
+        country = _country(country_code)
+        if country is None:
+            raise ValueError("country_code is required")
+        count, first_seen, last_seen = (
+            self.session.query(
+                func.count(DeviceLocation.id),
+                func.min(DeviceLocation.observed_at),
+                func.max(DeviceLocation.observed_at),
+            )
+            .filter(
+                DeviceLocation.tenant_id == tenant_id,
+                DeviceLocation.device_id == _device_id(device_id),
+                DeviceLocation.country_code == country,
+            )
+            .one()
+        )
+        if not count:
+            return None
+        return CountryPresence(country, count, first_seen, last_seen)
@@ -0,0 +1,245 @@
+"""Terrain elevation from SRTM height tiles.
+
+Tiles are the standard one-degree `.hgt` files (e.g., `N51W001.hgt`,
+optionally zipped as distributed by NASA/USGS: `N51W001.SRTMGL1.hgt.zip`),
+big-endian signed 16-bit heights in meters above the EGM96 geoid, rows
+north to south. SRTM1 tiles are 3601x3601 samples (1 arc-second, ~30 m),
+SRTM3 tiles 1201x1201 (3 arc-seconds, ~90 m); both can be mixed in one
+directory. SRTM has no ocean tiles and no coverage beyond 60N/56S, so
+coordinates there have no elevation.
+"""
+
+import logging
+import math
+import os
+import re
+import threading
+import zipfile
+from array import array
+from collections import OrderedDict
+from dataclasses import dataclass
+from sys import byteorder
+from typing import Dict, Optional, Tuple
+
+logger = logging.getLogger(__name__)
+
+_TILE_NAME = re.compile(r"^([NS])(\d{2})([EW])(\d{3})(?:\.[\w]+)*\.hgt(?:\.zip)?$", re.I)
+
+# Samples per tile side, by resolution in arc-seconds
+_TILE_SIZES = {3601: 1, 1201: 3}
+
+# SRTM marks missing samples with this value
+_VOID = -32768
+
+TileKey = Tuple[int, int]  # (floor(latitude), floor(longitude)) of the tile's SW corner
+
+
+@dataclass
+class ElevationResult:
+    """Terrain height at a coordinate."""
+    elevation_m: float  # Meters above the EGM96 geoid (approximately mean sea level)
+    resolution_arcsec: int  # 1 for SRTM1 tiles, 3 for SRTM3
+    tile: str  # Tile name, e.g. N51W001
+
+
+def tile_name(key: TileKey) -> str:
+    """Standard SRTM name of a tile, e.g. (51, -1) -> "N51W001"."""
+    latitude, longitude = key
+    return (
+        f"{'N' if latitude >= 0 else 'S'}{abs(latitude):02d}"
+        f"{'E' if longitude >= 0 else 'W'}{abs(longitude):03d}"
+    )
+
+
+def parse_tile_name(filename: str) -> Optional[TileKey]:
+    """Tile key from an SRTM filename, or None if the name is not a tile."""
+    match = _TILE_NAME.match(os.path.basename(filename))
+    if not match:
+        return None
+    latitude = int(match.group(2)) * (1 if match.group(1).upper() == "N" else -1)
+    longitude = int(match.group(4)) * (1 if match.group(3).upper() == "E" else -1)
+    return latitude, longitude
+
+
+class _Tile:
+    """Height samples of one loaded tile."""
+
+    def __init__(self, name: str, data: bytes):
+        side = math.isqrt(len(data) // 2)
+        if side not in _TILE_SIZES or side * side * 2 != len(data):
+            raise RuntimeError(f"Tile {name} has an unexpected size ({len(data)} bytes)")
+        self.name = name
+        self.side = side
+        self.resolution_arcsec = _TILE_SIZES[side]
+        self.samples = array("h", data)
+        if byteorder == "little":
+            self.samples.byteswap()
+
+    def height(self, key: TileKey, latitude: float, longitude: float) -> Optional[float]:
+        """Bilinear height inside the tile, ignoring void samples."""
+        steps = self.side - 1
+        row = (key[0] + 1 - latitude) * steps
+        col = (longitude - key[1]) * steps
+        r0, c0 = min(int(row), steps), min(int(col), steps)
+        r1, c1 = min(r0 + 1, steps), min(c0 + 1, steps)
+        dr, dc = row - r0, col - c0
+
+        total = weight_sum = 0.0
+        for r, c, weight in (
+            (r0, c0, (1 - dr) * (1 - dc)),
+            (r0, c1, (1 - dr) * dc),
+            (r1, c0, dr * (1 - dc)),
+            (r1, c1, dr * dc),
+        ):
+            sample = self.samples[r * self.side + c]
+            if sample != _VOID and weight > 0:
+                total += sample * weight
+                weight_sum += weight
+        if weight_sum == 0:
+            return None
+        return total / weight_sum
+
+
+class ElevationService:
+    """Looks up terrain elevation from a directory of SRTM tiles."""
+
+    def __init__(self, tile_paths: Optional[Dict[TileKey, str]] = None, cache_size: int = 8):
+        """Initialize elevation service.
+
+        Args:
+            tile_paths: Tile key -> .hgt or .hgt.zip path
+            cache_size: Maximum number of tiles kept in memory (an SRTM1
+                tile is ~26 MB)
+        """
+        self._paths = dict(tile_paths or {})
+        self.cache_size = max(1, cache_size)
+        self._tiles: "OrderedDict[TileKey, _Tile]" = OrderedDict()
+        self._lock = threading.Lock()
+
+    @classmethod
+    def from_directory(cls, path: str, cache_size: int = 8) -> "ElevationService":
+        """Index the SRTM tiles in a directory (tiles are read on first use).
+
+        Raises:
+            OSError: If the directory cannot be read
+        """
+        paths = {}
+        for filename in sorted(os.listdir(path)):
+            key = parse_tile_name(filename)
+            if key is not None:
+                # Prefer an unzipped tile over a zipped copy of the same area
+                if key not in paths or paths[key].lower().endswith(".zip"):
+                    paths[key] = os.path.join(path, filename)
+        return cls(paths, cache_size=cache_size)
+
+    @property
+    def tile_count(self) -> int:
+        """Number of indexed tiles."""
+        return len(self._paths)
+
+    def lookup(self, latitude: float, longitude: float) -> Optional[ElevationResult]:
+        """Terrain elevation at a coordinate.
+
+        Args:
+            latitude: Latitude in degrees (-90 to 90)
+            longitude: Longitude in degrees (-180 to 180)
+
+        Returns:
+            ElevationResult, or None if no tile covers the coordinate or
+            the samples there are void
+
+        Raises:
+            ValueError: If the coordinate is out of range
+            RuntimeError: If the covering tile cannot be read
+        """
+        if not -90 <= latitude <= 90:
+            raise ValueError(f"Latitude out of range: {latitude}")
+        if not -180 <= longitude <= 180:
+            raise ValueError(f"Longitude out of range: {longitude}")
+
+        key = (math.floor(latitude), math.floor(longitude))
+        tile = self._tile(key)
+        if tile is None:
+            return None
+        height = tile.height(key, latitude, longitude)
+        if height is None:
+            return None
+        return ElevationResult(
+            elevation_m=round(height, 1),
+            resolution_arcsec=tile.resolution_arcsec,
+            tile=tile.name,
+        )
+
+    def _tile(self, key: TileKey) -> Optional[_Tile]:
+        path = self._paths.get(key)
+        if path is None:
+            return None
+        with self._lock:
+            tile = self._tiles.get(key)
+            if tile is not None:
+                self._tiles.move_to_end(key)
+                return tile
+
+        tile = _Tile(tile_name(key), self._read(path))
+        with self._lock:
+            self._tiles[key] = tile
+            while len(self._tiles) > self.cache_size:
+                self._tiles.popitem(last=False)
+        logger.info(f"Loaded SRTM{tile.resolution_arcsec} tile {tile.name} from {path}")
+        return tile
+
+    @staticmethod
+    def _read(path: str) -> bytes:
+        try:
+            if not path.lower().endswith(".zip"):
+                with open(path, "rb") as f:
+                    return f.read()
+            with zipfile.ZipFile(path) as archive:
+                members = [n for n in archive.namelist() if n.lower().endswith(".hgt")]
+                if not members:
+                    raise RuntimeError(f"{path} contains no .hgt file")
+                return archive.read(members[0])
+        except (OSError, zipfile.BadZipFile) as e:
+            raise RuntimeError(f"Failed to read elevation tile {path}: {str(e)}")
+
+
+def lookup_elevation_m(latitude: float, longitude: float) -> Optional[float]:
+    """Elevation for optional response fields.
+
+    Failures are logged and give None so that elevation never fails an
+    otherwise successful request.
+    """
+    try:
+        result = get_elevation_service().lookup(latitude, longitude)
+    except (ValueError, RuntimeError) as e:
+        logger.error(f"Elevation lookup failed for ({latitude}, {longitude}): {str(e)}")
+        return None
+    return result.elevation_m if result is not None else None
+
+
+# Global elevation service (tiles indexed lazily from ELEVATION_TILE_DIR)
+_elevation_service: Optional[ElevationService] = None
+
+
+def get_elevation_service() -> ElevationService:
+    """Get the global elevation service.
+
+    Returns:
+        ElevationService (with no tiles if the directory is unavailable)
+    """
+    global _elevation_service
+    if _elevation_service is None:
+        from src.config import get_config
+
+        config = get_config()
+        try:
+            _elevation_service = ElevationService.from_directory(
+                config.elevation_tile_dir, cache_size=config.elevation_tile_cache_size
+            )
+            logger.info(
+                f"Indexed {_elevation_service.tile_count} SRTM tiles in {config.elevation_tile_dir}"
+            )
+        except OSError as e:
+            logger.warning(f"Elevation tiles unavailable: {e}")
+            _elevation_service = ElevationService(cache_size=config.elevation_tile_cache_size)
+    return _elevation_service
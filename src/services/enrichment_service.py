@@ -0,0 +1,169 @@
+"""Enrichment pipeline for IP lookup responses.
+
+Enrichers add optional blocks (ASN, anonymizer flags, datacenter
+classification, subdivision hierarchy, mobile carrier, locale, currency,
+region groups, ...) to a lookup result.
+Each enricher has a stable name so it can be switched on or off per API
+key.
+"""
+
+import logging
+from typing import Any, Dict, Iterable, List, Optional, Set
+
+from src.services.ip_lookup_service import IpLookupResult
+
+logger = logging.getLogger(__name__)
+
+
+class Enricher:
+    """Base class for lookup enrichers."""
+
+    name: str = ""
+
+    def enrich(self, result: IpLookupResult) -> Optional[Dict[str, Any]]:
+        """Compute the enrichment block for a lookup result.
+
+        Args:
+            result: IP lookup result (normalized address and GeoIP record)
+
+        Returns:
+            dict to include under the enricher's name, or None if no data
+        """
+        raise NotImplementedError
+
+
+class EnrichmentPipeline:
+    """Applies a configurable subset of enrichers to lookup results."""
+
+    def __init__(self, enrichers: Optional[List[Enricher]] = None):
+        """Initialize pipeline.
+
+        Args:
+            enrichers: Available enrichers, applied in list order
+        """
+        self._enrichers: Dict[str, Enricher] = {}
+        for enricher in enrichers or []:
+            self.register(enricher)
+
+    def register(self, enricher: Enricher) -> None:
+        """Register an enricher under its name (replacing any existing one)."""
+        self._enrichers[enricher.name] = enricher
+
+    @property
+    def available(self) -> List[str]:
+        """Names of registered enrichers."""
+        return list(self._enrichers)
+
+    def apply(self, result: IpLookupResult, enabled: Iterable[str]) -> Dict[str, Any]:
+        """Run the enabled enrichers against a lookup result.
+
+        A failing enricher is logged and skipped so that one broken dataset
+        cannot fail the whole lookup.
+
+        Args:
+            result: IP lookup result
+            enabled: Names of enrichers to run
+
+        Returns:
+            dict mapping enricher name to its block (enrichers without data omitted)
+        """
+        enabled = set(enabled)
+        blocks: Dict[str, Any] = {}
+        for name, enricher in self._enrichers.items():
+            if name not in enabled:
+                continue
+            try:
+                block = enricher.enrich(result)
+            except Exception as e:
+                logger.error(f"Enricher '{name}' failed: {type(e).__name__}: {str(e)}")
+                continue
+            if block is not None:
+                blocks[name] = block
+        return blocks
+
+
+def enabled_enrichments(api_key: Optional[str], config=None) -> Set[str]:
+    """Resolve which enrichments an API key receives.
+
+    Keys listed in ENRICHMENT_API_KEY_PROFILES (JSON object of key -> list of
+    enricher names) get exactly that list; all other callers get
+    ENRICHMENT_DEFAULTS.
+
+    Args:
+        api_key: Caller's API key (X-API-Key header), if any
+        config: Application configuration (defaults to the configuration
+            loaded with the enrichment pipeline, so profiles are parsed once)
+
+    Returns:
+        Set of enricher names
+    """
+    if config is None:
+        config = _enrichment_config()
+
+    if api_key and api_key in config.enrichment_profiles:
+        return set(config.enrichment_profiles[api_key])
+
+    return {
+        name.strip()
+        for name in config.enrichment_defaults.split(",")
+        if name.strip()
+    }
+
+
+# Configuration used for per-key enrichment profiles (loaded once)
+_config = None
+
+
+def _enrichment_config():
+    global _config
+    if _config is None:
+        from src.config import get_config
+
+        _config = get_config()
+    return _config
+
+
+# Global pipeline instance (built lazily from configuration)
+_enrichment_pipeline = None
+
+
+def get_enrichment_pipeline() -> EnrichmentPipeline:
+    """Get the global enrichment pipeline with all configured enrichers.
+
+    Returns:
+        EnrichmentPipeline (possibly without enrichers if no datasets load)
+    """
+    global _enrichment_pipeline
+    if _enrichment_pipeline is None:
+        from src.config import get_config
+        from src.services.anonymizer_service import build_anonymizer_enricher
+        from src.services.asn_service import build_asn_enricher
+        from src.services.carrier_service import build_carrier_enricher, get_carrier_directory
+        from src.services.currency_service import build_currency_enricher
+        from src.services.datacenter_service import build_datacenter_enricher
+        from src.services.locale_service import build_locale_enricher
+        from src.services.region_group_service import build_region_group_enricher
+        from src.services.subdivision_service import build_subdivision_enricher
+
+        config = get_config()
+        pipeline = EnrichmentPipeline()
+        asn_enricher = build_asn_enricher(config)
+        if asn_enricher is not None:
+            pipeline.register(asn_enricher)
+        pipeline.register(build_anonymizer_enricher(config, asn_enricher))
+        pipeline.register(build_datacenter_enricher(config, asn_enricher))
+        subdivision_enricher = build_subdivision_enricher(config)
+        if subdivision_enricher is not None:
+            pipeline.register(subdivision_enricher)
+        pipeline.register(build_carrier_enricher(config, get_carrier_directory()))
+        locale_enricher = build_locale_enricher(config)
+        if locale_enricher is not None:
+            pipeline.register(locale_enricher)
+        currency_enricher = build_currency_enricher(config)
+        if currency_enricher is not None:
+            pipeline.register(currency_enricher)
+        region_group_enricher = build_region_group_enricher(config)
+        if region_group_enricher is not None:
+            pipeline.register(region_group_enricher)
+        _enrichment_pipeline = pipeline
+    return _enrichment_pipeline
@@ -0,0 +1,408 @@
+"""Right to erasure of a tenant's data subjects (GDPR Art. 17).
+
+A subject is named by the identifier the tenant passes to the API for
+it: the user, account, device or tracked entity ID. Erasing one
+
+1. tombstones it at once (SubjectTombstone, keyed by the SHA-256 of the
+   tenant and identifier): device history, login, account session and
+   geofence tracking calls for it still answer but no longer store
+   anything (is_erased),
+2. queues a job that deletes, in batches of ERASURE_BATCH_SIZE rows, the
+   tenant's records of it: device sightings, logins and the location
+   profile trained on them, account sessions and sharing flags, geofence
+   membership and alerts, webhook deliveries whose payload names it and
+   its velocity counters; with IP addresses given, also the sanctions
+   audit records and rule feedback of those addresses,
+3. counts what is left in each store and stores the report.
+
+Entries of the hash-chained audit log that reference the subject (e.g.
+detection decisions on `user:<id>`) cannot be deleted or edited without
+breaking the chain that makes the log tamper-evident; the report counts
+them as retained. The identifier itself is only held in memory while the
+job runs. Jobs run in the API process that accepted them; jobs cut off
+by a restart are marked failed, and requesting the erasure again resumes
+it (the tombstone is kept).
+"""
+
+import asyncio
+import hashlib
+import ipaddress
+import json
+import logging
+import threading
+import uuid
+from dataclasses import dataclass, field
+from datetime import datetime
+from typing import Any, Callable, Dict, Iterable, List, Optional, Tuple
+
+from sqlalchemy.orm import Query, Session
+
+from src.models.database_models import (
+    AccountSession,
+    AccountSharingFlag,
+    AuditLogEntry,
+    DeviceLocation,
+    ErasureJob,
+    GeofenceAlert,
+    GeofenceEntityState,
+    LoginLocation,
+    RuleFeedback,
+    SanctionsAuditRecord,
+    SubjectTombstone,
+    UserLocationProfile,
+    WebhookDelivery,
+)
+
+logger = logging.getLogger(__name__)
+
+# Most IP addresses one erasure may name
+MAX_IP_ADDRESSES = 100
+
+# Audit log resources naming a subject (see the /api/v1/detect/* routes)
+AUDIT_RESOURCE_KINDS = ("user", "account", "session")
+
+
+class ErasureStatus:
+    """Lifecycle of an erasure job."""
+    QUEUED = "queued"
+    RUNNING = "running"
+    SUCCEEDED = "succeeded"
+    FAILED = "failed"
+
+    ACTIVE = (QUEUED, RUNNING)
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+def subject_hash(tenant_id: str, subject: str) -> str:
+    """Key of a subject's tombstone and erasure jobs."""
+    return hashlib.sha256(f"{tenant_id}\x00{subject}".encode()).hexdigest()
+
+
+def _subject(subject: str) -> str:
+    subject = (subject or "").strip()
+    if not subject:
+        raise ValueError("subject must not be empty")
+    if len(subject) > 255:
+        raise ValueError("subject must be at most 255 characters")
+    return subject
+
+
+def parse_ip_addresses(values: Iterable[str]) -> List[str]:
+    """Normalized, deduplicated IP addresses.
+
+    Raises:
+        ValueError: If an address is invalid or there are too many
+    """
+    addresses: List[str] = []
+    for value in values:
+        try:
+            address = str(ipaddress.ip_address((value or "").strip()))
+        except ValueError:
+            raise ValueError(f"Invalid IP address: {value}")
+        if address not in addresses:
+            addresses.append(address)
+    if len(addresses) > MAX_IP_ADDRESSES:
+        raise ValueError(f"At most {MAX_IP_ADDRESSES} IP addresses can be erased at once")
+    return addresses
+
+
+@dataclass
+class StoreErasure:
+    """Rows of one store deleted, and left after deletion (0 once verified)."""
+    deleted: int = 0
+    remaining: int = 0
+
+    def to_dict(self) -> Dict[str, int]:
+        """Representation used in API responses."""
+        return {"deleted": self.deleted, "remaining": self.remaining}
+
+
+@dataclass
+class ErasureReport:
+    """What an erasure deleted and what it left."""
+    stores: Dict[str, StoreErasure] = field(default_factory=dict)
+    velocity_counters_cleared: bool = False
+    audit_log_entries_retained: int = 0
+
+    @property
+    def verified(self) -> bool:
+        """No store holds records of the subject any more."""
+        return all(store.remaining == 0 for store in self.stores.values())
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "stores": {name: store.to_dict() for name, store in self.stores.items()},
+            "velocity_counters_cleared": self.velocity_counters_cleared,
+            "audit_log_entries_retained": self.audit_log_entries_retained,
+            "verified": self.verified,
+        }
+
+
+class ErasureService:
+    """Records erasure requests and tombstones, and deletes subjects' records."""
+
+    def __init__(self, session: Session, batch_size: Optional[int] = None):
+        """Initialize service.
+
+        Args:
+            session: SQLAlchemy database session
+            batch_size: Rows deleted per transaction (default ERASURE_BATCH_SIZE)
+        """
+        self.session = session
+        self.batch_size = batch_size
+
+    def is_erased(self, tenant_id: Optional[str], subject: str) -> bool:
+        """Whether a subject of the tenant was erased (records of it must not be stored).
+
+        Anonymous callers have no tenant and so nothing erased.
+        """
+        if tenant_id is None:
+            return False
+        key = subject_hash(tenant_id, (subject or "").strip())
+        return self.session.query(SubjectTombstone.id).filter(
+            SubjectTombstone.tenant_id == tenant_id, SubjectTombstone.subject_hash == key
+        ).first() is not None
+
+    def request(self, tenant_id: str, subject: str) -> ErasureJob:
+        """Tombstone a subject and queue the erasure of its records.
+
+        Raises:
+            ValueError: If the subject is invalid or the request cannot be stored
+        """
+        subject = _subject(subject)
+        key = subject_hash(tenant_id, subject)
+        job = ErasureJob(
+            erasure_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            subject_hash=key,
+            status=ErasureStatus.QUEUED,
+        )
+        try:
+            self.session.add(job)
+            if not self.is_erased(tenant_id, subject):
+                self.session.add(SubjectTombstone(tenant_id=tenant_id, subject_hash=key, erasure_id=job.erasure_id))
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store erasure request: {str(e)}")
+        logger.info(f"Tenant {tenant_id} queued erasure {job.erasure_id}")
+        return job
+
+    def get(self, tenant_id: str, erasure_id: str) -> Optional[ErasureJob]:
+        """An erasure of the tenant (None if unknown or another tenant's)."""
+        return self.session.query(ErasureJob).filter(
+            ErasureJob.tenant_id == tenant_id, ErasureJob.erasure_id == erasure_id
+        ).first()
+
+    def update(self, erasure_id: str, **fields: Any) -> ErasureJob:
+        """Set fields of an erasure job and commit."""
+        job = self.session.query(ErasureJob).filter(ErasureJob.erasure_id == erasure_id).one()
+        for name, value in fields.items():
+            setattr(job, name, value)
+        self.session.commit()
+        return job
+
+    def fail_interrupted(self) -> int:
+        """Mark queued and running erasures failed (at startup, when none can be running).
+
+        Returns:
+            Number of erasures marked failed
+        """
+        jobs = self.session.query(ErasureJob).filter(ErasureJob.status.in_(ErasureStatus.ACTIVE)).all()
+        for job in jobs:
+            job.status = ErasureStatus.FAILED
+            job.error = "Interrupted by a restart; request the erasure again"
+            job.finished_at = datetime.utcnow()
+        self.session.commit()
+        return len(jobs)
+
+    def _stores(self, tenant_id: str, subject: str, ip_addresses: List[str]) -> List[Tuple[str, Any, Query]]:
+        """(name, model, query of the subject's rows) of every store holding them."""
+        stores = [
+            (name, model, self.session.query(model).filter(model.tenant_id == tenant_id, column == subject))
+            for name, model, column in (
+                ("device_locations", DeviceLocation, DeviceLocation.device_id),
+                ("login_locations", LoginLocation, LoginLocation.user_id),
+                ("user_location_profiles", UserLocationProfile, UserLocationProfile.user_id),
+                ("account_sessions", AccountSession, AccountSession.account_id),
+                ("account_sharing_flags", AccountSharingFlag, AccountSharingFlag.account_id),
+                ("geofence_entity_states", GeofenceEntityState, GeofenceEntityState.entity_id),
+                ("geofence_alerts", GeofenceAlert, GeofenceAlert.entity_id),
+            )
+        ]
+        # Geofence alert payloads, as serialized by WebhookDispatcher
+        stores.append(("webhook_deliveries", WebhookDelivery, self.session.query(WebhookDelivery).filter(
+            WebhookDelivery.tenant_id == tenant_id,
+            WebhookDelivery.body.contains(f'"entity_id": {json.dumps(subject)}', autoescape=True),
+        )))
+        if ip_addresses:
+            for name, model in (
+                ("sanctions_audit_records", SanctionsAuditRecord),
+                ("rule_feedback", RuleFeedback),
+            ):
+                stores.append((name, model, self.session.query(model).filter(
+                    model.tenant_id == tenant_id, model.ip_address.in_(ip_addresses)
+                )))
+        return stores
+
+    def _delete(self, model: Any, query: Query, batch_size: int, should_stop: Callable[[], bool]) -> int:
+        deleted = 0
+        while True:
+            if should_stop():
+                raise RuntimeError("Interrupted by shutdown; request the erasure again")
+            ids = [row_id for (row_id,) in query.with_entities(model.id).limit(batch_size)]
+            if not ids:
+                return deleted
+            try:
+                self.session.query(model).filter(model.id.in_(ids)).delete(synchronize_session=False)
+                self.session.commit()
+            except Exception as e:
+                self.session.rollback()
+                raise RuntimeError(f"Failed to delete {model.__tablename__} rows: {str(e)}")
+            deleted += len(ids)
+
+    def erase(
+        self,
+        tenant_id: str,
+        subject: str,
+        ip_addresses: Iterable[str] = (),
+        should_stop: Callable[[], bool] = lambda: False,
+    ) -> ErasureReport:
+        """Delete a subject's records and verify none are left.
+
+        Args:
+            tenant_id: Owning tenant
+            subject: Subject identifier
+            ip_addresses: Addresses whose audit-referenced records are erased too
+            should_stop: Checked between batches; True aborts the erasure
+
+        Returns:
+            ErasureReport
+
+        Raises:
+            ValueError: If the subject or an address is invalid
+            RuntimeError: If a store cannot be erased, or the erasure was stopped
+        """
+        from src.config import get_config
+        from src.services.velocity_service import get_velocity_tracker
+
+        subject = _subject(subject)
+        ip_addresses = parse_ip_addresses(ip_addresses)
+        batch_size = max(1, self.batch_size or get_config().erasure_batch_size)
+        report = ErasureReport()
+        for name, model, query in self._stores(tenant_id, subject, ip_addresses):
+            report.stores[name] = StoreErasure(deleted=self._delete(model, query, batch_size, should_stop))
+        # Per process without VELOCITY_REDIS_URL; other workers' counters expire with their windows
+        get_velocity_tracker().forget(subject, tenant_id)
+        report.velocity_counters_cleared = True
+
+        for name, _, query in self._stores(tenant_id, subject, ip_addresses):
+            report.stores[name].remaining = query.count()
+        resources = [f"{kind}:{subject}" for kind in AUDIT_RESOURCE_KINDS]
+        resources += [f"ip:{address}" for address in ip_addresses]
+        report.audit_log_entries_retained = self.session.query(AuditLogEntry).filter(
+            AuditLogEntry.tenant_id == tenant_id, AuditLogEntry.resource.in_(resources)
+        ).count()
+        return report
+
+
+class ErasureRunner:
+    """Runs erasure jobs in the background of the API process."""
+
+    def __init__(self, session_factory: Callable[[], Session] = _default_session):
+        """Initialize runner.
+
+        Args:
+            session_factory: Creates database sessions
+        """
+        self.session_factory = session_factory
+        self._stopping = threading.Event()
+        self._tasks: Dict[str, asyncio.Task] = {}
+
+    def submit(self, erasure_id: str, tenant_id: str, subject: str, ip_addresses: List[str]) -> asyncio.Task:
+        """Start a queued erasure in the background (see run)."""
+        task = asyncio.create_task(self.run(erasure_id, tenant_id, subject, ip_addresses))
+        self._tasks[erasure_id] = task
+        task.add_done_callback(lambda _: self._tasks.pop(erasure_id, None))
+        return task
+
+    async def run(self, erasure_id: str, tenant_id: str, subject: str, ip_addresses: List[str]) -> None:
+        """Process a queued erasure to completion, storing its report or failure."""
+        await asyncio.to_thread(self._run, erasure_id, tenant_id, subject, ip_addresses)
+
+    def _run(self, erasure_id: str, tenant_id: str, subject: str, ip_addresses: List[str]) -> None:
+        session = self.session_factory()
+        try:
+            service = ErasureService(session)
+            service.update(erasure_id, status=ErasureStatus.RUNNING, started_at=datetime.utcnow())
+            try:
+                report = service.erase(tenant_id, subject, ip_addresses, self._stopping.is_set)
+            except Exception as e:
+                known = isinstance(e, (ValueError, RuntimeError))
+                if not known:
+                    logger.error(f"Erasure {erasure_id} failed: {type(e).__name__}: {str(e)}", exc_info=True)
+                session.rollback()
+                service.update(
+                    erasure_id,
+                    status=ErasureStatus.FAILED,
+                    error=str(e)[:1000] if known else "Internal error",
+                    finished_at=datetime.utcnow(),
+                )
+                return
+            service.update(
+                erasure_id,
+                status=ErasureStatus.SUCCEEDED,
+                report=json.dumps(report.to_dict()),
+                finished_at=datetime.utcnow(),
+            )
+            deleted = sum(store.deleted for store in report.stores.values())
+            logger.info(f"Erasure {erasure_id} finished: {deleted} rows deleted, verified={report.verified}")
+        except Exception as e:
+            logger.error(f"Failed to record the outcome of erasure {erasure_id}: {str(e)}")
+        finally:
+            session.close()
+
+    def recover(self) -> int:
+        """Mark erasures a previous process left queued or running failed.
+
+        Returns:
+            Number of erasures marked failed (0 if the database is unreachable,
+            which is logged)
+        """
+        try:
+            session = self.session_factory()
+            try:
+                interrupted = ErasureService(session).fail_interrupted()
+            finally:
+                session.close()
+        except Exception as e:
+            logger.warning(f"Could not check for interrupted erasures: {str(e)}")
+            return 0
+        if interrupted:
+            logger.warning(f"Marked {interrupted} interrupted erasures failed")
+        return interrupted
+
+    async def stop(self) -> None:
+        """Stop running erasures after their current batch (they are marked failed)."""
+        self._stopping.set()
+        tasks = list(self._tasks.values())
+        if tasks:
+            await asyncio.gather(*tasks, return_exceptions=True)
+
+
+# Global erasure runner
+_erasure_runner: Optional[ErasureRunner] = None
+
+
+def get_erasure_runner() -> ErasureRunner:
+    """Get the global erasure runner."""
+    global _erasure_runner
+    if _erasure_runner is None:
+        _erasure_runner = ErasureRunner()
+    return _erasure_runner
@@ -0,0 +1,661 @@
+"""Forward geocoding through pluggable external providers.
+
+Providers (Nominatim, Google) translate a free-text address into
+candidates normalized to a common shape with a 0-1 confidence score. A
+ProviderChain tries providers in order and fails over when one errors,
+rate-limits or returns nothing. Each provider has an optional daily request
+budget and a circuit breaker, so a provider that is down or over quota is
+skipped instead of slowing every request.
+"""
+
+import asyncio
+import logging
+import time
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Sequence
+
+logger = logging.getLogger(__name__)
+
+# async (url, params, headers) -> decoded JSON
+FetchJson = Callable[[str, Dict[str, Any], Dict[str, str]], Awaitable[Any]]
+
+
+class GeocodingProviderError(RuntimeError):
+    """Raised when a provider cannot answer a request."""
+
+
+class GeocodingRateLimitError(GeocodingProviderError):
+    """Raised when a provider rejects a request for exceeding its quota."""
+
+    def __init__(self, message: str, retry_after: Optional[float] = None):
+        """Initialize error.
+
+        Args:
+            message: Error description
+            retry_after: Seconds the provider asked us to wait, if given
+        """
+        super().__init__(message)
+        self.retry_after = retry_after
+
+
+def _retry_after_seconds(value: Optional[str]) -> Optional[float]:
+    """Parse a Retry-After header given in seconds (HTTP dates are ignored)."""
+    try:
+        return max(0.0, float(value)) if value else None
+    except ValueError:
+        return None
+
+
+@dataclass
+class GeocodeCandidate:
+    """A normalized forward geocoding match."""
+    formatted_address: str
+    latitude: float
+    longitude: float
+    confidence: float
+    provider: str
+    country_code: Optional[str] = None
+    components: Dict[str, str] = field(default_factory=dict)
+    bbox: Optional[List[float]] = None  # [min_lon, min_lat, max_lon, max_lat]
+
+
+@dataclass
+class GeocodeQuery:
+    """Forward geocoding request parameters."""
+    query: str
+    limit: int = 5
+    language: Optional[str] = None
+    country_codes: Sequence[str] = ()
+
+
+@dataclass
+class GeocodeResult:
+    """Candidates returned by the provider that answered."""
+    query: str
+    provider: Optional[str]
+    candidates: List[GeocodeCandidate]
+
+
+async def fetch_json(
+    url: str,
+    params: Dict[str, Any],
+    headers: Dict[str, str],
+    timeout_seconds: float = 10.0,
+) -> Any:
+    """GET a JSON resource.
+
+    Raises:
+        GeocodingRateLimitError: On HTTP 429
+        GeocodingProviderError: On transport errors, timeouts, other non-200
+            responses or bodies that are not JSON
+    """
+    import aiohttp
+
+    try:
+        async with aiohttp.ClientSession(headers=headers) as session:
+            async with session.get(
+                url,
+                params=params,
+                timeout=aiohttp.ClientTimeout(total=timeout_seconds),
+            ) as response:
+                if response.status == 429:
+                    raise GeocodingRateLimitError(
+                        f"{url} rate limited the request (HTTP 429)",
+                        retry_after=_retry_after_seconds(response.headers.get("Retry-After")),
+                    )
+                if response.status != 200:
+                    raise GeocodingProviderError(
+                        f"{url} returned HTTP {response.status}"
+                    )
+                return await response.json(content_type=None)
+    except aiohttp.ClientError as e:
+        raise GeocodingProviderError(f"{url} request failed: {str(e)}")
+    except asyncio.TimeoutError:
+        raise GeocodingProviderError(f"{url} timed out after {timeout_seconds}s")
+    except ValueError as e:
+        raise GeocodingProviderError(f"{url} returned invalid JSON: {str(e)}")
+
+
+def _parse_candidates(provider: str, items, parse) -> List["GeocodeCandidate"]:
+    """Parse provider results, treating malformed items as a provider failure."""
+    try:
+        return [parse(item) for item in items]
+    except (KeyError, TypeError, ValueError, AttributeError) as e:
+        raise GeocodingProviderError(
+            f"Malformed {provider} response: {type(e).__name__}: {str(e)}"
+        )
+
+
+class GeocodingProvider:
+    """Base class for forward geocoding providers."""
+
+    name: str = ""
+
+    async def geocode(self, query: GeocodeQuery) -> List[GeocodeCandidate]:
+        """Geocode a free-text address.
+
+        Args:
+            query: Request parameters
+
+        Returns:
+            Candidates (possibly empty), best first
+
+        Raises:
+            GeocodingProviderError: If the provider fails
+        """
+        raise NotImplementedError
+
+
+class NominatimProvider(GeocodingProvider):
+    """OpenStreetMap Nominatim search API."""
+
+    name = "nominatim"
+
+    # Nominatim address keys folded into normalized component names
+    _COMPONENT_KEYS = {
+        "house_number": ("house_number",),
+        "road": ("road", "pedestrian", "footway"),
+        "city": ("city", "town", "village", "hamlet", "municipality"),
+        "county": ("county",),
+        "state": ("state", "region"),
+        "postcode": ("postcode",),
+        "country": ("country",),
+    }
+
+    def __init__(
+        self,
+        base_url: str = "https://nominatim.openstreetmap.org",
+        user_agent: str = "geolocation-engine",
+        email: Optional[str] = None,
+        fetch: FetchJson = fetch_json,
+    ):
+        """Initialize Nominatim provider.
+
+        Args:
+            base_url: Nominatim server URL
+            user_agent: User-Agent sent with requests (required by the usage policy)
+            email: Contact address passed to Nominatim, if any
+            fetch: JSON fetch function (injectable for testing)
+        """
+        self.base_url = base_url.rstrip("/")
+        self.user_agent = user_agent
+        self.email = email
+        self.fetch = fetch
+
+    async def geocode(self, query: GeocodeQuery) -> List[GeocodeCandidate]:
+        """Search Nominatim for the query."""
+        params: Dict[str, Any] = {
+            "q": query.query,
+            "format": "jsonv2",
+            "addressdetails": 1,
+            "limit": query.limit,
+        }
+        if query.country_codes:
+            params["countrycodes"] = ",".join(c.lower() for c in query.country_codes)
+        if self.email:
+            params["email"] = self.email
+        headers = {"User-Agent": self.user_agent}
+        if query.language:
+            headers["Accept-Language"] = query.language
+
+        results = await self.fetch(f"{self.base_url}/search", params, headers)
+        if not isinstance(results, list):
+            raise GeocodingProviderError("Unexpected Nominatim response")
+        return _parse_candidates(self.name, results, self._candidate)
+
+    def _candidate(self, item: Dict[str, Any]) -> GeocodeCandidate:
+        address = item.get("address") or {}
+        components = {}
+        for component, keys in self._COMPONENT_KEYS.items():
+            value = next((address[k] for k in keys if address.get(k)), None)
+            if value:
+                components[component] = value
+
+        bbox = None
+        if item.get("boundingbox"):
+            # Nominatim order: [min_lat, max_lat, min_lon, max_lon]
+            min_lat, max_lat, min_lon, max_lon = (float(v) for v in item["boundingbox"])
+            bbox = [min_lon, min_lat, max_lon, max_lat]
+
+        country_code = address.get("country_code")
+        return GeocodeCandidate(
+            formatted_address=item.get("display_name", ""),
+            latitude=float(item["lat"]),
+            longitude=float(item["lon"]),
+            confidence=round(min(max(float(item.get("importance", 0.0)), 0.0), 1.0), 3),
+            provider=self.name,
+            country_code=country_code.upper() if country_code else None,
+            components=components,
+            bbox=bbox,
+        )
+
+
+class GoogleGeocodingProvider(GeocodingProvider):
+    """Google Maps Geocoding API."""
+
+    name = "google"
+
+    URL = "https://maps.googleapis.com/maps/api/geocode/json"
+
+    # Precision of the returned geometry
+    _LOCATION_TYPE_CONFIDENCE = {
+        "ROOFTOP": 1.0,
+        "RANGE_INTERPOLATED": 0.8,
+        "GEOMETRIC_CENTER": 0.6,
+        "APPROXIMATE": 0.4,
+    }
+    # Statuses reporting an exhausted quota rather than a bad request
+    _QUOTA_STATUSES = ("OVER_QUERY_LIMIT", "OVER_DAILY_LIMIT")
+    _COMPONENT_TYPES = {
+        "street_number": "house_number",
+        "route": "road",
+        "locality": "city",
+        "postal_town": "city",
+        "administrative_area_level_2": "county",
+        "administrative_area_level_1": "state",
+        "postal_code": "postcode",
+        "country": "country",
+    }
+
+    def __init__(self, api_key: str, fetch: FetchJson = fetch_json):
+        """Initialize Google provider.
+
+        Args:
+            api_key: Google Maps Platform API key
+            fetch: JSON fetch function (injectable for testing)
+        """
+        self.api_key = api_key
+        self.fetch = fetch
+
+    async def geocode(self, query: GeocodeQuery) -> List[GeocodeCandidate]:
+        """Geocode the query with the Google Geocoding API."""
+        params: Dict[str, Any] = {"address": query.query, "key": self.api_key}
+        if query.language:
+            params["language"] = query.language
+        if query.country_codes:
+            params["components"] = "|".join(
+                f"country:{c.upper()}" for c in query.country_codes
+            )
+
+        body = await self.fetch(self.URL, params, {})
+        status = body.get("status") if isinstance(body, dict) else None
+        if status == "ZERO_RESULTS":
+            return []
+        if status != "OK":
+            message = body.get("error_message", "") if isinstance(body, dict) else ""
+            error = GeocodingRateLimitError if status in self._QUOTA_STATUSES else GeocodingProviderError
+            raise error(f"Google geocoding failed: {status} {message}".strip())
+
+        results = body.get("results", [])
+        if not isinstance(results, list):
+            raise GeocodingProviderError("Unexpected Google response")
+        return _parse_candidates(self.name, results[:query.limit], self._candidate)
+
+    def _candidate(self, item: Dict[str, Any]) -> GeocodeCandidate:
+        geometry = item.get("geometry") or {}
+        location = geometry.get("location") or {}
+
+        components = {}
+        country_code = None
+        for component in item.get("address_components", []):
+            for component_type in component.get("types", []):
+                name = self._COMPONENT_TYPES.get(component_type)
+                if name and name not in components:
+                    components[name] = component.get("long_name")
+                if component_type == "country":
+                    country_code = component.get("short_name")
+
+        confidence = self._LOCATION_TYPE_CONFIDENCE.get(
+            geometry.get("location_type"), 0.4
+        )
+        if item.get("partial_match"):
+            confidence *= 0.8
+
+        bbox = None
+        viewport = geometry.get("viewport")
+        if viewport:
+            bbox = [
+                viewport["southwest"]["lng"], viewport["southwest"]["lat"],
+                viewport["northeast"]["lng"], viewport["northeast"]["lat"],
+            ]
+
+        return GeocodeCandidate(
+            formatted_address=item.get("formatted_address", ""),
+            latitude=float(location["lat"]),
+            longitude=float(location["lng"]),
+            confidence=round(confidence, 3),
+            provider=self.name,
+            country_code=country_code,
+            components=components,
+            bbox=bbox,
+        )
+
+
+class DailyBudget:
+    """Cap on requests sent to a provider per UTC day."""
+
+    def __init__(self, limit: int, clock: Callable[[], float] = time.time):
+        """Initialize budget.
+
+        Args:
+            limit: Requests allowed per UTC day
+            clock: Wall clock (Unix seconds), injectable for testing
+        """
+        self.limit = limit
+        self.clock = clock
+        self._day: Optional[str] = None
+        self._used = 0
+
+    def _roll_over(self) -> None:
+        day = datetime.fromtimestamp(self.clock(), tz=timezone.utc).date().isoformat()
+        if day != self._day:
+            self._day = day
+            self._used = 0
+
+    @property
+    def used(self) -> int:
+        """Requests sent today."""
+        self._roll_over()
+        return self._used
+
+    @property
+    def exhausted(self) -> bool:
+        """True once today's requests reach the limit."""
+        return self.used >= self.limit
+
+    def consume(self) -> None:
+        """Count one request against today's budget."""
+        self._roll_over()
+        self._used += 1
+
+
+class CircuitBreaker:
+    """Stops calling a provider after repeated failures.
+
+    After `failure_threshold` consecutive failures (or one rate-limit
+    response) the circuit opens and the provider is skipped for the
+    cooldown. A single trial request is then let through: success closes
+    the circuit, failure reopens it.
+    """
+
+    CLOSED = "closed"
+    OPEN = "open"
+    HALF_OPEN = "half_open"
+
+    def __init__(
+        self,
+        failure_threshold: int = 5,
+        cooldown_seconds: float = 60.0,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        """Initialize circuit breaker.
+
+        Args:
+            failure_threshold: Consecutive failures that open the circuit
+            cooldown_seconds: How long the circuit stays open
+            clock: Monotonic clock (seconds), injectable for testing
+        """
+        self.failure_threshold = max(1, failure_threshold)
+        self.cooldown_seconds = cooldown_seconds
+        self.clock = clock
+        self.failures = 0
+        self._open_until: Optional[float] = None
+        self._trial_in_flight = False
+
+    @property
+    def state(self) -> str:
+        """Current circuit state."""
+        if self._open_until is None:
+            return self.CLOSED
+        if self.clock() < self._open_until:
+            return self.OPEN
+        return self.HALF_OPEN
+
+    def allow(self) -> bool:
+        """Whether a request may be sent now (claims the half-open trial)."""
+        state = self.state
+        if state == self.CLOSED:
+            return True
+        if state == self.HALF_OPEN and not self._trial_in_flight:
+            self._trial_in_flight = True
+            return True
+        return False
+
+    def record_success(self) -> None:
+        """Close the circuit."""
+        self.failures = 0
+        self._open_until = None
+        self._trial_in_flight = False
+
+    def record_failure(self, cooldown_seconds: Optional[float] = None) -> None:
+        """Count a failure, opening the circuit when warranted.
+
+        Args:
+            cooldown_seconds: Open immediately for this long (rate limits)
+        """
+        self.failures += 1
+        trial_failed = self._trial_in_flight
+        self._trial_in_flight = False
+        if cooldown_seconds is not None or trial_failed or self.failures >= self.failure_threshold:
+            cooldown = self.cooldown_seconds if cooldown_seconds is None else cooldown_seconds
+            self._open_until = self.clock() + cooldown
+
+
+@dataclass
+class ProviderStatus:
+    """Health and budget of one provider in the chain."""
+    name: str
+    circuit: str
+    consecutive_failures: int
+    daily_budget: Optional[int] = None
+    used_today: Optional[int] = None
+
+
+class ProviderChain:
+    """Tries providers in order until one returns candidates."""
+
+    def __init__(
+        self,
+        providers: Sequence[GeocodingProvider],
+        daily_budgets: Optional[Dict[str, int]] = None,
+        failure_threshold: int = 5,
+        cooldown_seconds: float = 60.0,
+        clock: Callable[[], float] = time.monotonic,
+        wall_clock: Callable[[], float] = time.time,
+    ):
+        """Initialize provider chain.
+
+        Args:
+            providers: Providers in priority order
+            daily_budgets: Provider name -> requests allowed per UTC day
+                (providers without an entry are unlimited)
+            failure_threshold: Consecutive failures that open a provider's circuit
+            cooldown_seconds: How long an open circuit skips its provider
+            clock: Monotonic clock for circuit breakers (testing)
+            wall_clock: Wall clock for daily budgets (testing)
+        """
+        self.providers = list(providers)
+        self.cooldown_seconds = cooldown_seconds
+        self._breakers = {
+            provider.name: CircuitBreaker(failure_threshold, cooldown_seconds, clock)
+            for provider in self.providers
+        }
+        self._budgets = {
+            name: DailyBudget(limit, wall_clock)
+            for name, limit in (daily_budgets or {}).items()
+        }
+
+    async def geocode(self, query: GeocodeQuery) -> GeocodeResult:
+        """Geocode with failover.
+
+        A provider that errors, rate-limits or returns no candidates hands
+        over to the next one. Providers whose circuit is open or whose daily
+        budget is spent are skipped. Candidates are sorted by confidence.
+
+        Args:
+            query: Request parameters
+
+        Returns:
+            GeocodeResult (empty when every provider answered with nothing)
+
+        Raises:
+            ValueError: If the query is empty or the limit is invalid
+            GeocodingProviderError: If every provider failed or was skipped
+        """
+        if not query.query or not query.query.strip():
+            raise ValueError("Geocoding query must not be empty")
+        if query.limit < 1:
+            raise ValueError("limit must be at least 1")
+        if not self.providers:
+            raise GeocodingProviderError("No geocoding providers configured")
+
+        errors = []
+        for provider in self.providers:
+            budget = self._budgets.get(provider.name)
+            breaker = self._breakers[provider.name]
+            if budget is not None and budget.exhausted:
+                errors.append(f"{provider.name}: daily budget of {budget.limit} requests spent")
+                continue
+            if not breaker.allow():
+                errors.append(f"{provider.name}: circuit open after repeated failures")
+                continue
+            if budget is not None:
+                budget.consume()
+
+            try:
+                candidates = await provider.geocode(query)
+            except GeocodingRateLimitError as e:
+                logger.warning(f"Geocoding provider '{provider.name}' rate limited: {str(e)}")
+                breaker.record_failure(
+                    e.retry_after if e.retry_after is not None else self.cooldown_seconds
+                )
+                errors.append(f"{provider.name}: {str(e)}")
+                continue
+            except GeocodingProviderError as e:
+                logger.warning(f"Geocoding provider '{provider.name}' failed: {str(e)}")
+                breaker.record_failure()
+                errors.append(f"{provider.name}: {str(e)}")
+                continue
+            except Exception:
+                # Release a half-open trial before the error propagates
+                breaker.record_failure()
+                raise
+
+            breaker.record_success()
+            if candidates:
+                candidates.sort(key=lambda c: c.confidence, reverse=True)
+                return GeocodeResult(
+                    query=query.query,
+                    provider=provider.name,
+                    candidates=candidates[:query.limit],
+                )
+
+        if len(errors) == len(self.providers):
+            raise GeocodingProviderError(
+                "All geocoding providers failed: " + "; ".join(errors)
+            )
+        return GeocodeResult(query=query.query, provider=None, candidates=[])
+
+    def status(self) -> List[ProviderStatus]:
+        """Circuit state and budget usage of every provider, in chain order."""
+        statuses = []
+        for provider in self.providers:
+            breaker = self._breakers[provider.name]
+            budget = self._budgets.get(provider.name)
+            statuses.append(ProviderStatus(
+                name=provider.name,
+                circuit=breaker.state,
+                consecutive_failures=breaker.failures,
+                daily_budget=budget.limit if budget else None,
+                used_today=budget.used if budget else None,
+            ))
+        return statuses
+
+
+def parse_daily_budgets(raw: str) -> Dict[str, int]:
+    """Parse GEOCODING_DAILY_BUDGETS ("nominatim:10000,google:2500").
+
+    Malformed entries are logged and ignored.
+
+    Args:
+        raw: Comma-separated provider:limit pairs
+
+    Returns:
+        dict of provider name -> daily request limit
+    """
+    budgets = {}
+    for entry in (e.strip() for e in raw.split(",")):
+        if not entry:
+            continue
+        name, _, limit = entry.partition(":")
+        try:
+            budgets[name.strip().lower()] = max(0, int(limit))
+        except ValueError:
+            logger.warning(f"Ignoring malformed geocoding budget entry: {entry}")
+    return budgets
+
+
+def build_provider_chain(config) -> ProviderChain:
+    """Create the provider chain from GEOCODING_PROVIDERS and its budget settings.
+
+    Providers that are listed but not configured (e.g., Google without an
+    API key) are logged and skipped.
+
+    Args:
+        config: Application configuration
+
+    Returns:
+        ProviderChain
+    """
+    providers: List[GeocodingProvider] = []
+    for name in (n.strip().lower() for n in config.geocoding_providers.split(",")):
+        if not name:
+            continue
+        if name == NominatimProvider.name:
+            providers.append(NominatimProvider(
+                base_url=config.nominatim_url,
+                user_agent=config.nominatim_user_agent,
+                email=config.nominatim_email or None,
+            ))
+        elif name == GoogleGeocodingProvider.name:
+            if not config.google_geocoding_api_key:
+                logger.warning("Google geocoding listed without GOOGLE_GEOCODING_API_KEY")
+                continue
+            providers.append(GoogleGeocodingProvider(config.google_geocoding_api_key))
+        else:
+            logger.warning(f"Unknown geocoding provider: {name}")
+    return ProviderChain(
+        providers,
+        daily_budgets=parse_daily_budgets(config.geocoding_daily_budgets),
+        failure_threshold=config.geocoding_circuit_failure_threshold,
+        cooldown_seconds=config.geocoding_circuit_cooldown_seconds,
+    )
+
+
+# Global provider chain (built lazily from configuration)
+_provider_chain: Optional[ProviderChain] = None
+_provider_chain_generation = 0
+
+
+def get_provider_chain() -> ProviderChain:
+    """Get the global geocoding provider chain.
+
+    When secrets rotate, the providers take the new API keys in place, so
+    daily budgets and circuit breakers carry on.
+    """
+    global _provider_chain, _provider_chain_generation
+    from src.config import get_config
+    from src.services.secrets_service import secrets_generation
+
+    generation = secrets_generation()
+    if _provider_chain is None:
+        _provider_chain_generation = generation
+        _provider_chain = build_provider_chain(get_config())
+    elif _provider_chain_generation != generation:
+        _provider_chain_generation = generation
+        api_key = get_config().google_geocoding_api_key
+        for provider in _provider_chain.providers:
+            if isinstance(provider, GoogleGeocodingProvider) and api_key:
+                provider.api_key = api_key
+    return _provider_chain
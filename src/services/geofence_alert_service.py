@@ -0,0 +1,306 @@
+"""Geofence enter, exit and dwell alerts for tracked entities.
+
+Each position report of an entity is matched against the stored geofences
+and compared with the entity's last confirmed membership of each fence. A
+change of membership is held as pending until it has persisted for
+GEOFENCE_ALERT_DEBOUNCE_SECONDS, so that GPS jitter along a boundary does
+not produce enter/exit storms; the alert is then dated to when the change
+started. An entity that has stayed inside a fence for
+GEOFENCE_ALERT_DWELL_SECONDS raises one dwell alert per stay (0 disables
+dwell alerts). Reports older than the entity's last report near a fence
+are ignored.
+"""
+
+import logging
+import uuid
+from dataclasses import dataclass
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import GeofenceAlert, GeofenceEntityState
+from src.pagination import Page, keyset_page
+from src.services.erasure_service import ErasureService
+from src.services.geofence_service import GeofenceEngineCache, get_geofence_engine_cache
+
+logger = logging.getLogger(__name__)
+
+EVENT_ENTER = "enter"
+EVENT_EXIT = "exit"
+EVENT_DWELL = "dwell"
+
+# Webhook event type of an alert ("geofence.enter")
+WEBHOOK_EVENT_PREFIX = "geofence."
+
+
+def _utc(value: datetime) -> datetime:
+    """Naive UTC datetime as stored in the database."""
+    if value.tzinfo is not None:
+        value = value.astimezone(timezone.utc).replace(tzinfo=None)
+    return value
+
+
+@dataclass
+class GeofenceAlertEvent:
+    """Confirmed geofence event of an entity."""
+    alert_id: str
+    entity_id: str
+    geofence_id: str
+    event: str  # enter, exit or dwell
+    occurred_at: datetime  # UTC
+    latitude: float
+    longitude: float
+
+    @property
+    def webhook_event(self) -> str:
+        """Event type delivered to webhooks."""
+        return WEBHOOK_EVENT_PREFIX + self.event
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses and webhook payloads."""
+        return {
+            "alert_id": self.alert_id,
+            "entity_id": self.entity_id,
+            "geofence_id": self.geofence_id,
+            "event": self.event,
+            "occurred_at": self.occurred_at.replace(tzinfo=timezone.utc),
+            "latitude": self.latitude,
+            "longitude": self.longitude,
+        }
+
+
+@dataclass
+class PositionResult:
+    """Outcome of one position report."""
+    entity_id: str
+    geofence_ids: List[str]  # Fences the position lies in
+    alerts: List[GeofenceAlertEvent]
+    stale: bool = False  # Older than the entity's last report (not evaluated)
+
+
+def _to_event(row: GeofenceAlert) -> GeofenceAlertEvent:
+    return GeofenceAlertEvent(
+        alert_id=row.alert_id,
+        entity_id=row.entity_id,
+        geofence_id=row.geofence_id,
+        event=row.event,
+        occurred_at=row.occurred_at,
+        latitude=row.latitude,
+        longitude=row.longitude,
+    )
+
+
+class GeofenceAlertService:
+    """Tracks entity geofence membership and raises alerts on changes."""
+
+    def __init__(
+        self,
+        session: Session,
+        debounce_seconds: Optional[float] = None,
+        dwell_seconds: Optional[float] = None,
+        engine_cache: Optional[GeofenceEngineCache] = None,
+    ):
+        """Initialize geofence alert service.
+
+        Args:
+            session: SQLAlchemy database session
+            debounce_seconds: How long a membership change must persist
+                before it is alerted (default GEOFENCE_ALERT_DEBOUNCE_SECONDS)
+            dwell_seconds: Stay after which a dwell alert is raised, 0 to
+                disable (default GEOFENCE_ALERT_DWELL_SECONDS)
+            engine_cache: Geofence engine holder (default: the global one)
+        """
+        from src.config import get_config
+
+        config = get_config()
+        self.session = session
+        self.debounce = timedelta(
+            seconds=debounce_seconds if debounce_seconds is not None else config.geofence_alert_debounce_seconds
+        )
+        self.dwell = timedelta(
+            seconds=dwell_seconds if dwell_seconds is not None else config.geofence_alert_dwell_seconds
+        )
+        self.engine_cache = (
+            engine_cache if engine_cache is not None else get_geofence_engine_cache()
+        )
+
+    def _alert(
+        self, tenant_id: str, entity_id: str, geofence_id: str, event: str,
+        occurred_at: datetime, latitude: float, longitude: float,
+    ) -> GeofenceAlertEvent:
+        row = GeofenceAlert(
+            alert_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            entity_id=entity_id,
+            geofence_id=geofence_id,
+            event=event,
+            occurred_at=occurred_at,
+            latitude=latitude,
+            longitude=longitude,
+        )
+        self.session.add(row)
+        return _to_event(row)
+
+    def observe(
+        self,
+        tenant_id: str,
+        entity_id: str,
+        latitude: float,
+        longitude: float,
+        observed_at: Optional[datetime] = None,
+    ) -> PositionResult:
+        """Process a position report of an entity.
+
+        Erased entities are matched against the fences but not tracked.
+
+        Args:
+            tenant_id: Owning tenant
+            entity_id: Tracked entity (vehicle, asset, user, ...)
+            latitude: Latitude in degrees
+            longitude: Longitude in degrees
+            observed_at: Time of the position (default: now)
+
+        Returns:
+            PositionResult with the fences containing the position and the
+            alerts it confirmed
+
+        Raises:
+            ValueError: If the entity ID or coordinate is invalid, or the
+                state cannot be stored
+        """
+        entity_id = (entity_id or "").strip()
+        if not entity_id:
+            raise ValueError("entity_id must not be empty")
+        if not -90 <= latitude <= 90 or not -180 <= longitude <= 180:
+            raise ValueError(f"Coordinate out of range: ({latitude}, {longitude})")
+        now = _utc(observed_at) if observed_at is not None else datetime.utcnow()
+
+        inside_ids = self.engine_cache.get(self.session).match(latitude, longitude)
+        if ErasureService(self.session).is_erased(tenant_id, entity_id):
+            return PositionResult(entity_id, inside_ids, [])
+        states = {
+            state.geofence_id: state
+            for state in self.session.query(GeofenceEntityState).filter(
+                GeofenceEntityState.tenant_id == tenant_id,
+                GeofenceEntityState.entity_id == entity_id,
+            )
+        }
+        if any(state.last_seen_at > now for state in states.values()):
+            logger.debug(f"Ignoring out-of-order position of {entity_id} at {now}")
+            return PositionResult(entity_id, inside_ids, [], stale=True)
+
+        alerts: List[GeofenceAlertEvent] = []
+        inside_set = set(inside_ids)
+        try:
+            for geofence_id in sorted(inside_set | set(states)):
+                inside = geofence_id in inside_set
+                state = states.get(geofence_id)
+                if state is None:
+                    state = GeofenceEntityState(
+                        tenant_id=tenant_id,
+                        entity_id=entity_id,
+                        geofence_id=geofence_id,
+                        inside=False,
+                        dwell_alerted=False,
+                    )
+                    self.session.add(state)
+                state.last_seen_at = now
+
+                if inside == state.inside:
+                    # Back to the confirmed state before the debounce expired
+                    state.pending_inside = None
+                    state.pending_since = None
+                else:
+                    if state.pending_inside is None:
+                        state.pending_inside = inside
+                        state.pending_since = now
+                    if now - state.pending_since >= self.debounce:
+                        changed_at = state.pending_since
+                        state.inside = inside
+                        state.entered_at = changed_at if inside else None
+                        state.dwell_alerted = False
+                        state.pending_inside = None
+                        state.pending_since = None
+                        alerts.append(self._alert(
+                            tenant_id, entity_id, geofence_id,
+                            EVENT_ENTER if inside else EVENT_EXIT,
+                            changed_at, latitude, longitude,
+                        ))
+
+                if (
+                    state.inside
+                    and self.dwell.total_seconds() > 0
+                    and not state.dwell_alerted
+                    and now - state.entered_at >= self.dwell
+                ):
+                    state.dwell_alerted = True
+                    alerts.append(self._alert(
+                        tenant_id, entity_id, geofence_id, EVENT_DWELL,
+                        state.entered_at + self.dwell, latitude, longitude,
+                    ))
+
+                if not state.inside and state.pending_inside is None:
+                    # Confirmed outside with nothing pending; nothing left to track
+                    self.session.delete(state)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store geofence state: {str(e)}")
+
+        for alert in alerts:
+            logger.info(f"Geofence {alert.event}: {entity_id} / {alert.geofence_id} at {alert.occurred_at}")
+        return PositionResult(entity_id, inside_ids, alerts)
+
+    def alerts(
+        self,
+        tenant_id: str,
+        entity_id: Optional[str] = None,
+        geofence_id: Optional[str] = None,
+        since: Optional[datetime] = None,
+        limit: int = 100,
+    ) -> List[GeofenceAlertEvent]:
+        """Stored alerts of a tenant, newest first.
+
+        Args:
+            tenant_id: Owning tenant
+            entity_id: Only alerts of this entity
+            geofence_id: Only alerts of this geofence
+            since: Only alerts that occurred at or after this time
+            limit: Most alerts returned
+
+        Returns:
+            list of GeofenceAlertEvent
+        """
+        return self.alert_page(tenant_id, entity_id, geofence_id, since, limit).items
+
+    def alert_page(
+        self,
+        tenant_id: str,
+        entity_id: Optional[str] = None,
+        geofence_id: Optional[str] = None,
+        since: Optional[datetime] = None,
+        limit: int = 100,
+        cursor: Optional[str] = None,
+    ) -> Page[GeofenceAlertEvent]:
+        """One page of a tenant's stored alerts, newest first.
+
+        Args:
+            tenant_id: Owning tenant
+            entity_id: Only alerts of this entity
+            geofence_id: Only alerts of this geofence
+            since: Only alerts that occurred at or after this time
+            limit: Most alerts on the page
+            cursor: next_cursor of the previous page
+
+        Raises:
+            ValueError: If the cursor is invalid
+        """
+        query = self.session.query(GeofenceAlert).filter(GeofenceAlert.tenant_id == tenant_id)
+        if entity_id is not None:
+            query = query.filter(GeofenceAlert.entity_id == entity_id)
+        if geofence_id is not None:
+            query = query.filter(GeofenceAlert.geofence_id == geofence_id)
+        if since is not None:
+            query = query.filter(GeofenceAlert.occurred_at >= _utc(since))
+        return keyset_page(query, GeofenceAlert.occurred_at, GeofenceAlert.id, limit, cursor, _to_event)
@@ -0,0 +1,447 @@
+"""Geofence storage and membership tests.
+
+Each geofence stores an S2 cell covering computed at creation time. A
+point is first checked against the covering: outside the covering means
+outside the fence, inside an interior cell means inside, and only points
+in boundary cells need the exact point-in-polygon test.
+
+Compiled fences are cached per geofence (least recently used evicted
+first) and their polygon is only parsed the first time a point lands in
+a boundary cell.
+
+The geofence engine evaluates a point against every stored fence: an
+R-tree over fence bounding boxes picks the candidates, then each runs the
+membership test above.
+"""
+
+import logging
+import threading
+import uuid
+from collections import OrderedDict
+from dataclasses import dataclass, field
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from sqlalchemy import func
+from sqlalchemy.orm import Session
+
+from src.models.database_models import Geofence, GeofenceEntityState
+from src.pagination import Page, keyset_page
+from src.spatial import s2
+from src.spatial.geometry import (
+    BoundingBox,
+    Geometry,
+    check_lonlat,
+    geojson_bbox,
+    geometry_from_geojson,
+)
+from src.spatial.rtree import RTree
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass
+class CompiledGeofence:
+    """In-memory geofence ready for fast membership tests."""
+    geofence_id: str
+    name: str
+    covering: s2.CellUnion
+    interior: s2.CellUnion
+    geometry_geojson: Dict[str, Any] = field(repr=False)
+    updated_at: Optional[datetime] = None
+    _geometry: Optional[Geometry] = field(default=None, init=False, repr=False)
+    _bbox: Optional[BoundingBox] = field(default=None, init=False, repr=False)
+
+    @classmethod
+    def from_model(
+        cls, geofence: Geofence, geometry: Optional[Geometry] = None
+    ) -> "CompiledGeofence":
+        """Compile a stored geofence.
+
+        Args:
+            geofence: Stored geofence
+            geometry: Already-parsed polygon, if at hand; otherwise it is
+                parsed on first use
+        """
+        compiled = cls(
+            geofence_id=geofence.geofence_id,
+            name=geofence.name,
+            covering=s2.CellUnion.from_tokens(geofence.s2_covering),
+            interior=s2.CellUnion.from_tokens(geofence.s2_interior),
+            geometry_geojson=geofence.geometry,
+            updated_at=geofence.updated_at,
+        )
+        compiled._geometry = geometry
+        return compiled
+
+    @property
+    def geometry(self) -> Geometry:
+        """Parsed polygon, built lazily."""
+        if self._geometry is None:
+            self._geometry = geometry_from_geojson(self.geometry_geojson)
+        return self._geometry
+
+    @property
+    def bbox(self) -> BoundingBox:
+        """Bounding box of the polygon (read from GeoJSON without parsing it)."""
+        if self._bbox is None:
+            if self._geometry is not None:
+                self._bbox = self._geometry.bbox
+            else:
+                self._bbox = geojson_bbox(self.geometry_geojson)
+        return self._bbox
+
+    def contains(self, latitude: float, longitude: float) -> bool:
+        """True if the coordinate is inside the geofence."""
+        prefiltered = s2.classify_point(self.covering, self.interior, latitude, longitude)
+        if prefiltered is not None:
+            return prefiltered
+        return self.geometry.contains(longitude, latitude)
+
+
+class CompiledGeofenceCache:
+    """Thread-safe LRU cache of compiled geofences keyed by geofence ID."""
+
+    def __init__(self, max_size: int = 1024):
+        """Initialize cache.
+
+        Args:
+            max_size: Maximum number of compiled geofences kept in memory
+        """
+        self.max_size = max(1, max_size)
+        self._fences: "OrderedDict[str, CompiledGeofence]" = OrderedDict()
+        self._lock = threading.Lock()
+
+    def get(self, geofence: Geofence) -> CompiledGeofence:
+        """Compiled form of a stored geofence, compiling it on a miss.
+
+        Entries compiled from an older version of the geofence (different
+        updated_at) are replaced.
+        """
+        with self._lock:
+            compiled = self._fences.get(geofence.geofence_id)
+            if compiled is not None and compiled.updated_at == geofence.updated_at:
+                self._fences.move_to_end(geofence.geofence_id)
+                return compiled
+
+        compiled = CompiledGeofence.from_model(geofence)
+        self.put(compiled)
+        return compiled
+
+    def put(self, compiled: CompiledGeofence) -> None:
+        """Cache a compiled geofence, evicting the least recently used if full."""
+        with self._lock:
+            self._fences[compiled.geofence_id] = compiled
+            self._fences.move_to_end(compiled.geofence_id)
+            while len(self._fences) > self.max_size:
+                self._fences.popitem(last=False)
+
+    def invalidate(self, geofence_id: str) -> None:
+        """Drop a geofence's compiled form."""
+        with self._lock:
+            self._fences.pop(geofence_id, None)
+
+    def __len__(self) -> int:
+        return len(self._fences)
+
+
+class GeofenceEngine:
+    """Evaluates coordinates against many geofences at once."""
+
+    def __init__(self, fences: Sequence[CompiledGeofence]):
+        """Index geofences by bounding box.
+
+        Args:
+            fences: Compiled geofences to evaluate
+        """
+        self._index: RTree[CompiledGeofence] = RTree([(fence.bbox, fence) for fence in fences])
+
+    def __len__(self) -> int:
+        return len(self._index)
+
+    def match(self, latitude: float, longitude: float) -> List[str]:
+        """IDs of all geofences containing a coordinate.
+
+        Args:
+            latitude: Latitude in degrees (-90 to 90)
+            longitude: Longitude in degrees (-180 to 180)
+
+        Returns:
+            Matching geofence IDs, sorted
+
+        Raises:
+            ValueError: If the coordinate is out of range
+        """
+        _validate_point(latitude, longitude)
+        return sorted(
+            fence.geofence_id
+            for fence in self._index.query_point(longitude, latitude)
+            if fence.contains(latitude, longitude)
+        )
+
+    def intersecting(self, bbox: BoundingBox) -> List[CompiledGeofence]:
+        """Geofences whose bounding box intersects a box, by geofence ID."""
+        return sorted(self._index.query_bbox(bbox), key=lambda fence: fence.geofence_id)
+
+
+class GeofenceEngineCache:
+    """Holds the engine for the current set of stored geofences."""
+
+    def __init__(self):
+        self._engine: Optional[GeofenceEngine] = None
+        self._version: Optional[Tuple[Any, ...]] = None
+        self._lock = threading.Lock()
+
+    def get(self, session: Session) -> GeofenceEngine:
+        """Engine over every stored geofence, rebuilt when fences change.
+
+        A change is detected from the geofence count and latest updated_at,
+        so creates, deletes and updates all trigger a rebuild.
+        """
+        version = tuple(
+            session.query(func.count(Geofence.id), func.max(Geofence.updated_at)).one()
+        )
+        with self._lock:
+            if self._engine is not None and self._version == version:
+                return self._engine
+
+        engine = GeofenceEngine(
+            [CompiledGeofence.from_model(g) for g in session.query(Geofence).all()]
+        )
+        with self._lock:
+            self._engine, self._version = engine, version
+        logger.info(f"Built geofence engine over {len(engine)} geofences")
+        return engine
+
+    def invalidate(self) -> None:
+        """Force a rebuild on next use."""
+        with self._lock:
+            self._engine = self._version = None
+
+
+def _validate_point(latitude: float, longitude: float) -> None:
+    if not -90 <= latitude <= 90:
+        raise ValueError(f"Latitude out of range: {latitude}")
+    if not -180 <= longitude <= 180:
+        raise ValueError(f"Longitude out of range: {longitude}")
+
+
+class GeofenceService:
+    """Creates, updates, deletes and queries stored geofences."""
+
+    def __init__(
+        self,
+        session: Session,
+        max_cells: Optional[int] = None,
+        max_level: Optional[int] = None,
+        cache: Optional[CompiledGeofenceCache] = None,
+        engine_cache: Optional[GeofenceEngineCache] = None,
+    ):
+        """Initialize geofence service.
+
+        Args:
+            session: SQLAlchemy database session
+            max_cells: S2 covering cell budget (default GEOFENCE_S2_MAX_CELLS)
+            max_level: Finest S2 covering level (default GEOFENCE_S2_MAX_LEVEL)
+            cache: Compiled geofence cache (default: the global cache)
+            engine_cache: Geofence engine holder (default: the global one)
+        """
+        if max_cells is None or max_level is None:
+            from src.config import get_config
+
+            config = get_config()
+            max_cells = max_cells if max_cells is not None else config.geofence_s2_max_cells
+            max_level = max_level if max_level is not None else config.geofence_s2_max_level
+        self.session = session
+        self.max_cells = max_cells
+        self.max_level = max_level
+        self.cache = cache if cache is not None else get_compiled_geofence_cache()
+        self.engine_cache = (
+            engine_cache if engine_cache is not None else get_geofence_engine_cache()
+        )
+
+    def create_geofence(
+        self,
+        name: str,
+        geometry: Dict[str, Any],
+        properties: Optional[Dict[str, Any]] = None,
+    ) -> Geofence:
+        """Validate, cover and store a geofence.
+
+        Rings are stored with RFC 7946 winding (exterior counterclockwise,
+        holes clockwise) whatever the input orientation.
+
+        Args:
+            name: Display name
+            geometry: GeoJSON Polygon or MultiPolygon in WGS84
+            properties: Arbitrary metadata
+
+        Returns:
+            Stored Geofence
+
+        Raises:
+            ValueError: If the geometry is invalid or cannot be stored
+        """
+        shape = geometry_from_geojson(geometry).oriented()
+        check_lonlat(shape)
+        covering = s2.cover_geometry(
+            shape, max_cells=self.max_cells, max_level=self.max_level
+        )
+
+        geofence = Geofence(
+            geofence_id=str(uuid.uuid4()),
+            name=name,
+            geometry=shape.to_geojson(),
+            properties=properties,
+            s2_covering=covering.tokens(),
+            s2_interior=covering.interior_tokens(),
+        )
+        try:
+            self.session.add(geofence)
+            self.session.commit()
+            self.session.refresh(geofence)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store geofence: {str(e)}")
+
+        self.cache.put(CompiledGeofence.from_model(geofence, shape))
+
+        logger.info(
+            f"Created geofence {geofence.geofence_id} with {len(covering.cells)} S2 cells "
+            f"({len(covering.interior)} interior)"
+        )
+        return geofence
+
+    def update_geofence(
+        self,
+        geofence: Geofence,
+        name: str,
+        geometry: Dict[str, Any],
+        properties: Optional[Dict[str, Any]] = None,
+    ) -> Geofence:
+        """Replace a stored geofence's name, geometry and metadata.
+
+        The covering is recomputed and cached compiled forms are replaced,
+        so membership tests see the new polygon at once.
+
+        Args:
+            geofence: Stored geofence
+            name: Display name
+            geometry: GeoJSON Polygon or MultiPolygon in WGS84
+            properties: Arbitrary metadata
+
+        Returns:
+            Updated Geofence
+
+        Raises:
+            ValueError: If the geometry is invalid or cannot be stored
+        """
+        shape = geometry_from_geojson(geometry).oriented()
+        check_lonlat(shape)
+        covering = s2.cover_geometry(
+            shape, max_cells=self.max_cells, max_level=self.max_level
+        )
+
+        geofence.name = name
+        geofence.geometry = shape.to_geojson()
+        geofence.properties = properties
+        geofence.s2_covering = covering.tokens()
+        geofence.s2_interior = covering.interior_tokens()
+        try:
+            self.session.commit()
+            self.session.refresh(geofence)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store geofence: {str(e)}")
+
+        self.cache.put(CompiledGeofence.from_model(geofence, shape))
+        self.engine_cache.invalidate()
+        logger.info(f"Updated geofence {geofence.geofence_id} ({len(covering.cells)} S2 cells)")
+        return geofence
+
+    def delete_geofence(self, geofence_id: str) -> bool:
+        """Remove a geofence and the tracked entities' membership of it.
+
+        Stored alerts are history and are kept.
+
+        Returns:
+            True if it existed
+        """
+        geofence = self.get_geofence(geofence_id)
+        if geofence is None:
+            return False
+        self.session.query(GeofenceEntityState).filter(
+            GeofenceEntityState.geofence_id == geofence_id
+        ).delete(synchronize_session=False)
+        self.session.delete(geofence)
+        self.session.commit()
+        self.cache.invalidate(geofence_id)
+        self.engine_cache.invalidate()
+        logger.info(f"Deleted geofence {geofence_id}")
+        return True
+
+    def geofence_page(self, limit: int = 100, cursor: Optional[str] = None) -> Page[Geofence]:
+        """Stored geofences, newest first, a page at a time.
+
+        Raises:
+            ValueError: If the cursor is invalid
+        """
+        return keyset_page(
+            self.session.query(Geofence), Geofence.created_at, Geofence.id, limit, cursor, lambda g: g
+        )
+
+    def get_geofence(self, geofence_id: str) -> Optional[Geofence]:
+        """Fetch a geofence by its public ID."""
+        return (
+            self.session.query(Geofence)
+            .filter(Geofence.geofence_id == geofence_id)
+            .first()
+        )
+
+    def contains(self, geofence: Geofence, latitude: float, longitude: float) -> bool:
+        """Test whether a coordinate is inside a geofence.
+
+        Raises:
+            ValueError: If the coordinate is out of range
+        """
+        _validate_point(latitude, longitude)
+        return self.cache.get(geofence).contains(latitude, longitude)
+
+    def matching_geofences(self, latitude: float, longitude: float) -> List[str]:
+        """IDs of all stored geofences containing a coordinate.
+
+        Raises:
+            ValueError: If the coordinate is out of range
+        """
+        _validate_point(latitude, longitude)
+        return self.engine_cache.get(self.session).match(latitude, longitude)
+
+    def geofences_in_bbox(self, bbox: BoundingBox) -> List[CompiledGeofence]:
+        """Stored geofences whose bounding box intersects a box, by geofence ID."""
+        return self.engine_cache.get(self.session).intersecting(bbox)
+
+
+# Global compiled geofence cache (shared across requests)
+_compiled_geofence_cache: Optional[CompiledGeofenceCache] = None
+
+
+def get_compiled_geofence_cache() -> CompiledGeofenceCache:
+    """Get the global compiled geofence cache, sized from GEOFENCE_CACHE_SIZE."""
+    global _compiled_geofence_cache
+    if _compiled_geofence_cache is None:
+        from src.config import get_config
+
+        _compiled_geofence_cache = CompiledGeofenceCache(get_config().geofence_cache_size)
+    return _compiled_geofence_cache
+
+
+# Global geofence engine (rebuilt when stored geofences change)
+_geofence_engine_cache: Optional[GeofenceEngineCache] = None
+
+
+def get_geofence_engine_cache() -> GeofenceEngineCache:
+    """Get the global geofence engine holder."""
+    global _geofence_engine_cache
+    if _geofence_engine_cache is None:
+        _geofence_engine_cache = GeofenceEngineCache()
+    return _geofence_engine_cache
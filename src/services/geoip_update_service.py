@@ -0,0 +1,495 @@
+"""Background GeoIP dataset updater with checksum verification and hot swap.
+
+Polls a release source (MaxMind download service or an S3 bucket) for a new
+database build, downloads it next to the active file, verifies the SHA-256
+checksum, validates that it opens as an MMDB, atomically renames it into
+place and swaps the global reader without restarting the API. The outgoing
+build is kept in the snapshot store for historical lookups, and callbacks
+registered for activation run (the lookup cache drops the outgoing build's
+answers).
+"""
+
+import asyncio
+import hashlib
+import logging
+import os
+import shutil
+import tarfile
+import tempfile
+import time
+from dataclasses import dataclass
+from typing import Callable, List, Optional, Tuple
+
+from src.metrics import GEOIP_DATASET_LAST_CHECK, GEOIP_DATASET_UPDATES
+from src.services.mmdb_service import MMDBReader, set_mmdb_reader
+from src.services.snapshot_service import DatasetSnapshotStore, get_snapshot_store
+
+
+@dataclass
+class DatasetRelease:
+    """A downloadable dataset release advertised by a source."""
+    version: str
+    sha256: str
+    url: Optional[str] = None
+    archive: bool = False  # True for tar.gz archives containing the .mmdb
+
+
+class DatasetSource:
+    """Base class for GeoIP dataset release sources."""
+
+    async def latest_release(self) -> DatasetRelease:
+        """Fetch metadata for the newest available release.
+
+        Raises:
+            RuntimeError: If the source cannot be reached
+        """
+        raise NotImplementedError
+
+    async def download(self, release: DatasetRelease, dest_path: str) -> None:
+        """Download a release to a local path.
+
+        Raises:
+            RuntimeError: If the download fails
+        """
+        raise NotImplementedError
+
+
+class MaxMindSource(DatasetSource):
+    """MaxMind GeoIP download service (https://download.maxmind.com)."""
+
+    DOWNLOAD_URL = "https://download.maxmind.com/geoip/databases/{edition_id}/download"
+
+    def __init__(
+        self,
+        account_id: str,
+        license_key: str,
+        edition_id: str = "GeoLite2-City",
+        credentials: Optional[Callable[[], Tuple[str, str]]] = None,
+    ):
+        """Initialize MaxMind source.
+
+        Args:
+            account_id: MaxMind account ID
+            license_key: MaxMind license key
+            edition_id: Database edition (e.g., GeoLite2-City, GeoIP2-City)
+            credentials: Returns the current account ID and license key
+                before each request (e.g. rotated in the secrets backend);
+                None always uses account_id and license_key
+        """
+        self.account_id = account_id
+        self.license_key = license_key
+        self.edition_id = edition_id
+        self.credentials = credentials
+
+    def _auth(self):
+        import aiohttp
+
+        if self.credentials is not None:
+            self.account_id, self.license_key = self.credentials()
+        return aiohttp.BasicAuth(self.account_id, self.license_key)
+
+    def _url(self, suffix: str) -> str:
+        return self.DOWNLOAD_URL.format(edition_id=self.edition_id) + f"?suffix={suffix}"
+
+    async def latest_release(self) -> DatasetRelease:
+        """Read the published SHA-256 of the newest tar.gz build."""
+        import aiohttp
+
+        auth = self._auth()
+        try:
+            async with aiohttp.ClientSession(auth=auth) as session:
+                async with session.get(
+                    self._url("tar.gz.sha256"),
+                    timeout=aiohttp.ClientTimeout(total=30),
+                ) as response:
+                    if response.status != 200:
+                        raise RuntimeError(
+                            f"MaxMind checksum request failed: HTTP {response.status}"
+                        )
+                    body = await response.text()
+                    last_modified = response.headers.get("Last-Modified", "")
+        except aiohttp.ClientError as e:
+            raise RuntimeError(f"MaxMind checksum request failed: {str(e)}")
+
+        # Format: "<sha256>  GeoLite2-City_20260210.tar.gz"
+        parts = body.split()
+        if not parts:
+            raise RuntimeError("MaxMind checksum response was empty")
+        version = parts[1] if len(parts) > 1 else last_modified or parts[0]
+        return DatasetRelease(
+            version=version,
+            sha256=parts[0].lower(),
+            url=self._url("tar.gz"),
+            archive=True,
+        )
+
+    async def download(self, release: DatasetRelease, dest_path: str) -> None:
+        """Stream the release archive to dest_path."""
+        await http_download(release.url, dest_path, auth=self._auth())
+
+
+class S3Source(DatasetSource):
+    """Dataset releases published to an S3 bucket.
+
+    The object at `key` is the .mmdb file (or a .tar.gz when the key ends
+    with .tar.gz); its SHA-256 is read from `key + ".sha256"`.
+    """
+
+    def __init__(self, bucket: str, key: str, client=None):
+        """Initialize S3 source.
+
+        Args:
+            bucket: Bucket name
+            key: Object key of the dataset
+            client: Optional boto3 S3 client (created on demand)
+        """
+        self.bucket = bucket
+        self.key = key
+        self._client = client
+
+    def _get_client(self):
+        if self._client is None:
+            try:
+                import boto3
+            except ImportError:
+                raise RuntimeError("boto3 is required for the S3 dataset source")
+            self._client = boto3.client("s3")
+        return self._client
+
+    async def latest_release(self) -> DatasetRelease:
+        """Read the checksum object and the dataset's ETag as its version."""
+        return await asyncio.to_thread(self._latest_release_sync)
+
+    def _latest_release_sync(self) -> DatasetRelease:
+        client = self._get_client()
+        try:
+            head = client.head_object(Bucket=self.bucket, Key=self.key)
+            checksum_obj = client.get_object(Bucket=self.bucket, Key=self.key + ".sha256")
+            checksum = checksum_obj["Body"].read().decode("utf-8").split()[0]
+        except Exception as e:
+            raise RuntimeError(f"S3 release lookup failed: {str(e)}")
+        return DatasetRelease(
+            version=head.get("ETag", "").strip('"'),
+            sha256=checksum.lower(),
+            url=f"s3://{self.bucket}/{self.key}",
+            archive=self.key.endswith(".tar.gz"),
+        )
+
+    async def download(self, release: DatasetRelease, dest_path: str) -> None:
+        """Download the dataset object to dest_path."""
+        def _download():
+            try:
+                self._get_client().download_file(self.bucket, self.key, dest_path)
+            except Exception as e:
+                raise RuntimeError(f"S3 download failed: {str(e)}")
+
+        await asyncio.to_thread(_download)
+
+
+class LocalFileSource(DatasetSource):
+    """A dataset file already on local disk, such as an admin upload.
+
+    The release is the file itself: its SHA-256 is computed rather than
+    published, and gzip content is taken as a tar.gz archive.
+    """
+
+    GZIP_MAGIC = b"\x1f\x8b"
+
+    def __init__(self, path: str, version: Optional[str] = None):
+        """Initialize local source.
+
+        Args:
+            path: Path of the .mmdb file or tar.gz archive
+            version: Release label (default: the file name)
+        """
+        self.path = path
+        self.version = version or os.path.basename(path)
+
+    async def latest_release(self) -> DatasetRelease:
+        """Checksum the file."""
+        def _release() -> DatasetRelease:
+            try:
+                with open(self.path, "rb") as f:
+                    archive = f.read(2) == self.GZIP_MAGIC
+                return DatasetRelease(
+                    version=self.version,
+                    sha256=sha256_file(self.path),
+                    url=f"file:{self.path}",
+                    archive=archive,
+                )
+            except OSError as e:
+                raise RuntimeError(f"Cannot read dataset file: {str(e)}")
+
+        return await asyncio.to_thread(_release)
+
+    async def download(self, release: DatasetRelease, dest_path: str) -> None:
+        """Copy the file to dest_path."""
+        def _copy():
+            try:
+                shutil.copyfile(self.path, dest_path)
+            except OSError as e:
+                raise RuntimeError(f"Cannot copy dataset file: {str(e)}")
+
+        await asyncio.to_thread(_copy)
+
+
+async def http_download(url: str, dest_path: str, auth=None) -> None:
+    """Stream an HTTP(S) resource to a local file."""
+    import aiohttp
+
+    try:
+        async with aiohttp.ClientSession(auth=auth) as session:
+            async with session.get(
+                url, timeout=aiohttp.ClientTimeout(total=600)
+            ) as response:
+                if response.status != 200:
+                    raise RuntimeError(f"Download failed: HTTP {response.status}")
+                with open(dest_path, "wb") as f:
+                    async for chunk in response.content.iter_chunked(1 << 20):
+                        f.write(chunk)
+    except aiohttp.ClientError as e:
+        raise RuntimeError(f"Download failed: {str(e)}")
+
+
+def sha256_file(path: str) -> str:
+    """Compute the hex SHA-256 digest of a file."""
+    digest = hashlib.sha256()
+    with open(path, "rb") as f:
+        for chunk in iter(lambda: f.read(1 << 20), b""):
+            digest.update(chunk)
+    return digest.hexdigest()
+
+
+class GeoIPUpdateService:
+    """Keeps the active GeoIP dataset current by polling a release source."""
+
+    CHECKSUM_SUFFIX = ".sha256"
+
+    def __init__(
+        self,
+        source: DatasetSource,
+        database_path: str,
+        check_interval_seconds: int = 86400,
+        activate: Callable[[MMDBReader], object] = set_mmdb_reader,
+        snapshots: Optional[DatasetSnapshotStore] = None,
+        use_mmap: bool = True,
+    ):
+        """Initialize updater.
+
+        Args:
+            source: Release source to poll
+            database_path: Path of the active .mmdb file
+            check_interval_seconds: Polling interval
+            activate: Callback that makes a new reader active (default: global hot swap)
+            snapshots: Store that retains replaced builds (None keeps no history)
+            use_mmap: Memory-map the installed dataset (GEOIP_USE_MMAP)
+        """
+        self.source = source
+        self.database_path = database_path
+        self.check_interval_seconds = check_interval_seconds
+        self.activate = activate
+        self.snapshots = snapshots
+        self.use_mmap = use_mmap
+        self.logger = logging.getLogger(__name__)
+        self._activation_callbacks: List[Callable[[MMDBReader], None]] = []
+        self._running = False
+
+    def register_activation_callback(self, callback: Callable[[MMDBReader], None]) -> None:
+        """Register callback run after a new dataset is activated.
+
+        Args:
+            callback: Function that takes the newly active reader; its errors
+                are logged and do not fail the update
+        """
+        self._activation_callbacks.append(callback)
+
+    @property
+    def installed_checksum(self) -> Optional[str]:
+        """Checksum of the release currently installed, if recorded."""
+        try:
+            with open(self.database_path + self.CHECKSUM_SUFFIX) as f:
+                return f.read().strip() or None
+        except OSError:
+            return None
+
+    async def check_for_update(self) -> bool:
+        """Check the source and install a new release if one is available.
+
+        Returns:
+            True if a new dataset was installed and activated
+
+        Raises:
+            RuntimeError: If the release cannot be fetched, verified or installed
+        """
+        GEOIP_DATASET_LAST_CHECK.set(time.time())
+        try:
+            release = await self.source.latest_release()
+            if release.sha256 == self.installed_checksum:
+                self.logger.info(f"GeoIP dataset up to date ({release.version})")
+                GEOIP_DATASET_UPDATES.labels(result="unchanged").inc()
+                return False
+
+            self.logger.info(f"Installing GeoIP dataset release {release.version}")
+            await self._install(release)
+        except Exception:
+            GEOIP_DATASET_UPDATES.labels(result="failed").inc()
+            raise
+
+        GEOIP_DATASET_UPDATES.labels(result="updated").inc()
+        return True
+
+    async def _install(self, release: DatasetRelease) -> None:
+        """Download, verify, validate and atomically activate a release.
+
+        Hashing, extraction and opening readers run in worker threads: a
+        dataset is tens to hundreds of MB, and the event loop keeps serving lookups.
+        """
+        target_dir = os.path.dirname(os.path.abspath(self.database_path))
+        os.makedirs(target_dir, exist_ok=True)
+        # Stage in the target directory so os.replace() is an atomic rename
+        staging_dir = tempfile.mkdtemp(prefix=".geoip-update-", dir=target_dir)
+        try:
+            download_path = os.path.join(staging_dir, "download")
+            await self.source.download(release, download_path)
+
+            actual = await asyncio.to_thread(sha256_file, download_path)
+            if actual != release.sha256:
+                raise RuntimeError(
+                    f"Checksum mismatch for release {release.version}: "
+                    f"expected {release.sha256}, got {actual}"
+                )
+
+            mmdb_path = (
+                await asyncio.to_thread(self._extract_mmdb, download_path, staging_dir)
+                if release.archive
+                else download_path
+            )
+
+            # Validate before it replaces the active file
+            reader = await asyncio.to_thread(MMDBReader, mmdb_path, use_mmap=self.use_mmap)
+            reader.close()
+
+            await asyncio.to_thread(self._retain_active)
+            os.replace(mmdb_path, self.database_path)
+            with open(self.database_path + self.CHECKSUM_SUFFIX, "w") as f:
+                f.write(release.sha256)
+
+            reader = await asyncio.to_thread(MMDBReader, self.database_path, use_mmap=self.use_mmap)
+            self.activate(reader)
+            self.logger.info(f"GeoIP dataset release {release.version} activated")
+            for callback in self._activation_callbacks:
+                try:
+                    callback(reader)
+                except Exception as e:
+                    self.logger.warning(f"GeoIP dataset activation callback failed: {e}")
+        finally:
+            shutil.rmtree(staging_dir, ignore_errors=True)
+
+    def _retain_active(self) -> None:
+        """Snapshot the dataset about to be replaced (best effort)."""
+        if self.snapshots is None or not os.path.exists(self.database_path):
+            return
+        try:
+            self.snapshots.retain(self.database_path)
+        except (OSError, ValueError) as e:
+            # History is lost for this build, but the update must still go ahead
+            self.logger.warning(f"Could not snapshot the outgoing GeoIP dataset: {e}")
+
+    @staticmethod
+    def _extract_mmdb(archive_path: str, staging_dir: str) -> str:
+        """Extract the .mmdb member from a MaxMind tar.gz archive."""
+        try:
+            with tarfile.open(archive_path, "r:gz") as archive:
+                member = next(
+                    (m for m in archive.getmembers()
+                     if m.isfile() and m.name.endswith(".mmdb")),
+                    None,
+                )
+                if member is None:
+                    raise RuntimeError("Release archive contains no .mmdb file")
+                source = archive.extractfile(member)
+                mmdb_path = os.path.join(staging_dir, "dataset.mmdb")
+                with open(mmdb_path, "wb") as f:
+                    shutil.copyfileobj(source, f)
+                return mmdb_path
+        except tarfile.TarError as e:
+            raise RuntimeError(f"Invalid release archive: {str(e)}")
+
+    async def start(self) -> None:
+        """Poll for updates until stopped."""
+        self._running = True
+        self.logger.info(
+            f"Starting GeoIP dataset updater (interval: {self.check_interval_seconds}s)"
+        )
+        try:
+            while self._running:
+                try:
+                    await self.check_for_update()
+                except Exception as e:
+                    self.logger.error(f"GeoIP dataset update failed: {str(e)}")
+                await asyncio.sleep(self.check_interval_seconds)
+        except asyncio.CancelledError:
+            self.logger.info("GeoIP dataset updater stopped")
+            self._running = False
+
+    def stop(self) -> None:
+        """Stop polling after the current iteration."""
+        self._running = False
+
+
+def invalidate_cached_lookups(reader: MMDBReader) -> None:
+    """Activation callback dropping cached IP lookups (the outgoing build's answers)."""
+    from src.services.lookup_cache_service import LookupType, invalidate_lookups
+
+    invalidate_lookups(LookupType.IP)
+
+
+def _maxmind_credentials() -> Tuple[str, str]:
+    """MaxMind credentials of the current configuration (see src.services.secrets_service)."""
+    from src.config import get_config
+
+    config = get_config()
+    return config.maxmind_account_id, config.maxmind_license_key
+
+
+def build_update_service(config) -> Optional[GeoIPUpdateService]:
+    """Create the updater described by configuration.
+
+    Args:
+        config: Application configuration
+
+    Returns:
+        GeoIPUpdateService, or None if updates are disabled or misconfigured
+    """
+    if not config.geoip_update_enabled:
+        return None
+
+    if config.geoip_update_source == "s3":
+        if not (config.geoip_s3_bucket and config.geoip_s3_key):
+            logging.getLogger(__name__).error(
+                "GeoIP S3 updates enabled without GEOIP_S3_BUCKET/GEOIP_S3_KEY"
+            )
+            return None
+        source = S3Source(config.geoip_s3_bucket, config.geoip_s3_key)
+    else:
+        if not (config.maxmind_account_id and config.maxmind_license_key):
+            logging.getLogger(__name__).error(
+                "GeoIP MaxMind updates enabled without MAXMIND_ACCOUNT_ID/MAXMIND_LICENSE_KEY"
+            )
+            return None
+        source = MaxMindSource(
+            config.maxmind_account_id,
+            config.maxmind_license_key,
+            config.geoip_edition_id,
+            credentials=_maxmind_credentials if config.secrets_backend else None,
+        )
+
+    updater = GeoIPUpdateService(
+        source=source,
+        database_path=config.geoip_database_path,
+        check_interval_seconds=config.geoip_update_interval_seconds,
+        snapshots=get_snapshot_store(),
+        use_mmap=config.geoip_use_mmap,
+    )
+    updater.register_activation_callback(invalidate_cached_lookups)
+    return updater
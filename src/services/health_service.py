@@ -0,0 +1,190 @@
+"""Dependency health checks behind the Kubernetes probe endpoints.
+
+Each check reports one dependency:
+
+- `database`: a `SELECT 1` through the connection pool (Postgres in
+  production, SQLite in development);
+- `geoip_dataset`: the GeoIP dataset at GEOIP_DATABASE_PATH is loaded;
+- `geoip_dataset_age`: the loaded dataset was built within
+  HEALTH_DATASET_MAX_AGE_DAYS;
+- `velocity_redis` / `idempotency_redis` / `token_denylist_redis`: the
+  Redis servers of VELOCITY_REDIS_URL, IDEMPOTENCY_REDIS_URL and
+  TOKEN_DENYLIST_REDIS_URL answer PING (skipped when the URL is not set).
+- `lookup_cache_warming`: the lookup cache has been warmed from the
+  hottest lookups (skipped unless LOOKUP_CACHE_WARM_ENABLED).
+
+Critical checks decide readiness: without them requests fail. A stale
+dataset still answers lookups, so dataset age only degrades health;
+taking every replica out of rotation because an update is late would
+turn a freshness problem into an outage.
+"""
+
+import logging
+import time
+from dataclasses import dataclass, field
+from typing import Callable, List, Optional
+
+from src.config import Config, get_config
+from src.database import get_db_manager
+from src.services.cache_warming_service import get_cache_warmer
+from src.services.mmdb_service import get_mmdb_reader
+
+logger = logging.getLogger(__name__)
+
+
+class CheckStatus:
+    """Outcome of a dependency check."""
+    OK = "ok"
+    FAIL = "fail"
+    SKIPPED = "skipped"
+
+
+class HealthStatus:
+    """Overall status of a probe."""
+    OK = "ok"
+    DEGRADED = "degraded"        # Only non-critical checks failed
+    FAIL = "fail"                # A critical check failed
+
+
+@dataclass
+class DependencyCheck:
+    """Result of checking one dependency."""
+    name: str
+    status: str
+    critical: bool
+    latency_ms: Optional[float] = None
+    detail: Optional[str] = None
+
+
+@dataclass
+class HealthReport:
+    """Results of a set of checks and the resulting probe status."""
+    checks: List[DependencyCheck] = field(default_factory=list)
+
+    @property
+    def status(self) -> str:
+        failed = [c for c in self.checks if c.status == CheckStatus.FAIL]
+        if any(c.critical for c in failed):
+            return HealthStatus.FAIL
+        return HealthStatus.DEGRADED if failed else HealthStatus.OK
+
+
+def _ping_redis(url: str, timeout: float) -> None:
+    """PING a Redis server.
+
+    Raises:
+        RuntimeError: If the redis package is not installed
+        Exception: Connection errors of the redis client
+    """
+    try:
+        import redis
+    except ImportError:
+        raise RuntimeError("redis is not installed")
+    client = redis.Redis.from_url(url, socket_connect_timeout=timeout, socket_timeout=timeout)
+    try:
+        client.ping()
+    finally:
+        client.close()
+
+
+class HealthService:
+    """Runs the dependency checks of the probe endpoints."""
+
+    def __init__(self, config: Optional[Config] = None):
+        """Initialize health service.
+
+        Args:
+            config: Configuration (default: get_config())
+        """
+        self.config = config if config is not None else get_config()
+
+    def _run(self, name: str, critical: bool, check: Callable[[], Optional[str]]) -> DependencyCheck:
+        """Time one check; a raised exception marks it failed with its message."""
+        started = time.perf_counter()
+        try:
+            detail = check()
+            status = CheckStatus.OK
+        except Exception as e:
+            detail = str(e) or type(e).__name__
+            status = CheckStatus.FAIL
+            logger.warning(f"Health check {name} failed: {detail}")
+        latency_ms = round((time.perf_counter() - started) * 1000, 2)
+        return DependencyCheck(name, status, critical, latency_ms, detail)
+
+    def check_database(self) -> DependencyCheck:
+        """Database reachable through the connection pool, with a connection to spare."""
+        def check() -> str:
+            manager = get_db_manager()
+            # Waiting for a connection would time the probe out; fail at once
+            # so that requests are routed to workers that have one
+            if manager.pool_exhausted():
+                stats = manager.get_pool_size()
+                raise RuntimeError(f"connection pool exhausted ({stats['checked_out']} in use)")
+            if not manager.health_check():
+                raise RuntimeError("database unreachable")
+            return manager.engine.dialect.name
+
+        return self._run("database", True, check)
+
+    def check_dataset(self) -> DependencyCheck:
+        """GeoIP dataset loaded."""
+        def check() -> Optional[str]:
+            reader = get_mmdb_reader()
+            if reader is None:
+                raise RuntimeError(f"GeoIP dataset not loaded from {self.config.geoip_database_path}")
+            return reader.database_type or None
+
+        return self._run("geoip_dataset", True, check)
+
+    def check_dataset_age(self) -> DependencyCheck:
+        """GeoIP dataset built within HEALTH_DATASET_MAX_AGE_DAYS."""
+        max_age_days = self.config.health_dataset_max_age_days
+        reader = get_mmdb_reader()
+        if reader is None or max_age_days <= 0:
+            reason = "dataset not loaded" if reader is None else "HEALTH_DATASET_MAX_AGE_DAYS is 0"
+            return DependencyCheck("geoip_dataset_age", CheckStatus.SKIPPED, False, detail=reason)
+
+        def check() -> str:
+            age_days = (time.time() - reader.build_epoch) / 86400
+            if age_days > max_age_days:
+                raise RuntimeError(f"dataset is {age_days:.1f} days old (limit {max_age_days:g})")
+            return f"{age_days:.1f} days old"
+
+        return self._run("geoip_dataset_age", False, check)
+
+    def check_redis(self, name: str, url: str) -> DependencyCheck:
+        """Redis server answering PING (skipped when no URL is configured)."""
+        if not url:
+            return DependencyCheck(name, CheckStatus.SKIPPED, True, detail="not configured")
+        timeout = self.config.health_check_timeout_seconds
+        return self._run(name, True, lambda: _ping_redis(url, timeout))
+
+    def check_cache_warming(self) -> DependencyCheck:
+        """Lookup cache warmed at startup (skipped when warming is off)."""
+        warmer = get_cache_warmer()
+        if warmer is None:
+            return DependencyCheck("lookup_cache_warming", CheckStatus.SKIPPED, True, detail="not configured")
+
+        def check() -> str:
+            if not warmer.ready:
+                raise RuntimeError("warming the lookup cache")
+            return f"{warmer.warmed} lookups warmed"
+
+        return self._run("lookup_cache_warming", True, check)
+
+    def readiness(self) -> HealthReport:
+        """Critical checks: can this instance serve requests."""
+        return HealthReport([
+            self.check_database(),
+            self.check_dataset(),
+            self.check_redis("velocity_redis", self.config.velocity_redis_url),
+            self.check_redis("idempotency_redis", self.config.idempotency_redis_url),
+            self.check_redis("token_denylist_redis", self.config.token_denylist_redis_url),
+            self.check_cache_warming(),
+        ])
+
+    def health(self) -> HealthReport:
+        """Every check, including dataset freshness."""
+        report = self.readiness()
+        report.checks.append(self.check_dataset_age())
+        return report
@@ -0,0 +1,121 @@
+"""Detection density aggregation for heatmaps."""
+
+from collections import Counter
+from dataclasses import dataclass
+from datetime import datetime
+from typing import List, Optional, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import Detection
+from src.spatial import h3index
+from src.spatial.geometry import BoundingBox
+
+
+@dataclass
+class HeatmapCell:
+    """Detection count for one H3 cell."""
+    cell: str
+    count: int
+    latitude: float
+    longitude: float
+
+
+@dataclass
+class HeatmapResult:
+    """Per-cell counts and whether the scan hit the detection limit."""
+    cells: List[HeatmapCell]
+    total: int
+    truncated: bool
+
+
+class HeatmapService:
+    """Aggregates stored detections into H3 cells."""
+
+    def __init__(self, session: Session, max_detections: Optional[int] = None):
+        """Initialize heatmap service.
+
+        Args:
+            session: SQLAlchemy database session
+            max_detections: Upper bound on detections scanned per request
+                (default HEATMAP_MAX_DETECTIONS); the most recent are kept
+        """
+        if max_detections is None:
+            from src.config import get_config
+
+            max_detections = get_config().heatmap_max_detections
+        self.session = session
+        self.max_detections = max_detections
+
+    def h3_counts(
+        self,
+        resolution: int,
+        since: Optional[datetime] = None,
+        until: Optional[datetime] = None,
+        object_class: Optional[str] = None,
+    ) -> HeatmapResult:
+        """Count detections per H3 cell.
+
+        Args:
+            resolution: H3 resolution (0-15)
+            since: Only detections captured at or after this time
+            until: Only detections captured before this time
+            object_class: Only detections of this class
+
+        Returns:
+            HeatmapResult with cells (highest count first) and a truncated
+            flag set when more than max_detections matched
+
+        Raises:
+            ValueError: If the resolution is invalid
+            RuntimeError: If the h3 package is not installed
+        """
+        h3index.validate_resolution(resolution)
+        points, truncated = self.recent_points(since=since, until=until, object_class=object_class)
+
+        counts = Counter(h3index.cells_for_points(points, resolution))
+        cells = []
+        for cell, count in counts.most_common():
+            latitude, longitude = h3index.cell_to_latlng(cell)
+            cells.append(HeatmapCell(cell=cell, count=count, latitude=latitude, longitude=longitude))
+        return HeatmapResult(cells=cells, total=len(points), truncated=truncated)
+
+    def recent_points(
+        self,
+        since: Optional[datetime] = None,
+        until: Optional[datetime] = None,
+        object_class: Optional[str] = None,
+        bbox: Optional[BoundingBox] = None,
+    ) -> Tuple[List[Tuple[float, float]], bool]:
+        """Positions of the most recent matching detections.
+
+        Args:
+            since: Only detections captured at or after this time
+            until: Only detections captured before this time
+            object_class: Only detections of this class
+            bbox: Only detections inside this box
+
+        Returns:
+            (latitude, longitude) of at most max_detections detections, and
+            whether more matched
+        """
+        query = self.session.query(Detection.calculated_lat, Detection.calculated_lon)
+        if since is not None:
+            query = query.filter(Detection.timestamp >= since)
+        if until is not None:
+            query = query.filter(Detection.timestamp < until)
+        if object_class is not None:
+            query = query.filter(Detection.object_class == object_class)
+        if bbox is not None:
+            query = query.filter(
+                Detection.calculated_lat.between(bbox.min_lat, bbox.max_lat),
+                Detection.calculated_lon.between(bbox.min_lon, bbox.max_lon),
+            )
+        # Fetch one extra row to detect truncation without a COUNT query
+        points = (
+            query.order_by(Detection.timestamp.desc())
+            .limit(self.max_detections + 1)
+            .all()
+        )
+        truncated = len(points) > self.max_detections
+        return [(lat, lon) for lat, lon in points[:self.max_detections]], truncated
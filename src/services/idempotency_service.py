@@ -0,0 +1,248 @@
+"""Idempotency keys for mutating requests.
+
+A client that sends `Idempotency-Key: <unique value>` with a create
+request can retry it safely: the first request claims the key and its
+result is stored for a window (IDEMPOTENCY_TTL_SECONDS). Retries within
+the window get that result back instead of creating a duplicate. Keys are
+scoped to the caller (tenant or anonymous) and the route. Reusing a key
+for a different request body is refused, as is a retry that arrives while
+the first request is still running. Failed requests release their key, so
+they can be retried.
+
+With IDEMPOTENCY_REDIS_URL the keys are shared by all workers
+(`pip install -e ".[redis]"`); otherwise they are kept in process memory.
+"""
+
+import hashlib
+import json
+import logging
+import threading
+import time
+from dataclasses import asdict, dataclass
+from typing import Any, Dict, Optional, Tuple
+
+logger = logging.getLogger(__name__)
+
+MAX_KEY_LENGTH = 255
+# How long a claimed key blocks retries if its request never completes
+PENDING_TTL_SECONDS = 60
+
+
+class IdempotencyConflict(Exception):
+    """A key is in use by another request (different body, or still running)."""
+
+    def __init__(self, message: str, in_progress: bool = False):
+        super().__init__(message)
+        self.in_progress = in_progress
+
+
+@dataclass
+class IdempotencyEntry:
+    """State of one idempotency key."""
+    fingerprint: str                    # Digest of the request that claimed the key
+    status_code: Optional[int] = None   # None while the request is running
+    body: Optional[Any] = None          # JSON response of the completed request
+
+    @property
+    def completed(self) -> bool:
+        return self.status_code is not None
+
+    def dumps(self) -> str:
+        return json.dumps(asdict(self))
+
+    @classmethod
+    def loads(cls, raw) -> "IdempotencyEntry":
+        return cls(**json.loads(raw))
+
+
+def request_fingerprint(payload: Any) -> str:
+    """Digest of a JSON-serializable request payload."""
+    return hashlib.sha256(json.dumps(payload, sort_keys=True, default=str).encode()).hexdigest()
+
+
+def validate_key(key: str) -> str:
+    """Idempotency-Key header value, stripped.
+
+    Raises:
+        ValueError: If it is empty, too long or not printable ASCII
+    """
+    key = key.strip()
+    if not key or len(key) > MAX_KEY_LENGTH or not key.isascii() or not key.isprintable():
+        raise ValueError(f"Idempotency-Key must be 1-{MAX_KEY_LENGTH} printable ASCII characters")
+    return key
+
+
+class IdempotencyStore:
+    """Storage of idempotency entries with expiry."""
+
+    def claim(self, key: str, entry: IdempotencyEntry, ttl_seconds: int) -> Optional[IdempotencyEntry]:
+        """Store an entry unless the key exists.
+
+        Returns:
+            None if the key was claimed, else the existing entry
+        """
+        raise NotImplementedError
+
+    def put(self, key: str, entry: IdempotencyEntry, ttl_seconds: int) -> None:
+        """Replace a key's entry."""
+        raise NotImplementedError
+
+    def delete(self, key: str) -> None:
+        """Release a key."""
+        raise NotImplementedError
+
+
+class InMemoryIdempotencyStore(IdempotencyStore):
+    """Process-local store (not shared between workers)."""
+
+    def __init__(self, clock=time.monotonic):
+        self._entries: Dict[str, Tuple[float, IdempotencyEntry]] = {}
+        self._lock = threading.Lock()
+        self._clock = clock
+
+    def claim(self, key: str, entry: IdempotencyEntry, ttl_seconds: int) -> Optional[IdempotencyEntry]:
+        now = self._clock()
+        with self._lock:
+            for stale in [k for k, (expires, _) in self._entries.items() if expires <= now]:
+                del self._entries[stale]
+            if key in self._entries:
+                return self._entries[key][1]
+            self._entries[key] = (now + ttl_seconds, entry)
+            return None
+
+    def put(self, key: str, entry: IdempotencyEntry, ttl_seconds: int) -> None:
+        with self._lock:
+            self._entries[key] = (self._clock() + ttl_seconds, entry)
+
+    def delete(self, key: str) -> None:
+        with self._lock:
+            self._entries.pop(key, None)
+
+
+class RedisIdempotencyStore(IdempotencyStore):
+    """Redis strings with expiry (SET NX claims a key atomically)."""
+
+    def __init__(self, client):
+        """Initialize Redis store.
+
+        Args:
+            client: redis.Redis client
+        """
+        self.client = client
+
+    @classmethod
+    def from_url(cls, url: str) -> "RedisIdempotencyStore":
+        """Connect to Redis.
+
+        Raises:
+            RuntimeError: If the redis package is not installed
+        """
+        try:
+            import redis
+        except ImportError:
+            raise RuntimeError("redis is required for IDEMPOTENCY_REDIS_URL")
+        return cls(redis.Redis.from_url(url))
+
+    def claim(self, key: str, entry: IdempotencyEntry, ttl_seconds: int) -> Optional[IdempotencyEntry]:
+        if self.client.set(key, entry.dumps(), nx=True, ex=ttl_seconds):
+            return None
+        existing = self.client.get(key)
+        if existing is None:
+            # Expired in between: try once more
+            return None if self.client.set(key, entry.dumps(), nx=True, ex=ttl_seconds) else entry
+        return IdempotencyEntry.loads(existing)
+
+    def put(self, key: str, entry: IdempotencyEntry, ttl_seconds: int) -> None:
+        self.client.set(key, entry.dumps(), ex=ttl_seconds)
+
+    def delete(self, key: str) -> None:
+        self.client.delete(key)
+
+
+class IdempotencyService:
+    """Claims idempotency keys and replays stored results."""
+
+    def __init__(self, store: IdempotencyStore, ttl_seconds: int = 86400, key_prefix: str = "idempotency"):
+        """Initialize service.
+
+        Args:
+            store: Entry storage
+            ttl_seconds: How long a completed result is replayed
+            key_prefix: Prefix of the store keys
+        """
+        self.store = store
+        self.ttl_seconds = ttl_seconds
+        self.key_prefix = key_prefix
+
+    def _key(self, scope: str, key: str) -> str:
+        return f"{self.key_prefix}:{scope}:{key}"
+
+    def begin(self, scope: str, key: str, fingerprint: str) -> Optional[IdempotencyEntry]:
+        """Claim a key for a request.
+
+        Args:
+            scope: Caller and route the key belongs to
+            key: Idempotency-Key header value
+            fingerprint: request_fingerprint of the request
+
+        Returns:
+            None if the request should run, else the completed entry to replay
+
+        Raises:
+            ValueError: If the key is malformed
+            IdempotencyConflict: If the key belongs to a different request,
+                or its request is still running
+        """
+        entry = self.store.claim(
+            self._key(scope, validate_key(key)), IdempotencyEntry(fingerprint), PENDING_TTL_SECONDS
+        )
+        if entry is None:
+            return None
+        if entry.fingerprint != fingerprint:
+            raise IdempotencyConflict("Idempotency-Key was already used for a different request")
+        if not entry.completed:
+            raise IdempotencyConflict(
+                "A request with this Idempotency-Key is still in progress", in_progress=True
+            )
+        return entry
+
+    def complete(self, scope: str, key: str, fingerprint: str, status_code: int, body: Any) -> None:
+        """Store the result of a claimed key for replay."""
+        self.store.put(
+            self._key(scope, validate_key(key)),
+            IdempotencyEntry(fingerprint, status_code, body),
+            self.ttl_seconds,
+        )
+
+    def release(self, scope: str, key: str) -> None:
+        """Free a claimed key after its request failed."""
+        self.store.delete(self._key(scope, validate_key(key)))
+
+
+# Global idempotency service (store chosen lazily from IDEMPOTENCY_REDIS_URL)
+_idempotency_service: Optional[IdempotencyService] = None
+
+
+def get_idempotency_service() -> IdempotencyService:
+    """Get the global idempotency service.
+
+    Returns:
+        IdempotencyService backed by Redis, or by memory if no URL is configured
+
+    Raises:
+        RuntimeError: If IDEMPOTENCY_REDIS_URL is set but redis is not installed
+    """
+    global _idempotency_service
+    if _idempotency_service is None:
+        from src.config import get_config
+
+        config = get_config()
+        if config.idempotency_redis_url:
+            store: IdempotencyStore = RedisIdempotencyStore.from_url(config.idempotency_redis_url)
+        else:
+            logger.info("IDEMPOTENCY_REDIS_URL not set; idempotency keys are per process")
+            store = InMemoryIdempotencyStore()
+        _idempotency_service = IdempotencyService(
+            store, config.idempotency_ttl_seconds, config.idempotency_key_prefix
+        )
+    return _idempotency_service
@@ -0,0 +1,144 @@
+"""Source network allowlists of tenant API keys.
+
+An API key can be restricted to a list of networks (`allowed_cidrs`,
+e.g. ["203.0.113.0/24", "2001:db8::/32"]; empty: any address). Outside
+them the key cannot be exchanged for tokens and its refresh tokens
+cannot be spent. The access tokens issued from the key carry the list in
+their `cidrs` claim, and IpAllowlistMiddleware refuses requests made with
+them from other addresses before the token is verified or any other
+authentication work is done, so a leaked key, or a token issued from it,
+is useless outside the tenant's network.
+
+The client address is the connection's peer, or behind a trusted proxy
+the rightmost entry of API_KEY_CLIENT_IP_HEADER.
+"""
+
+import ipaddress
+import logging
+from typing import Any, Iterable, Mapping, Optional, Tuple
+
+import jwt
+
+logger = logging.getLogger(__name__)
+
+# Access token claim listing the networks of the key the token was issued from
+CIDRS_CLAIM = "cidrs"
+
+# Most networks one key may list
+MAX_CIDRS = 50
+
+
+class SourceNetworkError(Exception):
+    """Credentials used from an address outside their key's allowlist."""
+
+    def __init__(self, client_ip: Optional[str]):
+        super().__init__(f"API key is not allowed from {client_ip or 'an unknown address'}")
+        self.client_ip = client_ip
+
+
+def parse_cidrs(values: Iterable[str]) -> Tuple[str, ...]:
+    """Normalize an allowlist.
+
+    Args:
+        values: IPv4/IPv6 networks or single addresses; host bits are
+            dropped ("10.1.2.3/8" is 10.0.0.0/8)
+
+    Returns:
+        Networks in CIDR notation, in the given order without duplicates
+
+    Raises:
+        ValueError: If an entry is not a network or there are too many
+    """
+    if isinstance(values, str):
+        raise ValueError("allowed_cidrs must be a list of networks")
+    networks = []
+    for value in values:
+        try:
+            network = str(ipaddress.ip_network(str(value).strip(), strict=False))
+        except ValueError:
+            raise ValueError(f"Invalid network {value!r}: expected CIDR notation, e.g. 203.0.113.0/24")
+        if network not in networks:
+            networks.append(network)
+    if len(networks) > MAX_CIDRS:
+        raise ValueError(f"At most {MAX_CIDRS} networks per API key")
+    return tuple(networks)
+
+
+def address_allowed(address: Optional[str], cidrs: Iterable[str]) -> bool:
+    """Whether an address is in an allowlist (an empty list allows any address).
+
+    Unknown and malformed addresses are only allowed by an empty list.
+    """
+    cidrs = tuple(cidrs)
+    if not cidrs:
+        return True
+    try:
+        ip = ipaddress.ip_address((address or "").strip())
+    except ValueError:
+        return False
+    if ip.version == 6 and ip.ipv4_mapped is not None:
+        ip = ip.ipv4_mapped
+    for cidr in cidrs:
+        try:
+            if ip in ipaddress.ip_network(cidr, strict=False):
+                return True
+        except ValueError:
+            logger.error(f"Invalid network {cidr!r} in an API key allowlist")
+    return False
+
+
+def check_address(address: Optional[str], cidrs: Iterable[str]) -> None:
+    """Refuse an address outside an allowlist.
+
+    Raises:
+        SourceNetworkError: If the address is not allowed
+    """
+    if not address_allowed(address, cidrs):
+        raise SourceNetworkError(address)
+
+
+def stored_cidrs(value: Optional[str]) -> Tuple[str, ...]:
+    """Allowlist of a stored key (space-separated column)."""
+    return tuple((value or "").split())
+
+
+def token_cidrs(token: str) -> Tuple[str, ...]:
+    """Allowlist in an access token's claims, read without verifying the token.
+
+    Only good for refusing requests early: a token altered to drop the
+    claim fails verification later anyway.
+
+    Returns:
+        The cidrs claim, empty if the token has none or cannot be decoded
+    """
+    try:
+        claims = jwt.decode(token, options={"verify_signature": False})
+    except jwt.InvalidTokenError:
+        return ()
+    cidrs = claims.get(CIDRS_CLAIM) if isinstance(claims, dict) else None
+    if not isinstance(cidrs, list):
+        return ()
+    return tuple(str(cidr) for cidr in cidrs)
+
+
+def client_address(scope: Mapping[str, Any], client_ip_header: str = "") -> Optional[str]:
+    """Address of an ASGI connection's client.
+
+    Args:
+        scope: ASGI scope (or a Request's scope)
+        client_ip_header: Header set by a trusted proxy; its rightmost
+            entry is used when present
+    """
+    if client_ip_header:
+        name = client_ip_header.lower().encode("latin-1")
+        entries = [
+            entry.strip()
+            for header, value in scope.get("headers") or ()
+            if header == name
+            for entry in value.decode("latin-1").split(",")
+            if entry.strip()
+        ]
+        if entries:
+            return entries[-1]
+    client = scope.get("client")
+    return client[0] if client else None
@@ -0,0 +1,313 @@
+"""IP lookup engine: address normalization and GeoIP dataset resolution.
+
+IPv6 traffic frequently carries an IPv4 address inside it (6to4 relays,
+Teredo tunnels, IPv4-mapped sockets). The geolocation of the embedded IPv4
+client is far more accurate than that of the tunnel relay, so lookups
+normalize the address first and fall back to the literal IPv6 address.
+"""
+
+import bisect
+import ipaddress
+import logging
+from dataclasses import dataclass
+from typing import Dict, Generic, Iterable, Iterator, List, Optional, Tuple, TypeVar, Union
+
+from src.services.mmdb_service import GeoIPRecord, MMDBReader, get_mmdb_reader
+
+logger = logging.getLogger(__name__)
+
+IPAddress = Union[ipaddress.IPv4Address, ipaddress.IPv6Address]
+
+V = TypeVar("V")
+
+
+class TunnelType:
+    """Kinds of IPv6 addresses that embed an IPv4 address."""
+    IPV4_MAPPED = "ipv4_mapped"
+    SIX_TO_FOUR = "6to4"
+    TEREDO = "teredo"
+
+
+@dataclass
+class NormalizedAddress:
+    """An input IP address and the address used for geolocation."""
+    original: IPAddress
+    address: IPAddress
+    tunnel: Optional[str] = None
+
+    @property
+    def ip_version(self) -> int:
+        """IP version of the original (client-supplied) address."""
+        return self.original.version
+
+
+@dataclass
+class IpLookupResult:
+    """Result of an IP lookup through the engine."""
+    normalized: NormalizedAddress
+    record: Optional[GeoIPRecord] = None
+    dataset_build_epoch: Optional[int] = None  # Build time of the answering dataset
+
+
+def parse_ip(ip: str) -> IPAddress:
+    """Parse an IP address string, accepting bracketed and zone-scoped IPv6.
+
+    Args:
+        ip: Address such as "81.2.69.142", "2001:db8::1", "[2001:db8::1]"
+            or "fe80::1%eth0"
+
+    Returns:
+        Parsed IPv4Address or IPv6Address
+
+    Raises:
+        ValueError: If the string is not a valid IP address
+    """
+    if not isinstance(ip, str):
+        raise ValueError(f"Invalid IP address: {ip}")
+    value = ip.strip()
+    if value.startswith("[") and value.endswith("]"):
+        value = value[1:-1]
+    if "%" in value:
+        value = value.split("%", 1)[0]
+    try:
+        return ipaddress.ip_address(value)
+    except ValueError:
+        raise ValueError(f"Invalid IP address: {ip}")
+
+
+def normalize_ip(ip: str) -> NormalizedAddress:
+    """Normalize an IP address for geolocation.
+
+    IPv4-mapped (::ffff:0:0/96), 6to4 (2002::/16) and Teredo (2001::/32)
+    addresses are reduced to the IPv4 address they carry.
+
+    Args:
+        ip: IPv4 or IPv6 address string
+
+    Returns:
+        NormalizedAddress with the original and lookup addresses
+
+    Raises:
+        ValueError: If the string is not a valid IP address
+    """
+    original = parse_ip(ip)
+    if original.version == 4:
+        return NormalizedAddress(original=original, address=original)
+
+    if original.ipv4_mapped is not None:
+        return NormalizedAddress(
+            original=original,
+            address=original.ipv4_mapped,
+            tunnel=TunnelType.IPV4_MAPPED,
+        )
+    if original.sixtofour is not None:
+        return NormalizedAddress(
+            original=original,
+            address=original.sixtofour,
+            tunnel=TunnelType.SIX_TO_FOUR,
+        )
+    if original.teredo is not None:
+        _server, client = original.teredo
+        return NormalizedAddress(
+            original=original, address=client, tunnel=TunnelType.TEREDO
+        )
+    return NormalizedAddress(original=original, address=original)
+
+
+class IpRangeSet:
+    """Set of IPv4/IPv6 networks with O(log n) membership tests.
+
+    Networks are stored as merged, sorted integer ranges per IP version so
+    large lists (VPN ranges, cloud provider ranges) stay cheap to query.
+    """
+
+    def __init__(self, networks: Iterable[str] = ()):
+        """Build the set from CIDR strings or bare addresses.
+
+        Args:
+            networks: CIDRs such as "10.0.0.0/8" or single addresses
+
+        Raises:
+            ValueError: If an entry is not a valid network or address
+        """
+        ranges = {4: [], 6: []}
+        count = 0
+        for entry in networks:
+            network = ipaddress.ip_network(entry.strip(), strict=False)
+            ranges[network.version].append(
+                (int(network.network_address), int(network.broadcast_address))
+            )
+            count += 1
+        self._starts = {}
+        self._ends = {}
+        for version, items in ranges.items():
+            merged = self._merge(items)
+            self._starts[version] = [start for start, _ in merged]
+            self._ends[version] = [end for _, end in merged]
+        self._count = count
+
+    @staticmethod
+    def _merge(items: List[Tuple[int, int]]) -> List[Tuple[int, int]]:
+        merged: List[Tuple[int, int]] = []
+        for start, end in sorted(items):
+            if merged and start <= merged[-1][1] + 1:
+                if end > merged[-1][1]:
+                    merged[-1] = (merged[-1][0], end)
+            else:
+                merged.append((start, end))
+        return merged
+
+    @classmethod
+    def from_file(cls, path: str) -> "IpRangeSet":
+        """Load networks from a text file (one per line, '#' comments).
+
+        Args:
+            path: File path
+
+        Returns:
+            IpRangeSet with the file's networks
+
+        Raises:
+            OSError: If the file cannot be read
+            ValueError: If a line is not a valid network
+        """
+        with open(path) as f:
+            lines = [line.split("#", 1)[0].strip() for line in f]
+        return cls(line for line in lines if line)
+
+    def __contains__(self, ip) -> bool:
+        address = ip if not isinstance(ip, str) else parse_ip(ip)
+        value = int(address)
+        starts = self._starts[address.version]
+        index = bisect.bisect_right(starts, value) - 1
+        return index >= 0 and value <= self._ends[address.version][index]
+
+    def __len__(self) -> int:
+        """Number of networks the set was built from."""
+        return self._count
+
+
+class IpPrefixMap(Generic[V]):
+    """IPv4/IPv6 networks mapped to values, matched most specific first.
+
+    Unlike IpRangeSet, overlapping networks keep their own values, so a
+    network nested inside a larger one wins. A lookup costs one dict probe
+    per distinct prefix length in the map.
+    """
+
+    def __init__(self, entries: Iterable[Tuple[str, V]] = ()):
+        """Build the map.
+
+        Args:
+            entries: (CIDR, value) pairs; later duplicates replace earlier ones
+
+        Raises:
+            ValueError: If a network is invalid
+        """
+        # version -> prefix length -> network address -> value
+        self._tables: Dict[int, Dict[int, Dict[int, V]]] = {4: {}, 6: {}}
+        self._prefixes: Dict[int, List[int]] = {4: [], 6: []}
+        for cidr, value in entries:
+            self.add(cidr, value)
+
+    def add(self, cidr: str, value: V) -> None:
+        """Map a network (host bits are ignored) to a value.
+
+        Raises:
+            ValueError: If the network is invalid
+        """
+        network = ipaddress.ip_network(cidr.strip(), strict=False)
+        tables = self._tables[network.version]
+        if network.prefixlen not in tables:
+            tables[network.prefixlen] = {}
+            self._prefixes[network.version] = sorted(tables, reverse=True)
+        tables[network.prefixlen][int(network.network_address)] = value
+
+    def matches(self, ip) -> Iterator[V]:
+        """Values of every network containing an address, most specific first."""
+        address = ip if not isinstance(ip, str) else parse_ip(ip)
+        bits = address.max_prefixlen
+        value = int(address)
+        tables = self._tables[address.version]
+        for prefixlen in self._prefixes[address.version]:
+            match = tables[prefixlen].get(value >> (bits - prefixlen) << (bits - prefixlen))
+            if match is not None:
+                yield match
+
+    def lookup(self, ip) -> Optional[V]:
+        """Value of the most specific network containing an address."""
+        return next(self.matches(ip), None)
+
+    def __len__(self) -> int:
+        """Number of distinct networks in the map."""
+        return sum(len(t) for tables in self._tables.values() for t in tables.values())
+
+
+class IpLookupService:
+    """Resolves IP addresses (IPv4 and IPv6) against the GeoIP dataset."""
+
+    def __init__(self, reader: MMDBReader):
+        """Initialize lookup service.
+
+        Args:
+            reader: Open GeoIP database reader
+        """
+        self.reader = reader
+
+    def lookup(self, ip: str) -> IpLookupResult:
+        """Look up an IP address.
+
+        Embedded IPv4 addresses are tried first; the literal IPv6 address is
+        used as a fallback when the embedded address has no record.
+
+        Args:
+            ip: IPv4 or IPv6 address string
+
+        Returns:
+            IpLookupResult (record is None when the address is not in the dataset)
+
+        Raises:
+            ValueError: If the address is invalid or cannot be looked up in
+                the loaded dataset
+        """
+        normalized = normalize_ip(ip)
+        candidates = self._candidates(normalized)
+        if not candidates:
+            raise ValueError(
+                f"Cannot look up IPv6 address {normalized.original}: "
+                "the loaded GeoIP dataset only contains IPv4 networks"
+            )
+
+        for candidate in candidates:
+            record = self.reader.lookup(candidate)
+            if record is not None:
+                return IpLookupResult(
+                    normalized=normalized,
+                    record=record,
+                    dataset_build_epoch=self.reader.build_epoch,
+                )
+
+        return IpLookupResult(normalized=normalized)
+
+    def _candidates(self, normalized: NormalizedAddress) -> List[IPAddress]:
+        """Addresses to try, in order, that the dataset can answer."""
+        ordered = [normalized.address]
+        if normalized.original != normalized.address:
+            ordered.append(normalized.original)
+        return [
+            address
+            for address in ordered
+            if address.version == 4 or self.reader.ip_version == 6
+        ]
+
+
+def get_ip_lookup_service() -> Optional[IpLookupService]:
+    """Get an IP lookup service bound to the global GeoIP reader.
+
+    Returns:
+        IpLookupService, or None if no GeoIP dataset is available.
+    """
+    reader = get_mmdb_reader()
+    if reader is None:
+        return None
+    return IpLookupService(reader)
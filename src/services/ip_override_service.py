@@ -0,0 +1,304 @@
+"""Tenant IP range overrides for enterprise networks.
+
+Public GeoIP data places corporate ranges at the ISP's headquarters. A
+tenant can register its own CIDR -> site mappings; lookups made with the
+tenant's token check those first, most specific network first, and only
+fall back to the public dataset when no override matches.
+
+Each tenant's overrides are compiled into an in-memory prefix map that is
+rebuilt whenever the tenant's rows change (detected from the row count and
+latest updated_at, so changes made by other workers are picked up too).
+"""
+
+import ipaddress
+import logging
+import threading
+import uuid
+from collections import OrderedDict
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional, Tuple
+
+from sqlalchemy import func
+from sqlalchemy.orm import Session
+
+from src.models.database_models import IpRangeOverride
+from src.services.ip_lookup_service import IpPrefixMap
+from src.services.mmdb_service import GeoIPRecord
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass(frozen=True)
+class OverrideEntry:
+    """Compiled override, detached from the database session."""
+    override_id: str
+    network: str
+    label: str
+    latitude: float
+    longitude: float
+    created_at: datetime  # naive UTC, as stored
+    accuracy_radius_km: Optional[float] = None
+    country_iso_code: Optional[str] = None
+    city_name: Optional[str] = None
+    properties: Optional[Dict[str, Any]] = None
+
+    @classmethod
+    def from_model(cls, row: IpRangeOverride) -> "OverrideEntry":
+        return cls(
+            override_id=row.override_id,
+            network=row.network,
+            label=row.label,
+            created_at=row.created_at,
+            latitude=row.latitude,
+            longitude=row.longitude,
+            accuracy_radius_km=row.accuracy_radius_km,
+            country_iso_code=row.country_iso_code,
+            city_name=row.city_name,
+            properties=row.properties,
+        )
+
+    def record(self, ip_address: str) -> GeoIPRecord:
+        """GeoIP record for an address in this override's network.
+
+        The override replaces the dataset's location entirely; no dataset
+        fields are mixed in, since they describe the ISP rather than the site.
+        """
+        return GeoIPRecord(
+            ip_address=ip_address,
+            network=self.network,
+            country_iso_code=self.country_iso_code,
+            city_name=self.city_name,
+            latitude=self.latitude,
+            longitude=self.longitude,
+            accuracy_radius_km=self.accuracy_radius_km,
+        )
+
+
+class OverrideTable:
+    """One tenant's overrides, matched most specific network first."""
+
+    def __init__(self, entries: List[OverrideEntry]):
+        self._map: IpPrefixMap[OverrideEntry] = IpPrefixMap(
+            (entry.network, entry) for entry in entries
+        )
+
+    def match(self, ip, as_of: Optional[datetime] = None) -> Optional[OverrideEntry]:
+        """Most specific override containing an address.
+
+        Args:
+            ip: Address (string or ipaddress object)
+            as_of: Only consider overrides registered by this instant
+
+        Returns:
+            OverrideEntry, or None if no override applies
+        """
+        cutoff = None
+        if as_of is not None:
+            cutoff = as_of.astimezone(timezone.utc).replace(tzinfo=None) if as_of.tzinfo else as_of
+        for entry in self._map.matches(ip):
+            if cutoff is None or entry.created_at <= cutoff:
+                return entry
+        return None
+
+    def __len__(self) -> int:
+        return len(self._map)
+
+
+class IpOverrideTableCache:
+    """Thread-safe LRU cache of compiled override tables keyed by tenant."""
+
+    def __init__(self, max_tenants: int = 256):
+        """Initialize cache.
+
+        Args:
+            max_tenants: Maximum number of tenant tables kept in memory
+        """
+        self.max_tenants = max(1, max_tenants)
+        self._tables: "OrderedDict[str, Tuple[Tuple[Any, ...], OverrideTable]]" = OrderedDict()
+        self._lock = threading.Lock()
+
+    def get(self, session: Session, tenant_id: str) -> OverrideTable:
+        """The tenant's compiled table, rebuilt when its overrides change."""
+        version = tuple(
+            session.query(func.count(IpRangeOverride.id), func.max(IpRangeOverride.updated_at))
+            .filter(IpRangeOverride.tenant_id == tenant_id)
+            .one()
+        )
+        with self._lock:
+            cached = self._tables.get(tenant_id)
+            if cached is not None and cached[0] == version:
+                self._tables.move_to_end(tenant_id)
+                return cached[1]
+
+        rows = session.query(IpRangeOverride).filter(IpRangeOverride.tenant_id == tenant_id).all()
+        table = OverrideTable([OverrideEntry.from_model(row) for row in rows])
+        with self._lock:
+            self._tables[tenant_id] = (version, table)
+            self._tables.move_to_end(tenant_id)
+            while len(self._tables) > self.max_tenants:
+                self._tables.popitem(last=False)
+        logger.info(f"Compiled {len(table)} IP overrides for tenant {tenant_id}")
+        return table
+
+    def invalidate(self, tenant_id: str) -> None:
+        """Drop a tenant's table."""
+        with self._lock:
+            self._tables.pop(tenant_id, None)
+
+    def __len__(self) -> int:
+        return len(self._tables)
+
+
+class IpOverrideService:
+    """Stores tenant IP range overrides and compiles them for lookups."""
+
+    def __init__(
+        self,
+        session: Session,
+        cache: Optional[IpOverrideTableCache] = None,
+        max_per_tenant: Optional[int] = None,
+    ):
+        """Initialize override service.
+
+        Args:
+            session: SQLAlchemy database session
+            cache: Compiled table cache (default: the global cache)
+            max_per_tenant: Maximum overrides per tenant (default IP_OVERRIDE_MAX_PER_TENANT)
+        """
+        if max_per_tenant is None:
+            from src.config import get_config
+
+            max_per_tenant = get_config().ip_override_max_per_tenant
+        self.session = session
+        self.cache = cache if cache is not None else get_ip_override_cache()
+        self.max_per_tenant = max_per_tenant
+
+    def create_override(
+        self,
+        tenant_id: str,
+        network: str,
+        label: str,
+        latitude: float,
+        longitude: float,
+        accuracy_radius_km: Optional[float] = None,
+        country_iso_code: Optional[str] = None,
+        city_name: Optional[str] = None,
+        properties: Optional[Dict[str, Any]] = None,
+    ) -> IpRangeOverride:
+        """Validate and store an override.
+
+        Args:
+            tenant_id: Owning tenant
+            network: CIDR (host bits are dropped) or a single address
+            label: Site name
+            latitude: Site latitude
+            longitude: Site longitude
+            accuracy_radius_km: Site radius
+            country_iso_code: ISO 3166-1 alpha-2 country code
+            city_name: City name
+            properties: Arbitrary metadata returned with matches
+
+        Returns:
+            Stored IpRangeOverride
+
+        Raises:
+            ValueError: If the input is invalid, the tenant already has an
+                override for the network or is at its limit, or it cannot be stored
+        """
+        try:
+            canonical = str(ipaddress.ip_network(network.strip(), strict=False))
+        except ValueError:
+            raise ValueError(f"Invalid network: {network}")
+        if not label or not label.strip():
+            raise ValueError("label must not be empty")
+        if not -90 <= latitude <= 90:
+            raise ValueError(f"Latitude out of range: {latitude}")
+        if not -180 <= longitude <= 180:
+            raise ValueError(f"Longitude out of range: {longitude}")
+        if accuracy_radius_km is not None and accuracy_radius_km < 0:
+            raise ValueError("accuracy_radius_km must not be negative")
+
+        query = self.session.query(IpRangeOverride).filter(IpRangeOverride.tenant_id == tenant_id)
+        if query.filter(IpRangeOverride.network == canonical).first() is not None:
+            raise ValueError(f"An override for {canonical} already exists")
+        if query.count() >= self.max_per_tenant:
+            raise ValueError(f"Tenant already has the maximum of {self.max_per_tenant} overrides")
+
+        override = IpRangeOverride(
+            override_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            network=canonical,
+            label=label.strip(),
+            latitude=latitude,
+            longitude=longitude,
+            accuracy_radius_km=accuracy_radius_km,
+            country_iso_code=country_iso_code.upper() if country_iso_code else None,
+            city_name=city_name,
+            properties=properties,
+        )
+        try:
+            self.session.add(override)
+            self.session.commit()
+            self.session.refresh(override)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store IP override: {str(e)}")
+
+        self.cache.invalidate(tenant_id)
+        logger.info(f"Created IP override {canonical} for tenant {tenant_id}")
+        return override
+
+    def list_overrides(self, tenant_id: str) -> List[IpRangeOverride]:
+        """List a tenant's overrides, ordered by network."""
+        return (
+            self.session.query(IpRangeOverride)
+            .filter(IpRangeOverride.tenant_id == tenant_id)
+            .order_by(IpRangeOverride.network)
+            .all()
+        )
+
+    def get_override(self, tenant_id: str, override_id: str) -> Optional[IpRangeOverride]:
+        """Fetch an override owned by the tenant (None for other tenants' overrides)."""
+        return (
+            self.session.query(IpRangeOverride)
+            .filter(
+                IpRangeOverride.override_id == override_id,
+                IpRangeOverride.tenant_id == tenant_id,
+            )
+            .first()
+        )
+
+    def delete_override(self, override: IpRangeOverride) -> None:
+        """Delete an override.
+
+        Raises:
+            ValueError: If the override cannot be deleted
+        """
+        tenant_id = override.tenant_id
+        try:
+            self.session.delete(override)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to delete IP override: {str(e)}")
+        self.cache.invalidate(tenant_id)
+        logger.info(f"Deleted IP override {override.network} for tenant {tenant_id}")
+
+    def table(self, tenant_id: str) -> OverrideTable:
+        """The tenant's compiled overrides for lookups."""
+        return self.cache.get(self.session, tenant_id)
+
+
+# Global table cache (shared across requests)
+_ip_override_cache: Optional[IpOverrideTableCache] = None
+
+
+def get_ip_override_cache() -> IpOverrideTableCache:
+    """Get the global override table cache, sized from IP_OVERRIDE_CACHE_SIZE."""
+    global _ip_override_cache
+    if _ip_override_cache is None:
+        from src.config import get_config
+
+        _ip_override_cache = IpOverrideTableCache(get_config().ip_override_cache_size)
+    return _ip_override_cache
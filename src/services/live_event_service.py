@@ -0,0 +1,259 @@
+"""In-process fan-out of live tracking events to connected clients.
+
+Position reports, the geofence alerts they confirm and tenant detections
+are published to the hub; each WebSocket or SSE client holds a subscription
+filtered by tenant and optionally by entity, geofence and event type.
+Every subscription has a bounded queue: when a client falls behind, the
+oldest queued events are dropped rather than slowing down publishers.
+
+The hub lives in one process, so with several API workers a client only
+sees the events reported to the worker it is connected to.
+"""
+
+import asyncio
+import logging
+import threading
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from typing import Any, Dict, FrozenSet, Iterable, List, Optional
+
+from src.services.geofence_alert_service import GeofenceAlertEvent
+
+logger = logging.getLogger(__name__)
+
+EVENT_POSITION = "position"
+EVENT_DETECTION = "detection"
+
+
+def _utc(value: datetime) -> datetime:
+    """Naive UTC datetime, as the geofence services use."""
+    if value.tzinfo is not None:
+        value = value.astimezone(timezone.utc).replace(tzinfo=None)
+    return value
+
+
+@dataclass
+class LiveEvent:
+    """Event pushed to subscribers."""
+    type: str                       # position, geofence.enter/exit/dwell or detection
+    tenant_id: str
+    occurred_at: datetime           # Naive UTC
+    entity_id: Optional[str] = None
+    geofence_ids: List[str] = field(default_factory=list)  # Fences the event concerns
+    data: Dict[str, Any] = field(default_factory=dict)    # JSON-ready payload
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Message sent to clients (the tenant is implied by the subscription)."""
+        return {
+            "type": self.type,
+            "entity_id": self.entity_id,
+            "occurred_at": self.occurred_at.replace(tzinfo=timezone.utc).isoformat(),
+            "data": self.data,
+        }
+
+
+def position_event(
+    tenant_id: str,
+    entity_id: str,
+    latitude: float,
+    longitude: float,
+    geofence_ids: List[str],
+    observed_at: Optional[datetime] = None,
+) -> LiveEvent:
+    """Event for a reported entity position (observed_at defaults to now)."""
+    occurred_at = _utc(observed_at) if observed_at is not None else datetime.utcnow()
+    return LiveEvent(
+        type=EVENT_POSITION,
+        tenant_id=tenant_id,
+        occurred_at=occurred_at,
+        entity_id=entity_id,
+        geofence_ids=list(geofence_ids),
+        data={"latitude": latitude, "longitude": longitude, "geofence_ids": list(geofence_ids)},
+    )
+
+
+def alert_event(tenant_id: str, alert: GeofenceAlertEvent) -> LiveEvent:
+    """Event for a confirmed geofence transition (typed like its webhook event)."""
+    data = alert.to_dict()
+    data["occurred_at"] = data["occurred_at"].isoformat()
+    return LiveEvent(
+        type=alert.webhook_event,
+        tenant_id=tenant_id,
+        occurred_at=alert.occurred_at,
+        entity_id=alert.entity_id,
+        geofence_ids=[alert.geofence_id],
+        data=data,
+    )
+
+
+def detection_event(
+    tenant_id: str,
+    detection_id: str,
+    object_class: str,
+    camera_id: str,
+    latitude: float,
+    longitude: float,
+    confidence_flag: str,
+    geofence_ids: List[str],
+    captured_at: datetime,
+) -> LiveEvent:
+    """Event for a geolocated detection (dated to the image capture)."""
+    return LiveEvent(
+        type=EVENT_DETECTION,
+        tenant_id=tenant_id,
+        occurred_at=_utc(captured_at),
+        geofence_ids=list(geofence_ids),
+        data={
+            "detection_id": detection_id,
+            "object_class": object_class,
+            "camera_id": camera_id,
+            "latitude": latitude,
+            "longitude": longitude,
+            "confidence_flag": confidence_flag,
+            "geofence_ids": list(geofence_ids),
+        },
+    )
+
+
+class Subscription:
+    """A client's filtered view of the event stream."""
+
+    def __init__(
+        self,
+        tenant_id: str,
+        entity_id: Optional[str] = None,
+        geofence_ids: Optional[Iterable[str]] = None,
+        types: Optional[Iterable[str]] = None,
+        queue_size: int = 100,
+    ):
+        """Initialize subscription.
+
+        Args:
+            tenant_id: Tenant whose events are received
+            entity_id: Only events of this entity (None: all entities)
+            geofence_ids: Only events concerning these geofences (None: all);
+                position events match when the position is in one of them
+            types: Only these event types (None: all)
+            queue_size: Events held for a slow client before the oldest are dropped
+        """
+        self.tenant_id = tenant_id
+        self.entity_id = entity_id
+        self.geofence_ids: Optional[FrozenSet[str]] = frozenset(geofence_ids) if geofence_ids else None
+        self.types: Optional[FrozenSet[str]] = frozenset(types) if types else None
+        self.queue: "asyncio.Queue[LiveEvent]" = asyncio.Queue(maxsize=max(1, queue_size))
+        self.dropped = 0
+        try:
+            self._loop: Optional[asyncio.AbstractEventLoop] = asyncio.get_running_loop()
+        except RuntimeError:
+            self._loop = None
+
+    def matches(self, event: LiveEvent) -> bool:
+        """True if the event passes the subscription's filters."""
+        if event.tenant_id != self.tenant_id:
+            return False
+        if self.entity_id is not None and event.entity_id != self.entity_id:
+            return False
+        if self.types is not None and event.type not in self.types:
+            return False
+        if self.geofence_ids is not None and self.geofence_ids.isdisjoint(event.geofence_ids):
+            return False
+        return True
+
+    def offer(self, event: LiveEvent) -> None:
+        """Queue an event, dropping the oldest one if the client is behind.
+
+        Safe to call from any thread: the event is handed to the loop the
+        subscription was created on.
+        """
+        if self._loop is not None and self._loop.is_running() and not self._on_loop():
+            self._loop.call_soon_threadsafe(self._put, event)
+        else:
+            self._put(event)
+
+    def _on_loop(self) -> bool:
+        try:
+            return asyncio.get_running_loop() is self._loop
+        except RuntimeError:
+            return False
+
+    def _put(self, event: LiveEvent) -> None:
+        if self.queue.full():
+            self.queue.get_nowait()
+            self.dropped += 1
+        self.queue.put_nowait(event)
+
+    async def get(self) -> LiveEvent:
+        """Next event (waits until one is published)."""
+        return await self.queue.get()
+
+
+class LiveEventHub:
+    """Routes published events to matching subscriptions."""
+
+    def __init__(self, queue_size: int = 100):
+        """Initialize hub.
+
+        Args:
+            queue_size: Per-subscription queue size
+        """
+        self.queue_size = queue_size
+        self._subscriptions: List[Subscription] = []
+        self._lock = threading.Lock()
+
+    def subscribe(
+        self,
+        tenant_id: str,
+        entity_id: Optional[str] = None,
+        geofence_ids: Optional[Iterable[str]] = None,
+        types: Optional[Iterable[str]] = None,
+    ) -> Subscription:
+        """Start receiving events.
+
+        Returns:
+            Subscription; pass it to unsubscribe when the client goes away
+        """
+        subscription = Subscription(tenant_id, entity_id, geofence_ids, types, self.queue_size)
+        with self._lock:
+            self._subscriptions.append(subscription)
+        return subscription
+
+    def unsubscribe(self, subscription: Subscription) -> None:
+        """Stop delivering events to a subscription."""
+        with self._lock:
+            if subscription in self._subscriptions:
+                self._subscriptions.remove(subscription)
+        if subscription.dropped:
+            logger.warning(f"Live subscriber of tenant {subscription.tenant_id} missed {subscription.dropped} events")
+
+    @property
+    def subscriber_count(self) -> int:
+        return len(self._subscriptions)
+
+    def publish(self, event: LiveEvent) -> int:
+        """Deliver an event to every matching subscription.
+
+        Returns:
+            Number of subscriptions the event was queued for
+        """
+        with self._lock:
+            subscriptions = list(self._subscriptions)
+        delivered = 0
+        for subscription in subscriptions:
+            if subscription.matches(event):
+                subscription.offer(event)
+                delivered += 1
+        return delivered
+
+
+# Global live event hub (created lazily from configuration)
+_live_event_hub: Optional[LiveEventHub] = None
+
+
+def get_live_event_hub() -> LiveEventHub:
+    """Get the global live event hub."""
+    global _live_event_hub
+    if _live_event_hub is None:
+        from src.config import get_config
+
+        _live_event_hub = LiveEventHub(get_config().live_event_queue_size)
+    return _live_event_hub
@@ -0,0 +1,177 @@
+"""Locale and language inference from a resolved location.
+
+Country languages come from the GeoNames `countryInfo.txt` export (see
+src.services.country_info_service), whose Languages column lists BCP 47
+tags by prevalence (e.g. "pt-BR,es,en,fr"
+for Brazil). A few subdivisions whose majority language differs from the
+country's first language (Quebec, Flanders, Catalonia, ...) are built in,
+so lookups there suggest the regional language first.
+"""
+
+from dataclasses import dataclass, field
+from typing import Any, Dict, Iterable, List, Optional
+
+from src.services.country_info_service import get_country_info, load_country_info
+from src.services.enrichment_service import Enricher
+from src.services.ip_lookup_service import IpLookupResult
+from src.services.subdivision_service import iso_code
+
+# ISO 3166-2 subdivision -> languages placed ahead of the country's
+_SUBDIVISION_LANGUAGES: Dict[str, List[str]] = {
+    "CA-QC": ["fr-CA"],
+    "BE-VLG": ["nl-BE"],
+    "BE-WAL": ["fr-BE"],
+    "BE-BRU": ["fr-BE", "nl-BE"],
+    "ES-CT": ["ca-ES"],
+    "ES-PV": ["eu-ES"],
+    "ES-GA": ["gl-ES"],
+    "CH-GE": ["fr-CH"],
+    "CH-VD": ["fr-CH"],
+    "CH-TI": ["it-CH"],
+    "FI-01": ["sv-FI"],  # Åland
+    "IT-32": ["de-IT"],  # Trentino-South Tyrol
+}
+
+
+def normalize_tag(tag: str) -> Optional[str]:
+    """Canonical casing of a BCP 47 language tag ("PT-br" -> "pt-BR"), or None if empty."""
+    parts = [part for part in tag.strip().replace("_", "-").split("-") if part]
+    if not parts:
+        return None
+    formatted = [parts[0].lower()]
+    for part in parts[1:]:
+        if len(part) == 2 or part.isdigit():
+            formatted.append(part.upper())  # region
+        elif len(part) == 4:
+            formatted.append(part.title())  # script
+        else:
+            formatted.append(part.lower())
+    return "-".join(formatted)
+
+
+@dataclass
+class LocaleInfo:
+    """Likely languages for a location, most likely first."""
+    locale: str  # Language with region, e.g. "pt-BR"
+    languages: List[str] = field(default_factory=list)
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {"locale": self.locale, "languages": list(self.languages)}
+
+
+class LocaleDirectory:
+    """Country and subdivision language lists."""
+
+    def __init__(self, country_languages: Optional[Dict[str, List[str]]] = None):
+        """Initialize locale directory.
+
+        Args:
+            country_languages: ISO 3166-1 alpha-2 code -> language tags by prevalence
+        """
+        self._countries: Dict[str, List[str]] = {}
+        for country, languages in (country_languages or {}).items():
+            tags = [t for t in (normalize_tag(tag) for tag in languages) if t]
+            if tags:
+                self._countries[country.upper()] = tags
+
+    def __len__(self) -> int:
+        return len(self._countries)
+
+    @classmethod
+    def from_country_info(cls, path: str) -> "LocaleDirectory":
+        """Load a GeoNames countryInfo.txt file.
+
+        Raises:
+            OSError: If the file cannot be read
+            ValueError: If the file has no country rows
+        """
+        return cls({code: info.languages for code, info in load_country_info(path).items()})
+
+    def infer(
+        self, country_code: Optional[str], subdivision_codes: Iterable[str] = ()
+    ) -> Optional[LocaleInfo]:
+        """Likely locale and languages for a country and its subdivisions.
+
+        Args:
+            country_code: ISO 3166-1 alpha-2 code
+            subdivision_codes: ISO 3166-2 codes, full ("CA-QC") or bare ("QC"),
+                any order; the most specific known override wins
+
+        Returns:
+            LocaleInfo, or None if the country is unknown
+        """
+        if not country_code:
+            return None
+        country = country_code.strip().upper()
+        languages = list(self._countries.get(country, []))
+        if not languages:
+            return None
+
+        for code in reversed(list(subdivision_codes)):
+            regional = _SUBDIVISION_LANGUAGES.get(iso_code(country, code))
+            if regional:
+                languages = regional + [tag for tag in languages if tag not in regional]
+                break
+
+        first = languages[0]
+        locale = first if "-" in first else f"{first}-{country}"
+        return LocaleInfo(locale=locale, languages=languages)
+
+
+class LocaleEnricher(Enricher):
+    """Adds the likely locale and languages to lookups."""
+
+    name = "locale"
+
+    def __init__(self, directory: LocaleDirectory):
+        """Initialize locale enricher.
+
+        Args:
+            directory: Country language lists
+        """
+        self.directory = directory
+
+    def enrich(self, result: IpLookupResult) -> Optional[Dict[str, Any]]:
+        """Infer the locale from the record's country and subdivisions."""
+        record = result.record
+        if record is None:
+            return None
+        info = self.directory.infer(
+            record.country_iso_code,
+            [sub["iso_code"] for sub in record.subdivisions if sub.get("iso_code")],
+        )
+        return info.to_dict() if info is not None else None
+
+
+def build_locale_enricher(config) -> Optional[LocaleEnricher]:
+    """Create the locale enricher from COUNTRY_INFO_PATH.
+
+    Args:
+        config: Application configuration
+
+    Returns:
+        LocaleEnricher, or None if no dataset is configured or it cannot be read
+    """
+    directory = get_locale_directory(config)
+    if not directory:
+        return None
+    return LocaleEnricher(directory)
+
+
+# Global locale directory (built lazily from the country info table)
+_locale_directory: Optional[LocaleDirectory] = None
+
+
+def get_locale_directory(config=None) -> LocaleDirectory:
+    """Get the global locale directory.
+
+    Returns:
+        LocaleDirectory (empty if the dataset is unavailable)
+    """
+    global _locale_directory
+    if _locale_directory is None:
+        _locale_directory = LocaleDirectory(
+            {code: info.languages for code, info in get_country_info(config).items()}
+        )
+    return _locale_directory
@@ -0,0 +1,496 @@
+"""Per-tenant envelope encryption of device location history.
+
+Each tenant's sightings are sealed with AES-256-GCM under a data key of
+that tenant. Data keys are only stored wrapped (encrypted) by a master
+key: an AWS KMS key (LOCATION_ENCRYPTION_KMS_KEY_ID) or, without KMS, a
+256-bit key held by the service (LOCATION_ENCRYPTION_MASTER_KEY, e.g.
+from the secrets backend). A database dump alone therefore exposes no
+tenant's movements, and a tenant's data key opens no other tenant's
+rows: the wrapped key is bound to its tenant, each ciphertext to its
+tenant, device and data key.
+
+The city, coordinate, accuracy and IP address of a sighting are sealed;
+its country and time stay in clear so that the history queries (countries
+seen, seen in a country, time ranges) keep using their indexes.
+
+Rotation:
+- rotate_data_key retires a tenant's data key. New sightings are sealed
+  with a fresh key; the retired one keeps opening its rows until
+  reencrypt moves them to the active key, and is deleted once unused.
+  reencrypt also seals rows stored before encryption was enabled.
+- rewrap_data_keys re-wraps the data keys under the current master key
+  after it changes, without touching the rows. A replaced local master
+  key stays usable as LOCATION_ENCRYPTION_PREVIOUS_MASTER_KEY until then;
+  KMS decrypts with whichever KMS key wrapped a data key.
+"""
+
+import base64
+import binascii
+import hashlib
+import json
+import logging
+import os
+import threading
+import uuid
+from dataclasses import dataclass
+from datetime import datetime
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+from sqlalchemy import func, or_
+from sqlalchemy.orm import Session
+
+from src.models.database_models import DeviceLocation, TenantDataKey
+
+logger = logging.getLogger(__name__)
+
+# Sighting fields kept in DeviceLocation.sealed
+SEALED_FIELDS = ("city_name", "latitude", "longitude", "accuracy_km", "ip_address")
+
+_FORMAT = "v1"
+_NONCE_BYTES = 12
+_DATA_KEY_BYTES = 32
+
+# Prefix of local master key IDs (KMS keys are named by their ARN or alias)
+LOCAL_KEY_PREFIX = "local:"
+
+
+def _aesgcm(key: bytes):
+    try:
+        from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+    except ImportError:
+        raise RuntimeError("cryptography is required for location encryption (the encryption extra)")
+    return AESGCM(key)
+
+
+def _tenant_label(tenant_id: Optional[str]) -> str:
+    return tenant_id or "(anonymous)"
+
+
+class MasterKey:
+    """Base class for keys wrapping tenant data keys."""
+
+    key_id = ""
+
+    def wrap(self, data_key: bytes, tenant_id: Optional[str]) -> bytes:
+        """Encrypt a data key for a tenant.
+
+        Raises:
+            RuntimeError: If the key service fails
+        """
+        raise NotImplementedError
+
+    def unwrap(self, wrapped: bytes, tenant_id: Optional[str]) -> bytes:
+        """Decrypt a data key of a tenant.
+
+        Raises:
+            RuntimeError: If the key service fails or the key is not the tenant's
+        """
+        raise NotImplementedError
+
+    def can_unwrap(self, master_key_id: str) -> bool:
+        """Whether data keys wrapped by a master key can be unwrapped with this one."""
+        return master_key_id == self.key_id
+
+
+class LocalMasterKey(MasterKey):
+    """256-bit key held by the service; wraps with AES-GCM."""
+
+    def __init__(self, key: bytes):
+        """Initialize local master key.
+
+        Args:
+            key: 32 random bytes
+
+        Raises:
+            ValueError: If the key is not 32 bytes
+        """
+        if len(key) != _DATA_KEY_BYTES:
+            raise ValueError("Location encryption master key must be 32 bytes (base64-encoded)")
+        self._key = key
+        self.key_id = LOCAL_KEY_PREFIX + hashlib.sha256(key).hexdigest()[:16]
+
+    @classmethod
+    def from_base64(cls, value: str) -> "LocalMasterKey":
+        """Key from its base64 encoding (e.g. `openssl rand -base64 32`).
+
+        Raises:
+            ValueError: If the value is not a base64-encoded 32-byte key
+        """
+        try:
+            key = base64.b64decode(value.strip(), validate=True)
+        except (binascii.Error, ValueError):
+            raise ValueError("Location encryption master key must be base64-encoded")
+        return cls(key)
+
+    def wrap(self, data_key: bytes, tenant_id: Optional[str]) -> bytes:
+        """Encrypt a data key, bound to its tenant."""
+        nonce = os.urandom(_NONCE_BYTES)
+        return nonce + _aesgcm(self._key).encrypt(nonce, data_key, _tenant_label(tenant_id).encode())
+
+    def unwrap(self, wrapped: bytes, tenant_id: Optional[str]) -> bytes:
+        """Decrypt a data key of a tenant."""
+        cipher = _aesgcm(self._key)
+        try:
+            return cipher.decrypt(wrapped[:_NONCE_BYTES], wrapped[_NONCE_BYTES:], _tenant_label(tenant_id).encode())
+        except Exception:
+            raise RuntimeError(f"Cannot unwrap data key of tenant {_tenant_label(tenant_id)}")
+
+
+class KmsMasterKey(MasterKey):
+    """AWS KMS key; the tenant is the encryption context of its data keys."""
+
+    def __init__(self, kms_key_id: str, region_name: str = "", client=None):
+        """Initialize KMS master key.
+
+        Args:
+            kms_key_id: KMS key ID, ARN or alias
+            region_name: AWS region (empty: the SDK's default)
+            client: Optional boto3 KMS client (created on demand)
+        """
+        self.key_id = kms_key_id
+        self.region_name = region_name
+        self._client = client
+
+    def _get_client(self):
+        if self._client is None:
+            try:
+                import boto3
+            except ImportError:
+                raise RuntimeError("boto3 is required for KMS location encryption")
+            self._client = boto3.client("kms", region_name=self.region_name or None)
+        return self._client
+
+    def can_unwrap(self, master_key_id: str) -> bool:
+        """KMS ciphertexts name their key, so any KMS-wrapped data key qualifies."""
+        return not master_key_id.startswith(LOCAL_KEY_PREFIX)
+
+    def wrap(self, data_key: bytes, tenant_id: Optional[str]) -> bytes:
+        """Encrypt a data key with KMS."""
+        client = self._get_client()
+        try:
+            response = client.encrypt(
+                KeyId=self.key_id, Plaintext=data_key, EncryptionContext={"tenant_id": _tenant_label(tenant_id)}
+            )
+        except Exception as e:
+            raise RuntimeError(f"KMS encrypt failed: {str(e)}")
+        return response["CiphertextBlob"]
+
+    def unwrap(self, wrapped: bytes, tenant_id: Optional[str]) -> bytes:
+        """Decrypt a data key with KMS."""
+        client = self._get_client()
+        try:
+            response = client.decrypt(
+                CiphertextBlob=wrapped, EncryptionContext={"tenant_id": _tenant_label(tenant_id)}
+            )
+        except Exception as e:
+            raise RuntimeError(f"KMS decrypt failed: {str(e)}")
+        return response["Plaintext"]
+
+
+@dataclass
+class ReencryptResult:
+    """Outcome of one reencrypt batch."""
+    reencrypted: int            # Rows sealed with the active key
+    remaining: int              # Rows still under a retired key or in plaintext
+    deleted_keys: List[str]     # Retired keys no row used any more
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {"reencrypted": self.reencrypted, "remaining": self.remaining, "deleted_keys": self.deleted_keys}
+
+
+# Unwrapped data keys by key ID (unwrapping can be a KMS call)
+_data_keys: Dict[str, bytes] = {}
+_data_keys_lock = threading.Lock()
+
+
+def _row_context(tenant_id: Optional[str], device_id: str, key_id: str) -> bytes:
+    return json.dumps([_tenant_label(tenant_id), device_id, key_id]).encode()
+
+
+class LocationCipher:
+    """Seals and opens location history with per-tenant data keys."""
+
+    def __init__(self, session: Session, master_key: MasterKey, previous_master_keys: Iterable[MasterKey] = ()):
+        """Initialize cipher.
+
+        Args:
+            session: SQLAlchemy database session (data keys are stored in it)
+            master_key: Wraps new data keys
+            previous_master_keys: Replaced master keys, still unwrapping
+                the data keys they wrapped until rewrap_data_keys
+        """
+        self.session = session
+        self.master_key = master_key
+        self.previous_master_keys = list(previous_master_keys)
+
+    def _unwrap(self, row: TenantDataKey) -> bytes:
+        with _data_keys_lock:
+            cached = _data_keys.get(row.key_id)
+        if cached is not None:
+            return cached
+        for master_key in [self.master_key, *self.previous_master_keys]:
+            if master_key.can_unwrap(row.master_key_id):
+                data_key = master_key.unwrap(base64.b64decode(row.wrapped_key), row.tenant_id)
+                with _data_keys_lock:
+                    _data_keys[row.key_id] = data_key
+                return data_key
+        raise RuntimeError(f"No master key can unwrap data key {row.key_id} (wrapped by {row.master_key_id})")
+
+    def _data_key(self, key_id: str) -> bytes:
+        with _data_keys_lock:
+            cached = _data_keys.get(key_id)
+        if cached is not None:
+            return cached
+        row = self.session.query(TenantDataKey).filter(TenantDataKey.key_id == key_id).first()
+        if row is None:
+            raise RuntimeError(f"Data key {key_id} not found")
+        return self._unwrap(row)
+
+    def _new_key(self, tenant_id: Optional[str]) -> TenantDataKey:
+        data_key = os.urandom(_DATA_KEY_BYTES)
+        row = TenantDataKey(
+            key_id=uuid.uuid4().hex,
+            tenant_id=tenant_id,
+            wrapped_key=base64.b64encode(self.master_key.wrap(data_key, tenant_id)).decode(),
+            master_key_id=self.master_key.key_id,
+        )
+        with _data_keys_lock:
+            _data_keys[row.key_id] = data_key
+        return row
+
+    def active_key(self, tenant_id: Optional[str]) -> TenantDataKey:
+        """The tenant's current data key, created on first use.
+
+        Raises:
+            RuntimeError: If the master key cannot wrap a new key, or the
+                key cannot be stored
+        """
+        row = (
+            self.session.query(TenantDataKey)
+            .filter(TenantDataKey.tenant_id == tenant_id, TenantDataKey.retired_at.is_(None))
+            .order_by(TenantDataKey.created_at.desc(), TenantDataKey.id.desc())
+            .first()
+        )
+        if row is not None:
+            return row
+        row = self._new_key(tenant_id)
+        try:
+            self.session.add(row)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise RuntimeError(f"Failed to store data key: {str(e)}")
+        logger.info(f"Created location data key {row.key_id} for tenant {_tenant_label(tenant_id)}")
+        return row
+
+    def seal(self, tenant_id: Optional[str], device_id: str, fields: Dict[str, Any]) -> Tuple[str, str]:
+        """Encrypt a sighting's precise fields.
+
+        Args:
+            tenant_id: Tenant the sighting belongs to
+            device_id: Device seen
+            fields: Values of SEALED_FIELDS
+
+        Returns:
+            (data key ID, sealed value) for DeviceLocation.data_key_id and .sealed
+
+        Raises:
+            RuntimeError: If the tenant's data key is unavailable
+        """
+        key = self.active_key(tenant_id)
+        nonce = os.urandom(_NONCE_BYTES)
+        plaintext = json.dumps({name: fields.get(name) for name in SEALED_FIELDS}, separators=(",", ":"))
+        ciphertext = _aesgcm(self._unwrap(key)).encrypt(
+            nonce, plaintext.encode(), _row_context(tenant_id, device_id, key.key_id)
+        )
+        return key.key_id, f"{_FORMAT}:{base64.b64encode(nonce + ciphertext).decode()}"
+
+    def open(self, row: DeviceLocation) -> Dict[str, Any]:
+        """Decrypt the precise fields of a stored sighting.
+
+        Raises:
+            RuntimeError: If its data key is unavailable or the row was
+                altered or moved to another tenant or device
+        """
+        version, _, payload = (row.sealed or "").partition(":")
+        if version != _FORMAT or not row.data_key_id:
+            raise RuntimeError(f"Device location {row.id} has an unknown encryption format")
+        cipher = _aesgcm(self._data_key(row.data_key_id))
+        try:
+            raw = base64.b64decode(payload)
+            plaintext = cipher.decrypt(
+                raw[:_NONCE_BYTES], raw[_NONCE_BYTES:], _row_context(row.tenant_id, row.device_id, row.data_key_id)
+            )
+        except Exception:
+            raise RuntimeError(f"Cannot decrypt device location {row.id}")
+        return json.loads(plaintext)
+
+    def list_keys(self, tenant_id: Optional[str]) -> List[Tuple[TenantDataKey, int]]:
+        """A tenant's data keys, oldest first, with the rows each seals."""
+        keys = (
+            self.session.query(TenantDataKey)
+            .filter(TenantDataKey.tenant_id == tenant_id)
+            .order_by(TenantDataKey.created_at, TenantDataKey.id)
+            .all()
+        )
+        counts = dict(
+            self.session.query(DeviceLocation.data_key_id, func.count(DeviceLocation.id))
+            .filter(DeviceLocation.data_key_id.in_([k.key_id for k in keys]))
+            .group_by(DeviceLocation.data_key_id)
+            .all()
+        ) if keys else {}
+        return [(key, counts.get(key.key_id, 0)) for key in keys]
+
+    def rotate_data_key(self, tenant_id: Optional[str]) -> TenantDataKey:
+        """Retire the tenant's data key; new sightings are sealed with a fresh one.
+
+        Rows sealed with the retired key stay readable; move them with reencrypt.
+
+        Raises:
+            RuntimeError: If the new key cannot be wrapped or stored
+        """
+        now = datetime.utcnow()
+        row = self._new_key(tenant_id)
+        try:
+            self.session.query(TenantDataKey).filter(
+                TenantDataKey.tenant_id == tenant_id, TenantDataKey.retired_at.is_(None)
+            ).update({TenantDataKey.retired_at: now}, synchronize_session=False)
+            self.session.add(row)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise RuntimeError(f"Failed to rotate data key: {str(e)}")
+        logger.info(f"Rotated location data key of tenant {_tenant_label(tenant_id)} to {row.key_id}")
+        return row
+
+    def _stale_rows(self, tenant_id: Optional[str], active_key_id: str):
+        return self.session.query(DeviceLocation).filter(
+            DeviceLocation.tenant_id == tenant_id,
+            or_(DeviceLocation.data_key_id.is_(None), DeviceLocation.data_key_id != active_key_id),
+        )
+
+    def reencrypt(self, tenant_id: Optional[str], limit: int = 1000) -> ReencryptResult:
+        """Seal up to `limit` of the tenant's rows with its active data key.
+
+        Picks rows under retired keys and rows stored in plaintext, then
+        deletes the retired keys no row uses any more.
+
+        Raises:
+            RuntimeError: If a key is unavailable, a row cannot be opened,
+                or the batch cannot be stored
+        """
+        active = self.active_key(tenant_id)
+        rows = self._stale_rows(tenant_id, active.key_id).order_by(DeviceLocation.id).limit(limit).all()
+        for row in rows:
+            if row.sealed:
+                fields = self.open(row)
+            else:
+                fields = {name: getattr(row, name) for name in SEALED_FIELDS}
+            row.data_key_id, row.sealed = self.seal(tenant_id, row.device_id, fields)
+            for name in SEALED_FIELDS:
+                setattr(row, name, None)
+        try:
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise RuntimeError(f"Failed to store re-encrypted rows: {str(e)}")
+
+        deleted = []
+        for key, rows_sealed in self.list_keys(tenant_id):
+            if key.retired_at is not None and rows_sealed == 0:
+                self.session.delete(key)
+                deleted.append(key.key_id)
+        if deleted:
+            try:
+                self.session.commit()
+            except Exception as e:
+                self.session.rollback()
+                raise RuntimeError(f"Failed to delete retired data keys: {str(e)}")
+            with _data_keys_lock:
+                for key_id in deleted:
+                    _data_keys.pop(key_id, None)
+            logger.info(f"Deleted retired location data keys {', '.join(deleted)}")
+        remaining = self._stale_rows(tenant_id, active.key_id).count()
+        return ReencryptResult(reencrypted=len(rows), remaining=remaining, deleted_keys=deleted)
+
+    def rewrap_data_keys(self) -> int:
+        """Wrap every data key not wrapped by the current master key with it.
+
+        Returns:
+            Number of keys re-wrapped
+
+        Raises:
+            RuntimeError: If a key cannot be unwrapped or re-wrapped, or storage fails
+        """
+        rows = (
+            self.session.query(TenantDataKey)
+            .filter(TenantDataKey.master_key_id != self.master_key.key_id)
+            .all()
+        )
+        for row in rows:
+            data_key = self._unwrap(row)
+            row.wrapped_key = base64.b64encode(self.master_key.wrap(data_key, row.tenant_id)).decode()
+            row.master_key_id = self.master_key.key_id
+        try:
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise RuntimeError(f"Failed to store re-wrapped data keys: {str(e)}")
+        if rows:
+            logger.info(f"Re-wrapped {len(rows)} location data keys with {self.master_key.key_id}")
+        return len(rows)
+
+
+def build_master_keys(config) -> Tuple[Optional[MasterKey], List[MasterKey]]:
+    """Master keys from the LOCATION_ENCRYPTION_* settings.
+
+    Returns:
+        (current master key or None when encryption is off, previous
+        master keys)
+
+    Raises:
+        ValueError: If a local master key is malformed
+    """
+    previous = []
+    if config.location_encryption_previous_master_key:
+        previous.append(LocalMasterKey.from_base64(config.location_encryption_previous_master_key))
+    if config.location_encryption_kms_key_id:
+        return KmsMasterKey(config.location_encryption_kms_key_id, config.location_encryption_kms_region), previous
+    if config.location_encryption_master_key:
+        return LocalMasterKey.from_base64(config.location_encryption_master_key), previous
+    return None, previous
+
+
+# Global master keys (built lazily from configuration, rebuilt when secrets rotate)
+_master_keys: Optional[Tuple[Optional[MasterKey], List[MasterKey]]] = None
+_master_keys_generation = 0
+
+
+def get_master_keys() -> Tuple[Optional[MasterKey], List[MasterKey]]:
+    """Get the configured master keys.
+
+    Raises:
+        RuntimeError: If the settings are malformed (sightings are then
+            refused rather than stored in plaintext)
+    """
+    global _master_keys, _master_keys_generation
+    from src.config import get_config
+    from src.services.secrets_service import secrets_generation
+
+    generation = secrets_generation()
+    if _master_keys is None or _master_keys_generation != generation:
+        try:
+            master_keys = build_master_keys(get_config())
+        except ValueError as e:
+            raise RuntimeError(f"Location encryption is misconfigured: {str(e)}")
+        _master_keys, _master_keys_generation = master_keys, generation
+    return _master_keys
+
+
+def location_cipher(session: Session) -> Optional[LocationCipher]:
+    """Cipher for location history per configuration (None when encryption is off)."""
+    master_key, previous = get_master_keys()
+    if master_key is None:
+        return None
+    return LocationCipher(session, master_key, previous)
@@ -0,0 +1,118 @@
+"""GPS versus IP location mismatch detection.
+
+A device that reports its own coordinates should be roughly where its IP
+address geolocates. The great-circle distance between the two, less the
+IP record's accuracy radius and the device's reported accuracy, is the
+unexplained discrepancy; beyond LOCATION_MISMATCH_THRESHOLD_KM the request
+is flagged. When the anonymizer flags explain the gap (VPN, Tor, proxy,
+residential proxy or hosting address) that is reported as the likely cause; otherwise the
+device coordinates themselves are suspect.
+"""
+
+from dataclasses import dataclass
+from typing import Any, Dict, Optional
+
+from src.spatial.distance import haversine
+
+
+class MismatchCause:
+    """Likely explanations of a flagged mismatch, in order of precedence."""
+    TOR = "tor"
+    VPN = "vpn"
+    PROXY = "proxy"
+    RESIDENTIAL_PROXY = "residential_proxy"
+    HOSTING = "hosting"
+    GPS_SPOOFING = "gps_spoofing"  # No anonymizer evidence for the IP address
+
+    ANONYMIZERS = (TOR, VPN, PROXY, RESIDENTIAL_PROXY, HOSTING)
+
+
+@dataclass
+class LocationMismatch:
+    """Comparison of device-reported and IP-derived locations."""
+    distance_km: float  # Between the device and the IP location
+    excess_km: float  # Distance beyond both accuracy radii
+    threshold_km: float
+    mismatch: bool
+    likely_cause: Optional[str] = None  # MismatchCause value when mismatched
+    ip_accuracy_km: Optional[float] = None
+    device_accuracy_m: Optional[float] = None
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "distance_km": self.distance_km,
+            "excess_km": self.excess_km,
+            "threshold_km": self.threshold_km,
+            "mismatch": self.mismatch,
+            "likely_cause": self.likely_cause,
+            "ip_accuracy_km": self.ip_accuracy_km,
+            "device_accuracy_m": self.device_accuracy_m,
+        }
+
+
+class LocationMismatchDetector:
+    """Flags device coordinates too far from the IP address location."""
+
+    def __init__(self, threshold_km: Optional[float] = None):
+        """Initialize mismatch detector.
+
+        Args:
+            threshold_km: Unexplained distance that is flagged (default
+                LOCATION_MISMATCH_THRESHOLD_KM)
+        """
+        if threshold_km is None:
+            from src.config import get_config
+
+            threshold_km = get_config().location_mismatch_threshold_km
+        self.threshold_km = threshold_km
+
+    def compare(
+        self,
+        device_latitude: float,
+        device_longitude: float,
+        ip_latitude: float,
+        ip_longitude: float,
+        ip_accuracy_km: Optional[float] = None,
+        device_accuracy_m: Optional[float] = None,
+        anonymizer: Optional[Dict[str, Optional[bool]]] = None,
+    ) -> LocationMismatch:
+        """Compare a device-reported coordinate with the IP location.
+
+        Args:
+            device_latitude, device_longitude: Coordinate reported by the device
+            ip_latitude, ip_longitude: Coordinate of the IP record
+            ip_accuracy_km: Accuracy radius of the IP record
+            device_accuracy_m: Accuracy the device reported for its coordinate
+            anonymizer: Anonymizer flags of the IP (vpn, tor, proxy, hosting)
+
+        Returns:
+            LocationMismatch
+
+        Raises:
+            ValueError: If a coordinate is out of range or an accuracy is negative
+        """
+        if (ip_accuracy_km or 0) < 0 or (device_accuracy_m or 0) < 0:
+            raise ValueError("Accuracy radii must not be negative")
+        distance_km = haversine(
+            device_latitude, device_longitude, ip_latitude, ip_longitude
+        ).meters / 1000.0
+        excess_km = max(0.0, distance_km - (ip_accuracy_km or 0.0) - (device_accuracy_m or 0.0) / 1000.0)
+        mismatch = excess_km > self.threshold_km
+
+        likely_cause = None
+        if mismatch:
+            flags = anonymizer or {}
+            likely_cause = next(
+                (cause for cause in MismatchCause.ANONYMIZERS if flags.get(cause)),
+                MismatchCause.GPS_SPOOFING,
+            )
+        return LocationMismatch(
+            distance_km=round(distance_km, 1),
+            excess_km=round(excess_km, 1),
+            threshold_km=self.threshold_km,
+            mismatch=mismatch,
+            likely_cause=likely_cause,
+            ip_accuracy_km=ip_accuracy_km,
+            device_accuracy_m=device_accuracy_m,
+        )
@@ -0,0 +1,549 @@
+"""Read-through cache of lookup results.
+
+Repeated lookups of hot addresses and coordinates are answered from the
+cache instead of the GeoIP dataset and boundary indexes. Each entry is
+keyed by its lookup type, the version of the data that answered (the
+dataset build, or the boundary files) and a digest of the query, so a new
+dataset build is never answered from the previous one's entries, and
+expires after its type's TTL:
+
+- ip: IP lookups against the active dataset (CACHE_TTL_SECONDS)
+- ip_as_of: historical (as_of) IP lookups (a day)
+- reverse_geocode: reverse geocoding (an hour)
+
+IP lookups located by a tenant override are not cached. When the GeoIP
+updater activates a new build, every cached `ip` lookup is dropped
+(invalidate_lookups); `ip_as_of` answers stay valid.
+
+LOOKUP_CACHE_TTLS changes them (0 stops caching a type). Cached answers
+keep the confidence computed when they were stored, which drifts with the
+dataset age by at most the TTL.
+
+Queries not found (bogons, unroutable ranges, the open sea) are looked up
+again and again, and always miss. Their answer is cached as a not-found
+marker for LOOKUP_CACHE_NEGATIVE_TTL_SECONDS (at most the type's TTL), so
+repeats get the same 404 without a lookup.
+
+With LOOKUP_CACHE_REDIS_URL the cache is shared by all workers
+(`pip install -e ".[redis]"`); otherwise each process keeps its
+CACHE_MAX_ENTRIES most recent entries. With Redis each process still
+keeps that many in memory (L1) for at most LOOKUP_CACHE_L1_TTL_SECONDS, so
+hot entries cost no round trip. A cache that cannot be reached counts as
+empty: lookups are answered from the data, never failed. Each tier's
+hits, misses and latency are measured per lookup type, to tune the TTLs.
+
+Concurrent misses of one entry are looked up once (singleflight): the
+other callers wait for that answer, so a thundering herd for one address
+costs one dataset lookup and one Redis write.
+"""
+
+import hashlib
+import json
+import logging
+import threading
+import time
+from collections import OrderedDict
+from typing import Any, Callable, Dict, List, Optional, Sequence, Tuple, Type, TypeVar, Union
+
+from pydantic import BaseModel
+
+logger = logging.getLogger(__name__)
+
+M = TypeVar("M", bound=BaseModel)
+
+
+class LookupType:
+    """Lookup types with their own TTL."""
+    IP = "ip"
+    IP_AS_OF = "ip_as_of"
+    REVERSE_GEOCODE = "reverse_geocode"
+
+    ALL = (IP, IP_AS_OF, REVERSE_GEOCODE)
+
+
+DEFAULT_TTLS = {LookupType.IP_AS_OF: 86400, LookupType.REVERSE_GEOCODE: 3600}
+
+# Stored instead of a result for queries not found; no JSON document starts with it
+NOT_FOUND_MARKER = "!not-found:"
+
+
+def parse_ttls(raw: str, default_ttl: int) -> Dict[str, int]:
+    """TTLs of every lookup type, from a JSON object of type -> seconds.
+
+    Args:
+        raw: LOOKUP_CACHE_TTLS (empty: the defaults)
+        default_ttl: TTL of ip lookups unless named (CACHE_TTL_SECONDS)
+
+    Raises:
+        ValueError: If it is not an object of known types to non-negative integers
+    """
+    ttls = {LookupType.IP: default_ttl, **DEFAULT_TTLS}
+    if not raw.strip():
+        return ttls
+    try:
+        parsed = json.loads(raw)
+    except json.JSONDecodeError as e:
+        raise ValueError(f"LOOKUP_CACHE_TTLS is not valid JSON: {e}")
+    if not isinstance(parsed, dict):
+        raise ValueError("LOOKUP_CACHE_TTLS must be a JSON object of lookup type -> seconds")
+    for kind, ttl in parsed.items():
+        if kind not in LookupType.ALL:
+            raise ValueError(
+                f"Unknown lookup type in LOOKUP_CACHE_TTLS: {kind} (expected one of {', '.join(LookupType.ALL)})"
+            )
+        if not isinstance(ttl, int) or isinstance(ttl, bool) or ttl < 0:
+            raise ValueError(f"TTL of {kind} lookups must be a non-negative integer, got {ttl!r}")
+        ttls[kind] = ttl
+    return ttls
+
+
+def query_key(version: str, query: Any) -> str:
+    """Store key part of a query against a data version."""
+    digest = hashlib.sha256(json.dumps(query, sort_keys=True, default=str).encode()).hexdigest()
+    return f"{version}:{digest[:32]}"
+
+
+class LookupCacheStore:
+    """Storage of cached results with expiry."""
+
+    def get(self, key: str) -> Optional[str]:
+        raise NotImplementedError
+
+    def set(self, key: str, value: str, ttl_seconds: int) -> None:
+        raise NotImplementedError
+
+    def delete_prefix(self, prefix: str) -> int:
+        """Drop the entries whose key starts with prefix.
+
+        Returns:
+            Entries dropped
+        """
+        raise NotImplementedError
+
+
+class InMemoryLookupCacheStore(LookupCacheStore):
+    """Process-local store of the most recently used entries (not shared between workers)."""
+
+    def __init__(self, max_entries: int = 1000, clock=time.monotonic):
+        self.max_entries = max(1, max_entries)
+        self._entries: "OrderedDict[str, Tuple[float, str]]" = OrderedDict()
+        self._lock = threading.Lock()
+        self._clock = clock
+
+    def get(self, key: str) -> Optional[str]:
+        now = self._clock()
+        with self._lock:
+            entry = self._entries.get(key)
+            if entry is None:
+                return None
+            expires, value = entry
+            if expires <= now:
+                del self._entries[key]
+                return None
+            self._entries.move_to_end(key)
+            return value
+
+    def set(self, key: str, value: str, ttl_seconds: int) -> None:
+        with self._lock:
+            self._entries[key] = (self._clock() + ttl_seconds, value)
+            self._entries.move_to_end(key)
+            while len(self._entries) > self.max_entries:
+                self._entries.popitem(last=False)
+
+    def delete_prefix(self, prefix: str) -> int:
+        with self._lock:
+            keys = [key for key in self._entries if key.startswith(prefix)]
+            for key in keys:
+                del self._entries[key]
+        return len(keys)
+
+    def __len__(self) -> int:
+        return len(self._entries)
+
+
+class RedisLookupCacheStore(LookupCacheStore):
+    """Redis strings with expiry."""
+
+    def __init__(self, client):
+        """Initialize Redis store.
+
+        Args:
+            client: redis.Redis client
+        """
+        self.client = client
+
+    @classmethod
+    def from_url(cls, url: str) -> "RedisLookupCacheStore":
+        """Connect to Redis.
+
+        Raises:
+            RuntimeError: If the redis package is not installed
+        """
+        try:
+            import redis
+        except ImportError:
+            raise RuntimeError("redis is required for LOOKUP_CACHE_REDIS_URL")
+        return cls(redis.Redis.from_url(url))
+
+    def get(self, key: str) -> Optional[str]:
+        value = self.client.get(key)
+        return value.decode() if isinstance(value, bytes) else value
+
+    def set(self, key: str, value: str, ttl_seconds: int) -> None:
+        self.client.set(key, value, ex=ttl_seconds)
+
+    def delete_prefix(self, prefix: str) -> int:
+        # SCAN rather than KEYS, which would block Redis for the whole keyspace
+        deleted = 0
+        batch: List[Any] = []
+        for key in self.client.scan_iter(match=f"{prefix}*", count=1000):
+            batch.append(key)
+            if len(batch) == 1000:
+                deleted += self.client.unlink(*batch)
+                batch = []
+        if batch:
+            deleted += self.client.unlink(*batch)
+        return deleted
+
+
+class CacheTier:
+    """One tier of a tiered cache: a store, its name in metrics, and the longest it keeps entries.
+
+    Reads are counted (hit, miss, error) and reads and writes timed per
+    tier and lookup type, so the hit ratio and cost of each tier can be
+    compared when tuning TTLs.
+    """
+
+    def __init__(self, name: str, store: LookupCacheStore, max_ttl_seconds: Optional[int] = None):
+        """Initialize tier.
+
+        Args:
+            name: Tier label of the metrics (l1, l2)
+            store: Entry storage
+            max_ttl_seconds: Longest time an entry is kept (None: its own TTL)
+        """
+        self.name = name
+        self.store = store
+        self.max_ttl_seconds = max_ttl_seconds
+
+    def get(self, kind: str, key: str) -> Optional[str]:
+        from src.metrics import LOOKUP_CACHE_TIER_REQUESTS, LOOKUP_CACHE_TIER_SECONDS
+
+        started = time.perf_counter()
+        try:
+            value = self.store.get(key)
+        except Exception:
+            LOOKUP_CACHE_TIER_REQUESTS.labels(tier=self.name, type=kind, result="error").inc()
+            raise
+        finally:
+            LOOKUP_CACHE_TIER_SECONDS.labels(tier=self.name, type=kind, operation="get").observe(
+                time.perf_counter() - started
+            )
+        LOOKUP_CACHE_TIER_REQUESTS.labels(tier=self.name, type=kind, result="miss" if value is None else "hit").inc()
+        return value
+
+    def set(self, kind: str, key: str, value: str, ttl_seconds: int) -> None:
+        from src.metrics import LOOKUP_CACHE_TIER_SECONDS
+
+        if self.max_ttl_seconds is not None:
+            ttl_seconds = min(ttl_seconds, self.max_ttl_seconds)
+        started = time.perf_counter()
+        try:
+            self.store.set(key, value, ttl_seconds)
+        finally:
+            LOOKUP_CACHE_TIER_SECONDS.labels(tier=self.name, type=kind, operation="set").observe(
+                time.perf_counter() - started
+            )
+
+    def delete_prefix(self, prefix: str) -> int:
+        return self.store.delete_prefix(prefix)
+
+
+class TieredCache:
+    """Cache tiers searched in order, fastest first: a process-local L1 in front of a shared L2 (Redis).
+
+    Hot entries are answered from memory without a round trip. An entry
+    found in a later tier is copied into the earlier ones that cap their
+    TTL (for that long), so entries replaced in the shared store (e.g. by
+    another worker) are picked up soon after. Writes go to every tier.
+    """
+
+    def __init__(self, tiers: Sequence[CacheTier]):
+        self.tiers = list(tiers)
+
+    @classmethod
+    def single(cls, store: LookupCacheStore) -> "TieredCache":
+        """A store alone, as the L1."""
+        return cls([CacheTier("l1", store)])
+
+    def get(self, kind: str, key: str) -> Optional[str]:
+        """The entry from the first tier holding it.
+
+        Raises:
+            Exception: The error of a tier that cannot be reached
+        """
+        for i, tier in enumerate(self.tiers):
+            value = tier.get(kind, key)
+            if value is not None:
+                for earlier in self.tiers[:i]:
+                    if earlier.max_ttl_seconds is not None:
+                        earlier.set(kind, key, value, earlier.max_ttl_seconds)
+                return value
+        return None
+
+    def set(self, kind: str, key: str, value: str, ttl_seconds: int) -> None:
+        for tier in self.tiers:
+            tier.set(kind, key, value, ttl_seconds)
+
+    def delete_prefix(self, prefix: str) -> int:
+        """Drop the matching entries of every tier that can be reached.
+
+        Returns:
+            Entries dropped, summed over the tiers
+        """
+        deleted = 0
+        for tier in self.tiers:
+            try:
+                deleted += tier.delete_prefix(prefix)
+            except Exception as e:
+                logger.warning(f"Failed to invalidate lookup cache tier {tier.name}: {e}")
+        return deleted
+
+
+class _Flight:
+    """One lookup being computed, with its outcome for the callers waiting on it."""
+
+    def __init__(self):
+        self.done = threading.Event()
+        self.value: Optional[str] = None
+        self.error: Optional[BaseException] = None
+
+
+class SingleFlight:
+    """Runs one call per key at a time; concurrent callers of a key share its outcome.
+
+    Callers are threads (gRPC workers, batch jobs, thread pool routes): a
+    coroutine calling in from the event loop never waits on another one.
+    """
+
+    def __init__(self):
+        self._flights: Dict[str, _Flight] = {}
+        self._lock = threading.Lock()
+
+    def do(self, key: str, fn: Callable[[], Any]) -> Tuple[Any, bool]:
+        """Run fn, or wait for the run of the same key already under way.
+
+        Returns:
+            The result (raising its error), and whether it came from another caller's run
+        """
+        with self._lock:
+            flight = self._flights.get(key)
+            leader = flight is None
+            if leader:
+                flight = self._flights[key] = _Flight()
+        if not leader:
+            flight.done.wait()
+            if flight.error is not None:
+                raise flight.error
+            return flight.value, True
+        try:
+            flight.value = fn()
+            return flight.value, False
+        except BaseException as e:
+            flight.error = e
+            raise
+        finally:
+            with self._lock:
+                del self._flights[key]
+            flight.done.set()
+
+    def in_flight(self) -> int:
+        return len(self._flights)
+
+
+class LookupCache:
+    """Read-through cache of lookup response models.
+
+    Misses of the same entry are deduplicated: while one caller looks it
+    up, the others wait for its answer instead of looking it up too, so a
+    burst of lookups of one hot address costs one dataset lookup.
+    """
+
+    def __init__(
+        self,
+        store: Union[LookupCacheStore, TieredCache],
+        ttls: Optional[Dict[str, int]] = None,
+        key_prefix: str = "lookup",
+        negative_ttl_seconds: int = 60,
+    ):
+        """Initialize cache.
+
+        Args:
+            store: Entry storage, or tiers of it
+            ttls: Seconds entries of each lookup type are kept (default:
+                parse_ttls defaults with 300 for ip lookups)
+            key_prefix: Prefix of the store keys
+            negative_ttl_seconds: Seconds not-found answers are kept (at
+                most their type's TTL; 0: not kept)
+        """
+        self.store = store
+        self.tiers = store if isinstance(store, TieredCache) else TieredCache.single(store)
+        self.ttls = ttls if ttls is not None else parse_ttls("", 300)
+        self.key_prefix = key_prefix
+        self.negative_ttl_seconds = negative_ttl_seconds
+        self.flights = SingleFlight()
+        # Counts lookups for warming the next processes (see src.services.cache_warming_service)
+        self.hot_keys = None
+
+    def read_through(
+        self,
+        kind: str,
+        version: str,
+        query: Any,
+        model: Type[M],
+        compute: Callable[[], M],
+    ) -> M:
+        """A cached result, else the computed one, stored for later lookups.
+
+        Args:
+            kind: Lookup type (LookupType)
+            version: Version of the data answering the query
+            query: JSON-serializable query (everything the result depends on)
+            model: Response model of the result
+            compute: Looks the query up; its errors propagate, and only
+                not-found answers (LookupError) are stored
+
+        Returns:
+            A fresh model (never shared, so callers may add to it)
+
+        Raises:
+            LookupError: If the query was not found, now or within the negative TTL
+        """
+        from src.metrics import LOOKUP_CACHE_REQUESTS
+
+        ttl = self.ttls.get(kind, 0)
+        if ttl <= 0:
+            return compute()
+        if self.hot_keys is not None:
+            self.hot_keys.record(kind, query)
+        key = f"{self.key_prefix}:{kind}:{query_key(version, query)}"
+        computed: List[M] = []
+
+        def fetch() -> str:
+            """The entry's JSON, from the store or computed and stored."""
+            try:
+                cached = self.tiers.get(kind, key)
+            except Exception as e:
+                logger.warning(f"Lookup cache unavailable: {e}")
+                LOOKUP_CACHE_REQUESTS.labels(type=kind, result="error").inc()
+                reachable, cached = False, None
+            else:
+                reachable = True
+            if cached is not None:
+                not_found = cached.startswith(NOT_FOUND_MARKER)
+                LOOKUP_CACHE_REQUESTS.labels(type=kind, result="negative_hit" if not_found else "hit").inc()
+                return cached
+            if reachable:
+                LOOKUP_CACHE_REQUESTS.labels(type=kind, result="miss").inc()
+            try:
+                result = compute()
+            except (KeyError, IndexError):
+                # LookupErrors too, but bugs rather than answers
+                raise
+            except LookupError as e:
+                negative_ttl = min(ttl, self.negative_ttl_seconds)
+                if reachable and negative_ttl > 0:
+                    try:
+                        self.tiers.set(kind, key, f"{NOT_FOUND_MARKER}{e}", negative_ttl)
+                    except Exception as store_error:
+                        logger.warning(f"Failed to cache {kind} lookup: {store_error}")
+                raise
+            computed.append(result)
+            value = result.model_dump_json()
+            if reachable:
+                try:
+                    self.tiers.set(kind, key, value, ttl)
+                except Exception as e:
+                    logger.warning(f"Failed to cache {kind} lookup: {e}")
+            return value
+
+        value, shared = self.flights.do(key, fetch)
+        if shared:
+            LOOKUP_CACHE_REQUESTS.labels(type=kind, result="shared").inc()
+        if value.startswith(NOT_FOUND_MARKER):
+            raise LookupError(value[len(NOT_FOUND_MARKER):])
+        # The caller that computed the result gets it; the others a copy of their own
+        return computed[0] if computed else model.model_validate_json(value)
+
+    def invalidate(self, kind: str) -> int:
+        """Drop every entry of a lookup type, whatever its data version.
+
+        Returns:
+            Entries dropped
+        """
+        deleted = self.tiers.delete_prefix(f"{self.key_prefix}:{kind}:")
+        logger.info(f"Invalidated {deleted} cached {kind} lookups")
+        return deleted
+
+
+# Global lookup cache (None unless LOOKUP_CACHE_ENABLED; store chosen lazily from LOOKUP_CACHE_REDIS_URL)
+_lookup_cache: Optional[LookupCache] = None
+
+
+def get_lookup_cache() -> Optional[LookupCache]:
+    """Get the global lookup cache, if LOOKUP_CACHE_ENABLED.
+
+    Returns:
+        LookupCache backed by Redis behind a process-local L1, or by memory
+        alone if no URL is configured
+
+    Raises:
+        RuntimeError: If LOOKUP_CACHE_REDIS_URL is set but redis is not installed
+    """
+    global _lookup_cache
+    if _lookup_cache is None:
+        from src.config import get_config
+
+        config = get_config()
+        if not config.lookup_cache_enabled:
+            return None
+        if config.lookup_cache_redis_url:
+            store: Union[LookupCacheStore, TieredCache] = TieredCache([
+                CacheTier(
+                    "l1", InMemoryLookupCacheStore(config.cache_max_entries), config.lookup_cache_l1_ttl_seconds
+                ),
+                CacheTier("l2", RedisLookupCacheStore.from_url(config.lookup_cache_redis_url)),
+            ])
+        else:
+            logger.info("LOOKUP_CACHE_REDIS_URL not set; lookup results are cached per process")
+            store = InMemoryLookupCacheStore(config.cache_max_entries)
+        try:
+            ttls = parse_ttls(config.lookup_cache_ttls, int(config.cache_ttl_seconds))
+        except ValueError as e:
+            # Raised from a lookup, the error would read as the caller's (400)
+            logger.error(f"Invalid LOOKUP_CACHE_TTLS, using the default TTLs: {e}")
+            ttls = parse_ttls("", int(config.cache_ttl_seconds))
+        _lookup_cache = LookupCache(
+            store, ttls, config.lookup_cache_key_prefix, config.lookup_cache_negative_ttl_seconds
+        )
+    return _lookup_cache
+
+
+def invalidate_lookups(*kinds: str) -> int:
+    """Drop the cached lookups of the given types, when the data answering them is replaced.
+
+    Entries are keyed by data version, so those of the replaced data are
+    never served again; dropping them frees the cache for the new data at
+    once, and also drops answers of a replacement that kept its version.
+
+    Returns:
+        Entries dropped (0 without a lookup cache)
+    """
+    try:
+        cache = get_lookup_cache()
+    except RuntimeError as e:
+        logger.warning(f"Lookup cache not invalidated: {e}")
+        return 0
+    if cache is None:
+        return 0
+    return sum(cache.invalidate(kind) for kind in kinds)
@@ -0,0 +1,190 @@
+"""Maritime zone detection: territorial seas, EEZs and the high seas.
+
+Uses Marine Regions (marineregions.org) boundary layers exported as
+GeoJSON: the World EEZ layer and, optionally, the 12 nautical mile
+territorial seas layer. Properties are read from the Marine Regions
+attribute names (GEONAME, SOVEREIGN1, ISO_SOV1, TERRITORY1, ISO_TER1,
+MRGID, POL_TYPE), in either case. Territorial seas take precedence over
+the EEZ that contains them; sea outside every polygon is high seas.
+"""
+
+import logging
+from dataclasses import dataclass
+from typing import Any, Dict, List, Optional
+
+from src.spatial.geojson import read_polygon_features
+from src.spatial.geometry import Geometry
+from src.spatial.rtree import RTree
+
+logger = logging.getLogger(__name__)
+
+
+class ZoneType:
+    """Maritime zone types, most specific first."""
+    TERRITORIAL = "territorial"
+    EEZ = "eez"
+    HIGH_SEAS = "high_seas"
+
+
+# Polygons of disputed or shared waters (Marine Regions POL_TYPE values)
+_SHARED_POLYGON_TYPES = {"overlapping claim", "joint regime"}
+
+
+@dataclass
+class MaritimeZone:
+    """One territorial sea or EEZ polygon."""
+    zone_type: str
+    name: Optional[str]
+    sovereign: Optional[str]  # ISO 3166-1 alpha-3 of the sovereign state
+    territory: Optional[str]  # ISO 3166-1 alpha-3 of the territory (may differ, e.g. GUF/FRA)
+    mrgid: Optional[int]  # Marine Regions identifier
+    polygon_type: Optional[str]  # POL_TYPE, e.g. "200NM", "Overlapping claim"
+    geometry: Geometry
+
+    @property
+    def shared(self) -> bool:
+        """True for overlapping claims and joint regimes."""
+        return (self.polygon_type or "").lower() in _SHARED_POLYGON_TYPES
+
+
+@dataclass
+class MaritimeZoneResult:
+    """Maritime zone containing a coordinate."""
+    latitude: float
+    longitude: float
+    zone_type: str
+    zone: Optional[MaritimeZone] = None  # None on the high seas
+
+
+def _property(properties: Dict[str, Any], name: str) -> Optional[str]:
+    value = properties.get(name, properties.get(name.lower()))
+    if value in (None, ""):
+        return None
+    return str(value)
+
+
+def load_zones(path: str, zone_type: str) -> List[MaritimeZone]:
+    """Load maritime zone polygons from a Marine Regions GeoJSON export.
+
+    Args:
+        path: GeoJSON FeatureCollection
+        zone_type: ZoneType value assigned to the features
+
+    Returns:
+        List of MaritimeZone
+
+    Raises:
+        OSError: If the file cannot be read
+        ValueError: If the file is not a FeatureCollection
+    """
+    zones = []
+    for properties, geometry in read_polygon_features(path):
+        mrgid = _property(properties, "MRGID")
+        zones.append(MaritimeZone(
+            zone_type=zone_type,
+            name=_property(properties, "GEONAME"),
+            sovereign=_property(properties, "ISO_SOV1"),
+            territory=_property(properties, "ISO_TER1"),
+            mrgid=int(float(mrgid)) if mrgid is not None else None,
+            polygon_type=_property(properties, "POL_TYPE"),
+            geometry=geometry,
+        ))
+    return zones
+
+
+class MaritimeZoneService:
+    """Resolves the maritime zone of a sea coordinate."""
+
+    def __init__(self, zones: Optional[List[MaritimeZone]] = None):
+        """Initialize maritime zone resolver.
+
+        Args:
+            zones: Territorial sea and EEZ polygons
+        """
+        zones = zones or []
+        self._indexes = {
+            zone_type: RTree([
+                (zone.geometry.bbox, zone) for zone in zones if zone.zone_type == zone_type
+            ])
+            for zone_type in (ZoneType.TERRITORIAL, ZoneType.EEZ)
+        }
+
+    @classmethod
+    def from_geojson(
+        cls, eez_path: str, territorial_path: Optional[str] = None
+    ) -> "MaritimeZoneService":
+        """Load the EEZ layer and, if given, the territorial seas layer.
+
+        Raises:
+            OSError: If a file cannot be read
+            ValueError: If a file is not a FeatureCollection
+        """
+        zones = load_zones(eez_path, ZoneType.EEZ)
+        if territorial_path:
+            zones += load_zones(territorial_path, ZoneType.TERRITORIAL)
+        return cls(zones)
+
+    @property
+    def zone_count(self) -> int:
+        """Number of loaded zone polygons."""
+        return sum(len(index) for index in self._indexes.values())
+
+    def zone_at(self, latitude: float, longitude: float) -> MaritimeZoneResult:
+        """Find the maritime zone containing a coordinate.
+
+        The boundary layers cover sea only, so a point on land outside
+        every polygon also resolves to the high seas; callers that may
+        pass land coordinates should check a land mask first.
+
+        Args:
+            latitude: Latitude in degrees (-90 to 90)
+            longitude: Longitude in degrees (-180 to 180)
+
+        Returns:
+            MaritimeZoneResult
+
+        Raises:
+            ValueError: If the coordinate is out of range
+        """
+        if not -90 <= latitude <= 90:
+            raise ValueError(f"Latitude out of range: {latitude}")
+        if not -180 <= longitude <= 180:
+            raise ValueError(f"Longitude out of range: {longitude}")
+
+        for zone_type in (ZoneType.TERRITORIAL, ZoneType.EEZ):
+            matches = [
+                zone
+                for zone in self._indexes[zone_type].query_point(longitude, latitude)
+                if zone.geometry.contains(longitude, latitude)
+            ]
+            if matches:
+                # Overlapping claims are published as their own polygons; prefer the smallest
+                zone = min(matches, key=lambda match: match.geometry.area)
+                return MaritimeZoneResult(latitude, longitude, zone_type, zone)
+        return MaritimeZoneResult(latitude, longitude, ZoneType.HIGH_SEAS)
+
+
+# Global maritime zone service (loaded lazily from MARITIME_EEZ_PATH)
+_maritime_zone_service: Optional[MaritimeZoneService] = None
+
+
+def get_maritime_zone_service() -> MaritimeZoneService:
+    """Get the global maritime zone service.
+
+    Returns:
+        MaritimeZoneService (without zones if the EEZ layer is unavailable)
+    """
+    global _maritime_zone_service
+    if _maritime_zone_service is None:
+        from src.config import get_config
+
+        config = get_config()
+        try:
+            _maritime_zone_service = MaritimeZoneService.from_geojson(
+                config.maritime_eez_path, config.maritime_territorial_path or None
+            )
+            logger.info(f"Loaded {_maritime_zone_service.zone_count} maritime zone polygons")
+        except (OSError, ValueError) as e:
+            logger.warning(f"Maritime boundaries unavailable: {e}")
+            _maritime_zone_service = MaritimeZoneService()
+    return _maritime_zone_service
@@ -0,0 +1,218 @@
+"""Client certificate (mutual TLS) authentication for internal callers.
+
+When the server terminates TLS itself (`python -m src.server` with
+TLS_CERT_FILE and TLS_CLIENT_CA_FILE), services calling it can
+authenticate with a client certificate issued by the internal CA instead
+of a bearer token. The listener verifies the certificate chain; the
+certificate's subject alternative names then map to a tenant and scopes
+through MTLS_IDENTITIES:
+
+    {
+      "URI:spiffe://corp.internal/ns/billing/sa/api": {"tenant_id": "billing",
+                                                        "scopes": ["lookup:read"]},
+      "DNS:reports.corp.internal": {"tenant_id": "analytics",
+                                    "scopes": ["lookup:read", "geofence:read"]}
+    }
+
+Names are written `TYPE:value` with the type as reported by the TLS
+library (DNS, URI, IP, email) and must match exactly. A certificate whose
+names map to more than one tenant is ambiguous and not accepted.
+
+The verified certificate reaches the application as the `client_cert`
+ASGI scope extension (the decoded certificate, as returned by
+ssl.SSLSocket.getpeercert), set by the server's protocol for each
+connection; requests forwarded by a proxy never carry one.
+"""
+
+import json
+import logging
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, List, Mapping, Optional, Tuple
+
+from src.services.auth_service import Principal, validate_scopes
+
+logger = logging.getLogger(__name__)
+
+# ASGI scope extension holding the verified peer certificate
+SCOPE_EXTENSION = "client_cert"
+
+# getpeercert names the IP type "IP Address"
+_SAN_TYPES = {"DNS": "DNS", "URI": "URI", "IP Address": "IP", "email": "email"}
+
+
+@dataclass(frozen=True)
+class ClientIdentity:
+    """Tenant and scopes granted to a client certificate name."""
+    tenant_id: str
+    scopes: Tuple[str, ...] = ()
+
+
+def parse_identities(raw: str) -> Dict[str, ClientIdentity]:
+    """Parse MTLS_IDENTITIES.
+
+    Args:
+        raw: JSON object mapping `TYPE:value` names to
+            {"tenant_id": ..., "scopes": [...]}
+
+    Returns:
+        dict of name -> identity. Malformed JSON maps no name (so no
+        certificate is accepted); invalid entries are skipped.
+    """
+    if not raw:
+        return {}
+    try:
+        entries = json.loads(raw)
+    except json.JSONDecodeError as e:
+        logger.error(f"Invalid MTLS_IDENTITIES: {str(e)}")
+        return {}
+    if not isinstance(entries, dict):
+        logger.error("Invalid MTLS_IDENTITIES: expected a JSON object")
+        return {}
+
+    identities: Dict[str, ClientIdentity] = {}
+    for name, entry in entries.items():
+        kind, _, value = name.partition(":")
+        if kind not in _SAN_TYPES.values() or not value:
+            logger.error(f"Invalid MTLS_IDENTITIES name {name!r}: expected DNS:, URI:, IP: or email:")
+            continue
+        if not isinstance(entry, dict) or not isinstance(entry.get("tenant_id"), str) or not entry["tenant_id"]:
+            logger.error(f"Invalid MTLS_IDENTITIES entry {name!r}: tenant_id is required")
+            continue
+        try:
+            scopes = validate_scopes(entry.get("scopes", []))
+        except (TypeError, ValueError) as e:
+            logger.error(f"Invalid MTLS_IDENTITIES entry {name!r}: {str(e)}")
+            continue
+        identities[name] = ClientIdentity(entry["tenant_id"], scopes)
+    return identities
+
+
+def certificate_names(certificate: Mapping[str, Any]) -> List[str]:
+    """Subject alternative names of a decoded certificate, as `TYPE:value`."""
+    names = []
+    for kind, value in certificate.get("subjectAltName", ()):
+        if kind in _SAN_TYPES:
+            names.append(f"{_SAN_TYPES[kind]}:{value}")
+    return names
+
+
+class ClientCertificateMapper:
+    """Maps verified client certificates to principals."""
+
+    def __init__(self, identities: Dict[str, ClientIdentity]):
+        """Initialize mapper.
+
+        Args:
+            identities: Certificate name -> identity (see parse_identities)
+        """
+        self.identities = identities
+
+    def principal(self, certificate: Optional[Mapping[str, Any]]) -> Optional[Principal]:
+        """Principal of a client certificate.
+
+        Args:
+            certificate: Decoded, already verified peer certificate
+
+        Returns:
+            Principal named `mtls:<name>`, or None if no certificate was
+            sent, none of its names is mapped or they map to several tenants
+        """
+        if not certificate:
+            return None
+        names = certificate_names(certificate)
+        matches = [(name, self.identities[name]) for name in names if name in self.identities]
+        if not matches:
+            logger.info(f"Client certificate {names} is not mapped to a tenant")
+            return None
+        tenants = {identity.tenant_id for _, identity in matches}
+        if len(tenants) > 1:
+            logger.warning(f"Client certificate maps to several tenants ({', '.join(sorted(tenants))})")
+            return None
+        name, identity = matches[0]
+        scopes = frozenset(scope for _, matched in matches for scope in matched.scopes)
+        return Principal(
+            subject=f"mtls:{name}",
+            tenant_id=identity.tenant_id,
+            scopes=scopes,
+            claims={"san": [matched_name for matched_name, _ in matches]},
+        )
+
+    def from_scope(self, scope: Mapping[str, Any]) -> Optional[Principal]:
+        """Principal of the client certificate of an ASGI connection, if any."""
+        return self.principal((scope.get("extensions") or {}).get(SCOPE_EXTENSION))
+
+
+def with_client_certificate(app: Callable, certificate: Mapping[str, Any]) -> Callable:
+    """ASGI app passing a connection's verified client certificate on to `app`."""
+
+    async def wrapped(scope, receive, send):
+        if scope["type"] in ("http", "websocket"):
+            scope = {**scope, "extensions": {**(scope.get("extensions") or {}), SCOPE_EXTENSION: certificate}}
+        await app(scope, receive, send)
+
+    return wrapped
+
+
+# Global mapper (built lazily from configuration)
+_mapper: Optional[ClientCertificateMapper] = None
+
+
+def get_client_certificate_mapper() -> ClientCertificateMapper:
+    """Get the global client certificate mapper."""
+    global _mapper
+    if _mapper is None:
+        from src.config import get_config
+
+        _mapper = ClientCertificateMapper(parse_identities(get_config().mtls_identities))
+    return _mapper
+
+
+def client_certificate_principal(scope: Mapping[str, Any]) -> Optional[Principal]:
+    """Principal of a request's client certificate (None without a mapped one)."""
+    return get_client_certificate_mapper().from_scope(scope)
+
+
+def uvicorn_ssl_options(
+    cert_file: str, key_file: str, client_ca_file: str = "", client_auth: str = "optional"
+) -> Dict[str, Any]:
+    """uvicorn.Config keyword arguments of the TLS listener.
+
+    Args:
+        cert_file: Server certificate chain (PEM)
+        key_file: Server private key (PEM)
+        client_ca_file: CAs issuing client certificates (empty: none requested)
+        client_auth: "optional" (callers may still use bearer tokens) or
+            "required" (handshakes without a valid certificate fail)
+
+    Raises:
+        ValueError: For an unknown client_auth mode
+    """
+    import ssl
+
+    options: Dict[str, Any] = {"ssl_certfile": cert_file, "ssl_keyfile": key_file}
+    if client_ca_file:
+        modes = {"optional": ssl.CERT_OPTIONAL, "required": ssl.CERT_REQUIRED}
+        if client_auth not in modes:
+            raise ValueError(f"TLS_CLIENT_AUTH must be optional or required, not {client_auth!r}")
+        options.update(ssl_ca_certs=client_ca_file, ssl_cert_reqs=modes[client_auth])
+    return options
+
+
+def client_certificate_protocol(base: type) -> type:
+    """uvicorn HTTP protocol class exposing each connection's client certificate.
+
+    Args:
+        base: uvicorn protocol class (H11Protocol or HttpToolsProtocol)
+    """
+
+    class ClientCertificateProtocol(base):
+        def connection_made(self, transport):
+            super().connection_made(transport)
+            ssl_object = transport.get_extra_info("ssl_object")
+            certificate = ssl_object.getpeercert() if ssl_object is not None else None
+            if certificate:
+                # The handshake is done, so the certificate holds for every request on the connection
+                self.app = with_client_certificate(self.app, certificate)
+
+    ClientCertificateProtocol.__name__ = f"ClientCertificate{base.__name__}"
+    return ClientCertificateProtocol
@@ -0,0 +1,253 @@
+"""Admin API sign-in through the corporate identity provider (OIDC).
+
+Operators call the admin API with an access or ID token from the IdP at
+OIDC_ISSUER_URL instead of credentials of this service. The IdP is found
+through OpenID Connect discovery
+(`{issuer}/.well-known/openid-configuration`), and tokens are verified
+against the signing keys it publishes (`jwks_uri`): signature, issuer,
+audience (OIDC_AUDIENCE) and expiry. Keys are cached for
+OIDC_JWKS_CACHE_SECONDS; a token signed with an unknown key ID refetches
+them, so IdP key rotations need no restart.
+
+The IdP's groups (OIDC_GROUPS_CLAIM) map to roles through
+OIDC_GROUP_ROLES, e.g. `{"geo-ops": ["admin"], "geo-support":
+["admin:read"]}`. Roles become the principal's scopes, so users outside
+every mapped group authenticate but get 403 from the admin API.
+"""
+
+import logging
+import time
+from typing import Any, Awaitable, Callable, Dict, FrozenSet, Iterable, List, Optional
+
+import jwt
+
+from src.services.auth_service import AuthenticationError, Principal
+
+logger = logging.getLogger(__name__)
+
+FetchJson = Callable[[str], Awaitable[Any]]
+
+# Least time between JWKS refetches for unknown key IDs (forged kids cannot hammer the IdP)
+_MIN_REFRESH_SECONDS = 60.0
+
+
+async def fetch_json(url: str, timeout_seconds: float = 10.0) -> Any:
+    """GET a JSON document from the IdP.
+
+    Raises:
+        RuntimeError: On transport errors, non-200 responses or bodies that
+            are not JSON
+    """
+    import aiohttp
+
+    try:
+        async with aiohttp.ClientSession() as session:
+            async with session.get(url, timeout=aiohttp.ClientTimeout(total=timeout_seconds)) as response:
+                if response.status != 200:
+                    raise RuntimeError(f"{url} returned HTTP {response.status}")
+                return await response.json(content_type=None)
+    except RuntimeError:
+        raise
+    except Exception as e:
+        raise RuntimeError(f"Cannot fetch {url}: {str(e)}")
+
+
+def claim_values(claims: Dict[str, Any], path: str) -> List[str]:
+    """String values of a claim, following dots into nested objects.
+
+    A single string counts as one value; anything else yields none.
+    """
+    value: Any = claims
+    for part in path.split("."):
+        if not isinstance(value, dict):
+            return []
+        value = value.get(part)
+    if isinstance(value, str):
+        return [value]
+    if isinstance(value, list):
+        return [v for v in value if isinstance(v, str)]
+    return []
+
+
+class OIDCVerifier:
+    """Validates IdP tokens and maps the user's groups to roles."""
+
+    def __init__(
+        self,
+        issuer: str,
+        audience: str,
+        group_roles: Dict[str, FrozenSet[str]],
+        groups_claim: str = "groups",
+        tenant_id: str = "platform",
+        algorithms: Iterable[str] = ("RS256", "ES256"),
+        cache_seconds: float = 3600.0,
+        fetch: FetchJson = fetch_json,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        """Initialize verifier.
+
+        Args:
+            issuer: Issuer URL; must equal the iss claim and the discovery
+                document's issuer
+            audience: Required aud claim (the client ID registered at the IdP)
+            group_roles: IdP group -> roles granted
+            groups_claim: Claim listing the user's groups (dotted for nested)
+            tenant_id: Tenant the operators act for
+            algorithms: Accepted signing algorithms
+            cache_seconds: How long discovery and keys are cached
+            fetch: Async JSON GET (injectable for tests)
+            clock: Monotonic clock
+        """
+        self.issuer = issuer.rstrip("/")
+        self.audience = audience
+        self.group_roles = group_roles
+        self.groups_claim = groups_claim
+        self.tenant_id = tenant_id
+        self.algorithms = list(algorithms)
+        self.cache_seconds = cache_seconds
+        self.fetch = fetch
+        self.clock = clock
+        self._jwks_uri: Optional[str] = None
+        self._keys: Dict[str, jwt.PyJWK] = {}
+        self._keys_fetched_at: Optional[float] = None
+
+    def handles(self, token: str) -> bool:
+        """Whether a token claims to come from this IdP (not verified)."""
+        try:
+            claims = jwt.decode(token, options={"verify_signature": False})
+        except jwt.InvalidTokenError:
+            return False
+        return isinstance(claims.get("iss"), str) and claims["iss"].rstrip("/") == self.issuer
+
+    def roles(self, claims: Dict[str, Any]) -> FrozenSet[str]:
+        """Roles granted by the groups listed in a token."""
+        roles = set()
+        for group in claim_values(claims, self.groups_claim):
+            roles |= self.group_roles.get(group, frozenset())
+        return frozenset(roles)
+
+    async def verify(self, token: str) -> Principal:
+        """Validate an IdP token.
+
+        Returns:
+            Principal of the user, with their roles as scopes
+
+        Raises:
+            AuthenticationError: If the token is invalid, expired, for
+                another audience or signed with an unknown key
+            RuntimeError: If the IdP cannot be reached or misbehaves
+        """
+        try:
+            header = jwt.get_unverified_header(token)
+        except jwt.InvalidTokenError as e:
+            raise AuthenticationError(f"Invalid token: {str(e)}")
+        algorithm = header.get("alg")
+        if algorithm not in self.algorithms:
+            raise AuthenticationError(f"Token algorithm {algorithm!r} is not accepted")
+
+        key = await self._signing_key(header.get("kid"))
+        try:
+            claims = jwt.decode(
+                token,
+                key.key,
+                algorithms=[algorithm],
+                audience=self.audience,
+                issuer=self.issuer,
+                options={"require": ["exp", "iss", "sub", "aud"]},
+            )
+        except jwt.ExpiredSignatureError:
+            raise AuthenticationError("Token has expired")
+        except jwt.InvalidTokenError as e:
+            raise AuthenticationError(f"Invalid token: {str(e)}")
+
+        roles = self.roles(claims)
+        if not roles:
+            logger.info(f"OIDC user {claims['sub']} has no mapped group")
+        return Principal(subject=claims["sub"], tenant_id=self.tenant_id, scopes=roles, claims=claims)
+
+    async def _signing_key(self, kid: Optional[str]) -> jwt.PyJWK:
+        now = self.clock()
+        stale = self._keys_fetched_at is None or now - self._keys_fetched_at >= self.cache_seconds
+        unknown = self._find_key(kid) is None
+        if stale or (
+            unknown and now - self._keys_fetched_at >= min(_MIN_REFRESH_SECONDS, self.cache_seconds)
+        ):
+            await self._refresh_keys()
+        key = self._find_key(kid)
+        if key is None:
+            raise AuthenticationError(f"Token signed with unknown key {kid!r}")
+        return key
+
+    def _find_key(self, kid: Optional[str]) -> Optional[jwt.PyJWK]:
+        if kid is not None:
+            return self._keys.get(kid)
+        # Without a kid only an IdP publishing a single key is unambiguous
+        return next(iter(self._keys.values())) if len(self._keys) == 1 else None
+
+    async def _refresh_keys(self) -> None:
+        if self._jwks_uri is None or self._keys_fetched_at is None or (
+            self.clock() - self._keys_fetched_at >= self.cache_seconds
+        ):
+            self._jwks_uri = await self._discover()
+        document = await self.fetch(self._jwks_uri)
+        if not isinstance(document, dict) or not isinstance(document.get("keys"), list):
+            raise RuntimeError(f"{self._jwks_uri} is not a JWK set")
+
+        keys: Dict[str, jwt.PyJWK] = {}
+        for entry in document["keys"]:
+            if not isinstance(entry, dict) or entry.get("use", "sig") != "sig":
+                continue
+            try:
+                key = jwt.PyJWK(entry)
+            except (jwt.PyJWKError, jwt.InvalidKeyError) as e:
+                # e.g. an algorithm this deployment cannot verify
+                logger.warning(f"Skipping IdP key {entry.get('kid')!r}: {str(e)}")
+                continue
+            keys[entry.get("kid") or ""] = key
+        self._keys = keys
+        self._keys_fetched_at = self.clock()
+        logger.info(f"Loaded {len(keys)} signing keys from {self._jwks_uri}")
+
+    async def _discover(self) -> str:
+        url = f"{self.issuer}/.well-known/openid-configuration"
+        document = await self.fetch(url)
+        if not isinstance(document, dict):
+            raise RuntimeError(f"{url} is not a discovery document")
+        if str(document.get("issuer", "")).rstrip("/") != self.issuer:
+            raise RuntimeError(f"IdP reports issuer {document.get('issuer')!r}, expected {self.issuer}")
+        jwks_uri = document.get("jwks_uri")
+        if not isinstance(jwks_uri, str) or not jwks_uri:
+            raise RuntimeError(f"{url} has no jwks_uri")
+        return jwks_uri
+
+
+# Global verifier (None while OIDC_ISSUER_URL is unset)
+_oidc_verifier: Optional[OIDCVerifier] = None
+
+
+def get_oidc_verifier() -> Optional[OIDCVerifier]:
+    """Get the global IdP token verifier.
+
+    Returns:
+        OIDCVerifier, or None if OIDC sign-in is not configured
+    """
+    global _oidc_verifier
+    if _oidc_verifier is None:
+        from src.config import get_config
+
+        config = get_config()
+        if not config.oidc_issuer_url:
+            return None
+        if not config.oidc_audience:
+            logger.error("OIDC_ISSUER_URL is set without OIDC_AUDIENCE; OIDC sign-in is disabled")
+            return None
+        _oidc_verifier = OIDCVerifier(
+            config.oidc_issuer_url,
+            config.oidc_audience,
+            config.oidc_group_roles,
+            groups_claim=config.oidc_groups_claim,
+            tenant_id=config.oidc_tenant_id,
+            algorithms=config.oidc_algorithms,
+            cache_seconds=config.oidc_jwks_cache_seconds,
+        )
+    return _oidc_verifier
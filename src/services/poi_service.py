@@ -0,0 +1,336 @@
+"""Tenant POI datasets with nearest-neighbor search.
+
+Datasets are stored in the database and indexed on demand in an
+in-memory k-d tree. Indexes are cached per dataset (least recently used
+evicted first) and dropped when the dataset is deleted, so queries after
+the first never touch the POI table.
+"""
+
+import logging
+import threading
+import uuid
+from collections import OrderedDict
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import Poi, PoiDataset
+from src.spatial.kdtree import KDTree
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass
+class PoiRecord:
+    """POI as held in the in-memory index."""
+    poi_id: str
+    latitude: float
+    longitude: float
+    name: Optional[str] = None
+    properties: Optional[Dict[str, Any]] = None
+
+
+@dataclass
+class PoiMatch:
+    """POI returned by a nearest-neighbor query."""
+    poi: PoiRecord
+    distance_meters: float
+
+
+@dataclass
+class PoiIndex:
+    """k-d tree over one dataset's POIs."""
+    dataset_id: str
+    tree: KDTree = field(repr=False)
+
+    @classmethod
+    def build(cls, dataset_id: str, pois: List[PoiRecord]) -> "PoiIndex":
+        """Index a dataset's POIs."""
+        return cls(
+            dataset_id=dataset_id,
+            tree=KDTree([(p.latitude, p.longitude, p) for p in pois]),
+        )
+
+    def nearest(
+        self,
+        latitude: float,
+        longitude: float,
+        n: int,
+        radius_meters: Optional[float] = None,
+    ) -> List[PoiMatch]:
+        """Nearest POIs, closest first."""
+        return [
+            PoiMatch(poi=poi, distance_meters=distance)
+            for distance, poi in self.tree.nearest(
+                latitude, longitude, k=n, max_distance_meters=radius_meters
+            )
+        ]
+
+
+class PoiIndexCache:
+    """Thread-safe LRU cache of POI indexes keyed by dataset ID."""
+
+    def __init__(self, max_size: int = 64):
+        """Initialize cache.
+
+        Args:
+            max_size: Maximum number of indexed datasets kept in memory
+        """
+        self.max_size = max(1, max_size)
+        self._indexes: "OrderedDict[str, PoiIndex]" = OrderedDict()
+        self._lock = threading.Lock()
+
+    def get(self, dataset_id: str) -> Optional[PoiIndex]:
+        """Get a cached index, marking it recently used."""
+        with self._lock:
+            index = self._indexes.get(dataset_id)
+            if index is not None:
+                self._indexes.move_to_end(dataset_id)
+            return index
+
+    def put(self, index: PoiIndex) -> None:
+        """Cache an index, evicting the least recently used if full."""
+        with self._lock:
+            self._indexes[index.dataset_id] = index
+            self._indexes.move_to_end(index.dataset_id)
+            while len(self._indexes) > self.max_size:
+                self._indexes.popitem(last=False)
+
+    def invalidate(self, dataset_id: str) -> None:
+        """Drop a dataset's index."""
+        with self._lock:
+            self._indexes.pop(dataset_id, None)
+
+    def __len__(self) -> int:
+        return len(self._indexes)
+
+
+def _validate_poi(raw: Dict[str, Any], position: int) -> PoiRecord:
+    """Validate one uploaded POI."""
+    poi_id = raw.get("id")
+    if poi_id is None or str(poi_id) == "":
+        raise ValueError(f"POI {position}: missing id")
+    try:
+        latitude = float(raw["latitude"])
+        longitude = float(raw["longitude"])
+    except (KeyError, TypeError, ValueError):
+        raise ValueError(f"POI {poi_id}: latitude and longitude are required numbers")
+    if not -90 <= latitude <= 90:
+        raise ValueError(f"POI {poi_id}: latitude out of range: {latitude}")
+    if not -180 <= longitude <= 180:
+        raise ValueError(f"POI {poi_id}: longitude out of range: {longitude}")
+    return PoiRecord(
+        poi_id=str(poi_id),
+        latitude=latitude,
+        longitude=longitude,
+        name=raw.get("name"),
+        properties=raw.get("properties"),
+    )
+
+
+class PoiService:
+    """Stores tenant POI datasets and answers nearest-neighbor queries."""
+
+    def __init__(
+        self,
+        session: Session,
+        cache: Optional[PoiIndexCache] = None,
+        max_dataset_size: Optional[int] = None,
+        max_results: Optional[int] = None,
+    ):
+        """Initialize POI service.
+
+        Args:
+            session: SQLAlchemy database session
+            cache: Index cache (default: the global cache)
+            max_dataset_size: Maximum POIs per dataset (default POI_MAX_DATASET_SIZE)
+            max_results: Maximum n per query (default POI_MAX_RESULTS)
+        """
+        if max_dataset_size is None or max_results is None:
+            from src.config import get_config
+
+            config = get_config()
+            if max_dataset_size is None:
+                max_dataset_size = config.poi_max_dataset_size
+            if max_results is None:
+                max_results = config.poi_max_results
+        self.session = session
+        self.cache = cache if cache is not None else get_poi_index_cache()
+        self.max_dataset_size = max_dataset_size
+        self.max_results = max_results
+
+    def create_dataset(
+        self, tenant_id: str, name: str, pois: List[Dict[str, Any]]
+    ) -> PoiDataset:
+        """Validate and store a POI dataset.
+
+        Args:
+            tenant_id: Owning tenant
+            name: Dataset display name
+            pois: dicts with id, latitude, longitude and optional name/properties
+
+        Returns:
+            Stored PoiDataset
+
+        Raises:
+            ValueError: If the dataset is empty, too large, contains invalid
+                or duplicate POIs, or cannot be stored
+        """
+        if not pois:
+            raise ValueError("Dataset must contain at least one POI")
+        if len(pois) > self.max_dataset_size:
+            raise ValueError(
+                f"Dataset has {len(pois)} POIs; the limit is {self.max_dataset_size}"
+            )
+
+        records = [_validate_poi(raw, i) for i, raw in enumerate(pois)]
+        seen = set()
+        for record in records:
+            if record.poi_id in seen:
+                raise ValueError(f"Duplicate POI id: {record.poi_id}")
+            seen.add(record.poi_id)
+
+        dataset = PoiDataset(
+            dataset_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            name=name,
+            poi_count=len(records),
+        )
+        try:
+            self.session.add(dataset)
+            self.session.add_all(
+                Poi(
+                    dataset_id=dataset.dataset_id,
+                    poi_id=record.poi_id,
+                    name=record.name,
+                    latitude=record.latitude,
+                    longitude=record.longitude,
+                    properties=record.properties,
+                )
+                for record in records
+            )
+            self.session.commit()
+            self.session.refresh(dataset)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store POI dataset: {str(e)}")
+
+        # Index eagerly; the records are already in hand
+        self.cache.put(PoiIndex.build(dataset.dataset_id, records))
+        logger.info(
+            f"Created POI dataset {dataset.dataset_id} for tenant {tenant_id} "
+            f"with {len(records)} POIs"
+        )
+        return dataset
+
+    def list_datasets(self, tenant_id: str) -> List[PoiDataset]:
+        """List a tenant's datasets, newest first."""
+        return (
+            self.session.query(PoiDataset)
+            .filter(PoiDataset.tenant_id == tenant_id)
+            .order_by(PoiDataset.created_at.desc())
+            .all()
+        )
+
+    def get_dataset(self, tenant_id: str, dataset_id: str) -> Optional[PoiDataset]:
+        """Fetch a dataset owned by the tenant (None for other tenants' datasets)."""
+        return (
+            self.session.query(PoiDataset)
+            .filter(
+                PoiDataset.dataset_id == dataset_id,
+                PoiDataset.tenant_id == tenant_id,
+            )
+            .first()
+        )
+
+    def delete_dataset(self, dataset: PoiDataset) -> None:
+        """Delete a dataset, its POIs and its cached index.
+
+        Raises:
+            ValueError: If the dataset cannot be deleted
+        """
+        dataset_id = dataset.dataset_id
+        try:
+            self.session.query(Poi).filter(Poi.dataset_id == dataset_id).delete(
+                synchronize_session=False
+            )
+            self.session.delete(dataset)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to delete POI dataset: {str(e)}")
+        self.cache.invalidate(dataset_id)
+        logger.info(f"Deleted POI dataset {dataset_id}")
+
+    def nearest(
+        self,
+        dataset: PoiDataset,
+        latitude: float,
+        longitude: float,
+        n: int = 10,
+        radius_meters: Optional[float] = None,
+    ) -> List[PoiMatch]:
+        """Find the n POIs nearest to a coordinate.
+
+        Args:
+            dataset: Dataset to search
+            latitude: Query latitude
+            longitude: Query longitude
+            n: Maximum number of results
+            radius_meters: Only POIs within this great-circle distance
+
+        Returns:
+            Matches, nearest first
+
+        Raises:
+            ValueError: If the coordinate, n or radius is out of range
+        """
+        if not -90 <= latitude <= 90:
+            raise ValueError(f"Latitude out of range: {latitude}")
+        if not -180 <= longitude <= 180:
+            raise ValueError(f"Longitude out of range: {longitude}")
+        if not 1 <= n <= self.max_results:
+            raise ValueError(f"n must be between 1 and {self.max_results}")
+        if radius_meters is not None and radius_meters <= 0:
+            raise ValueError("radius_m must be positive")
+        return self._index(dataset).nearest(latitude, longitude, n, radius_meters)
+
+    def _index(self, dataset: PoiDataset) -> PoiIndex:
+        index = self.cache.get(dataset.dataset_id)
+        if index is None:
+            rows = (
+                self.session.query(Poi)
+                .filter(Poi.dataset_id == dataset.dataset_id)
+                .all()
+            )
+            index = PoiIndex.build(
+                dataset.dataset_id,
+                [
+                    PoiRecord(
+                        poi_id=row.poi_id,
+                        latitude=row.latitude,
+                        longitude=row.longitude,
+                        name=row.name,
+                        properties=row.properties,
+                    )
+                    for row in rows
+                ],
+            )
+            self.cache.put(index)
+            logger.info(f"Indexed POI dataset {dataset.dataset_id} ({len(rows)} POIs)")
+        return index
+
+
+# Global index cache (shared across requests)
+_poi_index_cache: Optional[PoiIndexCache] = None
+
+
+def get_poi_index_cache() -> PoiIndexCache:
+    """Get the global POI index cache, sized from POI_INDEX_CACHE_SIZE."""
+    global _poi_index_cache
+    if _poi_index_cache is None:
+        from src.config import get_config
+
+        _poi_index_cache = PoiIndexCache(get_config().poi_index_cache_size)
+    return _poi_index_cache
@@ -0,0 +1,256 @@
+"""Postal code areas: boundaries, containing postal code and validation.
+
+Postal code polygons are imported from GeoJSON FeatureCollections such as
+national postcode boundary releases or OpenStreetMap `boundary=postal_code`
+extracts. Each feature's code is read from the first of its `postal_code`,
+`postcode`, `code` or `zip` properties and its country from `country_code`
+or `country` (or a country given for the whole file):
+
+    python -m src.services.postal_service de_postcodes.geojson DE
+
+Code formats are checked against the GeoNames country info table (see
+src.services.country_info_service), independently of whether the area is
+imported.
+"""
+
+import logging
+import re
+from dataclasses import dataclass
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import PostalArea
+from src.services.country_info_service import CountryInfo, get_country_info
+from src.spatial.geojson import read_polygon_features
+from src.spatial.geometry import Geometry, geometry_from_geojson
+
+logger = logging.getLogger(__name__)
+
+CODE_PROPERTIES = ("postal_code", "postcode", "code", "zip")
+COUNTRY_PROPERTIES = ("country_code", "country")
+NAME_PROPERTIES = ("name", "place_name", "note")
+
+
+@dataclass
+class PostalValidation:
+    """Result of checking a postal code."""
+    country_code: str
+    postal_code: str  # Normalized
+    format_valid: Optional[bool]  # None if the country's format is unknown
+    known: bool  # An area with this code is imported
+
+
+def normalize_country(country_code: str) -> str:
+    """Upper-case ISO 3166-1 alpha-2 code.
+
+    Raises:
+        ValueError: If the code is not two letters
+    """
+    country = (country_code or "").strip().upper()
+    if len(country) != 2 or not country.isalpha():
+        raise ValueError(f"Invalid country code {country_code!r}: expected ISO 3166-1 alpha-2")
+    return country
+
+
+def normalize_postal_code(postal_code: str) -> str:
+    """Upper-case a postal code and collapse its whitespace ("sw1a  1aa" -> "SW1A 1AA").
+
+    Raises:
+        ValueError: If the code is empty or too long
+    """
+    code = " ".join(str(postal_code or "").upper().split())
+    if not code:
+        raise ValueError("Postal code must not be empty")
+    if len(code) > 20:
+        raise ValueError(f"Postal code too long: {postal_code!r}")
+    return code
+
+
+def _first_property(properties: Dict[str, Any], names: Iterable[str]) -> Optional[str]:
+    for name in names:
+        value = properties.get(name, properties.get(name.upper()))
+        if value not in (None, ""):
+            return str(value)
+    return None
+
+
+class PostalService:
+    """Stores postal code areas and resolves coordinates and codes against them."""
+
+    def __init__(self, session: Session, countries: Optional[Dict[str, CountryInfo]] = None):
+        """Initialize postal service.
+
+        Args:
+            session: SQLAlchemy database session
+            countries: ISO code -> CountryInfo for format validation
+                (default: the global country info table)
+        """
+        self.session = session
+        self.countries = countries if countries is not None else get_country_info()
+
+    def import_features(
+        self,
+        features: Iterable[Tuple[Dict[str, Any], Geometry]],
+        country_code: Optional[str] = None,
+    ) -> int:
+        """Insert or update postal areas.
+
+        Features without a code or country are logged and skipped.
+
+        Args:
+            features: (properties, geometry) pairs
+            country_code: Country of every feature, overriding their properties
+
+        Returns:
+            Number of areas stored
+
+        Raises:
+            ValueError: If the country code is invalid or the areas cannot be stored
+        """
+        default_country = normalize_country(country_code) if country_code else None
+        areas: Dict[Tuple[str, str], Dict[str, Any]] = {}
+        for index, (properties, geometry) in enumerate(features):
+            try:
+                country = default_country or normalize_country(
+                    _first_property(properties, COUNTRY_PROPERTIES) or ""
+                )
+                code = normalize_postal_code(_first_property(properties, CODE_PROPERTIES) or "")
+            except ValueError as e:
+                logger.warning(f"Skipping postal feature {index}: {e}")
+                continue
+            centroid_lon, centroid_lat = geometry.centroid
+            bbox = geometry.bbox
+            areas[(country, code)] = {
+                "country_code": country,
+                "postal_code": code,
+                "name": _first_property(properties, NAME_PROPERTIES),
+                "geometry": geometry.to_geojson(),
+                "centroid_lat": centroid_lat,
+                "centroid_lon": centroid_lon,
+                "min_lat": bbox.min_lat,
+                "min_lon": bbox.min_lon,
+                "max_lat": bbox.max_lat,
+                "max_lon": bbox.max_lon,
+            }
+
+        existing = {}
+        for country in {key[0] for key in areas}:
+            for area in self.session.query(PostalArea).filter(PostalArea.country_code == country):
+                existing[(area.country_code, area.postal_code)] = area
+        try:
+            for key, fields in areas.items():
+                area = existing.get(key)
+                if area is None:
+                    self.session.add(PostalArea(**fields))
+                else:
+                    for name, value in fields.items():
+                        setattr(area, name, value)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store postal areas: {str(e)}")
+        logger.info(f"Stored {len(areas)} postal areas")
+        return len(areas)
+
+    def import_geojson(self, path: str, country_code: Optional[str] = None) -> int:
+        """Load postal areas from a GeoJSON FeatureCollection.
+
+        Raises:
+            OSError: If the file cannot be read
+            ValueError: If the file is not a FeatureCollection or the areas cannot be stored
+        """
+        return self.import_features(read_polygon_features(path), country_code)
+
+    def get(self, country_code: str, postal_code: str) -> Optional[PostalArea]:
+        """Postal area by country and code.
+
+        Raises:
+            ValueError: If the country or postal code is malformed
+        """
+        return (
+            self.session.query(PostalArea)
+            .filter(
+                PostalArea.country_code == normalize_country(country_code),
+                PostalArea.postal_code == normalize_postal_code(postal_code),
+            )
+            .first()
+        )
+
+    def validate(self, country_code: str, postal_code: str) -> PostalValidation:
+        """Check a postal code's format and whether its area is known.
+
+        Raises:
+            ValueError: If the country code or postal code is malformed
+        """
+        country = normalize_country(country_code)
+        code = normalize_postal_code(postal_code)
+        info = self.countries.get(country)
+        format_valid = None
+        if info is not None and info.postal_code_regex:
+            try:
+                format_valid = re.fullmatch(info.postal_code_regex, code, re.IGNORECASE) is not None
+            except re.error as e:
+                logger.warning(f"Invalid postal code pattern for {country}: {e}")
+        return PostalValidation(
+            country_code=country,
+            postal_code=code,
+            format_valid=format_valid,
+            known=self.get(country, code) is not None,
+        )
+
+    def locate(
+        self, latitude: float, longitude: float, country_code: Optional[str] = None
+    ) -> Optional[PostalArea]:
+        """Postal area containing a coordinate.
+
+        Args:
+            latitude: Latitude in degrees (-90 to 90)
+            longitude: Longitude in degrees (-180 to 180)
+            country_code: Restrict the search to one country
+
+        Returns:
+            PostalArea (the smallest if areas overlap), or None if no area contains the point
+
+        Raises:
+            ValueError: If the coordinate or country code is invalid
+        """
+        if not -90 <= latitude <= 90:
+            raise ValueError(f"Latitude out of range: {latitude}")
+        if not -180 <= longitude <= 180:
+            raise ValueError(f"Longitude out of range: {longitude}")
+
+        query = self.session.query(PostalArea).filter(
+            PostalArea.min_lat <= latitude,
+            PostalArea.max_lat >= latitude,
+            PostalArea.min_lon <= longitude,
+            PostalArea.max_lon >= longitude,
+        )
+        if country_code:
+            query = query.filter(PostalArea.country_code == normalize_country(country_code))
+
+        matches: List[Tuple[float, PostalArea]] = []
+        for area in query.all():
+            geometry = geometry_from_geojson(area.geometry)
+            if geometry.contains(longitude, latitude):
+                matches.append((geometry.area, area))
+        if not matches:
+            return None
+        return min(matches, key=lambda match: match[0])[1]
+
+
+if __name__ == "__main__":
+    import sys
+
+    from src.database import get_db_manager
+
+    logging.basicConfig(level=logging.INFO)
+    if len(sys.argv) not in (2, 3):
+        sys.exit("usage: python -m src.services.postal_service POSTAL_AREAS.geojson [COUNTRY]")
+    manager = get_db_manager()
+    manager.create_all()
+    with manager.session_scope() as session:
+        count = PostalService(session).import_geojson(
+            sys.argv[1], sys.argv[2] if len(sys.argv) == 3 else None
+        )
+    print(f"Imported {count} postal areas")
@@ -0,0 +1,227 @@
+"""Privacy mode of tenant API keys.
+
+A key can be put in privacy mode with a coordinate precision (decimal
+places, 0-6; e.g. 2 is about 1.1 km). The access tokens issued from it
+carry the precision in their `privacy` claim, and PrivacyMiddleware
+applies it to every request made with them:
+
+- coordinates in JSON responses (latitude/longitude fields and GeoJSON
+  positions) are truncated to the precision, and geohashes shortened to
+  a cell at least as coarse,
+- street-level data (formatted addresses, house numbers, roads) is
+  omitted,
+- IP addresses are redacted from the log records written while the
+  request is handled.
+
+Responses whose format cannot be rewritten (protobuf, vector tiles,
+event streams, XML, WebSockets, gRPC) are refused rather than served
+precise.
+"""
+
+import contextvars
+import ipaddress
+import logging
+import math
+import re
+from typing import Any, Optional
+
+import jwt
+
+logger = logging.getLogger(__name__)
+
+# Access token claim holding the coordinate precision of the key's privacy mode
+PRIVACY_CLAIM = "privacy"
+
+# Most decimal places a privacy precision may keep (about 11 cm)
+MAX_PRECISION = 6
+
+# Fields holding street-level data, omitted wherever they appear
+STREET_FIELDS = frozenset({
+    "formatted_address", "street_address", "address_line", "house_number", "road", "street",
+})
+
+# Numeric fields named like these (or ending in _lat / _lon / _lng) are coordinates
+COORDINATE_FIELDS = frozenset({"latitude", "longitude", "lat", "lon", "lng"})
+_COORDINATE_SUFFIXES = ("_lat", "_lon", "_lng", "_latitude", "_longitude")
+
+# GeoJSON position arrays and polygons (nested lists of numbers)
+POSITION_FIELDS = frozenset({"coordinates", "bbox", "boundary"})
+
+# Geohash fields (strings, or objects of strings such as neighbors)
+GEOHASH_FIELDS = frozenset({"geohash", "neighbors"})
+
+# Longest geohash no finer than each precision (cell half-height >= 10^-precision degrees)
+GEOHASH_LENGTHS = (2, 3, 5, 6, 7, 9, 10)
+
+REDACTED_IP = "[ip]"
+
+# Candidate IP addresses in log text (validated before being replaced)
+_IP_CANDIDATE = re.compile(r"(?<![\w.:])(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f.]*(?:%\w+)?|\b(?:\d{1,3}\.){3}\d{1,3}\b")
+
+# Precision of the request being handled (None: not in privacy mode)
+_request_precision: contextvars.ContextVar[Optional[int]] = contextvars.ContextVar(
+    "privacy_precision", default=None
+)
+
+
+class PrivacyModeError(Exception):
+    """Response format that privacy mode cannot apply to."""
+
+    def __init__(self):
+        super().__init__("This response format is not available in privacy mode")
+
+
+def validate_precision(value: Optional[int]) -> Optional[int]:
+    """Check a privacy precision (None turns privacy mode off).
+
+    Raises:
+        ValueError: If the value is not a whole number of decimal places in range
+    """
+    if value is None:
+        return None
+    if isinstance(value, bool) or not isinstance(value, int) or not 0 <= value <= MAX_PRECISION:
+        raise ValueError(f"Privacy precision must be 0 to {MAX_PRECISION} decimal places")
+    return value
+
+
+def token_precision(token: str) -> Optional[int]:
+    """Privacy precision in an access token's claims, read without verifying the token.
+
+    A token altered to drop the claim fails verification later anyway.
+
+    Returns:
+        The privacy claim, None if the token has none or cannot be decoded
+    """
+    try:
+        claims = jwt.decode(token, options={"verify_signature": False})
+    except jwt.InvalidTokenError:
+        return None
+    precision = claims.get(PRIVACY_CLAIM) if isinstance(claims, dict) else None
+    try:
+        return validate_precision(precision)
+    except ValueError:
+        # Unreadable setting: be as coarse as possible rather than precise
+        return 0
+
+
+def truncate(value: float, precision: int) -> float:
+    """Coordinate truncated (toward zero) to a number of decimal places."""
+    factor = 10 ** precision
+    return math.trunc(value * factor) / factor
+
+
+def _shorten_geohashes(value: Any, precision: int) -> Any:
+    if isinstance(value, str):
+        return value[:GEOHASH_LENGTHS[precision]]
+    if isinstance(value, dict):
+        return {name: _shorten_geohashes(item, precision) for name, item in value.items()}
+    if isinstance(value, list):
+        return [_shorten_geohashes(item, precision) for item in value]
+    return value
+
+
+def _is_coordinate_field(name: str) -> bool:
+    name = name.lower()
+    return name in COORDINATE_FIELDS or name.endswith(_COORDINATE_SUFFIXES)
+
+
+def _truncate_positions(value: Any, precision: int) -> Any:
+    if isinstance(value, list):
+        return [_truncate_positions(item, precision) for item in value]
+    if isinstance(value, (int, float)) and not isinstance(value, bool):
+        return truncate(value, precision)
+    return value
+
+
+def redact_content(content: Any, precision: int) -> Any:
+    """JSON content with coordinates truncated, geohashes shortened and
+    street-level fields omitted.
+
+    Args:
+        content: Decoded JSON document
+        precision: Decimal places kept
+
+    Returns:
+        A rewritten copy
+    """
+    if isinstance(content, list):
+        return [redact_content(item, precision) for item in content]
+    if not isinstance(content, dict):
+        return content
+    redacted = {}
+    for name, value in content.items():
+        if name in STREET_FIELDS:
+            continue
+        if name in POSITION_FIELDS:
+            redacted[name] = _truncate_positions(value, precision)
+        elif name in GEOHASH_FIELDS:
+            redacted[name] = _shorten_geohashes(value, precision)
+        elif _is_coordinate_field(name) and isinstance(value, (int, float)) and not isinstance(value, bool):
+            redacted[name] = truncate(value, precision)
+        else:
+            redacted[name] = redact_content(value, precision)
+    return redacted
+
+
+def _redact_match(match: "re.Match[str]") -> str:
+    candidate = match.group(0)
+    address = candidate.rstrip(":.")  # Sentence punctuation after an address
+    try:
+        ipaddress.ip_address(address.split("%", 1)[0])
+    except ValueError:
+        return candidate
+    return REDACTED_IP + candidate[len(address):]
+
+
+def redact_ips(text: str) -> str:
+    """Text with its IPv4 and IPv6 addresses replaced by [ip]."""
+    return _IP_CANDIDATE.sub(_redact_match, text)
+
+
+def current_precision() -> Optional[int]:
+    """Privacy precision of the request being handled (None outside privacy mode)."""
+    return _request_precision.get()
+
+
+def enter_privacy_mode(precision: int) -> contextvars.Token:
+    """Apply privacy mode to the rest of the current request context.
+
+    Returns:
+        Token for leave_privacy_mode
+    """
+    return _request_precision.set(precision)
+
+
+def leave_privacy_mode(token: contextvars.Token) -> None:
+    """Undo enter_privacy_mode."""
+    _request_precision.reset(token)
+
+
+_log_redaction_installed = False
+
+
+def install_log_redaction() -> None:
+    """Redact IP addresses from log records created in privacy mode (idempotent).
+
+    Wraps the log record factory, so records of every logger are covered,
+    including those of libraries. The message is formatted and redacted
+    when the record is created; tracebacks are not rewritten.
+    """
+    global _log_redaction_installed
+    if _log_redaction_installed:
+        return
+    create_record = logging.getLogRecordFactory()
+
+    def record_factory(*args, **kwargs) -> logging.LogRecord:
+        record = create_record(*args, **kwargs)
+        if _request_precision.get() is not None:
+            try:
+                message = record.getMessage()
+            except Exception:
+                message = str(record.msg)
+            record.msg = redact_ips(message)
+            record.args = None
+        return record
+
+    logging.setLogRecordFactory(record_factory)
+    _log_redaction_installed = True
@@ -0,0 +1,198 @@
+"""On-demand and continuous profiling of the API process.
+
+The admin API serves profiles for diagnosing CPU and allocation
+regressions in a running deployment:
+
+- CPU: every thread's stack is sampled for a few seconds and the samples
+  counted per stack, in the folded format flame graph tools, speedscope
+  and Pyroscope read (``main;handler;lookup 42``);
+- allocations: tracemalloc traces allocations for a few seconds, and the
+  allocation sites holding the most memory at the end are listed;
+- threads: the current stack of every thread and asyncio task.
+
+Captures are bounded (PROFILING_MAX_SECONDS) and run one at a time, so a
+profile costs a few percent of one core while it lasts and nothing
+otherwise. With PROFILING_PYROSCOPE_SERVER_ADDRESS the Pyroscope agent
+(the pyroscope extra) profiles continuously and pushes to that server.
+"""
+
+import asyncio
+import io
+import logging
+import sys
+import threading
+import time
+import traceback
+import tracemalloc
+from collections import Counter
+from typing import Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+
+class ProfilerBusyError(RuntimeError):
+    """A profile capture is already running."""
+
+
+def _folded(frame) -> str:
+    """A stack as semicolon-separated frames, outermost first."""
+    names = []
+    while frame is not None:
+        code = frame.f_code
+        names.append(f"{code.co_name} ({code.co_filename.rsplit('/', 1)[-1]}:{frame.f_lineno})")
+        frame = frame.f_back
+    return ";".join(reversed(names))
+
+
+class Profiler:
+    """Captures profiles of the running process, one at a time."""
+
+    def __init__(self, max_seconds: float = 60, sample_interval: float = 0.01):
+        """Initialize profiler.
+
+        Args:
+            max_seconds: Longest capture accepted
+            sample_interval: Seconds between CPU samples
+        """
+        self.max_seconds = max_seconds
+        self.sample_interval = sample_interval
+        self._busy = threading.Lock()
+
+    def _duration(self, seconds: float) -> float:
+        if not 0 < seconds <= self.max_seconds:
+            raise ValueError(f"seconds must be above 0 and at most {self.max_seconds:g}")
+        return seconds
+
+    def _acquire(self) -> None:
+        if not self._busy.acquire(blocking=False):
+            raise ProfilerBusyError("A profile is already being captured; try again when it ends")
+
+    def cpu_profile(self, seconds: float) -> str:
+        """Sample every thread's stack for a while (blocks the calling thread).
+
+        Args:
+            seconds: Capture duration
+
+        Returns:
+            Folded stacks, one line of frames and sample count per stack,
+            most sampled first
+
+        Raises:
+            ValueError: If seconds is out of range
+            ProfilerBusyError: If another capture is running
+        """
+        deadline = time.monotonic() + self._duration(seconds)
+        self._acquire()
+        try:
+            samples: Counter = Counter()
+            me = threading.get_ident()
+            names = {thread.ident: thread.name for thread in threading.enumerate()}
+            while time.monotonic() < deadline:
+                for ident, frame in sys._current_frames().items():
+                    if ident != me:
+                        samples[f"{names.get(ident, ident)};{_folded(frame)}"] += 1
+                time.sleep(self.sample_interval)
+        finally:
+            self._busy.release()
+        return "".join(f"{stack} {count}\n" for stack, count in samples.most_common())
+
+    def allocation_profile(self, seconds: float, limit: int = 50) -> List[Dict[str, object]]:
+        """Trace allocations for a while (blocks the calling thread).
+
+        Args:
+            seconds: Capture duration
+            limit: Allocation sites returned
+
+        Returns:
+            The sites still holding the most memory allocated during the
+            capture: ``{"site", "size_bytes", "count", "traceback"}``
+
+        Raises:
+            ValueError: If seconds is out of range
+            ProfilerBusyError: If another capture (or tracemalloc) is running
+        """
+        duration = self._duration(seconds)
+        self._acquire()
+        try:
+            if tracemalloc.is_tracing():
+                raise ProfilerBusyError("tracemalloc is already tracing this process")
+            tracemalloc.start(16)
+            try:
+                time.sleep(duration)
+                snapshot = tracemalloc.take_snapshot()
+            finally:
+                tracemalloc.stop()
+        finally:
+            self._busy.release()
+        snapshot = snapshot.filter_traces([tracemalloc.Filter(False, tracemalloc.__file__)])
+        return [
+            {
+                # Frames are ordered outermost first
+                "site": str(stat.traceback[-1]),
+                "size_bytes": stat.size,
+                "count": stat.count,
+                "traceback": [str(frame) for frame in stat.traceback],
+            }
+            for stat in snapshot.statistics("traceback")[:limit]
+        ]
+
+
+def thread_dump(loop: Optional[asyncio.AbstractEventLoop] = None) -> str:
+    """Current stack of every thread and of the loop's asyncio tasks."""
+    names = {thread.ident: thread.name for thread in threading.enumerate()}
+    sections = []
+    for ident, frame in sys._current_frames().items():
+        stack = "".join(traceback.format_stack(frame))
+        sections.append(f"Thread {names.get(ident, ident)} ({ident}):\n{stack}")
+    if loop is not None:
+        for task in asyncio.all_tasks(loop):
+            stack = io.StringIO()
+            task.print_stack(file=stack)
+            sections.append(stack.getvalue())
+    return "\n".join(sections)
+
+
+def start_continuous_profiling(config) -> bool:
+    """Start the Pyroscope agent if a server is configured.
+
+    Args:
+        config: Configuration (PROFILING_PYROSCOPE_* settings)
+
+    Returns:
+        True if the agent was started; False without a server, or when
+        pyroscope-io is not installed (logged, as profiling must not keep
+        the service from starting)
+    """
+    if not config.profiling_pyroscope_server_address:
+        return False
+    try:
+        import pyroscope
+    except ImportError:
+        logger.error("pyroscope-io is required for PROFILING_PYROSCOPE_SERVER_ADDRESS; not profiling")
+        return False
+    pyroscope.configure(
+        application_name=config.profiling_pyroscope_application_name,
+        server_address=config.profiling_pyroscope_server_address,
+        sample_rate=config.profiling_pyroscope_sample_rate,
+        tags=config.profiling_pyroscope_tags,
+    )
+    logger.info(f"Continuous profiling to {config.profiling_pyroscope_server_address}")
+    return True
+
+
+# Global profiler (created lazily from configuration)
+_profiler: Optional[Profiler] = None
+
+
+def get_profiler() -> Profiler:
+    """Get the global profiler.
+
+    Returns:
+        Profiler bounded by PROFILING_MAX_SECONDS
+    """
+    global _profiler
+    if _profiler is None:
+        from src.config import get_config
+
+        _profiler = Profiler(max_seconds=get_config().profiling_max_seconds)
+    return _profiler
@@ -0,0 +1,270 @@
+"""Quota threshold alerts to tenants, by webhook and email.
+
+As an API key's usage of a quota cap crosses one of QUOTA_ALERT_THRESHOLDS
+(see src.services.quota_service), its tenant is told before requests are
+refused: a `quota.threshold` webhook event to the tenant's webhooks
+subscribed to it, and an email to the addresses the tenant named.
+
+Tenants choose which thresholds they hear of, whether webhooks are sent
+and which addresses are emailed (PUT /api/v1/usage/quota-alerts). Without
+preferences every threshold is sent by webhook and nothing by email.
+Email needs SMTP_HOST.
+"""
+
+import asyncio
+import logging
+import re
+import smtplib
+from dataclasses import dataclass, field
+from email.message import EmailMessage
+from typing import Iterable, List, Optional, Sequence, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import QuotaAlertPreference
+from src.services.quota_service import QuotaAlert
+
+logger = logging.getLogger(__name__)
+
+QUOTA_ALERT_EVENT = "quota.threshold"
+
+_MAX_EMAILS = 10
+_EMAIL = re.compile(r"^[^@\s]+@[^@\s]+\.[^@\s]+$")
+
+
+@dataclass
+class AlertPreferences:
+    """How a tenant is alerted of quota thresholds."""
+
+    thresholds: List[int]
+    webhook: bool = True
+    emails: List[str] = field(default_factory=list)
+
+
+class QuotaAlertService:
+    """Stores tenants' quota alert preferences."""
+
+    def __init__(self, session: Session):
+        """Initialize quota alert service.
+
+        Args:
+            session: SQLAlchemy database session
+        """
+        self.session = session
+
+    def get_row(self, tenant_id: str) -> Optional[QuotaAlertPreference]:
+        """A tenant's stored preferences, if any."""
+        return (
+            self.session.query(QuotaAlertPreference)
+            .filter(QuotaAlertPreference.tenant_id == tenant_id)
+            .first()
+        )
+
+    def preferences(self, tenant_id: str, available: Sequence[int]) -> AlertPreferences:
+        """Preferences applied to a tenant: its own, else every available threshold by webhook.
+
+        Args:
+            tenant_id: Tenant
+            available: Thresholds alerts are raised for (QUOTA_ALERT_THRESHOLDS)
+        """
+        row = self.get_row(tenant_id)
+        if row is None:
+            return AlertPreferences(list(available))
+        # Thresholds no longer configured are never raised
+        return AlertPreferences([t for t in row.thresholds if t in available], row.webhook, list(row.emails))
+
+    def set_preferences(
+        self,
+        tenant_id: str,
+        thresholds: Iterable[int],
+        webhook: bool,
+        emails: Iterable[str],
+        available: Sequence[int],
+    ) -> QuotaAlertPreference:
+        """Create or replace a tenant's preferences.
+
+        Args:
+            tenant_id: Owning tenant
+            thresholds: Thresholds to be alerted of (a subset of available)
+            webhook: Whether to deliver quota.threshold webhook events
+            emails: Addresses to alert by email
+            available: Thresholds alerts are raised for (QUOTA_ALERT_THRESHOLDS)
+
+        Returns:
+            Stored QuotaAlertPreference
+
+        Raises:
+            ValueError: If a threshold is not available or an address is invalid, or storage fails
+        """
+        thresholds = sorted(set(thresholds))
+        unknown = [t for t in thresholds if t not in available]
+        if unknown:
+            raise ValueError(
+                f"Unknown quota alert thresholds {unknown}: expected some of {', '.join(map(str, available))}"
+            )
+        emails = sorted({email.strip().lower() for email in emails if email.strip()})
+        invalid = [email for email in emails if not _EMAIL.match(email)]
+        if invalid:
+            raise ValueError(f"Invalid email addresses: {', '.join(invalid)}")
+        if len(emails) > _MAX_EMAILS:
+            raise ValueError(f"At most {_MAX_EMAILS} email addresses can be alerted")
+
+        row = self.get_row(tenant_id)
+        if row is None:
+            row = QuotaAlertPreference(tenant_id=tenant_id)
+            self.session.add(row)
+        row.thresholds, row.webhook, row.emails = thresholds, webhook, emails
+        try:
+            self.session.commit()
+            self.session.refresh(row)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store quota alert preferences: {str(e)}")
+        logger.info(f"Quota alerts of tenant {tenant_id}: {thresholds}, webhook={webhook}, {len(emails)} emails")
+        return row
+
+    def delete_preferences(self, tenant_id: str) -> bool:
+        """Revert a tenant to the default preferences.
+
+        Returns:
+            True if the tenant had preferences
+        """
+        row = self.get_row(tenant_id)
+        if row is None:
+            return False
+        self.session.delete(row)
+        self.session.commit()
+        return True
+
+
+class SmtpMailer:
+    """Sends plain-text email through an SMTP relay."""
+
+    def __init__(
+        self,
+        host: str,
+        port: int = 587,
+        username: str = "",
+        password: str = "",
+        sender: str = "geolocation-engine@localhost",
+        starttls: bool = True,
+        timeout_seconds: float = 10.0,
+    ):
+        """Initialize mailer.
+
+        Args:
+            host: SMTP relay
+            port: SMTP port
+            username: Login (empty: none)
+            password: Password of the login
+            sender: From address
+            starttls: Upgrade the connection with STARTTLS before logging in
+            timeout_seconds: Connection timeout
+        """
+        self.host = host
+        self.port = port
+        self.username = username
+        self.password = password
+        self.sender = sender
+        self.starttls = starttls
+        self.timeout_seconds = timeout_seconds
+
+    def send(self, recipients: Sequence[str], subject: str, body: str) -> None:
+        """Send one message (blocking; run it in a thread from the event loop).
+
+        Raises:
+            smtplib.SMTPException, OSError: If the relay cannot be reached or refuses the message
+        """
+        message = EmailMessage()
+        message["From"] = self.sender
+        message["To"] = ", ".join(recipients)
+        message["Subject"] = subject
+        message.set_content(body)
+        with smtplib.SMTP(self.host, self.port, timeout=self.timeout_seconds) as smtp:
+            if self.starttls:
+                smtp.starttls()
+            if self.username:
+                smtp.login(self.username, self.password)
+            smtp.send_message(message)
+
+
+def alert_email(tenant_id: str, alert: QuotaAlert) -> Tuple[str, str]:
+    """Subject and body of a quota alert email."""
+    quota = "monthly requests" if alert.scope == "monthly" else f"{alert.scope} requests"
+    subject = f"Quota alert: API key {alert.key_id} has used {alert.threshold}% of its {quota}"
+    body = (
+        f"Tenant {tenant_id}: API key {alert.key_id} ({alert.plan} plan) has made {alert.used} of the "
+        f"{alert.limit} {quota} its plan allows in {alert.month} (UTC).\n\n"
+        + (
+            "Further requests will be refused with 429 until the month ends.\n"
+            if alert.threshold >= 100 else
+            "Requests beyond the quota will be refused with 429 until the month ends.\n"
+        )
+    )
+    return subject, body
+
+
+async def _send_email(mailer: SmtpMailer, tenant_id: str, recipients: List[str], alert: QuotaAlert) -> None:
+    subject, body = alert_email(tenant_id, alert)
+    try:
+        await asyncio.to_thread(mailer.send, recipients, subject, body)
+    except Exception as e:
+        logger.error(f"Failed to email quota alert of API key {alert.key_id} to tenant {tenant_id}: {e}")
+
+
+def notify(session: Session, tenant_id: str, alert: QuotaAlert, available: Sequence[int]) -> None:
+    """Alert a tenant of a crossed threshold as its preferences ask.
+
+    Must be called from the event loop, which delivers the webhooks and
+    emails. Failures are logged; the threshold crossing has already been
+    logged by the quota enforcer.
+
+    Args:
+        session: Database session for the preferences and webhooks
+        tenant_id: Tenant of the key
+        alert: The crossing
+        available: Thresholds alerts are raised for (QUOTA_ALERT_THRESHOLDS)
+    """
+    from src.services.webhook_service import WebhookService, get_webhook_dispatcher
+
+    preferences = QuotaAlertService(session).preferences(tenant_id, available)
+    if alert.threshold not in preferences.thresholds:
+        return
+    if preferences.webhook:
+        try:
+            targets = WebhookService(session).targets(tenant_id, QUOTA_ALERT_EVENT)
+            if targets:
+                asyncio.create_task(get_webhook_dispatcher().dispatch(targets, QUOTA_ALERT_EVENT, alert.to_dict()))
+        except Exception as e:
+            logger.error(f"Failed to schedule webhook delivery of quota alert of API key {alert.key_id}: {e}")
+    if preferences.emails:
+        mailer = get_quota_mailer()
+        if mailer is None:
+            logger.warning(f"Quota alert of API key {alert.key_id} not emailed: SMTP_HOST is not set")
+        else:
+            asyncio.create_task(_send_email(mailer, tenant_id, preferences.emails, alert))
+
+
+# Global mailer (configured lazily from SMTP_* settings; None without SMTP_HOST)
+_quota_mailer: Optional[SmtpMailer] = None
+
+
+def get_quota_mailer() -> Optional[SmtpMailer]:
+    """Get the global mailer of quota alerts, if SMTP_HOST is set."""
+    global _quota_mailer
+    if _quota_mailer is None:
+        from src.config import get_config
+
+        config = get_config()
+        if not config.smtp_host:
+            return None
+        _quota_mailer = SmtpMailer(
+            config.smtp_host,
+            config.smtp_port,
+            config.smtp_username,
+            config.smtp_password,
+            config.smtp_from,
+            config.smtp_starttls,
+            config.smtp_timeout_seconds,
+        )
+    return _quota_mailer
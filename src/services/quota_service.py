@@ -0,0 +1,370 @@
+"""Plan-based monthly request quotas of tenant API keys.
+
+An API key can be put on a quota plan. A plan caps the requests made with
+the key's tokens per calendar month (UTC), in total and for individual
+endpoints (method and route template, e.g. "POST /api/v1/jobs"). Callers
+are warned in response headers once they have used `warn_ratio` of a cap
+and refused (429, E015) from `block_ratio` of it until the month ends.
+Keys without a plan are not metered.
+
+The built-in plans are free, standard and enterprise; QUOTA_PLANS (JSON)
+changes them or adds others. The plan travels in the key's access tokens
+(plan claim), so a change reaches a session when its token is refreshed.
+
+Tenants are also alerted as their keys cross QUOTA_ALERT_THRESHOLDS
+percentages of a cap (80, 95 and 100 by default), through webhooks and
+email as their preferences ask (see src.services.quota_alert_service).
+Each threshold is crossed by exactly one request per month: the one whose
+count reaches it, so alerts need no bookkeeping of their own.
+
+With QUOTA_REDIS_URL the monthly counters are shared by all workers
+(`pip install -e ".[redis]"`); otherwise each process counts on its own.
+"""
+
+import json
+import logging
+import math
+from dataclasses import dataclass, field
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from src.services.auth_throttle_service import InMemoryThrottleStore, RedisThrottleStore, ThrottleStore
+
+logger = logging.getLogger(__name__)
+
+# Counters outlive their month by a few days, so that a late request still finds them
+_COUNTER_TTL_SECONDS = 35 * 24 * 3600
+
+# Percentages of a cap whose crossing tenants are alerted of
+DEFAULT_ALERT_THRESHOLDS: Tuple[int, ...] = (80, 95, 100)
+
+
+@dataclass(frozen=True)
+class QuotaPlan:
+    """Monthly request caps of a plan."""
+
+    name: str
+    monthly_requests: Optional[int] = None                      # None: no total cap
+    endpoints: Dict[str, int] = field(default_factory=dict)     # Endpoint -> monthly cap
+    warn_ratio: float = 0.8                                     # Share of a cap that warns
+    block_ratio: float = 1.0                                    # Share of a cap that refuses
+
+    def to_dict(self) -> Dict[str, Any]:
+        return {
+            "name": self.name,
+            "monthly_requests": self.monthly_requests,
+            "endpoints": dict(self.endpoints),
+            "warn_ratio": self.warn_ratio,
+            "block_ratio": self.block_ratio,
+        }
+
+
+DEFAULT_PLANS: Dict[str, QuotaPlan] = {
+    "free": QuotaPlan(
+        "free",
+        monthly_requests=10_000,
+        endpoints={
+            "POST /api/v1/jobs": 10,
+            "POST /api/v1/jobs/upload": 10,
+            "POST /api/v1/lookup/batch": 100,
+            "GET /api/v1/geocode": 1_000,
+        },
+    ),
+    "standard": QuotaPlan(
+        "standard",
+        monthly_requests=1_000_000,
+        endpoints={
+            "POST /api/v1/jobs": 1_000,
+            "POST /api/v1/jobs/upload": 1_000,
+            "POST /api/v1/lookup/batch": 10_000,
+            "GET /api/v1/geocode": 100_000,
+        },
+    ),
+    "enterprise": QuotaPlan("enterprise"),
+}
+
+
+def _cap(value: Any, what: str) -> int:
+    if not isinstance(value, int) or isinstance(value, bool) or value < 1:
+        raise ValueError(f"{what} must be a positive integer")
+    return value
+
+
+def parse_plans(raw: str, warn_ratio: float = 0.8, block_ratio: float = 1.0) -> Dict[str, QuotaPlan]:
+    """The built-in plans, changed or extended by a QUOTA_PLANS document.
+
+    Args:
+        raw: JSON object mapping plan name -> {"monthly_requests", "endpoints",
+            "warn_ratio", "block_ratio"} (omitted fields keep the built-in plan's)
+        warn_ratio: Default share of a cap that warns
+        block_ratio: Default share of a cap that refuses
+
+    Raises:
+        ValueError: If the document or a plan is invalid
+    """
+    defaults = {
+        name: QuotaPlan(name, plan.monthly_requests, dict(plan.endpoints), warn_ratio, block_ratio)
+        for name, plan in DEFAULT_PLANS.items()
+    }
+    if not raw.strip():
+        return defaults
+    try:
+        entries = json.loads(raw)
+    except json.JSONDecodeError as e:
+        raise ValueError(f"Invalid QUOTA_PLANS: {str(e)}")
+    if not isinstance(entries, dict):
+        raise ValueError("Invalid QUOTA_PLANS: expected a JSON object")
+
+    plans = dict(defaults)
+    for name, entry in entries.items():
+        if not isinstance(entry, dict):
+            raise ValueError(f"Invalid quota plan '{name}': expected a JSON object")
+        base = defaults.get(name, QuotaPlan(name, warn_ratio=warn_ratio, block_ratio=block_ratio))
+        endpoints = entry.get("endpoints", base.endpoints)
+        if not isinstance(endpoints, dict):
+            raise ValueError(f"Invalid quota plan '{name}': endpoints must map endpoints to caps")
+        monthly = entry.get("monthly_requests", base.monthly_requests)
+        plan = QuotaPlan(
+            name,
+            monthly_requests=None if monthly is None else _cap(monthly, "monthly_requests"),
+            endpoints={endpoint: _cap(cap, f"Cap of {endpoint}") for endpoint, cap in endpoints.items()},
+            warn_ratio=float(entry.get("warn_ratio", base.warn_ratio)),
+            block_ratio=float(entry.get("block_ratio", base.block_ratio)),
+        )
+        if not 0 < plan.warn_ratio <= plan.block_ratio:
+            raise ValueError(f"Invalid quota plan '{name}': need 0 < warn_ratio <= block_ratio")
+        plans[name] = plan
+    return plans
+
+
+def parse_thresholds(raw: str) -> Tuple[int, ...]:
+    """Alert thresholds of a QUOTA_ALERT_THRESHOLDS list, ascending.
+
+    Args:
+        raw: Comma-separated percentages of a cap, 1 to 100
+
+    Raises:
+        ValueError: If a threshold is not a whole percentage
+    """
+    thresholds = set()
+    for part in raw.split(","):
+        if not part.strip():
+            continue
+        try:
+            threshold = int(part.strip())
+        except ValueError:
+            threshold = 0
+        if not 1 <= threshold <= 100:
+            raise ValueError(f"Invalid quota alert threshold {part.strip()!r}: expected a percentage from 1 to 100")
+        thresholds.add(threshold)
+    return tuple(sorted(thresholds))
+
+
+def threshold_count(limit: int, threshold: int) -> int:
+    """Requests at which a cap's threshold (a percentage) is crossed."""
+    return max(1, math.ceil(limit * threshold / 100))
+
+
+def month_of(at: datetime) -> str:
+    """Quota period of a time: its UTC month, YYYY-MM."""
+    return at.strftime("%Y-%m")
+
+
+def month_end(at: datetime) -> datetime:
+    """Start of the month after a time's, when its quotas reset."""
+    return datetime(at.year + at.month // 12, at.month % 12 + 1, 1)
+
+
+@dataclass
+class QuotaUsage:
+    """Usage of one cap after a request."""
+
+    scope: str          # "monthly" or the endpoint
+    used: int
+    limit: int
+    warn_at: int
+    block_at: int
+
+    @property
+    def warning(self) -> bool:
+        return self.used >= self.warn_at
+
+    @property
+    def blocked(self) -> bool:
+        return self.used > self.block_at
+
+
+@dataclass
+class QuotaAlert:
+    """A key's usage of a cap crossing an alert threshold."""
+
+    key_id: str
+    plan: str
+    scope: str          # "monthly" or the endpoint
+    threshold: int      # Percentage of the cap
+    used: int
+    limit: int
+    month: str          # YYYY-MM
+
+    def to_dict(self) -> Dict[str, Any]:
+        return {
+            "key_id": self.key_id,
+            "plan": self.plan,
+            "quota": self.scope,
+            "threshold": self.threshold,
+            "used": self.used,
+            "limit": self.limit,
+            "month": self.month,
+        }
+
+
+@dataclass
+class QuotaDecision:
+    """Outcome of counting a request against its key's plan."""
+
+    plan: str
+    usages: List[QuotaUsage]
+    reset_seconds: int              # Until the month ends
+    alerts: List[QuotaAlert] = field(default_factory=list)     # Thresholds this request crossed
+
+    @property
+    def allowed(self) -> bool:
+        return not any(usage.blocked for usage in self.usages)
+
+    @property
+    def blocking(self) -> Optional[QuotaUsage]:
+        return next((usage for usage in self.usages if usage.blocked), None)
+
+    def headers(self) -> Dict[str, str]:
+        """X-Quota-* response headers of the total cap, with a warning near any cap."""
+        headers = {"X-Quota-Plan": self.plan, "X-Quota-Reset": str(self.reset_seconds)}
+        monthly = next((usage for usage in self.usages if usage.scope == "monthly"), None)
+        if monthly is not None:
+            headers["X-Quota-Limit"] = str(monthly.limit)
+            headers["X-Quota-Remaining"] = str(max(0, monthly.limit - monthly.used))
+        warnings = [
+            f"{usage.scope} {min(usage.used, usage.limit)}/{usage.limit}"
+            for usage in self.usages if usage.warning
+        ]
+        if warnings:
+            headers["X-Quota-Warning"] = ", ".join(warnings)
+        return headers
+
+
+class QuotaEnforcer:
+    """Counts requests of API keys against their plans' monthly caps."""
+
+    def __init__(
+        self,
+        plans: Optional[Dict[str, QuotaPlan]] = None,
+        store: Optional[ThrottleStore] = None,
+        alert_thresholds: Sequence[int] = DEFAULT_ALERT_THRESHOLDS,
+    ):
+        """Initialize enforcer.
+
+        Args:
+            plans: Plans by name (default: the built-in ones)
+            store: Counter store (process memory by default)
+            alert_thresholds: Percentages of a cap whose crossing is alerted
+        """
+        self.plans = plans if plans is not None else dict(DEFAULT_PLANS)
+        self.store = store or InMemoryThrottleStore()
+        self.alert_thresholds = tuple(sorted(alert_thresholds))
+
+    def _count(self, counter: str, scope: str, limit: int, plan: QuotaPlan) -> QuotaUsage:
+        used = self.store.hit(counter, _COUNTER_TTL_SECONDS)
+        return QuotaUsage(
+            scope,
+            used,
+            limit,
+            warn_at=max(1, int(limit * plan.warn_ratio)),
+            block_at=max(1, int(limit * plan.block_ratio)),
+        )
+
+    def count(
+        self, key_id: str, plan_name: str, endpoint: str, at: Optional[datetime] = None
+    ) -> Optional[QuotaDecision]:
+        """Count a request of a key.
+
+        Args:
+            key_id: API key the request's token was issued for
+            plan_name: Plan of the key (from the token)
+            endpoint: Method and route template of the request
+            at: Time of the request (default: now, UTC)
+
+        Returns:
+            The decision, or None if the plan is unknown (the request is not metered)
+        """
+        plan = self.plans.get(plan_name)
+        if plan is None:
+            logger.warning(f"API key {key_id} has unknown quota plan '{plan_name}'; not metered")
+            return None
+        at = at or datetime.utcnow()
+        month = month_of(at)
+        usages = []
+        if plan.monthly_requests is not None:
+            usages.append(self._count(f"quota:{key_id}:{month}", "monthly", plan.monthly_requests, plan))
+        if endpoint in plan.endpoints:
+            usages.append(
+                self._count(f"quota:{key_id}:{month}:{endpoint}", endpoint, plan.endpoints[endpoint], plan)
+            )
+        for usage in usages:
+            threshold = "block" if usage.blocked else "warn" if usage.warning else None
+            if threshold and self.store.mark(f"quota:{key_id}:{month}:{usage.scope}:{threshold}", _COUNTER_TTL_SECONDS):
+                logger.warning(
+                    f"API key {key_id} ({plan.name} plan) reached the {threshold} threshold of its "
+                    f"{usage.scope} quota: {usage.used}/{usage.limit} in {month}"
+                )
+        alerts = []
+        for usage in usages:
+            # Thresholds of small caps can fall on the same count; the highest one speaks for them
+            crossed = [t for t in self.alert_thresholds if threshold_count(usage.limit, t) == usage.used]
+            if crossed:
+                alerts.append(QuotaAlert(key_id, plan.name, usage.scope, crossed[-1], usage.used, usage.limit, month))
+        return QuotaDecision(plan.name, usages, int((month_end(at) - at).total_seconds()), alerts)
+
+    def needs_endpoint(self, plan_name: str) -> bool:
+        """Whether a plan caps individual endpoints (so the route must be resolved)."""
+        plan = self.plans.get(plan_name)
+        return plan is not None and bool(plan.endpoints)
+
+
+def validate_plan(name: Optional[str], plans: Optional[Dict[str, QuotaPlan]] = None) -> Optional[str]:
+    """Check a plan name (None: not metered).
+
+    Raises:
+        ValueError: If no plan has the name
+    """
+    if name is None:
+        return None
+    plans = plans if plans is not None else get_quota_enforcer().plans
+    if name not in plans:
+        raise ValueError(f"Unknown quota plan '{name}' (plans: {', '.join(sorted(plans))})")
+    return name
+
+
+# Global enforcer (plans and store loaded lazily from configuration)
+_quota_enforcer: Optional[QuotaEnforcer] = None
+
+
+def get_quota_enforcer() -> QuotaEnforcer:
+    """Get the global quota enforcer.
+
+    Raises:
+        ValueError: If QUOTA_PLANS or QUOTA_ALERT_THRESHOLDS is invalid
+        RuntimeError: If QUOTA_REDIS_URL is set but redis is not installed
+    """
+    global _quota_enforcer
+    if _quota_enforcer is None:
+        from src.config import get_config
+
+        config = get_config()
+        plans = parse_plans(config.quota_plans, config.quota_warn_ratio, config.quota_block_ratio)
+        thresholds = parse_thresholds(config.quota_alert_thresholds)
+        if config.quota_redis_url:
+            store: ThrottleStore = RedisThrottleStore.from_url(config.quota_redis_url, config.quota_key_prefix)
+        else:
+            logger.info("QUOTA_REDIS_URL not set; quotas are counted per process")
+            store = InMemoryThrottleStore()
+        _quota_enforcer = QuotaEnforcer(plans, store, thresholds)
+    return _quota_enforcer
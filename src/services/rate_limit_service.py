@@ -0,0 +1,462 @@
+"""Rate limiting of API callers.
+
+Two algorithms (RATE_LIMIT_ALGORITHM):
+
+- token_bucket: each caller has a bucket holding up to RATE_LIMIT_CAPACITY
+  tokens (the burst allowance), refilled at RATE_LIMIT_REFILL_RATE tokens
+  per second; a request takes one token and is refused when the bucket
+  is empty.
+- sliding_window: a caller may make RATE_LIMIT_CAPACITY requests in any
+  RATE_LIMIT_WINDOW_SECONDS; the times of its accepted requests within
+  the window are kept, so there is no burst at window boundaries.
+
+On top of either, RATE_LIMIT_SPIKE_LIMIT arrests spikes: a caller may
+make at most that many requests in any RATE_LIMIT_SPIKE_WINDOW_SECONDS
+(a sliding window, one second by default), however much of its sustained
+allowance is left, so a burst of thousands of requests in one second is
+refused before it reaches the database.
+
+Callers are identified by their bearer token's tenant and subject (an API
+key, user or operator), and callers without a valid token by their client
+address, so one noisy client cannot starve the others.
+
+With RATE_LIMIT_REDIS_URL the limits are enforced across all replicas
+(`pip install -e ".[redis]"`): each check is one Lua script, atomic in
+Redis and timed by the Redis server's clock, so replicas with skewed
+clocks agree. Otherwise each process limits on its own, and a caller
+spread over N workers gets up to N times the rate.
+
+Every 429 of the API (rate limits, brute-force lockouts, quotas) carries
+Retry-After and the RateLimit-Limit, RateLimit-Remaining and
+RateLimit-Reset headers of the IETF draft, built by rate_limit_headers
+from the state of the limit that refused the request.
+"""
+
+import logging
+import math
+import threading
+import time
+import uuid
+from collections import deque
+from dataclasses import dataclass
+from typing import Deque, Dict, Optional, Tuple
+
+logger = logging.getLogger(__name__)
+
+
+def rate_limit_headers(
+    limit: int, remaining: int, reset_seconds: int, retry_after: Optional[int] = None
+) -> Dict[str, str]:
+    """RateLimit-* response headers (IETF draft), with Retry-After for a refusal.
+
+    Args:
+        limit: Requests the limit allows
+        remaining: Requests that could be made right away
+        reset_seconds: Until the limit is fully available again
+        retry_after: Until a refused request may be retried (None: not refused)
+    """
+    headers = {
+        "RateLimit-Limit": str(limit),
+        "RateLimit-Remaining": str(max(0, remaining)),
+        "RateLimit-Reset": str(max(0, reset_seconds)),
+    }
+    if retry_after is not None:
+        # Clients retrying at once would only be refused again
+        headers["Retry-After"] = str(max(1, retry_after))
+    return headers
+
+
+@dataclass
+class RateLimitDecision:
+    """Outcome of a caller's rate limit check."""
+
+    allowed: bool
+    limit: int                # Bucket capacity, or requests per window
+    remaining: int            # Requests that could be made right away
+    reset_seconds: int        # Until the bucket is full again, or the oldest request leaves the window
+    retry_after: int          # Until a request would be allowed (0 if allowed)
+    policy: str = ""          # RateLimit-Policy of the limit that decided
+
+    def headers(self) -> Dict[str, str]:
+        """RateLimit-* response headers (IETF draft), with Retry-After when refused."""
+        return rate_limit_headers(
+            self.limit, self.remaining, self.reset_seconds, None if self.allowed else self.retry_after
+        )
+
+
+class RateLimitStore:
+    """Token buckets by caller key."""
+
+    def take(self, key: str, capacity: int, refill_rate: float) -> Tuple[bool, float]:
+        """Take a token from a bucket, refilling it for the time elapsed.
+
+        Returns:
+            Whether a token was taken, and the tokens left afterwards
+        """
+        raise NotImplementedError
+
+
+class InMemoryRateLimitStore(RateLimitStore):
+    """Process-local buckets (not shared between workers)."""
+
+    def __init__(self, clock=time.monotonic):
+        self._buckets: Dict[str, Tuple[float, float]] = {}
+        self._lock = threading.Lock()
+        self._clock = clock
+        self._pruned_at = clock()
+
+    def _prune(self, now: float, capacity: int, refill_rate: float) -> None:
+        # Buckets untouched long enough to be full again are the same as new ones
+        idle = capacity / refill_rate
+        if now - self._pruned_at < idle:
+            return
+        self._pruned_at = now
+        for stale in [k for k, (_, updated) in self._buckets.items() if now - updated >= idle]:
+            del self._buckets[stale]
+
+    def take(self, key: str, capacity: int, refill_rate: float) -> Tuple[bool, float]:
+        now = self._clock()
+        with self._lock:
+            self._prune(now, capacity, refill_rate)
+            tokens, updated = self._buckets.get(key, (float(capacity), now))
+            tokens = min(float(capacity), tokens + (now - updated) * refill_rate)
+            allowed = tokens >= 1
+            if allowed:
+                tokens -= 1
+            self._buckets[key] = (tokens, now)
+        return allowed, tokens
+
+
+# Refill and take atomically; the hash expires once the bucket would be full
+_TAKE_SCRIPT = """
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1]) or capacity
+local updated = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - updated) * rate)
+local allowed = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil(capacity / rate) + 1)
+return {allowed, tostring(tokens)}
+"""
+
+
+class RedisRateLimitStore(RateLimitStore):
+    """Redis hashes with expiry, one per bucket, updated by a Lua script."""
+
+    def __init__(self, client, key_prefix: str = "rate-limit"):
+        """Initialize Redis store.
+
+        Args:
+            client: redis.Redis client
+            key_prefix: Prefix of the Redis keys
+        """
+        self.client = client
+        self.key_prefix = key_prefix
+        self._take = client.register_script(_TAKE_SCRIPT)
+
+    @classmethod
+    def from_url(cls, url: str, key_prefix: str = "rate-limit") -> "RedisRateLimitStore":
+        """Connect to Redis.
+
+        Raises:
+            RuntimeError: If the redis package is not installed
+        """
+        try:
+            import redis
+        except ImportError:
+            raise RuntimeError("redis is required for RATE_LIMIT_REDIS_URL")
+        return cls(redis.Redis.from_url(url), key_prefix)
+
+    def take(self, key: str, capacity: int, refill_rate: float) -> Tuple[bool, float]:
+        allowed, tokens = self._take(keys=[f"{self.key_prefix}:{key}"], args=[capacity, refill_rate])
+        return bool(int(allowed)), float(tokens)
+
+
+class SlidingWindowStore:
+    """Times of callers' accepted requests within a window."""
+
+    def add(self, key: str, limit: int, window_seconds: float) -> Tuple[bool, int, float]:
+        """Record a request unless the window already holds limit requests.
+
+        Returns:
+            Whether it was recorded, the requests in the window afterwards,
+            and the seconds until the oldest of them leaves the window
+        """
+        raise NotImplementedError
+
+
+class InMemorySlidingWindowStore(SlidingWindowStore):
+    """Process-local request logs (not shared between workers)."""
+
+    def __init__(self, clock=time.monotonic):
+        self._logs: Dict[str, Deque[float]] = {}
+        self._lock = threading.Lock()
+        self._clock = clock
+        self._pruned_at = clock()
+
+    def add(self, key: str, limit: int, window_seconds: float) -> Tuple[bool, int, float]:
+        now = self._clock()
+        with self._lock:
+            if now - self._pruned_at >= window_seconds:
+                self._pruned_at = now
+                for stale in [k for k, log in self._logs.items() if not log or log[-1] <= now - window_seconds]:
+                    del self._logs[stale]
+            log = self._logs.setdefault(key, deque())
+            while log and log[0] <= now - window_seconds:
+                log.popleft()
+            allowed = len(log) < limit
+            if allowed:
+                log.append(now)
+            oldest = log[0] if log else now
+            return allowed, len(log), max(0.0, oldest + window_seconds - now)
+
+
+# Drop the requests that left the window, then record this one if there is
+# room; the sorted set expires with its newest member
+_SLIDING_WINDOW_SCRIPT = """
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+    redis.call('ZADD', KEYS[1], now, now .. ':' .. ARGV[3])
+    redis.call('PEXPIRE', KEYS[1], window)
+    count = count + 1
+    allowed = 1
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+local wait = 0
+if oldest[2] then
+    wait = math.max(0, tonumber(oldest[2]) + window - now)
+end
+return {allowed, count, wait}
+"""
+
+
+class RedisSlidingWindowStore(SlidingWindowStore):
+    """Redis sorted sets of request times, one per caller, updated by a Lua script."""
+
+    def __init__(self, client, key_prefix: str = "rate-limit"):
+        """Initialize Redis store.
+
+        Args:
+            client: redis.Redis client
+            key_prefix: Prefix of the Redis keys
+        """
+        self.client = client
+        self.key_prefix = key_prefix
+        self._add = client.register_script(_SLIDING_WINDOW_SCRIPT)
+
+    @classmethod
+    def from_url(cls, url: str, key_prefix: str = "rate-limit") -> "RedisSlidingWindowStore":
+        """Connect to Redis.
+
+        Raises:
+            RuntimeError: If the redis package is not installed
+        """
+        try:
+            import redis
+        except ImportError:
+            raise RuntimeError("redis is required for RATE_LIMIT_REDIS_URL")
+        return cls(redis.Redis.from_url(url), key_prefix)
+
+    def add(self, key: str, limit: int, window_seconds: float) -> Tuple[bool, int, float]:
+        allowed, count, wait_ms = self._add(
+            keys=[f"{self.key_prefix}:window:{key}"],
+            # Members are unique, so that simultaneous requests are all counted
+            args=[limit, int(window_seconds * 1000), uuid.uuid4().hex],
+        )
+        return bool(int(allowed)), int(count), int(wait_ms) / 1000.0
+
+
+class RateLimiter:
+    """Decides whether a caller may make another request."""
+
+    @property
+    def policy(self) -> str:
+        """RateLimit-Policy header value."""
+        raise NotImplementedError
+
+    def check(self, key: str) -> RateLimitDecision:
+        """Count a request of a caller, unless it is refused."""
+        raise NotImplementedError
+
+
+class TokenBucketLimiter(RateLimiter):
+    """Token bucket per caller: bursts up to the capacity, then the refill rate."""
+
+    def __init__(self, store: Optional[RateLimitStore] = None, capacity: int = 100, refill_rate: float = 10.0):
+        """Initialize limiter.
+
+        Args:
+            store: Bucket store (process memory by default)
+            capacity: Tokens a bucket holds (the burst allowance)
+            refill_rate: Tokens added per second (the sustained rate)
+
+        Raises:
+            ValueError: If the capacity or rate is not positive
+        """
+        if capacity < 1 or refill_rate <= 0:
+            raise ValueError("Rate limit capacity and refill rate must be positive")
+        self.store = store or InMemoryRateLimitStore()
+        self.capacity = capacity
+        self.refill_rate = refill_rate
+
+    @property
+    def policy(self) -> str:
+        """The capacity per time to refill it."""
+        return f"{self.capacity};w={math.ceil(self.capacity / self.refill_rate)}"
+
+    def check(self, key: str) -> RateLimitDecision:
+        """Take a token for a request of a caller."""
+        allowed, tokens = self.store.take(key, self.capacity, self.refill_rate)
+        return RateLimitDecision(
+            allowed=allowed,
+            limit=self.capacity,
+            remaining=int(tokens),
+            reset_seconds=math.ceil((self.capacity - tokens) / self.refill_rate),
+            retry_after=0 if allowed else max(1, math.ceil((1 - tokens) / self.refill_rate)),
+            policy=self.policy,
+        )
+
+
+class SlidingWindowLimiter(RateLimiter):
+    """At most limit requests per caller in any window_seconds."""
+
+    def __init__(self, store: Optional[SlidingWindowStore] = None, limit: int = 100, window_seconds: float = 10.0):
+        """Initialize limiter.
+
+        Args:
+            store: Request log store (process memory by default)
+            limit: Requests allowed in a window
+            window_seconds: Window length
+
+        Raises:
+            ValueError: If the limit or window is not positive
+        """
+        if limit < 1 or window_seconds <= 0:
+            raise ValueError("Rate limit capacity and window must be positive")
+        self.store = store or InMemorySlidingWindowStore()
+        self.limit = limit
+        self.window_seconds = window_seconds
+
+    @property
+    def policy(self) -> str:
+        """The limit per window."""
+        return f"{self.limit};w={math.ceil(self.window_seconds)}"
+
+    def check(self, key: str) -> RateLimitDecision:
+        """Record a request of a caller if its window has room."""
+        allowed, count, wait = self.store.add(key, self.limit, self.window_seconds)
+        return RateLimitDecision(
+            allowed=allowed,
+            limit=self.limit,
+            remaining=max(0, self.limit - count),
+            reset_seconds=math.ceil(wait),
+            retry_after=0 if allowed else max(1, math.ceil(wait)),
+            policy=self.policy,
+        )
+
+
+class SpikeArrestLimiter(RateLimiter):
+    """A sustained-rate limiter with a short sliding window on top, arresting spikes."""
+
+    def __init__(
+        self,
+        sustained: RateLimiter,
+        store: Optional[SlidingWindowStore] = None,
+        limit: int = 50,
+        window_seconds: float = 1.0,
+    ):
+        """Initialize limiter.
+
+        Args:
+            sustained: Limiter of the sustained rate
+            store: Request log store of the spike window (process memory by
+                default; not shared with a sliding-window sustained limiter,
+                whose logs the shorter window would prune)
+            limit: Requests allowed in a spike window
+            window_seconds: Spike window length
+
+        Raises:
+            ValueError: If the limit or window is not positive
+        """
+        self.sustained = sustained
+        self.spike = SlidingWindowLimiter(store, limit, window_seconds)
+
+    @property
+    def policy(self) -> str:
+        """Both limits, the sustained one first."""
+        return f"{self.sustained.policy}, {self.spike.policy}"
+
+    def check(self, key: str) -> RateLimitDecision:
+        """Record a request in the spike window, then count it against the sustained rate.
+
+        A request arrested as a spike costs none of the sustained allowance.
+        The decision of an allowed request is that of the limit closer to
+        being used up, as the RateLimit headers call for.
+        """
+        spike = self.spike.check(key)
+        if not spike.allowed:
+            return spike
+        sustained = self.sustained.check(key)
+        if not sustained.allowed or sustained.remaining <= spike.remaining:
+            return sustained
+        return spike
+
+
+# Global limiter (store chosen lazily from RATE_LIMIT_REDIS_URL)
+_rate_limiter: Optional[RateLimiter] = None
+
+
+def get_rate_limiter() -> RateLimiter:
+    """Get the global rate limiter.
+
+    Raises:
+        RuntimeError: If RATE_LIMIT_REDIS_URL is set but redis is not installed
+        ValueError: If the configured algorithm is unknown, or a limit is not positive
+    """
+    global _rate_limiter
+    if _rate_limiter is None:
+        from src.config import get_config
+
+        config = get_config()
+        algorithm = config.rate_limit_algorithm.strip().lower()
+        if algorithm not in ("token_bucket", "sliding_window"):
+            raise ValueError(f"Unknown RATE_LIMIT_ALGORITHM '{config.rate_limit_algorithm}'")
+        if not config.rate_limit_redis_url:
+            logger.info("RATE_LIMIT_REDIS_URL not set; rate limits are per process")
+        if algorithm == "sliding_window":
+            window_store: SlidingWindowStore = (
+                RedisSlidingWindowStore.from_url(config.rate_limit_redis_url, config.rate_limit_key_prefix)
+                if config.rate_limit_redis_url else InMemorySlidingWindowStore()
+            )
+            _rate_limiter = SlidingWindowLimiter(
+                window_store, config.rate_limit_capacity, config.rate_limit_window_seconds
+            )
+        else:
+            store: RateLimitStore = (
+                RedisRateLimitStore.from_url(config.rate_limit_redis_url, config.rate_limit_key_prefix)
+                if config.rate_limit_redis_url else InMemoryRateLimitStore()
+            )
+            _rate_limiter = TokenBucketLimiter(store, config.rate_limit_capacity, config.rate_limit_refill_rate)
+        if config.rate_limit_spike_limit > 0:
+            spike_store: SlidingWindowStore = (
+                RedisSlidingWindowStore.from_url(config.rate_limit_redis_url, f"{config.rate_limit_key_prefix}:spike")
+                if config.rate_limit_redis_url else InMemorySlidingWindowStore()
+            )
+            _rate_limiter = SpikeArrestLimiter(
+                _rate_limiter, spike_store, config.rate_limit_spike_limit, config.rate_limit_spike_window_seconds
+            )
+    return _rate_limiter
@@ -0,0 +1,264 @@
+"""Per-endpoint scopes, defined in a policy file hot-reloaded from RBAC_POLICY_PATH.
+
+The policy file is a JSON object with a list of rules, checked in order;
+the first rule whose methods and path match a request names the scopes
+it needs (any one of them):
+
+    {
+      "rules": [
+        {"id": "lookups", "methods": ["GET", "POST"], "path": "/api/v1/lookup/**",
+         "scopes": ["lookup:read"]},
+        {"id": "geofence-reads", "methods": ["GET"], "path": "/api/v1/geofences/**",
+         "scopes": ["geofence:read"]},
+        {"id": "geofence-writes", "path": "/api/v1/geofences/**",
+         "scopes": ["geofence:write"]},
+        {"id": "public-tiles", "path": "/api/v1/tiles/**", "scopes": []}
+      ],
+      "default_scopes": ["lookup:read"]
+    }
+
+In paths `*` matches within one segment and `**` any number of them
+(including none, so `/api/v1/geofences/**` also covers
+`/api/v1/geofences`). Rules without `methods` match every method. An
+empty `scopes` list needs no scope (nor a token, unless AUTH_REQUIRED);
+requests no rule matches need one of `default_scopes`, or nothing if the
+key is absent.
+
+Scopes are granted by the token (`scope` claim; tenant API keys pass
+theirs on) and match as in src.services.auth_service.scope_granted: a
+granted `geofence:*` covers `geofence:write`.
+
+The file is re-read when its modification time changes, checked at most
+every RBAC_RELOAD_INTERVAL_SECONDS. A file that fails validation is
+rejected as a whole and the previous policy stays active; until a first
+policy loads, requests outside AUTH_EXEMPT_PATHS get 503.
+"""
+
+import hashlib
+import json
+import logging
+import os
+import re
+import threading
+import time
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Any, Callable, List, Optional, Pattern, Tuple
+
+from src.services.auth_service import validate_scopes
+
+logger = logging.getLogger(__name__)
+
+_METHODS = ("GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS")
+
+
+def compile_path(pattern: str) -> Pattern:
+    """Regular expression of a policy path pattern (`*` one segment, `**` any)."""
+    if not pattern.startswith("/"):
+        raise ValueError(f"path {pattern!r} must start with /")
+    regex = ""
+    index = 0
+    while index < len(pattern):
+        if pattern.startswith("/**", index):
+            regex += "(/.*)?"
+            index += 3
+        elif pattern.startswith("**", index):
+            regex += ".*"
+            index += 2
+        elif pattern[index] == "*":
+            regex += "[^/]*"
+            index += 1
+        else:
+            regex += re.escape(pattern[index])
+            index += 1
+    return re.compile(f"^{regex}$")
+
+
+@dataclass
+class PolicyRule:
+    """Scopes needed by the requests a method and path pattern match."""
+    rule_id: str
+    path: str
+    methods: Optional[Tuple[str, ...]]  # None: every method
+    scopes: Tuple[str, ...]             # Any one suffices; empty: none needed
+    _regex: Pattern
+
+    def matches(self, method: str, path: str) -> bool:
+        return (self.methods is None or method in self.methods) and bool(self._regex.match(path))
+
+    def to_dict(self) -> dict:
+        return {
+            "id": self.rule_id,
+            "path": self.path,
+            "methods": list(self.methods) if self.methods is not None else None,
+            "scopes": list(self.scopes),
+        }
+
+
+@dataclass
+class Policy:
+    """Validated RBAC policy."""
+    rules: List[PolicyRule]
+    default_scopes: Optional[Tuple[str, ...]] = None  # None: unmatched requests are unrestricted
+
+    def required_scopes(self, method: str, path: str) -> Tuple[Optional[PolicyRule], Optional[Tuple[str, ...]]]:
+        """First matching rule and the scopes a request needs (None: unrestricted)."""
+        for rule in self.rules:
+            if rule.matches(method, path):
+                return rule, rule.scopes
+        return None, self.default_scopes
+
+
+def _scopes(value: Any, label: str) -> Tuple[str, ...]:
+    if not isinstance(value, list):
+        raise ValueError(f"{label}: scopes must be a list of resource:action scopes")
+    try:
+        return validate_scopes(value)
+    except ValueError as e:
+        raise ValueError(f"{label}: {e}")
+
+
+def parse_policy(document: Any) -> Policy:
+    """Validate a policy document.
+
+    Args:
+        document: Decoded JSON object with a "rules" list
+
+    Returns:
+        Policy with the rules in file order
+
+    Raises:
+        ValueError: If the document or any rule is invalid (naming the rule)
+    """
+    if not isinstance(document, dict) or not isinstance(document.get("rules"), list):
+        raise ValueError('RBAC policy must be a JSON object with a "rules" list')
+    rules: List[PolicyRule] = []
+    seen = set()
+    for index, entry in enumerate(document["rules"]):
+        if not isinstance(entry, dict):
+            raise ValueError(f"Rule #{index + 1}: expected an object")
+        rule_id = entry.get("id", f"#{index + 1}")
+        label = f"Rule {rule_id!r}"
+        if not isinstance(rule_id, str) or not rule_id.strip():
+            raise ValueError(f"Rule #{index + 1}: id must be a non-empty string")
+        if rule_id in seen:
+            raise ValueError(f"{label}: duplicate id")
+        path = entry.get("path")
+        if not isinstance(path, str):
+            raise ValueError(f"{label}: path is required")
+        try:
+            regex = compile_path(path)
+        except ValueError as e:
+            raise ValueError(f"{label}: {e}")
+        methods = entry.get("methods")
+        if methods is not None:
+            if not isinstance(methods, list) or not methods or not all(
+                isinstance(m, str) and m.upper() in _METHODS for m in methods
+            ):
+                raise ValueError(f"{label}: methods must be a list of {', '.join(_METHODS)}")
+            methods = tuple(m.upper() for m in methods)
+        scopes = _scopes(entry.get("scopes"), label)
+        seen.add(rule_id)
+        rules.append(PolicyRule(rule_id, path, methods, scopes, regex))
+
+    default_scopes = None
+    if document.get("default_scopes") is not None:
+        default_scopes = _scopes(document["default_scopes"], "default_scopes")
+    return Policy(rules, default_scopes)
+
+
+class PolicyStore:
+    """Holds the active policy and re-reads the policy file when it changes."""
+
+    def __init__(
+        self,
+        path: str = "",
+        reload_interval_seconds: float = 5.0,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        """Initialize policy store.
+
+        Args:
+            path: Policy file (empty: no policy, every request unrestricted)
+            reload_interval_seconds: Least time between file change checks
+            clock: Monotonic time source
+        """
+        self.path = path
+        self.reload_interval_seconds = reload_interval_seconds
+        self.clock = clock
+        self._policy: Optional[Policy] = None
+        self._mtime: Optional[int] = None
+        self._checked_at: Optional[float] = None
+        self._lock = threading.Lock()
+        self.version: Optional[str] = None  # Content hash of the active file
+        self.loaded_at: Optional[datetime] = None
+        self.last_error: Optional[str] = None  # Why the latest change was rejected
+
+    @property
+    def policy(self) -> Optional[Policy]:
+        """Active policy (the file is checked for changes first); None if none was loaded."""
+        self._maybe_reload()
+        return self._policy
+
+    def _maybe_reload(self) -> None:
+        if not self.path:
+            return
+        now = self.clock()
+        with self._lock:
+            if self._checked_at is not None and now - self._checked_at < self.reload_interval_seconds:
+                return
+            self._checked_at = now
+        try:
+            mtime = os.stat(self.path).st_mtime_ns
+        except OSError as e:
+            if self.last_error is None:
+                logger.error(f"RBAC policy file unavailable, keeping the active policy: {e}")
+            self.last_error = f"RBAC policy file unavailable: {str(e)}"
+            return
+        if mtime != self._mtime:
+            self.reload()
+
+    def reload(self) -> bool:
+        """Read and validate the policy file now.
+
+        Returns:
+            True if the file was loaded; False if it was rejected and the
+            previous policy stays active
+        """
+        if not self.path:
+            return False
+        mtime = None
+        try:
+            mtime = os.stat(self.path).st_mtime_ns
+            with open(self.path, "rb") as f:
+                content = f.read()
+            policy = parse_policy(json.loads(content))
+        except (OSError, ValueError) as e:
+            # The rejected version is not retried until the file changes again
+            self._mtime = mtime
+            self.last_error = str(e)
+            logger.error(f"Rejected RBAC policy {self.path}, keeping the active policy: {e}")
+            return False
+        with self._lock:
+            self._policy = policy
+            self._mtime = mtime
+            self.version = hashlib.sha256(content).hexdigest()[:12]
+            self.loaded_at = datetime.now(timezone.utc)
+            self.last_error = None
+        logger.info(f"Loaded {len(policy.rules)} RBAC rules from {self.path} (version {self.version})")
+        return True
+
+
+# Global policy store (built lazily from configuration)
+_policy_store: Optional[PolicyStore] = None
+
+
+def get_policy_store() -> PolicyStore:
+    """Get the global RBAC policy store."""
+    global _policy_store
+    if _policy_store is None:
+        from src.config import get_config
+
+        config = get_config()
+        _policy_store = PolicyStore(config.rbac_policy_path, config.rbac_reload_interval_seconds)
+    return _policy_store
@@ -0,0 +1,254 @@
+"""Refresh tokens with rotation and reuse detection.
+
+A login session (started by exchanging a tenant API key) gets a
+short-lived access token (JWT) and a long-lived, opaque refresh token.
+Presenting the refresh token at POST /api/v1/auth/refresh returns a new
+pair; the presented token is spent. Only a SHA-256 hash of each refresh
+token is stored.
+
+A spent refresh token presented again means it leaked (the legitimate
+client already holds its successor), so the whole session is revoked:
+every refresh token of it, and through the denylist every access token
+carrying its session ID. Sessions started from an API key also end when
+that key is revoked or past its rotation grace period, and only refresh
+from the key's allowed networks.
+"""
+
+import hashlib
+import logging
+import secrets
+import uuid
+from dataclasses import dataclass
+from datetime import datetime, timedelta
+from typing import Iterable, Optional
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import RefreshToken, TenantApiKey
+from src.services.auth_service import AuthenticationError, TokenVerifier, get_token_verifier
+from src.services.ip_allowlist_service import check_address, stored_cidrs
+from src.services.request_signing_service import RequestSigningService
+from src.services.tenant_service import key_active
+
+logger = logging.getLogger(__name__)
+
+REFRESH_TOKEN_PREFIX = "ger_"
+
+
+def _hash(token: str) -> str:
+    return hashlib.sha256(token.encode("utf-8")).hexdigest()
+
+
+@dataclass
+class TokenPair:
+    """Access and refresh token issued together."""
+    access_token: str
+    refresh_token: str
+    expires_in: int           # Seconds the access token is valid
+    refresh_expires_in: int   # Seconds the refresh token is valid
+    tenant_id: str
+    session_id: str
+
+
+class RefreshTokenService:
+    """Starts login sessions and rotates their refresh tokens."""
+
+    def __init__(
+        self,
+        session: Session,
+        verifier: Optional[TokenVerifier] = None,
+        access_ttl: Optional[timedelta] = None,
+        refresh_ttl: Optional[timedelta] = None,
+    ):
+        """Initialize refresh token service.
+
+        Args:
+            session: SQLAlchemy database session
+            verifier: Signs access tokens (default: the global verifier)
+            access_ttl: Access token lifetime (default JWT_EXPIRATION_MINUTES)
+            refresh_ttl: Refresh token lifetime (default JWT_REFRESH_EXPIRATION_DAYS)
+        """
+        if access_ttl is None or refresh_ttl is None:
+            from src.config import get_config
+
+            config = get_config()
+            access_ttl = access_ttl or timedelta(minutes=config.jwt_expiration_minutes)
+            refresh_ttl = refresh_ttl or timedelta(days=config.jwt_refresh_expiration_days)
+        self.session = session
+        self.verifier = verifier if verifier is not None else get_token_verifier()
+        self.access_ttl = access_ttl
+        self.refresh_ttl = refresh_ttl
+
+    def start_session(
+        self,
+        subject: str,
+        tenant_id: str,
+        scopes: Iterable[str] = (),
+        api_key_id: Optional[str] = None,
+    ) -> TokenPair:
+        """Issue the first token pair of a new login session.
+
+        Args:
+            subject: sub claim of the access tokens
+            tenant_id: Tenant the tokens act for
+            scopes: Granted scopes
+            api_key_id: Tenant API key the session is started from; the
+                session ends when the key does
+
+        Raises:
+            RuntimeError: If tokens cannot be signed
+            ValueError: If storage fails
+        """
+        pair = self._issue(str(uuid.uuid4()), subject, tenant_id, " ".join(sorted(scopes)), api_key_id)
+        self._commit()
+        logger.info(f"Started session {pair.session_id} for {subject} (tenant {tenant_id})")
+        return pair
+
+    def refresh(self, refresh_token: str, client_ip: Optional[str] = None) -> TokenPair:
+        """Spend a refresh token for a new token pair of the same session.
+
+        Args:
+            refresh_token: Presented refresh token
+            client_ip: Address it is presented from, checked against the
+                networks of the session's API key before it is spent
+
+        Raises:
+            AuthenticationError: If the token is unknown, expired or revoked,
+                its API key was revoked, or it was already spent (which
+                revokes the whole session)
+            SourceNetworkError: If the API key is restricted to other networks
+            RuntimeError: If tokens cannot be signed
+            ValueError: If storage fails
+        """
+        stored = self._find(refresh_token)
+        now = datetime.utcnow()
+        if stored is None or stored.revoked_at is not None:
+            raise AuthenticationError("Invalid refresh token")
+        key = self._api_key(stored.api_key_id) if stored.api_key_id else None
+        if key is not None:
+            check_address(client_ip, stored_cidrs(key.allowed_cidrs))
+        if stored.expires_at <= now:
+            raise AuthenticationError("Refresh token has expired")
+        if stored.api_key_id and (key is None or not key_active(key)):
+            self.revoke_session(stored.session_id)
+            raise AuthenticationError("The API key of this session was revoked")
+
+        # Mark spent only if still unspent, so two concurrent refreshes cannot both win
+        claimed = (
+            self.session.query(RefreshToken)
+            .filter(RefreshToken.id == stored.id, RefreshToken.used_at.is_(None))
+            .update({RefreshToken.used_at: now}, synchronize_session=False)
+        )
+        if not claimed:
+            self.session.rollback()
+            logger.warning(f"Refresh token reuse in session {stored.session_id}; revoking the session")
+            self.revoke_session(stored.session_id)
+            raise AuthenticationError("Refresh token was already used; the session is revoked")
+
+        pair = self._issue(stored.session_id, stored.subject, stored.tenant_id, stored.scopes, stored.api_key_id)
+        self._commit()
+        return pair
+
+    def revoke(self, refresh_token: str) -> bool:
+        """Revoke the session of a refresh token (logout).
+
+        Returns:
+            True if the token was known
+        """
+        stored = self._find(refresh_token)
+        if stored is None:
+            return False
+        self.revoke_session(stored.session_id)
+        return True
+
+    def revoke_api_key(self, api_key_id: str) -> int:
+        """Revoke every session started from a tenant API key.
+
+        Returns:
+            Sessions revoked
+        """
+        session_ids = [
+            row.session_id
+            for row in self.session.query(RefreshToken.session_id)
+            .filter(RefreshToken.api_key_id == api_key_id, RefreshToken.revoked_at.is_(None))
+            .distinct()
+        ]
+        for session_id in session_ids:
+            self.revoke_session(session_id)
+        return len(session_ids)
+
+    def revoke_session(self, session_id: str) -> int:
+        """Revoke every refresh token of a session and deny its access tokens.
+
+        Returns:
+            Refresh tokens revoked
+        """
+        now = datetime.utcnow()
+        revoked = (
+            self.session.query(RefreshToken)
+            .filter(RefreshToken.session_id == session_id, RefreshToken.revoked_at.is_(None))
+            .update({RefreshToken.revoked_at: now}, synchronize_session=False)
+        )
+        self._commit()
+        if self.verifier.denylist is not None:
+            self.verifier.revoke_session(session_id, int(self.access_ttl.total_seconds()))
+        else:
+            logger.warning(f"No token denylist; access tokens of session {session_id} stay valid until they expire")
+        logger.info(f"Revoked session {session_id} ({revoked} refresh tokens)")
+        return revoked
+
+    def _find(self, refresh_token: str) -> Optional[RefreshToken]:
+        if not refresh_token.startswith(REFRESH_TOKEN_PREFIX):
+            return None
+        return (
+            self.session.query(RefreshToken)
+            .filter(RefreshToken.token_hash == _hash(refresh_token))
+            .first()
+        )
+
+    def _api_key(self, key_id: str) -> Optional[TenantApiKey]:
+        return self.session.query(TenantApiKey).filter(TenantApiKey.key_id == key_id).first()
+
+    def _issue(
+        self, session_id: str, subject: str, tenant_id: str, scopes: str, api_key_id: Optional[str]
+    ) -> TokenPair:
+        # Tenants with signing keys get tokens that only work on signed requests
+        signed_requests = bool(RequestSigningService(self.session).active_keys(tenant_id))
+        key = self._api_key(api_key_id) if api_key_id else None
+        access_token = self.verifier.issue(
+            subject,
+            tenant_id,
+            scopes.split(),
+            expires_in=self.access_ttl,
+            session_id=session_id,
+            signed_requests=signed_requests,
+            allowed_cidrs=stored_cidrs(key.allowed_cidrs) if key is not None else (),
+            privacy_precision=key.privacy_precision if key is not None else None,
+            quota_plan=key.quota_plan if key is not None else None,
+        )
+        refresh_token = REFRESH_TOKEN_PREFIX + secrets.token_urlsafe(32)
+        self.session.add(RefreshToken(
+            token_id=str(uuid.uuid4()),
+            session_id=session_id,
+            tenant_id=tenant_id,
+            subject=subject,
+            scopes=scopes,
+            api_key_id=api_key_id,
+            token_hash=_hash(refresh_token),
+            expires_at=datetime.utcnow() + self.refresh_ttl,
+        ))
+        return TokenPair(
+            access_token=access_token,
+            refresh_token=refresh_token,
+            expires_in=int(self.access_ttl.total_seconds()),
+            refresh_expires_in=int(self.refresh_ttl.total_seconds()),
+            tenant_id=tenant_id,
+            session_id=session_id,
+        )
+
+    def _commit(self) -> None:
+        try:
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store refresh token: {str(e)}")
@@ -0,0 +1,214 @@
+"""Named country groupings (continents, trade blocs, policy lists).
+
+Continent groups are built from the GeoNames country info table (see
+src.services.country_info_service) and named after the continent
+("europe", "north-america", ...). Custom groups come from a JSON object
+at REGION_GROUPS_PATH mapping a group name to its members, which are
+ISO 3166-1 alpha-2 codes or the names of other groups:
+
+    {
+      "EU": ["AT", "BE", "BG", "..."],
+      "LATAM": ["south-america", "MX", "GT", "..."],
+      "embargoed": ["CU", "IR", "KP", "SY"]
+    }
+
+A member that names a group is expanded to that group's countries; a
+custom group may replace a continent group of the same name. Policy code
+can key off group names, which stay stable when membership changes.
+"""
+
+import json
+import logging
+from typing import Dict, Iterable, List, Optional, Set
+
+from src.services.country_info_service import CountryInfo, get_country_info
+from src.services.enrichment_service import Enricher
+from src.services.ip_lookup_service import IpLookupResult
+
+logger = logging.getLogger(__name__)
+
+# GeoNames continent code -> group name
+CONTINENT_GROUPS = {
+    "AF": "africa",
+    "AN": "antarctica",
+    "AS": "asia",
+    "EU": "europe",
+    "NA": "north-america",
+    "OC": "oceania",
+    "SA": "south-america",
+}
+
+
+def continent_groups(countries: Dict[str, CountryInfo]) -> Dict[str, List[str]]:
+    """Continent group members from the country info table."""
+    groups: Dict[str, List[str]] = {}
+    for code, info in countries.items():
+        name = CONTINENT_GROUPS.get((info.continent or "").upper())
+        if name is not None:
+            groups.setdefault(name, []).append(code)
+    return groups
+
+
+def load_region_groups(path: str) -> Dict[str, List[str]]:
+    """Load custom group definitions from a JSON file.
+
+    Args:
+        path: JSON object of group name -> list of members
+
+    Returns:
+        dict of group name -> members
+
+    Raises:
+        OSError: If the file cannot be read
+        ValueError: If the file is not an object of string lists
+    """
+    with open(path, encoding="utf-8") as f:
+        try:
+            document = json.load(f)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Invalid region groups in {path}: {str(e)}")
+    if not isinstance(document, dict):
+        raise ValueError(f"{path}: expected a JSON object of group name -> members")
+    for name, members in document.items():
+        if not isinstance(members, list) or not all(isinstance(m, str) for m in members):
+            raise ValueError(f"{path}: members of group {name!r} must be a list of strings")
+    return document
+
+
+class RegionGroups:
+    """Resolved country membership of named groups."""
+
+    def __init__(self, groups: Optional[Dict[str, Iterable[str]]] = None):
+        """Initialize region groups.
+
+        Args:
+            groups: Group name -> members (country codes or other group names)
+
+        Raises:
+            ValueError: If a member is neither a group nor a country code,
+                or groups reference each other in a cycle
+        """
+        definitions = {name: list(members) for name, members in (groups or {}).items()}
+        self._members: Dict[str, Set[str]] = {}
+        for name in definitions:
+            self._resolve(name, definitions, [])
+
+        self._by_country: Dict[str, List[str]] = {}
+        for name in sorted(self._members):
+            for country in self._members[name]:
+                self._by_country.setdefault(country, []).append(name)
+
+    def _resolve(self, name: str, definitions: Dict[str, List[str]], path: List[str]) -> Set[str]:
+        if name in self._members:
+            return self._members[name]
+        if name in path:
+            raise ValueError(f"Region group cycle: {' -> '.join(path + [name])}")
+        countries: Set[str] = set()
+        for member in definitions[name]:
+            member = member.strip()
+            if member in definitions:
+                countries |= self._resolve(member, definitions, path + [name])
+            elif len(member) == 2 and member.isalpha():
+                countries.add(member.upper())
+            else:
+                raise ValueError(
+                    f"Region group {name!r}: {member!r} is neither a group nor an ISO 3166-1 alpha-2 code"
+                )
+        self._members[name] = countries
+        return countries
+
+    def __len__(self) -> int:
+        return len(self._members)
+
+    @property
+    def names(self) -> List[str]:
+        """Group names, sorted."""
+        return sorted(self._members)
+
+    def members(self, name: str) -> Optional[List[str]]:
+        """Sorted country codes of a group, or None if the group is not defined."""
+        countries = self._members.get(name)
+        return sorted(countries) if countries is not None else None
+
+    def groups_for(self, country_code: Optional[str]) -> List[str]:
+        """Sorted names of the groups containing a country."""
+        if not country_code:
+            return []
+        return list(self._by_country.get(country_code.strip().upper(), []))
+
+
+class RegionGroupEnricher(Enricher):
+    """Adds the names of the groups containing the location's country."""
+
+    name = "groups"
+
+    def __init__(self, groups: RegionGroups):
+        """Initialize region group enricher.
+
+        Args:
+            groups: Resolved group membership
+        """
+        self.groups = groups
+
+    def enrich(self, result: IpLookupResult) -> Optional[List[str]]:
+        """Groups of the record's country (an empty list if it is in none)."""
+        record = result.record
+        if record is None or not record.country_iso_code:
+            return None
+        return self.groups.groups_for(record.country_iso_code)
+
+
+def build_region_groups(config) -> RegionGroups:
+    """Combine continent groups with the custom groups from REGION_GROUPS_PATH.
+
+    Args:
+        config: Application configuration
+
+    Raises:
+        OSError: If the custom groups file cannot be read
+        ValueError: If the custom groups are malformed
+    """
+    groups: Dict[str, List[str]] = dict(continent_groups(get_country_info(config)))
+    if config.region_groups_path:
+        groups.update(load_region_groups(config.region_groups_path))
+    return RegionGroups(groups)
+
+
+def build_region_group_enricher(config) -> Optional[RegionGroupEnricher]:
+    """Create the region group enricher.
+
+    Args:
+        config: Application configuration
+
+    Returns:
+        RegionGroupEnricher, or None if no groups are defined
+    """
+    groups = get_region_groups(config)
+    if not groups:
+        return None
+    return RegionGroupEnricher(groups)
+
+
+# Global region groups (built lazily from the country info table and REGION_GROUPS_PATH)
+_region_groups: Optional[RegionGroups] = None
+
+
+def get_region_groups(config=None) -> RegionGroups:
+    """Get the global region groups.
+
+    Returns:
+        RegionGroups (continent groups only if the custom groups cannot be loaded)
+    """
+    global _region_groups
+    if _region_groups is None:
+        if config is None:
+            from src.config import get_config
+
+            config = get_config()
+        try:
+            _region_groups = build_region_groups(config)
+            logger.info(f"Loaded {len(_region_groups)} region groups")
+        except (OSError, ValueError) as e:
+            logger.warning(f"Custom region groups unavailable: {e}")
+            _region_groups = RegionGroups(continent_groups(get_country_info(config)))
+    return _region_groups
@@ -0,0 +1,389 @@
+"""HMAC request signing for tenants that may not rely on bearer tokens alone.
+
+Behind TLS-terminating proxies a bearer token travels in clear between
+the proxy and the service, so anyone able to read it there could replay
+it. Tenants whose compliance rules forbid that get a signing key through
+the admin API; from then on the access tokens issued to them (at
+/api/v1/auth/token and /auth/refresh) carry a `req_sig` claim, and every
+request made with such a token must also carry
+
+    X-Request-Signature: keyId=<key_id>,t=<unix seconds>,n=<nonce>,v1=<hex>
+
+where v1 is the HMAC-SHA256, under the key's secret, of
+
+    v1\\n<t>\\n<nonce>\\n<METHOD>\\n<path>\\n<query string>\\n<hex SHA-256 of the body>
+
+(see canonical_request and sign_request). Unsigned requests, signatures
+older or newer than REQUEST_SIGNING_TOLERANCE_SECONDS and reused nonces
+get 401. A token intercepted on its way is then useless without the
+secret, which never leaves the tenant.
+
+Nonces are remembered for twice the tolerance, covering every timestamp
+that would still be accepted. With REQUEST_SIGNING_REDIS_URL they are
+shared by all workers (`pip install -e ".[redis]"`); otherwise a replay
+is only caught by the worker that saw the original request.
+
+Tokens issued before a tenant's first signing key keep working unsigned
+until they expire (JWT_EXPIRATION_MINUTES), and revoking a tenant's last
+key makes signing optional for the tokens issued after that. Rotating a
+signing key issues a new one and lets the previous keys keep working for
+a grace period. Signatures sent by other callers are checked as well.
+"""
+
+import hashlib
+import hmac
+import logging
+import re
+import secrets
+import threading
+import time
+import uuid
+from dataclasses import dataclass
+from datetime import datetime, timedelta
+from typing import Callable, Dict, List, Optional
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import Tenant, TenantSigningKey
+from src.services.auth_service import AuthenticationError
+
+logger = logging.getLogger(__name__)
+
+SIGNATURE_HEADER = "X-Request-Signature"
+# ASGI scope extension holding the BodyDigest of a signed request
+BODY_DIGEST_EXTENSION = "body_digest"
+_NONCE = re.compile(r"^[A-Za-z0-9_-]{16,128}$")
+
+
+def body_sha256(body: bytes) -> str:
+    """Hex SHA-256 of a request body, as signed."""
+    return hashlib.sha256(body).hexdigest()
+
+
+class BodyDigest:
+    """SHA-256 of a request body, computed as the application receives it."""
+
+    def __init__(self):
+        self._hash = hashlib.sha256()
+        self.complete = False  # The whole body has been received
+
+    def update(self, chunk: bytes, more_body: bool) -> None:
+        self._hash.update(chunk)
+        self.complete = not more_body
+
+    def hexdigest(self) -> str:
+        return self._hash.hexdigest()
+
+
+def canonical_request(
+    timestamp: int, nonce: str, method: str, path: str, query: str, body_digest: str
+) -> bytes:
+    """String a request signature covers."""
+    return "\n".join(["v1", str(timestamp), nonce, method.upper(), path, query, body_digest]).encode("utf-8")
+
+
+def sign_request(
+    key_id: str,
+    secret: str,
+    method: str,
+    path: str,
+    query: str = "",
+    body: bytes = b"",
+    timestamp: Optional[int] = None,
+    nonce: Optional[str] = None,
+) -> str:
+    """X-Request-Signature value of a request (for clients and tests).
+
+    Args:
+        key_id: Signing key ID
+        secret: Signing key secret
+        method: HTTP method
+        path: Request path, as sent
+        query: Query string without the "?", as sent
+        body: Raw request body
+        timestamp: Unix seconds (default: now)
+        nonce: Unique value (default: random)
+    """
+    timestamp = int(time.time()) if timestamp is None else timestamp
+    nonce = nonce or secrets.token_urlsafe(18)
+    message = canonical_request(timestamp, nonce, method, path, query, body_sha256(body))
+    digest = hmac.new(secret.encode("utf-8"), message, hashlib.sha256).hexdigest()
+    return f"keyId={key_id},t={timestamp},n={nonce},v1={digest}"
+
+
+@dataclass
+class RequestSignature:
+    """Parsed X-Request-Signature header."""
+    key_id: str
+    timestamp: int
+    nonce: str
+    digest: str
+
+
+def parse_signature(header: str) -> RequestSignature:
+    """Parse an X-Request-Signature header.
+
+    Raises:
+        AuthenticationError: If a field is missing or malformed
+    """
+    fields: Dict[str, str] = {}
+    for part in header.split(","):
+        name, _, value = part.strip().partition("=")
+        fields[name.strip()] = value.strip()
+    try:
+        signature = RequestSignature(fields["keyId"], int(fields["t"]), fields["n"], fields["v1"].lower())
+    except (KeyError, ValueError):
+        raise AuthenticationError(f"Malformed {SIGNATURE_HEADER}: expected keyId=,t=,n=,v1=")
+    if not _NONCE.match(signature.nonce):
+        raise AuthenticationError("Signature nonce must be 16-128 characters of A-Z, a-z, 0-9, - and _")
+    return signature
+
+
+def signing_key_active(key: TenantSigningKey, now: Optional[datetime] = None) -> bool:
+    """Whether a signing key is accepted: neither revoked nor past its grace period."""
+    now = now or datetime.utcnow()
+    return key.revoked_at is None and (key.expires_at is None or key.expires_at > now)
+
+
+class NonceStore:
+    """Nonces of accepted signatures, remembered until replays would be too old anyway."""
+
+    def claim(self, nonce: str, ttl_seconds: int) -> bool:
+        """Remember a nonce.
+
+        Returns:
+            False if it was already used
+        """
+        raise NotImplementedError
+
+
+class InMemoryNonceStore(NonceStore):
+    """Process-local nonces (replays across workers are not caught)."""
+
+    def __init__(self, clock=time.monotonic):
+        self._entries: Dict[str, float] = {}
+        self._lock = threading.Lock()
+        self._clock = clock
+
+    def claim(self, nonce: str, ttl_seconds: int) -> bool:
+        now = self._clock()
+        with self._lock:
+            for stale in [n for n, expires in self._entries.items() if expires <= now]:
+                del self._entries[stale]
+            if nonce in self._entries:
+                return False
+            self._entries[nonce] = now + max(1, ttl_seconds)
+            return True
+
+
+class RedisNonceStore(NonceStore):
+    """Redis keys with expiry, one per nonce."""
+
+    def __init__(self, client, key_prefix: str = "request-nonce"):
+        """Initialize Redis nonce store.
+
+        Args:
+            client: redis.Redis client
+            key_prefix: Prefix of the Redis keys
+        """
+        self.client = client
+        self.key_prefix = key_prefix
+
+    @classmethod
+    def from_url(cls, url: str, key_prefix: str = "request-nonce") -> "RedisNonceStore":
+        """Connect to Redis.
+
+        Raises:
+            RuntimeError: If the redis package is not installed
+        """
+        try:
+            import redis
+        except ImportError:
+            raise RuntimeError("redis is required for REQUEST_SIGNING_REDIS_URL")
+        return cls(redis.Redis.from_url(url), key_prefix)
+
+    def claim(self, nonce: str, ttl_seconds: int) -> bool:
+        return bool(self.client.set(f"{self.key_prefix}:{nonce}", "1", nx=True, ex=max(1, ttl_seconds)))
+
+
+class RequestSigningService:
+    """Issues tenants' signing keys and verifies signed requests."""
+
+    def __init__(
+        self,
+        session: Session,
+        nonces: Optional[NonceStore] = None,
+        tolerance_seconds: int = 300,
+        clock: Callable[[], float] = time.time,
+    ):
+        """Initialize request signing service.
+
+        Args:
+            session: SQLAlchemy database session
+            nonces: Nonces of accepted signatures (default: a new in-memory store)
+            tolerance_seconds: Largest accepted difference between the
+                signature's timestamp and the server clock
+            clock: Wall clock (Unix seconds)
+        """
+        self.session = session
+        self.nonces = nonces or InMemoryNonceStore()
+        self.tolerance_seconds = tolerance_seconds
+        self.clock = clock
+
+    def list_keys(self, tenant_id: str) -> List[TenantSigningKey]:
+        """Signing keys of a tenant (revoked and expired ones included), oldest first."""
+        return (
+            self.session.query(TenantSigningKey)
+            .filter(TenantSigningKey.tenant_id == tenant_id)
+            .order_by(TenantSigningKey.id)
+            .all()
+        )
+
+    def active_keys(self, tenant_id: str) -> List[TenantSigningKey]:
+        """Signing keys currently accepted; tokens issued while there are any require signing."""
+        now = datetime.utcnow()
+        return [key for key in self.list_keys(tenant_id) if signing_key_active(key, now)]
+
+    def create_key(self, tenant_id: str, grace_seconds: int = 0) -> TenantSigningKey:
+        """Issue a signing key, making signing mandatory for the tenant's new tokens.
+
+        Args:
+            tenant_id: Tenant identifier
+            grace_seconds: How long the tenant's previous keys keep working
+                (0 revokes them at once)
+
+        Returns:
+            The new key, secret included
+
+        Raises:
+            LookupError: If the tenant does not exist
+            ValueError: If the grace period is negative or storage fails
+        """
+        if grace_seconds < 0:
+            raise ValueError("Grace period must not be negative")
+        if self.session.query(Tenant).filter(Tenant.tenant_id == tenant_id).first() is None:
+            raise LookupError(f"Tenant {tenant_id} not found")
+
+        now = datetime.utcnow()
+        retire_at = now + timedelta(seconds=grace_seconds)
+        for key in self.active_keys(tenant_id):
+            if grace_seconds == 0:
+                key.revoked_at = now
+            elif key.expires_at is None or key.expires_at > retire_at:
+                key.expires_at = retire_at
+        key = TenantSigningKey(
+            key_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            secret=secrets.token_hex(32),
+        )
+        try:
+            self.session.add(key)
+            self.session.commit()
+            self.session.refresh(key)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store signing key: {str(e)}")
+        logger.info(f"Issued signing key {key.key_id} for tenant {tenant_id}")
+        return key
+
+    def revoke_key(self, tenant_id: str, key_id: str) -> TenantSigningKey:
+        """Stop accepting a signing key at once (revoking twice is a no-op).
+
+        Raises:
+            LookupError: If the tenant has no such key
+            ValueError: If storage fails
+        """
+        key = (
+            self.session.query(TenantSigningKey)
+            .filter(TenantSigningKey.tenant_id == tenant_id, TenantSigningKey.key_id == key_id)
+            .first()
+        )
+        if key is None:
+            raise LookupError(f"Signing key {key_id} of tenant {tenant_id} not found")
+        if key.revoked_at is None:
+            key.revoked_at = datetime.utcnow()
+            try:
+                self.session.commit()
+                self.session.refresh(key)
+            except Exception as e:
+                self.session.rollback()
+                raise ValueError(f"Failed to revoke signing key: {str(e)}")
+            logger.info(f"Revoked signing key {key_id} of tenant {tenant_id}")
+        return key
+
+    def verify(
+        self,
+        tenant_id: str,
+        header: Optional[str],
+        method: str,
+        path: str,
+        query: str,
+        body_digest: Optional[str],
+        required: bool = False,
+    ) -> Optional[TenantSigningKey]:
+        """Check the signature of a request made for a tenant.
+
+        Args:
+            tenant_id: Tenant the request's token names
+            header: X-Request-Signature value, if sent
+            method: HTTP method
+            path: Request path
+            query: Query string without the "?"
+            body_digest: Hex SHA-256 of the body (only needed if a header is sent)
+            required: The token requires signed requests (req_sig claim)
+
+        Returns:
+            Key the request was signed with; None for an unsigned request
+            that needs no signature
+
+        Raises:
+            AuthenticationError: If a signature is required but missing, or
+                invalid, expired or replayed
+            RuntimeError: If the nonce store cannot be reached
+        """
+        if header is None:
+            if required:
+                raise AuthenticationError(f"Requests of tenant {tenant_id} must be signed ({SIGNATURE_HEADER})")
+            return None
+
+        signature = parse_signature(header)
+        key = next((k for k in self.active_keys(tenant_id) if k.key_id == signature.key_id), None)
+        if key is None:
+            raise AuthenticationError(f"Unknown or inactive signing key {signature.key_id!r}")
+        if abs(self.clock() - signature.timestamp) > self.tolerance_seconds:
+            raise AuthenticationError("Request signature expired (check the client clock)")
+        message = canonical_request(signature.timestamp, signature.nonce, method, path, query, body_digest or "")
+        expected = hmac.new(key.secret.encode("utf-8"), message, hashlib.sha256).hexdigest()
+        if not hmac.compare_digest(expected, signature.digest):
+            raise AuthenticationError("Invalid request signature")
+        try:
+            fresh = self.nonces.claim(f"{key.key_id}:{signature.nonce}", 2 * self.tolerance_seconds)
+        except Exception as e:
+            # Without the nonces replays cannot be told apart
+            raise RuntimeError(f"Signature nonce store unavailable: {str(e)}")
+        if not fresh:
+            logger.warning(f"Replayed request signature for tenant {tenant_id} (key {key.key_id})")
+            raise AuthenticationError("Request signature replayed")
+        return key
+
+
+# Global nonce store (chosen lazily from REQUEST_SIGNING_REDIS_URL)
+_nonce_store: Optional[NonceStore] = None
+
+
+def get_nonce_store() -> NonceStore:
+    """Get the global store of signature nonces.
+
+    Raises:
+        RuntimeError: If REQUEST_SIGNING_REDIS_URL is set but redis is not installed
+    """
+    global _nonce_store
+    if _nonce_store is None:
+        from src.config import get_config
+
+        config = get_config()
+        if config.request_signing_redis_url:
+            _nonce_store = RedisNonceStore.from_url(config.request_signing_redis_url)
+        else:
+            _nonce_store = InMemoryNonceStore()
+    return _nonce_store
@@ -0,0 +1,175 @@
+"""Residential proxy detection from session ASN churn.
+
+Residential proxy networks route each request through a different home
+connection, so one client session appears from many consumer ISPs in
+quick succession. A real user changes network rarely (home Wi-Fi to
+cellular and back). The tracker records which autonomous systems and
+addresses each session is seen from, in the velocity store, and flags
+the session once it has used more than RESIDENTIAL_PROXY_MAX_SESSION_ASNS
+access-network ASNs within RESIDENTIAL_PROXY_WINDOW. Hosting ASNs are not
+counted: rotating through datacenter exits is VPN behavior and is covered
+by the anonymizer and datacenter checks.
+
+Known residential proxy endpoints (ANONYMIZER_RESIDENTIAL_PROXY_RANGES_PATH
+and GeoIP2-Anonymous-IP) are flagged by the anonymizer block directly.
+"""
+
+import logging
+import time
+from dataclasses import dataclass
+from typing import Any, Dict, Optional
+
+from src.services.asn_service import ConnectionType
+from src.services.velocity_service import VelocityStore, get_velocity_tracker, parse_windows
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass
+class SessionChurn:
+    """Networks a session has been seen from within the window."""
+    session_id: str
+    window: str
+    distinct_asns: int  # Access-network ASNs
+    distinct_ips: int
+    max_asns: int
+    asn_churn: bool  # More ASNs than allowed
+    listed: Optional[bool] = None  # Address is a known residential proxy endpoint
+
+    @property
+    def detected(self) -> bool:
+        """Either a listed endpoint or a churning session."""
+        return bool(self.listed) or self.asn_churn
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "session_id": self.session_id,
+            "detected": self.detected,
+            "listed": self.listed,
+            "asn_churn": self.asn_churn,
+            "distinct_asns": self.distinct_asns,
+            "distinct_ips": self.distinct_ips,
+            "max_asns": self.max_asns,
+            "window": self.window,
+        }
+
+
+class SessionAsnTracker:
+    """Counts the distinct ASNs and addresses of client sessions."""
+
+    def __init__(
+        self,
+        store: VelocityStore,
+        window_seconds: int = 3600,
+        window_label: str = "1h",
+        max_asns: int = 2,
+        key_prefix: str = "velocity:session",
+    ):
+        """Initialize session tracker.
+
+        Args:
+            store: Velocity store holding the per-session sets
+            window_seconds: Window the ASNs are counted over
+            window_label: Label of the window reported in responses
+            max_asns: Most access-network ASNs a session may use in the window
+            key_prefix: Prefix of the storage keys
+        """
+        self.store = store
+        self.window_seconds = window_seconds
+        self.window_label = window_label
+        self.max_asns = max_asns
+        self.key_prefix = key_prefix
+
+    def _key(self, tenant_id: Optional[str], dimension: str, session_id: str) -> str:
+        return f"{self.key_prefix}:{tenant_id or '-'}:{dimension}:{session_id}"
+
+    def observe(
+        self,
+        session_id: str,
+        ip_address: str,
+        asn: Optional[int],
+        connection_type: Optional[str] = None,
+        listed: Optional[bool] = None,
+        timestamp: Optional[float] = None,
+        tenant_id: Optional[str] = None,
+    ) -> SessionChurn:
+        """Record a request of a session and return its churn.
+
+        Args:
+            session_id: Client session identifier
+            ip_address: Address the request came from
+            asn: Autonomous system number of the address (None if unknown)
+            connection_type: ASN connection type; hosting ASNs are not counted
+            listed: Anonymizer residential_proxy flag of the address
+            timestamp: Unix time of the request (default: now)
+            tenant_id: Calling tenant; session IDs are scoped to it
+
+        Returns:
+            SessionChurn as of the request
+
+        Raises:
+            ValueError: If the session ID is empty
+            RuntimeError: If the store cannot be reached
+        """
+        session_id = (session_id or "").strip()
+        if not session_id:
+            raise ValueError("session_id must not be empty")
+        now = time.time() if timestamp is None else timestamp
+        since = now - self.window_seconds
+        asn_key = self._key(tenant_id, "asn", session_id)
+        ip_key = self._key(tenant_id, "ip", session_id)
+        try:
+            self.store.record(ip_key, ip_address, now, self.window_seconds)
+            if asn is not None and connection_type != ConnectionType.HOSTING:
+                self.store.record(asn_key, str(asn), now, self.window_seconds)
+            distinct_asns = self.store.count_since(asn_key, since)
+            distinct_ips = self.store.count_since(ip_key, since)
+        except Exception as e:
+            raise RuntimeError(f"Velocity store unavailable: {str(e)}")
+
+        churn = SessionChurn(
+            session_id=session_id,
+            window=self.window_label,
+            distinct_asns=distinct_asns,
+            distinct_ips=distinct_ips,
+            max_asns=self.max_asns,
+            asn_churn=distinct_asns > self.max_asns,
+            listed=listed,
+        )
+        if churn.asn_churn:
+            logger.warning(
+                f"Session {session_id} used {distinct_asns} ASNs within {self.window_label}; "
+                f"likely a residential proxy"
+            )
+        return churn
+
+
+# Global session tracker (shares the velocity store)
+_session_asn_tracker: Optional[SessionAsnTracker] = None
+
+
+def get_session_asn_tracker() -> SessionAsnTracker:
+    """Get the global session ASN tracker.
+
+    Raises:
+        RuntimeError: If VELOCITY_REDIS_URL is set but redis is not installed
+    """
+    global _session_asn_tracker
+    if _session_asn_tracker is None:
+        from src.config import get_config
+
+        config = get_config()
+        try:
+            ((label, seconds),) = parse_windows(config.residential_proxy_window)
+        except ValueError as e:
+            logger.error(f"Invalid RESIDENTIAL_PROXY_WINDOW, using 1h: {e}")
+            label, seconds = "1h", 3600
+        _session_asn_tracker = SessionAsnTracker(
+            get_velocity_tracker().store,
+            window_seconds=seconds,
+            window_label=label,
+            max_asns=config.residential_proxy_max_session_asns,
+            key_prefix=f"{config.velocity_key_prefix}:session",
+        )
+    return _session_asn_tracker
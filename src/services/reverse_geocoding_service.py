@@ -0,0 +1,222 @@
+"""Reverse geocoding against locally loaded administrative boundaries.
+
+Boundaries are GeoJSON FeatureCollections, one file per level, in the
+boundary data directory:
+
+    country.geojson  admin1.geojson  admin2.geojson  city.geojson  postal.geojson
+
+Each feature needs a Polygon or MultiPolygon geometry and `code` / `name`
+properties (`iso_code`, `id`, `postcode` and `NAME` are accepted as
+fallbacks). Missing level files are skipped, so a deployment can ship only
+the levels it needs.
+"""
+
+import logging
+import os
+from dataclasses import dataclass
+from typing import Any, Dict, List, Optional
+
+from src.spatial.geojson import read_polygon_features
+from src.spatial.geometry import Geometry
+from src.spatial.rtree import RTree
+
+logger = logging.getLogger(__name__)
+
+
+class AdminLevel:
+    """Boundary levels resolved by reverse geocoding, largest first."""
+    COUNTRY = "country"
+    ADMIN1 = "admin1"
+    ADMIN2 = "admin2"
+    CITY = "city"
+    POSTAL = "postal"
+
+    ALL = (COUNTRY, ADMIN1, ADMIN2, CITY, POSTAL)
+
+
+_CODE_PROPERTIES = ("code", "iso_code", "postcode", "postal_code", "id")
+_NAME_PROPERTIES = ("name", "NAME", "name_en")
+
+
+@dataclass
+class Boundary:
+    """A named boundary polygon at one admin level."""
+    level: str
+    code: Optional[str]
+    name: Optional[str]
+    geometry: Geometry
+    properties: Dict[str, Any]
+
+    def to_dict(self) -> Dict[str, Optional[str]]:
+        """Code/name representation used in API responses."""
+        return {"code": self.code, "name": self.name}
+
+
+@dataclass
+class ReverseGeocodeResult:
+    """Admin areas containing a coordinate (None where no boundary matched)."""
+    latitude: float
+    longitude: float
+    country: Optional[Boundary] = None
+    admin1: Optional[Boundary] = None
+    admin2: Optional[Boundary] = None
+    city: Optional[Boundary] = None
+    postal: Optional[Boundary] = None
+
+    @property
+    def matched(self) -> bool:
+        """True if any level matched."""
+        return any(getattr(self, level) is not None for level in AdminLevel.ALL)
+
+
+def _first_property(properties: Dict[str, Any], names) -> Optional[str]:
+    for name in names:
+        value = properties.get(name)
+        if value not in (None, ""):
+            return str(value)
+    return None
+
+
+def load_boundaries(path: str, level: str) -> List[Boundary]:
+    """Load boundaries for one level from a GeoJSON FeatureCollection.
+
+    Features with unsupported or malformed geometry are logged and skipped.
+
+    Args:
+        path: GeoJSON file path
+        level: AdminLevel value assigned to the features
+
+    Returns:
+        List of Boundary
+
+    Raises:
+        OSError: If the file cannot be read
+        ValueError: If the file is not a FeatureCollection
+    """
+    return [
+        Boundary(
+            level=level,
+            code=_first_property(properties, _CODE_PROPERTIES),
+            name=_first_property(properties, _NAME_PROPERTIES),
+            geometry=geometry,
+            properties=properties,
+        )
+        for properties, geometry in read_polygon_features(path)
+    ]
+
+
+class ReverseGeocodingService:
+    """Maps coordinates to country, admin1, admin2, city and postal code."""
+
+    def __init__(self, boundaries: Optional[Dict[str, List[Boundary]]] = None, version: str = "0"):
+        """Initialize reverse geocoder.
+
+        Args:
+            boundaries: Boundaries keyed by AdminLevel value
+            version: Version of the boundary data (for caches of its answers)
+        """
+        self.version = version
+        self._indexes: Dict[str, RTree] = {}
+        for level, items in (boundaries or {}).items():
+            if level not in AdminLevel.ALL:
+                raise ValueError(f"Unknown admin level: {level}")
+            self._indexes[level] = RTree([(b.geometry.bbox, b) for b in items])
+
+    @classmethod
+    def from_directory(cls, directory: str) -> "ReverseGeocodingService":
+        """Load all level files present in a boundary data directory.
+
+        Args:
+            directory: Directory containing <level>.geojson files
+
+        Returns:
+            ReverseGeocodingService (levels with unreadable files are skipped)
+        """
+        boundaries = {}
+        modified = 0
+        for level in AdminLevel.ALL:
+            path = os.path.join(directory, f"{level}.geojson")
+            if not os.path.exists(path):
+                continue
+            try:
+                boundaries[level] = load_boundaries(path, level)
+                modified = max(modified, int(os.path.getmtime(path)))
+                logger.info(f"Loaded {len(boundaries[level])} {level} boundaries from {path}")
+            except (OSError, ValueError) as e:
+                logger.warning(f"{level} boundaries unavailable: {e}")
+        # Replacing a file changes the version, as a new GeoIP build does
+        return cls(boundaries, version=f"{','.join(boundaries)}@{modified}")
+
+    @property
+    def levels(self) -> List[str]:
+        """Levels with loaded boundaries."""
+        return [level for level in AdminLevel.ALL if level in self._indexes]
+
+    def boundary_at(self, level: str, latitude: float, longitude: float) -> Optional[Boundary]:
+        """Find the boundary at one level containing a coordinate.
+
+        When boundaries overlap (disputed areas, nested city limits) the
+        smallest one wins.
+
+        Args:
+            level: AdminLevel value
+            latitude: Latitude in degrees
+            longitude: Longitude in degrees
+
+        Returns:
+            Matching Boundary, or None
+        """
+        index = self._indexes.get(level)
+        if index is None:
+            return None
+        matches = [
+            boundary
+            for boundary in index.query_point(longitude, latitude)
+            if boundary.geometry.contains(longitude, latitude)
+        ]
+        if not matches:
+            return None
+        return min(matches, key=lambda boundary: boundary.geometry.area)
+
+    def reverse(self, latitude: float, longitude: float) -> ReverseGeocodeResult:
+        """Resolve the admin areas containing a coordinate.
+
+        Args:
+            latitude: Latitude in degrees (-90 to 90)
+            longitude: Longitude in degrees (-180 to 180)
+
+        Returns:
+            ReverseGeocodeResult
+
+        Raises:
+            ValueError: If the coordinate is out of range
+        """
+        if not -90 <= latitude <= 90:
+            raise ValueError(f"Latitude out of range: {latitude}")
+        if not -180 <= longitude <= 180:
+            raise ValueError(f"Longitude out of range: {longitude}")
+
+        result = ReverseGeocodeResult(latitude=latitude, longitude=longitude)
+        for level in AdminLevel.ALL:
+            setattr(result, level, self.boundary_at(level, latitude, longitude))
+        return result
+
+
+# Global reverse geocoder (loaded lazily from BOUNDARY_DATA_DIR)
+_reverse_geocoding_service: Optional[ReverseGeocodingService] = None
+
+
+def get_reverse_geocoding_service() -> ReverseGeocodingService:
+    """Get the global reverse geocoding service.
+
+    Returns:
+        ReverseGeocodingService (without levels if no boundary files exist)
+    """
+    global _reverse_geocoding_service
+    if _reverse_geocoding_service is None:
+        from src.config import get_config
+
+        _reverse_geocoding_service = ReverseGeocodingService.from_directory(
+            get_config().boundary_data_dir
+        )
+    return _reverse_geocoding_service
@@ -0,0 +1,212 @@
+"""Per-tenant high-risk countries, regions and polygons.
+
+Tenants list the ISO 3166-1 countries, ISO 3166-2 regions and custom
+GeoJSON polygons they treat as high risk. Once a tenant has any entry,
+the risk scorer's high_risk_country signal uses the tenant's list in
+place of RISK_HIGH_RISK_COUNTRIES for the tenant's lookups. Compiled lists
+are cached per tenant and rebuilt when the tenant's entries change.
+"""
+
+import logging
+import threading
+import uuid
+from collections import OrderedDict
+from typing import Any, Dict, List, Optional, Tuple
+
+from sqlalchemy import func
+from sqlalchemy.orm import Session
+
+from src.models.database_models import TenantRiskArea
+from src.services.risk_service import HighRiskArea, HighRiskList
+from src.services.sanctions_service import parse_codes
+from src.spatial.geometry import geometry_from_geojson
+
+logger = logging.getLogger(__name__)
+
+
+class RiskAreaKind:
+    """Kinds of high-risk list entries."""
+    COUNTRY = "country"
+    REGION = "region"
+    POLYGON = "polygon"
+
+    ALL = (COUNTRY, REGION, POLYGON)
+
+
+def compile_risk_list(rows: List[TenantRiskArea]) -> HighRiskList:
+    """Build the scorer's list from stored entries."""
+    return HighRiskList(
+        countries=frozenset(row.code for row in rows if row.kind == RiskAreaKind.COUNTRY),
+        regions=frozenset(row.code for row in rows if row.kind == RiskAreaKind.REGION),
+        areas=[
+            HighRiskArea(row.area_id, row.name, geometry_from_geojson(row.geometry))
+            for row in rows
+            if row.kind == RiskAreaKind.POLYGON
+        ],
+    )
+
+
+class RiskListCache:
+    """Thread-safe LRU cache of compiled high-risk lists keyed by tenant."""
+
+    def __init__(self, max_tenants: int = 256):
+        """Initialize cache.
+
+        Args:
+            max_tenants: Maximum number of tenant lists kept in memory
+        """
+        self.max_tenants = max(1, max_tenants)
+        self._lists: "OrderedDict[str, Tuple[Tuple[Any, ...], HighRiskList]]" = OrderedDict()
+        self._lock = threading.Lock()
+
+    def get(self, session: Session, tenant_id: str) -> HighRiskList:
+        """The tenant's compiled list, rebuilt when its entries change."""
+        version = tuple(
+            session.query(func.count(TenantRiskArea.id), func.max(TenantRiskArea.created_at))
+            .filter(TenantRiskArea.tenant_id == tenant_id)
+            .one()
+        )
+        with self._lock:
+            cached = self._lists.get(tenant_id)
+            if cached is not None and cached[0] == version:
+                self._lists.move_to_end(tenant_id)
+                return cached[1]
+
+        rows = session.query(TenantRiskArea).filter(TenantRiskArea.tenant_id == tenant_id).all()
+        compiled = compile_risk_list(rows)
+        with self._lock:
+            self._lists[tenant_id] = (version, compiled)
+            self._lists.move_to_end(tenant_id)
+            while len(self._lists) > self.max_tenants:
+                self._lists.popitem(last=False)
+        return compiled
+
+    def invalidate(self, tenant_id: str) -> None:
+        """Drop a tenant's list."""
+        with self._lock:
+            self._lists.pop(tenant_id, None)
+
+    def __len__(self) -> int:
+        return len(self._lists)
+
+
+class RiskAreaService:
+    """Stores tenant high-risk list entries and compiles them for scoring."""
+
+    def __init__(self, session: Session, cache: Optional[RiskListCache] = None):
+        """Initialize risk area service.
+
+        Args:
+            session: SQLAlchemy database session
+            cache: Compiled list cache (default: the global cache)
+        """
+        self.session = session
+        self.cache = cache if cache is not None else get_risk_list_cache()
+
+    def create_area(
+        self,
+        tenant_id: str,
+        kind: str,
+        code: Optional[str] = None,
+        name: Optional[str] = None,
+        geometry: Optional[Dict[str, Any]] = None,
+    ) -> TenantRiskArea:
+        """Add a country, region or polygon to a tenant's high-risk list.
+
+        Args:
+            tenant_id: Owning tenant
+            kind: country, region or polygon
+            code: ISO 3166-1 alpha-2 (country) or ISO 3166-2 (region) code
+            name: Display name (reported in risk reasons for polygons)
+            geometry: GeoJSON Polygon or MultiPolygon (polygon)
+
+        Returns:
+            Stored TenantRiskArea
+
+        Raises:
+            ValueError: If the entry is invalid, already listed, or cannot be stored
+        """
+        if kind not in RiskAreaKind.ALL:
+            raise ValueError(f"Invalid kind {kind!r}: expected one of {', '.join(RiskAreaKind.ALL)}")
+        if kind == RiskAreaKind.POLYGON:
+            if geometry is None or code is not None:
+                raise ValueError("Polygon entries take a geometry and no code")
+            geometry = geometry_from_geojson(geometry).to_geojson()
+        else:
+            if code is None or geometry is not None:
+                raise ValueError(f"{kind.capitalize()} entries take a code and no geometry")
+            codes = parse_codes([code])
+            if len(codes) != 1 or ("-" in next(iter(codes))) != (kind == RiskAreaKind.REGION):
+                raise ValueError(f"Invalid {kind} code: {code!r}")
+            code = next(iter(codes))
+            exists = (
+                self.session.query(TenantRiskArea)
+                .filter(TenantRiskArea.tenant_id == tenant_id, TenantRiskArea.code == code)
+                .first()
+            )
+            if exists is not None:
+                raise ValueError(f"{code} is already listed")
+
+        area = TenantRiskArea(
+            area_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            kind=kind,
+            code=code,
+            name=name,
+            geometry=geometry,
+        )
+        try:
+            self.session.add(area)
+            self.session.commit()
+            self.session.refresh(area)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store high-risk area: {str(e)}")
+        self.cache.invalidate(tenant_id)
+        logger.info(f"Tenant {tenant_id} listed high-risk {kind} {code or area.area_id}")
+        return area
+
+    def list_areas(self, tenant_id: str) -> List[TenantRiskArea]:
+        """A tenant's high-risk list entries, oldest first."""
+        return (
+            self.session.query(TenantRiskArea)
+            .filter(TenantRiskArea.tenant_id == tenant_id)
+            .order_by(TenantRiskArea.created_at, TenantRiskArea.id)
+            .all()
+        )
+
+    def get_area(self, tenant_id: str, area_id: str) -> Optional[TenantRiskArea]:
+        """One of a tenant's entries (None if missing or another tenant's)."""
+        return (
+            self.session.query(TenantRiskArea)
+            .filter(TenantRiskArea.tenant_id == tenant_id, TenantRiskArea.area_id == area_id)
+            .first()
+        )
+
+    def delete_area(self, area: TenantRiskArea) -> None:
+        """Remove an entry from its tenant's list."""
+        tenant_id = area.tenant_id
+        self.session.delete(area)
+        self.session.commit()
+        self.cache.invalidate(tenant_id)
+
+    def risk_list(self, tenant_id: Optional[str]) -> Optional[HighRiskList]:
+        """The list scoring a tenant's lookups (None: use the global list)."""
+        if tenant_id is None:
+            return None
+        compiled = self.cache.get(self.session, tenant_id)
+        return compiled if len(compiled) else None
+
+
+# Global list cache (shared across requests)
+_risk_list_cache: Optional[RiskListCache] = None
+
+
+def get_risk_list_cache() -> RiskListCache:
+    """Get the global high-risk list cache, sized from RISK_AREA_CACHE_SIZE."""
+    global _risk_list_cache
+    if _risk_list_cache is None:
+        from src.config import get_config
+
+        _risk_list_cache = RiskListCache(get_config().risk_area_cache_size)
+    return _risk_list_cache
@@ -0,0 +1,453 @@
+"""Risk scoring from lookup and detection signals.
+
+Each signal inspects one aspect of a request (anonymizer flags, residential
+proxy, impossible travel, datacenter address, high-risk country, ...) and, when it fires,
+contributes its configured weight (0-1, RISK_WEIGHTS). Contributions are
+combined like independent probabilities,
+
+    score = 100 * (1 - (1 - w1) * (1 - w2) * ...)
+
+so a single signal scores its own weight, several signals compound, and
+the result never exceeds 100. Every fired signal is reported with a
+stable reason code. New signals subclass RiskSignal and are registered on
+the scorer.
+
+The high-risk signal uses the calling tenant's own list of countries,
+ISO 3166-2 regions and polygons when the tenant has one, and
+RISK_HIGH_RISK_COUNTRIES otherwise.
+"""
+
+import logging
+from dataclasses import dataclass, field
+from typing import Any, Dict, FrozenSet, Iterable, List, Optional
+
+from src.services.velocity_service import VelocitySnapshot
+
+logger = logging.getLogger(__name__)
+
+
+class ReasonCode:
+    """Reason codes reported for fired signals."""
+    ANONYMIZER_TOR = "ANONYMIZER_TOR"
+    ANONYMIZER_PROXY = "ANONYMIZER_PROXY"
+    ANONYMIZER_VPN = "ANONYMIZER_VPN"
+    RESIDENTIAL_PROXY = "RESIDENTIAL_PROXY"
+    IMPOSSIBLE_TRAVEL = "IMPOSSIBLE_TRAVEL"
+    DATACENTER_IP = "DATACENTER_IP"
+    HIGH_RISK_COUNTRY = "HIGH_RISK_COUNTRY"
+    HIGH_RISK_REGION = "HIGH_RISK_REGION"
+    HIGH_RISK_AREA = "HIGH_RISK_AREA"
+    LOCATION_MISMATCH = "LOCATION_MISMATCH"
+    HIGH_VELOCITY = "HIGH_VELOCITY"
+    NEW_DEVICE_COUNTRY = "NEW_DEVICE_COUNTRY"
+    LOCATION_ANOMALY = "LOCATION_ANOMALY"
+
+
+# Weights of the built-in signals when RISK_WEIGHTS does not set them
+DEFAULT_WEIGHTS = {
+    "anonymizer": 0.5,
+    "residential_proxy": 0.5,
+    "impossible_travel": 0.7,
+    "datacenter": 0.3,
+    "high_risk_country": 0.4,
+    "location_mismatch": 0.5,
+    "velocity": 0.4,
+    "new_device_country": 0.3,
+    "location_anomaly": 0.4,
+}
+
+
+@dataclass
+class HighRiskArea:
+    """Custom high-risk polygon."""
+    area_id: str
+    name: Optional[str]
+    geometry: Any  # Polygon or MultiPolygon from src.spatial.geometry
+
+
+@dataclass
+class HighRiskList:
+    """Countries, ISO 3166-2 regions and polygons treated as high risk."""
+    countries: FrozenSet[str] = frozenset()
+    regions: FrozenSet[str] = frozenset()
+    areas: List[HighRiskArea] = field(default_factory=list)
+
+    def __len__(self) -> int:
+        return len(self.countries) + len(self.regions) + len(self.areas)
+
+
+@dataclass
+class RiskContext:
+    """What is known about a request when it is scored."""
+    country_code: Optional[str] = None
+    region_codes: List[str] = field(default_factory=list)  # ISO 3166-2, e.g. GB-ENG
+    latitude: Optional[float] = None
+    longitude: Optional[float] = None
+    anonymizer: Dict[str, Optional[bool]] = field(default_factory=dict)  # vpn/tor/proxy/hosting
+    connection_type: Optional[str] = None  # ASN connection type
+    residential_proxy: Optional[bool] = None  # Listed endpoint or session ASN churn
+    datacenter: Optional[bool] = None  # Datacenter classification (None if not classified)
+    impossible_travel: Optional[bool] = None  # None if no travel check ran
+    location_mismatch: Optional[bool] = None  # None if no device coordinate was compared
+    velocity: Optional[VelocitySnapshot] = None
+    new_device_country: Optional[bool] = None  # Known device never seen in the country before
+    location_anomaly: Optional[bool] = None  # None if no anomaly check ran
+    high_risk: Optional[HighRiskList] = None  # Tenant's list (None uses the global one)
+
+    @classmethod
+    def from_lookup(
+        cls,
+        response: Any,
+        velocity: Optional[VelocitySnapshot] = None,
+        high_risk: Optional[HighRiskList] = None,
+    ) -> "RiskContext":
+        """Context of an IP lookup response and the blocks attached to it."""
+        anonymizer = getattr(response, "anonymizer", None)
+        asn = getattr(response, "asn", None)
+        travel = getattr(response, "travel", None)
+        mismatch = getattr(response, "location_mismatch", None)
+        datacenter = getattr(response, "datacenter", None)
+        device = getattr(response, "device", None)
+        anomaly = getattr(response, "anomaly", None)
+        session = getattr(response, "residential_proxy", None)
+        flags = anonymizer.model_dump() if anonymizer is not None else {}
+        residential_proxy = flags.get("residential_proxy")
+        if session is not None:
+            residential_proxy = bool(residential_proxy) or session.detected
+        country = (response.country_iso_code or "").upper()
+        regions = [
+            f"{country}-{sub['iso_code']}".upper()
+            for sub in getattr(response, "subdivisions", None) or []
+            if country and sub.get("iso_code")
+        ]
+        regions += [level.iso_code.upper() for level in getattr(response, "hierarchy", None) or []]
+        return cls(
+            country_code=response.country_iso_code,
+            region_codes=list(dict.fromkeys(regions)),
+            latitude=getattr(response, "latitude", None),
+            longitude=getattr(response, "longitude", None),
+            anonymizer=flags,
+            connection_type=asn.connection_type if asn is not None else None,
+            residential_proxy=residential_proxy,
+            datacenter=datacenter.is_datacenter if datacenter is not None else None,
+            impossible_travel=travel.impossible if travel is not None else None,
+            location_mismatch=mismatch.mismatch if mismatch is not None else None,
+            velocity=velocity,
+            new_device_country=(
+                device.known_device and device.country_seen_before is False if device is not None else None
+            ),
+            location_anomaly=anomaly.anomalous if anomaly is not None else None,
+            high_risk=high_risk,
+        )
+
+
+@dataclass
+class RiskReason:
+    """A fired signal."""
+    code: str  # ReasonCode value
+    signal: str
+    weight: float
+    detail: Optional[str] = None
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {"code": self.code, "signal": self.signal, "weight": self.weight, "detail": self.detail}
+
+
+@dataclass
+class RiskScore:
+    """Combined score of a request."""
+    score: int  # 0-100
+    reasons: List[RiskReason] = field(default_factory=list)  # Highest weight first
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {"score": self.score, "reasons": [reason.to_dict() for reason in self.reasons]}
+
+
+@dataclass
+class SignalHit:
+    """Result of a signal that fired."""
+    code: str
+    detail: Optional[str] = None
+
+
+class RiskSignal:
+    """Base class for risk signals."""
+
+    name: str = ""
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        """Check the signal against a request.
+
+        Returns:
+            SignalHit if the signal fired, else None (also when the data it
+            needs is missing)
+        """
+        raise NotImplementedError
+
+
+class AnonymizerSignal(RiskSignal):
+    """Tor, proxy or VPN address (strongest flag reported)."""
+
+    name = "anonymizer"
+
+    _FLAGS = (
+        ("tor", ReasonCode.ANONYMIZER_TOR),
+        ("proxy", ReasonCode.ANONYMIZER_PROXY),
+        ("vpn", ReasonCode.ANONYMIZER_VPN),
+    )
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        for flag, code in self._FLAGS:
+            if context.anonymizer.get(flag):
+                return SignalHit(code, f"Address is a known {flag} endpoint")
+        return None
+
+
+class ResidentialProxySignal(RiskSignal):
+    """Rotating residential proxy: a listed endpoint or a session hopping ASNs."""
+
+    name = "residential_proxy"
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        if context.residential_proxy:
+            return SignalHit(ReasonCode.RESIDENTIAL_PROXY, "Request comes through a residential proxy network")
+        return None
+
+
+class ImpossibleTravelSignal(RiskSignal):
+    """Implied speed from the user's previous login is too high."""
+
+    name = "impossible_travel"
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        if context.impossible_travel:
+            return SignalHit(ReasonCode.IMPOSSIBLE_TRAVEL, "Too far from the previous login for the time elapsed")
+        return None
+
+
+class DatacenterSignal(RiskSignal):
+    """Address of a hosting or cloud provider rather than an access network."""
+
+    name = "datacenter"
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        if (
+            context.datacenter
+            or context.anonymizer.get("hosting")
+            or context.connection_type == "hosting"
+        ):
+            return SignalHit(ReasonCode.DATACENTER_IP, "Address belongs to a hosting provider")
+        return None
+
+
+class HighRiskCountrySignal(RiskSignal):
+    """Address located in a listed country, region or polygon.
+
+    The tenant's list in the context replaces the global one; the most
+    specific match is reported.
+    """
+
+    name = "high_risk_country"
+
+    def __init__(self, countries: Iterable[str] = ()):
+        """Initialize signal.
+
+        Args:
+            countries: ISO 3166-1 alpha-2 codes treated as high risk for
+                requests without a tenant list
+        """
+        self.countries: FrozenSet[str] = frozenset(c.strip().upper() for c in countries if c.strip())
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        listed = context.high_risk if context.high_risk is not None else HighRiskList(self.countries)
+        if context.latitude is not None and context.longitude is not None:
+            for area in listed.areas:
+                if area.geometry.contains(context.longitude, context.latitude):
+                    return SignalHit(ReasonCode.HIGH_RISK_AREA, f"Located in {area.name or area.area_id}")
+        for region in context.region_codes:
+            if region in listed.regions:
+                return SignalHit(ReasonCode.HIGH_RISK_REGION, f"Located in {region}")
+        country = (context.country_code or "").upper()
+        if country in listed.countries:
+            return SignalHit(ReasonCode.HIGH_RISK_COUNTRY, f"Located in {country}")
+        return None
+
+
+class LocationMismatchSignal(RiskSignal):
+    """Device-reported coordinate far from the IP location."""
+
+    name = "location_mismatch"
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        if context.location_mismatch:
+            return SignalHit(ReasonCode.LOCATION_MISMATCH, "Device coordinate is far from the IP location")
+        return None
+
+
+class VelocitySignal(RiskSignal):
+    """Too many distinct countries for the account within a window."""
+
+    name = "velocity"
+
+    def __init__(self, max_countries: int = 2):
+        """Initialize signal.
+
+        Args:
+            max_countries: Most distinct countries allowed in any window
+        """
+        self.max_countries = max_countries
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        snapshot = context.velocity
+        if snapshot is None:
+            return None
+        for window, count in snapshot.counts.get("country", {}).items():
+            if count > self.max_countries:
+                return SignalHit(ReasonCode.HIGH_VELOCITY, f"{count} countries within {window}")
+        return None
+
+
+class NewDeviceCountrySignal(RiskSignal):
+    """Known device seen in a country for the first time."""
+
+    name = "new_device_country"
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        if context.new_device_country:
+            return SignalHit(ReasonCode.NEW_DEVICE_COUNTRY, "Device has not been seen in this country before")
+        return None
+
+
+class LocationAnomalySignal(RiskSignal):
+    """Event far outside the user's usual locations."""
+
+    name = "location_anomaly"
+
+    def evaluate(self, context: RiskContext) -> Optional[SignalHit]:
+        if context.location_anomaly:
+            return SignalHit(ReasonCode.LOCATION_ANOMALY, "Far outside the user's usual locations")
+        return None
+
+
+def parse_weights(raw: str) -> Dict[str, float]:
+    """Parse RISK_WEIGHTS ("anonymizer:0.5,datacenter:0.3").
+
+    Weights are clamped to 0-1; malformed entries are logged and ignored.
+
+    Args:
+        raw: Comma-separated signal:weight pairs
+
+    Returns:
+        dict of signal name -> weight
+    """
+    weights = {}
+    for entry in (e.strip() for e in raw.split(",")):
+        if not entry:
+            continue
+        name, _, weight = entry.partition(":")
+        try:
+            weights[name.strip().lower()] = min(1.0, max(0.0, float(weight)))
+        except ValueError:
+            logger.warning(f"Ignoring malformed risk weight entry: {entry}")
+    return weights
+
+
+class RiskScorer:
+    """Runs the registered signals and combines their weights."""
+
+    def __init__(self, signals: Optional[List[RiskSignal]] = None, weights: Optional[Dict[str, float]] = None):
+        """Initialize scorer.
+
+        Args:
+            signals: Signals to run
+            weights: Signal name -> weight (0 disables a signal; signals
+                without an entry use DEFAULT_WEIGHTS, else 0)
+        """
+        self.weights = {**DEFAULT_WEIGHTS, **(weights or {})}
+        self._signals: Dict[str, RiskSignal] = {}
+        for signal in signals or []:
+            self.register(signal)
+
+    def register(self, signal: RiskSignal) -> None:
+        """Register a signal under its name (replacing any existing one)."""
+        self._signals[signal.name] = signal
+
+    @property
+    def signals(self) -> Dict[str, float]:
+        """Registered signal names and their weights."""
+        return {name: self.weights.get(name, 0.0) for name in self._signals}
+
+    def score(self, context: RiskContext) -> RiskScore:
+        """Score a request.
+
+        A failing signal is logged and skipped so that one broken check
+        cannot fail the request.
+
+        Args:
+            context: What is known about the request
+
+        Returns:
+            RiskScore with the reasons of every fired signal
+        """
+        reasons: List[RiskReason] = []
+        remaining = 1.0
+        for name, signal in self._signals.items():
+            weight = self.weights.get(name, 0.0)
+            if weight <= 0:
+                continue
+            try:
+                hit = signal.evaluate(context)
+            except Exception as e:
+                logger.error(f"Risk signal '{name}' failed: {type(e).__name__}: {str(e)}")
+                continue
+            if hit is None:
+                continue
+            reasons.append(RiskReason(hit.code, name, weight, hit.detail))
+            remaining *= 1.0 - weight
+        reasons.sort(key=lambda reason: reason.weight, reverse=True)
+        return RiskScore(score=round(100 * (1.0 - remaining)), reasons=reasons)
+
+
+def build_risk_scorer(config) -> RiskScorer:
+    """Create the scorer with the built-in signals from configuration.
+
+    Args:
+        config: Application configuration
+
+    Returns:
+        RiskScorer
+    """
+    return RiskScorer(
+        [
+            AnonymizerSignal(),
+            ResidentialProxySignal(),
+            ImpossibleTravelSignal(),
+            DatacenterSignal(),
+            HighRiskCountrySignal(config.risk_high_risk_countries.split(",")),
+            LocationMismatchSignal(),
+            VelocitySignal(config.risk_velocity_max_countries),
+            NewDeviceCountrySignal(),
+            LocationAnomalySignal(),
+        ],
+        parse_weights(config.risk_weights),
+    )
+
+
+# Global risk scorer (built lazily from configuration)
+_risk_scorer: Optional[RiskScorer] = None
+
+
+def get_risk_scorer() -> RiskScorer:
+    """Get the global risk scorer.
+
+    Returns:
+        RiskScorer with the built-in signals
+    """
+    global _risk_scorer
+    if _risk_scorer is None:
+        from src.config import get_config
+
+        _risk_scorer = build_risk_scorer(get_config())
+    return _risk_scorer
@@ -0,0 +1,318 @@
+"""Expression language for detection rules.
+
+Rules are boolean expressions over the variables of a lookup (see
+RULE_VARIABLES):
+
+    risk > 70 && country in ["RU", "IR"] && is_vpn
+    !is_datacenter and ("ANONYMIZER_TOR" in reasons || asn == 9009)
+
+Operators, loosest binding first: `||` (or `or`), `&&` (`and`), `!`
+(`not`), then the comparisons `==`, `!=`, `<`, `<=`, `>`, `>=`, `in` and
+`not in`. Literals are numbers, "strings" or 'strings', true, false, null
+and [lists] of literals. Any comparison with a missing (null) value is
+false, so `risk > 70` does not fire when no risk score was computed.
+
+Expressions are parsed and type-checked once, when the rule is loaded:
+unknown variables, malformed syntax and mismatched operand types are
+reported with their column.
+"""
+
+import re
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, FrozenSet, List, Tuple
+
+# Variable name -> (type, description); types are bool, number, string and list
+RULE_VARIABLES: Dict[str, Tuple[str, str]] = {
+    "risk": ("number", "Risk score 0-100"),
+    "country": ("string", "ISO 3166-1 alpha-2 country code"),
+    "continent": ("string", "Continent code"),
+    "city": ("string", "City name"),
+    "regions": ("list", "ISO 3166-2 codes of the location"),
+    "groups": ("list", "Country groups of the location"),
+    "asn": ("number", "Autonomous system number"),
+    "connection_type": ("string", "ASN connection type (residential, business, cellular, hosting)"),
+    "confidence": ("number", "Location confidence 0-1"),
+    "accuracy_km": ("number", "Accuracy radius in kilometers"),
+    "is_vpn": ("bool", "Known VPN endpoint"),
+    "is_tor": ("bool", "Tor exit node"),
+    "is_proxy": ("bool", "Open or public proxy"),
+    "is_hosting": ("bool", "Hosting provider address"),
+    "is_residential_proxy": ("bool", "Residential proxy endpoint or churning session"),
+    "is_datacenter": ("bool", "Classified as a datacenter address"),
+    "impossible_travel": ("bool", "Travel block flagged"),
+    "location_mismatch": ("bool", "Device and IP locations disagree"),
+    "location_anomaly": ("bool", "Unusual location for the user"),
+    "new_device_country": ("bool", "Known device in a new country"),
+    "reasons": ("list", "Reason codes of the risk score"),
+}
+
+ANY = "any"
+
+_TOKEN = re.compile(
+    r"""\s*(?:
+        (?P<number>-?\d+(?:\.\d+)?)
+      | (?P<string>"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')
+      | (?P<op>&&|\|\||==|!=|<=|>=|<|>|!|\(|\)|\[|\]|,)
+      | (?P<name>[A-Za-z_][A-Za-z0-9_]*)
+    )""",
+    re.VERBOSE,
+)
+_WORD_OPS = {"and": "&&", "or": "||", "not": "!"}
+_LITERALS = {"true": True, "false": False, "null": None}
+_COMPARISONS = {
+    "==": lambda a, b: a == b,
+    "!=": lambda a, b: a != b,
+    "<": lambda a, b: a < b,
+    "<=": lambda a, b: a <= b,
+    ">": lambda a, b: a > b,
+    ">=": lambda a, b: a >= b,
+}
+
+Evaluator = Callable[[Dict[str, Any]], Any]
+
+
+class RuleSyntaxError(ValueError):
+    """Invalid rule expression."""
+
+    def __init__(self, message: str, position: int):
+        super().__init__(f"{message} at column {position + 1}")
+        self.position = position
+
+
+@dataclass
+class _Token:
+    kind: str  # number, string, op, name or end
+    value: Any
+    position: int
+
+
+@dataclass
+class _Node:
+    evaluate: Evaluator
+    type: str  # bool, number, string, list, null or any
+    position: int
+
+
+def _tokenize(text: str) -> List[_Token]:
+    tokens: List[_Token] = []
+    position = 0
+    while True:
+        while position < len(text) and text[position].isspace():
+            position += 1
+        if position >= len(text):
+            break
+        match = _TOKEN.match(text, position)
+        if match is None or match.end() == position:
+            raise RuleSyntaxError(f"Unexpected character {text[position]!r}", position)
+        kind = match.lastgroup
+        raw = match.group(kind)
+        start = match.start(kind)
+        if kind == "number":
+            value: Any = float(raw) if "." in raw else int(raw)
+        elif kind == "string":
+            value = re.sub(r"\\(.)", r"\1", raw[1:-1])
+        elif kind == "name" and raw in _WORD_OPS:
+            kind, value = "op", _WORD_OPS[raw]
+        else:
+            value = raw
+        tokens.append(_Token(kind, value, start))
+        position = match.end()
+    tokens.append(_Token("end", None, len(text)))
+    return tokens
+
+
+def _truthy(value: Any) -> bool:
+    return bool(value)
+
+
+class _Parser:
+    """Recursive-descent parser building evaluator closures."""
+
+    def __init__(self, text: str):
+        self.tokens = _tokenize(text)
+        self.index = 0
+        self.variables: set = set()
+
+    @property
+    def current(self) -> _Token:
+        return self.tokens[self.index]
+
+    def _accept(self, op: str) -> bool:
+        token = self.current
+        if token.kind == "op" and token.value == op:
+            self.index += 1
+            return True
+        return False
+
+    def _expect(self, op: str) -> None:
+        if not self._accept(op):
+            raise RuleSyntaxError(f"Expected '{op}'", self.current.position)
+
+    def parse(self) -> _Node:
+        node = self._or()
+        if self.current.kind != "end":
+            raise RuleSyntaxError(f"Unexpected {self.current.value!r}", self.current.position)
+        return node
+
+    def _or(self) -> _Node:
+        node = self._and()
+        while self._accept("||"):
+            left, right = node, self._and()
+            node = _Node(
+                lambda ctx, l=left.evaluate, r=right.evaluate: _truthy(l(ctx)) or _truthy(r(ctx)),
+                "bool",
+                left.position,
+            )
+        return node
+
+    def _and(self) -> _Node:
+        node = self._not()
+        while self._accept("&&"):
+            left, right = node, self._not()
+            node = _Node(
+                lambda ctx, l=left.evaluate, r=right.evaluate: _truthy(l(ctx)) and _truthy(r(ctx)),
+                "bool",
+                left.position,
+            )
+        return node
+
+    def _not(self) -> _Node:
+        token = self.current
+        if self._accept("!"):
+            operand = self._not()
+            return _Node(lambda ctx, e=operand.evaluate: not _truthy(e(ctx)), "bool", token.position)
+        return self._comparison()
+
+    def _comparison(self) -> _Node:
+        left = self._primary()
+        token = self.current
+        if token.kind == "op" and token.value in _COMPARISONS:
+            self.index += 1
+            right = self._primary()
+            return self._compare(token, left, right)
+        negate = False
+        if token.kind == "op" and token.value == "!" and self.tokens[self.index + 1].value == "in":
+            # "not in" (the word "not" is tokenized as "!")
+            self.index += 1
+            negate = True
+        if self.current.kind == "name" and self.current.value == "in":
+            self.index += 1
+            right = self._primary()
+            return self._membership(token, left, right, negate)
+        return left
+
+    def _compare(self, token: _Token, left: _Node, right: _Node) -> _Node:
+        op = token.value
+        types = {left.type, right.type} - {ANY}
+        if op in ("==", "!="):
+            if len(types - {"null"}) > 1:
+                raise RuleSyntaxError(f"Cannot compare {left.type} with {right.type}", token.position)
+        elif types - {"number"}:
+            raise RuleSyntaxError(f"'{op}' needs numbers, got {left.type} and {right.type}", token.position)
+        compare = _COMPARISONS[op]
+
+        def evaluate(ctx, l=left.evaluate, r=right.evaluate, null_ok=op in ("==", "!=")):
+            a, b = l(ctx), r(ctx)
+            if (a is None or b is None) and not null_ok:
+                return False
+            try:
+                return compare(a, b)
+            except TypeError:
+                return False
+
+        return _Node(evaluate, "bool", left.position)
+
+    def _membership(self, token: _Token, left: _Node, right: _Node, negate: bool) -> _Node:
+        if right.type not in ("list", ANY):
+            raise RuleSyntaxError(f"'in' needs a list, got {right.type}", token.position)
+
+        def evaluate(ctx, l=left.evaluate, r=right.evaluate):
+            value, members = l(ctx), r(ctx)
+            if value is None or members is None:
+                return False
+            return (value in members) != negate
+
+        return _Node(evaluate, "bool", left.position)
+
+    def _primary(self) -> _Node:
+        token = self.current
+        if token.kind in ("number", "string"):
+            self.index += 1
+            value = token.value
+            return _Node(lambda ctx: value, "number" if token.kind == "number" else "string", token.position)
+        if token.kind == "name":
+            self.index += 1
+            if token.value in _LITERALS:
+                value = _LITERALS[token.value]
+                return _Node(lambda ctx: value, "null" if value is None else "bool", token.position)
+            if token.value not in RULE_VARIABLES:
+                raise RuleSyntaxError(f"Unknown variable {token.value!r}", token.position)
+            name = token.value
+            self.variables.add(name)
+            return _Node(lambda ctx: ctx.get(name), RULE_VARIABLES[name][0], token.position)
+        if self._accept("("):
+            node = self._or()
+            self._expect(")")
+            return node
+        if self._accept("["):
+            return self._list(token)
+        if token.kind == "end":
+            raise RuleSyntaxError("Unexpected end of expression", token.position)
+        raise RuleSyntaxError(f"Unexpected {token.value!r}", token.position)
+
+    def _list(self, start: _Token) -> _Node:
+        values: List[Any] = []
+        if not self._accept("]"):
+            while True:
+                token = self.current
+                if token.kind in ("number", "string"):
+                    values.append(token.value)
+                elif token.kind == "name" and token.value in _LITERALS:
+                    values.append(_LITERALS[token.value])
+                else:
+                    raise RuleSyntaxError("Lists may only hold literals", token.position)
+                self.index += 1
+                if self._accept("]"):
+                    break
+                self._expect(",")
+        members = frozenset(values)
+        return _Node(lambda ctx: members, "list", start.position)
+
+
+@dataclass(frozen=True)
+class CompiledExpression:
+    """Validated rule expression."""
+    source: str
+    variables: FrozenSet[str]
+    _evaluate: Evaluator
+
+    def __call__(self, context: Dict[str, Any]) -> bool:
+        """Evaluate against a lookup's variables (missing ones are null)."""
+        return _truthy(self._evaluate(context))
+
+
+def compile_expression(text: str) -> CompiledExpression:
+    """Parse and type-check a rule expression.
+
+    Args:
+        text: Expression source
+
+    Returns:
+        CompiledExpression
+
+    Raises:
+        RuleSyntaxError: If the expression is malformed, uses an unknown
+            variable, mixes operand types or is not a condition
+    """
+    if not isinstance(text, str) or not text.strip():
+        raise RuleSyntaxError("Empty expression", 0)
+    parser = _Parser(text)
+    node = parser.parse()
+    if node.type not in ("bool", ANY):
+        raise RuleSyntaxError(f"Expression must be a condition, got {node.type}", node.position)
+    return CompiledExpression(text, frozenset(parser.variables), node.evaluate)
+
+
+def describe_variables() -> Dict[str, Dict[str, str]]:
+    """Variables available to rules, for documentation endpoints."""
+    return {name: {"type": kind, "description": text} for name, (kind, text) in RULE_VARIABLES.items()}
@@ -0,0 +1,162 @@
+"""Integrator feedback on detection rule decisions.
+
+Integrators report that a lookup's rule decision was wrong (a false
+positive) or confirm that it was right, naming the rules that fired. The
+reports are stored per rule and aggregated into each rule's precision,
+confirmed / (confirmed + false positives), to guide threshold tuning.
+"""
+
+import ipaddress
+import logging
+import uuid
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Any, Collection, Dict, List, Optional
+
+from sqlalchemy import func
+from sqlalchemy.orm import Session
+
+from src.models.database_models import RuleFeedback
+from src.services.rule_service import RuleAction
+
+logger = logging.getLogger(__name__)
+
+
+class FeedbackVerdict:
+    """Integrator verdicts on a decision."""
+    FALSE_POSITIVE = "false_positive"
+    TRUE_POSITIVE = "true_positive"
+
+    ALL = (FALSE_POSITIVE, TRUE_POSITIVE)
+
+
+@dataclass
+class RuleFeedbackStats:
+    """Feedback totals of one rule."""
+    rule_id: str
+    true_positives: int = 0
+    false_positives: int = 0
+
+    @property
+    def reports(self) -> int:
+        return self.true_positives + self.false_positives
+
+    @property
+    def precision(self) -> Optional[float]:
+        """Share of reported decisions that were right (None without reports)."""
+        if not self.reports:
+            return None
+        return round(self.true_positives / self.reports, 4)
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "rule_id": self.rule_id,
+            "reports": self.reports,
+            "true_positives": self.true_positives,
+            "false_positives": self.false_positives,
+            "precision": self.precision,
+        }
+
+
+def _utc(value: datetime) -> datetime:
+    """Naive UTC datetime as stored in the database."""
+    if value.tzinfo is not None:
+        value = value.astimezone(timezone.utc).replace(tzinfo=None)
+    return value
+
+
+class RuleFeedbackService:
+    """Stores and aggregates feedback on detection rules."""
+
+    def __init__(self, session: Session):
+        """Initialize service.
+
+        Args:
+            session: SQLAlchemy database session
+        """
+        self.session = session
+
+    def record(
+        self,
+        tenant_id: str,
+        rule_ids: List[str],
+        verdict: str,
+        decision: Optional[str] = None,
+        ip_address: Optional[str] = None,
+        comment: Optional[str] = None,
+        known_rule_ids: Optional[Collection[str]] = None,
+    ) -> List[RuleFeedback]:
+        """Store a verdict on the rules that fired for a decision.
+
+        Args:
+            tenant_id: Reporting tenant
+            rule_ids: Rules that fired for the decision
+            verdict: false_positive or true_positive
+            decision: Decision the lookup got
+            ip_address: Looked-up address
+            comment: Free-text explanation
+            known_rule_ids: Rules that may be reported (None: any)
+
+        Returns:
+            Stored rows, one per rule, sharing a feedback_id
+
+        Raises:
+            ValueError: If the verdict, decision, address or a rule ID is invalid
+        """
+        if verdict not in FeedbackVerdict.ALL:
+            raise ValueError(f"verdict must be one of {', '.join(FeedbackVerdict.ALL)}")
+        if decision is not None and decision not in RuleAction.ALL:
+            raise ValueError(f"decision must be one of {', '.join(RuleAction.ALL)}")
+        if ip_address is not None:
+            try:
+                ip_address = str(ipaddress.ip_address(ip_address.strip()))
+            except ValueError:
+                raise ValueError(f"Invalid IP address: {ip_address}")
+        rule_ids = list(dict.fromkeys(r.strip() for r in rule_ids if r and r.strip()))
+        if not rule_ids:
+            raise ValueError("rule_ids must name at least one rule")
+        if known_rule_ids is not None:
+            unknown = [r for r in rule_ids if r not in known_rule_ids]
+            if unknown:
+                raise ValueError(f"Unknown rules: {', '.join(unknown)}")
+
+        feedback_id = str(uuid.uuid4())
+        rows = [
+            RuleFeedback(
+                feedback_id=feedback_id,
+                tenant_id=tenant_id,
+                rule_id=rule_id,
+                verdict=verdict,
+                decision=decision,
+                ip_address=ip_address,
+                comment=comment,
+            )
+            for rule_id in rule_ids
+        ]
+        for row in rows:
+            self.session.add(row)
+        self.session.commit()
+        logger.info(f"Tenant {tenant_id} reported {verdict} for rules {', '.join(rule_ids)}")
+        return rows
+
+    def stats(self, since: Optional[datetime] = None) -> List[RuleFeedbackStats]:
+        """Per-rule feedback totals of all tenants, least precise first.
+
+        Args:
+            since: Only count feedback from this time on
+
+        Returns:
+            Stats of every rule with feedback
+        """
+        query = self.session.query(RuleFeedback.rule_id, RuleFeedback.verdict, func.count(RuleFeedback.id))
+        if since is not None:
+            query = query.filter(RuleFeedback.created_at >= _utc(since))
+        stats: Dict[str, RuleFeedbackStats] = {}
+        for rule_id, verdict, count in query.group_by(RuleFeedback.rule_id, RuleFeedback.verdict).all():
+            entry = stats.setdefault(rule_id, RuleFeedbackStats(rule_id))
+            if verdict == FeedbackVerdict.TRUE_POSITIVE:
+                entry.true_positives += count
+            else:
+                entry.false_positives += count
+        return sorted(stats.values(), key=lambda s: (s.precision, -s.reports, s.rule_id))
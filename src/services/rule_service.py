@@ -0,0 +1,347 @@
+"""Detection rules authored as data and hot-reloaded from RULES_PATH.
+
+The rules file is a JSON object with a list of rules:
+
+    {
+      "rules": [
+        {"id": "vpn-sanctioned", "action": "block",
+         "expression": "risk > 70 && country in [\\"RU\\", \\"IR\\"] && is_vpn",
+         "description": "High-risk VPN traffic from embargoed countries"},
+        {"id": "tor", "action": "review", "expression": "is_tor"},
+        {"id": "tor-strict", "action": "block", "state": "shadow",
+         "expression": "is_tor || is_proxy"}
+      ]
+    }
+
+Expressions use the language in src.services.rule_dsl. Every lookup is
+evaluated against the loaded rules; the most severe action of the enforced
+rules that fire (block > review > flag) is the lookup's decision.
+
+Rules in the shadow state are evaluated and their matches logged and
+counted in rule_matches_total, but never reach the response, so a new rule
+can be measured against live traffic before it is enforced.
+
+The file is re-read when its modification time changes, checked at most
+every RULES_RELOAD_INTERVAL_SECONDS, so rules change without a deploy. A
+file that fails validation is rejected as a whole and the previous rules
+stay active; the error is reported by GET /api/v1/rules.
+"""
+
+import hashlib
+import json
+import logging
+import os
+import threading
+import time
+from dataclasses import dataclass, field
+from datetime import datetime, timezone
+from typing import Any, Callable, Dict, List, Optional
+
+from src.services.rule_dsl import CompiledExpression, RuleSyntaxError, compile_expression
+
+logger = logging.getLogger(__name__)
+
+
+class RuleAction:
+    """Actions of a firing rule, least severe first."""
+    FLAG = "flag"
+    REVIEW = "review"
+    BLOCK = "block"
+
+    ALL = (FLAG, REVIEW, BLOCK)
+
+
+ALLOW = "allow"
+
+
+class RuleState:
+    """Whether a rule affects responses."""
+    ENFORCE = "enforce"
+    SHADOW = "shadow"
+
+    ALL = (ENFORCE, SHADOW)
+
+
+@dataclass
+class Rule:
+    """Validated detection rule."""
+    rule_id: str
+    action: str
+    expression: CompiledExpression
+    description: Optional[str] = None
+    state: str = RuleState.ENFORCE
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "rule_id": self.rule_id,
+            "action": self.action,
+            "state": self.state,
+            "expression": self.expression.source,
+            "description": self.description,
+            "variables": sorted(self.expression.variables),
+        }
+
+
+@dataclass
+class RuleMatch:
+    """A rule that fired for a lookup."""
+    rule_id: str
+    action: str
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {"rule_id": self.rule_id, "action": self.action}
+
+
+def _most_severe(matches: List[RuleMatch]) -> str:
+    actions = [match.action for match in matches]
+    for action in reversed(RuleAction.ALL):
+        if action in actions:
+            return action
+    return ALLOW
+
+
+@dataclass
+class RuleEvaluation:
+    """Outcome of evaluating the rules for a lookup."""
+    matches: List[RuleMatch] = field(default_factory=list)
+    shadow_matches: List[RuleMatch] = field(default_factory=list)
+
+    @property
+    def decision(self) -> str:
+        """Most severe action of the fired enforced rules (allow if none fired)."""
+        return _most_severe(self.matches)
+
+    @property
+    def shadow_decision(self) -> str:
+        """Decision if the fired shadow rules were enforced too."""
+        return _most_severe(self.matches + self.shadow_matches)
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses (shadow matches left out)."""
+        return {"decision": self.decision, "matches": [match.to_dict() for match in self.matches]}
+
+
+def parse_rules(document: Any) -> List[Rule]:
+    """Validate a rules document.
+
+    Args:
+        document: Decoded JSON object with a "rules" list
+
+    Returns:
+        Rules in file order
+
+    Raises:
+        ValueError: If the document or any rule is invalid (naming the rule)
+    """
+    if not isinstance(document, dict) or not isinstance(document.get("rules"), list):
+        raise ValueError('Rules file must be a JSON object with a "rules" list')
+    rules: List[Rule] = []
+    seen = set()
+    for index, entry in enumerate(document["rules"]):
+        if not isinstance(entry, dict):
+            raise ValueError(f"Rule #{index + 1}: expected an object")
+        rule_id = entry.get("id")
+        label = f"Rule {rule_id!r}" if rule_id else f"Rule #{index + 1}"
+        if not isinstance(rule_id, str) or not rule_id.strip():
+            raise ValueError(f"{label}: id is required")
+        if rule_id in seen:
+            raise ValueError(f"{label}: duplicate id")
+        action = entry.get("action", RuleAction.FLAG)
+        if action not in RuleAction.ALL:
+            raise ValueError(f"{label}: action must be one of {', '.join(RuleAction.ALL)}")
+        state = entry.get("state", RuleState.ENFORCE)
+        if state not in RuleState.ALL:
+            raise ValueError(f"{label}: state must be one of {', '.join(RuleState.ALL)}")
+        try:
+            expression = compile_expression(entry.get("expression"))
+        except RuleSyntaxError as e:
+            raise ValueError(f"{label}: {e}")
+        seen.add(rule_id)
+        rules.append(Rule(rule_id, action, expression, entry.get("description"), state))
+    return rules
+
+
+def rule_context(response: Any) -> Dict[str, Any]:
+    """Rule variables of an IP lookup response and the blocks attached to it."""
+    anonymizer = getattr(response, "anonymizer", None)
+    flags = anonymizer.model_dump() if anonymizer is not None else {}
+    asn = getattr(response, "asn", None)
+    risk = getattr(response, "risk", None)
+    datacenter = getattr(response, "datacenter", None)
+    travel = getattr(response, "travel", None)
+    mismatch = getattr(response, "location_mismatch", None)
+    anomaly = getattr(response, "anomaly", None)
+    device = getattr(response, "device", None)
+    session = getattr(response, "residential_proxy", None)
+    country = response.country_iso_code
+    regions = [
+        f"{country}-{sub['iso_code']}"
+        for sub in getattr(response, "subdivisions", None) or []
+        if country and sub.get("iso_code")
+    ]
+    regions += [level.iso_code for level in getattr(response, "hierarchy", None) or []]
+    return {
+        "risk": risk.score if risk is not None else None,
+        "reasons": [reason.code for reason in risk.reasons] if risk is not None else None,
+        "country": country,
+        "continent": getattr(response, "continent_code", None),
+        "city": getattr(response, "city_name", None),
+        "regions": list(dict.fromkeys(regions)),
+        "groups": getattr(response, "groups", None),
+        "asn": asn.number if asn is not None else None,
+        "connection_type": asn.connection_type if asn is not None else None,
+        "confidence": getattr(response, "confidence", None),
+        "accuracy_km": getattr(response, "accuracy_radius_km", None),
+        "is_vpn": flags.get("vpn"),
+        "is_tor": flags.get("tor"),
+        "is_proxy": flags.get("proxy"),
+        "is_hosting": flags.get("hosting"),
+        "is_residential_proxy": bool(flags.get("residential_proxy")) or bool(session and session.detected),
+        "is_datacenter": datacenter.is_datacenter if datacenter is not None else None,
+        "impossible_travel": travel.impossible if travel is not None else None,
+        "location_mismatch": mismatch.mismatch if mismatch is not None else None,
+        "location_anomaly": anomaly.anomalous if anomaly is not None else None,
+        "new_device_country": (
+            device.known_device and device.country_seen_before is False if device is not None else None
+        ),
+    }
+
+
+class RuleEngine:
+    """Holds the active rules and re-reads the rules file when it changes."""
+
+    def __init__(
+        self,
+        path: str = "",
+        reload_interval_seconds: float = 5.0,
+        clock: Callable[[], float] = time.monotonic,
+    ):
+        """Initialize rule engine.
+
+        Args:
+            path: Rules file (empty: no rules)
+            reload_interval_seconds: Least time between file change checks
+            clock: Monotonic time source
+        """
+        self.path = path
+        self.reload_interval_seconds = reload_interval_seconds
+        self.clock = clock
+        self._rules: List[Rule] = []
+        self._mtime: Optional[int] = None
+        self._checked_at: Optional[float] = None
+        self._lock = threading.Lock()
+        self.version: Optional[str] = None  # Content hash of the active file
+        self.loaded_at: Optional[datetime] = None
+        self.last_error: Optional[str] = None  # Why the latest change was rejected
+
+    @property
+    def rules(self) -> List[Rule]:
+        """Active rules (the file is checked for changes first)."""
+        self._maybe_reload()
+        return self._rules
+
+    def _maybe_reload(self) -> None:
+        if not self.path:
+            return
+        now = self.clock()
+        with self._lock:
+            if self._checked_at is not None and now - self._checked_at < self.reload_interval_seconds:
+                return
+            self._checked_at = now
+        try:
+            mtime = os.stat(self.path).st_mtime_ns
+        except OSError as e:
+            if self.last_error is None:
+                logger.error(f"Rules file unavailable, keeping {len(self._rules)} active rules: {e}")
+            self.last_error = f"Rules file unavailable: {str(e)}"
+            return
+        if mtime != self._mtime:
+            self.reload()
+
+    def reload(self) -> bool:
+        """Read and validate the rules file now.
+
+        Returns:
+            True if the file was loaded; False if it was rejected and the
+            previous rules stay active
+        """
+        if not self.path:
+            return False
+        mtime = None
+        try:
+            mtime = os.stat(self.path).st_mtime_ns
+            with open(self.path, "rb") as f:
+                content = f.read()
+            rules = parse_rules(json.loads(content))
+        except (OSError, ValueError) as e:
+            # json.JSONDecodeError is a ValueError; the rejected version is
+            # not retried until the file changes again
+            self._mtime = mtime
+            self.last_error = str(e)
+            logger.error(f"Rejected rules file {self.path}, keeping {len(self._rules)} active rules: {e}")
+            return False
+        with self._lock:
+            self._rules = rules
+            self._mtime = mtime
+            self.version = hashlib.sha256(content).hexdigest()[:12]
+            self.loaded_at = datetime.now(timezone.utc)
+            self.last_error = None
+        logger.info(f"Loaded {len(rules)} detection rules from {self.path} (version {self.version})")
+        return True
+
+    def evaluate(self, context: Dict[str, Any], subject: Optional[str] = None) -> RuleEvaluation:
+        """Evaluate the active rules.
+
+        A rule that fails at evaluation time is logged and skipped. Matches
+        are counted per rule and state; shadow matches are also logged with
+        the decision they would have produced.
+
+        Args:
+            context: Rule variables (see rule_context)
+            subject: What was evaluated (e.g. the IP address), for the log
+
+        Returns:
+            RuleEvaluation with the fired rules in file order
+        """
+        from src.metrics import RULE_EVALUATIONS, RULE_MATCHES
+
+        evaluation = RuleEvaluation()
+        for rule in self.rules:
+            try:
+                fired = rule.expression(context)
+            except Exception as e:
+                logger.error(f"Rule '{rule.rule_id}' failed: {type(e).__name__}: {str(e)}")
+                continue
+            if not fired:
+                continue
+            RULE_MATCHES.labels(rule=rule.rule_id, action=rule.action, state=rule.state).inc()
+            match = RuleMatch(rule.rule_id, rule.action)
+            if rule.state == RuleState.SHADOW:
+                evaluation.shadow_matches.append(match)
+            else:
+                evaluation.matches.append(match)
+        RULE_EVALUATIONS.inc()
+        if evaluation.shadow_matches:
+            logger.info(
+                f"Shadow rules {', '.join(m.rule_id for m in evaluation.shadow_matches)} matched"
+                f"{f' {subject}' if subject else ''}: decision would be {evaluation.shadow_decision}"
+                f" (enforced: {evaluation.decision})"
+            )
+        return evaluation
+
+
+# Global rule engine (built lazily from configuration)
+_rule_engine: Optional[RuleEngine] = None
+
+
+def get_rule_engine() -> RuleEngine:
+    """Get the global rule engine."""
+    global _rule_engine
+    if _rule_engine is None:
+        from src.config import get_config
+
+        config = get_config()
+        _rule_engine = RuleEngine(config.rules_path, config.rules_reload_interval_seconds)
+    return _rule_engine
@@ -0,0 +1,441 @@
+"""Sanctioned-country (embargo) screening of client addresses.
+
+The client address is geolocated with the GeoIP dataset and matched
+against SANCTIONS_COUNTRIES: ISO 3166-1 country codes ("IR") and ISO
+3166-2 region codes ("UA-43" for Crimea), so that regional embargoes do
+not block a whole country. The default list covers the comprehensive
+OFAC programs (Cuba, Iran, North Korea, Syria and the Crimea, Donetsk and
+Luhansk regions of Ukraine); keep it in line with your own legal advice.
+
+SANCTIONS_MODE decides what a match does: `tag` lets the request through
+and marks it, `block` refuses it. Tenants may change the action for their
+own traffic and add or exempt codes. Every block is written to the
+sanctions audit trail. Addresses that cannot be located are allowed.
+"""
+
+import logging
+import uuid
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Any, Callable, Dict, FrozenSet, Iterable, List, Optional, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import SanctionsAuditRecord, TenantSanctionsPolicy
+from src.pagination import Page, keyset_page
+from src.services.ip_lookup_service import IpLookupService, get_ip_lookup_service
+
+logger = logging.getLogger(__name__)
+
+# OFAC comprehensive sanctions programs (countries and ISO 3166-2 regions)
+DEFAULT_SANCTIONED_CODES = "CU,IR,KP,SY,UA-43,UA-40,UA-14,UA-09"
+
+
+class SanctionsAction:
+    """What a sanctions match does."""
+    OFF = "off"
+    TAG = "tag"
+    BLOCK = "block"
+
+    ALL = (OFF, TAG, BLOCK)
+
+
+class PolicySource:
+    """Which policy decided."""
+    GLOBAL = "global"
+    TENANT = "tenant"
+
+
+def parse_codes(codes: Iterable[str]) -> FrozenSet[str]:
+    """Normalize country and region codes ("ir", " UA-43 ") to upper case.
+
+    Raises:
+        ValueError: If a code is neither a 2-letter country nor a CC-XXX region
+    """
+    normalized = set()
+    for code in codes:
+        code = code.strip().upper()
+        if not code:
+            continue
+        country, _, region = code.partition("-")
+        if len(country) != 2 or not country.isalpha() or (code.count("-") and not 1 <= len(region) <= 3):
+            raise ValueError(f"Invalid country or region code: {code!r}")
+        normalized.add(code)
+    return frozenset(normalized)
+
+
+@dataclass(frozen=True)
+class SanctionsPolicy:
+    """Action and sanctioned codes applied to a request."""
+    action: str
+    codes: FrozenSet[str]
+    source: str = PolicySource.GLOBAL
+
+    def match(self, country_code: Optional[str], region_codes: Iterable[str] = ()) -> Optional[str]:
+        """Sanctioned code covering a location, the most specific first."""
+        for code in region_codes:
+            if code in self.codes:
+                return code
+        if country_code and country_code.upper() in self.codes:
+            return country_code.upper()
+        return None
+
+    def for_tenant(self, row: Optional[TenantSanctionsPolicy]) -> "SanctionsPolicy":
+        """This policy with a tenant's adjustments applied."""
+        if row is None:
+            return self
+        return SanctionsPolicy(
+            action=row.action or self.action,
+            codes=(self.codes | frozenset(row.extra_codes)) - frozenset(row.exempt_codes),
+            source=PolicySource.TENANT,
+        )
+
+
+@dataclass
+class SanctionsDecision:
+    """Outcome of screening one address."""
+    ip_address: str
+    action: str  # allow, tag or block
+    matched_code: Optional[str] = None
+    country_code: Optional[str] = None
+    region_code: Optional[str] = None
+    policy_source: str = PolicySource.GLOBAL
+    tenant_id: Optional[str] = None
+
+    @property
+    def blocked(self) -> bool:
+        return self.action == SanctionsAction.BLOCK
+
+    @property
+    def tagged(self) -> bool:
+        return self.action == SanctionsAction.TAG
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "ip_address": self.ip_address,
+            "action": self.action,
+            "matched_code": self.matched_code,
+            "country_code": self.country_code,
+            "region_code": self.region_code,
+            "policy_source": self.policy_source,
+        }
+
+
+ALLOW = "allow"
+
+
+@dataclass
+class AuditRecord:
+    """Stored block, detached from the database session."""
+    record_id: str
+    ip_address: str
+    matched_code: str
+    action: str
+    policy_source: str
+    created_at: datetime  # UTC
+    country_code: Optional[str] = None
+    region_code: Optional[str] = None
+    method: Optional[str] = None
+    path: Optional[str] = None
+    user_agent: Optional[str] = None
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "record_id": self.record_id,
+            "ip_address": self.ip_address,
+            "country_code": self.country_code,
+            "region_code": self.region_code,
+            "matched_code": self.matched_code,
+            "action": self.action,
+            "policy_source": self.policy_source,
+            "method": self.method,
+            "path": self.path,
+            "user_agent": self.user_agent,
+            "created_at": self.created_at.replace(tzinfo=timezone.utc),
+        }
+
+
+def _to_record(row: SanctionsAuditRecord) -> AuditRecord:
+    return AuditRecord(
+        record_id=row.record_id,
+        ip_address=row.ip_address,
+        matched_code=row.matched_code,
+        action=row.action,
+        policy_source=row.policy_source,
+        created_at=row.created_at,
+        country_code=row.country_code,
+        region_code=row.region_code,
+        method=row.method,
+        path=row.path,
+        user_agent=row.user_agent,
+    )
+
+
+class SanctionsScreener:
+    """Screens client addresses against the sanctions policy."""
+
+    def __init__(
+        self,
+        policy: SanctionsPolicy,
+        lookup_service: Callable[[], Optional[IpLookupService]] = get_ip_lookup_service,
+    ):
+        """Initialize screener.
+
+        Args:
+            policy: Global policy (SANCTIONS_MODE and SANCTIONS_COUNTRIES)
+            lookup_service: Provider of the GeoIP lookup service
+        """
+        self.policy = policy
+        self.lookup_service = lookup_service
+
+    def locate(self, ip: str) -> Tuple[Optional[str], List[str]]:
+        """Country and ISO 3166-2 region codes of an address (None, [] if unknown)."""
+        service = self.lookup_service()
+        if service is None:
+            return None, []
+        try:
+            record = service.lookup(ip).record
+        except ValueError:
+            return None, []
+        if record is None or not record.country_iso_code:
+            return None, []
+        country = record.country_iso_code.upper()
+        regions = [
+            f"{country}-{sub['iso_code']}".upper()
+            for sub in reversed(record.subdivisions)
+            if sub.get("iso_code")
+        ]
+        return country, regions
+
+    def policy_for(self, tenant_id: Optional[str], session: Optional[Session]) -> SanctionsPolicy:
+        """Policy applied to a tenant's requests."""
+        if tenant_id is None or session is None:
+            return self.policy
+        row = (
+            session.query(TenantSanctionsPolicy)
+            .filter(TenantSanctionsPolicy.tenant_id == tenant_id)
+            .first()
+        )
+        return self.policy.for_tenant(row)
+
+    def screen(
+        self, ip: str, tenant_id: Optional[str] = None, session: Optional[Session] = None
+    ) -> SanctionsDecision:
+        """Screen a client address.
+
+        Args:
+            ip: Client IP address
+            tenant_id: Calling tenant, if known
+            session: Database session for tenant policies (global policy without)
+
+        Returns:
+            SanctionsDecision (action allow when nothing matched or the
+            address cannot be located)
+        """
+        from src.metrics import SANCTIONS_DECISIONS
+
+        policy = self.policy_for(tenant_id, session)
+        country, regions = self.locate(ip) if policy.action != SanctionsAction.OFF else (None, [])
+        matched = policy.match(country, regions)
+        action = policy.action if matched is not None and policy.action != SanctionsAction.OFF else ALLOW
+        decision = SanctionsDecision(
+            ip_address=ip,
+            action=action,
+            matched_code=matched,
+            country_code=country,
+            region_code=regions[0] if regions else None,
+            policy_source=policy.source,
+            tenant_id=tenant_id,
+        )
+        if action != ALLOW:
+            SANCTIONS_DECISIONS.labels(action=action).inc()
+        return decision
+
+
+class SanctionsService:
+    """Tenant sanctions policies and the audit trail of blocks."""
+
+    def __init__(self, session: Session):
+        """Initialize sanctions service.
+
+        Args:
+            session: SQLAlchemy database session
+        """
+        self.session = session
+
+    def get_policy(self, tenant_id: str) -> Optional[TenantSanctionsPolicy]:
+        """A tenant's policy adjustments, if any."""
+        return (
+            self.session.query(TenantSanctionsPolicy)
+            .filter(TenantSanctionsPolicy.tenant_id == tenant_id)
+            .first()
+        )
+
+    def set_policy(
+        self,
+        tenant_id: str,
+        action: Optional[str] = None,
+        extra_codes: Iterable[str] = (),
+        exempt_codes: Iterable[str] = (),
+    ) -> TenantSanctionsPolicy:
+        """Create or replace a tenant's policy adjustments.
+
+        Args:
+            tenant_id: Owning tenant
+            action: off, tag or block (None keeps SANCTIONS_MODE)
+            extra_codes: Countries/regions sanctioned in addition to the global list
+            exempt_codes: Global countries/regions not sanctioned for the tenant
+
+        Returns:
+            Stored TenantSanctionsPolicy
+
+        Raises:
+            ValueError: If the action or a code is invalid, or storage fails
+        """
+        if action is not None and action not in SanctionsAction.ALL:
+            raise ValueError(f"Invalid action {action!r}: expected one of {', '.join(SanctionsAction.ALL)}")
+        extra = sorted(parse_codes(extra_codes))
+        exempt = sorted(parse_codes(exempt_codes))
+
+        row = self.get_policy(tenant_id)
+        if row is None:
+            row = TenantSanctionsPolicy(tenant_id=tenant_id)
+            self.session.add(row)
+        row.action, row.extra_codes, row.exempt_codes = action, extra, exempt
+        try:
+            self.session.commit()
+            self.session.refresh(row)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store sanctions policy: {str(e)}")
+        logger.info(f"Sanctions policy of tenant {tenant_id}: action={action} +{extra} -{exempt}")
+        return row
+
+    def delete_policy(self, tenant_id: str) -> bool:
+        """Revert a tenant to the global policy.
+
+        Returns:
+            True if the tenant had adjustments
+        """
+        row = self.get_policy(tenant_id)
+        if row is None:
+            return False
+        self.session.delete(row)
+        self.session.commit()
+        return True
+
+    def record_block(
+        self,
+        decision: SanctionsDecision,
+        method: Optional[str] = None,
+        path: Optional[str] = None,
+        user_agent: Optional[str] = None,
+    ) -> AuditRecord:
+        """Write a blocked request to the audit trail.
+
+        Raises:
+            ValueError: If the record cannot be stored
+        """
+        row = SanctionsAuditRecord(
+            record_id=str(uuid.uuid4()),
+            tenant_id=decision.tenant_id,
+            ip_address=decision.ip_address,
+            country_code=decision.country_code,
+            region_code=decision.region_code,
+            matched_code=decision.matched_code,
+            action=decision.action,
+            policy_source=decision.policy_source,
+            method=method,
+            path=(path or "")[:2048] or None,
+            user_agent=(user_agent or "")[:512] or None,
+            created_at=datetime.utcnow(),
+        )
+        try:
+            self.session.add(row)
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store sanctions audit record: {str(e)}")
+        logger.warning(
+            f"Blocked {method} {path} from {decision.ip_address} "
+            f"({decision.matched_code}, {decision.policy_source} policy)"
+        )
+        return _to_record(row)
+
+    def audit_records(
+        self, tenant_id: Optional[str], since: Optional[datetime] = None, limit: int = 100
+    ) -> List[AuditRecord]:
+        """Blocks of a tenant's requests, newest first.
+
+        Args:
+            tenant_id: Owning tenant (None for anonymous requests)
+            since: Only blocks at or after this time
+            limit: Most records returned
+
+        Returns:
+            list of AuditRecord
+        """
+        return self.audit_page(tenant_id, since, limit).items
+
+    def audit_page(
+        self,
+        tenant_id: Optional[str],
+        since: Optional[datetime] = None,
+        limit: int = 100,
+        cursor: Optional[str] = None,
+    ) -> Page[AuditRecord]:
+        """One page of the blocks of a tenant's requests, newest first.
+
+        Args:
+            tenant_id: Owning tenant (None for anonymous requests)
+            since: Only blocks at or after this time
+            limit: Most records on the page
+            cursor: next_cursor of the previous page
+
+        Raises:
+            ValueError: If the cursor is invalid
+        """
+        query = self.session.query(SanctionsAuditRecord).filter(SanctionsAuditRecord.tenant_id == tenant_id)
+        if since is not None:
+            if since.tzinfo is not None:
+                since = since.astimezone(timezone.utc).replace(tzinfo=None)
+            query = query.filter(SanctionsAuditRecord.created_at >= since)
+        return keyset_page(
+            query, SanctionsAuditRecord.created_at, SanctionsAuditRecord.id, limit, cursor, _to_record
+        )
+
+
+def build_sanctions_screener(config) -> SanctionsScreener:
+    """Create the screener from SANCTIONS_MODE and SANCTIONS_COUNTRIES.
+
+    Raises:
+        ValueError: If the mode or a code is invalid
+    """
+    mode = config.sanctions_mode.strip().lower()
+    if mode not in SanctionsAction.ALL:
+        raise ValueError(f"Invalid SANCTIONS_MODE {mode!r}: expected one of {', '.join(SanctionsAction.ALL)}")
+    return SanctionsScreener(SanctionsPolicy(mode, parse_codes(config.sanctions_countries.split(","))))
+
+
+# Global screener (built lazily from configuration)
+_sanctions_screener: Optional[SanctionsScreener] = None
+
+
+def get_sanctions_screener() -> SanctionsScreener:
+    """Get the global sanctions screener."""
+    global _sanctions_screener
+    if _sanctions_screener is None:
+        from src.config import get_config
+
+        _sanctions_screener = build_sanctions_screener(get_config())
+    return _sanctions_screener
+
+
+def screen_ip(ip: str, tenant_id: Optional[str] = None, session: Optional[Session] = None) -> SanctionsDecision:
+    """Screen a client address with the global policy (and the tenant's adjustments).
+
+    For code paths outside the HTTP middleware, such as queue consumers.
+    """
+    return get_sanctions_screener().screen(ip, tenant_id, session)
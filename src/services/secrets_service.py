@@ -0,0 +1,383 @@
+"""Secrets loaded from AWS Secrets Manager or HashiCorp Vault.
+
+With SECRETS_BACKEND=aws or vault, the settings in SECRET_SETTINGS are
+read from one secret holding a JSON object keyed by setting name, e.g.
+
+    {"JWT_SECRET_KEY": "...", "DATABASE_PASSWORD": "...", "MAXMIND_LICENSE_KEY": "..."}
+
+and take precedence over the environment variables of the same name
+(which remain the fallback for settings the secret leaves out). The
+secret is fetched once when first needed and then every
+SECRETS_REFRESH_INTERVAL_SECONDS by a background task; a failed refresh
+keeps the values already loaded. Each change bumps the store's
+generation, which the components holding secrets check to pick up
+rotated values without a restart: the token verifier is rebuilt, the
+database engine reconnects with the new credentials, the geocoding
+and GeoIP download providers use the new API keys, and new location data
+keys are wrapped with the new master key.
+
+Only the bootstrap settings (which backend, where, and how to reach it)
+come from the environment.
+"""
+
+import asyncio
+import hashlib
+import json
+import logging
+import threading
+import urllib.error
+import urllib.request
+from datetime import datetime, timezone
+from typing import Any, Callable, Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+# Settings a secret may provide: setting name -> Config attribute
+SECRET_SETTINGS = {
+    "JWT_SECRET_KEY": "jwt_secret_key",
+    "JWT_PREVIOUS_SECRET_KEY": "jwt_previous_secret_key",
+    "JWT_PUBLIC_KEY": "jwt_public_key",
+    "JWT_PREVIOUS_PUBLIC_KEY": "jwt_previous_public_key",
+    "JWT_PRIVATE_KEY": "jwt_private_key",
+    "DATABASE_URL": "database_url",
+    "DATABASE_USERNAME": "database_username",
+    "DATABASE_PASSWORD": "database_password",
+    "GOOGLE_GEOCODING_API_KEY": "google_geocoding_api_key",
+    "BILLING_STRIPE_API_KEY": "billing_stripe_api_key",
+    "SMTP_PASSWORD": "smtp_password",
+    "MAXMIND_ACCOUNT_ID": "maxmind_account_id",
+    "MAXMIND_LICENSE_KEY": "maxmind_license_key",
+    "LOCATION_ENCRYPTION_MASTER_KEY": "location_encryption_master_key",
+    "LOCATION_ENCRYPTION_PREVIOUS_MASTER_KEY": "location_encryption_previous_master_key",
+}
+
+
+def parse_secret(raw: Any, source: str) -> Dict[str, str]:
+    """Settings in a secret's payload.
+
+    Args:
+        raw: JSON text, or an already decoded object
+        source: Secret name, for messages
+
+    Returns:
+        dict of setting name -> value for the names in SECRET_SETTINGS;
+        other names are logged and skipped
+
+    Raises:
+        RuntimeError: If the payload is not a JSON object of strings
+    """
+    if isinstance(raw, (str, bytes)):
+        try:
+            raw = json.loads(raw)
+        except json.JSONDecodeError as e:
+            raise RuntimeError(f"Secret {source} is not JSON: {str(e)}")
+    if not isinstance(raw, dict):
+        raise RuntimeError(f"Secret {source} must be a JSON object of setting names")
+
+    values: Dict[str, str] = {}
+    for name, value in raw.items():
+        if name not in SECRET_SETTINGS:
+            logger.warning(f"Ignoring unknown setting {name} in secret {source}")
+            continue
+        if not isinstance(value, str):
+            raise RuntimeError(f"Setting {name} in secret {source} must be a string")
+        values[name] = value
+    return values
+
+
+class SecretsBackend:
+    """Base class for secret stores."""
+
+    name = ""
+
+    def fetch(self) -> Dict[str, str]:
+        """Read the current settings.
+
+        Raises:
+            RuntimeError: If the secret cannot be read or is malformed
+        """
+        raise NotImplementedError
+
+
+class AwsSecretsManagerBackend(SecretsBackend):
+    """A secret in AWS Secrets Manager (its current version, SecretString)."""
+
+    name = "aws"
+
+    def __init__(self, secret_id: str, region_name: str = "", client=None):
+        """Initialize AWS backend.
+
+        Args:
+            secret_id: Secret name or ARN
+            region_name: AWS region (empty: the SDK's default)
+            client: Optional boto3 Secrets Manager client (created on demand)
+        """
+        self.secret_id = secret_id
+        self.region_name = region_name
+        self._client = client
+
+    def _get_client(self):
+        if self._client is None:
+            try:
+                import boto3
+            except ImportError:
+                raise RuntimeError("boto3 is required for the AWS secrets backend")
+            self._client = boto3.client("secretsmanager", region_name=self.region_name or None)
+        return self._client
+
+    def fetch(self) -> Dict[str, str]:
+        """Read the secret's current version."""
+        try:
+            response = self._get_client().get_secret_value(SecretId=self.secret_id)
+        except RuntimeError:
+            raise
+        except Exception as e:
+            raise RuntimeError(f"Cannot read secret {self.secret_id}: {str(e)}")
+        if "SecretString" not in response:
+            raise RuntimeError(f"Secret {self.secret_id} has no SecretString")
+        return parse_secret(response["SecretString"], self.secret_id)
+
+
+def _http_get_json(url: str, headers: Dict[str, str], timeout_seconds: float) -> Any:
+    request = urllib.request.Request(url, headers=headers)
+    try:
+        with urllib.request.urlopen(request, timeout=timeout_seconds) as response:
+            return json.loads(response.read())
+    except urllib.error.HTTPError as e:
+        raise RuntimeError(f"HTTP {e.code}")
+    except (urllib.error.URLError, OSError, ValueError) as e:
+        raise RuntimeError(str(e))
+
+
+class VaultBackend(SecretsBackend):
+    """A secret in a HashiCorp Vault KV version 2 engine."""
+
+    name = "vault"
+
+    def __init__(
+        self,
+        address: str,
+        path: str,
+        token: str = "",
+        token_file: str = "",
+        mount: str = "secret",
+        namespace: str = "",
+        timeout_seconds: float = 10.0,
+        fetch: Callable[[str, Dict[str, str], float], Any] = _http_get_json,
+    ):
+        """Initialize Vault backend.
+
+        Args:
+            address: Vault address, e.g. https://vault.internal:8200
+            path: Secret path within the engine
+            token: Vault token
+            token_file: File holding the token, re-read at every fetch (as
+                kept fresh by Vault Agent); used when token is empty
+            mount: Mount path of the KV engine
+            namespace: Vault Enterprise namespace (empty: none)
+            timeout_seconds: Request timeout
+            fetch: GET returning decoded JSON (injectable for testing)
+        """
+        self.address = address.rstrip("/")
+        self.path = path.strip("/")
+        self.token = token
+        self.token_file = token_file
+        self.mount = mount.strip("/")
+        self.namespace = namespace
+        self.timeout_seconds = timeout_seconds
+        self._fetch = fetch
+
+    def _token(self) -> str:
+        if self.token or not self.token_file:
+            return self.token
+        try:
+            with open(self.token_file) as f:
+                return f.read().strip()
+        except OSError as e:
+            raise RuntimeError(f"Cannot read Vault token file: {str(e)}")
+
+    def fetch(self) -> Dict[str, str]:
+        """Read the secret's latest version."""
+        headers = {"X-Vault-Token": self._token()}
+        if self.namespace:
+            headers["X-Vault-Namespace"] = self.namespace
+        url = f"{self.address}/v1/{self.mount}/data/{self.path}"
+        try:
+            body = self._fetch(url, headers, self.timeout_seconds)
+        except RuntimeError as e:
+            raise RuntimeError(f"Cannot read Vault secret {self.mount}/{self.path}: {str(e)}")
+        data = body.get("data") if isinstance(body, dict) else None
+        if not isinstance(data, dict) or "data" not in data:
+            raise RuntimeError(f"Unexpected Vault response for {self.mount}/{self.path}")
+        return parse_secret(data["data"], f"{self.mount}/{self.path}")
+
+
+class SecretStore:
+    """Holds the latest settings read from a secrets backend."""
+
+    def __init__(self, backend: SecretsBackend, refresh_interval_seconds: float = 300.0):
+        """Initialize secret store.
+
+        Args:
+            backend: Where the secret lives
+            refresh_interval_seconds: Time between background refreshes
+        """
+        self.backend = backend
+        self.refresh_interval_seconds = refresh_interval_seconds
+        self._values: Dict[str, str] = {}
+        self._lock = threading.Lock()
+        self._running = False
+        self.generation = 0  # Bumped whenever the values change
+        self.version: Optional[str] = None  # Hash of the current values
+        self.loaded_at: Optional[datetime] = None
+        self.last_error: Optional[str] = None  # Why the latest refresh failed
+
+    @property
+    def values(self) -> Dict[str, str]:
+        """Current settings (a copy)."""
+        with self._lock:
+            return dict(self._values)
+
+    def refresh(self) -> bool:
+        """Read the secret now.
+
+        Returns:
+            True if the values changed; False if they are unchanged or the
+            read failed (the previous values stay in use)
+        """
+        try:
+            values = self.backend.fetch()
+        except RuntimeError as e:
+            self.last_error = str(e)
+            logger.error(f"Secrets refresh from {self.backend.name} failed, keeping current values: {e}")
+            return False
+        version = hashlib.sha256(json.dumps(values, sort_keys=True).encode()).hexdigest()[:12]
+        self.last_error = None
+        self.loaded_at = datetime.now(timezone.utc)
+        with self._lock:
+            if version == self.version:
+                return False
+            changed = sorted(
+                name for name in set(values) | set(self._values) if values.get(name) != self._values.get(name)
+            )
+            self._values = values
+            self.version = version
+            self.generation += 1
+        logger.info(f"Loaded secrets from {self.backend.name} (version {version}; changed: {', '.join(changed)})")
+        return True
+
+    async def start(self) -> None:
+        """Refresh periodically until stopped."""
+        self._running = True
+        logger.info(f"Starting secrets refresh from {self.backend.name} (interval: {self.refresh_interval_seconds}s)")
+        try:
+            while self._running:
+                await asyncio.sleep(self.refresh_interval_seconds)
+                await asyncio.to_thread(self.refresh)
+        except asyncio.CancelledError:
+            logger.info("Secrets refresh stopped")
+            self._running = False
+
+    def stop(self) -> None:
+        """Stop refreshing after the current iteration."""
+        self._running = False
+
+
+def build_secrets_backend(config) -> Optional[SecretsBackend]:
+    """Create the backend selected by SECRETS_BACKEND.
+
+    Args:
+        config: Application configuration (only its bootstrap settings are used)
+
+    Returns:
+        SecretsBackend, or None if none is configured
+
+    Raises:
+        ValueError: If the backend is unknown or its settings are missing
+    """
+    kind = config.secrets_backend
+    if not kind:
+        return None
+    if kind == AwsSecretsManagerBackend.name:
+        if not config.secrets_aws_secret_id:
+            raise ValueError("SECRETS_BACKEND=aws requires SECRETS_AWS_SECRET_ID")
+        return AwsSecretsManagerBackend(config.secrets_aws_secret_id, config.secrets_aws_region)
+    if kind == VaultBackend.name:
+        if not (config.vault_addr and config.vault_secret_path):
+            raise ValueError("SECRETS_BACKEND=vault requires VAULT_ADDR and VAULT_SECRET_PATH")
+        if not (config.vault_token or config.vault_token_file):
+            raise ValueError("SECRETS_BACKEND=vault requires VAULT_TOKEN or VAULT_TOKEN_FILE")
+        return VaultBackend(
+            config.vault_addr,
+            config.vault_secret_path,
+            token=config.vault_token,
+            token_file=config.vault_token_file,
+            mount=config.vault_kv_mount,
+            namespace=config.vault_namespace,
+        )
+    raise ValueError(f"Unknown SECRETS_BACKEND {kind!r} (expected aws or vault)")
+
+
+# Global secret store (built lazily from the bootstrap configuration)
+_secret_store: Optional[SecretStore] = None
+_secret_store_lock = threading.Lock()
+
+
+def get_secret_store(config=None) -> Optional[SecretStore]:
+    """Get the global secret store, loading the secret the first time.
+
+    Args:
+        config: Bootstrap configuration, for the first call (default:
+            read from the environment)
+
+    Returns:
+        SecretStore, or None if SECRETS_BACKEND is not set
+
+    Raises:
+        ValueError: If SECRETS_BACKEND is misconfigured
+        RuntimeError: If the secret cannot be read the first time; starting
+            with the environment's values instead could mean running with
+            stale or placeholder credentials
+    """
+    global _secret_store
+    if _secret_store is not None:
+        return _secret_store
+    with _secret_store_lock:
+        if _secret_store is None:
+            if config is None:
+                from src.config import Config
+
+                config = Config(load_secrets=False)
+            backend = build_secrets_backend(config)
+            if backend is None:
+                return None
+            store = SecretStore(backend, config.secrets_refresh_interval_seconds)
+            store.refresh()
+            if store.version is None:
+                raise RuntimeError(f"Cannot load secrets from {backend.name}: {store.last_error}")
+            _secret_store = store
+    return _secret_store
+
+
+def secrets_generation() -> int:
+    """Generation of the loaded secrets (0 without a secrets backend).
+
+    Components holding secret values compare it with the generation they
+    were built at to pick up rotations.
+    """
+    store = _secret_store
+    return store.generation if store is not None else 0
+
+
+def apply_secrets(config) -> None:
+    """Overlay the loaded secrets on a configuration.
+
+    Args:
+        config: Config whose bootstrap settings are already read
+    """
+    store = get_secret_store(config)
+    if store is None:
+        return
+    for name, value in store.values.items():
+        setattr(config, SECRET_SETTINGS[name], value)
+
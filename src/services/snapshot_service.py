@@ -0,0 +1,197 @@
+"""Retention of past GeoIP dataset builds for historical (as-of) lookups.
+
+Each time the updater replaces the active dataset, the outgoing build is
+kept in GEOIP_SNAPSHOT_DIR as `geoip-<build_epoch>.mmdb`. A query "as of"
+an instant is answered by the newest build made at or before it, so an
+address is located the way the engine would have located it then.
+
+Snapshots older than the retention window are pruned, except the newest
+one before the window starts: it was still active at the window's start
+and answers queries right at the boundary.
+"""
+
+import logging
+import os
+import re
+import shutil
+import threading
+import time
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Dict, List, Optional
+
+from src.services.mmdb_service import MMDBReader
+
+logger = logging.getLogger(__name__)
+
+_SNAPSHOT_NAME = re.compile(r"^geoip-(\d+)\.mmdb$")
+
+
+@dataclass(frozen=True)
+class DatasetSnapshot:
+    """A retained dataset build."""
+    build_epoch: int
+    path: str
+
+    @property
+    def built_at(self) -> datetime:
+        """Build time as an aware UTC datetime."""
+        return datetime.fromtimestamp(self.build_epoch, tz=timezone.utc)
+
+
+class DatasetSnapshotStore:
+    """Directory of retained dataset builds, keyed by build time."""
+
+    def __init__(self, directory: str, retention_days: float = 365.0, use_mmap: bool = True):
+        """Initialize snapshot store.
+
+        Args:
+            directory: Snapshot directory (created if missing)
+            retention_days: How far back as-of queries must be answerable
+            use_mmap: Memory-map snapshot readers
+        """
+        self.directory = directory
+        self.retention_days = retention_days
+        self.use_mmap = use_mmap
+        self._readers: Dict[int, MMDBReader] = {}
+        self._lock = threading.Lock()
+        os.makedirs(directory, exist_ok=True)
+
+    def snapshots(self) -> List[DatasetSnapshot]:
+        """Retained snapshots, oldest first."""
+        snapshots = []
+        for name in os.listdir(self.directory):
+            match = _SNAPSHOT_NAME.match(name)
+            if match:
+                snapshots.append(DatasetSnapshot(
+                    build_epoch=int(match.group(1)),
+                    path=os.path.join(self.directory, name),
+                ))
+        return sorted(snapshots, key=lambda s: s.build_epoch)
+
+    def retain(self, database_path: str) -> DatasetSnapshot:
+        """Keep a copy of a dataset build, then prune expired snapshots.
+
+        The file is hard-linked when possible, so retaining the active
+        dataset before it is replaced costs no disk space until then.
+
+        Args:
+            database_path: .mmdb file to retain
+
+        Returns:
+            The retained snapshot
+
+        Raises:
+            OSError: If the file cannot be read or copied
+            ValueError: If the file is not a valid MMDB database
+        """
+        reader = MMDBReader(database_path, use_mmap=False)
+        build_epoch = reader.build_epoch
+        reader.close()
+
+        snapshot = DatasetSnapshot(
+            build_epoch=build_epoch,
+            path=os.path.join(self.directory, f"geoip-{build_epoch}.mmdb"),
+        )
+        if not os.path.exists(snapshot.path):
+            staging_path = snapshot.path + ".tmp"
+            try:
+                os.link(database_path, staging_path)
+            except OSError:
+                shutil.copyfile(database_path, staging_path)
+            os.replace(staging_path, snapshot.path)
+            logger.info(f"Retained GeoIP dataset snapshot {snapshot.path}")
+
+        self.prune()
+        return snapshot
+
+    def prune(self, now: Optional[float] = None) -> List[DatasetSnapshot]:
+        """Delete snapshots no longer needed for the retention window.
+
+        Args:
+            now: Current time (Unix seconds), for tests
+
+        Returns:
+            Deleted snapshots
+        """
+        now = time.time() if now is None else now
+        cutoff = now - self.retention_days * 86400
+        snapshots = self.snapshots()
+        expired = [s for s in snapshots if s.build_epoch < cutoff]
+        # The newest build before the cutoff was active at the cutoff
+        expired = expired[:-1]
+
+        for snapshot in expired:
+            with self._lock:
+                # Not closed: in-flight lookups may still hold the reader
+                self._readers.pop(snapshot.build_epoch, None)
+            try:
+                os.remove(snapshot.path)
+                logger.info(f"Pruned GeoIP dataset snapshot {snapshot.path}")
+            except OSError as e:
+                logger.warning(f"Could not prune {snapshot.path}: {e}")
+        return expired
+
+    def snapshot_for(self, as_of: datetime) -> DatasetSnapshot:
+        """The newest snapshot built at or before an instant.
+
+        Args:
+            as_of: Instant (naive values are taken as UTC)
+
+        Raises:
+            LookupError: If no retained snapshot is that old
+        """
+        if as_of.tzinfo is None:
+            as_of = as_of.replace(tzinfo=timezone.utc)
+        target = as_of.timestamp()
+        candidates = [s for s in self.snapshots() if s.build_epoch <= target]
+        if not candidates:
+            raise LookupError(
+                f"No GeoIP dataset snapshot retained for {as_of.isoformat()}"
+            )
+        return candidates[-1]
+
+    def reader_for(self, as_of: datetime) -> MMDBReader:
+        """Reader for the dataset build active at an instant.
+
+        Raises:
+            LookupError: If no retained snapshot is that old
+            OSError: If the snapshot cannot be opened
+            ValueError: If the snapshot is not a valid MMDB database
+        """
+        snapshot = self.snapshot_for(as_of)
+        with self._lock:
+            reader = self._readers.get(snapshot.build_epoch)
+            if reader is None:
+                reader = MMDBReader(snapshot.path, use_mmap=self.use_mmap)
+                self._readers[snapshot.build_epoch] = reader
+        return reader
+
+
+# Global snapshot store (None when GEOIP_SNAPSHOT_DIR is unset)
+_snapshot_store: Optional[DatasetSnapshotStore] = None
+_snapshot_store_loaded = False
+
+
+def get_snapshot_store() -> Optional[DatasetSnapshotStore]:
+    """Get the global dataset snapshot store.
+
+    Returns:
+        DatasetSnapshotStore, or None if snapshots are not configured
+    """
+    global _snapshot_store, _snapshot_store_loaded
+    if not _snapshot_store_loaded:
+        from src.config import get_config
+
+        config = get_config()
+        if config.geoip_snapshot_dir:
+            try:
+                _snapshot_store = DatasetSnapshotStore(
+                    config.geoip_snapshot_dir,
+                    retention_days=config.geoip_snapshot_retention_days,
+                    use_mmap=config.geoip_use_mmap,
+                )
+            except OSError as e:
+                logger.error(f"GeoIP dataset snapshots unavailable: {e}")
+        _snapshot_store_loaded = True
+    return _snapshot_store
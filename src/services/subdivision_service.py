@@ -0,0 +1,187 @@
+"""ISO 3166-2 subdivision hierarchy enrichment for IP lookups.
+
+GeoIP records carry at most a couple of subdivision codes (e.g. "ENG" for
+England). A subdivision dataset with each code's parent and type lets the
+full chain be resolved, from the top-level state or province down to the
+county, with ISO 3166-2 codes at every level.
+"""
+
+import csv
+import logging
+from dataclasses import dataclass
+from typing import Any, Dict, Iterable, List, Optional
+
+from src.services.enrichment_service import Enricher
+from src.services.ip_lookup_service import IpLookupResult
+
+logger = logging.getLogger(__name__)
+
+# Guard against parent cycles in hand-edited datasets
+_MAX_DEPTH = 8
+
+
+@dataclass(frozen=True)
+class Subdivision:
+    """One ISO 3166-2 subdivision."""
+    code: str                    # Full ISO 3166-2 code, e.g. "GB-ENG"
+    name: str
+    type: Optional[str] = None   # e.g. "state", "province", "county"
+    parent_code: Optional[str] = None
+
+
+def iso_code(country_code: str, code: str) -> str:
+    """Full ISO 3166-2 code for a country and a possibly bare subdivision code ("ENG")."""
+    code = code.strip().upper()
+    if "-" in code:
+        return code
+    return f"{country_code.strip().upper()}-{code}"
+
+
+class SubdivisionHierarchy:
+    """Parent links between ISO 3166-2 subdivisions."""
+
+    def __init__(self, subdivisions: Iterable[Subdivision] = ()):
+        """Initialize hierarchy.
+
+        Args:
+            subdivisions: Known subdivisions (later duplicates replace earlier ones)
+        """
+        self._by_code: Dict[str, Subdivision] = {s.code: s for s in subdivisions}
+
+    def __len__(self) -> int:
+        return len(self._by_code)
+
+    @classmethod
+    def from_csv(cls, path: str) -> "SubdivisionHierarchy":
+        """Load subdivisions from a CSV with code, name, type and parent columns.
+
+        Codes may be full ("GB-ENG") or bare ("ENG"); bare parent codes are
+        taken to be in the same country as the subdivision. Rows without a
+        code or name are skipped.
+
+        Raises:
+            OSError: If the file cannot be read
+            ValueError: If the file has no code or name column
+        """
+        subdivisions = []
+        with open(path, newline="", encoding="utf-8") as f:
+            reader = csv.DictReader(f)
+            if not {"code", "name"} <= set(reader.fieldnames or ()):
+                raise ValueError(f"{path}: expected code and name columns")
+            for row in reader:
+                code = (row.get("code") or "").strip()
+                name = (row.get("name") or "").strip()
+                if "-" not in code or not name:
+                    continue
+                code = code.upper()
+                parent = (row.get("parent") or "").strip()
+                subdivisions.append(Subdivision(
+                    code=code,
+                    name=name,
+                    type=(row.get("type") or "").strip().lower() or None,
+                    parent_code=iso_code(code.split("-", 1)[0], parent) if parent else None,
+                ))
+        return cls(subdivisions)
+
+    def get(self, code: str) -> Optional[Subdivision]:
+        """Subdivision by full ISO 3166-2 code."""
+        return self._by_code.get(code.upper())
+
+    def chain(self, code: str) -> List[Subdivision]:
+        """The subdivision and its ancestors, top level first.
+
+        Returns:
+            List of subdivisions (empty if the code is unknown)
+        """
+        chain: List[Subdivision] = []
+        current = self.get(code)
+        while current is not None and len(chain) < _MAX_DEPTH:
+            if current in chain:
+                logger.warning(f"Subdivision parent cycle at {current.code}")
+                break
+            chain.append(current)
+            current = self.get(current.parent_code) if current.parent_code else None
+        return list(reversed(chain))
+
+    def resolve(
+        self, country_code: Optional[str], subdivisions: List[Dict[str, Any]]
+    ) -> List[Subdivision]:
+        """Full hierarchy for a GeoIP record's subdivisions.
+
+        The deepest code found in the dataset supplies the chain; record
+        subdivisions the dataset does not know are kept with their record
+        name so no information is lost.
+
+        Args:
+            country_code: Record's ISO 3166-1 country code
+            subdivisions: Record subdivisions (iso_code, name), largest first
+
+        Returns:
+            Subdivisions, top level first
+        """
+        if not country_code:
+            return []
+        codes = [
+            (iso_code(country_code, sub["iso_code"]), sub.get("name"))
+            for sub in subdivisions
+            if sub.get("iso_code")
+        ]
+
+        chain: List[Subdivision] = []
+        for code, _ in reversed(codes):
+            chain = self.chain(code)
+            if chain:
+                break
+
+        known = {s.code for s in chain}
+        for code, name in codes:
+            if code not in known and name:
+                chain.append(Subdivision(code=code, name=name))
+        return chain
+
+
+class SubdivisionEnricher(Enricher):
+    """Adds the ISO 3166-2 administrative hierarchy to lookups."""
+
+    name = "hierarchy"
+
+    def __init__(self, hierarchy: SubdivisionHierarchy):
+        """Initialize hierarchy enricher.
+
+        Args:
+            hierarchy: Subdivision dataset
+        """
+        self.hierarchy = hierarchy
+
+    def enrich(self, result: IpLookupResult) -> Optional[List[Dict[str, Any]]]:
+        """Resolve the record's subdivisions to their full hierarchy."""
+        record = result.record
+        if record is None:
+            return None
+        chain = self.hierarchy.resolve(record.country_iso_code, record.subdivisions)
+        if not chain:
+            return None
+        return [
+            {"level": level, "iso_code": s.code, "name": s.name, "type": s.type}
+            for level, s in enumerate(chain, start=1)
+        ]
+
+
+def build_subdivision_enricher(config) -> Optional[SubdivisionEnricher]:
+    """Create the hierarchy enricher from SUBDIVISION_DATA_PATH.
+
+    Args:
+        config: Application configuration
+
+    Returns:
+        SubdivisionEnricher, or None if no dataset is configured or it cannot be read
+    """
+    if not config.subdivision_data_path:
+        return None
+    try:
+        hierarchy = SubdivisionHierarchy.from_csv(config.subdivision_data_path)
+    except (OSError, ValueError) as e:
+        logger.warning(f"Subdivision hierarchy disabled: {e}")
+        return None
+    logger.info(f"Loaded {len(hierarchy)} ISO 3166-2 subdivisions")
+    return SubdivisionEnricher(hierarchy)
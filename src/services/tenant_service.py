@@ -0,0 +1,436 @@
+"""Tenants and their API keys.
+
+Tenants are created through the admin API, which returns the tenant's
+first API key once; only a SHA-256 hash of each key is stored. A key is
+exchanged at POST /api/v1/auth/token for a short-lived bearer token
+naming the tenant, so the data-plane API keeps authenticating with JWTs
+only.
+
+Rotating a tenant's key issues a new one and lets the previous keys keep
+working for a grace period, so integrations can be redeployed with the
+new key without an outage. With a maximum key age, every key is due for
+rotation that long after it was issued and keeps working for the grace
+period after that, so rotations overlap even when they are scheduled.
+Revoking a key stops it at once.
+
+Each key carries scopes (e.g. `lookup:read geofence:write`) that the
+tokens it is exchanged for are granted, and optionally the networks it
+may be used from (see src.services.ip_allowlist_service); rotation keeps
+both unless new ones are given. A key can also be put in privacy mode
+(see src.services.privacy_service) and on a quota plan (see
+src.services.quota_service), which rotation keeps.
+
+Keys are recognised by their first characters (`prefix`, e.g. in a
+leaked-credential report) and record when they were last exchanged.
+"""
+
+import hashlib
+import logging
+import re
+import secrets
+import uuid
+from dataclasses import dataclass
+from datetime import datetime, timedelta
+from typing import Iterable, List, Optional, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import Tenant, TenantApiKey
+from src.services.auth_service import validate_scopes
+from src.services.ip_allowlist_service import check_address, parse_cidrs, stored_cidrs
+from src.services.privacy_service import validate_precision
+from src.services.quota_service import validate_plan
+
+logger = logging.getLogger(__name__)
+
+KEY_PREFIX = "gek_"
+_PREFIX_LENGTH = 12  # Characters of a key kept in clear to recognise it ("gek_" + 8)
+_TENANT_ID = re.compile(r"^[a-z0-9][a-z0-9_-]{0,63}$")
+# last_used_at is only written when older than this, not on every exchange
+_LAST_USED_RESOLUTION = timedelta(minutes=1)
+
+
+def hash_key(api_key: str) -> str:
+    """Hex SHA-256 of an API key, as stored."""
+    return hashlib.sha256(api_key.encode("utf-8")).hexdigest()
+
+
+def key_active(key: TenantApiKey, now: Optional[datetime] = None) -> bool:
+    """Whether a stored key is accepted: neither revoked nor past its grace period."""
+    now = now or datetime.utcnow()
+    return key.revoked_at is None and (key.expires_at is None or key.expires_at > now)
+
+
+def rotation_due(key: TenantApiKey, now: Optional[datetime] = None) -> bool:
+    """Whether an active key is past its scheduled rotation."""
+    now = now or datetime.utcnow()
+    return key_active(key, now) and key.rotate_after is not None and key.rotate_after <= now
+
+
+@dataclass
+class IssuedKey:
+    """A newly issued API key; the plaintext is never stored or shown again."""
+    key: TenantApiKey
+    api_key: str
+
+
+class TenantService:
+    """Creates tenants and issues, rotates and checks their API keys."""
+
+    def __init__(
+        self,
+        session: Session,
+        key_max_age: Optional[timedelta] = None,
+        key_grace: timedelta = timedelta(0),
+        default_scopes: Iterable[str] = (),
+    ):
+        """Initialize tenant service.
+
+        Args:
+            session: SQLAlchemy database session
+            key_max_age: Age at which new keys are due for rotation (None:
+                keys are never due)
+            key_grace: How long a key due for rotation keeps working
+            default_scopes: Scopes of a tenant's first key when none are given
+        """
+        self.session = session
+        self.key_max_age = key_max_age
+        self.key_grace = key_grace
+        self.default_scopes = tuple(default_scopes)
+
+    def create_tenant(
+        self,
+        tenant_id: str,
+        name: str,
+        scopes: Optional[Iterable[str]] = None,
+        allowed_cidrs: Iterable[str] = (),
+        privacy_precision: Optional[int] = None,
+        quota_plan: Optional[str] = None,
+    ) -> Tuple[Tenant, IssuedKey]:
+        """Create a tenant with its first API key.
+
+        Args:
+            tenant_id: Tenant identifier, as carried in bearer tokens
+                (lowercase letters, digits, - and _; at most 64 characters)
+            name: Display name
+            scopes: Scopes of the key (default: the service's default scopes)
+            allowed_cidrs: Networks the key may be used from (empty: any)
+            privacy_precision: Coordinate decimal places of the key's
+                privacy mode (None: off)
+            quota_plan: Quota plan of the key (None: not metered)
+
+        Returns:
+            (stored Tenant, its first API key)
+
+        Raises:
+            ValueError: If the ID, a scope, a network, the precision or the
+                plan is invalid, the ID is taken, or storage fails
+        """
+        if not _TENANT_ID.match(tenant_id):
+            raise ValueError(
+                f"Invalid tenant ID {tenant_id!r}: use up to 64 lowercase letters, digits, - and _"
+            )
+        if self.get_tenant(tenant_id) is not None:
+            raise ValueError(f"Tenant {tenant_id} already exists")
+
+        key_scopes = validate_scopes(self.default_scopes if scopes is None else scopes)
+        cidrs = parse_cidrs(allowed_cidrs)
+        precision = validate_precision(privacy_precision)
+        plan = validate_plan(quota_plan)
+        tenant = Tenant(tenant_id=tenant_id, name=name)
+        issued = self._new_key(tenant_id, key_scopes, cidrs, precision, plan)
+        try:
+            self.session.add(tenant)
+            self.session.add(issued.key)
+            self.session.commit()
+            self.session.refresh(tenant)
+            self.session.refresh(issued.key)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store tenant: {str(e)}")
+        logger.info(f"Created tenant {tenant_id} with API key {issued.key.key_id}")
+        return tenant, issued
+
+    def list_tenants(self) -> List[Tenant]:
+        """Every tenant, oldest first."""
+        return self.session.query(Tenant).order_by(Tenant.id).all()
+
+    def get_tenant(self, tenant_id: str) -> Optional[Tenant]:
+        """Fetch a tenant by ID."""
+        return self.session.query(Tenant).filter(Tenant.tenant_id == tenant_id).first()
+
+    def list_keys(self, tenant_id: str) -> List[TenantApiKey]:
+        """API keys of a tenant (revoked and expired ones included), oldest first."""
+        return (
+            self.session.query(TenantApiKey)
+            .filter(TenantApiKey.tenant_id == tenant_id)
+            .order_by(TenantApiKey.id)
+            .all()
+        )
+
+    def find_keys(
+        self, prefix: Optional[str] = None, due_only: bool = False, limit: int = 100
+    ) -> List[TenantApiKey]:
+        """API keys of every tenant, oldest first.
+
+        Args:
+            prefix: Leading characters of a key (at least the "gek_" prefix
+                and one more); a longer value matches on its first 12
+            due_only: Only active keys past their scheduled rotation
+            limit: Most keys returned
+
+        Raises:
+            ValueError: If the prefix is not that of an API key
+        """
+        query = self.session.query(TenantApiKey)
+        if prefix is not None:
+            if not prefix.startswith(KEY_PREFIX) or len(prefix) <= len(KEY_PREFIX):
+                raise ValueError(f"Key prefix must start with {KEY_PREFIX} and one more character")
+            prefix = prefix[:_PREFIX_LENGTH]
+            query = query.filter(TenantApiKey.prefix.startswith(prefix, autoescape=True))
+        if due_only:
+            now = datetime.utcnow()
+            query = query.filter(
+                TenantApiKey.revoked_at.is_(None),
+                TenantApiKey.rotate_after <= now,
+                (TenantApiKey.expires_at.is_(None)) | (TenantApiKey.expires_at > now),
+            )
+        return query.order_by(TenantApiKey.id).limit(limit).all()
+
+    def rotate_key(
+        self,
+        tenant_id: str,
+        grace_seconds: int,
+        scopes: Optional[Iterable[str]] = None,
+        allowed_cidrs: Optional[Iterable[str]] = None,
+    ) -> IssuedKey:
+        """Issue a new API key and retire the tenant's current ones.
+
+        Args:
+            tenant_id: Tenant identifier
+            grace_seconds: How long the previous keys keep working (0 revokes
+                them at once)
+            scopes: Scopes of the new key (default: those of the newest key)
+            allowed_cidrs: Networks the new key may be used from (default:
+                those of the newest key)
+
+        Returns:
+            The new API key
+
+        Raises:
+            LookupError: If the tenant does not exist
+            ValueError: If the grace period is negative, a scope or network
+                is invalid or storage fails
+        """
+        if grace_seconds < 0:
+            raise ValueError("Grace period must not be negative")
+        if self.get_tenant(tenant_id) is None:
+            raise LookupError(f"Tenant {tenant_id} not found")
+
+        keys = self.list_keys(tenant_id)
+        if scopes is None:
+            scopes = keys[-1].scopes.split() if keys else self.default_scopes
+        key_scopes = validate_scopes(scopes)
+        if allowed_cidrs is None:
+            allowed_cidrs = stored_cidrs(keys[-1].allowed_cidrs) if keys else ()
+        cidrs = parse_cidrs(allowed_cidrs)
+        precision = keys[-1].privacy_precision if keys else None
+        plan = keys[-1].quota_plan if keys else None
+        now = datetime.utcnow()
+        retire_at = now + timedelta(seconds=grace_seconds)
+        for key in keys:
+            if not key_active(key, now):
+                continue
+            key.rotate_after = None  # Rotated, no longer due
+            if grace_seconds == 0:
+                key.revoked_at = now
+            elif key.expires_at is None or key.expires_at > retire_at:
+                key.expires_at = retire_at
+        issued = self._new_key(tenant_id, key_scopes, cidrs, precision, plan)
+        try:
+            self.session.add(issued.key)
+            self.session.commit()
+            self.session.refresh(issued.key)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store API key: {str(e)}")
+        logger.info(f"Rotated API key of tenant {tenant_id} (new key {issued.key.key_id})")
+        return issued
+
+    def set_allowed_cidrs(self, tenant_id: str, key_id: str, allowed_cidrs: Iterable[str]) -> TenantApiKey:
+        """Replace the networks an API key may be used from.
+
+        Sessions already started keep the previous list in their current
+        access token until it is refreshed.
+
+        Args:
+            tenant_id: Tenant identifier
+            key_id: Key identifier
+            allowed_cidrs: Networks (empty: any address)
+
+        Raises:
+            LookupError: If the tenant has no such key
+            ValueError: If a network is invalid or storage fails
+        """
+        cidrs = parse_cidrs(allowed_cidrs)
+        key = self._get_key(tenant_id, key_id)
+        key.allowed_cidrs = " ".join(cidrs)
+        try:
+            self.session.commit()
+            self.session.refresh(key)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store API key: {str(e)}")
+        logger.info(f"API key {key_id} of tenant {tenant_id} allowed from {', '.join(cidrs) or 'any address'}")
+        return key
+
+    def set_privacy(self, tenant_id: str, key_id: str, precision: Optional[int]) -> TenantApiKey:
+        """Put an API key in privacy mode, or take it out.
+
+        Sessions already started keep the previous setting in their current
+        access token until it is refreshed.
+
+        Args:
+            tenant_id: Tenant identifier
+            key_id: Key identifier
+            precision: Decimal places returned coordinates are truncated to
+                (None: privacy mode off)
+
+        Raises:
+            LookupError: If the tenant has no such key
+            ValueError: If the precision is invalid or storage fails
+        """
+        precision = validate_precision(precision)
+        key = self._get_key(tenant_id, key_id)
+        key.privacy_precision = precision
+        try:
+            self.session.commit()
+            self.session.refresh(key)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store API key: {str(e)}")
+        if precision is None:
+            logger.info(f"API key {key_id} of tenant {tenant_id} out of privacy mode")
+        else:
+            logger.info(f"API key {key_id} of tenant {tenant_id} in privacy mode ({precision} decimal places)")
+        return key
+
+    def set_quota_plan(self, tenant_id: str, key_id: str, plan: Optional[str]) -> TenantApiKey:
+        """Put an API key on a quota plan, or stop metering it.
+
+        Sessions already started keep the previous plan in their current
+        access token until it is refreshed. Requests already counted this
+        month still count against the new plan.
+
+        Args:
+            tenant_id: Tenant identifier
+            key_id: Key identifier
+            plan: Plan name (None: not metered)
+
+        Raises:
+            LookupError: If the tenant has no such key
+            ValueError: If there is no such plan or storage fails
+        """
+        plan = validate_plan(plan)
+        key = self._get_key(tenant_id, key_id)
+        key.quota_plan = plan
+        try:
+            self.session.commit()
+            self.session.refresh(key)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store API key: {str(e)}")
+        logger.info(f"API key {key_id} of tenant {tenant_id} on quota plan {plan or '(none)'}")
+        return key
+
+    def revoke_key(self, tenant_id: str, key_id: str) -> TenantApiKey:
+        """Stop accepting an API key at once (revoking twice is a no-op).
+
+        Tokens already issued for the key are not affected here; end its
+        sessions with RefreshTokenService.revoke_api_key.
+
+        Raises:
+            LookupError: If the tenant has no such key
+            ValueError: If storage fails
+        """
+        key = self._get_key(tenant_id, key_id)
+        if key.revoked_at is None:
+            key.revoked_at = datetime.utcnow()
+            try:
+                self.session.commit()
+                self.session.refresh(key)
+            except Exception as e:
+                self.session.rollback()
+                raise ValueError(f"Failed to revoke API key: {str(e)}")
+            logger.info(f"Revoked API key {key_id} of tenant {tenant_id}")
+        return key
+
+    def authenticate(self, api_key: str, client_ip: Optional[str] = None) -> Optional[TenantApiKey]:
+        """The stored key matching an API key, if it is still usable.
+
+        Args:
+            api_key: Presented key
+            client_ip: Address the key is presented from, checked against the
+                key's networks before anything else is done with it
+
+        Returns:
+            TenantApiKey, or None for an unknown, revoked or expired key
+
+        Raises:
+            SourceNetworkError: If the key is restricted to other networks
+        """
+        if not api_key.startswith(KEY_PREFIX):
+            return None
+        key = (
+            self.session.query(TenantApiKey)
+            .filter(TenantApiKey.key_hash == hash_key(api_key))
+            .first()
+        )
+        if key is not None:
+            check_address(client_ip, stored_cidrs(key.allowed_cidrs))
+        now = datetime.utcnow()
+        if key is None or not key_active(key, now):
+            return None
+        if key.last_used_at is None or now - key.last_used_at >= _LAST_USED_RESOLUTION:
+            key.last_used_at = now
+            try:
+                self.session.commit()
+            except Exception as e:
+                # Losing a usage timestamp must not lock the tenant out
+                self.session.rollback()
+                logger.warning(f"Cannot record use of API key {key.key_id}: {str(e)}")
+        return key
+
+    def _get_key(self, tenant_id: str, key_id: str) -> TenantApiKey:
+        key = (
+            self.session.query(TenantApiKey)
+            .filter(TenantApiKey.tenant_id == tenant_id, TenantApiKey.key_id == key_id)
+            .first()
+        )
+        if key is None:
+            raise LookupError(f"API key {key_id} of tenant {tenant_id} not found")
+        return key
+
+    def _new_key(
+        self,
+        tenant_id: str,
+        scopes: Tuple[str, ...],
+        cidrs: Tuple[str, ...] = (),
+        privacy_precision: Optional[int] = None,
+        quota_plan: Optional[str] = None,
+    ) -> IssuedKey:
+        api_key = KEY_PREFIX + secrets.token_urlsafe(32)
+        key = TenantApiKey(
+            key_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            key_hash=hash_key(api_key),
+            prefix=api_key[:_PREFIX_LENGTH],
+            scopes=" ".join(scopes),
+            allowed_cidrs=" ".join(cidrs),
+            privacy_precision=privacy_precision,
+            quota_plan=quota_plan,
+        )
+        if self.key_max_age is not None:
+            key.rotate_after = datetime.utcnow() + self.key_max_age
+            key.expires_at = key.rotate_after + self.key_grace
+        return IssuedKey(key, api_key)
@@ -0,0 +1,144 @@
+"""Vector tiles of geofences and detection density for map dashboards.
+
+Each tile has up to two layers:
+
+- `geofences`: the polygons of the stored geofences crossing the tile,
+  with `geofence_id` and `name` attributes;
+- `detections`: stored detections counted on a grid of HEATMAP_GRID x
+  HEATMAP_GRID cells per tile, one point per non-empty cell at the mean
+  position of its detections, with a `count` attribute.
+
+Zooming in subdivides the grid, so density stays readable at every zoom.
+"""
+
+from collections import Counter, defaultdict
+from dataclasses import dataclass
+from datetime import datetime
+from typing import Dict, List, Optional, Sequence, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.services.geofence_service import GeofenceService
+from src.services.heatmap_service import HeatmapService
+from src.spatial import mvt
+from src.spatial.geometry import Polygon
+
+GEOFENCE_LAYER = "geofences"
+DETECTION_LAYER = "detections"
+TILE_LAYERS = (GEOFENCE_LAYER, DETECTION_LAYER)
+
+# Detection aggregation cells per tile side (64 tile units, 4 px of a 256 px tile)
+HEATMAP_GRID = 64
+
+
+@dataclass
+class Tile:
+    """Encoded tile and whether its detection layer hit the detection limit."""
+    data: bytes
+    truncated: bool = False
+
+
+class TileService:
+    """Renders Mapbox Vector Tiles from stored geofences and detections."""
+
+    def __init__(self, session: Session, max_detections: Optional[int] = None):
+        """Initialize tile service.
+
+        Args:
+            session: SQLAlchemy database session
+            max_detections: Detections counted per tile (default
+                HEATMAP_MAX_DETECTIONS); the most recent are kept
+        """
+        self.session = session
+        self.heatmaps = HeatmapService(session, max_detections=max_detections)
+
+    def render(
+        self,
+        z: int,
+        x: int,
+        y: int,
+        layers: Sequence[str] = TILE_LAYERS,
+        since: Optional[datetime] = None,
+        until: Optional[datetime] = None,
+        object_class: Optional[str] = None,
+    ) -> Tile:
+        """Encode tile z/x/y.
+
+        Args:
+            z: Zoom level
+            x: Tile column
+            y: Tile row (from the north)
+            layers: Layers to include
+            since: Only detections captured at or after this time
+            until: Only detections captured before this time
+            object_class: Only detections of this class
+
+        Returns:
+            Tile (empty data when nothing lies in the tile)
+
+        Raises:
+            ValueError: For a tile outside the grid or an unknown layer
+        """
+        mvt.validate_tile(z, x, y)
+        unknown = sorted(set(layers) - set(TILE_LAYERS))
+        if unknown:
+            raise ValueError(f"Unknown tile layers: {', '.join(unknown)}")
+
+        encoded: List[mvt.Layer] = []
+        truncated = False
+        if GEOFENCE_LAYER in layers:
+            encoded.append(self.geofence_layer(z, x, y))
+        if DETECTION_LAYER in layers:
+            layer, truncated = self.detection_layer(z, x, y, since, until, object_class)
+            encoded.append(layer)
+        return Tile(data=mvt.encode_tile(encoded), truncated=truncated)
+
+    def geofence_layer(self, z: int, x: int, y: int) -> mvt.Layer:
+        """Layer of the geofences crossing a tile."""
+        layer = mvt.Layer(GEOFENCE_LAYER)
+        fences = GeofenceService(self.session).geofences_in_bbox(mvt.tile_bbox(z, x, y, mvt.BUFFER))
+        for fence in fences:
+            geometry = fence.geometry
+            polygons = [geometry] if isinstance(geometry, Polygon) else geometry.polygons
+            layer.add_polygon(
+                [
+                    [[mvt.project(z, x, y, lon, lat) for lon, lat in ring] for ring in (p.exterior, *p.holes)]
+                    for p in polygons
+                ],
+                {"geofence_id": fence.geofence_id, "name": fence.name},
+            )
+        return layer
+
+    def detection_layer(
+        self,
+        z: int,
+        x: int,
+        y: int,
+        since: Optional[datetime] = None,
+        until: Optional[datetime] = None,
+        object_class: Optional[str] = None,
+    ) -> Tuple[mvt.Layer, bool]:
+        """Layer of detection counts per grid cell of a tile, and whether the detection limit was hit."""
+        points, truncated = self.heatmaps.recent_points(
+            since=since, until=until, object_class=object_class, bbox=mvt.tile_bbox(z, x, y)
+        )
+        cell_size = mvt.EXTENT / HEATMAP_GRID
+        counts: Counter = Counter()
+        sums: Dict[Tuple[int, int], Tuple[float, float]] = defaultdict(lambda: (0.0, 0.0))
+        for lat, lon in points:
+            px, py = mvt.project(z, x, y, lon, lat)
+            # Points on the tile's edge belong to the outer cells
+            cell = (_grid_index(px, cell_size), _grid_index(py, cell_size))
+            counts[cell] += 1
+            sums[cell] = (sums[cell][0] + px, sums[cell][1] + py)
+
+        layer = mvt.Layer(DETECTION_LAYER)
+        for cell in sorted(counts, key=lambda cell: (cell[1], cell[0])):
+            count = counts[cell]
+            mean = (sums[cell][0] / count, sums[cell][1] / count)
+            layer.add_point(tuple(min(max(value, 0), mvt.EXTENT - 1) for value in mean), {"count": count})
+        return layer, truncated
+
+
+def _grid_index(value: float, cell_size: float) -> int:
+    return min(max(int(value // cell_size), 0), HEATMAP_GRID - 1)
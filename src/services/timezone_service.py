@@ -0,0 +1,229 @@
+"""Timezone resolution from coordinates.
+
+Uses IANA timezone boundary polygons (e.g., the timezone-boundary-builder
+`combined.json` release, with a `tzid` property per feature). Coordinates
+outside every polygon (open ocean, or when no dataset is loaded) resolve
+to the nautical `Etc/GMT±N` zone for their longitude.
+"""
+
+import logging
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import List, Optional, Tuple
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+
+from src.spatial.geojson import read_polygon_features
+from src.spatial.geometry import Geometry
+from src.spatial.rtree import RTree
+
+logger = logging.getLogger(__name__)
+
+
+class TimezoneSource:
+    """How a timezone was determined."""
+    BOUNDARY = "boundary"
+    NAUTICAL = "nautical"
+
+
+@dataclass
+class TimezoneResult:
+    """IANA timezone and its UTC offset at a point in time."""
+    tzid: str
+    utc_offset_seconds: int
+    dst_offset_seconds: int
+    abbreviation: Optional[str]
+    source: str
+
+    @property
+    def utc_offset(self) -> str:
+        """Offset formatted as ±HH:MM."""
+        sign = "+" if self.utc_offset_seconds >= 0 else "-"
+        minutes = abs(self.utc_offset_seconds) // 60
+        return f"{sign}{minutes // 60:02d}:{minutes % 60:02d}"
+
+    @property
+    def is_dst(self) -> bool:
+        """True if daylight saving time is in effect."""
+        return self.dst_offset_seconds != 0
+
+
+def nautical_tzid(longitude: float) -> str:
+    """Nautical timezone for a longitude (15° bands centred on multiples of 15°).
+
+    `Etc/GMT` zone names use POSIX sign inversion: UTC+2 is `Etc/GMT-2`.
+    """
+    hours = max(-12, min(12, round(longitude / 15.0)))
+    if hours == 0:
+        return "Etc/GMT"
+    return f"Etc/GMT{-hours:+d}"
+
+
+def describe_timezone(
+    tzid: str, at: Optional[datetime] = None, source: str = TimezoneSource.BOUNDARY
+) -> TimezoneResult:
+    """Compute the UTC offset of an IANA timezone at an instant.
+
+    Args:
+        tzid: IANA timezone name
+        at: Instant (defaults to now; naive values are treated as UTC)
+        source: TimezoneSource value to report
+
+    Returns:
+        TimezoneResult
+
+    Raises:
+        ValueError: If the timezone is unknown
+    """
+    try:
+        zone = ZoneInfo(tzid)
+    except (ZoneInfoNotFoundError, ValueError):
+        raise ValueError(f"Unknown timezone: {tzid}")
+
+    if at is None:
+        at = datetime.now(timezone.utc)
+    elif at.tzinfo is None:
+        at = at.replace(tzinfo=timezone.utc)
+    local = at.astimezone(zone)
+    offset = local.utcoffset()
+    dst = local.dst()
+    return TimezoneResult(
+        tzid=tzid,
+        utc_offset_seconds=int(offset.total_seconds()) if offset else 0,
+        dst_offset_seconds=int(dst.total_seconds()) if dst else 0,
+        abbreviation=local.tzname(),
+        source=source,
+    )
+
+
+def is_known_tzid(tzid: str) -> bool:
+    """True if the installed tz database knows the timezone."""
+    try:
+        ZoneInfo(tzid)
+    except (ZoneInfoNotFoundError, ValueError):
+        return False
+    return True
+
+
+class TimezoneService:
+    """Resolves the IANA timezone of a coordinate."""
+
+    def __init__(self, zones: Optional[List[Tuple[str, Geometry]]] = None):
+        """Initialize timezone resolver.
+
+        Args:
+            zones: (tzid, geometry) pairs
+        """
+        zones = zones or []
+        self._index = RTree([(geometry.bbox, (tzid, geometry)) for tzid, geometry in zones])
+
+    @classmethod
+    def from_geojson(cls, path: str) -> "TimezoneService":
+        """Load timezone boundaries from a GeoJSON FeatureCollection.
+
+        Features whose tzid the installed tz database does not know (a
+        boundary release newer than tzdata) are dropped and logged; points
+        in them fall back to the nautical zone.
+
+        Args:
+            path: File with a `tzid` property per feature
+
+        Returns:
+            TimezoneService
+
+        Raises:
+            OSError: If the file cannot be read
+            ValueError: If the file is not a FeatureCollection
+        """
+        zones = []
+        unknown = set()
+        for properties, geometry in read_polygon_features(path):
+            tzid = properties.get("tzid")
+            if not tzid:
+                continue
+            if not is_known_tzid(tzid):
+                unknown.add(tzid)
+                continue
+            zones.append((tzid, geometry))
+        if unknown:
+            logger.warning(
+                f"Ignoring {len(unknown)} timezones unknown to the installed tz "
+                f"database: {', '.join(sorted(unknown))}"
+            )
+        return cls(zones)
+
+    @property
+    def zone_count(self) -> int:
+        """Number of loaded timezone polygons."""
+        return len(self._index)
+
+    def tzid_at(self, latitude: float, longitude: float) -> Tuple[str, str]:
+        """Find the timezone name for a coordinate.
+
+        Returns:
+            (tzid, TimezoneSource value)
+        """
+        matches = [
+            (tzid, geometry)
+            for tzid, geometry in self._index.query_point(longitude, latitude)
+            if geometry.contains(longitude, latitude)
+        ]
+        if matches:
+            # Overlapping polygons are rare (disputed areas); prefer the smaller
+            tzid, _ = min(matches, key=lambda match: match[1].area)
+            return tzid, TimezoneSource.BOUNDARY
+        return nautical_tzid(longitude), TimezoneSource.NAUTICAL
+
+    def lookup(
+        self, latitude: float, longitude: float, at: Optional[datetime] = None
+    ) -> TimezoneResult:
+        """Resolve the timezone and UTC offset for a coordinate.
+
+        Args:
+            latitude: Latitude in degrees (-90 to 90)
+            longitude: Longitude in degrees (-180 to 180)
+            at: Instant for the offset (defaults to now)
+
+        Returns:
+            TimezoneResult
+
+        Raises:
+            ValueError: If the coordinate is out of range
+            RuntimeError: If the resolved timezone is missing from the tz database
+        """
+        if not -90 <= latitude <= 90:
+            raise ValueError(f"Latitude out of range: {latitude}")
+        if not -180 <= longitude <= 180:
+            raise ValueError(f"Longitude out of range: {longitude}")
+
+        tzid, source = self.tzid_at(latitude, longitude)
+        try:
+            return describe_timezone(tzid, at, source)
+        except ValueError as e:
+            # Coordinates are valid, so this is a dataset/tzdata problem
+            raise RuntimeError(f"Timezone data unavailable: {str(e)}")
+
+
+# Global timezone service (loaded lazily from TIMEZONE_BOUNDARY_PATH)
+_timezone_service: Optional[TimezoneService] = None
+
+
+def get_timezone_service() -> TimezoneService:
+    """Get the global timezone service.
+
+    Returns:
+        TimezoneService (nautical zones only if the dataset is unavailable)
+    """
+    global _timezone_service
+    if _timezone_service is None:
+        from src.config import get_config
+
+        path = get_config().timezone_boundary_path
+        try:
+            _timezone_service = TimezoneService.from_geojson(path)
+            logger.info(
+                f"Loaded {_timezone_service.zone_count} timezone polygons from {path}"
+            )
+        except (OSError, ValueError) as e:
+            logger.warning(f"Timezone boundaries unavailable, using nautical zones: {e}")
+            _timezone_service = TimezoneService()
+    return _timezone_service
@@ -0,0 +1,122 @@
+"""Denylist of revoked bearer tokens.
+
+Access tokens are self-contained JWTs, so revoking one before it expires
+means remembering its ID (`jti` claim) until then; revoking a login
+session remembers the session ID (`sid` claim) carried by every access
+token refreshed within it. Entries expire with the tokens they cover, so
+the list stays as small as the set of revoked, still-unexpired tokens.
+
+With TOKEN_DENYLIST_REDIS_URL the list is shared by all workers
+(`pip install -e ".[redis]"`); otherwise it is kept in process memory and
+a revocation only reaches the worker that handled it.
+"""
+
+import logging
+import threading
+import time
+from typing import Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+
+class TokenDenylist:
+    """Set of revoked token and session IDs with expiry."""
+
+    def revoke(self, kind: str, value: str, ttl_seconds: int) -> None:
+        """Deny an ID until the tokens carrying it have expired.
+
+        Args:
+            kind: ID namespace, "jti" or "sid"
+            value: Token or session ID
+            ttl_seconds: How long to remember it
+        """
+        raise NotImplementedError
+
+    def is_revoked(self, kind: str, value: str) -> bool:
+        """Whether an ID is denied."""
+        raise NotImplementedError
+
+
+class InMemoryTokenDenylist(TokenDenylist):
+    """Process-local denylist (not shared between workers)."""
+
+    def __init__(self, clock=time.monotonic):
+        self._entries: Dict[str, float] = {}
+        self._lock = threading.Lock()
+        self._clock = clock
+
+    def revoke(self, kind: str, value: str, ttl_seconds: int) -> None:
+        now = self._clock()
+        with self._lock:
+            for stale in [k for k, expires in self._entries.items() if expires <= now]:
+                del self._entries[stale]
+            self._entries[f"{kind}:{value}"] = now + max(1, ttl_seconds)
+
+    def is_revoked(self, kind: str, value: str) -> bool:
+        with self._lock:
+            expires = self._entries.get(f"{kind}:{value}")
+        return expires is not None and expires > self._clock()
+
+
+class RedisTokenDenylist(TokenDenylist):
+    """Redis keys with expiry, one per revoked ID."""
+
+    def __init__(self, client, key_prefix: str = "token-denylist"):
+        """Initialize Redis denylist.
+
+        Args:
+            client: redis.Redis client
+            key_prefix: Prefix of the Redis keys
+        """
+        self.client = client
+        self.key_prefix = key_prefix
+
+    @classmethod
+    def from_url(cls, url: str, key_prefix: str = "token-denylist") -> "RedisTokenDenylist":
+        """Connect to Redis.
+
+        Raises:
+            RuntimeError: If the redis package is not installed
+        """
+        try:
+            import redis
+        except ImportError:
+            raise RuntimeError("redis is required for TOKEN_DENYLIST_REDIS_URL")
+        return cls(redis.Redis.from_url(url), key_prefix)
+
+    def _key(self, kind: str, value: str) -> str:
+        return f"{self.key_prefix}:{kind}:{value}"
+
+    def revoke(self, kind: str, value: str, ttl_seconds: int) -> None:
+        self.client.set(self._key(kind, value), "1", ex=max(1, ttl_seconds))
+
+    def is_revoked(self, kind: str, value: str) -> bool:
+        return bool(self.client.exists(self._key(kind, value)))
+
+
+# Global denylist (store chosen lazily from TOKEN_DENYLIST_REDIS_URL)
+_token_denylist: Optional[TokenDenylist] = None
+
+
+def get_token_denylist() -> TokenDenylist:
+    """Get the global token denylist.
+
+    Returns:
+        TokenDenylist backed by Redis, or by memory if no URL is configured
+
+    Raises:
+        RuntimeError: If TOKEN_DENYLIST_REDIS_URL is set but redis is not installed
+    """
+    global _token_denylist
+    if _token_denylist is None:
+        from src.config import get_config
+
+        config = get_config()
+        if config.token_denylist_redis_url:
+            _token_denylist = RedisTokenDenylist.from_url(
+                config.token_denylist_redis_url, config.token_denylist_key_prefix
+            )
+        else:
+            logger.info("TOKEN_DENYLIST_REDIS_URL not set; token revocations are per process")
+            _token_denylist = InMemoryTokenDenylist()
+    return _token_denylist
@@ -0,0 +1,212 @@
+"""Impossible travel detection from consecutive login locations.
+
+Each login is compared with the same user's previous login (by login
+time). The great-circle distance between them, less both locations'
+accuracy radii so that coarse IP geolocation does not raise false alarms,
+divided by the time between them gives the implied travel speed. A jump
+of at least TRAVEL_MIN_DISTANCE_KM whose implied speed exceeds
+TRAVEL_MAX_SPEED_KMH is flagged.
+"""
+
+import logging
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import LoginLocation
+from src.services.erasure_service import ErasureService
+from src.spatial.distance import haversine
+
+logger = logging.getLogger(__name__)
+
+
+@dataclass
+class TravelPoint:
+    """Location of one login."""
+    latitude: float
+    longitude: float
+    observed_at: datetime  # UTC
+    accuracy_km: Optional[float] = None
+    ip_address: Optional[str] = None
+    country_code: Optional[str] = None
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "latitude": self.latitude,
+            "longitude": self.longitude,
+            "timestamp": self.observed_at.replace(tzinfo=timezone.utc),
+            "accuracy_km": self.accuracy_km,
+            "ip_address": self.ip_address,
+            "country_code": self.country_code,
+        }
+
+
+@dataclass
+class TravelAssessment:
+    """Comparison of a login with the user's previous one."""
+    user_id: str
+    current: TravelPoint
+    previous: Optional[TravelPoint]  # None for a user's first login
+    distance_km: Optional[float]  # Great-circle distance between the two locations
+    elapsed_seconds: Optional[float]
+    speed_kmh: Optional[float]  # Implied speed after accuracy radii; None if no time elapsed
+    impossible: bool
+    max_speed_kmh: float
+
+    def to_dict(self) -> Dict[str, Any]:
+        """Representation used in API responses."""
+        return {
+            "user_id": self.user_id,
+            "impossible": self.impossible,
+            "distance_km": self.distance_km,
+            "elapsed_seconds": self.elapsed_seconds,
+            "speed_kmh": self.speed_kmh,
+            "max_speed_kmh": self.max_speed_kmh,
+            "current": self.current.to_dict(),
+            "previous": self.previous.to_dict() if self.previous is not None else None,
+        }
+
+
+def _utc(value: datetime) -> datetime:
+    """Naive UTC datetime (aware values are converted)."""
+    if value.tzinfo is not None:
+        value = value.astimezone(timezone.utc).replace(tzinfo=None)
+    return value
+
+
+def _to_point(login: LoginLocation) -> TravelPoint:
+    return TravelPoint(
+        latitude=login.latitude,
+        longitude=login.longitude,
+        observed_at=login.observed_at,
+        accuracy_km=login.accuracy_km,
+        ip_address=login.ip_address,
+        country_code=login.country_code,
+    )
+
+
+class TravelDetectionService:
+    """Records logins and flags physically impossible travel between them."""
+
+    def __init__(
+        self,
+        session: Session,
+        max_speed_kmh: Optional[float] = None,
+        min_distance_km: Optional[float] = None,
+    ):
+        """Initialize travel detection service.
+
+        Args:
+            session: SQLAlchemy database session
+            max_speed_kmh: Fastest plausible travel (default TRAVEL_MAX_SPEED_KMH)
+            min_distance_km: Shortest jump, after accuracy radii, that can be
+                flagged (default TRAVEL_MIN_DISTANCE_KM)
+        """
+        from src.config import get_config
+
+        config = get_config()
+        self.session = session
+        self.max_speed_kmh = max_speed_kmh if max_speed_kmh is not None else config.travel_max_speed_kmh
+        self.min_distance_km = (
+            min_distance_km if min_distance_km is not None else config.travel_min_distance_km
+        )
+
+    def assess(self, user_id: str, previous: Optional[TravelPoint], current: TravelPoint) -> TravelAssessment:
+        """Compare a login with a previous one without storing anything.
+
+        Raises:
+            ValueError: If a coordinate is out of range
+        """
+        if previous is None:
+            return TravelAssessment(
+                user_id, current, None, None, None, None, False, self.max_speed_kmh
+            )
+
+        distance_km = haversine(
+            previous.latitude, previous.longitude, current.latitude, current.longitude
+        ).meters / 1000.0
+        uncertainty_km = (previous.accuracy_km or 0.0) + (current.accuracy_km or 0.0)
+        travelled_km = max(0.0, distance_km - uncertainty_km)
+        elapsed = abs((_utc(current.observed_at) - _utc(previous.observed_at)).total_seconds())
+
+        speed_kmh = travelled_km / (elapsed / 3600.0) if elapsed > 0 else None
+        impossible = travelled_km >= self.min_distance_km and (
+            speed_kmh is None or speed_kmh > self.max_speed_kmh
+        )
+        return TravelAssessment(
+            user_id=user_id,
+            current=current,
+            previous=previous,
+            distance_km=round(distance_km, 1),
+            elapsed_seconds=elapsed,
+            speed_kmh=round(speed_kmh, 1) if speed_kmh is not None else None,
+            impossible=impossible,
+            max_speed_kmh=self.max_speed_kmh,
+        )
+
+    def evaluate(
+        self, user_id: str, login: TravelPoint, tenant_id: Optional[str] = None
+    ) -> TravelAssessment:
+        """Record a login and compare it with the user's previous one.
+
+        Logins may arrive out of order; each is compared with the latest
+        login recorded at or before its own time. Logins of erased users
+        are assessed but not recorded.
+
+        Args:
+            user_id: User identifier (scoped to the tenant)
+            login: Login location and time (naive times are UTC)
+            tenant_id: Calling tenant, if any
+
+        Returns:
+            TravelAssessment
+
+        Raises:
+            ValueError: If the user ID or coordinate is invalid, or the login cannot be stored
+        """
+        user_id = (user_id or "").strip()
+        if not user_id:
+            raise ValueError("user_id must not be empty")
+        if not -90 <= login.latitude <= 90 or not -180 <= login.longitude <= 180:
+            raise ValueError(f"Coordinate out of range: ({login.latitude}, {login.longitude})")
+        login.observed_at = _utc(login.observed_at)
+
+        previous = (
+            self.session.query(LoginLocation)
+            .filter(
+                LoginLocation.tenant_id == tenant_id,
+                LoginLocation.user_id == user_id,
+                LoginLocation.observed_at <= login.observed_at,
+            )
+            .order_by(LoginLocation.observed_at.desc(), LoginLocation.id.desc())
+            .first()
+        )
+        assessment = self.assess(user_id, _to_point(previous) if previous is not None else None, login)
+        if ErasureService(self.session).is_erased(tenant_id, user_id):
+            return assessment
+
+        try:
+            self.session.add(LoginLocation(
+                tenant_id=tenant_id,
+                user_id=user_id,
+                observed_at=login.observed_at,
+                latitude=login.latitude,
+                longitude=login.longitude,
+                accuracy_km=login.accuracy_km,
+                ip_address=login.ip_address,
+                country_code=login.country_code,
+            ))
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store login: {str(e)}")
+
+        if assessment.impossible:
+            logger.warning(
+                f"Impossible travel for user {user_id}: {assessment.distance_km} km in "
+                f"{assessment.elapsed_seconds:.0f} s"
+            )
+        return assessment
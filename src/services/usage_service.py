@@ -0,0 +1,212 @@
+"""Usage analytics of tenant API keys.
+
+Requests made with tokens issued for an API key are counted per key, UTC
+day, endpoint (method and route template) and response class. Counts are
+kept in memory and added to the `api_key_usage` table every
+USAGE_FLUSH_INTERVAL_SECONDS, so each request costs no database work and
+every worker's counts end up in the same rows.
+"""
+
+import asyncio
+import logging
+import threading
+from collections import Counter
+from dataclasses import dataclass, field
+from datetime import date, datetime, timedelta
+from typing import Callable, Dict, List, Optional, Tuple
+
+from sqlalchemy.exc import SQLAlchemyError
+from sqlalchemy.orm import Session
+
+from src.models.database_models import ApiKeyUsage, TenantApiKey
+
+logger = logging.getLogger(__name__)
+
+API_KEY_SUBJECT_PREFIX = "apikey:"  # sub claim of tokens issued for an API key
+
+# (tenant_id, key_id, day, endpoint, status_class)
+UsageBucket = Tuple[str, str, str, str, str]
+
+
+def status_class(status_code: int) -> str:
+    """Response class of a status code, e.g. "4xx"."""
+    return f"{min(max(status_code // 100, 1), 5)}xx"
+
+
+def api_key_of(subject: Optional[str]) -> Optional[str]:
+    """Key ID of a token subject, if the token was issued for an API key."""
+    if subject and subject.startswith(API_KEY_SUBJECT_PREFIX):
+        return subject[len(API_KEY_SUBJECT_PREFIX):] or None
+    return None
+
+
+class UsageRecorder:
+    """Counts requests in memory and adds them to the database periodically."""
+
+    def __init__(self, session_factory: Callable[[], Session], flush_interval_seconds: int = 60):
+        """Initialize recorder.
+
+        Args:
+            session_factory: Creates database sessions for flushing
+            flush_interval_seconds: Seconds between flushes
+        """
+        self.session_factory = session_factory
+        self.flush_interval_seconds = max(1, flush_interval_seconds)
+        self._counts: Counter = Counter()
+        self._lock = threading.Lock()
+        self._running = False
+
+    def record(
+        self,
+        tenant_id: str,
+        key_id: str,
+        endpoint: str,
+        status_code: int,
+        at: Optional[datetime] = None,
+    ) -> None:
+        """Count one request."""
+        day = (at or datetime.utcnow()).date().isoformat()
+        with self._lock:
+            self._counts[(tenant_id, key_id, day, endpoint[:255], status_class(status_code))] += 1
+
+    def _take(self) -> Dict[UsageBucket, int]:
+        with self._lock:
+            counts, self._counts = self._counts, Counter()
+        return dict(counts)
+
+    def _restore(self, counts: Dict[UsageBucket, int]) -> None:
+        with self._lock:
+            self._counts.update(counts)
+
+    def flush(self) -> int:
+        """Add the counts so far to the database.
+
+        Counts that cannot be stored are kept for the next flush.
+
+        Returns:
+            Buckets written
+        """
+        counts = self._take()
+        if not counts:
+            return 0
+        session = self.session_factory()
+        try:
+            for (tenant_id, key_id, day, endpoint, response_class), requests in counts.items():
+                updated = session.query(ApiKeyUsage).filter(
+                    ApiKeyUsage.tenant_id == tenant_id,
+                    ApiKeyUsage.key_id == key_id,
+                    ApiKeyUsage.day == day,
+                    ApiKeyUsage.endpoint == endpoint,
+                    ApiKeyUsage.status_class == response_class,
+                ).update({ApiKeyUsage.requests: ApiKeyUsage.requests + requests}, synchronize_session=False)
+                if not updated:
+                    session.add(ApiKeyUsage(
+                        tenant_id=tenant_id, key_id=key_id, day=day, endpoint=endpoint,
+                        status_class=response_class, requests=requests,
+                    ))
+            session.commit()
+        except SQLAlchemyError as e:
+            # Another worker may have created one of the rows meanwhile; retried next time
+            session.rollback()
+            self._restore(counts)
+            logger.error(f"Failed to store API key usage: {str(e)}")
+            return 0
+        finally:
+            session.close()
+        return len(counts)
+
+    async def start(self) -> None:
+        """Flush periodically until stopped."""
+        self._running = True
+        try:
+            while self._running:
+                await asyncio.sleep(self.flush_interval_seconds)
+                await asyncio.to_thread(self.flush)
+        except asyncio.CancelledError:
+            self._running = False
+
+    def stop(self) -> None:
+        """Stop flushing after the current iteration."""
+        self._running = False
+
+
+@dataclass
+class KeyUsage:
+    """Requests of one API key, broken down."""
+
+    key_id: str
+    prefix: Optional[str]
+    total: int = 0
+    by_endpoint: Dict[str, int] = field(default_factory=dict)
+    by_day: Dict[str, int] = field(default_factory=dict)
+    by_status_class: Dict[str, int] = field(default_factory=dict)
+
+    def add(self, row: ApiKeyUsage) -> None:
+        self.total += row.requests
+        for breakdown, name in (
+            (self.by_endpoint, row.endpoint),
+            (self.by_day, row.day),
+            (self.by_status_class, row.status_class),
+        ):
+            breakdown[name] = breakdown.get(name, 0) + row.requests
+
+
+class UsageService:
+    """Reads the usage of a tenant's API keys."""
+
+    def __init__(self, session: Session):
+        self.session = session
+
+    def breakdown(
+        self, tenant_id: str, since: date, until: date, key_id: Optional[str] = None
+    ) -> List[KeyUsage]:
+        """Usage of a tenant's keys from since to until (inclusive), busiest key first.
+
+        Raises:
+            ValueError: If until is before since
+        """
+        if until < since:
+            raise ValueError("until must not be before since")
+        query = self.session.query(ApiKeyUsage).filter(
+            ApiKeyUsage.tenant_id == tenant_id,
+            ApiKeyUsage.day >= since.isoformat(),
+            ApiKeyUsage.day <= until.isoformat(),
+        )
+        if key_id is not None:
+            query = query.filter(ApiKeyUsage.key_id == key_id)
+        prefixes = {
+            key.key_id: key.prefix
+            for key in self.session.query(TenantApiKey).filter(TenantApiKey.tenant_id == tenant_id)
+        }
+        usage: Dict[str, KeyUsage] = {}
+        for row in query.order_by(ApiKeyUsage.day, ApiKeyUsage.endpoint):
+            if row.key_id not in usage:
+                usage[row.key_id] = KeyUsage(row.key_id, prefixes.get(row.key_id))
+            usage[row.key_id].add(row)
+        return sorted(usage.values(), key=lambda u: (-u.total, u.key_id))
+
+
+def default_range(until: Optional[date] = None, days: int = 30) -> Tuple[date, date]:
+    """The last days up to and including until (default: today, UTC)."""
+    until = until or datetime.utcnow().date()
+    return until - timedelta(days=days - 1), until
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+# Global recorder (created lazily from configuration)
+_usage_recorder: Optional[UsageRecorder] = None
+
+
+def get_usage_recorder() -> UsageRecorder:
+    """Get the global usage recorder."""
+    global _usage_recorder
+    if _usage_recorder is None:
+        from src.config import get_config
+
+        _usage_recorder = UsageRecorder(_default_session, get_config().usage_flush_interval_seconds)
+    return _usage_recorder
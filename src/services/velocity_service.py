@@ -0,0 +1,305 @@
+"""Per-entity velocity counters over sliding windows.
+
+Tracks how many distinct countries and cities an account or device has
+appeared from in each configured window (VELOCITY_WINDOWS, e.g. "15m,1h,24h").
+Every observation stores the location with its last-seen time in a
+sorted set per entity and dimension, so the distinct count in a window is
+the number of members seen since its start. With VELOCITY_REDIS_URL the
+sets live in Redis and are shared by all workers (`pip install -e ".[redis]"`);
+otherwise they are kept in process memory.
+
+Risk scoring reads the counts with `VelocityTracker.counts`.
+"""
+
+import logging
+import re
+import threading
+import time
+from dataclasses import dataclass, field
+from typing import Dict, List, Optional, Tuple
+
+logger = logging.getLogger(__name__)
+
+ENTITY_TYPES = ("account", "device")
+DIMENSIONS = ("country", "city")
+
+_WINDOW = re.compile(r"^(\d+)([smhd])$")
+_UNIT_SECONDS = {"s": 1, "m": 60, "h": 3600, "d": 86400}
+
+
+def parse_windows(raw: str) -> List[Tuple[str, int]]:
+    """Parse a comma-separated window list ("15m,1h,24h").
+
+    Returns:
+        (label, seconds) pairs, shortest first
+
+    Raises:
+        ValueError: If a window is malformed or none are given
+    """
+    windows = {}
+    for part in raw.split(","):
+        label = part.strip().lower()
+        if not label:
+            continue
+        match = _WINDOW.match(label)
+        if not match or int(match.group(1)) == 0:
+            raise ValueError(f"Invalid velocity window {part!r}: expected e.g. 30s, 15m, 1h, 7d")
+        windows[label] = int(match.group(1)) * _UNIT_SECONDS[match.group(2)]
+    if not windows:
+        raise ValueError("At least one velocity window is required")
+    return sorted(windows.items(), key=lambda item: item[1])
+
+
+class VelocityStore:
+    """Storage of last-seen times per (key, member)."""
+
+    def record(self, key: str, member: str, timestamp: float, retention_seconds: int) -> None:
+        """Mark a member as seen and drop members older than the retention."""
+        raise NotImplementedError
+
+    def count_since(self, key: str, since: float) -> int:
+        """Number of members seen at or after a time."""
+        raise NotImplementedError
+
+    def delete(self, keys: List[str]) -> None:
+        """Drop keys and their members."""
+        raise NotImplementedError
+
+
+class InMemoryVelocityStore(VelocityStore):
+    """Process-local store (not shared between workers)."""
+
+    def __init__(self):
+        self._sets: Dict[str, Dict[str, float]] = {}
+        self._lock = threading.Lock()
+
+    def record(self, key: str, member: str, timestamp: float, retention_seconds: int) -> None:
+        cutoff = timestamp - retention_seconds
+        with self._lock:
+            members = self._sets.setdefault(key, {})
+            members[member] = max(timestamp, members.get(member, timestamp))
+            for stale in [m for m, seen in members.items() if seen < cutoff]:
+                del members[stale]
+
+    def count_since(self, key: str, since: float) -> int:
+        with self._lock:
+            return sum(1 for seen in self._sets.get(key, {}).values() if seen >= since)
+
+    def delete(self, keys: List[str]) -> None:
+        with self._lock:
+            for key in keys:
+                self._sets.pop(key, None)
+
+
+class RedisVelocityStore(VelocityStore):
+    """Redis sorted sets scored by last-seen time."""
+
+    def __init__(self, client):
+        """Initialize Redis store.
+
+        Args:
+            client: redis.Redis client
+        """
+        self.client = client
+
+    @classmethod
+    def from_url(cls, url: str) -> "RedisVelocityStore":
+        """Connect to Redis.
+
+        Raises:
+            RuntimeError: If the redis package is not installed
+        """
+        try:
+            import redis
+        except ImportError:
+            raise RuntimeError("redis is required for VELOCITY_REDIS_URL")
+        return cls(redis.Redis.from_url(url))
+
+    def record(self, key: str, member: str, timestamp: float, retention_seconds: int) -> None:
+        pipeline = self.client.pipeline()
+        # GT (Redis 6.2+) adds new members but never moves a last-seen time back
+        pipeline.zadd(key, {member: timestamp}, gt=True)
+        pipeline.zremrangebyscore(key, "-inf", f"({timestamp - retention_seconds}")
+        pipeline.expire(key, retention_seconds)
+        pipeline.execute()
+
+    def count_since(self, key: str, since: float) -> int:
+        return int(self.client.zcount(key, since, "+inf"))
+
+    def delete(self, keys: List[str]) -> None:
+        if keys:
+            self.client.delete(*keys)
+
+
+@dataclass
+class VelocitySnapshot:
+    """Distinct locations of an entity per dimension and window."""
+    entity_type: str
+    entity_id: str
+    counts: Dict[str, Dict[str, int]] = field(default_factory=dict)  # dimension -> window -> count
+
+    def count(self, dimension: str, window: str) -> int:
+        """Distinct values of a dimension in a window (0 if not tracked)."""
+        return self.counts.get(dimension, {}).get(window, 0)
+
+    def to_dict(self) -> Dict[str, object]:
+        """Representation used in API responses."""
+        return {
+            "entity_type": self.entity_type,
+            "entity_id": self.entity_id,
+            "countries": dict(self.counts.get("country", {})),
+            "cities": dict(self.counts.get("city", {})),
+        }
+
+
+class VelocityTracker:
+    """Records entity locations and counts distinct ones per window."""
+
+    def __init__(
+        self,
+        store: Optional[VelocityStore] = None,
+        windows: Optional[List[Tuple[str, int]]] = None,
+        key_prefix: str = "velocity",
+    ):
+        """Initialize velocity tracker.
+
+        Args:
+            store: Counter storage (default: in memory)
+            windows: (label, seconds) pairs (default: 15m, 1h, 24h)
+            key_prefix: Prefix of the storage keys
+        """
+        self.store = store or InMemoryVelocityStore()
+        self.windows = windows or parse_windows("15m,1h,24h")
+        self.key_prefix = key_prefix
+
+    def _key(self, tenant_id: Optional[str], entity_type: str, entity_id: str, dimension: str) -> str:
+        return f"{self.key_prefix}:{tenant_id or '-'}:{entity_type}:{dimension}:{entity_id}"
+
+    @staticmethod
+    def _entity(entity_type: str, entity_id: str) -> Tuple[str, str]:
+        entity_type = (entity_type or "").strip().lower()
+        if entity_type not in ENTITY_TYPES:
+            raise ValueError(
+                f"Unknown entity type {entity_type!r}; expected one of {', '.join(ENTITY_TYPES)}"
+            )
+        entity_id = (entity_id or "").strip()
+        if not entity_id:
+            raise ValueError("Entity ID must not be empty")
+        return entity_type, entity_id
+
+    def observe(
+        self,
+        entity_type: str,
+        entity_id: str,
+        country_code: Optional[str],
+        city: Optional[str] = None,
+        timestamp: Optional[float] = None,
+        tenant_id: Optional[str] = None,
+    ) -> VelocitySnapshot:
+        """Record where an entity appeared and return its updated counts.
+
+        Args:
+            entity_type: "account" or "device"
+            entity_id: Account or device identifier
+            country_code: ISO 3166-1 alpha-2 code of the location
+            city: City name (counted per country, so same-named cities differ)
+            timestamp: Unix time of the observation (default: now)
+            tenant_id: Calling tenant; entity IDs are scoped to it
+
+        Returns:
+            VelocitySnapshot as of the observation
+
+        Raises:
+            ValueError: If the entity is invalid
+            RuntimeError: If the store cannot be reached
+        """
+        entity_type, entity_id = self._entity(entity_type, entity_id)
+        now = time.time() if timestamp is None else timestamp
+        retention = self.windows[-1][1]
+        country = (country_code or "").strip().upper()
+        values = {
+            "country": country or None,
+            "city": f"{country}/{city.strip().lower()}" if country and city and city.strip() else None,
+        }
+        try:
+            for dimension, value in values.items():
+                if value is not None:
+                    key = self._key(tenant_id, entity_type, entity_id, dimension)
+                    self.store.record(key, value, now, retention)
+        except Exception as e:
+            raise RuntimeError(f"Velocity store unavailable: {str(e)}")
+        return self.counts(entity_type, entity_id, now, tenant_id)
+
+    def counts(
+        self,
+        entity_type: str,
+        entity_id: str,
+        now: Optional[float] = None,
+        tenant_id: Optional[str] = None,
+    ) -> VelocitySnapshot:
+        """Distinct countries and cities per window.
+
+        Raises:
+            ValueError: If the entity is invalid
+            RuntimeError: If the store cannot be reached
+        """
+        entity_type, entity_id = self._entity(entity_type, entity_id)
+        now = time.time() if now is None else now
+        snapshot = VelocitySnapshot(entity_type, entity_id)
+        try:
+            for dimension in DIMENSIONS:
+                key = self._key(tenant_id, entity_type, entity_id, dimension)
+                snapshot.counts[dimension] = {
+                    label: self.store.count_since(key, now - seconds) for label, seconds in self.windows
+                }
+        except Exception as e:
+            raise RuntimeError(f"Velocity store unavailable: {str(e)}")
+        return snapshot
+
+    def forget(self, entity_id: str, tenant_id: Optional[str] = None) -> None:
+        """Drop the counters of an account or device ID (every entity type).
+
+        Raises:
+            RuntimeError: If the store cannot be reached
+        """
+        keys = [
+            self._key(tenant_id, entity_type, entity_id.strip(), dimension)
+            for entity_type in ENTITY_TYPES
+            for dimension in DIMENSIONS
+        ]
+        try:
+            self.store.delete(keys)
+        except Exception as e:
+            raise RuntimeError(f"Velocity store unavailable: {str(e)}")
+
+
+# Global velocity tracker (store chosen lazily from VELOCITY_REDIS_URL)
+_velocity_tracker: Optional[VelocityTracker] = None
+
+
+def get_velocity_tracker() -> VelocityTracker:
+    """Get the global velocity tracker.
+
+    Returns:
+        VelocityTracker backed by Redis, or by memory if no URL is configured
+
+    Raises:
+        RuntimeError: If VELOCITY_REDIS_URL is set but redis is not installed
+    """
+    global _velocity_tracker
+    if _velocity_tracker is None:
+        from src.config import get_config
+
+        config = get_config()
+        try:
+            windows = parse_windows(config.velocity_windows)
+        except ValueError as e:
+            logger.error(f"Invalid VELOCITY_WINDOWS, using 15m,1h,24h: {e}")
+            windows = None
+        if config.velocity_redis_url:
+            store: VelocityStore = RedisVelocityStore.from_url(config.velocity_redis_url)
+        else:
+            logger.info("VELOCITY_REDIS_URL not set; velocity counters are per process")
+            store = InMemoryVelocityStore()
+        _velocity_tracker = VelocityTracker(store, windows, config.velocity_key_prefix)
+    return _velocity_tracker
@@ -0,0 +1,426 @@
+"""Webhook registrations and signed event delivery.
+
+Tenants register HTTPS endpoints for event types ("geofence.enter"), a
+family ("geofence.*") or everything ("*"). Each event is POSTed as JSON
+
+    {"event": "geofence.enter", "delivery_id": "...", "created_at": "...", "data": {...}}
+
+with `X-Webhook-Event`, `X-Webhook-Delivery` and `X-Webhook-Signature:
+sha256=<hex>` headers, the signature being the HMAC-SHA256 of the raw body
+under the webhook's secret. Failed deliveries (network errors and non-2xx
+responses) are retried WEBHOOK_MAX_ATTEMPTS times with exponential backoff.
+
+Every delivery is logged in the webhook_deliveries table with its status,
+attempt count and latest answer. A logged delivery can be redelivered: the
+stored body is resent unchanged (same delivery_id, so receivers can
+deduplicate) and signed with the webhook's current secret.
+"""
+
+import asyncio
+import hashlib
+import hmac
+import json
+import logging
+import secrets
+import uuid
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Any, Awaitable, Callable, Dict, List, Optional, Sequence
+from urllib.parse import urlparse
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import Webhook, WebhookDelivery
+from src.pagination import Page, keyset_page
+
+logger = logging.getLogger(__name__)
+
+# (url, body, headers, timeout_seconds) -> HTTP status
+Sender = Callable[[str, bytes, Dict[str, str], float], Awaitable[int]]
+
+
+class DeliveryStatus:
+    """Outcome of a logged delivery."""
+    PENDING = "pending"
+    DELIVERED = "delivered"
+    FAILED = "failed"
+
+    ALL = (PENDING, DELIVERED, FAILED)
+
+
+def _default_session() -> Session:
+    from src.database import get_db_manager
+
+    return get_db_manager().get_session()
+
+
+def sign(secret: str, body: bytes) -> str:
+    """Signature header value of a delivery body."""
+    return "sha256=" + hmac.new(secret.encode("utf-8"), body, hashlib.sha256).hexdigest()
+
+
+def event_matches(patterns: Sequence[str], event: str) -> bool:
+    """Whether a webhook subscribed to patterns receives an event type."""
+    for pattern in patterns:
+        if pattern == "*" or pattern == event:
+            return True
+        if pattern.endswith(".*") and event.startswith(pattern[:-1]):
+            return True
+    return False
+
+
+@dataclass
+class WebhookTarget:
+    """Delivery destination of one webhook."""
+    webhook_id: str
+    url: str
+    secret: str
+    tenant_id: Optional[str] = None
+
+
+class WebhookService:
+    """Stores webhook registrations of tenants."""
+
+    def __init__(self, session: Session):
+        """Initialize webhook service.
+
+        Args:
+            session: SQLAlchemy database session
+        """
+        self.session = session
+
+    def create_webhook(
+        self, tenant_id: str, url: str, events: Sequence[str], secret: Optional[str] = None
+    ) -> Webhook:
+        """Register a webhook.
+
+        Args:
+            tenant_id: Owning tenant
+            url: http(s) endpoint
+            events: Event types or patterns to deliver
+            secret: Signing secret (default: generated)
+
+        Returns:
+            Stored Webhook
+
+        Raises:
+            ValueError: If the URL or events are invalid, or storage fails
+        """
+        parsed = urlparse(url)
+        if parsed.scheme not in ("http", "https") or not parsed.netloc:
+            raise ValueError(f"Invalid webhook URL {url!r}: expected an http(s) URL")
+        events = sorted({e.strip() for e in events if e.strip()})
+        if not events:
+            raise ValueError("At least one event type is required")
+
+        webhook = Webhook(
+            webhook_id=str(uuid.uuid4()),
+            tenant_id=tenant_id,
+            url=url,
+            secret=secret or secrets.token_hex(32),
+            events=events,
+        )
+        try:
+            self.session.add(webhook)
+            self.session.commit()
+            self.session.refresh(webhook)
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store webhook: {str(e)}")
+        logger.info(f"Registered webhook {webhook.webhook_id} for tenant {tenant_id}: {events}")
+        return webhook
+
+    def list_webhooks(self, tenant_id: str) -> List[Webhook]:
+        """Webhooks of a tenant, oldest first."""
+        return (
+            self.session.query(Webhook)
+            .filter(Webhook.tenant_id == tenant_id)
+            .order_by(Webhook.id)
+            .all()
+        )
+
+    def get_webhook(self, tenant_id: str, webhook_id: str) -> Optional[Webhook]:
+        """Fetch one of a tenant's webhooks by ID."""
+        return (
+            self.session.query(Webhook)
+            .filter(Webhook.tenant_id == tenant_id, Webhook.webhook_id == webhook_id)
+            .first()
+        )
+
+    def delete_webhook(self, tenant_id: str, webhook_id: str) -> bool:
+        """Remove a webhook and its delivery log.
+
+        Returns:
+            True if it existed
+        """
+        webhook = self.get_webhook(tenant_id, webhook_id)
+        if webhook is None:
+            return False
+        self.session.query(WebhookDelivery).filter(
+            WebhookDelivery.tenant_id == tenant_id, WebhookDelivery.webhook_id == webhook_id
+        ).delete(synchronize_session=False)
+        self.session.delete(webhook)
+        self.session.commit()
+        return True
+
+    def targets(self, tenant_id: str, event: str) -> List[WebhookTarget]:
+        """Webhooks of a tenant that receive an event type."""
+        return [
+            WebhookTarget(w.webhook_id, w.url, w.secret, w.tenant_id)
+            for w in self.list_webhooks(tenant_id)
+            if event_matches(w.events, event)
+        ]
+
+    def delivery_page(
+        self,
+        tenant_id: str,
+        webhook_id: str,
+        status: Optional[str] = None,
+        limit: int = 100,
+        cursor: Optional[str] = None,
+    ) -> Page[WebhookDelivery]:
+        """One page of a webhook's logged deliveries, newest first.
+
+        Args:
+            tenant_id: Owning tenant
+            webhook_id: Webhook whose deliveries to list
+            status: Only deliveries with this status
+            limit: Most deliveries on the page
+            cursor: next_cursor of the previous page
+
+        Raises:
+            ValueError: If the status or cursor is invalid
+        """
+        query = self.session.query(WebhookDelivery).filter(
+            WebhookDelivery.tenant_id == tenant_id, WebhookDelivery.webhook_id == webhook_id
+        )
+        if status is not None:
+            if status not in DeliveryStatus.ALL:
+                raise ValueError(f"Unknown delivery status: {status}")
+            query = query.filter(WebhookDelivery.status == status)
+        return keyset_page(
+            query, WebhookDelivery.created_at, WebhookDelivery.id, limit, cursor, lambda d: d
+        )
+
+    def get_delivery(self, tenant_id: str, webhook_id: str, delivery_id: str) -> Optional[WebhookDelivery]:
+        """Fetch one logged delivery of a tenant's webhook."""
+        return (
+            self.session.query(WebhookDelivery)
+            .filter(
+                WebhookDelivery.tenant_id == tenant_id,
+                WebhookDelivery.webhook_id == webhook_id,
+                WebhookDelivery.delivery_id == delivery_id,
+            )
+            .first()
+        )
+
+    def requeue_delivery(self, delivery: WebhookDelivery) -> WebhookDelivery:
+        """Mark a logged delivery pending again before redelivering it."""
+        delivery.status = DeliveryStatus.PENDING
+        self.session.commit()
+        self.session.refresh(delivery)
+        return delivery
+
+
+class WebhookDeliveryLog:
+    """Records deliveries and their attempts in the webhook_deliveries table.
+
+    Deliveries run in the background, after the request that triggered
+    them has finished, so each record is written in a session of its own.
+    """
+
+    def __init__(self, session_factory: Callable[[], Session] = _default_session):
+        """Initialize delivery log.
+
+        Args:
+            session_factory: Creates database sessions
+        """
+        self.session_factory = session_factory
+
+    def _write(self, delivery_id: str, update: Callable[[WebhookDelivery], None]) -> None:
+        session = self.session_factory()
+        try:
+            delivery = (
+                session.query(WebhookDelivery)
+                .filter(WebhookDelivery.delivery_id == delivery_id)
+                .first()
+            )
+            if delivery is not None:
+                update(delivery)
+                session.commit()
+        finally:
+            session.close()
+
+    def started(self, delivery_id: str, target: WebhookTarget, event: str, body: bytes) -> None:
+        """Log a new delivery as pending."""
+        session = self.session_factory()
+        try:
+            session.add(WebhookDelivery(
+                delivery_id=delivery_id,
+                webhook_id=target.webhook_id,
+                tenant_id=target.tenant_id or "",
+                event=event,
+                body=body.decode("utf-8"),
+                status=DeliveryStatus.PENDING,
+                attempts=0,
+            ))
+            session.commit()
+        finally:
+            session.close()
+
+    def attempted(self, delivery_id: str, status_code: Optional[int], error: Optional[str]) -> None:
+        """Log one attempt: the endpoint's HTTP status, or why there was none."""
+
+        def update(delivery: WebhookDelivery) -> None:
+            delivery.attempts += 1
+            delivery.last_status_code = status_code
+            delivery.last_error = error[:512] if error else None
+            delivery.last_attempt_at = datetime.utcnow()
+
+        self._write(delivery_id, update)
+
+    def finished(self, delivery_id: str, delivered: bool) -> None:
+        """Log the outcome once the endpoint accepted it or retries ran out."""
+
+        def update(delivery: WebhookDelivery) -> None:
+            delivery.status = DeliveryStatus.DELIVERED if delivered else DeliveryStatus.FAILED
+            if delivered:
+                delivery.delivered_at = datetime.utcnow()
+
+        self._write(delivery_id, update)
+
+
+async def _post(url: str, body: bytes, headers: Dict[str, str], timeout_seconds: float) -> int:
+    import aiohttp
+
+    async with aiohttp.ClientSession() as session:
+        async with session.post(
+            url, data=body, headers=headers, timeout=aiohttp.ClientTimeout(total=timeout_seconds)
+        ) as response:
+            return response.status
+
+
+class WebhookDispatcher:
+    """Delivers events to webhooks with signing and retries."""
+
+    def __init__(
+        self,
+        timeout_seconds: float = 5.0,
+        max_attempts: int = 3,
+        backoff_seconds: float = 1.0,
+        sender: Optional[Sender] = None,
+        delivery_log: Optional[WebhookDeliveryLog] = None,
+    ):
+        """Initialize dispatcher.
+
+        Args:
+            timeout_seconds: Per-attempt request timeout
+            max_attempts: Attempts per delivery, the first included
+            backoff_seconds: Wait before the first retry, doubled for each further one
+            sender: HTTP transport (default: aiohttp POST)
+            delivery_log: Where deliveries are recorded (default: not recorded)
+        """
+        self.timeout_seconds = timeout_seconds
+        self.max_attempts = max(1, max_attempts)
+        self.backoff_seconds = backoff_seconds
+        self.sender = sender or _post
+        self.delivery_log = delivery_log
+
+    def _log(self, method: str, delivery_id: str, *args: Any) -> None:
+        """Record in the delivery log; a failing log never stops a delivery."""
+        if self.delivery_log is None:
+            return
+        try:
+            getattr(self.delivery_log, method)(delivery_id, *args)
+        except Exception as e:
+            logger.error(f"Failed to log webhook delivery {delivery_id}: {str(e)}")
+
+    async def deliver(self, target: WebhookTarget, event: str, data: Dict[str, Any]) -> bool:
+        """Deliver one event to one webhook.
+
+        Returns:
+            True if the endpoint answered 2xx within the allowed attempts
+        """
+        delivery_id = str(uuid.uuid4())
+        body = json.dumps(
+            {
+                "event": event,
+                "delivery_id": delivery_id,
+                "created_at": datetime.now(timezone.utc).isoformat(),
+                "data": data,
+            },
+            default=str,
+        ).encode("utf-8")
+        self._log("started", delivery_id, target, event, body)
+        return await self._send(target, event, delivery_id, body)
+
+    async def redeliver(self, target: WebhookTarget, delivery_id: str, event: str, body: str) -> bool:
+        """Resend a logged delivery's body, signed with the target's current secret.
+
+        Returns:
+            True if the endpoint answered 2xx within the allowed attempts
+        """
+        return await self._send(target, event, delivery_id, body.encode("utf-8"))
+
+    async def _send(self, target: WebhookTarget, event: str, delivery_id: str, body: bytes) -> bool:
+        from src.metrics import WEBHOOK_DELIVERIES
+
+        headers = {
+            "Content-Type": "application/json",
+            "X-Webhook-Event": event,
+            "X-Webhook-Delivery": delivery_id,
+            "X-Webhook-Signature": sign(target.secret, body),
+        }
+
+        for attempt in range(1, self.max_attempts + 1):
+            try:
+                status = await self.sender(target.url, body, headers, self.timeout_seconds)
+            except Exception as e:
+                problem = f"{type(e).__name__}: {str(e)}"
+                self._log("attempted", delivery_id, None, problem)
+            else:
+                self._log("attempted", delivery_id, status, None)
+                if 200 <= status < 300:
+                    WEBHOOK_DELIVERIES.labels(event=event, result="delivered").inc()
+                    self._log("finished", delivery_id, True)
+                    return True
+                problem = f"HTTP {status}"
+            logger.warning(
+                f"Webhook {target.webhook_id} delivery {delivery_id} attempt {attempt} failed: {problem}"
+            )
+            if attempt < self.max_attempts:
+                await asyncio.sleep(self.backoff_seconds * 2 ** (attempt - 1))
+
+        WEBHOOK_DELIVERIES.labels(event=event, result="failed").inc()
+        self._log("finished", delivery_id, False)
+        logger.error(f"Webhook {target.webhook_id} gave up on {event} delivery {delivery_id}")
+        return False
+
+    async def dispatch(self, targets: Sequence[WebhookTarget], event: str, data: Dict[str, Any]) -> int:
+        """Deliver one event to several webhooks concurrently.
+
+        Returns:
+            Number of successful deliveries
+        """
+        results = await asyncio.gather(*(self.deliver(t, event, data) for t in targets))
+        return sum(1 for delivered in results if delivered)
+
+
+# Global dispatcher (configured lazily from WEBHOOK_* settings)
+_webhook_dispatcher: Optional[WebhookDispatcher] = None
+
+
+def get_webhook_dispatcher() -> WebhookDispatcher:
+    """Get the global webhook dispatcher."""
+    global _webhook_dispatcher
+    if _webhook_dispatcher is None:
+        from src.config import get_config
+
+        config = get_config()
+        _webhook_dispatcher = WebhookDispatcher(
+            timeout_seconds=config.webhook_timeout_seconds,
+            max_attempts=config.webhook_max_attempts,
+            backoff_seconds=config.webhook_retry_backoff_seconds,
+            delivery_log=WebhookDeliveryLog(),
+        )
+    return _webhook_dispatcher
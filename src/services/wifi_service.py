@@ -0,0 +1,274 @@
+"""WiFi BSSID positioning from an access point database.
+
+A device reports the access points it can hear (BSSID and RSSI). Each
+known BSSID's stored location becomes a ranged anchor, the range coming
+from the log-distance path loss model, and the position is the weighted
+centroid of the largest group of mutually close anchors (see
+src.spatial.positioning).
+
+The access point database is loaded from CSV extracts (columns bssid,
+latitude, longitude and optionally ssid), e.g.:
+
+    python -m src.services.wifi_service access_points.csv
+"""
+
+import csv
+import logging
+import re
+from dataclasses import dataclass
+from typing import Dict, Iterable, List, Optional, Tuple
+
+from sqlalchemy.orm import Session
+
+from src.models.database_models import WifiAccessPoint
+from src.spatial.positioning import (
+    PositionEstimate,
+    RangedAnchor,
+    consistent_cluster,
+    log_distance_range,
+    weighted_centroid,
+)
+
+logger = logging.getLogger(__name__)
+
+_BSSID_SEPARATORS = re.compile(r"[:\-.]")
+_HEX = re.compile(r"^[0-9a-f]{12}$")
+
+# SSIDs ending in this opt out of location databases (IEEE 802.11 convention)
+_NOMAP_SUFFIX = "_nomap"
+
+# Plausible received signal strength for WiFi, in dBm
+_RSSI_RANGE = (-120.0, 0.0)
+
+
+def normalize_bssid(bssid: str) -> str:
+    """Validate a BSSID and format it as lowercase colon-separated hex.
+
+    Accepts colon, hyphen and Cisco dotted notation.
+
+    Raises:
+        ValueError: If the value is not a unicast MAC address
+    """
+    digits = _BSSID_SEPARATORS.sub("", str(bssid).strip().lower())
+    if not _HEX.match(digits):
+        raise ValueError(f"Invalid BSSID: {bssid}")
+    if int(digits[:2], 16) & 1 or digits == "0" * 12:
+        raise ValueError(f"Invalid BSSID {bssid}: not a unicast address")
+    return ":".join(digits[i:i + 2] for i in range(0, 12, 2))
+
+
+@dataclass
+class WifiPosition:
+    """Position estimated from WiFi observations."""
+    estimate: PositionEstimate
+    access_points_known: int  # Observed BSSIDs found in the database
+
+
+class WifiPositioningService:
+    """Stores access point locations and positions devices from observations."""
+
+    def __init__(
+        self,
+        session: Session,
+        min_access_points: Optional[int] = None,
+        max_observations: Optional[int] = None,
+        reference_rssi_dbm: Optional[float] = None,
+        path_loss_exponent: Optional[float] = None,
+        max_spread_m: Optional[float] = None,
+    ):
+        """Initialize WiFi positioning service.
+
+        Args:
+            session: SQLAlchemy database session
+            min_access_points: Known access points required for a fix
+                (default WIFI_MIN_ACCESS_POINTS)
+            max_observations: Observations accepted per request (default WIFI_MAX_OBSERVATIONS)
+            reference_rssi_dbm: RSSI at 1 m (default WIFI_REFERENCE_RSSI_DBM)
+            path_loss_exponent: Path loss exponent (default WIFI_PATH_LOSS_EXPONENT)
+            max_spread_m: Radius of the group of access points used; others
+                are ignored (default WIFI_MAX_AP_SPREAD_M)
+        """
+        from src.config import get_config
+
+        config = get_config()
+        self.session = session
+        self.min_access_points = (
+            min_access_points if min_access_points is not None else config.wifi_min_access_points
+        )
+        self.max_observations = (
+            max_observations if max_observations is not None else config.wifi_max_observations
+        )
+        self.reference_rssi_dbm = (
+            reference_rssi_dbm if reference_rssi_dbm is not None else config.wifi_reference_rssi_dbm
+        )
+        self.path_loss_exponent = (
+            path_loss_exponent if path_loss_exponent is not None else config.wifi_path_loss_exponent
+        )
+        self.max_spread_m = max_spread_m if max_spread_m is not None else config.wifi_max_ap_spread_m
+
+    def ingest(self, access_points: Iterable[Dict], batch_size: int = 1000) -> int:
+        """Insert or update access point locations.
+
+        Rows with an invalid BSSID or coordinate are skipped, as are
+        access points whose SSID opts out with the "_nomap" suffix (an
+        existing entry for such a BSSID is removed).
+
+        Args:
+            access_points: dicts with bssid, latitude, longitude and optional ssid
+            batch_size: Rows written per transaction
+
+        Returns:
+            Number of access points stored
+
+        Raises:
+            ValueError: If the rows cannot be stored
+        """
+        stored = 0
+        batch: Dict[str, Dict] = {}
+        for row in access_points:
+            parsed = self._parse_row(row)
+            if parsed is None:
+                continue
+            batch[parsed["bssid"]] = parsed
+            if len(batch) >= batch_size:
+                stored += self._store(batch)
+                batch = {}
+        if batch:
+            stored += self._store(batch)
+        logger.info(f"Stored {stored} WiFi access points")
+        return stored
+
+    def import_csv(self, path: str, batch_size: int = 1000) -> int:
+        """Load access points from a CSV with bssid, latitude and longitude columns.
+
+        Raises:
+            OSError: If the file cannot be read
+            ValueError: If the columns are missing or the rows cannot be stored
+        """
+        with open(path, newline="", encoding="utf-8") as f:
+            reader = csv.DictReader(f)
+            if not {"bssid", "latitude", "longitude"} <= set(reader.fieldnames or ()):
+                raise ValueError(f"{path}: expected bssid, latitude and longitude columns")
+            return self.ingest(reader, batch_size=batch_size)
+
+    def locate(self, observations: List[Tuple[str, float]]) -> WifiPosition:
+        """Estimate a position from observed access points.
+
+        Args:
+            observations: (BSSID, RSSI in dBm) pairs; repeated BSSIDs keep
+                their strongest reading
+
+        Returns:
+            WifiPosition
+
+        Raises:
+            ValueError: If there are no or too many observations, or one is malformed
+            LookupError: If too few of the access points are in the database
+        """
+        if not observations:
+            raise ValueError("At least one access point observation is required")
+        if len(observations) > self.max_observations:
+            raise ValueError(
+                f"Too many observations: {len(observations)} (max {self.max_observations})"
+            )
+
+        strongest: Dict[str, float] = {}
+        for bssid, rssi in observations:
+            bssid = normalize_bssid(bssid)
+            if not _RSSI_RANGE[0] <= rssi <= _RSSI_RANGE[1]:
+                raise ValueError(f"RSSI out of range for {bssid}: {rssi} dBm")
+            strongest[bssid] = max(rssi, strongest.get(bssid, rssi))
+
+        known = (
+            self.session.query(WifiAccessPoint)
+            .filter(WifiAccessPoint.bssid.in_(list(strongest)))
+            .all()
+        )
+        anchors = consistent_cluster(
+            [
+                RangedAnchor(
+                    latitude=ap.latitude,
+                    longitude=ap.longitude,
+                    range_m=log_distance_range(
+                        strongest[ap.bssid], self.reference_rssi_dbm, self.path_loss_exponent
+                    ),
+                )
+                for ap in known
+            ],
+            self.max_spread_m,
+        )
+        if len(anchors) < self.min_access_points:
+            raise LookupError(
+                f"{len(anchors)} usable access points known; at least "
+                f"{self.min_access_points} needed for a position"
+            )
+        return WifiPosition(estimate=weighted_centroid(anchors), access_points_known=len(known))
+
+    @staticmethod
+    def _parse_row(row: Dict) -> Optional[Dict]:
+        """Validated access point fields, or None to skip the row."""
+        try:
+            bssid = normalize_bssid(row.get("bssid") or "")
+            latitude = float(row["latitude"])
+            longitude = float(row["longitude"])
+        except (KeyError, TypeError, ValueError):
+            return None
+        if not -90 <= latitude <= 90 or not -180 <= longitude <= 180:
+            return None
+        ssid = (row.get("ssid") or "").strip() or None
+        return {
+            "bssid": bssid,
+            "ssid": ssid,
+            "latitude": latitude,
+            "longitude": longitude,
+            "nomap": bool(ssid and ssid.lower().endswith(_NOMAP_SUFFIX)),
+        }
+
+    def _store(self, batch: Dict[str, Dict]) -> int:
+        """Upsert one batch of parsed rows."""
+        existing = {
+            ap.bssid: ap
+            for ap in self.session.query(WifiAccessPoint)
+            .filter(WifiAccessPoint.bssid.in_(list(batch)))
+            .all()
+        }
+        stored = 0
+        try:
+            for bssid, row in batch.items():
+                ap = existing.get(bssid)
+                if row["nomap"]:
+                    if ap is not None:
+                        self.session.delete(ap)
+                    continue
+                if ap is None:
+                    self.session.add(WifiAccessPoint(
+                        bssid=bssid,
+                        ssid=row["ssid"],
+                        latitude=row["latitude"],
+                        longitude=row["longitude"],
+                    ))
+                else:
+                    ap.ssid = row["ssid"]
+                    ap.latitude = row["latitude"]
+                    ap.longitude = row["longitude"]
+                stored += 1
+            self.session.commit()
+        except Exception as e:
+            self.session.rollback()
+            raise ValueError(f"Failed to store WiFi access points: {str(e)}")
+        return stored
+
+
+if __name__ == "__main__":
+    import sys
+
+    from src.database import get_db_manager
+
+    logging.basicConfig(level=logging.INFO)
+    if len(sys.argv) != 2:
+        sys.exit("usage: python -m src.services.wifi_service ACCESS_POINTS.csv")
+    manager = get_db_manager()
+    manager.create_all()
+    with manager.session_scope() as session:
+        count = WifiPositioningService(session).import_csv(sys.argv[1])
+    print(f"Imported {count} WiFi access points")
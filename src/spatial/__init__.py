@@ -0,0 +1 @@
+"""Spatial primitives: geometry, indexing and geodesy utilities."""
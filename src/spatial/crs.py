@@ -0,0 +1,260 @@
+"""Coordinate reference system conversions: WGS84, Web Mercator and UTM.
+
+Supported systems, by EPSG code:
+
+- EPSG:4326  WGS84 longitude/latitude in degrees (GeoJSON axis order)
+- EPSG:3857  Web Mercator (spherical, as used by web map tiles), meters
+- EPSG:326zz / EPSG:327zz  UTM zone zz north / south on WGS84, meters
+
+UTM uses the Krüger series for the transverse Mercator projection (third
+order in the ellipsoid's third flattening), accurate to about a
+millimeter within a zone. All conversions go through WGS84.
+"""
+
+import math
+import re
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, List, Tuple
+
+from src.spatial.distance import WGS84_A, WGS84_F
+
+Transform = Callable[[float, float], Tuple[float, float]]
+
+# Web Mercator is a sphere of the WGS84 semi-major axis, cut off where the map is square
+WEB_MERCATOR_MAX_LATITUDE = 85.0511287798
+
+# UTM is defined from 80S to 84N
+UTM_MIN_LATITUDE = -80.0
+UTM_MAX_LATITUDE = 84.0
+
+_UTM_K0 = 0.9996
+_UTM_FALSE_EASTING = 500000.0
+_UTM_FALSE_NORTHING_SOUTH = 10000000.0
+
+_CRS_NAME = re.compile(r"^(?:EPSG:|urn:ogc:def:crs:EPSG::|urn:ogc:def:crs:EPSG:[\d.]*:)(\d+)$", re.I)
+
+# Krüger series coefficients
+_N = WGS84_F / (2 - WGS84_F)
+_RECTIFYING_A = WGS84_A / (1 + _N) * (1 + _N ** 2 / 4 + _N ** 4 / 64)
+_ALPHA = (
+    _N / 2 - 2 * _N ** 2 / 3 + 5 * _N ** 3 / 16,
+    13 * _N ** 2 / 48 - 3 * _N ** 3 / 5,
+    61 * _N ** 3 / 240,
+)
+_BETA = (
+    _N / 2 - 2 * _N ** 2 / 3 + 37 * _N ** 3 / 96,
+    _N ** 2 / 48 + _N ** 3 / 15,
+    17 * _N ** 3 / 480,
+)
+_DELTA = (
+    2 * _N - 2 * _N ** 2 / 3 - 2 * _N ** 3,
+    7 * _N ** 2 / 3 - 8 * _N ** 3 / 5,
+    56 * _N ** 3 / 15,
+)
+_TWO_ROOT_N = 2 * math.sqrt(_N) / (1 + _N)
+
+
+@dataclass(frozen=True)
+class CRS:
+    """A supported coordinate reference system."""
+    epsg: int
+
+    @property
+    def name(self) -> str:
+        """Name in "EPSG:<code>" form."""
+        return f"EPSG:{self.epsg}"
+
+    @property
+    def utm_zone(self) -> int:
+        """UTM zone number (0 for non-UTM systems)."""
+        return self.epsg % 100 if self.epsg // 100 in (326, 327) else 0
+
+    @property
+    def utm_south(self) -> bool:
+        """True for southern-hemisphere UTM zones."""
+        return self.epsg // 100 == 327
+
+
+WGS84 = CRS(4326)
+WEB_MERCATOR = CRS(3857)
+
+
+def parse_crs(value: str) -> CRS:
+    """Parse a CRS name ("EPSG:3857", "urn:ogc:def:crs:EPSG::32633").
+
+    "EPSG:900913" (an old alias of Web Mercator) and "CRS84" (= WGS84
+    lon/lat) are also accepted.
+
+    Raises:
+        ValueError: If the name is malformed or the system is not supported
+    """
+    text = str(value).strip()
+    if text.upper() in ("CRS84", "OGC:CRS84", "URN:OGC:DEF:CRS:OGC:1.3:CRS84"):
+        return WGS84
+    match = _CRS_NAME.match(text)
+    if not match:
+        raise ValueError(f"Invalid CRS {value!r}: expected EPSG:<code>")
+    code = int(match.group(1))
+    if code == 900913:
+        return WEB_MERCATOR
+    if code in (4326, 3857) or (code // 100 in (326, 327) and 1 <= code % 100 <= 60):
+        return CRS(code)
+    raise ValueError(
+        f"Unsupported CRS EPSG:{code}; expected EPSG:4326, EPSG:3857 or a UTM zone (EPSG:326zz/327zz)"
+    )
+
+
+def utm_crs_for(longitude: float, latitude: float) -> CRS:
+    """UTM zone containing a WGS84 coordinate, with the Norway and Svalbard exceptions.
+
+    Raises:
+        ValueError: If the coordinate is outside the UTM latitude band
+    """
+    _check_latitude(latitude, UTM_MIN_LATITUDE, UTM_MAX_LATITUDE, "UTM")
+    longitude = _wrap_longitude(longitude)
+    zone = min(60, int((longitude + 180) // 6) + 1)
+    if 56 <= latitude < 64 and 3 <= longitude < 12:
+        zone = 32
+    elif latitude >= 72:
+        for limit, exception in ((9, 31), (21, 33), (33, 35), (42, 37)):
+            if 0 <= longitude < limit:
+                zone = exception
+                break
+    return CRS((32700 if latitude < 0 else 32600) + zone)
+
+
+def to_wgs84(crs: CRS, x: float, y: float) -> Tuple[float, float]:
+    """Convert a coordinate in a CRS to WGS84 (longitude, latitude).
+
+    Raises:
+        ValueError: If the coordinate is not finite
+    """
+    _check_finite(x, y)
+    if crs == WGS84:
+        return x, y
+    if crs == WEB_MERCATOR:
+        longitude = math.degrees(x / WGS84_A)
+        latitude = math.degrees(2 * math.atan(math.exp(y / WGS84_A)) - math.pi / 2)
+        return _wrap_longitude(longitude), latitude
+    return _utm_inverse(crs, x, y)
+
+
+def from_wgs84(crs: CRS, longitude: float, latitude: float) -> Tuple[float, float]:
+    """Convert a WGS84 coordinate to a CRS.
+
+    Raises:
+        ValueError: If the coordinate is out of range or outside the
+            projection's latitude limits
+    """
+    _check_finite(longitude, latitude)
+    _check_latitude(latitude, -90.0, 90.0, "WGS84")
+    if crs == WGS84:
+        return longitude, latitude
+    if crs == WEB_MERCATOR:
+        _check_latitude(latitude, -WEB_MERCATOR_MAX_LATITUDE, WEB_MERCATOR_MAX_LATITUDE, "Web Mercator")
+        x = WGS84_A * math.radians(_wrap_longitude(longitude))
+        y = WGS84_A * math.log(math.tan(math.pi / 4 + math.radians(latitude) / 2))
+        return x, y
+    _check_latitude(latitude, UTM_MIN_LATITUDE, UTM_MAX_LATITUDE, "UTM")
+    return _utm_forward(crs, longitude, latitude)
+
+
+def transformer(source: CRS, target: CRS) -> Transform:
+    """Function converting (x, y) from source to target."""
+    if source == target:
+        return lambda x, y: (x, y)
+
+    def transform(x: float, y: float) -> Tuple[float, float]:
+        longitude, latitude = to_wgs84(source, x, y)
+        return from_wgs84(target, longitude, latitude)
+
+    return transform
+
+
+def transform_geometry(geometry: Dict[str, Any], transform: Transform) -> Dict[str, Any]:
+    """Copy of a GeoJSON geometry with every position transformed.
+
+    Extra position members (e.g. elevation) are kept unchanged.
+
+    Raises:
+        ValueError: If the geometry is malformed or a position cannot be converted
+    """
+    if not isinstance(geometry, dict):
+        raise ValueError("Geometry must be a GeoJSON object")
+    if geometry.get("type") == "GeometryCollection":
+        return {
+            **geometry,
+            "geometries": [transform_geometry(g, transform) for g in geometry.get("geometries") or []],
+        }
+    if "coordinates" not in geometry:
+        raise ValueError("Geometry has no coordinates")
+    return {**geometry, "coordinates": _transform_coordinates(geometry["coordinates"], transform)}
+
+
+def _transform_coordinates(coordinates: Any, transform: Transform) -> List[Any]:
+    if not isinstance(coordinates, (list, tuple)) or not coordinates:
+        raise ValueError("Invalid GeoJSON coordinates")
+    if isinstance(coordinates[0], (int, float)):
+        if len(coordinates) < 2:
+            raise ValueError("GeoJSON positions need at least two numbers")
+        x, y = transform(float(coordinates[0]), float(coordinates[1]))
+        return [x, y, *coordinates[2:]]
+    return [_transform_coordinates(c, transform) for c in coordinates]
+
+
+def _utm_forward(crs: CRS, longitude: float, latitude: float) -> Tuple[float, float]:
+    phi = math.radians(latitude)
+    lam = math.radians(_wrap_longitude(longitude - _central_meridian(crs)))
+    t = math.sinh(math.atanh(math.sin(phi)) - _TWO_ROOT_N * math.atanh(_TWO_ROOT_N * math.sin(phi)))
+    xi = math.atan2(t, math.cos(lam))
+    eta = math.atanh(math.sin(lam) / math.sqrt(1 + t * t))
+
+    easting = eta + sum(
+        a * math.cos(2 * j * xi) * math.sinh(2 * j * eta) for j, a in enumerate(_ALPHA, start=1)
+    )
+    northing = xi + sum(
+        a * math.sin(2 * j * xi) * math.cosh(2 * j * eta) for j, a in enumerate(_ALPHA, start=1)
+    )
+    x = _UTM_FALSE_EASTING + _UTM_K0 * _RECTIFYING_A * easting
+    y = _UTM_K0 * _RECTIFYING_A * northing
+    if crs.utm_south:
+        y += _UTM_FALSE_NORTHING_SOUTH
+    return x, y
+
+
+def _utm_inverse(crs: CRS, x: float, y: float) -> Tuple[float, float]:
+    if crs.utm_south:
+        y -= _UTM_FALSE_NORTHING_SOUTH
+    xi = y / (_UTM_K0 * _RECTIFYING_A)
+    eta = (x - _UTM_FALSE_EASTING) / (_UTM_K0 * _RECTIFYING_A)
+
+    xi_p = xi - sum(
+        b * math.sin(2 * j * xi) * math.cosh(2 * j * eta) for j, b in enumerate(_BETA, start=1)
+    )
+    eta_p = eta - sum(
+        b * math.cos(2 * j * xi) * math.sinh(2 * j * eta) for j, b in enumerate(_BETA, start=1)
+    )
+    chi = math.asin(max(-1.0, min(1.0, math.sin(xi_p) / math.cosh(eta_p))))
+    phi = chi + sum(d * math.sin(2 * j * chi) for j, d in enumerate(_DELTA, start=1))
+    lam = math.atan2(math.sinh(eta_p), math.cos(xi_p))
+    return _wrap_longitude(_central_meridian(crs) + math.degrees(lam)), math.degrees(phi)
+
+
+def _central_meridian(crs: CRS) -> float:
+    return crs.utm_zone * 6.0 - 183.0
+
+
+def _wrap_longitude(longitude: float) -> float:
+    if -180.0 <= longitude <= 180.0:
+        return longitude
+    return (longitude + 180.0) % 360.0 - 180.0
+
+
+def _check_finite(x: float, y: float) -> None:
+    if not (math.isfinite(x) and math.isfinite(y)):
+        raise ValueError(f"Coordinate is not finite: ({x}, {y})")
+
+
+def _check_latitude(latitude: float, minimum: float, maximum: float, system: str) -> None:
+    if not minimum <= latitude <= maximum:
+        raise ValueError(f"Latitude {latitude} outside the {system} range ({minimum} to {maximum})")
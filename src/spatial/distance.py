@@ -0,0 +1,219 @@
+"""Great-circle and ellipsoidal distances between coordinates.
+
+`haversine` treats the Earth as a sphere (mean radius 6,371 km; error up to
+about 0.5%). `vincenty` solves the inverse geodesic problem on the WGS84
+ellipsoid to sub-millimeter accuracy, but can fail to converge for nearly
+antipodal points.
+"""
+
+import math
+from dataclasses import dataclass
+from typing import Optional
+
+EARTH_RADIUS_METERS = 6371008.8  # IUGG mean radius
+
+# WGS84 ellipsoid
+WGS84_A = 6378137.0
+WGS84_F = 1 / 298.257223563
+WGS84_B = WGS84_A * (1 - WGS84_F)
+
+METERS_PER_UNIT = {
+    "m": 1.0,
+    "km": 1000.0,
+    "mi": 1609.344,
+    "nmi": 1852.0,
+    "ft": 0.3048,
+}
+
+
+class DistanceMethod:
+    """Supported distance calculations."""
+    HAVERSINE = "haversine"
+    VINCENTY = "vincenty"
+
+    ALL = (HAVERSINE, VINCENTY)
+
+
+class VincentyConvergenceError(ValueError):
+    """Raised when Vincenty's iteration does not converge (near-antipodal points)."""
+
+
+@dataclass
+class DistanceResult:
+    """Distance and bearings between two coordinates."""
+    meters: float
+    initial_bearing: Optional[float]  # degrees clockwise from north
+    final_bearing: Optional[float]
+    method: str
+
+    def in_unit(self, unit: str) -> float:
+        """Distance converted to a unit (m, km, mi, nmi, ft)."""
+        return convert(self.meters, unit)
+
+
+def convert(meters: float, unit: str) -> float:
+    """Convert meters to another unit.
+
+    Raises:
+        ValueError: If the unit is unknown
+    """
+    try:
+        return meters / METERS_PER_UNIT[unit]
+    except KeyError:
+        raise ValueError(
+            f"Unknown distance unit {unit!r}; expected one of {', '.join(METERS_PER_UNIT)}"
+        )
+
+
+def _validate(latitude: float, longitude: float) -> None:
+    if not -90 <= latitude <= 90:
+        raise ValueError(f"Latitude out of range: {latitude}")
+    if not -180 <= longitude <= 180:
+        raise ValueError(f"Longitude out of range: {longitude}")
+
+
+def _normalize_bearing(radians: float) -> float:
+    return (math.degrees(radians) + 360.0) % 360.0
+
+
+def haversine(lat1: float, lon1: float, lat2: float, lon2: float) -> DistanceResult:
+    """Great-circle distance on a spherical Earth.
+
+    Args:
+        lat1, lon1: Start coordinate in degrees
+        lat2, lon2: End coordinate in degrees
+
+    Returns:
+        DistanceResult with great-circle bearings
+
+    Raises:
+        ValueError: If a coordinate is out of range
+    """
+    _validate(lat1, lon1)
+    _validate(lat2, lon2)
+    phi1, phi2 = math.radians(lat1), math.radians(lat2)
+    d_phi = phi2 - phi1
+    d_lambda = math.radians(lon2 - lon1)
+
+    a = math.sin(d_phi / 2) ** 2 + math.cos(phi1) * math.cos(phi2) * math.sin(d_lambda / 2) ** 2
+    meters = 2 * EARTH_RADIUS_METERS * math.asin(min(1.0, math.sqrt(a)))
+
+    if meters == 0:
+        return DistanceResult(0.0, None, None, DistanceMethod.HAVERSINE)
+
+    def bearing(p1, p2, dl):
+        return math.atan2(
+            math.sin(dl) * math.cos(p2),
+            math.cos(p1) * math.sin(p2) - math.sin(p1) * math.cos(p2) * math.cos(dl),
+        )
+
+    initial = bearing(phi1, phi2, d_lambda)
+    # Final bearing is the reverse of the initial bearing from the end point
+    final = bearing(phi2, phi1, -d_lambda) + math.pi
+    return DistanceResult(
+        meters, _normalize_bearing(initial), _normalize_bearing(final), DistanceMethod.HAVERSINE
+    )
+
+
+def vincenty(
+    lat1: float,
+    lon1: float,
+    lat2: float,
+    lon2: float,
+    max_iterations: int = 200,
+    tolerance: float = 1e-12,
+) -> DistanceResult:
+    """Inverse geodesic on the WGS84 ellipsoid (Vincenty, 1975).
+
+    Args:
+        lat1, lon1: Start coordinate in degrees
+        lat2, lon2: End coordinate in degrees
+        max_iterations: Iteration limit
+        tolerance: Convergence threshold on lambda (radians)
+
+    Returns:
+        DistanceResult with ellipsoidal bearings
+
+    Raises:
+        ValueError: If a coordinate is out of range
+        VincentyConvergenceError: If the iteration does not converge
+    """
+    _validate(lat1, lon1)
+    _validate(lat2, lon2)
+    a, b, f = WGS84_A, WGS84_B, WGS84_F
+
+    L = math.radians(lon2 - lon1)
+    U1 = math.atan((1 - f) * math.tan(math.radians(lat1)))
+    U2 = math.atan((1 - f) * math.tan(math.radians(lat2)))
+    sin_u1, cos_u1 = math.sin(U1), math.cos(U1)
+    sin_u2, cos_u2 = math.sin(U2), math.cos(U2)
+
+    lam = L
+    for _ in range(max_iterations):
+        sin_lam, cos_lam = math.sin(lam), math.cos(lam)
+        sin_sigma = math.hypot(
+            cos_u2 * sin_lam, cos_u1 * sin_u2 - sin_u1 * cos_u2 * cos_lam
+        )
+        if sin_sigma == 0:
+            return DistanceResult(0.0, None, None, DistanceMethod.VINCENTY)
+        cos_sigma = sin_u1 * sin_u2 + cos_u1 * cos_u2 * cos_lam
+        sigma = math.atan2(sin_sigma, cos_sigma)
+        sin_alpha = cos_u1 * cos_u2 * sin_lam / sin_sigma
+        cos_sq_alpha = 1 - sin_alpha ** 2
+        # Equatorial lines have cos_sq_alpha == 0
+        cos_2sigma_m = (
+            cos_sigma - 2 * sin_u1 * sin_u2 / cos_sq_alpha if cos_sq_alpha != 0 else 0.0
+        )
+        C = f / 16 * cos_sq_alpha * (4 + f * (4 - 3 * cos_sq_alpha))
+        lam_prev = lam
+        lam = L + (1 - C) * f * sin_alpha * (
+            sigma + C * sin_sigma * (
+                cos_2sigma_m + C * cos_sigma * (-1 + 2 * cos_2sigma_m ** 2)
+            )
+        )
+        if abs(lam - lam_prev) < tolerance:
+            break
+    else:
+        raise VincentyConvergenceError(
+            f"Vincenty formula did not converge for ({lat1}, {lon1}) -> ({lat2}, {lon2})"
+        )
+
+    u_sq = cos_sq_alpha * (a ** 2 - b ** 2) / b ** 2
+    A = 1 + u_sq / 16384 * (4096 + u_sq * (-768 + u_sq * (320 - 175 * u_sq)))
+    B = u_sq / 1024 * (256 + u_sq * (-128 + u_sq * (74 - 47 * u_sq)))
+    delta_sigma = B * sin_sigma * (
+        cos_2sigma_m + B / 4 * (
+            cos_sigma * (-1 + 2 * cos_2sigma_m ** 2)
+            - B / 6 * cos_2sigma_m * (-3 + 4 * sin_sigma ** 2) * (-3 + 4 * cos_2sigma_m ** 2)
+        )
+    )
+    meters = b * A * (sigma - delta_sigma)
+
+    initial = math.atan2(cos_u2 * sin_lam, cos_u1 * sin_u2 - sin_u1 * cos_u2 * cos_lam)
+    final = math.atan2(cos_u1 * sin_lam, -sin_u1 * cos_u2 + cos_u1 * sin_u2 * cos_lam)
+    return DistanceResult(
+        meters, _normalize_bearing(initial), _normalize_bearing(final), DistanceMethod.VINCENTY
+    )
+
+
+def distance(
+    lat1: float, lon1: float, lat2: float, lon2: float, method: str = DistanceMethod.VINCENTY
+) -> DistanceResult:
+    """Distance by the named method.
+
+    Vincenty falls back to haversine for near-antipodal points where it does
+    not converge; the result's `method` reports which one was used.
+
+    Raises:
+        ValueError: If a coordinate or the method is invalid
+    """
+    if method == DistanceMethod.HAVERSINE:
+        return haversine(lat1, lon1, lat2, lon2)
+    if method == DistanceMethod.VINCENTY:
+        try:
+            return vincenty(lat1, lon1, lat2, lon2)
+        except VincentyConvergenceError:
+            return haversine(lat1, lon1, lat2, lon2)
+    raise ValueError(
+        f"Unknown distance method {method!r}; expected one of {', '.join(DistanceMethod.ALL)}"
+    )
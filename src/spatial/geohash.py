@@ -0,0 +1,219 @@
+"""Geohash encoding, decoding and neighbor computation.
+
+A geohash interleaves longitude and latitude bisection bits and encodes
+them in base32; each additional character narrows the cell by 5 bits, so
+locations sharing a prefix fall in the same cell.
+"""
+
+from typing import Dict, Tuple
+
+from src.spatial.geometry import BoundingBox
+
+BASE32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+_DECODE = {c: i for i, c in enumerate(BASE32)}
+
+MAX_PRECISION = 12
+
+# Approximate cell size (width, height) in meters at the equator by precision
+CELL_SIZE_METERS = {
+    1: (5_009_400, 4_992_600),
+    2: (1_252_300, 624_100),
+    3: (156_500, 156_000),
+    4: (39_100, 19_500),
+    5: (4_900, 4_900),
+    6: (1_200, 609.4),
+    7: (152.9, 152.4),
+    8: (38.2, 19.0),
+    9: (4.8, 4.8),
+    10: (1.2, 0.595),
+    11: (0.149, 0.149),
+    12: (0.037, 0.019),
+}
+
+DIRECTIONS = ("n", "ne", "e", "se", "s", "sw", "w", "nw")
+_OFFSETS = {
+    "n": (0, 1), "ne": (1, 1), "e": (1, 0), "se": (1, -1),
+    "s": (0, -1), "sw": (-1, -1), "w": (-1, 0), "nw": (-1, 1),
+}
+
+
+def validate(geohash: str) -> str:
+    """Normalize and validate a geohash.
+
+    Args:
+        geohash: Geohash string (case-insensitive)
+
+    Returns:
+        Lowercase geohash
+
+    Raises:
+        ValueError: If empty, too long or containing invalid characters
+    """
+    value = (geohash or "").strip().lower()
+    if not 1 <= len(value) <= MAX_PRECISION:
+        raise ValueError(f"Geohash must be 1-{MAX_PRECISION} characters: {geohash!r}")
+    invalid = set(value) - set(BASE32)
+    if invalid:
+        raise ValueError(f"Invalid geohash characters {sorted(invalid)} in {geohash!r}")
+    return value
+
+
+def encode(latitude: float, longitude: float, precision: int = 9) -> str:
+    """Encode a coordinate as a geohash.
+
+    Args:
+        latitude: Latitude in degrees (-90 to 90)
+        longitude: Longitude in degrees (-180 to 180)
+        precision: Number of characters (1-12)
+
+    Returns:
+        Geohash string
+
+    Raises:
+        ValueError: If the coordinate or precision is out of range
+    """
+    if not -90 <= latitude <= 90:
+        raise ValueError(f"Latitude out of range: {latitude}")
+    if not -180 <= longitude <= 180:
+        raise ValueError(f"Longitude out of range: {longitude}")
+    if not 1 <= precision <= MAX_PRECISION:
+        raise ValueError(f"Precision must be 1-{MAX_PRECISION}: {precision}")
+
+    lat_range = [-90.0, 90.0]
+    lon_range = [-180.0, 180.0]
+    chars = []
+    bits = 0
+    bit_count = 0
+    even = True  # even bits refine longitude
+    while len(chars) < precision:
+        interval, value = (lon_range, longitude) if even else (lat_range, latitude)
+        mid = (interval[0] + interval[1]) / 2
+        if value >= mid:
+            bits = (bits << 1) | 1
+            interval[0] = mid
+        else:
+            bits <<= 1
+            interval[1] = mid
+        even = not even
+        bit_count += 1
+        if bit_count == 5:
+            chars.append(BASE32[bits])
+            bits = 0
+            bit_count = 0
+    return "".join(chars)
+
+
+def decode_bbox(geohash: str) -> BoundingBox:
+    """Bounding box of a geohash cell.
+
+    Raises:
+        ValueError: If the geohash is invalid
+    """
+    value = validate(geohash)
+    lat_range = [-90.0, 90.0]
+    lon_range = [-180.0, 180.0]
+    even = True
+    for char in value:
+        bits = _DECODE[char]
+        for shift in range(4, -1, -1):
+            interval = lon_range if even else lat_range
+            mid = (interval[0] + interval[1]) / 2
+            if (bits >> shift) & 1:
+                interval[0] = mid
+            else:
+                interval[1] = mid
+            even = not even
+    return BoundingBox(lon_range[0], lat_range[0], lon_range[1], lat_range[1])
+
+
+def decode(geohash: str) -> Tuple[float, float, float, float]:
+    """Decode a geohash to its cell center and error margins.
+
+    Args:
+        geohash: Geohash string
+
+    Returns:
+        (latitude, longitude, latitude_error, longitude_error) in degrees
+
+    Raises:
+        ValueError: If the geohash is invalid
+    """
+    bbox = decode_bbox(geohash)
+    lon, lat = bbox.center
+    return (
+        lat,
+        lon,
+        (bbox.max_lat - bbox.min_lat) / 2,
+        (bbox.max_lon - bbox.min_lon) / 2,
+    )
+
+
+def neighbor(geohash: str, direction: str) -> str:
+    """Adjacent cell of the same precision.
+
+    Longitude wraps across the antimeridian; latitude is clamped at the poles
+    (the cell itself is returned when there is no cell beyond the pole).
+
+    Args:
+        geohash: Geohash string
+        direction: One of n, ne, e, se, s, sw, w, nw
+
+    Returns:
+        Neighboring geohash
+
+    Raises:
+        ValueError: If the geohash or direction is invalid
+    """
+    if direction not in _OFFSETS:
+        raise ValueError(f"Invalid direction: {direction}")
+    value = validate(geohash)
+    bbox = decode_bbox(value)
+    d_lon, d_lat = _OFFSETS[direction]
+    lon, lat = bbox.center
+    width = bbox.max_lon - bbox.min_lon
+    height = bbox.max_lat - bbox.min_lat
+
+    lat += d_lat * height
+    if lat > 90 or lat < -90:
+        lat -= d_lat * height
+    lon += d_lon * width
+    if lon > 180:
+        lon -= 360
+    elif lon < -180:
+        lon += 360
+    return encode(lat, lon, len(value))
+
+
+def neighbors(geohash: str) -> Dict[str, str]:
+    """All eight neighbors of a geohash keyed by direction."""
+    return {direction: neighbor(geohash, direction) for direction in DIRECTIONS}
+
+
+def precision_for_radius(radius_meters: float) -> int:
+    """Longest precision whose cells are at least as large as a radius.
+
+    Useful to pick a prefix length for proximity bucketing: a point's cell
+    plus its neighbors then covers a circle of that radius.
+    """
+    for precision in range(MAX_PRECISION, 0, -1):
+        width, height = CELL_SIZE_METERS[precision]
+        if min(width, height) >= radius_meters:
+            return precision
+    return 1
+
+
+def prefix_range(prefix: str) -> Tuple[str, str]:
+    """Half-open string range [start, end) matching all hashes with a prefix.
+
+    Lets prefix queries use an ordinary B-tree index (geohash >= start AND
+    geohash < end) instead of LIKE.
+    """
+    value = validate(prefix)
+    last = value[-1]
+    if last == BASE32[-1]:
+        # 'z' is the highest base32 digit; shorten and bump the parent
+        if len(value) == 1:
+            return value, "~"
+        _, parent_end = prefix_range(value[:-1])
+        return value, parent_end
+    return value, value[:-1] + BASE32[_DECODE[last] + 1]
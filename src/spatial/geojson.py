@@ -0,0 +1,132 @@
+"""GeoJSON FeatureCollection loading for polygon datasets, and RFC 7946 output.
+
+Features written here follow RFC 7946: WGS84 longitude/latitude
+positions, no `crs` member, and a `bbox` of [west, south, east, north]
+on every located feature and on the collection.
+"""
+
+import json
+import logging
+from typing import Any, Dict, Iterator, List, Optional, Sequence, Tuple
+
+from src.spatial.geometry import Geometry, geometry_from_geojson
+
+logger = logging.getLogger(__name__)
+
+
+def read_polygon_features(path: str) -> List[Tuple[Dict[str, Any], Geometry]]:
+    """Read the Polygon/MultiPolygon features of a FeatureCollection file.
+
+    Features with unsupported or malformed geometry are logged and skipped.
+
+    Args:
+        path: GeoJSON file path
+
+    Returns:
+        List of (properties, geometry) pairs
+
+    Raises:
+        OSError: If the file cannot be read
+        ValueError: If the file is not a GeoJSON FeatureCollection
+    """
+    with open(path) as f:
+        try:
+            document = json.load(f)
+        except json.JSONDecodeError as e:
+            raise ValueError(f"Invalid GeoJSON in {path}: {str(e)}")
+
+    if not isinstance(document, dict) or document.get("type") != "FeatureCollection":
+        raise ValueError(f"{path} is not a GeoJSON FeatureCollection")
+
+    features = []
+    for index, feature in enumerate(document.get("features", [])):
+        try:
+            geometry = geometry_from_geojson(feature.get("geometry"))
+        except ValueError as e:
+            logger.warning(f"Skipping feature {index} in {path}: {e}")
+            continue
+        features.append((feature.get("properties") or {}, geometry))
+    return features
+
+
+def _positions(coordinates: Any) -> Iterator[Sequence[float]]:
+    """Every position of a (nested) coordinates array."""
+    if coordinates is None:
+        return
+    if not isinstance(coordinates, (list, tuple)):
+        raise TypeError(f"expected an array, got {coordinates!r}")
+    if coordinates and isinstance(coordinates[0], (int, float)):
+        yield coordinates
+        return
+    for item in coordinates:
+        yield from _positions(item)
+
+
+def geometry_bbox(geometry: Optional[Dict[str, Any]]) -> Optional[List[float]]:
+    """[west, south, east, north] of any GeoJSON geometry (None for null or empty geometry).
+
+    Raises:
+        ValueError: If the geometry is malformed
+    """
+    if geometry is None:
+        return None
+    try:
+        if geometry.get("type") == "GeometryCollection":
+            boxes = [box for box in map(geometry_bbox, geometry.get("geometries") or ()) if box is not None]
+            return merge_bboxes(boxes)
+        positions = list(_positions(geometry.get("coordinates")))
+        if not positions:
+            return None
+        lons = [float(p[0]) for p in positions]
+        lats = [float(p[1]) for p in positions]
+    except (AttributeError, IndexError, TypeError) as e:
+        raise ValueError(f"Malformed GeoJSON geometry: {str(e)}")
+    return [min(lons), min(lats), max(lons), max(lats)]
+
+
+def merge_bboxes(boxes: Sequence[Sequence[float]]) -> Optional[List[float]]:
+    """Smallest bbox holding every box (None for no boxes)."""
+    if not boxes:
+        return None
+    return [
+        min(box[0] for box in boxes),
+        min(box[1] for box in boxes),
+        max(box[2] for box in boxes),
+        max(box[3] for box in boxes),
+    ]
+
+
+def point(longitude: float, latitude: float) -> Dict[str, Any]:
+    """GeoJSON Point geometry."""
+    return {"type": "Point", "coordinates": [longitude, latitude]}
+
+
+def feature(
+    geometry: Optional[Dict[str, Any]],
+    properties: Optional[Dict[str, Any]] = None,
+    feature_id: Optional[str] = None,
+) -> Dict[str, Any]:
+    """RFC 7946 Feature, with a bbox unless the geometry is null.
+
+    Raises:
+        ValueError: If the geometry is malformed
+    """
+    document: Dict[str, Any] = {"type": "Feature"}
+    if feature_id is not None:
+        document["id"] = feature_id
+    bbox = geometry_bbox(geometry)
+    if bbox is not None:
+        document["bbox"] = bbox
+    document["geometry"] = geometry
+    document["properties"] = properties
+    return document
+
+
+def feature_collection(features: Sequence[Dict[str, Any]]) -> Dict[str, Any]:
+    """RFC 7946 FeatureCollection, with the bbox of its located features."""
+    document: Dict[str, Any] = {"type": "FeatureCollection"}
+    bbox = merge_bboxes([f["bbox"] for f in features if "bbox" in f])
+    if bbox is not None:
+        document["bbox"] = bbox
+    document["features"] = list(features)
+    return document
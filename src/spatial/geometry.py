@@ -0,0 +1,326 @@
+"""Planar geometry on longitude/latitude coordinates.
+
+Coordinates follow GeoJSON order: (longitude, latitude). Polygons are
+evaluated in the plane, which is accurate for point-in-polygon tests on
+administrative boundaries and geofences that do not cross the antimeridian.
+"""
+
+from dataclasses import dataclass
+from typing import Any, Dict, List, Sequence, Tuple, Union
+
+Coordinate = Tuple[float, float]
+Ring = List[Coordinate]
+
+
+@dataclass(frozen=True)
+class BoundingBox:
+    """Axis-aligned bounding box in degrees."""
+    min_lon: float
+    min_lat: float
+    max_lon: float
+    max_lat: float
+
+    @classmethod
+    def of_points(cls, points: Sequence[Coordinate]) -> "BoundingBox":
+        """Compute the bounding box of a sequence of (lon, lat) points."""
+        if not points:
+            raise ValueError("Cannot compute bounding box of no points")
+        lons = [p[0] for p in points]
+        lats = [p[1] for p in points]
+        return cls(min(lons), min(lats), max(lons), max(lats))
+
+    def contains(self, lon: float, lat: float) -> bool:
+        """True if the point lies inside or on the box."""
+        return (
+            self.min_lon <= lon <= self.max_lon
+            and self.min_lat <= lat <= self.max_lat
+        )
+
+    def intersects(self, other: "BoundingBox") -> bool:
+        """True if the boxes overlap (touching counts)."""
+        return not (
+            other.min_lon > self.max_lon
+            or other.max_lon < self.min_lon
+            or other.min_lat > self.max_lat
+            or other.max_lat < self.min_lat
+        )
+
+    def union(self, other: "BoundingBox") -> "BoundingBox":
+        """Smallest box containing both boxes."""
+        return BoundingBox(
+            min(self.min_lon, other.min_lon),
+            min(self.min_lat, other.min_lat),
+            max(self.max_lon, other.max_lon),
+            max(self.max_lat, other.max_lat),
+        )
+
+    @property
+    def area(self) -> float:
+        """Area in square degrees."""
+        return (self.max_lon - self.min_lon) * (self.max_lat - self.min_lat)
+
+    @property
+    def center(self) -> Coordinate:
+        """Center point (lon, lat)."""
+        return (
+            (self.min_lon + self.max_lon) / 2.0,
+            (self.min_lat + self.max_lat) / 2.0,
+        )
+
+
+def point_in_ring(lon: float, lat: float, ring: Sequence[Coordinate]) -> bool:
+    """Ray-casting point-in-ring test (even-odd rule).
+
+    Args:
+        lon: Point longitude
+        lat: Point latitude
+        ring: Ring vertices; closing vertex optional
+
+    Returns:
+        True if the point is inside the ring
+    """
+    inside = False
+    count = len(ring)
+    j = count - 1
+    for i in range(count):
+        xi, yi = ring[i][0], ring[i][1]
+        xj, yj = ring[j][0], ring[j][1]
+        if (yi > lat) != (yj > lat):
+            x_cross = (xj - xi) * (lat - yi) / (yj - yi) + xi
+            if lon < x_cross:
+                inside = not inside
+        j = i
+    return inside
+
+
+def ring_area(ring: Sequence[Coordinate]) -> float:
+    """Signed planar area of a ring (positive when counter-clockwise)."""
+    area = 0.0
+    count = len(ring)
+    for i in range(count):
+        x1, y1 = ring[i][0], ring[i][1]
+        x2, y2 = ring[(i + 1) % count][0], ring[(i + 1) % count][1]
+        area += x1 * y2 - x2 * y1
+    return area / 2.0
+
+
+def ring_centroid(ring: Sequence[Coordinate]) -> Coordinate:
+    """Planar centroid of a ring (vertex average for degenerate rings)."""
+    area = ring_area(ring)
+    if area == 0:
+        count = len(ring)
+        return sum(p[0] for p in ring) / count, sum(p[1] for p in ring) / count
+    cx = cy = 0.0
+    count = len(ring)
+    for i in range(count):
+        x1, y1 = ring[i][0], ring[i][1]
+        x2, y2 = ring[(i + 1) % count][0], ring[(i + 1) % count][1]
+        cross = x1 * y2 - x2 * y1
+        cx += (x1 + x2) * cross
+        cy += (y1 + y2) * cross
+    return cx / (6 * area), cy / (6 * area)
+
+
+def oriented_ring(ring: Sequence[Coordinate], counterclockwise: bool = True) -> Ring:
+    """Ring with the requested winding, reversed if needed."""
+    points = list(ring)
+    if (ring_area(points) > 0) != counterclockwise:
+        points.reverse()
+    return points
+
+
+def _weighted_centroid(parts: Sequence[Tuple[float, Coordinate]]) -> Coordinate:
+    total = sum(weight for weight, _ in parts)
+    if total <= 0:
+        return parts[0][1]
+    return (
+        sum(weight * c[0] for weight, c in parts) / total,
+        sum(weight * c[1] for weight, c in parts) / total,
+    )
+
+
+class Polygon:
+    """Polygon with one exterior ring and optional holes."""
+
+    def __init__(self, exterior: Sequence[Coordinate], holes: Sequence[Sequence[Coordinate]] = ()):
+        """Initialize polygon.
+
+        Args:
+            exterior: Exterior ring (lon, lat) vertices
+            holes: Interior rings
+
+        Raises:
+            ValueError: If a ring has fewer than three distinct vertices
+        """
+        self.exterior: Ring = _clean_ring(exterior)
+        self.holes: List[Ring] = [_clean_ring(hole) for hole in holes]
+        self.bbox = BoundingBox.of_points(self.exterior)
+
+    def contains(self, lon: float, lat: float) -> bool:
+        """True if the point is inside the polygon (and not in a hole)."""
+        if not self.bbox.contains(lon, lat):
+            return False
+        if not point_in_ring(lon, lat, self.exterior):
+            return False
+        return not any(point_in_ring(lon, lat, hole) for hole in self.holes)
+
+    @property
+    def area(self) -> float:
+        """Planar area in square degrees (holes subtracted)."""
+        return abs(ring_area(self.exterior)) - sum(
+            abs(ring_area(hole)) for hole in self.holes
+        )
+
+    @property
+    def centroid(self) -> Coordinate:
+        """Planar (lon, lat) centroid; may lie outside a concave polygon."""
+        exterior = (abs(ring_area(self.exterior)), ring_centroid(self.exterior))
+        if not self.holes:
+            return exterior[1]
+        holes = [(-abs(ring_area(hole)), ring_centroid(hole)) for hole in self.holes]
+        return _weighted_centroid([exterior, *holes])
+
+    def oriented(self) -> "Polygon":
+        """Same polygon wound per RFC 7946: exterior counterclockwise, holes clockwise."""
+        return Polygon(
+            oriented_ring(self.exterior),
+            [oriented_ring(hole, counterclockwise=False) for hole in self.holes],
+        )
+
+    def to_geojson(self) -> Dict[str, Any]:
+        """GeoJSON geometry with closed rings."""
+        return {
+            "type": "Polygon",
+            "coordinates": [
+                _closed(self.exterior),
+                *[_closed(hole) for hole in self.holes],
+            ],
+        }
+
+
+class MultiPolygon:
+    """Collection of polygons treated as one geometry."""
+
+    def __init__(self, polygons: Sequence[Polygon]):
+        """Initialize multipolygon.
+
+        Raises:
+            ValueError: If no polygons are given
+        """
+        if not polygons:
+            raise ValueError("MultiPolygon requires at least one polygon")
+        self.polygons: List[Polygon] = list(polygons)
+        bbox = self.polygons[0].bbox
+        for polygon in self.polygons[1:]:
+            bbox = bbox.union(polygon.bbox)
+        self.bbox = bbox
+
+    def contains(self, lon: float, lat: float) -> bool:
+        """True if any member polygon contains the point."""
+        if not self.bbox.contains(lon, lat):
+            return False
+        return any(polygon.contains(lon, lat) for polygon in self.polygons)
+
+    @property
+    def area(self) -> float:
+        """Planar area in square degrees."""
+        return sum(polygon.area for polygon in self.polygons)
+
+    @property
+    def centroid(self) -> Coordinate:
+        """Area-weighted (lon, lat) centroid of the member polygons."""
+        return _weighted_centroid([(polygon.area, polygon.centroid) for polygon in self.polygons])
+
+    def oriented(self) -> "MultiPolygon":
+        """Same multipolygon with every member wound per RFC 7946."""
+        return MultiPolygon([polygon.oriented() for polygon in self.polygons])
+
+    def to_geojson(self) -> Dict[str, Any]:
+        """GeoJSON geometry with closed rings."""
+        return {
+            "type": "MultiPolygon",
+            "coordinates": [
+                polygon.to_geojson()["coordinates"] for polygon in self.polygons
+            ],
+        }
+
+
+Geometry = Union[Polygon, MultiPolygon]
+
+
+def _clean_ring(ring: Sequence[Sequence[float]]) -> Ring:
+    """Convert to (lon, lat) tuples and drop the closing vertex."""
+    points = [(float(p[0]), float(p[1])) for p in ring]
+    if len(points) > 1 and points[0] == points[-1]:
+        points = points[:-1]
+    if len(set(points)) < 3:
+        raise ValueError("Polygon ring needs at least three distinct vertices")
+    return points
+
+
+def _closed(ring: Ring) -> List[List[float]]:
+    return [list(p) for p in ring] + [list(ring[0])]
+
+
+def geometry_from_geojson(geometry: Dict[str, Any]) -> Geometry:
+    """Build a Polygon or MultiPolygon from a GeoJSON geometry object.
+
+    Args:
+        geometry: GeoJSON geometry dict
+
+    Returns:
+        Polygon or MultiPolygon
+
+    Raises:
+        ValueError: If the geometry type is unsupported or malformed
+    """
+    if not isinstance(geometry, dict):
+        raise ValueError("Geometry must be a GeoJSON object")
+    geometry_type = geometry.get("type")
+    coordinates = geometry.get("coordinates")
+    try:
+        if geometry_type == "Polygon":
+            return Polygon(coordinates[0], coordinates[1:])
+        if geometry_type == "MultiPolygon":
+            return MultiPolygon([Polygon(rings[0], rings[1:]) for rings in coordinates])
+    except (TypeError, IndexError) as e:
+        raise ValueError(f"Malformed {geometry_type} coordinates: {str(e)}")
+    raise ValueError(f"Unsupported geometry type: {geometry_type}")
+
+
+def check_lonlat(shape: Geometry) -> None:
+    """Check that every vertex is a WGS84 longitude/latitude.
+
+    Raises:
+        ValueError: For a vertex outside [-180, 180] x [-90, 90] (or NaN)
+    """
+    polygons = [shape] if isinstance(shape, Polygon) else shape.polygons
+    for polygon in polygons:
+        for ring in (polygon.exterior, *polygon.holes):
+            for lon, lat in ring:
+                if not (-180 <= lon <= 180 and -90 <= lat <= 90):
+                    raise ValueError(f"Vertex ({lon}, {lat}) is not a WGS84 longitude/latitude")
+
+
+def geojson_bbox(geometry: Dict[str, Any]) -> BoundingBox:
+    """Bounding box of a GeoJSON Polygon or MultiPolygon without building it.
+
+    Only exterior rings are scanned; holes lie inside them.
+
+    Raises:
+        ValueError: If the geometry type is unsupported or malformed
+    """
+    geometry_type = geometry.get("type") if isinstance(geometry, dict) else None
+    coordinates = geometry.get("coordinates") if geometry_type else None
+    try:
+        if geometry_type == "Polygon":
+            exteriors = [coordinates[0]]
+        elif geometry_type == "MultiPolygon":
+            exteriors = [rings[0] for rings in coordinates]
+        else:
+            raise ValueError(f"Unsupported geometry type: {geometry_type}")
+        return BoundingBox.of_points(
+            [(float(p[0]), float(p[1])) for ring in exteriors for p in ring]
+        )
+    except (TypeError, IndexError) as e:
+        raise ValueError(f"Malformed {geometry_type} coordinates: {str(e)}")
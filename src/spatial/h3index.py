@@ -0,0 +1,109 @@
+"""Uber H3 hexagonal cell helpers.
+
+Thin wrapper over the `h3` library (v4 API) that validates inputs the same
+way as the other spatial modules and reports a clear error when the
+library (the optional `h3` extra) is not installed.
+"""
+
+from typing import Iterable, List, Tuple
+
+try:
+    import h3
+except ImportError:  # pragma: no cover - exercised when h3 is not installed
+    h3 = None
+
+MIN_RESOLUTION = 0
+MAX_RESOLUTION = 15
+
+
+def _require_h3():
+    if h3 is None:
+        raise RuntimeError("H3 support requires the optional 'h3' extra (pip install -e '.[h3]')")
+    return h3
+
+
+def validate_resolution(resolution: int) -> int:
+    """Check an H3 resolution.
+
+    Raises:
+        ValueError: If the resolution is outside 0-15
+    """
+    if not MIN_RESOLUTION <= resolution <= MAX_RESOLUTION:
+        raise ValueError(
+            f"H3 resolution must be {MIN_RESOLUTION}-{MAX_RESOLUTION}: {resolution}"
+        )
+    return resolution
+
+
+def latlng_to_cell(latitude: float, longitude: float, resolution: int) -> str:
+    """H3 cell containing a coordinate.
+
+    Args:
+        latitude: Latitude in degrees (-90 to 90)
+        longitude: Longitude in degrees (-180 to 180)
+        resolution: H3 resolution (0 coarsest - 15 finest)
+
+    Returns:
+        H3 cell index as a hex string
+
+    Raises:
+        ValueError: If the coordinate or resolution is invalid
+        RuntimeError: If the h3 package is not installed
+    """
+    if not -90 <= latitude <= 90:
+        raise ValueError(f"Latitude out of range: {latitude}")
+    if not -180 <= longitude <= 180:
+        raise ValueError(f"Longitude out of range: {longitude}")
+    validate_resolution(resolution)
+    return _require_h3().latlng_to_cell(latitude, longitude, resolution)
+
+
+def validate_cell(cell: str) -> str:
+    """Normalize and validate an H3 cell index.
+
+    Raises:
+        ValueError: If the index is not a valid H3 cell
+        RuntimeError: If the h3 package is not installed
+    """
+    value = (cell or "").strip().lower()
+    if not _require_h3().is_valid_cell(value):
+        raise ValueError(f"Invalid H3 cell: {cell!r}")
+    return value
+
+
+def cell_resolution(cell: str) -> int:
+    """Resolution of a valid H3 cell."""
+    return _require_h3().get_resolution(validate_cell(cell))
+
+
+def cell_to_latlng(cell: str) -> Tuple[float, float]:
+    """Center (latitude, longitude) of an H3 cell."""
+    return _require_h3().cell_to_latlng(validate_cell(cell))
+
+
+def cell_boundary(cell: str) -> List[Tuple[float, float]]:
+    """Boundary vertices of an H3 cell as (longitude, latitude) pairs."""
+    return [
+        (lng, lat) for lat, lng in _require_h3().cell_to_boundary(validate_cell(cell))
+    ]
+
+
+def cell_area_km2(cell: str) -> float:
+    """Area of an H3 cell in square kilometers."""
+    return _require_h3().cell_area(validate_cell(cell), unit="km^2")
+
+
+def grid_disk(cell: str, k: int) -> List[str]:
+    """Cells within k grid steps of a cell (including the cell itself)."""
+    if k < 0:
+        raise ValueError(f"k must be non-negative: {k}")
+    return sorted(_require_h3().grid_disk(validate_cell(cell), k))
+
+
+def cells_for_points(
+    points: Iterable[Tuple[float, float]], resolution: int
+) -> List[str]:
+    """H3 cell of each (latitude, longitude) point, in input order."""
+    validate_resolution(resolution)
+    lib = _require_h3()
+    return [lib.latlng_to_cell(lat, lon, resolution) for lat, lon in points]
@@ -0,0 +1,136 @@
+"""k-d tree for nearest-neighbor queries on geographic points.
+
+Points are stored as unit vectors on the sphere, so straight-line (chord)
+distance is monotonic in great-circle distance. This keeps queries correct
+across the antimeridian and near the poles, where lon/lat trees break.
+"""
+
+import heapq
+import math
+from typing import Generic, List, Optional, Sequence, Tuple, TypeVar
+
+from src.spatial.distance import EARTH_RADIUS_METERS
+
+T = TypeVar("T")
+
+Vector = Tuple[float, float, float]
+
+
+def _to_vector(latitude: float, longitude: float) -> Vector:
+    phi = math.radians(latitude)
+    lam = math.radians(longitude)
+    return (math.cos(phi) * math.cos(lam), math.cos(phi) * math.sin(lam), math.sin(phi))
+
+
+def chord_to_meters(chord: float) -> float:
+    """Great-circle distance for a unit-sphere chord length."""
+    return 2 * EARTH_RADIUS_METERS * math.asin(min(1.0, chord / 2))
+
+
+def meters_to_chord(meters: float) -> float:
+    """Unit-sphere chord length for a great-circle distance."""
+    angle = min(math.pi, meters / EARTH_RADIUS_METERS)
+    return 2 * math.sin(angle / 2)
+
+
+class KDTree(Generic[T]):
+    """Static 3-D k-d tree over (latitude, longitude, item) points."""
+
+    def __init__(self, points: Sequence[Tuple[float, float, T]]):
+        """Build the tree.
+
+        Args:
+            points: (latitude, longitude, item) tuples
+
+        Raises:
+            ValueError: If a coordinate is out of range
+        """
+        vectors: List[Tuple[Vector, T]] = []
+        for latitude, longitude, item in points:
+            if not -90 <= latitude <= 90 or not -180 <= longitude <= 180:
+                raise ValueError(f"Coordinate out of range: ({latitude}, {longitude})")
+            vectors.append((_to_vector(latitude, longitude), item))
+        self._size = len(vectors)
+        # Implicit balanced tree: node = (vector, item, axis, left, right)
+        self._root = self._build(vectors, 0)
+
+    def __len__(self) -> int:
+        return self._size
+
+    def _build(self, vectors: List[Tuple[Vector, T]], depth: int):
+        if not vectors:
+            return None
+        axis = depth % 3
+        vectors.sort(key=lambda entry: entry[0][axis])
+        median = len(vectors) // 2
+        vector, item = vectors[median]
+        return (
+            vector,
+            item,
+            axis,
+            self._build(vectors[:median], depth + 1),
+            self._build(vectors[median + 1:], depth + 1),
+        )
+
+    def nearest(
+        self,
+        latitude: float,
+        longitude: float,
+        k: int = 1,
+        max_distance_meters: Optional[float] = None,
+    ) -> List[Tuple[float, T]]:
+        """Find the k nearest points, optionally within a radius.
+
+        Args:
+            latitude: Query latitude
+            longitude: Query longitude
+            k: Maximum number of results
+            max_distance_meters: Only points within this great-circle distance
+
+        Returns:
+            (distance_meters, item) pairs, nearest first
+
+        Raises:
+            ValueError: If k is less than 1 or the coordinate is out of range
+        """
+        if k < 1:
+            raise ValueError("k must be at least 1")
+        if not -90 <= latitude <= 90 or not -180 <= longitude <= 180:
+            raise ValueError(f"Coordinate out of range: ({latitude}, {longitude})")
+
+        target = _to_vector(latitude, longitude)
+        limit_sq = math.inf
+        if max_distance_meters is not None:
+            limit_sq = meters_to_chord(max_distance_meters) ** 2
+
+        # Max-heap of the best k as (-distance_sq, tiebreak, item)
+        best: List[Tuple[float, int, T]] = []
+        counter = 0
+        stack = [self._root]
+        while stack:
+            node = stack.pop()
+            if node is None:
+                continue
+            vector, item, axis, left, right = node
+            dist_sq = (
+                (vector[0] - target[0]) ** 2
+                + (vector[1] - target[1]) ** 2
+                + (vector[2] - target[2]) ** 2
+            )
+            if dist_sq <= limit_sq:
+                counter += 1
+                if len(best) < k:
+                    heapq.heappush(best, (-dist_sq, counter, item))
+                elif dist_sq < -best[0][0]:
+                    heapq.heapreplace(best, (-dist_sq, counter, item))
+
+            bound = limit_sq if len(best) < k else min(limit_sq, -best[0][0])
+            delta = target[axis] - vector[axis]
+            near, far = (left, right) if delta < 0 else (right, left)
+            # Push far first so the near side is explored first
+            if delta * delta <= bound:
+                stack.append(far)
+            stack.append(near)
+
+        results = sorted((-neg_sq, tiebreak, item) for neg_sq, tiebreak, item in best)
+        return [(chord_to_meters(math.sqrt(dist_sq)), item) for dist_sq, _, item in results]
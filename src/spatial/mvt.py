@@ -0,0 +1,266 @@
+"""Mapbox Vector Tiles (MVT 2.1) in the Web Mercator tile grid.
+
+A tile z/x/y covers 1/2^z of the Web Mercator square in each direction,
+x growing east and y growing south from the north-west corner. Features
+are given in tile coordinates, 0 to EXTENT across the tile with y down,
+and geometry reaching past the tile is clipped to a small buffer around
+it so renderers draw no seams at tile edges.
+
+The protobuf encoding of vector_tile.proto is written by hand (the
+message set is small and fixed), so no protobuf runtime is needed.
+"""
+
+import math
+import struct
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from src.spatial.crs import WEB_MERCATOR_MAX_LATITUDE
+from src.spatial.geometry import BoundingBox
+
+MEDIA_TYPE = "application/vnd.mapbox-vector-tile"
+
+# Tile coordinate range, and the margin kept around it when clipping
+EXTENT = 4096
+BUFFER = 64
+
+MAX_ZOOM = 24
+
+Point = Tuple[float, float]
+
+# Geometry types and commands of vector_tile.proto
+_POINT = 1
+_POLYGON = 3
+_MOVE_TO = 1
+_LINE_TO = 2
+_CLOSE_PATH = 7
+
+
+def validate_tile(z: int, x: int, y: int) -> None:
+    """Check a tile address.
+
+    Raises:
+        ValueError: If z is outside 0-MAX_ZOOM or x/y outside the zoom's grid
+    """
+    if not 0 <= z <= MAX_ZOOM:
+        raise ValueError(f"Zoom must be between 0 and {MAX_ZOOM}, got {z}")
+    size = 1 << z
+    if not (0 <= x < size and 0 <= y < size):
+        raise ValueError(f"Tile {x}/{y} is outside zoom {z} (0-{size - 1})")
+
+
+def _latitude(world_y: float) -> float:
+    return math.degrees(math.atan(math.sinh(math.pi * (1 - 2 * world_y))))
+
+
+def tile_bbox(z: int, x: int, y: int, buffer: int = 0) -> BoundingBox:
+    """Longitude/latitude box of a tile, widened by `buffer` tile units on every side."""
+    size = 1 << z
+    margin = buffer / EXTENT
+    return BoundingBox(
+        min_lon=(x - margin) / size * 360 - 180,
+        min_lat=_latitude(min((y + 1 + margin) / size, 1.0)),
+        max_lon=(x + 1 + margin) / size * 360 - 180,
+        max_lat=_latitude(max((y - margin) / size, 0.0)),
+    )
+
+
+def project(z: int, x: int, y: int, lon: float, lat: float) -> Point:
+    """Tile coordinates of a longitude/latitude in tile z/x/y (may fall outside 0-EXTENT)."""
+    lat = max(-WEB_MERCATOR_MAX_LATITUDE, min(WEB_MERCATOR_MAX_LATITUDE, lat))
+    size = 1 << z
+    world_x = (lon + 180) / 360
+    radians = math.radians(lat)
+    world_y = (1 - math.log(math.tan(radians) + 1 / math.cos(radians)) / math.pi) / 2
+    return (world_x * size - x) * EXTENT, (world_y * size - y) * EXTENT
+
+
+def _clip_edge(ring: List[Point], axis: int, bound: float, keep_below: bool) -> List[Point]:
+    """One Sutherland-Hodgman pass: the part of a ring on one side of a line."""
+    def inside(point: Point) -> bool:
+        return point[axis] <= bound if keep_below else point[axis] >= bound
+
+    clipped: List[Point] = []
+    for index, current in enumerate(ring):
+        previous = ring[index - 1]
+        if inside(current):
+            if not inside(previous):
+                clipped.append(_intersect(previous, current, axis, bound))
+            clipped.append(current)
+        elif inside(previous):
+            clipped.append(_intersect(previous, current, axis, bound))
+    return clipped
+
+
+def _intersect(a: Point, b: Point, axis: int, bound: float) -> Point:
+    t = (bound - a[axis]) / (b[axis] - a[axis])
+    if axis == 0:
+        return bound, a[1] + t * (b[1] - a[1])
+    return a[0] + t * (b[0] - a[0]), bound
+
+
+def clip_ring(ring: Sequence[Point], buffer: int = BUFFER) -> List[Point]:
+    """Part of a ring (tile coordinates, unclosed) inside the buffered tile; empty if none."""
+    clipped = list(ring)
+    low, high = -buffer, EXTENT + buffer
+    for axis in (0, 1):
+        clipped = _clip_edge(clipped, axis, low, keep_below=False) if clipped else clipped
+        clipped = _clip_edge(clipped, axis, high, keep_below=True) if clipped else clipped
+    return clipped
+
+
+def _area(ring: Sequence[Tuple[int, int]]) -> int:
+    """Twice the signed area by the surveyor's formula (positive: clockwise with y down)."""
+    return sum(
+        ring[index - 1][0] * point[1] - point[0] * ring[index - 1][1]
+        for index, point in enumerate(ring)
+    )
+
+
+def _round_ring(ring: Sequence[Point]) -> List[Tuple[int, int]]:
+    """Integer ring without repeated vertices."""
+    rounded: List[Tuple[int, int]] = []
+    for px, py in ring:
+        point = (int(round(px)), int(round(py)))
+        if not rounded or rounded[-1] != point:
+            rounded.append(point)
+    if len(rounded) > 1 and rounded[0] == rounded[-1]:
+        rounded.pop()
+    return rounded
+
+
+def _zigzag(value: int) -> int:
+    return (value << 1) ^ (value >> 63)
+
+
+def _varint(value: int) -> bytes:
+    out = bytearray()
+    while True:
+        byte = value & 0x7F
+        value >>= 7
+        if value:
+            out.append(byte | 0x80)
+        else:
+            out.append(byte)
+            return bytes(out)
+
+
+def _field(number: int, wire_type: int) -> bytes:
+    return _varint((number << 3) | wire_type)
+
+
+def _length_delimited(number: int, data: bytes) -> bytes:
+    return _field(number, 2) + _varint(len(data)) + data
+
+
+def _packed(number: int, values: Sequence[int]) -> bytes:
+    return _length_delimited(number, b"".join(_varint(value) for value in values))
+
+
+def _command(command: int, count: int) -> int:
+    return (command & 0x7) | (count << 3)
+
+
+def _value(value: Any) -> bytes:
+    """Value message of a property (strings, booleans, integers and floats)."""
+    if isinstance(value, bool):
+        return _field(7, 0) + _varint(int(value))
+    if isinstance(value, int):
+        return _field(6, 0) + _varint(_zigzag(value))
+    if isinstance(value, float):
+        return _field(3, 1) + struct.pack("<d", value)
+    return _length_delimited(1, str(value).encode())
+
+
+class Layer:
+    """One named layer of a tile, collecting features in tile coordinates."""
+
+    def __init__(self, name: str):
+        """Initialize layer.
+
+        Args:
+            name: Layer name renderers select features by
+        """
+        self.name = name
+        self._keys: Dict[str, int] = {}
+        self._values: Dict[Tuple[type, Any], int] = {}
+        self._features: List[bytes] = []
+
+    def __len__(self) -> int:
+        return len(self._features)
+
+    def _tags(self, properties: Optional[Dict[str, Any]]) -> List[int]:
+        tags: List[int] = []
+        for key, value in (properties or {}).items():
+            if value is None:
+                continue
+            tags.append(self._keys.setdefault(key, len(self._keys)))
+            tags.append(self._values.setdefault((type(value), value), len(self._values)))
+        return tags
+
+    def _add(self, geometry_type: int, geometry: List[int], properties: Optional[Dict[str, Any]]) -> None:
+        feature = _packed(2, self._tags(properties)) + _field(3, 0) + _varint(geometry_type)
+        self._features.append(feature + _packed(4, geometry))
+
+    def add_point(self, point: Point, properties: Optional[Dict[str, Any]] = None) -> bool:
+        """Add a point feature; points outside the tile are skipped.
+
+        Returns:
+            True if the point was added
+        """
+        px, py = int(round(point[0])), int(round(point[1]))
+        if not (0 <= px < EXTENT and 0 <= py < EXTENT):
+            return False
+        self._add(_POINT, [_command(_MOVE_TO, 1), _zigzag(px), _zigzag(py)], properties)
+        return True
+
+    def add_polygon(
+        self, polygons: Sequence[Sequence[Sequence[Point]]], properties: Optional[Dict[str, Any]] = None
+    ) -> bool:
+        """Add a (multi)polygon feature, clipped to the buffered tile.
+
+        Args:
+            polygons: Polygons, each an exterior ring followed by its holes,
+                in tile coordinates (rings unclosed, any winding order)
+            properties: Feature attributes
+
+        Returns:
+            True if any part of the geometry lies in the tile
+        """
+        commands: List[int] = []
+        cursor = (0, 0)
+        for rings in polygons:
+            for index, ring in enumerate(rings):
+                rounded = _round_ring(clip_ring(ring))
+                area = _area(rounded) if len(rounded) >= 3 else 0
+                if area == 0:
+                    if index == 0:
+                        break  # exterior outside the tile: skip its holes too
+                    continue
+                # Exterior rings are clockwise (positive area) and holes counterclockwise
+                if (area > 0) != (index == 0):
+                    rounded.reverse()
+                commands.append(_command(_MOVE_TO, 1))
+                commands.extend((_zigzag(rounded[0][0] - cursor[0]), _zigzag(rounded[0][1] - cursor[1])))
+                commands.append(_command(_LINE_TO, len(rounded) - 1))
+                for previous, point in zip(rounded, rounded[1:]):
+                    commands.extend((_zigzag(point[0] - previous[0]), _zigzag(point[1] - previous[1])))
+                commands.append(_command(_CLOSE_PATH, 1))
+                cursor = rounded[-1]
+        if not commands:
+            return False
+        self._add(_POLYGON, commands, properties)
+        return True
+
+    def encode(self) -> bytes:
+        """Layer message of vector_tile.proto."""
+        data = _length_delimited(1, self.name.encode())
+        data += b"".join(_length_delimited(2, feature) for feature in self._features)
+        data += b"".join(_length_delimited(3, key.encode()) for key in self._keys)
+        data += b"".join(_length_delimited(4, _value(value)) for _, value in self._values)
+        data += _field(5, 0) + _varint(EXTENT)
+        return data + _field(15, 0) + _varint(2)
+
+
+def encode_tile(layers: Sequence[Layer]) -> bytes:
+    """Tile message of the layers that have features (empty bytes: empty tile)."""
+    return b"".join(_length_delimited(3, layer.encode()) for layer in layers if len(layer))
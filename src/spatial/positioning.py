@@ -0,0 +1,124 @@
+"""Position estimates from radio anchors with known locations.
+
+An anchor is a transmitter (WiFi access point, cell tower) whose location
+is known, together with an estimate of the device's distance from it. The
+device position is the centroid of the anchors weighted by inverse squared
+range, so near anchors dominate; the centroid is taken on unit vectors so
+it stays correct across the antimeridian.
+
+Signal strength is converted to range with the log-distance path loss
+model: received power falls by 10 * n dB per decade of distance, where the
+exponent n is about 2 in free space and 3-4 indoors.
+"""
+
+import math
+from dataclasses import dataclass
+from typing import List, Sequence
+
+from src.spatial.distance import haversine
+
+# Ranges below this are treated as equal, so one anchor cannot take all the weight
+_MIN_RANGE_M = 1.0
+
+
+@dataclass(frozen=True)
+class RangedAnchor:
+    """Transmitter at a known location, at an estimated range from the device."""
+    latitude: float
+    longitude: float
+    range_m: float
+
+
+@dataclass
+class PositionEstimate:
+    """Estimated device position."""
+    latitude: float
+    longitude: float
+    accuracy_m: float       # Radius expected to contain the device
+    anchors_used: int
+
+
+def log_distance_range(
+    rssi_dbm: float,
+    reference_dbm: float = -40.0,
+    path_loss_exponent: float = 3.0,
+    reference_m: float = 1.0,
+) -> float:
+    """Estimate distance from received signal strength.
+
+    Args:
+        rssi_dbm: Received signal strength
+        reference_dbm: Signal strength at reference_m from the transmitter
+        path_loss_exponent: Path loss exponent n
+        reference_m: Reference distance
+
+    Returns:
+        Estimated distance in meters (never below reference_m)
+
+    Raises:
+        ValueError: If the path loss exponent is not positive
+    """
+    if path_loss_exponent <= 0:
+        raise ValueError(f"Path loss exponent must be positive: {path_loss_exponent}")
+    loss = max(0.0, reference_dbm - rssi_dbm)
+    return reference_m * 10 ** (loss / (10 * path_loss_exponent))
+
+
+def consistent_cluster(anchors: Sequence[RangedAnchor], max_spread_m: float) -> List[RangedAnchor]:
+    """The largest group of anchors within max_spread_m of one of them.
+
+    A device cannot hear two transmitters kilometers apart, so anchors
+    outside the group are stale entries (moved access points, re-used
+    cell IDs) and are dropped before estimating. Among equally large
+    groups, the one around the shortest-range anchor wins.
+
+    Returns:
+        Matching anchors, nearest first (empty if there are no anchors)
+    """
+    ordered = sorted(anchors, key=lambda a: a.range_m)
+    best: List[RangedAnchor] = []
+    for seed in ordered:
+        group = [
+            anchor
+            for anchor in ordered
+            if haversine(seed.latitude, seed.longitude, anchor.latitude, anchor.longitude).meters
+            <= max_spread_m
+        ]
+        if len(group) > len(best):
+            best = group
+    return best
+
+
+def weighted_centroid(anchors: Sequence[RangedAnchor]) -> PositionEstimate:
+    """Estimate the device position from ranged anchors.
+
+    The accuracy radius is the weighted mean, over anchors, of the larger
+    of each anchor's range and its distance from the estimate: a single
+    anchor gives its own range, and anchors that disagree widen the radius.
+
+    Raises:
+        ValueError: If no anchors are given
+    """
+    if not anchors:
+        raise ValueError("At least one anchor is required")
+
+    weights = [1.0 / max(a.range_m, _MIN_RANGE_M) ** 2 for a in anchors]
+    x = y = z = 0.0
+    for anchor, weight in zip(anchors, weights):
+        phi, lam = math.radians(anchor.latitude), math.radians(anchor.longitude)
+        x += weight * math.cos(phi) * math.cos(lam)
+        y += weight * math.cos(phi) * math.sin(lam)
+        z += weight * math.sin(phi)
+    latitude = math.degrees(math.atan2(z, math.hypot(x, y)))
+    longitude = math.degrees(math.atan2(y, x))
+
+    spread = sum(
+        weight * max(a.range_m, haversine(latitude, longitude, a.latitude, a.longitude).meters)
+        for a, weight in zip(anchors, weights)
+    )
+    return PositionEstimate(
+        latitude=latitude,
+        longitude=longitude,
+        accuracy_m=spread / sum(weights),
+        anchors_used=len(anchors),
+    )
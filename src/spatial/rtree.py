@@ -0,0 +1,100 @@
+"""Static R-tree built with Sort-Tile-Recursive (STR) bulk loading.
+
+Used to narrow point and box queries over many polygons down to a handful
+of candidates before running exact geometry tests.
+"""
+
+import math
+from typing import Any, Generic, List, Sequence, Tuple, TypeVar
+
+from src.spatial.geometry import BoundingBox
+
+T = TypeVar("T")
+
+
+class _Node:
+    __slots__ = ("bbox", "children", "items")
+
+    def __init__(self, bbox: BoundingBox, children=None, items=None):
+        self.bbox = bbox
+        self.children: List["_Node"] = children or []
+        self.items: List[Tuple[BoundingBox, Any]] = items or []
+
+
+class RTree(Generic[T]):
+    """Read-only R-tree over (bounding box, item) pairs."""
+
+    def __init__(self, entries: Sequence[Tuple[BoundingBox, T]], node_capacity: int = 16):
+        """Bulk-load the tree.
+
+        Args:
+            entries: (bbox, item) pairs
+            node_capacity: Maximum children per node
+        """
+        if node_capacity < 2:
+            raise ValueError("node_capacity must be at least 2")
+        self.node_capacity = node_capacity
+        self._size = len(entries)
+        self._root = self._build(list(entries)) if entries else None
+
+    def __len__(self) -> int:
+        return self._size
+
+    def _build(self, entries: List[Tuple[BoundingBox, T]]) -> _Node:
+        leaves = [
+            _Node(_union([bbox for bbox, _ in group]), items=group)
+            for group in self._tile(entries, key=lambda entry: entry[0])
+        ]
+        level = leaves
+        while len(level) > 1:
+            level = [
+                _Node(_union([node.bbox for node in group]), children=group)
+                for group in self._tile(level, key=lambda node: node.bbox)
+            ]
+        return level[0]
+
+    def _tile(self, objects: list, key) -> List[list]:
+        """Group objects into nodes by sorting into vertical slices, then by latitude."""
+        capacity = self.node_capacity
+        node_count = math.ceil(len(objects) / capacity)
+        slice_count = math.ceil(math.sqrt(node_count))
+        slice_size = slice_count * capacity
+
+        objects = sorted(objects, key=lambda o: key(o).center[0])
+        groups = []
+        for start in range(0, len(objects), slice_size):
+            vertical = sorted(
+                objects[start:start + slice_size], key=lambda o: key(o).center[1]
+            )
+            for offset in range(0, len(vertical), capacity):
+                groups.append(vertical[offset:offset + capacity])
+        return groups
+
+    def query_point(self, lon: float, lat: float) -> List[T]:
+        """Items whose bounding box contains the point."""
+        return self.query_bbox(BoundingBox(lon, lat, lon, lat))
+
+    def query_bbox(self, bbox: BoundingBox) -> List[T]:
+        """Items whose bounding box intersects the given box."""
+        if self._root is None:
+            return []
+        results: List[T] = []
+        stack = [self._root]
+        while stack:
+            node = stack.pop()
+            if not node.bbox.intersects(bbox):
+                continue
+            if node.children:
+                stack.extend(node.children)
+            else:
+                results.extend(
+                    item for item_bbox, item in node.items if item_bbox.intersects(bbox)
+                )
+        return results
+
+
+def _union(boxes: List[BoundingBox]) -> BoundingBox:
+    result = boxes[0]
+    for bbox in boxes[1:]:
+        result = result.union(bbox)
+    return result
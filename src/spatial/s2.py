@@ -0,0 +1,538 @@
+"""S2 cell ids and polygon coverings.
+
+Implements the parts of the S2 geometry library needed to pre-filter
+geofence membership: leaf cell ids for coordinates (quadratic projection
+and Hilbert curve ordering, bit-compatible with S2), cell vertices,
+parent/child navigation, tokens, and a region coverer that approximates a
+polygon with a bounded number of cells. Interior cells lie entirely inside
+the polygon, so a point in one of them needs no exact geometry test.
+
+Polygons in this service are planar in longitude/latitude (see
+src.spatial.geometry), so cells are compared against them using their
+densified lon/lat outline.
+"""
+
+import bisect
+import math
+from dataclasses import dataclass
+from typing import List, Optional, Sequence, Tuple
+
+from src.spatial.geometry import BoundingBox, Geometry, MultiPolygon, point_in_ring
+from src.spatial.rtree import RTree
+
+MAX_LEVEL = 30
+_POS_BITS = 2 * MAX_LEVEL + 1
+_MAX_SIZE = 1 << MAX_LEVEL
+
+# Hilbert curve tables (orientation bits: 1 = swap i/j, 2 = invert)
+_IJ_TO_POS = ((0, 1, 3, 2), (0, 3, 1, 2), (2, 3, 1, 0), (2, 1, 3, 0))
+_POS_TO_IJ = ((0, 1, 3, 2), (0, 2, 3, 1), (3, 2, 0, 1), (3, 1, 0, 2))
+_POS_TO_ORIENTATION = (1, 0, 0, 3)
+
+
+# --- Projection -------------------------------------------------------------
+
+def _latlng_to_xyz(latitude: float, longitude: float) -> Tuple[float, float, float]:
+    phi = math.radians(latitude)
+    theta = math.radians(longitude)
+    cos_phi = math.cos(phi)
+    return cos_phi * math.cos(theta), cos_phi * math.sin(theta), math.sin(phi)
+
+
+def _xyz_to_latlng(x: float, y: float, z: float) -> Tuple[float, float]:
+    return (
+        math.degrees(math.atan2(z, math.sqrt(x * x + y * y))),
+        math.degrees(math.atan2(y, x)),
+    )
+
+
+def _xyz_to_face_uv(x: float, y: float, z: float) -> Tuple[int, float, float]:
+    ax, ay, az = abs(x), abs(y), abs(z)
+    if ax >= ay and ax >= az:
+        face = 0 if x > 0 else 3
+    elif ay >= az:
+        face = 1 if y > 0 else 4
+    else:
+        face = 2 if z > 0 else 5
+
+    if face == 0:
+        return face, y / x, z / x
+    if face == 1:
+        return face, -x / y, z / y
+    if face == 2:
+        return face, -x / z, -y / z
+    if face == 3:
+        return face, z / x, y / x
+    if face == 4:
+        return face, z / y, -x / y
+    return face, -y / z, -x / z
+
+
+def _face_uv_to_xyz(face: int, u: float, v: float) -> Tuple[float, float, float]:
+    if face == 0:
+        return 1.0, u, v
+    if face == 1:
+        return -u, 1.0, v
+    if face == 2:
+        return -u, -v, 1.0
+    if face == 3:
+        return -1.0, -v, -u
+    if face == 4:
+        return v, -1.0, -u
+    return v, u, -1.0
+
+
+def _uv_to_st(u: float) -> float:
+    if u >= 0:
+        return 0.5 * math.sqrt(1 + 3 * u)
+    return 1 - 0.5 * math.sqrt(1 - 3 * u)
+
+
+def _st_to_uv(s: float) -> float:
+    if s >= 0.5:
+        return (1.0 / 3.0) * (4 * s * s - 1)
+    return (1.0 / 3.0) * (1 - 4 * (1 - s) * (1 - s))
+
+
+def _st_to_ij(s: float) -> int:
+    return max(0, min(_MAX_SIZE - 1, int(math.floor(_MAX_SIZE * s))))
+
+
+# --- Cell ids ---------------------------------------------------------------
+
+def _from_face_ij(face: int, i: int, j: int) -> int:
+    """Leaf cell id from face and leaf coordinates."""
+    orientation = face & 1
+    pos = 0
+    for level in range(MAX_LEVEL - 1, -1, -1):
+        ij = (((i >> level) & 1) << 1) | ((j >> level) & 1)
+        step = _IJ_TO_POS[orientation][ij]
+        pos = (pos << 2) | step
+        orientation ^= _POS_TO_ORIENTATION[step]
+    return (face << 61) | (pos << 1) | 1
+
+
+def cell_id_from_latlng(latitude: float, longitude: float, level: int = MAX_LEVEL) -> int:
+    """S2 cell id containing a coordinate.
+
+    Args:
+        latitude: Latitude in degrees (-90 to 90)
+        longitude: Longitude in degrees
+        level: Cell level (0 = face, 30 = leaf, roughly 1cm)
+
+    Returns:
+        64-bit cell id
+
+    Raises:
+        ValueError: If the latitude or level is out of range
+    """
+    if not -90 <= latitude <= 90:
+        raise ValueError(f"Latitude out of range: {latitude}")
+    face, u, v = _xyz_to_face_uv(*_latlng_to_xyz(latitude, longitude))
+    leaf = _from_face_ij(face, _st_to_ij(_uv_to_st(u)), _st_to_ij(_uv_to_st(v)))
+    return parent(leaf, level)
+
+
+def _lsb(cell_id: int) -> int:
+    return cell_id & -cell_id
+
+
+def is_valid(cell_id: int) -> bool:
+    """True if the integer is a valid S2 cell id."""
+    if not 0 < cell_id < (1 << 64):
+        return False
+    if (cell_id >> 61) > 5:
+        return False
+    return (_lsb(cell_id) & 0x1555555555555555) != 0
+
+
+def level(cell_id: int) -> int:
+    """Level of a cell (0-30)."""
+    return MAX_LEVEL - (_lsb(cell_id).bit_length() - 1) // 2
+
+
+def face(cell_id: int) -> int:
+    """Cube face (0-5) of a cell."""
+    return cell_id >> 61
+
+
+def parent(cell_id: int, parent_level: int) -> int:
+    """Ancestor of a cell at a coarser (or equal) level.
+
+    Raises:
+        ValueError: If the level is invalid or finer than the cell
+    """
+    if not 0 <= parent_level <= level(cell_id):
+        raise ValueError(f"Invalid parent level {parent_level} for level {level(cell_id)} cell")
+    lsb = 1 << (2 * (MAX_LEVEL - parent_level))
+    return (cell_id & -lsb) | lsb
+
+
+def children(cell_id: int) -> List[int]:
+    """The four child cells, in Hilbert curve order.
+
+    Raises:
+        ValueError: If the cell is a leaf
+    """
+    if level(cell_id) == MAX_LEVEL:
+        raise ValueError("Leaf cells have no children")
+    lsb = _lsb(cell_id)
+    child_lsb = lsb >> 2
+    start = cell_id - lsb + child_lsb
+    return [start + k * 2 * child_lsb for k in range(4)]
+
+
+def face_cell(face_index: int) -> int:
+    """Level-0 cell of a cube face."""
+    return (face_index << 61) | (1 << 60)
+
+
+def range_min(cell_id: int) -> int:
+    """Smallest leaf id contained in the cell."""
+    return cell_id - (_lsb(cell_id) - 1)
+
+
+def range_max(cell_id: int) -> int:
+    """Largest leaf id contained in the cell."""
+    return cell_id + (_lsb(cell_id) - 1)
+
+
+def contains(cell_id: int, other: int) -> bool:
+    """True if a cell contains another cell (or leaf)."""
+    return range_min(cell_id) <= other <= range_max(cell_id)
+
+
+def to_token(cell_id: int) -> str:
+    """Compact hex token (trailing zeros stripped), as used by S2."""
+    return f"{cell_id:016x}".rstrip("0") or "X"
+
+
+def from_token(token: str) -> int:
+    """Cell id from a token.
+
+    Raises:
+        ValueError: If the token is not a valid cell
+    """
+    value = (token or "").strip().lower()
+    if not 1 <= len(value) <= 16:
+        raise ValueError(f"Invalid S2 token: {token!r}")
+    try:
+        cell_id = int(value.ljust(16, "0"), 16)
+    except ValueError:
+        raise ValueError(f"Invalid S2 token: {token!r}")
+    if not is_valid(cell_id):
+        raise ValueError(f"Invalid S2 token: {token!r}")
+    return cell_id
+
+
+def _face_ij(cell_id: int) -> Tuple[int, int, int]:
+    """Face and level-resolution (i, j) of a cell."""
+    cell_face = face(cell_id)
+    cell_level = level(cell_id)
+    orientation = cell_face & 1
+    pos = cell_id >> 1
+    i = j = 0
+    for step in range(cell_level):
+        shift = 2 * (MAX_LEVEL - 1 - step)
+        bits = (pos >> shift) & 3
+        ij = _POS_TO_IJ[orientation][bits]
+        i = (i << 1) | (ij >> 1)
+        j = (j << 1) | (ij & 1)
+        orientation ^= _POS_TO_ORIENTATION[bits]
+    return cell_face, i, j
+
+
+def _st_to_lonlat(cell_face: int, s: float, t: float) -> Tuple[float, float]:
+    latitude, longitude = _xyz_to_latlng(
+        *_face_uv_to_xyz(cell_face, _st_to_uv(s), _st_to_uv(t))
+    )
+    return longitude, latitude
+
+
+def _outline_params(cell_id: int, points_per_edge: int):
+    """Face and (s, t) of each densified outline point, in order."""
+    cell_face, i, j = _face_ij(cell_id)
+    size = 1 << level(cell_id)
+    s0, s1 = i / size, (i + 1) / size
+    t0, t1 = j / size, (j + 1) / size
+
+    corners = [(s0, t0), (s1, t0), (s1, t1), (s0, t1)]
+    params = []
+    for index, (sa, ta) in enumerate(corners):
+        sb, tb = corners[(index + 1) % 4]
+        for step in range(points_per_edge):
+            fraction = step / points_per_edge
+            params.append((sa + (sb - sa) * fraction, ta + (tb - ta) * fraction))
+    return cell_face, params
+
+
+def cell_outline(cell_id: int, points_per_edge: int = 4) -> List[Tuple[float, float]]:
+    """Cell boundary as (longitude, latitude) points, densified along edges.
+
+    S2 edges are geodesics, which curve in lon/lat; intermediate points keep
+    the outline close to the true cell. The remaining gap between the
+    chords and the true edges is bounded by outline_error().
+    """
+    cell_face, params = _outline_params(cell_id, points_per_edge)
+    return [_st_to_lonlat(cell_face, s, t) for s, t in params]
+
+
+def _point_segment_distance(p, a, b) -> float:
+    """Planar distance from point p to segment ab."""
+    dx, dy = b[0] - a[0], b[1] - a[1]
+    length_sq = dx * dx + dy * dy
+    if length_sq == 0:
+        return math.hypot(p[0] - a[0], p[1] - a[1])
+    fraction = max(0.0, min(1.0, ((p[0] - a[0]) * dx + (p[1] - a[1]) * dy) / length_sq))
+    return math.hypot(p[0] - (a[0] + fraction * dx), p[1] - (a[1] + fraction * dy))
+
+
+# Multiplier on the sampled chord deviation; the true maximum between
+# samples of a smooth edge is close to the sampled one
+_OUTLINE_ERROR_SAFETY = 2.0
+
+
+def outline_error(cell_id: int, points_per_edge: int = 4) -> float:
+    """Upper bound (degrees) on how far true cell edges stray from cell_outline.
+
+    The deviation of each true edge from its chord is sampled at several
+    points along the chord and scaled by a safety factor.
+    """
+    cell_face, params = _outline_params(cell_id, points_per_edge)
+    outline = [_st_to_lonlat(cell_face, s, t) for s, t in params]
+    worst = 0.0
+    for index, (sa, ta) in enumerate(params):
+        sb, tb = params[(index + 1) % len(params)]
+        a, b = outline[index], outline[(index + 1) % len(outline)]
+        for fraction in (0.25, 0.5, 0.75):
+            point = _st_to_lonlat(
+                cell_face, sa + (sb - sa) * fraction, ta + (tb - ta) * fraction
+            )
+            worst = max(worst, _point_segment_distance(point, a, b))
+    return worst * _OUTLINE_ERROR_SAFETY + 1e-12
+
+
+# --- Covering ---------------------------------------------------------------
+
+_DISJOINT, _PARTIAL, _INTERIOR = 0, 1, 2
+
+
+def _segments_intersect(p1, p2, q1, q2) -> bool:
+    """Closed-segment intersection (touching counts)."""
+    def orient(a, b, c):
+        value = (b[0] - a[0]) * (c[1] - a[1]) - (b[1] - a[1]) * (c[0] - a[0])
+        return (value > 0) - (value < 0)
+
+    def on_segment(a, b, c):
+        return (
+            min(a[0], b[0]) <= c[0] <= max(a[0], b[0])
+            and min(a[1], b[1]) <= c[1] <= max(a[1], b[1])
+        )
+
+    o1, o2 = orient(p1, p2, q1), orient(p1, p2, q2)
+    o3, o4 = orient(q1, q2, p1), orient(q1, q2, p2)
+    if o1 != o2 and o3 != o4:
+        return True
+    return (
+        (o1 == 0 and on_segment(p1, p2, q1))
+        or (o2 == 0 and on_segment(p1, p2, q2))
+        or (o3 == 0 and on_segment(q1, q2, p1))
+        or (o4 == 0 and on_segment(q1, q2, p2))
+    )
+
+
+def _segment_distance(p1, p2, q1, q2) -> float:
+    """Planar distance between two closed segments (0 if they intersect)."""
+    if _segments_intersect(p1, p2, q1, q2):
+        return 0.0
+    return min(
+        _point_segment_distance(p1, q1, q2),
+        _point_segment_distance(p2, q1, q2),
+        _point_segment_distance(q1, p1, p2),
+        _point_segment_distance(q2, p1, p2),
+    )
+
+
+def _segment_index(geometry: Geometry) -> RTree:
+    """R-tree over every ring segment of a polygon."""
+    polygons = geometry.polygons if isinstance(geometry, MultiPolygon) else [geometry]
+    entries = []
+    for polygon in polygons:
+        for ring in [polygon.exterior, *polygon.holes]:
+            for index, start in enumerate(ring):
+                end = ring[(index + 1) % len(ring)]
+                entries.append((BoundingBox.of_points([start, end]), (start, end)))
+    return RTree(entries)
+
+
+_NORTH_POLE = cell_id_from_latlng(90.0, 0.0)
+_SOUTH_POLE = cell_id_from_latlng(-90.0, 0.0)
+
+
+def _relation(cell_id: int, geometry: Geometry, segments: RTree) -> int:
+    """Classify a cell against a polygon as disjoint, partial or interior.
+
+    The test runs against the densified lon/lat outline, widened by the
+    outline error bound: any polygon boundary within that distance of the
+    outline makes the cell partial, so interior and disjoint answers hold
+    for the true (geodesic-edged) cell.
+    """
+    outline = cell_outline(cell_id)
+    bbox = BoundingBox.of_points(outline)
+    # Cells containing a pole or crossing the antimeridian have no faithful
+    # lon/lat outline; bound them generously and never treat them as interior
+    if contains(cell_id, _NORTH_POLE):
+        bbox = BoundingBox(-180.0, bbox.min_lat, 180.0, 90.0)
+        return _PARTIAL if bbox.intersects(geometry.bbox) else _DISJOINT
+    if contains(cell_id, _SOUTH_POLE):
+        bbox = BoundingBox(-180.0, -90.0, 180.0, bbox.max_lat)
+        return _PARTIAL if bbox.intersects(geometry.bbox) else _DISJOINT
+    if bbox.max_lon - bbox.min_lon > 180:
+        bbox = BoundingBox(-180.0, bbox.min_lat, 180.0, bbox.max_lat)
+        return _PARTIAL if bbox.intersects(geometry.bbox) else _DISJOINT
+
+    error = outline_error(cell_id)
+    bbox = BoundingBox(
+        bbox.min_lon - error, bbox.min_lat - error,
+        bbox.max_lon + error, bbox.max_lat + error,
+    )
+    if not bbox.intersects(geometry.bbox):
+        return _DISJOINT
+
+    cell_edges = list(zip(outline, outline[1:] + outline[:1]))
+    for start, end in segments.query_bbox(bbox):
+        if bbox.contains(*start) and point_in_ring(start[0], start[1], outline):
+            return _PARTIAL
+        for a, b in cell_edges:
+            if _segment_distance(a, b, start, end) <= error:
+                return _PARTIAL
+
+    # No boundary near the cell: it is either wholly inside or outside.
+    # Outline vertices are exact cell points.
+    lon, lat = outline[0]
+    return _INTERIOR if geometry.contains(lon, lat) else _DISJOINT
+
+
+@dataclass(frozen=True)
+class Covering:
+    """Cells approximating a region; interior cells lie fully inside it."""
+    cells: Tuple[int, ...]
+    interior: Tuple[int, ...]
+
+    def tokens(self) -> List[str]:
+        """Tokens of all covering cells."""
+        return [to_token(c) for c in self.cells]
+
+    def interior_tokens(self) -> List[str]:
+        """Tokens of the interior cells."""
+        return [to_token(c) for c in self.interior]
+
+
+def cover_geometry(
+    geometry: Geometry,
+    max_cells: int = 128,
+    min_level: int = 0,
+    max_level: int = 20,
+) -> Covering:
+    """Approximate a polygon with S2 cells.
+
+    Coarse cells are subdivided breadth-first while the cell budget
+    allows; cells crossing the boundary at the budget or level limit are
+    kept as (non-interior) boundary cells, so the covering always contains
+    the whole polygon.
+
+    Args:
+        geometry: Polygon or MultiPolygon
+        max_cells: Soft limit on the number of cells (exceeded only when
+            min_level forces subdivision)
+        min_level: Coarsest level to emit
+        max_level: Finest level to subdivide to
+
+    Returns:
+        Covering
+
+    Raises:
+        ValueError: If the limits are inconsistent
+    """
+    if not 0 <= min_level <= max_level <= MAX_LEVEL:
+        raise ValueError("Require 0 <= min_level <= max_level <= 30")
+    if max_cells < 1:
+        raise ValueError("max_cells must be at least 1")
+
+    segments = _segment_index(geometry)
+    frontier = [face_cell(f) for f in range(6)]
+    cells: List[int] = []
+    interior: List[int] = []
+    while frontier:
+        next_frontier: List[int] = []
+        for index, cell_id in enumerate(frontier):
+            relation = _relation(cell_id, geometry, segments)
+            if relation == _DISJOINT:
+                continue
+            cell_level = level(cell_id)
+            if relation == _INTERIOR and cell_level >= min_level:
+                cells.append(cell_id)
+                interior.append(cell_id)
+                continue
+            pending = len(cells) + (len(frontier) - index - 1) + len(next_frontier)
+            if cell_level < min_level or (
+                cell_level < max_level and pending + 4 <= max_cells
+            ):
+                next_frontier.extend(children(cell_id))
+            else:
+                cells.append(cell_id)
+        frontier = next_frontier
+
+    return Covering(cells=tuple(sorted(cells)), interior=tuple(sorted(interior)))
+
+
+class CellUnion:
+    """Fast membership tests against a set of cells."""
+
+    def __init__(self, cells: Sequence[int]):
+        """Build from cell ids (overlapping cells are allowed)."""
+        ranges = sorted((range_min(c), range_max(c)) for c in cells)
+        self._starts = [start for start, _ in ranges]
+        self._ends = [end for _, end in ranges]
+        # Running maximum so a containing cell is found even if a later
+        # (finer) range starts after it
+        self._max_ends = []
+        current = -1
+        for end in self._ends:
+            current = max(current, end)
+            self._max_ends.append(current)
+
+    @classmethod
+    def from_tokens(cls, tokens: Sequence[str]) -> "CellUnion":
+        """Build from S2 tokens."""
+        return cls([from_token(t) for t in tokens])
+
+    def __len__(self) -> int:
+        return len(self._starts)
+
+    def contains_leaf(self, leaf_id: int) -> bool:
+        """True if any cell contains the leaf cell id."""
+        index = bisect.bisect_right(self._starts, leaf_id) - 1
+        return index >= 0 and self._max_ends[index] >= leaf_id
+
+    def contains_point(self, latitude: float, longitude: float) -> bool:
+        """True if any cell contains the coordinate."""
+        return self.contains_leaf(cell_id_from_latlng(latitude, longitude))
+
+
+def classify_point(
+    covering: CellUnion, interior: CellUnion, latitude: float, longitude: float
+) -> Optional[bool]:
+    """Pre-filter a point against a covering.
+
+    Returns:
+        False if the point is outside the covering (definitely outside),
+        True if it is in an interior cell (definitely inside), or None if
+        it lies in a boundary cell and needs an exact test
+    """
+    leaf = cell_id_from_latlng(latitude, longitude)
+    if not covering.contains_leaf(leaf):
+        return False
+    if interior.contains_leaf(leaf):
+        return True
+    return None
@@ -0,0 +1,235 @@
+"""Well-Known Text and Well-Known Binary polygon input.
+
+GIS tools export geometry as OGC WKT (`POLYGON ((...))`) or WKB, often
+in the PostGIS extended forms carrying an SRID (`SRID=3857;POLYGON ...`,
+EWKB). Polygons and multipolygons are decoded to GeoJSON geometry dicts;
+Z and M ordinates are dropped. Rings must be closed, as both formats
+require.
+"""
+
+import math
+import re
+import struct
+from typing import Any, Dict, List, Optional, Tuple
+
+# WKB geometry type codes (ISO adds 1000/2000/3000 for Z/M/ZM)
+WKB_POLYGON = 3
+WKB_MULTIPOLYGON = 6
+
+# EWKB flags in the high bits of the type code
+_EWKB_Z = 0x80000000
+_EWKB_M = 0x40000000
+_EWKB_SRID = 0x20000000
+
+_SRID_PREFIX = re.compile(r"^\s*SRID\s*=\s*(\d+)\s*;", re.IGNORECASE)
+_TOKEN = re.compile(r"\s*([A-Za-z]+|\(|\)|,|[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?)")
+
+Parsed = Tuple[Dict[str, Any], Optional[int]]
+
+
+def _check_ring(ring: List[List[float]]) -> List[List[float]]:
+    if len(ring) < 4:
+        raise ValueError("Polygon ring needs at least four positions")
+    if ring[0] != ring[-1]:
+        raise ValueError("Polygon ring is not closed")
+    if not all(math.isfinite(value) for position in ring for value in position):
+        raise ValueError("Polygon coordinates must be finite numbers")
+    return ring
+
+
+def _geojson(geometry_type: str, polygons: List[List[List[List[float]]]]) -> Dict[str, Any]:
+    if geometry_type == "Polygon":
+        return {"type": "Polygon", "coordinates": polygons[0]}
+    return {"type": "MultiPolygon", "coordinates": polygons}
+
+
+class _WktReader:
+    """Recursive-descent reader over WKT tokens."""
+
+    def __init__(self, text: str):
+        self.tokens: List[str] = []
+        position = 0
+        text = text.rstrip()
+        while position < len(text):
+            match = _TOKEN.match(text, position)
+            if match is None:
+                raise ValueError(f"Invalid WKT near {text[position:position + 20]!r}")
+            self.tokens.append(match.group(1))
+            position = match.end()
+        self.index = 0
+
+    def peek(self) -> Optional[str]:
+        return self.tokens[self.index] if self.index < len(self.tokens) else None
+
+    def take(self, expected: Optional[str] = None) -> str:
+        token = self.peek()
+        if token is None:
+            raise ValueError("Unexpected end of WKT")
+        if expected is not None and token.upper() != expected:
+            raise ValueError(f"Invalid WKT: expected {expected!r}, got {token!r}")
+        self.index += 1
+        return token
+
+    def position(self) -> List[float]:
+        values = []
+        while self.peek() not in (",", ")", None):
+            try:
+                values.append(float(self.take()))
+            except ValueError:
+                raise ValueError("Invalid WKT: coordinates must be numbers")
+        if not 2 <= len(values) <= 4:
+            raise ValueError("Invalid WKT: positions need two to four ordinates")
+        return values[:2]
+
+    def listed(self, item):
+        self.take("(")
+        items = [item()]
+        while self.peek() == ",":
+            self.take()
+            items.append(item())
+        self.take(")")
+        return items
+
+    def ring(self) -> List[List[float]]:
+        return _check_ring(self.listed(self.position))
+
+    def polygon(self) -> List[List[List[float]]]:
+        return self.listed(self.ring)
+
+
+def parse_wkt(text: str) -> Parsed:
+    """Decode a WKT or EWKT Polygon or MultiPolygon.
+
+    Args:
+        text: WKT text, optionally prefixed with `SRID=<code>;`
+
+    Returns:
+        (GeoJSON geometry dict, SRID of the prefix or None)
+
+    Raises:
+        ValueError: If the text is malformed, empty or not a polygon type
+    """
+    srid = None
+    match = _SRID_PREFIX.match(text)
+    if match:
+        srid = int(match.group(1))
+        text = text[match.end():]
+    reader = _WktReader(text)
+    keyword = reader.take().upper()
+    if reader.peek() is not None and reader.peek().upper() in ("Z", "M", "ZM"):
+        reader.take()
+    if keyword not in ("POLYGON", "MULTIPOLYGON"):
+        raise ValueError(f"Unsupported WKT geometry type: {keyword} (expected POLYGON or MULTIPOLYGON)")
+    if reader.peek() is not None and reader.peek().upper() == "EMPTY":
+        raise ValueError(f"{keyword} EMPTY has no coordinates")
+    if keyword == "POLYGON":
+        geometry = _geojson("Polygon", [reader.polygon()])
+    else:
+        geometry = _geojson("MultiPolygon", reader.listed(reader.polygon))
+    if reader.peek() is not None:
+        raise ValueError(f"Invalid WKT: unexpected {reader.peek()!r} after the geometry")
+    return geometry, srid
+
+
+class _WkbReader:
+    """Sequential reader over WKB bytes."""
+
+    def __init__(self, data: bytes):
+        self.data = data
+        self.offset = 0
+
+    def unpack(self, fmt: str) -> Tuple:
+        size = struct.calcsize(fmt)
+        if self.offset + size > len(self.data):
+            raise ValueError("Truncated WKB")
+        values = struct.unpack_from(fmt, self.data, self.offset)
+        self.offset += size
+        return values
+
+    def header(self) -> Tuple[str, int, int, Optional[int]]:
+        """(byte order, base type, ordinates per position, SRID)."""
+        (order,) = self.unpack("B")
+        if order not in (0, 1):
+            raise ValueError(f"Invalid WKB byte order: {order}")
+        endian = "<" if order == 1 else ">"
+        (code,) = self.unpack(endian + "I")
+        srid = None
+        if code & _EWKB_SRID:
+            (srid,) = self.unpack(endian + "I")
+        dims = 2 + bool(code & _EWKB_Z) + bool(code & _EWKB_M)
+        code &= 0x0FFFFFFF
+        iso, base = divmod(code, 1000)
+        if iso not in (0, 1, 2, 3):
+            raise ValueError(f"Unsupported WKB geometry type code: {code}")
+        dims += {0: 0, 1: 1, 2: 1, 3: 2}[iso]
+        return endian, base, dims, srid
+
+    def polygon(self, endian: str, dims: int) -> List[List[List[float]]]:
+        (ring_count,) = self.unpack(endian + "I")
+        rings = []
+        for _ in range(ring_count):
+            (point_count,) = self.unpack(endian + "I")
+            values = self.unpack(f"{endian}{point_count * dims}d")
+            rings.append(_check_ring([list(values[i:i + 2]) for i in range(0, len(values), dims)]))
+        if not rings:
+            raise ValueError("POLYGON EMPTY has no coordinates")
+        return rings
+
+
+def parse_wkb(data: bytes) -> Parsed:
+    """Decode a WKB or EWKB Polygon or MultiPolygon.
+
+    Args:
+        data: WKB bytes (ISO or PostGIS extended)
+
+    Returns:
+        (GeoJSON geometry dict, SRID of the EWKB header or None)
+
+    Raises:
+        ValueError: If the bytes are malformed, empty or not a polygon type
+    """
+    reader = _WkbReader(data)
+    endian, base, dims, srid = reader.header()
+    if base == WKB_POLYGON:
+        geometry = _geojson("Polygon", [reader.polygon(endian, dims)])
+    elif base == WKB_MULTIPOLYGON:
+        (count,) = reader.unpack(endian + "I")
+        polygons = []
+        for _ in range(count):
+            member_endian, member_base, member_dims, _ = reader.header()
+            if member_base != WKB_POLYGON:
+                raise ValueError("WKB MultiPolygon members must be polygons")
+            polygons.append(reader.polygon(member_endian, member_dims))
+        if not polygons:
+            raise ValueError("MULTIPOLYGON EMPTY has no coordinates")
+        geometry = _geojson("MultiPolygon", polygons)
+    else:
+        raise ValueError(f"Unsupported WKB geometry type: {base} (expected Polygon or MultiPolygon)")
+    if reader.offset != len(data):
+        raise ValueError("Trailing bytes after the WKB geometry")
+    return geometry, srid
+
+
+def parse_wkb_hex(text: str) -> Parsed:
+    """Decode hex-encoded WKB, as printed by PostGIS and GDAL.
+
+    Raises:
+        ValueError: If the text is not hex or not valid WKB
+    """
+    try:
+        data = bytes.fromhex(text.strip())
+    except ValueError:
+        raise ValueError("WKB must be hex encoded")
+    return parse_wkb(data)
+
+
+def parse_geometry_text(text: str) -> Parsed:
+    """Decode WKT/EWKT, or hex WKB/EWKB (told apart by the first character).
+
+    Raises:
+        ValueError: If the text is neither valid WKT nor valid hex WKB
+    """
+    stripped = text.strip()
+    if stripped[:1].isalpha():
+        return parse_wkt(stripped)
+    return parse_wkb_hex(stripped)
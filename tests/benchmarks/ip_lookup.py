@@ -0,0 +1,97 @@
+"""Benchmark of the IP lookup hot path: dataset reads per second and their allocations.
+
+Builds a City-like dataset of 4096 networks and looks up a skewed mix of
+addresses (a few hot ones, as real traffic has) through IpLookupService,
+reporting lookups per second, the peak memory of a burst of lookups whose
+answers are dropped, and the memory blocks an answer holds while it is
+kept (by a response, a cache).
+
+    python -m tests.benchmarks.ip_lookup [--lookups 200000]
+"""
+
+import argparse
+import os
+import random
+import tempfile
+import time
+import tracemalloc
+
+from src.services.ip_lookup_service import IpLookupService
+from src.services.mmdb_service import MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+
+def _location(n: int) -> dict:
+    return {
+        "city": {"geoname_id": n, "names": {"en": f"City {n}", "de": f"Stadt {n}"}},
+        "continent": {"code": "EU", "names": {"en": "Europe"}},
+        "country": {"iso_code": "GB", "names": {"en": "United Kingdom", "de": "Vereinigtes Königreich"}},
+        "location": {
+            "accuracy_radius": 20,
+            "latitude": 51.5 + n / 10000,
+            "longitude": -0.12 - n / 10000,
+            "time_zone": "Europe/London",
+        },
+        "postal": {"code": f"E{n % 100}"},
+        "subdivisions": [{"iso_code": "ENG", "names": {"en": "England"}}],
+    }
+
+
+def _addresses(count: int, seed: int = 1) -> list:
+    """Addresses in the dataset's networks, a fifth of the networks getting most lookups."""
+    rng = random.Random(seed)
+    hot = [n for n in range(4096) if n % 5 == 0]
+    addresses = []
+    for _ in range(count):
+        n = rng.choice(hot) if rng.random() < 0.8 else rng.randrange(4096)
+        addresses.append(f"81.{n >> 6}.{(n & 63) * 4}.{rng.randrange(1, 4)}")
+    return addresses
+
+
+def run(lookups: int) -> dict:
+    """Look up `lookups` addresses; returns the measurements."""
+    directory = tempfile.mkdtemp(prefix="bench-")
+    path = write_mmdb(
+        os.path.join(directory, "city.mmdb"),
+        [(f"81.{n >> 6}.{(n & 63) * 4}.0/30", _location(n)) for n in range(4096)],
+    )
+    reader = MMDBReader(str(path))
+    service = IpLookupService(reader)
+    addresses = _addresses(lookups)
+    for ip in addresses[:1000]:
+        service.lookup(ip)  # warm up
+
+    started = time.perf_counter()
+    for ip in addresses:
+        service.lookup(ip)
+    elapsed = time.perf_counter() - started
+
+    sample = addresses[:10000]
+    tracemalloc.start()
+    for ip in sample:
+        service.lookup(ip)
+    _, peak = tracemalloc.get_traced_memory()
+    before = sum(stat.count for stat in tracemalloc.take_snapshot().statistics("filename"))
+    kept = [service.lookup(ip) for ip in sample]
+    after = sum(stat.count for stat in tracemalloc.take_snapshot().statistics("filename"))
+    tracemalloc.stop()
+    del kept
+    reader.close()
+    return {
+        "lookups_per_second": lookups / elapsed,
+        "microseconds_per_lookup": elapsed / lookups * 1e6,
+        "burst_peak_kib": peak / 1024,
+        "blocks_per_kept_answer": (after - before) / len(sample),
+    }
+
+
+def main() -> None:
+    parser = argparse.ArgumentParser(description=__doc__.splitlines()[0])
+    parser.add_argument("--lookups", type=int, default=200_000)
+    args = parser.parse_args()
+    for name, value in run(args.lookups).items():
+        print(f"{name:>24}: {value:,.1f}")
+
+
+if __name__ == "__main__":
+    main()
@@ -3,6 +3,50 @@ from fastapi.testclient import TestClient
 from httpx import AsyncClient
 
 
+@pytest.fixture(autouse=True)
+def auth_throttle(monkeypatch):
+    """Fresh brute-force counters, so that one test's failures never lock out the next."""
+    from src.services import auth_throttle_service
+    from src.services.auth_throttle_service import AuthThrottle
+
+    throttle = AuthThrottle()
+    monkeypatch.setattr(auth_throttle_service, "_auth_throttle", throttle)
+    return throttle
+
+
+@pytest.fixture(autouse=True)
+def rate_limiter(monkeypatch):
+    """Fresh rate limit buckets, so that one test's requests never use up the next one's."""
+    from src.services import rate_limit_service
+    from src.services.rate_limit_service import TokenBucketLimiter
+
+    limiter = TokenBucketLimiter()
+    monkeypatch.setattr(rate_limit_service, "_rate_limiter", limiter)
+    return limiter
+
+
+@pytest.fixture(autouse=True)
+def quota_enforcer(monkeypatch):
+    """Fresh quota counters with the built-in plans."""
+    from src.services import quota_service
+    from src.services.quota_service import QuotaEnforcer
+
+    enforcer = QuotaEnforcer()
+    monkeypatch.setattr(quota_service, "_quota_enforcer", enforcer)
+    return enforcer
+
+
+@pytest.fixture(autouse=True)
+def concurrency_limiter(monkeypatch):
+    """Fresh concurrency slots, so that a test's unfinished requests never shed the next one's."""
+    from src.services import concurrency_service
+    from src.services.concurrency_service import ConcurrencyLimiter
+
+    limiter = ConcurrencyLimiter()
+    monkeypatch.setattr(concurrency_service, "_concurrency_limiter", limiter)
+    return limiter
+
+
 @pytest.fixture
 def test_client():
     """Provides a synchronous TestClient for the FastAPI app."""
@@ -37,3 +81,19 @@ def db_session():
     finally:
         session.close()
         db_manager.close()
+
+
+@pytest.fixture
+def db_client(db_session):
+    """Provides a TestClient whose routes use the test database session."""
+    from src.database import get_db_session
+    from src.main import app
+
+    def _session():
+        yield db_session
+
+    app.dependency_overrides[get_db_session] = _session
+    try:
+        yield TestClient(app)
+    finally:
+        app.dependency_overrides.pop(get_db_session, None)
@@ -0,0 +1 @@
+"""Load tests against a deployed environment (run as modules, not collected by pytest)."""
@@ -0,0 +1,184 @@
+"""Load test of a deployed detection API: mixed lookup, batch, geofence and detect traffic.
+
+Sends a realistic mix of requests at a fixed rate and fails (exit status
+1) when a latency SLO or the error budget is missed, so it can gate a
+staging deployment:
+
+- lookup: GET /api/v1/lookup/ip/{ip}, a few hot addresses getting most
+  lookups as real traffic does;
+- batch: POST /api/v1/lookup/batch of IP addresses and coordinates;
+- geofence: GET /api/v1/geofences/match around population centres;
+- detect: POST /api/v1/detect/travel logins of a pool of synthetic users
+  (stored as their login history: point it at a tenant kept for load tests).
+
+    python -m tests.load.detection_api --base-url https://geo.staging.internal \\
+        --rate 200 --duration 300 [--slo lookup:p99=100] [--ips-file addresses.txt]
+
+The bearer token is read from LOAD_TEST_TOKEN and the API key from
+LOAD_TEST_API_KEY. Without --ips-file the lookups use addresses of the
+MaxMind test databases, which production datasets may not cover (404s
+count as answered).
+"""
+
+import argparse
+import asyncio
+import os
+import random
+import sys
+from typing import Dict, List, Optional, Sequence
+
+from tests.load.harness import LoadRequest, Operation, Slo, parse_slo, run_load
+
+DEFAULT_SLOS = [
+    "lookup:p95=50", "lookup:p99=150",
+    "batch:p95=500", "batch:p99=1000",
+    "geofence:p95=100", "geofence:p99=250",
+    "detect:p95=100", "detect:p99=300",
+]
+
+DEFAULT_MIX = "lookup=70,batch=5,geofence=15,detect=10"
+
+DEFAULT_IPS = [
+    "81.2.69.142", "81.2.69.160", "2.125.160.216", "89.160.20.112", "89.160.20.128",
+    "67.43.156.0", "202.196.224.0", "216.160.83.56", "175.16.199.0", "2001:218::",
+]
+
+# Population centres (lat, lon) positions and logins are scattered around
+CENTRES = [
+    (51.507, -0.128), (48.857, 2.352), (52.520, 13.405), (40.713, -74.006), (34.052, -118.244),
+    (35.690, 139.692), (-23.551, -46.633), (19.076, 72.878), (-33.869, 151.209), (1.352, 103.820),
+]
+
+USERS = 1000
+BATCH_ITEMS = 100
+
+
+def _near(rng: random.Random, spread_degrees: float = 0.5) -> Dict[str, float]:
+    lat, lon = rng.choice(CENTRES)
+    return {
+        "lat": round(lat + rng.uniform(-spread_degrees, spread_degrees), 5),
+        "lon": round(lon + rng.uniform(-spread_degrees, spread_degrees), 5),
+    }
+
+
+def operations(ips: Sequence[str], mix: Dict[str, float]) -> List[Operation]:
+    """The workload: each operation's requests, weighted by the mix."""
+    hot = list(ips[: max(1, len(ips) // 5)])
+
+    def address(rng: random.Random) -> str:
+        # A fifth of the addresses get 80% of the lookups
+        return rng.choice(hot) if rng.random() < 0.8 else rng.choice(ips)
+
+    def lookup(rng: random.Random) -> LoadRequest:
+        return LoadRequest("GET", f"/api/v1/lookup/ip/{address(rng)}")
+
+    def batch(rng: random.Random) -> LoadRequest:
+        items = [{"ip": address(rng)} if rng.random() < 0.8 else _near(rng) for _ in range(BATCH_ITEMS)]
+        return LoadRequest("POST", "/api/v1/lookup/batch", json={"items": items})
+
+    def geofence(rng: random.Random) -> LoadRequest:
+        return LoadRequest("GET", "/api/v1/geofences/match", params=_near(rng))
+
+    def detect(rng: random.Random) -> LoadRequest:
+        return LoadRequest("POST", "/api/v1/detect/travel", json={
+            "user_id": f"loadtest-{rng.randrange(USERS)}", "ip_address": address(rng),
+        })
+
+    builders = {"lookup": lookup, "batch": batch, "geofence": geofence, "detect": detect}
+    unknown = set(mix) - set(builders)
+    if unknown:
+        raise ValueError(f"Unknown operations in the mix: {', '.join(sorted(unknown))}")
+    # Addresses outside the dataset answer 404, which is an answer too
+    ok = {"lookup": {200, 404}, "detect": {200, 404}}
+    return [
+        Operation(name, weight, builders[name], frozenset(ok.get(name, {200})))
+        for name, weight in mix.items()
+        if weight > 0
+    ]
+
+
+def parse_mix(raw: str) -> Dict[str, float]:
+    """Parse ``operation=weight,...``.
+
+    Raises:
+        ValueError: If an entry is malformed
+    """
+    mix = {}
+    for entry in raw.split(","):
+        name, _, weight = entry.partition("=")
+        try:
+            mix[name.strip()] = float(weight)
+        except ValueError:
+            raise ValueError(f"Invalid mix entry {entry!r} (expected operation=weight)")
+    return mix
+
+
+async def run(args: argparse.Namespace, slos: List[Slo]) -> int:
+    import aiohttp
+
+    ips = DEFAULT_IPS
+    if args.ips_file:
+        with open(args.ips_file) as f:
+            ips = [line.strip() for line in f if line.strip() and not line.startswith("#")]
+    headers = {}
+    if os.getenv("LOAD_TEST_TOKEN"):
+        headers["Authorization"] = f"Bearer {os.environ['LOAD_TEST_TOKEN']}"
+    if os.getenv("LOAD_TEST_API_KEY"):
+        headers["X-API-Key"] = os.environ["LOAD_TEST_API_KEY"]
+
+    timeout = aiohttp.ClientTimeout(total=args.timeout)
+    connector = aiohttp.TCPConnector(limit=args.max_in_flight)
+    async with aiohttp.ClientSession(
+        args.base_url.rstrip("/"), headers=headers, timeout=timeout, connector=connector
+    ) as session:
+        async def send(request: LoadRequest) -> int:
+            async with session.request(
+                request.method, request.path, params=request.params, json=request.json
+            ) as response:
+                await response.read()
+                return response.status
+
+        results = await run_load(
+            send,
+            operations(ips, parse_mix(args.mix)),
+            rate=args.rate,
+            duration_seconds=args.duration,
+            max_in_flight=args.max_in_flight,
+            seed=args.seed,
+        )
+
+    print(results.report())
+    failures = results.violations(slos, args.max_error_rate)
+    for failure in failures:
+        print(f"FAIL {failure}")
+    if not failures:
+        print(f"PASS {len(slos)} SLOs, error rate {results.error_rate():.2%}")
+    return 1 if failures else 0
+
+
+def main(argv: Optional[Sequence[str]] = None) -> None:
+    parser = argparse.ArgumentParser(description=__doc__.splitlines()[0])
+    parser.add_argument("--base-url", required=True, help="Deployment under test, e.g. https://geo.staging.internal")
+    parser.add_argument("--rate", type=float, default=50, help="Requests started per second")
+    parser.add_argument("--duration", type=float, default=60, help="Seconds requests are started for")
+    parser.add_argument("--mix", default=DEFAULT_MIX, help="Operation weights")
+    parser.add_argument(
+        "--slo", action="append", default=None,
+        help="operation:pNN=milliseconds (operation * for all; repeatable; replaces the defaults)",
+    )
+    parser.add_argument("--max-error-rate", type=float, default=0.01, help="Share of failed requests tolerated")
+    parser.add_argument("--max-in-flight", type=int, default=256, help="Requests outstanding at most")
+    parser.add_argument("--timeout", type=float, default=10, help="Seconds before a request counts as failed")
+    parser.add_argument("--ips-file", help="Addresses to look up, one per line (hot ones first)")
+    parser.add_argument("--seed", type=int, default=None, help="Seed for a repeatable request sequence")
+    args = parser.parse_args(argv)
+    try:
+        slos = [parse_slo(raw) for raw in (args.slo or DEFAULT_SLOS)]
+        operations(DEFAULT_IPS, parse_mix(args.mix))
+    except ValueError as e:
+        parser.error(str(e))
+    sys.exit(asyncio.run(run(args, slos)))
+
+
+if __name__ == "__main__":
+    main()
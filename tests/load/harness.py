@@ -0,0 +1,213 @@
+"""Open-loop load generator with per-operation latency SLOs.
+
+Requests are started at the target rate whether or not earlier ones have
+answered (exponential gaps between them, as independent clients send
+them), and each latency is measured from the moment its request was due.
+A slow server therefore shows up as high latency rather than as a lower
+request rate, which a closed loop of clients waiting on each other would
+report instead (coordinated omission).
+
+A scenario is a weighted mix of operations, each building its requests
+from a random generator; run_load drives the mix and LoadResults checks
+the measured percentiles against SLOs such as ``lookup:p99=150``.
+"""
+
+import asyncio
+import math
+import random
+import time
+from collections import Counter
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, FrozenSet, List, Optional, Sequence
+
+
+@dataclass(frozen=True)
+class LoadRequest:
+    """One HTTP request of an operation."""
+
+    method: str
+    path: str
+    params: Optional[Dict[str, Any]] = None
+    json: Optional[Any] = None
+
+
+@dataclass(frozen=True)
+class Operation:
+    """A kind of request in the workload mix."""
+
+    name: str
+    weight: float
+    build: Callable[[random.Random], LoadRequest]
+    # Statuses that count as answered; anything else (and timeouts) is an error
+    ok_statuses: FrozenSet[int] = frozenset({200})
+
+
+@dataclass(frozen=True)
+class Slo:
+    """Latency objective: the percentile of an operation within a bound."""
+
+    operation: str  # "*" for every operation
+    percentile: float
+    max_ms: float
+
+    def __str__(self) -> str:
+        return f"{self.operation}:p{self.percentile:g}={self.max_ms:g}"
+
+
+def parse_slo(raw: str) -> Slo:
+    """Parse ``operation:pNN=milliseconds``, e.g. ``lookup:p99=150``.
+
+    Raises:
+        ValueError: If the SLO is malformed
+    """
+    try:
+        operation, objective = raw.split(":", 1)
+        percentile, max_ms = objective.split("=", 1)
+        if not percentile.startswith("p"):
+            raise ValueError("percentile must start with p")
+        slo = Slo(operation.strip(), float(percentile[1:]), float(max_ms))
+    except ValueError as e:
+        raise ValueError(f"Invalid SLO {raw!r} (expected operation:pNN=milliseconds): {str(e)}")
+    if not slo.operation or not 0 < slo.percentile <= 100 or slo.max_ms <= 0:
+        raise ValueError(f"Invalid SLO {raw!r}")
+    return slo
+
+
+def percentile(values: Sequence[float], p: float) -> float:
+    """Nearest-rank percentile (the smallest value at least p% of values do not exceed)."""
+    if not values:
+        return math.nan
+    ordered = sorted(values)
+    return ordered[max(0, math.ceil(p / 100 * len(ordered)) - 1)]
+
+
+@dataclass
+class OperationStats:
+    """Measurements of one operation."""
+
+    latencies_ms: List[float] = field(default_factory=list)
+    errors: Dict[str, int] = field(default_factory=dict)
+
+    @property
+    def requests(self) -> int:
+        return len(self.latencies_ms)
+
+    @property
+    def error_count(self) -> int:
+        return sum(self.errors.values())
+
+
+@dataclass
+class LoadResults:
+    """Measurements of a load run."""
+
+    duration_seconds: float
+    operations: Dict[str, OperationStats]
+
+    def all_latencies(self) -> List[float]:
+        return [latency for stats in self.operations.values() for latency in stats.latencies_ms]
+
+    def error_rate(self) -> float:
+        requests = sum(stats.requests for stats in self.operations.values())
+        errors = sum(stats.error_count for stats in self.operations.values())
+        return errors / requests if requests else 0.0
+
+    def violations(self, slos: Sequence[Slo], max_error_rate: float) -> List[str]:
+        """SLOs the run missed, described for the report (empty: all met).
+
+        Failed requests count towards the latencies as well as the error
+        rate, so timeouts cannot hide from the percentiles.
+        """
+        failures = []
+        for slo in slos:
+            if slo.operation == "*":
+                latencies = self.all_latencies()
+            elif slo.operation in self.operations:
+                latencies = self.operations[slo.operation].latencies_ms
+            else:
+                continue
+            measured = percentile(latencies, slo.percentile)
+            if latencies and measured > slo.max_ms:
+                failures.append(f"{slo}: measured {measured:.1f} ms")
+        rate = self.error_rate()
+        if rate > max_error_rate:
+            failures.append(f"error rate {rate:.2%} above {max_error_rate:.2%}")
+        return failures
+
+    def report(self) -> str:
+        """Table of requests, errors and latency percentiles per operation."""
+        columns = ("requests", "errors", "rps", "p50 ms", "p95 ms", "p99 ms", "max ms")
+        lines = [f"{'operation':<12} " + " ".join(f"{column:>9}" for column in columns)]
+        errors: Counter = Counter()
+        for stats in self.operations.values():
+            errors.update(stats.errors)
+        rows = {**self.operations, "*": OperationStats(self.all_latencies(), dict(errors))}
+        for name, stats in rows.items():
+            values = stats.latencies_ms
+            figures = [percentile(values, p) for p in (50, 95, 99)] + [max(values, default=math.nan)]
+            lines.append(
+                f"{name:<12} {stats.requests:>9} {stats.error_count:>9} "
+                f"{stats.requests / self.duration_seconds:>9.1f} "
+                + " ".join(f"{figure:>9.1f}" for figure in figures)
+            )
+        for name, stats in self.operations.items():
+            for kind, count in sorted(stats.errors.items()):
+                lines.append(f"  {name} errors: {count} x {kind}")
+        return "\n".join(lines)
+
+
+async def run_load(
+    send: Callable[[LoadRequest], Any],
+    operations: Sequence[Operation],
+    rate: float,
+    duration_seconds: float,
+    max_in_flight: int = 256,
+    seed: Optional[int] = None,
+) -> LoadResults:
+    """Drive a weighted mix of operations at a fixed arrival rate.
+
+    Args:
+        send: Coroutine function sending a request and returning its status
+            code (raising on timeouts and connection errors)
+        operations: Workload mix
+        rate: Requests started per second
+        duration_seconds: How long requests are started for (the run ends
+            when the last of them has answered)
+        max_in_flight: Requests outstanding at most; beyond it due requests
+            wait, and their wait counts as latency
+        seed: Seed of the random generator (same seed, same requests)
+
+    Returns:
+        LoadResults of the run
+    """
+    if rate <= 0 or duration_seconds <= 0:
+        raise ValueError("rate and duration must be positive")
+    rng = random.Random(seed)
+    weights = [operation.weight for operation in operations]
+    stats = {operation.name: OperationStats() for operation in operations}
+    slots = asyncio.Semaphore(max_in_flight)
+
+    async def fire(operation: Operation, request: LoadRequest, due: float) -> None:
+        async with slots:
+            try:
+                status = await send(request)
+                error = None if status in operation.ok_statuses else f"HTTP {status}"
+            except Exception as e:
+                error = type(e).__name__
+        stats[operation.name].latencies_ms.append((time.monotonic() - due) * 1000)
+        if error is not None:
+            stats[operation.name].errors[error] = stats[operation.name].errors.get(error, 0) + 1
+
+    started = time.monotonic()
+    due = started
+    tasks = []
+    while True:
+        due += rng.expovariate(rate)
+        if due - started >= duration_seconds:
+            break
+        operation = rng.choices(operations, weights)[0]
+        request = operation.build(rng)
+        await asyncio.sleep(max(0.0, due - time.monotonic()))
+        tasks.append(asyncio.ensure_future(fire(operation, request, due)))
+    await asyncio.gather(*tasks)
+    return LoadResults(duration_seconds=time.monotonic() - started, operations=stats)
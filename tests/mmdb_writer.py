@@ -0,0 +1,155 @@
+"""Minimal MaxMind DB writer used to build fixture databases in tests."""
+import ipaddress
+import struct
+from typing import Any, Dict, Iterable, Tuple
+
+METADATA_MARKER = b"\xab\xcd\xefMaxMind.com"
+
+
+def _encode_control(type_num: int, size: int) -> bytes:
+    """Encode a control byte (plus extended type and size bytes)."""
+    if type_num <= 7:
+        first = type_num << 5
+        extended = b""
+    else:
+        first = 0
+        extended = bytes([type_num - 7])
+
+    if size < 29:
+        return bytes([first | size]) + extended
+    if size < 285:
+        return bytes([first | 29]) + extended + bytes([size - 29])
+    if size < 65821:
+        return bytes([first | 30]) + extended + (size - 285).to_bytes(2, "big")
+    return bytes([first | 31]) + extended + (size - 65821).to_bytes(3, "big")
+
+
+def encode_value(value: Any) -> bytes:
+    """Encode a Python value using the MaxMind DB data types."""
+    if isinstance(value, bool):
+        return _encode_control(14, 1 if value else 0)
+    if isinstance(value, str):
+        data = value.encode("utf-8")
+        return _encode_control(2, len(data)) + data
+    if isinstance(value, float):
+        return _encode_control(3, 8) + struct.pack(">d", value)
+    if isinstance(value, bytes):
+        return _encode_control(4, len(value)) + value
+    if isinstance(value, int):
+        if value < 0:
+            data = value.to_bytes(4, "big", signed=True)
+            return _encode_control(8, 4) + data
+        length = max(1, (value.bit_length() + 7) // 8)
+        if length <= 2:
+            type_num, width = 5, 2
+        elif length <= 4:
+            type_num, width = 6, 4
+        elif length <= 8:
+            type_num, width = 9, 8
+        else:
+            type_num, width = 10, 16
+        data = value.to_bytes(width, "big").lstrip(b"\x00")
+        return _encode_control(type_num, len(data)) + data
+    if isinstance(value, dict):
+        out = _encode_control(7, len(value))
+        for key, item in value.items():
+            out += encode_value(str(key)) + encode_value(item)
+        return out
+    if isinstance(value, (list, tuple)):
+        out = _encode_control(11, len(value))
+        for item in value:
+            out += encode_value(item)
+        return out
+    raise TypeError(f"Cannot encode {type(value).__name__} in MMDB")
+
+
+def _encode_node(left: int, right: int, record_size: int) -> bytes:
+    """Encode a search tree node with the given record size."""
+    if record_size == 24:
+        return left.to_bytes(3, "big") + right.to_bytes(3, "big")
+    if record_size == 28:
+        middle = ((left >> 24) & 0x0F) << 4 | ((right >> 24) & 0x0F)
+        return (
+            (left & 0xFFFFFF).to_bytes(3, "big")
+            + bytes([middle])
+            + (right & 0xFFFFFF).to_bytes(3, "big")
+        )
+    return left.to_bytes(4, "big") + right.to_bytes(4, "big")
+
+
+def build_mmdb(
+    networks: Iterable[Tuple[str, Dict[str, Any]]],
+    ip_version: int = 6,
+    record_size: int = 28,
+    database_type: str = "GeoLite2-City",
+    build_epoch: int = 1700000000,
+) -> bytes:
+    """Build an MMDB file image from (cidr, data) pairs.
+
+    IPv4 networks in an IPv6 database are placed under ::/96, matching
+    the layout of official MaxMind databases.
+    """
+    # Trie nodes as [left, right]; children are node indexes, ("data", i) or None
+    nodes = [[None, None]]
+    payloads = []
+
+    for cidr, data in networks:
+        network = ipaddress.ip_network(cidr)
+        bits = network.network_address.packed
+        prefix = network.prefixlen
+        if ip_version == 6 and network.version == 4:
+            bits = b"\x00" * 12 + bits
+            prefix += 96
+        payloads.append(data)
+        payload_ref = ("data", len(payloads) - 1)
+
+        node = 0
+        for depth in range(prefix):
+            bit = (bits[depth >> 3] >> (7 - (depth & 7))) & 1
+            if depth == prefix - 1:
+                nodes[node][bit] = payload_ref
+                break
+            child = nodes[node][bit]
+            if not isinstance(child, int):
+                nodes.append([child, child])
+                child = len(nodes) - 1
+                nodes[node][bit] = child
+            node = child
+
+    node_count = len(nodes)
+    data_section = b""
+    data_offsets = []
+    for payload in payloads:
+        data_offsets.append(len(data_section))
+        data_section += encode_value(payload)
+
+    def record_value(child) -> int:
+        if child is None:
+            return node_count
+        if isinstance(child, int):
+            return child
+        return node_count + 16 + data_offsets[child[1]]
+
+    tree = b"".join(
+        _encode_node(record_value(left), record_value(right), record_size)
+        for left, right in nodes
+    )
+    metadata = {
+        "node_count": node_count,
+        "record_size": record_size,
+        "ip_version": ip_version,
+        "database_type": database_type,
+        "languages": ["en"],
+        "binary_format_major_version": 2,
+        "binary_format_minor_version": 0,
+        "build_epoch": build_epoch,
+        "description": {"en": "Test database"},
+    }
+    return tree + b"\x00" * 16 + data_section + METADATA_MARKER + encode_value(metadata)
+
+
+def write_mmdb(path, networks, **kwargs) -> str:
+    """Write an MMDB fixture database to disk and return its path."""
+    with open(path, "wb") as f:
+        f.write(build_mmdb(networks, **kwargs))
+    return str(path)
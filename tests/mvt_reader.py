@@ -0,0 +1,117 @@
+"""Minimal Mapbox Vector Tile reader used to check encoded tiles in tests."""
+import struct
+from typing import Any, Dict, Iterator, List, Tuple
+
+
+def _varint(data: bytes, offset: int) -> Tuple[int, int]:
+    value = shift = 0
+    while True:
+        byte = data[offset]
+        offset += 1
+        value |= (byte & 0x7F) << shift
+        shift += 7
+        if not byte & 0x80:
+            return value, offset
+
+
+def _fields(data: bytes) -> Iterator[Tuple[int, Any]]:
+    """(field number, value) pairs; length-delimited values as bytes."""
+    offset = 0
+    while offset < len(data):
+        key, offset = _varint(data, offset)
+        number, wire_type = key >> 3, key & 7
+        if wire_type == 0:
+            value, offset = _varint(data, offset)
+        elif wire_type == 1:
+            value, offset = data[offset:offset + 8], offset + 8
+        elif wire_type == 2:
+            length, offset = _varint(data, offset)
+            value, offset = data[offset:offset + length], offset + length
+        else:
+            raise ValueError(f"Unsupported wire type {wire_type}")
+        yield number, value
+
+
+def _packed(data: bytes) -> List[int]:
+    values, offset = [], 0
+    while offset < len(data):
+        value, offset = _varint(data, offset)
+        values.append(value)
+    return values
+
+
+def _unzigzag(value: int) -> int:
+    return (value >> 1) ^ -(value & 1)
+
+
+def _value(data: bytes) -> Any:
+    for number, value in _fields(data):
+        if number == 1:
+            return value.decode()
+        if number == 3:
+            return struct.unpack("<d", value)[0]
+        if number == 6:
+            return _unzigzag(value)
+        if number == 7:
+            return bool(value)
+        return value
+    return None
+
+
+def _geometry(commands: List[int]) -> List[List[Tuple[int, int]]]:
+    """Point groups: one per MoveTo, in absolute tile coordinates."""
+    parts: List[List[Tuple[int, int]]] = []
+    x = y = index = 0
+    while index < len(commands):
+        command, count = commands[index] & 7, commands[index] >> 3
+        index += 1
+        if command == 7:
+            continue
+        for _ in range(count):
+            x += _unzigzag(commands[index])
+            y += _unzigzag(commands[index + 1])
+            index += 2
+            if command == 1:
+                parts.append([])
+            parts[-1].append((x, y))
+    return parts
+
+
+def read_tile(data: bytes) -> Dict[str, Dict[str, Any]]:
+    """Layers of a tile by name: {"version", "extent", "features": [...]}.
+
+    Each feature is {"type", "properties", "geometry"} with the geometry
+    as point groups (rings of a polygon, single points of a point).
+    """
+    layers = {}
+    for number, layer_data in _fields(data):
+        if number != 3:
+            continue
+        layer: Dict[str, Any] = {"features": [], "extent": 4096}
+        keys, values, features = [], [], []
+        for field, value in _fields(layer_data):
+            if field == 1:
+                layer["name"] = value.decode()
+            elif field == 2:
+                features.append(value)
+            elif field == 3:
+                keys.append(value.decode())
+            elif field == 4:
+                values.append(_value(value))
+            elif field == 5:
+                layer["extent"] = value
+            elif field == 15:
+                layer["version"] = value
+        for feature_data in features:
+            feature: Dict[str, Any] = {"properties": {}}
+            for field, value in _fields(feature_data):
+                if field == 2:
+                    tags = _packed(value)
+                    feature["properties"] = {keys[k]: values[v] for k, v in zip(tags[::2], tags[1::2])}
+                elif field == 3:
+                    feature["type"] = value
+                elif field == 4:
+                    feature["geometry"] = _geometry(_packed(value))
+            layer["features"].append(feature)
+        layers[layer["name"]] = layer
+    return layers
@@ -0,0 +1,102 @@
+"""Unit tests for account sharing detection."""
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.services.account_sharing_service import AccountSharingService
+from src.services.travel_service import TravelPoint
+
+T0 = datetime(2026, 3, 1, 9, 0)
+
+LONDON = (51.5142, -0.0931)
+READING = (51.4543, -0.9781)
+NEW_YORK = (40.7128, -74.0060)
+
+
+def _at(place, minutes=0, country_code=None):
+    return TravelPoint(place[0], place[1], T0 + timedelta(minutes=minutes), country_code=country_code)
+
+
+@pytest.fixture
+def sharing(db_session):
+    """Account sharing with 30-minute sessions and the default travel limits."""
+    return AccountSharingService(db_session, session_ttl_seconds=1800, max_speed_kmh=1000, min_distance_km=100)
+
+
+class TestAccountSharing:
+    """Test comparing concurrent sessions."""
+
+    def test_single_session(self, sharing):
+        """One session moving around should never conflict with itself."""
+        sharing.observe("alice", "s-1", _at(LONDON))
+        assessment = sharing.observe("alice", "s-1", _at(NEW_YORK, 5))
+        assert assessment.active_sessions == 1
+        assert assessment.flagged is False
+
+    def test_distant_concurrent_sessions_flagged(self, sharing):
+        """Sessions in London and New York five minutes apart should be flagged."""
+        sharing.observe("alice", "s-1", _at(LONDON, country_code="GB"))
+        assessment = sharing.observe("alice", "s-2", _at(NEW_YORK, 5, country_code="US"))
+        assert assessment.active_sessions == 2
+        assert assessment.flagged is True
+        conflict = assessment.conflicts[0]
+        assert conflict.session_id == "s-1"
+        assert conflict.distance_km == pytest.approx(5570, abs=10)
+        assert conflict.location.country_code == "GB"
+
+    def test_nearby_sessions_allowed(self, sharing):
+        """Two sessions in neighbouring towns are plausible for one person."""
+        sharing.observe("alice", "s-1", _at(LONDON))
+        assert sharing.observe("alice", "s-2", _at(READING, 1)).flagged is False
+
+    def test_idle_session_not_compared(self, sharing):
+        """Sessions idle longer than the TTL should no longer be active."""
+        sharing.observe("alice", "s-1", _at(LONDON))
+        assessment = sharing.observe("alice", "s-2", _at(NEW_YORK, 31))
+        assert assessment.active_sessions == 1
+        assert assessment.flagged is False
+
+    def test_accounts_and_tenants_separate(self, sharing):
+        """Sessions of other accounts and tenants should not be compared."""
+        sharing.observe("alice", "s-1", _at(LONDON), tenant_id="acme")
+        assert sharing.observe("bob", "s-2", _at(NEW_YORK, 1), tenant_id="acme").flagged is False
+        assert sharing.observe("alice", "s-3", _at(NEW_YORK, 1), tenant_id="globex").flagged is False
+
+    def test_empty_ids_rejected(self, sharing):
+        """Account and session IDs are required."""
+        with pytest.raises(ValueError):
+            sharing.observe(" ", "s-1", _at(LONDON))
+        with pytest.raises(ValueError):
+            sharing.observe("alice", "", _at(LONDON))
+
+
+class TestFlaggedAccounts:
+    """Test listing currently flagged accounts."""
+
+    def test_listed_while_current(self, sharing):
+        """A flagged account should be listed until its conflict lapses."""
+        sharing.observe("alice", "s-1", _at(LONDON, country_code="GB"))
+        sharing.observe("alice", "s-2", _at(NEW_YORK, 5, country_code="US"))
+        flags = sharing.flagged(now=T0 + timedelta(minutes=10))
+        assert [f.account_id for f in flags] == ["alice"]
+        assert flags[0].session_ids == ["s-2", "s-1"]
+        assert flags[0].country_codes == ["US", "GB"]
+        assert flags[0].flagged_at == T0 + timedelta(minutes=5)
+        assert sharing.flagged(now=T0 + timedelta(minutes=40)) == []
+
+    def test_repeated_conflicts_keep_flag_start(self, sharing):
+        """Ongoing conflicts should refresh the flag but keep when it was raised."""
+        sharing.observe("alice", "s-1", _at(LONDON))
+        sharing.observe("alice", "s-2", _at(NEW_YORK, 5))
+        sharing.observe("alice", "s-1", _at(LONDON, 20))
+        flag = sharing.flagged(now=T0 + timedelta(minutes=45))[0]
+        assert flag.flagged_at == T0 + timedelta(minutes=5)
+        assert flag.last_detected_at == T0 + timedelta(minutes=20)
+
+    def test_scoped_to_tenant(self, sharing):
+        """Tenants should only see their own flagged accounts."""
+        sharing.observe("alice", "s-1", _at(LONDON), tenant_id="acme")
+        sharing.observe("alice", "s-2", _at(NEW_YORK, 5), tenant_id="acme")
+        now = T0 + timedelta(minutes=10)
+        assert len(sharing.flagged("acme", now=now)) == 1
+        assert sharing.flagged("globex", now=now) == []
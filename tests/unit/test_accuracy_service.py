@@ -0,0 +1,97 @@
+"""Unit tests for lookup accuracy and confidence scoring."""
+import pytest
+
+from src.services.accuracy_service import (
+    AccuracyEstimator,
+    Granularity,
+    record_granularity,
+)
+from src.services.mmdb_service import GeoIPRecord
+
+
+BUILD_EPOCH = 1700000000
+DAY = 86400
+
+
+def _record(**fields):
+    return GeoIPRecord(ip_address="81.2.69.142", network="81.2.69.128/26", **fields)
+
+
+class TestGranularity:
+    """Test record granularity classification."""
+
+    @pytest.mark.parametrize("fields, expected", [
+        ({"city_name": "London", "postal_code": "EC2V"}, Granularity.POSTAL),
+        ({"city_name": "London"}, Granularity.CITY),
+        ({"subdivisions": [{"iso_code": "ENG"}], "country_iso_code": "GB"}, Granularity.SUBDIVISION),
+        ({"country_iso_code": "GB"}, Granularity.COUNTRY),
+        ({"continent_code": "EU"}, Granularity.CONTINENT),
+        ({}, None),
+    ])
+    def test_most_specific_level(self, fields, expected):
+        """The most specific named level should be reported."""
+        assert record_granularity(_record(**fields)) == expected
+
+    def test_postal_code_without_city(self):
+        """A postal code alone should not count as postal precision."""
+        assert record_granularity(_record(postal_code="EC2V", country_iso_code="GB")) == Granularity.COUNTRY
+
+
+class TestAccuracyEstimator:
+    """Test radius and confidence estimates."""
+
+    def test_dataset_radius_kept(self):
+        """A radius reported by the dataset should be used as is."""
+        estimate = AccuracyEstimator().estimate(_record(city_name="London", accuracy_radius_km=10))
+        assert estimate.accuracy_radius_km == 10
+        assert estimate.granularity == Granularity.CITY
+
+    def test_radius_from_granularity(self):
+        """A missing radius should default to the granularity's typical radius."""
+        estimate = AccuracyEstimator().estimate(_record(country_iso_code="GB"))
+        assert estimate.accuracy_radius_km == 1000.0
+        assert estimate.confidence == 0.4
+        assert estimate.confidence_flag == "RED"
+
+    def test_fresh_dataset(self):
+        """Fresh datasets should score the granularity's base confidence."""
+        estimate = AccuracyEstimator().estimate(
+            _record(city_name="London"), BUILD_EPOCH, now=BUILD_EPOCH
+        )
+        assert estimate.confidence == 0.85
+        assert estimate.dataset_age_days == 0.0
+        assert estimate.confidence_flag == "GREEN"
+
+    def test_confidence_halves_per_half_life(self):
+        """Confidence should halve after each half-life of dataset age."""
+        estimator = AccuracyEstimator(half_life_days=30)
+        estimate = estimator.estimate(
+            _record(city_name="London"), BUILD_EPOCH, now=BUILD_EPOCH + 30 * DAY
+        )
+        assert estimate.confidence == pytest.approx(0.425)
+        assert estimate.dataset_age_days == 30.0
+        assert estimate.confidence_flag == "RED"
+
+    def test_aging_disabled(self):
+        """A zero half-life should disable aging."""
+        estimate = AccuracyEstimator(half_life_days=0).estimate(
+            _record(city_name="London"), BUILD_EPOCH, now=BUILD_EPOCH + 3650 * DAY
+        )
+        assert estimate.confidence == 0.85
+
+    def test_unknown_place(self):
+        """Records naming no place should have zero confidence and no radius."""
+        estimate = AccuracyEstimator().estimate(_record(latitude=51.5, longitude=-0.1))
+        assert estimate.granularity is None
+        assert estimate.accuracy_radius_km is None
+        assert estimate.confidence == 0.0
+
+    def test_known_granularity(self):
+        """A supplied granularity (e.g. a tenant site) should override inference."""
+        estimate = AccuracyEstimator().estimate(
+            _record(city_name="London"), granularity=Granularity.SITE
+        )
+        assert estimate.granularity == "site"
+        assert estimate.accuracy_radius_km == 1.0
+        assert estimate.confidence == 1.0
+        assert estimate.confidence_flag == "GREEN"
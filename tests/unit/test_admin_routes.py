@@ -0,0 +1,503 @@
+"""Route tests for the admin API and the API key token exchange."""
+import json
+from datetime import datetime
+
+import pytest
+
+from src.services import location_encryption_service, mmdb_service
+from src.services.audit_log_service import AuditCategory, AuditLogService, AuditOutcome
+from src.services.auth_service import ADMIN_READ_SCOPE, ADMIN_SCOPE, AuthenticationError, TokenVerifier
+from src.services.device_history_service import DeviceHistoryService, DeviceObservation
+from src.services.oidc_service import OIDCVerifier
+from src.services.rbac_service import PolicyStore
+from src.services.request_signing_service import sign_request
+from src.services.token_denylist_service import InMemoryTokenDenylist
+from tests.mmdb_writer import build_mmdb
+from tests.unit.test_oidc_service import AUDIENCE, ISSUER, ROLES, FakeIdP
+
+SQUARE = {
+    "type": "Polygon",
+    "coordinates": [[[-0.13, 51.50], [-0.12, 51.50], [-0.12, 51.51], [-0.13, 51.51], [-0.13, 51.50]]],
+}
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API, with a test secret and denylist."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789", denylist=InMemoryTokenDenylist())
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    monkeypatch.setattr("src.api.auth_routes.get_token_verifier", lambda: verifier)
+    monkeypatch.setattr("src.api.admin_routes.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+@pytest.fixture
+def admin(verifier):
+    """Headers of an operator token with the admin scope."""
+    return {"Authorization": f"Bearer {verifier.issue('ops-1', 'platform', scopes=[ADMIN_SCOPE])}"}
+
+
+def _dataset(city: str, build_epoch: int) -> bytes:
+    return build_mmdb(
+        [("81.2.69.128/26", {"city": {"names": {"en": city}}})],
+        build_epoch=build_epoch,
+    )
+
+
+class TestAdminAuth:
+    """Test access to /admin/v1."""
+
+    def test_requires_token(self, db_client, verifier):
+        """Anonymous callers should get 401."""
+        assert db_client.get("/admin/v1/tenants").status_code == 401
+
+    def test_requires_admin_scope(self, db_client, verifier):
+        """Tenant tokens without the admin scope should get 403."""
+        headers = {"Authorization": f"Bearer {verifier.issue('user-1', 'acme')}"}
+        response = db_client.get("/admin/v1/tenants", headers=headers)
+        assert response.status_code == 403
+        assert response.json()["detail"]["error_code"] == "E010"
+
+    def test_read_only_scope(self, db_client, verifier):
+        """The admin:read scope should allow reads only."""
+        token = verifier.issue("support-1", "platform", scopes=[ADMIN_READ_SCOPE])
+        headers = {"Authorization": f"Bearer {token}"}
+        assert db_client.get("/admin/v1/tenants", headers=headers).status_code == 200
+        response = db_client.post("/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=headers)
+        assert response.status_code == 403
+
+    def test_oidc_token(self, db_client, verifier, monkeypatch):
+        """Operators should sign in with IdP tokens, their groups deciding access."""
+        idp = FakeIdP()
+        oidc = OIDCVerifier(ISSUER, AUDIENCE, ROLES, fetch=idp.fetch)
+        monkeypatch.setattr("src.api.dependencies.get_oidc_verifier", lambda: oidc)
+
+        ops = {"Authorization": f"Bearer {idp.token(groups=['geo-ops'])}"}
+        response = db_client.post("/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=ops)
+        assert response.status_code == 201
+        support = {"Authorization": f"Bearer {idp.token(groups=['geo-support'])}"}
+        assert db_client.get("/admin/v1/tenants", headers=support).status_code == 200
+        assert db_client.post("/admin/v1/tenants/acme/keys/rotate", headers=support).status_code == 403
+        stranger = {"Authorization": f"Bearer {idp.token(groups=['staff'])}"}
+        assert db_client.get("/admin/v1/tenants", headers=stranger).status_code == 403
+        expired = {"Authorization": f"Bearer {idp.token(exp=1)}"}
+        assert db_client.get("/admin/v1/tenants", headers=expired).status_code == 401
+
+    def test_rbac_policy(self, test_client, admin, tmp_path, monkeypatch):
+        """The active policy should be listed and reloadable on demand."""
+        path = tmp_path / "rbac.json"
+        path.write_text('{"rules": [{"id": "lookups", "path": "/api/v1/lookup/**", "scopes": ["lookup:read"]}]}')
+        store = PolicyStore(str(path))
+        monkeypatch.setattr("src.api.admin_routes.get_policy_store", lambda: store)
+
+        policy = test_client.get("/admin/v1/rbac/policy", headers=admin).json()
+        assert [rule["id"] for rule in policy["rules"]] == ["lookups"]
+        path.write_text('{"rules": "nope"}')
+        response = test_client.post("/admin/v1/rbac/policy/reload", headers=admin)
+        assert response.status_code == 400
+        assert test_client.get("/admin/v1/rbac/policy", headers=admin).json()["last_error"] is not None
+
+    def test_openapi_security(self, test_client):
+        """Admin operations should declare bearer security."""
+        spec = test_client.get("/openapi.json").json()
+        assert spec["paths"]["/admin/v1/tenants"]["post"]["security"] == [{"bearerAuth": []}]
+        assert "security" not in spec["paths"]["/api/v1/auth/token"]["post"]
+
+
+class TestTenantRoutes:
+    """Test tenant management and API key exchange."""
+
+    def test_create_and_exchange_key(self, db_client, verifier, admin):
+        """A new tenant's key should buy tokens for that tenant."""
+        response = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin
+        )
+        assert response.status_code == 201
+        tenant = response.json()
+        assert tenant["api_key"].startswith("gek_")
+        assert [k["active"] for k in tenant["api_keys"]] == [True]
+
+        response = db_client.post("/api/v1/auth/token", json={"api_key": tenant["api_key"]})
+        assert response.status_code == 200
+        token = response.json()
+        assert (token["token_type"], token["tenant_id"], token["expires_in"]) == ("bearer", "acme", 3600)
+        assert token["refresh_token"].startswith("ger_")
+        principal = verifier.verify(token["access_token"])
+        assert principal.tenant_id == "acme"
+        assert principal.scopes == {"lookup:read", "geofence:read"}
+
+        # The key is not shown again
+        listed = db_client.get("/admin/v1/tenants/acme", headers=admin).json()
+        assert listed["api_key"] is None
+        assert listed["api_keys"][0]["prefix"] == tenant["api_key"][:12]
+
+    def test_refresh_and_revoke(self, db_client, verifier, admin):
+        """Refresh tokens should rotate, and revoking one should end the session."""
+        api_key = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin
+        ).json()["api_key"]
+        first = db_client.post("/api/v1/auth/token", json={"api_key": api_key}).json()
+
+        response = db_client.post("/api/v1/auth/refresh", json={"refresh_token": first["refresh_token"]})
+        assert response.status_code == 200
+        second = response.json()
+        assert second["refresh_token"] != first["refresh_token"]
+
+        response = db_client.post("/api/v1/auth/revoke", json={"token": second["refresh_token"]})
+        assert response.json() == {"revoked": True}
+        with pytest.raises(AuthenticationError, match="revoked"):
+            verifier.verify(second["access_token"])
+        response = db_client.post("/api/v1/auth/refresh", json={"refresh_token": second["refresh_token"]})
+        assert response.status_code == 401
+
+    def test_refresh_reuse_is_401(self, db_client, admin):
+        """A spent refresh token should be refused."""
+        api_key = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin
+        ).json()["api_key"]
+        refresh = db_client.post("/api/v1/auth/token", json={"api_key": api_key}).json()["refresh_token"]
+        assert db_client.post("/api/v1/auth/refresh", json={"refresh_token": refresh}).status_code == 200
+        response = db_client.post("/api/v1/auth/refresh", json={"refresh_token": refresh})
+        assert response.status_code == 401
+        assert response.json()["detail"]["error_code"] == "E006"
+
+    def test_revoke_access_token(self, db_client, verifier):
+        """A revoked access token should be refused by the API."""
+        token = verifier.issue("user-1", "acme")
+        assert db_client.post("/api/v1/auth/revoke", json={"token": token}).json() == {"revoked": True}
+        response = db_client.get("/admin/v1/tenants", headers={"Authorization": f"Bearer {token}"})
+        assert response.status_code == 401
+        assert db_client.post("/api/v1/auth/revoke", json={"token": "garbage"}).json() == {"revoked": False}
+
+    def test_key_scopes(self, db_client, verifier, admin):
+        """Tokens should carry the scopes given to the tenant's key."""
+        tenant = db_client.post(
+            "/admin/v1/tenants",
+            json={"tenant_id": "acme", "name": "Acme", "scopes": ["geofence:write"]},
+            headers=admin,
+        ).json()
+        assert tenant["api_keys"][0]["scopes"] == ["geofence:write"]
+        token = db_client.post("/api/v1/auth/token", json={"api_key": tenant["api_key"]}).json()
+        assert verifier.verify(token["access_token"]).scopes == {"geofence:write"}
+
+        response = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "globex", "name": "Globex", "scopes": ["Bad"]}, headers=admin
+        )
+        assert response.status_code == 400
+
+    def test_key_allowed_cidrs(self, db_client, verifier, admin, monkeypatch):
+        """A network-restricted key and its tokens should only work from its networks."""
+        tenant = db_client.post(
+            "/admin/v1/tenants",
+            json={"tenant_id": "acme", "name": "Acme", "allowed_cidrs": ["10.1.2.3/8"]},
+            headers=admin,
+        ).json()
+        [key] = tenant["api_keys"]
+        assert key["allowed_cidrs"] == ["10.0.0.0/8"]
+        response = db_client.post("/api/v1/auth/token", json={"api_key": tenant["api_key"]})
+        assert response.status_code == 403
+        assert response.json()["detail"]["error_code"] == "E011"
+
+        monkeypatch.setenv("API_KEY_CLIENT_IP_HEADER", "X-Forwarded-For")
+        proxied = {"X-Forwarded-For": "198.51.100.7, 10.4.5.6"}
+        token = db_client.post("/api/v1/auth/token", json={"api_key": tenant["api_key"]}, headers=proxied).json()
+        assert verifier.verify(token["access_token"]).tenant_id == "acme"
+        # The app's middleware sees the test client's own address
+        response = db_client.get("/api/v1/risk/areas", headers={"Authorization": f"Bearer {token['access_token']}"})
+        assert response.status_code == 403
+        assert response.json()["detail"]["details"] == {"client_ip": "testclient"}
+        response = db_client.post("/api/v1/auth/refresh", json={"refresh_token": token["refresh_token"]})
+        assert response.status_code == 403
+
+        response = db_client.put(
+            f"/admin/v1/tenants/acme/keys/{key['key_id']}/allowed-cidrs", json={"allowed_cidrs": []}, headers=admin
+        )
+        assert response.status_code == 200
+        assert response.json()["allowed_cidrs"] == []
+        assert db_client.post("/api/v1/auth/token", json={"api_key": tenant["api_key"]}).status_code == 200
+        response = db_client.put(
+            f"/admin/v1/tenants/acme/keys/{key['key_id']}/allowed-cidrs", json={"allowed_cidrs": ["10.0.0.300/8"]},
+            headers=admin,
+        )
+        assert response.status_code == 400
+        response = db_client.put(
+            "/admin/v1/tenants/acme/keys/missing/allowed-cidrs", json={"allowed_cidrs": []}, headers=admin
+        )
+        assert response.status_code == 404
+
+    def test_signed_requests(self, db_client, verifier, admin):
+        """Once a tenant has a signing key its tokens should only work on signed requests."""
+        api_key = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin
+        ).json()["api_key"]
+        response = db_client.post("/admin/v1/tenants/acme/signing-keys", headers=admin)
+        assert response.status_code == 201
+        issued = response.json()
+        key_id, secret = issued["key"]["key_id"], issued["secret"]
+        listed = db_client.get("/admin/v1/tenants/acme/signing-keys", headers=admin).json()
+        assert listed["signing_required"] is True
+        assert "secret" not in listed["signing_keys"][0]
+
+        token = db_client.post("/api/v1/auth/token", json={"api_key": api_key}).json()["access_token"]
+        assert verifier.verify(token).signed_requests
+        body = json.dumps({"kind": "country", "code": "IR"}).encode()
+        headers = {"Authorization": f"Bearer {token}", "Content-Type": "application/json"}
+        response = db_client.post("/api/v1/risk/areas", content=body, headers=headers)
+        assert response.status_code == 401
+        assert "signed" in response.json()["detail"]["error_message"]
+
+        signature = sign_request(key_id, secret, "POST", "/api/v1/risk/areas", body=body)
+        signed = {**headers, "X-Request-Signature": signature}
+        assert db_client.post("/api/v1/risk/areas", content=body, headers=signed).status_code == 201
+        response = db_client.post("/api/v1/risk/areas", content=body, headers=signed)
+        assert response.status_code == 401
+        assert "replayed" in response.json()["detail"]["error_message"]
+        tampered = {**headers, "X-Request-Signature": sign_request(key_id, secret, "POST", "/api/v1/risk/areas")}
+        assert db_client.post("/api/v1/risk/areas", content=body, headers=tampered).status_code == 401
+
+        assert db_client.delete(f"/admin/v1/tenants/acme/signing-keys/{key_id}", headers=admin).status_code == 204
+        token = db_client.post("/api/v1/auth/token", json={"api_key": api_key}).json()["access_token"]
+        assert not verifier.verify(token).signed_requests
+
+    def test_invalid_key_is_401(self, db_client, verifier):
+        """Unknown keys should not be exchanged."""
+        response = db_client.post("/api/v1/auth/token", json={"api_key": "gek_unknown"})
+        assert response.status_code == 401
+        assert response.json()["detail"]["error_code"] == "E006"
+
+    def test_duplicate_tenant_is_400(self, db_client, admin):
+        """Tenant IDs should be unique."""
+        body = {"tenant_id": "acme", "name": "Acme"}
+        assert db_client.post("/admin/v1/tenants", json=body, headers=admin).status_code == 201
+        response = db_client.post("/admin/v1/tenants", json=body, headers=admin)
+        assert response.status_code == 400
+
+    def test_rotate_key(self, db_client, admin):
+        """Rotation without grace should retire the previous key at once."""
+        first = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin
+        ).json()["api_key"]
+
+        response = db_client.post(
+            "/admin/v1/tenants/acme/keys/rotate", json={"grace_seconds": 0}, headers=admin
+        )
+        assert response.status_code == 201
+        rotated = response.json()
+        assert [k["active"] for k in rotated["api_keys"]] == [False, True]
+
+        assert db_client.post("/api/v1/auth/token", json={"api_key": first}).status_code == 401
+        assert db_client.post("/api/v1/auth/token", json={"api_key": rotated["api_key"]}).status_code == 200
+
+    def test_rotate_default_grace(self, db_client, admin):
+        """Without a body the previous key should keep working for the grace period."""
+        first = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin
+        ).json()["api_key"]
+        response = db_client.post("/admin/v1/tenants/acme/keys/rotate", headers=admin)
+        assert response.status_code == 201
+        assert response.json()["api_keys"][0]["expires_at"] is not None
+        assert db_client.post("/api/v1/auth/token", json={"api_key": first}).status_code == 200
+
+    def test_revoke_key_ends_sessions(self, db_client, verifier, admin):
+        """A revoked key should stop working along with the tokens issued for it."""
+        tenant = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin
+        ).json()
+        key_id = tenant["api_keys"][0]["key_id"]
+        token = db_client.post("/api/v1/auth/token", json={"api_key": tenant["api_key"]}).json()
+
+        response = db_client.delete(f"/admin/v1/tenants/acme/keys/{key_id}", headers=admin)
+        assert response.status_code == 204
+        assert db_client.post("/api/v1/auth/token", json={"api_key": tenant["api_key"]}).status_code == 401
+        response = db_client.post("/api/v1/auth/refresh", json={"refresh_token": token["refresh_token"]})
+        assert response.status_code == 401
+        with pytest.raises(AuthenticationError, match="revoked"):
+            verifier.verify(token["access_token"])
+
+        assert db_client.delete(f"/admin/v1/tenants/acme/keys/{key_id}", headers=admin).status_code == 204
+        assert db_client.delete("/admin/v1/tenants/acme/keys/missing", headers=admin).status_code == 404
+
+    def test_find_key_by_prefix(self, db_client, admin):
+        """Keys should be found by their leading characters, with their last use."""
+        api_key = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin
+        ).json()["api_key"]
+        db_client.post("/api/v1/auth/token", json={"api_key": api_key})
+
+        response = db_client.get("/admin/v1/api-keys", params={"prefix": api_key}, headers=admin)
+        assert response.status_code == 200
+        [key] = response.json()["api_keys"]
+        assert key["tenant_id"] == "acme"
+        assert key["last_used_at"] is not None
+        response = db_client.get("/admin/v1/api-keys", params={"prefix": "abc"}, headers=admin)
+        assert response.status_code == 400
+
+    def test_scheduled_rotation(self, db_client, admin, monkeypatch):
+        """With a maximum age, new keys should carry their rotation schedule."""
+        monkeypatch.setenv("TENANT_KEY_MAX_AGE_DAYS", "90")
+        monkeypatch.setenv("TENANT_KEY_ROTATION_GRACE_SECONDS", "3600")
+        [key] = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin
+        ).json()["api_keys"]
+        assert key["rotate_after"] is not None and key["rotation_due"] is False
+        listed = db_client.get("/admin/v1/api-keys", params={"rotation_due": True}, headers=admin)
+        assert listed.json()["api_keys"] == []
+
+    def test_location_data_keys(self, db_client, db_session, admin, monkeypatch):
+        """Data keys should be listed, rotated, emptied by re-encryption and re-wrapped."""
+        monkeypatch.setattr(location_encryption_service, "_master_keys", None)
+        db_client.post("/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme"}, headers=admin)
+        response = db_client.post("/admin/v1/tenants/acme/data-keys/rotate", headers=admin)
+        assert response.status_code == 503
+
+        monkeypatch.setenv("LOCATION_ENCRYPTION_MASTER_KEY", "YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWE=")
+        monkeypatch.setattr(location_encryption_service, "_master_keys", None)
+        DeviceHistoryService(db_session).record("fp-1", DeviceObservation(datetime(2026, 3, 1), "GB"), "acme")
+        listed = db_client.get("/admin/v1/tenants/acme/data-keys", headers=admin).json()
+        assert listed["encryption_enabled"] is True
+        [old_key] = listed["data_keys"]
+        assert old_key["active"] is True and old_key["rows"] == 1
+
+        rotated = db_client.post("/admin/v1/tenants/acme/data-keys/rotate", headers=admin)
+        assert rotated.status_code == 201
+        assert [k["active"] for k in rotated.json()["data_keys"]] == [False, True]
+        result = db_client.post("/admin/v1/tenants/acme/data-keys/reencrypt", headers=admin).json()
+        assert result == {"tenant_id": "acme", "reencrypted": 1, "remaining": 0, "deleted_keys": [old_key["key_id"]]}
+        rewrap = db_client.post("/admin/v1/data-keys/rewrap", headers=admin).json()
+        assert rewrap["rewrapped"] == 0
+        assert db_client.get("/admin/v1/tenants/missing/data-keys", headers=admin).status_code == 404
+
+    def test_unknown_tenant_is_404(self, db_client, admin):
+        """Missing tenants should be 404."""
+        assert db_client.get("/admin/v1/tenants/missing", headers=admin).status_code == 404
+        response = db_client.post("/admin/v1/tenants/missing/keys/rotate", headers=admin)
+        assert response.status_code == 404
+
+
+class TestGeofenceAdminRoutes:
+    """Test geofence management."""
+
+    def test_create_update_delete(self, db_client, admin):
+        """A geofence should be replaceable and removable."""
+        response = db_client.post(
+            "/admin/v1/geofences", json={"name": "Depot", "geometry": SQUARE}, headers=admin
+        )
+        assert response.status_code == 201
+        geofence_id = response.json()["geofence_id"]
+
+        response = db_client.put(
+            f"/admin/v1/geofences/{geofence_id}",
+            json={"name": "Yard", "wkt": "POLYGON ((2 48, 2.01 48, 2.01 48.01, 2 48.01, 2 48))"},
+            headers=admin,
+        )
+        assert response.status_code == 200
+        assert response.json()["name"] == "Yard"
+        match = db_client.get("/api/v1/geofences/match", params={"lat": 48.005, "lon": 2.005}).json()
+        assert match["geofence_ids"] == [geofence_id]
+
+        listed = db_client.get("/admin/v1/geofences", headers=admin).json()
+        assert [g["geofence_id"] for g in listed["geofences"]] == [geofence_id]
+
+        assert db_client.delete(f"/admin/v1/geofences/{geofence_id}", headers=admin).status_code == 204
+        assert db_client.get(f"/api/v1/geofences/{geofence_id}").status_code == 404
+        assert db_client.delete(f"/admin/v1/geofences/{geofence_id}", headers=admin).status_code == 404
+
+    def test_invalid_update_is_400(self, db_client, admin):
+        """Updates should validate geometry like creation."""
+        geofence_id = db_client.post(
+            "/admin/v1/geofences", json={"name": "Depot", "geometry": SQUARE}, headers=admin
+        ).json()["geofence_id"]
+        response = db_client.put(
+            f"/admin/v1/geofences/{geofence_id}", json={"name": "Depot"}, headers=admin
+        )
+        assert response.status_code == 400
+
+    def test_update_missing_is_404(self, db_client, admin):
+        """Unknown geofences should be 404."""
+        response = db_client.put(
+            "/admin/v1/geofences/missing", json={"name": "Depot", "geometry": SQUARE}, headers=admin
+        )
+        assert response.status_code == 404
+
+
+class TestAuditLogRoutes:
+    """Test /admin/v1/audit/log."""
+
+    def test_query_and_verify(self, db_client, db_session, admin):
+        """Entries should be filtered and paged, and the chain verified."""
+        log = AuditLogService(db_session)
+        log.append(AuditCategory.ADMIN, "create_tenant", AuditOutcome.SUCCESS, actor="ops-1", tenant_id="acme")
+        log.append(AuditCategory.DETECTION, "detect_anomaly", AuditOutcome.CLEAR, tenant_id="acme", resource="user:alice")
+        log.append(AuditCategory.ADMIN, "create_tenant", AuditOutcome.SUCCESS, actor="ops-2", tenant_id="globex")
+
+        body = db_client.get("/admin/v1/audit/log", params={"tenant_id": "acme", "limit": 1}, headers=admin).json()
+        assert [e["action"] for e in body["entries"]] == ["detect_anomaly"]
+        following = db_client.get(
+            "/admin/v1/audit/log", params={"tenant_id": "acme", "cursor": body["next_cursor"]}, headers=admin
+        ).json()
+        assert [e["actor"] for e in following["entries"]] == ["ops-1"]
+        by_actor = db_client.get("/admin/v1/audit/log", params={"actor": "ops-2"}, headers=admin).json()
+        assert [e["tenant_id"] for e in by_actor["entries"]] == ["globex"]
+
+        verified = db_client.get("/admin/v1/audit/log/verify", headers=admin).json()
+        assert verified["valid"] is True
+        assert verified["entries"] == 3
+        assert verified["head_hash"] == by_actor["entries"][0]["entry_hash"]
+
+    def test_invalid_cursor_is_400(self, db_client, admin):
+        """A malformed cursor should be rejected."""
+        response = db_client.get("/admin/v1/audit/log", params={"cursor": "garbage"}, headers=admin)
+        assert response.status_code == 400
+
+
+class TestDatasetRoutes:
+    """Test GeoIP dataset uploads."""
+
+    @pytest.fixture
+    def database_path(self, tmp_path, monkeypatch):
+        """Active dataset path in a temporary directory, restored global reader."""
+        path = tmp_path / "GeoLite2-City.mmdb"
+        path.write_bytes(_dataset("Old London", 1700000000))
+        monkeypatch.setenv("GEOIP_DATABASE_PATH", str(path))
+        monkeypatch.setattr(mmdb_service, "_mmdb_reader", None)
+        monkeypatch.setattr("src.api.admin_routes.get_snapshot_store", lambda: None)
+        return path
+
+    def test_upload_activates_dataset(self, test_client, admin, database_path):
+        """An uploaded MMDB should replace the active dataset."""
+        response = test_client.post(
+            "/admin/v1/datasets/geoip",
+            files={"file": ("GeoLite2-City.mmdb", _dataset("New London", 1800000000))},
+            headers=admin,
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert body["updated"] is True
+        assert body["dataset"]["build_epoch"] == 1800000000
+        assert mmdb_service.get_mmdb_reader().lookup("81.2.69.142").city_name == "New London"
+
+        again = test_client.post(
+            "/admin/v1/datasets/geoip",
+            files={"file": ("GeoLite2-City.mmdb", _dataset("New London", 1800000000))},
+            headers=admin,
+        )
+        assert again.json()["updated"] is False
+        assert test_client.get("/admin/v1/datasets/geoip", headers=admin).json()["sha256"] == body["dataset"]["sha256"]
+
+    def test_invalid_upload_is_400(self, test_client, admin, database_path):
+        """A file that is not an MMDB should leave the dataset in place."""
+        response = test_client.post(
+            "/admin/v1/datasets/geoip", files={"file": ("bad.mmdb", b"garbage")}, headers=admin
+        )
+        assert response.status_code == 400
+        assert database_path.read_bytes() == _dataset("Old London", 1700000000)
+
+    def test_oversized_upload_is_413(self, test_client, admin, database_path, monkeypatch):
+        """Uploads over ADMIN_DATASET_MAX_BYTES should be refused."""
+        monkeypatch.setenv("ADMIN_DATASET_MAX_BYTES", "10")
+        response = test_client.post(
+            "/admin/v1/datasets/geoip",
+            files={"file": ("GeoLite2-City.mmdb", _dataset("New London", 1800000000))},
+            headers=admin,
+        )
+        assert response.status_code == 413
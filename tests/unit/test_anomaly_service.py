@@ -0,0 +1,147 @@
+"""Unit tests for location anomaly detection."""
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.models.database_models import LoginLocation
+from src.services.anomaly_service import (
+    LocationAnomalyDetector,
+    LocationProfile,
+    fit_profile,
+    spherical_centroid,
+)
+
+T0 = datetime(2026, 3, 1, 9, 0)
+
+# Logins around London (about 10-30 km apart)
+LONDON_AREA = [(51.5142, -0.0931), (51.45, -0.98), (51.60, -0.20), (51.38, -0.10), (51.52, 0.05), (51.50, -0.30)]
+NEW_YORK = (40.7128, -74.0060)
+
+
+def _store_logins(session, user_id, points, tenant_id=None):
+    for i, (lat, lon) in enumerate(points):
+        session.add(LoginLocation(
+            tenant_id=tenant_id, user_id=user_id, observed_at=T0 + timedelta(hours=i),
+            latitude=lat, longitude=lon,
+        ))
+    session.commit()
+
+
+@pytest.fixture
+def detector(db_session):
+    """Detector with the default 3.5 threshold, 5 samples and a 25 km MAD floor."""
+    return LocationAnomalyDetector(db_session, threshold=3.5, min_samples=5, min_mad_km=25, history_limit=100)
+
+
+class TestProfileFitting:
+    """Test centroid and MAD computation."""
+
+    def test_centroid_across_antimeridian(self):
+        """The centroid of points either side of 180 degrees should lie on it."""
+        lat, lon = spherical_centroid([(0.0, 179.0), (0.0, -179.0)])
+        assert lat == pytest.approx(0.0, abs=1e-9)
+        assert abs(lon) == pytest.approx(180.0)
+
+    def test_empty(self):
+        """Fitting needs at least one point."""
+        with pytest.raises(ValueError):
+            spherical_centroid([])
+
+    def test_fit(self):
+        """Profiles should have a centroid among the points and a small spread."""
+        profile = fit_profile("alice", LONDON_AREA, T0)
+        assert profile.centroid_lat == pytest.approx(51.5, abs=0.1)
+        assert profile.centroid_lon == pytest.approx(-0.27, abs=0.1)
+        assert profile.samples == 6
+        assert 0 < profile.median_distance_km < 60
+        assert profile.mad_km < profile.median_distance_km
+
+
+class TestAnomalyScoring:
+    """Test scoring events against a profile."""
+
+    def test_usual_area_not_flagged(self, detector):
+        """A login in the user's usual area should not be anomalous."""
+        assessment = detector.score(fit_profile("alice", LONDON_AREA, T0), 51.47, -0.45)
+        assert assessment.anomalous is False
+        assert assessment.score < 3.5
+
+    def test_distant_flagged(self, detector):
+        """A login across the Atlantic should be anomalous."""
+        assessment = detector.score(fit_profile("alice", LONDON_AREA, T0), *NEW_YORK)
+        assert assessment.anomalous is True
+        assert assessment.distance_km == pytest.approx(5560, abs=30)
+
+    def test_mad_floor(self, detector):
+        """A user always seen in one place should not be flagged for crossing town."""
+        profile = fit_profile("bob", [LONDON_AREA[0]] * 10, T0)
+        assert profile.mad_km == 0
+        assert detector.score(profile, 51.60, -0.20).anomalous is False
+        assert detector.score(profile, 53.48, -2.24).anomalous is True  # Manchester
+
+    def test_insufficient_history(self, detector):
+        """Profiles with too few logins should give no verdict."""
+        assessment = detector.score(fit_profile("alice", LONDON_AREA[:3], T0), *NEW_YORK)
+        assert (assessment.anomalous, assessment.score, assessment.samples) == (False, None, 3)
+
+    def test_invalid_coordinate(self, detector):
+        """Out-of-range coordinates should be rejected."""
+        with pytest.raises(ValueError):
+            detector.score(fit_profile("alice", LONDON_AREA, T0), 95.0, 0.0)
+
+
+class TestAnomalyTraining:
+    """Test training from stored logins and inline evaluation."""
+
+    def test_train_and_store(self, detector, db_session):
+        """Training should store a profile that can be read back and replaced."""
+        _store_logins(db_session, "alice", LONDON_AREA)
+        trained = detector.train("alice")
+        assert detector.profile("alice").samples == trained.samples == 6
+        _store_logins(db_session, "alice", [NEW_YORK])
+        assert detector.train("alice").samples == 7
+        assert detector.profile("alice", tenant_id="acme") is None
+
+    def test_train_without_logins(self, detector):
+        """Users without logins cannot be trained."""
+        with pytest.raises(LookupError):
+            detector.train("nobody")
+
+    def test_history_limit(self, db_session):
+        """Only the most recent logins should be trained on."""
+        _store_logins(db_session, "alice", LONDON_AREA)
+        detector = LocationAnomalyDetector(db_session, history_limit=4)
+        assert detector.train("alice").samples == 4
+
+    def test_evaluate_trains_lazily(self, detector, db_session):
+        """Evaluation should train a missing profile and score against it."""
+        _store_logins(db_session, "alice", LONDON_AREA)
+        assert detector.evaluate("alice", *NEW_YORK).anomalous is True
+        assert detector.profile("alice") is not None
+
+    def test_evaluate_new_user(self, detector):
+        """Users without history should get no verdict."""
+        assessment = detector.evaluate("carol", *NEW_YORK)
+        assert (assessment.anomalous, assessment.distance_km, assessment.samples) == (False, None, 0)
+
+    def test_stale_profile_retrained(self, db_session):
+        """Profiles older than the maximum age should be retrained on evaluation."""
+        _store_logins(db_session, "alice", LONDON_AREA)
+        detector = LocationAnomalyDetector(db_session, max_profile_age=timedelta(0))
+        detector.train("alice")
+        _store_logins(db_session, "alice", [NEW_YORK] * 10)
+        # Most logins are now in New York
+        assert detector.evaluate("alice", *NEW_YORK).anomalous is False
+
+    def test_train_all(self, detector, db_session):
+        """All users of all tenants should be trained."""
+        _store_logins(db_session, "alice", LONDON_AREA)
+        _store_logins(db_session, "alice", [NEW_YORK], tenant_id="acme")
+        assert detector.train_all() == 2
+        assert detector.profile("alice", tenant_id="acme").samples == 1
+
+
+def test_profile_to_dict():
+    """Profiles should serialize trained_at as UTC."""
+    profile = LocationProfile("alice", 51.5, -0.1, 12.0, 5.0, 20, T0)
+    assert profile.to_dict()["trained_at"].tzinfo is not None
@@ -0,0 +1,202 @@
+"""Unit tests for VPN / proxy / Tor / hosting detection."""
+import pytest
+
+from src.services.anonymizer_service import AnonymizerEnricher, load_tor_exit_list
+from src.services.asn_service import AsnEnricher
+from src.services.ip_lookup_service import IpLookupResult, IpRangeSet, normalize_ip
+from src.services.mmdb_service import MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+
+def _result(ip):
+    return IpLookupResult(normalized=normalize_ip(ip))
+
+
+@pytest.fixture
+def asn_enricher(tmp_path):
+    """ASN enricher with one hosting and one residential ASN."""
+    path = write_mmdb(
+        tmp_path / "asn.mmdb",
+        [
+            ("3.0.0.0/8", {"autonomous_system_number": 16509,
+                           "autonomous_system_organization": "AMAZON-02"}),
+            ("95.216.0.0/16", {"autonomous_system_number": 99999,
+                               "autonomous_system_organization": "Example Hosting Ltd"}),
+            ("81.2.69.0/24", {"autonomous_system_number": 20712,
+                              "autonomous_system_organization": "Andrews & Arnold Ltd"}),
+        ],
+    )
+    reader = MMDBReader(path)
+    yield AsnEnricher(reader)
+    reader.close()
+
+
+class TestIpRangeSet:
+    """Test CIDR set membership."""
+
+    def test_membership(self):
+        """Addresses inside listed networks should match."""
+        ranges = IpRangeSet(["10.0.0.0/8", "192.0.2.7", "2001:db8::/32"])
+        assert "10.20.30.40" in ranges
+        assert "192.0.2.7" in ranges
+        assert "192.0.2.8" not in ranges
+        assert "2001:db8::1" in ranges
+        assert "2001:db9::1" not in ranges
+        assert len(ranges) == 3
+
+    def test_overlapping_ranges_merged(self):
+        """Overlapping and adjacent ranges should still match correctly."""
+        ranges = IpRangeSet(["10.0.0.0/24", "10.0.0.128/25", "10.0.1.0/24"])
+        assert "10.0.0.200" in ranges
+        assert "10.0.1.255" in ranges
+        assert "10.0.2.0" not in ranges
+
+    def test_empty(self):
+        """Empty sets should match nothing."""
+        assert "10.0.0.1" not in IpRangeSet()
+
+    def test_from_file(self, tmp_path):
+        """Files should support comments and blank lines."""
+        path = tmp_path / "vpn.txt"
+        path.write_text("# NordVPN\n185.0.0.0/16  # frankfurt\n\n2a0d::/29\n")
+        ranges = IpRangeSet.from_file(str(path))
+        assert "185.0.1.1" in ranges
+        assert "2a0d::1" in ranges
+
+    def test_invalid_entry(self):
+        """Invalid entries should raise ValueError."""
+        with pytest.raises(ValueError):
+            IpRangeSet(["not-a-network"])
+
+
+class TestTorExitList:
+    """Test Tor exit list parsing."""
+
+    def test_bulk_format(self, tmp_path):
+        """One address per line should be accepted."""
+        path = tmp_path / "tor.txt"
+        path.write_text("185.220.101.1\n185.220.101.2\n")
+        exits = load_tor_exit_list(str(path))
+        assert "185.220.101.2" in exits
+
+    def test_exit_addresses_format(self, tmp_path):
+        """ExitAddress records should be parsed."""
+        path = tmp_path / "exit-addresses"
+        path.write_text(
+            "ExitNode 0011BD2485AD45D984EC4159C88FC066E5E3300E\n"
+            "Published 2026-02-10 06:40:56\n"
+            "ExitAddress 162.247.74.201 2026-02-10 07:03:08\n"
+        )
+        exits = load_tor_exit_list(str(path))
+        assert "162.247.74.201" in exits
+        assert len(exits) == 1
+
+
+class TestAnonymizerEnricher:
+    """Test anonymizer flags."""
+
+    def test_clean_address(self):
+        """Unlisted addresses should have all checked flags false."""
+        enricher = AnonymizerEnricher(
+            vpn_ranges=IpRangeSet(["185.0.0.0/16"]),
+            proxy_ranges=IpRangeSet(["198.51.100.0/24"]),
+            tor_exits=IpRangeSet(["162.247.74.201"]),
+        )
+        flags = enricher.enrich(_result("81.2.69.142"))
+        assert flags == {
+            "vpn": False, "tor": False, "proxy": False, "residential_proxy": None, "hosting": None
+        }
+
+    def test_no_sources(self):
+        """Without any source the block should be omitted, not reported clean."""
+        enricher = AnonymizerEnricher()
+        assert enricher.has_sources is False
+        assert enricher.enrich(_result("81.2.69.142")) is None
+
+    def test_unchecked_flags_unknown(self):
+        """Flags without a covering source should be None."""
+        enricher = AnonymizerEnricher(tor_exits=IpRangeSet(["162.247.74.201"]))
+        flags = enricher.enrich(_result("81.2.69.142"))
+        assert flags == {
+            "vpn": None, "tor": False, "proxy": None, "residential_proxy": None, "hosting": None
+        }
+
+    def test_vpn(self):
+        """VPN ranges should set the vpn flag."""
+        enricher = AnonymizerEnricher(vpn_ranges=IpRangeSet(["185.0.0.0/16"]))
+        assert enricher.enrich(_result("185.0.3.4"))["vpn"] is True
+
+    def test_tor(self):
+        """Tor exits should set the tor flag."""
+        enricher = AnonymizerEnricher(tor_exits=IpRangeSet(["162.247.74.201"]))
+        assert enricher.enrich(_result("162.247.74.201"))["tor"] is True
+
+    def test_proxy(self):
+        """Proxy ranges should set the proxy flag."""
+        enricher = AnonymizerEnricher(proxy_ranges=IpRangeSet(["198.51.100.0/24"]))
+        assert enricher.enrich(_result("198.51.100.9"))["proxy"] is True
+
+    def test_residential_proxy(self):
+        """Residential proxy endpoint lists should set their own flag, not proxy."""
+        enricher = AnonymizerEnricher(residential_proxy_ranges=IpRangeSet(["100.64.0.0/16"]))
+        flags = enricher.enrich(_result("100.64.7.7"))
+        assert flags["residential_proxy"] is True
+        assert flags["proxy"] is None
+
+    def test_tunneled_address_matched(self):
+        """Embedded IPv4 addresses should be checked against the lists."""
+        enricher = AnonymizerEnricher(tor_exits=IpRangeSet(["81.2.69.142"]))
+        assert enricher.enrich(_result("2002:5102:458e::1"))["tor"] is True
+
+    def test_hosting_asn(self, asn_enricher):
+        """Known hosting ASNs should set the hosting flag."""
+        enricher = AnonymizerEnricher(asn_enricher=asn_enricher)
+        assert enricher.enrich(_result("3.5.140.2"))["hosting"] is True
+
+    def test_hosting_organization(self, asn_enricher):
+        """Hosting organization names should set the hosting flag."""
+        enricher = AnonymizerEnricher(asn_enricher=asn_enricher)
+        assert enricher.enrich(_result("95.216.1.1"))["hosting"] is True
+
+    def test_residential_not_hosting(self, asn_enricher):
+        """Residential ASNs should not be flagged as hosting."""
+        enricher = AnonymizerEnricher(asn_enricher=asn_enricher)
+        assert enricher.enrich(_result("81.2.69.142"))["hosting"] is False
+
+    def test_hosting_unknown_without_asn_record(self, asn_enricher):
+        """Addresses missing from the ASN database should leave hosting unknown."""
+        enricher = AnonymizerEnricher(asn_enricher=asn_enricher)
+        assert enricher.enrich(_result("192.0.2.1"))["hosting"] is None
+
+    def test_anonymous_ip_database(self, tmp_path):
+        """GeoIP2-Anonymous-IP flags should be merged in."""
+        path = write_mmdb(
+            tmp_path / "anon.mmdb",
+            [("203.0.113.0/24", {
+                "is_anonymous": True,
+                "is_anonymous_vpn": True,
+                "is_hosting_provider": True,
+                "is_residential_proxy": True,
+            })],
+            database_type="GeoIP2-Anonymous-IP",
+        )
+        reader = MMDBReader(path)
+        flags = AnonymizerEnricher(anonymous_ip_reader=reader).enrich(_result("203.0.113.5"))
+        reader.close()
+        assert flags == {
+            "vpn": True, "tor": False, "proxy": False, "residential_proxy": True, "hosting": True
+        }
+
+    def test_anonymous_ip_database_miss_is_clean(self, tmp_path):
+        """Addresses absent from the Anonymous-IP database should be checked and clean."""
+        path = write_mmdb(
+            tmp_path / "anon.mmdb",
+            [("203.0.113.0/24", {"is_anonymous": True, "is_anonymous_vpn": True})],
+            database_type="GeoIP2-Anonymous-IP",
+        )
+        reader = MMDBReader(path)
+        flags = AnonymizerEnricher(anonymous_ip_reader=reader).enrich(_result("81.2.69.142"))
+        reader.close()
+        assert flags == {
+            "vpn": False, "tor": False, "proxy": False, "residential_proxy": False, "hosting": False
+        }
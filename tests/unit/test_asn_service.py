@@ -0,0 +1,120 @@
+"""Unit tests for ASN/ISP enrichment."""
+import pytest
+
+from src.services.asn_service import (
+    AsnEnricher,
+    ConnectionType,
+    classify_organization,
+)
+from src.services.ip_lookup_service import IpLookupResult, normalize_ip
+from src.services.mmdb_service import GeoIPRecord, MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+
+@pytest.fixture
+def asn_reader(tmp_path):
+    """GeoLite2-ASN style database."""
+    path = write_mmdb(
+        tmp_path / "asn.mmdb",
+        [
+            ("81.2.69.0/24", {
+                "autonomous_system_number": 20712,
+                "autonomous_system_organization": "Andrews & Arnold Ltd",
+            }),
+            ("3.0.0.0/8", {
+                "autonomous_system_number": 16509,
+                "autonomous_system_organization": "AMAZON-02",
+            }),
+            ("2400:4000::/22", {
+                "autonomous_system_number": 2516,
+                "autonomous_system_organization": "KDDI Mobile Wireless",
+            }),
+        ],
+        database_type="GeoLite2-ASN",
+    )
+    reader = MMDBReader(path)
+    yield reader
+    reader.close()
+
+
+@pytest.fixture
+def connection_type_reader(tmp_path):
+    """GeoIP2-Connection-Type style database."""
+    path = write_mmdb(
+        tmp_path / "conn.mmdb",
+        [("81.2.69.0/24", {"connection_type": "Cable/DSL"})],
+        database_type="GeoIP2-Connection-Type",
+    )
+    reader = MMDBReader(path)
+    yield reader
+    reader.close()
+
+
+def _result(ip, raw=None):
+    normalized = normalize_ip(ip)
+    record = None
+    if raw is not None:
+        record = GeoIPRecord.from_raw(str(normalized.address), "0.0.0.0/0", raw)
+    return IpLookupResult(normalized=normalized, record=record)
+
+
+class TestClassifyOrganization:
+    """Test organization name heuristics."""
+
+    @pytest.mark.parametrize("org", ["AMAZON-02", "Hetzner Online GmbH", "DigitalOcean, LLC", "OVH SAS"])
+    def test_hosting(self, org):
+        """Cloud and hosting providers should classify as hosting."""
+        assert classify_organization(org) == ConnectionType.HOSTING
+
+    def test_cellular(self):
+        """Mobile operators should classify as cellular."""
+        assert classify_organization("T-Mobile USA") == ConnectionType.CELLULAR
+
+    def test_unknown(self):
+        """Unrecognized names should not be classified."""
+        assert classify_organization("Andrews & Arnold Ltd") is None
+        assert classify_organization(None) is None
+
+
+class TestAsnEnricher:
+    """Test the ASN enrichment block."""
+
+    def test_asn_block(self, asn_reader):
+        """ASN number and organization should be returned."""
+        block = AsnEnricher(asn_reader).enrich(_result("81.2.69.142"))
+        assert block["number"] == 20712
+        assert block["organization"] == "Andrews & Arnold Ltd"
+        assert block["isp"] == "Andrews & Arnold Ltd"
+        assert block["connection_type"] is None
+
+    def test_hosting_heuristic(self, asn_reader):
+        """Hosting ASNs should be classified from the organization name."""
+        block = AsnEnricher(asn_reader).enrich(_result("3.5.140.2"))
+        assert block["connection_type"] == ConnectionType.HOSTING
+
+    def test_connection_type_dataset_preferred(self, asn_reader, connection_type_reader):
+        """The connection type database should take precedence."""
+        enricher = AsnEnricher(asn_reader, connection_type_reader)
+        block = enricher.enrich(_result("81.2.69.142"))
+        assert block["connection_type"] == ConnectionType.RESIDENTIAL
+
+    def test_geoip_traits_used(self, asn_reader):
+        """GeoIP2 traits.user_type should classify when available."""
+        result = _result("81.2.69.142", {"traits": {"user_type": "business"}})
+        block = AsnEnricher(asn_reader).enrich(result)
+        assert block["connection_type"] == ConnectionType.BUSINESS
+
+    def test_ipv6(self, asn_reader):
+        """IPv6 addresses should resolve their ASN."""
+        block = AsnEnricher(asn_reader).enrich(_result("2400:4000::1"))
+        assert block["number"] == 2516
+        assert block["connection_type"] == ConnectionType.CELLULAR
+
+    def test_6to4_uses_embedded_ipv4(self, asn_reader):
+        """Tunneled addresses should use the embedded IPv4 ASN."""
+        block = AsnEnricher(asn_reader).enrich(_result("2002:5102:458e::1"))
+        assert block["number"] == 20712
+
+    def test_not_found(self, asn_reader):
+        """Addresses without an ASN should produce no block."""
+        assert AsnEnricher(asn_reader).enrich(_result("8.8.8.8")) is None
@@ -0,0 +1,170 @@
+"""Unit tests for the hash-chained audit log."""
+from datetime import datetime, timedelta
+from typing import Optional
+
+import pytest
+from fastapi import Depends, FastAPI, Header, HTTPException, Request
+from fastapi.testclient import TestClient
+from sqlalchemy import text
+
+from src.middleware.audit_log import AuditLogMiddleware
+from src.models.database_models import AuditLogEntry
+from src.services.audit_log_service import (
+    GENESIS_HASH,
+    AuditCategory,
+    AuditLogService,
+    AuditOutcome,
+)
+from src.services.auth_service import Principal
+
+
+@pytest.fixture
+def service(db_session):
+    """Audit log over the test database, with three entries."""
+    service = AuditLogService(db_session)
+    service.append(AuditCategory.ADMIN, "create_tenant", AuditOutcome.SUCCESS, actor="ops@corp", tenant_id="acme")
+    service.append(
+        AuditCategory.DETECTION, "detect_travel", AuditOutcome.FLAGGED,
+        actor="acme-api", tenant_id="acme", resource="user:alice", details={"impossible": True},
+    )
+    service.append(AuditCategory.ADMIN, "rotate_tenant_key", AuditOutcome.DENIED, actor="support@corp", tenant_id="globex")
+    return service
+
+
+class TestChain:
+    """Test hash chaining and verification."""
+
+    def test_entries_are_chained(self, service):
+        """Each entry should point at its predecessor's hash."""
+        entries = list(reversed(service.page().items))
+        assert entries[0].prev_hash == GENESIS_HASH
+        assert [e.prev_hash for e in entries[1:]] == [e.entry_hash for e in entries[:-1]]
+        assert entries[1].details == {"impossible": True}
+
+    def test_intact_chain_verifies(self, service):
+        """An untouched log should verify up to its newest entry."""
+        result = service.verify()
+        assert result.valid
+        assert result.entries == 3
+        assert result.head_hash == service.page(limit=1).items[0].entry_hash
+
+    def test_empty_log_verifies(self, db_session):
+        """An empty log should be valid with the genesis hash as head."""
+        result = AuditLogService(db_session).verify()
+        assert result.valid and result.entries == 0 and result.head_hash == GENESIS_HASH
+
+    def test_altered_entry_detected(self, service, db_session):
+        """Changing a stored field should break the chain at that entry."""
+        db_session.execute(text("UPDATE audit_log SET outcome = 'clear' WHERE id = 2"))
+        db_session.commit()
+        db_session.expire_all()
+        result = service.verify()
+        assert not result.valid
+        assert (result.broken_seq, result.entries) == (2, 1)
+        assert "altered" in result.reason
+
+    def test_deleted_entry_detected(self, service, db_session):
+        """Removing an entry should break the chain at its successor."""
+        db_session.execute(text("DELETE FROM audit_log WHERE id = 2"))
+        db_session.commit()
+        db_session.expire_all()
+        result = service.verify()
+        assert not result.valid
+        assert result.broken_seq == 3
+        assert "removed" in result.reason
+
+    def test_orm_changes_refused(self, service, db_session):
+        """Entries should not be updated or deleted through the ORM."""
+        row = db_session.query(AuditLogEntry).first()
+        row.outcome = AuditOutcome.FAILED
+        with pytest.raises(ValueError, match="append-only"):
+            db_session.flush()
+        db_session.rollback()
+        db_session.delete(db_session.query(AuditLogEntry).first())
+        with pytest.raises(ValueError, match="append-only"):
+            db_session.flush()
+
+
+class TestPage:
+    """Test filtering and paging the log."""
+
+    def test_filters(self, service):
+        """Entries should be filtered by tenant, actor, category and action."""
+        assert [e.action for e in service.page(tenant_id="acme").items] == ["detect_travel", "create_tenant"]
+        assert [e.action for e in service.page(actor="support@corp").items] == ["rotate_tenant_key"]
+        assert [e.action for e in service.page(category=AuditCategory.DETECTION).items] == ["detect_travel"]
+        assert service.page(action="delete_geofence").items == []
+
+    def test_time_window(self, service):
+        """since is inclusive and until exclusive."""
+        now = datetime.utcnow()
+        assert len(service.page(since=now - timedelta(minutes=1)).items) == 3
+        assert service.page(until=now - timedelta(minutes=1)).items == []
+
+    def test_cursor(self, service):
+        """Pages should continue after the cursor without repeats."""
+        first = service.page(limit=2)
+        second = service.page(limit=2, cursor=first.next_cursor)
+        assert [e.seq for e in first.items + second.items] == [3, 2, 1]
+        assert second.next_cursor is None
+        with pytest.raises(ValueError):
+            service.page(cursor="garbage")
+
+
+def _client(db_session):
+    """Small admin API behind the middleware; `Bearer write` may change things."""
+    app = FastAPI()
+    app.add_middleware(AuditLogMiddleware, session_factory=lambda: db_session)
+
+    async def admin(request: Request, authorization: Optional[str] = Header(None)):
+        if authorization is None:
+            raise HTTPException(status_code=401)
+        request.state.principal = Principal(subject="ops@corp", tenant_id="platform")
+        if request.method != "GET" and authorization != "Bearer write":
+            raise HTTPException(status_code=403)
+
+    @app.post("/admin/v1/tenants/{tenant_id}/keys/rotate", dependencies=[Depends(admin)])
+    async def rotate_tenant_key(tenant_id: str):
+        return {"rotated": True}
+
+    @app.get("/admin/v1/tenants", dependencies=[Depends(admin)])
+    async def list_tenants():
+        return []
+
+    @app.post("/api/v1/ping")
+    async def ping():
+        return {}
+
+    return TestClient(app)
+
+
+class TestAuditLogMiddleware:
+    """Test recording admin API requests."""
+
+    def test_change_recorded(self, db_session):
+        """Admin changes should be recorded with actor, tenant and status."""
+        client = _client(db_session)
+        assert client.post("/admin/v1/tenants/acme/keys/rotate", headers={"Authorization": "Bearer write"}).status_code == 200
+        entry = AuditLogService(db_session).page().items[0]
+        assert (entry.category, entry.action, entry.outcome) == ("admin", "rotate_tenant_key", "success")
+        assert (entry.actor, entry.tenant_id) == ("ops@corp", "acme")
+        assert entry.resource == "/admin/v1/tenants/acme/keys/rotate"
+        assert entry.details["status_code"] == 200
+
+    def test_refusals_recorded(self, db_session):
+        """Refused admin requests should be recorded, with the actor when known."""
+        client = _client(db_session)
+        client.post("/admin/v1/tenants/acme/keys/rotate")
+        client.post("/admin/v1/tenants/acme/keys/rotate", headers={"Authorization": "Bearer read"})
+        client.get("/admin/v1/tenants")
+        entries = AuditLogService(db_session).page().items
+        assert [(e.actor, e.outcome, e.details["status_code"]) for e in entries] == [
+            (None, "denied", 401), ("ops@corp", "denied", 403), (None, "denied", 401)
+        ]
+
+    def test_reads_and_other_paths_not_recorded(self, db_session):
+        """Successful reads and data-plane requests should not be recorded."""
+        client = _client(db_session)
+        assert client.get("/admin/v1/tenants", headers={"Authorization": "Bearer read"}).status_code == 200
+        client.post("/api/v1/ping")
+        assert AuditLogService(db_session).page().items == []
@@ -0,0 +1,170 @@
+"""Unit tests for bearer token authentication."""
+from datetime import timedelta
+
+import pytest
+
+from src.services.auth_service import (
+    INSECURE_DEFAULT_SECRET,
+    AuthenticationError,
+    TokenVerifier,
+)
+from src.services.token_denylist_service import InMemoryTokenDenylist
+
+
+@pytest.fixture
+def verifier():
+    """Verifier with a real secret."""
+    return TokenVerifier("test-secret-0123456789abcdef0123456789")
+
+
+@pytest.fixture
+def rsa_keys():
+    """PEM private and public key of a fresh RSA key pair."""
+    serialization = pytest.importorskip("cryptography.hazmat.primitives.serialization")
+    from cryptography.hazmat.primitives.asymmetric import rsa
+
+    key = rsa.generate_private_key(public_exponent=65537, key_size=2048)
+    private = key.private_bytes(
+        serialization.Encoding.PEM,
+        serialization.PrivateFormat.PKCS8,
+        serialization.NoEncryption(),
+    ).decode()
+    public = key.public_key().public_bytes(
+        serialization.Encoding.PEM, serialization.PublicFormat.SubjectPublicKeyInfo
+    ).decode()
+    return private, public
+
+
+class TestTokenVerifier:
+    """Test token validation and principal extraction."""
+
+    def test_round_trip(self, verifier):
+        """Issued tokens should verify to the same principal."""
+        token = verifier.issue("user-1", "acme", scopes=["poi:read", "poi:write"])
+        principal = verifier.verify(token)
+        assert principal.subject == "user-1"
+        assert principal.tenant_id == "acme"
+        assert principal.scopes == {"poi:read", "poi:write"}
+
+    def test_wrong_secret_rejected(self, verifier):
+        """Tokens signed with another secret should be rejected."""
+        token = TokenVerifier("another-secret-0123456789abcdef").issue("user-1", "acme")
+        with pytest.raises(AuthenticationError, match="Invalid token"):
+            verifier.verify(token)
+
+    def test_previous_key_accepted(self, verifier):
+        """Tokens signed with the key replaced by a rotation should still verify."""
+        rotated = TokenVerifier("rotated-secret-0123456789abcdef012345", previous_key=verifier.secret)
+        assert rotated.verify(verifier.issue("user-1", "acme")).tenant_id == "acme"
+        assert rotated.verify(rotated.issue("user-1", "acme")).tenant_id == "acme"
+        with pytest.raises(AuthenticationError, match="Invalid token"):
+            rotated.verify(TokenVerifier("another-secret-0123456789abcdef").issue("user-1", "acme"))
+
+    def test_expired_rejected(self, verifier):
+        """Expired tokens should be rejected."""
+        token = verifier.issue("user-1", "acme", expires_in=timedelta(seconds=-5))
+        with pytest.raises(AuthenticationError, match="expired"):
+            verifier.verify(token)
+
+    def test_missing_tenant_rejected(self, verifier):
+        """Tokens without the tenant claim should be rejected."""
+        token = TokenVerifier(verifier.secret, tenant_claim="org").issue("user-1", "acme")
+        with pytest.raises(AuthenticationError, match="tenant_id"):
+            verifier.verify(token)
+
+    def test_placeholder_secret_refused(self):
+        """The shipped placeholder secret should not be trusted."""
+        verifier = TokenVerifier(INSECURE_DEFAULT_SECRET)
+        assert verifier.configured is False
+        with pytest.raises(RuntimeError, match="not configured"):
+            verifier.verify("anything")
+
+    def test_placeholder_allowed_in_debug(self):
+        """Debug deployments may opt into the placeholder secret."""
+        verifier = TokenVerifier(INSECURE_DEFAULT_SECRET, allow_insecure_secret=True)
+        assert verifier.verify(verifier.issue("dev", "default")).tenant_id == "default"
+
+    def test_issuer_and_audience(self, verifier):
+        """Configured iss/aud claims should be required."""
+        strict = TokenVerifier(verifier.secret, issuer="geo", audience="api")
+        assert strict.verify(strict.issue("user-1", "acme")).tenant_id == "acme"
+        with pytest.raises(AuthenticationError):
+            strict.verify(verifier.issue("user-1", "acme"))
+
+
+class TestAsymmetricTokens:
+    """Test RS256 signing and verification."""
+
+    def test_round_trip(self, rsa_keys):
+        """Tokens signed with the private key should verify with the public key."""
+        private, public = rsa_keys
+        issuer = TokenVerifier("", algorithm="RS256", public_key=public, private_key=private)
+        api = TokenVerifier("", algorithm="RS256", public_key=public)
+        assert api.configured is True
+        assert api.verify(issuer.issue("user-1", "acme")).tenant_id == "acme"
+
+    def test_verify_only_cannot_issue(self, rsa_keys):
+        """Without the private key the verifier should refuse to issue."""
+        api = TokenVerifier("", algorithm="RS256", public_key=rsa_keys[1])
+        with pytest.raises(RuntimeError, match="JWT_PRIVATE_KEY"):
+            api.issue("user-1", "acme")
+
+    def test_algorithm_pinned(self, rsa_keys, verifier):
+        """HS256 tokens should be rejected when RS256 is configured."""
+        api = TokenVerifier(verifier.secret, algorithm="RS256", public_key=rsa_keys[1])
+        with pytest.raises(AuthenticationError, match="Invalid token"):
+            api.verify(verifier.issue("attacker", "acme"))
+
+    def test_missing_public_key_not_configured(self):
+        """RS256 without a public key should not be configured."""
+        verifier = TokenVerifier("some-secret-0123456789abcdef0123", algorithm="RS256")
+        with pytest.raises(RuntimeError, match="JWT_PUBLIC_KEY"):
+            verifier.verify("anything")
+
+
+class TestRevocation:
+    """Test token and session revocation through the denylist."""
+
+    @pytest.fixture
+    def revocable(self, verifier):
+        return TokenVerifier(verifier.secret, denylist=InMemoryTokenDenylist())
+
+    def test_tokens_have_ids(self, verifier):
+        """Every issued token should carry its own jti."""
+        first = verifier.verify(verifier.issue("user-1", "acme"))
+        second = verifier.verify(verifier.issue("user-1", "acme"))
+        assert first.token_id and first.token_id != second.token_id
+        assert first.session_id is None
+
+    def test_revoke_token(self, revocable):
+        """A revoked token should be rejected, others of the subject not."""
+        token = revocable.issue("user-1", "acme")
+        other = revocable.issue("user-1", "acme")
+        revocable.revoke(revocable.verify(token))
+        with pytest.raises(AuthenticationError, match="revoked"):
+            revocable.verify(token)
+        assert revocable.verify(other).subject == "user-1"
+
+    def test_revoke_session(self, revocable):
+        """Revoking a session should reject every token carrying its sid."""
+        token = revocable.issue("user-1", "acme", session_id="s-1")
+        unrelated = revocable.issue("user-1", "acme", session_id="s-2")
+        revocable.revoke_session("s-1", 3600)
+        with pytest.raises(AuthenticationError, match="revoked"):
+            revocable.verify(token)
+        assert revocable.verify(unrelated).session_id == "s-2"
+
+    def test_no_denylist(self, verifier):
+        """Revocation should be unavailable without a denylist."""
+        with pytest.raises(RuntimeError, match="not configured"):
+            verifier.revoke(verifier.verify(verifier.issue("user-1", "acme")))
+
+    def test_denylist_down_fails_closed(self, revocable):
+        """An unreadable denylist should not let tokens through."""
+        class Broken(InMemoryTokenDenylist):
+            def is_revoked(self, kind, value):
+                raise ConnectionError("Connection refused")
+
+        revocable.denylist = Broken()
+        with pytest.raises(RuntimeError, match="denylist unavailable"):
+            revocable.verify(revocable.issue("user-1", "acme"))
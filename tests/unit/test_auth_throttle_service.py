@@ -0,0 +1,117 @@
+"""Unit tests for brute-force protection of the authentication endpoints."""
+import pytest
+
+from src.services.audit_log_service import AuditCategory, AuditLogService
+from src.services.auth_service import TokenVerifier
+from src.services.auth_throttle_service import (
+    AuthThrottle,
+    CredentialAttackAlert,
+    InMemoryThrottleStore,
+    api_key_identity,
+    report_attack,
+    token_identity,
+)
+from src.services.tenant_service import TenantService
+
+
+@pytest.fixture
+def clock():
+    """Settable monotonic clock."""
+    return [1000.0]
+
+
+@pytest.fixture
+def throttle(clock):
+    return AuthThrottle(
+        InMemoryThrottleStore(clock=lambda: clock[0]),
+        max_failures=3,
+        window_seconds=60,
+        lockout_seconds=10,
+        max_lockout_seconds=30,
+        alert_failures=4,
+    )
+
+
+class TestAuthThrottle:
+    """Test counting failures and locking out addresses."""
+
+    def test_progressive_lockout(self, throttle, clock):
+        """Addresses should be locked out at the limit, longer with each further failure."""
+        for _ in range(2):
+            throttle.failed("token", "203.0.113.7")
+        assert throttle.retry_after("203.0.113.7") == 0
+
+        throttle.failed("token", "203.0.113.7")
+        assert throttle.retry_after("203.0.113.7") == 10
+        assert throttle.retry_after("198.51.100.1") == 0
+        throttle.failed("token", "203.0.113.7")
+        assert throttle.retry_after("203.0.113.7") == 20
+        throttle.failed("token", "203.0.113.7")
+        assert throttle.retry_after("203.0.113.7") == 30  # capped
+
+        clock[0] += 31
+        assert throttle.retry_after("203.0.113.7") == 0
+        clock[0] += 60
+        throttle.failed("token", "203.0.113.7")
+        assert throttle.retry_after("203.0.113.7") == 0  # the window has passed
+
+    def test_alert_once_per_window(self, throttle, clock):
+        """A credential failing from many addresses should raise one alert per window."""
+        alerts = [throttle.failed("token", f"203.0.113.{i}", "key:gek_Abc12345") for i in range(6)]
+        assert [a is not None for a in alerts] == [False, False, False, True, False, False]
+        assert alerts[3].kind == "key" and alerts[3].failures == 4
+        assert all(throttle.retry_after(f"203.0.113.{i}") == 0 for i in range(6))
+
+        clock[0] += 61
+        alerts = [throttle.failed("token", "198.51.100.1", "key:gek_Abc12345") for _ in range(4)]
+        assert alerts[-1] is not None
+
+    def test_identities(self):
+        """Identities should name the key prefix or the token's subject."""
+        verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+        assert api_key_identity(" gek_Abc12345secret ") == "key:gek_Abc12345"
+        assert token_identity(verifier.issue("ops-1", "platform")) == "account:ops-1"
+        assert token_identity("not-a-token") is None
+
+
+class TestReportAttack:
+    """Test auditing credential attacks."""
+
+    def test_audits_key_of_tenant(self, db_session):
+        """Attacks on a tenant's key should be audited under its tenant."""
+        _, issued = TenantService(db_session).create_tenant("acme", "Acme")
+        alert = CredentialAttackAlert(api_key_identity(issued.api_key), 20, 900, "token", "203.0.113.7")
+        report_attack(db_session, alert)
+        report_attack(db_session, CredentialAttackAlert("account:ops-1", 20, 900, "admin", "203.0.113.7"))
+
+        entries = AuditLogService(db_session).page(category=AuditCategory.SECURITY).items
+        assert [(e.tenant_id, e.resource) for e in entries] == [
+            (None, "account:ops-1"),
+            ("acme", f"key:{issued.key.key_id}"),
+        ]
+
+
+class TestAuthThrottleMiddleware:
+    """Test throttling the token and admin endpoints."""
+
+    def test_lockout_and_alert(self, db_client, auth_throttle, monkeypatch):
+        """Repeated bad keys should lock the address out and report the key."""
+        reported = []
+        monkeypatch.setattr(
+            "src.middleware.auth_throttle.AuthThrottleMiddleware._report", lambda self, alert: reported.append(alert)
+        )
+        auth_throttle.alert_failures = 3
+
+        for _ in range(auth_throttle.max_failures):
+            response = db_client.post("/api/v1/auth/token", json={"api_key": "gek_Abc12345wrong"})
+            assert response.status_code == 401
+        response = db_client.post("/api/v1/auth/token", json={"api_key": "gek_Abc12345wrong"})
+        assert response.status_code == 429
+        assert response.json()["detail"]["error_code"] == "E013"
+        assert int(response.headers["Retry-After"]) == auth_throttle.lockout_seconds
+        # The lockout's own state, not the rate limit's
+        assert response.headers["RateLimit-Limit"] == str(auth_throttle.max_failures)
+        assert response.headers["RateLimit-Remaining"] == "0"
+        assert response.headers["RateLimit-Reset"] == response.headers["Retry-After"]
+        assert db_client.get("/admin/v1/tenants").status_code == 429
+        assert [alert.identity for alert in reported] == ["key:gek_Abc12345"]
@@ -0,0 +1,164 @@
+"""Tests for mandatory bearer tokens, client certificates and per-endpoint scopes."""
+import json
+
+import pytest
+from fastapi import FastAPI, Request
+from fastapi.testclient import TestClient
+
+from src.middleware.authentication import AuthenticationMiddleware
+from src.services.auth_service import TokenVerifier
+from src.services.mtls_service import ClientCertificateMapper, ClientIdentity, with_client_certificate
+from src.services.rbac_service import PolicyStore
+from src.services.token_denylist_service import InMemoryTokenDenylist
+
+POLICY = {
+    "rules": [
+        {"id": "geofence-writes", "methods": ["POST"], "path": "/geofences/**", "scopes": ["geofence:write"]},
+        {"id": "geofence-reads", "path": "/geofences/**", "scopes": ["geofence:read"]},
+    ]
+}
+
+BILLING_CERT = {"subjectAltName": (("URI", "spiffe://corp.internal/billing"), ("DNS", "billing.internal"))}
+IDENTITIES = {"URI:spiffe://corp.internal/billing": ClientIdentity("billing", ("geofence:read",))}
+
+
+@pytest.fixture
+def verifier():
+    """Verifier with a test secret and an in-memory denylist."""
+    return TokenVerifier("test-secret-0123456789abcdef0123456789", denylist=InMemoryTokenDenylist())
+
+
+def _client(verifier, require_token=True, policy_store=None, certificate=None):
+    """Small app behind the middleware (on a mutual TLS connection if `certificate`)."""
+    app = FastAPI()
+    app.add_middleware(
+        AuthenticationMiddleware,
+        verifier=verifier,
+        exempt_paths=["/health", ""],
+        require_token=require_token,
+        policy_store=policy_store or PolicyStore(),
+        certificates=ClientCertificateMapper(IDENTITIES),
+    )
+
+    @app.get("/ping")
+    async def ping(request: Request):
+        return {"tenant_id": request.state.principal.tenant_id}
+
+    @app.get("/geofences")
+    async def list_geofences():
+        return {"geofences": []}
+
+    @app.post("/geofences")
+    async def create_geofence():
+        return {"created": True}
+
+    @app.get("/open")
+    async def open_endpoint():
+        return {"open": True}
+
+    @app.get("/health")
+    async def health():
+        return {"status": "healthy"}
+
+    return TestClient(with_client_certificate(app, certificate) if certificate else app)
+
+
+class TestAuthenticationMiddleware:
+    """Test token enforcement outside the exempt paths."""
+
+    def test_valid_token(self, verifier):
+        """Requests with a valid token should reach the route with their principal."""
+        headers = {"Authorization": f"Bearer {verifier.issue('user-1', 'acme')}"}
+        response = _client(verifier).get("/ping", headers=headers)
+        assert response.status_code == 200
+        assert response.json() == {"tenant_id": "acme"}
+
+    def test_missing_token_is_401(self, verifier):
+        """Anonymous requests should be refused with a Bearer challenge."""
+        response = _client(verifier).get("/ping")
+        assert response.status_code == 401
+        assert response.headers["www-authenticate"] == "Bearer"
+        assert response.json()["detail"]["error_code"] == "E006"
+
+    def test_revoked_token_is_401(self, verifier):
+        """Revoked tokens should be refused."""
+        token = verifier.issue("user-1", "acme")
+        verifier.revoke(verifier.verify(token))
+        response = _client(verifier).get("/ping", headers={"Authorization": f"Bearer {token}"})
+        assert response.status_code == 401
+
+    def test_exempt_paths(self, verifier):
+        """Exempt prefixes should be served without a token (empty entries ignored)."""
+        assert _client(verifier).get("/health").status_code == 200
+
+    def test_not_configured_is_503(self):
+        """Without a signing key requests should fail closed."""
+        response = _client(TokenVerifier("")).get("/ping", headers={"Authorization": "Bearer x"})
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+
+class TestScopeEnforcement:
+    """Test per-endpoint scopes from the RBAC policy."""
+
+    @pytest.fixture
+    def store(self, tmp_path):
+        path = tmp_path / "rbac.json"
+        path.write_text(json.dumps(POLICY))
+        return PolicyStore(str(path))
+
+    def _headers(self, verifier, *scopes):
+        return {"Authorization": f"Bearer {verifier.issue('user-1', 'acme', scopes=scopes)}"}
+
+    def test_scopes_enforced(self, verifier, store):
+        """Endpoints should need the scopes of the first matching rule."""
+        client = _client(verifier, require_token=False, policy_store=store)
+        reader = self._headers(verifier, "geofence:read")
+        assert client.get("/geofences", headers=reader).status_code == 200
+        response = client.post("/geofences", headers=reader)
+        assert response.status_code == 403
+        assert response.json()["detail"]["error_code"] == "E010"
+        assert client.post("/geofences", headers=self._headers(verifier, "geofence:*")).status_code == 200
+
+    def test_unrestricted_paths_stay_anonymous(self, verifier, store):
+        """Without AUTH_REQUIRED, paths no rule covers should not need a token."""
+        client = _client(verifier, require_token=False, policy_store=store)
+        assert client.get("/open").status_code == 200
+        assert client.get("/geofences").status_code == 401
+
+    def test_policy_not_loaded_is_503(self, verifier, tmp_path):
+        """A configured but unreadable policy should fail closed."""
+        client = _client(verifier, policy_store=PolicyStore(str(tmp_path / "missing.json")))
+        headers = self._headers(verifier, "*")
+        assert client.get("/geofences", headers=headers).status_code == 503
+
+
+class TestClientCertificates:
+    """Test mutual TLS callers, identified by their certificate names."""
+
+    def test_mapped_certificate(self, verifier):
+        """A mapped certificate should authenticate without a bearer token."""
+        response = _client(verifier, certificate=BILLING_CERT).get("/ping")
+        assert response.status_code == 200
+        assert response.json() == {"tenant_id": "billing"}
+
+    def test_unmapped_certificate_is_401(self, verifier):
+        """Certificates of the CA whose names are not mapped should be refused."""
+        certificate = {"subjectAltName": (("DNS", "unknown.internal"),)}
+        response = _client(verifier, certificate=certificate).get("/ping")
+        assert response.status_code == 401
+        assert "not mapped" in response.json()["detail"]["error_message"]
+
+    def test_bearer_token_takes_precedence(self, verifier):
+        """A token sent over a mutual TLS connection should identify the caller."""
+        headers = {"Authorization": f"Bearer {verifier.issue('user-1', 'acme')}"}
+        response = _client(verifier, certificate=BILLING_CERT).get("/ping", headers=headers)
+        assert response.json() == {"tenant_id": "acme"}
+
+    def test_certificate_scopes(self, verifier, tmp_path):
+        """The identity's scopes should be checked against the policy."""
+        path = tmp_path / "rbac.json"
+        path.write_text(json.dumps(POLICY))
+        client = _client(verifier, policy_store=PolicyStore(str(path)), certificate=BILLING_CERT)
+        assert client.get("/geofences").status_code == 200
+        assert client.post("/geofences").status_code == 403
@@ -0,0 +1,197 @@
+"""Tests for asynchronous batch lookup jobs."""
+import json
+
+import pytest
+
+from src.services.batch_job_service import (
+    BatchJobRunner,
+    BatchJobService,
+    JobStatus,
+    count_rows,
+    fetch_source,
+    parse_source,
+    read_rows,
+)
+from src.services.batch_lookup_service import BatchLookupService
+
+
+def _lookup(row):
+    if row["ip"] == "bad":
+        raise ValueError("Invalid IP address: bad")
+    return row["ip"].upper()
+
+
+def _record(outcome):
+    if outcome.ok:
+        return {"index": outcome.index, "value": outcome.result}
+    return {"index": outcome.index, "error": outcome.error_code}
+
+
+class TestSources:
+    """Test input references and CSV parsing."""
+
+    def test_parse_source(self, tmp_path):
+        """Should accept S3 and HTTPS references, and file: only with an input directory."""
+        assert parse_source(" s3://exports/logins.csv ") == "s3://exports/logins.csv"
+        assert parse_source("https://files.example.com/logins.csv")
+        assert parse_source("file:logins.csv", str(tmp_path)) == "file:logins.csv"
+
+        for source in ("s3://exports", "http://files.example.com/a.csv", "/etc/passwd", ""):
+            with pytest.raises(ValueError):
+                parse_source(source, str(tmp_path))
+        with pytest.raises(ValueError, match="disabled"):
+            parse_source("file:logins.csv")
+        with pytest.raises(ValueError, match="inside"):
+            parse_source("file:../secrets.csv", str(tmp_path))
+
+    @pytest.mark.asyncio
+    async def test_fetch_local_file(self, tmp_path):
+        """Should read file: inputs in place and report missing files."""
+        (tmp_path / "logins.csv").write_text("ip\n81.2.69.142\n")
+
+        path = await fetch_source("file:logins.csv", str(tmp_path / "download"), str(tmp_path))
+        assert path == str(tmp_path / "logins.csv")
+        with pytest.raises(RuntimeError, match="not found"):
+            await fetch_source("file:missing.csv", str(tmp_path / "download"), str(tmp_path))
+
+    @pytest.mark.asyncio
+    async def test_fetch_upload(self, tmp_path):
+        """Should read uploads where they were stored and report removed ones."""
+        stored = tmp_path / "job.input"
+        with pytest.raises(RuntimeError, match="no longer available"):
+            await fetch_source("upload:logins.csv", str(stored))
+        stored.write_text("ip\n81.2.69.142\n")
+        assert await fetch_source("upload:logins.csv", str(stored)) == str(stored)
+
+    def test_upload_source_not_accepted_as_reference(self):
+        """Should only create upload: jobs for actual uploads."""
+        with pytest.raises(ValueError):
+            parse_source("upload:logins.csv")
+
+    def test_read_rows(self, tmp_path):
+        """Should map header columns case-insensitively and blank cells to None."""
+        path = tmp_path / "points.csv"
+        path.write_text("\ufeffLat, Lon ,note\n51.5,-0.12,a\n ,2.29,b\n")
+
+        rows = list(read_rows(str(path)))
+        assert rows == [
+            {"ip": None, "lat": "51.5", "lon": "-0.12"},
+            {"ip": None, "lat": None, "lon": "2.29"},
+        ]
+        assert count_rows(str(path)) == 2
+
+    def test_header_required(self, tmp_path):
+        """Should reject files without an ip column or lat/lon columns."""
+        path = tmp_path / "logins.csv"
+        path.write_text("81.2.69.142\n8.8.8.8\n")
+
+        with pytest.raises(ValueError, match="header"):
+            count_rows(str(path))
+
+
+class TestBatchJobService:
+    """Test job storage."""
+
+    def test_create_and_get(self, db_session):
+        """Should queue a job visible only to its tenant."""
+        service = BatchJobService(db_session)
+        job = service.create("acme", "s3://exports/logins.csv")
+
+        assert job.status == JobStatus.QUEUED
+        assert service.get("acme", job.job_id).source == "s3://exports/logins.csv"
+        assert service.get("globex", job.job_id) is None
+
+    def test_fail_interrupted(self, db_session):
+        """Should fail jobs that were queued or running, leaving finished jobs alone."""
+        service = BatchJobService(db_session)
+        running = service.create("acme", "s3://exports/a.csv")
+        service.update(running.job_id, status=JobStatus.RUNNING)
+        done = service.create("acme", "s3://exports/b.csv")
+        service.update(done.job_id, status=JobStatus.SUCCEEDED)
+        service.create("acme", "s3://exports/c.csv")
+
+        assert service.fail_interrupted() == 2
+        assert service.get("acme", running.job_id).status == JobStatus.FAILED
+        assert service.get("acme", running.job_id).error == "Interrupted by a restart"
+        assert service.get("acme", done.job_id).status == JobStatus.SUCCEEDED
+
+
+class TestBatchJobRunner:
+    """Test background processing."""
+
+    def _runner(self, db_session, tmp_path, **kwargs):
+        return BatchJobRunner(
+            result_dir=str(tmp_path / "jobs"),
+            input_dir=str(tmp_path),
+            session_factory=lambda: db_session,
+            batch_service=BatchLookupService(workers=2),
+            **kwargs,
+        )
+
+    @pytest.mark.asyncio
+    async def test_run(self, db_session, tmp_path):
+        """Should write one result line per row in order and store the final progress."""
+        (tmp_path / "logins.csv").write_text("ip\na\nbad\nc\nd\ne\n")
+        job = BatchJobService(db_session).create("acme", "file:logins.csv", str(tmp_path))
+        runner = self._runner(db_session, tmp_path, chunk_size=2)
+
+        await runner.run(job.job_id, _lookup, _record)
+
+        job = BatchJobService(db_session).get("acme", job.job_id)
+        assert job.status == JobStatus.SUCCEEDED
+        assert (job.total_rows, job.processed_rows, job.failed_rows) == (5, 5, 1)
+        assert job.started_at is not None and job.finished_at is not None
+        with open(job.result_path) as f:
+            lines = [json.loads(line) for line in f]
+        assert lines == [
+            {"index": 0, "value": "A"},
+            {"index": 1, "error": "E002"},
+            {"index": 2, "value": "C"},
+            {"index": 3, "value": "D"},
+            {"index": 4, "value": "E"},
+        ]
+        assert sorted(p.name for p in (tmp_path / "jobs").iterdir()) == [f"{job.job_id}.ndjson"]
+
+    @pytest.mark.asyncio
+    async def test_run_upload(self, db_session, tmp_path):
+        """Should process an uploaded input and remove it afterwards."""
+        job = BatchJobService(db_session).create_upload("acme", "C:\\exports\\logins.csv")
+        assert job.source == "upload:logins.csv"
+        runner = self._runner(db_session, tmp_path)
+        (tmp_path / "jobs").mkdir()
+        with open(runner.input_path(job.job_id), "w") as f:
+            f.write("ip\na\nbad\n")
+
+        await runner.run(job.job_id, _lookup, _record)
+
+        job = BatchJobService(db_session).get("acme", job.job_id)
+        assert job.status == JobStatus.SUCCEEDED
+        assert (job.total_rows, job.failed_rows) == (2, 1)
+        assert sorted(p.name for p in (tmp_path / "jobs").iterdir()) == [f"{job.job_id}.ndjson"]
+
+    @pytest.mark.asyncio
+    async def test_unusable_input_fails_job(self, db_session, tmp_path):
+        """Should fail the job with the reason when the input has no usable header."""
+        (tmp_path / "logins.csv").write_text("address\n81.2.69.142\n")
+        job = BatchJobService(db_session).create("acme", "file:logins.csv", str(tmp_path))
+
+        await self._runner(db_session, tmp_path).run(job.job_id, _lookup, _record)
+
+        job = BatchJobService(db_session).get("acme", job.job_id)
+        assert job.status == JobStatus.FAILED
+        assert "header" in job.error
+        assert job.result_path is None
+
+    @pytest.mark.asyncio
+    async def test_download_failure_fails_job(self, db_session, tmp_path):
+        """Should fail the job when the input cannot be fetched."""
+
+        async def unreachable(source, download_path, input_dir):
+            raise RuntimeError("S3 download failed: AccessDenied")
+
+        job = BatchJobService(db_session).create("acme", "s3://exports/logins.csv")
+        await self._runner(db_session, tmp_path, fetch=unreachable).run(job.job_id, _lookup, _record)
+
+        job = BatchJobService(db_session).get("acme", job.job_id)
+        assert job.status == JobStatus.FAILED
+        assert job.error == "S3 download failed: AccessDenied"
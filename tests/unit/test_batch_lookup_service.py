@@ -0,0 +1,106 @@
+"""Unit tests for concurrent bulk lookups."""
+import asyncio
+import threading
+import time
+
+import pytest
+
+from src.services.batch_lookup_service import BatchLookupService, BatchOverloadedError
+
+
+def _lookup(item):
+    if item == "bad":
+        raise ValueError("Invalid item")
+    if item == "missing":
+        raise LookupError("No data")
+    if item == "down":
+        raise RuntimeError("Dataset unavailable")
+    if item == "bug":
+        raise TypeError("unexpected")
+    return item.upper()
+
+
+@pytest.fixture
+def service():
+    """Batch service with a small pool."""
+    service = BatchLookupService(workers=4, max_items=100)
+    yield service
+    service.shutdown()
+
+
+class TestBatchLookupService:
+    """Test per-item execution and error reporting."""
+
+    async def test_results_in_input_order(self, service):
+        """Outcomes should line up with the input items."""
+        items = [f"item-{i}" for i in range(50)]
+        outcomes = await service.run(items, _lookup)
+        assert [o.index for o in outcomes] == list(range(50))
+        assert [o.result for o in outcomes] == [item.upper() for item in items]
+        assert all(o.ok for o in outcomes)
+
+    async def test_errors_reported_per_item(self, service):
+        """A failing item should not affect the others."""
+        outcomes = await service.run(["a", "bad", "missing", "down", "bug", "b"], _lookup)
+        assert [o.error_code for o in outcomes] == [None, "E002", "E004", "E003", "E999", None]
+        assert outcomes[1].error_message == "Invalid item"
+        assert outcomes[4].error_message == "Internal error"
+        assert outcomes[5].result == "B"
+
+    async def test_runs_on_worker_threads(self, service):
+        """Items should run off the event loop thread."""
+        loop_thread = threading.get_ident()
+        outcomes = await service.run(list(range(20)), lambda _: threading.get_ident())
+        assert loop_thread not in {o.result for o in outcomes}
+
+    async def test_limits(self, service):
+        """Empty and oversized batches should be rejected."""
+        with pytest.raises(ValueError, match="at least one"):
+            await service.run([], _lookup)
+        with pytest.raises(ValueError, match="exceeds the limit of 100"):
+            await service.run(["x"] * 101, _lookup)
+
+
+class TestWorkerPoolLimits:
+    """Test per-batch worker caps and the pending-item budget."""
+
+    async def test_workers_per_batch(self):
+        """A batch should occupy no more than its share of the pool."""
+        service = BatchLookupService(workers=8, max_items=100, workers_per_batch=2)
+        running, peak = set(), []
+        lock = threading.Lock()
+
+        def slow(item):
+            with lock:
+                running.add(threading.get_ident())
+                peak.append(len(running))
+            time.sleep(0.01)
+            with lock:
+                running.discard(threading.get_ident())
+            return item
+
+        outcomes = await service.run(list(range(40)), slow)
+        assert [o.result for o in outcomes] == list(range(40))
+        assert max(peak) <= 2
+        service.shutdown()
+
+    async def test_pending_budget(self):
+        """Beyond the budget, request batches should be refused and job batches wait."""
+        service = BatchLookupService(workers=4, max_items=100, workers_per_batch=2, max_pending_items=10)
+        release = threading.Event()
+        first = asyncio.ensure_future(service.run(list(range(8)), lambda item: release.wait(5) and item))
+        await asyncio.sleep(0.01)
+        assert service.pending_items == 8
+        with pytest.raises(BatchOverloadedError, match="8 batch items are pending"):
+            await service.run(list(range(5)), _lookup)
+        assert len(await service.run(["a", "b"], _lookup)) == 2
+
+        waiting = asyncio.ensure_future(service.run(list(range(5)), str, wait=True))
+        await asyncio.sleep(0.1)
+        assert not waiting.done()
+        release.set()
+        assert [o.result for o in await waiting] == ["0", "1", "2", "3", "4"]
+        assert [o.result for o in await first] == list(range(8)) and service.pending_items == 0
+        # A batch larger than the budget still runs when nothing else is pending
+        assert len(await service.run(list(range(20)), str)) == 20
+        service.shutdown()
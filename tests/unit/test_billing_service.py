@@ -0,0 +1,194 @@
+"""Unit tests for billing metering and export."""
+from datetime import datetime
+from typing import Optional
+
+import pytest
+from fastapi import Depends, FastAPI, Header, HTTPException, Request
+from fastapi.testclient import TestClient
+
+from src.middleware.billing import BillingMiddleware
+from src.models.database_models import BillingUsage
+from src.services import billing_service
+from src.services.auth_service import ADMIN_SCOPE, Principal, TokenVerifier
+from src.services.billing_service import (
+    BillingExporter,
+    BillingMeter,
+    DirectorySink,
+    S3Sink,
+    StripeMeterReporter,
+    billable,
+    export_sink,
+    parse_customers,
+)
+
+NINE = datetime(2026, 3, 1, 9, 15)
+TEN = datetime(2026, 3, 1, 10, 30)
+NOW = datetime(2026, 3, 1, 11, 10)  # 09:00 and 10:00 have ended more than 5 minutes ago
+
+
+@pytest.fixture
+def meter(db_session):
+    return BillingMeter(session_factory=lambda: db_session)
+
+
+class TestBillingMeter:
+    """Test counting billable requests and storing the counts."""
+
+    def test_flush_adds_to_hourly_rows(self, db_session, meter):
+        """Counts of every flush should be added to the tenant's hourly rows."""
+        for _ in range(2):
+            meter.record("acme", at=NINE)
+        meter.record("acme", at=TEN)
+        meter.record("globex", at=NINE)
+        assert meter.flush() == 3
+        meter.record("acme", at=NINE)
+        assert meter.flush() == 1
+
+        rows = {(r.tenant_id, r.hour, r.requests) for r in db_session.query(BillingUsage)}
+        assert rows == {("acme", "2026-03-01T09", 3), ("acme", "2026-03-01T10", 1), ("globex", "2026-03-01T09", 1)}
+
+    def test_billable(self):
+        """Refused and failed requests and exempt paths should not be billed."""
+        exempt = ["/admin/", "/api/v1/auth/"]
+        assert billable("/api/v1/lookup/ip/81.2.69.160", 200, exempt)
+        assert billable("/api/v1/geofences", 304, exempt)
+        assert not billable("/api/v1/geofences", 429, exempt)
+        assert not billable("/api/v1/geofences", 500, exempt)
+        assert not billable("/admin/v1/tenants", 200, exempt)
+
+    def test_middleware(self, meter):
+        """Requests with a principal should be metered for its tenant."""
+        app = FastAPI()
+        app.add_middleware(BillingMiddleware, exempt_paths=["/admin/"], meter_factory=lambda: meter)
+
+        async def principal(request: Request, authorization: Optional[str] = Header(None)):
+            if authorization is None:
+                raise HTTPException(status_code=401)
+            request.state.principal = Principal(subject="apikey:k-1", tenant_id="acme")
+
+        @app.get("/api/v1/ping", dependencies=[Depends(principal)])
+        async def ping():
+            return {}
+
+        client = TestClient(app)
+        assert client.get("/api/v1/ping", headers={"Authorization": "Bearer t"}).status_code == 200
+        assert client.get("/api/v1/ping").status_code == 401
+        assert meter._take() == {("acme", datetime.utcnow().strftime("%Y-%m-%dT%H")): 1}
+
+
+class TestBillingExporter:
+    """Test exporting ended hours."""
+
+    def test_file_export_is_incremental(self, db_session, meter, tmp_path):
+        """Ended hours should be exported once, late counts as an adjustment."""
+        exporter = BillingExporter(lambda: db_session, DirectorySink(str(tmp_path)), delay_seconds=300)
+        meter.record("acme", at=NINE)
+        meter.record("acme", at=NINE)
+        meter.record("acme", at=datetime(2026, 3, 1, 11, 5))  # current hour, not ended
+        meter.flush()
+
+        result = exporter.export_file(NOW)
+        assert (result.records, result.requests) == (1, 2)
+        assert open(result.location).read() == (
+            "tenant_id,period_start,period_end,requests\n"
+            "acme,2026-03-01T09:00:00Z,2026-03-01T10:00:00Z,2\n"
+        )
+        assert exporter.export_file(NOW).location is None
+
+        meter.record("acme", at=NINE)  # flushed late
+        meter.flush()
+        late = exporter.export_file(datetime(2026, 3, 1, 11, 20))
+        assert (late.records, late.requests) == (1, 1)
+        assert open(late.location).read().endswith("acme,2026-03-01T09:00:00Z,2026-03-01T10:00:00Z,1\n")
+
+    def test_failed_write_marks_nothing(self, db_session, meter):
+        """Counts of a file that could not be written should be exported next time."""
+        class BrokenSink(DirectorySink):
+            def write(self, name, body):
+                raise OSError("disk full")
+
+        meter.record("acme", at=NINE)
+        meter.flush()
+        with pytest.raises(RuntimeError, match="disk full"):
+            BillingExporter(lambda: db_session, BrokenSink("/nonexistent")).export_file(NOW)
+        assert db_session.query(BillingUsage).one().exported_requests == 0
+
+    @pytest.mark.asyncio
+    async def test_stripe_report(self, db_session, meter):
+        """Mapped tenants should be reported once, with idempotent identifiers, and retried on failure."""
+        calls = []
+        answers = [(500, "unavailable"), (200, "{}"), (200, "{}")]
+
+        async def sender(url, fields, headers, timeout_seconds):
+            calls.append((url, fields, headers))
+            return answers.pop(0)
+
+        stripe = StripeMeterReporter("sk_test_1", {"acme": "cus_1"}, sender=sender)
+        exporter = BillingExporter(lambda: db_session, stripe=stripe)
+        meter.record("acme", at=NINE)
+        meter.record("globex", at=NINE)
+        meter.flush()
+
+        assert (await exporter.run_once(NOW)).failed == 1
+        assert (await exporter.run_once(NOW)).reported == 1
+        assert (await exporter.run_once(NOW)).reported == 0
+        url, fields, headers = calls[1]
+        assert url.endswith("/v1/billing/meter_events")
+        assert headers["Authorization"] == "Bearer sk_test_1"
+        assert fields == {
+            "event_name": "geolocation_requests",
+            "identifier": "acme:2026-03-01T09:0",
+            "timestamp": "1772355600",
+            "payload[stripe_customer_id]": "cus_1",
+            "payload[value]": "1",
+        }
+        assert calls[0][1]["identifier"] == fields["identifier"]
+
+    def test_export_sink(self, tmp_path):
+        """Export URLs should name an S3 prefix or a directory."""
+        s3 = export_sink("s3://finance/engine/usage/")
+        assert isinstance(s3, S3Sink) and (s3.bucket, s3.prefix) == ("finance", "engine/usage")
+        assert isinstance(export_sink(str(tmp_path)), DirectorySink)
+        assert export_sink("") is None
+        with pytest.raises(ValueError):
+            export_sink("ftp://finance/usage")
+        with pytest.raises(ValueError):
+            parse_customers('{"acme": 1}')
+
+    def test_s3_upload(self):
+        """S3 exports should be put under the prefix."""
+        puts = []
+
+        class FakeS3:
+            def put_object(self, **kwargs):
+                puts.append(kwargs)
+
+        assert S3Sink("finance", "usage", FakeS3()).write("a.csv", b"x") == "s3://finance/usage/a.csv"
+        assert (puts[0]["Bucket"], puts[0]["Key"]) == ("finance", "usage/a.csv")
+
+
+class TestBillingRoutes:
+    """Test the admin billing endpoints."""
+
+    def test_hourly_usage(self, db_client, db_session, meter, monkeypatch):
+        """Stored counts should be listed by tenant and hour, as JSON or CSV."""
+        verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+        monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+        admin = {"Authorization": f"Bearer {verifier.issue('ops-1', 'platform', scopes=[ADMIN_SCOPE])}"}
+        meter.record("acme", at=NINE)
+        meter.record("globex", at=TEN)
+        meter.flush()
+
+        query = "since=2026-03-01T00:00:00&until=2026-03-02T00:00:00"
+        usage = db_client.get(f"/admin/v1/billing/usage?{query}", headers=admin).json()
+        assert usage["total"] == 2
+        assert [(r["tenant_id"], r["period_start"]) for r in usage["records"]] == [
+            ("acme", "2026-03-01T09:00:00"), ("globex", "2026-03-01T10:00:00"),
+        ]
+        csv = db_client.get(f"/admin/v1/billing/usage?{query}&tenant_id=globex&format=csv", headers=admin)
+        assert csv.headers["content-type"].startswith("text/csv")
+        assert csv.text.splitlines()[1:] == ["globex,2026-03-01T10:00:00Z,2026-03-01T11:00:00Z,1"]
+        reversed_range = "since=2026-03-02T00:00:00&until=2026-03-01T00:00:00"
+        assert db_client.get(f"/admin/v1/billing/usage?{reversed_range}", headers=admin).status_code == 400
+        monkeypatch.setattr(billing_service, "_billing_exporter", BillingExporter(lambda: db_session))
+        assert db_client.post("/admin/v1/billing/export", headers=admin).status_code == 503
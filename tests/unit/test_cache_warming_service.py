@@ -0,0 +1,130 @@
+"""Unit tests for warming the lookup cache from the hottest lookups."""
+import json
+
+from pydantic import BaseModel
+
+from src.config import get_config
+from src.services import cache_warming_service
+from src.services.cache_warming_service import (
+    CacheWarmer,
+    FileHotKeyStore,
+    HotKeyStore,
+    HotKeyTracker,
+    hot_key,
+)
+from src.services.health_service import CheckStatus, HealthService
+from src.services.lookup_cache_service import InMemoryLookupCacheStore, LookupCache, LookupType
+
+
+class Located(BaseModel):
+    ip: str
+
+
+class BrokenStore(HotKeyStore):
+    def add(self, counts, keep):
+        raise ConnectionError("redis down")
+
+    def top(self, n):
+        raise ConnectionError("redis down")
+
+
+class TestHotKeyTracker:
+    """Test counting lookups."""
+
+    def test_counts_warmable_lookups(self):
+        """Only lookup types that can be warmed should be counted, and not while warming."""
+        tracker = HotKeyTracker([LookupType.IP], max_tracked=4)
+        for _ in range(3):
+            tracker.record(LookupType.IP, {"ip": "81.2.69.142"})
+        tracker.record(LookupType.IP_AS_OF, {"ip": "81.2.69.142"})
+        with tracker.paused():
+            tracker.record(LookupType.IP, {"ip": "81.2.69.142"})
+        assert tracker.take() == {hot_key(LookupType.IP, {"ip": "81.2.69.142"}): 3}
+        assert tracker.take() == {}
+
+    def test_drops_least_counted(self):
+        """Beyond max_tracked queries, the least counted half should be dropped."""
+        tracker = HotKeyTracker([LookupType.IP], max_tracked=4)
+        for n in range(4):
+            for _ in range(n + 1):
+                tracker.record(LookupType.IP, {"ip": f"10.0.0.{n}"})
+        tracker.record(LookupType.IP, {"ip": "10.0.0.9"})
+        assert sorted(json.loads(key)["query"]["ip"] for key in tracker.take()) == ["10.0.0.2", "10.0.0.3"]
+
+
+class TestFileHotKeyStore:
+    """Test persisting counts to a file."""
+
+    def test_add_and_top(self, tmp_path):
+        """Counts should add up across persists, keeping the most counted."""
+        store = FileHotKeyStore(str(tmp_path / "cache" / "hot-keys.json"))
+        assert store.top(10) == []
+        store.add({"a": 1, "b": 5}, keep=2)
+        store.add({"a": 10, "c": 2}, keep=2)
+        assert store.top(10) == ["a", "b"]
+        assert store.top(1) == ["a"]
+
+
+class TestCacheWarmer:
+    """Test warming and persisting."""
+
+    def make(self, store, warmers=None, **options):
+        cache = LookupCache(InMemoryLookupCacheStore(), {LookupType.IP: 60})
+        computed = []
+
+        def warm_ip(query):
+            def compute():
+                if query["ip"] == "10.0.0.1":
+                    raise LookupError("No geolocation data for 10.0.0.1")
+                computed.append(query["ip"])
+                return Located(ip=query["ip"])
+
+            cache.read_through(LookupType.IP, "1", query, Located, compute)
+
+        tracker = HotKeyTracker([LookupType.IP])
+        cache.hot_keys = tracker
+        warmer = CacheWarmer(tracker, store, warmers or {LookupType.IP: warm_ip}, top_n=10, **options)
+        return warmer, cache, computed
+
+    def test_warm_from_hot_keys(self, tmp_path):
+        """The hottest lookups should be cached before the process is ready, skipping failures."""
+        store = FileHotKeyStore(str(tmp_path / "hot-keys.json"))
+        store.add({
+            hot_key(LookupType.IP, {"ip": "81.2.69.142"}): 9,
+            hot_key(LookupType.IP, {"ip": "10.0.0.1"}): 5,
+            hot_key("geocode", {"q": "London"}): 3,
+        }, keep=10)
+        warmer, cache, computed = self.make(store)
+        assert not warmer.ready
+        assert warmer.warm() == 1 and warmer.ready
+        assert computed == ["81.2.69.142"]
+        # Warming lookups are not counted; the process's own are
+        assert warmer.tracker.take() == {}
+        cache.read_through(LookupType.IP, "1", {"ip": "81.2.69.142"}, Located, lambda: None)
+        assert warmer.persist() == 1
+        assert store.top(1) == [hot_key(LookupType.IP, {"ip": "81.2.69.142"})]
+
+    def test_timeout_and_unreachable_store(self, tmp_path):
+        """Warming should stop at its timeout, and an unreachable store should not hold back readiness."""
+        store = FileHotKeyStore(str(tmp_path / "hot-keys.json"))
+        store.add({hot_key(LookupType.IP, {"ip": f"81.2.69.{n}"}): 1 for n in range(5)}, keep=10)
+        ticks = iter(range(100))
+        warmer, _, computed = self.make(store, timeout_seconds=3)
+        assert warmer.warm(clock=lambda: next(ticks)) == 2
+
+        broken, _, _ = self.make(BrokenStore())
+        assert broken.warm() == 0 and broken.ready
+        broken.tracker.record(LookupType.IP, {"ip": "81.2.69.142"})
+        assert broken.persist() == 0
+        # Kept for the next time
+        assert len(broken.tracker.take()) == 1
+
+    def test_readiness(self, tmp_path, monkeypatch):
+        """Readiness should fail until the cache is warmed."""
+        warmer, _, _ = self.make(FileHotKeyStore(str(tmp_path / "hot-keys.json")))
+        assert HealthService(get_config()).check_cache_warming().status == CheckStatus.SKIPPED
+        monkeypatch.setattr(cache_warming_service, "_cache_warmer", warmer)
+        check = HealthService(get_config()).check_cache_warming()
+        assert (check.status, check.critical) == (CheckStatus.FAIL, True)
+        warmer.warm()
+        assert HealthService(get_config()).check_cache_warming().detail == "0 lookups warmed"
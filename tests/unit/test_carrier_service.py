@@ -0,0 +1,130 @@
+"""Unit tests for mobile carrier attribution."""
+import pytest
+
+from src.services.carrier_service import (
+    Carrier,
+    CarrierDirectory,
+    CarrierEnricher,
+    CarrierRangeMap,
+    normalize_plmn,
+)
+from src.services.ip_lookup_service import IpLookupResult, normalize_ip
+from src.services.mmdb_service import GeoIPRecord
+
+
+DIRECTORY_CSV = """mcc,mnc,name,country
+234,15,Vodafone UK,gb
+234,10,O2 UK,GB
+310,260,T-Mobile US,US
+310,26,,US
+31,1,Broken,XX
+"""
+
+
+@pytest.fixture
+def directory(tmp_path):
+    """Directory loaded from a small CSV."""
+    path = tmp_path / "mcc-mnc.csv"
+    path.write_text(DIRECTORY_CSV)
+    return CarrierDirectory.from_csv(str(path))
+
+
+@pytest.fixture
+def ranges():
+    """Vodafone block with a more specific O2 allocation inside it."""
+    return CarrierRangeMap([
+        ("82.132.0.0/16", "234", "15"),
+        ("82.132.128.0/17", "234", "10"),
+        ("2a01:4c8::/32", "234", "15"),
+    ])
+
+
+def _result(ip, traits=None):
+    normalized = normalize_ip(ip)
+    record = GeoIPRecord.from_raw(
+        str(normalized.address), "0.0.0.0/0", {"traits": traits} if traits else {}
+    )
+    return IpLookupResult(normalized=normalized, record=record)
+
+
+class TestPlmn:
+    """Test MCC/MNC validation."""
+
+    def test_valid_pair_keeps_leading_zeros(self):
+        """MNC leading zeros should be preserved."""
+        assert normalize_plmn(" 310 ", "026") == ("310", "026")
+        assert normalize_plmn(234, "15") == ("234", "15")
+
+    @pytest.mark.parametrize("mcc, mnc", [("31", "26"), ("310", "2"), ("310", "2604"), ("abc", "15")])
+    def test_malformed_pair(self, mcc, mnc):
+        """Malformed codes should raise ValueError."""
+        with pytest.raises(ValueError):
+            normalize_plmn(mcc, mnc)
+
+
+class TestCarrierDirectory:
+    """Test directory loading and lookups."""
+
+    def test_load_csv(self, directory):
+        """Valid named rows should load; others should be skipped."""
+        assert len(directory) == 3
+        assert directory.get("234", "15") == Carrier("234", "15", "Vodafone UK", "GB")
+        assert directory.get("310", "260").name == "T-Mobile US"
+
+    def test_two_and_three_digit_mnc_differ(self, directory):
+        """A two-digit MNC should not match its three-digit form."""
+        assert directory.get("234", "015") is None
+
+    def test_missing_columns(self, tmp_path):
+        """A CSV without mcc, mnc and name columns should be rejected."""
+        path = tmp_path / "bad.csv"
+        path.write_text("mcc,operator\n234,Vodafone\n")
+        with pytest.raises(ValueError, match="mcc, mnc and name"):
+            CarrierDirectory.from_csv(str(path))
+
+
+class TestCarrierRangeMap:
+    """Test IP range attribution."""
+
+    def test_most_specific_network_wins(self, ranges):
+        """A nested allocation should win over its enclosing block."""
+        assert ranges.lookup(normalize_ip("82.132.1.1").address) == ("234", "15")
+        assert ranges.lookup(normalize_ip("82.132.200.1").address) == ("234", "10")
+
+    def test_ipv6_and_misses(self, ranges):
+        """IPv6 networks should match; other addresses should not."""
+        assert ranges.lookup(normalize_ip("2a01:4c8:1::1").address) == ("234", "15")
+        assert ranges.lookup(normalize_ip("81.2.69.142").address) is None
+        assert len(ranges) == 3
+
+    def test_malformed_row(self):
+        """Invalid networks or codes should be rejected."""
+        with pytest.raises(ValueError):
+            CarrierRangeMap([("82.132.0.0/16", "234", "X")])
+
+
+class TestCarrierEnricher:
+    """Test the carrier enrichment block."""
+
+    def test_range_match_named(self, directory, ranges):
+        """Range matches should be named from the directory."""
+        block = CarrierEnricher(directory, ranges).enrich(_result("82.132.200.1"))
+        assert block == {
+            "mcc": "234", "mnc": "10", "name": "O2 UK", "country_iso_code": "GB"
+        }
+
+    def test_geoip_traits_fallback(self, directory, ranges):
+        """GeoIP2 mobile traits should be used when no range matches."""
+        result = _result("81.2.69.142", {"mobile_country_code": "310", "mobile_network_code": "260"})
+        assert CarrierEnricher(directory, ranges).enrich(result)["name"] == "T-Mobile US"
+
+    def test_unknown_pair_unnamed(self, ranges):
+        """A pair missing from the directory should still be reported."""
+        block = CarrierEnricher(ranges=ranges).enrich(_result("82.132.1.1"))
+        assert block == {"mcc": "234", "mnc": "15", "name": None, "country_iso_code": None}
+
+    def test_non_mobile_address(self, directory, ranges):
+        """Addresses without carrier data should produce no block."""
+        assert CarrierEnricher(directory, ranges).enrich(_result("81.2.69.142")) is None
+        bad = _result("81.2.69.142", {"mobile_country_code": "3", "mobile_network_code": "26"})
+        assert CarrierEnricher(directory).enrich(bad) is None
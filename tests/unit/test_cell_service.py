@@ -0,0 +1,124 @@
+"""Unit tests for cell tower positioning."""
+import gzip
+
+import pytest
+
+from src.models.database_models import CellTower
+from src.services.cell_service import CellObservation, CellPositioningService
+
+HEADER = "radio,mcc,net,area,cell,unit,lon,lat,range,samples,changeable,created,updated,averageSignal\n"
+
+TOWERS = [
+    {"radio": "LTE", "mcc": "234", "net": "15", "area": "1234", "cell": "1001",
+     "lon": "-0.1195", "lat": "51.5030", "range": "1500", "samples": "40"},
+    {"radio": "LTE", "mcc": "234", "net": "15", "area": "1234", "cell": "1002",
+     "lon": "-0.1100", "lat": "51.5080", "range": "1500", "samples": "12"},
+    {"radio": "GSM", "mcc": "234", "net": "15", "area": "1234", "cell": "1001",
+     "lon": "-0.1300", "lat": "51.5000", "range": "0", "samples": "3"},
+    {"radio": "LTE", "mcc": "234", "net": "15", "area": "1234", "cell": "2001",
+     "lon": "2.2945", "lat": "48.8584", "range": "1000", "samples": "5"},  # moved cell ID
+]
+
+
+def _cell(cid, lac=1234, **fields):
+    return CellObservation(mcc=234, mnc=15, lac=lac, cell_id=cid, **fields)
+
+
+@pytest.fixture
+def cell_service(db_session):
+    """Cell positioning service over a few London towers."""
+    service = CellPositioningService(db_session, min_towers=1, max_observations=5, max_spread_m=20000)
+    service.ingest(TOWERS)
+    return service
+
+
+class TestCellIngestion:
+    """Test loading OpenCelliD extracts."""
+
+    def test_ingest_skips_invalid_rows(self, db_session):
+        """Rows with an unknown radio or bad coordinate should be skipped."""
+        stored = CellPositioningService(db_session).ingest([
+            {**TOWERS[0]},
+            {**TOWERS[1], "radio": "WIMAX"},
+            {**TOWERS[1], "lat": "95"},
+            {**TOWERS[1], "cell": "x"},
+        ])
+        assert stored == 1
+
+    def test_ingest_updates_existing(self, cell_service, db_session):
+        """Re-ingesting a cell should update it rather than duplicate it."""
+        cell_service.ingest([{**TOWERS[0], "lat": "51.6"}])
+        tower = db_session.query(CellTower).filter_by(radio="LTE", cell_id=1001).one()
+        assert tower.latitude == 51.6
+
+    def test_zero_range_stored_as_unknown(self, cell_service, db_session):
+        """OpenCelliD's zero range should fall back to the radio default."""
+        tower = db_session.query(CellTower).filter_by(radio="GSM", cell_id=1001).one()
+        assert tower.range_m is None
+
+    def test_import_gzipped_csv(self, db_session, tmp_path):
+        """Gzipped OpenCelliD extracts should be loaded."""
+        path = tmp_path / "234.csv.gz"
+        with gzip.open(path, "wt") as f:
+            f.write(HEADER + "LTE,234,15,1234,1001,0,-0.1195,51.5030,1500,40,1,0,0,0\n")
+        assert CellPositioningService(db_session).import_csv(str(path)) == 1
+
+    def test_missing_columns(self, db_session, tmp_path):
+        """Files without OpenCelliD columns should raise ValueError."""
+        path = tmp_path / "cells.csv"
+        path.write_text("mcc,mnc\n234,15\n")
+        with pytest.raises(ValueError, match="missing OpenCelliD columns"):
+            CellPositioningService(db_session).import_csv(str(path))
+
+
+class TestCellLocate:
+    """Test positioning from observed cells."""
+
+    def test_single_tower(self, cell_service):
+        """One known cell should give the tower with its coverage range."""
+        position = cell_service.locate([_cell(1002, radio="LTE")])
+        assert position.estimate.latitude == pytest.approx(51.5080)
+        assert position.estimate.accuracy_m == pytest.approx(1500)
+        assert position.towers_known == 1
+
+    def test_timing_advance_narrows_range(self, cell_service):
+        """LTE timing advance should replace the coverage range."""
+        position = cell_service.locate([_cell(1002, radio="lte", timing_advance=2)])
+        assert position.estimate.accuracy_m == pytest.approx(2.5 * 78.12)
+
+    def test_radio_disambiguates(self, cell_service):
+        """The radio type should select between towers sharing identifiers."""
+        position = cell_service.locate([_cell(1001, radio="GSM")])
+        assert position.estimate.longitude == pytest.approx(-0.1300)
+        assert position.estimate.accuracy_m == 5000  # GSM default range
+
+    def test_without_radio_prefers_best_sampled(self, cell_service):
+        """Without a radio type, the best-sampled matching tower should be used."""
+        position = cell_service.locate([_cell(1001)])
+        assert position.estimate.longitude == pytest.approx(-0.1195)
+
+    def test_multiple_towers_and_outliers(self, cell_service):
+        """Neighbor cells should combine, and a distant re-used cell ID should be dropped."""
+        position = cell_service.locate([
+            _cell(1001, radio="LTE"), _cell(1002, radio="LTE"), _cell(2001, radio="LTE"),
+        ])
+        assert position.estimate.anchors_used == 2
+        assert position.towers_known == 3
+        assert 51.5030 < position.estimate.latitude < 51.5080
+
+    def test_unknown_cell(self, cell_service):
+        """Unknown cells should raise LookupError."""
+        with pytest.raises(LookupError):
+            cell_service.locate([_cell(1001, lac=9999)])
+
+    @pytest.mark.parametrize("observations,message", [
+        ([], "At least one"),
+        ([_cell(1001)] * 6, "Too many"),
+        ([CellObservation(mcc=1234, mnc=15, lac=1, cell_id=1)], "Invalid MCC"),
+        ([_cell(1001, radio="WIMAX")], "Unknown radio type"),
+        ([_cell(1001, timing_advance=-1)], "Timing advance"),
+    ])
+    def test_invalid_observations(self, cell_service, observations, message):
+        """Malformed requests should raise ValueError."""
+        with pytest.raises(ValueError, match=message):
+            cell_service.locate(observations)
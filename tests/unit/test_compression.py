@@ -0,0 +1,98 @@
+"""Tests for response compression negotiation."""
+import gzip
+
+import pytest
+from fastapi import FastAPI
+from fastapi.responses import PlainTextResponse, StreamingResponse
+from fastapi.testclient import TestClient
+
+from src.middleware.compression import CompressionMiddleware, negotiate, parse_encodings
+
+BODY = "x" * 4096
+
+
+def _client(**options):
+    """Small app behind the middleware, offering gzip."""
+    app = FastAPI()
+    app.add_middleware(CompressionMiddleware, encodings=("gzip",), minimum_size=1024, **options)
+
+    @app.get("/large")
+    async def large():
+        return PlainTextResponse(BODY, headers={"ETag": '"v1-abc"'})
+
+    @app.get("/small")
+    async def small():
+        return PlainTextResponse("ok")
+
+    @app.get("/stream")
+    async def stream():
+        return StreamingResponse(iter(["a" * 10, "b" * 10]), media_type="application/x-ndjson")
+
+    @app.get("/events")
+    async def events():
+        return StreamingResponse(iter(["data: 1\n\n"]), media_type="text/event-stream")
+
+    return TestClient(app)
+
+
+def _get(client, path, encoding="gzip"):
+    # Undecoded, to check the bytes on the wire
+    with client.stream("GET", path, headers={"Accept-Encoding": encoding}) as response:
+        return response, b"".join(response.iter_raw())
+
+
+class TestNegotiation:
+    """Test Accept-Encoding negotiation."""
+
+    def test_negotiate(self):
+        """Should pick the highest q-value, then the server's preference."""
+        assert negotiate("gzip, br", ("br", "gzip")) == "br"
+        assert negotiate("gzip, br;q=0.5", ("br", "gzip")) == "gzip"
+        assert negotiate("br;q=0, *", ("br", "gzip")) == "gzip"
+        assert negotiate("identity", ("br", "gzip")) is None
+        assert negotiate("", ("gzip",)) is None
+
+    def test_parse_encodings(self):
+        """Should reject encodings it cannot produce."""
+        assert "gzip" in parse_encodings("br, gzip")
+        assert parse_encodings("") == ()
+        with pytest.raises(ValueError, match="deflate"):
+            parse_encodings("deflate")
+
+
+class TestCompressionMiddleware:
+    """Test CompressionMiddleware."""
+
+    def test_large_body_compressed(self):
+        """Should gzip bodies from the minimum size on and weaken their ETag."""
+        response, raw = _get(_client(), "/large")
+        assert response.headers["content-encoding"] == "gzip"
+        assert "Accept-Encoding" in response.headers["vary"]
+        assert response.headers["etag"] == 'W/"v1-abc"'
+        assert int(response.headers["content-length"]) == len(raw)
+        assert gzip.decompress(raw).decode() == BODY
+
+    def test_small_body_and_identity(self):
+        """Should leave small bodies and clients without gzip alone."""
+        response, raw = _get(_client(), "/small")
+        assert "content-encoding" not in response.headers
+        assert raw == b"ok"
+
+        response, raw = _get(_client(), "/large", encoding="identity")
+        assert "content-encoding" not in response.headers
+        assert raw.decode() == BODY
+
+    def test_stream_compressed(self):
+        """Should compress streamed bodies chunk by chunk."""
+        response, raw = _get(_client(), "/stream")
+        assert response.headers["content-encoding"] == "gzip"
+        assert gzip.decompress(raw).decode() == "a" * 10 + "b" * 10
+
+    def test_opt_outs(self):
+        """Should never compress event streams or exempt paths."""
+        response, _ = _get(_client(), "/events")
+        assert "content-encoding" not in response.headers
+
+        response, raw = _get(_client(exempt_paths=["/large"]), "/large")
+        assert "content-encoding" not in response.headers
+        assert raw.decode() == BODY
@@ -0,0 +1,157 @@
+"""Unit tests for concurrency limiting and load shedding."""
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from src.middleware.concurrency import ConcurrencyMiddleware
+from src.services.auth_service import TokenVerifier
+from src.services.concurrency_service import (
+    GLOBAL_BUDGET,
+    ConcurrencyLimiter,
+    parse_endpoint_limits,
+    parse_priority_shares,
+)
+
+TILES = "GET /api/v1/tiles/{z}/{x}/{y}.mvt"
+
+
+class TestConcurrencyLimiter:
+    """Test taking and releasing request slots."""
+
+    def test_global_and_endpoint_budgets(self):
+        """Requests should be refused once the process or their endpoint has no slot left."""
+        limiter = ConcurrencyLimiter(max_in_flight=3, endpoints={TILES: 1}, default_share=1.0)
+        assert limiter.acquire(TILES).allowed
+        refused = limiter.acquire(TILES)
+        assert (refused.allowed, refused.budget, refused.limit, refused.in_flight) == (False, TILES, 1, 1)
+
+        assert limiter.acquire("GET /api/v1/pois").allowed
+        assert limiter.acquire().allowed
+        full = limiter.acquire("GET /api/v1/pois")
+        assert (full.allowed, full.budget, full.limit) == (False, GLOBAL_BUDGET, 3)
+
+        limiter.release(TILES)
+        assert limiter.in_flight() == 2 and limiter.in_flight_by_endpoint() == {}
+        # A free endpoint slot does not help while the process is full
+        assert limiter.acquire(TILES).allowed
+        assert not limiter.acquire().allowed
+
+    def test_endpoint_limits(self):
+        """Budgets should be a JSON object of positive integers."""
+        assert parse_endpoint_limits(f'{{"{TILES}": 16}}') == {TILES: 16}
+        assert parse_endpoint_limits("") == {}
+        for raw in ("[]", "{not json", f'{{"{TILES}": 0}}', f'{{"{TILES}": true}}'):
+            with pytest.raises(ValueError):
+                parse_endpoint_limits(raw)
+        with pytest.raises(ValueError):
+            ConcurrencyLimiter(max_in_flight=0)
+
+    def test_priority_shares(self):
+        """Near saturation, premium classes should still be admitted after lower ones are shed."""
+        limiter = ConcurrencyLimiter(max_in_flight=10, default_share=0.8)
+        assert limiter.priority_limits == {"enterprise": 10, "standard": 9, "free": 6}
+        for _ in range(5):
+            assert limiter.acquire(priority="free").allowed
+        assert not limiter.contended()
+        assert limiter.acquire(priority="free").allowed
+        assert limiter.contended()
+
+        free = limiter.acquire(priority="free")
+        assert (free.allowed, free.budget, free.limit) == (False, "priority:free", 6)
+        assert [limiter.acquire().allowed for _ in range(3)] == [True, True, False]
+        assert limiter.acquire(priority="platinum").budget == "priority:default"
+        assert limiter.acquire(priority="standard").allowed
+        assert not limiter.acquire(priority="standard").allowed
+        assert limiter.acquire(priority="enterprise").allowed
+        assert limiter.acquire(priority="enterprise").budget == GLOBAL_BUDGET
+
+    def test_parse_priority_shares(self):
+        """Entries should change the built-in shares or add plans."""
+        assert parse_priority_shares('{"free": 0.5, "batch": 0.3}') == {
+            "enterprise": 1.0, "standard": 0.9, "free": 0.5, "batch": 0.3,
+        }
+        for raw in ("[]", '{"free": 0}', '{"free": 1.5}', '{"free": "half"}'):
+            with pytest.raises(ValueError):
+                parse_priority_shares(raw)
+
+
+class TestConcurrencyMiddleware:
+    """Test shedding HTTP requests."""
+
+    @pytest.fixture
+    def limiter(self):
+        return ConcurrencyLimiter(max_in_flight=2, endpoints={"GET /slow/{n}": 1}, default_share=1.0)
+
+    @pytest.fixture
+    def client(self, limiter):
+        app = FastAPI()
+        app.add_middleware(
+            ConcurrencyMiddleware, exempt_paths=["/healthz"], retry_after_seconds=2, limiter_factory=lambda: limiter
+        )
+
+        @app.get("/slow/{n}")
+        async def slow(n: int):
+            return {"n": n}
+
+        @app.get("/fast")
+        async def fast():
+            return {}
+
+        @app.get("/fail")
+        async def fail():
+            raise RuntimeError("boom")
+
+        @app.get("/healthz")
+        async def healthz():
+            return {}
+
+        return TestClient(app, raise_server_exceptions=False)
+
+    def test_sheds_beyond_budgets(self, client, limiter):
+        """Requests should get 503 while their budget is taken, and give their slot back when served."""
+        limiter.acquire("GET /slow/{n}")  # a slow request still running
+        shed = client.get("/slow/2")
+        assert shed.status_code == 503
+        assert shed.headers["Retry-After"] == "2"
+        assert shed.json()["detail"] == {
+            "error_code": "E016",
+            "error_message": "Server overloaded; try again later",
+            "details": {"budget": "GET /slow/{n}", "limit": 1, "retry_after": 2},
+        }
+        assert client.get("/fast").status_code == 200
+
+        limiter.acquire()
+        assert client.get("/fast").json()["detail"]["details"]["budget"] == GLOBAL_BUDGET
+        assert client.get("/healthz").status_code == 200
+
+        limiter.release()
+        limiter.release("GET /slow/{n}")
+        assert client.get("/slow/2").json() == {"n": 2}
+        assert client.get("/fail").status_code == 500
+        assert limiter.in_flight() == 0
+
+    def test_premium_keys_admitted_under_contention(self):
+        """Tokens should be classified by their plan claim once the process is contended."""
+        verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+        limiter = ConcurrencyLimiter(max_in_flight=10, default_share=0.5)
+        app = FastAPI()
+        app.add_middleware(ConcurrencyMiddleware, limiter_factory=lambda: limiter, verifier=verifier)
+
+        @app.get("/fast")
+        async def fast():
+            return {}
+
+        def key(plan):
+            return {"Authorization": f"Bearer {verifier.issue('apikey:k-1', 'acme', quota_plan=plan)}"}
+
+        client = TestClient(app)
+        for _ in range(7):
+            limiter.acquire(priority="enterprise")
+        shed = client.get("/fast", headers=key("free"))
+        assert shed.status_code == 503
+        assert shed.json()["detail"]["details"]["budget"] == "priority:free"
+        assert client.get("/fast").json()["detail"]["details"]["budget"] == "priority:default"
+        assert client.get("/fast", headers=key("standard")).status_code == 200
+        # A user's token claiming a plan is no API key, and gets the default share
+        user = {"Authorization": f"Bearer {verifier.issue('user-1', 'acme', quota_plan='enterprise')}"}
+        assert client.get("/fast", headers=user).status_code == 503
@@ -36,3 +36,13 @@ def test_config_defaults_are_set():
     config = get_config()
     assert config.app_title == "Detection to COP"
     assert len(config.cors_origins) > 0
+
+
+@pytest.mark.unit
+def test_oidc_group_roles_parsed(monkeypatch):
+    """Unit test: OIDC group roles are parsed, malformed entries skipped."""
+    from src.config import get_config
+    monkeypatch.setenv("OIDC_GROUP_ROLES", '{"geo-ops": ["admin"], "bad": "admin"}')
+    assert get_config().oidc_group_roles == {"geo-ops": frozenset({"admin"})}
+    monkeypatch.setenv("OIDC_GROUP_ROLES", "not json")
+    assert get_config().oidc_group_roles == {}
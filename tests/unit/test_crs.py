@@ -0,0 +1,136 @@
+"""Unit tests for coordinate reference system conversions."""
+import pytest
+
+from src.spatial.crs import (
+    CRS,
+    WEB_MERCATOR,
+    WGS84,
+    from_wgs84,
+    parse_crs,
+    to_wgs84,
+    transform_geometry,
+    transformer,
+    utm_crs_for,
+)
+
+
+class TestParseCrs:
+    """Test CRS name parsing."""
+
+    @pytest.mark.parametrize("name,epsg", [
+        ("EPSG:4326", 4326),
+        ("epsg:3857", 3857),
+        ("EPSG:900913", 3857),
+        ("CRS84", 4326),
+        ("urn:ogc:def:crs:EPSG::32633", 32633),
+        ("urn:ogc:def:crs:EPSG:6.6:32756", 32756),
+    ])
+    def test_supported(self, name, epsg):
+        """Supported names should map to their EPSG code."""
+        assert parse_crs(name).epsg == epsg
+
+    @pytest.mark.parametrize("name", ["", "4326", "EPSG:27700", "EPSG:32661", "EPSG:32600"])
+    def test_unsupported(self, name):
+        """Malformed names and unsupported systems should raise ValueError."""
+        with pytest.raises(ValueError):
+            parse_crs(name)
+
+    def test_utm_properties(self):
+        """UTM codes should expose their zone and hemisphere."""
+        assert (CRS(32756).utm_zone, CRS(32756).utm_south) == (56, True)
+        assert (CRS(32633).utm_zone, CRS(32633).utm_south) == (33, False)
+        assert WEB_MERCATOR.utm_zone == 0
+
+
+class TestUtmZone:
+    """Test UTM zone selection."""
+
+    @pytest.mark.parametrize("lon,lat,epsg", [
+        (-0.1276, 51.5072, 32630),
+        (151.2093, -33.8688, 32756),
+        (5.3, 60.4, 32632),   # Bergen, widened zone 32
+        (15.6, 78.2, 32633),  # Svalbard
+        (180.0, 0.0, 32660),
+    ])
+    def test_zone(self, lon, lat, epsg):
+        """Coordinates should map to their zone, including the Norway exceptions."""
+        assert utm_crs_for(lon, lat).epsg == epsg
+
+    def test_polar_rejected(self):
+        """Latitudes outside 80S-84N should raise ValueError."""
+        with pytest.raises(ValueError, match="UTM"):
+            utm_crs_for(0, 85)
+
+
+class TestConversions:
+    """Test point conversions."""
+
+    def test_utm_reference_point(self):
+        """Sydney should project to its published zone 56S coordinates."""
+        x, y = from_wgs84(CRS(32756), 151.2093, -33.8688)
+        assert x == pytest.approx(334368.63, abs=0.01)
+        assert y == pytest.approx(6250948.35, abs=0.01)
+
+    def test_utm_origin(self):
+        """The central meridian at the equator should be the false origin."""
+        assert from_wgs84(CRS(32631), 3.0, 0.0) == pytest.approx((500000.0, 0.0))
+        assert from_wgs84(CRS(32731), 3.0, 0.0) == pytest.approx((500000.0, 10000000.0))
+
+    def test_web_mercator_extent(self):
+        """The antimeridian and latitude limit should be the tile grid edge."""
+        x, y = from_wgs84(WEB_MERCATOR, 180.0, 85.0511287798)
+        assert x == pytest.approx(20037508.34, abs=0.01)
+        assert y == pytest.approx(20037508.34, abs=0.01)
+
+    def test_web_mercator_latitude_limit(self):
+        """Latitudes beyond the Web Mercator limit should raise ValueError."""
+        with pytest.raises(ValueError, match="Web Mercator"):
+            from_wgs84(WEB_MERCATOR, 0, 89)
+
+    @pytest.mark.parametrize("crs", [WEB_MERCATOR, CRS(32630), CRS(32631), CRS(32756)])
+    @pytest.mark.parametrize("lon,lat", [(-0.1276, 51.5072), (2.5, 70.0), (151.2093, -33.8688)])
+    def test_round_trip(self, crs, lon, lat):
+        """Converting there and back should recover the coordinate."""
+        x, y = from_wgs84(crs, lon, lat)
+        assert to_wgs84(crs, x, y) == pytest.approx((lon, lat), abs=1e-8)
+
+    def test_zone_to_zone(self):
+        """Transformers should chain through WGS84."""
+        forward = transformer(CRS(32630), CRS(32631))
+        x, y = forward(*from_wgs84(CRS(32630), 0.0, 45.0))
+        assert to_wgs84(CRS(32631), x, y) == pytest.approx((0.0, 45.0))
+
+    def test_not_finite(self):
+        """NaN and infinite coordinates should raise ValueError."""
+        with pytest.raises(ValueError, match="not finite"):
+            to_wgs84(WEB_MERCATOR, float("nan"), 0)
+
+
+class TestTransformGeometry:
+    """Test GeoJSON geometry conversion."""
+
+    def test_polygon(self):
+        """Every position should be converted and extra members kept."""
+        polygon = {"type": "Polygon", "coordinates": [[[0, 0, 12], [1, 0], [1, 1], [0, 0, 12]]]}
+        projected = transform_geometry(polygon, transformer(WGS84, WEB_MERCATOR))
+        ring = projected["coordinates"][0]
+        assert ring[0] == pytest.approx([0.0, 0.0, 12], abs=1e-6)
+        assert ring[1][0] == pytest.approx(111319.49, abs=0.01)
+        assert polygon["coordinates"][0][1] == [1, 0]  # input untouched
+
+    def test_geometry_collection(self):
+        """Collections should convert each member geometry."""
+        collection = {"type": "GeometryCollection", "geometries": [
+            {"type": "Point", "coordinates": [1, 0]},
+        ]}
+        converted = transform_geometry(collection, transformer(WGS84, WEB_MERCATOR))
+        assert converted["geometries"][0]["coordinates"][0] == pytest.approx(111319.49, abs=0.01)
+
+    @pytest.mark.parametrize("geometry", [
+        None, {"type": "Point"}, {"type": "Point", "coordinates": []},
+        {"type": "Point", "coordinates": [1]},
+    ])
+    def test_malformed(self, geometry):
+        """Malformed geometry should raise ValueError."""
+        with pytest.raises(ValueError):
+            transform_geometry(geometry, transformer(WGS84, WEB_MERCATOR))
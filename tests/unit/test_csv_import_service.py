@@ -0,0 +1,130 @@
+"""Unit tests for CSV imports of geofences and POI datasets."""
+import io
+import json
+
+import pytest
+
+from src.models.database_models import BatchJob, Geofence
+from src.services.batch_job_service import BatchJobService, JobStatus
+from src.services.csv_import_service import CsvImportService, poi_row, read_csv
+from src.services.poi_service import PoiService
+
+DEPOT = json.dumps({
+    "type": "Polygon",
+    "coordinates": [[[-0.13, 51.50], [-0.12, 51.50], [-0.12, 51.51], [-0.13, 51.51], [-0.13, 51.50]]],
+})
+
+
+def _csv(*lines):
+    return io.StringIO("\n".join(lines) + "\n")
+
+
+def _quoted(value):
+    return '"' + value.replace('"', '""') + '"'
+
+
+def _results(job):
+    with open(job.result_path) as f:
+        return [json.loads(line) for line in f]
+
+
+class TestReadCsv:
+    """Test CSV parsing."""
+
+    def test_columns(self):
+        """Should match columns case-insensitively and map blank cells to None."""
+        stream = _csv(" ID ,Latitude,longitude,extra", "kgx, 51.53 ,,x")
+        rows = read_csv(stream, ("id", "latitude", "longitude", "name"), ("id",))
+        assert list(rows) == [{"id": "kgx", "latitude": "51.53", "longitude": None, "name": None}]
+
+    def test_missing_column(self):
+        """Should reject a header without the required columns before reading rows."""
+        with pytest.raises(ValueError, match="missing geometry"):
+            read_csv(_csv("name", "Depot"), ("name", "geometry"), ("name", "geometry"))
+
+    def test_poi_row(self):
+        """Should validate the coordinate and properties of a POI row."""
+        row = {"id": "kgx", "latitude": "51.53", "longitude": "-0.12", "name": None, "properties": '{"zone": 1}'}
+        assert poi_row(row)["properties"] == {"zone": 1}
+        with pytest.raises(ValueError, match="latitude out of range"):
+            poi_row({**row, "latitude": "95"})
+        with pytest.raises(ValueError, match="longitude is required"):
+            poi_row({**row, "longitude": None})
+        with pytest.raises(ValueError, match="properties must be a JSON object"):
+            poi_row({**row, "properties": "[1]"})
+
+
+class TestCsvImportService:
+    """Test imports recorded as batch jobs."""
+
+    def test_geofences(self, db_session, tmp_path):
+        """Should import the valid rows and report the others."""
+        stream = _csv(
+            "name,geometry,properties",
+            f"Depot,{_quoted(DEPOT)},{_quoted(json.dumps({'site': 'north'}))}",
+            "Broken,{not json,",
+            f",{_quoted(DEPOT)},",
+        )
+        result = CsvImportService(db_session, str(tmp_path)).import_geofences("acme", "fences.csv", stream)
+
+        job = result.job
+        assert job.status == JobStatus.SUCCEEDED
+        assert job.source == "upload:fences.csv"
+        assert (job.total_rows, job.processed_rows, job.failed_rows) == (3, 3, 2)
+        assert [(e.index, e.error_code) for e in result.errors] == [(1, "E002"), (2, "E002")]
+        assert result.errors[1].error_message == "name is required"
+
+        lines = _results(job)
+        geofence = db_session.query(Geofence).one()
+        assert lines[0] == {"index": 0, "geofence_id": geofence.geofence_id}
+        assert geofence.properties == {"site": "north"}
+        assert lines[1] == {"index": 1, "error": {"error_code": "E002", "error_message": "geometry is not valid JSON"}}
+        assert BatchJobService(db_session).get("acme", job.job_id) is not None
+
+    def test_geofences_from_wkt(self, db_session, tmp_path):
+        """Geometry cells may hold WGS84 WKT."""
+        stream = _csv(
+            "name,geometry",
+            f"Depot,{_quoted('POLYGON ((-0.13 51.50, -0.12 51.50, -0.12 51.51, -0.13 51.51, -0.13 51.50))')}",
+            f"Mercator,{_quoted('SRID=3857;POLYGON ((0 0, 1 0, 1 1, 0 0))')}",
+        )
+        result = CsvImportService(db_session, str(tmp_path)).import_geofences("acme", "fences.csv", stream)
+        assert db_session.query(Geofence).one().name == "Depot"
+        assert [e.error_message for e in result.errors] == ["geometry must be WGS84, not SRID 3857"]
+
+    def test_invalid_header_records_nothing(self, db_session, tmp_path):
+        """Should reject the upload before recording a job."""
+        with pytest.raises(ValueError):
+            CsvImportService(db_session, str(tmp_path)).import_geofences("acme", "fences.csv", _csv("label", "x"))
+        assert list(tmp_path.iterdir()) == []
+
+    def test_pois(self, db_session, tmp_path):
+        """Should create one dataset of the valid rows, rejecting repeated ids."""
+        stream = _csv(
+            "id,latitude,longitude,name",
+            "kgx,51.5308,-0.1238,King's Cross",
+            "eus,north,-0.1337,Euston",
+            "kgx,51.5282,-0.1337,Duplicate",
+            "pad,51.5154,-0.1755,",
+        )
+        result = CsvImportService(db_session, str(tmp_path)).import_pois("acme", "stations.csv", "Stations", stream)
+
+        dataset = PoiService(db_session).get_dataset("acme", result.dataset_id)
+        assert dataset.poi_count == 2
+        assert result.job.failed_rows == 2
+        assert [e.error_message for e in result.errors] == [
+            "latitude is required and must be a number",
+            "Duplicate POI id: kgx",
+        ]
+        assert [line.get("poi_id") for line in _results(result.job)] == ["kgx", None, None, "pad"]
+
+    def test_pois_without_valid_rows(self, db_session, tmp_path):
+        """Should fail the import when no row is valid."""
+        service = CsvImportService(db_session, str(tmp_path))
+        with pytest.raises(ValueError, match="No valid POI rows"):
+            service.import_pois("acme", "stations.csv", "Stations", _csv("id,latitude,longitude", "kgx,,"))
+
+        job = db_session.query(BatchJob).one()
+        assert job.status == JobStatus.FAILED
+        assert job.error == "No valid POI rows to import"
+        assert list(tmp_path.iterdir()) == []
@@ -0,0 +1,66 @@
+"""Unit tests for country info loading and the currency enrichment."""
+import pytest
+
+from src.services.country_info_service import load_country_info
+from src.services.currency_service import CurrencyEnricher
+from src.services.ip_lookup_service import IpLookupResult, normalize_ip
+from src.services.mmdb_service import GeoIPRecord
+
+
+def _country_row(iso, iso3, name, continent, currency_code, currency_name, languages):
+    columns = [iso, iso3, "", "", name, "", "", "", continent, "",
+               currency_code, currency_name, "", "", "", languages, "", "", ""]
+    return "\t".join(columns)
+
+
+COUNTRY_INFO = "\n".join([
+    "#ISO\tISO3\tISO-Numeric\tfips\tCountry\tCapital\t...",
+    _country_row("BR", "BRA", "Brazil", "SA", "BRL", "Real", "pt-BR,es,en,fr"),
+    _country_row("DE", "DEU", "Germany", "EU", "eur", "Euro", "de"),
+    _country_row("AQ", "ATA", "Antarctica", "AN", "", "", ""),
+]) + "\n"
+
+
+@pytest.fixture
+def countries(tmp_path):
+    """Country info loaded from a small countryInfo.txt."""
+    path = tmp_path / "countryInfo.txt"
+    path.write_text(COUNTRY_INFO)
+    return load_country_info(str(path))
+
+
+def _result(country):
+    record = GeoIPRecord(ip_address="81.2.69.142", network="81.2.69.128/26", country_iso_code=country)
+    return IpLookupResult(normalized=normalize_ip("81.2.69.142"), record=record)
+
+
+class TestLoadCountryInfo:
+    """Test GeoNames countryInfo.txt parsing."""
+
+    def test_columns(self, countries):
+        """Rows should be keyed by ISO code with the used columns parsed."""
+        brazil = countries["BR"]
+        assert (brazil.iso3_code, brazil.name, brazil.continent) == ("BRA", "Brazil", "SA")
+        assert brazil.languages == ["pt-BR", "es", "en", "fr"]
+        assert countries["DE"].currency_code == "EUR"
+        assert countries["AQ"].currency_code is None
+
+    def test_no_rows(self, tmp_path):
+        """A file without country rows should raise ValueError."""
+        path = tmp_path / "countryInfo.txt"
+        path.write_text("# header only\n")
+        with pytest.raises(ValueError):
+            load_country_info(str(path))
+
+
+class TestCurrencyEnricher:
+    """Test the currency enrichment block."""
+
+    def test_block(self, countries):
+        """The record's country should map to its ISO 4217 currency."""
+        assert CurrencyEnricher(countries).enrich(_result("BR")) == {"code": "BRL", "name": "Real"}
+
+    @pytest.mark.parametrize("country", ["AQ", "ZZ", None])
+    def test_no_currency(self, countries, country):
+        """Countries without a currency, unknown or missing, should give no block."""
+        assert CurrencyEnricher(countries).enrich(_result(country)) is None
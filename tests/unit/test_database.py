@@ -0,0 +1,40 @@
+"""Unit tests for the database connection pool."""
+import pytest
+from sqlalchemy import text
+from sqlalchemy.exc import TimeoutError as PoolTimeoutError
+
+from src.database import DatabaseManager
+
+
+@pytest.fixture
+def small_pool(tmp_path, monkeypatch):
+    """A database whose pool holds one connection and waits briefly for it."""
+    monkeypatch.setenv("DATABASE_POOL_SIZE", "1")
+    monkeypatch.setenv("DATABASE_MAX_OVERFLOW", "0")
+    monkeypatch.setenv("DATABASE_POOL_TIMEOUT_SECONDS", "0.05")
+    manager = DatabaseManager(database_url=f"sqlite:///{tmp_path / 'app.db'}")
+    yield manager
+    manager.close()
+
+
+class TestConnectionPool:
+    """Test pool sizing, exhaustion and its metrics."""
+
+    def test_pool_configured(self, small_pool):
+        """The pool should be sized from configuration."""
+        assert small_pool.get_pool_size() == {
+            "pool_size": 1, "max_overflow": 0, "checked_in": 0, "checked_out": 0,
+        }
+        assert not small_pool.pool_exhausted()
+
+    def test_exhausted_pool_times_out(self, small_pool, monkeypatch):
+        """Beyond its connections, checkouts should fail after the pool timeout and be counted."""
+        timeouts = []
+        monkeypatch.setattr("src.metrics.DB_POOL_CHECKOUT_TIMEOUTS.inc", lambda: timeouts.append(1))
+        with small_pool.engine.connect() as connection:
+            connection.execute(text("SELECT 1"))
+            assert small_pool.pool_exhausted()
+            with pytest.raises(PoolTimeoutError):
+                small_pool.engine.connect()
+        assert timeouts == [1]
+        assert not small_pool.pool_exhausted() and small_pool.health_check()
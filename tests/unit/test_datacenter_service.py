@@ -0,0 +1,127 @@
+"""Unit tests for datacenter / cloud IP classification."""
+import json
+
+import pytest
+
+from src.services.asn_service import AsnEnricher
+from src.services.datacenter_service import (
+    DatacenterEnricher,
+    load_cloud_ranges,
+    parse_cloud_ranges,
+)
+from src.services.ip_lookup_service import IpLookupResult, IpRangeSet, normalize_ip
+from src.services.mmdb_service import MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+AWS = {
+    "syncToken": "1700000000",
+    "prefixes": [{"ip_prefix": "3.5.140.0/22", "region": "ap-northeast-2", "service": "AMAZON"}],
+    "ipv6_prefixes": [{"ipv6_prefix": "2600:1f14::/35", "region": "us-west-2", "service": "EC2"}],
+}
+GCP = {
+    "syncToken": "1700000000",
+    "prefixes": [{"ipv4Prefix": "34.80.0.0/15", "service": "Google Cloud", "scope": "asia-east1"},
+                 {"ipv6Prefix": "2600:1900:4030::/44", "service": "Google Cloud", "scope": "asia-east1"}],
+}
+AZURE = {
+    "changeNumber": 1,
+    "values": [{"name": "AzureCloud", "properties": {"addressPrefixes": ["20.33.0.0/16", "2603:1000::/47"]}}],
+}
+
+
+def _result(ip):
+    return IpLookupResult(normalized=normalize_ip(ip))
+
+
+@pytest.fixture
+def asn_enricher(tmp_path):
+    """ASN enricher with a known cloud ASN, a hosting-named AS and an ISP."""
+    path = write_mmdb(
+        tmp_path / "asn.mmdb",
+        [
+            ("52.0.0.0/8", {"autonomous_system_number": 16509,
+                            "autonomous_system_organization": "AMAZON-02"}),
+            ("95.216.0.0/16", {"autonomous_system_number": 99999,
+                               "autonomous_system_organization": "Example Hosting Ltd"}),
+            ("81.2.69.0/24", {"autonomous_system_number": 20712,
+                              "autonomous_system_organization": "Andrews & Arnold Ltd"}),
+        ],
+    )
+    reader = MMDBReader(path)
+    yield AsnEnricher(reader)
+    reader.close()
+
+
+class TestParseCloudRanges:
+    """Test the published range file formats."""
+
+    @pytest.mark.parametrize("data,provider,expected", [
+        (AWS, "aws", ["3.5.140.0/22", "2600:1f14::/35"]),
+        (GCP, "gcp", ["34.80.0.0/15", "2600:1900:4030::/44"]),
+        (AZURE, "azure", ["20.33.0.0/16", "2603:1000::/47"]),
+    ])
+    def test_json_layouts(self, data, provider, expected):
+        """AWS, Google Cloud and Azure files should be recognized by their layout."""
+        assert parse_cloud_ranges(json.dumps(data), "ignored") == {provider: expected}
+
+    def test_text_lines(self):
+        """Text files should take an optional provider per line."""
+        text = "# OVH and others\n51.68.0.0/16 ovh\n141.95.0.0/16,OVH\n\n185.199.108.0/22\n"
+        assert parse_cloud_ranges(text, "extra") == {
+            "ovh": ["51.68.0.0/16", "141.95.0.0/16"],
+            "extra": ["185.199.108.0/22"],
+        }
+
+    def test_unknown_json(self):
+        """JSON of no known layout should be rejected."""
+        with pytest.raises(ValueError):
+            parse_cloud_ranges('{"ranges": []}', "x")
+
+    def test_load_skips_bad_files(self, tmp_path):
+        """Unreadable or malformed files should be skipped, not fail the load."""
+        good = tmp_path / "aws.json"
+        good.write_text(json.dumps(AWS))
+        bad = tmp_path / "bad.json"
+        bad.write_text('{"ranges": []}')
+
+        ranges = load_cloud_ranges([str(good), str(bad), str(tmp_path / "missing.txt")])
+        assert list(ranges) == ["aws"]
+        assert "3.5.141.9" in ranges["aws"]
+
+
+class TestDatacenterEnricher:
+    """Test classification of addresses."""
+
+    def test_cloud_ranges(self):
+        """Addresses in published ranges should name the provider."""
+        enricher = DatacenterEnricher({"gcp": IpRangeSet(["34.80.0.0/15"])})
+        assert enricher.enrich(_result("34.81.2.3")) == {
+            "is_datacenter": True, "provider": "gcp", "source": "cloud_ranges",
+        }
+        assert enricher.enrich(_result("81.2.69.142"))["is_datacenter"] is False
+
+    def test_embedded_ipv4(self):
+        """IPv4-mapped addresses should be matched on the IPv4 address."""
+        enricher = DatacenterEnricher({"gcp": IpRangeSet(["34.80.0.0/15"])})
+        assert enricher.enrich(_result("::ffff:34.81.2.3"))["provider"] == "gcp"
+
+    def test_hosting_asn(self, asn_enricher):
+        """Known cloud ASNs should classify addresses outside the loaded ranges."""
+        block = DatacenterEnricher(asn_enricher=asn_enricher).enrich(_result("52.1.2.3"))
+        assert block == {"is_datacenter": True, "provider": "aws", "source": "asn"}
+
+    def test_hosting_organization(self, asn_enricher):
+        """Hosting AS organization names should classify the address."""
+        block = DatacenterEnricher(asn_enricher=asn_enricher).enrich(_result("95.216.1.1"))
+        assert block == {
+            "is_datacenter": True, "provider": "Example Hosting Ltd", "source": "connection_type",
+        }
+
+    def test_residential(self, asn_enricher):
+        """Access network addresses should not be datacenter traffic."""
+        block = DatacenterEnricher(asn_enricher=asn_enricher).enrich(_result("81.2.69.142"))
+        assert block == {"is_datacenter": False, "provider": None, "source": None}
+
+    def test_no_sources(self):
+        """Without ranges or ASN data the block should be omitted."""
+        assert DatacenterEnricher().enrich(_result("34.81.2.3")) is None
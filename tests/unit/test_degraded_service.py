@@ -0,0 +1,111 @@
+"""Unit tests for degraded IP lookups."""
+import pytest
+from sqlalchemy.exc import SQLAlchemyError
+
+from src.services import degraded_service
+from src.services.degraded_service import DegradedMode, RecentAnswers
+from src.services.enrichment_service import EnrichmentPipeline
+from src.services.ip_lookup_service import IpLookupService
+from src.services.mmdb_service import MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+LONDON = {
+    "city": {"names": {"en": "London"}},
+    "country": {"iso_code": "GB", "names": {"en": "United Kingdom"}},
+    "continent": {"code": "EU"},
+    "location": {"latitude": 51.5142, "longitude": -0.0931, "accuracy_radius": 10},
+}
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 0.0
+
+    def __call__(self):
+        return self.now
+
+
+class TestRecentAnswers:
+    """Test keeping recent answers."""
+
+    def test_evicts_least_recent_and_expires(self):
+        """Answers should be dropped beyond max_entries, and not served once too old."""
+        clock = FakeClock()
+        answers = RecentAnswers(max_entries=2, max_age_seconds=60, clock=clock)
+        answers.put("a", 1)
+        answers.put("b", 2)
+        assert answers.get("a") == 1
+        answers.put("c", 3)
+        assert (answers.get("a"), answers.get("b"), answers.get("c")) == (1, None, 3)
+
+        clock.now = 61
+        assert answers.get("a") is None and len(answers) == 1
+
+    def test_overloaded(self):
+        """Only a load at or above the ratio should count as overload."""
+        load = [0.5]
+        mode = DegradedMode(overload_ratio=0.9, load=lambda: load[0])
+        assert not mode.overloaded()
+        load[0] = 0.9
+        assert mode.overloaded()
+        assert not DegradedMode().overloaded()
+
+
+class TestDegradedLookups:
+    """Test degraded answers of GET /api/v1/lookup/ip/{ip}."""
+
+    @pytest.fixture
+    def load(self, tmp_path, monkeypatch):
+        """Serve lookups from a one-network dataset, with degraded mode on at a settable load."""
+        path = write_mmdb(tmp_path / "city.mmdb", [("81.2.69.128/26", LONDON)])
+        reader = MMDBReader(path)
+        service = IpLookupService(reader)
+        monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: service)
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: EnrichmentPipeline())
+        load = [0.0]
+        monkeypatch.setattr(degraded_service, "_degraded_mode", DegradedMode(load=lambda: load[0]))
+        yield load
+        reader.close()
+
+    @staticmethod
+    def database_down(monkeypatch):
+        def fail(*args, **kwargs):
+            raise SQLAlchemyError("connection refused")
+
+        monkeypatch.setattr("src.api.lookup_routes.tenant_overrides", fail)
+
+    def test_dependency_unavailable(self, test_client, load, monkeypatch):
+        """Failed lookups should get the last answer, else the country of the dataset record."""
+        fresh = test_client.get("/api/v1/lookup/ip/81.2.69.142").json()
+        assert (fresh["degraded"], fresh["degraded_reason"]) == (False, None)
+
+        self.database_down(monkeypatch)
+        stale = test_client.get("/api/v1/lookup/ip/81.2.69.142").json()
+        assert (stale["degraded"], stale["degraded_reason"]) == (True, "dependency_unavailable")
+        assert stale["city_name"] == "London"
+
+        # The 6to4 address was never answered, so only its country is known
+        coarse = test_client.get("/api/v1/lookup/ip/2002:5102:458e::1")
+        assert coarse.status_code == 200
+        body = coarse.json()
+        assert (body["degraded"], body["country_iso_code"], body["continent_code"]) == (True, "GB", "EU")
+        assert (body["city_name"], body["latitude"], body["granularity"]) == (None, None, "country")
+        assert test_client.get("/api/v2/lookup/ip/81.2.69.142").json()["degraded"] is True
+
+    def test_dataset_down_too_is_503(self, test_client, load, monkeypatch):
+        """Without a recent answer or a dataset, lookups should fail as before."""
+        monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: None)
+        assert test_client.get("/api/v1/lookup/ip/81.2.69.142").json()["detail"]["error_code"] == "E003"
+
+    def test_overload(self, test_client, load, monkeypatch):
+        """Overloaded processes should answer plain lookups from recent answers, without the database."""
+        test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        load[0] = 1.0
+        calls = []
+        monkeypatch.setattr("src.api.lookup_routes.tenant_overrides", lambda *args: calls.append(args))
+        body = test_client.get("/api/v1/lookup/ip/81.2.69.142").json()
+        assert (body["degraded"], body["degraded_reason"], calls) == (True, "overload", [])
+
+        # Lookups of sessions are not plain, and never answered from another request's answer
+        session = test_client.get("/api/v1/lookup/ip/81.2.69.142", params={"session_id": "s-1"}).json()
+        assert session["degraded"] is False and len(calls) == 1
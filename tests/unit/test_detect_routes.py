@@ -0,0 +1,206 @@
+"""Route tests for the detection rule API."""
+from datetime import datetime, timedelta, timezone
+
+import pytest
+
+from src.services.anonymizer_service import AnonymizerEnricher
+from src.services.audit_log_service import AuditLogService
+from src.services.enrichment_service import Enricher, EnrichmentPipeline
+from src.services.ip_lookup_service import IpRangeSet
+from src.services.residential_proxy_service import SessionAsnTracker
+from src.services.velocity_service import InMemoryVelocityStore
+
+
+def _event(user_id, timestamp, latitude, longitude, **fields):
+    return {"user_id": user_id, "timestamp": timestamp,
+            "latitude": latitude, "longitude": longitude, **fields}
+
+
+class TestTravelRoute:
+    """Test POST /api/v1/detect/travel."""
+
+    def test_flags_impossible_travel(self, db_client):
+        """A London login followed by New York an hour later should be flagged."""
+        first = db_client.post(
+            "/api/v1/detect/travel", json=_event("alice", "2026-03-01T09:00:00Z", 51.5142, -0.0931)
+        )
+        assert first.status_code == 200
+        assert first.json()["impossible"] is False
+        assert first.json()["previous"] is None
+
+        second = db_client.post(
+            "/api/v1/detect/travel", json=_event("alice", "2026-03-01T10:00:00Z", 40.7128, -74.006)
+        )
+        assert second.status_code == 200
+        body = second.json()
+        assert body["impossible"] is True
+        assert body["elapsed_seconds"] == 3600
+        assert body["previous"]["latitude"] == 51.5142
+
+    def test_decisions_audited(self, db_client, db_session):
+        """Each verdict should be written to the audit log."""
+        db_client.post("/api/v1/detect/travel", json=_event("bob", "2026-03-01T09:00:00Z", 51.5142, -0.0931))
+        db_client.post("/api/v1/detect/travel", json=_event("bob", "2026-03-01T10:00:00Z", 40.7128, -74.006))
+        entries = AuditLogService(db_session).page(action="detect_travel").items
+        assert [(e.outcome, e.resource) for e in entries] == [("flagged", "user:bob"), ("clear", "user:bob")]
+        assert entries[0].details["speed_kmh"] > 1000
+        assert entries[0].actor is None
+
+    def test_both_location_forms_is_400(self, db_client):
+        """Should reject events with both an IP address and a coordinate."""
+        response = db_client.post(
+            "/api/v1/detect/travel",
+            json=_event("alice", "2026-03-01T09:00:00Z", 51.5, -0.1, ip_address="81.2.69.142"),
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_no_location_is_400(self, db_client):
+        """Should require an IP address or a coordinate."""
+        response = db_client.post("/api/v1/detect/travel", json={"user_id": "alice"})
+        assert response.status_code == 400
+
+    def test_unavailable_dataset_is_503(self, db_client, monkeypatch):
+        """Should return 503 when the IP cannot be located for lack of a dataset."""
+        monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: None)
+        response = db_client.post(
+            "/api/v1/detect/travel", json={"user_id": "alice", "ip_address": "81.2.69.142"}
+        )
+        assert response.status_code == 503
+
+
+class TestAnomalyRoutes:
+    """Test location anomaly scoring and profiles."""
+
+    def _logins(self, client, user_id, points):
+        for i, (lat, lon) in enumerate(points):
+            client.post("/api/v1/detect/travel", json=_event(user_id, f"2026-03-0{i + 1}T09:00:00Z", lat, lon))
+
+    def test_detect_anomaly(self, db_client):
+        """An event far from the user's logins should be flagged."""
+        self._logins(db_client, "alice", [(51.51, -0.09), (51.45, -0.98), (51.60, -0.20),
+                                          (51.38, -0.10), (51.52, 0.05)])
+        response = db_client.post(
+            "/api/v1/detect/anomaly", json={"user_id": "alice", "latitude": 40.7128, "longitude": -74.006}
+        )
+        assert response.status_code == 200
+        assert response.json()["anomalous"] is True
+        assert response.json()["samples"] == 5
+
+        profile = db_client.get("/api/v1/detect/anomaly/profiles/alice")
+        assert profile.status_code == 200
+        assert profile.json()["samples"] == 5
+
+    def test_missing_profile_is_404(self, db_client):
+        """Users never trained should have no profile."""
+        assert db_client.get("/api/v1/detect/anomaly/profiles/nobody").status_code == 404
+        assert db_client.post("/api/v1/detect/anomaly/profiles/nobody/train").status_code == 404
+
+    def test_requires_location(self, db_client):
+        """Events need an IP address or a coordinate."""
+        response = db_client.post("/api/v1/detect/anomaly", json={"user_id": "alice"})
+        assert response.status_code == 400
+
+
+class AsnByAddress(Enricher):
+    """ASN enricher answering from a fixed address -> ASN map."""
+
+    name = "asn"
+
+    def __init__(self, asns):
+        self.asns = asns
+
+    def enrich(self, result):
+        number = self.asns.get(str(result.normalized.address))
+        return {"number": number, "connection_type": "residential"} if number else None
+
+
+class TestResidentialProxyRoute:
+    """Test POST /api/v1/detect/residential-proxy."""
+
+    @pytest.fixture(autouse=True)
+    def tracker(self, monkeypatch):
+        """In-memory session tracker and a few residential ASNs."""
+        pipeline = EnrichmentPipeline([
+            AsnByAddress({"81.2.69.142": 20712, "73.1.1.1": 7922, "88.1.1.1": 3320}),
+            AnonymizerEnricher(residential_proxy_ranges=IpRangeSet(["100.64.0.0/16"])),
+        ])
+        tracker = SessionAsnTracker(InMemoryVelocityStore(), max_asns=2)
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: pipeline)
+        monkeypatch.setattr("src.api.lookup_routes.get_session_asn_tracker", lambda: tracker)
+        return tracker
+
+    def _request(self, client, ip, session_id="s-1"):
+        return client.post("/api/v1/detect/residential-proxy", json={"session_id": session_id, "ip_address": ip})
+
+    def test_asn_churn_detected(self, test_client):
+        """A session hopping across three ISPs should be flagged on the third."""
+        assert self._request(test_client, "81.2.69.142").json()["detected"] is False
+        assert self._request(test_client, "73.1.1.1").json()["detected"] is False
+        response = self._request(test_client, "88.1.1.1")
+        assert response.status_code == 200
+        body = response.json()
+        assert (body["detected"], body["asn_churn"], body["distinct_asns"]) == (True, True, 3)
+        assert (body["asn"], body["listed"]) == (3320, False)
+
+    def test_listed_endpoint_detected(self, test_client):
+        """A listed residential proxy endpoint should be detected immediately."""
+        body = self._request(test_client, "100.64.3.4").json()
+        assert (body["detected"], body["listed"], body["asn_churn"]) == (True, True, False)
+
+    def test_invalid_ip_is_400(self, test_client):
+        """Should reject input that is not an IP address."""
+        response = self._request(test_client, "not-an-ip")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_store_unavailable_is_503(self, test_client, tracker, monkeypatch):
+        """Should return 503 when the velocity store cannot be reached."""
+        def broken(*args, **kwargs):
+            raise RuntimeError("Velocity store unavailable: connection refused")
+
+        monkeypatch.setattr(tracker, "observe", broken)
+        response = self._request(test_client, "81.2.69.142")
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+
+class TestAccountSharingRoutes:
+    """Test account sharing detection and the flagged account list."""
+
+    def _activity(self, client, session_id, timestamp, latitude, longitude):
+        return client.post("/api/v1/detect/account-sharing", json={
+            "account_id": "alice", "session_id": session_id, "timestamp": timestamp,
+            "latitude": latitude, "longitude": longitude,
+        })
+
+    def test_distant_sessions_flagged_and_listed(self, db_client):
+        """Concurrent London and New York sessions should flag the account."""
+        now = datetime.now(timezone.utc).replace(microsecond=0)
+        first = self._activity(db_client, "s-1", (now - timedelta(minutes=5)).isoformat(), 51.5142, -0.0931)
+        assert first.status_code == 200
+        assert first.json()["flagged"] is False
+
+        second = self._activity(db_client, "s-2", now.isoformat(), 40.7128, -74.006)
+        assert second.status_code == 200
+        body = second.json()
+        assert (body["flagged"], body["active_sessions"]) == (True, 2)
+        assert body["conflicts"][0]["session_id"] == "s-1"
+
+        listed = db_client.get("/api/v1/detect/account-sharing/flagged")
+        assert listed.status_code == 200
+        accounts = listed.json()["accounts"]
+        assert [a["account_id"] for a in accounts] == ["alice"]
+        assert accounts[0]["session_ids"] == ["s-2", "s-1"]
+
+    def test_no_flags(self, db_client):
+        """Tenants without flagged accounts should get an empty list."""
+        response = db_client.get("/api/v1/detect/account-sharing/flagged")
+        assert response.json() == {"accounts": []}
+
+    def test_requires_location(self, db_client):
+        """Activities need an IP address or a coordinate."""
+        response = db_client.post(
+            "/api/v1/detect/account-sharing", json={"account_id": "alice", "session_id": "s-1"}
+        )
+        assert response.status_code == 400
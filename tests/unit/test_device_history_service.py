@@ -0,0 +1,111 @@
+"""Unit tests for device location history."""
+from datetime import datetime, timedelta, timezone
+
+import pytest
+
+from src.services.device_history_service import DeviceHistoryService, DeviceObservation
+
+T0 = datetime(2026, 3, 1, 9, 0)
+
+
+def _seen(country, minutes=0, city=None, **fields):
+    return DeviceObservation(T0 + timedelta(minutes=minutes), country, city, **fields)
+
+
+@pytest.fixture
+def history(db_session):
+    """Device history with sightings in GB, FR and GB again."""
+    service = DeviceHistoryService(db_session)
+    service.record("fp-1", _seen("GB", 0, "London"))
+    service.record("fp-1", _seen("fr", 60, "Paris"))
+    service.record("fp-1", _seen("GB", 120, "London"))
+    return service
+
+
+class TestDeviceHistory:
+    """Test recording and querying sightings."""
+
+    def test_history_newest_first(self, history):
+        """Sightings should be returned newest first with normalized countries."""
+        assert [o.country_code for o in history.history("fp-1")] == ["GB", "FR", "GB"]
+        assert [o.city_name for o in history.history("fp-1", limit=1)] == ["London"]
+        assert len(history.history("fp-1", since=T0 + timedelta(minutes=60))) == 2
+
+    def test_history_pages(self, history):
+        """Pages should continue after the cursor without repeating sightings."""
+        history.record("fp-1", _seen("DE", 120, "Berlin"))  # Same time as the last London sighting
+        first = history.history_page("fp-1", limit=2)
+        assert [o.city_name for o in first.items] == ["Berlin", "London"]
+        assert first.next_cursor is not None
+
+        second = history.history_page("fp-1", limit=2, cursor=first.next_cursor)
+        assert [o.city_name for o in second.items] == ["Paris", "London"]
+        assert [o.observed_at for o in second.items] == [T0 + timedelta(minutes=60), T0]
+        assert second.next_cursor is None
+
+    def test_invalid_cursor(self, history):
+        """A malformed cursor should be rejected."""
+        with pytest.raises(ValueError, match="Invalid cursor"):
+            history.history_page("fp-1", cursor="not-a-cursor")
+
+    def test_countries(self, history):
+        """Countries should be summarized with counts and first/last sightings."""
+        countries = history.countries("fp-1")
+        assert [(c.country_code, c.observations) for c in countries] == [("GB", 2), ("FR", 1)]
+        assert countries[0].first_seen == T0
+        assert countries[0].last_seen == T0 + timedelta(minutes=120)
+
+    def test_seen_in_country(self, history):
+        """Should say whether the device has ever been seen in a country."""
+        assert history.seen_in_country("fp-1", "fr").observations == 1
+        assert history.seen_in_country("fp-1", "US") is None
+        assert history.seen_in_country("fp-2", "GB") is None
+
+    def test_tenant_scoped(self, history):
+        """Device IDs of different tenants should not share history."""
+        assert history.countries("fp-1", tenant_id="acme") == []
+        history.record("fp-1", _seen("US"), tenant_id="acme")
+        assert [c.country_code for c in history.countries("fp-1", tenant_id="acme")] == ["US"]
+        assert history.seen_in_country("fp-1", "US") is None
+
+    def test_aware_times_stored_as_utc(self, db_session):
+        """Aware sighting times should be converted to UTC."""
+        service = DeviceHistoryService(db_session)
+        paris = timezone(timedelta(hours=1))
+        service.record("fp-1", DeviceObservation(datetime(2026, 3, 1, 10, 0, tzinfo=paris), "FR"))
+        assert service.history("fp-1")[0].observed_at == T0
+
+    @pytest.mark.parametrize("device_id,observation", [
+        ("", _seen("GB")),
+        ("fp-1", _seen("GBR")),
+        ("fp-1", _seen("GB", latitude=51.5)),
+        ("fp-1", _seen("GB", latitude=91.0, longitude=0.0)),
+    ])
+    def test_invalid(self, db_session, device_id, observation):
+        """Invalid device IDs, countries and coordinates should be rejected."""
+        with pytest.raises(ValueError):
+            DeviceHistoryService(db_session).record(device_id, observation)
+
+
+class TestDeviceCorrelation:
+    """Test comparing a sighting with the history before it."""
+
+    def test_new_device(self, db_session):
+        """A device's first sighting should be unknown with no countries."""
+        correlation = DeviceHistoryService(db_session).correlate("fp-9", _seen("GB"))
+        assert correlation.known_device is False
+        assert correlation.country_seen_before is False
+        assert correlation.countries_seen == 0
+
+    def test_new_country(self, history):
+        """A known device in a new country should be reported as such, then stored."""
+        correlation = history.correlate("fp-1", _seen("US", 180))
+        assert (correlation.known_device, correlation.country_seen_before) == (True, False)
+        assert correlation.countries_seen == 2
+        assert history.correlate("fp-1", _seen("US", 240)).country_seen_before is True
+
+    def test_without_country(self, history):
+        """Sightings without a country should leave country_seen_before unset."""
+        correlation = history.correlate("fp-1", _seen(None, 180))
+        assert correlation.known_device is True
+        assert correlation.country_seen_before is None
@@ -0,0 +1,59 @@
+"""Route tests for the device location history API."""
+
+
+def _record(client, device_id="fp-1", **fields):
+    return client.post(f"/api/v1/devices/{device_id}/locations", json=fields)
+
+
+class TestDeviceRoutes:
+    """Test recording and querying device sightings."""
+
+    def test_record_and_correlate(self, db_client):
+        """Sightings should report whether the device and country were known."""
+        first = _record(db_client, country_code="GB", city_name="London", timestamp="2026-03-01T09:00:00Z")
+        assert first.status_code == 200
+        assert first.json()["known_device"] is False
+        assert first.json()["observation"]["country_code"] == "GB"
+
+        second = _record(db_client, country_code="US", timestamp="2026-03-01T10:00:00Z")
+        body = second.json()
+        assert (body["known_device"], body["country_seen_before"], body["countries_seen"]) == (True, False, 1)
+
+    def test_history_queries(self, db_client):
+        """History, country summary and country checks should reflect sightings."""
+        _record(db_client, country_code="GB", timestamp="2026-03-01T09:00:00Z")
+        _record(db_client, country_code="GB", timestamp="2026-03-01T11:00:00Z")
+
+        history = db_client.get("/api/v1/devices/fp-1/locations").json()
+        assert [o["timestamp"] for o in history["observations"]] == [
+            "2026-03-01T11:00:00Z", "2026-03-01T09:00:00Z",
+        ]
+
+        assert history["next_cursor"] is None
+        first = db_client.get("/api/v1/devices/fp-1/locations", params={"limit": 1}).json()
+        assert [o["timestamp"] for o in first["observations"]] == ["2026-03-01T11:00:00Z"]
+        rest = db_client.get(
+            "/api/v1/devices/fp-1/locations", params={"limit": 1, "cursor": first["next_cursor"]}
+        ).json()
+        assert [o["timestamp"] for o in rest["observations"]] == ["2026-03-01T09:00:00Z"]
+        invalid = db_client.get("/api/v1/devices/fp-1/locations", params={"cursor": "x"})
+        assert invalid.status_code == 400
+
+        countries = db_client.get("/api/v1/devices/fp-1/countries").json()["countries"]
+        assert [(c["country_code"], c["observations"]) for c in countries] == [("GB", 2)]
+
+        seen = db_client.get("/api/v1/devices/fp-1/countries/gb").json()
+        assert (seen["seen"], seen["observations"], seen["first_seen"]) == (True, 2, "2026-03-01T09:00:00Z")
+        unseen = db_client.get("/api/v1/devices/fp-1/countries/US").json()
+        assert (unseen["seen"], unseen["country_code"], unseen["first_seen"]) == (False, "US", None)
+
+    def test_location_required(self, db_client):
+        """Should require an IP address or a country code, not both."""
+        assert _record(db_client).status_code == 400
+        response = _record(db_client, ip_address="81.2.69.142", country_code="GB")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_invalid_country_is_400(self, db_client):
+        """Malformed country codes should be rejected."""
+        assert db_client.get("/api/v1/devices/fp-1/countries/G1").status_code == 400
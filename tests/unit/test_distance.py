@@ -0,0 +1,91 @@
+"""Unit tests for haversine and Vincenty distances."""
+import pytest
+
+from src.spatial.distance import (
+    DistanceMethod,
+    VincentyConvergenceError,
+    convert,
+    distance,
+    haversine,
+    vincenty,
+)
+
+
+class TestVincenty:
+    """Test the ellipsoidal inverse solution."""
+
+    def test_reference_geodesic(self):
+        """Flinders Peak to Buninyong should match Vincenty's published result."""
+        result = vincenty(-37.95103342, 144.42486789, -37.65282114, 143.92649554)
+        assert result.meters == pytest.approx(54972.271, abs=0.001)
+        assert result.initial_bearing == pytest.approx(306.86816, abs=1e-5)
+        assert result.method == DistanceMethod.VINCENTY
+
+    def test_equator_quarter(self):
+        """A quarter of the equator should equal a quarter of its circumference."""
+        result = vincenty(0, 0, 0, 90)
+        assert result.meters == pytest.approx(10018754.171, abs=0.01)
+        assert result.initial_bearing == pytest.approx(90.0)
+
+    def test_same_point(self):
+        """Coincident points should have zero distance and no bearing."""
+        result = vincenty(51.5, -0.12, 51.5, -0.12)
+        assert result.meters == 0.0
+        assert result.initial_bearing is None
+
+    def test_antipodal_does_not_converge(self):
+        """Nearly antipodal points should raise VincentyConvergenceError."""
+        with pytest.raises(VincentyConvergenceError):
+            vincenty(0, 0, 0.5, 179.7)
+
+
+class TestHaversine:
+    """Test the spherical formula."""
+
+    def test_london_new_york(self):
+        """London to New York should be about 5570 km, heading west-northwest."""
+        result = haversine(51.5074, -0.1278, 40.7128, -74.006)
+        assert result.meters == pytest.approx(5_570_230, rel=1e-4)
+        assert result.initial_bearing == pytest.approx(288.33, abs=0.01)
+
+    def test_close_to_vincenty(self):
+        """Haversine should be within 0.5% of Vincenty."""
+        spherical = haversine(-33.8688, 151.2093, 35.6762, 139.6503)
+        ellipsoidal = vincenty(-33.8688, 151.2093, 35.6762, 139.6503)
+        assert spherical.meters == pytest.approx(ellipsoidal.meters, rel=0.005)
+
+    def test_meridian_bearing(self):
+        """Due north should have bearings of 0."""
+        result = haversine(10, 20, 30, 20)
+        assert result.initial_bearing == pytest.approx(0.0)
+        assert result.final_bearing == pytest.approx(0.0)
+
+    def test_out_of_range(self):
+        """Out-of-range coordinates should raise ValueError."""
+        with pytest.raises(ValueError, match="out of range"):
+            haversine(100, 0, 0, 0)
+
+
+class TestDistance:
+    """Test method dispatch and units."""
+
+    def test_vincenty_falls_back(self):
+        """Non-convergent Vincenty should fall back to haversine."""
+        assert distance(0, 0, 0.5, 179.7).method == DistanceMethod.HAVERSINE
+
+    def test_unknown_method(self):
+        """Unknown methods should raise ValueError."""
+        with pytest.raises(ValueError, match="Unknown distance method"):
+            distance(0, 0, 1, 1, method="manhattan")
+
+    @pytest.mark.parametrize("unit,expected", [
+        ("m", 1852.0), ("km", 1.852), ("nmi", 1.0), ("mi", 1.150779), ("ft", 6076.115),
+    ])
+    def test_convert(self, unit, expected):
+        """Conversions should use exact unit definitions."""
+        assert convert(1852.0, unit) == pytest.approx(expected, rel=1e-6)
+
+    def test_unknown_unit(self):
+        """Unknown units should raise ValueError."""
+        with pytest.raises(ValueError, match="Unknown distance unit"):
+            convert(1.0, "furlong")
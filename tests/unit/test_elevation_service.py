@@ -0,0 +1,100 @@
+"""Unit tests for SRTM terrain elevation lookups."""
+import struct
+import zipfile
+
+import pytest
+
+from src.services.elevation_service import ElevationService, parse_tile_name, tile_name
+
+SRTM3_SIDE = 1201
+VOID = -32768
+
+
+def _write_tile(path, value=lambda row, col: 100, side=SRTM3_SIDE):
+    """Write an .hgt tile whose sample at (row, col) is value(row, col)."""
+    data = b"".join(
+        struct.pack(f">{side}h", *(value(row, col) for col in range(side))) for row in range(side)
+    )
+    if str(path).endswith(".zip"):
+        with zipfile.ZipFile(path, "w") as archive:
+            archive.writestr(path.name.split(".")[0] + ".hgt", data)
+    else:
+        path.write_bytes(data)
+
+
+class TestTileNames:
+    """Test SRTM tile naming."""
+
+    @pytest.mark.parametrize("key,name", [((51, -1), "N51W001"), ((-34, 151), "S34E151"), ((0, 0), "N00E000")])
+    def test_round_trip(self, key, name):
+        """Tile keys and names should convert both ways."""
+        assert tile_name(key) == name
+        assert parse_tile_name(name + ".hgt") == key
+
+    def test_distribution_names(self):
+        """Zipped NASA/USGS downloads should be recognized; other files ignored."""
+        assert parse_tile_name("/data/N51W001.SRTMGL1.hgt.zip") == (51, -1)
+        assert parse_tile_name("readme.txt") is None
+
+
+class TestElevationLookup:
+    """Test elevation from tiles."""
+
+    def test_gradient_is_interpolated(self, tmp_path):
+        """Heights between samples should be bilinearly interpolated."""
+        _write_tile(tmp_path / "N51W001.hgt", lambda row, col: col)
+        service = ElevationService.from_directory(str(tmp_path))
+        result = service.lookup(51.5, -1 + 100.5 / 1200)
+        assert result.elevation_m == pytest.approx(100.5)
+        assert result.resolution_arcsec == 3
+        assert result.tile == "N51W001"
+
+    def test_rows_run_north_to_south(self, tmp_path):
+        """The first row of a tile should be its northern edge."""
+        _write_tile(tmp_path / "N51W001.hgt", lambda row, col: 1200 - row)
+        service = ElevationService.from_directory(str(tmp_path))
+        assert service.lookup(51.999999, -0.5).elevation_m == pytest.approx(1200, abs=0.1)
+        assert service.lookup(51.0, -0.5).elevation_m == 0
+
+    def test_voids_are_skipped(self, tmp_path):
+        """Void samples should be left out of the interpolation."""
+        _write_tile(tmp_path / "N51W001.hgt", lambda row, col: VOID if col == 0 else 50)
+        service = ElevationService.from_directory(str(tmp_path))
+        assert service.lookup(51.5, -1 + 0.5 / 1200).elevation_m == 50
+        assert service.lookup(51.5, -1.0) is None
+
+    def test_zipped_tile(self, tmp_path):
+        """Zipped tiles should be read in place."""
+        _write_tile(tmp_path / "N51W001.SRTMGL3.hgt.zip")
+        service = ElevationService.from_directory(str(tmp_path))
+        assert service.lookup(51.5, -0.5).elevation_m == 100
+
+    def test_no_tile(self, tmp_path):
+        """Coordinates without a tile should have no elevation."""
+        _write_tile(tmp_path / "N51W001.hgt")
+        assert ElevationService.from_directory(str(tmp_path)).lookup(10.0, 10.0) is None
+
+    def test_cache_evicts_least_recent(self, tmp_path):
+        """At most cache_size tiles should stay loaded."""
+        _write_tile(tmp_path / "N51W001.hgt")
+        _write_tile(tmp_path / "N51E000.hgt")
+        service = ElevationService.from_directory(str(tmp_path), cache_size=1)
+        service.lookup(51.5, -0.5)
+        service.lookup(51.5, 0.5)
+        assert list(service._tiles) == [(51, 0)]
+
+    def test_corrupt_tile(self, tmp_path):
+        """A tile of the wrong size should raise RuntimeError."""
+        (tmp_path / "N51W001.hgt").write_bytes(b"\x00" * 100)
+        with pytest.raises(RuntimeError, match="unexpected size"):
+            ElevationService.from_directory(str(tmp_path)).lookup(51.5, -0.5)
+
+    def test_invalid_coordinate(self):
+        """Out-of-range coordinates should raise ValueError."""
+        with pytest.raises(ValueError):
+            ElevationService().lookup(91, 0)
+
+    def test_missing_directory(self, tmp_path):
+        """An unreadable tile directory should raise OSError."""
+        with pytest.raises(OSError):
+            ElevationService.from_directory(str(tmp_path / "missing"))
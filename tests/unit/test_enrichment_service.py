@@ -0,0 +1,121 @@
+"""Unit tests for the lookup enrichment pipeline."""
+import json
+import pytest
+
+from src.config import Config
+from src.services.enrichment_service import (
+    Enricher,
+    EnrichmentPipeline,
+    enabled_enrichments,
+)
+from src.services.ip_lookup_service import IpLookupResult, normalize_ip
+
+DEFAULTS = {
+    "asn", "anonymizer", "datacenter", "hierarchy", "carrier", "locale", "currency", "groups", "risk",
+}
+
+
+class StaticEnricher(Enricher):
+    """Enricher returning a fixed block."""
+
+    def __init__(self, name, block):
+        self.name = name
+        self.block = block
+
+    def enrich(self, result):
+        return self.block
+
+
+class FailingEnricher(Enricher):
+    """Enricher that always raises."""
+
+    name = "broken"
+
+    def enrich(self, result):
+        raise RuntimeError("dataset corrupt")
+
+
+@pytest.fixture
+def lookup_result():
+    """Lookup result without a GeoIP record."""
+    return IpLookupResult(normalized=normalize_ip("81.2.69.142"))
+
+
+class TestEnrichmentPipeline:
+    """Test applying enrichers."""
+
+    def test_only_enabled_enrichers_run(self, lookup_result):
+        """Enrichers not enabled for the caller should be skipped."""
+        pipeline = EnrichmentPipeline([
+            StaticEnricher("asn", {"number": 1}),
+            StaticEnricher("anonymizer", {"vpn": False}),
+        ])
+        blocks = pipeline.apply(lookup_result, {"asn"})
+        assert blocks == {"asn": {"number": 1}}
+
+    def test_none_blocks_omitted(self, lookup_result):
+        """Enrichers with no data should not appear in the output."""
+        pipeline = EnrichmentPipeline([StaticEnricher("asn", None)])
+        assert pipeline.apply(lookup_result, {"asn"}) == {}
+
+    def test_failing_enricher_isolated(self, lookup_result):
+        """A failing enricher should not break the others."""
+        pipeline = EnrichmentPipeline([
+            FailingEnricher(),
+            StaticEnricher("asn", {"number": 1}),
+        ])
+        blocks = pipeline.apply(lookup_result, {"asn", "broken"})
+        assert blocks == {"asn": {"number": 1}}
+
+    def test_available(self):
+        """Registered enricher names should be listed."""
+        pipeline = EnrichmentPipeline([StaticEnricher("asn", {})])
+        pipeline.register(StaticEnricher("anonymizer", {}))
+        assert pipeline.available == ["asn", "anonymizer"]
+
+
+class TestEnabledEnrichments:
+    """Test per-API-key enrichment selection."""
+
+    def test_defaults_without_key(self):
+        """Callers without a key should get the default enrichments."""
+        assert enabled_enrichments(None, Config()) == DEFAULTS
+
+    def test_profile_for_key(self, monkeypatch):
+        """Keys with a profile should get exactly their profile."""
+        monkeypatch.setenv(
+            "ENRICHMENT_API_KEY_PROFILES",
+            json.dumps({"key-basic": [], "key-full": ["asn", "anonymizer"]}),
+        )
+        config = Config()
+        assert enabled_enrichments("key-basic", config) == set()
+        assert enabled_enrichments("key-full", config) == {"asn", "anonymizer"}
+        assert enabled_enrichments("key-other", config) == DEFAULTS
+
+    def test_custom_defaults(self, monkeypatch):
+        """ENRICHMENT_DEFAULTS should be a comma-separated list."""
+        monkeypatch.setenv("ENRICHMENT_DEFAULTS", "asn, anonymizer,")
+        assert enabled_enrichments(None, Config()) == {"asn", "anonymizer"}
+        monkeypatch.setenv("ENRICHMENT_DEFAULTS", "asn")
+        assert enabled_enrichments(None, Config()) == {"asn"}
+
+    def test_invalid_profiles_fall_back(self, monkeypatch):
+        """Malformed profile JSON should fall back to defaults."""
+        monkeypatch.setenv("ENRICHMENT_API_KEY_PROFILES", "{not json")
+        assert enabled_enrichments("key", Config()) == DEFAULTS
+
+    def test_non_list_profile_skipped(self, monkeypatch):
+        """A profile that is not a list of names should be ignored."""
+        monkeypatch.setenv(
+            "ENRICHMENT_API_KEY_PROFILES",
+            json.dumps({"key-bad": "asn", "key-good": ["anonymizer"]}),
+        )
+        config = Config()
+        assert "key-bad" not in config.enrichment_profiles
+        assert enabled_enrichments("key-bad", config) == DEFAULTS
+        assert enabled_enrichments("key-good", config) == {"anonymizer"}
+
+    def test_non_object_profiles_ignored(self, monkeypatch):
+        """A JSON value that is not an object should yield no profiles."""
+        monkeypatch.setenv("ENRICHMENT_API_KEY_PROFILES", json.dumps(["asn"]))
+        assert Config().enrichment_profiles == {}
@@ -0,0 +1,173 @@
+"""Unit tests for the right to erasure of data subjects."""
+import json
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.models.database_models import (
+    DeviceLocation,
+    ErasureJob,
+    LoginLocation,
+    SanctionsAuditRecord,
+    SubjectTombstone,
+    WebhookDelivery,
+)
+from src.services import velocity_service
+from src.services.account_sharing_service import AccountSharingService
+from src.services.audit_log_service import AuditCategory, AuditLogService, AuditOutcome
+from src.services.auth_service import TokenVerifier
+from src.services.device_history_service import DeviceHistoryService, DeviceObservation
+from src.services.erasure_service import ErasureRunner, ErasureService, ErasureStatus, parse_ip_addresses
+from src.services.travel_service import TravelDetectionService, TravelPoint
+from src.services.velocity_service import VelocityTracker
+
+T0 = datetime(2026, 3, 1, 9, 0)
+
+
+@pytest.fixture(autouse=True)
+def tracker(monkeypatch):
+    """Fresh in-memory velocity counters."""
+    tracker = VelocityTracker()
+    monkeypatch.setattr(velocity_service, "_velocity_tracker", tracker)
+    return tracker
+
+
+def _login(minutes=0):
+    return TravelPoint(51.5142, -0.0931, T0 + timedelta(minutes=minutes), ip_address="81.2.69.142")
+
+
+def _store_subject(session, tenant_id="acme"):
+    DeviceHistoryService(session).record(
+        "alice", DeviceObservation(T0, "GB", "London", 51.5142, -0.0931, 10.0, "81.2.69.142"), tenant_id
+    )
+    TravelDetectionService(session).evaluate("alice", _login(), tenant_id)
+    AccountSharingService(session).observe("alice", "s-1", _login(), tenant_id)
+    session.add(WebhookDelivery(
+        delivery_id=f"d-{tenant_id}", webhook_id="w-1", tenant_id=tenant_id, event="geofence.enter",
+        body=json.dumps({"event": "geofence.enter", "data": {"entity_id": "alice", "latitude": 51.5}}),
+        status="delivered",
+    ))
+    session.add(SanctionsAuditRecord(
+        record_id=f"r-{tenant_id}", tenant_id=tenant_id, ip_address="81.2.69.142",
+        matched_code="IR", action="block", policy_source="global",
+    ))
+    session.commit()
+
+
+class TestErasure:
+    """Test deleting and tombstoning a subject."""
+
+    def test_erase_and_verify(self, db_session, tracker):
+        """The tenant's records of the subject should be deleted in batches and verified."""
+        _store_subject(db_session)
+        _store_subject(db_session, "globex")
+        tracker.observe("account", "alice", "GB", tenant_id="acme")
+        AuditLogService(db_session).append(
+            AuditCategory.DETECTION, "detect_travel", AuditOutcome.CLEAR, tenant_id="acme", resource="user:alice"
+        )
+
+        service = ErasureService(db_session, batch_size=1)
+        job = service.request("acme", " alice ")
+        report = service.erase("acme", "alice", ["81.2.69.142"])
+
+        assert report.verified
+        assert {name: store.deleted for name, store in report.stores.items() if store.deleted} == {
+            "device_locations": 1,
+            "login_locations": 1,
+            "account_sessions": 1,
+            "webhook_deliveries": 1,
+            "sanctions_audit_records": 1,
+        }
+        assert report.audit_log_entries_retained == 1
+        assert tracker.counts("account", "alice", tenant_id="acme").count("country", "24h") == 0
+        assert db_session.query(DeviceLocation).filter(DeviceLocation.tenant_id == "globex").count() == 1
+        assert db_session.query(SanctionsAuditRecord).count() == 1
+        stored = db_session.query(ErasureJob).one()
+        assert stored.erasure_id == job.erasure_id and "alice" not in stored.subject_hash
+
+    def test_tombstone_blocks_ingestion(self, db_session):
+        """Erased subjects should still be answered but no longer stored."""
+        ErasureService(db_session).request("acme", "alice")
+        assert ErasureService(db_session).is_erased("acme", "alice")
+        assert not ErasureService(db_session).is_erased("globex", "alice")
+        assert not ErasureService(db_session).is_erased(None, "alice")
+
+        _store_subject(db_session)
+        assert db_session.query(DeviceLocation).count() == 0
+        assert db_session.query(LoginLocation).count() == 0
+        assert TravelDetectionService(db_session).evaluate("alice", _login(5), "acme").previous is None
+
+        ErasureService(db_session).request("acme", "alice")
+        assert db_session.query(SubjectTombstone).count() == 1
+
+    def test_invalid_input(self, db_session):
+        """Empty subjects and malformed addresses should be refused."""
+        with pytest.raises(ValueError, match="subject"):
+            ErasureService(db_session).request("acme", "  ")
+        with pytest.raises(ValueError, match="Invalid IP address"):
+            parse_ip_addresses(["example.com"])
+        assert parse_ip_addresses(["2001:DB8::1", "2001:db8::1"]) == ["2001:db8::1"]
+
+
+class TestErasureRunner:
+    """Test running erasures in the background."""
+
+    async def test_run_stores_report(self, db_session):
+        """Should record the report once the erasure succeeded."""
+        _store_subject(db_session)
+        job = ErasureService(db_session).request("acme", "alice")
+        runner = ErasureRunner(session_factory=lambda: db_session)
+        await runner.run(job.erasure_id, "acme", "alice", [])
+
+        db_session.expire_all()
+        stored = ErasureService(db_session).get("acme", job.erasure_id)
+        assert stored.status == ErasureStatus.SUCCEEDED
+        assert json.loads(stored.report)["stores"]["login_locations"] == {"deleted": 1, "remaining": 0}
+
+    def test_recover_marks_interrupted(self, db_session):
+        """Erasures left running by a previous process should be marked failed."""
+        job = ErasureService(db_session).request("acme", "alice")
+        assert ErasureRunner(session_factory=lambda: db_session).recover() == 1
+        assert ErasureService(db_session).get("acme", job.erasure_id).status == ErasureStatus.FAILED
+
+
+class FakeRunner:
+    """Records submitted erasures instead of running them."""
+
+    def __init__(self):
+        self.submitted = []
+
+    def submit(self, erasure_id, tenant_id, subject, ip_addresses):
+        self.submitted.append((erasure_id, tenant_id, subject, ip_addresses))
+
+
+class TestErasureRoutes:
+    """Test the /api/v1/erasures endpoints."""
+
+    def test_request_and_poll(self, db_client, db_session, monkeypatch):
+        """Should tombstone the subject, queue the job and report it to its tenant only."""
+        verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+        monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+        runner = FakeRunner()
+        monkeypatch.setattr("src.api.erasure_routes.get_erasure_runner", lambda: runner)
+        auth = {"Authorization": f"Bearer {verifier.issue('user-1', 'acme')}"}
+
+        assert db_client.post("/api/v1/erasures", json={"subject": "alice"}).status_code == 401
+        response = db_client.post(
+            "/api/v1/erasures", json={"subject": "alice", "ip_addresses": ["81.2.69.142"]}, headers=auth
+        )
+        assert response.status_code == 202
+        erasure = response.json()
+        assert erasure["status"] == "queued" and erasure["tombstoned"] is True
+        assert runner.submitted == [(erasure["erasure_id"], "acme", "alice", ["81.2.69.142"])]
+
+        ErasureService(db_session).update(
+            erasure["erasure_id"], status=ErasureStatus.SUCCEEDED,
+            report=json.dumps(ErasureService(db_session).erase("acme", "alice").to_dict()),
+        )
+        polled = db_client.get(f"/api/v1/erasures/{erasure['erasure_id']}", headers=auth).json()
+        assert polled["report"]["verified"] is True
+        other = {"Authorization": f"Bearer {verifier.issue('user-2', 'globex')}"}
+        assert db_client.get(f"/api/v1/erasures/{erasure['erasure_id']}", headers=other).status_code == 404
+        bad = db_client.post("/api/v1/erasures", json={"subject": "alice", "ip_addresses": ["x"]}, headers=auth)
+        assert bad.status_code == 400
@@ -0,0 +1,22 @@
+"""Unit tests for dataset ETags and conditional requests."""
+from datetime import datetime, timezone
+
+from src.api.etag import dataset_version, etag_matches
+
+
+class TestEtag:
+    """Test ETag helpers."""
+
+    def test_dataset_version(self):
+        """Should use the build time in epoch seconds, 0 when unknown."""
+        assert dataset_version(datetime(2024, 2, 10, tzinfo=timezone.utc)) == "1707523200"
+        assert dataset_version(None) == "0"
+
+    def test_if_none_match(self):
+        """Should match listed, weak and wildcard tags."""
+        etag = '"1707523200-3f9a0c2be41d7a5c86e1"'
+        assert etag_matches(etag, etag)
+        assert etag_matches(f'"other", W/{etag}', etag)
+        assert etag_matches("*", etag)
+        assert not etag_matches('"1707523200-000000000000"', etag)
+        assert not etag_matches(None, etag)
@@ -0,0 +1,63 @@
+"""Unit tests for sparse fieldsets."""
+from typing import List, Optional
+
+import pytest
+from pydantic import BaseModel
+
+from src.api.fields import parse_fields, select_fields
+
+
+class Reason(BaseModel):
+    code: str
+    weight: float
+
+
+class Risk(BaseModel):
+    score: int
+    reasons: List[Reason] = []
+
+
+class Lookup(BaseModel):
+    ip_address: str
+    country_iso_code: Optional[str] = None
+    risk: Optional[Risk] = None
+
+
+LOOKUP = Lookup(
+    ip_address="81.2.69.142",
+    country_iso_code="GB",
+    risk=Risk(score=50, reasons=[Reason(code="ANONYMIZER_VPN", weight=0.5)]),
+).model_dump(mode="json")
+
+
+class TestSparseFields:
+    """Test parse_fields and select_fields."""
+
+    def test_no_selection(self):
+        """Without fields (or with only separators), every attribute is returned."""
+        assert parse_fields(None, Lookup) is None
+        assert parse_fields(" , ", Lookup) is None
+        assert select_fields(LOOKUP, None) == LOOKUP
+
+    def test_top_level(self):
+        """Should keep only the listed attributes."""
+        tree = parse_fields("country_iso_code, risk", Lookup)
+        assert select_fields(LOOKUP, tree) == {"country_iso_code": "GB", "risk": LOOKUP["risk"]}
+
+    def test_nested(self):
+        """Dotted names should select inside objects and lists of objects."""
+        tree = parse_fields("risk.score,risk.reasons.code", Lookup)
+        assert select_fields(LOOKUP, tree) == {"risk": {"score": 50, "reasons": [{"code": "ANONYMIZER_VPN"}]}}
+        # A whole attribute wins over a nested selection of it
+        assert parse_fields("risk.score,risk", Lookup) == {"risk": None}
+
+    def test_null_block(self):
+        """A selected null object stays null."""
+        data = Lookup(ip_address="81.2.69.142").model_dump(mode="json")
+        assert select_fields(data, parse_fields("risk.score", Lookup)) == {"risk": None}
+
+    @pytest.mark.parametrize("fields", ["country", "risk.level", "ip_address.version"])
+    def test_unknown_field(self, fields):
+        """Attributes the model lacks should be rejected."""
+        with pytest.raises(ValueError, match="Unknown field"):
+            parse_fields(fields, Lookup)
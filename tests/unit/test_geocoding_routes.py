@@ -0,0 +1,330 @@
+"""Route tests for the geocoding API."""
+import json
+
+import pytest
+
+from src.services.elevation_service import ElevationResult, ElevationService
+from src.services.geocoding_service import (
+    GeocodeCandidate,
+    GeocodingProvider,
+    GeocodingProviderError,
+    ProviderChain,
+)
+from src.services.maritime_service import MaritimeZone, MaritimeZoneService, ZoneType
+from src.services.reverse_geocoding_service import ReverseGeocodingService
+from src.services.timezone_service import TimezoneService
+from src.spatial.geometry import geometry_from_geojson
+
+
+def _box(min_lon, min_lat, max_lon, max_lat):
+    return {
+        "type": "Polygon",
+        "coordinates": [[
+            [min_lon, min_lat], [max_lon, min_lat], [max_lon, max_lat],
+            [min_lon, max_lat], [min_lon, min_lat],
+        ]],
+    }
+
+
+def _feature_collection(features):
+    return json.dumps({
+        "type": "FeatureCollection",
+        "features": [
+            {"type": "Feature", "properties": properties, "geometry": geometry}
+            for properties, geometry in features
+        ],
+    })
+
+
+def _broken_timezones():
+    """Timezone service whose only zone is missing from the tz database."""
+    return TimezoneService([("Mars/Olympus_Mons", geometry_from_geojson(_box(-8, 50, 2, 59)))])
+
+
+class StaticElevation(ElevationService):
+    """Elevation service answering 12.5 m north of the equator, nothing south of it."""
+
+    def __init__(self):
+        super().__init__({(51, -1): "N51W001.hgt"})
+
+    def lookup(self, latitude, longitude):
+        if not -90 <= latitude <= 90:
+            raise ValueError(f"Latitude out of range: {latitude}")
+        if latitude < 0:
+            return None
+        return ElevationResult(elevation_m=12.5, resolution_arcsec=1, tile="N51W001")
+
+
+class StaticProvider(GeocodingProvider):
+    """Provider returning fixed candidates or raising."""
+
+    def __init__(self, name, candidates=None, error=None):
+        self.name = name
+        self.candidates = candidates or []
+        self.error = error
+
+    async def geocode(self, query):
+        if self.error:
+            raise GeocodingProviderError(self.error)
+        return list(self.candidates)
+
+
+def _use_providers(monkeypatch, *providers):
+    chain = ProviderChain(list(providers))
+    monkeypatch.setattr("src.api.geocoding_routes.get_provider_chain", lambda: chain)
+
+
+@pytest.fixture
+def reverse_service(tmp_path, monkeypatch):
+    """Reverse geocoder over a toy UK boundary set, with ocean-only timezones."""
+    (tmp_path / "country.geojson").write_text(_feature_collection([
+        ({"code": "GB", "name": "United Kingdom"}, _box(-8, 50, 2, 59)),
+    ]))
+    (tmp_path / "city.geojson").write_text(_feature_collection([
+        ({"name": "London"}, _box(-0.5, 51.2, 0.3, 51.7)),
+    ]))
+    service = ReverseGeocodingService.from_directory(str(tmp_path))
+    monkeypatch.setattr(
+        "src.api.geocoding_routes.get_reverse_geocoding_service", lambda: service
+    )
+    monkeypatch.setattr(
+        "src.api.geocoding_routes.get_timezone_service", lambda: TimezoneService([])
+    )
+    return service
+
+
+class TestReverseGeocodeRoute:
+    """Test GET /api/v1/reverse."""
+
+    def test_reverse_match(self, test_client, reverse_service):
+        """Should return the admin areas containing the coordinate."""
+        response = test_client.get("/api/v1/reverse", params={"lat": 51.5, "lon": -0.12})
+        assert response.status_code == 200
+        body = response.json()
+        assert body["country"]["code"] == "GB"
+        assert body["city"]["name"] == "London"
+        assert body["admin1"] is None
+
+    def test_reverse_by_geohash(self, test_client, reverse_service):
+        """Should reverse geocode the center of a geohash cell."""
+        response = test_client.get("/api/v1/reverse", params={"geohash": "gcpvj0"})
+        assert response.status_code == 200
+        assert response.json()["city"]["name"] == "London"
+
+    def test_reverse_as_geojson(self, test_client, reverse_service):
+        """Should return the point and every matched boundary as Features."""
+        response = test_client.get(
+            "/api/v1/reverse", params={"lat": 51.5, "lon": -0.12, "format": "geojson"}
+        )
+        assert response.status_code == 200
+        assert response.headers["content-type"] == "application/geo+json"
+        body = response.json()
+        point, country, city = body["features"]
+        assert point["geometry"] == {"type": "Point", "coordinates": [-0.12, 51.5]}
+        assert point["properties"]["city"]["name"] == "London"
+        assert country["properties"] == {"level": "country", "code": "GB", "name": "United Kingdom"}
+        assert city["properties"]["level"] == "city"
+        assert body["bbox"] == [-8, 50, 2, 59]
+
+    def test_missing_coordinate_is_400(self, test_client, reverse_service):
+        """Should reject requests without lat/lon or geohash."""
+        response = test_client.get("/api/v1/reverse", params={"lat": 51.5})
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_no_boundary_is_404(self, test_client, reverse_service):
+        """Should return 404 when no boundary contains the coordinate."""
+        response = test_client.get("/api/v1/reverse", params={"lat": 0.0, "lon": 0.0})
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_no_boundary_data_is_503(self, test_client, monkeypatch):
+        """Should return 503 when no boundary level is loaded."""
+        monkeypatch.setattr(
+            "src.api.geocoding_routes.get_reverse_geocoding_service",
+            lambda: ReverseGeocodingService(),
+        )
+        response = test_client.get("/api/v1/reverse", params={"lat": 51.5, "lon": -0.12})
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+    def test_timezone_failure_omits_timezone(self, test_client, reverse_service, monkeypatch):
+        """Should still answer, without a timezone, if the timezone lookup fails."""
+        monkeypatch.setattr("src.api.geocoding_routes.get_timezone_service", _broken_timezones)
+        response = test_client.get("/api/v1/reverse", params={"lat": 51.5, "lon": -0.12})
+        assert response.status_code == 200
+        assert response.json()["country"]["code"] == "GB"
+        assert response.json()["timezone"] is None
+
+    def test_elevation_on_request(self, test_client, reverse_service, monkeypatch):
+        """Should include elevation only when asked for."""
+        monkeypatch.setattr("src.services.elevation_service.get_elevation_service", StaticElevation)
+        response = test_client.get("/api/v1/reverse", params={"lat": 51.5, "lon": -0.12})
+        assert response.json()["elevation_m"] is None
+
+        response = test_client.get(
+            "/api/v1/reverse", params={"lat": 51.5, "lon": -0.12, "elevation": "true"}
+        )
+        assert response.status_code == 200
+        assert response.json()["elevation_m"] == 12.5
+
+
+class TestElevationRoute:
+    """Test GET /api/v1/elevation."""
+
+    def test_elevation_lookup(self, test_client, monkeypatch):
+        """Should return the terrain height and source tile."""
+        monkeypatch.setattr("src.api.geocoding_routes.get_elevation_service", StaticElevation)
+        response = test_client.get("/api/v1/elevation", params={"lat": 51.5, "lon": -0.12})
+        assert response.status_code == 200
+        body = response.json()
+        assert body["elevation_m"] == 12.5
+        assert body["tile"] == "N51W001"
+
+    def test_no_coverage_is_404(self, test_client, monkeypatch):
+        """Should return 404 where no tile covers the coordinate."""
+        monkeypatch.setattr("src.api.geocoding_routes.get_elevation_service", StaticElevation)
+        response = test_client.get("/api/v1/elevation", params={"lat": -10.0, "lon": 0.0})
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_out_of_range_is_400(self, test_client, monkeypatch):
+        """Should reject coordinates out of range."""
+        monkeypatch.setattr("src.api.geocoding_routes.get_elevation_service", StaticElevation)
+        response = test_client.get("/api/v1/elevation", params={"lat": 91.0, "lon": 0.0})
+        assert response.status_code == 400
+
+    def test_no_tiles_is_503(self, test_client, monkeypatch):
+        """Should return 503 when no tiles are installed."""
+        monkeypatch.setattr("src.api.geocoding_routes.get_elevation_service", ElevationService)
+        response = test_client.get("/api/v1/elevation", params={"lat": 51.5, "lon": -0.12})
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+
+class TestMaritimeZoneRoute:
+    """Test GET /api/v1/maritime-zone."""
+
+    @pytest.fixture
+    def maritime(self, reverse_service, monkeypatch):
+        """A UK EEZ over the North Sea east of the toy UK boundary."""
+        zone = MaritimeZone(
+            zone_type=ZoneType.EEZ, name="United Kingdom EEZ", sovereign="GBR",
+            territory="GBR", mrgid=5696, polygon_type="200NM",
+            geometry=geometry_from_geojson(_box(2, 50, 4, 59)),
+        )
+        monkeypatch.setattr(
+            "src.api.geocoding_routes.get_maritime_zone_service",
+            lambda: MaritimeZoneService([zone]),
+        )
+
+    def test_eez(self, test_client, maritime):
+        """Should return the EEZ containing the coordinate."""
+        response = test_client.get("/api/v1/maritime-zone", params={"lat": 55.0, "lon": 3.0})
+        assert response.status_code == 200
+        body = response.json()
+        assert body["zone_type"] == "eez"
+        assert body["sovereign"] == "GBR"
+
+    def test_high_seas(self, test_client, maritime):
+        """Should report sea outside every zone as high seas."""
+        response = test_client.get("/api/v1/maritime-zone", params={"lat": 45.0, "lon": -30.0})
+        assert response.status_code == 200
+        assert response.json()["zone_type"] == "high_seas"
+        assert response.json()["name"] is None
+
+    def test_land_is_404(self, test_client, maritime):
+        """Should not report points inside a country boundary as high seas."""
+        response = test_client.get("/api/v1/maritime-zone", params={"lat": 51.5, "lon": -0.12})
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_no_data_is_503(self, test_client, monkeypatch):
+        """Should return 503 when no maritime boundaries are loaded."""
+        monkeypatch.setattr(
+            "src.api.geocoding_routes.get_maritime_zone_service", lambda: MaritimeZoneService()
+        )
+        response = test_client.get("/api/v1/maritime-zone", params={"lat": 55.0, "lon": 3.0})
+        assert response.status_code == 503
+
+
+class TestTimezoneRoute:
+    """Test GET /api/v1/timezone."""
+
+    def test_timezone_lookup(self, test_client, monkeypatch):
+        """Should return the offset for the given instant."""
+        monkeypatch.setattr(
+            "src.api.geocoding_routes.get_timezone_service", lambda: TimezoneService([])
+        )
+        response = test_client.get(
+            "/api/v1/timezone",
+            params={"lat": 0.0, "lon": 45.0, "at": "2026-07-01T12:00:00Z"},
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert body["tzid"] == "Etc/GMT-3"
+        assert body["utc_offset_seconds"] == 3 * 3600
+        assert body["source"] == "nautical"
+
+    def test_out_of_range_is_400(self, test_client, monkeypatch):
+        """Should reject coordinates out of range."""
+        monkeypatch.setattr(
+            "src.api.geocoding_routes.get_timezone_service", lambda: TimezoneService([])
+        )
+        response = test_client.get("/api/v1/timezone", params={"lat": 91.0, "lon": 0.0})
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_dataset_problem_is_503(self, test_client, monkeypatch):
+        """Should return 503, not 400, when the dataset names an unknown zone."""
+        monkeypatch.setattr("src.api.geocoding_routes.get_timezone_service", _broken_timezones)
+        response = test_client.get("/api/v1/timezone", params={"lat": 51.5, "lon": -0.12})
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+
+class TestGeocodeRoute:
+    """Test GET /api/v1/geocode."""
+
+    def test_geocode_fails_over(self, test_client, monkeypatch):
+        """Should answer from the first provider that returns candidates."""
+        _use_providers(
+            monkeypatch,
+            StaticProvider("nominatim", error="timed out after 10s"),
+            StaticProvider("google", [GeocodeCandidate(
+                formatted_address="10 Downing St, London",
+                latitude=51.5034, longitude=-0.1276, confidence=0.9, provider="google",
+            )]),
+        )
+        response = test_client.get("/api/v1/geocode", params={"q": "10 Downing St"})
+        assert response.status_code == 200
+        body = response.json()
+        assert body["provider"] == "google"
+        assert body["candidates"][0]["formatted_address"] == "10 Downing St, London"
+
+    def test_blank_query_is_400(self, test_client, monkeypatch):
+        """Should reject a blank query."""
+        _use_providers(monkeypatch, StaticProvider("nominatim"))
+        response = test_client.get("/api/v1/geocode", params={"q": "  "})
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_all_providers_failed_is_502(self, test_client, monkeypatch):
+        """Should return 502 when every provider errors."""
+        _use_providers(
+            monkeypatch,
+            StaticProvider("nominatim", error="down"),
+            StaticProvider("google", error="down"),
+        )
+        response = test_client.get("/api/v1/geocode", params={"q": "10 Downing St"})
+        assert response.status_code == 502
+        assert response.json()["detail"]["error_code"] == "E005"
+
+    def test_provider_status(self, test_client, monkeypatch):
+        """Should report each provider's circuit state in failover order."""
+        _use_providers(monkeypatch, StaticProvider("nominatim"), StaticProvider("google"))
+        response = test_client.get("/api/v1/geocode/providers")
+        assert response.status_code == 200
+        assert [(p["name"], p["circuit"]) for p in response.json()] == [
+            ("nominatim", "closed"), ("google", "closed"),
+        ]
@@ -0,0 +1,435 @@
+"""Unit tests for forward geocoding providers and the provider chain."""
+import asyncio
+import sys
+import types
+
+import pytest
+
+from src.services.geocoding_service import (
+    CircuitBreaker,
+    DailyBudget,
+    GeocodeCandidate,
+    GeocodeQuery,
+    GeocodingProvider,
+    GeocodingProviderError,
+    GeocodingRateLimitError,
+    GoogleGeocodingProvider,
+    NominatimProvider,
+    ProviderChain,
+    fetch_json,
+    parse_daily_budgets,
+)
+
+NOMINATIM_RESULT = [{
+    "lat": "51.5034878",
+    "lon": "-0.1276965",
+    "display_name": "10 Downing Street, Westminster, London, SW1A 2AA, United Kingdom",
+    "importance": 0.6201,
+    "boundingbox": ["51.5033", "51.5035", "-0.1277", "-0.1275"],
+    "address": {
+        "house_number": "10",
+        "road": "Downing Street",
+        "town": "London",
+        "postcode": "SW1A 2AA",
+        "country": "United Kingdom",
+        "country_code": "gb",
+    },
+}]
+
+GOOGLE_RESULT = {
+    "status": "OK",
+    "results": [{
+        "formatted_address": "10 Downing St, London SW1A 2AA, UK",
+        "partial_match": True,
+        "geometry": {
+            "location": {"lat": 51.5033, "lng": -0.1276},
+            "location_type": "ROOFTOP",
+            "viewport": {
+                "southwest": {"lat": 51.50, "lng": -0.13},
+                "northeast": {"lat": 51.51, "lng": -0.12},
+            },
+        },
+        "address_components": [
+            {"long_name": "10", "short_name": "10", "types": ["street_number"]},
+            {"long_name": "London", "short_name": "London", "types": ["postal_town"]},
+            {"long_name": "United Kingdom", "short_name": "GB", "types": ["country", "political"]},
+        ],
+    }],
+}
+
+
+class RecordingFetch:
+    """Fake fetch that records requests and returns a canned body."""
+
+    def __init__(self, body):
+        self.body = body
+        self.calls = []
+
+    async def __call__(self, url, params, headers):
+        self.calls.append((url, params, headers))
+        if isinstance(self.body, Exception):
+            raise self.body
+        return self.body
+
+
+class StaticProvider(GeocodingProvider):
+    """Provider returning fixed candidates or raising."""
+
+    def __init__(self, name, candidates=None, error=None, error_type=GeocodingProviderError):
+        self.name = name
+        self.candidates = candidates or []
+        self.error = error
+        self.error_type = error_type
+        self.calls = 0
+
+    async def geocode(self, query):
+        self.calls += 1
+        if self.error:
+            raise self.error_type(self.error)
+        return list(self.candidates)
+
+
+class FakeClock:
+    """Manually advanced clock."""
+
+    def __init__(self, now=1767225600.0):  # 2026-01-01T00:00:00Z
+        self.now = now
+
+    def __call__(self):
+        return self.now
+
+
+@pytest.fixture
+def timing_out_aiohttp(monkeypatch):
+    """Stub aiohttp whose requests hit the client timeout."""
+    module = types.ModuleType("aiohttp")
+
+    class ClientError(Exception):
+        pass
+
+    class ClientTimeout:
+        def __init__(self, total=None):
+            self.total = total
+
+    class _Request:
+        async def __aenter__(self):
+            raise asyncio.TimeoutError()
+
+        async def __aexit__(self, *exc):
+            return False
+
+    class ClientSession:
+        def __init__(self, headers=None):
+            pass
+
+        async def __aenter__(self):
+            return self
+
+        async def __aexit__(self, *exc):
+            return False
+
+        def get(self, url, params=None, timeout=None):
+            return _Request()
+
+    module.ClientError = ClientError
+    module.ClientTimeout = ClientTimeout
+    module.ClientSession = ClientSession
+    monkeypatch.setitem(sys.modules, "aiohttp", module)
+    return module
+
+
+def _candidate(provider, confidence):
+    return GeocodeCandidate(
+        formatted_address=f"{provider} {confidence}",
+        latitude=0.0, longitude=0.0, confidence=confidence, provider=provider,
+    )
+
+
+class TestNominatimProvider:
+    """Test Nominatim response normalization."""
+
+    async def test_normalizes_result(self):
+        """Nominatim results should map to normalized candidates."""
+        fetch = RecordingFetch(NOMINATIM_RESULT)
+        provider = NominatimProvider(user_agent="test-agent", fetch=fetch)
+        candidates = await provider.geocode(GeocodeQuery("10 Downing Street", language="en"))
+
+        candidate = candidates[0]
+        assert candidate.latitude == pytest.approx(51.5034878)
+        assert candidate.confidence == pytest.approx(0.62)
+        assert candidate.country_code == "GB"
+        assert candidate.components["city"] == "London"
+        assert candidate.bbox == [-0.1277, 51.5033, -0.1275, 51.5035]
+
+        url, params, headers = fetch.calls[0]
+        assert url.endswith("/search")
+        assert params["q"] == "10 Downing Street"
+        assert headers == {"User-Agent": "test-agent", "Accept-Language": "en"}
+
+    async def test_country_filter(self):
+        """Country codes should be passed as lowercase countrycodes."""
+        fetch = RecordingFetch([])
+        provider = NominatimProvider(fetch=fetch)
+        assert await provider.geocode(GeocodeQuery("x", country_codes=["GB", "IE"])) == []
+        assert fetch.calls[0][1]["countrycodes"] == "gb,ie"
+
+
+    async def test_malformed_result(self):
+        """Items without coordinates should raise GeocodingProviderError."""
+        provider = NominatimProvider(fetch=RecordingFetch([{"display_name": "x"}]))
+        with pytest.raises(GeocodingProviderError, match="Malformed"):
+            await provider.geocode(GeocodeQuery("x"))
+
+    async def test_non_numeric_coordinates(self):
+        """Unparseable coordinates should be a provider error, not a ValueError."""
+        body = [{"lat": "north", "lon": "0", "display_name": "x"}]
+        provider = NominatimProvider(fetch=RecordingFetch(body))
+        with pytest.raises(GeocodingProviderError):
+            await provider.geocode(GeocodeQuery("x"))
+
+
+class TestGoogleGeocodingProvider:
+    """Test Google response normalization."""
+
+    async def test_normalizes_result(self):
+        """Google results should map to normalized candidates."""
+        fetch = RecordingFetch(GOOGLE_RESULT)
+        provider = GoogleGeocodingProvider("key", fetch=fetch)
+        candidates = await provider.geocode(GeocodeQuery("10 Downing St", country_codes=["gb"]))
+
+        candidate = candidates[0]
+        assert candidate.longitude == pytest.approx(-0.1276)
+        # ROOFTOP (1.0) reduced for a partial match
+        assert candidate.confidence == pytest.approx(0.8)
+        assert candidate.country_code == "GB"
+        assert candidate.components == {
+            "house_number": "10", "city": "London", "country": "United Kingdom",
+        }
+        assert candidate.bbox == [-0.13, 51.50, -0.12, 51.51]
+        assert fetch.calls[0][1]["components"] == "country:GB"
+
+    async def test_zero_results(self):
+        """ZERO_RESULTS should return no candidates."""
+        provider = GoogleGeocodingProvider("key", fetch=RecordingFetch({"status": "ZERO_RESULTS"}))
+        assert await provider.geocode(GeocodeQuery("nowhere")) == []
+
+    async def test_error_status(self):
+        """Non-OK statuses should raise GeocodingProviderError."""
+        body = {"status": "REQUEST_DENIED", "error_message": "Invalid key"}
+        provider = GoogleGeocodingProvider("key", fetch=RecordingFetch(body))
+        with pytest.raises(GeocodingProviderError, match="REQUEST_DENIED"):
+            await provider.geocode(GeocodeQuery("x"))
+
+    async def test_quota_status_is_rate_limit(self):
+        """OVER_QUERY_LIMIT should raise GeocodingRateLimitError."""
+        provider = GoogleGeocodingProvider("key", fetch=RecordingFetch({"status": "OVER_QUERY_LIMIT"}))
+        with pytest.raises(GeocodingRateLimitError):
+            await provider.geocode(GeocodeQuery("x"))
+
+
+    async def test_malformed_viewport(self):
+        """A viewport missing a corner should raise GeocodingProviderError."""
+        body = {"status": "OK", "results": [{
+            "geometry": {"location": {"lat": 1, "lng": 2}, "viewport": {"northeast": {}}},
+        }]}
+        provider = GoogleGeocodingProvider("key", fetch=RecordingFetch(body))
+        with pytest.raises(GeocodingProviderError, match="Malformed"):
+            await provider.geocode(GeocodeQuery("x"))
+
+
+class TestFetchJson:
+    """Test transport error handling."""
+
+    async def test_timeout_converted(self, timing_out_aiohttp):
+        """Client timeouts should raise GeocodingProviderError."""
+        with pytest.raises(GeocodingProviderError, match="timed out"):
+            await fetch_json("https://geocoder.test/search", {}, {}, timeout_seconds=0.1)
+
+
+class TestProviderChain:
+    """Test failover and ranking."""
+
+    async def test_first_provider_answers(self):
+        """The first provider with candidates should answer."""
+        primary = StaticProvider("primary", [_candidate("primary", 0.3), _candidate("primary", 0.9)])
+        secondary = StaticProvider("secondary", [_candidate("secondary", 1.0)])
+        result = await ProviderChain([primary, secondary]).geocode(GeocodeQuery("x"))
+
+        assert result.provider == "primary"
+        assert [c.confidence for c in result.candidates] == [0.9, 0.3]
+        assert secondary.calls == 0
+
+    async def test_failover_on_error(self):
+        """A failing provider should hand over to the next one."""
+        chain = ProviderChain([
+            StaticProvider("primary", error="timeout"),
+            StaticProvider("secondary", [_candidate("secondary", 0.5)]),
+        ])
+        result = await chain.geocode(GeocodeQuery("x"))
+        assert result.provider == "secondary"
+
+    async def test_failover_on_timeout(self, timing_out_aiohttp):
+        """A provider whose request times out should hand over to the next one."""
+        chain = ProviderChain([
+            NominatimProvider(),
+            StaticProvider("secondary", [_candidate("secondary", 0.5)]),
+        ])
+        result = await chain.geocode(GeocodeQuery("x"))
+        assert result.provider == "secondary"
+
+    async def test_failover_on_empty(self):
+        """A provider with no candidates should hand over to the next one."""
+        chain = ProviderChain([
+            StaticProvider("primary"),
+            StaticProvider("secondary", [_candidate("secondary", 0.5)]),
+        ])
+        assert (await chain.geocode(GeocodeQuery("x"))).provider == "secondary"
+
+    async def test_limit_applied(self):
+        """Candidates should be truncated to the limit."""
+        chain = ProviderChain([
+            StaticProvider("primary", [_candidate("primary", c / 10) for c in range(10)]),
+        ])
+        result = await chain.geocode(GeocodeQuery("x", limit=3))
+        assert len(result.candidates) == 3
+
+    async def test_all_failed(self):
+        """If every provider errors the chain should raise."""
+        chain = ProviderChain([StaticProvider("a", error="down"), StaticProvider("b", error="down")])
+        with pytest.raises(GeocodingProviderError, match="All geocoding providers failed"):
+            await chain.geocode(GeocodeQuery("x"))
+
+    async def test_no_matches(self):
+        """If providers answer with nothing the result should be empty."""
+        chain = ProviderChain([StaticProvider("a", error="down"), StaticProvider("b")])
+        result = await chain.geocode(GeocodeQuery("x"))
+        assert result.provider is None
+        assert result.candidates == []
+
+    async def test_empty_query_rejected(self):
+        """Blank queries should raise ValueError."""
+        with pytest.raises(ValueError, match="must not be empty"):
+            await ProviderChain([StaticProvider("a")]).geocode(GeocodeQuery("  "))
+
+
+class TestDailyBudget:
+    """Test per-day request caps."""
+
+    def test_exhausted_then_reset_next_utc_day(self):
+        """The budget should run out and reset at UTC midnight."""
+        clock = FakeClock()
+        budget = DailyBudget(2, clock)
+        budget.consume()
+        budget.consume()
+        assert budget.exhausted
+        clock.now += 86400
+        assert not budget.exhausted
+        assert budget.used == 0
+
+    def test_parse_budgets(self):
+        """Budgets should parse from provider:limit pairs, skipping bad entries."""
+        assert parse_daily_budgets("Nominatim:10000, google:2500,bad,x:y") == {
+            "nominatim": 10000, "google": 2500,
+        }
+
+
+class TestCircuitBreaker:
+    """Test circuit state transitions."""
+
+    def test_opens_after_threshold(self):
+        """Consecutive failures should open the circuit for the cooldown."""
+        clock = FakeClock()
+        breaker = CircuitBreaker(failure_threshold=2, cooldown_seconds=30, clock=clock)
+        breaker.record_failure()
+        assert breaker.allow()
+        breaker.record_failure()
+        assert breaker.state == CircuitBreaker.OPEN
+        assert not breaker.allow()
+        clock.now += 30
+        assert breaker.state == CircuitBreaker.HALF_OPEN
+
+    def test_single_trial_when_half_open(self):
+        """Only one trial should pass; its outcome decides the state."""
+        clock = FakeClock()
+        breaker = CircuitBreaker(failure_threshold=1, cooldown_seconds=30, clock=clock)
+        breaker.record_failure()
+        clock.now += 30
+        assert breaker.allow()
+        assert not breaker.allow()
+        breaker.record_failure()
+        assert breaker.state == CircuitBreaker.OPEN
+
+        clock.now += 30
+        assert breaker.allow()
+        breaker.record_success()
+        assert breaker.state == CircuitBreaker.CLOSED
+        assert breaker.failures == 0
+
+    def test_explicit_cooldown_opens_immediately(self):
+        """A rate-limit cooldown should open the circuit on the first failure."""
+        clock = FakeClock()
+        breaker = CircuitBreaker(failure_threshold=5, cooldown_seconds=30, clock=clock)
+        breaker.record_failure(cooldown_seconds=120)
+        clock.now += 60
+        assert breaker.state == CircuitBreaker.OPEN
+
+
+class TestProviderChainControls:
+    """Test budget caps and circuit breaking in the chain."""
+
+    async def test_budget_spent_fails_over(self):
+        """A provider over its daily budget should be skipped."""
+        clock = FakeClock()
+        primary = StaticProvider("primary", [_candidate("primary", 0.9)])
+        secondary = StaticProvider("secondary", [_candidate("secondary", 0.5)])
+        chain = ProviderChain([primary, secondary], daily_budgets={"primary": 1}, wall_clock=clock)
+
+        assert (await chain.geocode(GeocodeQuery("x"))).provider == "primary"
+        assert (await chain.geocode(GeocodeQuery("x"))).provider == "secondary"
+        assert primary.calls == 1
+
+        clock.now += 86400
+        assert (await chain.geocode(GeocodeQuery("x"))).provider == "primary"
+
+    async def test_open_circuit_skips_provider(self):
+        """A repeatedly failing provider should stop being called."""
+        clock = FakeClock()
+        primary = StaticProvider("primary", error="down")
+        secondary = StaticProvider("secondary", [_candidate("secondary", 0.5)])
+        chain = ProviderChain(
+            [primary, secondary], failure_threshold=2, cooldown_seconds=60, clock=clock
+        )
+        for _ in range(4):
+            assert (await chain.geocode(GeocodeQuery("x"))).provider == "secondary"
+        assert primary.calls == 2
+
+        clock.now += 60
+        primary.error = None
+        primary.candidates = [_candidate("primary", 0.9)]
+        assert (await chain.geocode(GeocodeQuery("x"))).provider == "primary"
+        assert chain.status()[0].circuit == CircuitBreaker.CLOSED
+
+    async def test_rate_limit_opens_circuit(self):
+        """A rate-limited provider should be skipped on the next request."""
+        primary = StaticProvider("primary", error="429", error_type=GeocodingRateLimitError)
+        secondary = StaticProvider("secondary", [_candidate("secondary", 0.5)])
+        chain = ProviderChain([primary, secondary], failure_threshold=5, clock=FakeClock())
+        await chain.geocode(GeocodeQuery("x"))
+        await chain.geocode(GeocodeQuery("x"))
+        assert primary.calls == 1
+
+    async def test_every_provider_skipped(self):
+        """The chain should raise when every provider is skipped."""
+        chain = ProviderChain([StaticProvider("a", [_candidate("a", 0.5)])], daily_budgets={"a": 0})
+        with pytest.raises(GeocodingProviderError, match="daily budget"):
+            await chain.geocode(GeocodeQuery("x"))
+
+    def test_status(self):
+        """Status should report each provider's circuit and budget."""
+        chain = ProviderChain(
+            [StaticProvider("a"), StaticProvider("b")], daily_budgets={"b": 100}
+        )
+        statuses = chain.status()
+        assert [(s.name, s.circuit, s.daily_budget, s.used_today) for s in statuses] == [
+            ("a", "closed", None, None), ("b", "closed", 100, 0),
+        ]
@@ -0,0 +1,169 @@
+"""Unit tests for geofence enter/exit/dwell alerts."""
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.services.geofence_alert_service import GeofenceAlertService
+from src.services.geofence_service import (
+    CompiledGeofenceCache,
+    GeofenceEngineCache,
+    GeofenceService,
+)
+
+DEPOT = {
+    "type": "Polygon",
+    "coordinates": [[[-0.13, 51.50], [-0.12, 51.50], [-0.12, 51.51], [-0.13, 51.51], [-0.13, 51.50]]],
+}
+INSIDE = (51.505, -0.125)
+OUTSIDE = (51.515, -0.125)
+T0 = datetime(2026, 3, 1, 10, 0, 0)
+
+
+@pytest.fixture
+def engine_cache():
+    """Engine cache private to the test."""
+    return GeofenceEngineCache()
+
+
+@pytest.fixture
+def depot(db_session, engine_cache):
+    """Stored depot geofence."""
+    return GeofenceService(
+        db_session, cache=CompiledGeofenceCache(8), engine_cache=engine_cache
+    ).create_geofence("Depot", DEPOT)
+
+
+def _service(db_session, engine_cache, debounce=0, dwell=0):
+    return GeofenceAlertService(
+        db_session, debounce_seconds=debounce, dwell_seconds=dwell, engine_cache=engine_cache
+    )
+
+
+def _report(service, point, seconds, entity="truck-17", tenant="acme"):
+    return service.observe(tenant, entity, point[0], point[1], T0 + timedelta(seconds=seconds))
+
+
+class TestEnterExit:
+    """Test immediate enter and exit alerts."""
+
+    def test_enter_then_exit(self, db_session, engine_cache, depot):
+        """Should alert on entering and on leaving the fence."""
+        service = _service(db_session, engine_cache)
+
+        entered = _report(service, INSIDE, 0)
+        assert entered.geofence_ids == [depot.geofence_id]
+        assert [(a.event, a.geofence_id) for a in entered.alerts] == [("enter", depot.geofence_id)]
+
+        assert _report(service, INSIDE, 10).alerts == []
+
+        left = _report(service, OUTSIDE, 20)
+        assert left.geofence_ids == []
+        assert [a.event for a in left.alerts] == ["exit"]
+        assert left.alerts[0].occurred_at == T0 + timedelta(seconds=20)
+
+    def test_outside_reports_are_quiet(self, db_session, engine_cache, depot):
+        """Should not alert for an entity that never entered."""
+        service = _service(db_session, engine_cache)
+        assert _report(service, OUTSIDE, 0).alerts == []
+        assert _report(service, OUTSIDE, 10).alerts == []
+
+    def test_entities_and_tenants_are_separate(self, db_session, engine_cache, depot):
+        """Should track membership per tenant and entity."""
+        service = _service(db_session, engine_cache)
+        _report(service, INSIDE, 0)
+
+        assert len(_report(service, INSIDE, 5, entity="truck-18").alerts) == 1
+        assert len(_report(service, INSIDE, 5, tenant="globex").alerts) == 1
+
+    def test_out_of_order_report_is_ignored(self, db_session, engine_cache, depot):
+        """Should not evaluate a report older than the last one."""
+        service = _service(db_session, engine_cache)
+        _report(service, INSIDE, 60)
+
+        late = _report(service, OUTSIDE, 0)
+        assert late.stale is True
+        assert late.alerts == []
+        assert _report(service, INSIDE, 70).alerts == []
+
+    def test_invalid_input(self, db_session, engine_cache, depot):
+        """Should reject an empty entity ID or an out-of-range coordinate."""
+        service = _service(db_session, engine_cache)
+        with pytest.raises(ValueError):
+            service.observe("acme", " ", *INSIDE)
+        with pytest.raises(ValueError):
+            service.observe("acme", "truck-17", 91.0, 0.0)
+
+
+class TestDebounce:
+    """Test debounced membership changes."""
+
+    def test_transient_breach_is_absorbed(self, db_session, engine_cache, depot):
+        """Should not alert when the entity leaves before the debounce expires."""
+        service = _service(db_session, engine_cache, debounce=30)
+
+        assert _report(service, INSIDE, 0).alerts == []
+        assert _report(service, OUTSIDE, 10).alerts == []
+        assert _report(service, INSIDE, 20).alerts == []
+
+    def test_confirmed_change_is_dated_to_its_start(self, db_session, engine_cache, depot):
+        """Should alert once the change persists, dated to when it began."""
+        service = _service(db_session, engine_cache, debounce=30)
+
+        assert _report(service, INSIDE, 0).alerts == []
+        confirmed = _report(service, INSIDE, 30)
+        assert [a.event for a in confirmed.alerts] == ["enter"]
+        assert confirmed.alerts[0].occurred_at == T0
+
+        assert _report(service, OUTSIDE, 40).alerts == []
+        left = _report(service, OUTSIDE, 75)
+        assert [a.event for a in left.alerts] == ["exit"]
+        assert left.alerts[0].occurred_at == T0 + timedelta(seconds=40)
+
+
+class TestDwell:
+    """Test dwell alerts."""
+
+    def test_dwell_fires_once_per_stay(self, db_session, engine_cache, depot):
+        """Should raise one dwell alert after the dwell time and again after re-entering."""
+        service = _service(db_session, engine_cache, dwell=300)
+
+        _report(service, INSIDE, 0)
+        assert _report(service, INSIDE, 120).alerts == []
+        dwell = _report(service, INSIDE, 320)
+        assert [a.event for a in dwell.alerts] == ["dwell"]
+        assert dwell.alerts[0].occurred_at == T0 + timedelta(seconds=300)
+        assert _report(service, INSIDE, 900).alerts == []
+
+        _report(service, OUTSIDE, 1000)
+        _report(service, INSIDE, 1100)
+        assert [a.event for a in _report(service, INSIDE, 1400).alerts] == ["dwell"]
+
+
+class TestAlertHistory:
+    """Test stored alerts."""
+
+    def test_newest_first_with_filters(self, db_session, engine_cache, depot):
+        """Should list the tenant's alerts newest first, filtered by entity and time."""
+        service = _service(db_session, engine_cache)
+        _report(service, INSIDE, 0)
+        _report(service, OUTSIDE, 60)
+        _report(service, INSIDE, 30, entity="truck-18")
+
+        assert [a.event for a in service.alerts("acme", entity_id="truck-17")] == ["exit", "enter"]
+        assert len(service.alerts("acme", since=T0 + timedelta(seconds=30))) == 2
+        assert len(service.alerts("acme", geofence_id=depot.geofence_id)) == 3
+        assert service.alerts("globex") == []
+
+    def test_pages(self, db_session, engine_cache, depot):
+        """Should page through alerts with cursors, oldest last."""
+        service = _service(db_session, engine_cache)
+        _report(service, INSIDE, 0)
+        _report(service, OUTSIDE, 60)
+        _report(service, INSIDE, 120)
+
+        first = service.alert_page("acme", limit=2)
+        assert [a.event for a in first.items] == ["enter", "exit"]
+        second = service.alert_page("acme", limit=2, cursor=first.next_cursor)
+        assert [a.event for a in second.items] == ["enter"]
+        assert second.items[0].occurred_at == T0
+        assert second.next_cursor is None
@@ -0,0 +1,408 @@
+"""Route tests for the geofence API."""
+import asyncio
+import json
+import struct
+
+import pytest
+
+from src.services.auth_service import TokenVerifier
+from src.services.idempotency_service import IdempotencyService, InMemoryIdempotencyStore
+
+
+DEPOT = {
+    "type": "Polygon",
+    "coordinates": [[[-0.13, 51.50], [-0.12, 51.50], [-0.12, 51.51],
+                     [-0.13, 51.51], [-0.13, 51.50]]],
+}
+
+
+@pytest.fixture
+def depot(db_client):
+    """Geofence created through the API."""
+    response = db_client.post(
+        "/api/v1/geofences",
+        json={"name": "Depot perimeter", "geometry": DEPOT, "properties": {"site": "north"}},
+    )
+    assert response.status_code == 201
+    return response.json()
+
+
+@pytest.fixture
+def idempotency(monkeypatch):
+    """Process-local idempotency keys, fresh for each test."""
+    service = IdempotencyService(InMemoryIdempotencyStore())
+    monkeypatch.setattr("src.api.idempotency.get_idempotency_service", lambda: service)
+    return service
+
+
+class TestGeofenceRoutes:
+    """Test the /api/v1/geofences endpoints."""
+
+    def test_create_and_fetch(self, db_client, depot):
+        """Should store the geofence with its S2 covering and return it by ID."""
+        assert depot["s2_cell_count"] > 0
+
+        response = db_client.get(f"/api/v1/geofences/{depot['geofence_id']}")
+        assert response.status_code == 200
+        assert response.json()["name"] == "Depot perimeter"
+        assert response.json()["properties"] == {"site": "north"}
+
+    def test_invalid_geometry_is_400(self, db_client):
+        """Should reject geometry that is not a polygon."""
+        response = db_client.post(
+            "/api/v1/geofences",
+            json={"name": "Point", "geometry": {"type": "Point", "coordinates": [0, 0]}},
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_idempotent_create(self, db_client, idempotency):
+        """Retries with the same Idempotency-Key should return the first geofence."""
+        body = {"name": "Yard", "geometry": DEPOT}
+        headers = {"Idempotency-Key": "6f1c2f9e-create-yard"}
+        first = db_client.post("/api/v1/geofences", json=body, headers=headers)
+        retry = db_client.post("/api/v1/geofences", json=body, headers=headers)
+        assert first.status_code == retry.status_code == 201
+        assert retry.json()["geofence_id"] == first.json()["geofence_id"]
+        assert retry.headers["Idempotent-Replayed"] == "true"
+
+        other = db_client.post("/api/v1/geofences", json={**body, "name": "Other"}, headers=headers)
+        assert other.status_code == 422
+        assert other.json()["detail"]["error_code"] == "E009"
+
+    def test_failed_create_releases_key(self, db_client, idempotency):
+        """A rejected request should not hold its Idempotency-Key."""
+        headers = {"Idempotency-Key": "retry-after-fix"}
+        point = {"name": "Yard", "geometry": {"type": "Point", "coordinates": [0, 0]}}
+        assert db_client.post("/api/v1/geofences", json=point, headers=headers).status_code == 400
+        assert db_client.post("/api/v1/geofences", json=point, headers=headers).status_code == 400
+
+    def test_unknown_geofence_is_404(self, db_client):
+        """Should return 404 for an unknown geofence ID."""
+        response = db_client.get("/api/v1/geofences/does-not-exist")
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    @pytest.mark.parametrize("lat,lon,inside", [
+        (51.505, -0.125, True),
+        (51.515, -0.125, False),
+    ])
+    def test_contains(self, db_client, depot, lat, lon, inside):
+        """Should report whether the coordinate lies inside the geofence."""
+        response = db_client.get(
+            f"/api/v1/geofences/{depot['geofence_id']}/contains",
+            params={"lat": lat, "lon": lon},
+        )
+        assert response.status_code == 200
+        assert response.json()["inside"] is inside
+
+    def test_contains_invalid_coordinate_is_400(self, db_client, depot):
+        """Should reject coordinates out of range."""
+        response = db_client.get(
+            f"/api/v1/geofences/{depot['geofence_id']}/contains",
+            params={"lat": 91.0, "lon": 0.0},
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_contains_unknown_geofence_is_404(self, db_client):
+        """Should return 404 when testing membership of an unknown geofence."""
+        response = db_client.get(
+            "/api/v1/geofences/does-not-exist/contains", params={"lat": 51.5, "lon": -0.12}
+        )
+        assert response.status_code == 404
+
+    def test_match(self, db_client, depot):
+        """Should list every geofence containing the coordinate."""
+        response = db_client.get("/api/v1/geofences/match", params={"lat": 51.505, "lon": -0.125})
+        assert response.status_code == 200
+        assert depot["geofence_id"] in response.json()["geofence_ids"]
+
+        response = db_client.get("/api/v1/geofences/match", params={"lat": 10.0, "lon": 10.0})
+        assert response.json()["geofence_ids"] == []
+
+    def test_match_invalid_coordinate_is_400(self, db_client):
+        """Should reject coordinates out of range rather than treat "match" as an ID."""
+        response = db_client.get("/api/v1/geofences/match", params={"lat": 91.0, "lon": 0.0})
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_fetch_in_web_mercator(self, db_client, depot):
+        """Should return the geometry converted to the requested CRS."""
+        response = db_client.get(
+            f"/api/v1/geofences/{depot['geofence_id']}", params={"crs": "EPSG:3857"}
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert body["crs"] == "EPSG:3857"
+        x, y = body["geometry"]["coordinates"][0][0]
+        assert x == pytest.approx(-14471.53, abs=0.01)
+        assert y == pytest.approx(6710219.08, abs=0.01)
+
+    def test_create_in_utm(self, db_client):
+        """Should store UTM geometry as WGS84 and echo it in the request CRS."""
+        ring = [[699000, 5710000], [699500, 5710000], [699500, 5710500],
+                [699000, 5710500], [699000, 5710000]]
+        response = db_client.post("/api/v1/geofences", json={
+            "name": "UTM site", "crs": "EPSG:32630",
+            "geometry": {"type": "Polygon", "coordinates": [ring]},
+        })
+        assert response.status_code == 201
+        body = response.json()
+        assert body["crs"] == "EPSG:32630"
+        assert body["geometry"]["coordinates"][0][0] == pytest.approx([699000, 5710000], abs=1e-3)
+
+        stored = db_client.get(f"/api/v1/geofences/{body['geofence_id']}").json()
+        assert stored["crs"] == "EPSG:4326"
+        lon, lat = stored["geometry"]["coordinates"][0][0]
+        assert lat == pytest.approx(51.506, abs=0.001)
+        assert lon == pytest.approx(-0.132, abs=0.001)
+
+    def test_fetch_as_geojson(self, db_client, depot):
+        """Should return an RFC 7946 Feature with the attributes as properties."""
+        response = db_client.get(
+            f"/api/v1/geofences/{depot['geofence_id']}", params={"format": "geojson"}
+        )
+        assert response.status_code == 200
+        assert response.headers["content-type"] == "application/geo+json"
+        body = response.json()
+        assert body["type"] == "Feature"
+        assert body["id"] == depot["geofence_id"]
+        assert body["bbox"] == [-0.13, 51.50, -0.12, 51.51]
+        assert body["geometry"] == DEPOT
+        assert body["properties"]["name"] == "Depot perimeter"
+        assert "crs" not in body and "crs" not in body["properties"]
+
+    def test_create_as_geojson(self, db_client):
+        """Creation should answer with the Feature when asked."""
+        response = db_client.post(
+            "/api/v1/geofences", params={"format": "geojson"}, json={"name": "Yard", "geometry": DEPOT}
+        )
+        assert response.status_code == 201
+        assert response.headers["content-type"] == "application/geo+json"
+        assert response.json()["properties"]["name"] == "Yard"
+
+    def test_geojson_in_other_crs_is_400(self, db_client, depot):
+        """GeoJSON output is WGS84 only."""
+        response = db_client.get(
+            f"/api/v1/geofences/{depot['geofence_id']}",
+            params={"format": "geojson", "crs": "EPSG:3857"},
+        )
+        assert response.status_code == 400
+        assert "RFC 7946" in response.json()["detail"]["error_message"]
+
+    def test_unknown_format_is_400(self, db_client, depot):
+        """Should list the supported formats."""
+        response = db_client.get(f"/api/v1/geofences/{depot['geofence_id']}", params={"format": "kml"})
+        assert response.status_code == 400
+        assert response.json()["detail"]["details"] == {"supported": ["json", "geojson"]}
+
+    def test_create_from_wkt(self, db_client):
+        """WKT geometry should be stored as GeoJSON, rings rewound counterclockwise."""
+        response = db_client.post("/api/v1/geofences", json={
+            "name": "Yard",
+            "wkt": "POLYGON ((-0.13 51.50, -0.13 51.51, -0.12 51.51, -0.12 51.50, -0.13 51.50))",
+        })
+        assert response.status_code == 201
+        assert response.json()["geometry"]["coordinates"][0] == [
+            [-0.12, 51.50], [-0.12, 51.51], [-0.13, 51.51], [-0.13, 51.50], [-0.12, 51.50]
+        ]
+
+    def test_create_from_ewkt_uses_srid(self, db_client):
+        """The EWKT SRID should stand for the crs field."""
+        response = db_client.post("/api/v1/geofences", json={
+            "name": "UTM site",
+            "wkt": "SRID=32630;POLYGON ((699000 5710000, 699500 5710000, 699500 5710500, 699000 5710000))",
+        })
+        assert response.status_code == 201
+        assert response.json()["crs"] == "EPSG:32630"
+
+    def test_create_from_wkb(self, db_client):
+        """Hex WKB geometry should be accepted."""
+        ring = DEPOT["coordinates"][0]
+        wkb = struct.pack("<BII", 1, 3, 1) + struct.pack("<I", len(ring))
+        wkb += b"".join(struct.pack("<dd", lon, lat) for lon, lat in ring)
+        response = db_client.post("/api/v1/geofences", json={"name": "Yard", "wkb": wkb.hex()})
+        assert response.status_code == 201
+        assert response.json()["geometry"] == DEPOT
+
+    @pytest.mark.parametrize("body", [
+        {"name": "Both", "geometry": DEPOT, "wkt": "POLYGON ((0 0, 1 0, 1 1, 0 0))"},
+        {"name": "None"},
+        {"name": "Open", "wkt": "POLYGON ((0 0, 1 0, 1 1, 0 1))"},
+        {"name": "Conflict", "crs": "EPSG:3857", "wkt": "SRID=4326;POLYGON ((0 0, 1 0, 1 1, 0 0))"},
+        {"name": "Not hex", "wkb": "zz"},
+    ])
+    def test_invalid_wkt_input_is_400(self, db_client, body):
+        """Should reject ambiguous, missing, malformed or contradictory geometry."""
+        response = db_client.post("/api/v1/geofences", json=body)
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_unsupported_crs_is_400(self, db_client, depot):
+        """Should reject unknown reference systems."""
+        response = db_client.get(
+            f"/api/v1/geofences/{depot['geofence_id']}", params={"crs": "EPSG:27700"}
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_match_with_elevation(self, db_client, depot, monkeypatch):
+        """Should add the terrain elevation when requested."""
+        monkeypatch.setattr(
+            "src.api.geofence_routes.lookup_elevation_m", lambda lat, lon: 21.0
+        )
+        response = db_client.get(
+            "/api/v1/geofences/match", params={"lat": 51.505, "lon": -0.125, "elevation": "true"}
+        )
+        assert response.json()["elevation_m"] == 21.0
+
+        response = db_client.get(
+            f"/api/v1/geofences/{depot['geofence_id']}/contains",
+            params={"lat": 51.505, "lon": -0.125},
+        )
+        assert response.json()["elevation_m"] is None
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API, with a test secret."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+def _auth(verifier, tenant_id="acme"):
+    return {"Authorization": f"Bearer {verifier.issue('user-1', tenant_id)}"}
+
+
+class FakeDispatcher:
+    """Records scheduled webhook deliveries."""
+
+    def __init__(self):
+        self.calls = []
+
+    def dispatch(self, targets, event, data):
+        self.calls.append(([t.url for t in targets], event, data))
+        return asyncio.sleep(0)
+
+
+@pytest.fixture
+def dispatcher(monkeypatch):
+    """Webhook dispatcher that delivers nothing."""
+    dispatcher = FakeDispatcher()
+    monkeypatch.setattr("src.api.geofence_routes.get_webhook_dispatcher", lambda: dispatcher)
+    return dispatcher
+
+
+def _position(db_client, verifier, lat, lon, timestamp, entity_id="truck-17"):
+    return db_client.post(
+        "/api/v1/geofences/positions",
+        json={"entity_id": entity_id, "latitude": lat, "longitude": lon, "timestamp": timestamp},
+        headers=_auth(verifier),
+    )
+
+
+class TestGeofenceImportRoute:
+    """Test POST /api/v1/geofences/import."""
+
+    def test_import(self, db_client, verifier, monkeypatch, tmp_path):
+        """Should import the valid rows and serve every row's outcome as job results."""
+        monkeypatch.setenv("BATCH_JOB_RESULT_DIR", str(tmp_path))
+        geometry = json.dumps(DEPOT).replace('"', '""')
+        upload = f'name,geometry\nDepot,"{geometry}"\nNowhere,\n'.encode("utf-8")
+
+        response = db_client.post(
+            "/api/v1/geofences/import",
+            files={"file": ("fences.csv", upload, "text/csv")},
+            headers=_auth(verifier),
+        )
+        assert response.status_code == 201
+        body = response.json()
+        assert (body["total_rows"], body["imported_rows"], body["failed_rows"]) == (2, 1, 1)
+        assert body["errors"] == [
+            {"index": 1, "error": {"error_code": "E002", "error_message": "geometry is required", "details": None}}
+        ]
+
+        result = db_client.get(body["result_url"], headers=_auth(verifier))
+        assert result.status_code == 200
+        created = json.loads(result.text.splitlines()[0])
+        fence = db_client.get(f"/api/v1/geofences/{created['geofence_id']}")
+        assert fence.json()["name"] == "Depot"
+        # Results belong to the importing tenant
+        assert db_client.get(body["result_url"], headers=_auth(verifier, "globex")).status_code == 404
+
+    def test_requires_token(self, db_client, verifier):
+        """Should require a bearer token."""
+        response = db_client.post(
+            "/api/v1/geofences/import", files={"file": ("fences.csv", b"name,geometry\n", "text/csv")}
+        )
+        assert response.status_code == 401
+
+    def test_missing_columns_is_400(self, db_client, verifier):
+        """Should reject a CSV without name and geometry columns."""
+        response = db_client.post(
+            "/api/v1/geofences/import",
+            files={"file": ("fences.csv", b"label\nDepot\n", "text/csv")},
+            headers=_auth(verifier),
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+
+class TestGeofenceAlertRoutes:
+    """Test position reports and geofence alerts."""
+
+    def test_requires_token(self, db_client, verifier):
+        """Should require a bearer token."""
+        response = db_client.post(
+            "/api/v1/geofences/positions",
+            json={"entity_id": "truck-17", "latitude": 51.505, "longitude": -0.125},
+        )
+        assert response.status_code == 401
+
+    def test_enter_and_exit_are_delivered(self, db_client, verifier, depot, dispatcher):
+        """Should return the alerts and schedule them for subscribed webhooks."""
+        created = db_client.post(
+            "/api/v1/webhooks",
+            json={"url": "https://hooks.example.com/geo", "events": ["geofence.enter"]},
+            headers=_auth(verifier),
+        )
+        assert created.status_code == 201
+
+        entered = _position(db_client, verifier, 51.505, -0.125, "2026-03-01T10:00:00Z")
+        assert entered.status_code == 200
+        body = entered.json()
+        assert body["geofence_ids"] == [depot["geofence_id"]]
+        assert [a["event"] for a in body["alerts"]] == ["enter"]
+
+        left = _position(db_client, verifier, 51.515, -0.125, "2026-03-01T10:05:00Z")
+        assert [a["event"] for a in left.json()["alerts"]] == ["exit"]
+
+        # Only the enter event has a subscriber
+        assert len(dispatcher.calls) == 1
+        urls, event, data = dispatcher.calls[0]
+        assert urls == ["https://hooks.example.com/geo"]
+        assert event == "geofence.enter"
+        assert data["geofence_id"] == depot["geofence_id"]
+        assert data["entity_id"] == "truck-17"
+
+    def test_list_alerts(self, db_client, verifier, depot, dispatcher):
+        """Should list the tenant's alerts newest first."""
+        _position(db_client, verifier, 51.505, -0.125, "2026-03-01T10:00:00Z")
+        _position(db_client, verifier, 51.515, -0.125, "2026-03-01T10:05:00Z")
+
+        response = db_client.get(
+            "/api/v1/geofences/alerts", params={"entity_id": "truck-17"}, headers=_auth(verifier)
+        )
+        assert response.status_code == 200
+        assert [a["event"] for a in response.json()["alerts"]] == ["exit", "enter"]
+
+        other = db_client.get("/api/v1/geofences/alerts", headers=_auth(verifier, "globex"))
+        assert other.json()["alerts"] == []
+
+    def test_invalid_position_is_400(self, db_client, verifier, depot):
+        """Should reject an entity ID that is only whitespace."""
+        response = _position(db_client, verifier, 51.505, -0.125, None, entity_id="  ")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
@@ -0,0 +1,291 @@
+"""Unit tests for geofence storage and S2-prefiltered membership."""
+import pytest
+
+from src.models.database_models import Geofence, GeofenceEntityState
+from datetime import datetime
+
+from src.services import geofence_service as geofence_module
+from src.services.geofence_service import (
+    CompiledGeofence,
+    CompiledGeofenceCache,
+    GeofenceEngine,
+    GeofenceEngineCache,
+    GeofenceService,
+)
+from src.spatial.geometry import ring_area
+
+SQUARE = {
+    "type": "Polygon",
+    "coordinates": [[[-0.13, 51.50], [-0.12, 51.50], [-0.12, 51.51], [-0.13, 51.51], [-0.13, 51.50]]],
+}
+
+
+@pytest.fixture
+def geofence_service(db_session):
+    """Geofence service with a small covering budget and its own caches."""
+    return GeofenceService(
+        db_session,
+        max_cells=32,
+        max_level=18,
+        cache=CompiledGeofenceCache(8),
+        engine_cache=GeofenceEngineCache(),
+    )
+
+
+def _square(min_lon, min_lat, size):
+    return {
+        "type": "Polygon",
+        "coordinates": [[
+            [min_lon, min_lat], [min_lon + size, min_lat], [min_lon + size, min_lat + size],
+            [min_lon, min_lat + size], [min_lon, min_lat],
+        ]],
+    }
+
+
+def _stored(geofence_id="fence-1", updated_at=None, max_cells=16, geometry=SQUARE):
+    """Unsaved Geofence model with a computed covering."""
+    from src.spatial import s2
+    from src.spatial.geometry import geometry_from_geojson
+
+    covering = s2.cover_geometry(geometry_from_geojson(geometry), max_cells=max_cells)
+    return Geofence(
+        geofence_id=geofence_id,
+        name="Depot",
+        geometry=geometry,
+        s2_covering=covering.tokens(),
+        s2_interior=covering.interior_tokens(),
+        updated_at=updated_at or datetime(2026, 3, 1),
+    )
+
+
+@pytest.fixture
+def parse_counter(monkeypatch):
+    """Count polygon parses done by compiled geofences."""
+    calls = []
+    original = geofence_module.geometry_from_geojson
+
+    def counting(geometry):
+        calls.append(geometry)
+        return original(geometry)
+
+    monkeypatch.setattr(geofence_module, "geometry_from_geojson", counting)
+    return calls
+
+
+class TestGeofenceService:
+    """Test geofence creation and containment."""
+
+    def test_create_stores_covering(self, geofence_service, db_session):
+        """Creating a geofence should persist its S2 covering."""
+        geofence = geofence_service.create_geofence("Depot", SQUARE, {"site": "north"})
+
+        stored = db_session.query(Geofence).filter_by(geofence_id=geofence.geofence_id).one()
+        assert stored.name == "Depot"
+        assert stored.properties == {"site": "north"}
+        assert 0 < len(stored.s2_covering) <= 32
+        assert set(stored.s2_interior) <= set(stored.s2_covering)
+
+    def test_invalid_geometry_rejected(self, geofence_service):
+        """Non-polygon geometry should raise ValueError."""
+        with pytest.raises(ValueError, match="Unsupported geometry type"):
+            geofence_service.create_geofence("Point", {"type": "Point", "coordinates": [0, 0]})
+
+    def test_rings_stored_counterclockwise(self, geofence_service):
+        """A clockwise exterior ring should be stored rewound."""
+        clockwise = {"type": "Polygon", "coordinates": [list(reversed(SQUARE["coordinates"][0]))]}
+        geofence = geofence_service.create_geofence("Depot", clockwise)
+        assert ring_area(geofence.geometry["coordinates"][0]) > 0
+        assert geofence_service.contains(geofence, 51.505, -0.125) is True
+
+    def test_projected_coordinates_rejected(self, geofence_service):
+        """Coordinates that are not longitude/latitude should raise ValueError."""
+        ring = [[699000, 5710000], [699500, 5710000], [699500, 5710500], [699000, 5710000]]
+        with pytest.raises(ValueError, match="not a WGS84"):
+            geofence_service.create_geofence("UTM", {"type": "Polygon", "coordinates": [ring]})
+
+    def test_contains(self, geofence_service):
+        """Membership should agree with the polygon."""
+        geofence = geofence_service.create_geofence("Depot", SQUARE)
+        assert geofence_service.contains(geofence, 51.505, -0.125) is True
+        assert geofence_service.contains(geofence, 51.515, -0.125) is False
+        assert geofence_service.contains(geofence, 10.0, 10.0) is False
+
+    def test_get_missing(self, geofence_service):
+        """Unknown IDs should return None."""
+        assert geofence_service.get_geofence("missing") is None
+
+    def test_contains_out_of_range(self, geofence_service):
+        """Out-of-range coordinates should raise ValueError."""
+        geofence = geofence_service.create_geofence("Depot", SQUARE)
+        with pytest.raises(ValueError, match="out of range"):
+            geofence_service.contains(geofence, 0.0, 200.0)
+
+    def test_update_replaces_geometry(self, geofence_service):
+        """An update should recompute the covering and be matched at once."""
+        geofence = geofence_service.create_geofence("Depot", SQUARE)
+        assert geofence_service.matching_geofences(51.505, -0.125) == [geofence.geofence_id]
+
+        moved = geofence_service.update_geofence(geofence, "Yard", _square(2.0, 48.0, 0.01), {"site": "paris"})
+
+        assert (moved.geofence_id, moved.name, moved.properties) == (geofence.geofence_id, "Yard", {"site": "paris"})
+        assert geofence_service.contains(moved, 51.505, -0.125) is False
+        assert geofence_service.contains(moved, 48.005, 2.005) is True
+        assert geofence_service.matching_geofences(51.505, -0.125) == []
+        assert geofence_service.matching_geofences(48.005, 2.005) == [geofence.geofence_id]
+
+    def test_update_invalid_geometry_keeps_fence(self, geofence_service):
+        """A rejected update should leave the stored geofence unchanged."""
+        geofence = geofence_service.create_geofence("Depot", SQUARE)
+        with pytest.raises(ValueError):
+            geofence_service.update_geofence(geofence, "Point", {"type": "Point", "coordinates": [0, 0]})
+        assert geofence_service.get_geofence(geofence.geofence_id).name == "Depot"
+
+    def test_delete(self, geofence_service, db_session):
+        """Deleting should drop the fence and entity membership of it."""
+        geofence = geofence_service.create_geofence("Depot", SQUARE)
+        db_session.add(GeofenceEntityState(
+            tenant_id="acme", entity_id="truck-1", geofence_id=geofence.geofence_id,
+            inside=True, entered_at=datetime(2026, 3, 1), last_seen_at=datetime(2026, 3, 1),
+        ))
+        db_session.commit()
+
+        assert geofence_service.delete_geofence(geofence.geofence_id) is True
+        assert geofence_service.get_geofence(geofence.geofence_id) is None
+        assert db_session.query(GeofenceEntityState).count() == 0
+        assert geofence_service.matching_geofences(51.505, -0.125) == []
+        assert geofence_service.delete_geofence(geofence.geofence_id) is False
+
+    def test_geofence_page(self, geofence_service):
+        """Geofences should be paged newest first."""
+        ids = [geofence_service.create_geofence(f"Fence {i}", SQUARE).geofence_id for i in range(3)]
+        first = geofence_service.geofence_page(limit=2)
+        assert [g.geofence_id for g in first.items] == ids[::-1][:2]
+        rest = geofence_service.geofence_page(limit=2, cursor=first.next_cursor)
+        assert [g.geofence_id for g in rest.items] == [ids[0]]
+        assert rest.next_cursor is None
+
+
+class TestCompiledGeofence:
+    """Test the compiled in-memory geofence."""
+
+    def test_from_model(self):
+        """Compiling a stored fence should restore its covering."""
+        from src.spatial import s2
+        from src.spatial.geometry import geometry_from_geojson
+
+        covering = s2.cover_geometry(geometry_from_geojson(SQUARE), max_cells=16)
+        model = Geofence(
+            geofence_id="fence-1",
+            name="Depot",
+            geometry=SQUARE,
+            s2_covering=covering.tokens(),
+            s2_interior=covering.interior_tokens(),
+        )
+        compiled = CompiledGeofence.from_model(model)
+        assert len(compiled.covering) == len(covering.cells)
+        assert compiled.contains(51.505, -0.125) is True
+        assert compiled.contains(51.4, -0.125) is False
+
+    def test_geometry_parsed_lazily(self, parse_counter):
+        """Points decided by the covering should never parse the polygon."""
+        compiled = CompiledGeofence.from_model(_stored())
+        assert compiled.contains(10.0, 10.0) is False
+        assert parse_counter == []
+
+    def test_geometry_parsed_once(self, parse_counter):
+        """Boundary-cell points should parse the polygon once and reuse it."""
+        compiled = CompiledGeofence.from_model(_stored(max_cells=4))
+        boundary_points = [(51.5001, -0.1299), (51.5099, -0.1201), (51.5002, -0.1202)]
+        for lat, lon in boundary_points:
+            assert compiled.contains(lat, lon) is True
+        assert len(parse_counter) <= 1
+
+
+class TestCompiledGeofenceCache:
+    """Test caching of compiled geofences."""
+
+    def test_reuses_compiled_fence(self):
+        """Repeated lookups of an unchanged fence should hit the cache."""
+        cache = CompiledGeofenceCache(4)
+        stored = _stored()
+        assert cache.get(stored) is cache.get(stored)
+        assert len(cache) == 1
+
+    def test_recompiles_changed_fence(self):
+        """A fence with a new updated_at should be recompiled."""
+        cache = CompiledGeofenceCache(4)
+        first = cache.get(_stored(updated_at=datetime(2026, 3, 1)))
+        second = cache.get(_stored(updated_at=datetime(2026, 3, 2)))
+        assert first is not second
+        assert len(cache) == 1
+
+    def test_invalidate_and_evict(self):
+        """Invalidated and least recently used fences should be dropped."""
+        cache = CompiledGeofenceCache(2)
+        a, b, c = _stored("a"), _stored("b"), _stored("c")
+        compiled_a = cache.get(a)
+        cache.get(b)
+        cache.get(a)
+        cache.get(c)
+        assert len(cache) == 2
+        assert cache.get(a) is compiled_a
+
+        cache.invalidate("a")
+        assert cache.get(a) is not compiled_a
+
+    def test_service_uses_cache(self, geofence_service, parse_counter):
+        """Containment checks on a created fence should reuse its compiled form."""
+        geofence = geofence_service.create_geofence("Depot", SQUARE)
+        for _ in range(5):
+            assert geofence_service.contains(geofence, 51.505, -0.125) is True
+        assert parse_counter == []
+        assert len(geofence_service.cache) == 1
+
+
+class TestGeofenceEngine:
+    """Test evaluating a point against many geofences."""
+
+    def test_matches_brute_force(self):
+        """Matches should equal testing every fence's polygon directly."""
+        from src.spatial.geometry import geometry_from_geojson
+
+        stored = [
+            _stored(f"fence-{i}-{j}", geometry=_square(i * 0.5, j * 0.5, 0.8))
+            for i in range(5)
+            for j in range(5)
+        ]
+        engine = GeofenceEngine([CompiledGeofence.from_model(g) for g in stored])
+        assert len(engine) == 25
+
+        for lat, lon in [(0.1, 0.1), (0.6, 0.6), (1.3, 2.1), (2.9, 2.9), (5.0, 5.0)]:
+            expected = sorted(
+                g.geofence_id for g in stored
+                if geometry_from_geojson(g.geometry).contains(lon, lat)
+            )
+            assert engine.match(lat, lon) == expected
+        assert engine.match(0.6, 0.6) == ["fence-0-0", "fence-0-1", "fence-1-0", "fence-1-1"]
+
+    def test_far_points_do_not_parse(self, parse_counter):
+        """Points outside every bounding box should not parse any polygon."""
+        engine = GeofenceEngine([CompiledGeofence.from_model(_stored(max_cells=4))])
+        assert engine.match(10.0, 10.0) == []
+        assert parse_counter == []
+
+    def test_out_of_range(self):
+        """Out-of-range coordinates should raise ValueError."""
+        with pytest.raises(ValueError, match="out of range"):
+            GeofenceEngine([]).match(95.0, 0.0)
+
+    def test_service_rebuilds_on_change(self, geofence_service):
+        """New geofences should be matched without restarting."""
+        assert geofence_service.matching_geofences(51.505, -0.125) == []
+        first = geofence_service.create_geofence("Depot", SQUARE)
+        assert geofence_service.matching_geofences(51.505, -0.125) == [first.geofence_id]
+
+        engine = geofence_service.engine_cache.get(geofence_service.session)
+        assert geofence_service.engine_cache.get(geofence_service.session) is engine
+
+        second = geofence_service.create_geofence("Yard", SQUARE)
+        assert geofence_service.matching_geofences(51.505, -0.125) == sorted(
+            [first.geofence_id, second.geofence_id]
+        )
@@ -0,0 +1,88 @@
+"""Unit tests for geohash encoding, decoding and neighbors."""
+import pytest
+
+from src.spatial import geohash
+
+
+class TestEncodeDecode:
+    """Test geohash encoding and decoding."""
+
+    def test_known_value(self):
+        """Encoding should match the reference geohash."""
+        assert geohash.encode(57.64911, 10.40744, 11) == "u4pruydqqvj"
+        assert geohash.encode(51.50734, -0.12772, 7) == "gcpvj0d"
+
+    def test_decode_round_trip(self):
+        """Decoded centers should be within the error margin of the input."""
+        for lat, lon in [(0.0, 0.0), (-33.8688, 151.2093), (89.9, -179.9), (40.7128, -74.006)]:
+            code = geohash.encode(lat, lon, 9)
+            dec_lat, dec_lon, lat_err, lon_err = geohash.decode(code)
+            assert abs(dec_lat - lat) <= lat_err
+            assert abs(dec_lon - lon) <= lon_err
+
+    def test_prefix_contains_point(self):
+        """Every prefix of a geohash should contain the encoded point."""
+        code = geohash.encode(48.8584, 2.2945, 12)
+        for length in range(1, 13):
+            assert geohash.decode_bbox(code[:length]).contains(2.2945, 48.8584)
+
+    def test_case_insensitive(self):
+        """Uppercase geohashes should decode like lowercase ones."""
+        assert geohash.decode("GCPVJ0D") == geohash.decode("gcpvj0d")
+
+    @pytest.mark.parametrize("value", ["", "abc", "gcpvj0d123456", "gcp!"])
+    def test_invalid_geohash(self, value):
+        """Invalid characters and lengths should raise ValueError."""
+        with pytest.raises(ValueError):
+            geohash.validate(value)
+
+    def test_invalid_precision(self):
+        """Precision outside 1-12 should raise ValueError."""
+        with pytest.raises(ValueError, match="Precision"):
+            geohash.encode(0, 0, 13)
+
+
+class TestNeighbors:
+    """Test neighbor computation."""
+
+    def test_neighbors(self):
+        """Neighbors should match reference values."""
+        result = geohash.neighbors("u4pruydqqvj")
+        assert result["n"] == "u4pruydqqvm"
+        assert result["s"] == "u4pruydqquv"
+        assert result["e"] == "u4pruydqqvn"
+        assert result["w"] == "u4pruydqqvh"
+        assert len(result) == 8
+
+    def test_antimeridian_wrap(self):
+        """East of the antimeridian should wrap to the western hemisphere."""
+        east_edge = geohash.encode(0.1, 179.99, 5)
+        wrapped = geohash.neighbor(east_edge, "e")
+        assert geohash.decode(wrapped)[1] < -179
+
+    def test_pole_clamped(self):
+        """North of the top row should return the same row."""
+        top = geohash.encode(89.99, 0.0, 3)
+        assert geohash.neighbor(top, "n") == top
+
+
+class TestPrefixQueries:
+    """Test helpers used for prefix bucketing."""
+
+    def test_prefix_range(self):
+        """Prefix ranges should bound all hashes with the prefix."""
+        start, end = geohash.prefix_range("gcp")
+        assert (start, end) == ("gcp", "gcq")
+        assert start <= "gcpvj0d" < end
+        assert not start <= "gcq0" < end
+
+    def test_prefix_range_carries(self):
+        """A trailing 'z' should carry into the parent character."""
+        assert geohash.prefix_range("u4z") == ("u4z", "u5")
+        assert geohash.prefix_range("z")[1] > "zzzzzzzzzzzz"
+
+    def test_precision_for_radius(self):
+        """Precision should give cells at least as large as the radius."""
+        assert geohash.precision_for_radius(10_000) == 4
+        assert geohash.precision_for_radius(100) == 7
+        assert geohash.precision_for_radius(10_000_000) == 1
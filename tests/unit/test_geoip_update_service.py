@@ -0,0 +1,300 @@
+"""Unit tests for the GeoIP dataset updater (download, verify, hot swap)."""
+import hashlib
+import io
+import os
+import tarfile
+import threading
+import pytest
+
+from src.config import Config
+from src.services import mmdb_service
+from src.services.geoip_update_service import (
+    DatasetRelease,
+    DatasetSource,
+    GeoIPUpdateService,
+    LocalFileSource,
+    build_update_service,
+    sha256_file,
+)
+from src.services.mmdb_service import MMDBReader, set_mmdb_reader
+from src.services.snapshot_service import DatasetSnapshotStore
+from tests.mmdb_writer import build_mmdb
+
+
+def _dataset(city: str, build_epoch: int) -> bytes:
+    return build_mmdb(
+        [("81.2.69.128/26", {"city": {"names": {"en": city}}})],
+        build_epoch=build_epoch,
+    )
+
+
+def _tar_gz(name: str, payload: bytes) -> bytes:
+    buffer = io.BytesIO()
+    with tarfile.open(fileobj=buffer, mode="w:gz") as archive:
+        info = tarfile.TarInfo(name)
+        info.size = len(payload)
+        archive.addfile(info, io.BytesIO(payload))
+    return buffer.getvalue()
+
+
+class FakeSource(DatasetSource):
+    """Release source serving in-memory payloads."""
+
+    def __init__(self, payload: bytes, archive: bool = False, sha256: str = None):
+        self.payload = payload
+        self.archive = archive
+        self.sha256 = sha256 or hashlib.sha256(payload).hexdigest()
+        self.downloads = 0
+
+    async def latest_release(self) -> DatasetRelease:
+        return DatasetRelease(version="v2", sha256=self.sha256, archive=self.archive)
+
+    async def download(self, release: DatasetRelease, dest_path: str) -> None:
+        self.downloads += 1
+        with open(dest_path, "wb") as f:
+            f.write(self.payload)
+
+
+@pytest.fixture
+def database_path(tmp_path):
+    """Path of an active dataset built in 2023."""
+    path = tmp_path / "GeoLite2-City.mmdb"
+    path.write_bytes(_dataset("Old London", 1700000000))
+    return str(path)
+
+
+@pytest.fixture
+def activated():
+    """Collects readers passed to the activation callback."""
+    readers = []
+    yield readers
+    for reader in readers:
+        reader.close()
+
+
+class TestCheckForUpdate:
+    """Test update checks and installation."""
+
+    async def test_installs_new_release(self, database_path, activated):
+        """A new release should replace the file and be activated."""
+        source = FakeSource(_dataset("New London", 1800000000))
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+
+        assert await updater.check_for_update() is True
+
+        assert len(activated) == 1
+        assert activated[0].build_epoch == 1800000000
+        assert activated[0].lookup("81.2.69.142").city_name == "New London"
+        assert updater.installed_checksum == source.sha256
+        with MMDBReader(database_path) as reader:
+            assert reader.build_epoch == 1800000000
+
+    async def test_outgoing_build_snapshotted(self, database_path, activated, tmp_path):
+        """The replaced build should be kept in the snapshot store."""
+        snapshots = DatasetSnapshotStore(str(tmp_path / "snapshots"))
+        source = FakeSource(_dataset("New London", 1800000000))
+        updater = GeoIPUpdateService(
+            source, database_path, activate=activated.append, snapshots=snapshots
+        )
+
+        assert await updater.check_for_update() is True
+
+        assert [s.build_epoch for s in snapshots.snapshots()] == [1700000000]
+        with MMDBReader(snapshots.snapshots()[0].path) as reader:
+            assert reader.lookup("81.2.69.142").city_name == "Old London"
+
+    async def test_activation_callbacks(self, database_path, activated):
+        """Callbacks should get the new reader, and their failures not fail the update."""
+        source = FakeSource(_dataset("New London", 1800000000))
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+        seen = []
+
+        def broken(reader):
+            raise RuntimeError("cache down")
+
+        updater.register_activation_callback(broken)
+        updater.register_activation_callback(seen.append)
+
+        assert await updater.check_for_update() is True
+        assert seen == activated
+
+    async def test_use_mmap_honoured(self, database_path, activated):
+        """Readers of an installed release should follow GEOIP_USE_MMAP."""
+        source = FakeSource(_dataset("New London", 1800000000))
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append, use_mmap=False)
+
+        assert await updater.check_for_update() is True
+        assert isinstance(activated[0]._buffer, bytes)
+
+    async def test_file_work_off_the_event_loop(self, database_path, activated, monkeypatch):
+        """Hashing, extraction and opening readers should run in worker threads."""
+        from src.services import geoip_update_service
+
+        threads = []
+
+        def recording(function):
+            def wrapper(*args, **kwargs):
+                threads.append((function.__name__, threading.current_thread() is threading.main_thread()))
+                return function(*args, **kwargs)
+            return wrapper
+
+        monkeypatch.setattr(geoip_update_service, "sha256_file", recording(sha256_file))
+        monkeypatch.setattr(geoip_update_service, "MMDBReader", recording(MMDBReader))
+        monkeypatch.setattr(
+            GeoIPUpdateService, "_extract_mmdb", staticmethod(recording(GeoIPUpdateService._extract_mmdb))
+        )
+        source = FakeSource(_tar_gz("GeoLite2-City.mmdb", _dataset("New London", 1800000000)), archive=True)
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+
+        assert await updater.check_for_update() is True
+        assert [name for name, _ in threads] == ["sha256_file", "_extract_mmdb", "MMDBReader", "MMDBReader"]
+        assert not any(on_loop for _, on_loop in threads)
+
+    async def test_unchanged_release_skipped(self, database_path, activated):
+        """A release matching the installed checksum should not be downloaded."""
+        source = FakeSource(_dataset("New London", 1800000000))
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+        await updater.check_for_update()
+
+        assert await updater.check_for_update() is False
+        assert source.downloads == 1
+        assert len(activated) == 1
+
+    async def test_checksum_mismatch_rejected(self, database_path, activated):
+        """A corrupted download should not replace the active dataset."""
+        source = FakeSource(_dataset("New London", 1800000000), sha256="0" * 64)
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+
+        with pytest.raises(RuntimeError, match="Checksum mismatch"):
+            await updater.check_for_update()
+
+        assert activated == []
+        with MMDBReader(database_path) as reader:
+            assert reader.build_epoch == 1700000000
+
+    async def test_invalid_database_rejected(self, database_path, activated):
+        """A download that is not an MMDB should not replace the active dataset."""
+        source = FakeSource(b"garbage")
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+
+        with pytest.raises(ValueError):
+            await updater.check_for_update()
+
+        assert activated == []
+        with MMDBReader(database_path) as reader:
+            assert reader.build_epoch == 1700000000
+
+    async def test_archive_release(self, database_path, activated):
+        """tar.gz releases should have their .mmdb member extracted."""
+        payload = _tar_gz(
+            "GeoLite2-City_20260210/GeoLite2-City.mmdb",
+            _dataset("Archived London", 1800000000),
+        )
+        source = FakeSource(payload, archive=True)
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+
+        assert await updater.check_for_update() is True
+        assert activated[0].lookup("81.2.69.142").city_name == "Archived London"
+
+    async def test_staging_files_cleaned_up(self, database_path, activated):
+        """No staging directories should be left behind."""
+        source = FakeSource(_dataset("New London", 1800000000))
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+        await updater.check_for_update()
+
+        leftovers = [
+            name for name in os.listdir(os.path.dirname(database_path))
+            if name.startswith(".geoip-update-")
+        ]
+        assert leftovers == []
+
+
+class TestLocalFileSource:
+    """Test installing a file already on disk (admin uploads)."""
+
+    async def test_installs_file(self, database_path, activated, tmp_path):
+        """The file's own checksum should be the release checksum."""
+        upload = tmp_path / "upload.mmdb"
+        upload.write_bytes(_dataset("Uploaded London", 1800000000))
+        source = LocalFileSource(str(upload), "GeoLite2-City.mmdb")
+
+        release = await source.latest_release()
+        assert (release.version, release.archive) == ("GeoLite2-City.mmdb", False)
+        assert release.sha256 == sha256_file(str(upload))
+
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+        assert await updater.check_for_update() is True
+        assert activated[0].lookup("81.2.69.142").city_name == "Uploaded London"
+        assert await updater.check_for_update() is False
+
+    async def test_gzip_detected_as_archive(self, database_path, activated, tmp_path):
+        """gzip content should be extracted as a tar.gz release."""
+        upload = tmp_path / "upload"
+        upload.write_bytes(_tar_gz("GeoLite2-City.mmdb", _dataset("Archived London", 1800000000)))
+        source = LocalFileSource(str(upload))
+
+        assert (await source.latest_release()).archive is True
+        updater = GeoIPUpdateService(source, database_path, activate=activated.append)
+        assert await updater.check_for_update() is True
+        assert activated[0].lookup("81.2.69.142").city_name == "Archived London"
+
+    async def test_missing_file(self, tmp_path):
+        """An unreadable file should raise RuntimeError."""
+        with pytest.raises(RuntimeError, match="Cannot read dataset file"):
+            await LocalFileSource(str(tmp_path / "missing.mmdb")).latest_release()
+
+
+class TestHotSwap:
+    """Test swapping the global reader."""
+
+    def test_set_reader_returns_previous(self, tmp_path, monkeypatch):
+        """Swapping should return the previous reader without closing it."""
+        monkeypatch.setattr(mmdb_service, "_mmdb_reader", None)
+        first_path = tmp_path / "a.mmdb"
+        first_path.write_bytes(_dataset("A", 1))
+        second_path = tmp_path / "b.mmdb"
+        second_path.write_bytes(_dataset("B", 2))
+        first = MMDBReader(str(first_path))
+        second = MMDBReader(str(second_path))
+
+        assert set_mmdb_reader(first) is None
+        assert set_mmdb_reader(second) is first
+        assert mmdb_service.get_mmdb_reader() is second
+        # Old reader still usable by in-flight lookups
+        assert first.lookup("81.2.69.142").city_name == "A"
+        first.close()
+        second.close()
+
+
+class TestBuildUpdateService:
+    """Test construction from configuration."""
+
+    def test_disabled_by_default(self):
+        """Updates should be disabled unless configured."""
+        assert build_update_service(Config()) is None
+
+    def test_maxmind_requires_credentials(self, monkeypatch):
+        """MaxMind updates need an account ID and license key."""
+        monkeypatch.setenv("GEOIP_UPDATE_ENABLED", "true")
+        monkeypatch.delenv("MAXMIND_LICENSE_KEY", raising=False)
+        assert build_update_service(Config()) is None
+
+    def test_maxmind_configured(self, monkeypatch):
+        """Configured MaxMind credentials should produce an updater."""
+        monkeypatch.setenv("GEOIP_UPDATE_ENABLED", "true")
+        monkeypatch.setenv("MAXMIND_ACCOUNT_ID", "12345")
+        monkeypatch.setenv("MAXMIND_LICENSE_KEY", "secret")
+        monkeypatch.setenv("GEOIP_UPDATE_INTERVAL_SECONDS", "3600")
+        monkeypatch.setenv("GEOIP_USE_MMAP", "false")
+        updater = build_update_service(Config())
+        assert updater is not None
+        assert updater.check_interval_seconds == 3600
+        assert updater.use_mmap is False
+        assert updater.source.edition_id == "GeoLite2-City"
+
+
+def test_sha256_file(tmp_path):
+    """sha256_file should match hashlib on the file contents."""
+    path = tmp_path / "data.bin"
+    path.write_bytes(b"abc" * 1000)
+    assert sha256_file(str(path)) == hashlib.sha256(b"abc" * 1000).hexdigest()
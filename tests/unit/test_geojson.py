@@ -0,0 +1,70 @@
+"""Unit tests for RFC 7946 GeoJSON output."""
+import pytest
+
+from src.spatial import geojson
+
+SQUARE = {
+    "type": "Polygon",
+    "coordinates": [[[0, 0], [10, 0], [10, 5], [0, 5], [0, 0]]],
+}
+
+
+class TestBbox:
+    """Test bounding boxes of GeoJSON geometry."""
+
+    def test_polygon(self):
+        """Should be [west, south, east, north] of every position."""
+        assert geojson.geometry_bbox(SQUARE) == [0, 0, 10, 5]
+
+    def test_point_and_collection(self):
+        """Points and geometry collections should have boxes too."""
+        assert geojson.geometry_bbox(geojson.point(2.5, 48.8)) == [2.5, 48.8, 2.5, 48.8]
+        collection = {"type": "GeometryCollection", "geometries": [SQUARE, geojson.point(-3, 7)]}
+        assert geojson.geometry_bbox(collection) == [-3, 0, 10, 7]
+
+    def test_null_and_empty(self):
+        """Null and empty geometry should have no box."""
+        assert geojson.geometry_bbox(None) is None
+        assert geojson.geometry_bbox({"type": "MultiPolygon", "coordinates": []}) is None
+
+    def test_malformed(self):
+        """Should reject positions that are not numbers."""
+        with pytest.raises(ValueError, match="Malformed"):
+            geojson.geometry_bbox({"type": "Point", "coordinates": [["a"]]})
+
+
+class TestFeatures:
+    """Test Feature and FeatureCollection documents."""
+
+    def test_feature(self):
+        """Should carry id, bbox, geometry and properties."""
+        document = geojson.feature(SQUARE, {"name": "yard"}, "gf-1")
+        assert document == {
+            "type": "Feature",
+            "id": "gf-1",
+            "bbox": [0, 0, 10, 5],
+            "geometry": SQUARE,
+            "properties": {"name": "yard"},
+        }
+        assert "crs" not in document
+
+    def test_null_geometry_has_no_bbox(self):
+        """Unlocated features should keep a null geometry and no bbox."""
+        document = geojson.feature(None, {"country_code": "DE"})
+        assert document["geometry"] is None
+        assert "bbox" not in document
+
+    def test_collection_bbox(self):
+        """The collection bbox should cover its located features."""
+        collection = geojson.feature_collection([
+            geojson.feature(SQUARE),
+            geojson.feature(geojson.point(-20, 30)),
+            geojson.feature(None),
+        ])
+        assert collection["type"] == "FeatureCollection"
+        assert collection["bbox"] == [-20, 0, 10, 30]
+        assert len(collection["features"]) == 3
+
+    def test_empty_collection(self):
+        """An empty collection should have no bbox."""
+        assert geojson.feature_collection([]) == {"type": "FeatureCollection", "features": []}
@@ -0,0 +1,108 @@
+"""Route tests for the GraphQL endpoint (skipped without graphql-core)."""
+import pytest
+
+from src.services.enrichment_service import EnrichmentPipeline
+from src.services.geofence_service import GeofenceService
+from src.services.ip_lookup_service import IpLookupService
+from src.services.mmdb_service import MMDBReader
+from src.services.timezone_service import TimezoneResult
+from tests.mmdb_writer import write_mmdb
+
+pytest.importorskip("graphql")
+
+LONDON = {
+    "city": {"names": {"en": "London"}},
+    "country": {"iso_code": "GB", "names": {"en": "United Kingdom"}},
+    "location": {"latitude": 51.5142, "longitude": -0.0931, "accuracy_radius": 10},
+}
+
+SQUARE = {
+    "type": "Polygon",
+    "coordinates": [[[-0.2, 51.4], [0.0, 51.4], [0.0, 51.6], [-0.2, 51.6], [-0.2, 51.4]]],
+}
+
+
+class StubTimezones:
+    """Timezone service answering Europe/London everywhere."""
+
+    def __init__(self):
+        self.calls = 0
+
+    def lookup(self, latitude, longitude):
+        self.calls += 1
+        return TimezoneResult("Europe/London", 3600, 3600, "BST", "boundary")
+
+
+@pytest.fixture
+def timezones(tmp_path, monkeypatch):
+    """Lookups from a small fixture database and a stub timezone service."""
+    path = write_mmdb(tmp_path / "city.mmdb", [("81.2.69.128/26", LONDON)])
+    reader = MMDBReader(path)
+    service = IpLookupService(reader)
+    monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: service)
+    monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: EnrichmentPipeline())
+    stub = StubTimezones()
+    monkeypatch.setattr("src.api.graphql_routes.get_timezone_service", lambda: stub)
+    yield stub
+    reader.close()
+
+
+def _query(client, query, variables=None):
+    response = client.post("/api/v1/graphql", json={"query": query, "variables": variables})
+    assert response.status_code == 200
+    return response.json()
+
+
+class TestGraphQLRoute:
+    """Test POST /api/v1/graphql."""
+
+    def test_composed_query(self, db_client, db_session, timezones):
+        """One query should return the location, timezone, risk and geofences."""
+        geofence = GeofenceService(db_session).create_geofence("City", SQUARE)
+        body = _query(db_client, """
+            query($ip: String!, $fence: String!) {
+              ip(address: $ip) {
+                country_iso_code
+                city_name
+                timezone { tzid utc_offset dst }
+                risk { score reasons { code } }
+                geofences
+                inside_geofence(geofence_id: $fence)
+              }
+            }
+        """, {"ip": "81.2.69.142", "fence": geofence.geofence_id})
+        assert "errors" not in body
+        assert body["data"]["ip"] == {
+            "country_iso_code": "GB",
+            "city_name": "London",
+            "timezone": {"tzid": "Europe/London", "utc_offset": "+01:00", "dst": True},
+            "risk": {"score": 0, "reasons": []},
+            "geofences": [geofence.geofence_id],
+            "inside_geofence": True,
+        }
+
+    def test_unselected_fields_not_computed(self, db_client, timezones):
+        """Fields the query does not select should not be resolved."""
+        body = _query(db_client, '{ ip(address: "81.2.69.142") { city_name } }')
+        assert body["data"]["ip"] == {"city_name": "London"}
+        assert timezones.calls == 0
+
+    def test_point(self, db_client, timezones):
+        """A coordinate should get its timezone and geofences."""
+        body = _query(db_client, "{ point(lat: 51.5, lon: -0.1) { timezone { tzid } geofences } }")
+        assert body["data"]["point"] == {"timezone": {"tzid": "Europe/London"}, "geofences": []}
+
+    def test_errors_carry_codes(self, db_client, timezones):
+        """Service errors should be reported per field with the REST error code."""
+        body = _query(db_client, '{ ip(address: "8.8.8.8") { city_name } }')
+        assert body["data"]["ip"] is None
+        assert body["errors"][0]["extensions"]["error_code"] == "E004"
+
+        body = _query(db_client, '{ point(lat: 95, lon: 0) { latitude } }')
+        assert body["errors"][0]["extensions"]["error_code"] == "E002"
+
+    def test_invalid_query(self, db_client, timezones):
+        """Unknown fields should be reported as query errors."""
+        body = _query(db_client, '{ ip(address: "81.2.69.142") { nope } }')
+        assert body["data"] is None
+        assert "nope" in body["errors"][0]["message"]
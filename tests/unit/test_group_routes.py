@@ -0,0 +1,49 @@
+"""Route tests for the country group API."""
+import pytest
+
+from src.services.region_group_service import RegionGroups
+
+
+@pytest.fixture(autouse=True)
+def groups(monkeypatch):
+    """A few groups served by the API."""
+    groups = RegionGroups({"EU": ["DE", "FR"], "embargoed": ["CU", "IR"], "europe": ["EU", "CH"]})
+    monkeypatch.setattr("src.api.group_routes.get_region_groups", lambda: groups)
+    return groups
+
+
+class TestGroupRoutes:
+    """Test the group endpoints."""
+
+    def test_list(self, test_client):
+        """Should list every group with its members."""
+        response = test_client.get("/api/v1/groups")
+        assert response.status_code == 200
+        assert response.json() == [
+            {"name": "EU", "countries": ["DE", "FR"]},
+            {"name": "embargoed", "countries": ["CU", "IR"]},
+            {"name": "europe", "countries": ["CH", "DE", "FR"]},
+        ]
+
+    def test_get(self, test_client):
+        """Should return one group."""
+        response = test_client.get("/api/v1/groups/embargoed")
+        assert response.status_code == 200
+        assert response.json()["countries"] == ["CU", "IR"]
+
+    def test_get_unknown_is_404(self, test_client):
+        """Should return 404 for undefined groups."""
+        response = test_client.get("/api/v1/groups/nato")
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_country_groups(self, test_client):
+        """Should list the groups containing a country."""
+        response = test_client.get("/api/v1/countries/de/groups")
+        assert response.status_code == 200
+        assert response.json() == {"country_code": "DE", "groups": ["EU", "europe"]}
+
+    def test_bad_country_is_400(self, test_client):
+        """Should reject malformed country codes."""
+        response = test_client.get("/api/v1/countries/DEU/groups")
+        assert response.status_code == 400
@@ -0,0 +1,139 @@
+"""Unit tests for the call policy of the gRPC API."""
+import json
+
+import pytest
+
+from src.grpc_api.policy import CallPolicy, CallRefused, peer_address
+from src.services.auth_service import TokenVerifier
+from src.services.quota_service import QuotaEnforcer, QuotaPlan
+from src.services.rate_limit_service import InMemoryRateLimitStore, TokenBucketLimiter
+from src.services.rbac_service import PolicyStore
+from src.services.sanctions_service import SanctionsService
+from tests.unit.test_sanctions_routes import IRAN, LONDON, _screener
+
+SECRET = "test-secret-0123456789abcdef0123456789"
+
+
+@pytest.fixture
+def verifier():
+    return TokenVerifier(SECRET)
+
+
+def _bearer(token):
+    return {"authorization": f"Bearer {token}"}
+
+
+async def _refusal(policy, rpc="LookupIp", metadata=None, peer=LONDON):
+    with pytest.raises(CallRefused) as refused:
+        await policy.admit(rpc, metadata or {}, peer)
+    return refused.value
+
+
+class TestCallPolicy:
+    """Test admitting and refusing RPC calls."""
+
+    def test_peer_address(self):
+        """Peers should resolve to their client address."""
+        assert peer_address("ipv4:198.51.100.4:50312") == "198.51.100.4"
+        assert peer_address("ipv6:[2001:db8::1]:50312") == "2001:db8::1"
+        assert peer_address("unix:/tmp/grpc.sock") is None
+        assert peer_address(None) is None
+
+    async def test_token_verified(self, verifier):
+        """Valid tokens should give the caller, missing ones an anonymous call, bad ones UNAUTHENTICATED."""
+        policy = CallPolicy(verifier=verifier)
+        principal = await policy.admit("LookupIp", _bearer(verifier.issue("user-1", "acme")), LONDON)
+        assert principal.tenant_id == "acme"
+        assert await policy.admit("LookupIp", {}, LONDON) is None
+        for metadata in ({"authorization": "Basic x"}, _bearer("not-a-jwt")):
+            assert (await _refusal(policy, metadata=metadata)).status == "UNAUTHENTICATED"
+
+    async def test_token_required(self, verifier):
+        """With require_token anonymous calls should be UNAUTHENTICATED."""
+        policy = CallPolicy(require_token=True, verifier=verifier)
+        assert (await _refusal(policy)).status == "UNAUTHENTICATED"
+        principal = await policy.admit("LookupIp", _bearer(verifier.issue("user-1", "acme")), LONDON)
+        assert principal.tenant_id == "acme"
+
+    async def test_rbac_scopes(self, verifier, tmp_path):
+        """RPCs should need the scopes the RBAC policy requires of their REST equivalent."""
+        path = tmp_path / "rbac.json"
+        path.write_text(json.dumps({"rules": [
+            {"id": "lookups", "path": "/api/v1/lookup/**", "scopes": ["lookup:read"]},
+            {"id": "geofence-reads", "methods": ["GET"], "path": "/api/v1/geofences/**", "scopes": ["geofence:read"]},
+        ]}))
+        policy = CallPolicy(verifier=verifier, policy_store=PolicyStore(str(path)))
+        reader = _bearer(verifier.issue("user-1", "acme", scopes=["lookup:read"]))
+        assert (await policy.admit("LookupIp", reader, LONDON)).tenant_id == "acme"
+        assert (await policy.admit("BatchLookup", reader, LONDON)).tenant_id == "acme"
+        for rpc in ("MatchGeofences", "CheckGeofence"):
+            refused = await _refusal(policy, rpc, reader)
+            assert refused.status == "PERMISSION_DENIED" and "geofence:read" in refused.message
+        assert (await _refusal(policy, "StreamLookup")).status == "UNAUTHENTICATED"
+
+        path.write_text("{not json")
+        unloaded = CallPolicy(verifier=verifier, policy_store=PolicyStore(str(path)))
+        assert (await _refusal(unloaded, metadata=reader)).status == "UNAVAILABLE"
+
+    async def test_privacy_mode_refused(self, verifier):
+        """Tokens of API keys in privacy mode should be PERMISSION_DENIED."""
+        token = verifier.issue("apikey:k-1", "acme", privacy_precision=2)
+        refused = await _refusal(CallPolicy(verifier=verifier), metadata=_bearer(token))
+        assert refused.status == "PERMISSION_DENIED"
+
+    async def test_ip_allowlist(self, verifier):
+        """Tokens of network-restricted keys should only be admitted from their networks."""
+        policy = CallPolicy(verifier=verifier)
+        token = verifier.issue("apikey:k-1", "acme", allowed_cidrs=["198.51.100.0/24"])
+        assert (await policy.admit("LookupIp", _bearer(token), LONDON)).tenant_id == "acme"
+        refused = await _refusal(policy, metadata=_bearer(token), peer="203.0.113.7")
+        assert refused.status == "PERMISSION_DENIED"
+        assert "203.0.113.7" in refused.message
+
+    async def test_sanctions_block_audited(self, db_session):
+        """Calls from sanctioned regions should be refused and written to the audit trail."""
+        policy = CallPolicy(
+            sanctions=True, screener_factory=lambda: _screener("block"), session_factory=lambda: db_session
+        )
+        assert await policy.admit("MatchGeofences", {}, LONDON) is None
+        refused = await _refusal(policy, "MatchGeofences", {"user-agent": "grpc-python/1.6"}, peer=IRAN)
+        assert refused.status == "PERMISSION_DENIED"
+        (record,) = SanctionsService(db_session).audit_records(None)
+        assert (record.ip_address, record.method, record.path) == (IRAN, "GET", "/api/v1/geofences/match")
+        assert record.user_agent == "grpc-python/1.6"
+
+    async def test_rate_limit(self, verifier):
+        """Callers over their rate limit should be RESOURCE_EXHAUSTED with a retry delay."""
+        limiter = TokenBucketLimiter(InMemoryRateLimitStore(), capacity=2, refill_rate=0.001)
+        policy = CallPolicy(rate_limit=True, verifier=verifier, limiter_factory=lambda: limiter)
+        token = _bearer(verifier.issue("user-1", "acme"))
+        await policy.admit("LookupIp", token, LONDON)
+        await policy.admit("CheckGeofence", token, LONDON)
+        refused = await _refusal(policy, metadata=token)
+        assert refused.status == "RESOURCE_EXHAUSTED" and refused.retry_after > 0
+        # Anonymous callers have their own bucket
+        assert await policy.admit("LookupIp", {}, LONDON) is None
+
+    async def test_quota_counts_rest_endpoint(self, verifier, db_session):
+        """RPCs should count against the quota of their REST endpoint."""
+        enforcer = QuotaEnforcer({"tiny": QuotaPlan("tiny", endpoints={"POST /api/v1/lookup/batch": 1})})
+        policy = CallPolicy(
+            quota=True, verifier=verifier, enforcer_factory=lambda: enforcer, session_factory=lambda: db_session
+        )
+        token = _bearer(verifier.issue("apikey:k-1", "acme", quota_plan="tiny"))
+        await policy.admit("BatchLookup", token, LONDON)
+        await policy.admit("LookupIp", token, LONDON)
+        refused = await _refusal(policy, "BatchLookup", token)
+        assert refused.status == "RESOURCE_EXHAUSTED" and refused.retry_after >= 1
+
+    def test_from_config(self):
+        """The policy should apply the checks enabled for the HTTP API."""
+        from src.config import Config
+
+        config = Config()
+        config.sanctions_mode, config.rate_limit_enabled, config.quota_enabled = "block", False, True
+        config.auth_required = True
+        policy = CallPolicy.from_config(config)
+        assert (policy.sanctions, policy.rate_limit, policy.quota, policy.require_token) == (True, False, True, True)
+        config.sanctions_mode = "off"
+        assert CallPolicy.from_config(config).sanctions is False
@@ -0,0 +1,209 @@
+"""Tests for the gRPC API (skipped without the grpc extra)."""
+import pytest
+
+from src.services.auth_service import TokenVerifier
+from src.services.enrichment_service import EnrichmentPipeline
+from src.services.geofence_service import GeofenceService
+from src.services.ip_lookup_service import IpLookupService
+from src.services.mmdb_service import MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+grpc = pytest.importorskip("grpc")
+pytest.importorskip("grpc_tools")
+
+from src.grpc_api.policy import CallPolicy  # noqa: E402
+from src.grpc_api.server import GrpcServer  # noqa: E402
+
+LONDON = {
+    "city": {"names": {"en": "London"}},
+    "country": {"iso_code": "GB", "names": {"en": "United Kingdom"}},
+    "location": {"latitude": 51.5142, "longitude": -0.0931, "accuracy_radius": 10},
+}
+
+SQUARE = {
+    "type": "Polygon",
+    "coordinates": [[[-0.2, 51.4], [0.0, 51.4], [0.0, 51.6], [-0.2, 51.6], [-0.2, 51.4]]],
+}
+
+
+@pytest.fixture
+def lookup_service(tmp_path, monkeypatch):
+    """Serve lookups from a small fixture database with no enrichers."""
+    path = write_mmdb(tmp_path / "city.mmdb", [("81.2.69.128/26", LONDON)])
+    reader = MMDBReader(path)
+    service = IpLookupService(reader)
+    monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: service)
+    monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: EnrichmentPipeline())
+    yield service
+    reader.close()
+
+
+@pytest.fixture
+async def stub(db_session, lookup_service):
+    """Client stub of a server on a free local port."""
+    policy = CallPolicy(verifier=TokenVerifier("test-secret-0123456789abcdef0123456789"))
+    server = GrpcServer("127.0.0.1:0", session_factory=lambda: db_session, policy=policy)
+    await server.start()
+    channel = grpc.aio.insecure_channel(f"127.0.0.1:{server.port}")
+    yield server.protos, server.services.GeolocationStub(channel)
+    await channel.close()
+    await server.stop(0)
+
+
+class TestGrpcLookups:
+    """Test the lookup RPCs."""
+
+    async def test_lookup_ip(self, stub):
+        """LookupIp should answer like the REST lookup."""
+        protos, client = stub
+        location = await client.LookupIp(protos.LookupIpRequest(ip="81.2.69.142"))
+        assert location.country_iso_code == "GB"
+        assert location.city_name == "London"
+        assert location.network == "81.2.69.128/26"
+        assert '"city_name":"London"' in location.details_json
+
+    async def test_errors_map_to_status(self, stub):
+        """Invalid and unknown addresses should get INVALID_ARGUMENT and NOT_FOUND."""
+        protos, client = stub
+        with pytest.raises(grpc.aio.AioRpcError) as error:
+            await client.LookupIp(protos.LookupIpRequest(ip="not-an-ip"))
+        assert error.value.code() == grpc.StatusCode.INVALID_ARGUMENT
+        with pytest.raises(grpc.aio.AioRpcError) as error:
+            await client.LookupIp(protos.LookupIpRequest(ip="8.8.8.8"))
+        assert error.value.code() == grpc.StatusCode.NOT_FOUND
+
+    async def test_invalid_token(self, stub):
+        """A malformed authorization header should be UNAUTHENTICATED."""
+        protos, client = stub
+        with pytest.raises(grpc.aio.AioRpcError) as error:
+            await client.LookupIp(
+                protos.LookupIpRequest(ip="81.2.69.142"), metadata=[("authorization", "Basic x")]
+            )
+        assert error.value.code() == grpc.StatusCode.UNAUTHENTICATED
+
+    async def test_batch_lookup(self, stub):
+        """Items should succeed or fail on their own."""
+        protos, client = stub
+        response = await client.BatchLookup(protos.BatchLookupRequest(ips=["81.2.69.142", "8.8.8.8"]))
+        assert (response.total, response.succeeded, response.failed) == (2, 1, 1)
+        assert response.results[0].location.country_iso_code == "GB"
+        assert response.results[1].error.error_code == "E004"
+
+    async def test_stream_lookup(self, stub):
+        """Each streamed request should get one result, in order."""
+        protos, client = stub
+        requests = [protos.LookupIpRequest(ip=ip) for ip in ("81.2.69.142", "bogus", "81.2.69.130")]
+        results = [result async for result in client.StreamLookup(iter(requests))]
+        assert [result.index for result in results] == [0, 1, 2]
+        assert [result.WhichOneof("outcome") for result in results] == ["location", "error", "location"]
+        assert results[1].error.error_code == "E002"
+
+
+class TestGrpcGeofences:
+    """Test the geofence RPCs."""
+
+    async def test_match_and_check(self, stub, db_session):
+        """Geofence checks should use the stored geofences."""
+        protos, client = stub
+        geofence = GeofenceService(db_session).create_geofence("City", SQUARE)
+
+        match = await client.MatchGeofences(protos.GeofenceMatchRequest(lat=51.5, lon=-0.1))
+        assert list(match.geofence_ids) == [geofence.geofence_id]
+        check = await client.CheckGeofence(
+            protos.GeofenceContainsRequest(geofence_id=geofence.geofence_id, lat=52.0, lon=-0.1)
+        )
+        assert check.inside is False
+
+    async def test_unknown_geofence(self, stub):
+        """Checking a missing geofence should be NOT_FOUND."""
+        protos, client = stub
+        with pytest.raises(grpc.aio.AioRpcError) as error:
+            await client.CheckGeofence(protos.GeofenceContainsRequest(geofence_id="missing", lat=0, lon=0))
+        assert error.value.code() == grpc.StatusCode.NOT_FOUND
+
+    async def test_every_rpc_verifies_tokens(self, stub):
+        """Geofence and stream calls should refuse invalid tokens like the lookups."""
+        protos, client = stub
+        bad = [("authorization", "Bearer not-a-jwt")]
+        calls = [
+            client.MatchGeofences(protos.GeofenceMatchRequest(lat=51.5, lon=-0.1), metadata=bad),
+            client.CheckGeofence(protos.GeofenceContainsRequest(geofence_id="g", lat=0, lon=0), metadata=bad),
+        ]
+        for call in calls:
+            with pytest.raises(grpc.aio.AioRpcError) as error:
+                await call
+            assert error.value.code() == grpc.StatusCode.UNAUTHENTICATED
+        with pytest.raises(grpc.aio.AioRpcError) as error:
+            [result async for result in client.StreamLookup(iter([]), metadata=bad)]
+        assert error.value.code() == grpc.StatusCode.UNAUTHENTICATED
+
+
+def test_protos_load_from_any_directory(tmp_path, monkeypatch):
+    """The proto file should be found whatever the working directory."""
+    import sys
+
+    from src.grpc_api.messages import PACKAGE_ROOT, load_protos
+
+    monkeypatch.chdir(tmp_path)
+    monkeypatch.setattr(sys, "path", [entry for entry in sys.path if entry not in ("", ".", PACKAGE_ROOT)])
+    for name in ("src.grpc_api.geolocation_pb2", "src.grpc_api.geolocation_pb2_grpc"):
+        monkeypatch.delitem(sys.modules, name, raising=False)
+    protos, services = load_protos()
+    assert protos.LookupIpRequest(ip="81.2.69.142").ip == "81.2.69.142"
+    assert hasattr(services, "GeolocationStub")
+
+
+def _self_signed(tmp_path):
+    """Certificate and key files for localhost."""
+    from datetime import datetime, timedelta, timezone
+
+    x509 = pytest.importorskip("cryptography.x509")
+    from cryptography.hazmat.primitives import hashes, serialization
+    from cryptography.hazmat.primitives.asymmetric import ec
+    from cryptography.x509.oid import NameOID
+
+    key = ec.generate_private_key(ec.SECP256R1())
+    name = x509.Name([x509.NameAttribute(NameOID.COMMON_NAME, "localhost")])
+    now = datetime.now(timezone.utc)
+    cert = (
+        x509.CertificateBuilder()
+        .subject_name(name)
+        .issuer_name(name)
+        .public_key(key.public_key())
+        .serial_number(x509.random_serial_number())
+        .not_valid_before(now - timedelta(minutes=1))
+        .not_valid_after(now + timedelta(hours=1))
+        .add_extension(x509.SubjectAlternativeName([x509.DNSName("localhost")]), critical=False)
+        .sign(key, hashes.SHA256())
+    )
+    cert_file, key_file = tmp_path / "grpc.pem", tmp_path / "grpc.key"
+    cert_file.write_bytes(cert.public_bytes(serialization.Encoding.PEM))
+    key_file.write_bytes(key.private_bytes(
+        serialization.Encoding.PEM, serialization.PrivateFormat.PKCS8, serialization.NoEncryption()
+    ))
+    return cert_file, key_file
+
+
+class TestGrpcTls:
+    """Test the TLS listener."""
+
+    async def test_tls_listener(self, tmp_path, db_session, lookup_service):
+        """With a certificate the listener should serve TLS clients."""
+        cert_file, key_file = _self_signed(tmp_path)
+        server = GrpcServer(
+            "127.0.0.1:0", session_factory=lambda: db_session, tls_cert_file=str(cert_file), tls_key_file=str(key_file)
+        )
+        await server.start()
+        try:
+            credentials = grpc.ssl_channel_credentials(root_certificates=cert_file.read_bytes())
+            async with grpc.aio.secure_channel(f"localhost:{server.port}", credentials) as channel:
+                client = server.services.GeolocationStub(channel)
+                location = await client.LookupIp(server.protos.LookupIpRequest(ip="81.2.69.142"))
+            assert location.country_iso_code == "GB"
+        finally:
+            await server.stop(0)
+
+    def test_half_configured(self, tmp_path):
+        """A certificate without its key should be refused."""
+        with pytest.raises(ValueError, match="GRPC_TLS_KEY_FILE"):
+            GrpcServer("127.0.0.1:0", tls_cert_file=str(tmp_path / "grpc.pem"))
@@ -0,0 +1,67 @@
+"""Unit tests for H3 cell helpers."""
+import pytest
+
+from src.spatial import h3index
+
+
+@pytest.fixture
+def h3():
+    """The h3 library (tests are skipped when it is not installed)."""
+    return pytest.importorskip("h3")
+
+
+class TestValidation:
+    """Test input validation independent of the h3 library."""
+
+    @pytest.mark.parametrize("resolution", [-1, 16])
+    def test_invalid_resolution(self, resolution):
+        """Resolutions outside 0-15 should raise ValueError."""
+        with pytest.raises(ValueError, match="resolution"):
+            h3index.validate_resolution(resolution)
+
+    def test_missing_library(self, monkeypatch):
+        """Operations should raise RuntimeError when h3 is not installed."""
+        monkeypatch.setattr(h3index, "h3", None)
+        with pytest.raises(RuntimeError, match="h3"):
+            h3index.latlng_to_cell(51.5, -0.12, 9)
+
+    def test_invalid_coordinate_checked_first(self, monkeypatch):
+        """Invalid coordinates should be rejected before the library is used."""
+        monkeypatch.setattr(h3index, "h3", None)
+        with pytest.raises(ValueError, match="Latitude"):
+            h3index.latlng_to_cell(91, 0, 9)
+
+
+class TestCells:
+    """Test cell computation against the h3 library."""
+
+    def test_cell_contains_point(self, h3):
+        """The cell center should map back to the same cell."""
+        cell = h3index.latlng_to_cell(51.5074, -0.1278, 9)
+        assert h3index.cell_resolution(cell) == 9
+        lat, lon = h3index.cell_to_latlng(cell)
+        assert h3index.latlng_to_cell(lat, lon, 9) == cell
+
+    def test_boundary_is_lon_lat(self, h3):
+        """Boundaries should be hexagons in (lon, lat) order."""
+        cell = h3index.latlng_to_cell(51.5074, -0.1278, 9)
+        boundary = h3index.cell_boundary(cell)
+        assert len(boundary) == 6
+        assert all(-1 < lon < 1 and 51 < lat < 52 for lon, lat in boundary)
+
+    def test_grid_disk(self, h3):
+        """A k=1 disk should contain the cell and its six neighbors."""
+        cell = h3index.latlng_to_cell(0.0, 0.0, 7)
+        disk = h3index.grid_disk(cell, 1)
+        assert len(disk) == 7
+        assert cell in disk
+
+    def test_invalid_cell(self, h3):
+        """Invalid indexes should raise ValueError."""
+        with pytest.raises(ValueError, match="Invalid H3 cell"):
+            h3index.validate_cell("not-a-cell")
+
+    def test_cells_for_points(self, h3):
+        """Nearby points should share a coarse cell."""
+        cells = h3index.cells_for_points([(51.5074, -0.1278), (51.5075, -0.1279)], 5)
+        assert len(set(cells)) == 1
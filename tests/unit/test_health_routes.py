@@ -0,0 +1,51 @@
+"""Route tests for the Kubernetes probe endpoints."""
+from src.services.health_service import CheckStatus, DependencyCheck, HealthReport
+
+
+def _use_report(monkeypatch, *checks):
+    report = HealthReport(list(checks))
+    monkeypatch.setattr("src.services.health_service.HealthService.readiness", lambda self: report)
+    monkeypatch.setattr("src.services.health_service.HealthService.health", lambda self: report)
+
+
+class TestProbeRoutes:
+    """Test /livez, /readyz and /healthz."""
+
+    def test_livez_checks_nothing(self, test_client, monkeypatch):
+        """Liveness should not depend on any dependency."""
+        _use_report(monkeypatch, DependencyCheck("database", CheckStatus.FAIL, True))
+        response = test_client.get("/livez")
+        assert response.status_code == 200
+        assert response.json()["status"] == "ok"
+        assert response.json()["checks"] == {}
+
+    def test_ready(self, test_client, monkeypatch):
+        """Should report each dependency."""
+        _use_report(
+            monkeypatch,
+            DependencyCheck("database", CheckStatus.OK, True, 1.5, "postgresql"),
+            DependencyCheck("velocity_redis", CheckStatus.SKIPPED, True, detail="not configured"),
+        )
+        response = test_client.get("/readyz")
+        assert response.status_code == 200
+        assert response.headers["cache-control"] == "no-store"
+        body = response.json()
+        assert body["status"] == "ok"
+        assert body["checks"]["database"] == {
+            "status": "ok", "critical": True, "latency_ms": 1.5, "detail": "postgresql"
+        }
+
+    def test_critical_failure_is_503(self, test_client, monkeypatch):
+        """A failed critical check should make the probe fail."""
+        _use_report(monkeypatch, DependencyCheck("geoip_dataset", CheckStatus.FAIL, True, 0.1, "not loaded"))
+        for path in ("/readyz", "/healthz"):
+            response = test_client.get(path)
+            assert response.status_code == 503
+            assert response.json()["status"] == "fail"
+
+    def test_degraded_is_200(self, test_client, monkeypatch):
+        """Non-critical failures should degrade health but keep 200."""
+        _use_report(monkeypatch, DependencyCheck("geoip_dataset_age", CheckStatus.FAIL, False, 0.1, "stale"))
+        response = test_client.get("/healthz")
+        assert response.status_code == 200
+        assert response.json()["status"] == "degraded"
@@ -0,0 +1,106 @@
+"""Unit tests for dependency health checks."""
+import time
+from types import SimpleNamespace
+
+import pytest
+
+from src.config import get_config
+from src.services import health_service
+from src.services.health_service import CheckStatus, HealthService, HealthStatus
+
+
+class FakeManager:
+    """Database manager whose health check result is fixed."""
+
+    def __init__(self, healthy=True, checked_out=0):
+        self.healthy = healthy
+        self.checked_out = checked_out
+        self.engine = SimpleNamespace(dialect=SimpleNamespace(name="postgresql"))
+
+    def health_check(self):
+        return self.healthy
+
+    def get_pool_size(self):
+        return {"pool_size": 2, "max_overflow": 1, "checked_in": 0, "checked_out": self.checked_out}
+
+    def pool_exhausted(self):
+        return self.checked_out >= 3
+
+
+@pytest.fixture
+def dependencies(monkeypatch):
+    """Healthy database and a dataset built a day ago; no Redis configured."""
+    state = SimpleNamespace(
+        manager=FakeManager(),
+        reader=SimpleNamespace(database_type="GeoLite2-City", build_epoch=int(time.time()) - 86400),
+        pings=[],
+    )
+    monkeypatch.setattr(health_service, "get_db_manager", lambda: state.manager)
+    monkeypatch.setattr(health_service, "get_mmdb_reader", lambda: state.reader)
+    monkeypatch.setattr(health_service, "_ping_redis", lambda url, timeout: state.pings.append(url))
+    monkeypatch.delenv("VELOCITY_REDIS_URL", raising=False)
+    monkeypatch.delenv("IDEMPOTENCY_REDIS_URL", raising=False)
+    return state
+
+
+def _checks(report):
+    return {check.name: check for check in report.checks}
+
+
+class TestHealthService:
+    """Test readiness and health reports."""
+
+    def test_all_healthy(self, dependencies):
+        """Healthy dependencies should report ok, unconfigured Redis skipped."""
+        report = HealthService(get_config()).health()
+        checks = _checks(report)
+        assert report.status == HealthStatus.OK
+        assert checks["database"].detail == "postgresql"
+        assert checks["geoip_dataset"].status == CheckStatus.OK
+        assert checks["velocity_redis"].status == CheckStatus.SKIPPED
+        assert checks["token_denylist_redis"].status == CheckStatus.SKIPPED
+        assert checks["geoip_dataset_age"].detail == "1.0 days old"
+
+    def test_database_down_fails(self, dependencies):
+        """An unreachable database should fail readiness."""
+        dependencies.manager = FakeManager(healthy=False)
+        report = HealthService(get_config()).readiness()
+        assert report.status == HealthStatus.FAIL
+        assert _checks(report)["database"].detail == "database unreachable"
+
+    def test_database_pool_exhausted_fails(self, dependencies):
+        """A worker without a free database connection should fail readiness without waiting for one."""
+        dependencies.manager = FakeManager(checked_out=3)
+        report = HealthService(get_config()).readiness()
+        assert report.status == HealthStatus.FAIL
+        assert _checks(report)["database"].detail == "connection pool exhausted (3 in use)"
+
+    def test_dataset_missing_fails(self, dependencies):
+        """A dataset that could not be opened should fail readiness."""
+        dependencies.reader = None
+        checks = _checks(HealthService(get_config()).health())
+        assert checks["geoip_dataset"].status == CheckStatus.FAIL
+        assert checks["geoip_dataset_age"].status == CheckStatus.SKIPPED
+
+    def test_stale_dataset_degrades(self, dependencies, monkeypatch):
+        """A dataset past the age limit should degrade health without failing it."""
+        monkeypatch.setenv("HEALTH_DATASET_MAX_AGE_DAYS", "0.5")
+        report = HealthService(get_config()).health()
+        assert report.status == HealthStatus.DEGRADED
+        assert "limit 0.5" in _checks(report)["geoip_dataset_age"].detail
+        assert HealthService(get_config()).readiness().status == HealthStatus.OK
+
+    def test_redis(self, dependencies, monkeypatch):
+        """Configured Redis servers should be pinged and fail readiness when down."""
+        monkeypatch.setenv("VELOCITY_REDIS_URL", "redis://velocity:6379/0")
+        monkeypatch.setenv("IDEMPOTENCY_REDIS_URL", "redis://idempotency:6379/0")
+        assert HealthService(get_config()).readiness().status == HealthStatus.OK
+        assert dependencies.pings == ["redis://velocity:6379/0", "redis://idempotency:6379/0"]
+
+        def refuse(url, timeout):
+            raise ConnectionError("Connection refused")
+
+        monkeypatch.setattr(health_service, "_ping_redis", refuse)
+        report = HealthService(get_config()).readiness()
+        assert report.status == HealthStatus.FAIL
+        assert _checks(report)["idempotency_redis"].detail == "Connection refused"
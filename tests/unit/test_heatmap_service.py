@@ -0,0 +1,64 @@
+"""Unit tests for detection heatmap aggregation."""
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.models.database_models import Detection
+from src.services import heatmap_service
+from src.services.heatmap_service import HeatmapService
+
+
+def _detection(index, latitude, longitude, timestamp):
+    return Detection(
+        detection_id=f"det-{index}", source="test", camera_id="cam-1",
+        object_class="vehicle", ai_confidence=0.9, timestamp=timestamp,
+        pixel_x=0, pixel_y=0, camera_lat=latitude, camera_lon=longitude,
+        camera_elevation=0.0, camera_heading=0.0, camera_pitch=0.0, camera_roll=0.0,
+        focal_length=4.0, sensor_width_mm=6.0, sensor_height_mm=4.5,
+        image_width=1920, image_height=1080,
+        calculated_lat=latitude, calculated_lon=longitude,
+        confidence_value=0.8, confidence_flag="GREEN", uncertainty_radius_meters=5.0,
+    )
+
+
+@pytest.fixture
+def fake_h3(monkeypatch):
+    """Bucket points by rounded coordinate instead of real H3 cells."""
+    monkeypatch.setattr(
+        heatmap_service.h3index, "cells_for_points",
+        lambda points, resolution: [f"{round(lat)}:{round(lon)}" for lat, lon in points],
+    )
+    monkeypatch.setattr(
+        heatmap_service.h3index, "cell_to_latlng",
+        lambda cell: tuple(float(v) for v in cell.split(":")),
+    )
+
+
+@pytest.fixture
+def detections(db_session):
+    """Three detections near (10, 20) and one near (30, 40)."""
+    start = datetime(2026, 3, 1, 12, 0)
+    points = [(10.1, 20.1), (10.2, 20.2), (9.9, 19.9), (30.0, 40.0)]
+    for i, (latitude, longitude) in enumerate(points):
+        db_session.add(_detection(i, latitude, longitude, start + timedelta(minutes=i)))
+    db_session.commit()
+
+
+class TestHeatmapService:
+    """Test per-cell aggregation."""
+
+    def test_counts_per_cell(self, db_session, detections, fake_h3):
+        """Detections should be counted per cell, highest count first."""
+        result = HeatmapService(db_session, max_detections=100).h3_counts(7)
+
+        assert [(c.cell, c.count) for c in result.cells] == [("10:20", 3), ("30:40", 1)]
+        assert result.total == 4
+        assert result.truncated is False
+
+    def test_truncation_reported(self, db_session, detections, fake_h3):
+        """Hitting the scan limit should be reported, keeping the most recent."""
+        result = HeatmapService(db_session, max_detections=2).h3_counts(7)
+
+        assert result.truncated is True
+        assert result.total == 2
+        assert sorted(c.cell for c in result.cells) == ["10:20", "30:40"]
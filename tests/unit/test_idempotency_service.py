@@ -0,0 +1,87 @@
+"""Unit tests for idempotency keys."""
+import pytest
+
+from src.services.idempotency_service import (
+    PENDING_TTL_SECONDS,
+    IdempotencyConflict,
+    IdempotencyService,
+    InMemoryIdempotencyStore,
+    request_fingerprint,
+    validate_key,
+)
+
+SCOPE = "acme:POST /jobs"
+
+
+class FakeClock:
+    """Manually advanced monotonic clock."""
+
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+@pytest.fixture
+def clock():
+    return FakeClock()
+
+
+@pytest.fixture
+def service(clock):
+    return IdempotencyService(InMemoryIdempotencyStore(clock=clock), ttl_seconds=3600)
+
+
+class TestIdempotencyService:
+    """Test claiming, replaying and releasing keys."""
+
+    def test_replay_completed(self, service):
+        """A completed key should replay its stored response."""
+        fingerprint = request_fingerprint({"source": "s3://exports/a.csv"})
+        assert service.begin(SCOPE, "k1", fingerprint) is None
+        service.complete(SCOPE, "k1", fingerprint, 202, {"job_id": "j1"})
+
+        entry = service.begin(SCOPE, "k1", fingerprint)
+        assert (entry.status_code, entry.body) == (202, {"job_id": "j1"})
+
+    def test_conflicts(self, service):
+        """Keys still running, or reused for another request, should be refused."""
+        fingerprint = request_fingerprint({"source": "s3://exports/a.csv"})
+        service.begin(SCOPE, "k1", fingerprint)
+        with pytest.raises(IdempotencyConflict) as running:
+            service.begin(SCOPE, "k1", fingerprint)
+        assert running.value.in_progress
+
+        with pytest.raises(IdempotencyConflict) as reused:
+            service.begin(SCOPE, "k1", request_fingerprint({"source": "s3://exports/b.csv"}))
+        assert not reused.value.in_progress
+
+    def test_release_and_scope(self, service):
+        """Released keys can be claimed again, and scopes do not share keys."""
+        service.begin(SCOPE, "k1", "f")
+        service.release(SCOPE, "k1")
+        assert service.begin(SCOPE, "k1", "f") is None
+        assert service.begin("globex:POST /jobs", "k1", "f") is None
+
+    def test_expiry(self, service, clock):
+        """Stuck claims expire after the pending TTL, results after the TTL."""
+        service.begin(SCOPE, "stuck", "f")
+        clock.now += PENDING_TTL_SECONDS + 1
+        assert service.begin(SCOPE, "stuck", "f") is None
+
+        service.complete(SCOPE, "stuck", "f", 201, {})
+        clock.now += 3600 - 1
+        assert service.begin(SCOPE, "stuck", "f") is not None
+        clock.now += 2
+        assert service.begin(SCOPE, "stuck", "f") is None
+
+    def test_fingerprint_ignores_key_order(self):
+        """Equal payloads should have equal fingerprints."""
+        assert request_fingerprint({"a": 1, "b": 2}) == request_fingerprint({"b": 2, "a": 1})
+
+    @pytest.mark.parametrize("key", ["", "   ", "x" * 256, "clé", "tab\there"])
+    def test_invalid_key(self, key):
+        """Malformed keys should be rejected."""
+        with pytest.raises(ValueError, match="Idempotency-Key"):
+            validate_key(key)
@@ -0,0 +1,117 @@
+"""Unit tests for API key network allowlists."""
+import pytest
+from fastapi import FastAPI, WebSocket
+from fastapi.testclient import TestClient
+from starlette.websockets import WebSocketDisconnect
+
+from src.middleware.ip_allowlist import IpAllowlistMiddleware
+from src.services.auth_service import TokenVerifier
+from src.services.ip_allowlist_service import (
+    MAX_CIDRS,
+    address_allowed,
+    client_address,
+    parse_cidrs,
+    token_cidrs,
+)
+
+SECRET = "test-secret-0123456789abcdef0123456789"
+
+
+class TestAllowlists:
+    """Test parsing and matching networks."""
+
+    def test_parse_normalizes(self):
+        """Networks should be normalized and deduplicated, in order."""
+        assert parse_cidrs(["10.1.2.3/8", " 2001:db8::1/32", "10.0.0.0/8", "192.0.2.7"]) == (
+            "10.0.0.0/8", "2001:db8::/32", "192.0.2.7/32"
+        )
+        assert parse_cidrs([]) == ()
+
+    def test_parse_rejects(self):
+        """Invalid entries, bare strings and overlong lists should be refused."""
+        with pytest.raises(ValueError, match="Invalid network"):
+            parse_cidrs(["10.0.0.0/33"])
+        with pytest.raises(ValueError, match="list"):
+            parse_cidrs("10.0.0.0/8")
+        with pytest.raises(ValueError, match=str(MAX_CIDRS)):
+            parse_cidrs([f"10.0.{i}.0/24" for i in range(MAX_CIDRS + 1)])
+
+    def test_address_allowed(self):
+        """Addresses should match any listed network; an empty list allows all."""
+        cidrs = ("10.0.0.0/8", "2001:db8::/32")
+        assert address_allowed("10.200.0.1", cidrs)
+        assert address_allowed("2001:db8::5", cidrs)
+        assert address_allowed("::ffff:10.0.0.1", cidrs)
+        assert not address_allowed("192.0.2.1", cidrs)
+        assert not address_allowed("testclient", cidrs)
+        assert not address_allowed(None, cidrs)
+        assert address_allowed(None, ())
+
+    def test_client_address(self):
+        """The rightmost proxy header entry should win over the peer address."""
+        scope = {
+            "client": ("203.0.113.9", 5000),
+            "headers": [(b"x-forwarded-for", b"198.51.100.1, 10.0.0.1"), (b"x-forwarded-for", b"10.0.0.2")],
+        }
+        assert client_address(scope) == "203.0.113.9"
+        assert client_address(scope, "X-Forwarded-For") == "10.0.0.2"
+        assert client_address({"client": None, "headers": []}, "X-Forwarded-For") is None
+
+    def test_token_cidrs(self):
+        """The claim should be read without verifying the token."""
+        token = TokenVerifier(SECRET).issue("acme-api", "acme", allowed_cidrs=["10.0.0.0/8"])
+        assert token_cidrs(token) == ("10.0.0.0/8",)
+        assert token_cidrs(TokenVerifier("another-secret-0123456789abcdef01234").issue("a", "acme")) == ()
+        assert token_cidrs("garbage") == ()
+
+
+def _client(client_ip_header=""):
+    app = FastAPI()
+    app.add_middleware(IpAllowlistMiddleware, client_ip_header=client_ip_header)
+
+    @app.get("/api/v1/ping")
+    async def ping():
+        return {"ok": True}
+
+    @app.websocket("/api/v1/ws")
+    async def ws(websocket: WebSocket):
+        await websocket.accept()
+        await websocket.send_json({"ok": True})
+        await websocket.close()
+
+    return TestClient(app)
+
+
+class TestIpAllowlistMiddleware:
+    """Test refusing restricted tokens from other addresses."""
+
+    def test_restricted_token_refused(self):
+        """A restricted token should be refused from the test client's address."""
+        token = TokenVerifier(SECRET).issue("acme-api", "acme", allowed_cidrs=["10.0.0.0/8"])
+        response = _client().get("/api/v1/ping", headers={"Authorization": f"Bearer {token}"})
+        assert response.status_code == 403
+        assert response.json()["detail"]["error_code"] == "E011"
+
+    def test_allowed_behind_proxy(self):
+        """The configured header should supply the client address."""
+        token = TokenVerifier(SECRET).issue("acme-api", "acme", allowed_cidrs=["10.0.0.0/8"])
+        headers = {"Authorization": f"Bearer {token}", "X-Real-IP": "10.3.2.1"}
+        assert _client("X-Real-IP").get("/api/v1/ping", headers=headers).status_code == 200
+
+    def test_unrestricted_and_anonymous_pass(self):
+        """Tokens without the claim and requests without a token should pass."""
+        client = _client()
+        token = TokenVerifier(SECRET).issue("acme-api", "acme")
+        assert client.get("/api/v1/ping", headers={"Authorization": f"Bearer {token}"}).status_code == 200
+        assert client.get("/api/v1/ping").status_code == 200
+
+    def test_websocket_query_token(self):
+        """WebSocket tokens in the query string should be checked too."""
+        client = _client()
+        token = TokenVerifier(SECRET).issue("acme-api", "acme", allowed_cidrs=["10.0.0.0/8"])
+        with pytest.raises(WebSocketDisconnect) as closed:
+            with client.websocket_connect(f"/api/v1/ws?token={token}"):
+                pass
+        assert closed.value.code == 1008
+        with client.websocket_connect("/api/v1/ws") as websocket:
+            assert websocket.receive_json() == {"ok": True}
@@ -0,0 +1,200 @@
+"""Unit tests for the IP lookup engine (IPv4/IPv6 normalization)."""
+import ipaddress
+import pytest
+
+from src.services.ip_lookup_service import (
+    IpLookupService,
+    IpPrefixMap,
+    TunnelType,
+    normalize_ip,
+    parse_ip,
+)
+from src.services.mmdb_service import MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+
+LONDON = {"city": {"names": {"en": "London"}}, "country": {"iso_code": "GB"}}
+TOKYO = {"city": {"names": {"en": "Tokyo"}}, "country": {"iso_code": "JP"}}
+RELAY = {"country": {"iso_code": "US"}, "city": {"names": {"en": "Redmond"}}}
+
+
+@pytest.fixture
+def v6_service(tmp_path):
+    """Lookup service over an IPv6 database."""
+    path = write_mmdb(
+        tmp_path / "city.mmdb",
+        [
+            ("81.2.69.128/26", LONDON),
+            ("2400:4000::/22", TOKYO),
+            ("2001::/32", RELAY),
+        ],
+    )
+    reader = MMDBReader(path)
+    yield IpLookupService(reader)
+    reader.close()
+
+
+@pytest.fixture
+def v4_service(tmp_path):
+    """Lookup service over an IPv4-only database."""
+    path = write_mmdb(
+        tmp_path / "v4.mmdb", [("81.2.69.128/26", LONDON)], ip_version=4
+    )
+    reader = MMDBReader(path)
+    yield IpLookupService(reader)
+    reader.close()
+
+
+class TestParseIp:
+    """Test IP address parsing."""
+
+    def test_parse_ipv4(self):
+        """Plain IPv4 should parse."""
+        assert parse_ip("81.2.69.142") == ipaddress.ip_address("81.2.69.142")
+
+    def test_parse_ipv6_uppercase(self):
+        """IPv6 should parse case-insensitively."""
+        assert parse_ip("2001:DB8::1") == ipaddress.ip_address("2001:db8::1")
+
+    def test_parse_bracketed(self):
+        """Bracketed IPv6 (URL host form) should parse."""
+        assert parse_ip("[2001:db8::1]") == ipaddress.ip_address("2001:db8::1")
+
+    def test_parse_zone_id(self):
+        """Zone IDs should be stripped."""
+        assert parse_ip("fe80::1%eth0") == ipaddress.ip_address("fe80::1")
+
+    def test_parse_whitespace(self):
+        """Surrounding whitespace should be ignored."""
+        assert parse_ip(" 81.2.69.142 ") == ipaddress.ip_address("81.2.69.142")
+
+    @pytest.mark.parametrize("value", ["", "999.1.1.1", "2001:::1", "host", None])
+    def test_parse_invalid(self, value):
+        """Invalid addresses should raise ValueError."""
+        with pytest.raises(ValueError, match="Invalid IP address"):
+            parse_ip(value)
+
+
+class TestNormalizeIp:
+    """Test IPv6 transition address normalization."""
+
+    def test_ipv4_unchanged(self):
+        """IPv4 addresses need no normalization."""
+        normalized = normalize_ip("81.2.69.142")
+        assert normalized.address == normalized.original
+        assert normalized.tunnel is None
+        assert normalized.ip_version == 4
+
+    def test_native_ipv6_unchanged(self):
+        """Native IPv6 addresses are looked up as-is."""
+        normalized = normalize_ip("2400:4000::1")
+        assert normalized.address == ipaddress.ip_address("2400:4000::1")
+        assert normalized.tunnel is None
+        assert normalized.ip_version == 6
+
+    def test_ipv4_mapped(self):
+        """::ffff:a.b.c.d should map to the IPv4 address."""
+        normalized = normalize_ip("::ffff:81.2.69.142")
+        assert normalized.address == ipaddress.ip_address("81.2.69.142")
+        assert normalized.tunnel == TunnelType.IPV4_MAPPED
+
+    def test_6to4(self):
+        """2002:AABB:CCDD:: should map to the embedded IPv4 address."""
+        normalized = normalize_ip("2002:5102:458e::1")
+        assert normalized.address == ipaddress.ip_address("81.2.69.142")
+        assert normalized.tunnel == TunnelType.SIX_TO_FOUR
+        assert normalized.ip_version == 6
+
+    def test_teredo(self):
+        """Teredo addresses should map to the obfuscated client IPv4."""
+        # Client 81.2.69.142 is stored XOR 0xffffffff in the last 32 bits
+        normalized = normalize_ip("2001:0:4136:e378:8000:63bf:aefd:ba71")
+        assert normalized.address == ipaddress.ip_address("81.2.69.142")
+        assert normalized.tunnel == TunnelType.TEREDO
+
+
+class TestIpLookupService:
+    """Test lookups through the engine."""
+
+    def test_ipv4_lookup(self, v6_service):
+        """IPv4 lookups should return the record."""
+        result = v6_service.lookup("81.2.69.142")
+        assert result.record.city_name == "London"
+
+    def test_native_ipv6_lookup(self, v6_service):
+        """Native IPv6 lookups should return the IPv6 network record."""
+        result = v6_service.lookup("2400:4000::abcd")
+        assert result.record.city_name == "Tokyo"
+        assert result.normalized.tunnel is None
+
+    def test_6to4_uses_embedded_ipv4(self, v6_service):
+        """6to4 lookups should resolve the embedded IPv4 client."""
+        result = v6_service.lookup("2002:5102:458e::1")
+        assert result.record.city_name == "London"
+        assert result.normalized.tunnel == TunnelType.SIX_TO_FOUR
+
+    def test_teredo_prefers_client_over_relay(self, v6_service):
+        """Teredo lookups should prefer the client IPv4 over the relay prefix."""
+        result = v6_service.lookup("2001:0:4136:e378:8000:63bf:aefd:ba71")
+        assert result.record.city_name == "London"
+
+    def test_teredo_falls_back_to_ipv6(self, v6_service):
+        """Unknown Teredo clients should fall back to the IPv6 record."""
+        # Client 8.8.8.8 -> f7f7:f7f7
+        result = v6_service.lookup("2001:0:4136:e378:8000:63bf:f7f7:f7f7")
+        assert result.record.city_name == "Redmond"
+
+    def test_not_found(self, v6_service):
+        """Unknown addresses should produce an empty result."""
+        result = v6_service.lookup("2a00::1")
+        assert result.record is None
+
+    def test_ipv4_database_rejects_native_ipv6(self, v4_service):
+        """Native IPv6 cannot be answered from an IPv4-only dataset."""
+        with pytest.raises(ValueError, match="only contains IPv4"):
+            v4_service.lookup("2400:4000::1")
+
+    def test_ipv4_database_answers_6to4(self, v4_service):
+        """Tunneled IPv6 can still be answered from an IPv4-only dataset."""
+        result = v4_service.lookup("2002:5102:458e::1")
+        assert result.record.city_name == "London"
+
+    def test_invalid_ip(self, v6_service):
+        """Invalid input should raise ValueError."""
+        with pytest.raises(ValueError):
+            v6_service.lookup("nope")
+
+
+class TestIpPrefixMap:
+    """Test most-specific-first network matching."""
+
+    def test_nested_network_wins(self):
+        """A network inside a larger one should take precedence."""
+        prefixes = IpPrefixMap([("10.0.0.0/8", "corp"), ("10.20.0.0/16", "london")])
+        assert prefixes.lookup("10.20.1.1") == "london"
+        assert prefixes.lookup("10.30.1.1") == "corp"
+        assert list(prefixes.matches("10.20.1.1")) == ["london", "corp"]
+
+    def test_no_match(self):
+        """Addresses outside every network should return None."""
+        prefixes = IpPrefixMap([("10.0.0.0/8", "corp")])
+        assert prefixes.lookup("192.0.2.1") is None
+
+    def test_ipv6_and_host_bits(self):
+        """IPv6 networks work and host bits are ignored."""
+        prefixes = IpPrefixMap([("2001:db8::1/32", "lab"), ("192.0.2.7/32", "host")])
+        assert prefixes.lookup("2001:db8:ffff::1") == "lab"
+        assert prefixes.lookup(ipaddress.ip_address("192.0.2.7")) == "host"
+        assert prefixes.lookup("::ffff:192.0.2.7") is None
+        assert len(prefixes) == 2
+
+    def test_duplicate_replaces(self):
+        """Re-adding a network should replace its value."""
+        prefixes = IpPrefixMap([("10.0.0.0/8", "old"), ("10.0.0.0/8", "new")])
+        assert prefixes.lookup("10.1.1.1") == "new"
+        assert len(prefixes) == 1
+
+    def test_invalid_network(self):
+        """Invalid networks should raise ValueError."""
+        with pytest.raises(ValueError):
+            IpPrefixMap([("10.0.0.0/33", "bad")])
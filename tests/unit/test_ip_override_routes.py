@@ -0,0 +1,123 @@
+"""Route tests for tenant IP range overrides."""
+import pytest
+
+from src.services.auth_service import TokenVerifier
+from src.services.enrichment_service import EnrichmentPipeline
+
+
+HQ = {
+    "network": "10.20.0.0/16",
+    "label": "London HQ",
+    "latitude": 51.5155,
+    "longitude": -0.0922,
+    "city_name": "London",
+    "properties": {"building": "HQ-1"},
+}
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API, with a test secret."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+def _auth(verifier, tenant_id):
+    return {"Authorization": f"Bearer {verifier.issue('user-1', tenant_id)}"}
+
+
+@pytest.fixture
+def override(db_client, verifier):
+    """London HQ override registered by tenant "acme"."""
+    response = db_client.post("/api/v1/ip-overrides", json=HQ, headers=_auth(verifier, "acme"))
+    assert response.status_code == 201
+    return response.json()
+
+
+@pytest.fixture
+def no_dataset(monkeypatch):
+    """No GeoIP dataset loaded and no enrichers."""
+    monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: None)
+    monkeypatch.setattr(
+        "src.api.lookup_routes.get_enrichment_pipeline", lambda: EnrichmentPipeline()
+    )
+
+
+class TestIpOverrideRoutes:
+    """Test override management."""
+
+    def test_requires_token(self, db_client, verifier):
+        """Should require a bearer token."""
+        response = db_client.get("/api/v1/ip-overrides")
+        assert response.status_code == 401
+
+    def test_create_and_list(self, db_client, verifier, override):
+        """Should store the override and list it for its tenant only."""
+        assert override["network"] == "10.20.0.0/16"
+        response = db_client.get("/api/v1/ip-overrides", headers=_auth(verifier, "acme"))
+        assert [o["override_id"] for o in response.json()] == [override["override_id"]]
+        response = db_client.get("/api/v1/ip-overrides", headers=_auth(verifier, "globex"))
+        assert response.json() == []
+
+    def test_duplicate_is_400(self, db_client, verifier, override):
+        """Should reject a second override for the same network."""
+        response = db_client.post(
+            "/api/v1/ip-overrides", json=HQ, headers=_auth(verifier, "acme")
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_other_tenant_gets_404(self, db_client, verifier, override):
+        """Should hide overrides from other tenants."""
+        response = db_client.get(
+            f"/api/v1/ip-overrides/{override['override_id']}", headers=_auth(verifier, "globex")
+        )
+        assert response.status_code == 404
+
+    def test_delete(self, db_client, verifier, override):
+        """Should delete the override."""
+        url = f"/api/v1/ip-overrides/{override['override_id']}"
+        assert db_client.delete(url, headers=_auth(verifier, "acme")).status_code == 204
+        assert db_client.get(url, headers=_auth(verifier, "acme")).status_code == 404
+
+
+class TestLookupWithOverrides:
+    """Test that overrides take precedence in IP lookups."""
+
+    def test_override_answers_lookup(self, db_client, verifier, override, no_dataset):
+        """The tenant's override should locate the address, even without a dataset."""
+        response = db_client.get("/api/v1/lookup/ip/10.20.3.4", headers=_auth(verifier, "acme"))
+        assert response.status_code == 200
+        body = response.json()
+        assert body["network"] == "10.20.0.0/16"
+        assert body["city_name"] == "London"
+        assert body["granularity"] == "site"
+        assert body["confidence_flag"] == "GREEN"
+        assert body["override"] == {
+            "override_id": override["override_id"],
+            "label": "London HQ",
+            "properties": {"building": "HQ-1"},
+        }
+
+    def test_other_tenants_use_dataset(self, db_client, verifier, override, no_dataset):
+        """Other tenants and anonymous callers should not see the override."""
+        response = db_client.get("/api/v1/lookup/ip/10.20.3.4", headers=_auth(verifier, "globex"))
+        assert response.status_code == 503
+        assert db_client.get("/api/v1/lookup/ip/10.20.3.4").status_code == 503
+
+    def test_invalid_token_is_401(self, db_client, verifier, override, no_dataset):
+        """A token that is sent must be valid."""
+        other = TokenVerifier("another-secret-0123456789abcdef")
+        response = db_client.get("/api/v1/lookup/ip/10.20.3.4", headers=_auth(other, "acme"))
+        assert response.status_code == 401
+
+    def test_batch_uses_overrides(self, db_client, verifier, override, no_dataset):
+        """Batch IP items should also be answered from the tenant's overrides."""
+        response = db_client.post(
+            "/api/v1/lookup/batch",
+            json={"items": [{"ip": "10.20.3.4"}]},
+            headers=_auth(verifier, "acme"),
+        )
+        assert response.status_code == 200
+        assert response.json()["results"][0]["ip"]["override"]["label"] == "London HQ"
@@ -0,0 +1,125 @@
+"""Unit tests for tenant IP range overrides."""
+from datetime import datetime, timedelta, timezone
+
+import pytest
+
+from src.services.ip_override_service import (
+    IpOverrideService,
+    IpOverrideTableCache,
+    OverrideEntry,
+    OverrideTable,
+)
+
+HQ = {"label": "London HQ", "latitude": 51.5155, "longitude": -0.0922, "city_name": "London"}
+
+
+@pytest.fixture
+def override_service(db_session):
+    """Override service with its own table cache."""
+    return IpOverrideService(db_session, cache=IpOverrideTableCache(4), max_per_tenant=3)
+
+
+def _entry(network, label, created_at=datetime(2026, 1, 1)):
+    return OverrideEntry(
+        override_id=label, network=network, label=label,
+        latitude=0.0, longitude=0.0, created_at=created_at,
+    )
+
+
+class TestOverrideTable:
+    """Test matching compiled overrides."""
+
+    def test_most_specific_first(self):
+        """A site range inside a corporate block should win."""
+        table = OverrideTable([_entry("10.0.0.0/8", "corp"), _entry("10.20.0.0/16", "hq")])
+        assert table.match("10.20.3.4").label == "hq"
+        assert table.match("10.99.3.4").label == "corp"
+        assert table.match("192.0.2.1") is None
+
+    def test_as_of_skips_later_overrides(self):
+        """Historical matches should ignore overrides registered afterwards."""
+        table = OverrideTable([
+            _entry("10.0.0.0/8", "corp", datetime(2025, 1, 1)),
+            _entry("10.20.0.0/16", "hq", datetime(2026, 1, 1)),
+        ])
+        as_of = datetime(2025, 6, 1, tzinfo=timezone.utc)
+        assert table.match("10.20.3.4", as_of).label == "corp"
+        assert table.match("10.20.3.4", as_of - timedelta(days=365)) is None
+
+    def test_record(self):
+        """The record should carry the site's location, not the dataset's."""
+        record = OverrideEntry(
+            override_id="o1", network="10.20.0.0/16", label="hq",
+            latitude=51.5, longitude=-0.09, created_at=datetime(2026, 1, 1),
+            accuracy_radius_km=0.5, country_iso_code="GB",
+        ).record("10.20.3.4")
+        assert record.ip_address == "10.20.3.4"
+        assert record.network == "10.20.0.0/16"
+        assert (record.latitude, record.longitude) == (51.5, -0.09)
+        assert record.accuracy_radius_km == 0.5
+        assert record.country_iso_code == "GB"
+
+
+class TestIpOverrideService:
+    """Test override storage, validation and tenant isolation."""
+
+    def test_create_canonicalizes_network(self, override_service):
+        """Host bits should be dropped and single addresses become /32s."""
+        override = override_service.create_override("acme", " 10.20.1.7/16 ", **HQ)
+        assert override.network == "10.20.0.0/16"
+        assert override_service.create_override("acme", "192.0.2.9", **HQ).network == "192.0.2.9/32"
+
+    @pytest.mark.parametrize("network,fields,message", [
+        ("not-a-network", {}, "Invalid network"),
+        ("10.0.0.0/8", {"label": " "}, "label"),
+        ("10.0.0.0/8", {"latitude": 91.0}, "Latitude"),
+        ("10.0.0.0/8", {"longitude": -181.0}, "Longitude"),
+        ("10.0.0.0/8", {"accuracy_radius_km": -1.0}, "accuracy_radius_km"),
+    ])
+    def test_invalid_input(self, override_service, network, fields, message):
+        """Invalid overrides should raise ValueError."""
+        with pytest.raises(ValueError, match=message):
+            override_service.create_override("acme", network, **{**HQ, **fields})
+
+    def test_duplicate_network_rejected(self, override_service):
+        """A tenant should not register the same network twice."""
+        override_service.create_override("acme", "10.20.0.0/16", **HQ)
+        with pytest.raises(ValueError, match="already exists"):
+            override_service.create_override("acme", "10.20.5.5/16", **HQ)
+        # ...but another tenant may
+        override_service.create_override("globex", "10.20.0.0/16", **HQ)
+
+    def test_per_tenant_limit(self, override_service):
+        """Creating more than max_per_tenant overrides should fail."""
+        for i in range(3):
+            override_service.create_override("acme", f"10.{i}.0.0/16", **HQ)
+        with pytest.raises(ValueError, match="maximum of 3"):
+            override_service.create_override("acme", "10.9.0.0/16", **HQ)
+
+    def test_tenant_isolation(self, override_service):
+        """Tenants should only see their own overrides."""
+        override = override_service.create_override("acme", "10.20.0.0/16", **HQ)
+        assert override_service.get_override("globex", override.override_id) is None
+        assert override_service.list_overrides("globex") == []
+        assert override_service.table("globex").match("10.20.3.4") is None
+        assert override_service.table("acme").match("10.20.3.4").label == "London HQ"
+
+    def test_table_follows_changes(self, override_service):
+        """Compiled tables should reflect created and deleted overrides."""
+        assert override_service.table("acme").match("10.20.3.4") is None
+        override = override_service.create_override("acme", "10.20.0.0/16", **HQ)
+        assert override_service.table("acme").match("10.20.3.4") is not None
+        override_service.delete_override(override)
+        assert override_service.table("acme").match("10.20.3.4") is None
+
+    def test_table_cached(self, override_service):
+        """Unchanged overrides should reuse the compiled table."""
+        override_service.create_override("acme", "10.20.0.0/16", **HQ)
+        assert override_service.table("acme") is override_service.table("acme")
+
+    def test_changes_from_other_workers_detected(self, db_session, override_service):
+        """A table cached by one worker should notice rows added by another."""
+        other = IpOverrideService(db_session, cache=IpOverrideTableCache(4), max_per_tenant=3)
+        assert override_service.table("acme").match("10.20.3.4") is None
+        other.create_override("acme", "10.20.0.0/16", **HQ)
+        assert override_service.table("acme").match("10.20.3.4") is not None
@@ -0,0 +1,187 @@
+"""Route tests for asynchronous batch lookup jobs."""
+import pytest
+
+from src.api.job_routes import lookup_row
+from src.services.auth_service import TokenVerifier
+from src.services.batch_job_service import BatchJobService, JobStatus
+from src.services.idempotency_service import (
+    IdempotencyService,
+    InMemoryIdempotencyStore,
+    request_fingerprint,
+)
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API, with a test secret."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+def _auth(verifier, tenant_id="acme"):
+    return {"Authorization": f"Bearer {verifier.issue('user-1', tenant_id)}"}
+
+
+class FakeRunner:
+    """Records submitted jobs instead of running them."""
+
+    def __init__(self, result_dir="."):
+        self.result_dir = result_dir
+        self.submitted = []
+
+    def input_path(self, job_id):
+        return f"{self.result_dir}/{job_id}.input"
+
+    def submit(self, job_id, handler, to_record):
+        self.submitted.append(job_id)
+
+
+@pytest.fixture
+def runner(monkeypatch, tmp_path):
+    """Job runner that runs nothing."""
+    runner = FakeRunner(str(tmp_path))
+    monkeypatch.setattr("src.api.job_routes.get_batch_job_runner", lambda: runner)
+    return runner
+
+
+@pytest.fixture
+def idempotency(monkeypatch):
+    """Process-local idempotency keys, fresh for each test."""
+    service = IdempotencyService(InMemoryIdempotencyStore())
+    monkeypatch.setattr("src.api.idempotency.get_idempotency_service", lambda: service)
+    return service
+
+
+def _submit(db_client, verifier, source="s3://exports/logins.csv", **headers):
+    return db_client.post("/api/v1/jobs", json={"source": source}, headers={**_auth(verifier), **headers})
+
+
+class TestJobRoutes:
+    """Test the /api/v1/jobs endpoints."""
+
+    def test_requires_token(self, db_client, verifier, runner):
+        """Should require a bearer token."""
+        response = db_client.post("/api/v1/jobs", json={"source": "s3://exports/logins.csv"})
+        assert response.status_code == 401
+
+    def test_submit_and_poll(self, db_client, verifier, runner):
+        """Should queue the job, start it and report its status to its tenant only."""
+        response = _submit(db_client, verifier)
+        assert response.status_code == 202
+        job = response.json()
+        assert job["status"] == "queued"
+        assert job["progress"] is None
+        assert runner.submitted == [job["job_id"]]
+
+        polled = db_client.get(f"/api/v1/jobs/{job['job_id']}", headers=_auth(verifier))
+        assert polled.status_code == 200
+        assert polled.json()["source"] == "s3://exports/logins.csv"
+
+        other = db_client.get(f"/api/v1/jobs/{job['job_id']}", headers=_auth(verifier, "globex"))
+        assert other.status_code == 404
+        assert other.json()["detail"]["error_code"] == "E004"
+
+    def test_idempotent_submit(self, db_client, verifier, runner, idempotency):
+        """Retries with the same Idempotency-Key should return the first job, per tenant."""
+        first = _submit(db_client, verifier, **{"Idempotency-Key": "nightly-2026-10-14"})
+        retry = _submit(db_client, verifier, **{"Idempotency-Key": "nightly-2026-10-14"})
+        assert first.status_code == retry.status_code == 202
+        assert retry.json()["job_id"] == first.json()["job_id"]
+        assert runner.submitted == [first.json()["job_id"]]
+
+        other_tenant = db_client.post(
+            "/api/v1/jobs",
+            json={"source": "s3://exports/logins.csv"},
+            headers={**_auth(verifier, "globex"), "Idempotency-Key": "nightly-2026-10-14"},
+        )
+        assert other_tenant.json()["job_id"] != first.json()["job_id"]
+
+    def test_idempotency_key_in_progress_is_409(self, db_client, verifier, runner, idempotency):
+        """A retry racing the first request should be refused."""
+        idempotency.begin("acme:POST /jobs", "racing", request_fingerprint({"source": "s3://exports/logins.csv"}))
+        response = _submit(db_client, verifier, **{"Idempotency-Key": "racing"})
+        assert response.status_code == 409
+        assert response.json()["detail"]["error_code"] == "E009"
+        assert runner.submitted == []
+
+    def test_invalid_source_is_400(self, db_client, verifier, runner):
+        """Should reject references the jobs cannot read."""
+        response = _submit(db_client, verifier, "http://files.example.com/logins.csv")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+        assert runner.submitted == []
+
+    def test_result_before_success_is_409(self, db_client, verifier, runner):
+        """Should refuse the download while the job has not succeeded."""
+        job_id = _submit(db_client, verifier).json()["job_id"]
+
+        response = db_client.get(f"/api/v1/jobs/{job_id}/result", headers=_auth(verifier))
+        assert response.status_code == 409
+        assert response.json()["detail"]["error_code"] == "E008"
+        assert response.json()["detail"]["details"] == {"status": "queued"}
+
+    def test_progress_and_download(self, db_client, db_session, verifier, runner, tmp_path):
+        """Should report progress and serve the NDJSON results once succeeded."""
+        job_id = _submit(db_client, verifier).json()["job_id"]
+        service = BatchJobService(db_session)
+        service.update(job_id, status=JobStatus.RUNNING, total_rows=4, processed_rows=1)
+
+        running = db_client.get(f"/api/v1/jobs/{job_id}", headers=_auth(verifier)).json()
+        assert running["progress"] == 0.25
+        assert running["result_url"] is None
+
+        result = tmp_path / f"{job_id}.ndjson"
+        result.write_text('{"index": 0, "ip": {"ip_address": "81.2.69.142"}}\n')
+        service.update(job_id, status=JobStatus.SUCCEEDED, processed_rows=4, result_path=str(result))
+
+        done = db_client.get(f"/api/v1/jobs/{job_id}", headers=_auth(verifier)).json()
+        assert done["progress"] == 1.0
+        assert done["result_url"] == f"/api/v1/jobs/{job_id}/result"
+
+        download = db_client.get(done["result_url"], headers=_auth(verifier))
+        assert download.status_code == 200
+        assert download.headers["content-type"].startswith("application/x-ndjson")
+        assert download.text == result.read_text()
+
+    def test_upload(self, db_client, verifier, runner, tmp_path):
+        """Should store the uploaded CSV as the job's input and queue it."""
+        response = db_client.post(
+            "/api/v1/jobs/upload",
+            files={"file": ("logins.csv", b"ip\n81.2.69.142\n", "text/csv")},
+            headers=_auth(verifier),
+        )
+        assert response.status_code == 202
+        job = response.json()
+        assert job["source"] == "upload:logins.csv"
+        assert runner.submitted == [job["job_id"]]
+        assert (tmp_path / f"{job['job_id']}.input").read_bytes() == b"ip\n81.2.69.142\n"
+
+    def test_upload_without_lookup_columns_is_400(self, db_client, verifier, runner, tmp_path):
+        """Should check the header before queuing a job."""
+        response = db_client.post(
+            "/api/v1/jobs/upload",
+            files={"file": ("logins.csv", b"address\n81.2.69.142\n", "text/csv")},
+            headers=_auth(verifier),
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+        assert runner.submitted == []
+        assert list(tmp_path.iterdir()) == []
+
+    def test_oversized_upload_is_413(self, db_client, verifier, runner, monkeypatch):
+        """Should refuse uploads over UPLOAD_MAX_BYTES."""
+        monkeypatch.setenv("UPLOAD_MAX_BYTES", "8")
+        response = db_client.post(
+            "/api/v1/jobs/upload",
+            files={"file": ("logins.csv", b"ip\n81.2.69.142\n", "text/csv")},
+            headers=_auth(verifier),
+        )
+        assert response.status_code == 413
+        assert response.json()["detail"]["details"] == {"max_bytes": 8}
+        assert runner.submitted == []
+
+    def test_invalid_coordinate_row(self):
+        """Should report an unparsable coordinate as a row error."""
+        with pytest.raises(ValueError, match="Invalid lat: north"):
+            lookup_row({"ip": None, "lat": "north", "lon": "2.29"})
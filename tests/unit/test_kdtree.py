@@ -0,0 +1,71 @@
+"""Unit tests for the spherical k-d tree."""
+import random
+
+import pytest
+
+from src.spatial.distance import haversine
+from src.spatial.kdtree import KDTree, chord_to_meters, meters_to_chord
+
+
+def _brute_force(points, latitude, longitude, k, radius=None):
+    ranked = sorted(
+        (haversine(latitude, longitude, lat, lon).meters, item) for lat, lon, item in points
+    )
+    if radius is not None:
+        ranked = [r for r in ranked if r[0] <= radius]
+    return ranked[:k]
+
+
+class TestKDTree:
+    """Test nearest-neighbor queries."""
+
+    def test_matches_brute_force(self):
+        """Results should equal an exhaustive haversine ranking."""
+        rng = random.Random(7)
+        points = [(rng.uniform(-90, 90), rng.uniform(-180, 180), i) for i in range(2000)]
+        tree = KDTree(points)
+        assert len(tree) == 2000
+
+        for _ in range(25):
+            lat, lon = rng.uniform(-90, 90), rng.uniform(-180, 180)
+            expected = _brute_force(points, lat, lon, 5, radius=1_500_000)
+            actual = tree.nearest(lat, lon, k=5, max_distance_meters=1_500_000)
+            assert [item for _, item in actual] == [item for _, item in expected]
+            for (d1, _), (d2, _) in zip(actual, expected):
+                assert d1 == pytest.approx(d2, abs=0.01)
+
+    def test_across_antimeridian(self):
+        """Points just across the antimeridian should be nearest."""
+        tree = KDTree([(0.0, -179.99, "east"), (0.0, 170.0, "far")])
+        (distance, item), = tree.nearest(0.0, 179.99, k=1)
+        assert item == "east"
+        assert distance == pytest.approx(2224, rel=0.01)
+
+    def test_radius_limits_results(self):
+        """Points beyond the radius should be excluded."""
+        tree = KDTree([(51.5007, -0.1246, "a"), (51.5081, -0.0759, "b")])
+        results = tree.nearest(51.5033, -0.1196, k=10, max_distance_meters=1000)
+        assert [item for _, item in results] == ["a"]
+
+    def test_fewer_points_than_k(self):
+        """Asking for more points than exist should return all, nearest first."""
+        tree = KDTree([(0.0, 1.0, "far"), (0.0, 0.1, "near")])
+        assert [item for _, item in tree.nearest(0.0, 0.0, k=5)] == ["near", "far"]
+
+    def test_empty_tree(self):
+        """An empty tree should return no results."""
+        assert KDTree([]).nearest(0.0, 0.0, k=3) == []
+
+    def test_invalid_arguments(self):
+        """Bad k or coordinates should raise ValueError."""
+        with pytest.raises(ValueError):
+            KDTree([(91.0, 0.0, "x")])
+        tree = KDTree([(0.0, 0.0, "x")])
+        with pytest.raises(ValueError):
+            tree.nearest(0.0, 0.0, k=0)
+        with pytest.raises(ValueError):
+            tree.nearest(0.0, 200.0, k=1)
+
+    def test_chord_round_trip(self):
+        """Chord conversion should invert meters conversion."""
+        assert chord_to_meters(meters_to_chord(12345.0)) == pytest.approx(12345.0)
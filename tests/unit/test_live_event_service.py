@@ -0,0 +1,131 @@
+"""Tests for the live event hub."""
+import asyncio
+import threading
+from datetime import datetime, timezone
+
+import pytest
+
+from src.services.geofence_alert_service import GeofenceAlertEvent
+from src.services.live_event_service import (
+    LiveEventHub,
+    alert_event,
+    detection_event,
+    position_event,
+)
+
+
+def _position(tenant_id="acme", entity_id="truck-17", geofence_ids=("depot",)):
+    return position_event(
+        tenant_id, entity_id, 51.505, -0.125, list(geofence_ids), datetime(2026, 3, 1, 10, 0)
+    )
+
+
+ALERT = GeofenceAlertEvent(
+    alert_id="a-1",
+    entity_id="truck-17",
+    geofence_id="depot",
+    event="enter",
+    occurred_at=datetime(2026, 3, 1, 10, 0),
+    latitude=51.505,
+    longitude=-0.125,
+)
+
+
+class TestLiveEvents:
+    """Test event construction."""
+
+    def test_position_event(self):
+        """Should carry the coordinate and containing fences, dated in UTC."""
+        event = position_event(
+            "acme", "truck-17", 51.505, -0.125, ["depot"],
+            datetime(2026, 3, 1, 11, 0, tzinfo=timezone.utc).astimezone(),
+        )
+
+        assert event.to_dict() == {
+            "type": "position",
+            "entity_id": "truck-17",
+            "occurred_at": "2026-03-01T11:00:00+00:00",
+            "data": {"latitude": 51.505, "longitude": -0.125, "geofence_ids": ["depot"]},
+        }
+
+    def test_alert_event(self):
+        """Should be typed like the webhook event and carry the alert."""
+        event = alert_event("acme", ALERT)
+
+        assert event.type == "geofence.enter"
+        assert event.geofence_ids == ["depot"]
+        assert event.data["alert_id"] == "a-1"
+        assert event.data["occurred_at"] == "2026-03-01T10:00:00+00:00"
+
+    def test_detection_event(self):
+        """Should carry the geolocated position and its fences."""
+        event = detection_event(
+            "acme", "det-1", "vehicle", "cam-1", 51.505, -0.125, "GREEN", ["depot"],
+            datetime(2026, 3, 1, 10, 0, tzinfo=timezone.utc),
+        )
+
+        assert event.type == "detection"
+        assert event.entity_id is None
+        assert event.geofence_ids == ["depot"]
+        assert event.data["detection_id"] == "det-1"
+        assert event.to_dict()["occurred_at"] == "2026-03-01T10:00:00+00:00"
+
+
+class TestLiveEventHub:
+    """Test subscription filtering and delivery."""
+
+    def test_filters_by_tenant_and_entity(self):
+        """Should only deliver the subscribed tenant's entity."""
+        hub = LiveEventHub()
+        truck = hub.subscribe("acme", entity_id="truck-17")
+        fleet = hub.subscribe("acme")
+
+        assert hub.publish(_position()) == 2
+        assert hub.publish(_position(entity_id="van-3")) == 1
+        assert hub.publish(_position(tenant_id="globex")) == 0
+        assert truck.queue.qsize() == 1
+        assert fleet.queue.qsize() == 2
+
+    def test_filters_by_geofence_and_type(self):
+        """Should only deliver events of the listed fences and types."""
+        hub = LiveEventHub()
+        subscription = hub.subscribe("acme", geofence_ids=["depot"], types=["geofence.enter"])
+
+        assert hub.publish(_position()) == 0
+        assert hub.publish(alert_event("acme", ALERT)) == 1
+        assert hub.publish(_position(geofence_ids=())) == 0
+        assert subscription.queue.qsize() == 1
+
+    def test_unsubscribe(self):
+        """Should stop delivering to a removed subscription."""
+        hub = LiveEventHub()
+        subscription = hub.subscribe("acme")
+        hub.unsubscribe(subscription)
+
+        assert hub.publish(_position()) == 0
+        assert hub.subscriber_count == 0
+
+    def test_slow_client_drops_oldest(self):
+        """Should keep the newest events when the queue is full."""
+        hub = LiveEventHub(queue_size=2)
+        subscription = hub.subscribe("acme")
+        for entity_id in ("a", "b", "c"):
+            hub.publish(_position(entity_id=entity_id))
+
+        assert subscription.dropped == 1
+        assert [subscription.queue.get_nowait().entity_id for _ in range(2)] == ["b", "c"]
+
+    @pytest.mark.asyncio
+    async def test_publish_from_another_thread(self):
+        """Should wake a waiting subscriber when published from a worker thread."""
+        hub = LiveEventHub()
+        subscription = hub.subscribe("acme")
+
+        waiting = asyncio.ensure_future(subscription.get())
+        await asyncio.sleep(0)
+        thread = threading.Thread(target=hub.publish, args=(_position(),))
+        thread.start()
+        thread.join()
+
+        event = await asyncio.wait_for(waiting, timeout=1)
+        assert event.entity_id == "truck-17"
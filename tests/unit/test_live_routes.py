@@ -0,0 +1,162 @@
+"""Route tests for live tracking over WebSocket and the SSE event feed."""
+import json
+from datetime import datetime
+
+import pytest
+from fastapi import WebSocketDisconnect
+
+from src.api.live_routes import FEED_EVENTS, sse_messages
+from src.services.auth_service import TokenVerifier
+from src.services.geofence_alert_service import GeofenceAlertEvent
+from src.services.live_event_service import LiveEventHub, alert_event
+
+
+DEPOT = {
+    "type": "Polygon",
+    "coordinates": [[[-0.13, 51.50], [-0.12, 51.50], [-0.12, 51.51],
+                     [-0.13, 51.51], [-0.13, 51.50]]],
+}
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API, with a test secret."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    monkeypatch.setattr("src.api.live_routes.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+@pytest.fixture
+def hub(monkeypatch):
+    """Fresh live event hub shared by the position and WebSocket routes."""
+    hub = LiveEventHub()
+    monkeypatch.setattr("src.api.live_routes.get_live_event_hub", lambda: hub)
+    monkeypatch.setattr("src.api.geofence_routes.get_live_event_hub", lambda: hub)
+    return hub
+
+
+@pytest.fixture
+def depot(db_client):
+    """Geofence created through the API."""
+    response = db_client.post("/api/v1/geofences", json={"name": "Depot", "geometry": DEPOT})
+    assert response.status_code == 201
+    return response.json()
+
+
+def _token(verifier, tenant_id="acme"):
+    return verifier.issue("user-1", tenant_id)
+
+
+def _position(db_client, verifier, lat, lon, timestamp, entity_id="truck-17", tenant_id="acme"):
+    return db_client.post(
+        "/api/v1/geofences/positions",
+        json={"entity_id": entity_id, "latitude": lat, "longitude": lon, "timestamp": timestamp},
+        headers={"Authorization": f"Bearer {_token(verifier, tenant_id)}"},
+    )
+
+
+class TestTrackEntity:
+    """Test /api/v1/ws/entities/{entity_id}."""
+
+    def test_pushes_position_and_transition(self, db_client, verifier, hub, depot):
+        """Should push the entity's positions and the geofence events they confirm."""
+        headers = {"Authorization": f"Bearer {_token(verifier)}"}
+        with db_client.websocket_connect("/api/v1/ws/entities/truck-17", headers=headers) as ws:
+            assert hub.subscriber_count == 1
+            _position(db_client, verifier, 51.505, -0.125, "2026-03-01T10:00:00Z")
+
+            position = ws.receive_json()
+            assert position["type"] == "position"
+            assert position["entity_id"] == "truck-17"
+            assert position["occurred_at"] == "2026-03-01T10:00:00+00:00"
+            assert position["data"]["geofence_ids"] == [depot["geofence_id"]]
+
+            entered = ws.receive_json()
+            assert entered["type"] == "geofence.enter"
+            assert entered["data"]["geofence_id"] == depot["geofence_id"]
+
+        assert hub.subscriber_count == 0
+
+    def test_only_own_entity_and_tenant(self, db_client, verifier, hub, depot):
+        """Should not push other entities or other tenants' reports."""
+        url = f"/api/v1/ws/entities/truck-17?token={_token(verifier)}"
+        with db_client.websocket_connect(url) as ws:
+            _position(db_client, verifier, 51.505, -0.125, "2026-03-01T10:00:00Z", entity_id="van-3")
+            _position(db_client, verifier, 51.505, -0.125, "2026-03-01T10:00:00Z", tenant_id="globex")
+            _position(db_client, verifier, 51.515, -0.125, "2026-03-01T10:00:00Z")
+
+            message = ws.receive_json()
+            assert message["type"] == "position"
+            assert message["data"]["geofence_ids"] == []
+
+    def test_stale_report_is_not_pushed(self, db_client, verifier, hub, depot):
+        """Should skip reports older than the entity's last one."""
+        url = f"/api/v1/ws/entities/truck-17?token={_token(verifier)}"
+        _position(db_client, verifier, 51.505, -0.125, "2026-03-01T10:05:00Z")
+        with db_client.websocket_connect(url) as ws:
+            assert _position(db_client, verifier, 51.515, -0.125, "2026-03-01T10:00:00Z").json()["stale"]
+            _position(db_client, verifier, 51.505, -0.126, "2026-03-01T10:06:00Z")
+
+            assert ws.receive_json()["occurred_at"] == "2026-03-01T10:06:00+00:00"
+
+    def test_invalid_token_is_rejected(self, db_client, verifier, hub):
+        """Should close the connection with a policy violation."""
+        with pytest.raises(WebSocketDisconnect) as error:
+            with db_client.websocket_connect("/api/v1/ws/entities/truck-17?token=not-a-jwt"):
+                pass
+        assert error.value.code == 1008
+
+    def test_missing_token_is_rejected(self, db_client, verifier, hub):
+        """Should require a token."""
+        with pytest.raises(WebSocketDisconnect) as error:
+            with db_client.websocket_connect("/api/v1/ws/entities/truck-17"):
+                pass
+        assert error.value.code == 1008
+        assert hub.subscriber_count == 0
+
+
+class TestEventStream:
+    """Test /api/v1/events/stream."""
+
+    def test_requires_token(self, db_client, verifier, hub):
+        """Should reject a request without a bearer token."""
+        response = db_client.get("/api/v1/events/stream")
+        assert response.status_code == 401
+        assert response.json()["detail"]["error_code"] == "E006"
+
+    def test_unknown_event_type_is_400(self, db_client, verifier, hub):
+        """Should reject event types the feed does not carry."""
+        response = db_client.get(
+            "/api/v1/events/stream", params={"event": "position", "token": _token(verifier)}
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["details"]["supported"] == list(FEED_EVENTS)
+        assert hub.subscriber_count == 0
+
+    @pytest.mark.asyncio
+    async def test_messages(self):
+        """Should send matching events as named SSE messages until disconnect."""
+        hub = LiveEventHub()
+        subscription = hub.subscribe("acme", geofence_ids=["depot"], types=FEED_EVENTS)
+        alert = GeofenceAlertEvent(
+            "a-1", "truck-17", "depot", "exit", datetime(2026, 3, 1, 10, 0), 51.515, -0.125
+        )
+        hub.publish(alert_event("acme", alert))
+        hub.publish(alert_event("acme", GeofenceAlertEvent(
+            "a-2", "truck-17", "yard", "enter", datetime(2026, 3, 1, 10, 0), 51.515, -0.125
+        )))
+
+        checks = iter([False, False, True])
+
+        async def is_disconnected():
+            return next(checks)
+
+        messages = [m async for m in sse_messages(subscription, is_disconnected, keepalive_seconds=0.01)]
+
+        assert messages[0] == ": connected\n\n"
+        name, data = messages[1].rstrip("\n").split("\n")
+        assert name == "event: geofence.exit"
+        assert json.loads(data[len("data: "):])["data"]["alert_id"] == "a-1"
+        # Nothing else matches, so the idle period ends in a keep-alive
+        assert messages[2:] == [": keep-alive\n\n"]
@@ -0,0 +1,87 @@
+"""Unit tests for the load test harness."""
+import asyncio
+import random
+
+import pytest
+
+from tests.load.detection_api import DEFAULT_IPS, operations, parse_mix
+from tests.load.harness import LoadRequest, LoadResults, Operation, OperationStats, parse_slo, percentile, run_load
+
+
+class TestSlos:
+    """Test SLO parsing and evaluation."""
+
+    def test_parse_slo(self):
+        """SLOs should read as operation:pNN=milliseconds."""
+        slo = parse_slo("lookup:p99.9=150")
+        assert (slo.operation, slo.percentile, slo.max_ms, str(slo)) == ("lookup", 99.9, 150, "lookup:p99.9=150")
+        for raw in ("lookup", "lookup:99=150", "lookup:p99", "lookup:p0=10", "lookup:p99=-1", ":p99=10"):
+            with pytest.raises(ValueError):
+                parse_slo(raw)
+
+    def test_percentile(self):
+        """Percentiles should be nearest-rank."""
+        values = list(range(1, 101))
+        assert (percentile(values, 50), percentile(values, 95), percentile(values, 100)) == (50, 95, 100)
+        assert percentile([7], 99) == 7
+
+    def test_violations(self):
+        """Missed percentiles and a high error rate should be reported, met ones not."""
+        results = LoadResults(1, {
+            "lookup": OperationStats([10.0] * 98 + [400.0] * 2),
+            "detect": OperationStats([20.0] * 10, {"HTTP 503": 1}),
+        })
+        slos = [parse_slo(s) for s in ("lookup:p95=50", "lookup:p99=100", "detect:p99=50", "batch:p99=1")]
+        assert results.violations(slos, max_error_rate=0.01) == ["lookup:p99=100: measured 400.0 ms"]
+        assert results.violations([parse_slo("*:p50=5")], max_error_rate=0.001) == [
+            "*:p50=5: measured 10.0 ms", "error rate 0.91% above 0.10%",
+        ]
+        assert "detect errors: 1 x HTTP 503" in results.report()
+
+
+class TestRunLoad:
+    """Test driving a workload."""
+
+    async def test_open_loop(self):
+        """Requests should follow the mix and rate, and slow answers count from when they were due."""
+        sent = []
+
+        async def send(request):
+            sent.append(request.path)
+            if request.path == "/slow":
+                await asyncio.sleep(0.05)
+                return 200
+            return 503 if request.path == "/down" else 200
+
+        mix = [
+            Operation("fast", 8, lambda rng: LoadRequest("GET", "/fast")),
+            Operation("slow", 1, lambda rng: LoadRequest("GET", "/slow")),
+            Operation("down", 1, lambda rng: LoadRequest("GET", "/down")),
+        ]
+        results = await run_load(send, mix, rate=500, duration_seconds=0.4, max_in_flight=4, seed=1)
+        assert 120 < len(sent) < 280
+        assert sent.count("/fast") > 4 * sent.count("/slow")
+        assert percentile(results.operations["slow"].latencies_ms, 50) >= 50
+        assert results.operations["down"].errors == {"HTTP 503": results.operations["down"].requests}
+        # Same seed, same requests
+        again = []
+
+        async def record(request):
+            again.append(request.path)
+            return 200
+
+        await run_load(record, mix, rate=500, duration_seconds=0.4, seed=1)
+        assert again == sent
+
+    def test_detection_workload(self):
+        """The scenario should build requests for every weighted operation."""
+        ops = {op.name: op for op in operations(DEFAULT_IPS, parse_mix("lookup=1,batch=1,detect=1,geofence=0"))}
+        assert set(ops) == {"lookup", "batch", "detect"}
+        rng = random.Random(1)
+        assert ops["lookup"].build(rng).path.split("/")[-1] in DEFAULT_IPS
+        assert len(ops["batch"].build(rng).json["items"]) == 100
+        assert 404 in ops["detect"].ok_statuses and ops["batch"].ok_statuses == {200}
+        with pytest.raises(ValueError, match="geocode"):
+            operations(DEFAULT_IPS, parse_mix("geocode=1"))
+        with pytest.raises(ValueError):
+            parse_mix("lookup")
@@ -0,0 +1,97 @@
+"""Unit tests for locale and language inference."""
+import pytest
+
+from src.services.ip_lookup_service import IpLookupResult, normalize_ip
+from src.services.locale_service import LocaleDirectory, LocaleEnricher, normalize_tag
+from src.services.mmdb_service import GeoIPRecord
+
+
+def _country_row(iso, name, languages):
+    """A countryInfo.txt row with the languages in column 16."""
+    columns = [iso, "", "", "", name] + [""] * 10 + [languages, "", "", ""]
+    return "\t".join(columns)
+
+
+COUNTRY_INFO = "\n".join([
+    "# GeoNames country info",
+    "#ISO\tISO3\tISO-Numeric\tfips\tCountry\t...",
+    _country_row("BR", "Brazil", "pt-BR,es,en,fr"),
+    _country_row("CA", "Canada", "en-CA,fr-CA,iu"),
+    _country_row("DE", "Germany", "de"),
+    _country_row("AQ", "Antarctica", ""),
+]) + "\n"
+
+
+@pytest.fixture
+def directory(tmp_path):
+    """Locale directory loaded from a small countryInfo.txt."""
+    path = tmp_path / "countryInfo.txt"
+    path.write_text(COUNTRY_INFO)
+    return LocaleDirectory.from_country_info(str(path))
+
+
+class TestNormalizeTag:
+    """Test BCP 47 tag casing."""
+
+    @pytest.mark.parametrize("tag,expected", [
+        ("PT-br", "pt-BR"), ("zh_hant_tw", "zh-Hant-TW"), ("es-419", "es-419"), ("  ", None),
+    ])
+    def test_casing(self, tag, expected):
+        """Language, script and region subtags should get their canonical case."""
+        assert normalize_tag(tag) == expected
+
+
+class TestLocaleDirectory:
+    """Test country and subdivision language inference."""
+
+    def test_load(self, directory):
+        """Countries without a language list should be skipped."""
+        assert len(directory) == 3
+
+    def test_country_locale(self, directory):
+        """The first language should be the locale."""
+        info = directory.infer("BR")
+        assert info.locale == "pt-BR"
+        assert info.languages == ["pt-BR", "es", "en", "fr"]
+
+    def test_bare_language_gets_region(self, directory):
+        """A language listed without a region should be qualified by the country."""
+        assert directory.infer("de").locale == "de-DE"
+
+    def test_subdivision_override(self, directory):
+        """Quebec should suggest French first."""
+        info = directory.infer("CA", ["QC"])
+        assert info.locale == "fr-CA"
+        assert info.languages == ["fr-CA", "en-CA", "iu"]
+
+    def test_unknown_country(self, directory):
+        """Unknown or missing countries should give no locale."""
+        assert directory.infer("ZZ") is None
+        assert directory.infer(None) is None
+
+    def test_empty_file(self, tmp_path):
+        """A file without language lists should raise ValueError."""
+        path = tmp_path / "countryInfo.txt"
+        path.write_text("# nothing\n")
+        with pytest.raises(ValueError):
+            LocaleDirectory.from_country_info(str(path))
+
+
+class TestLocaleEnricher:
+    """Test the locale enrichment block."""
+
+    def test_block(self, directory):
+        """The block should use the record's country and subdivisions."""
+        record = GeoIPRecord(
+            ip_address="24.37.0.1", network="24.37.0.0/16", country_iso_code="CA",
+            subdivisions=[{"iso_code": "QC", "name": "Quebec"}],
+        )
+        result = IpLookupResult(normalized=normalize_ip("24.37.0.1"), record=record)
+        assert LocaleEnricher(directory).enrich(result) == {
+            "locale": "fr-CA", "languages": ["fr-CA", "en-CA", "iu"],
+        }
+
+    def test_no_record(self, directory):
+        """Lookups without a record should produce no block."""
+        result = IpLookupResult(normalized=normalize_ip("24.37.0.1"))
+        assert LocaleEnricher(directory).enrich(result) is None
@@ -0,0 +1,181 @@
+"""Unit tests for per-tenant encryption of device location history."""
+import base64
+import json
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.models.database_models import DeviceLocation, TenantDataKey
+from src.services import location_encryption_service
+from src.services.device_history_service import DeviceHistoryService, DeviceObservation
+from src.services.location_encryption_service import (
+    KmsMasterKey,
+    LocalMasterKey,
+    LocationCipher,
+    get_master_keys,
+    location_cipher,
+)
+
+T0 = datetime(2026, 3, 1, 9, 0)
+KEY_A = LocalMasterKey(b"a" * 32)
+KEY_B = LocalMasterKey(b"b" * 32)
+
+
+def _seen(minutes=0, city="London"):
+    return DeviceObservation(
+        T0 + timedelta(minutes=minutes), "GB", city, 51.5142, -0.0931, 10.0, "81.2.69.142"
+    )
+
+
+class FakeKms:
+    """Stand-in for a boto3 KMS client; binds ciphertexts to their context."""
+
+    def __init__(self):
+        self.calls = []
+
+    def encrypt(self, KeyId, Plaintext, EncryptionContext):
+        self.calls.append(("encrypt", KeyId, EncryptionContext))
+        return {"CiphertextBlob": json.dumps(EncryptionContext).encode() + b"|" + Plaintext}
+
+    def decrypt(self, CiphertextBlob, EncryptionContext):
+        self.calls.append(("decrypt", EncryptionContext))
+        context, _, plaintext = CiphertextBlob.partition(b"|")
+        if json.loads(context) != EncryptionContext:
+            raise ValueError("InvalidCiphertextException")
+        return {"Plaintext": plaintext}
+
+
+@pytest.fixture(autouse=True)
+def fresh_keys(monkeypatch):
+    """Empty data key cache and configuration-derived master keys."""
+    monkeypatch.setattr(location_encryption_service, "_data_keys", {})
+    monkeypatch.setattr(location_encryption_service, "_master_keys", None)
+
+
+def _forget_data_keys(monkeypatch):
+    monkeypatch.setattr(location_encryption_service, "_data_keys", {})
+
+
+class TestSealing:
+    """Test storing and reading encrypted sightings."""
+
+    def test_round_trip(self, db_session):
+        """Sightings should read back unchanged, with nothing precise stored in clear."""
+        service = DeviceHistoryService(db_session, LocationCipher(db_session, KEY_A))
+        service.record("fp-1", _seen(), tenant_id="acme")
+
+        [observation] = service.history("fp-1", tenant_id="acme")
+        assert (observation.city_name, observation.latitude, observation.ip_address) == (
+            "London", 51.5142, "81.2.69.142"
+        )
+        row = db_session.query(DeviceLocation).one()
+        assert row.country_code == "GB" and row.observed_at == T0
+        assert (row.city_name, row.latitude, row.longitude, row.accuracy_km, row.ip_address) == (None,) * 5
+        assert "London" not in row.sealed and "81.2.69.142" not in row.sealed
+        key = db_session.query(TenantDataKey).one()
+        assert key.tenant_id == "acme" and key.master_key_id == KEY_A.key_id
+        assert service.countries("fp-1", "acme")[0].country_code == "GB"
+
+    def test_tenants_isolated(self, db_session):
+        """Each tenant should get its own key, and rows moved across tenants should not open."""
+        cipher = LocationCipher(db_session, KEY_A)
+        service = DeviceHistoryService(db_session, cipher)
+        service.record("fp-1", _seen(), tenant_id="acme")
+        service.record("fp-1", _seen(), tenant_id="globex")
+        assert db_session.query(TenantDataKey).count() == 2
+
+        acme_row, globex_row = db_session.query(DeviceLocation).order_by(DeviceLocation.id).all()
+        assert acme_row.data_key_id != globex_row.data_key_id
+        acme_row.tenant_id = "globex"
+        with pytest.raises(RuntimeError, match="Cannot decrypt"):
+            cipher.open(acme_row)
+        acme_key = db_session.query(TenantDataKey).filter(TenantDataKey.tenant_id == "acme").one()
+        with pytest.raises(RuntimeError, match="Cannot unwrap"):
+            KEY_A.unwrap(base64.b64decode(acme_key.wrapped_key), "globex")
+
+    def test_unconfigured_reader_refused(self, db_session):
+        """Encrypted rows should not be returned without the cipher."""
+        DeviceHistoryService(db_session, LocationCipher(db_session, KEY_A)).record("fp-1", _seen())
+        with pytest.raises(RuntimeError, match="not configured"):
+            DeviceHistoryService(db_session).history("fp-1")
+
+
+class TestRotation:
+    """Test rotating data keys and master keys."""
+
+    def test_rotate_and_reencrypt(self, db_session):
+        """Rows should move to the new key in batches and the old key be deleted."""
+        cipher = LocationCipher(db_session, KEY_A)
+        service = DeviceHistoryService(db_session, cipher)
+        for minutes in range(3):
+            service.record("fp-1", _seen(minutes), tenant_id="acme")
+        old_key = cipher.active_key("acme")
+        new_key = cipher.rotate_data_key("acme")
+        service.record("fp-1", _seen(3), tenant_id="acme")
+        assert [(k.key_id, rows) for k, rows in cipher.list_keys("acme")] == [
+            (old_key.key_id, 3), (new_key.key_id, 1)
+        ]
+        assert len(service.history("fp-1", tenant_id="acme")) == 4
+
+        first = cipher.reencrypt("acme", limit=2)
+        assert (first.reencrypted, first.remaining, first.deleted_keys) == (2, 1, [])
+        second = cipher.reencrypt("acme", limit=2)
+        assert (second.reencrypted, second.remaining, second.deleted_keys) == (1, 0, [old_key.key_id])
+        assert [k.key_id for k, _ in cipher.list_keys("acme")] == [new_key.key_id]
+        assert [o.city_name for o in service.history("fp-1", tenant_id="acme")] == ["London"] * 4
+
+    def test_reencrypt_seals_plaintext_rows(self, db_session):
+        """Rows stored before encryption was enabled should be sealed."""
+        DeviceHistoryService(db_session).record("fp-1", _seen(), tenant_id="acme")
+        assert db_session.query(DeviceLocation).one().city_name == "London"
+
+        result = LocationCipher(db_session, KEY_A).reencrypt("acme")
+        assert (result.reencrypted, result.remaining) == (1, 0)
+        row = db_session.query(DeviceLocation).one()
+        assert row.city_name is None and row.sealed
+        service = DeviceHistoryService(db_session, LocationCipher(db_session, KEY_A))
+        assert service.history("fp-1", tenant_id="acme")[0].latitude == 51.5142
+
+    def test_rewrap_master_key(self, db_session, monkeypatch):
+        """Data keys should be re-wrapped with a new master key while the previous one unwraps them."""
+        DeviceHistoryService(db_session, LocationCipher(db_session, KEY_A)).record("fp-1", _seen(), "acme")
+        _forget_data_keys(monkeypatch)
+        with pytest.raises(RuntimeError, match="No master key"):
+            DeviceHistoryService(db_session, LocationCipher(db_session, KEY_B)).history("fp-1", "acme")
+
+        assert LocationCipher(db_session, KEY_B, [KEY_A]).rewrap_data_keys() == 1
+        assert db_session.query(TenantDataKey).one().master_key_id == KEY_B.key_id
+        _forget_data_keys(monkeypatch)
+        service = DeviceHistoryService(db_session, LocationCipher(db_session, KEY_B))
+        assert service.history("fp-1", "acme")[0].city_name == "London"
+
+    def test_kms_master_key(self, db_session):
+        """KMS should wrap data keys with the tenant as encryption context."""
+        kms = FakeKms()
+        master_key = KmsMasterKey("alias/geo-locations", client=kms)
+        wrapped = master_key.wrap(b"k" * 32, "acme")
+        assert master_key.unwrap(wrapped, "acme") == b"k" * 32
+        assert kms.calls[0] == ("encrypt", "alias/geo-locations", {"tenant_id": "acme"})
+        with pytest.raises(RuntimeError, match="KMS decrypt failed"):
+            master_key.unwrap(wrapped, "globex")
+        assert master_key.can_unwrap("arn:aws:kms:eu-west-1:111122223333:key/old")
+        assert not master_key.can_unwrap(KEY_A.key_id)
+
+
+class TestConfiguration:
+    """Test master keys built from the settings."""
+
+    def test_off_by_default(self, db_session):
+        """Without a master key, history should be stored in plaintext."""
+        assert location_cipher(db_session) is None
+
+    def test_local_master_key(self, db_session, monkeypatch):
+        """A base64 master key should enable encryption; malformed keys are refused."""
+        monkeypatch.setenv("LOCATION_ENCRYPTION_MASTER_KEY", "YWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWFhYWE=")
+        cipher = location_cipher(db_session)
+        assert cipher.master_key.key_id == KEY_A.key_id
+
+        monkeypatch.setenv("LOCATION_ENCRYPTION_MASTER_KEY", "c2hvcnQ=")
+        monkeypatch.setattr(location_encryption_service, "_master_keys", None)
+        with pytest.raises(RuntimeError, match="misconfigured"):
+            get_master_keys()
@@ -0,0 +1,54 @@
+"""Unit tests for GPS versus IP location mismatch detection."""
+import pytest
+
+from src.services.location_mismatch_service import LocationMismatchDetector, MismatchCause
+
+LONDON = (51.5142, -0.0931)
+READING = (51.4543, -0.9781)
+NEW_YORK = (40.7128, -74.0060)
+
+
+@pytest.fixture
+def detector():
+    """Detector flagging more than 500 km of unexplained distance."""
+    return LocationMismatchDetector(threshold_km=500)
+
+
+class TestLocationMismatch:
+    """Test device versus IP comparisons."""
+
+    def test_nearby_matches(self, detector):
+        """A device near its IP location should not be flagged."""
+        result = detector.compare(*READING, *LONDON, ip_accuracy_km=50)
+        assert result.distance_km == pytest.approx(61.6, abs=0.5)
+        assert result.excess_km == pytest.approx(11.6, abs=0.5)
+        assert result.mismatch is False
+        assert result.likely_cause is None
+
+    def test_distant_without_anonymizer(self, detector):
+        """A distant device with a clean IP suggests spoofed coordinates."""
+        result = detector.compare(*NEW_YORK, *LONDON, ip_accuracy_km=10)
+        assert result.mismatch is True
+        assert result.likely_cause == MismatchCause.GPS_SPOOFING
+
+    def test_anonymizer_explains(self, detector):
+        """Anonymizer flags should be reported as the likely cause."""
+        flags = {"vpn": True, "tor": False, "proxy": None, "hosting": True}
+        result = detector.compare(*NEW_YORK, *LONDON, anonymizer=flags)
+        assert result.likely_cause == MismatchCause.VPN
+
+    def test_accuracy_radii_subtracted(self):
+        """Reported accuracies should absorb part of the distance."""
+        result = LocationMismatchDetector(threshold_km=5500).compare(
+            *NEW_YORK, *LONDON, ip_accuracy_km=50, device_accuracy_m=30000
+        )
+        assert result.distance_km > 5500
+        assert result.excess_km == pytest.approx(result.distance_km - 80, abs=0.1)
+        assert result.mismatch is False
+
+    def test_invalid_input(self, detector):
+        """Out-of-range coordinates and negative accuracy should be rejected."""
+        with pytest.raises(ValueError):
+            detector.compare(91.0, 0.0, *LONDON)
+        with pytest.raises(ValueError):
+            detector.compare(*READING, *LONDON, ip_accuracy_km=-1)
@@ -0,0 +1,292 @@
+"""Unit tests for the read-through lookup cache."""
+import threading
+import time
+
+import pytest
+from pydantic import BaseModel
+
+from src.services import lookup_cache_service
+from src.services.enrichment_service import EnrichmentPipeline
+from src.services.ip_lookup_service import IpLookupService
+from src.services.lookup_cache_service import (
+    InMemoryLookupCacheStore,
+    LookupCache,
+    LookupCacheStore,
+    LookupType,
+    CacheTier,
+    SingleFlight,
+    TieredCache,
+    parse_ttls,
+    query_key,
+)
+from src.services.mmdb_service import MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+
+class Answer(BaseModel):
+    value: int
+    note: str = ""
+
+
+class FakeClock:
+    def __init__(self):
+        self.now = 0.0
+
+    def __call__(self):
+        return self.now
+
+
+class BrokenStore(LookupCacheStore):
+    def get(self, key):
+        raise ConnectionError("redis down")
+
+    def set(self, key, value, ttl_seconds):
+        raise ConnectionError("redis down")
+
+    def delete_prefix(self, prefix):
+        raise ConnectionError("redis down")
+
+
+class TestLookupCache:
+    """Test reading through the cache."""
+
+    def test_read_through(self):
+        """Results should be computed once per version and query, and come back as fresh models."""
+        cache = LookupCache(InMemoryLookupCacheStore(), {LookupType.IP: 60})
+        calls = []
+
+        def compute():
+            calls.append(1)
+            return Answer(value=len(calls))
+
+        first = cache.read_through(LookupType.IP, "1", {"ip": "81.2.69.142"}, Answer, compute)
+        first.note = "changed by the caller"
+        again = cache.read_through(LookupType.IP, "1", {"ip": "81.2.69.142"}, Answer, compute)
+        assert (again.value, again.note, len(calls)) == (1, "", 1)
+        # A new dataset build is never answered from the previous one's entries
+        assert cache.read_through(LookupType.IP, "2", {"ip": "81.2.69.142"}, Answer, compute).value == 2
+        # Types without a TTL are not cached
+        cache.read_through(LookupType.REVERSE_GEOCODE, "1", {}, Answer, compute)
+        cache.read_through(LookupType.REVERSE_GEOCODE, "1", {}, Answer, compute)
+        assert len(calls) == 4
+
+    def test_errors(self):
+        """Failed lookups should not be stored, and an unreachable cache should count as empty."""
+        cache = LookupCache(InMemoryLookupCacheStore(), {LookupType.IP: 60})
+
+        def unavailable():
+            raise RuntimeError("GeoIP dataset unavailable")
+
+        with pytest.raises(RuntimeError):
+            cache.read_through(LookupType.IP, "1", {}, Answer, unavailable)
+        assert len(cache.store) == 0
+
+        broken = LookupCache(BrokenStore(), {LookupType.IP: 60})
+        assert broken.read_through(LookupType.IP, "1", {}, Answer, lambda: Answer(value=7)).value == 7
+
+    def test_negative_caching(self):
+        """Not-found answers should be kept for the negative TTL, and raised again."""
+        clock = FakeClock()
+        cache = LookupCache(InMemoryLookupCacheStore(clock=clock), {LookupType.IP: 60}, negative_ttl_seconds=5)
+        calls = []
+
+        def missing():
+            calls.append(1)
+            raise LookupError("No geolocation data for 10.0.0.1")
+
+        for _ in range(3):
+            with pytest.raises(LookupError, match="No geolocation data for 10.0.0.1"):
+                cache.read_through(LookupType.IP, "1", {"ip": "10.0.0.1"}, Answer, missing)
+        assert len(calls) == 1
+        clock.now = 5
+        with pytest.raises(LookupError):
+            cache.read_through(LookupType.IP, "1", {"ip": "10.0.0.1"}, Answer, missing)
+        assert len(calls) == 2
+
+        # KeyErrors are bugs, not answers
+        def broken():
+            raise KeyError("city")
+
+        with pytest.raises(KeyError):
+            cache.read_through(LookupType.IP, "1", {"ip": "10.0.0.2"}, Answer, broken)
+        assert cache.store.get("lookup:ip:" + query_key("1", {"ip": "10.0.0.2"})) is None
+
+    def test_invalidate(self):
+        """Invalidating a lookup type should drop its entries of every version and tier, and only those."""
+        l1, l2 = InMemoryLookupCacheStore(), InMemoryLookupCacheStore()
+        cache = LookupCache(
+            TieredCache([CacheTier("l1", l1, max_ttl_seconds=5), CacheTier("l2", l2)]),
+            {LookupType.IP: 60, LookupType.IP_AS_OF: 60},
+        )
+        for version in ("1", "2"):
+            cache.read_through(LookupType.IP, version, {"ip": "81.2.69.142"}, Answer, lambda: Answer(value=1))
+        cache.read_through(LookupType.IP_AS_OF, "1", {"ip": "81.2.69.142"}, Answer, lambda: Answer(value=1))
+        assert cache.invalidate(LookupType.IP) == 4
+        assert (len(l1), len(l2)) == (1, 1)
+        assert cache.read_through(LookupType.IP, "2", {"ip": "81.2.69.142"}, Answer, lambda: Answer(value=2)).value == 2
+
+        broken = LookupCache(TieredCache([CacheTier("l1", l1), CacheTier("l2", BrokenStore())]))
+        assert broken.invalidate(LookupType.IP_AS_OF) == 1
+
+    def test_memory_store_expiry_and_size(self):
+        """Entries should expire after their TTL, and the least recently used go first."""
+        clock = FakeClock()
+        store = InMemoryLookupCacheStore(max_entries=2, clock=clock)
+        store.set("a", "1", 10)
+        store.set("b", "2", 60)
+        assert store.get("a") == "1"
+        store.set("c", "3", 60)
+        assert (store.get("a"), store.get("b"), store.get("c")) == ("1", None, "3")
+        clock.now = 10
+        assert store.get("a") is None and store.get("c") == "3"
+
+    def test_parse_ttls(self):
+        """TTLs should be a JSON object of known lookup types to seconds."""
+        assert parse_ttls("", 300) == {"ip": 300, "ip_as_of": 86400, "reverse_geocode": 3600}
+        assert parse_ttls('{"ip": 30, "reverse_geocode": 0}', 300)["ip"] == 30
+        for raw in ("[]", "{not json", '{"geocode": 60}', '{"ip": -1}', '{"ip": "60"}'):
+            with pytest.raises(ValueError):
+                parse_ttls(raw, 300)
+
+
+class TestTieredCache:
+    """Test the process-local L1 in front of Redis."""
+
+    def test_l1_in_front_of_shared_store(self):
+        """Entries should be served from L1 while it holds them, then from the shared store."""
+        clock = FakeClock()
+        l1, l2 = InMemoryLookupCacheStore(clock=clock), InMemoryLookupCacheStore(clock=clock)
+        tiers = TieredCache([CacheTier("l1", l1, max_ttl_seconds=5), CacheTier("l2", l2)])
+        tiers.set(LookupType.IP, "a", "1", 60)
+        l2.set("a", "2", 60)  # replaced by another worker
+        assert tiers.get(LookupType.IP, "a") == "1"
+        clock.now = 5
+        assert tiers.get(LookupType.IP, "a") == "2" and l1.get("a") == "2"
+        # L1 never keeps an entry beyond its own TTL
+        tiers.set(LookupType.IP, "b", "3", 2)
+        clock.now = 7
+        assert tiers.get(LookupType.IP, "b") is None
+
+    def test_tier_metrics(self, monkeypatch):
+        """Each tier's reads should be counted and timed per lookup type."""
+        counted, timed = [], []
+
+        class Recorder:
+            def __init__(self, out):
+                self.out = out
+
+            def labels(self, **labels):
+                self.out.append(labels)
+                return self
+
+            def inc(self, amount=1):
+                pass
+
+            def observe(self, seconds):
+                assert seconds >= 0
+
+        monkeypatch.setattr("src.metrics.LOOKUP_CACHE_TIER_REQUESTS", Recorder(counted))
+        monkeypatch.setattr("src.metrics.LOOKUP_CACHE_TIER_SECONDS", Recorder(timed))
+        tiers = TieredCache([
+            CacheTier("l1", InMemoryLookupCacheStore(), max_ttl_seconds=5),
+            CacheTier("l2", InMemoryLookupCacheStore()),
+        ])
+        cache = LookupCache(tiers, {LookupType.IP: 60})
+        cache.read_through(LookupType.IP, "1", {"ip": "81.2.69.142"}, Answer, lambda: Answer(value=1))
+        cache.read_through(LookupType.IP, "1", {"ip": "81.2.69.142"}, Answer, lambda: Answer(value=1))
+        assert [(c["tier"], c["result"]) for c in counted] == [("l1", "miss"), ("l2", "miss"), ("l1", "hit")]
+        assert {c["type"] for c in counted} == {"ip"}
+        assert [(t["tier"], t["operation"]) for t in timed] == [
+            ("l1", "get"), ("l2", "get"), ("l1", "set"), ("l2", "set"), ("l1", "get"),
+        ]
+
+        broken = TieredCache([CacheTier("l2", BrokenStore())])
+        with pytest.raises(ConnectionError):
+            broken.get(LookupType.REVERSE_GEOCODE, "a")
+        assert counted[-1] == {"tier": "l2", "type": "reverse_geocode", "result": "error"}
+
+
+class TestSingleFlight:
+    """Test deduplicating concurrent misses."""
+
+    def test_herd_computes_once(self):
+        """Concurrent lookups of one entry should cost one computation and give each caller its own model."""
+        cache = LookupCache(InMemoryLookupCacheStore(), {LookupType.IP: 60})
+        calls, results = [], []
+        started = threading.Event()
+
+        def compute():
+            calls.append(1)
+            started.set()
+            time.sleep(0.1)  # the herd arrives meanwhile
+            return Answer(value=42)
+
+        def lookup():
+            results.append(cache.read_through(LookupType.IP, "1", {"ip": "81.2.69.142"}, Answer, compute))
+
+        leader = threading.Thread(target=lookup)
+        leader.start()
+        started.wait()
+        herd = [threading.Thread(target=lookup) for _ in range(20)]
+        for thread in herd:
+            thread.start()
+        for thread in [leader, *herd]:
+            thread.join()
+        assert len(calls) == 1
+        assert [r.value for r in results] == [42] * 21 and len({id(r) for r in results}) == 21
+        assert cache.flights.in_flight() == 0
+
+    def test_error_shared(self):
+        """Callers waiting on a failing lookup should get its error, and the next caller try again."""
+        flights = SingleFlight()
+        release = threading.Event()
+        errors = []
+
+        def fail():
+            release.wait()
+            raise LookupError("No geolocation data")
+
+        def call():
+            try:
+                flights.do("k", fail)
+            except LookupError as e:
+                errors.append(e)
+
+        threads = [threading.Thread(target=call) for _ in range(5)]
+        for thread in threads:
+            thread.start()
+        while flights.in_flight() == 0:
+            time.sleep(0.01)
+        release.set()
+        for thread in threads:
+            thread.join()
+        assert len(errors) == 5
+        assert flights.do("k", lambda: "found") == ("found", False)
+
+
+class TestCachedLookupRoute:
+    """Test cached answers of GET /api/v1/lookup/ip/{ip}."""
+
+    def test_hot_address_skips_dataset(self, test_client, tmp_path, monkeypatch):
+        """Repeated lookups should be answered without reading the dataset."""
+        location = {"country": {"iso_code": "GB", "names": {"en": "United Kingdom"}}}
+        reader = MMDBReader(write_mmdb(tmp_path / "city.mmdb", [("81.2.69.128/26", location)]))
+        service = IpLookupService(reader)
+        calls = []
+        lookup = service.lookup
+        monkeypatch.setattr(service, "lookup", lambda ip: calls.append(ip) or lookup(ip))
+        monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: service)
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: EnrichmentPipeline())
+        monkeypatch.setattr(
+            lookup_cache_service, "_lookup_cache", LookupCache(InMemoryLookupCacheStore())
+        )
+
+        first = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        second = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert first.json() == second.json() and second.json()["country_iso_code"] == "GB"
+        assert calls == ["81.2.69.142"]
+        # Addresses without data are cached as not found
+        assert test_client.get("/api/v1/lookup/ip/81.2.69.1").status_code == 404
+        assert test_client.get("/api/v1/lookup/ip/81.2.69.1").status_code == 404
+        assert len(calls) == 2
+        reader.close()
@@ -0,0 +1,567 @@
+"""Route tests for the IP lookup API."""
+import json
+from datetime import datetime
+import pytest
+
+from src.config import Config
+from src.services.anonymizer_service import AnonymizerEnricher
+from src.services.auth_service import TokenVerifier
+from src.services.batch_lookup_service import BatchLookupService
+from src.services.carrier_service import Carrier, CarrierDirectory
+from src.services.datacenter_service import DatacenterEnricher
+from src.services.device_history_service import DeviceHistoryService, DeviceObservation
+from src.services.enrichment_service import Enricher, EnrichmentPipeline
+from src.services.ip_lookup_service import IpLookupService, IpRangeSet
+from src.services.residential_proxy_service import SessionAsnTracker
+from src.services.risk_area_service import RiskAreaService
+from src.services.reverse_geocoding_service import ReverseGeocodingService
+from src.services.risk_service import build_risk_scorer
+from src.services.rule_service import RuleEngine
+from src.services.mmdb_service import MMDBReader
+from src.services.snapshot_service import DatasetSnapshotStore
+from src.services.velocity_service import InMemoryVelocityStore, VelocityTracker
+from tests.mmdb_writer import write_mmdb
+
+
+LONDON = {
+    "city": {"names": {"en": "London"}},
+    "country": {"iso_code": "GB", "names": {"en": "United Kingdom"}},
+    "location": {"latitude": 51.5142, "longitude": -0.0931, "accuracy_radius": 10},
+}
+
+
+class StaticEnricher(Enricher):
+    """Enricher returning a fixed block."""
+
+    def __init__(self, name, block):
+        self.name = name
+        self.block = block
+
+    def enrich(self, result):
+        return self.block
+
+
+@pytest.fixture
+def lookup_service(tmp_path, monkeypatch):
+    """Serve lookups from a small fixture database with no enrichers."""
+    path = write_mmdb(tmp_path / "city.mmdb", [("81.2.69.128/26", LONDON)])
+    reader = MMDBReader(path)
+    service = IpLookupService(reader)
+    monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: service)
+    monkeypatch.setattr(
+        "src.api.lookup_routes.get_enrichment_pipeline", lambda: EnrichmentPipeline()
+    )
+    yield service
+    reader.close()
+
+
+class TestLookupIpRoute:
+    """Test GET /api/v1/lookup/ip/{ip}."""
+
+    def test_lookup_found(self, test_client, lookup_service):
+        """Should return the location record for an address in the dataset."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.status_code == 200
+        body = response.json()
+        assert body["ip_address"] == "81.2.69.142"
+        assert body["country_iso_code"] == "GB"
+        assert body["city_name"] == "London"
+        assert body["network"] == "81.2.69.128/26"
+
+    def test_lookup_reports_accuracy(self, test_client, lookup_service):
+        """Should report granularity and a confidence discounted for dataset age."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.status_code == 200
+        body = response.json()
+        assert body["granularity"] == "city"
+        assert body["accuracy_radius_km"] == 10
+        assert 0 < body["confidence"] < 0.85  # fixture dataset was built in 2023
+        assert body["dataset_age_days"] > 0
+
+    def test_sparse_fields(self, test_client, lookup_service):
+        """Should return only the attributes listed in fields."""
+        response = test_client.get(
+            "/api/v1/lookup/ip/81.2.69.142", params={"fields": "country_iso_code,city_name,asn"}
+        )
+        assert response.status_code == 200
+        assert response.json() == {"country_iso_code": "GB", "city_name": "London", "asn": None}
+
+    def test_etag_and_not_modified(self, test_client, lookup_service):
+        """Should tag responses with the dataset build and answer a matching If-None-Match with 304."""
+        first = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        etag = first.headers["ETag"]
+        built = int(datetime.fromisoformat(first.json()["dataset_built_at"].replace("Z", "+00:00")).timestamp())
+        assert etag.startswith(f'"{built}-')
+
+        cached = test_client.get("/api/v1/lookup/ip/81.2.69.142", headers={"If-None-Match": etag})
+        assert cached.status_code == 304
+        assert cached.headers["ETag"] == etag
+        assert cached.content == b""
+
+        other = test_client.get("/api/v1/lookup/ip/81.2.69.142?fields=city_name", headers={"If-None-Match": etag})
+        assert other.status_code == 200
+        assert other.headers["ETag"] != etag
+
+    def test_unknown_field_is_400(self, test_client, lookup_service):
+        """Should reject fields the response does not have."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142", params={"fields": "country"})
+        assert response.status_code == 400
+        detail = response.json()["detail"]
+        assert detail["error_code"] == "E002"
+        assert "country_iso_code" in detail["details"]["supported"]
+
+    def test_invalid_ip_is_400(self, test_client, lookup_service):
+        """Should reject input that is not an IP address."""
+        response = test_client.get("/api/v1/lookup/ip/not-an-ip")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_unknown_ip_is_404(self, test_client, lookup_service):
+        """Should return 404 for an address outside the dataset."""
+        response = test_client.get("/api/v1/lookup/ip/8.8.8.8")
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_unknown_route_is_plain_404(self, test_client):
+        """Paths no route matches should keep the plain 404 body."""
+        response = test_client.get("/api/v1/no-such-endpoint")
+        assert response.status_code == 404
+        assert response.json() == {"detail": "Not found"}
+
+    def test_no_dataset_is_503(self, test_client, monkeypatch):
+        """Should return 503 when no GeoIP dataset is loaded."""
+        monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: None)
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+    def test_6to4_address_resolves_embedded_ipv4(self, test_client, lookup_service):
+        """Should geolocate a 6to4 address via its embedded IPv4 client."""
+        response = test_client.get("/api/v1/lookup/ip/2002:5102:458e::1")
+        assert response.status_code == 200
+        body = response.json()
+        assert body["ip_version"] == 6
+        assert body["normalized_ip"] == "81.2.69.142"
+        assert body["tunnel"] == "6to4"
+        assert body["city_name"] == "London"
+
+
+class TestLookupAsOf:
+    """Test historical lookups with ?as_of= (fixture dataset built 2023-11-14)."""
+
+    @pytest.fixture
+    def snapshots(self, tmp_path, monkeypatch):
+        """Store holding a 2023-01-01 build that still placed the network in Leeds."""
+        leeds = dict(LONDON, city={"names": {"en": "Leeds"}})
+        store = DatasetSnapshotStore(str(tmp_path / "snapshots"), retention_days=100000)
+        store.retain(write_mmdb(
+            tmp_path / "old.mmdb", [("81.2.69.128/26", leeds)], build_epoch=1672531200
+        ))
+        monkeypatch.setattr("src.api.lookup_routes.get_snapshot_store", lambda: store)
+        return store
+
+    def test_as_of_uses_snapshot(self, test_client, lookup_service, snapshots):
+        """Should answer from the build active at as_of."""
+        response = test_client.get(
+            "/api/v1/lookup/ip/81.2.69.142", params={"as_of": "2023-06-01"}
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert body["city_name"] == "Leeds"
+        assert body["dataset_built_at"].startswith("2023-01-01T00:00:00")
+        assert body["dataset_age_days"] == 151.0
+
+    def test_as_of_after_active_build(self, test_client, lookup_service, snapshots):
+        """Should use the active dataset for instants after its build."""
+        response = test_client.get(
+            "/api/v1/lookup/ip/81.2.69.142", params={"as_of": "2024-03-01"}
+        )
+        assert response.status_code == 200
+        assert response.json()["city_name"] == "London"
+
+    def test_before_retained_history_is_404(self, test_client, lookup_service, snapshots):
+        """Should return 404 when no retained build is old enough."""
+        response = test_client.get(
+            "/api/v1/lookup/ip/81.2.69.142", params={"as_of": "2022-01-01"}
+        )
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_snapshots_disabled_is_503(self, test_client, lookup_service, monkeypatch):
+        """Should return 503 for historical instants when no store is configured."""
+        monkeypatch.setattr("src.api.lookup_routes.get_snapshot_store", lambda: None)
+        response = test_client.get(
+            "/api/v1/lookup/ip/81.2.69.142", params={"as_of": "2023-06-01"}
+        )
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+
+class TestLookupTravel:
+    """Test the travel block on GET /api/v1/lookup/ip/{ip}."""
+
+    def test_without_user_no_block(self, db_client, lookup_service):
+        """Lookups without a user_id should not be recorded."""
+        response = db_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.json()["travel"] is None
+        assert response.json()["anomaly"] is None
+
+    def test_user_lookups_compared(self, db_client, lookup_service):
+        """Each lookup for a user should be compared with their previous login."""
+        first = db_client.get("/api/v1/lookup/ip/81.2.69.142", params={"user_id": "alice"})
+        assert first.status_code == 200
+        assert first.json()["travel"]["previous"] is None
+
+        second = db_client.get("/api/v1/lookup/ip/81.2.69.142", params={"user_id": "alice"})
+        travel = second.json()["travel"]
+        assert travel["previous"]["ip_address"] == "81.2.69.142"
+        assert travel["distance_km"] == 0
+        assert travel["impossible"] is False
+        assert second.json()["anomaly"]["samples"] == 1
+        assert second.json()["anomaly"]["score"] is None
+
+    def test_detect_travel_by_ip(self, db_client, lookup_service):
+        """POST /detect/travel should locate IP addresses with the dataset."""
+        response = db_client.post(
+            "/api/v1/detect/travel", json={"user_id": "bob", "ip_address": "81.2.69.142"}
+        )
+        assert response.status_code == 200
+        assert response.json()["current"]["country_code"] == "GB"
+        assert response.json()["current"]["accuracy_km"] == 10
+
+
+class TestLocationMismatchRoutes:
+    """Test device versus IP location comparisons."""
+
+    def test_lookup_block(self, test_client, lookup_service):
+        """Device coordinates near the IP location should not be flagged."""
+        response = test_client.get(
+            "/api/v1/lookup/ip/81.2.69.142", params={"device_lat": 51.45, "device_lon": -0.98}
+        )
+        assert response.status_code == 200
+        block = response.json()["location_mismatch"]
+        assert block["mismatch"] is False
+        assert block["ip_accuracy_km"] == 10
+
+    def test_lookup_requires_both_coordinates(self, test_client, lookup_service):
+        """Should reject device_lat without device_lon."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142", params={"device_lat": 51.45})
+        assert response.status_code == 400
+
+    def test_detect_vpn(self, test_client, lookup_service, monkeypatch):
+        """A distant device behind a VPN address should be attributed to the VPN."""
+        pipeline = EnrichmentPipeline([
+            StaticEnricher("anonymizer", {"vpn": True, "tor": False, "proxy": False, "hosting": False}),
+        ])
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: pipeline)
+        response = test_client.post(
+            "/api/v1/detect/location-mismatch",
+            json={"ip_address": "81.2.69.142", "latitude": 40.7128, "longitude": -74.006},
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert (body["ip_latitude"], body["ip_country_code"]) == (51.5142, "GB")
+        assert body["comparison"]["mismatch"] is True
+        assert body["comparison"]["likely_cause"] == "vpn"
+
+    def test_detect_unknown_ip_is_404(self, test_client, lookup_service):
+        """Should return 404 for addresses outside the dataset."""
+        response = test_client.post(
+            "/api/v1/detect/location-mismatch",
+            json={"ip_address": "8.8.8.8", "latitude": 40.7128, "longitude": -74.006},
+        )
+        assert response.status_code == 404
+
+
+class TestLookupRisk:
+    """Test the risk block on GET /api/v1/lookup/ip/{ip}."""
+
+    @pytest.fixture(autouse=True)
+    def scoring(self, lookup_service, monkeypatch):
+        """Default-weighted scorer and an in-memory velocity tracker."""
+        tracker = VelocityTracker()
+        monkeypatch.setattr("src.api.lookup_routes.get_velocity_tracker", lambda: tracker)
+        monkeypatch.setattr(
+            "src.api.lookup_routes.get_risk_scorer", lambda: build_risk_scorer(Config())
+        )
+        return tracker
+
+    def test_clean_address(self, test_client):
+        """An address without signals should score 0."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.status_code == 200
+        assert response.json()["risk"] == {"score": 0, "reasons": []}
+
+    def test_reasons_from_blocks(self, test_client, monkeypatch):
+        """Anonymizer flags and a device mismatch should both be reported."""
+        pipeline = EnrichmentPipeline([
+            StaticEnricher("anonymizer", {"vpn": True, "tor": False, "proxy": False, "hosting": False}),
+        ])
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: pipeline)
+        response = test_client.get(
+            "/api/v1/lookup/ip/81.2.69.142", params={"device_lat": 40.7128, "device_lon": -74.006}
+        )
+        risk = response.json()["risk"]
+        assert [reason["code"] for reason in risk["reasons"]] == [
+            "ANONYMIZER_VPN", "LOCATION_MISMATCH",
+        ]
+        # 1 - 0.5 * 0.5
+        assert risk["score"] == 75
+
+    def test_velocity_feeds_in(self, db_client, scoring):
+        """The user's account velocity should be scored."""
+        for country in ("GB", "FR", "DE"):
+            scoring.observe("account", "alice", country)
+        response = db_client.get("/api/v1/lookup/ip/81.2.69.142", params={"user_id": "alice"})
+        assert [reason["code"] for reason in response.json()["risk"]["reasons"]] == ["HIGH_VELOCITY"]
+
+    def test_new_device_country(self, db_client, db_session):
+        """A known device in a new country should get a device block and a reason."""
+        DeviceHistoryService(db_session).record("fp-1", DeviceObservation(datetime(2026, 3, 1), "FR"))
+        response = db_client.get("/api/v1/lookup/ip/81.2.69.142", params={"device_id": "fp-1"})
+        body = response.json()
+        assert body["device"] == {
+            "device_id": "fp-1", "known_device": True, "country_seen_before": False, "countries_seen": 1,
+        }
+        assert [reason["code"] for reason in body["risk"]["reasons"]] == ["NEW_DEVICE_COUNTRY"]
+        again = db_client.get("/api/v1/lookup/ip/81.2.69.142", params={"device_id": "fp-1"}).json()
+        assert again["device"]["country_seen_before"] is True
+
+    def test_tenant_high_risk_list(self, db_client, db_session, monkeypatch):
+        """A tenant's high-risk list should score that tenant's lookups only."""
+        verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+        monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+        RiskAreaService(db_session).create_area("acme", "country", "GB")
+
+        headers = {"Authorization": f"Bearer {verifier.issue('user-1', 'acme')}"}
+        risk = db_client.get("/api/v1/lookup/ip/81.2.69.142", headers=headers).json()["risk"]
+        assert [reason["code"] for reason in risk["reasons"]] == ["HIGH_RISK_COUNTRY"]
+        anonymous = db_client.get("/api/v1/lookup/ip/81.2.69.142").json()["risk"]
+        assert anonymous["reasons"] == []
+
+    def test_detection_rules(self, test_client, tmp_path, monkeypatch):
+        """Rules firing on the lookup should be reported with the decision."""
+        path = tmp_path / "rules.json"
+        path.write_text(json.dumps({"rules": [
+            {"id": "gb-vpn", "action": "review", "expression": 'country == "GB" && is_vpn'},
+            {"id": "london", "expression": 'city == "London"'},
+            {"id": "gb-block", "action": "block", "state": "shadow", "expression": 'country == "GB"'},
+        ]}))
+        engine = RuleEngine(str(path))
+        monkeypatch.setattr("src.api.lookup_routes.get_rule_engine", lambda: engine)
+        assert test_client.get("/api/v1/lookup/ip/81.2.69.142").json()["rules"] == {
+            "decision": "flag", "matches": [{"rule_id": "london", "action": "flag"}],
+        }
+
+        pipeline = EnrichmentPipeline([
+            StaticEnricher("anonymizer", {"vpn": True, "tor": False, "proxy": False, "hosting": False}),
+        ])
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: pipeline)
+        assert test_client.get("/api/v1/lookup/ip/81.2.69.142").json()["rules"]["decision"] == "review"
+
+    def test_session_residential_proxy(self, test_client, monkeypatch):
+        """A session churning through ASNs should get the block and its own reason."""
+        tracker = SessionAsnTracker(InMemoryVelocityStore(), max_asns=0)
+        monkeypatch.setattr("src.api.lookup_routes.get_session_asn_tracker", lambda: tracker)
+        pipeline = EnrichmentPipeline([StaticEnricher("asn", {"number": 20712, "connection_type": "residential"})])
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: pipeline)
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142", params={"session_id": "s-1"})
+        body = response.json()
+        assert body["residential_proxy"]["asn_churn"] is True
+        assert body["residential_proxy"]["distinct_asns"] == 1
+        assert [reason["code"] for reason in body["risk"]["reasons"]] == ["RESIDENTIAL_PROXY"]
+
+    def test_session_store_unavailable_skipped(self, test_client, monkeypatch):
+        """An unreachable velocity store should not fail the lookup."""
+        class Broken:
+            def observe(self, *args, **kwargs):
+                raise RuntimeError("Velocity store unavailable: connection refused")
+
+        monkeypatch.setattr("src.api.lookup_routes.get_session_asn_tracker", lambda: Broken())
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142", params={"session_id": "s-1"})
+        assert response.status_code == 200
+        assert response.json()["residential_proxy"] is None
+
+    def test_disabled_per_key(self, test_client, monkeypatch):
+        """Keys whose profile omits risk should not get the block."""
+        monkeypatch.setenv("ENRICHMENT_API_KEY_PROFILES", json.dumps({"key-basic": []}))
+        monkeypatch.setattr("src.services.enrichment_service._config", Config())
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142", headers={"X-API-Key": "key-basic"})
+        assert response.json()["risk"] is None
+
+
+class TestLookupEnrichmentProfiles:
+    """Test per-key enrichment selection on GET /api/v1/lookup/ip/{ip}."""
+
+    @pytest.fixture(autouse=True)
+    def enrichers(self, lookup_service, monkeypatch):
+        """Register an ASN enricher and a profile for one key."""
+        pipeline = EnrichmentPipeline([
+            StaticEnricher("asn", {"number": 20712, "organization": "Andrews & Arnold Ltd"}),
+            StaticEnricher("locale", {"locale": "en-GB", "languages": ["en-GB", "cy-GB", "gd"]}),
+            StaticEnricher("currency", {"code": "GBP", "name": "Pound"}),
+            StaticEnricher("groups", ["europe"]),
+        ])
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: pipeline)
+        monkeypatch.setenv("ENRICHMENT_API_KEY_PROFILES", json.dumps({"key-basic": []}))
+        monkeypatch.setattr("src.services.enrichment_service._config", Config())
+
+    def test_defaults_without_key(self, test_client):
+        """Should include the default enrichments when no X-API-Key is sent."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.status_code == 200
+        assert response.json()["asn"]["number"] == 20712
+        assert response.json()["locale"]["locale"] == "en-GB"
+        assert response.json()["currency"]["code"] == "GBP"
+        assert response.json()["groups"] == ["europe"]
+
+    def test_profile_for_key(self, test_client):
+        """Should include only the enrichments in the key's profile."""
+        response = test_client.get(
+            "/api/v1/lookup/ip/81.2.69.142", headers={"X-API-Key": "key-basic"}
+        )
+        assert response.status_code == 200
+        assert response.json()["asn"] is None
+        assert response.json()["locale"] is None
+        assert response.json()["currency"] is None
+        assert response.json()["groups"] is None
+
+
+class TestLookupAnonymizerFlags:
+    """Test the anonymizer block on GET /api/v1/lookup/ip/{ip}."""
+
+    def _use(self, monkeypatch, enricher):
+        pipeline = EnrichmentPipeline([enricher])
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: pipeline)
+
+    def test_tor_exit_flagged(self, test_client, lookup_service, monkeypatch):
+        """Should flag Tor exits and leave uncovered flags unknown."""
+        self._use(monkeypatch, AnonymizerEnricher(tor_exits=IpRangeSet(["81.2.69.142/32"])))
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.status_code == 200
+        assert response.json()["anonymizer"] == {
+            "vpn": None, "tor": True, "proxy": None, "residential_proxy": None, "hosting": None
+        }
+
+    def test_no_sources_omits_block(self, test_client, lookup_service, monkeypatch):
+        """Should omit the anonymizer block when no source is loaded."""
+        self._use(monkeypatch, AnonymizerEnricher())
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.status_code == 200
+        assert response.json()["anonymizer"] is None
+
+
+class TestLookupDatacenter:
+    """Test datacenter classification on the lookup routes."""
+
+    @pytest.fixture(autouse=True)
+    def cloud_ranges(self, lookup_service, monkeypatch):
+        """Datacenter enricher with one published AWS range."""
+        pipeline = EnrichmentPipeline([DatacenterEnricher({"aws": IpRangeSet(["3.5.140.0/22"])})])
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: pipeline)
+        monkeypatch.setattr(
+            "src.api.lookup_routes.get_risk_scorer", lambda: build_risk_scorer(Config())
+        )
+
+    def test_lookup_block(self, test_client):
+        """Located addresses should carry the datacenter block."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.json()["datacenter"] == {
+            "is_datacenter": False, "provider": None, "source": None,
+        }
+
+    def test_classify_without_geolocation(self, test_client):
+        """Addresses outside the GeoIP dataset should still be classified."""
+        response = test_client.get("/api/v1/lookup/datacenter/3.5.141.9")
+        assert response.status_code == 200
+        assert response.json() == {
+            "ip_address": "3.5.141.9", "is_datacenter": True, "provider": "aws", "source": "cloud_ranges",
+        }
+
+    def test_invalid_ip_is_400(self, test_client):
+        """Should reject input that is not an IP address."""
+        response = test_client.get("/api/v1/lookup/datacenter/not-an-ip")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_no_sources_is_503(self, test_client, monkeypatch):
+        """Should report the classification as unavailable without any source."""
+        pipeline = EnrichmentPipeline([DatacenterEnricher()])
+        monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: pipeline)
+        response = test_client.get("/api/v1/lookup/datacenter/3.5.141.9")
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+
+class TestLookupCarrierRoute:
+    """Test GET /api/v1/lookup/carrier/{mcc}/{mnc}."""
+
+    @pytest.fixture
+    def directory(self, monkeypatch):
+        directory = CarrierDirectory([Carrier("234", "15", "Vodafone UK", "GB")])
+        monkeypatch.setattr("src.api.lookup_routes.get_carrier_directory", lambda: directory)
+        return directory
+
+    def test_known_pair(self, test_client, directory):
+        """Should name the carrier for a known pair."""
+        response = test_client.get("/api/v1/lookup/carrier/234/15")
+        assert response.status_code == 200
+        assert response.json() == {
+            "mcc": "234", "mnc": "15", "name": "Vodafone UK", "country_iso_code": "GB"
+        }
+
+    def test_unknown_pair_is_404(self, test_client, directory):
+        """Should return 404 for a pair not in the directory."""
+        response = test_client.get("/api/v1/lookup/carrier/234/015")
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_malformed_pair_is_400(self, test_client, directory):
+        """Should reject malformed codes."""
+        response = test_client.get("/api/v1/lookup/carrier/23/15")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_no_directory_is_503(self, test_client, monkeypatch):
+        """Should return 503 when no directory is loaded."""
+        monkeypatch.setattr("src.api.lookup_routes.get_carrier_directory", lambda: None)
+        response = test_client.get("/api/v1/lookup/carrier/234/15")
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+
+class TestLookupBatchRoute:
+    """Test POST /api/v1/lookup/batch."""
+
+    def test_mixed_batch(self, test_client, lookup_service, monkeypatch):
+        """Should report a result or an error for every item, in order."""
+        monkeypatch.setattr(
+            "src.api.geocoding_routes.get_reverse_geocoding_service",
+            lambda: ReverseGeocodingService(),
+        )
+        response = test_client.post("/api/v1/lookup/batch", json={"items": [
+            {"ip": "81.2.69.142"},
+            {"ip": "8.8.8.8"},
+            {"ip": "not-an-ip"},
+            {"lat": 51.5},
+            {"lat": 51.5, "lon": -0.12},
+        ]})
+        assert response.status_code == 200
+        body = response.json()
+        assert (body["total"], body["succeeded"], body["failed"]) == (5, 1, 4)
+
+        results = body["results"]
+        assert [r["index"] for r in results] == [0, 1, 2, 3, 4]
+        assert results[0]["ip"]["city_name"] == "London"
+        assert [r["error"]["error_code"] for r in results[1:]] == ["E004", "E002", "E002", "E003"]
+
+    def test_oversized_batch_is_400(self, test_client, lookup_service, monkeypatch):
+        """Should reject batches above the configured limit."""
+        service = BatchLookupService(workers=2, max_items=2)
+        monkeypatch.setattr("src.api.lookup_routes.get_batch_lookup_service", lambda: service)
+        response = test_client.post(
+            "/api/v1/lookup/batch", json={"items": [{"ip": "81.2.69.142"}] * 3}
+        )
+        service.shutdown()
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
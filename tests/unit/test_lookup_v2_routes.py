@@ -0,0 +1,98 @@
+"""Route tests for version 2 of the IP lookup API."""
+import pytest
+
+from src.services.enrichment_service import EnrichmentPipeline
+from src.services.ip_lookup_service import IpLookupService
+from src.services.mmdb_service import MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+
+LONDON = {
+    "city": {"names": {"en": "London"}},
+    "country": {"iso_code": "GB", "names": {"en": "United Kingdom"}},
+    "location": {"latitude": 51.5142, "longitude": -0.0931, "accuracy_radius": 10},
+}
+COUNTRY_ONLY = {"country": {"iso_code": "FR", "names": {"en": "France"}}}
+
+
+@pytest.fixture
+def lookup_service(tmp_path, monkeypatch):
+    """Serve lookups from a small fixture database with no enrichers."""
+    path = write_mmdb(
+        tmp_path / "city.mmdb",
+        [("81.2.69.128/26", LONDON), ("2.16.0.0/13", COUNTRY_ONLY)],
+    )
+    reader = MMDBReader(path)
+    service = IpLookupService(reader)
+    monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: service)
+    monkeypatch.setattr(
+        "src.api.lookup_routes.get_enrichment_pipeline", lambda: EnrichmentPipeline()
+    )
+    yield service
+    reader.close()
+
+
+class TestLookupIpV2Route:
+    """Test GET /api/v2/lookup/ip/{ip}."""
+
+    def test_nested_shape(self, test_client, lookup_service):
+        """Should group the v1 fields into ip, location, confidence and dataset objects."""
+        v1 = test_client.get("/api/v1/lookup/ip/81.2.69.142").json()
+        response = test_client.get("/api/v2/lookup/ip/81.2.69.142")
+        assert response.status_code == 200
+        body = response.json()
+
+        assert body["ip"] == {
+            "address": "81.2.69.142",
+            "version": 4,
+            "normalized": None,
+            "tunnel": None,
+            "network": "81.2.69.128/26",
+        }
+        location = body["location"]
+        assert location["country"] == {"iso_code": "GB", "name": "United Kingdom"}
+        assert location["city"] == "London"
+        assert location["coordinates"] == {"latitude": 51.5142, "longitude": -0.0931}
+        assert location["granularity"] == v1["granularity"]
+        assert body["confidence"] == {"score": v1["confidence"], "flag": v1["confidence_flag"]}
+        assert body["dataset"]["age_days"] == v1["dataset_age_days"]
+        assert "country_iso_code" not in body
+
+    def test_no_coordinates_is_null(self, test_client, lookup_service):
+        """Should report a record without a coordinate as null coordinates."""
+        body = test_client.get("/api/v2/lookup/ip/2.16.1.1").json()
+        assert body["location"]["country"]["iso_code"] == "FR"
+        assert body["location"]["coordinates"] is None
+
+    def test_sparse_fields(self, test_client, lookup_service):
+        """Should select v2 attributes, including inside the nested objects."""
+        response = test_client.get(
+            "/api/v2/lookup/ip/81.2.69.142", params={"fields": "location.country,confidence.flag"}
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert body["location"] == {"country": {"iso_code": "GB", "name": "United Kingdom"}}
+        assert list(body["confidence"]) == ["flag"]
+
+    def test_not_modified(self, test_client, lookup_service):
+        """Should honor If-None-Match like v1, with tags distinct from v1's."""
+        etag = test_client.get("/api/v2/lookup/ip/81.2.69.142").headers["ETag"]
+        assert etag != test_client.get("/api/v1/lookup/ip/81.2.69.142").headers["ETag"]
+        cached = test_client.get("/api/v2/lookup/ip/81.2.69.142", headers={"If-None-Match": etag})
+        assert cached.status_code == 304
+
+    def test_errors_match_v1(self, test_client, lookup_service):
+        """Should reject bad and unknown addresses like v1."""
+        invalid = test_client.get("/api/v2/lookup/ip/not-an-ip")
+        assert invalid.status_code == 400
+        assert invalid.json()["detail"]["error_code"] == "E002"
+
+        unknown = test_client.get("/api/v2/lookup/ip/8.8.8.8")
+        assert unknown.status_code == 404
+        assert unknown.json()["detail"]["error_code"] == "E004"
+
+    def test_v1_unchanged(self, test_client, lookup_service):
+        """Should keep the flat v1 shape, without deprecation headers by default."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.json()["country_iso_code"] == "GB"
+        assert "Deprecation" not in response.headers
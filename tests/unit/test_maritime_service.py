@@ -0,0 +1,88 @@
+"""Unit tests for maritime zone detection."""
+import json
+
+import pytest
+
+from src.services.maritime_service import MaritimeZoneService, ZoneType
+
+
+def _box(min_lon, min_lat, max_lon, max_lat):
+    return {
+        "type": "Polygon",
+        "coordinates": [[
+            [min_lon, min_lat], [max_lon, min_lat], [max_lon, max_lat],
+            [min_lon, max_lat], [min_lon, min_lat],
+        ]],
+    }
+
+
+def _write(path, features):
+    path.write_text(json.dumps({
+        "type": "FeatureCollection",
+        "features": [
+            {"type": "Feature", "properties": properties, "geometry": geometry}
+            for properties, geometry in features
+        ],
+    }))
+    return str(path)
+
+
+@pytest.fixture
+def service(tmp_path):
+    """Maritime zones around a toy coastline at longitude 0."""
+    eez = _write(tmp_path / "eez.geojson", [
+        ({"GEONAME": "Atlantis EEZ", "ISO_SOV1": "ATL", "ISO_TER1": "ATL",
+          "MRGID": 101.0, "POL_TYPE": "200NM"}, _box(0, 0, 4, 4)),
+        ({"geoname": "Atlantis/Lemuria joint regime", "iso_sov1": "ATL", "mrgid": 102,
+          "pol_type": "Joint regime"}, _box(3, 3, 4, 4)),
+    ])
+    territorial = _write(tmp_path / "12nm.geojson", [
+        ({"GEONAME": "Atlantis 12 NM", "ISO_SOV1": "ATL", "ISO_TER1": "ATL", "MRGID": 201},
+         _box(0, 0, 0.2, 4)),
+    ])
+    return MaritimeZoneService.from_geojson(eez, territorial)
+
+
+class TestMaritimeZones:
+    """Test zone resolution."""
+
+    def test_territorial_sea_wins(self, service):
+        """Territorial seas should take precedence over the surrounding EEZ."""
+        result = service.zone_at(2.0, 0.1)
+        assert result.zone_type == ZoneType.TERRITORIAL
+        assert result.zone.name == "Atlantis 12 NM"
+        assert result.zone.mrgid == 201
+
+    def test_eez(self, service):
+        """Points beyond 12 NM should resolve to the EEZ with its sovereign."""
+        result = service.zone_at(2.0, 2.0)
+        assert result.zone_type == ZoneType.EEZ
+        assert result.zone.sovereign == "ATL"
+        assert result.zone.mrgid == 101
+        assert not result.zone.shared
+
+    def test_shared_waters_prefer_smallest(self, service):
+        """Joint regimes should win over the EEZ they overlap and be flagged."""
+        result = service.zone_at(3.5, 3.5)
+        assert result.zone.name == "Atlantis/Lemuria joint regime"
+        assert result.zone.shared
+
+    def test_high_seas(self, service):
+        """Sea outside every zone should be high seas."""
+        result = service.zone_at(10.0, 10.0)
+        assert result.zone_type == ZoneType.HIGH_SEAS
+        assert result.zone is None
+
+    def test_zone_count(self, service):
+        """Both layers should be indexed."""
+        assert service.zone_count == 3
+
+    def test_invalid_coordinate(self, service):
+        """Out-of-range coordinates should raise ValueError."""
+        with pytest.raises(ValueError):
+            service.zone_at(0, 181)
+
+    def test_territorial_layer_optional(self, tmp_path):
+        """The EEZ layer alone should be enough."""
+        eez = _write(tmp_path / "eez.geojson", [({"GEONAME": "Atlantis EEZ"}, _box(0, 0, 4, 4))])
+        assert MaritimeZoneService.from_geojson(eez).zone_at(0.1, 0.1).zone_type == ZoneType.EEZ
@@ -0,0 +1,130 @@
+"""Unit tests for schema upgrades of existing databases."""
+import pytest
+from sqlalchemy import create_engine, inspect, text
+
+from src.migrations import (
+    add_detection_geohash,
+    add_detection_tenant,
+    add_device_location_sealing,
+    add_tenant_key_settings,
+    upgrade_database,
+)
+from src.spatial import geohash
+
+
+@pytest.fixture
+def legacy_engine():
+    """In-memory database with a detections table from before geohash existed."""
+    engine = create_engine("sqlite://")
+    with engine.begin() as connection:
+        connection.execute(text(
+            "CREATE TABLE detections ("
+            "id INTEGER PRIMARY KEY, detection_id VARCHAR(36), "
+            "calculated_lat FLOAT, calculated_lon FLOAT)"
+        ))
+        connection.execute(
+            text("INSERT INTO detections (detection_id, calculated_lat, calculated_lon) "
+                 "VALUES (:d, :lat, :lon)"),
+            [
+                {"d": "a", "lat": 51.5074, "lon": -0.1278},
+                {"d": "b", "lat": 40.7128, "lon": -74.0060},
+                {"d": "c", "lat": None, "lon": None},
+            ],
+        )
+    yield engine
+    engine.dispose()
+
+
+class TestDetectionGeohashUpgrade:
+    """Test adding and backfilling detections.geohash."""
+
+    def test_adds_column_index_and_backfills(self, legacy_engine):
+        """Legacy rows should become queryable by geohash."""
+        assert upgrade_database(legacy_engine) == ["detection_geohash", "detection_tenant"]
+
+        inspector = inspect(legacy_engine)
+        assert "geohash" in {c["name"] for c in inspector.get_columns("detections")}
+        assert "idx_detection_geohash" in {i["name"] for i in inspector.get_indexes("detections")}
+
+        with legacy_engine.connect() as connection:
+            rows = dict(connection.execute(
+                text("SELECT detection_id, geohash FROM detections")
+            ).fetchall())
+        assert rows["a"] == geohash.encode(51.5074, -0.1278, geohash.MAX_PRECISION)
+        assert rows["b"].startswith("dr5r")
+        assert rows["c"] is None
+
+    def test_idempotent(self, legacy_engine):
+        """Running the upgrade again should change nothing."""
+        upgrade_database(legacy_engine)
+        assert upgrade_database(legacy_engine) == []
+
+    def test_small_batches(self, legacy_engine):
+        """Backfill should page through rows in batches."""
+        assert add_detection_geohash(legacy_engine, batch_size=1) is True
+        with legacy_engine.connect() as connection:
+            missing = connection.execute(
+                text("SELECT COUNT(*) FROM detections WHERE geohash IS NULL")
+            ).scalar()
+        assert missing == 1
+
+    def test_missing_table_ignored(self):
+        """Databases without a detections table should be left alone."""
+        engine = create_engine("sqlite://")
+        assert upgrade_database(engine) == []
+
+
+class TestDetectionTenantUpgrade:
+    """Test adding detections.tenant_id."""
+
+    def test_adds_column_and_index(self, legacy_engine):
+        """Legacy detections should gain an unattributed tenant_id and its index, once."""
+        add_detection_geohash(legacy_engine)
+        assert add_detection_tenant(legacy_engine) is True
+        inspector = inspect(legacy_engine)
+        assert "tenant_id" in {c["name"] for c in inspector.get_columns("detections")}
+        assert "idx_detection_tenant_geohash" in {i["name"] for i in inspector.get_indexes("detections")}
+        with legacy_engine.connect() as connection:
+            tenants = connection.execute(text("SELECT DISTINCT tenant_id FROM detections")).scalars().all()
+        assert tenants == [None]
+        assert add_detection_tenant(legacy_engine) is False
+
+
+class TestDeviceLocationSealingUpgrade:
+    """Test adding the encryption columns to device_locations."""
+
+    def test_adds_columns_and_index(self):
+        """Legacy device_locations should gain sealed, data_key_id and their index, once."""
+        engine = create_engine("sqlite://")
+        with engine.begin() as connection:
+            connection.execute(text(
+                "CREATE TABLE device_locations ("
+                "id INTEGER PRIMARY KEY, tenant_id VARCHAR(64), device_id VARCHAR(255), "
+                "latitude FLOAT, longitude FLOAT)"
+            ))
+        assert upgrade_database(engine) == ["device_location_sealing"]
+        inspector = inspect(engine)
+        assert {"sealed", "data_key_id"} <= {c["name"] for c in inspector.get_columns("device_locations")}
+        assert "idx_device_location_data_key" in {i["name"] for i in inspector.get_indexes("device_locations")}
+        assert add_device_location_sealing(engine) is False
+
+
+class TestTenantKeySettingsUpgrade:
+    """Test adding the per-key settings to tenant_api_keys."""
+
+    def test_adds_columns(self):
+        """Legacy keys should gain allowed_cidrs, privacy_precision and quota_plan, unrestricted and off."""
+        engine = create_engine("sqlite://")
+        with engine.begin() as connection:
+            connection.execute(text(
+                "CREATE TABLE tenant_api_keys ("
+                "id INTEGER PRIMARY KEY, key_id VARCHAR(32), tenant_id VARCHAR(64))"
+            ))
+            connection.execute(text("INSERT INTO tenant_api_keys (key_id, tenant_id) VALUES ('k1', 'acme')"))
+        assert upgrade_database(engine) == ["tenant_key_settings"]
+        with engine.connect() as connection:
+            row = connection.execute(
+                text("SELECT allowed_cidrs, privacy_precision, quota_plan FROM tenant_api_keys")
+            ).one()
+        assert tuple(row) == ("", None, None)
+        assert add_tenant_key_settings(engine) is False
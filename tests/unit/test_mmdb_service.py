@@ -0,0 +1,202 @@
+"""Unit tests for the memory-mapped MaxMind DB reader."""
+import ipaddress
+
+import pytest
+
+from src.services.mmdb_service import MMDBReader, MMDBDecoder, GeoIPRecord
+from tests.mmdb_writer import encode_value, write_mmdb
+
+
+LONDON = {
+    "city": {"names": {"en": "London", "de": "London"}},
+    "continent": {"code": "EU", "names": {"en": "Europe"}},
+    "country": {"iso_code": "GB", "names": {"en": "United Kingdom", "de": "Vereinigtes Königreich"}},
+    "location": {
+        "latitude": 51.5142,
+        "longitude": -0.0931,
+        "accuracy_radius": 10,
+        "time_zone": "Europe/London",
+    },
+    "postal": {"code": "EC2V"},
+    "subdivisions": [{"iso_code": "ENG", "names": {"en": "England"}}],
+}
+
+SEATTLE = {
+    "city": {"names": {"en": "Seattle"}},
+    "country": {"iso_code": "US", "names": {"en": "United States"}},
+    "location": {"latitude": 47.6062, "longitude": -122.3321, "accuracy_radius": 20},
+}
+
+
+@pytest.fixture
+def city_db(tmp_path):
+    """IPv6 city database with IPv4 and IPv6 networks."""
+    path = write_mmdb(
+        tmp_path / "city.mmdb",
+        [
+            ("81.2.69.128/26", LONDON),
+            ("2001:db8::/32", SEATTLE),
+        ],
+    )
+    with MMDBReader(path) as reader:
+        yield reader
+
+
+class TestDecoder:
+    """Test the MMDB data section decoder."""
+
+    @pytest.mark.parametrize(
+        "value",
+        [
+            "hello",
+            "",
+            "x" * 300,
+            "y" * 70000,
+            42,
+            65535,
+            2**40,
+            2**100,
+            -17,
+            3.25,
+            True,
+            False,
+            [1, "two", 3.0],
+            {"nested": {"list": [1, 2]}},
+        ],
+    )
+    def test_round_trip(self, value):
+        """Values encoded by the writer should decode to the same value."""
+        decoder = MMDBDecoder(encode_value(value))
+        decoded, offset = decoder.decode(0)
+        assert decoded == value
+        assert offset == len(encode_value(value))
+
+    def test_pointer_resolution(self):
+        """Pointers should be resolved relative to the pointer base."""
+        target = encode_value("shared")
+        # Pointer with size bits 0 to offset 0 of the data section
+        buffer = target + bytes([0x20, 0x00])
+        decoder = MMDBDecoder(buffer, pointer_base=0)
+        value, offset = decoder.decode(len(target))
+        assert value == "shared"
+        assert offset == len(buffer)
+
+    def test_invalid_type_raises(self):
+        """Unknown extended types should raise ValueError."""
+        decoder = MMDBDecoder(bytes([0x00, 0x00]))
+        with pytest.raises(ValueError):
+            decoder.decode(0)
+
+
+class TestReaderMetadata:
+    """Test database opening and metadata parsing."""
+
+    def test_metadata_parsed(self, city_db):
+        """Metadata fields should be exposed on the reader."""
+        assert city_db.ip_version == 6
+        assert city_db.record_size == 28
+        assert city_db.database_type == "GeoLite2-City"
+        assert city_db.build_epoch == 1700000000
+
+    def test_invalid_file_raises(self, tmp_path):
+        """A file without the metadata marker should be rejected."""
+        path = tmp_path / "bogus.mmdb"
+        path.write_bytes(b"not a database")
+        with pytest.raises(ValueError, match="metadata marker"):
+            MMDBReader(str(path))
+
+    def test_missing_file_raises(self, tmp_path):
+        """A missing file should raise OSError."""
+        with pytest.raises(OSError):
+            MMDBReader(str(tmp_path / "missing.mmdb"))
+
+    def test_without_mmap(self, tmp_path):
+        """Reader should also work with the file read into memory."""
+        path = write_mmdb(tmp_path / "city.mmdb", [("81.2.69.128/26", LONDON)])
+        reader = MMDBReader(path, use_mmap=False)
+        assert reader.lookup("81.2.69.142").city_name == "London"
+        reader.close()
+
+
+class TestLookup:
+    """Test IP address lookups."""
+
+    def test_ipv4_lookup(self, city_db):
+        """IPv4 addresses should resolve through the ::/96 subtree."""
+        record = city_db.lookup("81.2.69.142")
+        assert isinstance(record, GeoIPRecord)
+        assert record.country_iso_code == "GB"
+        assert record.country_name == "United Kingdom"
+        assert record.city_name == "London"
+        assert record.continent_code == "EU"
+        assert record.postal_code == "EC2V"
+        assert record.latitude == pytest.approx(51.5142)
+        assert record.longitude == pytest.approx(-0.0931)
+        assert record.accuracy_radius_km == 10
+        assert record.time_zone == "Europe/London"
+        assert record.subdivisions == [{"iso_code": "ENG", "name": "England"}]
+
+    def test_network_reported(self, city_db):
+        """Record should carry the containing network."""
+        record = city_db.lookup("81.2.69.130")
+        assert record.network == "81.2.69.128/26"
+
+    def test_ipv6_lookup(self, city_db):
+        """IPv6 addresses should resolve from the root of the tree."""
+        record = city_db.lookup("2001:db8::1")
+        assert record.city_name == "Seattle"
+        assert record.network == "2001:db8::/32"
+
+    def test_not_found_returns_none(self, city_db):
+        """Addresses outside every network should return None."""
+        assert city_db.lookup("8.8.8.8") is None
+        assert city_db.lookup("2001:db9::1") is None
+
+    def test_invalid_ip_raises(self, city_db):
+        """Malformed addresses should raise ValueError."""
+        with pytest.raises(ValueError, match="Invalid IP address"):
+            city_db.lookup("not-an-ip")
+
+    def test_parsed_address(self, city_db):
+        """Parsed addresses should be looked up without formatting them again."""
+        record = city_db.lookup(ipaddress.ip_address("81.2.69.142"))
+        assert (record.ip_address, record.network) == ("81.2.69.142", "81.2.69.128/26")
+
+    def test_decoded_records_kept(self, tmp_path):
+        """Addresses sharing a data record should get the same decoded map, unless the cache is off."""
+        path = write_mmdb(tmp_path / "city.mmdb", [("81.2.69.128/26", LONDON)])
+        with MMDBReader(path) as reader:
+            first, _, _ = reader.lookup_raw("81.2.69.142")
+            again, _, _ = reader.lookup_raw("81.2.69.130")
+            assert first is again and first == LONDON
+        with MMDBReader(path, record_cache_size=0) as reader:
+            assert reader.lookup_raw("81.2.69.142")[0] is not reader.lookup_raw("81.2.69.142")[0]
+
+    def test_localized_names(self, tmp_path):
+        """Reader language should select localized names with English fallback."""
+        path = write_mmdb(tmp_path / "city.mmdb", [("81.2.69.128/26", LONDON)])
+        with MMDBReader(path, language="de") as reader:
+            record = reader.lookup("81.2.69.142")
+        assert record.country_name == "Vereinigtes Königreich"
+        assert record.subdivisions[0]["name"] == "England"
+
+    @pytest.mark.parametrize("record_size", [24, 28, 32])
+    def test_record_sizes(self, tmp_path, record_size):
+        """All three record sizes should be supported."""
+        path = write_mmdb(
+            tmp_path / "city.mmdb",
+            [("81.2.69.128/26", LONDON)],
+            record_size=record_size,
+        )
+        with MMDBReader(path) as reader:
+            assert reader.lookup("81.2.69.142").city_name == "London"
+
+    def test_ipv4_database(self, tmp_path):
+        """IPv4-only databases should reject IPv6 lookups."""
+        path = write_mmdb(
+            tmp_path / "v4.mmdb", [("81.2.69.128/26", LONDON)], ip_version=4
+        )
+        with MMDBReader(path) as reader:
+            assert reader.lookup("81.2.69.142").city_name == "London"
+            with pytest.raises(ValueError, match="IPv4-only"):
+                reader.lookup("2001:db8::1")
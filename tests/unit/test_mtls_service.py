@@ -0,0 +1,162 @@
+"""Unit tests for client certificate identities."""
+import json
+import ssl
+
+import pytest
+
+from src.services.mtls_service import (
+    SCOPE_EXTENSION,
+    ClientCertificateMapper,
+    ClientIdentity,
+    certificate_names,
+    client_certificate_protocol,
+    parse_identities,
+    uvicorn_ssl_options,
+    with_client_certificate,
+)
+
+CERT = {
+    "subject": ((("commonName", "billing-api"),),),
+    "subjectAltName": (
+        ("URI", "spiffe://corp.internal/ns/billing/sa/api"),
+        ("DNS", "billing.internal"),
+        ("IP Address", "10.0.0.7"),
+    ),
+}
+
+
+class TestParseIdentities:
+    """Test MTLS_IDENTITIES parsing."""
+
+    def test_valid(self):
+        """Entries should map certificate names to tenants and scopes."""
+        identities = parse_identities(json.dumps({
+            "URI:spiffe://corp.internal/ns/billing/sa/api": {"tenant_id": "billing", "scopes": ["lookup:read"]},
+            "IP:10.0.0.9": {"tenant_id": "ops"},
+        }))
+        assert identities == {
+            "URI:spiffe://corp.internal/ns/billing/sa/api": ClientIdentity("billing", ("lookup:read",)),
+            "IP:10.0.0.9": ClientIdentity("ops", ()),
+        }
+
+    def test_invalid_entries_skipped(self):
+        """Unknown name types, missing tenants and bad scopes should be skipped."""
+        identities = parse_identities(json.dumps({
+            "CN:billing": {"tenant_id": "billing"},
+            "DNS:a.internal": {"scopes": ["lookup:read"]},
+            "DNS:b.internal": {"tenant_id": "b", "scopes": ["not a scope"]},
+            "DNS:c.internal": {"tenant_id": "c"},
+        }))
+        assert list(identities) == ["DNS:c.internal"]
+
+    def test_malformed(self):
+        """Malformed JSON should map no certificate."""
+        assert parse_identities("{not json") == {}
+        assert parse_identities("[]") == {}
+        assert parse_identities("") == {}
+
+
+class TestClientCertificateMapper:
+    """Test mapping verified certificates to principals."""
+
+    def test_certificate_names(self):
+        """Names should be typed as in MTLS_IDENTITIES."""
+        assert certificate_names(CERT) == [
+            "URI:spiffe://corp.internal/ns/billing/sa/api",
+            "DNS:billing.internal",
+            "IP:10.0.0.7",
+        ]
+
+    def test_mapped(self):
+        """A mapped name should yield the identity's tenant and scopes."""
+        mapper = ClientCertificateMapper({
+            "DNS:billing.internal": ClientIdentity("billing", ("lookup:read",)),
+            "IP:10.0.0.7": ClientIdentity("billing", ("geofence:read",)),
+        })
+        principal = mapper.principal(CERT)
+        assert principal.subject == "mtls:DNS:billing.internal"
+        assert principal.tenant_id == "billing"
+        assert principal.scopes == {"lookup:read", "geofence:read"}
+
+    def test_unmapped(self):
+        """Certificates without a mapped name, or none at all, yield no principal."""
+        mapper = ClientCertificateMapper({"DNS:other.internal": ClientIdentity("other")})
+        assert mapper.principal(CERT) is None
+        assert mapper.principal(None) is None
+        assert mapper.principal({}) is None
+
+    def test_ambiguous(self):
+        """Names mapping to different tenants should not be accepted."""
+        mapper = ClientCertificateMapper({
+            "DNS:billing.internal": ClientIdentity("billing"),
+            "IP:10.0.0.7": ClientIdentity("ops"),
+        })
+        assert mapper.principal(CERT) is None
+
+    async def test_scope_extension(self):
+        """The wrapped app should see the certificate in its ASGI scope."""
+        seen = []
+
+        async def app(scope, receive, send):
+            seen.append(scope)
+
+        await with_client_certificate(app, CERT)({"type": "http", "path": "/"}, None, None)
+        await with_client_certificate(app, CERT)({"type": "lifespan"}, None, None)
+        mapper = ClientCertificateMapper({"DNS:billing.internal": ClientIdentity("billing")})
+        assert seen[0]["extensions"][SCOPE_EXTENSION] is CERT
+        assert mapper.from_scope(seen[0]).tenant_id == "billing"
+        assert "extensions" not in seen[1]
+
+
+class TestListener:
+    """Test the TLS listener options."""
+
+    def test_ssl_options(self):
+        """Client certificates should only be requested with a client CA."""
+        assert uvicorn_ssl_options("server.pem", "server.key") == {
+            "ssl_certfile": "server.pem",
+            "ssl_keyfile": "server.key",
+        }
+        options = uvicorn_ssl_options("server.pem", "server.key", "clients.pem", "required")
+        assert options["ssl_ca_certs"] == "clients.pem"
+        assert options["ssl_cert_reqs"] == ssl.CERT_REQUIRED
+        with pytest.raises(ValueError, match="TLS_CLIENT_AUTH"):
+            uvicorn_ssl_options("server.pem", "server.key", "clients.pem", "sometimes")
+
+    def test_protocol_wraps_app_per_connection(self):
+        """Connections presenting a certificate should pass it to the app."""
+
+        class FakeProtocol:
+            def __init__(self, app):
+                self.app = app
+
+            def connection_made(self, transport):
+                self.transport = transport
+
+        class FakeSSLObject:
+            def __init__(self, certificate):
+                self.certificate = certificate
+
+            def getpeercert(self):
+                return self.certificate
+
+        class FakeTransport:
+            def __init__(self, ssl_object):
+                self.ssl_object = ssl_object
+
+            def get_extra_info(self, name):
+                return self.ssl_object if name == "ssl_object" else None
+
+        async def app(scope, receive, send):
+            pass
+
+        protocol_class = client_certificate_protocol(FakeProtocol)
+        with_cert = protocol_class(app)
+        with_cert.connection_made(FakeTransport(FakeSSLObject(CERT)))
+        assert with_cert.app is not app
+        without_cert = protocol_class(app)
+        without_cert.connection_made(FakeTransport(FakeSSLObject(None)))
+        assert without_cert.app is app
+        plain = protocol_class(app)
+        plain.connection_made(FakeTransport(None))
+        assert plain.app is app
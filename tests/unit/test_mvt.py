@@ -0,0 +1,80 @@
+"""Unit tests for Mapbox Vector Tile encoding and tile math."""
+import pytest
+
+from src.spatial import mvt
+from tests.mvt_reader import read_tile
+
+
+def _area(ring):
+    return sum(ring[i - 1][0] * p[1] - p[0] * ring[i - 1][1] for i, p in enumerate(ring))
+
+
+class TestTileMath:
+    """Test tile addressing and projection."""
+
+    def test_validate_tile(self):
+        """Should accept tiles of the zoom's grid only."""
+        mvt.validate_tile(0, 0, 0)
+        mvt.validate_tile(3, 7, 7)
+        with pytest.raises(ValueError, match="outside zoom 3"):
+            mvt.validate_tile(3, 8, 0)
+        with pytest.raises(ValueError, match="Zoom must be between"):
+            mvt.validate_tile(25, 0, 0)
+
+    def test_tile_bbox(self):
+        """Zoom 1 tile 0/0 should be the north-west quarter of the map."""
+        bbox = mvt.tile_bbox(1, 0, 0)
+        assert (bbox.min_lon, bbox.max_lon) == (-180, 0)
+        assert bbox.min_lat == pytest.approx(0, abs=1e-9)
+        assert bbox.max_lat == pytest.approx(85.0511, abs=1e-4)
+
+    def test_project(self):
+        """Corners should map to the tile's coordinate range."""
+        assert mvt.project(0, 0, 0, -180, 85.0511287798) == pytest.approx((0, 0), abs=1e-6)
+        assert mvt.project(0, 0, 0, 0, 0) == pytest.approx((mvt.EXTENT / 2, mvt.EXTENT / 2))
+        assert mvt.project(1, 1, 1, 0, 0) == pytest.approx((0, 0), abs=1e-6)
+
+    def test_clip_ring(self):
+        """Should cut a ring at the buffered tile edge."""
+        ring = [(-1000, 100), (100, 100), (100, 200), (-1000, 200)]
+        clipped = mvt.clip_ring(ring, buffer=10)
+        assert min(x for x, _ in clipped) == -10
+        assert mvt.clip_ring([(-500, -500), (-400, -500), (-400, -400)]) == []
+
+
+class TestEncoding:
+    """Test layer and tile encoding."""
+
+    def test_points_and_properties(self):
+        """Should encode points with typed, shared properties."""
+        layer = mvt.Layer("detections")
+        assert layer.add_point((10, 20), {"count": 3, "label": "a", "hot": True, "w": 0.5})
+        assert layer.add_point((30.4, 40.6), {"count": 3})
+        assert not layer.add_point((-1, 20))
+
+        tile = read_tile(mvt.encode_tile([layer]))
+        decoded = tile["detections"]
+        assert decoded["version"] == 2
+        assert decoded["extent"] == mvt.EXTENT
+        assert [f["geometry"] for f in decoded["features"]] == [[[(10, 20)]], [[(30, 41)]]]
+        assert decoded["features"][0]["properties"] == {"count": 3, "label": "a", "hot": True, "w": 0.5}
+        assert decoded["features"][0]["type"] == 1
+
+    def test_polygon_winding(self):
+        """Exterior rings should be clockwise (positive area, y down) and holes counterclockwise."""
+        exterior = [(100, 100), (100, 900), (900, 900), (900, 100)]
+        hole = [(300, 300), (600, 300), (600, 600), (300, 600)]
+        layer = mvt.Layer("geofences")
+        assert layer.add_polygon([[exterior, hole]], {"name": "depot"})
+
+        feature = read_tile(mvt.encode_tile([layer]))["geofences"]["features"][0]
+        assert feature["type"] == 3
+        outer, inner = feature["geometry"]
+        assert _area(outer) > 0 and _area(inner) < 0
+        assert sorted(outer) == sorted(exterior)
+
+    def test_polygon_outside_tile_is_skipped(self):
+        """Polygons entirely outside the buffered tile should not be added."""
+        layer = mvt.Layer("geofences")
+        assert not layer.add_polygon([[[(-900, -900), (-800, -900), (-800, -800)]]])
+        assert mvt.encode_tile([layer]) == b""
@@ -0,0 +1,155 @@
+"""Unit tests for admin sign-in through the corporate IdP."""
+import json
+from datetime import datetime, timedelta, timezone
+
+import jwt
+import pytest
+
+from src.services.auth_service import AuthenticationError
+from src.services.oidc_service import OIDCVerifier, claim_values
+
+ISSUER = "https://idp.example.com/realms/corp"
+AUDIENCE = "geolocation-console"
+ROLES = {"geo-ops": frozenset({"admin"}), "geo-support": frozenset({"admin:read"})}
+
+
+class FakeClock:
+    """Manually advanced monotonic clock."""
+
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+class FakeIdP:
+    """Discovery document and JWK set of an IdP, signing with rotating RSA keys."""
+
+    def __init__(self):
+        self.keys = {}
+        self.requests = []
+        self.rotate("k1")
+
+    def rotate(self, kid):
+        serialization = pytest.importorskip("cryptography.hazmat.primitives.serialization")
+        from cryptography.hazmat.primitives.asymmetric import rsa
+
+        key = rsa.generate_private_key(public_exponent=65537, key_size=2048)
+        self.keys[kid] = key
+        self.kid = kid
+        self.private_pem = key.private_bytes(
+            serialization.Encoding.PEM, serialization.PrivateFormat.PKCS8, serialization.NoEncryption()
+        )
+
+    async def fetch(self, url):
+        self.requests.append(url)
+        if url == f"{ISSUER}/.well-known/openid-configuration":
+            return {"issuer": ISSUER, "jwks_uri": f"{ISSUER}/certs"}
+        if url == f"{ISSUER}/certs":
+            keys = []
+            for kid, key in self.keys.items():
+                jwk = json.loads(jwt.algorithms.RSAAlgorithm.to_jwk(key.public_key()))
+                keys.append({**jwk, "kid": kid, "use": "sig", "alg": "RS256"})
+            return {"keys": keys}
+        raise RuntimeError(f"{url} returned HTTP 404")
+
+    def token(self, groups=("geo-ops",), **overrides):
+        now = datetime.now(timezone.utc)
+        claims = {
+            "iss": ISSUER,
+            "aud": AUDIENCE,
+            "sub": "alice",
+            "groups": list(groups),
+            "iat": now,
+            "exp": now + timedelta(minutes=5),
+            **overrides,
+        }
+        return jwt.encode(claims, self.private_pem, algorithm="RS256", headers={"kid": self.kid})
+
+
+@pytest.fixture
+def idp():
+    return FakeIdP()
+
+
+@pytest.fixture
+def clock():
+    return FakeClock()
+
+
+@pytest.fixture
+def oidc(idp, clock):
+    return OIDCVerifier(ISSUER, AUDIENCE, ROLES, fetch=idp.fetch, clock=clock)
+
+
+class TestOIDCVerifier:
+    """Test discovery, token validation and role mapping."""
+
+    async def test_groups_map_to_roles(self, oidc, idp):
+        """A valid token should yield the roles of the user's groups."""
+        principal = await oidc.verify(idp.token(groups=["geo-ops", "geo-support", "staff"]))
+        assert principal.subject == "alice"
+        assert principal.tenant_id == "platform"
+        assert principal.scopes == {"admin", "admin:read"}
+        assert idp.requests == [f"{ISSUER}/.well-known/openid-configuration", f"{ISSUER}/certs"]
+
+    async def test_unmapped_user_has_no_roles(self, oidc, idp):
+        """Users outside every mapped group should authenticate without roles."""
+        assert (await oidc.verify(idp.token(groups=["staff"]))).scopes == frozenset()
+
+    async def test_wrong_audience_rejected(self, oidc, idp):
+        """Tokens issued to another client should be rejected."""
+        with pytest.raises(AuthenticationError, match="Invalid token"):
+            await oidc.verify(idp.token(aud="another-app"))
+
+    async def test_expired_rejected(self, oidc, idp):
+        """Expired tokens should be rejected."""
+        expired = datetime.now(timezone.utc) - timedelta(minutes=1)
+        with pytest.raises(AuthenticationError, match="expired"):
+            await oidc.verify(idp.token(exp=expired))
+
+    async def test_symmetric_algorithm_rejected(self, oidc):
+        """HS256 tokens should not be accepted from the IdP's issuer."""
+        forged = jwt.encode(
+            {"iss": ISSUER, "aud": AUDIENCE, "sub": "mallory", "exp": 9999999999}, "guess", algorithm="HS256"
+        )
+        with pytest.raises(AuthenticationError, match="not accepted"):
+            await oidc.verify(forged)
+
+    async def test_key_rotation(self, oidc, idp, clock):
+        """Tokens signed with a new key should refetch the key set."""
+        await oidc.verify(idp.token())
+        idp.rotate("k2")
+        with pytest.raises(AuthenticationError, match="unknown key"):
+            await oidc.verify(idp.token())  # Refetch throttled
+
+        clock.now += 61
+        assert (await oidc.verify(idp.token())).subject == "alice"
+        assert idp.requests.count(f"{ISSUER}/certs") == 2
+
+    async def test_idp_down(self, idp, clock):
+        """An unreachable IdP should raise RuntimeError, not reject the user."""
+        async def down(url):
+            raise RuntimeError(f"Cannot fetch {url}: connection refused")
+
+        oidc = OIDCVerifier(ISSUER, AUDIENCE, ROLES, fetch=down, clock=clock)
+        with pytest.raises(RuntimeError, match="connection refused"):
+            await oidc.verify(idp.token())
+
+    def test_handles(self, oidc, idp):
+        """Only tokens naming the IdP as issuer should be routed to it."""
+        assert oidc.handles(idp.token())
+        assert not oidc.handles(idp.token(iss="https://other.example.com"))
+        assert not oidc.handles("garbage")
+
+
+class TestClaimValues:
+    """Test group claim extraction."""
+
+    def test_nested_claim(self):
+        """Dotted paths should follow nested objects."""
+        claims = {"realm_access": {"roles": ["geo-ops", 7]}, "groups": "geo-support"}
+        assert claim_values(claims, "realm_access.roles") == ["geo-ops"]
+        assert claim_values(claims, "groups") == ["geo-support"]
+        assert claim_values(claims, "missing.path") == []
@@ -0,0 +1,37 @@
+"""Tests for the generated OpenAPI document."""
+from fastapi.routing import APIRoute
+
+from src.openapi import BEARER_SCHEME
+
+
+def _spec(test_client):
+    response = test_client.get("/openapi.json")
+    assert response.status_code == 200
+    return response.json()
+
+
+class TestOpenApi:
+    """Test /openapi.json."""
+
+    def test_covers_every_route(self, test_client):
+        """Should be an OpenAPI 3.1 document listing every HTTP route and method."""
+        from src.main import app
+
+        spec = _spec(test_client)
+        assert spec["openapi"].startswith("3.1")
+        for route in app.routes:
+            if isinstance(route, APIRoute) and route.include_in_schema:
+                assert route.path_format in spec["paths"], route.path_format
+                for method in route.methods:
+                    assert method.lower() in spec["paths"][route.path_format]
+
+    def test_bearer_security(self, test_client):
+        """Should mark authenticated, optionally authenticated and anonymous operations."""
+        spec = _spec(test_client)
+        assert spec["components"]["securitySchemes"][BEARER_SCHEME]["scheme"] == "bearer"
+
+        paths = spec["paths"]
+        assert paths["/api/v1/jobs"]["post"]["security"] == [{BEARER_SCHEME: []}]
+        assert paths["/api/v1/lookup/batch"]["post"]["security"] == [{}, {BEARER_SCHEME: []}]
+        assert paths["/api/v1/events/stream"]["get"]["security"] == [{BEARER_SCHEME: []}]
+        assert "security" not in paths["/api/v1/health"]["get"]
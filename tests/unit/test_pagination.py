@@ -0,0 +1,23 @@
+"""Unit tests for cursor pagination."""
+from datetime import datetime
+
+import pytest
+
+from src.pagination import Cursor, decode_cursor
+
+
+class TestCursor:
+    """Test cursor tokens."""
+
+    def test_round_trip(self):
+        """A token should decode to the cursor it was made from."""
+        cursor = Cursor(datetime(2026, 3, 1, 9, 0, 0, 123456), 42)
+        token = cursor.encode()
+        assert "=" not in token
+        assert decode_cursor(token) == cursor
+
+    @pytest.mark.parametrize("token", ["", "x", "bm90LWpzb24", "eyJhdCI6IjIwMjYifQ"])
+    def test_invalid(self, token):
+        """Malformed tokens should be rejected."""
+        with pytest.raises(ValueError, match="Invalid cursor"):
+            decode_cursor(token)
@@ -0,0 +1,144 @@
+"""Route tests for the POI dataset API."""
+import pytest
+
+from src.services.auth_service import TokenVerifier
+
+
+STATIONS = [
+    {"id": "kgx", "name": "King's Cross", "latitude": 51.5308, "longitude": -0.1238},
+    {"id": "eus", "name": "Euston", "latitude": 51.5282, "longitude": -0.1337},
+    {"id": "pad", "name": "Paddington", "latitude": 51.5154, "longitude": -0.1755},
+]
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API, with a test secret."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+def _auth(verifier, tenant_id):
+    return {"Authorization": f"Bearer {verifier.issue('user-1', tenant_id)}"}
+
+
+@pytest.fixture
+def dataset(db_client, verifier):
+    """Station dataset uploaded by tenant "acme"."""
+    response = db_client.post(
+        "/api/v1/poi/datasets",
+        json={"name": "Stations", "pois": STATIONS},
+        headers=_auth(verifier, "acme"),
+    )
+    assert response.status_code == 201
+    return response.json()
+
+
+class TestPoiAuthentication:
+    """Test bearer token handling on the POI endpoints."""
+
+    def test_missing_token_is_401(self, db_client, verifier):
+        """Should require a bearer token."""
+        response = db_client.get("/api/v1/poi/datasets")
+        assert response.status_code == 401
+        assert response.json()["detail"]["error_code"] == "E006"
+        assert response.headers["WWW-Authenticate"] == "Bearer"
+
+    def test_tenant_header_not_trusted(self, db_client, verifier):
+        """Should ignore X-Tenant-ID without a valid token."""
+        response = db_client.get("/api/v1/poi/datasets", headers={"X-Tenant-ID": "acme"})
+        assert response.status_code == 401
+
+    def test_invalid_token_is_401(self, db_client, verifier):
+        """Should reject tokens signed with another secret."""
+        other = TokenVerifier("another-secret-0123456789abcdef")
+        response = db_client.get("/api/v1/poi/datasets", headers=_auth(other, "acme"))
+        assert response.status_code == 401
+
+    def test_unconfigured_is_503(self, db_client, monkeypatch):
+        """Should return 503 when no signing secret is configured."""
+        monkeypatch.setattr(
+            "src.api.dependencies.get_token_verifier", lambda: TokenVerifier("")
+        )
+        response = db_client.get(
+            "/api/v1/poi/datasets", headers={"Authorization": "Bearer abc.def.ghi"}
+        )
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+
+class TestPoiRoutes:
+    """Test dataset management and nearest-neighbor queries."""
+
+    def test_nearest(self, db_client, verifier, dataset):
+        """Should return the nearest POIs in order."""
+        response = db_client.get(
+            f"/api/v1/poi/datasets/{dataset['dataset_id']}/nearest",
+            params={"lat": 51.5300, "lon": -0.1270, "n": 2},
+            headers=_auth(verifier, "acme"),
+        )
+        assert response.status_code == 200
+        assert [r["id"] for r in response.json()["results"]] == ["kgx", "eus"]
+
+    def test_other_tenant_gets_404(self, db_client, verifier, dataset):
+        """Should hide datasets owned by another tenant."""
+        headers = _auth(verifier, "globex")
+        response = db_client.get(
+            f"/api/v1/poi/datasets/{dataset['dataset_id']}", headers=headers
+        )
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+        assert db_client.get("/api/v1/poi/datasets", headers=headers).json() == []
+
+    def test_invalid_query_is_400(self, db_client, verifier, dataset):
+        """Should reject coordinates out of range."""
+        response = db_client.get(
+            f"/api/v1/poi/datasets/{dataset['dataset_id']}/nearest",
+            params={"lat": 91.0, "lon": 0.0},
+            headers=_auth(verifier, "acme"),
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_delete(self, db_client, verifier, dataset):
+        """Should delete the dataset for its owner."""
+        headers = _auth(verifier, "acme")
+        url = f"/api/v1/poi/datasets/{dataset['dataset_id']}"
+        assert db_client.delete(url, headers=headers).status_code == 204
+        assert db_client.get(url, headers=headers).status_code == 404
+
+
+class TestPoiImportRoute:
+    """Test POST /api/v1/poi/datasets/import."""
+
+    def test_import(self, db_client, verifier, monkeypatch, tmp_path):
+        """Should create a dataset of the valid rows."""
+        monkeypatch.setenv("BATCH_JOB_RESULT_DIR", str(tmp_path))
+        upload = b"id,latitude,longitude,name\nkgx,51.5308,-0.1238,King's Cross\neus,,-0.1337,Euston\n"
+
+        response = db_client.post(
+            "/api/v1/poi/datasets/import",
+            data={"name": "Stations"},
+            files={"file": ("stations.csv", upload, "text/csv")},
+            headers=_auth(verifier, "acme"),
+        )
+        assert response.status_code == 201
+        body = response.json()
+        assert (body["imported_rows"], body["failed_rows"]) == (1, 1)
+        assert body["errors"][0]["index"] == 1
+
+        dataset = db_client.get(f"/api/v1/poi/datasets/{body['dataset_id']}", headers=_auth(verifier, "acme"))
+        assert dataset.json()["poi_count"] == 1
+
+    def test_no_valid_rows_is_400(self, db_client, verifier, monkeypatch, tmp_path):
+        """Should reject an import without a valid row."""
+        monkeypatch.setenv("BATCH_JOB_RESULT_DIR", str(tmp_path))
+        response = db_client.post(
+            "/api/v1/poi/datasets/import",
+            data={"name": "Stations"},
+            files={"file": ("stations.csv", b"id,latitude,longitude\nkgx,,\n", "text/csv")},
+            headers=_auth(verifier, "acme"),
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_message"] == "No valid POI rows to import"
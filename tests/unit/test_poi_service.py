@@ -0,0 +1,100 @@
+"""Unit tests for tenant POI datasets and nearest-neighbor search."""
+import pytest
+
+from src.models.database_models import Poi
+from src.services.poi_service import PoiIndex, PoiIndexCache, PoiRecord, PoiService
+
+POIS = [
+    {"id": "store-1", "latitude": 51.5007, "longitude": -0.1246, "name": "Westminster"},
+    {"id": "store-2", "latitude": 51.5081, "longitude": -0.0759, "name": "Tower Hill"},
+    {"id": "store-3", "latitude": 48.8584, "longitude": 2.2945, "properties": {"city": "Paris"}},
+]
+
+
+@pytest.fixture
+def poi_service(db_session):
+    """POI service with its own index cache."""
+    return PoiService(db_session, cache=PoiIndexCache(4), max_dataset_size=10, max_results=5)
+
+
+class TestPoiService:
+    """Test dataset storage, tenant isolation and queries."""
+
+    def test_create_dataset(self, poi_service, db_session):
+        """Uploading should store the dataset and its POIs."""
+        dataset = poi_service.create_dataset("acme", "stores", POIS)
+
+        assert dataset.poi_count == 3
+        assert dataset.tenant_id == "acme"
+        assert db_session.query(Poi).filter_by(dataset_id=dataset.dataset_id).count() == 3
+
+    def test_nearest(self, poi_service):
+        """Nearest query should rank by distance and honor the radius."""
+        dataset = poi_service.create_dataset("acme", "stores", POIS)
+
+        matches = poi_service.nearest(dataset, 51.5033, -0.1196, n=5)
+        assert [m.poi.poi_id for m in matches] == ["store-1", "store-2", "store-3"]
+        assert matches[0].distance_meters == pytest.approx(451, abs=1)
+
+        within = poi_service.nearest(dataset, 51.5033, -0.1196, n=5, radius_meters=5000)
+        assert [m.poi.poi_id for m in within] == ["store-1", "store-2"]
+
+    def test_index_rebuilt_from_database(self, poi_service, db_session):
+        """A cold cache should rebuild the index from stored POIs."""
+        dataset = poi_service.create_dataset("acme", "stores", POIS)
+        cold = PoiService(db_session, cache=PoiIndexCache(4), max_dataset_size=10, max_results=5)
+
+        match, = cold.nearest(dataset, 48.86, 2.29, n=1)
+        assert match.poi.poi_id == "store-3"
+        assert match.poi.properties == {"city": "Paris"}
+
+    def test_tenant_isolation(self, poi_service):
+        """Datasets should only be visible to their tenant."""
+        dataset = poi_service.create_dataset("acme", "stores", POIS)
+
+        assert poi_service.get_dataset("acme", dataset.dataset_id) is not None
+        assert poi_service.get_dataset("globex", dataset.dataset_id) is None
+        assert poi_service.list_datasets("globex") == []
+
+    def test_delete_dataset(self, poi_service, db_session):
+        """Deleting should remove POIs and the cached index."""
+        dataset = poi_service.create_dataset("acme", "stores", POIS)
+        dataset_id = dataset.dataset_id
+        poi_service.delete_dataset(dataset)
+
+        assert poi_service.get_dataset("acme", dataset_id) is None
+        assert db_session.query(Poi).filter_by(dataset_id=dataset_id).count() == 0
+        assert poi_service.cache.get(dataset_id) is None
+
+    def test_invalid_datasets_rejected(self, poi_service):
+        """Empty, oversized, duplicate or out-of-range datasets should raise ValueError."""
+        with pytest.raises(ValueError, match="at least one"):
+            poi_service.create_dataset("acme", "empty", [])
+        with pytest.raises(ValueError, match="limit"):
+            poi_service.create_dataset("acme", "big", POIS * 4)
+        with pytest.raises(ValueError, match="Duplicate"):
+            poi_service.create_dataset("acme", "dup", [POIS[0], POIS[0]])
+        with pytest.raises(ValueError, match="latitude out of range"):
+            poi_service.create_dataset("acme", "bad", [{"id": "x", "latitude": 95, "longitude": 0}])
+
+    def test_invalid_query_rejected(self, poi_service):
+        """Out-of-range n should raise ValueError."""
+        dataset = poi_service.create_dataset("acme", "stores", POIS)
+        with pytest.raises(ValueError, match="n must be"):
+            poi_service.nearest(dataset, 0.0, 0.0, n=6)
+
+
+class TestPoiIndexCache:
+    """Test the LRU index cache."""
+
+    def test_evicts_least_recently_used(self):
+        """The oldest untouched index should be evicted first."""
+        cache = PoiIndexCache(2)
+        for dataset_id in ("a", "b"):
+            cache.put(PoiIndex.build(dataset_id, [PoiRecord("p", 0.0, 0.0)]))
+        cache.get("a")
+        cache.put(PoiIndex.build("c", [PoiRecord("p", 0.0, 0.0)]))
+
+        assert cache.get("b") is None
+        assert cache.get("a") is not None
+        assert len(cache) == 2
@@ -0,0 +1,97 @@
+"""Unit tests for position estimates from ranged anchors."""
+import pytest
+
+from src.spatial.distance import haversine
+from src.spatial.positioning import (
+    RangedAnchor,
+    log_distance_range,
+    consistent_cluster,
+    weighted_centroid,
+)
+
+
+class TestLogDistanceRange:
+    """Test RSSI to distance conversion."""
+
+    def test_reference_power_is_reference_distance(self):
+        """RSSI equal to the reference power should be the reference distance."""
+        assert log_distance_range(-40, reference_dbm=-40) == pytest.approx(1.0)
+
+    def test_decade_per_10n_db(self):
+        """Each 10*n dB of loss should multiply the distance by ten."""
+        assert log_distance_range(-70, reference_dbm=-40, path_loss_exponent=3) == pytest.approx(10)
+        assert log_distance_range(-80, reference_dbm=-40, path_loss_exponent=2) == pytest.approx(100)
+
+    def test_stronger_than_reference(self):
+        """Signals above the reference power should not give sub-reference distances."""
+        assert log_distance_range(-20, reference_dbm=-40) == pytest.approx(1.0)
+
+    def test_invalid_exponent(self):
+        """A non-positive exponent should raise ValueError."""
+        with pytest.raises(ValueError):
+            log_distance_range(-60, path_loss_exponent=0)
+
+
+class TestWeightedCentroid:
+    """Test centroid estimates and accuracy radii."""
+
+    def test_single_anchor(self):
+        """One anchor should give its position with its range as accuracy."""
+        estimate = weighted_centroid([RangedAnchor(51.5, -0.12, 30.0)])
+        assert estimate.latitude == pytest.approx(51.5)
+        assert estimate.longitude == pytest.approx(-0.12)
+        assert estimate.accuracy_m == pytest.approx(30.0)
+        assert estimate.anchors_used == 1
+
+    def test_equal_ranges_give_midpoint(self):
+        """Two anchors at equal range should give their midpoint."""
+        estimate = weighted_centroid([
+            RangedAnchor(51.5, -0.1200, 20.0),
+            RangedAnchor(51.5, -0.1180, 20.0),
+        ])
+        assert estimate.latitude == pytest.approx(51.5, abs=1e-6)
+        assert estimate.longitude == pytest.approx(-0.1190, abs=1e-6)
+        # Anchors are ~138 m apart, so each is ~69 m from the estimate
+        assert estimate.accuracy_m == pytest.approx(69.2, abs=0.5)
+
+    def test_nearer_anchor_dominates(self):
+        """The estimate should lie closer to the shorter-range anchor."""
+        near, far = RangedAnchor(51.5, -0.1200, 5.0), RangedAnchor(51.5, -0.1180, 50.0)
+        estimate = weighted_centroid([near, far])
+        to_near = haversine(estimate.latitude, estimate.longitude, near.latitude, near.longitude)
+        to_far = haversine(estimate.latitude, estimate.longitude, far.latitude, far.longitude)
+        assert to_near.meters < to_far.meters / 10
+
+    def test_antimeridian(self):
+        """Anchors either side of the antimeridian should not average to 0 degrees."""
+        estimate = weighted_centroid([
+            RangedAnchor(-16.5, 179.9995, 10.0),
+            RangedAnchor(-16.5, -179.9995, 10.0),
+        ])
+        assert abs(estimate.longitude) == pytest.approx(180.0, abs=1e-6)
+
+    def test_no_anchors(self):
+        """An empty anchor list should raise ValueError."""
+        with pytest.raises(ValueError):
+            weighted_centroid([])
+
+
+class TestConsistentCluster:
+    """Test dropping anchors far from the others."""
+
+    def test_drops_distant_anchor(self):
+        """An anchor kilometers away from the rest should be dropped."""
+        near = RangedAnchor(51.5, -0.12, 10.0)
+        close = RangedAnchor(51.5005, -0.12, 40.0)
+        moved = RangedAnchor(48.85, 2.29, 5.0)
+        assert consistent_cluster([close, moved, near], max_spread_m=500) == [near, close]
+
+    def test_tie_prefers_shortest_range(self):
+        """Equally large groups should be decided by the shortest range."""
+        london = RangedAnchor(51.5, -0.12, 40.0)
+        paris = RangedAnchor(48.85, 2.29, 10.0)
+        assert consistent_cluster([london, paris], max_spread_m=500) == [paris]
+
+    def test_empty(self):
+        """No anchors should give an empty cluster."""
+        assert consistent_cluster([], max_spread_m=500) == []
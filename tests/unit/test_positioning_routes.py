@@ -0,0 +1,86 @@
+"""Route tests for the radio positioning API."""
+import pytest
+
+from src.services.cell_service import CellPositioningService
+from src.services.wifi_service import WifiPositioningService
+
+
+@pytest.fixture
+def access_points(db_session):
+    """Two known access points near Waterloo."""
+    WifiPositioningService(db_session).ingest([
+        {"bssid": "00:11:22:33:44:55", "latitude": 51.50300, "longitude": -0.11950},
+        {"bssid": "00:11:22:33:44:66", "latitude": 51.50320, "longitude": -0.11920},
+    ])
+
+
+@pytest.fixture
+def towers(db_session):
+    """One LTE tower from an OpenCelliD extract."""
+    CellPositioningService(db_session).ingest([
+        {"radio": "LTE", "mcc": "234", "net": "15", "area": "1234", "cell": "1001",
+         "lon": "-0.1195", "lat": "51.5030", "range": "1500", "samples": "40"},
+    ])
+
+
+class TestWifiPositionRoute:
+    """Test POST /api/v1/position/wifi."""
+
+    def test_locate(self, db_client, access_points):
+        """Should estimate a position from known access points."""
+        response = db_client.post("/api/v1/position/wifi", json={"access_points": [
+            {"bssid": "00:11:22:33:44:55", "rssi": -55},
+            {"bssid": "00:11:22:33:44:66", "rssi": -60},
+        ]})
+        assert response.status_code == 200
+        body = response.json()
+        assert body["source"] == "wifi"
+        assert body["anchors_used"] == 2
+        assert body["latitude"] == pytest.approx(51.5031, abs=0.0002)
+        assert body["accuracy_m"] > 0
+        assert body["geohash"].startswith("gcpuv")
+
+    def test_unknown_access_points_is_404(self, db_client, access_points):
+        """Should return 404 when too few access points are known."""
+        response = db_client.post("/api/v1/position/wifi", json={"access_points": [
+            {"bssid": "de:ad:be:ef:00:01", "rssi": -55},
+        ]})
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_invalid_bssid_is_400(self, db_client, access_points):
+        """Should reject malformed BSSIDs."""
+        response = db_client.post("/api/v1/position/wifi", json={"access_points": [
+            {"bssid": "not-a-mac", "rssi": -55},
+        ]})
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+
+class TestCellPositionRoute:
+    """Test POST /api/v1/position/cell."""
+
+    def test_locate(self, db_client, towers):
+        """Should return the tower location with its range as accuracy."""
+        response = db_client.post("/api/v1/position/cell", json={"cells": [
+            {"radio": "LTE", "mcc": 234, "mnc": 15, "lac": 1234, "cid": 1001},
+        ]})
+        assert response.status_code == 200
+        body = response.json()
+        assert body["source"] == "cell"
+        assert body["latitude"] == pytest.approx(51.5030)
+        assert body["accuracy_m"] == 1500
+
+    def test_unknown_cell_is_404(self, db_client, towers):
+        """Should return 404 for cells not in the database."""
+        response = db_client.post("/api/v1/position/cell", json={"cells": [
+            {"mcc": 234, "mnc": 15, "lac": 1, "cid": 1},
+        ]})
+        assert response.status_code == 404
+
+    def test_unknown_radio_is_400(self, db_client, towers):
+        """Should reject unknown radio types."""
+        response = db_client.post("/api/v1/position/cell", json={"cells": [
+            {"radio": "WIMAX", "mcc": 234, "mnc": 15, "lac": 1234, "cid": 1001},
+        ]})
+        assert response.status_code == 400
@@ -0,0 +1,77 @@
+"""Route tests for the postal code API."""
+import pytest
+
+from src.services.country_info_service import CountryInfo
+from src.services.postal_service import PostalService
+from src.spatial.geometry import Polygon
+
+MITTE = Polygon([(13.0, 52.0), (14.0, 52.0), (14.0, 53.0), (13.0, 53.0)])
+
+
+@pytest.fixture
+def postal_areas(db_session, monkeypatch):
+    """One imported German postal area and the German postal format."""
+    countries = {"DE": CountryInfo(iso_code="DE", postal_code_regex=r"^(\d{5})$")}
+    monkeypatch.setattr("src.services.postal_service.get_country_info", lambda: countries)
+    PostalService(db_session).import_features([({"postcode": "10117", "name": "Mitte"}, MITTE)], "DE")
+
+
+class TestPostalRoutes:
+    """Test the postal code endpoints."""
+
+    def test_get(self, db_client, postal_areas):
+        """Should return the centroid and bounds, without geometry by default."""
+        response = db_client.get("/api/v1/postal-codes/de/10117")
+        assert response.status_code == 200
+        body = response.json()
+        assert body["country_code"] == "DE"
+        assert (body["latitude"], body["longitude"]) == (52.5, 13.5)
+        assert body["bbox"] == [13.0, 52.0, 14.0, 53.0]
+        assert body["geometry"] is None
+
+    def test_get_with_geometry(self, db_client, postal_areas):
+        """Should include the boundary when requested."""
+        response = db_client.get("/api/v1/postal-codes/DE/10117?geometry=true")
+        assert response.json()["geometry"]["type"] == "Polygon"
+
+    def test_get_as_geojson(self, db_client, postal_areas):
+        """Should return the boundary as a Feature with the area's attributes."""
+        response = db_client.get("/api/v1/postal-codes/DE/10117", params={"format": "geojson"})
+        assert response.status_code == 200
+        assert response.headers["content-type"] == "application/geo+json"
+        body = response.json()
+        assert body["id"] == "DE-10117"
+        assert body["bbox"] == [13.0, 52.0, 14.0, 53.0]
+        assert body["geometry"]["type"] == "Polygon"
+        assert body["properties"]["name"] == "Mitte"
+
+    def test_get_unknown_is_404(self, db_client, postal_areas):
+        """Should return 404 for codes not in the dataset."""
+        response = db_client.get("/api/v1/postal-codes/DE/10119")
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_bad_country_is_400(self, db_client, postal_areas):
+        """Should reject malformed country codes."""
+        response = db_client.get("/api/v1/postal-codes/DEU/10117")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_locate(self, db_client, postal_areas):
+        """Should return the area containing a coordinate."""
+        response = db_client.get("/api/v1/postal-codes/locate?lat=52.5&lon=13.4")
+        assert response.status_code == 200
+        assert response.json()["postal_code"] == "10117"
+
+    def test_locate_outside_is_404(self, db_client, postal_areas):
+        """Should return 404 outside every area."""
+        response = db_client.get("/api/v1/postal-codes/locate?lat=48.1&lon=11.6")
+        assert response.status_code == 404
+
+    def test_validate(self, db_client, postal_areas):
+        """Should report the format check and whether the code is known."""
+        response = db_client.get("/api/v1/postal-codes/DE/1011/validate")
+        assert response.status_code == 200
+        assert response.json() == {
+            "country_code": "DE", "postal_code": "1011", "format_valid": False, "known": False,
+        }
@@ -0,0 +1,133 @@
+"""Unit tests for postal code areas."""
+import json
+
+import pytest
+
+from src.services.country_info_service import CountryInfo
+from src.services.postal_service import PostalService, normalize_postal_code
+from src.spatial.geometry import Polygon
+
+
+def _square(min_lon, min_lat, size):
+    return Polygon([
+        (min_lon, min_lat), (min_lon + size, min_lat),
+        (min_lon + size, min_lat + size), (min_lon, min_lat + size),
+    ])
+
+
+FEATURES = [
+    ({"postcode": "10117", "country_code": "de", "name": "Berlin Mitte"}, _square(13.0, 52.0, 1.0)),
+    ({"postcode": "10115", "country_code": "DE"}, _square(13.2, 52.2, 0.2)),  # inside 10117
+    ({"postal_code": "sw1a  1aa", "country": "GB"}, _square(-0.2, 51.4, 0.2)),
+    ({"name": "no code", "country_code": "DE"}, _square(0.0, 0.0, 1.0)),
+    ({"postcode": "99999"}, _square(0.0, 0.0, 1.0)),  # no country
+]
+
+COUNTRIES = {
+    "DE": CountryInfo(iso_code="DE", postal_code_format="#####", postal_code_regex=r"^(\d{5})$"),
+    "GB": CountryInfo(iso_code="GB"),
+}
+
+
+@pytest.fixture
+def postal_service(db_session):
+    """Postal service with a few German and British areas."""
+    service = PostalService(db_session, countries=COUNTRIES)
+    assert service.import_features(FEATURES) == 3
+    return service
+
+
+class TestNormalization:
+    """Test postal code normalization."""
+
+    def test_case_and_spacing(self):
+        """Should upper-case and collapse whitespace."""
+        assert normalize_postal_code("  sw1a \t 1aa ") == "SW1A 1AA"
+
+    def test_empty_rejected(self):
+        """Should reject empty codes."""
+        with pytest.raises(ValueError):
+            normalize_postal_code("   ")
+
+
+class TestPostalImport:
+    """Test importing postal area polygons."""
+
+    def test_centroid_and_bbox(self, postal_service):
+        """Stored areas should carry their centroid and bounds."""
+        area = postal_service.get("de", "10117")
+        assert area.name == "Berlin Mitte"
+        assert (area.centroid_lat, area.centroid_lon) == pytest.approx((52.5, 13.5))
+        assert (area.min_lon, area.min_lat, area.max_lon, area.max_lat) == (13.0, 52.0, 14.0, 53.0)
+
+    def test_reimport_replaces(self, postal_service, db_session):
+        """Re-importing a code should replace its boundary."""
+        postal_service.import_features([({"postcode": "10117"}, _square(10.0, 50.0, 2.0))], "DE")
+        area = postal_service.get("DE", "10117")
+        assert (area.centroid_lat, area.centroid_lon) == pytest.approx((51.0, 11.0))
+        assert area.name is None
+
+    def test_import_geojson(self, db_session, tmp_path):
+        """Should read codes from GeoJSON features with a file-wide country."""
+        path = tmp_path / "postal.geojson"
+        path.write_text(json.dumps({
+            "type": "FeatureCollection",
+            "features": [{
+                "type": "Feature",
+                "properties": {"zip": "10001"},
+                "geometry": _square(-74.0, 40.7, 0.1).to_geojson(),
+            }],
+        }))
+        service = PostalService(db_session, countries={})
+        assert service.import_geojson(str(path), "us") == 1
+        assert service.get("US", "10001") is not None
+
+    def test_invalid_country_rejected(self, db_session):
+        """Should reject a malformed file-wide country."""
+        with pytest.raises(ValueError):
+            PostalService(db_session, countries={}).import_features(FEATURES, "DEU")
+
+
+class TestPostalLookup:
+    """Test resolving codes and coordinates."""
+
+    def test_get_normalizes(self, postal_service):
+        """Should find codes regardless of case and spacing."""
+        assert postal_service.get("gb", "Sw1A 1aa").postal_code == "SW1A 1AA"
+        assert postal_service.get("GB", "SW1A 2AA") is None
+
+    def test_locate_smallest(self, postal_service):
+        """Overlapping areas should resolve to the smallest."""
+        assert postal_service.locate(52.3, 13.3).postal_code == "10115"
+        assert postal_service.locate(52.8, 13.8).postal_code == "10117"
+
+    def test_locate_outside(self, postal_service):
+        """Points outside every area or in another country should give None."""
+        assert postal_service.locate(0.5, 0.5) is None
+        assert postal_service.locate(51.5, -0.1, country_code="DE") is None
+        assert postal_service.locate(51.5, -0.1, country_code="GB").postal_code == "SW1A 1AA"
+
+    def test_locate_out_of_range(self, postal_service):
+        """Should reject invalid coordinates."""
+        with pytest.raises(ValueError):
+            postal_service.locate(91.0, 0.0)
+
+
+class TestPostalValidation:
+    """Test postal code format checks."""
+
+    def test_known_and_valid(self, postal_service):
+        """Should report a well-formed imported code."""
+        result = postal_service.validate("DE", "10117")
+        assert (result.format_valid, result.known) == (True, True)
+
+    def test_malformed(self, postal_service):
+        """Should flag codes that do not match the country format."""
+        result = postal_service.validate("DE", "1011")
+        assert (result.format_valid, result.known) == (False, False)
+
+    def test_unknown_format(self, postal_service):
+        """Should give None when the country publishes no format."""
+        result = postal_service.validate("GB", "sw1a 1aa")
+        assert result.postal_code == "SW1A 1AA"
+        assert (result.format_valid, result.known) == (None, True)
@@ -0,0 +1,151 @@
+"""Unit tests for the privacy mode of API keys."""
+import logging
+
+import pytest
+from fastapi import FastAPI, WebSocket
+from fastapi.responses import Response, StreamingResponse
+from fastapi.testclient import TestClient
+from starlette.websockets import WebSocketDisconnect
+
+from src.middleware.privacy import PrivacyMiddleware
+from src.services.auth_service import TokenVerifier
+from src.services.privacy_service import (
+    enter_privacy_mode,
+    install_log_redaction,
+    leave_privacy_mode,
+    redact_content,
+    redact_ips,
+    token_precision,
+    truncate,
+    validate_precision,
+)
+
+SECRET = "test-secret-0123456789abcdef0123456789"
+
+
+class TestRedaction:
+    """Test rewriting response content and log text."""
+
+    def test_truncate(self):
+        """Coordinates should be truncated toward zero, not rounded."""
+        assert truncate(51.51999, 2) == 51.51
+        assert truncate(-0.0999, 2) == -0.09
+        assert truncate(10.9, 0) == 10.0
+
+    def test_redact_content(self):
+        """Coordinates, positions and geohashes should be coarsened and street data dropped."""
+        content = {
+            "latitude": 51.50142,
+            "from_lon": -0.14189,
+            "accuracy_km": 0.05,
+            "geometry": {"type": "Point", "coordinates": [-0.14189, 51.50142]},
+            "bbox": [-0.1291, 51.5066, -0.1277, 51.5080],
+            "geohash": "gcpuuz94k",
+            "neighbors": {"n": "gcpuuz95h"},
+            "candidates": [{
+                "formatted_address": "Buckingham Palace, London SW1A 1AA",
+                "components": {"road": "The Mall", "house_number": "1", "city": "London"},
+            }],
+            "confident": True,
+        }
+        assert redact_content(content, 2) == {
+            "latitude": 51.5,
+            "from_lon": -0.14,
+            "accuracy_km": 0.05,
+            "geometry": {"type": "Point", "coordinates": [-0.14, 51.5]},
+            "bbox": [-0.12, 51.5, -0.12, 51.5],
+            "geohash": "gcpuu",
+            "neighbors": {"n": "gcpuu"},
+            "candidates": [{"components": {"city": "London"}}],
+            "confident": True,
+        }
+
+    def test_redact_ips(self):
+        """IPv4 and IPv6 addresses should be replaced, other numbers kept."""
+        assert redact_ips("Lookup of 81.2.69.142 from 2001:db8::1: 404") == "Lookup of [ip] from [ip]: 404"
+        assert redact_ips("took 10:00:05 for v1.2.3") == "took 10:00:05 for v1.2.3"
+        assert redact_ips("peer ::ffff:10.0.0.1") == "peer [ip]"
+
+    def test_precision(self):
+        """Precisions should be 0-6 and read from the token claim."""
+        assert validate_precision(None) is None
+        with pytest.raises(ValueError, match="0 to 6"):
+            validate_precision(7)
+        token = TokenVerifier(SECRET).issue("acme-api", "acme", privacy_precision=2)
+        assert token_precision(token) == 2
+        assert token_precision(TokenVerifier(SECRET).issue("acme-api", "acme")) is None
+        assert token_precision("garbage") is None
+
+    def test_log_records_redacted(self, caplog):
+        """Only records created in privacy mode should lose their addresses."""
+        install_log_redaction()
+        logger = logging.getLogger("tests.privacy")
+        with caplog.at_level(logging.INFO, logger="tests.privacy"):
+            logger.info("Lookup of %s", "81.2.69.142")
+            mode = enter_privacy_mode(2)
+            try:
+                logger.info("Lookup of %s", "81.2.69.142")
+            finally:
+                leave_privacy_mode(mode)
+        assert [r.getMessage() for r in caplog.records] == ["Lookup of 81.2.69.142", "Lookup of [ip]"]
+
+
+def _client():
+    app = FastAPI()
+    app.add_middleware(PrivacyMiddleware)
+
+    @app.get("/api/v1/point")
+    async def point():
+        return {"latitude": 51.50142, "longitude": -0.14189, "formatted_address": "SW1A 1AA"}
+
+    @app.get("/api/v1/point.pb")
+    async def protobuf():
+        return Response(b"\x08\x01", media_type="application/x-protobuf")
+
+    @app.get("/api/v1/results")
+    async def results():
+        async def lines():
+            yield b'{"latitude": 51.50142}\n{"lat'
+            yield b'itude": 48.85661}\n'
+        return StreamingResponse(lines(), media_type="application/x-ndjson")
+
+    @app.websocket("/api/v1/ws")
+    async def ws(websocket: WebSocket):
+        await websocket.accept()
+        await websocket.send_json({"ok": True})
+        await websocket.close()
+
+    return TestClient(app)
+
+
+def _auth(precision=2):
+    return {"Authorization": f"Bearer {TokenVerifier(SECRET).issue('acme-api', 'acme', privacy_precision=precision)}"}
+
+
+class TestPrivacyMiddleware:
+    """Test applying privacy mode to responses."""
+
+    def test_json_rewritten(self):
+        """Privacy-mode tokens should get coarse JSON; others the route's answer."""
+        client = _client()
+        response = client.get("/api/v1/point", headers=_auth())
+        assert response.json() == {"latitude": 51.5, "longitude": -0.14}
+        assert response.headers["content-length"] == str(len(response.content))
+        assert client.get("/api/v1/point").json()["latitude"] == 51.50142
+
+    def test_ndjson_rewritten_by_line(self):
+        """Lines split across chunks should be rewritten whole."""
+        response = _client().get("/api/v1/results", headers=_auth(1))
+        assert response.text.splitlines() == ['{"latitude":51.5}', '{"latitude":48.8}']
+
+    def test_other_formats_refused(self):
+        """Formats that cannot be rewritten should be refused."""
+        client = _client()
+        response = client.get("/api/v1/point.pb", headers=_auth())
+        assert response.status_code == 403
+        assert response.json()["detail"]["error_code"] == "E012"
+        assert client.get("/api/v1/point.pb").status_code == 200
+        with pytest.raises(WebSocketDisconnect) as closed:
+            with client.websocket_connect("/api/v1/ws", headers=_auth()):
+                pass
+        assert closed.value.code == 1008
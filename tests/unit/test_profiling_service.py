@@ -0,0 +1,75 @@
+"""Unit tests for on-demand profiling."""
+import threading
+import time
+
+import pytest
+
+from src.services.profiling_service import Profiler, ProfilerBusyError, thread_dump
+
+
+def _spin(stop):
+    while not stop.is_set():
+        sum(range(1000))
+
+
+@pytest.fixture
+def busy_thread():
+    """A thread burning CPU in _spin until the test ends."""
+    stop = threading.Event()
+    thread = threading.Thread(target=_spin, args=(stop,), name="spinner")
+    thread.start()
+    yield thread
+    stop.set()
+    thread.join()
+
+
+class TestProfiler:
+    """Test CPU and allocation captures."""
+
+    def test_cpu_profile(self, busy_thread):
+        """Samples should be counted per folded stack, including other threads."""
+        profile = Profiler(sample_interval=0.001).cpu_profile(0.2)
+        spinning = [line for line in profile.splitlines() if line.startswith("spinner;")]
+        assert spinning
+        stack, count = spinning[0].rsplit(" ", 1)
+        assert stack.split(";")[-1].startswith("_spin (test_profiling_service.py:") and int(count) > 0
+
+    def test_allocation_profile(self):
+        """Sites should be listed by the memory they still hold."""
+        kept = []
+        stop = threading.Event()
+
+        def allocate():
+            while not stop.is_set():
+                kept.append(bytearray(10000))
+                time.sleep(0.001)
+
+        thread = threading.Thread(target=allocate)
+        thread.start()
+        try:
+            sites = Profiler().allocation_profile(0.2, limit=5)
+        finally:
+            stop.set()
+            thread.join()
+        assert "test_profiling_service.py" in sites[0]["site"]
+        assert sites[0]["size_bytes"] >= 10000 and len(sites) <= 5
+
+    def test_one_capture_at_a_time(self):
+        """A capture should be refused while another runs, and durations bounded."""
+        profiler = Profiler(max_seconds=1)
+        with pytest.raises(ValueError, match="at most 1"):
+            profiler.cpu_profile(5)
+        with pytest.raises(ValueError):
+            profiler.allocation_profile(0)
+        thread = threading.Thread(target=profiler.cpu_profile, args=(0.3,))
+        thread.start()
+        time.sleep(0.05)
+        with pytest.raises(ProfilerBusyError):
+            profiler.allocation_profile(0.1)
+        thread.join()
+        assert profiler.cpu_profile(0.01) is not None
+
+    def test_thread_dump(self, busy_thread):
+        """Every thread's stack should be listed under its name."""
+        dump = thread_dump()
+        assert "Thread spinner" in dump and "in _spin" in dump
@@ -0,0 +1,129 @@
+"""Tests for protobuf responses of the lookup routes."""
+import json
+
+import pytest
+from starlette.requests import Request
+
+from src.api.protobuf import PROTOBUF_MEDIA_TYPE, accept_qualities, wants_protobuf
+from src.services.enrichment_service import EnrichmentPipeline
+from src.services.ip_lookup_service import IpLookupService
+from src.services.mmdb_service import MMDBReader
+from tests.mmdb_writer import write_mmdb
+
+LONDON = {
+    "city": {"names": {"en": "London"}},
+    "country": {"iso_code": "GB", "names": {"en": "United Kingdom"}},
+    "location": {"latitude": 51.5142, "longitude": -0.0931, "accuracy_radius": 10},
+}
+
+PROTOBUF = {"Accept": PROTOBUF_MEDIA_TYPE}
+
+
+def _request(accept=None):
+    headers = [(b"accept", accept.encode())] if accept is not None else []
+    return Request({"type": "http", "method": "GET", "path": "/", "headers": headers})
+
+
+@pytest.fixture
+def lookup_service(tmp_path, monkeypatch):
+    """Serve lookups from a small fixture database with no enrichers."""
+    path = write_mmdb(tmp_path / "city.mmdb", [("81.2.69.128/26", LONDON)])
+    reader = MMDBReader(path)
+    service = IpLookupService(reader)
+    monkeypatch.setattr("src.api.lookup_routes.get_ip_lookup_service", lambda: service)
+    monkeypatch.setattr("src.api.lookup_routes.get_enrichment_pipeline", lambda: EnrichmentPipeline())
+    yield service
+    reader.close()
+
+
+@pytest.fixture
+def protos():
+    """Message module of geolocation.proto (skipped without the grpc extra)."""
+    pytest.importorskip("grpc")
+    pytest.importorskip("grpc_tools")
+    from src.grpc_api.messages import get_protos
+
+    return get_protos()
+
+
+class TestNegotiation:
+    """Test the Accept header negotiation."""
+
+    def test_accept_qualities(self):
+        """Should read q values per media range, defaulting to 1."""
+        qualities = accept_qualities("application/x-protobuf;q=0.9, Application/JSON; q=0.5, */*;q=oops")
+        assert qualities == {"application/x-protobuf": 0.9, "application/json": 0.5, "*/*": 1.0}
+        assert accept_qualities(None) == {}
+
+    def test_wants_protobuf(self):
+        """Should pick protobuf only when asked for explicitly and preferred over JSON."""
+        assert wants_protobuf(_request("application/x-protobuf"))
+        assert wants_protobuf(_request("application/x-protobuf, */*"))
+        assert wants_protobuf(_request("application/json;q=0.5, application/x-protobuf"))
+        assert not wants_protobuf(_request())
+        assert not wants_protobuf(_request("*/*"))
+        assert not wants_protobuf(_request("application/x-protobuf, application/json"))
+        assert not wants_protobuf(_request("application/x-protobuf;q=0"))
+
+
+class TestProtobufRoutes:
+    """Test Accept: application/x-protobuf on the lookup routes."""
+
+    def test_json_varies_on_accept(self, test_client, lookup_service):
+        """JSON answers should say they depend on Accept."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142")
+        assert response.headers["content-type"].startswith("application/json")
+        assert "Accept" in response.headers["vary"]
+
+    def test_lookup_ip(self, test_client, lookup_service, protos):
+        """Should return the IpLocation message, ignoring fields."""
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142?fields=city_name", headers=PROTOBUF)
+        assert response.status_code == 200
+        assert response.headers["content-type"] == PROTOBUF_MEDIA_TYPE
+        assert "Accept" in response.headers["vary"]
+
+        location = protos.IpLocation.FromString(response.content)
+        assert location.ip_address == "81.2.69.142"
+        assert location.country_iso_code == "GB"
+        assert json.loads(location.details_json)["city_name"] == "London"
+
+    def test_lookup_ip_etag(self, test_client, lookup_service, protos):
+        """Should tag protobuf answers apart from JSON ones and honor If-None-Match."""
+        first = test_client.get("/api/v1/lookup/ip/81.2.69.142", headers=PROTOBUF)
+        json_etag = test_client.get("/api/v1/lookup/ip/81.2.69.142").headers["ETag"]
+        assert first.headers["ETag"] != json_etag
+
+        cached = test_client.get(
+            "/api/v1/lookup/ip/81.2.69.142", headers={**PROTOBUF, "If-None-Match": first.headers["ETag"]}
+        )
+        assert cached.status_code == 304
+
+    def test_errors_stay_json(self, test_client, lookup_service, protos):
+        """Should report errors as JSON."""
+        response = test_client.get("/api/v1/lookup/ip/10.0.0.1", headers=PROTOBUF)
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_batch(self, test_client, lookup_service, protos):
+        """Should return the BatchLookupResponse message for mixed items."""
+        response = test_client.post(
+            "/api/v1/lookup/batch",
+            json={"items": [{"ip": "81.2.69.142"}, {"ip": "not-an-ip"}]},
+            headers=PROTOBUF,
+        )
+        assert response.status_code == 200
+        batch = protos.BatchLookupResponse.FromString(response.content)
+        assert (batch.total, batch.succeeded, batch.failed) == (2, 1, 1)
+        assert batch.results[0].location.country_iso_code == "GB"
+        assert batch.results[1].WhichOneof("outcome") == "error"
+        assert batch.results[1].error.error_code == "E002"
+
+    def test_without_grpc_is_503(self, test_client, lookup_service, monkeypatch):
+        """Should answer 503 when the message classes cannot be compiled."""
+        def missing():
+            raise RuntimeError("grpcio and grpcio-tools are required for protobuf messages")
+
+        monkeypatch.setattr("src.api.protobuf.get_protos", missing)
+        response = test_client.get("/api/v1/lookup/ip/81.2.69.142", headers=PROTOBUF)
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
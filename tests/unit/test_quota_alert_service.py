@@ -0,0 +1,101 @@
+"""Unit tests for quota threshold alerts to tenants."""
+import asyncio
+
+import pytest
+
+from src.services import quota_alert_service, webhook_service
+from src.services.auth_service import TokenVerifier
+from src.services.quota_alert_service import QUOTA_ALERT_EVENT, QuotaAlertService, alert_email, notify
+from src.services.quota_service import QuotaAlert
+from src.services.webhook_service import WebhookDispatcher, WebhookService
+
+AVAILABLE = (80, 95, 100)
+ALERT = QuotaAlert("k-1", "free", "monthly", 95, 9500, 10000, "2026-03")
+
+
+class FakeMailer:
+    def __init__(self):
+        self.sent = []
+
+    def send(self, recipients, subject, body):
+        self.sent.append((list(recipients), subject, body))
+
+
+class TestQuotaAlertPreferences:
+    """Test storing tenants' alert preferences."""
+
+    def test_defaults_and_replace(self, db_session):
+        """Tenants without preferences should get every threshold by webhook."""
+        service = QuotaAlertService(db_session)
+        defaults = service.preferences("acme", AVAILABLE)
+        assert (defaults.thresholds, defaults.webhook, defaults.emails) == ([80, 95, 100], True, [])
+
+        service.set_preferences("acme", [100, 80], False, ["Ops@Example.com "], AVAILABLE)
+        stored = service.preferences("acme", AVAILABLE)
+        assert (stored.thresholds, stored.webhook, stored.emails) == ([80, 100], False, ["ops@example.com"])
+        # Thresholds dropped from QUOTA_ALERT_THRESHOLDS are no longer applied
+        assert service.preferences("acme", (95, 100)).thresholds == [100]
+        assert service.delete_preferences("acme") and not service.delete_preferences("acme")
+
+    def test_invalid_preferences(self, db_session):
+        """Unknown thresholds and malformed addresses should be refused."""
+        service = QuotaAlertService(db_session)
+        with pytest.raises(ValueError, match="Unknown quota alert thresholds"):
+            service.set_preferences("acme", [50], True, [], AVAILABLE)
+        with pytest.raises(ValueError, match="Invalid email addresses"):
+            service.set_preferences("acme", [80], True, ["ops"], AVAILABLE)
+
+
+class TestNotify:
+    """Test alerting tenants of crossed thresholds."""
+
+    @pytest.mark.asyncio
+    async def test_webhook_and_email(self, db_session, monkeypatch):
+        """Alerts should reach subscribed webhooks and named addresses, as preferences ask."""
+        deliveries = []
+
+        async def sender(url, body, headers, timeout_seconds):
+            deliveries.append((url, headers["X-Webhook-Event"]))
+            return 200
+
+        mailer = FakeMailer()
+        monkeypatch.setattr(webhook_service, "_webhook_dispatcher", WebhookDispatcher(sender=sender))
+        monkeypatch.setattr(quota_alert_service, "_quota_mailer", mailer)
+        WebhookService(db_session).create_webhook("acme", "https://hooks.example.com/quota", ["quota.*"])
+        WebhookService(db_session).create_webhook("acme", "https://hooks.example.com/geo", ["geofence.*"])
+        QuotaAlertService(db_session).set_preferences("acme", [95, 100], True, ["ops@example.com"], AVAILABLE)
+
+        notify(db_session, "acme", ALERT, AVAILABLE)
+        notify(db_session, "acme", QuotaAlert("k-1", "free", "monthly", 80, 8000, 10000, "2026-03"), AVAILABLE)
+        await asyncio.sleep(0.05)
+        assert deliveries == [("https://hooks.example.com/quota", QUOTA_ALERT_EVENT)]
+        assert [(recipients, subject) for recipients, subject, _ in mailer.sent] == [
+            (["ops@example.com"], "Quota alert: API key k-1 has used 95% of its monthly requests"),
+        ]
+
+    def test_alert_email(self):
+        """Emails should name the key, its usage and what happens at the cap."""
+        subject, body = alert_email("acme", QuotaAlert("k-1", "free", "POST /api/v1/jobs", 100, 10, 10, "2026-03"))
+        assert subject == "Quota alert: API key k-1 has used 100% of its POST /api/v1/jobs requests"
+        assert "has made 10 of the 10 POST /api/v1/jobs requests" in body and "refused with 429" in body
+
+
+class TestQuotaAlertRoutes:
+    """Test the tenant's preference endpoints."""
+
+    def test_get_put_delete(self, db_client, monkeypatch):
+        """Tenants should read and replace their own preferences."""
+        verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+        monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+        headers = {"Authorization": f"Bearer {verifier.issue('apikey:k-1', 'acme')}"}
+
+        defaults = db_client.get("/api/v1/usage/quota-alerts", headers=headers).json()
+        assert (defaults["thresholds"], defaults["available_thresholds"]) == ([80, 95, 100], [80, 95, 100])
+        assert defaults["updated_at"] is None
+        body = {"thresholds": [100], "webhook": False, "emails": ["ops@example.com"]}
+        stored = db_client.put("/api/v1/usage/quota-alerts", json=body, headers=headers).json()
+        assert (stored["thresholds"], stored["webhook"], stored["emails"]) == ([100], False, ["ops@example.com"])
+        body["thresholds"] = [90]
+        assert db_client.put("/api/v1/usage/quota-alerts", json=body, headers=headers).status_code == 400
+        assert db_client.delete("/api/v1/usage/quota-alerts", headers=headers).status_code == 204
+        assert db_client.delete("/api/v1/usage/quota-alerts", headers=headers).status_code == 404
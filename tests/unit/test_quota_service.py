@@ -0,0 +1,160 @@
+"""Unit tests for plan-based quotas of API keys."""
+from datetime import datetime
+
+import pytest
+
+from src.services.auth_service import ADMIN_SCOPE, TokenVerifier
+from src.services.quota_service import (
+    QuotaEnforcer,
+    QuotaPlan,
+    month_end,
+    parse_plans,
+    parse_thresholds,
+    validate_plan,
+)
+
+JOBS = "POST /api/v1/jobs"
+MARCH = datetime(2026, 3, 31, 12, 0)
+
+
+class TestQuotaPlans:
+    """Test the built-in plans and QUOTA_PLANS."""
+
+    def test_overrides_and_additions(self):
+        """Entries should change the named plan's caps or add a plan."""
+        plans = parse_plans(
+            '{"free": {"monthly_requests": 5000}, "batch": {"endpoints": {"POST /api/v1/jobs": 50}, "warn_ratio": 0.5}}',
+            warn_ratio=0.9,
+        )
+        assert set(plans) == {"free", "standard", "enterprise", "batch"}
+        assert plans["free"].monthly_requests == 5000
+        assert plans["free"].endpoints[JOBS] == 10  # kept from the built-in plan
+        assert plans["free"].warn_ratio == 0.9
+        assert plans["batch"].monthly_requests is None
+        assert plans["batch"].endpoints == {JOBS: 50} and plans["batch"].warn_ratio == 0.5
+        assert parse_plans("") == parse_plans("{}")
+
+    @pytest.mark.parametrize("raw", [
+        "[]",
+        "{not json",
+        '{"free": {"monthly_requests": 0}}',
+        '{"free": {"endpoints": {"POST /api/v1/jobs": "ten"}}}',
+        '{"free": {"warn_ratio": 1.5}}',
+    ])
+    def test_invalid_plans(self, raw):
+        """Malformed documents, caps and ratios should be refused."""
+        with pytest.raises(ValueError):
+            parse_plans(raw)
+
+    def test_validate_plan(self):
+        """Only configured plan names should be accepted."""
+        assert validate_plan("standard") == "standard"
+        assert validate_plan(None) is None
+        with pytest.raises(ValueError, match="Unknown quota plan 'platinum'"):
+            validate_plan("platinum")
+
+
+class TestQuotaEnforcer:
+    """Test counting requests against plans."""
+
+    @pytest.fixture
+    def enforcer(self):
+        return QuotaEnforcer({"tiny": QuotaPlan("tiny", monthly_requests=10, endpoints={JOBS: 4}, warn_ratio=0.5)})
+
+    def test_warn_then_block(self, enforcer):
+        """Requests should warn from the warn share of a cap and be refused past it."""
+        first = enforcer.count("k-1", "tiny", JOBS, at=MARCH)
+        assert first.allowed and "X-Quota-Warning" not in first.headers()
+        assert first.headers() == {
+            "X-Quota-Plan": "tiny",
+            "X-Quota-Reset": "43200",
+            "X-Quota-Limit": "10",
+            "X-Quota-Remaining": "9",
+        }
+        second = enforcer.count("k-1", "tiny", JOBS, at=MARCH)
+        assert second.allowed and second.headers()["X-Quota-Warning"] == "POST /api/v1/jobs 2/4"
+        jobs = [enforcer.count("k-1", "tiny", JOBS, at=MARCH) for _ in range(3)]
+        assert [d.allowed for d in jobs] == [True, True, False]
+        assert jobs[2].blocking.scope == JOBS
+
+        # Other endpoints only count against the monthly cap (refused requests included)
+        others = [enforcer.count("k-1", "tiny", "GET /api/v1/pois", at=MARCH) for _ in range(6)]
+        assert [d.allowed for d in others] == [True] * 5 + [False]
+        assert others[0].headers()["X-Quota-Warning"] == "monthly 6/10"
+        assert others[5].headers()["X-Quota-Remaining"] == "0"
+        assert enforcer.count("k-2", "tiny", JOBS, at=MARCH).allowed
+
+    def test_new_month_resets(self, enforcer):
+        """Counts should start over when the month changes."""
+        for _ in range(5):
+            enforcer.count("k-1", "tiny", JOBS, at=MARCH)
+        assert enforcer.count("k-1", "tiny", JOBS, at=datetime(2026, 4, 1)).allowed
+        assert month_end(datetime(2026, 12, 15)) == datetime(2027, 1, 1)
+
+    def test_alert_thresholds(self):
+        """Each threshold should be raised once, by the request reaching it."""
+        enforcer = QuotaEnforcer({"tiny": QuotaPlan("tiny", monthly_requests=20, endpoints={JOBS: 2})})
+        crossed = []
+        for _ in range(21):
+            decision = enforcer.count("k-1", "tiny", "GET /api/v1/pois", at=MARCH)
+            crossed += [(a.scope, a.threshold, a.used) for a in decision.alerts]
+        assert crossed == [("monthly", 80, 16), ("monthly", 95, 19), ("monthly", 100, 20)]
+        # 80% and 95% of 2 fall on the second request, which speaks for the highest
+        alerts = [enforcer.count("k-2", "tiny", JOBS, at=MARCH).alerts for _ in range(2)]
+        assert alerts[0] == [] and [(a.scope, a.threshold) for a in alerts[1]] == [(JOBS, 100)]
+        assert alerts[1][0].to_dict()["month"] == "2026-03"
+
+        assert parse_thresholds(" 100,80 ,95,80") == (80, 95, 100)
+        with pytest.raises(ValueError):
+            parse_thresholds("80,110")
+
+    def test_unknown_and_unlimited_plans(self):
+        """Unknown plans should not be metered, unlimited ones never refused."""
+        enforcer = QuotaEnforcer()
+        assert enforcer.count("k-1", "platinum", JOBS) is None
+        decision = enforcer.count("k-1", "enterprise", JOBS)
+        assert decision.allowed and decision.usages == []
+        assert decision.headers()["X-Quota-Plan"] == "enterprise"
+        assert not enforcer.needs_endpoint("enterprise") and enforcer.needs_endpoint("free")
+
+
+class TestQuotaMiddleware:
+    """Test enforcing quotas on HTTP requests."""
+
+    def test_plan_key_is_refused_past_its_quota(self, db_client, quota_enforcer, monkeypatch):
+        """Tokens of a key on a plan should be counted and refused past it; other tokens never."""
+        verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+        for target in ("src.api.dependencies", "src.api.auth_routes", "src.middleware.quota"):
+            monkeypatch.setattr(f"{target}.get_token_verifier", lambda: verifier)
+        quota_enforcer.plans["free"] = QuotaPlan("free", monthly_requests=2)
+        admin = {"Authorization": f"Bearer {verifier.issue('ops-1', 'platform', scopes=[ADMIN_SCOPE])}"}
+
+        tenant = db_client.post(
+            "/admin/v1/tenants", json={"tenant_id": "acme", "name": "Acme", "quota_plan": "free"}, headers=admin
+        ).json()
+        assert tenant["api_keys"][0]["quota_plan"] == "free"
+        token = db_client.post("/api/v1/auth/token", json={"api_key": tenant["api_key"]}).json()["access_token"]
+        assert verifier.verify(token).quota_plan == "free"
+        key = {"Authorization": f"Bearer {token}"}
+
+        first = db_client.get("/api/v1/usage", headers=key)
+        assert first.status_code == 200
+        assert (first.headers["X-Quota-Plan"], first.headers["X-Quota-Remaining"]) == ("free", "1")
+        assert first.headers["X-Quota-Warning"] == "monthly 1/2"
+        assert db_client.get("/api/v1/usage", headers=key).headers["X-Quota-Remaining"] == "0"
+        refused = db_client.get("/api/v1/usage", headers=key)
+        assert refused.status_code == 429
+        assert refused.json()["detail"]["error_code"] == "E015"
+        assert int(refused.headers["Retry-After"]) > 0
+        assert (refused.headers["RateLimit-Limit"], refused.headers["RateLimit-Remaining"]) == ("2", "0")
+        assert refused.headers["RateLimit-Reset"] == refused.headers["Retry-After"]
+        assert "X-Quota-Plan" not in db_client.get("/admin/v1/tenants", headers=admin).headers
+
+        key_id = tenant["api_keys"][0]["key_id"]
+        response = db_client.put(f"/admin/v1/tenants/acme/keys/{key_id}/plan", json={"plan": "platinum"}, headers=admin)
+        assert response.status_code == 400
+        response = db_client.put(f"/admin/v1/tenants/acme/keys/{key_id}/plan", json={"plan": None}, headers=admin)
+        assert response.json()["quota_plan"] is None
+        assert [p["name"] for p in db_client.get("/admin/v1/quota-plans", headers=admin).json()["plans"]] == [
+            "enterprise", "free", "standard",
+        ]
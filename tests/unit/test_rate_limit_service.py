@@ -0,0 +1,159 @@
+"""Unit tests for rate limiting."""
+import pytest
+
+from src.middleware.rate_limit import caller_key
+from src.services.auth_service import TokenVerifier
+from src.services.rate_limit_service import (
+    InMemoryRateLimitStore,
+    InMemorySlidingWindowStore,
+    RedisSlidingWindowStore,
+    SlidingWindowLimiter,
+    SpikeArrestLimiter,
+    TokenBucketLimiter,
+    rate_limit_headers,
+)
+
+SECRET = "test-secret-0123456789abcdef0123456789"
+
+
+@pytest.fixture
+def clock():
+    """Settable monotonic clock."""
+    return [1000.0]
+
+
+class TestTokenBucketLimiter:
+    """Test taking tokens from buckets."""
+
+    def test_burst_then_refill(self, clock):
+        """A caller should burst up to the capacity, then get the refill rate."""
+        limiter = TokenBucketLimiter(InMemoryRateLimitStore(clock=lambda: clock[0]), capacity=3, refill_rate=0.5)
+        decisions = [limiter.check("token:acme:apikey:k-1") for _ in range(4)]
+        assert [d.allowed for d in decisions] == [True, True, True, False]
+        assert [d.remaining for d in decisions] == [2, 1, 0, 0]
+        assert decisions[3].retry_after == 2
+        assert decisions[3].headers() == {
+            "RateLimit-Limit": "3",
+            "RateLimit-Remaining": "0",
+            "RateLimit-Reset": "6",
+            "Retry-After": "2",
+        }
+        assert limiter.check("token:acme:apikey:k-2").allowed
+        assert rate_limit_headers(10, -1, 0, 0) == {
+            "RateLimit-Limit": "10",
+            "RateLimit-Remaining": "0",
+            "RateLimit-Reset": "0",
+            "Retry-After": "1",
+        }
+
+        clock[0] += 2
+        assert limiter.check("token:acme:apikey:k-1").allowed
+        assert not limiter.check("token:acme:apikey:k-1").allowed
+        clock[0] += 60
+        assert limiter.check("token:acme:apikey:k-1").remaining == 2  # never above the capacity
+        assert limiter.policy == "3;w=6"
+
+    def test_invalid_policy(self):
+        """Capacities and rates should be positive."""
+        with pytest.raises(ValueError, match="positive"):
+            TokenBucketLimiter(capacity=0)
+        with pytest.raises(ValueError, match="positive"):
+            TokenBucketLimiter(refill_rate=0)
+
+    def test_caller_key(self):
+        """Valid tokens should name their caller; others fall back to the client address."""
+        verifier = TokenVerifier(SECRET)
+        scope = {"type": "http", "client": ("203.0.113.7", 5000), "query_string": b""}
+        token = verifier.issue("apikey:k-1", "acme")
+        forged = TokenVerifier("another-secret-0123456789abcdef01234").issue("apikey:k-1", "acme")
+
+        assert caller_key({**scope, "headers": [(b"authorization", f"Bearer {token}".encode())]}, verifier) == (
+            "token:acme:apikey:k-1"
+        )
+        assert caller_key({**scope, "headers": [(b"authorization", f"Bearer {forged}".encode())]}, verifier) == (
+            "ip:203.0.113.7"
+        )
+        assert caller_key({**scope, "headers": []}, verifier) == "ip:203.0.113.7"
+
+
+class TestSlidingWindowLimiter:
+    """Test limiting requests per sliding window."""
+
+    def test_window_slides(self, clock):
+        """Requests should free their slot once they are a window old, refused ones never take one."""
+        limiter = SlidingWindowLimiter(InMemorySlidingWindowStore(clock=lambda: clock[0]), limit=2, window_seconds=10)
+        assert limiter.check("ip:203.0.113.7").remaining == 1
+        clock[0] += 4
+        assert limiter.check("ip:203.0.113.7").remaining == 0
+        refused = limiter.check("ip:203.0.113.7")
+        assert not refused.allowed and refused.retry_after == 6
+
+        clock[0] += 6
+        assert limiter.check("ip:203.0.113.7").allowed  # the first request left the window
+        assert not limiter.check("ip:203.0.113.7").allowed
+        clock[0] += 4
+        decision = limiter.check("ip:203.0.113.7")
+        assert decision.allowed and decision.reset_seconds == 6
+        assert limiter.policy == "2;w=10"
+
+    def test_redis_script_arguments(self):
+        """The Redis store should pass the limit and window in milliseconds to its script."""
+        calls = []
+
+        class FakeRedis:
+            def register_script(self, script):
+                assert "ZREMRANGEBYSCORE" in script and "TIME" in script
+                return lambda keys, args: calls.append((keys, args)) or [1, 3, 2500]
+
+        store = RedisSlidingWindowStore(FakeRedis(), key_prefix="rl")
+        assert store.add("token:acme:apikey:k-1", 5, 10) == (True, 3, 2.5)
+        keys, args = calls[0]
+        assert keys == ["rl:window:token:acme:apikey:k-1"] and args[:2] == [5, 10000]
+
+    def test_invalid_policy(self):
+        """Limits and windows should be positive."""
+        with pytest.raises(ValueError, match="positive"):
+            SlidingWindowLimiter(window_seconds=0)
+
+
+class TestSpikeArrestLimiter:
+    """Test the spike window on top of a sustained limit."""
+
+    def test_spikes_are_arrested(self, clock):
+        """Requests beyond the spike limit should be refused without spending the sustained allowance."""
+        sustained = TokenBucketLimiter(InMemoryRateLimitStore(clock=lambda: clock[0]), capacity=5, refill_rate=0.1)
+        limiter = SpikeArrestLimiter(
+            sustained, InMemorySlidingWindowStore(clock=lambda: clock[0]), limit=2, window_seconds=1
+        )
+        assert limiter.policy == "5;w=50, 2;w=1"
+        decisions = [limiter.check("token:acme:apikey:k-1") for _ in range(3)]
+        assert [d.allowed for d in decisions] == [True, True, False]
+        # The limit closer to being used up speaks for allowed requests
+        assert [(d.policy, d.remaining) for d in decisions[:2]] == [("2;w=1", 1), ("2;w=1", 0)]
+        assert (decisions[2].policy, decisions[2].retry_after) == ("2;w=1", 1)
+
+        clock[0] += 1
+        assert [limiter.check("token:acme:apikey:k-1").allowed for _ in range(3)] == [True, True, False]
+        clock[0] += 1
+        last = [limiter.check("token:acme:apikey:k-1") for _ in range(2)]
+        assert last[0].allowed and (last[0].policy, last[0].remaining) == ("5;w=50", 0)
+        assert not last[1].allowed and last[1].policy == "5;w=50"
+
+
+class TestRateLimitMiddleware:
+    """Test limiting HTTP requests."""
+
+    def test_limits_and_headers(self, test_client, rate_limiter):
+        """Requests beyond the burst should get 429, exempt paths never."""
+        rate_limiter.capacity, rate_limiter.refill_rate = 2, 0.01
+
+        first = test_client.get("/api/v1/usage")
+        assert first.status_code == 401
+        assert first.headers["RateLimit-Limit"] == "2" and first.headers["RateLimit-Remaining"] == "1"
+        assert first.headers["RateLimit-Policy"] == "2;w=200"
+        test_client.get("/api/v1/usage")
+        refused = test_client.get("/api/v1/usage")
+        assert refused.status_code == 429
+        assert refused.json()["detail"]["error_code"] == "E014"
+        assert int(refused.headers["Retry-After"]) > 0
+        assert test_client.get("/api/v1/health").status_code == 200
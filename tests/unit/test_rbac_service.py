@@ -0,0 +1,123 @@
+"""Unit tests for the hot-reloaded RBAC policy."""
+import json
+import os
+
+import pytest
+
+from src.services.auth_service import scope_granted, validate_scopes
+from src.services.rbac_service import PolicyStore, compile_path, parse_policy
+
+LOOKUPS = {"id": "lookups", "methods": ["GET", "POST"], "path": "/api/v1/lookup/**", "scopes": ["lookup:read"]}
+GEOFENCE_READS = {"id": "geofence-reads", "methods": ["GET"], "path": "/api/v1/geofences/**", "scopes": ["geofence:read"]}
+GEOFENCE_WRITES = {"id": "geofence-writes", "path": "/api/v1/geofences/**", "scopes": ["geofence:write"]}
+
+
+class FakeClock:
+    """Monotonic clock advanced by hand."""
+
+    def __init__(self):
+        self.now = 0.0
+
+    def __call__(self):
+        return self.now
+
+
+def _write(path, document, mtime):
+    path.write_text(json.dumps(document))
+    os.utime(path, (mtime, mtime))
+
+
+class TestScopes:
+    """Test scope syntax and wildcard grants."""
+
+    def test_granted(self):
+        """Wildcards and bare resources should cover every action of the resource."""
+        assert scope_granted(["lookup:read"], "lookup:read")
+        assert scope_granted(["geofence:*"], "geofence:write")
+        assert scope_granted(["admin"], "admin:write")
+        assert scope_granted(["*"], "geofence:write")
+        assert not scope_granted(["geofence:read"], "geofence:write")
+        assert not scope_granted(["lookup:*"], "geofence:read")
+
+    def test_validate(self):
+        """Malformed scopes should be rejected, duplicates dropped."""
+        assert validate_scopes(["lookup:read", "admin:*", "lookup:read"]) == ("admin:*", "lookup:read")
+        with pytest.raises(ValueError, match="Invalid scope"):
+            validate_scopes(["Lookup Read"])
+
+
+class TestPolicy:
+    """Test rule matching."""
+
+    def test_paths(self):
+        """* should match one segment and /** any number, including none."""
+        assert compile_path("/api/v1/geofences/**").match("/api/v1/geofences")
+        assert compile_path("/api/v1/geofences/**").match("/api/v1/geofences/g1/alerts")
+        assert compile_path("/api/v1/geofences/*").match("/api/v1/geofences/g1")
+        assert not compile_path("/api/v1/geofences/*").match("/api/v1/geofences/g1/alerts")
+        assert not compile_path("/api/v1/geofences/**").match("/api/v1/geofencesx")
+
+    def test_first_match_wins(self):
+        """Rules should be checked in order, methods included."""
+        policy = parse_policy({"rules": [LOOKUPS, GEOFENCE_READS, GEOFENCE_WRITES]})
+        assert policy.required_scopes("GET", "/api/v1/geofences/g1")[1] == ("geofence:read",)
+        rule, scopes = policy.required_scopes("DELETE", "/api/v1/geofences/g1")
+        assert (rule.rule_id, scopes) == ("geofence-writes", ("geofence:write",))
+        assert policy.required_scopes("GET", "/api/v1/tiles/1/2/3.mvt") == (None, None)
+
+    def test_default_scopes(self):
+        """Unmatched requests should need the default scopes when set."""
+        policy = parse_policy({"rules": [LOOKUPS], "default_scopes": ["lookup:read"]})
+        assert policy.required_scopes("GET", "/api/v1/cells")[1] == ("lookup:read",)
+
+    @pytest.mark.parametrize(
+        "rule, message",
+        [
+            ({"path": "api/v1", "scopes": []}, "must start with /"),
+            ({"path": "/api/v1", "scopes": ["Bad Scope"]}, "Invalid scope"),
+            ({"path": "/api/v1", "scopes": [], "methods": ["FETCH"]}, "methods"),
+            ({"scopes": []}, "path is required"),
+        ],
+    )
+    def test_invalid_rules(self, rule, message):
+        """Invalid rules should reject the whole policy."""
+        with pytest.raises(ValueError, match=message):
+            parse_policy({"rules": [rule]})
+
+
+class TestPolicyStore:
+    """Test loading and hot-reloading the policy file."""
+
+    def test_no_path(self):
+        """Without RBAC_POLICY_PATH there should be no policy."""
+        store = PolicyStore()
+        assert store.policy is None
+        assert store.reload() is False
+
+    def test_reload_on_change(self, tmp_path):
+        """A changed file should be picked up after the check interval."""
+        clock = FakeClock()
+        path = tmp_path / "rbac.json"
+        _write(path, {"rules": [LOOKUPS]}, 1000)
+        store = PolicyStore(str(path), 5, clock=clock)
+        assert [rule.rule_id for rule in store.policy.rules] == ["lookups"]
+
+        _write(path, {"rules": [LOOKUPS, GEOFENCE_WRITES]}, 2000)
+        assert len(store.policy.rules) == 1
+        clock.now += 6
+        assert [rule.rule_id for rule in store.policy.rules] == ["lookups", "geofence-writes"]
+
+    def test_invalid_change_keeps_policy(self, tmp_path):
+        """A rejected file should leave the previous policy active."""
+        clock = FakeClock()
+        path = tmp_path / "rbac.json"
+        _write(path, {"rules": [LOOKUPS]}, 1000)
+        store = PolicyStore(str(path), 5, clock=clock)
+        version = store.policy and store.version
+
+        path.write_text("{not json")
+        os.utime(path, (2000, 2000))
+        clock.now += 6
+        assert [rule.rule_id for rule in store.policy.rules] == ["lookups"]
+        assert store.version == version
+        assert store.last_error is not None
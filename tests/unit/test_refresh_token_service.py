@@ -0,0 +1,106 @@
+"""Unit tests for refresh token rotation."""
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.models.database_models import RefreshToken
+from src.services.auth_service import AuthenticationError, TokenVerifier
+from src.services.refresh_token_service import REFRESH_TOKEN_PREFIX, RefreshTokenService
+from src.services.tenant_service import TenantService
+from src.services.token_denylist_service import InMemoryTokenDenylist
+
+
+@pytest.fixture
+def verifier():
+    """Verifier with a test secret and an in-memory denylist."""
+    return TokenVerifier("test-secret-0123456789abcdef0123456789", denylist=InMemoryTokenDenylist())
+
+
+@pytest.fixture
+def service(db_session, verifier):
+    """Refresh token service over the test database."""
+    return RefreshTokenService(
+        db_session, verifier, access_ttl=timedelta(minutes=15), refresh_ttl=timedelta(days=1)
+    )
+
+
+class TestRefreshTokens:
+    """Test sessions, rotation and reuse detection."""
+
+    def test_start_session(self, service, verifier, db_session):
+        """A session should start with an access token carrying its sid."""
+        pair = service.start_session("user-1", "acme", scopes=["poi:read"])
+        assert pair.refresh_token.startswith(REFRESH_TOKEN_PREFIX)
+        assert (pair.expires_in, pair.refresh_expires_in) == (900, 86400)
+
+        principal = verifier.verify(pair.access_token)
+        assert (principal.tenant_id, principal.session_id) == ("acme", pair.session_id)
+        assert principal.scopes == {"poi:read"}
+        assert db_session.query(RefreshToken).one().token_hash != pair.refresh_token
+
+    def test_refresh_rotates(self, service, verifier):
+        """Refreshing should issue a new pair in the same session."""
+        first = service.start_session("user-1", "acme", scopes=["poi:read"])
+        second = service.refresh(first.refresh_token)
+        assert second.refresh_token != first.refresh_token
+        assert second.session_id == first.session_id
+        assert verifier.verify(second.access_token).scopes == {"poi:read"}
+        assert service.refresh(second.refresh_token).session_id == first.session_id
+
+    def test_reuse_revokes_session(self, service, verifier):
+        """Presenting a spent refresh token should end the whole session."""
+        first = service.start_session("user-1", "acme")
+        second = service.refresh(first.refresh_token)
+
+        with pytest.raises(AuthenticationError, match="already used"):
+            service.refresh(first.refresh_token)
+        with pytest.raises(AuthenticationError, match="Invalid refresh token"):
+            service.refresh(second.refresh_token)
+        with pytest.raises(AuthenticationError, match="revoked"):
+            verifier.verify(second.access_token)
+
+    def test_other_sessions_unaffected(self, service, verifier):
+        """Revoking one session should leave the subject's others alone."""
+        first = service.start_session("user-1", "acme")
+        other = service.start_session("user-1", "acme")
+        assert service.revoke(first.refresh_token) is True
+        assert verifier.verify(other.access_token).subject == "user-1"
+        assert service.refresh(other.refresh_token).session_id == other.session_id
+
+    def test_expired_rejected(self, service, db_session):
+        """Refresh tokens past their lifetime should be refused."""
+        pair = service.start_session("user-1", "acme")
+        stored = db_session.query(RefreshToken).one()
+        stored.expires_at = datetime.utcnow() - timedelta(seconds=1)
+        db_session.commit()
+        with pytest.raises(AuthenticationError, match="expired"):
+            service.refresh(pair.refresh_token)
+
+    def test_unknown_rejected(self, service):
+        """Unknown tokens should be refused and not revocable."""
+        with pytest.raises(AuthenticationError, match="Invalid refresh token"):
+            service.refresh("ger_unknown")
+        assert service.revoke("not-a-refresh-token") is False
+
+    def test_api_key_revocation_ends_session(self, service, db_session):
+        """Sessions should end once their API key stops working."""
+        tenants = TenantService(db_session)
+        _, issued = tenants.create_tenant("acme", "Acme")
+        key = issued.key
+        pair = service.start_session(f"apikey:{key.key_id}", "acme", api_key_id=key.key_id)
+        tenants.rotate_key("acme", grace_seconds=0)
+
+        with pytest.raises(AuthenticationError, match="API key"):
+            service.refresh(pair.refresh_token)
+        assert db_session.query(RefreshToken).filter(RefreshToken.revoked_at.is_(None)).count() == 0
+
+    def test_revoke_api_key(self, service, verifier):
+        """Revoking by API key should end every session started from it."""
+        first = service.start_session("apikey:k1", "acme", api_key_id="k1")
+        second = service.start_session("apikey:k1", "acme", api_key_id="k1")
+        other = service.start_session("apikey:k2", "acme", api_key_id="k2")
+        assert service.revoke_api_key("k1") == 2
+        for pair in (first, second):
+            with pytest.raises(AuthenticationError, match="revoked"):
+                verifier.verify(pair.access_token)
+        assert verifier.verify(other.access_token).subject == "apikey:k2"
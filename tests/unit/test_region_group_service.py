@@ -0,0 +1,112 @@
+"""Unit tests for named country groups."""
+import json
+from types import SimpleNamespace
+
+import pytest
+
+from src.services import region_group_service
+from src.services.country_info_service import CountryInfo
+from src.services.ip_lookup_service import IpLookupResult, normalize_ip
+from src.services.mmdb_service import GeoIPRecord
+from src.services.region_group_service import (
+    RegionGroupEnricher,
+    RegionGroups,
+    continent_groups,
+    get_region_groups,
+    load_region_groups,
+)
+
+COUNTRIES = {
+    "DE": CountryInfo(iso_code="DE", continent="EU"),
+    "FR": CountryInfo(iso_code="FR", continent="EU"),
+    "BR": CountryInfo(iso_code="BR", continent="SA"),
+    "MX": CountryInfo(iso_code="MX", continent="NA"),
+    "CU": CountryInfo(iso_code="CU", continent="NA"),
+}
+
+CUSTOM = {
+    "EU": ["DE", "FR"],
+    "LATAM": ["south-america", "mx", "CU"],
+    "embargoed": ["CU", "IR"],
+}
+
+
+@pytest.fixture
+def groups():
+    """Continent groups plus a few custom groups."""
+    return RegionGroups({**continent_groups(COUNTRIES), **CUSTOM})
+
+
+def _result(country):
+    record = GeoIPRecord(ip_address="81.2.69.142", network="81.2.69.128/26", country_iso_code=country)
+    return IpLookupResult(normalized=normalize_ip("81.2.69.142"), record=record)
+
+
+class TestRegionGroups:
+    """Test group definition and membership."""
+
+    def test_continents(self):
+        """Countries should be grouped by their GeoNames continent."""
+        continents = continent_groups(COUNTRIES)
+        assert sorted(continents["europe"]) == ["DE", "FR"]
+        assert sorted(continents["north-america"]) == ["CU", "MX"]
+
+    def test_group_references_expand(self, groups):
+        """A member naming another group should contribute its countries."""
+        assert groups.members("LATAM") == ["BR", "CU", "MX"]
+        assert groups.members("missing") is None
+
+    def test_groups_for(self, groups):
+        """Should list every group containing a country, sorted."""
+        assert groups.groups_for("cu") == ["LATAM", "embargoed", "north-america"]
+        assert groups.groups_for("JP") == []
+        assert groups.groups_for(None) == []
+
+    def test_unknown_member_rejected(self):
+        """Members that are neither groups nor country codes should be rejected."""
+        with pytest.raises(ValueError):
+            RegionGroups({"EMEA": ["europe", "middle-east"]})
+
+    def test_cycle_rejected(self):
+        """Groups referencing each other in a cycle should be rejected."""
+        with pytest.raises(ValueError, match="cycle"):
+            RegionGroups({"a": ["b"], "b": ["a"]})
+
+
+class TestLoadRegionGroups:
+    """Test reading custom groups from JSON."""
+
+    def test_load(self, tmp_path):
+        """Should read an object of group name -> members."""
+        path = tmp_path / "groups.json"
+        path.write_text(json.dumps(CUSTOM))
+        assert load_region_groups(str(path)) == CUSTOM
+
+    def test_malformed(self, tmp_path):
+        """Members must be lists of strings."""
+        path = tmp_path / "groups.json"
+        path.write_text(json.dumps({"EU": "DE,FR"}))
+        with pytest.raises(ValueError):
+            load_region_groups(str(path))
+
+    def test_invalid_file_keeps_continents(self, tmp_path, monkeypatch):
+        """An invalid custom file should leave the continent groups in place."""
+        path = tmp_path / "groups.json"
+        path.write_text(json.dumps({"EMEA": ["middle-east"]}))
+        monkeypatch.setattr(region_group_service, "_region_groups", None)
+        monkeypatch.setattr(region_group_service, "get_country_info", lambda config: COUNTRIES)
+        groups = get_region_groups(SimpleNamespace(region_groups_path=str(path)))
+        assert groups.members("EMEA") is None
+        assert groups.members("europe") == ["DE", "FR"]
+
+
+class TestRegionGroupEnricher:
+    """Test the groups enrichment block."""
+
+    def test_block(self, groups):
+        """The record's country should map to its group names."""
+        assert RegionGroupEnricher(groups).enrich(_result("DE")) == ["EU", "europe"]
+
+    def test_no_country(self, groups):
+        """Records without a country should give no block."""
+        assert RegionGroupEnricher(groups).enrich(_result(None)) is None
@@ -0,0 +1,142 @@
+"""Unit tests for HMAC request signing."""
+import pytest
+
+from src.services.auth_service import AuthenticationError
+from src.services.request_signing_service import (
+    BodyDigest,
+    InMemoryNonceStore,
+    RequestSigningService,
+    body_sha256,
+    parse_signature,
+    sign_request,
+)
+from src.services.tenant_service import TenantService
+
+NOW = 1_700_000_000
+BODY = b'{"kind": "country", "code": "IR"}'
+
+
+class FakeClock:
+    """Manually advanced wall clock."""
+
+    def __init__(self):
+        self.now = float(NOW)
+
+    def __call__(self):
+        return self.now
+
+
+@pytest.fixture
+def service(db_session):
+    """Signing service over the test database, with tenant acme."""
+    TenantService(db_session).create_tenant("acme", "Acme")
+    return RequestSigningService(db_session, InMemoryNonceStore(), tolerance_seconds=300, clock=FakeClock())
+
+
+def _verify(service, header, body=BODY, required=True, path="/api/v1/risk/areas"):
+    return service.verify("acme", header, "POST", path, "format=json", body_sha256(body), required=required)
+
+
+def _sign(key, body=BODY, timestamp=NOW, nonce=None):
+    return sign_request(
+        key.key_id, key.secret, "POST", "/api/v1/risk/areas", "format=json", body, timestamp=timestamp, nonce=nonce
+    )
+
+
+class TestVerify:
+    """Test signature validation and replay protection."""
+
+    def test_valid_signature(self, service):
+        """A correctly signed request should be accepted once."""
+        key = service.create_key("acme")
+        assert _verify(service, _sign(key)).key_id == key.key_id
+
+    def test_unsigned(self, service):
+        """Unsigned requests should only pass when the token does not require signing."""
+        with pytest.raises(AuthenticationError, match="must be signed"):
+            _verify(service, None)
+        assert _verify(service, None, required=False) is None
+
+    def test_tampered(self, service):
+        """Changing the body or path should invalidate the signature."""
+        key = service.create_key("acme")
+        with pytest.raises(AuthenticationError, match="Invalid request signature"):
+            _verify(service, _sign(key), body=b"{}")
+        with pytest.raises(AuthenticationError, match="Invalid request signature"):
+            _verify(service, _sign(key), path="/api/v1/geofences")
+
+    def test_expired(self, service):
+        """Signatures outside the tolerance should be refused."""
+        key = service.create_key("acme")
+        with pytest.raises(AuthenticationError, match="expired"):
+            _verify(service, _sign(key, timestamp=NOW - 301))
+        assert _verify(service, _sign(key, timestamp=NOW - 299))
+
+    def test_replay(self, service):
+        """A nonce should only be accepted once."""
+        key = service.create_key("acme")
+        header = _sign(key, nonce="n-0123456789abcdef")
+        _verify(service, header)
+        with pytest.raises(AuthenticationError, match="replayed"):
+            _verify(service, header)
+
+    def test_unknown_key(self, service, db_session):
+        """Signatures with another tenant's or a revoked key should be refused."""
+        TenantService(db_session).create_tenant("globex", "Globex")
+        other = service.create_key("globex")
+        with pytest.raises(AuthenticationError, match="Unknown or inactive"):
+            _verify(service, _sign(other))
+        key = service.create_key("acme")
+        service.revoke_key("acme", key.key_id)
+        with pytest.raises(AuthenticationError, match="Unknown or inactive"):
+            _verify(service, _sign(key))
+
+    def test_nonce_store_down(self, service):
+        """Without the nonce store requests should fail closed."""
+
+        class DownStore:
+            def claim(self, nonce, ttl_seconds):
+                raise ConnectionError("connection refused")
+
+        key = service.create_key("acme")
+        service.nonces = DownStore()
+        with pytest.raises(RuntimeError, match="nonce store unavailable"):
+            _verify(service, _sign(key))
+
+    def test_malformed_header(self):
+        """Headers missing fields or with short nonces should be refused."""
+        with pytest.raises(AuthenticationError, match="Malformed"):
+            parse_signature("keyId=k1,t=soon,n=0123456789abcdef,v1=00")
+        with pytest.raises(AuthenticationError, match="nonce"):
+            parse_signature("keyId=k1,t=1,n=short,v1=00")
+
+
+class TestSigningKeys:
+    """Test issuing, rotating and revoking signing keys."""
+
+    def test_rotation(self, service):
+        """Rotating should keep the previous key for the grace period only."""
+        first = service.create_key("acme")
+        second = service.create_key("acme", grace_seconds=60)
+        assert [k.key_id for k in service.active_keys("acme")] == [first.key_id, second.key_id]
+        assert first.expires_at is not None
+
+        service.create_key("acme", grace_seconds=0)
+        assert len(service.active_keys("acme")) == 1
+
+    def test_unknown_tenant(self, service):
+        """Keys should only be issued to existing tenants."""
+        with pytest.raises(LookupError):
+            service.create_key("globex")
+        with pytest.raises(LookupError):
+            service.revoke_key("acme", "missing")
+
+
+def test_body_digest():
+    """Chunked bodies should hash like the whole body."""
+    digest = BodyDigest()
+    digest.update(BODY[:10], more_body=True)
+    assert not digest.complete
+    digest.update(BODY[10:], more_body=False)
+    assert digest.complete
+    assert digest.hexdigest() == body_sha256(BODY)
@@ -0,0 +1,82 @@
+"""Unit tests for residential proxy detection from session ASN churn."""
+import pytest
+
+from src.services.residential_proxy_service import SessionAsnTracker
+from src.services.velocity_service import InMemoryVelocityStore
+
+T0 = 1_772_000_000.0
+
+
+@pytest.fixture
+def tracker():
+    """Tracker allowing two access-network ASNs per hour."""
+    return SessionAsnTracker(InMemoryVelocityStore(), window_seconds=3600, window_label="1h", max_asns=2)
+
+
+class TestSessionAsnTracker:
+    """Test per-session ASN counting."""
+
+    def test_stable_session(self, tracker):
+        """A session staying on one network should not be flagged."""
+        for i in range(5):
+            churn = tracker.observe("s-1", "81.2.69.142", 20712, timestamp=T0 + i)
+        assert (churn.distinct_asns, churn.distinct_ips) == (1, 1)
+        assert churn.detected is False
+
+    def test_network_switch_allowed(self, tracker):
+        """Moving between home Wi-Fi and cellular should stay within the limit."""
+        tracker.observe("s-1", "81.2.69.142", 20712, timestamp=T0)
+        churn = tracker.observe("s-1", "172.56.1.1", 21928, connection_type="cellular", timestamp=T0 + 60)
+        assert churn.distinct_asns == 2
+        assert churn.asn_churn is False
+
+    def test_asn_churn_flagged(self, tracker):
+        """Hopping across more consumer ISPs than allowed should be flagged."""
+        for i, asn in enumerate((20712, 7922, 3320)):
+            churn = tracker.observe("s-1", f"198.51.100.{i}", asn, timestamp=T0 + i)
+        assert churn.asn_churn is True
+        assert churn.detected is True
+        assert churn.to_dict()["distinct_asns"] == 3
+
+    def test_window_expires(self, tracker):
+        """ASNs seen before the window should no longer count."""
+        tracker.observe("s-1", "198.51.100.1", 20712, timestamp=T0)
+        tracker.observe("s-1", "198.51.100.2", 7922, timestamp=T0 + 1)
+        churn = tracker.observe("s-1", "198.51.100.3", 3320, timestamp=T0 + 3700)
+        assert churn.distinct_asns == 1
+        assert churn.asn_churn is False
+
+    def test_hosting_asns_not_counted(self, tracker):
+        """Datacenter exits are VPN behavior and should not count as churn."""
+        for i, asn in enumerate((16509, 14061, 24940)):
+            churn = tracker.observe("s-1", f"203.0.113.{i}", asn, connection_type="hosting", timestamp=T0 + i)
+        assert churn.distinct_asns == 0
+        assert churn.distinct_ips == 3
+        assert churn.detected is False
+
+    def test_listed_endpoint_detected(self, tracker):
+        """A listed residential proxy endpoint should be detected on its first request."""
+        churn = tracker.observe("s-1", "81.2.69.142", 20712, listed=True, timestamp=T0)
+        assert churn.asn_churn is False
+        assert churn.detected is True
+
+    def test_sessions_scoped_to_tenant(self, tracker):
+        """The same session ID of two tenants should be counted separately."""
+        for i, asn in enumerate((20712, 7922, 3320)):
+            tracker.observe("s-1", f"198.51.100.{i}", asn, timestamp=T0 + i, tenant_id="acme")
+        churn = tracker.observe("s-1", "81.2.69.142", 20712, timestamp=T0 + 5, tenant_id="globex")
+        assert churn.distinct_asns == 1
+
+    def test_empty_session_rejected(self, tracker):
+        """An empty session ID should be rejected."""
+        with pytest.raises(ValueError):
+            tracker.observe("  ", "81.2.69.142", 20712)
+
+    def test_store_failure_is_runtime_error(self):
+        """Store errors should surface as RuntimeError."""
+        class BrokenStore(InMemoryVelocityStore):
+            def record(self, *args):
+                raise ConnectionError("connection refused")
+
+        with pytest.raises(RuntimeError):
+            SessionAsnTracker(BrokenStore()).observe("s-1", "81.2.69.142", 20712)
@@ -0,0 +1,131 @@
+"""Unit tests for reverse geocoding against local boundaries."""
+import json
+
+import pytest
+
+from src.services.reverse_geocoding_service import (
+    AdminLevel,
+    ReverseGeocodingService,
+    load_boundaries,
+)
+
+
+def _box(min_lon, min_lat, max_lon, max_lat):
+    return {
+        "type": "Polygon",
+        "coordinates": [[
+            [min_lon, min_lat], [max_lon, min_lat], [max_lon, max_lat],
+            [min_lon, max_lat], [min_lon, min_lat],
+        ]],
+    }
+
+
+def _write_level(directory, level, features):
+    document = {
+        "type": "FeatureCollection",
+        "features": [
+            {"type": "Feature", "properties": properties, "geometry": geometry}
+            for properties, geometry in features
+        ],
+    }
+    (directory / f"{level}.geojson").write_text(json.dumps(document))
+
+
+@pytest.fixture
+def boundary_dir(tmp_path):
+    """Boundary directory with a toy country split into regions and a city."""
+    _write_level(tmp_path, "country", [
+        ({"code": "GB", "name": "United Kingdom"}, _box(-8, 50, 2, 59)),
+        ({"code": "FR", "name": "France"}, _box(-5, 42, 8, 49.9)),
+    ])
+    _write_level(tmp_path, "admin1", [
+        ({"iso_code": "GB-ENG", "name": "England"}, _box(-6, 50, 2, 55.5)),
+        ({"iso_code": "GB-SCT", "name": "Scotland"}, _box(-8, 55.5, 0, 59)),
+    ])
+    _write_level(tmp_path, "admin2", [
+        ({"code": "GB-LND", "name": "Greater London"}, _box(-0.5, 51.2, 0.3, 51.7)),
+        ({"code": "GB-WSM", "name": "Westminster"}, _box(-0.2, 51.48, -0.11, 51.54)),
+    ])
+    _write_level(tmp_path, "city", [
+        ({"name": "London"}, _box(-0.5, 51.2, 0.3, 51.7)),
+    ])
+    _write_level(tmp_path, "postal", [
+        ({"postcode": "SW1A"}, _box(-0.15, 51.49, -0.12, 51.51)),
+    ])
+    return tmp_path
+
+
+class TestLoadBoundaries:
+    """Test GeoJSON boundary loading."""
+
+    def test_properties_mapped(self, boundary_dir):
+        """Code and name should be read from properties with fallbacks."""
+        boundaries = load_boundaries(str(boundary_dir / "admin1.geojson"), AdminLevel.ADMIN1)
+        assert [(b.code, b.name) for b in boundaries] == [
+            ("GB-ENG", "England"), ("GB-SCT", "Scotland"),
+        ]
+
+    def test_invalid_features_skipped(self, tmp_path):
+        """Features with unsupported geometry should be skipped."""
+        _write_level(tmp_path, "country", [
+            ({"code": "XX"}, {"type": "Point", "coordinates": [0, 0]}),
+            ({"code": "YY"}, _box(0, 0, 1, 1)),
+        ])
+        boundaries = load_boundaries(str(tmp_path / "country.geojson"), AdminLevel.COUNTRY)
+        assert [b.code for b in boundaries] == ["YY"]
+
+    def test_not_feature_collection(self, tmp_path):
+        """Non-FeatureCollection documents should raise ValueError."""
+        path = tmp_path / "country.geojson"
+        path.write_text(json.dumps({"type": "Feature"}))
+        with pytest.raises(ValueError, match="FeatureCollection"):
+            load_boundaries(str(path), AdminLevel.COUNTRY)
+
+
+class TestReverseGeocodingService:
+    """Test coordinate to admin area resolution."""
+
+    def test_all_levels_resolved(self, boundary_dir):
+        """A point in central London should resolve every level."""
+        service = ReverseGeocodingService.from_directory(str(boundary_dir))
+        result = service.reverse(51.5014, -0.1419)
+
+        assert result.country.code == "GB"
+        assert result.admin1.name == "England"
+        assert result.city.name == "London"
+        assert result.postal.code == "SW1A"
+        assert result.matched is True
+
+    def test_smallest_overlapping_boundary_wins(self, boundary_dir):
+        """Nested boundaries at the same level should resolve to the smallest."""
+        service = ReverseGeocodingService.from_directory(str(boundary_dir))
+        assert service.reverse(51.5014, -0.1419).admin2.code == "GB-WSM"
+        assert service.reverse(51.3, 0.1).admin2.code == "GB-LND"
+
+    def test_partial_match(self, boundary_dir):
+        """Levels without a containing boundary should be None."""
+        service = ReverseGeocodingService.from_directory(str(boundary_dir))
+        result = service.reverse(57.0, -4.0)
+
+        assert result.country.code == "GB"
+        assert result.admin1.code == "GB-SCT"
+        assert result.admin2 is None
+        assert result.postal is None
+
+    def test_no_match(self, boundary_dir):
+        """Points outside every boundary should not match."""
+        service = ReverseGeocodingService.from_directory(str(boundary_dir))
+        assert service.reverse(0.0, 0.0).matched is False
+
+    def test_missing_levels_skipped(self, tmp_path):
+        """Only levels with files present should be loaded."""
+        _write_level(tmp_path, "country", [({"code": "GB"}, _box(-8, 50, 2, 59))])
+        service = ReverseGeocodingService.from_directory(str(tmp_path))
+        assert service.levels == [AdminLevel.COUNTRY]
+
+    @pytest.mark.parametrize("lat,lon", [(91, 0), (-91, 0), (0, 181), (0, -180.5)])
+    def test_out_of_range(self, boundary_dir, lat, lon):
+        """Out-of-range coordinates should raise ValueError."""
+        service = ReverseGeocodingService.from_directory(str(boundary_dir))
+        with pytest.raises(ValueError, match="out of range"):
+            service.reverse(lat, lon)
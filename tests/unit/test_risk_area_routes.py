@@ -0,0 +1,87 @@
+"""Route tests for tenant high-risk lists."""
+import pytest
+
+from src.services.auth_service import TokenVerifier
+
+PORT = {
+    "type": "Polygon",
+    "coordinates": [[[4.40, 51.22], [4.44, 51.22], [4.44, 51.25], [4.40, 51.25], [4.40, 51.22]]],
+}
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API, with a test secret."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+def _auth(verifier, tenant_id):
+    return {"Authorization": f"Bearer {verifier.issue('user-1', tenant_id)}"}
+
+
+class TestRiskAreaRoutes:
+    """Test managing the high-risk list."""
+
+    def test_requires_token(self, db_client, verifier):
+        """Should require a bearer token."""
+        assert db_client.get("/api/v1/risk/areas").status_code == 401
+
+    def test_create_list_delete(self, db_client, verifier):
+        """Entries should be listed for their tenant until deleted."""
+        headers = _auth(verifier, "acme")
+        region = db_client.post("/api/v1/risk/areas", json={"kind": "region", "code": "br-rj"}, headers=headers)
+        assert region.status_code == 201
+        assert region.json()["code"] == "BR-RJ"
+        polygon = db_client.post(
+            "/api/v1/risk/areas", json={"kind": "polygon", "name": "Port district", "geometry": PORT}, headers=headers
+        )
+        assert polygon.status_code == 201
+
+        listed = db_client.get("/api/v1/risk/areas", headers=headers).json()["areas"]
+        assert [a["kind"] for a in listed] == ["region", "polygon"]
+        assert db_client.get("/api/v1/risk/areas", headers=_auth(verifier, "globex")).json()["areas"] == []
+
+        area_id = polygon.json()["area_id"]
+        assert db_client.delete(f"/api/v1/risk/areas/{area_id}", headers=headers).status_code == 204
+        assert db_client.delete(f"/api/v1/risk/areas/{area_id}", headers=headers).status_code == 404
+
+    def test_list_as_geojson(self, db_client, verifier):
+        """Should list entries as a FeatureCollection, regions without geometry."""
+        headers = _auth(verifier, "acme")
+        db_client.post("/api/v1/risk/areas", json={"kind": "region", "code": "br-rj"}, headers=headers)
+        created = db_client.post(
+            "/api/v1/risk/areas",
+            params={"format": "geojson"},
+            json={"kind": "polygon", "name": "Port district", "geometry": PORT},
+            headers=headers,
+        )
+        assert created.status_code == 201
+        assert created.json()["type"] == "Feature"
+
+        response = db_client.get("/api/v1/risk/areas", params={"format": "geojson"}, headers=headers)
+        assert response.headers["content-type"] == "application/geo+json"
+        body = response.json()
+        assert body["type"] == "FeatureCollection"
+        assert body["bbox"] == [4.40, 51.22, 4.44, 51.25]
+        region, polygon = body["features"]
+        assert region["geometry"] is None and "bbox" not in region
+        assert region["properties"]["code"] == "BR-RJ"
+        assert polygon["id"] == created.json()["id"]
+
+    def test_invalid_entry_is_400(self, db_client, verifier):
+        """A region code passed as a country should be rejected."""
+        response = db_client.post(
+            "/api/v1/risk/areas", json={"kind": "country", "code": "BR-RJ"}, headers=_auth(verifier, "acme")
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_other_tenant_gets_404(self, db_client, verifier):
+        """Should hide entries of other tenants."""
+        created = db_client.post(
+            "/api/v1/risk/areas", json={"kind": "country", "code": "BE"}, headers=_auth(verifier, "acme")
+        ).json()
+        response = db_client.delete(f"/api/v1/risk/areas/{created['area_id']}", headers=_auth(verifier, "globex"))
+        assert response.status_code == 404
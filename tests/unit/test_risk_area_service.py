@@ -0,0 +1,77 @@
+"""Unit tests for per-tenant high-risk lists."""
+import pytest
+
+from src.services.risk_area_service import RiskAreaService, RiskListCache
+
+PORT = {
+    "type": "Polygon",
+    "coordinates": [[[4.40, 51.22], [4.44, 51.22], [4.44, 51.25], [4.40, 51.25], [4.40, 51.22]]],
+}
+
+
+@pytest.fixture
+def service(db_session):
+    """Risk area service with its own list cache."""
+    return RiskAreaService(db_session, cache=RiskListCache())
+
+
+class TestRiskAreaService:
+    """Test managing and compiling tenant lists."""
+
+    def test_entries_compiled(self, service):
+        """Countries, regions and polygons should land in the compiled list."""
+        service.create_area("acme", "country", "be")
+        service.create_area("acme", "region", "br-rj")
+        polygon = service.create_area("acme", "polygon", name="Port district", geometry=PORT)
+
+        compiled = service.risk_list("acme")
+        assert compiled.countries == {"BE"}
+        assert compiled.regions == {"BR-RJ"}
+        assert [area.area_id for area in compiled.areas] == [polygon.area_id]
+        assert compiled.areas[0].geometry.contains(4.42, 51.23)
+
+    def test_no_entries_uses_global(self, service):
+        """Tenants without entries and anonymous callers should get None."""
+        service.create_area("acme", "country", "BE")
+        assert service.risk_list("globex") is None
+        assert service.risk_list(None) is None
+
+    @pytest.mark.parametrize("kind,code,geometry", [
+        ("country", "BE-VAN", None),
+        ("region", "BE", None),
+        ("country", "Belgium", None),
+        ("country", None, None),
+        ("polygon", None, None),
+        ("polygon", "BE", PORT),
+        ("polygon", None, {"type": "Point", "coordinates": [4.4, 51.2]}),
+        ("city", "BE", None),
+    ])
+    def test_invalid_entries(self, service, kind, code, geometry):
+        """Codes must fit their kind and polygons need a valid polygon geometry."""
+        with pytest.raises(ValueError):
+            service.create_area("acme", kind, code, geometry=geometry)
+
+    def test_duplicate_code_rejected(self, service):
+        """A code should only be listed once per tenant."""
+        service.create_area("acme", "country", "BE")
+        with pytest.raises(ValueError):
+            service.create_area("acme", "country", "be")
+        assert service.create_area("globex", "country", "BE").code == "BE"
+
+    def test_changes_rebuild_list(self, service):
+        """Adding and deleting entries should be reflected in the compiled list."""
+        service.create_area("acme", "country", "BE")
+        assert service.risk_list("acme").countries == {"BE"}
+
+        nl = service.create_area("acme", "country", "NL")
+        assert service.risk_list("acme").countries == {"BE", "NL"}
+
+        service.delete_area(nl)
+        assert service.risk_list("acme").countries == {"BE"}
+
+    def test_scoped_to_tenant(self, service):
+        """Entries of other tenants should be hidden."""
+        area = service.create_area("acme", "country", "BE")
+        assert service.get_area("globex", area.area_id) is None
+        assert [a.code for a in service.list_areas("acme")] == ["BE"]
+        assert service.list_areas("globex") == []
@@ -0,0 +1,268 @@
+"""Unit tests for risk scoring."""
+from types import SimpleNamespace
+
+import pytest
+
+from src.config import Config
+from src.services.risk_service import (
+    AnonymizerSignal,
+    DatacenterSignal,
+    HighRiskArea,
+    HighRiskCountrySignal,
+    HighRiskList,
+    ImpossibleTravelSignal,
+    ReasonCode,
+    ResidentialProxySignal,
+    RiskContext,
+    RiskScorer,
+    RiskSignal,
+    SignalHit,
+    VelocitySignal,
+    build_risk_scorer,
+    parse_weights,
+)
+from src.services.velocity_service import VelocitySnapshot
+from src.spatial.geometry import geometry_from_geojson
+
+ANTWERP_PORT = geometry_from_geojson({
+    "type": "Polygon",
+    "coordinates": [[[4.40, 51.22], [4.44, 51.22], [4.44, 51.25], [4.40, 51.25], [4.40, 51.22]]],
+})
+
+
+class Flags(dict):
+    """Stand-in for the anonymizer block of a lookup response."""
+
+    def model_dump(self):
+        return dict(self)
+
+
+def _scorer(**weights):
+    return RiskScorer(
+        [AnonymizerSignal(), ImpossibleTravelSignal(), DatacenterSignal(), HighRiskCountrySignal(["KP"])],
+        weights,
+    )
+
+
+class TestParseWeights:
+    """Test RISK_WEIGHTS parsing."""
+
+    def test_pairs(self):
+        """Weights should be parsed per signal and clamped to 0-1."""
+        assert parse_weights("Anonymizer:0.6, datacenter:2,velocity:-1") == {
+            "anonymizer": 0.6, "datacenter": 1.0, "velocity": 0.0,
+        }
+
+    def test_malformed_ignored(self):
+        """Malformed entries should be skipped."""
+        assert parse_weights("anonymizer,datacenter:0.2,") == {"datacenter": 0.2}
+
+
+class TestRiskScorer:
+    """Test combining signals into a score."""
+
+    def test_no_signal(self):
+        """A clean request should score 0 with no reasons."""
+        score = _scorer().score(RiskContext(country_code="GB", anonymizer={"vpn": False}))
+        assert (score.score, score.reasons) == (0, [])
+
+    def test_single_signal_scores_its_weight(self):
+        """One fired signal should score its weight."""
+        score = _scorer(anonymizer=0.6).score(RiskContext(anonymizer={"vpn": True}))
+        assert score.score == 60
+        assert [(r.code, r.signal, r.weight) for r in score.reasons] == [
+            (ReasonCode.ANONYMIZER_VPN, "anonymizer", 0.6),
+        ]
+
+    def test_signals_compound(self):
+        """Several signals should combine below 100, highest weight first."""
+        context = RiskContext(country_code="kp", anonymizer={"tor": True, "vpn": True}, impossible_travel=True)
+        score = _scorer(anonymizer=0.5, impossible_travel=0.7, high_risk_country=0.4).score(context)
+        # 1 - 0.5 * 0.3 * 0.6
+        assert score.score == 91
+        assert [r.code for r in score.reasons] == [
+            ReasonCode.IMPOSSIBLE_TRAVEL, ReasonCode.ANONYMIZER_TOR, ReasonCode.HIGH_RISK_COUNTRY,
+        ]
+
+    def test_zero_weight_disables(self):
+        """Signals weighted 0 should neither fire nor add reasons."""
+        score = _scorer(datacenter=0).score(RiskContext(connection_type="hosting"))
+        assert (score.score, score.reasons) == (0, [])
+
+    def test_full_weight_caps_at_100(self):
+        """A weight of 1 should saturate the score."""
+        context = RiskContext(anonymizer={"hosting": True}, impossible_travel=True)
+        assert _scorer(datacenter=1.0).score(context).score == 100
+
+    def test_custom_signal(self):
+        """Registered signals should be scored with their configured weight."""
+        class AlwaysSignal(RiskSignal):
+            name = "always"
+
+            def evaluate(self, context):
+                return SignalHit("ALWAYS")
+
+        scorer = _scorer(always=0.25)
+        scorer.register(AlwaysSignal())
+        assert scorer.signals["always"] == 0.25
+        assert scorer.score(RiskContext()).reasons[0].code == "ALWAYS"
+
+    def test_unweighted_custom_signal_disabled(self):
+        """Signals without a configured weight should not fire."""
+        class AlwaysSignal(RiskSignal):
+            name = "always"
+
+            def evaluate(self, context):
+                return SignalHit("ALWAYS")
+
+        assert RiskScorer([AlwaysSignal()]).score(RiskContext()).score == 0
+
+    def test_failing_signal_skipped(self):
+        """A signal that raises should be logged and skipped."""
+        class BrokenSignal(RiskSignal):
+            name = "broken"
+
+            def evaluate(self, context):
+                raise KeyError("boom")
+
+        scorer = RiskScorer([BrokenSignal(), ImpossibleTravelSignal()], {"broken": 0.9})
+        score = scorer.score(RiskContext(impossible_travel=True))
+        assert score.score == 70
+        assert [r.signal for r in score.reasons] == ["impossible_travel"]
+
+
+class TestSignals:
+    """Test individual signals."""
+
+    @pytest.mark.parametrize("flags,code", [
+        ({"vpn": True, "proxy": True}, ReasonCode.ANONYMIZER_PROXY),
+        ({"vpn": True, "tor": None}, ReasonCode.ANONYMIZER_VPN),
+        ({"vpn": None, "hosting": True}, None),
+    ])
+    def test_anonymizer(self, flags, code):
+        """The strongest set flag should be reported; hosting is not an anonymizer."""
+        hit = AnonymizerSignal().evaluate(RiskContext(anonymizer=flags))
+        assert (hit.code if hit else None) == code
+
+    def test_residential_proxy_not_anonymizer(self):
+        """Residential proxies should fire their own signal, not the anonymizer one."""
+        context = RiskContext(anonymizer={"proxy": False, "residential_proxy": True}, residential_proxy=True)
+        assert AnonymizerSignal().evaluate(context) is None
+        assert ResidentialProxySignal().evaluate(context).code == ReasonCode.RESIDENTIAL_PROXY
+        assert ResidentialProxySignal().evaluate(RiskContext(residential_proxy=None)) is None
+
+    def test_datacenter_from_asn(self):
+        """Hosting ASNs should fire the datacenter signal without anonymizer flags."""
+        assert DatacenterSignal().evaluate(RiskContext(connection_type="hosting")).code == ReasonCode.DATACENTER_IP
+        assert DatacenterSignal().evaluate(RiskContext(connection_type="residential")) is None
+
+    def test_datacenter_classification(self):
+        """The datacenter block's flag should fire the datacenter signal."""
+        assert DatacenterSignal().evaluate(RiskContext(datacenter=True)).code == ReasonCode.DATACENTER_IP
+        assert DatacenterSignal().evaluate(RiskContext(datacenter=False)) is None
+
+    def test_velocity(self):
+        """More distinct countries than allowed in any window should fire."""
+        snapshot = VelocitySnapshot("account", "alice", {"country": {"15m": 1, "24h": 3}})
+        hit = VelocitySignal(max_countries=2).evaluate(RiskContext(velocity=snapshot))
+        assert hit.code == ReasonCode.HIGH_VELOCITY
+        assert "24h" in hit.detail
+        assert VelocitySignal(max_countries=3).evaluate(RiskContext(velocity=snapshot)) is None
+        assert VelocitySignal().evaluate(RiskContext()) is None
+
+    def test_global_high_risk_countries(self):
+        """Without a tenant list the configured countries should apply."""
+        signal = HighRiskCountrySignal(["KP"])
+        assert signal.evaluate(RiskContext(country_code="kp")).code == ReasonCode.HIGH_RISK_COUNTRY
+        assert signal.evaluate(RiskContext(country_code="BE")) is None
+
+    def test_tenant_list_replaces_global(self):
+        """A tenant's list should be used instead of the configured countries."""
+        tenant = HighRiskList(countries=frozenset({"BE"}))
+        signal = HighRiskCountrySignal(["KP"])
+        assert signal.evaluate(RiskContext(country_code="KP", high_risk=tenant)) is None
+        assert signal.evaluate(RiskContext(country_code="BE", high_risk=tenant)).code == ReasonCode.HIGH_RISK_COUNTRY
+
+    def test_tenant_regions_and_areas(self):
+        """Regions and polygons should match, the most specific reported."""
+        tenant = HighRiskList(
+            countries=frozenset({"BE"}),
+            regions=frozenset({"BE-VAN"}),
+            areas=[HighRiskArea("a-1", "Port district", ANTWERP_PORT)],
+        )
+        signal = HighRiskCountrySignal()
+        port = RiskContext(country_code="BE", region_codes=["BE-VLG", "BE-VAN"], latitude=51.23, longitude=4.42,
+                           high_risk=tenant)
+        hit = signal.evaluate(port)
+        assert (hit.code, hit.detail) == (ReasonCode.HIGH_RISK_AREA, "Located in Port district")
+
+        port.latitude = 51.10
+        assert signal.evaluate(port).code == ReasonCode.HIGH_RISK_REGION
+        port.region_codes = ["BE-BRU"]
+        assert signal.evaluate(port).code == ReasonCode.HIGH_RISK_COUNTRY
+
+
+class TestRiskContext:
+    """Test building contexts from lookup responses."""
+
+    def test_from_lookup(self):
+        """Blocks attached to a lookup should be read into the context."""
+        response = SimpleNamespace(
+            country_iso_code="US",
+            anonymizer=Flags(vpn=True),
+            asn=SimpleNamespace(connection_type="hosting"),
+            travel=SimpleNamespace(impossible=True),
+            location_mismatch=None,
+            device=SimpleNamespace(known_device=True, country_seen_before=False),
+        )
+        context = RiskContext.from_lookup(response)
+        assert context.anonymizer == {"vpn": True}
+        assert (context.connection_type, context.impossible_travel, context.location_mismatch) == (
+            "hosting", True, None,
+        )
+        assert context.new_device_country is True
+
+    def test_unknown_device_not_new_country(self):
+        """A device's first sighting should not count as a new country."""
+        response = SimpleNamespace(
+            country_iso_code="US", anonymizer=None, asn=None, travel=None, location_mismatch=None,
+            device=SimpleNamespace(known_device=False, country_seen_before=False),
+        )
+        assert RiskContext.from_lookup(response).new_device_country is False
+
+    def test_residential_proxy_from_flag_or_session(self):
+        """A listed endpoint or a churning session should mark a residential proxy."""
+        listed = SimpleNamespace(
+            country_iso_code="US", anonymizer=Flags(residential_proxy=True), residential_proxy=None,
+        )
+        assert RiskContext.from_lookup(listed).residential_proxy is True
+        churning = SimpleNamespace(
+            country_iso_code="US", anonymizer=Flags(residential_proxy=False),
+            residential_proxy=SimpleNamespace(detected=True),
+        )
+        assert RiskContext.from_lookup(churning).residential_proxy is True
+        clean = SimpleNamespace(country_iso_code="US", anonymizer=None)
+        assert RiskContext.from_lookup(clean).residential_proxy is None
+
+    def test_region_codes(self):
+        """Subdivisions and hierarchy levels should become ISO 3166-2 region codes."""
+        response = SimpleNamespace(
+            country_iso_code="GB", latitude=51.5, longitude=-0.09,
+            subdivisions=[{"iso_code": "ENG", "name": "England"}],
+            hierarchy=[SimpleNamespace(iso_code="GB-ENG"), SimpleNamespace(iso_code="GB-LND")],
+        )
+        context = RiskContext.from_lookup(response, high_risk=HighRiskList())
+        assert context.region_codes == ["GB-ENG", "GB-LND"]
+        assert (context.latitude, context.longitude) == (51.5, -0.09)
+        assert context.high_risk == HighRiskList()
+
+
+def test_build_from_config(monkeypatch):
+    """RISK_WEIGHTS should override the defaults of the built-in signals."""
+    monkeypatch.setenv("RISK_WEIGHTS", "datacenter:0.9")
+    monkeypatch.setenv("RISK_HIGH_RISK_COUNTRIES", "kp, ir")
+    scorer = build_risk_scorer(Config())
+    assert scorer.signals["datacenter"] == 0.9
+    assert scorer.signals["anonymizer"] == 0.5
+    score = scorer.score(RiskContext(country_code="IR"))
+    assert [r.code for r in score.reasons] == [ReasonCode.HIGH_RISK_COUNTRY]
@@ -0,0 +1,79 @@
+"""Unit tests for the detection rule expression language."""
+import pytest
+
+from src.services.rule_dsl import RuleSyntaxError, compile_expression
+
+
+class TestEvaluation:
+    """Test evaluating compiled expressions."""
+
+    def test_example_rule(self):
+        """The documented example should fire only when every clause holds."""
+        rule = compile_expression('risk > 70 && country in ["RU", "IR"] && is_vpn')
+        assert rule({"risk": 80, "country": "IR", "is_vpn": True}) is True
+        assert rule({"risk": 80, "country": "GB", "is_vpn": True}) is False
+        assert rule({"risk": 60, "country": "IR", "is_vpn": True}) is False
+        assert rule.variables == {"risk", "country", "is_vpn"}
+
+    def test_precedence(self):
+        """&& should bind tighter than ||, and parentheses should override."""
+        context = {"is_tor": True, "is_vpn": False, "is_proxy": False}
+        assert compile_expression("is_tor || is_vpn && is_proxy")(context) is True
+        assert compile_expression("(is_tor || is_vpn) && is_proxy")(context) is False
+
+    def test_word_operators(self):
+        """and/or/not should work like &&/||/!."""
+        rule = compile_expression("not is_datacenter and (asn == 9009 or country not in ['US', 'CA'])")
+        assert rule({"is_datacenter": False, "asn": 1, "country": "FR"}) is True
+        assert rule({"is_datacenter": False, "asn": 1, "country": "US"}) is False
+        assert rule({"is_datacenter": True, "asn": 9009}) is False
+
+    def test_list_variables(self):
+        """'in' should test membership of list variables."""
+        rule = compile_expression('"ANONYMIZER_TOR" in reasons || "GB-LND" in regions')
+        assert rule({"reasons": ["ANONYMIZER_TOR"], "regions": []}) is True
+        assert rule({"reasons": [], "regions": ["GB-ENG", "GB-LND"]}) is True
+        assert rule({"reasons": None, "regions": None}) is False
+
+    def test_missing_values_are_false(self):
+        """Comparisons with null should not fire, but == null should work."""
+        assert compile_expression("risk > 70")({}) is False
+        assert compile_expression("risk <= 70")({"risk": None}) is False
+        assert compile_expression("country in ['IR']")({}) is False
+        assert compile_expression("country == null")({}) is True
+        assert compile_expression("!is_vpn")({}) is True
+
+    def test_numbers(self):
+        """Integers, decimals and negative numbers should compare numerically."""
+        assert compile_expression("confidence < 0.5")({"confidence": 0.25}) is True
+        assert compile_expression("accuracy_km >= -1")({"accuracy_km": 0}) is True
+
+
+class TestValidation:
+    """Test rejecting invalid expressions."""
+
+    @pytest.mark.parametrize("expression,message", [
+        ("", "Empty expression"),
+        ("risk >", "Unexpected end of expression"),
+        ("risk > 70 )", "Unexpected ')'"),
+        ("(risk > 70", "Expected ')'"),
+        ("risky > 70", "Unknown variable 'risky'"),
+        ('risk > "high"', "'>' needs numbers"),
+        ("is_vpn == 1", "Cannot compare bool with number"),
+        ('country in "IR"', "'in' needs a list"),
+        ("country in [risk]", "Lists may only hold literals"),
+        ("risk", "Expression must be a condition"),
+        ("risk > 70 & is_vpn", "Unexpected character '&'"),
+    ])
+    def test_errors(self, expression, message):
+        """Malformed and ill-typed expressions should fail with a message."""
+        with pytest.raises(RuleSyntaxError) as error:
+            compile_expression(expression)
+        assert message in str(error.value)
+
+    def test_error_position(self):
+        """Errors should report the offending column."""
+        with pytest.raises(RuleSyntaxError) as error:
+            compile_expression("is_vpn && bogus")
+        assert error.value.position == 10
+        assert str(error.value).endswith("at column 11")
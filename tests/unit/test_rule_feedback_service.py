@@ -0,0 +1,64 @@
+"""Unit tests for feedback on detection rule decisions."""
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.services.rule_feedback_service import RuleFeedbackService, RuleFeedbackStats
+
+
+@pytest.fixture
+def service(db_session):
+    return RuleFeedbackService(db_session)
+
+
+class TestRecordFeedback:
+    """Test storing feedback."""
+
+    def test_one_row_per_rule(self, service):
+        """Each reported rule should get a row sharing the feedback ID."""
+        rows = service.record(
+            "acme", ["vpn", "tor", "vpn"], "false_positive", decision="block", ip_address=" 185.220.101.1 "
+        )
+        assert [row.rule_id for row in rows] == ["vpn", "tor"]
+        assert len({row.feedback_id for row in rows}) == 1
+        assert rows[0].ip_address == "185.220.101.1"
+
+    @pytest.mark.parametrize("kwargs", [
+        {"rule_ids": ["vpn"], "verdict": "wrong"},
+        {"rule_ids": [], "verdict": "false_positive"},
+        {"rule_ids": [" "], "verdict": "false_positive"},
+        {"rule_ids": ["vpn"], "verdict": "false_positive", "decision": "deny"},
+        {"rule_ids": ["vpn"], "verdict": "false_positive", "ip_address": "not-an-ip"},
+        {"rule_ids": ["vpn", "gone"], "verdict": "false_positive", "known_rule_ids": {"vpn"}},
+    ])
+    def test_invalid_feedback(self, service, kwargs):
+        """Invalid verdicts, decisions, addresses and unknown rules should be rejected."""
+        with pytest.raises(ValueError):
+            service.record("acme", **kwargs)
+
+
+class TestFeedbackStats:
+    """Test aggregating feedback into per-rule precision."""
+
+    def test_precision_per_rule(self, service):
+        """Precision should be confirmed reports over all reports, least precise first."""
+        service.record("acme", ["vpn", "tor"], "false_positive")
+        service.record("globex", ["vpn"], "false_positive")
+        service.record("acme", ["vpn"], "true_positive")
+        service.record("acme", ["tor"], "true_positive")
+
+        stats = {entry.rule_id: entry for entry in service.stats()}
+        assert [entry.rule_id for entry in service.stats()] == ["vpn", "tor"]
+        assert stats["vpn"].to_dict() == {
+            "rule_id": "vpn", "reports": 3, "true_positives": 1, "false_positives": 2, "precision": 0.3333,
+        }
+        assert stats["tor"].precision == 0.5
+
+    def test_since(self, service):
+        """Feedback before `since` should not be counted."""
+        service.record("acme", ["vpn"], "false_positive")
+        assert service.stats(since=datetime.utcnow() + timedelta(hours=1)) == []
+
+    def test_no_reports(self):
+        """A rule without reports should have no precision."""
+        assert RuleFeedbackStats("vpn").precision is None
@@ -0,0 +1,108 @@
+"""Route tests for detection rules."""
+import json
+
+import pytest
+
+from src.services.auth_service import TokenVerifier
+from src.services.rule_service import RuleEngine
+
+
+@pytest.fixture
+def rules_file(tmp_path):
+    path = tmp_path / "rules.json"
+    path.write_text(json.dumps({"rules": [{"id": "tor", "action": "review", "expression": "is_tor"}]}))
+    return path
+
+
+@pytest.fixture
+def engine(rules_file, monkeypatch):
+    """Rule engine reading the fixture rules file."""
+    engine = RuleEngine(str(rules_file))
+    monkeypatch.setattr("src.api.rule_routes.get_rule_engine", lambda: engine)
+    return engine
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API, with a test secret."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+def _auth(verifier, tenant_id):
+    return {"Authorization": f"Bearer {verifier.issue('user-1', tenant_id)}"}
+
+
+class TestRuleRoutes:
+    """Test the rules API."""
+
+    def test_list_rules(self, test_client, engine):
+        """Active rules should be listed with the file version."""
+        body = test_client.get("/api/v1/rules").json()
+        assert [rule["rule_id"] for rule in body["rules"]] == ["tor"]
+        assert body["version"] == engine.version
+        assert body["rules"][0]["state"] == "enforce"
+        assert body["last_error"] is None
+
+    def test_reload_rejected_file(self, test_client, engine, rules_file):
+        """A rejected file should be a 400 and keep the previous rules."""
+        assert len(engine.rules) == 1
+        rules_file.write_text(json.dumps({"rules": [{"id": "bad", "expression": "risky"}]}))
+        response = test_client.post("/api/v1/rules/reload")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+        assert [rule["rule_id"] for rule in test_client.get("/api/v1/rules").json()["rules"]] == ["tor"]
+
+    def test_reload_without_path_is_503(self, test_client, monkeypatch):
+        """Reloading without RULES_PATH should be a 503."""
+        monkeypatch.setattr("src.api.rule_routes.get_rule_engine", lambda: RuleEngine())
+        assert test_client.post("/api/v1/rules/reload").status_code == 503
+
+    def test_validate(self, test_client):
+        """Validation should report the variables or the error position."""
+        ok = test_client.post("/api/v1/rules/validate", json={"expression": "risk > 70 && is_vpn"}).json()
+        assert ok == {"valid": True, "error": None, "position": None, "variables": ["is_vpn", "risk"]}
+        bad = test_client.post("/api/v1/rules/validate", json={"expression": "risk >"}).json()
+        assert bad["valid"] is False
+        assert bad["position"] == 6
+
+    def test_variables(self, test_client):
+        """Variables should be listed with their types."""
+        assert test_client.get("/api/v1/rules/variables").json()["is_vpn"]["type"] == "bool"
+
+
+class TestRuleFeedbackRoutes:
+    """Test reporting feedback and reading per-rule precision."""
+
+    def test_requires_token(self, db_client, verifier, engine):
+        """Reporting should require a bearer token."""
+        response = db_client.post("/api/v1/rules/feedback", json={"rule_ids": ["tor"], "verdict": "false_positive"})
+        assert response.status_code == 401
+
+    def test_report_and_stats(self, db_client, verifier, engine):
+        """Reports should be aggregated into per-rule precision."""
+        headers = _auth(verifier, "acme")
+        for verdict in ("false_positive", "false_positive", "true_positive"):
+            response = db_client.post(
+                "/api/v1/rules/feedback",
+                json={"rule_ids": ["tor"], "verdict": verdict, "decision": "review"},
+                headers=headers,
+            )
+            assert response.status_code == 201
+            assert response.json()["rule_ids"] == ["tor"]
+
+        stats = db_client.get("/api/v1/rules/feedback/stats").json()["rules"]
+        assert stats == [
+            {"rule_id": "tor", "reports": 3, "true_positives": 1, "false_positives": 2, "precision": 0.3333},
+        ]
+
+    def test_unknown_rule_is_400(self, db_client, verifier, engine):
+        """Rules not in the active rules file should be rejected."""
+        response = db_client.post(
+            "/api/v1/rules/feedback",
+            json={"rule_ids": ["tor", "vpn"], "verdict": "false_positive"},
+            headers=_auth(verifier, "acme"),
+        )
+        assert response.status_code == 400
+        assert "vpn" in response.json()["detail"]["error_message"]
@@ -0,0 +1,191 @@
+"""Unit tests for detection rules and the hot-reloading rule engine."""
+import json
+import os
+from types import SimpleNamespace
+
+import pytest
+
+from src.services.rule_service import RuleEngine, RuleEvaluation, RuleMatch, parse_rules, rule_context
+
+VPN_RULE = {"id": "vpn-sanctioned", "action": "block", "expression": 'risk > 70 && country in ["RU", "IR"] && is_vpn'}
+TOR_RULE = {"id": "tor", "action": "review", "expression": "is_tor"}
+
+
+class FakeClock:
+    """Monotonic clock advanced by hand."""
+
+    def __init__(self):
+        self.now = 0.0
+
+    def __call__(self):
+        return self.now
+
+
+class Flags:
+    """Anonymizer block stand-in."""
+
+    def __init__(self, **flags):
+        self.flags = flags
+
+    def model_dump(self):
+        return self.flags
+
+
+def _write(path, rules, mtime):
+    path.write_text(json.dumps({"rules": rules}))
+    os.utime(path, (mtime, mtime))
+
+
+@pytest.fixture
+def clock():
+    return FakeClock()
+
+
+class TestParseRules:
+    """Test validating the rules document."""
+
+    def test_valid_document(self):
+        """Rules should keep file order and default to flag."""
+        rules = parse_rules({"rules": [VPN_RULE, {"id": "dc", "expression": "is_datacenter"}]})
+        assert [(rule.rule_id, rule.action) for rule in rules] == [("vpn-sanctioned", "block"), ("dc", "flag")]
+        assert rules[0].to_dict()["variables"] == ["country", "is_vpn", "risk"]
+        assert rules[0].state == "enforce"
+
+    @pytest.mark.parametrize("document,message", [
+        ([], '"rules" list'),
+        ({"rules": [{"expression": "is_tor"}]}, "Rule #1: id is required"),
+        ({"rules": [{"id": "x", "action": "deny", "expression": "is_tor"}]}, "Rule 'x': action must be"),
+        ({"rules": [{"id": "x", "expression": "is_tr"}]}, "Rule 'x': Unknown variable"),
+        ({"rules": [TOR_RULE, TOR_RULE]}, "Rule 'tor': duplicate id"),
+        ({"rules": [{"id": "x", "state": "dry-run", "expression": "is_tor"}]}, "Rule 'x': state must be"),
+    ])
+    def test_invalid_document(self, document, message):
+        """Invalid documents should name the offending rule."""
+        with pytest.raises(ValueError) as error:
+            parse_rules(document)
+        assert message in str(error.value)
+
+
+class TestRuleEvaluation:
+    """Test combining fired rules into a decision."""
+
+    def test_most_severe_action_wins(self):
+        """Block should outrank review, which outranks flag."""
+        matches = [RuleMatch("a", "flag"), RuleMatch("b", "block"), RuleMatch("c", "review")]
+        assert RuleEvaluation(matches).decision == "block"
+        assert RuleEvaluation([RuleMatch("a", "flag")]).decision == "flag"
+        assert RuleEvaluation().decision == "allow"
+
+    def test_shadow_matches_not_in_decision(self):
+        """Shadow matches should only show in the would-be decision."""
+        evaluation = RuleEvaluation([RuleMatch("a", "flag")], [RuleMatch("b", "block")])
+        assert evaluation.decision == "flag"
+        assert evaluation.shadow_decision == "block"
+        assert evaluation.to_dict() == {"decision": "flag", "matches": [{"rule_id": "a", "action": "flag"}]}
+
+
+class TestRuleEngine:
+    """Test loading and hot-reloading the rules file."""
+
+    def test_no_path_has_no_rules(self):
+        """Without RULES_PATH nothing should be loaded."""
+        engine = RuleEngine()
+        assert engine.rules == []
+        assert engine.reload() is False
+
+    def test_evaluate(self, tmp_path, clock):
+        """Fired rules should be reported in file order."""
+        path = tmp_path / "rules.json"
+        _write(path, [VPN_RULE, TOR_RULE], 1000)
+        engine = RuleEngine(str(path), 5, clock=clock)
+
+        evaluation = engine.evaluate({"risk": 90, "country": "IR", "is_vpn": True, "is_tor": True})
+        assert [match.rule_id for match in evaluation.matches] == ["vpn-sanctioned", "tor"]
+        assert evaluation.decision == "block"
+        assert engine.evaluate({"risk": 10}).decision == "allow"
+        assert engine.version is not None
+
+    def test_shadow_rules(self, tmp_path, clock):
+        """Shadow rules should be evaluated but kept out of the decision."""
+        path = tmp_path / "rules.json"
+        shadow = {"id": "tor-block", "action": "block", "state": "shadow", "expression": "is_tor"}
+        _write(path, [TOR_RULE, shadow], 1000)
+        engine = RuleEngine(str(path), 5, clock=clock)
+
+        evaluation = engine.evaluate({"is_tor": True}, "185.220.101.1")
+        assert evaluation.decision == "review"
+        assert [match.rule_id for match in evaluation.shadow_matches] == ["tor-block"]
+        assert evaluation.shadow_decision == "block"
+        assert [rule.state for rule in engine.rules] == ["enforce", "shadow"]
+
+    def test_reload_on_change(self, tmp_path, clock):
+        """A changed file should be picked up after the check interval."""
+        path = tmp_path / "rules.json"
+        _write(path, [TOR_RULE], 1000)
+        engine = RuleEngine(str(path), 5, clock=clock)
+        assert [rule.rule_id for rule in engine.rules] == ["tor"]
+        version = engine.version
+
+        _write(path, [TOR_RULE, VPN_RULE], 2000)
+        clock.now = 1
+        assert len(engine.rules) == 1
+        clock.now = 6
+        assert len(engine.rules) == 2
+        assert engine.version != version
+
+    def test_rejected_file_keeps_rules(self, tmp_path, clock):
+        """An invalid file should leave the previous rules active and report why."""
+        path = tmp_path / "rules.json"
+        _write(path, [TOR_RULE], 1000)
+        engine = RuleEngine(str(path), 5, clock=clock)
+        assert len(engine.rules) == 1
+
+        _write(path, [{"id": "broken", "expression": "risk >"}], 2000)
+        clock.now = 10
+        assert [rule.rule_id for rule in engine.rules] == ["tor"]
+        assert "Rule 'broken'" in engine.last_error
+
+        _write(path, [VPN_RULE], 3000)
+        clock.now = 20
+        assert [rule.rule_id for rule in engine.rules] == ["vpn-sanctioned"]
+        assert engine.last_error is None
+
+    def test_invalid_json_rejected(self, tmp_path, clock):
+        """Unparseable JSON should be reported by reload."""
+        path = tmp_path / "rules.json"
+        path.write_text("{not json")
+        engine = RuleEngine(str(path), 5, clock=clock)
+        assert engine.reload() is False
+        assert engine.rules == []
+        assert engine.last_error
+
+
+class TestRuleContext:
+    """Test building rule variables from a lookup response."""
+
+    def test_variables_from_blocks(self):
+        """Blocks attached to the response should map to rule variables."""
+        response = SimpleNamespace(
+            country_iso_code="GB",
+            continent_code="EU",
+            city_name="London",
+            subdivisions=[{"iso_code": "ENG"}],
+            hierarchy=[SimpleNamespace(iso_code="GB-LND")],
+            groups=["EU-EEA"],
+            confidence=0.8,
+            accuracy_radius_km=10,
+            anonymizer=Flags(vpn=True, tor=False, proxy=False, hosting=False),
+            asn=SimpleNamespace(number=20712, connection_type="residential"),
+            risk=SimpleNamespace(score=50, reasons=[SimpleNamespace(code="ANONYMIZER_VPN")]),
+            datacenter=None,
+            travel=None,
+            device=SimpleNamespace(known_device=True, country_seen_before=False),
+        )
+        context = rule_context(response)
+        assert context["regions"] == ["GB-ENG", "GB-LND"]
+        assert context["reasons"] == ["ANONYMIZER_VPN"]
+        assert context["is_vpn"] is True
+        assert context["asn"] == 20712
+        assert context["is_datacenter"] is None
+        assert context["is_residential_proxy"] is False
+        assert context["new_device_country"] is True
@@ -0,0 +1,165 @@
+"""Unit tests for S2 cell ids and polygon coverings."""
+import math
+import random
+
+import pytest
+
+from src.spatial import s2
+from src.spatial.geometry import Polygon
+
+LONDON = Polygon(
+    [(-0.5, 51.2), (0.3, 51.2), (0.3, 51.7), (-0.1, 51.45), (-0.5, 51.7)],
+    [[(-0.3, 51.3), (-0.2, 51.3), (-0.2, 51.35), (-0.3, 51.35)]],
+)
+
+
+class TestCellIds:
+    """Test cell id computation and navigation."""
+
+    def test_origin_leaf(self):
+        """(0, 0) should map to the reference S2 leaf id."""
+        assert s2.cell_id_from_latlng(0.0, 0.0) == 0x1000000000000001
+
+    def test_known_token(self):
+        """New York at level 12 should fall in the well-known 89c25 cell."""
+        cell = s2.cell_id_from_latlng(40.7128, -74.006, 12)
+        assert s2.to_token(cell).startswith("89c25")
+        assert s2.face(cell) == 4
+
+    def test_levels_and_parents(self):
+        """Each level's cell should contain the finer cells of the same point."""
+        leaf = s2.cell_id_from_latlng(51.5, -0.12)
+        assert s2.level(leaf) == 30
+        for level in range(30):
+            cell = s2.parent(leaf, level)
+            assert s2.level(cell) == level
+            assert s2.contains(cell, leaf)
+            assert s2.cell_id_from_latlng(51.5, -0.12, level) == cell
+
+    def test_children(self):
+        """Children should be contained by and partition their parent."""
+        cell = s2.cell_id_from_latlng(10.0, 20.0, 8)
+        kids = s2.children(cell)
+        assert len(kids) == 4
+        assert all(s2.parent(k, 8) == cell for k in kids)
+        assert s2.range_min(kids[0]) == s2.range_min(cell)
+        assert s2.range_max(kids[-1]) == s2.range_max(cell)
+
+    def test_token_round_trip(self):
+        """Tokens should round-trip to the same cell id."""
+        cell = s2.cell_id_from_latlng(-33.86, 151.21, 14)
+        assert s2.from_token(s2.to_token(cell)) == cell
+
+    @pytest.mark.parametrize("token", ["", "xyz", "0", "f000000000000000"])
+    def test_invalid_token(self, token):
+        """Malformed or invalid tokens should raise ValueError."""
+        with pytest.raises(ValueError):
+            s2.from_token(token)
+
+    def test_outline_surrounds_center(self):
+        """A cell's outline should contain the point that produced it."""
+        from src.spatial.geometry import point_in_ring
+
+        cell = s2.cell_id_from_latlng(48.85, 2.35, 10)
+        assert point_in_ring(2.35, 48.85, s2.cell_outline(cell))
+
+
+class TestCovering:
+    """Test polygon coverings and the point pre-filter."""
+
+    def _check(self, polygon, covering, bounds, samples=4000):
+        cells = s2.CellUnion(covering.cells)
+        interior = s2.CellUnion(covering.interior)
+        rng = random.Random(7)
+        decided = 0
+        for _ in range(samples):
+            lon = rng.uniform(bounds[0], bounds[2])
+            lat = rng.uniform(bounds[1], bounds[3])
+            result = s2.classify_point(cells, interior, lat, lon)
+            if result is not None:
+                decided += 1
+                assert result == polygon.contains(lon, lat), (lat, lon, result)
+            else:
+                assert cells.contains_point(lat, lon)
+        return decided
+
+    def test_covering_is_exact_prefilter(self):
+        """Cell decisions should never disagree with the exact test."""
+        covering = s2.cover_geometry(LONDON, max_cells=128)
+        decided = self._check(LONDON, covering, (-0.7, 51.0, 0.5, 51.9))
+        assert covering.interior
+        assert decided > 2000  # most points need no exact test
+
+    def test_points_in_polygon_are_covered(self):
+        """Every point inside the polygon should be inside the covering."""
+        covering = s2.cover_geometry(LONDON, max_cells=32)
+        cells = s2.CellUnion(covering.cells)
+        for lon, lat in [(-0.45, 51.25), (0.25, 51.65), (-0.1, 51.3), (-0.48, 51.68)]:
+            assert LONDON.contains(lon, lat)
+            assert cells.contains_point(lat, lon)
+
+    def test_hole_not_interior(self):
+        """Points in a hole should never be decided as inside."""
+        covering = s2.cover_geometry(LONDON)
+        cells = s2.CellUnion(covering.cells)
+        interior = s2.CellUnion(covering.interior)
+        assert s2.classify_point(cells, interior, 51.325, -0.25) is not True
+
+    def test_max_cells_respected(self):
+        """Coverings should stay within the cell budget."""
+        for budget in (8, 32, 64):
+            assert len(s2.cover_geometry(LONDON, max_cells=budget).cells) <= budget
+
+    def test_large_polygon(self):
+        """A country-sized, many-vertex polygon should cover correctly."""
+        ring = [
+            (20 + 25 * math.cos(a) + 3 * math.sin(7 * a), 40 + 20 * math.sin(a))
+            for a in (i * 2 * math.pi / 500 for i in range(500))
+        ]
+        polygon = Polygon(ring)
+        covering = s2.cover_geometry(polygon, max_cells=128)
+        self._check(polygon, covering, (-10, 15, 50, 65))
+
+    def test_antimeridian_adjacent(self):
+        """Polygons touching the antimeridian should still cover correctly."""
+        polygon = Polygon([(170, -10), (179.9, -10), (179.9, 10), (170, 10)])
+        self._check(polygon, s2.cover_geometry(polygon), (165, -15, 180, 15))
+
+    def test_near_aligned_polygon_not_interior(self):
+        """A polygon barely larger than a cell's outline must not make it interior.
+
+        True S2 edges bulge past the densified lon/lat chords, so points on
+        the real cell edge can lie outside such a polygon.
+        """
+        cell = s2.parent(s2.cell_id_from_latlng(50.0, 5.0), 5)
+        outline = s2.cell_outline(cell)
+        cx = sum(lon for lon, _ in outline) / len(outline)
+        cy = sum(lat for _, lat in outline) / len(outline)
+        polygon = Polygon([(cx + (lon - cx) * 1.0005, cy + (lat - cy) * 1.0005) for lon, lat in outline])
+
+        covering = s2.cover_geometry(polygon, max_cells=128, max_level=12)
+        assert cell not in covering.interior
+
+        cells = s2.CellUnion(covering.cells)
+        interior = s2.CellUnion(covering.interior)
+        for lon, lat in s2.cell_outline(cell, points_per_edge=400):
+            if s2.classify_point(cells, interior, lat, lon) is True:
+                assert polygon.contains(lon, lat), (lat, lon)
+
+    def test_outline_error_bounds_true_edges(self):
+        """Dense true-edge points should lie within outline_error of the outline."""
+        from src.spatial.geometry import point_in_ring
+
+        for cell in (s2.parent(s2.cell_id_from_latlng(50.0, 5.0), 5),
+                     s2.cell_id_from_latlng(-33.9, 18.4, 3)):
+            outline = s2.cell_outline(cell)
+            error = s2.outline_error(cell)
+            edges = list(zip(outline, outline[1:] + outline[:1]))
+            for point in s2.cell_outline(cell, points_per_edge=200):
+                distance = min(s2._point_segment_distance(point, a, b) for a, b in edges)
+                assert distance <= error or point_in_ring(point[0], point[1], outline)
+
+    def test_invalid_limits(self):
+        """Inconsistent levels should raise ValueError."""
+        with pytest.raises(ValueError):
+            s2.cover_geometry(LONDON, min_level=10, max_level=5)
@@ -0,0 +1,197 @@
+"""Route tests for sanctions enforcement and tenant sanctions policies."""
+import pytest
+from fastapi import FastAPI, Request
+from fastapi.testclient import TestClient
+
+from src.middleware.sanctions import SanctionsMiddleware
+from src.services.auth_service import TokenVerifier
+from src.services.sanctions_service import SanctionsPolicy, SanctionsScreener, SanctionsService, parse_codes
+from tests.unit.test_sanctions_service import StubLookupService
+
+IRAN = "198.51.100.1"
+CRIMEA = "198.51.100.2"
+LONDON = "198.51.100.4"
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API and the middleware, with a test secret."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    monkeypatch.setattr("src.middleware.sanctions.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+def _auth(verifier, tenant_id):
+    return {"Authorization": f"Bearer {verifier.issue('user-1', tenant_id)}"}
+
+
+def _screener(action):
+    return SanctionsScreener(
+        SanctionsPolicy(action, parse_codes(["CU", "IR", "KP", "SY", "UA-43"])),
+        lookup_service=StubLookupService,
+    )
+
+
+def _client(db_session, action="block"):
+    """Small app behind the middleware, trusting X-Forwarded-For."""
+    app = FastAPI()
+    app.add_middleware(
+        SanctionsMiddleware,
+        screener=_screener(action),
+        session_factory=lambda: db_session,
+        exempt_paths=["/health"],
+        client_ip_header="X-Forwarded-For",
+    )
+
+    @app.get("/ping")
+    async def ping(request: Request):
+        decision = request.state.sanctions
+        return {"action": decision.action}
+
+    @app.get("/health")
+    async def health():
+        return {"status": "healthy"}
+
+    return TestClient(app)
+
+
+def _from(ip, **headers):
+    return {"X-Forwarded-For": f"10.0.0.1, {ip}", **headers}
+
+
+class TestSanctionsMiddleware:
+    """Test blocking and tagging requests."""
+
+    def test_sanctioned_country_blocked(self, db_session):
+        """Requests from a sanctioned country should be refused with 451."""
+        response = _client(db_session).get("/ping", headers=_from(IRAN))
+        assert response.status_code == 451
+        detail = response.json()["detail"]
+        assert detail["error_code"] == "E007"
+        assert detail["details"]["matched_code"] == "IR"
+
+    def test_other_countries_pass(self, db_session):
+        """Requests from elsewhere should reach the route."""
+        response = _client(db_session).get("/ping", headers=_from(LONDON))
+        assert response.status_code == 200
+        assert response.json() == {"action": "allow"}
+        assert "X-Sanctions-Match" not in response.headers
+
+    def test_rightmost_forwarded_address_used(self, db_session):
+        """Only the address appended by the trusted proxy should count."""
+        headers = {"X-Forwarded-For": f"{IRAN}, 81.2.69.142"}
+        assert _client(db_session).get("/ping", headers=headers).status_code == 200
+
+    def test_tag_mode(self, db_session):
+        """In tag mode the request should proceed with the match header."""
+        response = _client(db_session, action="tag").get("/ping", headers=_from(CRIMEA))
+        assert response.status_code == 200
+        assert response.json() == {"action": "tag"}
+        assert response.headers["X-Sanctions-Match"] == "UA-43"
+
+    def test_exempt_paths_not_screened(self, db_session):
+        """Health checks should pass from anywhere."""
+        assert _client(db_session).get("/health", headers=_from(IRAN)).status_code == 200
+
+    def test_block_audited(self, db_session):
+        """Every block should be written to the audit trail."""
+        _client(db_session).get("/ping", headers=_from(IRAN, **{"User-Agent": "curl/8"}))
+        (record,) = SanctionsService(db_session).audit_records(None)
+        assert (record.ip_address, record.method, record.path) == (IRAN, "GET", "/ping")
+        assert record.user_agent == "curl/8"
+
+    def test_tenant_policy_applied(self, db_session, verifier):
+        """Authenticated requests should use their tenant's adjustments."""
+        SanctionsService(db_session).set_policy("acme", exempt_codes=["IR"], extra_codes=["GB"])
+        client = _client(db_session)
+        assert client.get("/ping", headers=_from(IRAN, **_auth(verifier, "acme"))).status_code == 200
+        assert client.get("/ping", headers=_from(LONDON, **_auth(verifier, "acme"))).status_code == 451
+        assert client.get("/ping", headers=_from(IRAN, **_auth(verifier, "globex"))).status_code == 451
+
+        (record,) = SanctionsService(db_session).audit_records("acme")
+        assert (record.matched_code, record.policy_source) == ("GB", "tenant")
+
+    def test_invalid_token_uses_global_policy(self, db_session, verifier):
+        """A bad token should not unlock a tenant's exemptions."""
+        SanctionsService(db_session).set_policy("acme", exempt_codes=["IR"])
+        headers = _from(IRAN, Authorization="Bearer not-a-token")
+        assert _client(db_session).get("/ping", headers=headers).status_code == 451
+
+
+@pytest.fixture
+def screener(monkeypatch):
+    """Global policy blocking the default test codes."""
+    screener = _screener("block")
+    monkeypatch.setattr("src.api.sanctions_routes.get_sanctions_screener", lambda: screener)
+    return screener
+
+
+class TestSanctionsPolicyRoutes:
+    """Test tenant sanctions policy management."""
+
+    def test_requires_token(self, db_client, verifier, screener):
+        """Should require a bearer token."""
+        assert db_client.get("/api/v1/sanctions/policy").status_code == 401
+
+    def test_global_policy_by_default(self, db_client, verifier, screener):
+        """Tenants without adjustments should see the global policy."""
+        body = db_client.get("/api/v1/sanctions/policy", headers=_auth(verifier, "acme")).json()
+        assert body["action"] == "block"
+        assert body["codes"] == ["CU", "IR", "KP", "SY", "UA-43"]
+        assert body["override_action"] is None
+
+    def test_set_and_delete(self, db_client, verifier, screener):
+        """Adjustments should change the effective policy until deleted."""
+        headers = _auth(verifier, "acme")
+        response = db_client.put(
+            "/api/v1/sanctions/policy",
+            json={"action": "tag", "extra_codes": ["by"], "exempt_codes": ["CU"]},
+            headers=headers,
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert (body["action"], body["override_action"]) == ("tag", "tag")
+        assert body["codes"] == ["BY", "IR", "KP", "SY", "UA-43"]
+
+        assert db_client.delete("/api/v1/sanctions/policy", headers=headers).status_code == 204
+        assert db_client.get("/api/v1/sanctions/policy", headers=headers).json()["action"] == "block"
+        assert db_client.delete("/api/v1/sanctions/policy", headers=headers).status_code == 404
+
+    def test_invalid_code_is_400(self, db_client, verifier, screener):
+        """Malformed codes should be rejected."""
+        response = db_client.put(
+            "/api/v1/sanctions/policy", json={"extra_codes": ["Belarus"]}, headers=_auth(verifier, "acme")
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_audit_scoped_to_tenant(self, db_client, db_session, verifier, screener):
+        """Tenants should only list their own blocks."""
+        service = SanctionsService(db_session)
+        service.record_block(screener.screen(IRAN, "acme"), "GET", "/api/v1/lookup/ip/81.2.69.142")
+        service.record_block(screener.screen(CRIMEA, "globex"), "GET", "/")
+
+        body = db_client.get("/api/v1/sanctions/audit", headers=_auth(verifier, "acme")).json()
+        assert [r["matched_code"] for r in body["records"]] == ["IR"]
+        assert body["records"][0]["path"] == "/api/v1/lookup/ip/81.2.69.142"
+        assert body["next_cursor"] is None
+
+    def test_audit_pages(self, db_client, db_session, verifier, screener):
+        """Should page through the audit trail with next_cursor."""
+        service = SanctionsService(db_session)
+        for path in ("/a", "/b", "/c"):
+            service.record_block(screener.screen(IRAN, "acme"), "GET", path)
+
+        first = db_client.get("/api/v1/sanctions/audit?limit=2", headers=_auth(verifier, "acme")).json()
+        assert [r["path"] for r in first["records"]] == ["/c", "/b"]
+        second = db_client.get(
+            "/api/v1/sanctions/audit",
+            params={"limit": 2, "cursor": first["next_cursor"]},
+            headers=_auth(verifier, "acme"),
+        ).json()
+        assert [r["path"] for r in second["records"]] == ["/a"]
+        assert second["next_cursor"] is None
+
+        invalid = db_client.get("/api/v1/sanctions/audit?cursor=x", headers=_auth(verifier, "acme"))
+        assert invalid.status_code == 400
@@ -0,0 +1,156 @@
+"""Unit tests for sanctioned-country screening."""
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.services.ip_lookup_service import IpLookupResult, normalize_ip
+from src.services.mmdb_service import GeoIPRecord
+from src.services.sanctions_service import (
+    SanctionsPolicy,
+    SanctionsScreener,
+    SanctionsService,
+    parse_codes,
+)
+
+# Documentation addresses located by the stub lookup service
+LOCATIONS = {
+    "198.51.100.1": ("IR", []),
+    "198.51.100.2": ("UA", [{"iso_code": "43", "names": {"en": "Crimea"}}]),
+    "198.51.100.3": ("UA", [{"iso_code": "30", "names": {"en": "Kyiv City"}}]),
+    "198.51.100.4": ("GB", [{"iso_code": "ENG"}, {"iso_code": "LND"}]),
+}
+
+
+class StubLookupService:
+    """Lookup service answering from LOCATIONS."""
+
+    def lookup(self, ip):
+        normalized = normalize_ip(ip)
+        if ip not in LOCATIONS:
+            return IpLookupResult(normalized=normalized)
+        country, subdivisions = LOCATIONS[ip]
+        record = GeoIPRecord(
+            ip_address=ip, network=f"{ip}/32", country_iso_code=country, subdivisions=subdivisions
+        )
+        return IpLookupResult(normalized=normalized, record=record)
+
+
+def _screener(action="block", codes="CU,IR,KP,SY,UA-43"):
+    policy = SanctionsPolicy(action, parse_codes(codes.split(",")))
+    return SanctionsScreener(policy, lookup_service=StubLookupService)
+
+
+class TestParseCodes:
+    """Test country and region code normalization."""
+
+    def test_normalized(self):
+        """Codes should be stripped and upper-cased, blanks dropped."""
+        assert parse_codes([" ir", "ua-43 ", ""]) == {"IR", "UA-43"}
+
+    @pytest.mark.parametrize("code", ["IRN", "1R", "UA-", "UA-1234", "U-43"])
+    def test_invalid(self, code):
+        """Anything but CC or CC-XXX should be rejected."""
+        with pytest.raises(ValueError):
+            parse_codes([code])
+
+
+class TestSanctionsScreener:
+    """Test screening addresses against the policy."""
+
+    def test_country_blocked(self):
+        """An address in a sanctioned country should be blocked."""
+        decision = _screener().screen("198.51.100.1")
+        assert decision.blocked is True
+        assert (decision.country_code, decision.matched_code) == ("IR", "IR")
+
+    def test_region_blocked(self):
+        """A sanctioned region should match without blocking the whole country."""
+        assert _screener().screen("198.51.100.2").matched_code == "UA-43"
+        assert _screener().screen("198.51.100.3").action == "allow"
+
+    def test_most_specific_region_reported(self):
+        """The region code should be the most specific subdivision."""
+        assert _screener().screen("198.51.100.4").region_code == "GB-LND"
+
+    def test_tag_mode(self):
+        """In tag mode a match should be tagged, not blocked."""
+        decision = _screener(action="tag").screen("198.51.100.1")
+        assert decision.tagged is True
+        assert decision.blocked is False
+
+    def test_off_mode(self):
+        """With enforcement off nothing should match."""
+        assert _screener(action="off").screen("198.51.100.1").action == "allow"
+
+    def test_unknown_address_allowed(self):
+        """Addresses that cannot be located should fail open."""
+        assert _screener().screen("203.0.113.9").action == "allow"
+        assert _screener().screen("not-an-ip").action == "allow"
+
+    def test_no_dataset_allowed(self):
+        """Without a GeoIP dataset every address should be allowed."""
+        screener = SanctionsScreener(SanctionsPolicy("block", frozenset({"IR"})), lambda: None)
+        assert screener.screen("198.51.100.1").action == "allow"
+
+
+class TestTenantPolicies:
+    """Test per-tenant adjustments."""
+
+    def test_extra_and_exempt_codes(self, db_session):
+        """Tenants should add and exempt codes for their own requests only."""
+        SanctionsService(db_session).set_policy("acme", extra_codes=["gb-lnd"], exempt_codes=["IR"])
+        screener = _screener()
+
+        london = screener.screen("198.51.100.4", "acme", db_session)
+        assert (london.matched_code, london.policy_source) == ("GB-LND", "tenant")
+        assert screener.screen("198.51.100.1", "acme", db_session).action == "allow"
+        assert screener.screen("198.51.100.1", "globex", db_session).blocked is True
+
+    def test_action_override(self, db_session):
+        """A tenant action should replace the global one; None keeps it."""
+        service = SanctionsService(db_session)
+        service.set_policy("acme", action="tag")
+        assert _screener().screen("198.51.100.1", "acme", db_session).tagged is True
+
+        service.set_policy("acme", action=None, exempt_codes=["CU"])
+        assert _screener().policy_for("acme", db_session).action == "block"
+
+    def test_invalid_policy_rejected(self, db_session):
+        """Unknown actions and malformed codes should raise ValueError."""
+        service = SanctionsService(db_session)
+        with pytest.raises(ValueError):
+            service.set_policy("acme", action="deny")
+        with pytest.raises(ValueError):
+            service.set_policy("acme", extra_codes=["Russia"])
+
+    def test_delete_reverts_to_global(self, db_session):
+        """Deleting the adjustments should restore the global policy."""
+        service = SanctionsService(db_session)
+        service.set_policy("acme", exempt_codes=["IR"])
+        assert service.delete_policy("acme") is True
+        assert service.delete_policy("acme") is False
+        assert _screener().screen("198.51.100.1", "acme", db_session).blocked is True
+
+
+class TestAuditRecords:
+    """Test the audit trail of blocks."""
+
+    def test_recorded_per_tenant(self, db_session):
+        """Blocks should be listed newest first for their tenant only."""
+        service = SanctionsService(db_session)
+        screener = _screener()
+        service.record_block(screener.screen("198.51.100.1", "acme"), "GET", "/api/v1/lookup/ip/1.1.1.1", "curl/8")
+        service.record_block(screener.screen("198.51.100.2", "acme"), "POST", "/api/v1/detections")
+        service.record_block(screener.screen("198.51.100.1", "globex"), "GET", "/")
+
+        records = service.audit_records("acme")
+        assert [r.matched_code for r in records] == ["UA-43", "IR"]
+        assert records[1].user_agent == "curl/8"
+        assert records[0].to_dict()["created_at"].tzinfo is not None
+
+    def test_since_filter(self, db_session):
+        """Only blocks at or after `since` should be listed."""
+        service = SanctionsService(db_session)
+        service.record_block(_screener().screen("198.51.100.1", "acme"), "GET", "/")
+        assert len(service.audit_records("acme", since=datetime.utcnow() - timedelta(minutes=1))) == 1
+        assert service.audit_records("acme", since=datetime.utcnow() + timedelta(minutes=1)) == []
@@ -13,6 +13,7 @@ from src.models.schemas import (
     APIRequest,
     APIResponse,
     ConfidenceFlagEnum,
+    IpLookupResponse,
 )
 
 
@@ -448,3 +449,28 @@ def test_detection_output_structure():
     assert output.calculated_lat == 40.7135
     assert output.confidence_flag == ConfidenceFlagEnum.GREEN
     assert output.processed_at is not None
+
+
+def test_ip_lookup_response_ipv6_tunnel():
+    """Unit test: IpLookupResponse carries IPv6 normalization details."""
+    response = IpLookupResponse(
+        ip_address="2002:5102:458e::1",
+        ip_version=6,
+        normalized_ip="81.2.69.142",
+        tunnel="6to4",
+        network="81.2.69.128/26",
+        country_iso_code="GB",
+    )
+    assert response.ip_version == 6
+    assert response.normalized_ip == "81.2.69.142"
+    assert response.tunnel == "6to4"
+
+
+def test_ip_lookup_response_rejects_unknown_version():
+    """Unit test: IpLookupResponse only accepts IP versions 4 and 6."""
+    with pytest.raises(ValidationError):
+        IpLookupResponse(
+            ip_address="81.2.69.142",
+            ip_version=5,
+            network="81.2.69.128/26",
+        )
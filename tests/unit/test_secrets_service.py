@@ -0,0 +1,211 @@
+"""Unit tests for settings loaded from a secrets backend."""
+import pytest
+
+from src import database
+from src.config import Config
+from src.services import auth_service, geocoding_service, secrets_service
+from src.services.auth_service import get_token_verifier
+from src.services.geocoding_service import GoogleGeocodingProvider
+from src.services.secrets_service import (
+    AwsSecretsManagerBackend,
+    SecretsBackend,
+    SecretStore,
+    VaultBackend,
+    build_secrets_backend,
+    parse_secret,
+)
+
+
+class FakeBackend(SecretsBackend):
+    """Backend serving whatever the test sets, or failing."""
+
+    name = "fake"
+
+    def __init__(self, values):
+        self.values = values
+        self.error = None
+
+    def fetch(self):
+        if self.error:
+            raise RuntimeError(self.error)
+        return dict(self.values)
+
+
+class FakeSecretsManager:
+    """Stand-in for a boto3 Secrets Manager client."""
+
+    def __init__(self, response):
+        self.response = response
+        self.requests = []
+
+    def get_secret_value(self, SecretId):
+        self.requests.append(SecretId)
+        return self.response
+
+
+@pytest.fixture
+def store(monkeypatch):
+    """Global secret store over a fake backend."""
+    monkeypatch.setenv("SECRETS_BACKEND", "fake")
+    store = SecretStore(FakeBackend({"JWT_SECRET_KEY": "secret-one-0123456789abcdef0123456789"}))
+    store.refresh()
+    monkeypatch.setattr(secrets_service, "_secret_store", store)
+    return store
+
+
+class TestBackends:
+    """Test reading secrets from AWS Secrets Manager and Vault."""
+
+    def test_parse_secret(self):
+        """Only known settings should be kept, and values must be strings."""
+        assert parse_secret('{"JWT_SECRET_KEY": "s", "OTHER": "x"}', "app") == {"JWT_SECRET_KEY": "s"}
+        with pytest.raises(RuntimeError, match="not JSON"):
+            parse_secret("JWT_SECRET_KEY=s", "app")
+        with pytest.raises(RuntimeError, match="string"):
+            parse_secret({"DATABASE_PASSWORD": 1234}, "app")
+
+    def test_aws(self):
+        """The secret's SecretString should be parsed."""
+        client = FakeSecretsManager({"SecretString": '{"DATABASE_PASSWORD": "pw"}'})
+        backend = AwsSecretsManagerBackend("geo/prod", client=client)
+        assert backend.fetch() == {"DATABASE_PASSWORD": "pw"}
+        assert client.requests == ["geo/prod"]
+        with pytest.raises(RuntimeError, match="SecretString"):
+            AwsSecretsManagerBackend("geo/prod", client=FakeSecretsManager({"SecretBinary": b""})).fetch()
+
+    def test_vault(self, tmp_path):
+        """The latest KV v2 version should be read with the token from its file."""
+        calls = []
+
+        def fetch(url, headers, timeout_seconds):
+            calls.append((url, headers))
+            return {"data": {"data": {"MAXMIND_LICENSE_KEY": "lk"}, "metadata": {"version": 3}}}
+
+        token_file = tmp_path / "token"
+        token_file.write_text("s.agent-token\n")
+        backend = VaultBackend(
+            "https://vault:8200/", "geo/prod", token_file=str(token_file), namespace="ops", fetch=fetch
+        )
+        assert backend.fetch() == {"MAXMIND_LICENSE_KEY": "lk"}
+        assert calls == [(
+            "https://vault:8200/v1/secret/data/geo/prod",
+            {"X-Vault-Token": "s.agent-token", "X-Vault-Namespace": "ops"},
+        )]
+
+    def test_vault_errors(self):
+        """Unreachable Vault and unexpected responses should raise RuntimeError."""
+        def unreachable(url, headers, timeout_seconds):
+            raise RuntimeError("HTTP 403")
+
+        with pytest.raises(RuntimeError, match="HTTP 403"):
+            VaultBackend("https://vault:8200", "geo", token="t", fetch=unreachable).fetch()
+        with pytest.raises(RuntimeError, match="Unexpected"):
+            VaultBackend("https://vault:8200", "geo", token="t", fetch=lambda *a: {"errors": []}).fetch()
+
+    def test_build_backend(self, monkeypatch):
+        """Backends should be built from the bootstrap settings."""
+        assert build_secrets_backend(Config(load_secrets=False)) is None
+        monkeypatch.setenv("SECRETS_BACKEND", "vault")
+        with pytest.raises(ValueError, match="VAULT_ADDR"):
+            build_secrets_backend(Config(load_secrets=False))
+        monkeypatch.setenv("VAULT_ADDR", "https://vault:8200")
+        monkeypatch.setenv("VAULT_SECRET_PATH", "geo/prod")
+        monkeypatch.setenv("VAULT_TOKEN", "t")
+        assert isinstance(build_secrets_backend(Config(load_secrets=False)), VaultBackend)
+        monkeypatch.setenv("SECRETS_BACKEND", "keychain")
+        with pytest.raises(ValueError, match="Unknown"):
+            build_secrets_backend(Config(load_secrets=False))
+
+
+class TestSecretStore:
+    """Test refreshing and applying secrets."""
+
+    def test_refresh_bumps_generation_on_change(self, store):
+        """Only changed values should count as a new generation."""
+        assert store.generation == 1
+        assert store.refresh() is False
+        store.backend.values["JWT_SECRET_KEY"] = "secret-two-0123456789abcdef0123456789"
+        assert store.refresh() is True
+        assert store.generation == 2
+
+    def test_failed_refresh_keeps_values(self, store):
+        """A failed read should keep the loaded values."""
+        store.backend.error = "Vault sealed"
+        assert store.refresh() is False
+        assert store.last_error == "Vault sealed"
+        assert store.values == {"JWT_SECRET_KEY": "secret-one-0123456789abcdef0123456789"}
+
+    def test_overrides_environment(self, store, monkeypatch):
+        """Secrets should take precedence over the environment, which stays the fallback."""
+        monkeypatch.setenv("JWT_SECRET_KEY", "from-env")
+        monkeypatch.setenv("GOOGLE_GEOCODING_API_KEY", "google-from-env")
+        config = Config()
+        assert config.jwt_secret_key == "secret-one-0123456789abcdef0123456789"
+        assert config.google_geocoding_api_key == "google-from-env"
+        assert Config(load_secrets=False).jwt_secret_key == "from-env"
+
+    def test_first_load_failure_refused(self, monkeypatch):
+        """The service should not start on environment values when the secret is unreadable."""
+        monkeypatch.setenv("SECRETS_BACKEND", "vault")
+        monkeypatch.setenv("VAULT_ADDR", "http://127.0.0.1:9")
+        monkeypatch.setenv("VAULT_SECRET_PATH", "geo/prod")
+        monkeypatch.setenv("VAULT_TOKEN", "t")
+        monkeypatch.setattr(secrets_service, "_secret_store", None)
+        with pytest.raises(RuntimeError, match="Cannot load secrets"):
+            Config()
+
+
+class TestRotation:
+    """Test components picking up rotated secrets."""
+
+    def test_token_verifier_rebuilt(self, store, monkeypatch):
+        """A rotated JWT key should be used at once, the previous one still accepted."""
+        monkeypatch.setattr(auth_service, "_token_verifier", None)
+        monkeypatch.setattr(auth_service, "_token_verifier_generation", 0)
+        first = get_token_verifier()
+        token = first.issue("acme-api", "acme")
+        assert get_token_verifier() is first
+
+        store.backend.values = {
+            "JWT_SECRET_KEY": "secret-two-0123456789abcdef0123456789",
+            "JWT_PREVIOUS_SECRET_KEY": "secret-one-0123456789abcdef0123456789",
+        }
+        store.refresh()
+        rotated = get_token_verifier()
+        assert rotated is not first
+        assert rotated.secret == "secret-two-0123456789abcdef0123456789"
+        assert rotated.verify(token).tenant_id == "acme"
+
+    def test_database_reconnects(self, store, monkeypatch):
+        """New database credentials should switch the engine."""
+        monkeypatch.setenv("DATABASE_URL", "postgresql://geo:old@db/geo")
+        monkeypatch.setattr(database, "_db_manager", None)
+        monkeypatch.setattr(database, "_db_manager_generation", 0)
+        monkeypatch.setattr(database.DatabaseManager, "_initialize_engine", lambda self: None)
+        reconnects = []
+        monkeypatch.setattr(database.DatabaseManager, "reconnect", lambda self, url: reconnects.append(url))
+        manager = database.get_db_manager()
+        assert manager.database_url == "postgresql://geo:old@db/geo"
+
+        store.backend.values = {**store.values, "DATABASE_PASSWORD": "new"}
+        store.refresh()
+        assert database.get_db_manager() is manager
+        assert reconnects == ["postgresql://geo:new@db/geo"]
+        store.backend.values = {**store.values, "MAXMIND_LICENSE_KEY": "lk"}
+        store.refresh()
+        database.get_db_manager()
+        assert len(reconnects) == 1
+
+    def test_geocoding_key_replaced(self, store, monkeypatch):
+        """The Google provider should take a rotated key without losing its state."""
+        monkeypatch.setenv("GEOCODING_PROVIDERS", "google")
+        monkeypatch.setenv("GOOGLE_GEOCODING_API_KEY", "google-one")
+        monkeypatch.setattr(geocoding_service, "_provider_chain", None)
+        monkeypatch.setattr(geocoding_service, "_provider_chain_generation", 0)
+        chain = geocoding_service.get_provider_chain()
+
+        store.backend.values = {**store.values, "GOOGLE_GEOCODING_API_KEY": "google-two"}
+        store.refresh()
+        assert geocoding_service.get_provider_chain() is chain
+        [provider] = chain.providers
+        assert isinstance(provider, GoogleGeocodingProvider) and provider.api_key == "google-two"
@@ -0,0 +1,91 @@
+"""Unit tests for GeoIP dataset snapshot retention."""
+from datetime import datetime, timezone
+
+import pytest
+
+from src.services.snapshot_service import DatasetSnapshotStore
+from tests.mmdb_writer import write_mmdb
+
+DAY = 86400
+JAN_2024 = 1704067200  # 2024-01-01T00:00:00Z
+
+
+def _dataset(tmp_path, city, build_epoch):
+    return write_mmdb(
+        tmp_path / f"{city}.mmdb",
+        [("81.2.69.128/26", {"city": {"names": {"en": city}}})],
+        build_epoch=build_epoch,
+    )
+
+
+@pytest.fixture
+def store(tmp_path):
+    """Store holding builds from 2024-01-01, 2024-02-01 and 2024-03-01."""
+    store = DatasetSnapshotStore(str(tmp_path / "snapshots"), retention_days=100000)
+    for city, days in (("January", 0), ("February", 31), ("March", 60)):
+        store.retain(_dataset(tmp_path, city, JAN_2024 + days * DAY))
+    return store
+
+
+class TestRetain:
+    """Test keeping dataset builds."""
+
+    def test_snapshots_named_by_build(self, store):
+        """Snapshots should be listed oldest first by build time."""
+        snapshots = store.snapshots()
+        assert [s.build_epoch for s in snapshots] == [
+            JAN_2024, JAN_2024 + 31 * DAY, JAN_2024 + 60 * DAY
+        ]
+        assert snapshots[0].built_at == datetime(2024, 1, 1, tzinfo=timezone.utc)
+
+    def test_retain_is_idempotent(self, store, tmp_path):
+        """Retaining the same build twice should keep one snapshot."""
+        store.retain(_dataset(tmp_path, "January", JAN_2024))
+        assert len(store.snapshots()) == 3
+
+    def test_invalid_database_rejected(self, store, tmp_path):
+        """Files that are not MMDB databases should not be retained."""
+        path = tmp_path / "junk.mmdb"
+        path.write_bytes(b"not a database")
+        with pytest.raises(ValueError):
+            store.retain(str(path))
+
+
+class TestAsOf:
+    """Test selecting the build active at an instant."""
+
+    def test_newest_build_at_or_before(self, store):
+        """The newest build made at or before as_of should answer."""
+        reader = store.reader_for(datetime(2024, 2, 15, tzinfo=timezone.utc))
+        assert reader.lookup("81.2.69.142").city_name == "February"
+
+    def test_exact_build_time_and_naive_utc(self, store):
+        """A build should answer from its own build time; naive times are UTC."""
+        reader = store.reader_for(datetime(2024, 3, 1))
+        assert reader.lookup("81.2.69.142").city_name == "March"
+
+    def test_readers_cached(self, store):
+        """Repeated queries against one build should share a reader."""
+        first = store.reader_for(datetime(2024, 1, 10))
+        assert store.reader_for(datetime(2024, 1, 20)) is first
+
+    def test_older_than_retained_is_lookup_error(self, store):
+        """Instants before every retained build should raise LookupError."""
+        with pytest.raises(LookupError, match="2023-12-31"):
+            store.snapshot_for(datetime(2023, 12, 31))
+
+
+class TestPrune:
+    """Test the retention window."""
+
+    def test_keeps_build_active_at_window_start(self, store):
+        """Expired builds should go, except the one active when the window opens."""
+        store.retention_days = 10
+        # Window opens 2024-02-20: the February build was active then
+        removed = store.prune(now=JAN_2024 + 60 * DAY)
+        assert [s.build_epoch for s in removed] == [JAN_2024]
+        assert [s.build_epoch for s in store.snapshots()] == [
+            JAN_2024 + 31 * DAY, JAN_2024 + 60 * DAY
+        ]
+        reader = store.reader_for(datetime(2024, 2, 20))
+        assert reader.lookup("81.2.69.142").city_name == "February"
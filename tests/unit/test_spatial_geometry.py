@@ -0,0 +1,177 @@
+"""Unit tests for planar geometry and the R-tree index."""
+import pytest
+
+from src.spatial.geometry import (
+    BoundingBox,
+    MultiPolygon,
+    Polygon,
+    check_lonlat,
+    geojson_bbox,
+    geometry_from_geojson,
+    point_in_ring,
+    ring_area,
+)
+from src.spatial.rtree import RTree
+
+SQUARE = [(0, 0), (10, 0), (10, 10), (0, 10), (0, 0)]
+HOLE = [(4, 4), (6, 4), (6, 6), (4, 6), (4, 4)]
+
+
+class TestPointInPolygon:
+    """Test ray-casting containment."""
+
+    def test_point_inside_ring(self):
+        """Points inside the ring should be contained."""
+        assert point_in_ring(5, 5, SQUARE) is True
+        assert point_in_ring(15, 5, SQUARE) is False
+
+    def test_concave_polygon(self):
+        """Points in the notch of a concave polygon should be outside."""
+        u_shape = Polygon([(0, 0), (9, 0), (9, 9), (6, 9), (6, 3), (3, 3), (3, 9), (0, 9)])
+        assert u_shape.contains(1, 8) is True
+        assert u_shape.contains(4.5, 6) is False
+        assert u_shape.contains(7, 8) is True
+
+    def test_hole_excluded(self):
+        """Points inside a hole should not be contained."""
+        polygon = Polygon(SQUARE, [HOLE])
+        assert polygon.contains(5, 5) is False
+        assert polygon.contains(2, 2) is True
+        assert polygon.area == pytest.approx(96)
+
+    def test_multipolygon(self):
+        """Any member polygon should contain the point."""
+        multi = MultiPolygon([
+            Polygon(SQUARE),
+            Polygon([(20, 20), (30, 20), (30, 30), (20, 30)]),
+        ])
+        assert multi.contains(25, 25) is True
+        assert multi.contains(15, 15) is False
+        assert multi.bbox == BoundingBox(0, 0, 30, 30)
+
+    def test_degenerate_ring_rejected(self):
+        """Rings with fewer than three distinct vertices should be rejected."""
+        with pytest.raises(ValueError, match="three distinct"):
+            Polygon([(0, 0), (1, 1), (0, 0)])
+
+
+class TestCentroid:
+    """Test planar centroids."""
+
+    def test_square(self):
+        """A square's centroid should be its center, whatever the winding."""
+        assert Polygon(SQUARE).centroid == pytest.approx((5, 5))
+        assert Polygon(list(reversed(SQUARE))).centroid == pytest.approx((5, 5))
+
+    def test_hole_shifts_centroid(self):
+        """An off-center hole should move the centroid away from it."""
+        polygon = Polygon(SQUARE, [[(6, 4), (9, 4), (9, 6), (6, 6)]])
+        x, y = polygon.centroid
+        assert x < 5
+        assert y == pytest.approx(5)
+
+    def test_multipolygon_weighted_by_area(self):
+        """Larger members should pull the centroid toward them."""
+        multi = MultiPolygon([Polygon(SQUARE), Polygon([(20, 0), (22, 0), (22, 2), (20, 2)])])
+        x, y = multi.centroid
+        assert x == pytest.approx((100 * 5 + 4 * 21) / 104)
+        assert y == pytest.approx((100 * 5 + 4 * 1) / 104)
+
+
+class TestGeoJSON:
+    """Test GeoJSON geometry parsing."""
+
+    def test_polygon_round_trip(self):
+        """Polygon geometry should parse and serialize with closed rings."""
+        geometry = {"type": "Polygon", "coordinates": [SQUARE, HOLE]}
+        polygon = geometry_from_geojson(geometry)
+        assert isinstance(polygon, Polygon)
+        assert polygon.to_geojson()["coordinates"][0][0] == [0.0, 0.0]
+        assert polygon.to_geojson()["coordinates"][0][-1] == [0.0, 0.0]
+        assert len(polygon.holes) == 1
+
+    def test_multipolygon(self):
+        """MultiPolygon geometry should parse to MultiPolygon."""
+        geometry = {"type": "MultiPolygon", "coordinates": [[SQUARE], [HOLE]]}
+        multi = geometry_from_geojson(geometry)
+        assert isinstance(multi, MultiPolygon)
+        assert len(multi.polygons) == 2
+
+    def test_unsupported_type(self):
+        """Non-areal geometries should be rejected."""
+        with pytest.raises(ValueError, match="Unsupported geometry type"):
+            geometry_from_geojson({"type": "Point", "coordinates": [1, 2]})
+
+    def test_malformed_coordinates(self):
+        """Missing coordinates should raise ValueError."""
+        with pytest.raises(ValueError, match="Malformed"):
+            geometry_from_geojson({"type": "Polygon", "coordinates": None})
+
+    def test_bbox_without_parsing(self):
+        """The bbox should cover every exterior ring of a MultiPolygon."""
+        geometry = {
+            "type": "MultiPolygon",
+            "coordinates": [[SQUARE, HOLE], [[(20, 20), (30, 20), (30, 30)]]],
+        }
+        assert geojson_bbox(geometry) == BoundingBox(0, 0, 30, 30)
+
+    def test_bbox_unsupported_type(self):
+        """Non-areal geometries should be rejected."""
+        with pytest.raises(ValueError, match="Unsupported geometry type"):
+            geojson_bbox({"type": "Point", "coordinates": [1, 2]})
+
+
+class TestOrientation:
+    """Test RFC 7946 ring winding and coordinate checks."""
+
+    def test_rewinds_rings(self):
+        """Clockwise exteriors and counterclockwise holes should be reversed."""
+        polygon = Polygon(list(reversed(SQUARE)), [HOLE]).oriented()
+        assert ring_area(polygon.exterior) > 0
+        assert ring_area(polygon.holes[0]) < 0
+
+    def test_keeps_wound_rings(self):
+        """Rings already wound per RFC 7946 should be unchanged."""
+        polygon = Polygon(SQUARE, [list(reversed(HOLE))])
+        assert polygon.oriented().to_geojson() == polygon.to_geojson()
+
+    def test_multipolygon(self):
+        """Every member should be rewound."""
+        multi = MultiPolygon([Polygon(list(reversed(SQUARE))), Polygon(HOLE)]).oriented()
+        assert all(ring_area(p.exterior) > 0 for p in multi.polygons)
+
+    def test_check_lonlat(self):
+        """Vertices outside the longitude/latitude range should be rejected."""
+        check_lonlat(Polygon(SQUARE))
+        with pytest.raises(ValueError, match="not a WGS84"):
+            check_lonlat(Polygon([(0, 0), (500000, 0), (500000, 100)]))
+
+
+class TestRTree:
+    """Test R-tree queries."""
+
+    def test_point_query_matches_brute_force(self):
+        """Point queries should return exactly the boxes containing the point."""
+        entries = [
+            (BoundingBox(x, y, x + 1.5, y + 1.5), (x, y))
+            for x in range(0, 40, 2)
+            for y in range(0, 40, 2)
+        ]
+        tree = RTree(entries, node_capacity=4)
+        assert len(tree) == 400
+        for lon, lat in [(3.2, 5.1), (0, 0), (39.4, 39.4), (1.7, 1.7), (100, 100)]:
+            expected = {item for bbox, item in entries if bbox.contains(lon, lat)}
+            assert set(tree.query_point(lon, lat)) == expected
+
+    def test_bbox_query(self):
+        """Box queries should return intersecting items."""
+        tree = RTree([
+            (BoundingBox(0, 0, 1, 1), "a"),
+            (BoundingBox(5, 5, 6, 6), "b"),
+            (BoundingBox(-10, -10, -9, -9), "c"),
+        ])
+        assert sorted(tree.query_bbox(BoundingBox(0.5, 0.5, 5.5, 5.5))) == ["a", "b"]
+
+    def test_empty_tree(self):
+        """An empty tree should return no results."""
+        assert RTree([]).query_point(0, 0) == []
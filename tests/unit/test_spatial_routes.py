@@ -0,0 +1,269 @@
+"""Route tests for the spatial utility and geohash query APIs."""
+from datetime import datetime
+
+import pytest
+
+from src.models.database_models import Detection
+from src.services.auth_service import TokenVerifier
+from src.spatial import geohash, h3index
+
+
+def _detection(detection_id, latitude, longitude, timestamp, tenant_id=None):
+    return Detection(
+        tenant_id=tenant_id,
+        detection_id=detection_id, source="test", camera_id="cam-1",
+        object_class="vehicle", ai_confidence=0.9, timestamp=timestamp,
+        pixel_x=0, pixel_y=0, camera_lat=latitude, camera_lon=longitude,
+        camera_elevation=0.0, camera_heading=0.0, camera_pitch=0.0, camera_roll=0.0,
+        focal_length=4.0, sensor_width_mm=6.0, sensor_height_mm=4.5,
+        image_width=1920, image_height=1080,
+        calculated_lat=latitude, calculated_lon=longitude,
+        confidence_value=0.8, confidence_flag="GREEN", uncertainty_radius_meters=5.0,
+        geohash=geohash.encode(latitude, longitude, geohash.MAX_PRECISION),
+    )
+
+
+@pytest.fixture
+def fake_h3(monkeypatch):
+    """Bucket points by rounded coordinate instead of real H3 cells."""
+    monkeypatch.setattr(
+        h3index, "cells_for_points",
+        lambda points, resolution: [f"{round(lat)}:{round(lon)}" for lat, lon in points],
+    )
+    monkeypatch.setattr(
+        h3index, "cell_to_latlng", lambda cell: tuple(float(v) for v in cell.split(":"))
+    )
+
+
+class TestGeohashRoutes:
+    """Test GET /api/v1/geohash/encode and /api/v1/geohash/{value}."""
+
+    def test_encode(self, test_client):
+        """Should encode a coordinate rather than decode the literal "encode"."""
+        response = test_client.get(
+            "/api/v1/geohash/encode", params={"lat": 57.64911, "lon": 10.40744, "precision": 11}
+        )
+        assert response.status_code == 200
+        assert response.json()["geohash"] == "u4pruydqqvj"
+
+    def test_encode_invalid_precision_is_400(self, test_client):
+        """Should reject precisions outside 1-12."""
+        response = test_client.get(
+            "/api/v1/geohash/encode", params={"lat": 0.0, "lon": 0.0, "precision": 13}
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_decode(self, test_client):
+        """Should decode a geohash to its cell and neighbors."""
+        response = test_client.get("/api/v1/geohash/u4pruydqqvj")
+        assert response.status_code == 200
+        body = response.json()
+        assert abs(body["latitude"] - 57.64911) < 1e-5
+        assert abs(body["longitude"] - 10.40744) < 1e-5
+        assert len(body["neighbors"]) == 8
+
+    def test_decode_invalid_is_400(self, test_client):
+        """Should reject characters outside the geohash alphabet."""
+        response = test_client.get("/api/v1/geohash/u4pa")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+
+class TestDetectionsByGeohashRoute:
+    """Test GET /api/v1/detections?geohash=..."""
+
+    def test_prefix_query(self, db_client, db_session):
+        """Should return only detections inside the geohash cell, newest first."""
+        db_session.add_all([
+            _detection("det-london-1", 51.5014, -0.1419, datetime(2026, 3, 1, 12, 0)),
+            _detection("det-london-2", 51.5010, -0.1410, datetime(2026, 3, 1, 13, 0)),
+            _detection("det-paris", 48.8584, 2.2945, datetime(2026, 3, 1, 14, 0)),
+        ])
+        db_session.commit()
+
+        response = db_client.get("/api/v1/detections", params={"geohash": "gcpuu"})
+        assert response.status_code == 200
+        assert [d["detection_id"] for d in response.json()] == ["det-london-2", "det-london-1"]
+
+    def test_scoped_to_tenant(self, db_client, db_session, monkeypatch):
+        """Callers should only list their tenant's detections, anonymous callers anonymous ones."""
+        verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+        monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+        db_session.add_all([
+            _detection("det-acme", 51.5014, -0.1419, datetime(2026, 3, 1, 12, 0), tenant_id="acme"),
+            _detection("det-globex", 51.5010, -0.1410, datetime(2026, 3, 1, 13, 0), tenant_id="globex"),
+            _detection("det-anonymous", 51.5012, -0.1415, datetime(2026, 3, 1, 14, 0)),
+        ])
+        db_session.commit()
+
+        def listed(headers=None):
+            response = db_client.get("/api/v1/detections", params={"geohash": "gcpuu"}, headers=headers)
+            assert response.status_code == 200
+            return [d["detection_id"] for d in response.json()]
+
+        assert listed({"Authorization": f"Bearer {verifier.issue('user-1', 'acme')}"}) == ["det-acme"]
+        assert listed() == ["det-anonymous"]
+        assert db_client.get(
+            "/api/v1/detections", params={"geohash": "gcpuu"}, headers={"Authorization": "Bearer not-a-jwt"}
+        ).status_code == 401
+
+    def test_invalid_geohash_is_400(self, db_client):
+        """Should reject an invalid geohash prefix."""
+        response = db_client.get("/api/v1/detections", params={"geohash": "gcpua"})
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+
+class TestH3Routes:
+    """Test GET /api/v1/h3/cell and /api/v1/h3/heatmap."""
+
+    def test_cell(self, test_client):
+        """Should return the cell containing the coordinate."""
+        pytest.importorskip("h3")
+        response = test_client.get(
+            "/api/v1/h3/cell", params={"lat": 51.5074, "lon": -0.1278, "resolution": 9}
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert body["resolution"] == 9
+        assert len(body["boundary"]) == 6
+
+    def test_cell_boundary_in_crs(self, test_client):
+        """Should return the boundary in the requested CRS."""
+        pytest.importorskip("h3")
+        response = test_client.get(
+            "/api/v1/h3/cell",
+            params={"lat": 51.5074, "lon": -0.1278, "resolution": 9, "crs": "EPSG:3857"},
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert body["crs"] == "EPSG:3857"
+        assert all(abs(y - 6711000) < 2000 for _, y in body["boundary"])
+
+    def test_cell_as_geojson(self, test_client):
+        """Should return the cell as a closed hexagon Feature."""
+        pytest.importorskip("h3")
+        response = test_client.get(
+            "/api/v1/h3/cell",
+            params={"lat": 51.5074, "lon": -0.1278, "resolution": 9, "format": "geojson"},
+        )
+        assert response.status_code == 200
+        assert response.headers["content-type"] == "application/geo+json"
+        body = response.json()
+        ring = body["geometry"]["coordinates"][0]
+        assert len(ring) == 7 and ring[0] == ring[-1]
+        assert body["properties"]["resolution"] == 9
+        assert body["bbox"][0] < -0.1278 < body["bbox"][2]
+
+    def test_cell_geojson_in_other_crs_is_400(self, test_client):
+        """GeoJSON output cannot use another CRS."""
+        response = test_client.get(
+            "/api/v1/h3/cell",
+            params={"lat": 51.5, "lon": -0.12, "format": "geojson", "crs": "EPSG:3857"},
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_cell_invalid_resolution_is_400(self, test_client):
+        """Should reject resolutions outside 0-15 whether or not h3 is installed."""
+        response = test_client.get(
+            "/api/v1/h3/cell", params={"lat": 51.5, "lon": -0.12, "resolution": 16}
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_cell_without_h3_is_503(self, test_client, monkeypatch):
+        """Should return 503 when the h3 extra is not installed."""
+        monkeypatch.setattr(h3index, "h3", None)
+        response = test_client.get("/api/v1/h3/cell", params={"lat": 51.5, "lon": -0.12})
+        assert response.status_code == 503
+        assert response.json()["detail"]["error_code"] == "E003"
+
+    def test_heatmap(self, db_client, db_session, fake_h3):
+        """Should count stored detections per cell."""
+        db_session.add_all([
+            _detection("det-1", 10.1, 20.1, datetime(2026, 3, 1, 12, 0)),
+            _detection("det-2", 9.9, 19.9, datetime(2026, 3, 1, 12, 1)),
+            _detection("det-3", 30.0, 40.0, datetime(2026, 3, 1, 12, 2)),
+        ])
+        db_session.commit()
+
+        response = db_client.get("/api/v1/h3/heatmap", params={"resolution": 7})
+        assert response.status_code == 200
+        body = response.json()
+        assert body["total"] == 3
+        assert body["truncated"] is False
+        assert [(c["cell"], c["count"]) for c in body["cells"]] == [("10:20", 2), ("30:40", 1)]
+
+    def test_heatmap_invalid_resolution_is_400(self, db_client):
+        """Should reject an invalid heatmap resolution."""
+        response = db_client.get("/api/v1/h3/heatmap", params={"resolution": -1})
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+
+class TestDistanceRoute:
+    """Test GET /api/v1/distance."""
+
+    LANDS_END_TO_JOHN_O_GROATS = {
+        "from_lat": 50.06632, "from_lon": -5.71475, "to_lat": 58.64402, "to_lon": -3.07009,
+    }
+
+    def test_vincenty_distance(self, test_client):
+        """Should return the ellipsoidal distance in the requested unit."""
+        response = test_client.get(
+            "/api/v1/distance", params={**self.LANDS_END_TO_JOHN_O_GROATS, "unit": "km"}
+        )
+        assert response.status_code == 200
+        body = response.json()
+        assert body["method"] == "vincenty"
+        assert body["unit"] == "km"
+        assert abs(body["distance"] - 969.954) < 0.001
+
+    @pytest.mark.parametrize("params", [
+        {"method": "manhattan"},
+        {"unit": "furlong"},
+        {"to_lat": 91.0},
+    ])
+    def test_invalid_input_is_400(self, test_client, params):
+        """Should reject unknown methods, unknown units and invalid coordinates."""
+        response = test_client.get(
+            "/api/v1/distance", params={**self.LANDS_END_TO_JOHN_O_GROATS, **params}
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+
+class TestCrsTransformRoute:
+    """Test GET /api/v1/crs/transform."""
+
+    def test_wgs84_to_utm_by_default(self, test_client):
+        """Should pick the UTM zone containing the coordinate when no target is given."""
+        response = test_client.get("/api/v1/crs/transform", params={"x": 151.2093, "y": -33.8688})
+        assert response.status_code == 200
+        body = response.json()
+        assert body["crs"] == "EPSG:32756"
+        assert body["x"] == pytest.approx(334368.63, abs=0.01)
+        assert body["y"] == pytest.approx(6250948.35, abs=0.01)
+
+    def test_web_mercator_to_wgs84(self, test_client):
+        """Should convert projected coordinates back to longitude/latitude."""
+        response = test_client.get("/api/v1/crs/transform", params={
+            "x": 20037508.342789244, "y": 0, "from_crs": "EPSG:3857", "to_crs": "EPSG:4326",
+        })
+        assert response.status_code == 200
+        body = response.json()
+        assert body["x"] == pytest.approx(180.0)
+        assert body["latitude"] == pytest.approx(0.0)
+
+    @pytest.mark.parametrize("params", [
+        {"x": 0, "y": 0, "from_crs": "EPSG:27700"},
+        {"x": 0, "y": 89, "to_crs": "EPSG:3857"},
+        {"x": 0, "y": 88},
+    ])
+    def test_invalid_input_is_400(self, test_client, params):
+        """Should reject unsupported systems and coordinates outside a projection."""
+        response = test_client.get("/api/v1/crs/transform", params=params)
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
@@ -0,0 +1,107 @@
+"""Unit tests for ISO 3166-2 subdivision hierarchy resolution."""
+import pytest
+
+from src.services.ip_lookup_service import IpLookupResult, normalize_ip
+from src.services.mmdb_service import GeoIPRecord
+from src.services.subdivision_service import (
+    Subdivision,
+    SubdivisionEnricher,
+    SubdivisionHierarchy,
+    iso_code,
+)
+
+
+CSV = """code,name,type,parent
+GB-ENG,England,country,
+GB-KEN,Kent,two-tier county,ENG
+GB-TWH,Tunbridge Wells,district,GB-KEN
+US-CA,California,state,
+,Nameless,state,
+"""
+
+
+@pytest.fixture
+def hierarchy(tmp_path):
+    """Hierarchy loaded from a small CSV."""
+    path = tmp_path / "iso3166-2.csv"
+    path.write_text(CSV)
+    return SubdivisionHierarchy.from_csv(str(path))
+
+
+def _record(country, subdivisions):
+    return GeoIPRecord(
+        ip_address="81.2.69.142",
+        network="81.2.69.128/26",
+        country_iso_code=country,
+        subdivisions=subdivisions,
+    )
+
+
+class TestSubdivisionHierarchy:
+    """Test dataset loading and chain resolution."""
+
+    def test_iso_code(self):
+        """Bare codes should be prefixed with the country."""
+        assert iso_code("gb", "eng") == "GB-ENG"
+        assert iso_code("GB", "GB-ENG") == "GB-ENG"
+
+    def test_load_csv(self, hierarchy):
+        """Rows should load with bare parents qualified; empty codes skipped."""
+        assert len(hierarchy) == 4
+        assert hierarchy.get("GB-KEN").parent_code == "GB-ENG"
+        assert hierarchy.get("gb-twh").type == "district"
+
+    def test_missing_columns(self, tmp_path):
+        """A CSV without code and name columns should be rejected."""
+        path = tmp_path / "bad.csv"
+        path.write_text("id,label\n1,x\n")
+        with pytest.raises(ValueError, match="code and name"):
+            SubdivisionHierarchy.from_csv(str(path))
+
+    def test_chain(self, hierarchy):
+        """A chain should run from the top-level subdivision down."""
+        assert [s.code for s in hierarchy.chain("GB-TWH")] == ["GB-ENG", "GB-KEN", "GB-TWH"]
+        assert hierarchy.chain("GB-XXX") == []
+
+    def test_cycle_terminates(self):
+        """Parent cycles should not loop forever."""
+        hierarchy = SubdivisionHierarchy([
+            Subdivision("XX-A", "A", parent_code="XX-B"),
+            Subdivision("XX-B", "B", parent_code="XX-A"),
+        ])
+        assert [s.code for s in hierarchy.chain("XX-A")] == ["XX-B", "XX-A"]
+
+    def test_resolve_from_deepest_record_code(self, hierarchy):
+        """The most specific known record code should supply the chain."""
+        chain = hierarchy.resolve("GB", [
+            {"iso_code": "ENG", "name": "England"},
+            {"iso_code": "KEN", "name": "Kent"},
+        ])
+        assert [s.code for s in chain] == ["GB-ENG", "GB-KEN"]
+
+    def test_unknown_record_codes_kept(self, hierarchy):
+        """Record subdivisions missing from the dataset should be kept."""
+        chain = hierarchy.resolve("FR", [{"iso_code": "IDF", "name": "Île-de-France"}])
+        assert [(s.code, s.name, s.type) for s in chain] == [("FR-IDF", "Île-de-France", None)]
+
+
+class TestSubdivisionEnricher:
+    """Test the hierarchy enrichment block."""
+
+    def test_block(self, hierarchy):
+        """The block should number levels from the top."""
+        result = IpLookupResult(
+            normalized=normalize_ip("81.2.69.142"),
+            record=_record("GB", [{"iso_code": "KEN", "name": "Kent"}]),
+        )
+        assert SubdivisionEnricher(hierarchy).enrich(result) == [
+            {"level": 1, "iso_code": "GB-ENG", "name": "England", "type": "country"},
+            {"level": 2, "iso_code": "GB-KEN", "name": "Kent", "type": "two-tier county"},
+        ]
+
+    def test_no_subdivisions(self, hierarchy):
+        """Records without subdivisions should produce no block."""
+        result = IpLookupResult(
+            normalized=normalize_ip("81.2.69.142"), record=_record("GB", [])
+        )
+        assert SubdivisionEnricher(hierarchy).enrich(result) is None
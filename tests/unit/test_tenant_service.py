@@ -0,0 +1,197 @@
+"""Unit tests for tenants and their API keys."""
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.models.database_models import TenantApiKey
+from src.services.ip_allowlist_service import SourceNetworkError
+from src.services.tenant_service import KEY_PREFIX, TenantService, hash_key, key_active, rotation_due
+
+
+@pytest.fixture
+def tenant_service(db_session):
+    """Tenant service over the test database."""
+    return TenantService(db_session)
+
+
+class TestTenants:
+    """Test tenant creation."""
+
+    def test_create_issues_key(self, tenant_service, db_session):
+        """A new tenant should get one API key, stored only as a hash."""
+        tenant, issued = tenant_service.create_tenant("acme", "Acme Logistics")
+
+        assert (tenant.tenant_id, tenant.name) == ("acme", "Acme Logistics")
+        assert issued.api_key.startswith(KEY_PREFIX)
+        stored = db_session.query(TenantApiKey).one()
+        assert stored.key_hash == hash_key(issued.api_key)
+        assert issued.api_key not in (stored.key_hash, stored.prefix)
+        assert issued.api_key.startswith(stored.prefix)
+
+    def test_invalid_id_rejected(self, tenant_service):
+        """Tenant IDs should be lowercase slugs."""
+        with pytest.raises(ValueError, match="Invalid tenant ID"):
+            tenant_service.create_tenant("Acme Corp", "Acme")
+
+    def test_duplicate_rejected(self, tenant_service):
+        """A tenant ID should only be created once."""
+        tenant_service.create_tenant("acme", "Acme")
+        with pytest.raises(ValueError, match="already exists"):
+            tenant_service.create_tenant("acme", "Acme again")
+
+    def test_list_and_get(self, tenant_service):
+        """Tenants should be listed oldest first."""
+        tenant_service.create_tenant("acme", "Acme")
+        tenant_service.create_tenant("globex", "Globex")
+        assert [t.tenant_id for t in tenant_service.list_tenants()] == ["acme", "globex"]
+        assert tenant_service.get_tenant("globex").name == "Globex"
+        assert tenant_service.get_tenant("missing") is None
+
+
+class TestApiKeys:
+    """Test key authentication and rotation."""
+
+    def test_authenticate(self, tenant_service):
+        """The issued key should authenticate; others should not."""
+        _, issued = tenant_service.create_tenant("acme", "Acme")
+        assert tenant_service.authenticate(issued.api_key).tenant_id == "acme"
+        assert tenant_service.authenticate(issued.api_key + "x") is None
+        assert tenant_service.authenticate("not-a-key") is None
+
+    def test_rotation_grace_period(self, tenant_service):
+        """The previous key should keep working until the grace period ends."""
+        _, first = tenant_service.create_tenant("acme", "Acme")
+        second = tenant_service.rotate_key("acme", grace_seconds=3600)
+
+        assert tenant_service.authenticate(first.api_key) is not None
+        assert tenant_service.authenticate(second.api_key) is not None
+        old = tenant_service.authenticate(first.api_key)
+        assert old.expires_at > datetime.utcnow() + timedelta(minutes=59)
+        assert not key_active(old, datetime.utcnow() + timedelta(hours=2))
+        assert second.key.expires_at is None
+
+    def test_rotation_without_grace_revokes(self, tenant_service):
+        """A zero grace period should revoke the previous keys at once."""
+        _, first = tenant_service.create_tenant("acme", "Acme")
+        second = tenant_service.rotate_key("acme", grace_seconds=0)
+
+        assert tenant_service.authenticate(first.api_key) is None
+        assert tenant_service.authenticate(second.api_key) is not None
+        keys = tenant_service.list_keys("acme")
+        assert [k.revoked_at is not None for k in keys] == [True, False]
+
+    def test_rotation_keeps_earlier_deadline(self, tenant_service):
+        """A later rotation should not extend a key's grace period."""
+        _, first = tenant_service.create_tenant("acme", "Acme")
+        tenant_service.rotate_key("acme", grace_seconds=60)
+        deadline = tenant_service.list_keys("acme")[0].expires_at
+        tenant_service.rotate_key("acme", grace_seconds=3600)
+        assert tenant_service.list_keys("acme")[0].expires_at == deadline
+
+    def test_rotate_unknown_tenant(self, tenant_service):
+        """Rotating the key of a missing tenant should raise LookupError."""
+        with pytest.raises(LookupError):
+            tenant_service.rotate_key("missing", grace_seconds=0)
+
+    def test_revoke_key(self, tenant_service):
+        """A revoked key should stop working at once, the tenant's others not."""
+        _, first = tenant_service.create_tenant("acme", "Acme")
+        second = tenant_service.rotate_key("acme", grace_seconds=3600)
+
+        revoked = tenant_service.revoke_key("acme", first.key.key_id)
+        assert revoked.revoked_at is not None
+        assert tenant_service.authenticate(first.api_key) is None
+        assert tenant_service.authenticate(second.api_key) is not None
+        assert tenant_service.revoke_key("acme", first.key.key_id).revoked_at == revoked.revoked_at
+        with pytest.raises(LookupError):
+            tenant_service.revoke_key("globex", second.key.key_id)
+
+    def test_last_used(self, tenant_service):
+        """Authenticating should record the key's last use, at minute resolution."""
+        _, issued = tenant_service.create_tenant("acme", "Acme")
+        assert issued.key.last_used_at is None
+        used = tenant_service.authenticate(issued.api_key).last_used_at
+        assert used is not None
+        assert tenant_service.authenticate(issued.api_key).last_used_at == used
+
+    def test_scheduled_rotation(self, db_session):
+        """Keys should fall due at their maximum age and expire a grace period later."""
+        service = TenantService(db_session, key_max_age=timedelta(days=90), key_grace=timedelta(days=1))
+        _, issued = service.create_tenant("acme", "Acme")
+        key = issued.key
+        assert key.expires_at - key.rotate_after == timedelta(days=1)
+
+        due = key.rotate_after + timedelta(hours=1)
+        assert rotation_due(key, due) and key_active(key, due)
+        assert not key_active(key, key.expires_at)
+
+        key.rotate_after = datetime.utcnow() - timedelta(hours=1)
+        db_session.commit()
+        assert service.find_keys(due_only=True) == [key]
+        service.rotate_key("acme", grace_seconds=3600)
+        assert service.find_keys(due_only=True) == []
+
+    def test_find_by_prefix(self, tenant_service):
+        """Keys should be found by their leading characters."""
+        _, acme = tenant_service.create_tenant("acme", "Acme")
+        tenant_service.create_tenant("globex", "Globex")
+        assert [k.tenant_id for k in tenant_service.find_keys(prefix=acme.api_key)] == ["acme"]
+        assert len(tenant_service.find_keys(prefix=KEY_PREFIX + acme.api_key[4])) >= 1
+        assert len(tenant_service.find_keys()) == 2
+        with pytest.raises(ValueError):
+            tenant_service.find_keys(prefix=KEY_PREFIX)
+
+    def test_key_scopes(self, db_session):
+        """Keys should carry scopes, kept across rotations unless replaced."""
+        service = TenantService(db_session, default_scopes=["lookup:read"])
+        _, first = service.create_tenant("acme", "Acme")
+        assert first.key.scopes == "lookup:read"
+        assert service.rotate_key("acme", grace_seconds=0).key.scopes == "lookup:read"
+        rotated = service.rotate_key("acme", grace_seconds=0, scopes=["geofence:*", "lookup:read"])
+        assert rotated.key.scopes == "geofence:* lookup:read"
+        with pytest.raises(ValueError, match="Invalid scope"):
+            service.create_tenant("globex", "Globex", scopes=["not a scope"])
+
+    def test_allowed_cidrs(self, tenant_service):
+        """Restricted keys should only authenticate from their networks, kept across rotations."""
+        _, issued = tenant_service.create_tenant("acme", "Acme", allowed_cidrs=["10.0.0.0/8"])
+        assert tenant_service.authenticate(issued.api_key, "10.9.8.7") is not None
+        with pytest.raises(SourceNetworkError):
+            tenant_service.authenticate(issued.api_key, "192.0.2.1")
+        with pytest.raises(SourceNetworkError):
+            tenant_service.authenticate(issued.api_key)
+
+        rotated = tenant_service.rotate_key("acme", grace_seconds=0)
+        assert rotated.key.allowed_cidrs == "10.0.0.0/8"
+        tenant_service.set_allowed_cidrs("acme", rotated.key.key_id, [])
+        assert tenant_service.authenticate(rotated.api_key, "192.0.2.1") is not None
+        with pytest.raises(ValueError, match="Invalid network"):
+            tenant_service.set_allowed_cidrs("acme", rotated.key.key_id, ["intranet"])
+        with pytest.raises(LookupError):
+            tenant_service.set_allowed_cidrs("acme", "missing", [])
+
+    def test_privacy(self, tenant_service):
+        """Privacy mode should be kept across rotations and switchable per key."""
+        _, issued = tenant_service.create_tenant("acme", "Acme", privacy_precision=2)
+        assert issued.key.privacy_precision == 2
+
+        rotated = tenant_service.rotate_key("acme", grace_seconds=0)
+        assert rotated.key.privacy_precision == 2
+        assert tenant_service.set_privacy("acme", rotated.key.key_id, None).privacy_precision is None
+        with pytest.raises(ValueError, match="Privacy precision"):
+            tenant_service.set_privacy("acme", rotated.key.key_id, 7)
+        with pytest.raises(LookupError):
+            tenant_service.set_privacy("acme", "missing", 2)
+
+    def test_quota_plan(self, tenant_service):
+        """Quota plans should be kept across rotations and known plans only."""
+        _, issued = tenant_service.create_tenant("acme", "Acme", quota_plan="free")
+        assert issued.key.quota_plan == "free"
+
+        rotated = tenant_service.rotate_key("acme", grace_seconds=0)
+        assert rotated.key.quota_plan == "free"
+        assert tenant_service.set_quota_plan("acme", rotated.key.key_id, None).quota_plan is None
+        with pytest.raises(ValueError, match="Unknown quota plan"):
+            tenant_service.set_quota_plan("acme", rotated.key.key_id, "platinum")
+        with pytest.raises(LookupError):
+            tenant_service.set_quota_plan("acme", "missing", "free")
@@ -0,0 +1,38 @@
+"""Route tests for the vector tile API."""
+from src.services.geofence_service import GeofenceService
+from src.spatial.mvt import MEDIA_TYPE
+from tests.mvt_reader import read_tile
+
+SQUARE = {
+    "type": "Polygon",
+    "coordinates": [[[-0.13, 51.50], [-0.12, 51.50], [-0.12, 51.51], [-0.13, 51.51], [-0.13, 51.50]]],
+}
+
+
+class TestTileRoutes:
+    """Test GET /api/v1/tiles/{z}/{x}/{y}.mvt."""
+
+    def test_tile(self, db_client, db_session):
+        """Should serve the layers as a vector tile."""
+        GeofenceService(db_session).create_geofence("Depot", SQUARE)
+
+        response = db_client.get("/api/v1/tiles/10/511/340.mvt")
+        assert response.status_code == 200
+        assert response.headers["content-type"] == MEDIA_TYPE
+        assert "X-Heatmap-Truncated" not in response.headers
+        assert list(read_tile(response.content)) == ["geofences"]
+
+    def test_empty_tile(self, db_client):
+        """A tile with nothing in it should have an empty body."""
+        response = db_client.get("/api/v1/tiles/0/0/0.mvt?layers=detections")
+        assert response.status_code == 200
+        assert response.content == b""
+
+    def test_invalid_tile_is_400(self, db_client):
+        """Should reject tiles outside the grid and unknown layers."""
+        outside = db_client.get("/api/v1/tiles/2/4/0.mvt")
+        assert outside.status_code == 400
+        assert outside.json()["detail"]["error_code"] == "E002"
+
+        unknown = db_client.get("/api/v1/tiles/2/1/1.mvt?layers=geofences,roads")
+        assert unknown.status_code == 400
@@ -0,0 +1,83 @@
+"""Unit tests for geofence and detection vector tiles."""
+from datetime import datetime, timedelta
+
+import pytest
+
+from src.models.database_models import Detection
+from src.services.geofence_service import GeofenceService
+from src.services.tile_service import HEATMAP_GRID, TileService
+from src.spatial import mvt
+from tests.mvt_reader import read_tile
+
+# Tile 10/511/340 covers central London
+LONDON_TILE = (10, 511, 340)
+
+SQUARE = {
+    "type": "Polygon",
+    "coordinates": [[[-0.13, 51.50], [-0.12, 51.50], [-0.12, 51.51], [-0.13, 51.51], [-0.13, 51.50]]],
+}
+
+
+def _detection(index, latitude, longitude, timestamp, object_class="vehicle"):
+    return Detection(
+        detection_id=f"det-{index}", source="test", camera_id="cam-1",
+        object_class=object_class, ai_confidence=0.9, timestamp=timestamp,
+        pixel_x=0, pixel_y=0, camera_lat=latitude, camera_lon=longitude,
+        camera_elevation=0.0, camera_heading=0.0, camera_pitch=0.0, camera_roll=0.0,
+        focal_length=4.0, sensor_width_mm=6.0, sensor_height_mm=4.5,
+        image_width=1920, image_height=1080,
+        calculated_lat=latitude, calculated_lon=longitude,
+        confidence_value=0.8, confidence_flag="GREEN", uncertainty_radius_meters=5.0,
+    )
+
+
+@pytest.fixture
+def detections(db_session):
+    """Two detections at one spot in London, one elsewhere in it and one in Paris."""
+    start = datetime(2026, 3, 1, 12, 0)
+    points = [(51.505, -0.125), (51.5051, -0.1251), (51.52, -0.05), (48.8566, 2.3522)]
+    for i, (latitude, longitude) in enumerate(points):
+        db_session.add(_detection(i, latitude, longitude, start + timedelta(minutes=i)))
+    db_session.commit()
+
+
+class TestTileService:
+    """Test tile rendering."""
+
+    def test_geofence_layer(self, db_session):
+        """Should draw geofences crossing the tile with their ID and name."""
+        geofence = GeofenceService(db_session).create_geofence("Depot", SQUARE)
+
+        layers = read_tile(TileService(db_session).render(*LONDON_TILE, layers=["geofences"]).data)
+        assert list(layers) == ["geofences"]
+        (feature,) = layers["geofences"]["features"]
+        assert feature["properties"] == {"geofence_id": geofence.geofence_id, "name": "Depot"}
+        assert len(feature["geometry"]) == 1
+
+        elsewhere = TileService(db_session).render(10, 0, 0, layers=["geofences"])
+        assert elsewhere.data == b""
+
+    def test_detection_layer(self, db_session, detections):
+        """Should count the tile's detections per grid cell."""
+        tile = TileService(db_session, max_detections=100).render(*LONDON_TILE, layers=["detections"])
+        features = read_tile(tile.data)["detections"]["features"]
+        assert sorted(f["properties"]["count"] for f in features) == [1, 2]
+        assert tile.truncated is False
+
+        cell = mvt.EXTENT / HEATMAP_GRID
+        (dense,) = [f for f in features if f["properties"]["count"] == 2]
+        px, py = mvt.project(*LONDON_TILE, -0.125, 51.505)
+        (point,) = dense["geometry"][0]
+        assert int(point[0] // cell) == int(px // cell) and int(point[1] // cell) == int(py // cell)
+
+    def test_detection_limit(self, db_session, detections):
+        """Should count the most recent detections only and report truncation."""
+        tile = TileService(db_session, max_detections=1).render(*LONDON_TILE, layers=["detections"])
+        assert tile.truncated is True
+        features = read_tile(tile.data)["detections"]["features"]
+        assert [f["properties"]["count"] for f in features] == [1]
+
+    def test_unknown_layer(self, db_session):
+        """Should reject layers the tiles do not have."""
+        with pytest.raises(ValueError, match="Unknown tile layers: roads"):
+            TileService(db_session).render(0, 0, 0, layers=["roads"])
@@ -0,0 +1,126 @@
+"""Unit tests for coordinate to timezone resolution."""
+import json
+from datetime import datetime, timezone
+
+import pytest
+
+from src.services.timezone_service import (
+    TimezoneService,
+    TimezoneSource,
+    describe_timezone,
+    nautical_tzid,
+)
+from src.spatial.geometry import geometry_from_geojson
+
+
+def _box(min_lon, min_lat, max_lon, max_lat):
+    return {
+        "type": "Polygon",
+        "coordinates": [[
+            [min_lon, min_lat], [max_lon, min_lat], [max_lon, max_lat],
+            [min_lon, max_lat], [min_lon, min_lat],
+        ]],
+    }
+
+
+@pytest.fixture
+def service(tmp_path):
+    """Timezone service with London and Berlin zones."""
+    path = tmp_path / "timezones.geojson"
+    path.write_text(json.dumps({
+        "type": "FeatureCollection",
+        "features": [
+            {"type": "Feature", "properties": {"tzid": "Europe/London"},
+             "geometry": _box(-8, 50, 2, 59)},
+            {"type": "Feature", "properties": {"tzid": "Europe/Berlin"},
+             "geometry": _box(6, 47, 15, 55)},
+            {"type": "Feature", "properties": {}, "geometry": _box(20, 20, 21, 21)},
+            {"type": "Feature", "properties": {"tzid": "Mars/Olympus_Mons"},
+             "geometry": _box(30, 30, 31, 31)},
+        ],
+    }))
+    return TimezoneService.from_geojson(str(path))
+
+
+SUMMER = datetime(2026, 7, 1, 12, 0, tzinfo=timezone.utc)
+WINTER = datetime(2026, 1, 15, 12, 0, tzinfo=timezone.utc)
+
+
+class TestTimezoneService:
+    """Test timezone lookup from coordinates."""
+
+    def test_boundary_match_with_dst(self, service):
+        """London in summer should be BST (+01:00)."""
+        result = service.lookup(51.5, -0.12, SUMMER)
+        assert result.tzid == "Europe/London"
+        assert result.source == TimezoneSource.BOUNDARY
+        assert result.utc_offset == "+01:00"
+        assert result.is_dst is True
+        assert result.abbreviation == "BST"
+
+    def test_boundary_match_without_dst(self, service):
+        """Berlin in winter should be CET (+01:00, no DST)."""
+        result = service.lookup(52.52, 13.4, WINTER)
+        assert result.tzid == "Europe/Berlin"
+        assert result.utc_offset_seconds == 3600
+        assert result.is_dst is False
+
+    def test_features_without_tzid_ignored(self, service):
+        """Only features with a tzid should be loaded."""
+        assert service.zone_count == 2
+
+    def test_unknown_tzids_dropped(self, service):
+        """Zones unknown to the tz database should be dropped at load time."""
+        assert service.zone_count == 2
+        result = service.lookup(30.5, 30.5, SUMMER)
+        assert result.source == TimezoneSource.NAUTICAL
+        assert result.tzid == "Etc/GMT-2"
+
+    def test_unresolvable_zone_is_server_error(self):
+        """A zone the tz database cannot describe should raise RuntimeError."""
+        service = TimezoneService([("Mars/Olympus_Mons", geometry_from_geojson(_box(30, 30, 31, 31)))])
+        with pytest.raises(RuntimeError, match="Timezone data unavailable"):
+            service.lookup(30.5, 30.5)
+
+    def test_ocean_falls_back_to_nautical(self, service):
+        """Points outside every polygon should use nautical zones."""
+        result = service.lookup(0.0, -140.0, WINTER)
+        assert result.tzid == "Etc/GMT+9"
+        assert result.source == TimezoneSource.NAUTICAL
+        assert result.utc_offset == "-09:00"
+
+    def test_out_of_range(self, service):
+        """Out-of-range coordinates should raise ValueError."""
+        with pytest.raises(ValueError, match="out of range"):
+            service.lookup(95.0, 0.0)
+
+
+class TestTimezoneHelpers:
+    """Test nautical zones and offset formatting."""
+
+    @pytest.mark.parametrize("longitude,expected", [
+        (0.0, "Etc/GMT"),
+        (7.4, "Etc/GMT"),
+        (7.6, "Etc/GMT-1"),
+        (-97.0, "Etc/GMT+6"),
+        (180.0, "Etc/GMT-12"),
+        (-180.0, "Etc/GMT+12"),
+    ])
+    def test_nautical_tzid(self, longitude, expected):
+        """Longitudes should map to 15° nautical bands with POSIX signs."""
+        assert nautical_tzid(longitude) == expected
+
+    def test_half_hour_offset(self):
+        """Non-hour offsets should format minutes."""
+        result = describe_timezone("Asia/Kolkata", WINTER)
+        assert result.utc_offset == "+05:30"
+
+    def test_naive_datetime_treated_as_utc(self):
+        """Naive instants should be interpreted as UTC."""
+        result = describe_timezone("Europe/London", datetime(2026, 7, 1, 12, 0))
+        assert result.is_dst is True
+
+    def test_unknown_timezone(self):
+        """Unknown tzids should raise ValueError."""
+        with pytest.raises(ValueError, match="Unknown timezone"):
+            describe_timezone("Mars/Olympus_Mons")
@@ -0,0 +1,79 @@
+"""Unit tests for the revoked token denylist."""
+import pytest
+
+from src.services.token_denylist_service import InMemoryTokenDenylist, RedisTokenDenylist
+
+
+class FakeClock:
+    """Manually advanced monotonic clock."""
+
+    def __init__(self):
+        self.now = 1000.0
+
+    def __call__(self):
+        return self.now
+
+
+class FakeRedis:
+    """Just enough of a Redis client for expiring keys."""
+
+    def __init__(self):
+        self.values = {}
+
+    def set(self, key, value, ex=None):
+        self.values[key] = (value, ex)
+
+    def exists(self, key):
+        return int(key in self.values)
+
+
+class TestInMemoryTokenDenylist:
+    """Test the process-local denylist."""
+
+    def test_revoke_and_expire(self):
+        """Entries should be denied until their TTL ends."""
+        clock = FakeClock()
+        denylist = InMemoryTokenDenylist(clock=clock)
+        denylist.revoke("jti", "t1", 60)
+        assert denylist.is_revoked("jti", "t1")
+        assert not denylist.is_revoked("sid", "t1")
+
+        clock.now += 61
+        assert not denylist.is_revoked("jti", "t1")
+
+    def test_stale_entries_dropped(self):
+        """Expired entries should be purged on the next revocation."""
+        clock = FakeClock()
+        denylist = InMemoryTokenDenylist(clock=clock)
+        denylist.revoke("jti", "t1", 10)
+        clock.now += 11
+        denylist.revoke("jti", "t2", 10)
+        assert list(denylist._entries) == ["jti:t2"]
+
+
+class TestRedisTokenDenylist:
+    """Test the Redis key layout."""
+
+    def test_keys_expire_with_tokens(self):
+        """Each revoked ID should be one key with the token's remaining lifetime."""
+        client = FakeRedis()
+        denylist = RedisTokenDenylist(client, key_prefix="geo")
+        denylist.revoke("sid", "s-1", 0)
+        assert client.values == {"geo:sid:s-1": ("1", 1)}
+        assert denylist.is_revoked("sid", "s-1")
+        assert not denylist.is_revoked("jti", "s-1")
+
+    def test_from_url_requires_redis(self, monkeypatch):
+        """A Redis URL without the redis package should fail loudly."""
+        import builtins
+
+        real_import = builtins.__import__
+
+        def no_redis(name, *args, **kwargs):
+            if name == "redis":
+                raise ImportError(name)
+            return real_import(name, *args, **kwargs)
+
+        monkeypatch.setattr(builtins, "__import__", no_redis)
+        with pytest.raises(RuntimeError, match="TOKEN_DENYLIST_REDIS_URL"):
+            RedisTokenDenylist.from_url("redis://localhost:6379/0")
@@ -0,0 +1,101 @@
+"""Unit tests for impossible travel detection."""
+from datetime import datetime, timedelta, timezone
+
+import pytest
+
+from src.services.travel_service import TravelDetectionService, TravelPoint
+
+T0 = datetime(2026, 3, 1, 9, 0)
+
+LONDON = (51.5142, -0.0931)
+PARIS = (48.8566, 2.3522)
+NEW_YORK = (40.7128, -74.0060)
+
+
+def _login(place, minutes=0, accuracy_km=None):
+    return TravelPoint(place[0], place[1], T0 + timedelta(minutes=minutes), accuracy_km)
+
+
+@pytest.fixture
+def travel_service(db_session):
+    """Travel detection with a 1000 km/h limit and 100 km minimum jump."""
+    return TravelDetectionService(db_session, max_speed_kmh=1000, min_distance_km=100)
+
+
+class TestTravelAssessment:
+    """Test speed computation between two logins."""
+
+    def test_first_login(self, travel_service):
+        """A user's first login has nothing to compare with."""
+        assessment = travel_service.evaluate("alice", _login(LONDON))
+        assert assessment.previous is None
+        assert assessment.impossible is False
+
+    def test_impossible(self, travel_service):
+        """London to New York in an hour should be flagged."""
+        travel_service.evaluate("alice", _login(LONDON))
+        assessment = travel_service.evaluate("alice", _login(NEW_YORK, 60, accuracy_km=20))
+        assert assessment.distance_km == pytest.approx(5570, abs=10)
+        assert assessment.speed_kmh == pytest.approx(5550, abs=10)
+        assert assessment.elapsed_seconds == 3600
+        assert assessment.impossible is True
+
+    def test_plausible_flight(self, travel_service):
+        """London to New York in eight hours is an ordinary flight."""
+        travel_service.evaluate("alice", _login(LONDON))
+        assert travel_service.evaluate("alice", _login(NEW_YORK, 8 * 60)).impossible is False
+
+    def test_accuracy_absorbs_jump(self, travel_service):
+        """Coarse locations whose radii cover the distance should not be flagged."""
+        travel_service.evaluate("alice", _login(LONDON, accuracy_km=200))
+        assessment = travel_service.evaluate("alice", _login(PARIS, 1, accuracy_km=200))
+        assert assessment.speed_kmh == 0
+        assert assessment.impossible is False
+
+    def test_simultaneous(self, travel_service):
+        """Distant logins at the same instant should be flagged without a speed."""
+        travel_service.evaluate("alice", _login(LONDON))
+        assessment = travel_service.evaluate("alice", _login(NEW_YORK))
+        assert assessment.speed_kmh is None
+        assert assessment.impossible is True
+
+    def test_aware_timestamps(self, travel_service):
+        """Aware timestamps should be compared in UTC."""
+        travel_service.evaluate("alice", _login(LONDON))
+        later = TravelPoint(*PARIS, datetime(2026, 3, 1, 14, 0, tzinfo=timezone(timedelta(hours=5))))
+        assert travel_service.evaluate("alice", later).elapsed_seconds == 0
+
+
+class TestTravelHistory:
+    """Test which previous login a login is compared with."""
+
+    def test_users_independent(self, travel_service):
+        """Logins of other users should be ignored."""
+        travel_service.evaluate("alice", _login(LONDON))
+        assert travel_service.evaluate("bob", _login(NEW_YORK, 60)).previous is None
+
+    def test_tenants_independent(self, travel_service):
+        """The same user ID in another tenant is another user."""
+        travel_service.evaluate("alice", _login(LONDON), tenant_id="acme")
+        assert travel_service.evaluate("alice", _login(NEW_YORK, 60), tenant_id="globex").previous is None
+        assert travel_service.evaluate("alice", _login(NEW_YORK, 60)).previous is None
+
+    def test_consecutive(self, travel_service):
+        """Each login should be compared with the latest one before it."""
+        travel_service.evaluate("alice", _login(LONDON))
+        travel_service.evaluate("alice", _login(NEW_YORK, 8 * 60))
+        assessment = travel_service.evaluate("alice", _login(NEW_YORK, 9 * 60))
+        assert assessment.previous.latitude == NEW_YORK[0]
+        assert assessment.impossible is False
+
+    def test_out_of_order(self, travel_service):
+        """A late-arriving login should be compared with its predecessor in time."""
+        travel_service.evaluate("alice", _login(LONDON))
+        travel_service.evaluate("alice", _login(NEW_YORK, 8 * 60))
+        assessment = travel_service.evaluate("alice", _login(PARIS, 30))
+        assert assessment.previous.latitude == LONDON[0]
+
+    def test_empty_user_rejected(self, travel_service):
+        """Should reject blank user IDs."""
+        with pytest.raises(ValueError):
+            travel_service.evaluate("  ", _login(LONDON))
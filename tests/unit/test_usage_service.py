@@ -0,0 +1,115 @@
+"""Unit tests for usage analytics of tenant API keys."""
+from datetime import date, datetime
+
+import pytest
+from sqlalchemy.exc import OperationalError
+
+from src.models.database_models import ApiKeyUsage
+from src.services import usage_service
+from src.services.auth_service import TokenVerifier
+from src.services.tenant_service import TenantService
+from src.services.usage_service import UsageRecorder, UsageService, api_key_of, status_class
+
+DAY1 = datetime(2026, 3, 1, 9, 0)
+DAY2 = datetime(2026, 3, 2, 23, 59)
+
+
+class TestUsageRecorder:
+    """Test counting requests and storing the counts."""
+
+    def test_flush_adds_to_stored_counts(self, db_session):
+        """Counts of every flush should be added to the same daily rows."""
+        recorder = UsageRecorder(session_factory=lambda: db_session)
+        for _ in range(2):
+            recorder.record("acme", "k-1", "GET /api/v1/pois", 200, at=DAY1)
+        recorder.record("acme", "k-1", "GET /api/v1/pois", 404, at=DAY1)
+        assert recorder.flush() == 2
+        recorder.record("acme", "k-1", "GET /api/v1/pois", 201, at=DAY1)
+        assert recorder.flush() == 1
+        assert recorder.flush() == 0
+
+        rows = {(r.status_class, r.requests) for r in db_session.query(ApiKeyUsage)}
+        assert rows == {("2xx", 3), ("4xx", 1)}
+
+    def test_failed_flush_keeps_counts(self, db_session):
+        """Counts that cannot be stored should be retried by the next flush."""
+        class BrokenSession:
+            def query(self, *args):
+                raise OperationalError("UPDATE", {}, Exception("database is locked"))
+
+            def rollback(self):
+                pass
+
+            def close(self):
+                pass
+
+        recorder = UsageRecorder(session_factory=BrokenSession)
+        recorder.record("acme", "k-1", "GET /api/v1/pois", 200, at=DAY1)
+        assert recorder.flush() == 0
+        recorder.session_factory = lambda: db_session
+        assert recorder.flush() == 1
+        assert db_session.query(ApiKeyUsage).one().requests == 1
+
+    def test_helpers(self):
+        """Status codes should map to classes and API key subjects to key IDs."""
+        assert [status_class(code) for code in (200, 302, 429, 503)] == ["2xx", "3xx", "4xx", "5xx"]
+        assert api_key_of("apikey:k-1") == "k-1"
+        assert api_key_of("user-1") is None and api_key_of(None) is None
+
+
+class TestUsageService:
+    """Test breaking down stored usage."""
+
+    @pytest.fixture
+    def stored(self, db_session):
+        recorder = UsageRecorder(session_factory=lambda: db_session)
+        recorder.record("acme", "k-1", "GET /api/v1/pois", 200, at=DAY1)
+        recorder.record("acme", "k-1", "POST /api/v1/pois", 400, at=DAY2)
+        for _ in range(3):
+            recorder.record("acme", "k-2", "GET /api/v1/pois", 200, at=DAY2)
+        recorder.record("globex", "k-3", "GET /api/v1/pois", 200, at=DAY1)
+        recorder.flush()
+
+    def test_breakdown(self, db_session, stored):
+        """Keys of the tenant should be broken down by endpoint, day and class, busiest first."""
+        keys = UsageService(db_session).breakdown("acme", date(2026, 3, 1), date(2026, 3, 2))
+        assert [(usage.key_id, usage.total) for usage in keys] == [("k-2", 3), ("k-1", 2)]
+        assert keys[1].by_endpoint == {"GET /api/v1/pois": 1, "POST /api/v1/pois": 1}
+        assert keys[1].by_day == {"2026-03-01": 1, "2026-03-02": 1}
+        assert keys[1].by_status_class == {"2xx": 1, "4xx": 1}
+
+    def test_range_and_key(self, db_session, stored):
+        """Days outside the range and other keys should be left out."""
+        service = UsageService(db_session)
+        assert [u.key_id for u in service.breakdown("acme", date(2026, 3, 1), date(2026, 3, 1))] == ["k-1"]
+        assert [u.total for u in service.breakdown("acme", date(2026, 3, 1), date(2026, 3, 2), "k-1")] == [2]
+        with pytest.raises(ValueError, match="until"):
+            service.breakdown("acme", date(2026, 3, 2), date(2026, 3, 1))
+
+
+class TestUsageRoutes:
+    """Test GET /api/v1/usage."""
+
+    def test_counts_api_key_requests(self, db_client, db_session, monkeypatch):
+        """Requests with API key tokens should be counted and reported to their tenant."""
+        verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+        monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+        recorder = UsageRecorder(session_factory=lambda: db_session)
+        monkeypatch.setattr(usage_service, "_usage_recorder", recorder)
+        _, issued = TenantService(db_session).create_tenant("acme", "Acme")
+        key_id, prefix = issued.key.key_id, issued.key.prefix
+        key = {"Authorization": f"Bearer {verifier.issue(f'apikey:{key_id}', 'acme')}"}
+        user = {"Authorization": f"Bearer {verifier.issue('user-1', 'acme')}"}
+
+        assert db_client.get("/api/v1/usage", headers=key).json()["total"] == 0
+        assert db_client.get("/api/v1/usage?since=2026-03-02&until=2026-03-01", headers=key).status_code == 400
+        assert db_client.get("/api/v1/usage", headers=user).status_code == 200
+        recorder.flush()
+
+        usage = db_client.get("/api/v1/usage", headers=key).json()
+        assert usage["total"] == 2
+        assert usage["keys"][0]["prefix"] == prefix
+        assert usage["keys"][0]["by_endpoint"] == {"GET /api/v1/usage": 2}
+        assert usage["keys"][0]["by_status_class"] == {"2xx": 1, "4xx": 1}
+        too_long = db_client.get("/api/v1/usage?since=2020-01-01&until=2026-03-01", headers=key)
+        assert too_long.status_code == 400
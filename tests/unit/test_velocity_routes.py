@@ -0,0 +1,57 @@
+"""Route tests for the velocity counter API."""
+import pytest
+
+from src.services.velocity_service import VelocityTracker
+
+
+@pytest.fixture(autouse=True)
+def tracker(monkeypatch):
+    """In-memory tracker served by the API."""
+    tracker = VelocityTracker()
+    monkeypatch.setattr("src.api.velocity_routes.get_velocity_tracker", lambda: tracker)
+    return tracker
+
+
+def _observe(client, **fields):
+    return client.post("/api/v1/velocity/observe", json={"entity_type": "account", "entity_id": "alice", **fields})
+
+
+class TestVelocityRoutes:
+    """Test recording and reading velocity counts."""
+
+    def test_observe_counts(self, test_client):
+        """Observations should return the updated distinct counts."""
+        _observe(test_client, country_code="GB", city="London")
+        response = _observe(test_client, country_code="FR", city="Paris")
+        assert response.status_code == 200
+        body = response.json()
+        assert body["countries"] == {"15m": 2, "1h": 2, "24h": 2}
+        assert body["cities"]["24h"] == 2
+
+    def test_get_counts(self, test_client):
+        """GET should report counts without recording."""
+        _observe(test_client, country_code="GB")
+        response = test_client.get("/api/v1/velocity/account/alice")
+        assert response.status_code == 200
+        assert response.json()["countries"]["1h"] == 1
+        assert test_client.get("/api/v1/velocity/device/alice").json()["countries"]["1h"] == 0
+
+    def test_location_required(self, test_client):
+        """Should require an IP address or a country code."""
+        assert _observe(test_client).status_code == 400
+        assert _observe(test_client, ip_address="81.2.69.142", country_code="GB").status_code == 400
+
+    def test_unknown_entity_type_is_400(self, test_client):
+        """Should reject entity types other than account and device."""
+        response = test_client.get("/api/v1/velocity/session/s-1")
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_store_unavailable_is_503(self, test_client, tracker, monkeypatch):
+        """Should return 503 when the store cannot be reached."""
+        def broken(*args, **kwargs):
+            raise RuntimeError("Velocity store unavailable: connection refused")
+
+        monkeypatch.setattr(tracker, "counts", broken)
+        response = test_client.get("/api/v1/velocity/account/alice")
+        assert response.status_code == 503
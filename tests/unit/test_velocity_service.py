@@ -0,0 +1,145 @@
+"""Unit tests for per-entity velocity counters."""
+import pytest
+
+from src.services.velocity_service import (
+    InMemoryVelocityStore,
+    RedisVelocityStore,
+    VelocityTracker,
+    parse_windows,
+)
+
+T0 = 1_772_000_000.0
+
+
+class FakeRedis:
+    """Just enough of a Redis client for sorted set velocity counters."""
+
+    def __init__(self):
+        self.sets = {}
+        self.ttls = {}
+
+    def pipeline(self):
+        return FakePipeline(self)
+
+    def zadd(self, key, mapping, gt=False):
+        members = self.sets.setdefault(key, {})
+        for member, score in mapping.items():
+            if member not in members or not gt or score > members[member]:
+                members[member] = score
+
+    def zremrangebyscore(self, key, low, high):
+        assert low == "-inf" and high.startswith("(")
+        cutoff = float(high[1:])
+        members = self.sets.get(key, {})
+        for member in [m for m, score in members.items() if score < cutoff]:
+            del members[member]
+
+    def expire(self, key, seconds):
+        self.ttls[key] = seconds
+
+    def zcount(self, key, low, high):
+        assert high == "+inf"
+        return sum(1 for score in self.sets.get(key, {}).values() if score >= low)
+
+
+class FakePipeline:
+    def __init__(self, client):
+        self.client = client
+        self.calls = []
+
+    def __getattr__(self, name):
+        return lambda *args, **kwargs: self.calls.append((name, args, kwargs))
+
+    def execute(self):
+        for name, args, kwargs in self.calls:
+            getattr(self.client, name)(*args, **kwargs)
+
+
+@pytest.fixture(params=["memory", "redis"])
+def tracker(request):
+    """Tracker with 15 minute and 1 hour windows over each store."""
+    store = InMemoryVelocityStore() if request.param == "memory" else RedisVelocityStore(FakeRedis())
+    return VelocityTracker(store, parse_windows("1h,15m"))
+
+
+class TestParseWindows:
+    """Test VELOCITY_WINDOWS parsing."""
+
+    def test_sorted_seconds(self):
+        """Windows should be converted to seconds, shortest first."""
+        assert parse_windows("24h, 15m,30s,7d") == [
+            ("30s", 30), ("15m", 900), ("24h", 86400), ("7d", 604800),
+        ]
+
+    @pytest.mark.parametrize("raw", ["", "15", "1w", "0m", "15m,x"])
+    def test_invalid(self, raw):
+        """Malformed or empty window lists should be rejected."""
+        with pytest.raises(ValueError):
+            parse_windows(raw)
+
+
+class TestVelocityTracker:
+    """Test distinct country and city counts."""
+
+    def test_counts_per_window(self, tracker):
+        """Locations should count in every window they fall inside."""
+        tracker.observe("account", "alice", "GB", "London", timestamp=T0)
+        tracker.observe("account", "alice", "FR", "Paris", timestamp=T0 + 1800)
+        snapshot = tracker.observe("account", "alice", "FR", "Lyon", timestamp=T0 + 2400)
+        assert snapshot.counts["country"] == {"15m": 1, "1h": 2}
+        assert snapshot.counts["city"] == {"15m": 2, "1h": 3}
+
+    def test_repeat_location_counted_once(self, tracker):
+        """The same location seen again should not grow the count."""
+        for offset in (0, 60, 120):
+            snapshot = tracker.observe("device", "d-1", "gb", "london", timestamp=T0 + offset)
+        assert snapshot.count("country", "1h") == 1
+        assert snapshot.count("city", "1h") == 1
+
+    def test_old_locations_expire(self, tracker):
+        """Locations outside the longest window should no longer count."""
+        tracker.observe("account", "alice", "GB", timestamp=T0)
+        snapshot = tracker.observe("account", "alice", "US", timestamp=T0 + 7200)
+        assert snapshot.count("country", "1h") == 1
+
+    def test_entities_and_tenants_independent(self, tracker):
+        """Counts should be kept per entity type, entity and tenant."""
+        tracker.observe("account", "alice", "GB", timestamp=T0)
+        tracker.observe("account", "alice", "FR", timestamp=T0, tenant_id="acme")
+        assert tracker.counts("device", "alice", now=T0).count("country", "1h") == 0
+        assert tracker.counts("account", "alice", now=T0).count("country", "1h") == 1
+        assert tracker.counts("account", "alice", now=T0, tenant_id="acme").count("country", "1h") == 1
+
+    def test_same_city_name_in_other_country(self, tracker):
+        """Cities should be distinguished by country."""
+        tracker.observe("account", "alice", "GB", "London", timestamp=T0)
+        snapshot = tracker.observe("account", "alice", "CA", "London", timestamp=T0 + 60)
+        assert snapshot.count("city", "15m") == 2
+
+    def test_invalid_entity(self, tracker):
+        """Unknown entity types and blank IDs should be rejected."""
+        with pytest.raises(ValueError):
+            tracker.counts("session", "s-1")
+        with pytest.raises(ValueError):
+            tracker.observe("account", " ", "GB")
+
+    def test_store_failure(self):
+        """Store errors should surface as RuntimeError."""
+        class BrokenStore(InMemoryVelocityStore):
+            def count_since(self, key, since):
+                raise ConnectionError("connection refused")
+
+        with pytest.raises(RuntimeError):
+            VelocityTracker(BrokenStore()).counts("account", "alice")
+
+
+class TestRedisVelocityStore:
+    """Test the Redis key layout."""
+
+    def test_expiry(self):
+        """Keys should expire after the longest window."""
+        client = FakeRedis()
+        VelocityTracker(RedisVelocityStore(client), parse_windows("15m,1h")).observe(
+            "account", "alice", "GB", timestamp=T0
+        )
+        assert client.ttls == {"velocity:-:account:country:alice": 3600}
@@ -0,0 +1,109 @@
+"""Tests for API versions and the deprecation headers of their responses."""
+from datetime import datetime, timezone
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from src.api.versioning import ApiVersion, api_versions, parse_policy_date, versioned_router
+from src.config import Config
+from src.middleware.versioning import ApiVersionMiddleware
+
+DEPRECATED = datetime(2027, 1, 1, tzinfo=timezone.utc)
+SUNSET = datetime(2027, 7, 1, tzinfo=timezone.utc)
+
+
+class TestApiVersion:
+    """Test ApiVersion and its configuration."""
+
+    def test_parse_policy_date(self):
+        """Should read dates and instants as UTC, and empty values as None."""
+        assert parse_policy_date("2027-01-01", "X") == DEPRECATED
+        assert parse_policy_date("2027-01-01T02:00:00+02:00", "X") == DEPRECATED
+        assert parse_policy_date("2027-01-01T00:00:00Z", "X") == DEPRECATED
+        assert parse_policy_date("  ", "X") is None
+
+    def test_invalid_date(self):
+        """Should name the setting of a malformed date."""
+        with pytest.raises(ValueError, match="API_V1_SUNSET_AT"):
+            parse_policy_date("next summer", "API_V1_SUNSET_AT")
+
+    def test_headers(self):
+        """Should format Deprecation (RFC 9745), Sunset (RFC 8594) and the successor link."""
+        version = ApiVersion("v1", DEPRECATED, SUNSET, successor="v2")
+        assert version.headers("/api/v2/lookup/ip/1.2.3.4") == {
+            "Deprecation": "@1798761600",
+            "Sunset": "Thu, 01 Jul 2027 00:00:00 GMT",
+            "Link": '</api/v2/lookup/ip/1.2.3.4>; rel="successor-version"',
+        }
+        assert ApiVersion("v2").headers("/api/v3/x") == {}
+
+    def test_api_versions_from_config(self, monkeypatch):
+        """Should schedule v1 from configuration and keep v2 supported."""
+        monkeypatch.setenv("API_V1_DEPRECATED_AT", "2027-01-01")
+        monkeypatch.setenv("API_V1_SUNSET_AT", "2027-07-01")
+        versions = api_versions(Config())
+        assert versions["v1"].deprecated_at == DEPRECATED
+        assert versions["v1"].sunset_at == SUNSET
+        assert versions["v1"].successor == "v2"
+        assert versions["v2"].deprecated_at is None
+
+    def test_versioned_router(self):
+        """Should mount routers under their version and reject unknown versions."""
+        assert versioned_router("v2").prefix == "/api/v2"
+        with pytest.raises(ValueError):
+            versioned_router("v9")
+
+
+def _client(*versions):
+    """Small app with a route in both versions and one only in v1."""
+    app = FastAPI()
+    app.add_middleware(ApiVersionMiddleware, versions=versions)
+    v1, v2 = versioned_router("v1"), versioned_router("v2")
+
+    @v1.get("/items/{item_id}")
+    async def item_v1(item_id: str):
+        return {"id": item_id}
+
+    @v1.get("/legacy")
+    async def legacy():
+        return {}
+
+    @v2.get("/items/{item_id}")
+    async def item_v2(item_id: str):
+        return {"item": {"id": item_id}}
+
+    app.include_router(v1)
+    app.include_router(v2)
+    return TestClient(app)
+
+
+class TestApiVersionMiddleware:
+    """Test ApiVersionMiddleware."""
+
+    def test_deprecated_version_headers(self):
+        """Should announce deprecation, sunset and the v2 route on v1 responses."""
+        client = _client(ApiVersion("v1", DEPRECATED, SUNSET, successor="v2"), ApiVersion("v2"))
+        response = client.get("/api/v1/items/42")
+        assert response.status_code == 200
+        assert response.headers["Deprecation"] == "@1798761600"
+        assert response.headers["Sunset"] == "Thu, 01 Jul 2027 00:00:00 GMT"
+        assert response.headers["Link"] == '</api/v2/items/42>; rel="successor-version"'
+
+        current = client.get("/api/v2/items/42")
+        assert "Deprecation" not in current.headers
+
+    def test_no_link_without_successor_route(self):
+        """Should omit the Link header for routes v2 does not serve."""
+        client = _client(ApiVersion("v1", DEPRECATED, successor="v2"), ApiVersion("v2"))
+        response = client.get("/api/v1/legacy")
+        assert response.headers["Deprecation"] == "@1798761600"
+        assert "Sunset" not in response.headers
+        assert "Link" not in response.headers
+
+    def test_supported_version_untouched(self):
+        """Should add nothing while no version is scheduled."""
+        client = _client(ApiVersion("v1", successor="v2"), ApiVersion("v2"))
+        response = client.get("/api/v1/items/42")
+        assert "Deprecation" not in response.headers
+        assert "Sunset" not in response.headers
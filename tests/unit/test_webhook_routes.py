@@ -0,0 +1,198 @@
+"""Route tests for tenant webhooks."""
+import asyncio
+from datetime import datetime
+
+import pytest
+
+from src.api.routes import _notify
+from src.services.auth_service import TokenVerifier
+from src.services.live_event_service import detection_event
+from src.services.webhook_service import WebhookDeliveryLog, WebhookService, WebhookTarget
+
+
+@pytest.fixture
+def verifier(monkeypatch):
+    """Token verifier used by the API, with a test secret."""
+    verifier = TokenVerifier("test-secret-0123456789abcdef0123456789")
+    monkeypatch.setattr("src.api.dependencies.get_token_verifier", lambda: verifier)
+    return verifier
+
+
+def _auth(verifier, tenant_id):
+    return {"Authorization": f"Bearer {verifier.issue('user-1', tenant_id)}"}
+
+
+@pytest.fixture
+def webhook(db_client, verifier):
+    """Geofence webhook registered by tenant "acme"."""
+    response = db_client.post(
+        "/api/v1/webhooks",
+        json={"url": "https://hooks.example.com/geo", "events": ["geofence.*"]},
+        headers=_auth(verifier, "acme"),
+    )
+    assert response.status_code == 201
+    return response.json()
+
+
+class FakeDispatcher:
+    """Records scheduled webhook deliveries."""
+
+    def __init__(self):
+        self.calls = []
+
+    def dispatch(self, targets, event, data):
+        self.calls.append(("dispatch", [t.url for t in targets], event, data))
+        return asyncio.sleep(0)
+
+    def redeliver(self, target, delivery_id, event, body):
+        self.calls.append(("redeliver", target.url, delivery_id, event))
+        return asyncio.sleep(0)
+
+
+@pytest.fixture
+def dispatcher(monkeypatch):
+    """Webhook dispatcher that delivers nothing."""
+    dispatcher = FakeDispatcher()
+    monkeypatch.setattr("src.api.webhook_routes.get_webhook_dispatcher", lambda: dispatcher)
+    monkeypatch.setattr("src.api.routes.get_webhook_dispatcher", lambda: dispatcher)
+    return dispatcher
+
+
+def _log_delivery(db_session, webhook, event="geofence.enter", body='{"event": "geofence.enter"}'):
+    """Log a failed delivery to a webhook created through the API."""
+    log = WebhookDeliveryLog(session_factory=lambda: db_session)
+    target = WebhookTarget(webhook["webhook_id"], webhook["url"], webhook["secret"], "acme")
+    delivery_id = f"delivery-{event}"
+    log.started(delivery_id, target, event, body.encode("utf-8"))
+    log.attempted(delivery_id, 500, None)
+    log.finished(delivery_id, False)
+    return delivery_id
+
+
+class TestWebhookRoutes:
+    """Test webhook management."""
+
+    def test_requires_token(self, db_client, verifier):
+        """Should require a bearer token."""
+        response = db_client.get("/api/v1/webhooks")
+        assert response.status_code == 401
+
+    def test_secret_only_returned_at_creation(self, db_client, verifier, webhook):
+        """Should return the signing secret once."""
+        assert len(webhook["secret"]) == 64
+
+        listed = db_client.get("/api/v1/webhooks", headers=_auth(verifier, "acme")).json()
+        assert [w["webhook_id"] for w in listed["webhooks"]] == [webhook["webhook_id"]]
+        assert listed["webhooks"][0]["secret"] is None
+
+    def test_invalid_url_is_400(self, db_client, verifier):
+        """Should reject a URL that is not http(s)."""
+        response = db_client.post(
+            "/api/v1/webhooks",
+            json={"url": "ftp://example.com/geo", "events": ["*"]},
+            headers=_auth(verifier, "acme"),
+        )
+        assert response.status_code == 400
+        assert response.json()["detail"]["error_code"] == "E002"
+
+    def test_other_tenant_gets_404(self, db_client, verifier, webhook):
+        """Should hide webhooks of other tenants."""
+        response = db_client.get(
+            f"/api/v1/webhooks/{webhook['webhook_id']}", headers=_auth(verifier, "globex")
+        )
+        assert response.status_code == 404
+        assert response.json()["detail"]["error_code"] == "E004"
+
+    def test_delete(self, db_client, verifier, webhook):
+        """Should delete the webhook."""
+        path = f"/api/v1/webhooks/{webhook['webhook_id']}"
+        assert db_client.delete(path, headers=_auth(verifier, "acme")).status_code == 204
+        assert db_client.delete(path, headers=_auth(verifier, "acme")).status_code == 404
+
+
+class TestWebhookDeliveryRoutes:
+    """Test the delivery log and redelivery."""
+
+    def test_list_and_get(self, db_client, db_session, verifier, webhook):
+        """Should list a webhook's deliveries and return one with its payload."""
+        delivery_id = _log_delivery(db_session, webhook)
+        path = f"/api/v1/webhooks/{webhook['webhook_id']}/deliveries"
+
+        listed = db_client.get(path, headers=_auth(verifier, "acme"))
+        assert listed.status_code == 200
+        [delivery] = listed.json()["deliveries"]
+        assert delivery["delivery_id"] == delivery_id
+        assert delivery["status"] == "failed"
+        assert delivery["attempts"] == 1
+        assert delivery["last_status_code"] == 500
+        assert delivery["payload"] is None
+        assert listed.json()["next_cursor"] is None
+
+        fetched = db_client.get(f"{path}/{delivery_id}", headers=_auth(verifier, "acme"))
+        assert fetched.status_code == 200
+        assert fetched.json()["payload"] == {"event": "geofence.enter"}
+
+    def test_status_filter(self, db_client, db_session, verifier, webhook):
+        """Should filter by status and reject unknown ones."""
+        _log_delivery(db_session, webhook)
+        path = f"/api/v1/webhooks/{webhook['webhook_id']}/deliveries"
+
+        delivered = db_client.get(path, params={"status": "delivered"}, headers=_auth(verifier, "acme"))
+        assert delivered.json()["deliveries"] == []
+
+        unknown = db_client.get(path, params={"status": "lost"}, headers=_auth(verifier, "acme"))
+        assert unknown.status_code == 400
+        assert unknown.json()["detail"]["error_code"] == "E002"
+
+    def test_other_tenant_gets_404(self, db_client, db_session, verifier, webhook):
+        """Should hide the deliveries of other tenants' webhooks."""
+        delivery_id = _log_delivery(db_session, webhook)
+        path = f"/api/v1/webhooks/{webhook['webhook_id']}/deliveries"
+
+        assert db_client.get(path, headers=_auth(verifier, "globex")).status_code == 404
+        response = db_client.get(f"{path}/{delivery_id}", headers=_auth(verifier, "globex"))
+        assert response.status_code == 404
+        unknown = db_client.get(f"{path}/missing", headers=_auth(verifier, "acme"))
+        assert unknown.status_code == 404
+        assert unknown.json()["detail"]["error_code"] == "E004"
+
+    def test_redeliver(self, db_client, db_session, verifier, webhook, dispatcher):
+        """Should mark the delivery pending and resend it in the background."""
+        delivery_id = _log_delivery(db_session, webhook)
+
+        response = db_client.post(
+            f"/api/v1/webhooks/{webhook['webhook_id']}/deliveries/{delivery_id}/redeliver",
+            headers=_auth(verifier, "acme"),
+        )
+        assert response.status_code == 202
+        assert response.json()["status"] == "pending"
+        assert dispatcher.calls == [("redeliver", webhook["url"], delivery_id, "geofence.enter")]
+
+    def test_redeliver_unknown_is_404(self, db_client, verifier, webhook, dispatcher):
+        """Should refuse to redeliver an unknown delivery."""
+        response = db_client.post(
+            f"/api/v1/webhooks/{webhook['webhook_id']}/deliveries/missing/redeliver",
+            headers=_auth(verifier, "acme"),
+        )
+        assert response.status_code == 404
+        assert dispatcher.calls == []
+
+
+class TestDetectionWebhooks:
+    """Test webhook delivery of detections."""
+
+    @pytest.mark.asyncio
+    async def test_detection_is_dispatched(self, db_session, dispatcher):
+        """Should schedule detection events for the tenant's subscribed webhooks."""
+        service = WebhookService(db_session)
+        service.create_webhook("acme", "https://hooks.example.com/det", ["detection"])
+        service.create_webhook("acme", "https://hooks.example.com/geo", ["geofence.*"])
+
+        _notify(db_session, detection_event(
+            "acme", "det-1", "vehicle", "cam-1", 51.505, -0.125, "GREEN", [], datetime(2026, 3, 1, 10, 0)
+        ))
+
+        [(kind, urls, event, data)] = dispatcher.calls
+        assert urls == ["https://hooks.example.com/det"]
+        assert event == "detection"
+        assert data["detection_id"] == "det-1"
@@ -0,0 +1,267 @@
+"""Unit tests for webhook registration and delivery."""
+import hashlib
+import hmac
+import json
+
+import pytest
+
+from src.models.database_models import WebhookDelivery
+from src.services.webhook_service import (
+    DeliveryStatus,
+    WebhookDeliveryLog,
+    WebhookDispatcher,
+    WebhookService,
+    WebhookTarget,
+    event_matches,
+    sign,
+)
+
+
+class FakeSender:
+    """Records deliveries and answers with queued statuses (or exceptions)."""
+
+    def __init__(self, *answers):
+        self.answers = list(answers)
+        self.calls = []
+
+    async def __call__(self, url, body, headers, timeout_seconds):
+        self.calls.append((url, body, headers))
+        answer = self.answers.pop(0) if self.answers else 200
+        if isinstance(answer, Exception):
+            raise answer
+        return answer
+
+
+TARGET = WebhookTarget("wh-1", "https://hooks.example.com/geo", "s3cret-s3cret-s3cret")
+
+
+class TestEventMatching:
+    """Test subscription patterns."""
+
+    @pytest.mark.parametrize("patterns,event,expected", [
+        (["geofence.enter"], "geofence.enter", True),
+        (["geofence.enter"], "geofence.exit", False),
+        (["geofence.*"], "geofence.dwell", True),
+        (["geofence.*"], "device.new", False),
+        (["*"], "anything", True),
+        ([], "geofence.enter", False),
+    ])
+    def test_patterns(self, patterns, event, expected):
+        """Should match exact types, families and the wildcard."""
+        assert event_matches(patterns, event) is expected
+
+
+class TestSigning:
+    """Test delivery signatures."""
+
+    def test_hmac_sha256(self):
+        """Should sign the raw body with HMAC-SHA256 of the secret."""
+        body = b'{"event": "geofence.enter"}'
+        expected = hmac.new(b"key", body, hashlib.sha256).hexdigest()
+        assert sign("key", body) == f"sha256={expected}"
+
+
+class TestWebhookService:
+    """Test webhook storage."""
+
+    def test_create_generates_secret(self, db_session):
+        """Should store the webhook with a generated secret and normalized events."""
+        webhook = WebhookService(db_session).create_webhook(
+            "acme", "https://hooks.example.com/geo", ["geofence.exit", " geofence.enter ", "geofence.exit"]
+        )
+        assert len(webhook.secret) == 64
+        assert webhook.events == ["geofence.enter", "geofence.exit"]
+
+    @pytest.mark.parametrize("url", ["ftp://example.com/x", "not a url", "https://"])
+    def test_invalid_url(self, db_session, url):
+        """Should reject URLs that are not http(s)."""
+        with pytest.raises(ValueError):
+            WebhookService(db_session).create_webhook("acme", url, ["*"])
+
+    def test_requires_events(self, db_session):
+        """Should reject a webhook without events."""
+        with pytest.raises(ValueError):
+            WebhookService(db_session).create_webhook("acme", "https://example.com", [" "])
+
+    def test_targets_are_tenant_scoped(self, db_session):
+        """Should return only the tenant's webhooks subscribed to the event."""
+        service = WebhookService(db_session)
+        fences = service.create_webhook("acme", "https://a.example.com", ["geofence.*"])
+        service.create_webhook("acme", "https://b.example.com", ["device.new"])
+        service.create_webhook("globex", "https://c.example.com", ["*"])
+
+        targets = service.targets("acme", "geofence.enter")
+        assert [t.webhook_id for t in targets] == [fences.webhook_id]
+
+    def test_delete(self, db_session):
+        """Should delete only the tenant's own webhook."""
+        service = WebhookService(db_session)
+        webhook = service.create_webhook("acme", "https://a.example.com", ["*"])
+        assert service.delete_webhook("globex", webhook.webhook_id) is False
+        assert service.delete_webhook("acme", webhook.webhook_id) is True
+        assert service.list_webhooks("acme") == []
+
+
+class TestWebhookDispatcher:
+    """Test signed delivery with retries."""
+
+    @pytest.mark.asyncio
+    async def test_signed_payload(self):
+        """Should POST the event envelope with a verifiable signature."""
+        sender = FakeSender(204)
+        delivered = await WebhookDispatcher(sender=sender).deliver(
+            TARGET, "geofence.enter", {"entity_id": "truck-17"}
+        )
+
+        assert delivered is True
+        url, body, headers = sender.calls[0]
+        assert url == TARGET.url
+        payload = json.loads(body)
+        assert payload["event"] == "geofence.enter"
+        assert payload["data"] == {"entity_id": "truck-17"}
+        assert headers["X-Webhook-Delivery"] == payload["delivery_id"]
+        assert headers["X-Webhook-Signature"] == sign(TARGET.secret, body)
+
+    @pytest.mark.asyncio
+    async def test_retries_until_success(self):
+        """Should retry network errors and non-2xx answers."""
+        sender = FakeSender(OSError("refused"), 500, 200)
+        dispatcher = WebhookDispatcher(max_attempts=3, backoff_seconds=0, sender=sender)
+
+        assert await dispatcher.deliver(TARGET, "geofence.exit", {}) is True
+        assert len(sender.calls) == 3
+        # Retries resend the same delivery
+        assert len({headers["X-Webhook-Delivery"] for _, _, headers in sender.calls}) == 1
+
+    @pytest.mark.asyncio
+    async def test_gives_up(self):
+        """Should give up after max_attempts."""
+        sender = FakeSender(503, 503, 503)
+        dispatcher = WebhookDispatcher(max_attempts=2, backoff_seconds=0, sender=sender)
+
+        assert await dispatcher.deliver(TARGET, "geofence.exit", {}) is False
+        assert len(sender.calls) == 2
+
+    @pytest.mark.asyncio
+    async def test_dispatch_counts_successes(self):
+        """Should deliver to every target and count the successes."""
+        sender = FakeSender(200, 410)
+        dispatcher = WebhookDispatcher(max_attempts=1, sender=sender)
+        other = WebhookTarget("wh-2", "https://other.example.com", "x" * 16)
+
+        assert await dispatcher.dispatch([TARGET, other], "geofence.dwell", {}) == 1
+        assert len(sender.calls) == 2
+
+
+class TestDeliveryLog:
+    """Test logged deliveries and redelivery."""
+
+    @pytest.fixture
+    def dispatcher_factory(self, db_session):
+        def factory(*answers, max_attempts=3):
+            return WebhookDispatcher(
+                max_attempts=max_attempts,
+                backoff_seconds=0,
+                sender=FakeSender(*answers),
+                delivery_log=WebhookDeliveryLog(session_factory=lambda: db_session),
+            )
+        return factory
+
+    @staticmethod
+    def _target(webhook):
+        return WebhookTarget(webhook.webhook_id, webhook.url, webhook.secret, webhook.tenant_id)
+
+    @pytest.mark.asyncio
+    async def test_logs_attempts_and_outcome(self, db_session, dispatcher_factory):
+        """Should log each attempt and the final status."""
+        webhook = WebhookService(db_session).create_webhook("acme", "https://a.example.com", ["*"])
+        dispatcher = dispatcher_factory(OSError("refused"), 500, 200)
+
+        assert await dispatcher.deliver(self._target(webhook), "geofence.enter", {"entity_id": "truck-17"})
+
+        delivery = db_session.query(WebhookDelivery).one()
+        assert delivery.tenant_id == "acme"
+        assert delivery.status == DeliveryStatus.DELIVERED
+        assert delivery.attempts == 3
+        assert delivery.last_status_code == 200
+        assert delivery.last_error is None
+        assert delivery.delivered_at is not None
+        assert json.loads(delivery.body)["data"] == {"entity_id": "truck-17"}
+
+    @pytest.mark.asyncio
+    async def test_logs_failure(self, db_session, dispatcher_factory):
+        """Should log the last answer of a delivery that gave up."""
+        webhook = WebhookService(db_session).create_webhook("acme", "https://a.example.com", ["*"])
+        dispatcher = dispatcher_factory(503, OSError("refused"), max_attempts=2)
+
+        assert not await dispatcher.deliver(self._target(webhook), "geofence.exit", {})
+
+        delivery = db_session.query(WebhookDelivery).one()
+        assert delivery.status == DeliveryStatus.FAILED
+        assert delivery.attempts == 2
+        assert delivery.last_status_code is None
+        assert delivery.last_error == "OSError: refused"
+
+    @pytest.mark.asyncio
+    async def test_redelivery_resigns_same_body(self, db_session, dispatcher_factory):
+        """Should resend the stored body under the same ID, signed with the current secret."""
+        service = WebhookService(db_session)
+        webhook = service.create_webhook("acme", "https://a.example.com", ["*"])
+        await dispatcher_factory(500, max_attempts=1).deliver(self._target(webhook), "detection", {})
+        delivery = db_session.query(WebhookDelivery).one()
+
+        assert service.requeue_delivery(delivery).status == DeliveryStatus.PENDING
+        rotated = WebhookTarget(webhook.webhook_id, webhook.url, "rotated-rotated-rotated", "acme")
+        dispatcher = dispatcher_factory(200)
+        assert await dispatcher.redeliver(rotated, delivery.delivery_id, delivery.event, delivery.body)
+
+        _, body, headers = dispatcher.sender.calls[0]
+        assert body.decode("utf-8") == delivery.body
+        assert headers["X-Webhook-Delivery"] == delivery.delivery_id
+        assert headers["X-Webhook-Signature"] == sign("rotated-rotated-rotated", body)
+        redelivered = db_session.query(WebhookDelivery).one()
+        assert redelivered.status == DeliveryStatus.DELIVERED
+        assert redelivered.attempts == 2
+
+    @pytest.mark.asyncio
+    async def test_failing_log_does_not_stop_delivery(self):
+        """Should deliver even if the log cannot be written."""
+        def broken_session():
+            raise RuntimeError("database down")
+
+        dispatcher = WebhookDispatcher(
+            sender=FakeSender(200), delivery_log=WebhookDeliveryLog(session_factory=broken_session)
+        )
+        assert await dispatcher.deliver(TARGET, "geofence.enter", {}) is True
+
+    @pytest.mark.asyncio
+    async def test_delivery_page(self, db_session, dispatcher_factory):
+        """Should page through a webhook's deliveries, newest first, by status."""
+        service = WebhookService(db_session)
+        webhook = service.create_webhook("acme", "https://a.example.com", ["*"])
+        target = self._target(webhook)
+        dispatcher = dispatcher_factory(200, 500, 200, max_attempts=1)
+        for event in ("geofence.enter", "geofence.exit", "geofence.dwell"):
+            await dispatcher.deliver(target, event, {})
+
+        first = service.delivery_page("acme", webhook.webhook_id, limit=2)
+        assert [d.event for d in first.items] == ["geofence.dwell", "geofence.exit"]
+        rest = service.delivery_page("acme", webhook.webhook_id, limit=2, cursor=first.next_cursor)
+        assert [d.event for d in rest.items] == ["geofence.enter"]
+        assert rest.next_cursor is None
+
+        failed = service.delivery_page("acme", webhook.webhook_id, status=DeliveryStatus.FAILED)
+        assert [d.event for d in failed.items] == ["geofence.exit"]
+        assert service.delivery_page("globex", webhook.webhook_id).items == []
+        with pytest.raises(ValueError, match="Unknown delivery status"):
+            service.delivery_page("acme", webhook.webhook_id, status="lost")
+
+    @pytest.mark.asyncio
+    async def test_delete_removes_log(self, db_session, dispatcher_factory):
+        """Should delete a webhook's deliveries with it."""
+        service = WebhookService(db_session)
+        webhook = service.create_webhook("acme", "https://a.example.com", ["*"])
+        await dispatcher_factory(200).deliver(self._target(webhook), "detection", {})
+
+        assert service.delete_webhook("acme", webhook.webhook_id) is True
+        assert db_session.query(WebhookDelivery).count() == 0
@@ -0,0 +1,140 @@
+"""Unit tests for WiFi BSSID positioning."""
+import pytest
+
+from src.models.database_models import WifiAccessPoint
+from src.services.wifi_service import WifiPositioningService, normalize_bssid
+
+ACCESS_POINTS = [
+    {"bssid": "00:11:22:33:44:55", "latitude": 51.50300, "longitude": -0.11950, "ssid": "cafe"},
+    {"bssid": "00:11:22:33:44:66", "latitude": 51.50320, "longitude": -0.11920},
+    {"bssid": "00:11:22:33:44:77", "latitude": 51.50280, "longitude": -0.11900},
+    {"bssid": "00:11:22:33:44:88", "latitude": 48.85840, "longitude": 2.29450},  # moved away
+]
+
+
+@pytest.fixture
+def wifi_service(db_session):
+    """WiFi positioning service over a small access point database."""
+    service = WifiPositioningService(
+        db_session,
+        min_access_points=2,
+        max_observations=10,
+        reference_rssi_dbm=-40,
+        path_loss_exponent=3.0,
+        max_spread_m=500,
+    )
+    service.ingest(ACCESS_POINTS)
+    return service
+
+
+class TestNormalizeBssid:
+    """Test BSSID validation and formatting."""
+
+    @pytest.mark.parametrize("value", [
+        "00:11:22:33:44:55", "00-11-22-33-44-55", "0011.2233.4455", " 00:11:22:33:44:55 ",
+    ])
+    def test_formats(self, value):
+        """Common notations should normalize to lowercase colon-separated hex."""
+        assert normalize_bssid(value) == "00:11:22:33:44:55"
+
+    @pytest.mark.parametrize("value", ["", "00:11:22:33:44", "zz:11:22:33:44:55",
+                                       "01:00:5e:00:00:01", "00:00:00:00:00:00"])
+    def test_invalid(self, value):
+        """Malformed, multicast and all-zero addresses should raise ValueError."""
+        with pytest.raises(ValueError):
+            normalize_bssid(value)
+
+
+class TestWifiIngestion:
+    """Test loading the access point database."""
+
+    def test_ingest_skips_invalid_rows(self, db_session):
+        """Rows with a bad BSSID or coordinate should be skipped."""
+        service = WifiPositioningService(db_session)
+        stored = service.ingest([
+            {"bssid": "AA-BB-CC-00-00-01", "latitude": "51.5", "longitude": "-0.1"},
+            {"bssid": "nope", "latitude": 51.5, "longitude": -0.1},
+            {"bssid": "aa:bb:cc:00:00:02", "latitude": 95, "longitude": -0.1},
+        ])
+        assert stored == 1
+        assert db_session.query(WifiAccessPoint).one().bssid == "aa:bb:cc:00:00:01"
+
+    def test_ingest_updates_existing(self, wifi_service, db_session):
+        """Re-ingesting a BSSID should move it rather than duplicate it."""
+        wifi_service.ingest([{"bssid": "00:11:22:33:44:55", "latitude": 51.6, "longitude": -0.2}])
+        ap = db_session.query(WifiAccessPoint).filter_by(bssid="00:11:22:33:44:55").one()
+        assert (ap.latitude, ap.longitude) == (51.6, -0.2)
+
+    def test_nomap_ssid_opts_out(self, wifi_service, db_session):
+        """Access points advertising a _nomap SSID should be removed and not stored."""
+        stored = wifi_service.ingest([
+            {"bssid": "00:11:22:33:44:55", "latitude": 51.5, "longitude": -0.1, "ssid": "cafe_nomap"},
+        ])
+        assert stored == 0
+        assert db_session.query(WifiAccessPoint).filter_by(bssid="00:11:22:33:44:55").count() == 0
+
+    def test_import_csv(self, db_session, tmp_path):
+        """CSV extracts should be loaded; missing columns should raise ValueError."""
+        path = tmp_path / "aps.csv"
+        path.write_text("bssid,latitude,longitude,ssid\n00:11:22:33:44:55,51.5,-0.1,cafe\n")
+        assert WifiPositioningService(db_session).import_csv(str(path)) == 1
+
+        bad = tmp_path / "bad.csv"
+        bad.write_text("mac,lat,lon\n")
+        with pytest.raises(ValueError, match="expected bssid"):
+            WifiPositioningService(db_session).import_csv(str(bad))
+
+
+class TestWifiLocate:
+    """Test positioning from observations."""
+
+    def test_locate(self, wifi_service):
+        """Known access points should give a position among them."""
+        position = wifi_service.locate([
+            ("00:11:22:33:44:55", -50),
+            ("00:11:22:33:44:66", -65),
+            ("00:11:22:33:44:77", -70),
+            ("de:ad:be:ef:00:01", -60),  # not in the database
+        ])
+        estimate = position.estimate
+        assert 51.5027 < estimate.latitude < 51.5033
+        assert -0.1196 < estimate.longitude < -0.1189
+        assert estimate.anchors_used == 3
+        assert position.access_points_known == 3
+        assert 0 < estimate.accuracy_m < 100
+
+    def test_distant_access_point_ignored(self, wifi_service):
+        """An access point far from the others should not drag the estimate."""
+        position = wifi_service.locate([
+            ("00:11:22:33:44:55", -50),
+            ("00:11:22:33:44:66", -60),
+            ("00:11:22:33:44:88", -55),
+        ])
+        assert position.estimate.anchors_used == 2
+        assert position.access_points_known == 3
+        assert position.estimate.latitude == pytest.approx(51.503, abs=0.001)
+
+    def test_too_few_known(self, wifi_service):
+        """Fewer known access points than required should raise LookupError."""
+        with pytest.raises(LookupError, match="at least 2"):
+            wifi_service.locate([("00:11:22:33:44:55", -50), ("de:ad:be:ef:00:01", -60)])
+
+    def test_duplicate_observations_keep_strongest(self, wifi_service):
+        """Repeated BSSIDs should count once."""
+        position = wifi_service.locate([
+            ("00:11:22:33:44:55", -80),
+            ("00-11-22-33-44-55", -50),
+            ("00:11:22:33:44:66", -65),
+        ])
+        assert position.estimate.anchors_used == 2
+
+    @pytest.mark.parametrize("observations,message", [
+        ([], "At least one"),
+        ([("00:11:22:33:44:55", -50)] * 11, "Too many"),
+        ([("00:11:22:33:44:55", 10)], "RSSI out of range"),
+        ([("bogus", -50)], "Invalid BSSID"),
+    ])
+    def test_invalid_observations(self, wifi_service, observations, message):
+        """Malformed requests should raise ValueError."""
+        with pytest.raises(ValueError, match=message):
+            wifi_service.locate(observations)
@@ -0,0 +1,100 @@
+"""Unit tests for WKT and WKB polygon input."""
+import struct
+
+import pytest
+
+from src.spatial import wkt
+
+RING = [(0, 0), (1, 0), (1, 1), (0, 0)]
+
+
+def _wkb_polygon(rings, little_endian=True, srid=None):
+    endian = "<" if little_endian else ">"
+    code = wkt.WKB_POLYGON | (0x20000000 if srid else 0)
+    data = struct.pack("B", int(little_endian)) + struct.pack(endian + "I", code)
+    if srid:
+        data += struct.pack(endian + "I", srid)
+    data += struct.pack(endian + "I", len(rings))
+    for ring in rings:
+        data += struct.pack(endian + "I", len(ring))
+        for x, y in ring:
+            data += struct.pack(endian + "dd", x, y)
+    return data
+
+
+class TestWkt:
+    """Test WKT decoding."""
+
+    def test_polygon_with_hole(self):
+        """Should decode rings in order as GeoJSON coordinates."""
+        geometry, srid = wkt.parse_wkt(
+            "POLYGON ((0 0, 10 0, 10 10, 0 10, 0 0), (2 2, 4 2, 4 4, 2 2))"
+        )
+        assert srid is None
+        assert geometry["type"] == "Polygon"
+        assert geometry["coordinates"][0][1] == [10.0, 0.0]
+        assert len(geometry["coordinates"]) == 2
+
+    def test_multipolygon(self):
+        """Should decode every member polygon."""
+        geometry, _ = wkt.parse_wkt("MULTIPOLYGON(((0 0,1 0,1 1,0 0)),((5 5,6 5,6 6,5 5)))")
+        assert geometry["type"] == "MultiPolygon"
+        assert geometry["coordinates"][1][0][0] == [5.0, 5.0]
+
+    def test_ewkt_with_z(self):
+        """Should read the SRID prefix and drop Z ordinates."""
+        geometry, srid = wkt.parse_wkt("SRID=3857;POLYGON Z ((0 0 5, 1 0 5, 1 1 5, 0 0 5))")
+        assert srid == 3857
+        assert geometry["coordinates"][0][0] == [0.0, 0.0]
+
+    @pytest.mark.parametrize("text,message", [
+        ("POINT (1 2)", "Unsupported WKT geometry type"),
+        ("POLYGON EMPTY", "no coordinates"),
+        ("POLYGON ((0 0, 1 0, 1 1, 0 1))", "not closed"),
+        ("POLYGON ((0 0, 1 0, 0 0))", "four positions"),
+        ("POLYGON ((0 0, 1 0, 1 1, 0 0)) extra", "unexpected"),
+        ("POLYGON ((0 0, 1 0", "end of WKT"),
+    ])
+    def test_invalid(self, text, message):
+        """Should reject malformed or non-polygon WKT."""
+        with pytest.raises(ValueError, match=message):
+            wkt.parse_wkt(text)
+
+
+class TestWkb:
+    """Test WKB decoding."""
+
+    def test_polygon_both_byte_orders(self):
+        """Little- and big-endian WKB should decode alike."""
+        expected = {"type": "Polygon", "coordinates": [[list(map(float, p)) for p in RING]]}
+        assert wkt.parse_wkb(_wkb_polygon([RING])) == (expected, None)
+        assert wkt.parse_wkb(_wkb_polygon([RING], little_endian=False)) == (expected, None)
+
+    def test_ewkb_srid_and_hex(self):
+        """Should read the EWKB SRID from hex text."""
+        geometry, srid = wkt.parse_wkb_hex(_wkb_polygon([RING], srid=4326).hex().upper())
+        assert srid == 4326
+        assert geometry["type"] == "Polygon"
+
+    def test_multipolygon(self):
+        """Members may use their own byte order."""
+        data = struct.pack("<BII", 1, wkt.WKB_MULTIPOLYGON, 2)
+        data += _wkb_polygon([RING]) + _wkb_polygon([RING], little_endian=False)
+        geometry, _ = wkt.parse_wkb(data)
+        assert len(geometry["coordinates"]) == 2
+
+    def test_invalid(self):
+        """Truncated, padded and non-polygon WKB should be rejected."""
+        with pytest.raises(ValueError, match="Truncated"):
+            wkt.parse_wkb(struct.pack("<BII", 1, wkt.WKB_POLYGON, 1) + struct.pack("<I", 2 ** 31))
+        with pytest.raises(ValueError, match="Trailing"):
+            wkt.parse_wkb(_wkb_polygon([RING]) + b"\x00")
+        with pytest.raises(ValueError, match="Unsupported WKB geometry type"):
+            wkt.parse_wkb(struct.pack("<BIdd", 1, 1, 0.0, 0.0))
+        with pytest.raises(ValueError, match="hex"):
+            wkt.parse_wkb_hex("not hex")
+
+    def test_geometry_text(self):
+        """WKT and hex WKB should be told apart."""
+        assert wkt.parse_geometry_text(" POLYGON ((0 0, 1 0, 1 1, 0 0))")[0]["type"] == "Polygon"
+        assert wkt.parse_geometry_text(_wkb_polygon([RING]).hex())[0]["type"] == "Polygon"